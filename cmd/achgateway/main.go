@@ -20,18 +20,57 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/moov-io/achgateway"
 	"github.com/moov-io/achgateway/internal"
+	"github.com/moov-io/achgateway/internal/cutoffs"
+	"github.com/moov-io/achgateway/internal/dev"
+	"github.com/moov-io/achgateway/internal/doctor"
+	"github.com/moov-io/achgateway/internal/fabricate"
+	"github.com/moov-io/achgateway/internal/inspect"
+	"github.com/moov-io/achgateway/internal/keys"
+	"github.com/moov-io/achgateway/internal/replay"
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/simulate"
 	"github.com/moov-io/base/log"
 )
 
+// commands are the subcommands run by `achgateway <name> ...` instead of the gateway.
+var commands = map[string]func(args []string) error{
+	"files":   runFiles,
+	"events":  runEvents,
+	"agent":   runAgent,
+	"shards":  runShards,
+	"keys":    runKeys,
+	"rdfi":    runRDFI,
+	"cutoffs": runCutoffs,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, exists := commands[os.Args[1]]; exists {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	if os.Getenv(internal.TenantsEnv) != "" {
+		runTenants()
+		return
+	}
+
 	env := &internal.Environment{
 		Logger: log.NewDefaultLogger().Set("app", log.String("achgateway")).Set("version", log.String(achgateway.Version)),
 	}
+	if len(os.Args) > 1 && (os.Args[1] == "--dev" || os.Args[1] == "-dev") {
+		stopDev := setupDev(env, os.Args[2:])
+		defer stopDev()
+	}
 
 	env, err := internal.NewEnvironment(env)
 	if err != nil {
@@ -51,3 +90,101 @@ func main() {
 
 	service.AwaitTermination(env.Logger, termListener)
 }
+
+// runTenants runs each tenant listed in TENANTS as its own gateway in this process.
+func runTenants() {
+	logger := log.NewDefaultLogger().Set("app", log.String("achgateway")).Set("version", log.String(achgateway.Version))
+
+	tenants, err := internal.LoadTenants(logger)
+	if err != nil {
+		logger.Fatal().LogErrorf("Error loading tenants: %v", err)
+		os.Exit(1)
+	}
+	envs, err := internal.NewTenantEnvironments(logger, tenants)
+	if err != nil {
+		logger.Fatal().LogErrorf("Error loading up environment: %v", err)
+		os.Exit(1)
+	}
+
+	termListener := service.NewTerminationListener()
+
+	for i := range envs {
+		env := envs[i]
+		defer env.Shutdown()
+
+		stopServers := env.RunServers(termListener)
+		defer stopServers()
+
+		if env.AdminServer != nil && env.ODFIFiles != nil {
+			env.ODFIFiles.RegisterRoutes(env.AdminServer)
+		}
+	}
+
+	service.AwaitTermination(logger, termListener)
+}
+
+// runFiles handles `achgateway files ...` which inspects pending and merged files locally.
+func runFiles(args []string) error {
+	return inspect.Run(args, os.Stdout, loadConfig(log.NewNopLogger()))
+}
+
+// runEvents handles `achgateway events replay ...` which publishes events again for handled files.
+func runEvents(args []string) error {
+	logger := commandLogger("events")
+	return replay.Run(args, os.Stdout, logger, loadConfig(logger))
+}
+
+// runAgent handles `achgateway agent doctor ...` which diagnoses connections to upload agents.
+func runAgent(args []string) error {
+	logger := commandLogger("agent")
+	return doctor.Run(args, os.Stdout, logger, loadConfig(logger))
+}
+
+// runShards handles `achgateway shards simulate ...` which runs sample files through a shard offline.
+func runShards(args []string) error {
+	logger := commandLogger("shards")
+	return simulate.Run(args, os.Stdout, logger, loadConfig(logger))
+}
+
+// runKeys handles `achgateway keys ...` which generates keys and re-encrypts storage after rotating them.
+func runKeys(args []string) error {
+	return keys.Run(args, os.Stdout, loadConfig(log.NewNopLogger()))
+}
+
+// runRDFI handles `achgateway rdfi return|correct ...` which fabricates bank responses for testing.
+func runRDFI(args []string) error {
+	logger := commandLogger("rdfi")
+	return fabricate.Run(args, os.Stdout, logger, loadConfig(logger))
+}
+
+// runCutoffs handles `achgateway cutoffs ...` which previews upcoming cutoff windows and ODFI scans.
+func runCutoffs(args []string) error {
+	return cutoffs.Run(args, os.Stdout, loadConfig(log.NewNopLogger()))
+}
+
+func commandLogger(command string) log.Logger {
+	return log.NewDefaultLogger().Set("app", log.String("achgateway")).Set("command", log.String(command))
+}
+
+// loadConfig defers reading the config until a subcommand needs it.
+func loadConfig(logger log.Logger) service.ConfigLoader {
+	return func() (*service.Config, error) {
+		return internal.LoadConfig(logger)
+	}
+}
+
+// setupDev configures env for `achgateway --dev` which runs with an embedded FTP server and seeded shard.
+func setupDev(env *internal.Environment, args []string) func() {
+	opts, err := dev.ParseFlags(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	cfg, stop, err := dev.Setup(env.Logger, opts)
+	if err != nil {
+		env.Logger.Fatal().LogErrorf("Error setting up dev mode: %v", err)
+		os.Exit(1)
+	}
+	env.Config = cfg
+	return stop
+}
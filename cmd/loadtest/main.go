@@ -0,0 +1,86 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/loadtest"
+	"github.com/moov-io/base/log"
+)
+
+var (
+	flagEndpoint      = flag.String("endpoint", "http://localhost:8484", "Base URL of achgateway's HTTP server")
+	flagAdminEndpoint = flag.String("admin", "http://localhost:9494", "Base URL of achgateway's admin server")
+	flagShards        = flag.String("shards", "testing", "Comma separated shardKeys with optional weights, e.g. testing=3,sandbox=1")
+	flagRate          = flag.Float64("rate", 10, "Files submitted per second")
+	flagEntries       = flag.Int("entries", 100, "EntryDetail records in each file")
+	flagDuration      = flag.Duration("duration", time.Minute, "How long to submit files for")
+	flagConcurrency   = flag.Int("concurrency", 10, "Submissions in flight at once")
+	flagTimeout       = flag.Duration("timeout", 30*time.Second, "Timeout for each HTTP request")
+	flagCutoff        = flag.String("cutoff", "", "Comma separated shard names to trigger a cutoff for after submitting files")
+)
+
+func main() {
+	flag.Parse()
+
+	logger := log.NewDefaultLogger().Set("app", log.String("loadtest"))
+
+	shards, err := loadtest.ParseShardSpread(*flagShards)
+	if err != nil {
+		logger.Fatal().LogErrorf("reading -shards: %v", err)
+		os.Exit(1)
+	}
+
+	client := &http.Client{
+		Timeout: *flagTimeout,
+	}
+	report, err := loadtest.Run(context.Background(), client, loadtest.Config{
+		Endpoint:       *flagEndpoint,
+		Shards:         shards,
+		FilesPerSecond: *flagRate,
+		EntriesPerFile: *flagEntries,
+		Duration:       *flagDuration,
+		Concurrency:    *flagConcurrency,
+	})
+	if err != nil {
+		logger.Fatal().LogErrorf("running load test: %v", err)
+		os.Exit(1)
+	}
+
+	if *flagCutoff != "" {
+		// Cutoffs can take much longer than a single submission
+		client.Timeout = 0
+
+		report.Cutoff, err = loadtest.TriggerCutoff(context.Background(), client, *flagAdminEndpoint, strings.Split(*flagCutoff, ","))
+		if err != nil {
+			logger.Error().LogErrorf("triggering cutoff: %v", err)
+		}
+	}
+
+	report.WriteTo(os.Stdout)
+
+	if report.Failed() > 0 {
+		os.Exit(1)
+	}
+}
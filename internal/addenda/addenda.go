@@ -0,0 +1,412 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package addenda formats and validates the government mandated layouts of CCD payment related
+// information: DED addenda of child support payments and TXP addenda of tax payments.
+package addenda
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moov-io/ach"
+)
+
+const (
+	ChildSupportFormat = "DED"
+	TaxPaymentFormat   = "TXP"
+)
+
+// ChildSupport is the DED addenda of a child support payment withheld by an employer
+type ChildSupport struct {
+	// CaseID is the case identifier from the income withholding order, up to 20 characters
+	CaseID string `json:"caseID"`
+
+	// PayDate is when the income was withheld, as YYMMDD
+	PayDate string `json:"payDate"`
+
+	// Amount is the payment in cents, which must be the entry's amount
+	Amount int `json:"amount"`
+
+	// SSN is the non-custodial parent's social security number
+	SSN string `json:"ssn"`
+
+	MedicalSupport bool `json:"medicalSupport"`
+
+	// Name is the non-custodial parent's last name, or the first 10 characters of it
+	Name string `json:"name"`
+
+	// FIPSCode identifies the state and county the order came from, when required
+	FIPSCode string `json:"fipsCode,omitempty"`
+
+	EmploymentTerminated bool `json:"employmentTerminated,omitempty"`
+}
+
+// String formats the addenda like DED*CS*A123*221014*25000*123456789*N*SMITH\
+func (cs ChildSupport) String() string {
+	elements := []string{
+		ChildSupportFormat, "CS", cs.CaseID, cs.PayDate, strconv.Itoa(cs.Amount), cs.SSN,
+		indicator(cs.MedicalSupport, "N"), cs.Name, cs.FIPSCode, indicator(cs.EmploymentTerminated, ""),
+	}
+	return segment(elements)
+}
+
+func (cs ChildSupport) Validate() error {
+	if cs.CaseID == "" || len(cs.CaseID) > 20 {
+		return fmt.Errorf("case identifier %q must be 1 to 20 characters", cs.CaseID)
+	}
+	if err := validDate("pay date", cs.PayDate); err != nil {
+		return err
+	}
+	if err := validAmount("payment amount", cs.Amount); err != nil {
+		return err
+	}
+	if len(cs.SSN) != 9 || !digits(cs.SSN) {
+		return errors.New("non-custodial parent SSN must be 9 digits")
+	}
+	if cs.Name == "" || len(cs.Name) > 10 {
+		return fmt.Errorf("non-custodial parent name %q must be 1 to 10 characters", cs.Name)
+	}
+	if len(cs.FIPSCode) > 7 {
+		return fmt.Errorf("FIPS code %q is longer than 7 characters", cs.FIPSCode)
+	}
+	return nil
+}
+
+// ParseChildSupport reads DED addenda
+func ParseChildSupport(info string) (*ChildSupport, error) {
+	elements, err := split(ChildSupportFormat, info, 8, 10)
+	if err != nil {
+		return nil, err
+	}
+	if elements[1] != "CS" {
+		return nil, fmt.Errorf("application identifier %q must be CS", elements[1])
+	}
+	amount, err := parseAmount("payment amount", elements[4])
+	if err != nil {
+		return nil, err
+	}
+	cs := &ChildSupport{
+		CaseID:  elements[2],
+		PayDate: elements[3],
+		Amount:  amount,
+		SSN:     elements[5],
+		Name:    elements[7],
+	}
+	if cs.MedicalSupport, err = parseIndicator("medical support indicator", elements[6], false); err != nil {
+		return nil, err
+	}
+	if len(elements) > 8 {
+		cs.FIPSCode = elements[8]
+	}
+	if len(elements) > 9 {
+		if cs.EmploymentTerminated, err = parseIndicator("employment termination indicator", elements[9], true); err != nil {
+			return nil, err
+		}
+	}
+	return cs, cs.Validate()
+}
+
+// TaxPayment is the TXP addenda of a federal or state tax payment
+type TaxPayment struct {
+	// TaxpayerID is the taxpayer's identification number, up to 15 characters
+	TaxpayerID string `json:"taxpayerID"`
+
+	// TaxType is the taxing authority's code for the tax being paid, like 94105
+	TaxType string `json:"taxType"`
+
+	// PeriodEnd is the last day of the tax period, as YYMMDD
+	PeriodEnd string `json:"periodEnd"`
+
+	// Amounts are up to three amounts which total the entry's amount
+	Amounts []TaxAmount `json:"amounts"`
+
+	// Verification is the taxpayer verification, when the taxing authority requires one
+	Verification string `json:"verification,omitempty"`
+}
+
+// TaxAmount is one amount of a TaxPayment
+type TaxAmount struct {
+	// Type is the amount type code, like T for tax, P for penalty or I for interest
+	Type string `json:"type"`
+
+	// Amount is in cents
+	Amount int `json:"amount"`
+}
+
+// String formats the addenda like TXP*123456789*94105*221231*T*100000\
+func (tp TaxPayment) String() string {
+	elements := make([]string, 10, 11)
+	elements[0] = TaxPaymentFormat
+	elements[1], elements[2], elements[3] = tp.TaxpayerID, tp.TaxType, tp.PeriodEnd
+	for i := 0; i < len(tp.Amounts) && i < 3; i++ {
+		elements[4+i*2] = tp.Amounts[i].Type
+		elements[5+i*2] = strconv.Itoa(tp.Amounts[i].Amount)
+	}
+	return segment(append(elements, tp.Verification))
+}
+
+// Total is the sum of the payment's amounts
+func (tp TaxPayment) Total() int {
+	total := 0
+	for i := range tp.Amounts {
+		total += tp.Amounts[i].Amount
+	}
+	return total
+}
+
+func (tp TaxPayment) Validate() error {
+	if tp.TaxpayerID == "" || len(tp.TaxpayerID) > 15 {
+		return fmt.Errorf("taxpayer identification number %q must be 1 to 15 characters", tp.TaxpayerID)
+	}
+	if tp.TaxType == "" || len(tp.TaxType) > 5 {
+		return fmt.Errorf("tax payment type code %q must be 1 to 5 characters", tp.TaxType)
+	}
+	if err := validDate("tax period end date", tp.PeriodEnd); err != nil {
+		return err
+	}
+	if len(tp.Amounts) == 0 || len(tp.Amounts) > 3 {
+		return fmt.Errorf("found %d amounts, expected 1 to 3", len(tp.Amounts))
+	}
+	for i := range tp.Amounts {
+		if len(tp.Amounts[i].Type) != 1 {
+			return fmt.Errorf("amount type %q must be 1 character", tp.Amounts[i].Type)
+		}
+		if err := validAmount("amount", tp.Amounts[i].Amount); err != nil {
+			return err
+		}
+	}
+	if len(tp.Verification) > 6 {
+		return fmt.Errorf("taxpayer verification %q is longer than 6 characters", tp.Verification)
+	}
+	return nil
+}
+
+// ParseTaxPayment reads TXP addenda
+func ParseTaxPayment(info string) (*TaxPayment, error) {
+	elements, err := split(TaxPaymentFormat, info, 6, 11)
+	if err != nil {
+		return nil, err
+	}
+	tp := &TaxPayment{
+		TaxpayerID: elements[1],
+		TaxType:    elements[2],
+		PeriodEnd:  elements[3],
+	}
+	for i := 4; i < len(elements) && i < 10; i += 2 {
+		value := ""
+		if i+1 < len(elements) {
+			value = elements[i+1]
+		}
+		if elements[i] == "" && value == "" {
+			continue
+		}
+		amount, err := parseAmount("amount", value)
+		if err != nil {
+			return nil, err
+		}
+		tp.Amounts = append(tp.Amounts, TaxAmount{Type: elements[i], Amount: amount})
+	}
+	if len(elements) == 11 {
+		tp.Verification = elements[10]
+	}
+	return tp, tp.Validate()
+}
+
+// Problem is an entry whose addenda doesn't follow its format
+type Problem struct {
+	BatchNumber int
+	TraceNumber string
+	Format      string
+	Err         error
+}
+
+func (p Problem) Error() string {
+	return fmt.Sprintf("entry trace number %s in batch %d: %s addenda: %v", p.TraceNumber, p.BatchNumber, p.Format, p.Err)
+}
+
+// Check returns each entry of file with addenda in one of formats which doesn't follow it.
+// Entries are checked when their addenda starts with the format's segment identifier.
+func Check(file *ach.File, formats []string) []Problem {
+	if file == nil || len(formats) == 0 {
+		return nil
+	}
+	var out []Problem
+	for _, batch := range file.Batches {
+		bh := batch.GetHeader()
+		for _, entry := range batch.GetEntries() {
+			var info strings.Builder
+			for _, addenda := range entry.Addenda05 {
+				info.WriteString(addenda.PaymentRelatedInformation)
+			}
+			format := detect(info.String())
+			if format == "" || !enabled(formats, format) {
+				continue
+			}
+			err := checkEntry(format, bh.StandardEntryClassCode, entry, strings.TrimSpace(info.String()))
+			if err != nil {
+				out = append(out, Problem{
+					BatchNumber: bh.BatchNumber,
+					TraceNumber: entry.TraceNumber,
+					Format:      format,
+					Err:         err,
+				})
+			}
+		}
+	}
+	return out
+}
+
+func checkEntry(format, secCode string, entry *ach.EntryDetail, info string) error {
+	if secCode != ach.CCD {
+		return fmt.Errorf("only CCD entries can have %s addenda, found %s", format, secCode)
+	}
+	if len(entry.Addenda05) > 1 {
+		return fmt.Errorf("found %d addenda records, expected 1", len(entry.Addenda05))
+	}
+	switch format {
+	case ChildSupportFormat:
+		cs, err := ParseChildSupport(info)
+		if err != nil {
+			return err
+		}
+		if cs.Amount != entry.Amount {
+			return fmt.Errorf("payment amount %d doesn't match entry amount %d", cs.Amount, entry.Amount)
+		}
+	case TaxPaymentFormat:
+		tp, err := ParseTaxPayment(info)
+		if err != nil {
+			return err
+		}
+		if total := tp.Total(); total != entry.Amount {
+			return fmt.Errorf("amounts total %d which doesn't match entry amount %d", total, entry.Amount)
+		}
+	}
+	return nil
+}
+
+// detect returns the format info is written in, or an empty string for other addenda. Addenda
+// starting with the segment identifier and any separator are detected so a wrong separator is
+// reported rather than skipped.
+func detect(info string) string {
+	info = strings.ToUpper(strings.TrimSpace(info))
+	for _, format := range []string{ChildSupportFormat, TaxPaymentFormat} {
+		if !strings.HasPrefix(info, format) {
+			continue
+		}
+		if len(info) == len(format) {
+			return format
+		}
+		next := info[len(format)]
+		if (next < 'A' || next > 'Z') && (next < '0' || next > '9') {
+			return format
+		}
+	}
+	return ""
+}
+
+// ValidFormat reports if format is one Check supports
+func ValidFormat(format string) bool {
+	return enabled([]string{ChildSupportFormat, TaxPaymentFormat}, format)
+}
+
+func enabled(formats []string, format string) bool {
+	for i := range formats {
+		if strings.EqualFold(strings.TrimSpace(formats[i]), format) {
+			return true
+		}
+	}
+	return false
+}
+
+// split separates the elements of an addenda segment ending with a backslash
+func split(id, info string, min, max int) ([]string, error) {
+	info = strings.TrimSpace(info)
+	if !strings.HasSuffix(info, `\`) {
+		return nil, errors.New(`missing \ segment terminator`)
+	}
+	elements := strings.Split(strings.TrimSuffix(info, `\`), "*")
+	if elements[0] != id {
+		return nil, fmt.Errorf("segment identifier %q must be %s followed by *", elements[0], id)
+	}
+	if len(elements) < min || len(elements) > max {
+		return nil, fmt.Errorf("found %d elements, expected %d to %d", len(elements)-1, min-1, max-1)
+	}
+	return elements, nil
+}
+
+// segment joins elements, dropping trailing empty elements, and adds the terminator
+func segment(elements []string) string {
+	for len(elements) > 0 && elements[len(elements)-1] == "" {
+		elements = elements[:len(elements)-1]
+	}
+	return strings.Join(elements, "*") + `\`
+}
+
+func indicator(value bool, no string) string {
+	if value {
+		return "Y"
+	}
+	return no
+}
+
+func parseIndicator(name, value string, optional bool) (bool, error) {
+	switch {
+	case value == "Y":
+		return true, nil
+	case value == "N", value == "" && optional:
+		return false, nil
+	}
+	return false, fmt.Errorf("%s %q must be Y or N", name, value)
+}
+
+func validDate(name, value string) error {
+	if _, err := time.Parse("060102", value); err != nil || len(value) != 6 {
+		return fmt.Errorf("%s %q must be YYMMDD", name, value)
+	}
+	return nil
+}
+
+func validAmount(name string, amount int) error {
+	if amount <= 0 || amount > 9999999999 {
+		return fmt.Errorf("%s %d must be 1 to 10 digits", name, amount)
+	}
+	return nil
+}
+
+func parseAmount(name, value string) (int, error) {
+	if value == "" || len(value) > 10 || !digits(value) {
+		return 0, fmt.Errorf("%s %q must be 1 to 10 digits of cents without a decimal point", name, value)
+	}
+	amount, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s %q: %v", name, value, err)
+	}
+	return amount, nil
+}
+
+func digits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
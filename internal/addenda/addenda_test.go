@@ -0,0 +1,157 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package addenda
+
+import (
+	"testing"
+
+	"github.com/moov-io/ach"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChildSupport(t *testing.T) {
+	cs := ChildSupport{
+		CaseID:  "A123456",
+		PayDate: "221014",
+		Amount:  25000,
+		SSN:     "123456789",
+		Name:    "SMITH",
+	}
+	require.NoError(t, cs.Validate())
+	require.Equal(t, `DED*CS*A123456*221014*25000*123456789*N*SMITH\`, cs.String())
+
+	cs.FIPSCode = "0601"
+	cs.EmploymentTerminated = true
+	require.Equal(t, `DED*CS*A123456*221014*25000*123456789*N*SMITH*0601*Y\`, cs.String())
+
+	parsed, err := ParseChildSupport(cs.String())
+	require.NoError(t, err)
+	require.Equal(t, cs, *parsed)
+
+	for info, msg := range map[string]string{
+		`DED*CS*A123456*221014*25000*123456789*N*SMITH`:       `missing \ segment terminator`,
+		`DED CS A123456 221014 25000 123456789 N SMITH\`:      `segment identifier "DED CS A123456 221014 25000 123456789 N SMITH" must be DED followed by *`,
+		`DED*CS*A123456*221014*25000*123456789*N\`:            "found 6 elements, expected 7 to 9",
+		`DED*XX*A123456*221014*25000*123456789*N*SMITH\`:      `application identifier "XX" must be CS`,
+		`DED*CS*A123456*10/14/22*25000*123456789*N*SMITH\`:    `pay date "10/14/22" must be YYMMDD`,
+		`DED*CS*A123456*221014*250.00*123456789*N*SMITH\`:     `payment amount "250.00" must be 1 to 10 digits of cents`,
+		`DED*CS*A123456*221014*25000*123-45-6789*N*SMITH\`:    "non-custodial parent SSN must be 9 digits",
+		`DED*CS*A123456*221014*25000*123456789*NO*SMITH\`:     `medical support indicator "NO" must be Y or N`,
+		`DED*CS*A123456*221014*25000*123456789*N*JOHNSTONES\`: "",
+	} {
+		_, err := ParseChildSupport(info)
+		if msg == "" {
+			require.NoError(t, err, info)
+		} else {
+			require.ErrorContains(t, err, msg, info)
+		}
+	}
+}
+
+func TestTaxPayment(t *testing.T) {
+	tp := TaxPayment{
+		TaxpayerID: "123456789",
+		TaxType:    "94105",
+		PeriodEnd:  "221231",
+		Amounts:    []TaxAmount{{Type: "T", Amount: 100000}},
+	}
+	require.NoError(t, tp.Validate())
+	require.Equal(t, `TXP*123456789*94105*221231*T*100000\`, tp.String())
+
+	parsed, err := ParseTaxPayment(tp.String())
+	require.NoError(t, err)
+	require.Equal(t, tp, *parsed)
+
+	// The verification comes after all three amounts
+	tp.Amounts = append(tp.Amounts, TaxAmount{Type: "I", Amount: 1500})
+	tp.Verification = "1234"
+	require.Equal(t, `TXP*123456789*94105*221231*T*100000*I*1500***1234\`, tp.String())
+	require.Equal(t, 101500, tp.Total())
+
+	parsed, err = ParseTaxPayment(tp.String())
+	require.NoError(t, err)
+	require.Equal(t, tp, *parsed)
+
+	for info, msg := range map[string]string{
+		`TXP*123456789*94105*221231*T*1000.00\`:  `amount "1000.00" must be 1 to 10 digits of cents`,
+		`TXP*123456789*94105*221231*T*100000*P\`: `amount "" must be 1 to 10 digits`,
+		`TXP*123456789*94105*20221231*T*100000\`: `tax period end date "20221231" must be YYMMDD`,
+		`TXP*123456789*941050*221231*T*100000\`:  `tax payment type code "941050" must be 1 to 5 characters`,
+		`TXP*123456789*94105*221231*TX*100000\`:  `amount type "TX" must be 1 character`,
+		`TXP*123456789*94105*221231\`:            "found 3 elements, expected 5 to 10",
+	} {
+		_, err := ParseTaxPayment(info)
+		require.ErrorContains(t, err, msg, info)
+	}
+}
+
+func testFile(t *testing.T, secCode string, amount int, info string) *ach.File {
+	t.Helper()
+
+	bh := ach.NewBatchHeader()
+	bh.StandardEntryClassCode = secCode
+	bh.BatchNumber = 1
+	batch, err := ach.NewBatch(bh)
+	require.NoError(t, err)
+
+	entry := ach.NewEntryDetail()
+	entry.Amount = amount
+	entry.TraceNumber = "121042880000001"
+	if info != "" {
+		addenda := ach.NewAddenda05()
+		addenda.PaymentRelatedInformation = info
+		entry.AddAddenda05(addenda)
+	}
+	batch.AddEntry(entry)
+
+	file := ach.NewFile()
+	file.AddBatch(batch)
+	return file
+}
+
+func TestCheck(t *testing.T) {
+	formats := []string{"ded", "TXP"}
+	ded := `DED*CS*A123456*221014*25000*123456789*N*SMITH\`
+
+	require.Empty(t, Check(testFile(t, ach.CCD, 25000, ded), formats))
+	require.Empty(t, Check(testFile(t, ach.CCD, 25000, "INVOICE 1234"), formats))
+	require.Empty(t, Check(testFile(t, ach.CCD, 25000, "DEDUCTIONS FOR OCTOBER"), formats))
+
+	// Formats which aren't enabled are skipped
+	require.Empty(t, Check(testFile(t, ach.CCD, 100, ded), []string{"TXP"}))
+
+	problems := Check(testFile(t, ach.CCD, 100, ded), formats)
+	require.Len(t, problems, 1)
+	require.Equal(t, "121042880000001", problems[0].TraceNumber)
+	require.Equal(t, 1, problems[0].BatchNumber)
+	require.Equal(t, "entry trace number 121042880000001 in batch 1: DED addenda: payment amount 25000 doesn't match entry amount 100", problems[0].Error())
+
+	problems = Check(testFile(t, ach.PPD, 25000, ded), formats)
+	require.Len(t, problems, 1)
+	require.ErrorContains(t, problems[0].Err, "only CCD entries can have DED addenda, found PPD")
+
+	problems = Check(testFile(t, ach.CCD, 25000, `DED-CS-A123456\`), formats)
+	require.Len(t, problems, 1)
+	require.ErrorContains(t, problems[0].Err, "must be DED followed by *")
+
+	problems = Check(testFile(t, ach.CCD, 1500, `TXP*123456789*94105*221231*T*1000*I*400\`), formats)
+	require.Len(t, problems, 1)
+	require.Equal(t, TaxPaymentFormat, problems[0].Format)
+	require.ErrorContains(t, problems[0].Err, "amounts total 1400 which doesn't match entry amount 1500")
+}
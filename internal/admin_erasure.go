@@ -0,0 +1,96 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/moov-io/achgateway/internal/erasure"
+	"github.com/moov-io/achgateway/internal/mask"
+
+	"github.com/moov-io/base/log"
+)
+
+func (env *Environment) registerErasureRoute() {
+	if env.Eraser == nil {
+		return
+	}
+	env.AdminServer.AddHandler("/erasure", env.erasureRouteHandler())
+}
+
+// erasureRouteHandler redacts a receiver's data on POST and responds with the signed report.
+// Requests which change data wait for a second operator when approvals are configured, in which
+// case the report is only saved to the Reports audit trail.
+func (env *Environment) erasureRouteHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := env.Logger.With(log.Fields{
+			"route": log.String("erasure"),
+		})
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req erasure.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("reading request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !req.DryRun {
+			desc := fmt.Sprintf("erase data of %s account %s (auditTrail=%v)", req.RoutingNumber, mask.AccountNumber(req.AccountNumber), req.AuditTrail)
+			requested := env.FileReceiver.RequestApproval(w, r, "erasure", desc, func() error {
+				report, err := env.Eraser.Erase(req)
+				if report != nil {
+					logger.Info().Logf("erasure %s changed %d files", report.ID, len(report.Files))
+				}
+				return err
+			})
+			if requested {
+				return
+			}
+		}
+
+		report, err := env.Eraser.Erase(req)
+		if err != nil {
+			logger.Error().LogErrorf("problem erasing receiver data: %v", err)
+			if report == nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		logger.Info().Logf("erasure %s found %d files (dryRun=%v)", report.ID, len(report.Files), report.DryRun)
+
+		bs, _ := erasure.MarshalReport(report)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err != nil {
+			// The report lists what was changed before the error
+			w.WriteHeader(http.StatusInternalServerError)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		w.Write(bs)
+	}
+}
@@ -0,0 +1,93 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/erasure"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminErasure(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "erasure.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	odfiDir := t.TempDir()
+	bs, err := os.ReadFile(filepath.Join("..", "testdata", "return-WEB.ach"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(odfiDir, "return-WEB.ach"), bs, 0600))
+
+	cfg := &service.Config{
+		Inbound: service.Inbound{
+			ODFI: &service.ODFIFiles{
+				Storage: service.ODFIStorage{Directory: odfiDir},
+			},
+		},
+		Erasure: &service.ErasureConfig{
+			SigningKeyFile: keyFile,
+		},
+	}
+	eraser, err := erasure.New(log.NewTestLogger(), cfg, nil)
+	require.NoError(t, err)
+
+	env := &Environment{
+		Logger: log.NewTestLogger(),
+		Config: cfg,
+		Eraser: eraser,
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/erasure", strings.NewReader(`{"routingNumber": "091000019", "accountNumber": "123456789"}`))
+	env.erasureRouteHandler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report erasure.Report
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	require.Len(t, report.Files, 1)
+	require.Equal(t, erasure.ActionRedacted, report.Files[0].Action)
+	require.NoError(t, erasure.Verify(report, public))
+
+	// Invalid requests are rejected
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/erasure", strings.NewReader(`{"routingNumber": "0910"}`))
+	env.erasureRouteHandler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/erasure", nil)
+	env.erasureRouteHandler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
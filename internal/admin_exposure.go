@@ -0,0 +1,91 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/exposure"
+
+	"github.com/moov-io/base/log"
+)
+
+func (env *Environment) registerExposureRoute() {
+	if env.Config.Exposure == nil {
+		return
+	}
+	env.AdminServer.AddHandler("/exposure", env.exposureRouteHandler())
+}
+
+type exposureResponse struct {
+	Positions []exposure.Position `json:"positions"`
+}
+
+// exposureRouteHandler reports each ODFI's daily position between the "from" and "to" days
+// (YYYY-MM-DD, both default to today) optionally limited to one "odfi".
+func (env *Environment) exposureRouteHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := env.Logger.With(log.Fields{
+			"route": log.String("exposure"),
+		})
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		repo := exposure.NewRepository(env.DB)
+		if repo == nil {
+			logger.Warn().Log("exposure requires a database")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		query := r.URL.Query()
+		today := time.Now().Format("2006-01-02")
+		from, to := query.Get("from"), query.Get("to")
+		if from == "" {
+			from = today
+		}
+		if to == "" {
+			to = today
+		}
+		for _, day := range []string{from, to} {
+			if _, err := time.Parse("2006-01-02", day); err != nil {
+				http.Error(w, fmt.Sprintf("invalid day %q", day), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var resp exposureResponse
+		var err error
+		resp.Positions, err = repo.Positions(from, to, query.Get("odfi"), exposure.Limits(env.Config.Exposure.Limits))
+		if err != nil {
+			logger.Error().LogErrorf("problem reading exposure: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,72 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/exposure"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminExposure(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	env := &Environment{
+		Logger: log.NewTestLogger(),
+		DB:     db.DB,
+		Config: &service.Config{
+			Exposure: &service.ExposureConfig{
+				Limits: map[string]int{"121042882": 1000},
+			},
+		},
+	}
+
+	repo := exposure.NewRepository(db.DB)
+	when := time.Date(2022, time.August, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.Record("testing", exposure.KindUploaded, map[string]exposure.Totals{
+		"12104288": {Credits: 1500},
+	}, when))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/exposure?from=2022-08-01&to=2022-08-01", nil)
+	env.exposureRouteHandler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp exposureResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Positions, 1)
+	require.Equal(t, 1500, resp.Positions[0].NetExposure)
+	require.Equal(t, 1000, resp.Positions[0].Limit)
+	require.True(t, resp.Positions[0].OverLimit)
+
+	// Invalid days are rejected
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/exposure?from=08-01-2022", nil)
+	env.exposureRouteHandler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
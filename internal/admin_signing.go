@@ -0,0 +1,139 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/moov-io/achgateway/internal/signing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+)
+
+func (env *Environment) registerSigningKeysRoutes() {
+	if env.SigningKeys == nil {
+		return
+	}
+	env.AdminServer.AddHandler("/signing-keys", env.signingKeysHandler())
+
+	sub := env.AdminServer.Subrouter("/signing-keys")
+	sub.HandleFunc("/rotate", env.rotateSigningKeyHandler())
+	sub.HandleFunc("/{keyID}", env.revokeSigningKeyHandler())
+}
+
+type signingKeysResponse struct {
+	Keys []signing.Key `json:"keys"`
+}
+
+// signingKeysHandler lists keys without their secrets on GET and creates a key which signs
+// alongside the others on POST. The new key's secret is only included in the POST response.
+func (env *Environment) signingKeysHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := env.Logger.With(log.Fields{
+			"route": log.String("signing-keys"),
+		})
+
+		switch r.Method {
+		case http.MethodGet:
+			keys, err := env.SigningKeys.List()
+			if err != nil {
+				logger.Error().LogErrorf("problem listing signing keys: %v", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if keys == nil {
+				keys = []signing.Key{}
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(signingKeysResponse{Keys: keys})
+
+		case http.MethodPost:
+			key, err := env.SigningKeys.Create()
+			if err != nil {
+				logger.Error().LogErrorf("problem creating signing key: %v", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			logger.Info().Logf("created signing key %s", key.ID)
+			writeSigningKey(w, key)
+
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// rotateSigningKeyHandler creates a key and expires the others after Signing.RotationGracePeriod
+func (env *Environment) rotateSigningKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := env.Logger.With(log.Fields{
+			"route": log.String("signing-keys"),
+		})
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		key, err := env.SigningKeys.Rotate()
+		if err != nil {
+			logger.Error().LogErrorf("problem rotating signing keys: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		logger.Info().Logf("rotated signing keys to %s", key.ID)
+		writeSigningKey(w, key)
+	}
+}
+
+// revokeSigningKeyHandler stops a key from signing events on DELETE
+func (env *Environment) revokeSigningKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keyID := mux.Vars(r)["keyID"]
+		logger := env.Logger.With(log.Fields{
+			"route":  log.String("signing-keys"),
+			"key_id": log.String(keyID),
+		})
+
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		err := env.SigningKeys.Revoke(keyID)
+		if errors.Is(err, signing.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error().LogErrorf("problem revoking signing key: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		logger.Info().Log("revoked signing key")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func writeSigningKey(w http.ResponseWriter, key *signing.Key) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
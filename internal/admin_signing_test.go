@@ -0,0 +1,87 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/signing"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminSigningKeys(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	env := &Environment{
+		Logger:      log.NewTestLogger(),
+		SigningKeys: signing.NewKeyring(signing.NewRepository(db.DB), &service.EventsSigning{}),
+	}
+
+	w := httptest.NewRecorder()
+	env.signingKeysHandler().ServeHTTP(w, httptest.NewRequest("POST", "/signing-keys", nil))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created signing.Key
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	require.NotEmpty(t, created.ID)
+	require.NotEmpty(t, created.Secret)
+
+	w = httptest.NewRecorder()
+	env.rotateSigningKeyHandler().ServeHTTP(w, httptest.NewRequest("POST", "/signing-keys/rotate", nil))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var rotated signing.Key
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&rotated))
+	require.NotEqual(t, created.ID, rotated.ID)
+
+	// Revoke the rotated key
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/signing-keys/"+rotated.ID, nil), map[string]string{"keyID": rotated.ID})
+	w = httptest.NewRecorder()
+	env.revokeSigningKeyHandler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req = mux.SetURLVars(httptest.NewRequest("DELETE", "/signing-keys/missing", nil), map[string]string{"keyID": "missing"})
+	w = httptest.NewRecorder()
+	env.revokeSigningKeyHandler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// Secrets aren't listed
+	w = httptest.NewRecorder()
+	env.signingKeysHandler().ServeHTTP(w, httptest.NewRequest("GET", "/signing-keys", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp signingKeysResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Keys, 2)
+	statuses := map[string]signing.Status{}
+	for _, key := range resp.Keys {
+		require.Empty(t, key.Secret)
+		statuses[key.ID] = key.Status
+	}
+	require.Equal(t, signing.StatusExpiring, statuses[created.ID])
+	require.Equal(t, signing.StatusRevoked, statuses[rotated.ID])
+}
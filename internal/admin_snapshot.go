@@ -0,0 +1,89 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/snapshot"
+
+	"github.com/moov-io/base/log"
+)
+
+func (env *Environment) registerSnapshotRoute() {
+	env.AdminServer.AddHandler("/snapshot", env.snapshotRouteHandler())
+}
+
+// snapshotRouteHandler exports the gateway's state on GET and imports a snapshot on PUT. Files
+// aren't accepted and cutoffs don't start while either runs.
+func (env *Environment) snapshotRouteHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := env.Logger.With(log.Fields{
+			"route": log.String("snapshot"),
+		})
+
+		switch r.Method {
+		case http.MethodGet:
+			opts := snapshot.Options{
+				Contents: r.URL.Query().Get("contents") == "true",
+			}
+
+			unfreeze := env.FileReceiver.Freeze()
+			defer unfreeze()
+
+			filename := fmt.Sprintf("achgateway-snapshot-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+			manifest, err := snapshot.Export(w, env.DB, env.FileReceiver.SnapshotShards(), opts)
+			if err != nil {
+				// The archive has already been partially written, so the client sees a truncated body
+				logger.Error().LogErrorf("exporting snapshot: %v", err)
+				return
+			}
+			logger.Info().Logf("exported snapshot of %d shards (contents=%v)", len(manifest.Shards), opts.Contents)
+
+		case http.MethodPut:
+			unfreeze := env.FileReceiver.Freeze()
+			defer unfreeze()
+
+			result, err := snapshot.Import(r.Body, env.DB, env.FileReceiver.SnapshotShards())
+			if err != nil {
+				logger.Error().LogErrorf("importing snapshot: %v", err)
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error":  err.Error(),
+					"result": result,
+				})
+				return
+			}
+			logger.Info().Logf("imported snapshot with %d files", result.Files)
+
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(result)
+
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
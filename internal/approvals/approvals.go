@@ -0,0 +1,225 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package approvals
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/audittrail"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+)
+
+var (
+	ErrUnauthorized = errors.New("missing or unknown operator token")
+	ErrForbidden    = errors.New("operator is not allowed to perform this action")
+	ErrNotFound     = errors.New("approval not found")
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusFailed   = "failed"
+	StatusExpired  = "expired"
+)
+
+// Approval is a sensitive admin action waiting on (or completed with) a second operator's confirmation.
+type Approval struct {
+	ID          string     `json:"id"`
+	Action      string     `json:"action"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	RequestedBy string     `json:"requestedBy"`
+	RequestedAt time.Time  `json:"requestedAt"`
+	ApprovedBy  string     `json:"approvedBy,omitempty"`
+	ApprovedAt  *time.Time `json:"approvedAt,omitempty"`
+	Error       string     `json:"error,omitempty"`
+
+	execute func() error
+}
+
+// Service holds pending approvals in memory. Requests are only approvable on the instance which received them.
+type Service struct {
+	logger log.Logger
+	cfg    service.Approvals
+	audit  audittrail.Storage
+
+	mu      sync.Mutex
+	pending map[string]*Approval
+
+	now func() time.Time
+}
+
+// New returns a Service for the config. A nil Service is returned when approvals are not configured,
+// in which case sensitive actions run immediately.
+func New(logger log.Logger, cfg *service.Approvals) (*Service, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	audit, err := audittrail.NewStorage(cfg.Audit)
+	if err != nil {
+		return nil, fmt.Errorf("approvals: %v", err)
+	}
+	return &Service{
+		logger:  logger,
+		cfg:     *cfg,
+		audit:   audit,
+		pending: make(map[string]*Approval),
+		now:     time.Now,
+	}, nil
+}
+
+// Authenticate finds the operator for the request's bearer token.
+func (s *Service) Authenticate(r *http.Request) (*service.Operator, error) {
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if token == "" {
+		return nil, ErrUnauthorized
+	}
+	for i := range s.cfg.Operators {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Operators[i].Token)) == 1 {
+			return &s.cfg.Operators[i], nil
+		}
+	}
+	return nil, ErrUnauthorized
+}
+
+// Request records a sensitive action which will run once a different operator approves it.
+func (s *Service) Request(op *service.Operator, action, description string, execute func() error) (*Approval, error) {
+	if op == nil || !op.HasRole(service.RoleRequester) {
+		return nil, ErrForbidden
+	}
+
+	approval := &Approval{
+		ID:          base.ID(),
+		Action:      action,
+		Description: description,
+		Status:      StatusPending,
+		RequestedBy: op.Name,
+		RequestedAt: s.now(),
+		execute:     execute,
+	}
+
+	s.mu.Lock()
+	s.expire()
+	s.pending[approval.ID] = approval
+	s.mu.Unlock()
+
+	s.record(*approval)
+	return approval, nil
+}
+
+// Approve runs a pending action. The approving operator must differ from the requester.
+func (s *Service) Approve(op *service.Operator, id string) (*Approval, error) {
+	if op == nil || !op.HasRole(service.RoleApprover) {
+		return nil, ErrForbidden
+	}
+
+	s.mu.Lock()
+	s.expire()
+	approval, exists := s.pending[id]
+	if !exists {
+		s.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if strings.EqualFold(approval.RequestedBy, op.Name) {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s requested this action", ErrForbidden, op.Name)
+	}
+	delete(s.pending, id)
+	s.mu.Unlock()
+
+	when := s.now()
+	approval.ApprovedBy = op.Name
+	approval.ApprovedAt = &when
+	approval.Status = StatusApproved
+	if err := approval.execute(); err != nil {
+		approval.Status = StatusFailed
+		approval.Error = err.Error()
+	}
+
+	s.record(*approval)
+	return approval, nil
+}
+
+// Pending returns each action awaiting approval, oldest first.
+func (s *Service) Pending() []Approval {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire()
+
+	out := make([]Approval, 0, len(s.pending))
+	for _, approval := range s.pending {
+		out = append(out, *approval)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].RequestedAt.Before(out[j].RequestedAt)
+	})
+	return out
+}
+
+// expire removes requests which have waited too long. The caller must hold s.mu.
+func (s *Service) expire() {
+	cutoff := s.now().Add(-1 * s.cfg.ExpiresAfter())
+	for id, approval := range s.pending {
+		if approval.RequestedAt.Before(cutoff) {
+			delete(s.pending, id)
+
+			approval.Status = StatusExpired
+			s.record(*approval)
+		}
+	}
+}
+
+func (s *Service) record(approval Approval) {
+	logger := s.logger.With(log.Fields{
+		"approvalID":  log.String(approval.ID),
+		"action":      log.String(approval.Action),
+		"status":      log.String(approval.Status),
+		"requestedBy": log.String(approval.RequestedBy),
+		"approvedBy":  log.String(approval.ApprovedBy),
+	})
+	logger.Info().Logf("admin approval %s: %s", approval.Status, approval.Description)
+
+	bs, err := json.Marshal(approval)
+	if err != nil {
+		logger.Error().LogErrorf("encoding approval audit record: %v", err)
+		return
+	}
+	path := fmt.Sprintf("approvals/%s/%s-%s.json", approval.RequestedAt.Format("2006-01-02"), approval.ID, approval.Status)
+	if err := s.audit.SaveFile(path, bs); err != nil {
+		logger.Error().LogErrorf("saving approval audit record: %v", err)
+	}
+}
+
+// Close releases the audit storage.
+func (s *Service) Close() error {
+	if s == nil || s.audit == nil {
+		return nil
+	}
+	return s.audit.Close()
+}
@@ -0,0 +1,124 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package approvals
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func setupService(t *testing.T) *Service {
+	t.Helper()
+
+	svc, err := New(log.NewNopLogger(), &service.Approvals{
+		Operators: []service.Operator{
+			{Name: "alice", Token: "alice-token", Roles: []string{"requester"}},
+			{Name: "bob", Token: "bob-token", Roles: []string{"requester", "approver"}},
+		},
+	})
+	require.NoError(t, err)
+	return svc
+}
+
+func TestApprovals__Nil(t *testing.T) {
+	svc, err := New(log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.Nil(t, svc)
+	require.NoError(t, svc.Close())
+}
+
+func TestApprovals__Authenticate(t *testing.T) {
+	svc := setupService(t)
+
+	req := httptest.NewRequest("GET", "/approvals", nil)
+	_, err := svc.Authenticate(req)
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	req.Header.Set("Authorization", "Bearer wrong")
+	_, err = svc.Authenticate(req)
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	req.Header.Set("Authorization", "Bearer bob-token")
+	op, err := svc.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, "bob", op.Name)
+}
+
+func TestApprovals__DualControl(t *testing.T) {
+	svc := setupService(t)
+	alice, bob := &svc.cfg.Operators[0], &svc.cfg.Operators[1]
+
+	var executed int
+	approval, err := svc.Request(alice, "trigger-cutoff", "testing", func() error {
+		executed++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, svc.Pending(), 1)
+
+	// alice isn't an approver, and bob can't approve his own requests
+	_, err = svc.Approve(alice, approval.ID)
+	require.ErrorIs(t, err, ErrForbidden)
+
+	other, err := svc.Request(bob, "trigger-cutoff", "testing", func() error { return nil })
+	require.NoError(t, err)
+	_, err = svc.Approve(bob, other.ID)
+	require.ErrorIs(t, err, ErrForbidden)
+
+	approved, err := svc.Approve(bob, approval.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusApproved, approved.Status)
+	require.Equal(t, "bob", approved.ApprovedBy)
+	require.Equal(t, 1, executed)
+
+	// Approvals only run once
+	_, err = svc.Approve(bob, approval.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Equal(t, 1, executed)
+}
+
+func TestApprovals__FailedAndExpired(t *testing.T) {
+	svc := setupService(t)
+	alice, bob := &svc.cfg.Operators[0], &svc.cfg.Operators[1]
+
+	approval, err := svc.Request(alice, "trigger-cutoff", "testing", func() error {
+		return errors.New("bad thing")
+	})
+	require.NoError(t, err)
+
+	approved, err := svc.Approve(bob, approval.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusFailed, approved.Status)
+	require.Equal(t, "bad thing", approved.Error)
+
+	approval, err = svc.Request(alice, "trigger-cutoff", "testing", func() error { return nil })
+	require.NoError(t, err)
+
+	svc.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	require.Empty(t, svc.Pending())
+
+	_, err = svc.Approve(bob, approval.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,261 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package as2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testCertificate(t *testing.T, name string) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestCMS__SignVerify(t *testing.T) {
+	cert, key := testCertificate(t, "signer")
+	other, _ := testCertificate(t, "other")
+	content := []byte("101 121042882 2313801041812180000A094101Federal Reserve Bank   My Bank Name")
+
+	for _, digest := range []string{SHA1, SHA256, SHA384, SHA512} {
+		t.Run(digest, func(t *testing.T) {
+			signature, err := SignDetached(content, cert, key, digest)
+			require.NoError(t, err)
+
+			used, err := VerifyDetached(signature, content, cert)
+			require.NoError(t, err)
+			require.Equal(t, digest, used)
+
+			_, err = VerifyDetached(signature, append([]byte("x"), content...), cert)
+			require.ErrorContains(t, err, "message digest does not match")
+
+			_, err = VerifyDetached(signature, content, other)
+			require.ErrorContains(t, err, "not signed by")
+		})
+	}
+
+	_, err := SignDetached(content, cert, key, "md5")
+	require.ErrorContains(t, err, "unsupported digest algorithm")
+}
+
+func TestCMS__EncryptDecrypt(t *testing.T) {
+	cert, key := testCertificate(t, "recipient")
+	other, otherKey := testCertificate(t, "other")
+	content := bytes.Repeat([]byte("9000001000001000000010012104288000000000000000000000100"), 20)
+
+	for _, alg := range []string{AES128CBC, AES192CBC, AES256CBC, DESEDE3CBC} {
+		t.Run(alg, func(t *testing.T) {
+			encrypted, err := Encrypt(content, cert, alg)
+			require.NoError(t, err)
+			require.NotContains(t, string(encrypted), string(content[:20]))
+
+			decrypted, used, err := Decrypt(encrypted, cert, key)
+			require.NoError(t, err)
+			require.Equal(t, alg, used)
+			require.Equal(t, content, decrypted)
+
+			_, _, err = Decrypt(encrypted, other, otherKey)
+			require.ErrorContains(t, err, "not encrypted for")
+
+			// The wrong key decrypts with a random content key instead of failing on its own
+			decrypted, _, err = Decrypt(encrypted, cert, otherKey)
+			if err != nil {
+				require.ErrorIs(t, err, ErrDecryption)
+			}
+			require.NotEqual(t, content, decrypted)
+		})
+	}
+}
+
+func TestCMS__unpad(t *testing.T) {
+	data := append(bytes.Repeat([]byte("a"), 13), 3, 3, 3)
+	out, err := unpad(data, 16)
+	require.NoError(t, err)
+	require.Equal(t, bytes.Repeat([]byte("a"), 13), out)
+
+	for _, last := range [][]byte{{0}, {17}, {2, 3, 3}} {
+		data := append(bytes.Repeat([]byte("a"), 16-len(last)), last...)
+		_, err := unpad(data, 16)
+		require.ErrorIs(t, err, ErrDecryption)
+	}
+}
+
+func testPartners(t *testing.T) (*Partner, *Partner) {
+	t.Helper()
+
+	ourCert, ourKey := testCertificate(t, "achgateway")
+	theirCert, theirKey := testCertificate(t, "partner")
+
+	ours := &Partner{
+		AS2From:             "ACHGATEWAY",
+		AS2To:               "ODFI BANK",
+		Certificate:         ourCert,
+		Key:                 ourKey,
+		PartnerCertificate:  theirCert,
+		SigningAlgorithm:    SHA256,
+		EncryptionAlgorithm: AES256CBC,
+	}
+	theirs := &Partner{
+		AS2From:             "ODFI BANK",
+		AS2To:               "ACHGATEWAY",
+		Certificate:         theirCert,
+		Key:                 theirKey,
+		PartnerCertificate:  ourCert,
+		SigningAlgorithm:    SHA256,
+		EncryptionAlgorithm: AES256CBC,
+	}
+	return ours, theirs
+}
+
+func TestMessage__RoundTrip(t *testing.T) {
+	payload := []byte("101 121042882 2313801041812180000A094101Federal Reserve Bank   My Bank Name\n")
+
+	cases := []struct {
+		name       string
+		signing    string
+		encryption string
+	}{
+		{name: "signed and encrypted", signing: SHA256, encryption: AES256CBC},
+		{name: "signed", signing: SHA1},
+		{name: "encrypted", encryption: DESEDE3CBC},
+		{name: "plain"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ours, theirs := testPartners(t)
+			ours.SigningAlgorithm, theirs.SigningAlgorithm = tc.signing, tc.signing
+			ours.EncryptionAlgorithm, theirs.EncryptionAlgorithm = tc.encryption, tc.encryption
+
+			out, err := ours.NewMessage("20220101-0001.ach", payload, ReceiptOptions{Signed: true})
+			require.NoError(t, err)
+			require.Equal(t, `"ODFI BANK"`, out.Header.Get("AS2-To"))
+			require.Equal(t, out.MessageID, out.Header.Get("Message-ID"))
+
+			msg, err := theirs.ReadMessage(out.Header, out.Body)
+			require.NoError(t, err)
+			require.Equal(t, payload, msg.Payload)
+			require.Equal(t, "20220101-0001.ach", msg.Filename)
+			require.Equal(t, "ACHGATEWAY", msg.AS2From)
+			require.Equal(t, tc.signing != "", msg.Signed)
+			require.Equal(t, tc.encryption != "", msg.Encrypted)
+			require.Equal(t, out.MIC, msg.MIC)
+			require.True(t, msg.Receipt.Signed)
+
+			header, body, err := theirs.NewMDN(msg, nil)
+			require.NoError(t, err)
+			require.Equal(t, "ACHGATEWAY", header.Get("AS2-To"))
+
+			mdn, err := ours.ReadMDN(header, body, true)
+			require.NoError(t, err)
+			require.True(t, mdn.Signed)
+			require.NoError(t, mdn.Err())
+			require.Equal(t, out.MessageID, mdn.OriginalMessageID)
+			require.True(t, mdn.MatchesMIC(out.MIC))
+		})
+	}
+}
+
+func TestMessage__Failures(t *testing.T) {
+	ours, theirs := testPartners(t)
+	payload := []byte("101 121042882 2313801041812180000A094101Federal Reserve Bank   My Bank Name\n")
+
+	// Partner requires encryption
+	ours.EncryptionAlgorithm = ""
+	out, err := ours.NewMessage("a.ach", payload, ReceiptOptions{})
+	require.NoError(t, err)
+
+	msg, err := theirs.ReadMessage(out.Header, out.Body)
+	var dispErr *DispositionError
+	require.True(t, errors.As(err, &dispErr))
+	require.Equal(t, "insufficient-message-security", dispErr.Description)
+
+	header, body, err := theirs.NewMDN(msg, err)
+	require.NoError(t, err)
+	mdn, err := ours.ReadMDN(header, body, false)
+	require.NoError(t, err)
+	require.False(t, mdn.Signed)
+	require.ErrorContains(t, mdn.Err(), "processed/error: insufficient-message-security")
+
+	_, err = ours.ReadMDN(header, body, true)
+	require.ErrorContains(t, err, "MDN is not signed")
+
+	// Unknown trading partner
+	ours.EncryptionAlgorithm = AES256CBC
+	ours.AS2From = "SOMEONE ELSE"
+	out, err = ours.NewMessage("a.ach", payload, ReceiptOptions{})
+	require.NoError(t, err)
+	_, err = theirs.ReadMessage(out.Header, out.Body)
+	require.True(t, errors.As(err, &dispErr))
+	require.Equal(t, "authentication-failed", dispErr.Description)
+
+	// Tampered signature
+	ours.AS2From = "ACHGATEWAY"
+	ours.EncryptionAlgorithm, theirs.EncryptionAlgorithm = "", ""
+	out, err = ours.NewMessage("a.ach", payload, ReceiptOptions{})
+	require.NoError(t, err)
+	out.Body = bytes.Replace(out.Body, []byte("Federal Reserve"), []byte("Federal Reverse"), 1)
+	_, err = theirs.ReadMessage(out.Header, out.Body)
+	require.True(t, errors.As(err, &dispErr))
+	require.Equal(t, "authentication-failed", dispErr.Description)
+}
+
+func TestMDN__MatchesMIC(t *testing.T) {
+	mdn := &MDN{MIC: "abc123=, SHA256"}
+	require.True(t, mdn.MatchesMIC("abc123=, sha-256"))
+	require.False(t, mdn.MatchesMIC("abc123=, sha-1"))
+	require.False(t, mdn.MatchesMIC("xyz=, sha-256"))
+}
+
+func TestSplitMultipart(t *testing.T) {
+	body := []byte("preamble\n--b\nContent-Type: text/plain\n\nhello\n--b\n\nworld\n--b--\n")
+	parts, err := splitMultipart(body, "b")
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	require.Equal(t, "Content-Type: text/plain\n\nhello", string(parts[0]))
+
+	_, err = splitMultipart([]byte("--b\r\nhello"), "b")
+	require.ErrorContains(t, err, "missing closing multipart boundary")
+}
@@ -0,0 +1,596 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package as2
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des" //nolint:gosec
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// This file implements the subset of CMS (RFC 5652) AS2 needs: detached SignedData with RSA
+// signatures and EnvelopedData with RSA key transport. None of our dependencies read or write
+// CMS and AS2 only needs these two structures, so it's kept here rather than adding a module.
+//
+// Its limits are:
+//   - RSA keys only, with PKCS#1 v1.5 signatures and key transport (no RSA-PSS, RSA-OAEP or ECDH)
+//   - CBC content encryption only (no AES-GCM AuthEnvelopedData)
+//   - one signer per SignedData and the IssuerAndSerialNumber recipient form
+//   - certificate chains aren't verified, signers are compared against the configured certificate
+//
+// Decryption failures from the key transport and the content padding are the same error and
+// a bad key transport decrypts the content with a random key, so callers can't tell them apart.
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+
+	oidAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// Digest algorithms, named as in micalg parameters
+const (
+	SHA1   = "sha1"
+	SHA256 = "sha256"
+	SHA384 = "sha384"
+	SHA512 = "sha512"
+)
+
+// Encryption algorithms
+const (
+	AES128CBC  = "aes128-cbc"
+	AES192CBC  = "aes192-cbc"
+	AES256CBC  = "aes256-cbc"
+	DESEDE3CBC = "des-ede3-cbc"
+)
+
+type digestAlgorithm struct {
+	name   string
+	micalg string
+	oid    asn1.ObjectIdentifier
+	hash   crypto.Hash
+}
+
+var digestAlgorithms = []digestAlgorithm{
+	{name: SHA1, micalg: "sha-1", oid: oidSHA1, hash: crypto.SHA1},
+	{name: SHA256, micalg: "sha-256", oid: oidSHA256, hash: crypto.SHA256},
+	{name: SHA384, micalg: "sha-384", oid: oidSHA384, hash: crypto.SHA384},
+	{name: SHA512, micalg: "sha-512", oid: oidSHA512, hash: crypto.SHA512},
+}
+
+// findDigest returns the algorithm by name, micalg or OID
+func findDigest(name string, oid asn1.ObjectIdentifier) (digestAlgorithm, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, alg := range digestAlgorithms {
+		if (name != "" && (alg.name == name || alg.micalg == name)) || (oid != nil && alg.oid.Equal(oid)) {
+			return alg, nil
+		}
+	}
+	if oid != nil {
+		return digestAlgorithm{}, fmt.Errorf("unsupported digest algorithm %v", oid)
+	}
+	return digestAlgorithm{}, fmt.Errorf("unsupported digest algorithm %q", name)
+}
+
+func (alg digestAlgorithm) sum(data []byte) []byte {
+	h := alg.hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+type encryptionAlgorithm struct {
+	name    string
+	oid     asn1.ObjectIdentifier
+	keySize int
+	block   func(key []byte) (cipher.Block, error)
+}
+
+var encryptionAlgorithms = []encryptionAlgorithm{
+	{name: AES128CBC, oid: oidAES128CBC, keySize: 16, block: aes.NewCipher},
+	{name: AES192CBC, oid: oidAES192CBC, keySize: 24, block: aes.NewCipher},
+	{name: AES256CBC, oid: oidAES256CBC, keySize: 32, block: aes.NewCipher},
+	{name: DESEDE3CBC, oid: oidDESEDE3CBC, keySize: 24, block: des.NewTripleDESCipher},
+}
+
+func findEncryption(name string, oid asn1.ObjectIdentifier) (encryptionAlgorithm, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, alg := range encryptionAlgorithms {
+		if (name != "" && alg.name == name) || (oid != nil && alg.oid.Equal(oid)) {
+			return alg, nil
+		}
+	}
+	if oid != nil {
+		return encryptionAlgorithm{}, fmt.Errorf("unsupported encryption algorithm %v", oid)
+	}
+	return encryptionAlgorithm{}, fmt.Errorf("unsupported encryption algorithm %q", name)
+}
+
+// ValidDigest reports if name is a supported signing digest
+func ValidDigest(name string) bool {
+	_, err := findDigest(name, nil)
+	return err == nil
+}
+
+// ValidEncryption reports if name is a supported encryption algorithm
+func ValidEncryption(name string) bool {
+	_, err := findEncryption(name, nil)
+	return err == nil
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue // SET
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue // SET OF AlgorithmIdentifier
+	ContentInfo      encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue // SET OF SignerInfo
+}
+
+type encapContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signerInfo struct {
+	Version            int
+	IssuerAndSerial    issuerAndSerial
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type envelopedData struct {
+	Version              int
+	RecipientInfos       asn1.RawValue // SET OF RecipientInfo
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type recipientInfo struct {
+	Version                int
+	IssuerAndSerial        issuerAndSerial
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// derSet returns a DER SET OF the encoded elements, which must be sorted
+func derSet(elems ...[]byte) asn1.RawValue {
+	sort.Slice(elems, func(i, j int) bool { return bytes.Compare(elems[i], elems[j]) < 0 })
+	return asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      bytes.Join(elems, nil),
+	}
+}
+
+// setElements splits the contents of a SET or SEQUENCE into its encoded elements
+func setElements(raw asn1.RawValue) ([][]byte, error) {
+	var out [][]byte
+	for rest := raw.Bytes; len(rest) > 0; {
+		var elem asn1.RawValue
+		next, err := asn1.Unmarshal(rest, &elem)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, elem.FullBytes)
+		rest = next
+	}
+	return out, nil
+}
+
+func issuerAndSerialOf(cert *x509.Certificate) issuerAndSerial {
+	return issuerAndSerial{
+		Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+		SerialNumber: cert.SerialNumber,
+	}
+}
+
+func (ias issuerAndSerial) matches(cert *x509.Certificate) bool {
+	return cert != nil && bytes.Equal(ias.Issuer.FullBytes, cert.RawIssuer) && ias.SerialNumber.Cmp(cert.SerialNumber) == 0
+}
+
+func marshalAttribute(oid asn1.ObjectIdentifier, value interface{}) ([]byte, error) {
+	bs, err := asn1.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(attribute{Type: oid, Values: derSet(bs)})
+}
+
+// SignDetached returns a DER encoded SignedData over content which doesn't include the content.
+func SignDetached(content []byte, cert *x509.Certificate, key crypto.Signer, digest string) ([]byte, error) {
+	if cert == nil || key == nil {
+		return nil, errors.New("missing signing certificate or key")
+	}
+	if _, ok := key.Public().(*rsa.PublicKey); !ok {
+		return nil, fmt.Errorf("unsupported %T signing key", key.Public())
+	}
+	alg, err := findDigest(digest, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ct, err := marshalAttribute(oidAttributeContentType, oidData)
+	if err != nil {
+		return nil, err
+	}
+	md, err := marshalAttribute(oidAttributeMessageDigest, alg.sum(content))
+	if err != nil {
+		return nil, err
+	}
+	st, err := marshalAttribute(oidAttributeSigningTime, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	attrs := derSet(ct, md, st)
+	signedAttrs, err := asn1.Marshal(attrs)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := key.Sign(rand.Reader, alg.sum(signedAttrs), alg.hash)
+	if err != nil {
+		return nil, fmt.Errorf("signing: %v", err)
+	}
+
+	si, err := asn1.Marshal(signerInfo{
+		Version:            1,
+		IssuerAndSerial:    issuerAndSerialOf(cert),
+		DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: alg.oid, Parameters: asn1.NullRawValue},
+		SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs.Bytes},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+		Signature:          signature,
+	})
+	if err != nil {
+		return nil, err
+	}
+	digestAlg, err := asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: alg.oid, Parameters: asn1.NullRawValue})
+	if err != nil {
+		return nil, err
+	}
+	sd, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: derSet(digestAlg),
+		ContentInfo:      encapContentInfo{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: cert.Raw},
+		SignerInfos:      derSet(si),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
+
+// VerifyDetached checks signature is a SignedData over content signed by signer
+// and returns the digest algorithm used.
+func VerifyDetached(signature, content []byte, signer *x509.Certificate) (string, error) {
+	if signer == nil {
+		return "", errors.New("missing signer certificate")
+	}
+	pub, ok := signer.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return "", fmt.Errorf("unsupported %T signer key", signer.PublicKey)
+	}
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(signature, &ci); err != nil {
+		return "", fmt.Errorf("reading signature: %v", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return "", fmt.Errorf("unexpected %v content in signature", ci.ContentType)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return "", fmt.Errorf("reading SignedData: %v", err)
+	}
+	signers, err := setElements(sd.SignerInfos)
+	if err != nil {
+		return "", fmt.Errorf("reading SignerInfos: %v", err)
+	}
+	for i := range signers {
+		var si signerInfo
+		if _, err := asn1.Unmarshal(signers[i], &si); err != nil {
+			return "", fmt.Errorf("reading SignerInfo: %v", err)
+		}
+		if !si.IssuerAndSerial.matches(signer) {
+			continue
+		}
+		alg, err := findDigest("", si.DigestAlgorithm.Algorithm)
+		if err != nil {
+			return "", err
+		}
+		signed := content
+		if len(si.SignedAttrs.Bytes) > 0 {
+			digest, err := messageDigest(si.SignedAttrs)
+			if err != nil {
+				return "", err
+			}
+			if !bytes.Equal(digest, alg.sum(content)) {
+				return "", errors.New("message digest does not match content")
+			}
+			// Signatures cover the attributes encoded as a SET rather than [0]
+			signed, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: si.SignedAttrs.Bytes})
+			if err != nil {
+				return "", err
+			}
+		}
+		if err := rsa.VerifyPKCS1v15(pub, alg.hash, alg.sum(signed), si.Signature); err != nil {
+			return "", fmt.Errorf("invalid signature: %v", err)
+		}
+		return alg.name, nil
+	}
+	return "", fmt.Errorf("not signed by %s", signer.Subject)
+}
+
+func messageDigest(raw asn1.RawValue) ([]byte, error) {
+	attrs, err := setElements(raw)
+	if err != nil {
+		return nil, fmt.Errorf("reading signed attributes: %v", err)
+	}
+	for i := range attrs {
+		var attr attribute
+		if _, err := asn1.Unmarshal(attrs[i], &attr); err != nil {
+			return nil, fmt.Errorf("reading signed attribute: %v", err)
+		}
+		if !attr.Type.Equal(oidAttributeMessageDigest) {
+			continue
+		}
+		var digest []byte
+		if _, err := asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil {
+			return nil, fmt.Errorf("reading message digest: %v", err)
+		}
+		return digest, nil
+	}
+	return nil, errors.New("missing message digest attribute")
+}
+
+// Encrypt returns a DER encoded EnvelopedData of content for the recipient
+func Encrypt(content []byte, recipient *x509.Certificate, algorithm string) ([]byte, error) {
+	if recipient == nil {
+		return nil, errors.New("missing recipient certificate")
+	}
+	pub, ok := recipient.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported %T recipient key", recipient.PublicKey)
+	}
+	alg, err := findEncryption(algorithm, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, alg.keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	block, err := alg.block(key)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, block.BlockSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	padded := pad(content, block.BlockSize())
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+
+	encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+	if err != nil {
+		return nil, fmt.Errorf("encrypting key: %v", err)
+	}
+	ri, err := asn1.Marshal(recipientInfo{
+		IssuerAndSerial:        issuerAndSerialOf(recipient),
+		KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+		EncryptedKey:           encryptedKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	ed, err := asn1.Marshal(envelopedData{
+		RecipientInfos: derSet(ri),
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: alg.oid, Parameters: asn1.RawValue{FullBytes: params}},
+			EncryptedContent:           asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: encrypted},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidEnvelopedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: ed},
+	})
+}
+
+// ErrDecryption is returned by Decrypt for a wrong key or corrupted content
+var ErrDecryption = errors.New("unable to decrypt content")
+
+// Decrypt returns the content of a DER encoded EnvelopedData addressed to cert and the
+// encryption algorithm used.
+func Decrypt(der []byte, cert *x509.Certificate, key crypto.Decrypter) ([]byte, string, error) {
+	if cert == nil || key == nil {
+		return nil, "", errors.New("missing decryption certificate or key")
+	}
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, "", fmt.Errorf("reading enveloped data: %v", err)
+	}
+	if !ci.ContentType.Equal(oidEnvelopedData) {
+		return nil, "", fmt.Errorf("unexpected %v content in enveloped data", ci.ContentType)
+	}
+	var ed envelopedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+		return nil, "", fmt.Errorf("reading EnvelopedData: %v", err)
+	}
+	recipients, err := setElements(ed.RecipientInfos)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading RecipientInfos: %v", err)
+	}
+
+	var encryptedKey []byte
+	for i := range recipients {
+		var ri recipientInfo
+		if _, err := asn1.Unmarshal(recipients[i], &ri); err != nil {
+			continue // other recipient types can't be for us
+		}
+		if ri.IssuerAndSerial.matches(cert) {
+			encryptedKey = ri.EncryptedKey
+			break
+		}
+	}
+	if encryptedKey == nil {
+		return nil, "", fmt.Errorf("not encrypted for %s", cert.Subject)
+	}
+
+	eci := ed.EncryptedContentInfo
+	alg, err := findEncryption("", eci.ContentEncryptionAlgorithm.Algorithm)
+	if err != nil {
+		return nil, "", err
+	}
+	// A random key is returned when the key transport's padding is invalid, so a bad key isn't
+	// told apart from bad content (Bleichenbacher's attack)
+	contentKey, err := key.Decrypt(rand.Reader, encryptedKey, &rsa.PKCS1v15DecryptOptions{SessionKeyLen: alg.keySize})
+	if err != nil {
+		return nil, "", ErrDecryption
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(eci.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil {
+		return nil, "", fmt.Errorf("reading IV: %v", err)
+	}
+	encrypted, err := encryptedContent(eci.EncryptedContent)
+	if err != nil {
+		return nil, "", err
+	}
+	block, err := alg.block(contentKey)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(iv) != block.BlockSize() || len(encrypted) == 0 || len(encrypted)%block.BlockSize() != 0 {
+		return nil, "", errors.New("invalid encrypted content")
+	}
+	decrypted := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, encrypted)
+
+	content, err := unpad(decrypted, block.BlockSize())
+	if err != nil {
+		return nil, "", ErrDecryption
+	}
+	return content, alg.name, nil
+}
+
+// encryptedContent reads the primitive or constructed (chunked) encoding of encrypted content
+func encryptedContent(raw asn1.RawValue) ([]byte, error) {
+	if !raw.IsCompound {
+		return raw.Bytes, nil
+	}
+	chunks, err := setElements(raw)
+	if err != nil {
+		return nil, fmt.Errorf("reading encrypted content: %v", err)
+	}
+	var out []byte
+	for i := range chunks {
+		var chunk []byte
+		if _, err := asn1.Unmarshal(chunks[i], &chunk); err != nil {
+			return nil, fmt.Errorf("reading encrypted content: %v", err)
+		}
+		out = append(out, chunk...)
+	}
+	return out, nil
+}
+
+func pad(data []byte, size int) []byte {
+	n := size - len(data)%size
+	return append(append([]byte{}, data...), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+// unpad checks every byte of the last block regardless of where the padding fails
+func unpad(data []byte, size int) ([]byte, error) {
+	if len(data) < size {
+		return nil, ErrDecryption
+	}
+	n := data[len(data)-1]
+	good := subtle.ConstantTimeLessOrEq(1, int(n)) & subtle.ConstantTimeLessOrEq(int(n), size)
+	for i := 1; i <= size; i++ {
+		inPadding := subtle.ConstantTimeLessOrEq(i, int(n))
+		matches := subtle.ConstantTimeByteEq(data[len(data)-i], n)
+		good &= subtle.ConstantTimeSelect(inPadding, matches, 1)
+	}
+	if good != 1 {
+		return nil, ErrDecryption
+	}
+	return data[:len(data)-int(n)], nil
+}
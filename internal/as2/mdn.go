@@ -0,0 +1,185 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package as2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// MDN is a Message Disposition Notification, the receipt for an AS2 message.
+type MDN struct {
+	MessageID         string
+	OriginalMessageID string
+
+	// Disposition is like "automatic-action/MDN-sent-automatically; processed"
+	Disposition string
+
+	// MIC is the partner's Received-Content-MIC
+	MIC string
+
+	Signed bool
+}
+
+// Err returns an error unless the disposition is a successful "processed".
+// Warnings are accepted as the message was still processed.
+func (m *MDN) Err() error {
+	_, disposition, _ := strings.Cut(m.Disposition, ";")
+	disposition = strings.ToLower(strings.TrimSpace(disposition))
+	if disposition == "processed" || strings.HasPrefix(disposition, "processed/warning") {
+		return nil
+	}
+	if disposition == "" {
+		return errors.New("MDN is missing a disposition")
+	}
+	return fmt.Errorf("partner disposition: %s", disposition)
+}
+
+// MatchesMIC reports if the MDN's Received-Content-MIC equals mic
+func (m *MDN) MatchesMIC(mic string) bool {
+	return normalizeMIC(m.MIC) == normalizeMIC(mic)
+}
+
+func normalizeMIC(mic string) string {
+	value, alg, _ := strings.Cut(mic, ",")
+	alg = strings.ToLower(strings.TrimSpace(alg))
+	if d, err := findDigest(alg, nil); err == nil {
+		alg = d.micalg
+	}
+	return strings.TrimSpace(value) + "," + alg
+}
+
+// NewMDN returns the receipt for msg, reporting failure when it's not nil. The MDN is signed when
+// the partner asked for a signed receipt and we have a key.
+func (p *Partner) NewMDN(msg *Message, failure error) (http.Header, []byte, error) {
+	if msg == nil {
+		return nil, nil, errors.New("nil Message")
+	}
+	disposition := "automatic-action/MDN-sent-automatically; processed"
+	text := fmt.Sprintf("The AS2 message %s was received and processed.", msg.MessageID)
+	if failure != nil {
+		description := "unexpected-processing-error"
+		var dispErr *DispositionError
+		if errors.As(failure, &dispErr) {
+			description = dispErr.Description
+		}
+		disposition += "/error: " + description
+		text = fmt.Sprintf("The AS2 message %s could not be processed: %s", msg.MessageID, description)
+	}
+
+	fields := [][2]string{
+		{"Reporting-UA", "achgateway"},
+		{"Original-Recipient", "rfc822; " + quoteID(p.AS2From)},
+		{"Final-Recipient", "rfc822; " + quoteID(p.AS2From)},
+		{"Original-Message-ID", msg.MessageID},
+	}
+	if failure == nil && msg.MIC != "" {
+		fields = append(fields, [2]string{"Received-Content-MIC", msg.MIC})
+	}
+	fields = append(fields, [2]string{"Disposition", disposition})
+
+	boundary := newBoundary()
+	report := multipart(boundary,
+		entity([][2]string{{"Content-Type", "text/plain; charset=us-ascii"}}, []byte(text+"\r\n")),
+		entity([][2]string{{"Content-Type", "message/disposition-notification"}}, entity(fields, nil)),
+	)
+	contentType := fmt.Sprintf(`multipart/report; report-type=disposition-notification; boundary="%s"`, boundary)
+	body := report
+
+	if msg.Receipt.Signed && p.Key != nil && p.Certificate != nil {
+		signer := *p
+		if signer.SigningAlgorithm == "" {
+			signer.SigningAlgorithm = SHA256
+		}
+		var err error
+		contentType, body, err = signer.sign(entity([][2]string{{"Content-Type", contentType}}, report))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	header := make(http.Header)
+	header.Set("AS2-Version", "1.2")
+	header.Set("AS2-From", quoteID(p.AS2From))
+	header.Set("AS2-To", quoteID(msg.AS2From))
+	header.Set("Message-ID", p.newMessageID())
+	header.Set("Subject", "Message Disposition Notification")
+	header.Set("Date", time.Now().UTC().Format(time.RFC1123Z))
+	header.Set("MIME-Version", "1.0")
+	header.Set("Content-Type", contentType)
+	return header, body, nil
+}
+
+// ReadMDN decodes a synchronous or asynchronous MDN from the partner. When requireSigned is set
+// the MDN must be signed by the partner's certificate.
+func (p *Partner) ReadMDN(header http.Header, body []byte, requireSigned bool) (*MDN, error) {
+	mdn := &MDN{
+		MessageID: strings.TrimSpace(header.Get("Message-ID")),
+	}
+	ct, params := mediaType(header.Get("Content-Type"))
+	if ct == "multipart/signed" {
+		signed, _, err := p.verify(params, body)
+		if err != nil {
+			return nil, fmt.Errorf("verifying MDN: %v", err)
+		}
+		mdn.Signed = true
+
+		var headers textproto.MIMEHeader
+		headers, body, err = readEntity(signed)
+		if err != nil {
+			return nil, err
+		}
+		ct, params = mediaType(headers.Get("Content-Type"))
+	} else if requireSigned {
+		return nil, errors.New("MDN is not signed")
+	}
+	if ct != "multipart/report" {
+		return nil, fmt.Errorf("unexpected %s MDN", ct)
+	}
+
+	parts, err := splitMultipart(body, params["boundary"])
+	if err != nil {
+		return nil, fmt.Errorf("reading MDN: %v", err)
+	}
+	for i := range parts {
+		headers, content, err := readEntity(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("reading MDN: %v", err)
+		}
+		if pt, _ := mediaType(headers.Get("Content-Type")); pt != "message/disposition-notification" {
+			continue
+		}
+		content, err = decodeBody(headers.Get("Content-Transfer-Encoding"), content)
+		if err != nil {
+			return nil, err
+		}
+		fields, _, err := readEntity(append(append([]byte{}, content...), "\r\n\r\n"...))
+		if err != nil {
+			return nil, fmt.Errorf("reading disposition notification: %v", err)
+		}
+		mdn.OriginalMessageID = strings.TrimSpace(fields.Get("Original-Message-ID"))
+		mdn.Disposition = strings.TrimSpace(fields.Get("Disposition"))
+		mdn.MIC = strings.TrimSpace(fields.Get("Received-Content-MIC"))
+		return mdn, nil
+	}
+	return nil, errors.New("MDN is missing a disposition notification")
+}
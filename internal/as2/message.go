@@ -0,0 +1,424 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package as2 implements AS2 (RFC 4130) messages and MDN receipts for exchanging files with
+// trading partners over HTTP. Messages are optionally signed and encrypted with S/MIME.
+package as2
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Partner holds the identifiers and keys for exchanging messages with one trading partner.
+type Partner struct {
+	// AS2From is our AS2 identifier and AS2To is the partner's
+	AS2From string
+	AS2To   string
+
+	// Certificate and Key are ours, used to sign outgoing messages and decrypt incoming ones
+	Certificate *x509.Certificate
+	Key         *rsa.PrivateKey
+
+	// PartnerCertificate encrypts messages to the partner and verifies their signatures
+	PartnerCertificate *x509.Certificate
+
+	// SigningAlgorithm is the digest messages are signed with, empty to send them unsigned.
+	// Incoming messages must be signed when set.
+	SigningAlgorithm string
+
+	// EncryptionAlgorithm encrypts messages, empty to send them unencrypted.
+	// Incoming messages must be encrypted when set.
+	EncryptionAlgorithm string
+}
+
+// ReceiptOptions describe the MDN requested for an outgoing message.
+type ReceiptOptions struct {
+	// Disabled sends the message without requesting an MDN
+	Disabled bool
+
+	// Signed asks the partner to sign their MDN
+	Signed bool
+
+	// AsyncURL asks the partner to POST the MDN to this URL instead of responding with it
+	AsyncURL string
+}
+
+// Outgoing is an encoded message ready to be POSTed to the partner.
+type Outgoing struct {
+	MessageID string
+	Header    http.Header
+	Body      []byte
+
+	// MIC is the Message Integrity Check the partner's MDN should return, like "<base64>, sha-256"
+	MIC string
+}
+
+// Message is an AS2 message read from a partner.
+type Message struct {
+	MessageID string
+	AS2From   string
+	AS2To     string
+	Subject   string
+
+	Filename string
+	Payload  []byte
+
+	Signed    bool
+	Encrypted bool
+
+	// MIC is computed over the received content for the MDN
+	MIC string
+
+	// Receipt describes the MDN the partner asked for
+	Receipt ReceiptOptions
+}
+
+// DispositionError is a failure reported back to the partner in an MDN, Description is one
+// of the RFC 4130 error modifiers like "decryption-failed".
+type DispositionError struct {
+	Description string
+	Err         error
+}
+
+func (e *DispositionError) Error() string {
+	if e.Err == nil {
+		return e.Description
+	}
+	return fmt.Sprintf("%s: %v", e.Description, e.Err)
+}
+
+func (e *DispositionError) Unwrap() error {
+	return e.Err
+}
+
+// ParseCertificate reads the first PEM encoded certificate in data
+func ParseCertificate(data []byte) (*x509.Certificate, error) {
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+	return nil, errors.New("no PEM certificate found")
+}
+
+// ParsePrivateKey reads a PEM encoded PKCS #1 or PKCS #8 RSA private key
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+				return rsaKey, nil
+			}
+			return nil, fmt.Errorf("unsupported %T private key", key)
+		}
+	}
+	return nil, errors.New("no PEM private key found")
+}
+
+// NewMessage encodes payload into a message for the partner, signing and encrypting it as configured.
+func (p *Partner) NewMessage(filename string, payload []byte, receipt ReceiptOptions) (*Outgoing, error) {
+	if p.AS2From == "" || p.AS2To == "" {
+		return nil, errors.New("missing AS2 identifiers")
+	}
+	digest, err := p.micDigest("")
+	if err != nil {
+		return nil, err
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(filename)})
+	inner := entity([][2]string{
+		{"Content-Type", "application/octet-stream"},
+		{"Content-Transfer-Encoding", "binary"},
+		{"Content-Disposition", disposition},
+	}, payload)
+
+	out := &Outgoing{
+		MessageID: p.newMessageID(),
+		Header:    make(http.Header),
+		MIC:       fmt.Sprintf("%s, %s", base64.StdEncoding.EncodeToString(digest.sum(inner)), digest.micalg),
+	}
+	contentType, body := "application/octet-stream", payload
+	current := inner
+
+	if p.SigningAlgorithm != "" {
+		contentType, body, err = p.sign(inner)
+		if err != nil {
+			return nil, err
+		}
+		current = entity([][2]string{{"Content-Type", contentType}}, body)
+	} else if p.EncryptionAlgorithm == "" {
+		// Unwrapped payloads carry their headers over HTTP, so the MIC only covers the content
+		out.MIC = fmt.Sprintf("%s, %s", base64.StdEncoding.EncodeToString(digest.sum(payload)), digest.micalg)
+		out.Header.Set("Content-Disposition", disposition)
+	}
+	if p.EncryptionAlgorithm != "" {
+		body, err = Encrypt(current, p.PartnerCertificate, p.EncryptionAlgorithm)
+		if err != nil {
+			return nil, fmt.Errorf("encrypting message: %v", err)
+		}
+		contentType = `application/pkcs7-mime; smime-type=enveloped-data; name="smime.p7m"`
+		out.Header.Set("Content-Disposition", `attachment; filename="smime.p7m"`)
+	}
+
+	out.Header.Set("AS2-Version", "1.2")
+	out.Header.Set("AS2-From", quoteID(p.AS2From))
+	out.Header.Set("AS2-To", quoteID(p.AS2To))
+	out.Header.Set("Message-ID", out.MessageID)
+	out.Header.Set("Subject", filepath.Base(filename))
+	out.Header.Set("Date", time.Now().UTC().Format(time.RFC1123Z))
+	out.Header.Set("MIME-Version", "1.0")
+	out.Header.Set("Content-Type", contentType)
+	if p.EncryptionAlgorithm != "" {
+		out.Header.Set("Content-Transfer-Encoding", "binary")
+	}
+	if !receipt.Disabled {
+		out.Header.Set("Disposition-Notification-To", p.AS2From)
+		if receipt.Signed {
+			out.Header.Set("Disposition-Notification-Options", fmt.Sprintf("signed-receipt-protocol=optional, pkcs7-signature; signed-receipt-micalg=optional, %s", digest.micalg))
+		}
+		if receipt.AsyncURL != "" {
+			out.Header.Set("Receipt-Delivery-Option", receipt.AsyncURL)
+		}
+	}
+	out.Body = body
+	return out, nil
+}
+
+// sign wraps the entity in a multipart/signed entity and returns its Content-Type and body
+func (p *Partner) sign(inner []byte) (string, []byte, error) {
+	digest, err := findDigest(p.SigningAlgorithm, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	signature, err := SignDetached(inner, p.Certificate, p.signer(), p.SigningAlgorithm)
+	if err != nil {
+		return "", nil, fmt.Errorf("signing message: %v", err)
+	}
+	boundary := newBoundary()
+	sigPart := entity([][2]string{
+		{"Content-Type", `application/pkcs7-signature; name="smime.p7s"`},
+		{"Content-Transfer-Encoding", "base64"},
+		{"Content-Disposition", `attachment; filename="smime.p7s"`},
+	}, encodeBase64(signature))
+
+	contentType := fmt.Sprintf(`multipart/signed; protocol="application/pkcs7-signature"; micalg=%s; boundary="%s"`, digest.micalg, boundary)
+	return contentType, multipart(boundary, inner, sigPart), nil
+}
+
+// verify checks a multipart/signed body was signed by the partner and returns the signed entity
+func (p *Partner) verify(params map[string]string, body []byte) ([]byte, string, error) {
+	parts, err := splitMultipart(body, params["boundary"])
+	if err != nil {
+		return nil, "", err
+	}
+	if len(parts) != 2 {
+		return nil, "", fmt.Errorf("multipart/signed has %d parts", len(parts))
+	}
+	header, sigBody, err := readEntity(parts[1])
+	if err != nil {
+		return nil, "", err
+	}
+	signature, err := decodeBody(header.Get("Content-Transfer-Encoding"), sigBody)
+	if err != nil {
+		return nil, "", err
+	}
+	digest, err := VerifyDetached(signature, parts[0], p.PartnerCertificate)
+	if err != nil {
+		return nil, "", err
+	}
+	return parts[0], digest, nil
+}
+
+// ReadMessage decodes a message POSTed by the partner, decrypting and verifying it as configured.
+// The returned Message has its headers populated even when an error is returned so an MDN can
+// be sent, errors to report in an MDN are a *DispositionError.
+func (p *Partner) ReadMessage(header http.Header, body []byte) (*Message, error) {
+	msg := &Message{
+		MessageID: strings.TrimSpace(header.Get("Message-ID")),
+		AS2From:   unquoteID(header.Get("AS2-From")),
+		AS2To:     unquoteID(header.Get("AS2-To")),
+		Subject:   header.Get("Subject"),
+		Receipt: ReceiptOptions{
+			Disabled: header.Get("Disposition-Notification-To") == "",
+			Signed:   strings.Contains(strings.ToLower(header.Get("Disposition-Notification-Options")), "pkcs7-signature"),
+			AsyncURL: strings.TrimSpace(header.Get("Receipt-Delivery-Option")),
+		},
+	}
+	micalg := requestedMICAlg(header.Get("Disposition-Notification-Options"))
+
+	if msg.MessageID == "" {
+		return msg, &DispositionError{Description: "unexpected-processing-error", Err: errors.New("missing Message-ID")}
+	}
+	if msg.AS2From != p.AS2To || msg.AS2To != p.AS2From {
+		return msg, &DispositionError{
+			Description: "authentication-failed",
+			Err:         fmt.Errorf("unknown trading partner %q to %q", msg.AS2From, msg.AS2To),
+		}
+	}
+
+	headers := textproto.MIMEHeader(header)
+	micContent := body
+	ct, params := mediaType(headers.Get("Content-Type"))
+
+	if ct == "application/pkcs7-mime" || ct == "application/x-pkcs7-mime" {
+		if smime := strings.ToLower(params["smime-type"]); smime != "" && smime != "enveloped-data" {
+			return msg, &DispositionError{Description: "unexpected-processing-error", Err: fmt.Errorf("unsupported smime-type %s", smime)}
+		}
+		encrypted, err := decodeBody(headers.Get("Content-Transfer-Encoding"), body)
+		if err != nil {
+			return msg, &DispositionError{Description: "decryption-failed", Err: err}
+		}
+		decrypted, _, err := Decrypt(encrypted, p.Certificate, p.decrypter())
+		if err != nil {
+			return msg, &DispositionError{Description: "decryption-failed", Err: err}
+		}
+		msg.Encrypted = true
+		micContent = decrypted
+
+		headers, body, err = readEntity(decrypted)
+		if err != nil {
+			return msg, &DispositionError{Description: "decryption-failed", Err: err}
+		}
+		ct, params = mediaType(headers.Get("Content-Type"))
+	} else if p.EncryptionAlgorithm != "" {
+		return msg, &DispositionError{Description: "insufficient-message-security", Err: errors.New("message is not encrypted")}
+	}
+
+	if ct == "multipart/signed" {
+		signed, digest, err := p.verify(params, body)
+		if err != nil {
+			return msg, &DispositionError{Description: "authentication-failed", Err: err}
+		}
+		msg.Signed = true
+		micContent = signed
+		if micalg == "" {
+			micalg = digest
+		}
+		headers, body, err = readEntity(signed)
+		if err != nil {
+			return msg, &DispositionError{Description: "unexpected-processing-error", Err: err}
+		}
+		ct, _ = mediaType(headers.Get("Content-Type"))
+	} else if p.SigningAlgorithm != "" {
+		return msg, &DispositionError{Description: "insufficient-message-security", Err: errors.New("message is not signed")}
+	}
+	if strings.HasPrefix(ct, "multipart/") || ct == "application/pkcs7-mime" {
+		return msg, &DispositionError{Description: "unexpected-processing-error", Err: fmt.Errorf("unsupported %s content", ct)}
+	}
+
+	payload, err := decodeBody(headers.Get("Content-Transfer-Encoding"), body)
+	if err != nil {
+		return msg, &DispositionError{Description: "unexpected-processing-error", Err: err}
+	}
+	msg.Payload = payload
+	if _, params, err := mime.ParseMediaType(headers.Get("Content-Disposition")); err == nil {
+		msg.Filename = filepath.Base(params["filename"])
+	}
+
+	digest, err := p.micDigest(micalg)
+	if err != nil {
+		return msg, &DispositionError{Description: "unexpected-processing-error", Err: err}
+	}
+	msg.MIC = fmt.Sprintf("%s, %s", base64.StdEncoding.EncodeToString(digest.sum(micContent)), digest.micalg)
+	return msg, nil
+}
+
+// micDigest picks the algorithm for computing a MIC, preferring what was requested
+func (p *Partner) micDigest(requested string) (digestAlgorithm, error) {
+	if requested != "" {
+		return findDigest(requested, nil)
+	}
+	if p.SigningAlgorithm != "" {
+		return findDigest(p.SigningAlgorithm, nil)
+	}
+	return findDigest(SHA256, nil)
+}
+
+// requestedMICAlg returns the first supported digest in signed-receipt-micalg
+func requestedMICAlg(options string) string {
+	for _, option := range strings.Split(options, ";") {
+		name, value, found := strings.Cut(option, "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "signed-receipt-micalg") {
+			continue
+		}
+		for _, alg := range strings.Split(value, ",") {
+			if ValidDigest(alg) {
+				return strings.TrimSpace(alg)
+			}
+		}
+	}
+	return ""
+}
+
+func (p *Partner) newMessageID() string {
+	var bs [8]byte
+	rand.Read(bs[:])
+	host := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '<' || r == '>' || r == '@' || r == '"' {
+			return '_'
+		}
+		return r
+	}, p.AS2From)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(bs[:]), host)
+}
+
+// quoteID quotes AS2 identifiers which contain spaces
+func quoteID(id string) string {
+	if strings.ContainsAny(id, " \t") {
+		return `"` + id + `"`
+	}
+	return id
+}
+
+func unquoteID(id string) string {
+	return strings.Trim(strings.TrimSpace(id), `"`)
+}
+
+// signer and decrypter return our key without wrapping a nil *rsa.PrivateKey in an interface
+func (p *Partner) signer() crypto.Signer {
+	if p.Key == nil {
+		return nil
+	}
+	return p.Key
+}
+
+func (p *Partner) decrypter() crypto.Decrypter {
+	if p.Key == nil {
+		return nil
+	}
+	return p.Key
+}
@@ -0,0 +1,166 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package as2
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+)
+
+// entity returns a MIME entity with the headers in order followed by body
+func entity(headers [][2]string, body []byte) []byte {
+	var buf bytes.Buffer
+	for _, h := range headers {
+		buf.WriteString(h[0] + ": " + h[1] + "\r\n")
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// readEntity splits a MIME entity into its headers and body
+func readEntity(raw []byte) (textproto.MIMEHeader, []byte, error) {
+	sep, end := []byte("\r\n\r\n"), 4
+	idx := bytes.Index(raw, sep)
+	if lf := bytes.Index(raw, []byte("\n\n")); lf >= 0 && (idx < 0 || lf < idx) {
+		idx, end = lf, 2
+	}
+	if idx < 0 {
+		return nil, nil, errors.New("malformed MIME entity")
+	}
+	rd := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(raw[:idx:idx], "\r\n\r\n"...))))
+	header, err := rd.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("reading MIME headers: %v", err)
+	}
+	return header, raw[idx+end:], nil
+}
+
+// decodeBody reverses the Content-Transfer-Encoding of body
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		clean := bytes.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, body)
+		out := make([]byte, base64.StdEncoding.DecodedLen(len(clean)))
+		n, err := base64.StdEncoding.Decode(out, clean)
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64 body: %v", err)
+		}
+		return out[:n], nil
+	case "quoted-printable":
+		out, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(body)))
+		if err != nil {
+			return nil, fmt.Errorf("decoding quoted-printable body: %v", err)
+		}
+		return out, nil
+	}
+	return body, nil
+}
+
+// encodeBase64 returns data base64 encoded into 76 character lines
+func encodeBase64(data []byte) []byte {
+	enc := base64.StdEncoding.EncodeToString(data)
+	var buf bytes.Buffer
+	for len(enc) > 76 {
+		buf.WriteString(enc[:76] + "\r\n")
+		enc = enc[76:]
+	}
+	buf.WriteString(enc)
+	return buf.Bytes()
+}
+
+func newBoundary() string {
+	var bs [16]byte
+	rand.Read(bs[:])
+	return "----=_Part_" + hex.EncodeToString(bs[:])
+}
+
+// multipart returns the body of a multipart entity holding each raw part
+func multipart(boundary string, parts ...[]byte) []byte {
+	var buf bytes.Buffer
+	for i := range parts {
+		buf.WriteString("--" + boundary + "\r\n")
+		buf.Write(parts[i])
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+	return buf.Bytes()
+}
+
+// splitMultipart returns each part (headers and body) of a multipart body exactly as written,
+// which signature verification depends on. The line break before a delimiter belongs to the
+// delimiter (RFC 2046).
+func splitMultipart(body []byte, boundary string) ([][]byte, error) {
+	if boundary == "" {
+		return nil, errors.New("missing multipart boundary")
+	}
+	newline := "\r\n"
+	if !bytes.Contains(body, []byte("\r\n--"+boundary)) && !bytes.HasPrefix(body, []byte("--"+boundary+"\r\n")) {
+		newline = "\n"
+	}
+	delim := []byte(newline + "--" + boundary)
+	data := append([]byte(newline), body...)
+
+	idx := bytes.Index(data, delim)
+	if idx < 0 {
+		return nil, errors.New("missing multipart boundary")
+	}
+	data = data[idx+len(delim):]
+
+	var parts [][]byte
+	for {
+		if bytes.HasPrefix(data, []byte("--")) {
+			return parts, nil
+		}
+		nl := bytes.Index(data, []byte(newline))
+		if nl < 0 {
+			return nil, errors.New("malformed multipart boundary")
+		}
+		data = data[nl+len(newline):]
+		end := bytes.Index(data, delim)
+		if end < 0 {
+			return nil, errors.New("missing closing multipart boundary")
+		}
+		parts = append(parts, data[:end])
+		data = data[end+len(delim):]
+	}
+}
+
+// mediaType parses a Content-Type header, lowercasing the type
+func mediaType(contentType string) (string, map[string]string) {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0])), nil
+	}
+	return mt, params
+}
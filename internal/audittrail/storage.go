@@ -36,6 +36,12 @@ type Storage interface {
 
 	GetFile(filepath string) (io.ReadCloser, error)
 
+	// ListFiles returns the path of every saved file which starts with prefix.
+	ListFiles(prefix string) ([]string, error)
+
+	// DeleteFile removes a saved file. It's only used to erase files past their retention.
+	DeleteFile(filepath string) error
+
 	Close() error
 }
 
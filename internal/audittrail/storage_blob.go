@@ -107,3 +107,30 @@ func (bs *blobStorage) GetFile(filepath string) (io.ReadCloser, error) {
 	}
 	return r, nil
 }
+
+func (bs *blobStorage) ListFiles(prefix string) ([]string, error) {
+	var out []string
+	iter := bs.bucket.List(&blob.ListOptions{
+		Prefix: prefix,
+	})
+	for {
+		obj, err := iter.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list files: %v", err)
+		}
+		if !obj.IsDir {
+			out = append(out, obj.Key)
+		}
+	}
+	return out, nil
+}
+
+func (bs *blobStorage) DeleteFile(filepath string) error {
+	if err := bs.bucket.Delete(context.Background(), filepath); err != nil {
+		return fmt.Errorf("delete file: %v", err)
+	}
+	return nil
+}
@@ -75,3 +75,39 @@ func TestBlobStorageErr(t *testing.T) {
 		t.Error("expected error")
 	}
 }
+
+func TestBlobStorage__ListFiles(t *testing.T) {
+	store, err := newBlobStorage(&service.AuditTrail{
+		BucketURI: "mem://",
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.SaveFile("odfi/ftp.dev.com/return/2022-10-13/first.ach", []byte("first")))
+	require.NoError(t, store.SaveFile("odfi/ftp.dev.com/return/2022-10-14/second.ach", []byte("second")))
+	require.NoError(t, store.SaveFile("outbound/ftp.dev.com/2022-10-14/merged.ach", []byte("merged")))
+
+	paths, err := store.ListFiles("odfi/ftp.dev.com/")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		"odfi/ftp.dev.com/return/2022-10-13/first.ach",
+		"odfi/ftp.dev.com/return/2022-10-14/second.ach",
+	}, paths)
+}
+
+func TestBlobStorage__DeleteFile(t *testing.T) {
+	store, err := newBlobStorage(&service.AuditTrail{
+		BucketURI: "mem://",
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.SaveFile("odfi/ftp.dev.com/return/2022-10-13/first.ach", []byte("first")))
+	require.NoError(t, store.DeleteFile("odfi/ftp.dev.com/return/2022-10-13/first.ach"))
+
+	paths, err := store.ListFiles("odfi/")
+	require.NoError(t, err)
+	require.Empty(t, paths)
+
+	require.Error(t, store.DeleteFile("odfi/ftp.dev.com/return/2022-10-13/first.ach"))
+}
@@ -41,3 +41,11 @@ func (s *MockStorage) GetFile(_ string) (io.ReadCloser, error) {
 	}
 	return io.NopCloser(strings.NewReader(s.FileContents)), nil
 }
+
+func (s *MockStorage) ListFiles(_ string) ([]string, error) {
+	return nil, s.Err
+}
+
+func (s *MockStorage) DeleteFile(_ string) error {
+	return s.Err
+}
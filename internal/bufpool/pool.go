@@ -0,0 +1,78 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package bufpool shares bytes.Buffers between the download, audit, storage
+// and upload stages so each file doesn't allocate (and later collect) a fresh
+// buffer sized to its contents.
+package bufpool
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// MaxRetained is the largest buffer capacity kept in the pool. Buffers which
+// grew beyond this are dropped so one oversized file doesn't pin its memory.
+const MaxRetained = 32 * 1024 * 1024
+
+var pool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// Get returns an empty buffer from the pool.
+func Get() *bytes.Buffer {
+	return pool.Get().(*bytes.Buffer)
+}
+
+// Put resets buf and returns it to the pool. Callers must not retain buf or any
+// slice returned from buf.Bytes() after calling Put.
+func Put(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > MaxRetained {
+		return
+	}
+	buf.Reset()
+	pool.Put(buf)
+}
+
+// NewReadCloser reads from buf and returns it to the pool once closed.
+func NewReadCloser(buf *bytes.Buffer) io.ReadCloser {
+	return &readCloser{buf: buf}
+}
+
+type readCloser struct {
+	mu  sync.Mutex
+	buf *bytes.Buffer
+}
+
+func (r *readCloser) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.buf == nil {
+		return 0, io.EOF
+	}
+	return r.buf.Read(p)
+}
+
+func (r *readCloser) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.buf == nil {
+		return 0, nil
+	}
+	return r.buf.WriteTo(w)
+}
+
+func (r *readCloser) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	Put(r.buf)
+	r.buf = nil
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package bufpool
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool(t *testing.T) {
+	buf := Get()
+	require.Equal(t, 0, buf.Len())
+
+	buf.WriteString("hello")
+	Put(buf)
+
+	buf = Get()
+	require.Equal(t, 0, buf.Len())
+	Put(buf)
+
+	// nil and oversized buffers are ignored
+	Put(nil)
+	Put(bytes.NewBuffer(make([]byte, 0, MaxRetained+1)))
+}
+
+func TestReadCloser(t *testing.T) {
+	buf := Get()
+	buf.WriteString("hello, world")
+
+	rc := NewReadCloser(buf)
+	bs, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, "hello, world", string(bs))
+
+	require.NoError(t, rc.Close())
+	require.NoError(t, rc.Close())
+
+	n, err := rc.Read(make([]byte, 10))
+	require.Equal(t, 0, n)
+	require.ErrorIs(t, err, io.EOF)
+
+	var out bytes.Buffer
+	rc = NewReadCloser(bytes.NewBufferString("abc"))
+	written, err := io.Copy(&out, rc)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), written)
+	require.Equal(t, "abc", out.String())
+}
@@ -20,12 +20,14 @@
 package consul
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/hashicorp/consul/api"
 
+	"github.com/moov-io/achgateway/internal/mask"
 	"github.com/moov-io/base/log"
 )
 
@@ -46,6 +48,21 @@ type Config struct {
 	TLS api.TLSConfig
 }
 
+func (cfg *Config) MarshalJSON() ([]byte, error) {
+	type Aux Config
+	aux := Aux(*cfg)
+	aux.Token = mask.Password(cfg.Token)
+	if len(cfg.TLS.KeyPEM) > 0 {
+		aux.TLS.KeyPEM = []byte(mask.Password(string(cfg.TLS.KeyPEM)))
+	}
+	return json.Marshal(aux)
+}
+
+func (cfg *Config) String() string {
+	return fmt.Sprintf("Consul{Address=%s, Scheme=%s, Token=%s, KeyPEM:%v}",
+		cfg.Address, cfg.Scheme, mask.Password(cfg.Token), len(cfg.TLS.KeyPEM) > 0)
+}
+
 type SessionConfig struct {
 	CheckInterval time.Duration
 }
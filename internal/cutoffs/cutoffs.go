@@ -0,0 +1,195 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package cutoffs implements `achgateway cutoffs` which previews the upcoming cutoff windows
+// and ODFI scans of each shard after applying its timezone and the banking holiday calendar.
+package cutoffs
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/schedule"
+	"github.com/moov-io/achgateway/internal/service"
+)
+
+const usage = `Usage: achgateway cutoffs [-shard <name>] [-days 14] [flags]
+
+Prints each upcoming cutoff window of the shard (or every shard) in its timezone and UTC, whether
+the window is skipped for a weekend or holiday (or deferred for the upload agent's maintenance
+windows) and, when the shard is in Inbound.ODFI.ShardNames,
+the first ODFI scan after the window.
+
+ODFI scans run every Inbound.ODFI.Interval from when achgateway started, see -started.
+`
+
+// Run executes the cutoffs subcommand and writes its output to out.
+func Run(args []string, out io.Writer, loadConfig service.ConfigLoader) error {
+	fs := flag.NewFlagSet("achgateway cutoffs", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprint(out, usage+"\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	flagShard := fs.String("shard", "", "Shard name to preview, defaults to every shard")
+	flagDays := fs.Int("days", 14, "Number of days to preview")
+	flagFrom := fs.String("from", "", "Preview from this RFC3339 time or YYYY-MM-DD date (UTC), defaults to now")
+	flagStarted := fs.String("started", "", "RFC3339 time achgateway started for ODFI scan times, defaults to -from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *flagDays <= 0 {
+		return fmt.Errorf("invalid -days %d", *flagDays)
+	}
+
+	from := time.Now()
+	if *flagFrom != "" {
+		t, err := parseTime(*flagFrom)
+		if err != nil {
+			return fmt.Errorf("invalid -from: %v", err)
+		}
+		from = t
+	}
+	started := from
+	if *flagStarted != "" {
+		t, err := time.Parse(time.RFC3339, *flagStarted)
+		if err != nil {
+			return fmt.Errorf("invalid -started: %v", err)
+		}
+		started = t
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %v", err)
+	}
+	shards, err := selectShards(cfg.Sharding, *flagShard)
+	if err != nil {
+		return err
+	}
+
+	for i, shard := range shards {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		agent := cfg.Upload.Find(shard.UploadAgent)
+		if err := preview(out, shard, agent, odfiInterval(cfg.Inbound.ODFI, shard.Name), from, started, *flagDays); err != nil {
+			return fmt.Errorf("shard %s: %v", shard.Name, err)
+		}
+	}
+	return nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func selectShards(cfg service.Sharding, name string) ([]service.Shard, error) {
+	if name != "" {
+		shard := cfg.Find(name)
+		if shard == nil {
+			return nil, fmt.Errorf("shard %s not found", name)
+		}
+		return []service.Shard{*shard}, nil
+	}
+	if len(cfg.Shards) == 0 {
+		return nil, errors.New("no shards configured")
+	}
+	return cfg.Shards, nil
+}
+
+// odfiInterval returns how often the shard is scanned for ODFI files, zero when it isn't scanned.
+func odfiInterval(cfg *service.ODFIFiles, shardName string) time.Duration {
+	if cfg == nil {
+		return 0
+	}
+	for i := range cfg.ShardNames {
+		if cfg.ShardNames[i] == shardName {
+			return cfg.Interval
+		}
+	}
+	return 0
+}
+
+// nextScan returns the first ODFI scan after when for a scheduler started at started.
+// Scans happen every interval after startup, like a time.Ticker.
+func nextScan(started time.Time, interval time.Duration, when time.Time) time.Time {
+	if !when.After(started) {
+		return started.Add(interval)
+	}
+	n := when.Sub(started)/interval + 1
+	return started.Add(n * interval)
+}
+
+func preview(w io.Writer, shard service.Shard, agent *service.UploadAgent, interval time.Duration, from, started time.Time, days int) error {
+	windows, err := schedule.Upcoming(shard.Cutoffs.Timezone, shard.Cutoffs.Windows, from, days)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Shard: %s (upload agent %s)\n", shard.Name, shard.UploadAgent)
+	fmt.Fprintf(w, "Timezone: %s\n", shard.Cutoffs.Location())
+	if interval > 0 {
+		fmt.Fprintf(w, "ODFI scans: every %v from %s\n\n", interval, started.In(shard.Cutoffs.Location()).Format(time.RFC3339))
+	} else {
+		fmt.Fprint(w, "ODFI scans: not configured\n\n")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Date\tDay\tCutoff\tUTC\tStatus\tNext ODFI scan")
+	for _, day := range windows {
+		scan := "-"
+		if interval > 0 {
+			next := nextScan(started, interval, day.Time)
+			// Scans are skipped during maintenance windows
+			for i := 0; i < 100; i++ {
+				until, ok := agent.MaintenanceUntil(next)
+				if !ok {
+					break
+				}
+				next = nextScan(started, interval, until.Add(-time.Nanosecond))
+			}
+			scan = next.In(day.Time.Location()).Format("2006-01-02 15:04 MST")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			day.Time.Format("2006-01-02"), day.Time.Format("Mon"), day.Time.Format("15:04 MST"),
+			day.Time.UTC().Format("15:04"), status(day, agent), scan)
+	}
+	return tw.Flush()
+}
+
+func status(day *schedule.Day, agent *service.UploadAgent) string {
+	if until, ok := agent.MaintenanceUntil(day.Time); ok && day.IsBankingDay {
+		return fmt.Sprintf("deferred (maintenance until %s)", until.In(day.Time.Location()).Format("15:04 MST"))
+	}
+	switch {
+	case day.IsWeekend:
+		return "skipped (weekend)"
+	case day.IsHoliday && day.FirstWindow:
+		return "skipped (holiday, notification sent)"
+	case day.IsHoliday || !day.IsBankingDay:
+		return "skipped (holiday)"
+	}
+	return "cutoff"
+}
@@ -0,0 +1,130 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cutoffs
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func loadConfig() (*service.Config, error) {
+	return &service.Config{
+		Sharding: service.Sharding{
+			Shards: []service.Shard{
+				{
+					Name:        "live",
+					UploadAgent: "ftp-live",
+					Cutoffs: service.Cutoffs{
+						Timezone: "America/New_York",
+						Windows:  []string{"16:20", "11:00"},
+					},
+				},
+				{
+					Name:        "testing",
+					UploadAgent: "mock",
+					Cutoffs: service.Cutoffs{
+						Timezone: "UTC",
+						Windows:  []string{"12:00"},
+					},
+				},
+			},
+		},
+		Inbound: service.Inbound{
+			ODFI: &service.ODFIFiles{
+				Interval:   10 * time.Minute,
+				ShardNames: []string{"live"},
+			},
+		},
+		Upload: service.UploadAgents{
+			Agents: []service.UploadAgent{
+				{
+					ID: "ftp-live",
+					MaintenanceWindows: []service.MaintenanceWindow{
+						{
+							Days:     []string{"Tuesday"},
+							Start:    "16:00",
+							Duration: time.Hour,
+							Timezone: "America/New_York",
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func TestRun(t *testing.T) {
+	var buf bytes.Buffer
+	args := []string{"-shard", "live", "-days", "4", "-from", "2022-09-02T12:00:00Z", "-started", "2022-09-02T11:55:00Z"}
+	err := Run(args, &buf, loadConfig)
+	require.NoError(t, err)
+
+	output := buf.String()
+	require.Contains(t, output, "Shard: live (upload agent ftp-live)\n")
+	require.Contains(t, output, "ODFI scans: every 10m0s from 2022-09-02T07:55:00-04:00\n")
+	require.Regexp(t, `2022-09-02  Fri  11:00 EDT  15:00  cutoff +2022-09-02 11:05 EDT`, output)
+	require.Regexp(t, `2022-09-03  Sat  11:00 EDT  15:00  skipped \(weekend\)`, output)
+	require.Regexp(t, `2022-09-05  Mon  11:00 EDT  15:00  skipped \(holiday, notification sent\)`, output)
+	require.Regexp(t, `2022-09-05  Mon  16:20 EDT  20:20  skipped \(holiday\) `, output)
+	require.NotContains(t, output, "testing")
+}
+
+func TestRun__AllShards(t *testing.T) {
+	var buf bytes.Buffer
+	err := Run([]string{"-days", "1", "-from", "2022-09-06"}, &buf, loadConfig)
+	require.NoError(t, err)
+
+	output := buf.String()
+	require.Contains(t, output, "Shard: live")
+	require.Contains(t, output, "Shard: testing (upload agent mock)\nTimezone: UTC\nODFI scans: not configured\n")
+	require.Regexp(t, `2022-09-06  Tue  12:00 UTC  12:00  cutoff +-`, output)
+	require.Regexp(t, `2022-09-06  Tue  16:20 EDT  20:20  deferred \(maintenance until 17:00 EDT\)  2022-09-06 17:00 EDT`, output)
+}
+
+func TestRun__Errors(t *testing.T) {
+	var buf bytes.Buffer
+	err := Run([]string{"-shard", "missing"}, &buf, loadConfig)
+	require.ErrorContains(t, err, "shard missing not found")
+
+	err = Run([]string{"-days", "0"}, &buf, loadConfig)
+	require.ErrorContains(t, err, "invalid -days 0")
+
+	err = Run([]string{"-from", "tomorrow"}, &buf, loadConfig)
+	require.ErrorContains(t, err, "invalid -from")
+
+	err = Run([]string{"-started", "2022-09-02"}, &buf, loadConfig)
+	require.ErrorContains(t, err, "invalid -started")
+
+	err = Run(nil, &buf, func() (*service.Config, error) {
+		return &service.Config{}, nil
+	})
+	require.ErrorContains(t, err, "no shards configured")
+}
+
+func TestNextScan(t *testing.T) {
+	started := time.Date(2022, time.September, 2, 12, 0, 0, 0, time.UTC)
+
+	require.Equal(t, started.Add(time.Minute), nextScan(started, time.Minute, started.Add(-time.Hour)))
+	require.Equal(t, started.Add(2*time.Minute), nextScan(started, time.Minute, started.Add(time.Minute)))
+	require.Equal(t, started.Add(10*time.Minute), nextScan(started, 10*time.Minute, started.Add(9*time.Minute)))
+}
@@ -0,0 +1,272 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dblock
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+)
+
+// Client acquires and renews locks on behalf of this replica. Each replica takes at most its
+// fair share of the known locks (rounded up) so shards are split across every running replica.
+type Client struct {
+	cfg    Config
+	db     *sql.DB
+	logger log.Logger
+	now    func() time.Time
+
+	mu   sync.Mutex
+	held map[string]bool // lock keys currently held
+
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	wg           sync.WaitGroup
+}
+
+func NewClient(logger log.Logger, db *sql.DB, config *Config) (*Client, error) {
+	if db == nil {
+		return nil, errors.New("database locks require a database")
+	}
+	if config == nil {
+		return nil, errors.New("nil database lock config")
+	}
+	cfg, err := config.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		cfg: cfg,
+		db:  db,
+		logger: logger.With(log.Fields{
+			"identity": log.String(cfg.Identity),
+		}),
+		now:      time.Now,
+		held:     make(map[string]bool),
+		shutdown: make(chan struct{}),
+	}
+	if err := c.heartbeat(); err != nil {
+		return nil, fmt.Errorf("registering %s: %v", cfg.Identity, err)
+	}
+
+	c.wg.Add(1)
+	go c.renewHeldLocks()
+
+	return c, nil
+}
+
+// Identity is the holder written to locks acquired by this replica
+func (c *Client) Identity() string {
+	return c.cfg.Identity
+}
+
+// AcquireLock returns nil when this replica holds (or has now acquired) the lock for key.
+// Locks are not acquired past this replica's fair share so other replicas can take them.
+func (c *Client) AcquireLock(key string) error {
+	c.mu.Lock()
+	held := c.held[key]
+	c.mu.Unlock()
+
+	err := c.acquire(key, held)
+
+	c.mu.Lock()
+	c.held[key] = (err == nil)
+	c.mu.Unlock()
+	return err
+}
+
+func (c *Client) acquire(key string, held bool) error {
+	now := c.timestamp()
+
+	// Record every key so each replica's fair share includes locks nobody holds
+	_, err := c.db.Exec(`insert into leadership_locks (lock_key, holder, expires_at) values (?, '', ?);`, key, now)
+	if err != nil && !database.UniqueViolation(err) {
+		return fmt.Errorf("recording lock %s: %v", key, err)
+	}
+
+	if !held {
+		share, err := c.fairShare(now)
+		if err != nil {
+			return err
+		}
+		if count := c.heldCount(); count >= share {
+			return fmt.Errorf("we are not the leader of %s (already holding %d of our share of %d locks)", key, count, share)
+		}
+	}
+
+	expires := now.Add(c.cfg.LockDuration)
+	_, err = c.db.Exec(`update leadership_locks set holder = ?, expires_at = ? where lock_key = ? and (holder = ? or holder = '' or expires_at < ?);`,
+		c.cfg.Identity, expires, key, c.cfg.Identity, now)
+	if err != nil {
+		return fmt.Errorf("acquiring lock %s: %v", key, err)
+	}
+
+	// Some databases report zero affected rows when nothing changed, so read back the holder
+	var holder string
+	if err := c.db.QueryRow(`select holder from leadership_locks where lock_key = ? limit 1;`, key).Scan(&holder); err != nil {
+		return fmt.Errorf("reading lock %s: %v", key, err)
+	}
+	if holder != c.cfg.Identity {
+		return fmt.Errorf("we are not the leader of %s (held by %s)", key, holder)
+	}
+	return nil
+}
+
+// fairShare is the number of locks this replica may hold: every known lock divided across
+// each replica with an unexpired heartbeat, rounded up.
+func (c *Client) fairShare(now time.Time) (int, error) {
+	var locks, members int
+	if err := c.db.QueryRow(`select count(*) from leadership_locks;`).Scan(&locks); err != nil {
+		return 0, fmt.Errorf("counting locks: %v", err)
+	}
+	err := c.db.QueryRow(`select count(*) from leadership_members where identity <> ? and expires_at >= ?;`, c.cfg.Identity, now).Scan(&members)
+	if err != nil {
+		return 0, fmt.Errorf("counting replicas: %v", err)
+	}
+	members++ // ourself
+	return (locks + members - 1) / members, nil
+}
+
+func (c *Client) heldCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := 0
+	for _, held := range c.held {
+		if held {
+			count++
+		}
+	}
+	return count
+}
+
+func (c *Client) heldKeys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var keys []string
+	for key, held := range c.held {
+		if held {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// heartbeat marks this replica as running so it's counted in each replica's fair share
+func (c *Client) heartbeat() error {
+	expires := c.timestamp().Add(c.cfg.LockDuration)
+	res, err := c.db.Exec(`update leadership_members set expires_at = ? where identity = ?;`, expires, c.cfg.Identity)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	_, err = c.db.Exec(`insert into leadership_members (identity, expires_at) values (?, ?);`, c.cfg.Identity, expires)
+	if err != nil && database.UniqueViolation(err) {
+		return nil
+	}
+	return err
+}
+
+// renewHeldLocks keeps leadership of each held lock between calls to AcquireLock and releases
+// locks past our fair share after other replicas start.
+func (c *Client) renewHeldLocks() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.cfg.LockDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.renew()
+
+		case <-c.shutdown:
+			return
+		}
+	}
+}
+
+func (c *Client) renew() {
+	if err := c.heartbeat(); err != nil {
+		c.logger.Warn().Logf("problem updating heartbeat: %v", err)
+	}
+
+	keys := c.heldKeys()
+	share, err := c.fairShare(c.timestamp())
+	if err != nil {
+		c.logger.Warn().Logf("problem computing fair share: %v", err)
+		share = len(keys)
+	}
+	for i, key := range keys {
+		if i >= share {
+			c.logger.Info().Logf("releasing lock %s to rebalance across replicas", key)
+			c.releaseKey(key)
+			continue
+		}
+		if err := c.acquire(key, true); err != nil {
+			c.logger.Warn().Logf("lost lock %s: %v", key, err)
+			c.mu.Lock()
+			c.held[key] = false
+			c.mu.Unlock()
+		}
+	}
+}
+
+// Shutdown stops renewing and releases every held lock so another replica can take over
+func (c *Client) Shutdown() {
+	if c == nil {
+		return
+	}
+	c.shutdownOnce.Do(func() {
+		close(c.shutdown)
+	})
+	c.wg.Wait()
+
+	for _, key := range c.heldKeys() {
+		c.releaseKey(key)
+	}
+	if _, err := c.db.Exec(`delete from leadership_members where identity = ?;`, c.cfg.Identity); err != nil {
+		c.logger.Warn().Logf("problem removing heartbeat: %v", err)
+	}
+}
+
+func (c *Client) releaseKey(key string) {
+	_, err := c.db.Exec(`update leadership_locks set holder = '' where lock_key = ? and holder = ?;`, key, c.cfg.Identity)
+	if err != nil {
+		c.logger.Warn().Logf("releasing lock %s: %v", key, err)
+	}
+	c.mu.Lock()
+	c.held[key] = false
+	c.mu.Unlock()
+}
+
+// timestamp is the current time in UTC with the precision DATETIME columns store
+func (c *Client) timestamp() time.Time {
+	return c.now().UTC().Truncate(time.Second)
+}
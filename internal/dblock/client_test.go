@@ -0,0 +1,81 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dblock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig__Validate(t *testing.T) {
+	var cfg *Config
+	require.NoError(t, cfg.Validate())
+
+	cfg = &Config{LockDuration: time.Millisecond}
+	require.Error(t, cfg.Validate())
+
+	cfg = &Config{Identity: "achgateway-0", LockDuration: time.Minute}
+	require.NoError(t, cfg.Validate())
+}
+
+func TestClient(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	first, err := NewClient(log.NewTestLogger(), db.DB, &Config{Identity: "first", LockDuration: time.Hour})
+	require.NoError(t, err)
+	t.Cleanup(first.Shutdown)
+
+	// Alone, the first replica leads every shard
+	require.NoError(t, first.AcquireLock("achgateway/outbound/live"))
+	require.NoError(t, first.AcquireLock("achgateway/outbound/sandbox"))
+	require.NoError(t, first.AcquireLock("achgateway/outbound/testing"))
+	require.NoError(t, first.AcquireLock("achgateway/outbound/live"))
+
+	second, err := NewClient(log.NewTestLogger(), db.DB, &Config{Identity: "second", LockDuration: time.Hour})
+	require.NoError(t, err)
+	t.Cleanup(second.Shutdown)
+
+	err = second.AcquireLock("achgateway/outbound/live")
+	require.ErrorContains(t, err, "held by first")
+
+	// The first replica gives up locks past its share of 2 once the second replica starts
+	first.renew()
+	require.Equal(t, []string{"achgateway/outbound/live", "achgateway/outbound/sandbox"}, first.heldKeys())
+	require.NoError(t, second.AcquireLock("achgateway/outbound/testing"))
+
+	err = first.AcquireLock("achgateway/outbound/testing")
+	require.ErrorContains(t, err, "our share of 2 locks")
+
+	// A new shard goes to the second replica, which is under its fair share
+	require.NoError(t, second.AcquireLock("achgateway/outbound/staging"))
+
+	// Expired locks can be taken over, and expired replicas don't count toward the fair share
+	first.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	require.NoError(t, first.AcquireLock("achgateway/outbound/staging"))
+	first.now = time.Now
+
+	// Shutdown releases each lock
+	first.Shutdown()
+	require.NoError(t, second.AcquireLock("achgateway/outbound/live"))
+}
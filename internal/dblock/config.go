@@ -0,0 +1,64 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package dblock elects leaders with rows in the achgateway database so replicas sharing a
+// database can split shards among themselves without running Consul or Kubernetes.
+package dblock
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const defaultLockDuration = 2 * time.Minute
+
+type Config struct {
+	// Identity is written as the holder of each lock. Defaults to the hostname.
+	Identity string
+
+	// LockDuration is how long a lock is held without being renewed. Held locks are renewed
+	// every third of LockDuration. Defaults to 2m
+	LockDuration time.Duration
+}
+
+func (cfg *Config) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.LockDuration < 0 || (cfg.LockDuration > 0 && cfg.LockDuration < time.Second) {
+		return fmt.Errorf("invalid LockDuration %v", cfg.LockDuration)
+	}
+	if len(cfg.Identity) > 200 {
+		return fmt.Errorf("identity %q is longer than 200 characters", cfg.Identity)
+	}
+	return nil
+}
+
+func (cfg Config) withDefaults() (Config, error) {
+	if cfg.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return cfg, fmt.Errorf("missing Identity: %v", err)
+		}
+		cfg.Identity = hostname
+	}
+	if cfg.LockDuration == 0 {
+		cfg.LockDuration = defaultLockDuration
+	}
+	return cfg, nil
+}
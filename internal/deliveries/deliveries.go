@@ -0,0 +1,110 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package deliveries correlates the files an ODFI splits one logical delivery (like a day's returns)
+// across, so a delivery is known to be complete or missing files.
+package deliveries
+
+import (
+	"sort"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/pkg/models"
+)
+
+const (
+	StatusPending    = "pending"
+	StatusComplete   = "complete"
+	StatusIncomplete = "incomplete"
+)
+
+// Delivery is the set of files received for one delivery
+type Delivery struct {
+	ID              string
+	Total           int
+	Status          string
+	FirstReceivedAt time.Time
+	UpdatedAt       time.Time
+
+	// Files are ordered by their sequence
+	Files []models.DeliveryFile
+}
+
+// Missing returns the sequence numbers (from 1 to Total) which haven't been received
+func (d *Delivery) Missing() []int {
+	received := make(map[int]bool, len(d.Files))
+	for i := range d.Files {
+		received[d.Files[i].Sequence] = true
+	}
+	out := []int{}
+	for seq := 1; seq <= d.Total; seq++ {
+		if !received[seq] {
+			out = append(out, seq)
+		}
+	}
+	return out
+}
+
+// Summary totals every file received for the delivery
+func (d *Delivery) Summary() models.DeliverySummary {
+	var out models.DeliverySummary
+	for i := range d.Files {
+		out.Add(d.Files[i].DeliverySummary)
+	}
+	return out
+}
+
+func (d *Delivery) sortFiles() {
+	sort.Slice(d.Files, func(i, j int) bool {
+		return d.Files[i].Sequence < d.Files[j].Sequence
+	})
+}
+
+// Summarize counts the entries, returns and corrections of file and totals its amounts
+func Summarize(file *ach.File) models.DeliverySummary {
+	var out models.DeliverySummary
+	if file == nil {
+		return out
+	}
+	for i := range file.Batches {
+		for _, entry := range file.Batches[i].GetEntries() {
+			out.Entries++
+			switch entry.CreditOrDebit() {
+			case "C":
+				out.CreditTotal += entry.Amount
+			case "D":
+				out.DebitTotal += entry.Amount
+			}
+		}
+	}
+	for i := range file.ReturnEntries {
+		for _, entry := range file.ReturnEntries[i].GetEntries() {
+			if entry.Addenda99 != nil {
+				out.Returns++
+			}
+		}
+	}
+	for i := range file.NotificationOfChange {
+		for _, entry := range file.NotificationOfChange[i].GetEntries() {
+			if entry.Addenda98 != nil {
+				out.Corrections++
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deliveries
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelivery(t *testing.T) {
+	d := &Delivery{
+		Total: 3,
+		Files: []models.DeliveryFile{
+			{Sequence: 3, DeliverySummary: models.DeliverySummary{Entries: 2, DebitTotal: 100}},
+			{Sequence: 1, DeliverySummary: models.DeliverySummary{Entries: 1, Returns: 1, CreditTotal: 25}},
+		},
+	}
+	require.Equal(t, []int{2}, d.Missing())
+	require.Equal(t, models.DeliverySummary{
+		Entries:     3,
+		Returns:     1,
+		DebitTotal:  100,
+		CreditTotal: 25,
+	}, d.Summary())
+
+	d.sortFiles()
+	require.Equal(t, 1, d.Files[0].Sequence)
+
+	d.Files = append(d.Files, models.DeliveryFile{Sequence: 2, ReceivedAt: time.Now()})
+	require.Empty(t, d.Missing())
+}
+
+func TestSummarize(t *testing.T) {
+	require.Equal(t, models.DeliverySummary{}, Summarize(nil))
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	require.Equal(t, models.DeliverySummary{Entries: 1, DebitTotal: 10500}, Summarize(file))
+
+	file, err = ach.ReadFile(filepath.Join("..", "..", "testdata", "cor-c01.ach"))
+	require.NoError(t, err)
+	require.Equal(t, models.DeliverySummary{Entries: 1, Corrections: 1}, Summarize(file))
+}
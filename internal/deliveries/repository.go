@@ -0,0 +1,183 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deliveries
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/achgateway/pkg/models"
+)
+
+type Repository interface {
+	// Add records file as part of the delivery with id, which is made up of total files, and
+	// returns the delivery. Files already received are ignored.
+	Add(id string, total int, file models.DeliveryFile) (*Delivery, error)
+
+	// Get returns the delivery with id, or nil
+	Get(id string) (*Delivery, error)
+
+	// Pending returns the deliveries still waiting for files which were first received before when
+	Pending(before time.Time) ([]*Delivery, error)
+
+	SetStatus(id, status string) error
+}
+
+// NewRepository returns a Repository stored in db, or nil when db is nil
+func NewRepository(db *sql.DB) Repository {
+	if db == nil {
+		return nil
+	}
+	return &sqlRepository{db: db, now: time.Now}
+}
+
+type sqlRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func (r *sqlRepository) Add(id string, total int, file models.DeliveryFile) (*Delivery, error) {
+	now := r.timestamp()
+	if file.ReceivedAt.IsZero() {
+		file.ReceivedAt = now
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("adding delivery %s file: %v", id, err)
+	}
+	defer tx.Rollback()
+
+	var existing int
+	err = tx.QueryRow(`select total from odfi_deliveries where delivery_id = ? limit 1;`, id).Scan(&existing)
+	switch {
+	case err == sql.ErrNoRows:
+		query := `insert into odfi_deliveries (delivery_id, total, status, first_received_at, updated_at) values (?, ?, ?, ?, ?);`
+		if _, err := tx.Exec(query, id, total, StatusPending, file.ReceivedAt, now); err != nil {
+			return nil, fmt.Errorf("creating delivery %s: %v", id, err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("reading delivery %s: %v", id, err)
+	case existing != total:
+		return nil, fmt.Errorf("delivery %s has %d files, not %d", id, existing, total)
+	}
+
+	var count int
+	err = tx.QueryRow(`select count(*) from odfi_delivery_files where delivery_id = ? and sequence = ?;`, id, file.Sequence).Scan(&count)
+	if err != nil {
+		return nil, fmt.Errorf("reading delivery %s file %d: %v", id, file.Sequence, err)
+	}
+	if count == 0 {
+		query := `insert into odfi_delivery_files (delivery_id, sequence, filename, entries, returns, corrections, debit_total, credit_total, received_at) values (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+		_, err := tx.Exec(query, id, file.Sequence, file.Filename, file.Entries, file.Returns, file.Corrections, file.DebitTotal, file.CreditTotal, file.ReceivedAt)
+		if err != nil {
+			return nil, fmt.Errorf("adding delivery %s file %d: %v", id, file.Sequence, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("adding delivery %s file: %v", id, err)
+	}
+	return r.Get(id)
+}
+
+func (r *sqlRepository) Get(id string) (*Delivery, error) {
+	query := `select delivery_id, total, status, first_received_at, updated_at from odfi_deliveries where delivery_id = ? limit 1;`
+
+	var d Delivery
+	err := r.db.QueryRow(query, id).Scan(&d.ID, &d.Total, &d.Status, &d.FirstReceivedAt, &d.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading delivery %s: %v", id, err)
+	}
+	if err := r.readFiles(&d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *sqlRepository) readFiles(d *Delivery) error {
+	query := `select sequence, filename, entries, returns, corrections, debit_total, credit_total, received_at from odfi_delivery_files where delivery_id = ?;`
+	rows, err := r.db.Query(query, d.ID)
+	if err != nil {
+		return fmt.Errorf("reading delivery %s files: %v", d.ID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f models.DeliveryFile
+		err := rows.Scan(&f.Sequence, &f.Filename, &f.Entries, &f.Returns, &f.Corrections, &f.DebitTotal, &f.CreditTotal, &f.ReceivedAt)
+		if err != nil {
+			return fmt.Errorf("reading delivery %s files: %v", d.ID, err)
+		}
+		d.Files = append(d.Files, f)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading delivery %s files: %v", d.ID, err)
+	}
+	d.sortFiles()
+	return nil
+}
+
+func (r *sqlRepository) Pending(before time.Time) ([]*Delivery, error) {
+	query := `select delivery_id from odfi_deliveries where status = ? and first_received_at < ? order by first_received_at;`
+	rows, err := r.db.Query(query, StatusPending, before.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("listing pending deliveries: %v", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("listing pending deliveries: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing pending deliveries: %v", err)
+	}
+
+	var out []*Delivery
+	for _, id := range ids {
+		d, err := r.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (r *sqlRepository) SetStatus(id, status string) error {
+	query := `update odfi_deliveries set status = ?, updated_at = ? where delivery_id = ?;`
+	if _, err := r.db.Exec(query, status, r.timestamp(), id); err != nil {
+		return fmt.Errorf("updating delivery %s: %v", id, err)
+	}
+	return nil
+}
+
+// timestamp is the current time in UTC with the precision DATETIME columns store
+func (r *sqlRepository) timestamp() time.Time {
+	return r.now().UTC().Truncate(time.Second)
+}
@@ -0,0 +1,93 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deliveries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository(t *testing.T) {
+	require.Nil(t, NewRepository(nil))
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := NewRepository(db.DB)
+
+	got, err := repo.Get("missing")
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	received := time.Date(2022, time.June, 1, 10, 0, 0, 0, time.UTC)
+	second := models.DeliveryFile{
+		Sequence:        2,
+		Filename:        "RET_20220601_2of3.ach",
+		ReceivedAt:      received,
+		DeliverySummary: models.DeliverySummary{Entries: 2, Returns: 2, DebitTotal: 150},
+	}
+	delivery, err := repo.Add("20220601", 3, second)
+	require.NoError(t, err)
+	require.Equal(t, StatusPending, delivery.Status)
+	require.Equal(t, received, delivery.FirstReceivedAt.UTC())
+	require.Equal(t, []int{1, 3}, delivery.Missing())
+
+	// Files received again aren't duplicated
+	delivery, err = repo.Add("20220601", 3, second)
+	require.NoError(t, err)
+	require.Len(t, delivery.Files, 1)
+
+	// The number of files can't change
+	_, err = repo.Add("20220601", 4, models.DeliveryFile{Sequence: 1})
+	require.ErrorContains(t, err, "delivery 20220601 has 3 files, not 4")
+
+	delivery, err = repo.Add("20220601", 3, models.DeliveryFile{
+		Sequence:        1,
+		Filename:        "RET_20220601_1of3.ach",
+		DeliverySummary: models.DeliverySummary{Entries: 1, Corrections: 1},
+	})
+	require.NoError(t, err)
+	require.Len(t, delivery.Files, 2)
+	require.Equal(t, 1, delivery.Files[0].Sequence)
+	require.False(t, delivery.Files[0].ReceivedAt.IsZero())
+	require.Equal(t, []int{3}, delivery.Missing())
+	require.Equal(t, models.DeliverySummary{Entries: 3, Returns: 2, Corrections: 1, DebitTotal: 150}, delivery.Summary())
+
+	pending, err := repo.Pending(received)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	pending, err = repo.Pending(received.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, "20220601", pending[0].ID)
+	require.Len(t, pending[0].Files, 2)
+
+	require.NoError(t, repo.SetStatus("20220601", StatusIncomplete))
+	pending, err = repo.Pending(received.Add(time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	delivery, err = repo.Get("20220601")
+	require.NoError(t, err)
+	require.Equal(t, StatusIncomplete, delivery.Status)
+}
@@ -0,0 +1,245 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package dev implements `achgateway --dev` which runs achgateway with an embedded FTP server,
+// in-memory streams, SQLite persistence and a seeded shard so applications can integrate against
+// a working gateway without any other services.
+package dev
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/incoming/stream"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/util"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"goftp.io/server"
+	"goftp.io/server/driver/file"
+)
+
+const (
+	// ShardName is the shard files are accepted into, also mapped from the "testing" shard key
+	ShardName = "dev"
+
+	// AgentID is the upload agent connected to the embedded FTP server
+	AgentID = "dev-ftp"
+
+	ftpUsername = "achgateway"
+	ftpPassword = "password"
+)
+
+// agentPaths are created under the FTP server's root directory
+var agentPaths = service.UploadPaths{
+	Inbound:        "inbound",
+	Outbound:       "outbound",
+	Reconciliation: "reconciliation",
+	Return:         "returned",
+}
+
+// Options configures the developer mode gateway.
+type Options struct {
+	// Directory holds the FTP server's files, merging storage and the SQLite database
+	Directory string
+
+	FTPPort int
+
+	// CutoffInterval is how often the dev shard has a cutoff window
+	CutoffInterval time.Duration
+
+	// Webhook receives events when set
+	Webhook string
+}
+
+// ParseFlags reads Options from the arguments following --dev
+func ParseFlags(args []string) (Options, error) {
+	var opts Options
+	fs := flag.NewFlagSet("achgateway --dev", flag.ContinueOnError)
+	fs.StringVar(&opts.Directory, "dir", "achgateway-dev", "Directory for FTP files, merging storage and the SQLite database")
+	fs.IntVar(&opts.FTPPort, "ftp-port", 2121, "Port of the embedded FTP server")
+	fs.DurationVar(&opts.CutoffInterval, "cutoff-interval", 15*time.Minute, "How often the dev shard has a cutoff window")
+	fs.StringVar(&opts.Webhook, "webhook", "", "Optional URL events are POSTed to")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// Setup starts the embedded FTP server and returns the config for the developer mode gateway.
+// The returned func stops the FTP server.
+func Setup(logger log.Logger, opts Options) (*service.Config, func(), error) {
+	if opts.Directory == "" {
+		return nil, nil, errors.New("missing Directory")
+	}
+	if opts.CutoffInterval < time.Minute || opts.CutoffInterval > 24*time.Hour {
+		return nil, nil, fmt.Errorf("invalid cutoff interval %v", opts.CutoffInterval)
+	}
+	dir, err := filepath.Abs(opts.Directory)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root := filepath.Join(dir, "ftp")
+	for _, path := range []string{agentPaths.Inbound, agentPaths.Outbound, agentPaths.Reconciliation, agentPaths.Return} {
+		if err := os.MkdirAll(filepath.Join(root, path), 0755); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	svc, err := startFTPServer(root, opts.FTPPort)
+	if err != nil {
+		return nil, nil, fmt.Errorf("starting ftp server: %v", err)
+	}
+	logger.Info().Logf("dev: FTP server listening on localhost:%d serving %s", opts.FTPPort, root)
+
+	cfg := devConfig(dir, opts)
+	if err := cfg.Validate(); err != nil {
+		svc.Shutdown()
+		return nil, nil, err
+	}
+
+	// In-memory subscriptions can only be opened once their topic exists
+	topic, err := stream.Topic(logger, cfg)
+	if err != nil {
+		svc.Shutdown()
+		return nil, nil, fmt.Errorf("creating in-memory stream: %v", err)
+	}
+
+	return cfg, func() {
+		topic.Shutdown(context.Background())
+		svc.Shutdown()
+	}, nil
+}
+
+func startFTPServer(root string, port int) (*server.Server, error) {
+	svc := server.NewServer(&server.ServerOpts{
+		Auth: &server.SimpleAuth{
+			Name:     ftpUsername,
+			Password: ftpPassword,
+		},
+		Factory: &file.DriverFactory{
+			RootPath: root,
+			Perm:     server.NewSimplePerm("achgateway", "achgateway"),
+		},
+		Hostname: "localhost",
+		Port:     port,
+		Logger:   &server.DiscardLogger{},
+	})
+	if svc == nil {
+		return nil, errors.New("nil FTP server")
+	}
+	// ListenAndServe blocks while the server is running, so wait briefly for any startup error
+	if err := util.Timeout(func() error { return svc.ListenAndServe() }, 50*time.Millisecond); err != nil && err != util.ErrTimeout {
+		return nil, err
+	}
+	return svc, nil
+}
+
+func devConfig(dir string, opts Options) *service.Config {
+	cfg := &service.Config{
+		Database: database.DatabaseConfig{
+			DatabaseName: "achgateway",
+			SQLite: &database.SQLiteConfig{
+				Path: filepath.Join(dir, "achgateway.db"),
+			},
+		},
+		Admin: service.Admin{
+			BindAddress: ":9494",
+		},
+		Inbound: service.Inbound{
+			HTTP: service.HTTPConfig{
+				BindAddress: ":8484",
+			},
+			InMem: &service.InMemory{
+				URL: "mem://achgateway-dev",
+			},
+			ODFI: &service.ODFIFiles{
+				Processors: service.ODFIProcessors{
+					Corrections:    service.ODFICorrections{Enabled: true},
+					Incoming:       service.ODFIIncoming{Enabled: true},
+					Reconciliation: service.ODFIReconciliation{Enabled: true},
+					Prenotes:       service.ODFIPrenotes{Enabled: true},
+					Returns:        service.ODFIReturns{Enabled: true},
+				},
+				Interval:   time.Minute,
+				ShardNames: []string{ShardName},
+				Storage: service.ODFIStorage{
+					Directory:             filepath.Join(dir, "odfi"),
+					CleanupLocalDirectory: true,
+				},
+			},
+		},
+		Sharding: service.Sharding{
+			Shards: []service.Shard{
+				{
+					Name: ShardName,
+					Cutoffs: service.Cutoffs{
+						Timezone: "UTC",
+						Windows:  cutoffWindows(opts.CutoffInterval),
+					},
+					UploadAgent: AgentID,
+				},
+			},
+			Mappings: map[string]service.ShardMapping{
+				"testing": {ShardKey: "testing", ShardName: ShardName},
+			},
+			Default: ShardName,
+		},
+		Upload: service.UploadAgents{
+			Agents: []service.UploadAgent{
+				{
+					ID: AgentID,
+					FTP: &service.FTP{
+						Hostname: fmt.Sprintf("localhost:%d", opts.FTPPort),
+						Username: ftpUsername,
+						Password: ftpPassword,
+					},
+					Paths: agentPaths,
+				},
+			},
+			Merging: service.Merging{
+				Directory: filepath.Join(dir, "storage"),
+			},
+			DefaultAgentID: AgentID,
+		},
+	}
+	if opts.Webhook != "" {
+		cfg.Events = &service.EventsConfig{
+			Webhook: &service.WebhookConfig{
+				Endpoint: opts.Webhook,
+			},
+		}
+	}
+	return cfg
+}
+
+// cutoffWindows returns the times of day every interval starting at midnight
+func cutoffWindows(interval time.Duration) []string {
+	var out []string
+	midnight := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for when := midnight; when.Day() == midnight.Day(); when = when.Add(interval) {
+		out = append(out, when.Format("15:04"))
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dev
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping due to -short")
+	}
+
+	opts, err := ParseFlags([]string{
+		"-dir", t.TempDir(),
+		"-webhook", "http://localhost:8080/events",
+	})
+	require.NoError(t, err)
+	opts.FTPPort = 30000 + rand.Intn(9999)
+
+	cfg, stop, err := Setup(log.NewTestLogger(), opts)
+	require.NoError(t, err)
+	t.Cleanup(stop)
+
+	require.NotNil(t, cfg.Database.SQLite)
+	require.Equal(t, "http://localhost:8080/events", cfg.Events.Webhook.Endpoint)
+	require.Equal(t, ShardName, cfg.Sharding.Default)
+	require.Len(t, cfg.Sharding.Shards[0].Cutoffs.Windows, 96)
+
+	// Upload a file to the embedded FTP server
+	agent, err := upload.New(log.NewTestLogger(), cfg.Upload, AgentID)
+	require.NoError(t, err)
+	t.Cleanup(func() { agent.Close() })
+
+	err = agent.UploadFile(upload.File{
+		Filename: "20220812-103000-076401251.ach",
+		Contents: io.NopCloser(bytes.NewReader([]byte("nacha"))),
+	})
+	require.NoError(t, err)
+
+	bs, err := os.ReadFile(filepath.Join(opts.Directory, "ftp", "outbound", "20220812-103000-076401251.ach"))
+	require.NoError(t, err)
+	require.Equal(t, "nacha", string(bs))
+
+	files, err := agent.GetReturnFiles()
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func TestSetup__Errors(t *testing.T) {
+	_, _, err := Setup(log.NewTestLogger(), Options{})
+	require.ErrorContains(t, err, "missing Directory")
+
+	_, _, err = Setup(log.NewTestLogger(), Options{Directory: t.TempDir(), CutoffInterval: time.Second})
+	require.ErrorContains(t, err, "invalid cutoff interval 1s")
+}
+
+func TestCutoffWindows(t *testing.T) {
+	require.Equal(t, []string{"00:00", "06:00", "12:00", "18:00"}, cutoffWindows(6*time.Hour))
+
+	windows := cutoffWindows(25 * time.Minute)
+	require.Equal(t, "00:25", windows[1])
+	require.Equal(t, "23:45", windows[len(windows)-1])
+}
@@ -0,0 +1,231 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package dishonor creates the dishonored returns an ODFI sends back for returns it received
+// and the contested dishonored returns an RDFI sends in response to those.
+package dishonor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moov-io/ach"
+)
+
+// Options controls which entries are responded to and how.
+type Options struct {
+	// Code is the dishonored (R61, R67-R70) or contested (R71-R76) return code used for each entry.
+	Code string
+
+	// TraceNumbers limits the response to these entries, matched against each entry's trace
+	// number or the trace number of the original entry. Every entry is used when empty.
+	TraceNumbers []string
+
+	// AddendaInformation is written into each Addenda99Dishonored
+	AddendaInformation string
+
+	// OriginalEntryReturned is when the original entry was returned, written into each Addenda99Contested
+	OriginalEntryReturned time.Time
+
+	// Now is used for file creation and effective entry dates. Defaults to time.Now()
+	Now time.Time
+}
+
+func (opts Options) now() time.Time {
+	if opts.Now.IsZero() {
+		return time.Now()
+	}
+	return opts.Now
+}
+
+func (opts Options) includes(traceNumbers ...string) bool {
+	if len(opts.TraceNumbers) == 0 {
+		return true
+	}
+	for i := range opts.TraceNumbers {
+		for j := range traceNumbers {
+			if opts.TraceNumbers[i] == traceNumbers[j] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Dishonor creates a file dishonoring each return entry of returns with opts.Code. The file is
+// sent by the ODFI which received the returns back to each RDFI that returned an entry.
+func Dishonor(returns *ach.File, opts Options) (*ach.File, error) {
+	if !ach.IsDishonoredReturnCode(opts.Code) {
+		return nil, fmt.Errorf("invalid dishonored return code %q", opts.Code)
+	}
+	return respond(returns, opts, func(bh *ach.BatchHeader, original *ach.EntryDetail) (string, bool) {
+		if original.Addenda99 == nil || !opts.includes(original.TraceNumber, original.Addenda99.OriginalTrace) {
+			return "", false
+		}
+		if original.Addenda99.OriginalDFI != "" {
+			return original.Addenda99.OriginalDFI, true
+		}
+		return bh.ODFIIdentification, true
+	}, func(bh *ach.BatchHeader, original, entry *ach.EntryDetail) {
+		addenda := ach.NewAddenda99Dishonored()
+		addenda.DishonoredReturnReasonCode = opts.Code
+		addenda.OriginalEntryTraceNumber = original.Addenda99.OriginalTrace
+		addenda.OriginalReceivingDFIIdentification = original.Addenda99.OriginalDFI
+		addenda.ReturnTraceNumber = original.TraceNumber
+		addenda.ReturnSettlementDate = bh.SettlementDate
+		addenda.ReturnReasonCode = reasonCode(original.Addenda99.ReturnCode)
+		addenda.AddendaInformation = opts.AddendaInformation
+		addenda.TraceNumber = entry.TraceNumber
+
+		entry.Category = ach.CategoryDishonoredReturn
+		entry.Addenda99Dishonored = addenda
+	})
+}
+
+// Contest creates a file contesting each dishonored return entry of dishonored with opts.Code.
+// The file is sent by the RDFI which received the dishonored returns back to each ODFI.
+func Contest(dishonored *ach.File, opts Options) (*ach.File, error) {
+	if !ach.IsContestedReturnCode(opts.Code) {
+		return nil, fmt.Errorf("invalid contested dishonored return code %q", opts.Code)
+	}
+	return respond(dishonored, opts, func(bh *ach.BatchHeader, original *ach.EntryDetail) (string, bool) {
+		if original.Addenda99Dishonored == nil || !opts.includes(original.TraceNumber, original.Addenda99Dishonored.OriginalEntryTraceNumber) {
+			return "", false
+		}
+		return bh.ODFIIdentification, true
+	}, func(bh *ach.BatchHeader, original, entry *ach.EntryDetail) {
+		dishonor := original.Addenda99Dishonored
+
+		addenda := ach.NewAddenda99Contested()
+		addenda.ContestedReturnCode = opts.Code
+		addenda.OriginalEntryTraceNumber = dishonor.OriginalEntryTraceNumber
+		if !opts.OriginalEntryReturned.IsZero() {
+			addenda.DateOriginalEntryReturned = opts.OriginalEntryReturned.Format("060102")
+		}
+		addenda.OriginalReceivingDFIIdentification = dishonor.OriginalReceivingDFIIdentification
+		addenda.ReturnTraceNumber = dishonor.ReturnTraceNumber
+		addenda.ReturnSettlementDate = dishonor.ReturnSettlementDate
+		addenda.ReturnReasonCode = reasonCode(dishonor.ReturnReasonCode)
+		addenda.DishonoredReturnTraceNumber = original.TraceNumber
+		addenda.DishonoredReturnSettlementDate = bh.SettlementDate
+		addenda.DishonoredReturnReasonCode = reasonCode(dishonor.DishonoredReturnReasonCode)
+		addenda.TraceNumber = entry.TraceNumber
+
+		entry.Category = ach.CategoryDishonoredReturnContested
+		entry.Addenda99Contested = addenda
+	})
+}
+
+// reasonCode returns the two digit form (e.g. 01 for R01) addenda use for earlier return codes
+func reasonCode(code string) string {
+	return strings.TrimPrefix(strings.TrimSpace(code), "R")
+}
+
+// destinationFunc returns the routing number original is sent back to, or false to skip it
+type destinationFunc func(bh *ach.BatchHeader, original *ach.EntryDetail) (string, bool)
+
+type addendaFunc func(bh *ach.BatchHeader, original, entry *ach.EntryDetail)
+
+// respond builds a file back to the sender of file with an entry for each entry accepted by
+// destination. Entries are batched by the financial institution they're sent to.
+func respond(file *ach.File, opts Options, destination destinationFunc, addenda addendaFunc) (*ach.File, error) {
+	if file == nil {
+		return nil, errors.New("nil File")
+	}
+	now := opts.now()
+
+	out := ach.NewFile()
+	out.Header = ach.NewFileHeader()
+	out.Header.ImmediateDestination = file.Header.ImmediateOrigin
+	out.Header.ImmediateDestinationName = file.Header.ImmediateOriginName
+	out.Header.ImmediateOrigin = file.Header.ImmediateDestination
+	out.Header.ImmediateOriginName = file.Header.ImmediateDestinationName
+	out.Header.FileCreationDate = now.Format("060102")
+	out.Header.FileCreationTime = now.Format("1504")
+	out.Header.FileIDModifier = "A"
+	out.SetValidation(file.GetValidation())
+
+	for _, batch := range file.Batches {
+		bh := batch.GetHeader()
+
+		// Group entries by where they're sent and who is sending them
+		type route struct{ from, to string }
+		var routes []route
+		entries := make(map[route][]*ach.EntryDetail)
+		for _, entry := range batch.GetEntries() {
+			to, ok := destination(bh, entry)
+			if !ok {
+				continue
+			}
+			r := route{from: entry.RDFIIdentification, to: to}
+			if _, exists := entries[r]; !exists {
+				routes = append(routes, r)
+			}
+			entries[r] = append(entries[r], entry)
+		}
+
+		for _, r := range routes {
+			header := *bh
+			header.ID = ""
+			header.ODFIIdentification = r.from
+			header.EffectiveEntryDate = now.Format("060102")
+			header.SettlementDate = ""
+			header.BatchNumber = len(out.Batches) + 1
+
+			b, err := ach.NewBatch(&header)
+			if err != nil {
+				return nil, fmt.Errorf("batch %s: %v", bh.ID, err)
+			}
+			for i, original := range entries[r] {
+				entry := respondToEntry(r.from, r.to, i+1, original)
+				addenda(bh, original, entry)
+				b.AddEntry(entry)
+			}
+			if err := b.Create(); err != nil {
+				return nil, fmt.Errorf("creating batch: %v", err)
+			}
+			out.AddBatch(b)
+		}
+	}
+	if len(out.Batches) == 0 {
+		return nil, errors.New("no matching entries found")
+	}
+	if err := out.Create(); err != nil {
+		return nil, fmt.Errorf("creating file: %v", err)
+	}
+	return out, nil
+}
+
+// respondToEntry creates the entry sent from the original entry's receiver (from) back to its
+// sender (to). Dishonored and contested returns keep the return transaction code.
+func respondToEntry(from, to string, seq int, original *ach.EntryDetail) *ach.EntryDetail {
+	entry := ach.NewEntryDetail()
+	entry.TransactionCode = original.TransactionCode
+	entry.RDFIIdentification = to
+	entry.CheckDigit = strconv.Itoa(entry.CalculateCheckDigit(to))
+	entry.DFIAccountNumber = original.DFIAccountNumber
+	entry.Amount = original.Amount
+	entry.IdentificationNumber = original.IdentificationNumber
+	entry.IndividualName = original.IndividualName
+	entry.DiscretionaryData = original.DiscretionaryData
+	entry.AddendaRecordIndicator = 1
+	entry.SetTraceNumber(from, seq)
+	return entry
+}
@@ -0,0 +1,144 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dishonor
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readReturns(t *testing.T) *ach.File {
+	t.Helper()
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "return-WEB.ach"))
+	require.NoError(t, err)
+	return file
+}
+
+// roundTrip writes file as Nacha and reads it back to ensure it's a valid file
+func roundTrip(t *testing.T, file *ach.File) *ach.File {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, ach.NewWriter(&buf).Write(file))
+
+	out, err := ach.NewReader(&buf).Read()
+	require.NoError(t, err)
+	return &out
+}
+
+func TestDishonor(t *testing.T) {
+	returns := readReturns(t)
+	now := time.Date(2022, time.August, 12, 10, 30, 0, 0, time.UTC)
+
+	file, err := Dishonor(returns, Options{Code: "R69", AddendaInformation: "INCORRECT ACCOUNT", Now: now})
+	require.NoError(t, err)
+	file = roundTrip(t, file)
+
+	require.Equal(t, returns.Header.ImmediateOrigin, file.Header.ImmediateDestination)
+	require.Equal(t, returns.Header.ImmediateDestination, file.Header.ImmediateOrigin)
+	require.Len(t, file.Batches, len(returns.Batches))
+
+	returned := returns.Batches[0].GetEntries()[0]
+	entries := file.Batches[0].GetEntries()
+	require.Len(t, entries, len(returns.Batches[0].GetEntries()))
+
+	entry := entries[0]
+	require.Equal(t, returned.TransactionCode, entry.TransactionCode)
+	require.Equal(t, returned.Amount, entry.Amount)
+	require.Equal(t, ach.CategoryDishonoredReturn, entry.Category)
+	require.Equal(t, returned.Addenda99.OriginalDFI, entry.RDFIIdentification)
+	require.Equal(t, returned.RDFIIdentification, file.Batches[0].GetHeader().ODFIIdentification)
+
+	addenda := entry.Addenda99Dishonored
+	require.NotNil(t, addenda)
+	require.Equal(t, "R69", addenda.DishonoredReturnReasonCode)
+	require.Equal(t, returned.Addenda99.OriginalTrace, addenda.OriginalEntryTraceNumber)
+	require.Equal(t, returned.TraceNumber, addenda.ReturnTraceNumber)
+	require.Equal(t, "01", addenda.ReturnReasonCode)
+	require.Equal(t, entry.TraceNumber, addenda.TraceNumber)
+}
+
+func TestDishonor__TraceNumbers(t *testing.T) {
+	returns := readReturns(t)
+	returned := returns.Batches[0].GetEntries()[0]
+
+	// Entries are matched by their original trace number
+	file, err := Dishonor(returns, Options{Code: "R68", TraceNumbers: []string{returned.Addenda99.OriginalTrace}})
+	require.NoError(t, err)
+	require.Len(t, file.Batches[0].GetEntries(), 1)
+
+	_, err = Dishonor(returns, Options{Code: "R68", TraceNumbers: []string{"999999999999999"}})
+	require.ErrorContains(t, err, "no matching entries found")
+}
+
+func TestDishonor__Errors(t *testing.T) {
+	_, err := Dishonor(readReturns(t), Options{Code: "R01"})
+	require.ErrorContains(t, err, `invalid dishonored return code "R01"`)
+
+	_, err = Dishonor(nil, Options{Code: "R69"})
+	require.ErrorContains(t, err, "nil File")
+
+	// Files without returns have nothing to dishonor
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	_, err = Dishonor(file, Options{Code: "R69"})
+	require.ErrorContains(t, err, "no matching entries found")
+}
+
+func TestContest(t *testing.T) {
+	now := time.Date(2022, time.August, 15, 10, 30, 0, 0, time.UTC)
+
+	dishonored, err := Dishonor(readReturns(t), Options{Code: "R69", Now: now.AddDate(0, 0, -3)})
+	require.NoError(t, err)
+	dishonored = roundTrip(t, dishonored)
+
+	file, err := Contest(dishonored, Options{Code: "R74", OriginalEntryReturned: now.AddDate(0, 0, -5), Now: now})
+	require.NoError(t, err)
+	file = roundTrip(t, file)
+
+	require.Equal(t, dishonored.Header.ImmediateOrigin, file.Header.ImmediateDestination)
+
+	original := dishonored.Batches[0].GetEntries()[0]
+	entry := file.Batches[0].GetEntries()[0]
+	require.Equal(t, ach.CategoryDishonoredReturnContested, entry.Category)
+	require.Equal(t, dishonored.Batches[0].GetHeader().ODFIIdentification, entry.RDFIIdentification)
+
+	addenda := entry.Addenda99Contested
+	require.NotNil(t, addenda)
+	require.Equal(t, "R74", addenda.ContestedReturnCode)
+	require.Equal(t, "220810", addenda.DateOriginalEntryReturned)
+	require.Equal(t, original.Addenda99Dishonored.OriginalEntryTraceNumber, addenda.OriginalEntryTraceNumber)
+	require.Equal(t, original.Addenda99Dishonored.ReturnTraceNumber, addenda.ReturnTraceNumber)
+	require.Equal(t, "01", addenda.ReturnReasonCode)
+	require.Equal(t, original.TraceNumber, addenda.DishonoredReturnTraceNumber)
+	require.Equal(t, "69", addenda.DishonoredReturnReasonCode)
+	require.Equal(t, entry.TraceNumber, addenda.TraceNumber)
+
+	_, err = Contest(dishonored, Options{Code: "R69"})
+	require.ErrorContains(t, err, `invalid contested dishonored return code "R69"`)
+
+	_, err = Contest(readReturns(t), Options{Code: "R74"})
+	require.ErrorContains(t, err, "no matching entries found")
+}
@@ -0,0 +1,106 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package doctor implements `achgateway agent doctor` which diagnoses the connection
+// to an upload agent's remote server.
+package doctor
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base/log"
+)
+
+const usage = `Usage: achgateway agent doctor <agent-id> [flags]
+
+Connects to the agent's remote server with the configured credentials and reports on:
+  allowed ips   hostname resolution and allowed_ips
+  host key      the SFTP server's host key compared against host_public_key
+  tls           the FTP ca_file
+  connect       authenticating with the remote server
+  <name> path   each configured path exists as a readable directory
+  transfer      upload and download throughput of a temporary file in the outbound path
+`
+
+// ErrChecksFailed is returned when the diagnosis found at least one failed check.
+var ErrChecksFailed = errors.New("agent doctor: checks failed")
+
+// Run executes the agent subcommand named by args[0] and writes its output to out.
+func Run(args []string, out io.Writer, logger log.Logger, loadConfig service.ConfigLoader) error {
+	if len(args) == 0 || args[0] != "doctor" {
+		fmt.Fprint(out, usage)
+		if len(args) == 0 {
+			return errors.New("missing command")
+		}
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	fs := flag.NewFlagSet("achgateway agent doctor", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprint(out, usage+"\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	flagSize := fs.Int64("size", 1024, "Size in KiB of the temporary file used to measure throughput")
+	flagSkipTransfer := fs.Bool("skip-transfer", false, "Skip writing a temporary file to the outbound path")
+
+	// Allow the agent ID before or after flags
+	args = args[1:]
+	var agentID string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		agentID, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if agentID == "" {
+		agentID = fs.Arg(0)
+	}
+	if agentID == "" {
+		fs.Usage()
+		return errors.New("missing agent-id")
+	}
+	if *flagSize <= 0 {
+		return fmt.Errorf("invalid -size %d", *flagSize)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %v", err)
+	}
+
+	diag, err := upload.Diagnose(logger, cfg.Upload, agentID, upload.DoctorOptions{
+		TransferSize: *flagSize * 1024,
+		SkipTransfer: *flagSkipTransfer,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := diag.WriteTo(out); err != nil {
+		return err
+	}
+	if diag.Failed() {
+		return ErrChecksFailed
+	}
+	return nil
+}
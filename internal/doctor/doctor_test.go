@@ -0,0 +1,62 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package doctor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	loadConfig := func() (*service.Config, error) {
+		return &service.Config{
+			Upload: service.UploadAgents{
+				Agents: []service.UploadAgent{
+					{ID: "mock", Mock: &service.MockAgent{}},
+				},
+			},
+		}, nil
+	}
+
+	var buf bytes.Buffer
+	err := Run([]string{"doctor", "mock", "-skip-transfer"}, &buf, log.NewNopLogger(), loadConfig)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "Agent:    mock\n")
+	require.Contains(t, buf.String(), "0 failed")
+
+	buf.Reset()
+	err = Run([]string{"doctor", "-size", "16", "mock"}, &buf, log.NewNopLogger(), loadConfig)
+	require.NoError(t, err)
+
+	err = Run([]string{"doctor", "missing"}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, "unknown Agent ID=missing")
+
+	err = Run([]string{"doctor"}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, "missing agent-id")
+
+	err = Run([]string{"doctor", "mock", "-size", "0"}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, "invalid -size")
+
+	err = Run([]string{"other"}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, `unknown command "other"`)
+}
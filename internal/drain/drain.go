@@ -0,0 +1,248 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package drain coordinates stopping an instance during rolling deploys. A draining instance
+// stops consuming new submissions and starting cutoffs or ODFI scans, finishes the work already
+// running and then releases its shard leaderships so another instance takes over.
+package drain
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/leadership"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+)
+
+// ErrDraining is returned when work is started after the instance began draining
+var ErrDraining = errors.New("instance is draining")
+
+// Coordinator tracks the work running on this instance. A nil Coordinator never drains.
+type Coordinator struct {
+	logger  log.Logger
+	elector leadership.Elector
+
+	mu        sync.Mutex
+	draining  chan struct{}
+	startedAt time.Time
+	inFlight  int
+	releasing bool
+	safe      chan struct{}
+	safeAt    time.Time
+}
+
+func New(logger log.Logger, elector leadership.Elector) *Coordinator {
+	return &Coordinator{
+		logger:   logger,
+		elector:  elector,
+		draining: make(chan struct{}),
+		safe:     make(chan struct{}),
+	}
+}
+
+// Drain starts draining the instance, calling it again has no effect
+func (c *Coordinator) Drain() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.draining:
+		return
+	default:
+	}
+	c.startedAt = time.Now()
+	close(c.draining)
+	c.logger.Info().Logf("draining with %d operations in flight", c.inFlight)
+
+	c.finishIfIdle()
+}
+
+// Draining is closed once the instance starts draining
+func (c *Coordinator) Draining() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	return c.draining
+}
+
+// Safe is closed once the instance has drained and can be terminated
+func (c *Coordinator) Safe() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	return c.safe
+}
+
+// Begin starts work which isn't started while draining, like a cutoff. Callers must call the
+// returned func when the work is finished.
+func (c *Coordinator) Begin() (func(), error) {
+	if c == nil {
+		return func() {}, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.draining:
+		return nil, ErrDraining
+	default:
+	}
+	c.inFlight++
+	return c.end, nil
+}
+
+// Track counts work which has to finish even while draining, like a submission already
+// received. Callers must call the returned func when the work is finished.
+func (c *Coordinator) Track() func() {
+	if c == nil {
+		return func() {}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight++
+	return c.end
+}
+
+func (c *Coordinator) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight--
+	c.finishIfIdle()
+}
+
+// finishIfIdle releases leadership once draining has no work left, c.mu must be held.
+func (c *Coordinator) finishIfIdle() {
+	select {
+	case <-c.draining:
+	default:
+		return
+	}
+	if c.inFlight > 0 || c.releasing {
+		return
+	}
+	c.releasing = true
+
+	// Releasing can call out to Consul or Kubernetes, so don't block callers on it
+	go func() {
+		leadership.Release(c.elector)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.safeAt = time.Now()
+		close(c.safe)
+		c.logger.Info().Logf("drained after %v, safe to terminate", c.safeAt.Sub(c.startedAt))
+	}()
+}
+
+// Status is how far the instance is through draining
+type Status struct {
+	Draining        bool       `json:"draining"`
+	InFlight        int        `json:"inFlight"`
+	SafeToTerminate bool       `json:"safeToTerminate"`
+	StartedAt       *time.Time `json:"startedAt,omitempty"`
+	SafeAt          *time.Time `json:"safeAt,omitempty"`
+}
+
+func (c *Coordinator) Status() Status {
+	if c == nil {
+		return Status{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := Status{
+		InFlight: c.inFlight,
+	}
+	select {
+	case <-c.draining:
+		status.Draining = true
+		started := c.startedAt
+		status.StartedAt = &started
+	default:
+	}
+	select {
+	case <-c.safe:
+		status.SafeToTerminate = true
+		safeAt := c.safeAt
+		status.SafeAt = &safeAt
+	default:
+	}
+	return status
+}
+
+type drainResponse struct {
+	Status
+	SourceHostname string `json:"sourceHostname"`
+}
+
+// RegisterAdminRoutes adds /drain to the admin server. PUT starts draining and GET returns the
+// drain Status. Both wait for the instance to drain when ?wait= is a duration (like 5m).
+// Draining instances also fail the readiness check.
+func (c *Coordinator) RegisterAdminRoutes(svc *admin.Server) {
+	if c == nil {
+		return
+	}
+	svc.AddHandler("/drain", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			c.Drain()
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if wait := r.URL.Query().Get("wait"); wait != "" {
+			timeout, err := time.ParseDuration(wait)
+			if err != nil {
+				http.Error(w, "invalid wait duration", http.StatusBadRequest)
+				return
+			}
+			select {
+			case <-c.Safe():
+			case <-time.After(timeout):
+			case <-r.Context().Done():
+			}
+		}
+
+		resp := drainResponse{Status: c.Status()}
+		resp.SourceHostname, _ = os.Hostname()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	})
+	svc.AddReadinessCheck("drain", func() error {
+		select {
+		case <-c.Draining():
+			return ErrDraining
+		default:
+			return nil
+		}
+	})
+}
@@ -0,0 +1,141 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package drain
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+type shutdownElector struct {
+	shutdown chan struct{}
+}
+
+func (e *shutdownElector) AcquireLock(key string) error {
+	return nil
+}
+
+func (e *shutdownElector) Shutdown() {
+	close(e.shutdown)
+}
+
+func TestCoordinator(t *testing.T) {
+	elector := &shutdownElector{shutdown: make(chan struct{})}
+	c := New(log.NewNopLogger(), elector)
+
+	done, err := c.Begin()
+	require.NoError(t, err)
+	tracked := c.Track()
+
+	c.Drain()
+	c.Drain()
+	require.True(t, c.Status().Draining)
+	require.Equal(t, 2, c.Status().InFlight)
+
+	// New work is refused, but work already received is still counted
+	_, err = c.Begin()
+	require.ErrorIs(t, err, ErrDraining)
+
+	done()
+	select {
+	case <-c.Safe():
+		t.Fatal("drained with work in flight")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	tracked()
+	select {
+	case <-c.Safe():
+	case <-time.After(time.Second):
+		t.Fatal("expected instance to drain")
+	}
+	<-elector.shutdown
+
+	status := c.Status()
+	require.True(t, status.SafeToTerminate)
+	require.Zero(t, status.InFlight)
+	require.NotNil(t, status.SafeAt)
+}
+
+func TestCoordinator__Nil(t *testing.T) {
+	var c *Coordinator
+	c.Drain()
+
+	done, err := c.Begin()
+	require.NoError(t, err)
+	done()
+	c.Track()()
+
+	require.Nil(t, c.Draining())
+	require.Equal(t, Status{}, c.Status())
+}
+
+func TestCoordinator__AdminRoutes(t *testing.T) {
+	svc := admin.NewServer(":0")
+	go svc.Listen()
+	t.Cleanup(func() { svc.Shutdown() })
+
+	c := New(log.NewNopLogger(), nil)
+	c.RegisterAdminRoutes(svc)
+	done := c.Track()
+
+	address := "http://" + svc.BindAddr()
+	readStatus := func(method, path string) Status {
+		t.Helper()
+
+		req, err := http.NewRequest(method, address+path, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var status Status
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+		return status
+	}
+
+	status := readStatus("GET", "/drain")
+	require.False(t, status.Draining)
+
+	resp, err := http.Get(address + "/ready")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	status = readStatus("PUT", "/drain?wait=10ms")
+	require.True(t, status.Draining)
+	require.False(t, status.SafeToTerminate)
+	require.Equal(t, 1, status.InFlight)
+
+	resp, err = http.Get(address + "/ready")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.NotEqual(t, http.StatusOK, resp.StatusCode)
+
+	done()
+	status = readStatus("GET", "/drain?wait=1s")
+	require.True(t, status.SafeToTerminate)
+}
@@ -22,19 +22,40 @@ package internal
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	_ "github.com/moov-io/achgateway"
 	"github.com/moov-io/achgateway/internal/consul"
+	"github.com/moov-io/achgateway/internal/dblock"
+	"github.com/moov-io/achgateway/internal/deliveries"
+	"github.com/moov-io/achgateway/internal/drain"
+	"github.com/moov-io/achgateway/internal/erasure"
 	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/exposure"
+	"github.com/moov-io/achgateway/internal/fips"
 	"github.com/moov-io/achgateway/internal/incoming/odfi"
+	"github.com/moov-io/achgateway/internal/incoming/sftpserver"
 	"github.com/moov-io/achgateway/internal/incoming/stream"
 	"github.com/moov-io/achgateway/internal/incoming/web"
+	"github.com/moov-io/achgateway/internal/kubelease"
+	"github.com/moov-io/achgateway/internal/leadership"
+	"github.com/moov-io/achgateway/internal/lineage"
+	"github.com/moov-io/achgateway/internal/microentries"
 	"github.com/moov-io/achgateway/internal/pipeline"
+	"github.com/moov-io/achgateway/internal/redis"
+	"github.com/moov-io/achgateway/internal/rejections"
+	"github.com/moov-io/achgateway/internal/representment"
 	"github.com/moov-io/achgateway/internal/service"
 	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/achgateway/internal/signing"
+	"github.com/moov-io/achgateway/internal/staging"
+	"github.com/moov-io/achgateway/internal/submissions"
+	"github.com/moov-io/achgateway/internal/testharness"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/internal/uploadledger"
 	"github.com/moov-io/base/admin"
 	"github.com/moov-io/base/config"
 	"github.com/moov-io/base/database"
@@ -53,6 +74,9 @@ type Environment struct {
 	DB             *sql.DB
 	InternalClient *http.Client
 	Consul         *consul.Client
+	Redis          *redis.Client
+	Leadership     leadership.Elector
+	Drain          *drain.Coordinator
 	Events         events.Emitter
 
 	PublicRouter *mux.Router
@@ -64,6 +88,11 @@ type Environment struct {
 	ODFIFiles   odfi.Scheduler
 
 	FileReceiver *pipeline.FileReceiver
+	Eraser       *erasure.Eraser
+	SigningKeys  *signing.Keyring
+
+	// Tenant names the gateway when it's one of several run by the process
+	Tenant string
 }
 
 // NewEnvironment - Generates a new default environment. Overrides can be specified via configs.
@@ -95,9 +124,18 @@ func NewEnvironment(env *Environment) (*Environment, error) {
 		env.Config = cfg
 	}
 	env.Config.Logger = env.Logger
+	env.Config.Upload.Tenant = env.Tenant
+
+	// Restrict crypto before any connections are made
+	if err := fips.Setup(env.Config); err != nil {
+		return env, err
+	}
+	if fips.Enabled() {
+		env.Logger.Info().Logf("FIPS mode enabled (validated crypto module: %v)", fips.ValidatedModule())
+	}
 
 	// db setup
-	if env.DB == nil && env.Config.Database.MySQL != nil {
+	if env.DB == nil && (env.Config.Database.MySQL != nil || env.Config.Database.SQLite != nil) {
 		db, close, err := initializeDatabase(env.Logger, env.Config.Database)
 		if err != nil {
 			close()
@@ -122,11 +160,15 @@ func NewEnvironment(env *Environment) (*Environment, error) {
 		env.TimeService = stime.NewSystemTimeService()
 	}
 
-	// File publishers
+	// File publishers, which are separate for each tenant
+	inmemURL := "mem://achgateway"
+	if env.Tenant != "" {
+		inmemURL += "-" + env.Tenant
+	}
 	inmemConfig := &service.Config{
 		Inbound: service.Inbound{
 			InMem: &service.InMemory{
-				URL: "mem://achgateway",
+				URL: inmemURL,
 			},
 		},
 	}
@@ -153,13 +195,108 @@ func NewEnvironment(env *Environment) (*Environment, error) {
 		}
 	}
 
+	// Setup our Redis client (if configured)
+	if env.Redis == nil && env.Config.Redis != nil {
+		cfg := *env.Config.Redis
+		if cfg.KeyPrefix == "" && env.Tenant != "" {
+			cfg.KeyPrefix = "achgateway:" + env.Tenant + ":"
+		}
+		redisClient, err := redis.NewClient(env.Logger, &cfg)
+		if err != nil {
+			return env, fmt.Errorf("unable to create redis client: %v", err)
+		}
+		env.Redis = redisClient
+		env.Logger.Info().Logf("created redis client for %s", cfg.Address)
+
+		prev := env.Shutdown
+		env.Shutdown = func() {
+			prev()
+			redisClient.Close()
+		}
+	}
+
+	// Setup our Kubernetes Lease elector (if configured)
+	if env.Leadership == nil && env.Config.KubernetesLease != nil {
+		leaseClient, err := kubelease.NewClient(env.Logger, env.Config.KubernetesLease)
+		if err != nil {
+			return env, fmt.Errorf("unable to create kubernetes lease client: %v", err)
+		}
+		env.Leadership = leadership.Kubernetes(leaseClient)
+		env.Logger.Info().Logf("electing leaders with kubernetes leases as %s", leaseClient.Identity())
+
+		prev := env.Shutdown
+		env.Shutdown = func() {
+			prev()
+			leaseClient.Shutdown()
+		}
+	}
+	// Setup our database lock elector (if configured)
+	if env.Leadership == nil && env.Config.DatabaseLocks != nil {
+		lockClient, err := dblock.NewClient(env.Logger, env.DB, env.Config.DatabaseLocks)
+		if err != nil {
+			return env, fmt.Errorf("unable to create database lock client: %v", err)
+		}
+		env.Leadership = leadership.Database(lockClient)
+		env.Logger.Info().Logf("electing leaders with database locks as %s", lockClient.Identity())
+
+		prev := env.Shutdown
+		env.Shutdown = func() {
+			prev()
+			lockClient.Shutdown()
+		}
+	}
+	// Setup our Redis lock elector (if configured)
+	if env.Leadership == nil && env.Redis != nil && env.Config.Redis != nil && env.Config.Redis.Locks != nil {
+		locker, err := redis.NewLocker(env.Logger, env.Redis, env.Config.Redis.Locks)
+		if err != nil {
+			return env, fmt.Errorf("unable to create redis locker: %v", err)
+		}
+		env.Leadership = leadership.Redis(locker)
+		env.Logger.Info().Logf("electing leaders with redis locks as %s", locker.Identity())
+
+		prev := env.Shutdown
+		env.Shutdown = func() {
+			prev()
+			locker.Shutdown()
+		}
+	}
+	if env.Leadership == nil {
+		env.Leadership = leadership.Consul(env.Logger, env.Consul)
+	}
+	if env.Drain == nil {
+		env.Drain = drain.New(env.Logger, env.Leadership)
+	}
+
 	// Setup our Events emitter
+	if env.SigningKeys == nil && env.Config.Events != nil && env.Config.Events.Signing != nil {
+		repo := signing.NewRepository(env.DB)
+		if repo == nil {
+			return env, errors.New("event signing requires a Database")
+		}
+		env.SigningKeys = signing.NewKeyring(repo, env.Config.Events.Signing)
+	}
 	if env.Events == nil && env.Config.Events != nil {
-		emitter, err := events.NewEmitter(env.Logger, env.Config.Events)
+		emitter, err := events.NewEmitter(env.Logger, env.Config.Events, env.SigningKeys)
 		if err != nil {
 			return env, err
 		}
 		env.Events = emitter
+
+		// Close the emitter once everything sending events has shut down, even when we return early
+		defer func() {
+			prev := env.Shutdown
+			env.Shutdown = func() {
+				prev()
+				if err := emitter.Close(); err != nil {
+					env.Logger.LogErrorf("problem closing events emitter: %v", err)
+				}
+			}
+		}()
+	}
+
+	// Script the returns and corrections of agents pointed at ach-test-harness
+	if err := testharness.ProvisionAll(ctx, env.Logger, env.InternalClient, env.Config.Upload); err != nil {
+		return env, fmt.Errorf("unable to provision ach-test-harness: %v", err)
 	}
 
 	// file pipeline
@@ -172,20 +309,67 @@ func NewEnvironment(env *Environment) (*Environment, error) {
 		return env, fmt.Errorf("unable to create stream files subscription: %v", err)
 	}
 
+	var exposureRepo exposure.Repository
+	if env.Config.Exposure != nil {
+		exposureRepo = exposure.NewRepository(env.DB)
+	}
+
 	shardRepository := shards.NewRepository(env.DB, env.Config.Sharding.Mappings)
-	fileReceiver, err := pipeline.Start(ctx, env.Logger, env.Config, env.Consul, shardRepository, httpSub, streamSub)
+	fileReceiver, err := pipeline.Start(ctx, env.Logger, env.Config, env.Leadership, env.Drain, shardRepository, uploadledger.NewRepository(env.DB), events.NewSequencer(env.DB), env.Events, lineage.NewRepository(env.DB), exposureRepo, submissions.NewRepository(env.DB), rejections.NewRepository(env.DB), httpSub, streamSub)
 	if err != nil {
 		return env, fmt.Errorf("unable to create file pipeline: %v", err)
 	}
 	env.FileReceiver = fileReceiver
 
+	env.Eraser, err = erasure.New(env.Logger, env.Config, lineage.NewRepository(env.DB))
+	if err != nil {
+		return env, err
+	}
+	// Rows are kept after their features are disabled, so they're always searched
+	if env.DB != nil {
+		env.Eraser.
+			WithStore(erasure.SourceStagedFiles, staging.NewRepository(env.DB)).
+			WithStore(erasure.SourceRepresentments, representment.NewRepository(env.DB)).
+			WithStore(erasure.SourceRejectedFiles, rejections.NewRepository(env.DB))
+	}
+	if env.Eraser != nil {
+		prev := env.Shutdown
+		env.Shutdown = func() {
+			prev()
+			env.Eraser.Close()
+		}
+	}
+
+	var microEntries microentries.Repository
+	if env.Config.Inbound.HTTP.MicroEntries != nil {
+		microEntries = microentries.NewRepository(env.DB)
+		if microEntries == nil {
+			return env, errors.New("micro entries require a Database")
+		}
+	}
+
+	var stagedFiles staging.Repository
+	if env.Config.Inbound.HTTP.StagedFiles != nil {
+		stagedFiles = staging.NewRepository(env.DB)
+		if stagedFiles == nil {
+			return env, errors.New("staged files require a Database")
+		}
+	}
+
 	// router
 	if env.PublicRouter == nil {
 		env.PublicRouter = mux.NewRouter()
 		env.PublicRouter.Path("/ping").Methods("GET").HandlerFunc(addPingRoute)
 
 		// append HTTP routes
-		web.NewFilesController(env.Config.Logger, env.Config.Inbound.HTTP, httpFiles).AppendRoutes(env.PublicRouter)
+		web.NewFilesController(env.Config.Logger, env.Config.Inbound.HTTP, httpFiles).
+			WithShards(shardRepository, env.Config.Sharding).
+			WithDrain(env.Drain).
+			WithSubmissionCheck(fileReceiver.CheckSubmission).
+			WithMicroEntries(microEntries, env.Events).
+			WithStagedFiles(stagedFiles).
+			WithRedis(env.Redis).
+			AppendRoutes(env.PublicRouter)
 
 		// shard mapping HTTP routes
 		shardMappingService, err := shards.NewShardMappingService(stime.NewStaticTimeService(), env.Config.Logger, shardRepository)
@@ -193,19 +377,69 @@ func NewEnvironment(env *Environment) (*Environment, error) {
 			return env, fmt.Errorf("unable to create shard mapping service: %v", err)
 		}
 		shards.NewShardMappingController(env.Config.Logger, shardMappingService).AppendRoutes(env.PublicRouter)
+
+		// AS2 partners send files and async MDNs to their upload agent
+		if err := upload.AppendAS2Routes(env.Config.Logger, env.Config.Upload, env.PublicRouter); err != nil {
+			return env, fmt.Errorf("unable to create AS2 routes: %v", err)
+		}
+	}
+
+	// Accept files uploaded by partners over SFTP
+	if cfg := env.Config.Inbound.SFTP; cfg != nil {
+		repo := sftpserver.NewRepository(env.DB, cfg.Partners)
+		sftpServer, err := sftpserver.NewServer(env.Logger, cfg, repo, httpFiles)
+		if err != nil {
+			return env, fmt.Errorf("unable to create sftp server: %v", err)
+		}
+		if err := sftpServer.Start(); err != nil {
+			return env, fmt.Errorf("unable to start sftp server: %v", err)
+		}
+
+		prev := env.Shutdown
+		env.Shutdown = func() {
+			prev()
+			sftpServer.Shutdown()
+		}
 	}
 
 	// Start our ODFI PeriodicScheduler
 	if env.ODFIFiles == nil && env.Config.Inbound.ODFI != nil {
 		cfg := env.Config.Inbound.ODFI
+		treasuryExporter, err := odfi.TreasuryExporter(env.Logger, cfg.Processors, env.Config.Upload, env.Events)
+		if err != nil {
+			return env, fmt.Errorf("problem creating odfi treasury exporter: %v", err)
+		}
+		var deliveryRepo deliveries.Repository
+		if cfg.Processors.Deliveries != nil {
+			deliveryRepo = deliveries.NewRepository(env.DB)
+			if deliveryRepo == nil {
+				return env, errors.New("delivery correlation requires a Database")
+			}
+		}
+		var representments representment.Repository
+		if cfg.Processors.Representment != nil {
+			representments = representment.NewRepository(env.DB)
+			if representments == nil {
+				return env, errors.New("representment requires a Database")
+			}
+		}
 		processors := odfi.SetupProcessors(
 			odfi.CorrectionEmitter(env.Logger, cfg.Processors.Corrections, env.Events),
 			odfi.PrenoteEmitter(env.Logger, cfg.Processors.Prenotes, env.Events),
 			odfi.CreditReconciliationEmitter(env.Logger, cfg.Processors.Reconciliation, env.Events),
-			odfi.ReturnEmitter(env.Logger, cfg.Processors.Returns, env.Events),
+			odfi.ReturnEmitter(env.Logger, cfg.Processors.Returns, env.Events).WithSubmissions(submissions.NewRepository(env.DB)),
 			odfi.IncomingEmitter(env.Logger, cfg.Processors.Incoming, cfg.Processors.Reconciliation, env.Events),
+			odfi.MicroEntryReturns(env.Logger, microEntries, env.Events),
+			odfi.ReturnExposure(env.Logger, exposureRepo),
+			odfi.DeliveryCorrelator(env.Logger, cfg.Processors.Deliveries, deliveryRepo, env.Events),
+			odfi.Representer(env.Logger, cfg.Processors.Representment, representments, submissions.NewRepository(env.DB), httpFiles, env.Events),
+			treasuryExporter,
 		)
-		odfiFiles, err := odfi.NewPeriodicScheduler(env.Logger, env.Config, env.Consul, processors)
+		var processed odfi.ProcessedFiles
+		if env.Redis != nil && env.Redis.Config().ProcessedFiles != nil {
+			processed = env.Redis.Set("odfi-processed", env.Redis.Config().ProcessedFiles.TTL)
+		}
+		odfiFiles, err := odfi.NewPeriodicScheduler(env.Logger, env.Config, env.Leadership, env.Drain, processors, processed, env.Events)
 		if err != nil {
 			return env, fmt.Errorf("problem creating odfi periodic scheduler: %v", err)
 		}
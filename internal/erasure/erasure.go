@@ -0,0 +1,440 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package erasure redacts a receiver's personal data from the files and records achgateway keeps,
+// for right-to-erasure requests, and produces a signed report of everything that was changed.
+package erasure
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/audittrail"
+	"github.com/moov-io/achgateway/internal/lineage"
+	"github.com/moov-io/achgateway/internal/mask"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+	"github.com/moov-io/cryptfs"
+)
+
+const (
+	SourceODFI           = "odfi"
+	SourceAudit          = "audit"
+	SourceStagedFiles    = "staged_files"
+	SourceRepresentments = "representments"
+	SourceRejectedFiles  = "rejected_files"
+
+	ActionRedacted = "redacted"
+	ActionDeleted  = "deleted"
+	ActionRetained = "retained"
+)
+
+// Request names the receiver whose data is erased
+type Request struct {
+	RoutingNumber string `json:"routingNumber"`
+	AccountNumber string `json:"accountNumber"`
+
+	// AuditTrail also deletes audit trail copies older than the configured Retention
+	AuditTrail bool `json:"auditTrail"`
+
+	// DryRun reports what would be erased without changing anything
+	DryRun bool `json:"dryRun"`
+}
+
+func (req Request) Validate() error {
+	if len(req.RoutingNumber) != 9 {
+		return fmt.Errorf("invalid routingNumber %q", req.RoutingNumber)
+	}
+	if _, err := strconv.ParseUint(req.RoutingNumber, 10, 64); err != nil {
+		return fmt.Errorf("invalid routingNumber %q", req.RoutingNumber)
+	}
+	if n := len(strings.TrimSpace(req.AccountNumber)); n == 0 || n > 17 {
+		return errors.New("invalid accountNumber")
+	}
+	return nil
+}
+
+// Report is what an erasure request found and changed. Account numbers are masked.
+type Report struct {
+	ID            string    `json:"id"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Hostname      string    `json:"hostname"`
+	DryRun        bool      `json:"dryRun"`
+	RoutingNumber string    `json:"routingNumber"`
+	AccountNumber string    `json:"accountNumber"`
+	AuditTrail    bool      `json:"auditTrail"`
+
+	Files   []File  `json:"files"`
+	Lineage Lineage `json:"lineage"`
+
+	Signature *Signature `json:"signature,omitempty"`
+}
+
+// File is a file holding the receiver's entries
+type File struct {
+	Source  string `json:"source"`
+	Path    string `json:"path"`
+	Entries int    `json:"entries"`
+	Action  string `json:"action"`
+
+	// SHA256 is the hash of the file before it was changed
+	SHA256 string `json:"sha256"`
+}
+
+// Lineage are the trace number lineage records of the receiver's entries
+type Lineage struct {
+	TraceNumbers []string `json:"traceNumbers"`
+	Removed      int      `json:"removed"`
+}
+
+// Store is a repository keeping Nacha contents in the Database, like staged files. RedactContents
+// calls redact with each record's key and contents and saves the contents returned when changed.
+type Store interface {
+	RedactContents(redact func(key string, contents []byte) ([]byte, bool)) error
+}
+
+// Eraser finds and redacts a receiver's data in ODFI files kept on local storage, stores in the
+// Database, trace number lineage and, when requested, audit trail copies past their retention.
+type Eraser struct {
+	logger log.Logger
+	cfg    service.ErasureConfig
+	key    ed25519.PrivateKey
+
+	odfiDirectory string
+	audits        []auditTrail
+	decryptor     *cryptfs.FS
+	lineage       lineage.Repository
+	stores        []namedStore
+	reports       audittrail.Storage
+
+	now func() time.Time
+}
+
+type namedStore struct {
+	source string
+	store  Store
+}
+
+// WithStore redacts the records of store, which are reported with source
+func (e *Eraser) WithStore(source string, store Store) *Eraser {
+	if e != nil && store != nil {
+		e.stores = append(e.stores, namedStore{source: source, store: store})
+	}
+	return e
+}
+
+// auditTrail is a bucket of audit trail files and the prefix ACH files are saved under
+type auditTrail struct {
+	cfg    *service.AuditTrail
+	prefix string
+}
+
+// New returns an Eraser for the config, or nil when erasure isn't configured. repo may be nil.
+func New(logger log.Logger, cfg *service.Config, repo lineage.Repository) (*Eraser, error) {
+	if cfg == nil || cfg.Erasure == nil {
+		return nil, nil
+	}
+	key, err := ReadSigningKey(cfg.Erasure.SigningKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("erasure: %v", err)
+	}
+	e := &Eraser{
+		logger:  logger,
+		cfg:     *cfg.Erasure,
+		key:     key,
+		lineage: repo,
+		now:     time.Now,
+	}
+
+	if odfi := cfg.Inbound.ODFI; odfi != nil {
+		e.odfiDirectory = odfi.Storage.Directory
+		if odfi.Audit != nil {
+			e.audits = append(e.audits, auditTrail{cfg: odfi.Audit, prefix: "odfi/"})
+		}
+	}
+	for _, shard := range cfg.Sharding.Shards {
+		if shard.Audit != nil {
+			e.audits = append(e.audits, auditTrail{cfg: shard.Audit, prefix: "outbound/"})
+		}
+	}
+	e.audits = uniqueAuditTrails(e.audits)
+
+	if dec := cfg.Erasure.Decryption; dec != nil {
+		e.decryptor, err = cryptfs.FromCryptor(cryptfs.NewGPGDecryptorFile(dec.KeyFile, []byte(dec.KeyPassword)))
+		if err != nil {
+			return nil, fmt.Errorf("erasure: reading gpg private key: %v", err)
+		}
+	}
+	if cfg.Erasure.Reports != nil {
+		e.reports, err = audittrail.NewStorage(cfg.Erasure.Reports)
+		if err != nil {
+			return nil, fmt.Errorf("erasure: reports: %v", err)
+		}
+	}
+	return e, nil
+}
+
+// Erase redacts the receiver's data and returns the signed report. Files which were changed
+// before an error are included in the report returned with the error.
+func (e *Eraser) Erase(req Request) (*Report, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	receiver := Receiver{
+		RoutingNumber: req.RoutingNumber,
+		AccountNumber: strings.TrimSpace(req.AccountNumber),
+	}
+
+	hostname, _ := os.Hostname()
+	report := &Report{
+		ID:            base.ID(),
+		CreatedAt:     e.now().UTC(),
+		Hostname:      hostname,
+		DryRun:        req.DryRun,
+		RoutingNumber: req.RoutingNumber,
+		AccountNumber: mask.AccountNumber(receiver.AccountNumber),
+		AuditTrail:    req.AuditTrail,
+		Files:         []File{},
+		Lineage:       Lineage{TraceNumbers: []string{}},
+	}
+
+	err := e.eraseODFIFiles(receiver, req.DryRun, report)
+	if err == nil {
+		err = e.eraseStores(receiver, req.DryRun, report)
+	}
+	if err == nil && req.AuditTrail {
+		err = e.eraseAuditFiles(receiver, req.DryRun, report)
+	}
+	if err == nil && e.lineage != nil && !req.DryRun {
+		report.Lineage.Removed, err = e.lineage.RemoveTraceNumbers(report.Lineage.TraceNumbers)
+	}
+
+	if signErr := Sign(report, e.key); signErr != nil {
+		return nil, signErr
+	}
+	e.save(report)
+	return report, err
+}
+
+// eraseODFIFiles redacts files downloaded from ODFIs which were kept on local storage
+func (e *Eraser) eraseODFIFiles(receiver Receiver, dryRun bool, report *Report) error {
+	if e.odfiDirectory == "" {
+		return nil
+	}
+	err := filepath.WalkDir(e.odfiDirectory, func(where string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		bs, err := os.ReadFile(where)
+		if err != nil {
+			return err
+		}
+		redacted, found := redact(bs, receiver)
+		if found.entries == 0 {
+			return nil
+		}
+		if !dryRun {
+			if err := os.WriteFile(where, redacted, info.Mode().Perm()); err != nil {
+				return err
+			}
+		}
+		report.add(SourceODFI, where, bs, found, ActionRedacted)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("erasing odfi files: %v", err)
+	}
+	return nil
+}
+
+// eraseStores redacts the contents kept by each store
+func (e *Eraser) eraseStores(receiver Receiver, dryRun bool, report *Report) error {
+	for _, s := range e.stores {
+		err := s.store.RedactContents(func(key string, contents []byte) ([]byte, bool) {
+			redacted, found := redact(contents, receiver)
+			if found.entries == 0 {
+				return nil, false
+			}
+			report.add(s.source, key, contents, found, ActionRedacted)
+			return redacted, !dryRun
+		})
+		if err != nil {
+			return fmt.Errorf("erasing %s: %v", s.source, err)
+		}
+	}
+	return nil
+}
+
+// eraseAuditFiles deletes audit trail copies holding the receiver's entries once they're past
+// their retention. Newer copies are reported as retained.
+func (e *Eraser) eraseAuditFiles(receiver Receiver, dryRun bool, report *Report) error {
+	cutoff := e.now().Add(-1 * e.cfg.RetainFor())
+
+	for _, audit := range e.audits {
+		if audit.cfg.GPG != nil && e.decryptor == nil {
+			return errors.New("erasing audit files: audit trail files are encrypted, Decryption is required")
+		}
+		storage, err := audittrail.NewStorage(audit.cfg)
+		if err != nil {
+			return fmt.Errorf("erasing audit files: %v", err)
+		}
+		err = e.eraseAuditTrail(storage, audit, receiver, cutoff, dryRun, report)
+		storage.Close()
+		if err != nil {
+			return fmt.Errorf("erasing audit files: %v", err)
+		}
+	}
+	return nil
+}
+
+func (e *Eraser) eraseAuditTrail(storage audittrail.Storage, audit auditTrail, receiver Receiver, cutoff time.Time, dryRun bool, report *Report) error {
+	paths, err := storage.ListFiles(audit.prefix)
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	for _, where := range paths {
+		bs, err := readAuditFile(storage, where, audit.cfg.GPG != nil, e.decryptor)
+		if err != nil {
+			return err
+		}
+		_, found := redact(bs, receiver)
+		if found.entries == 0 {
+			continue
+		}
+
+		// Files without a date in their path are treated as still within retention
+		saved, ok := savedOn(where)
+		if !ok || !saved.Before(cutoff) {
+			report.add(SourceAudit, where, bs, found, ActionRetained)
+			continue
+		}
+		if !dryRun {
+			if err := storage.DeleteFile(where); err != nil {
+				return err
+			}
+		}
+		report.add(SourceAudit, where, bs, found, ActionDeleted)
+	}
+	return nil
+}
+
+func readAuditFile(storage audittrail.Storage, where string, encrypted bool, decryptor *cryptfs.FS) ([]byte, error) {
+	file, err := storage.GetFile(where)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	bs, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", where, err)
+	}
+	if encrypted {
+		bs, err = decryptor.Reveal(bs)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting %s: %v", where, err)
+		}
+	}
+	return bs, nil
+}
+
+// savedOn returns the date audit trail files are saved under, like odfi/$hostname/$dir/$date/$filename
+func savedOn(where string) (time.Time, bool) {
+	for dir := path.Dir(where); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if when, err := time.ParseInLocation("2006-01-02", path.Base(dir), time.UTC); err == nil {
+			// The whole day has to be past the cutoff
+			return when.AddDate(0, 0, 1), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (report *Report) add(source, where string, contents []byte, found redaction, action string) {
+	sum := sha256.Sum256(contents)
+	report.Files = append(report.Files, File{
+		Source:  source,
+		Path:    where,
+		Entries: found.entries,
+		Action:  action,
+		SHA256:  hex.EncodeToString(sum[:]),
+	})
+	for _, traceNumber := range found.traceNumbers {
+		report.Lineage.TraceNumbers = appendTraceNumber(report.Lineage.TraceNumbers, traceNumber)
+	}
+}
+
+// save keeps a copy of the report when Reports is configured
+func (e *Eraser) save(report *Report) {
+	if e.reports == nil {
+		return
+	}
+	bs, err := MarshalReport(report)
+	if err != nil {
+		e.logger.Error().LogErrorf("encoding erasure report %s: %v", report.ID, err)
+		return
+	}
+	where := fmt.Sprintf("erasure/%s/%s.json", report.CreatedAt.Format("2006-01-02"), report.ID)
+	if err := e.reports.SaveFile(where, bs); err != nil {
+		e.logger.Error().LogErrorf("saving erasure report %s: %v", report.ID, err)
+	}
+}
+
+// Close releases the report storage
+func (e *Eraser) Close() error {
+	if e == nil || e.reports == nil {
+		return nil
+	}
+	return e.reports.Close()
+}
+
+func uniqueAuditTrails(audits []auditTrail) []auditTrail {
+	var out []auditTrail
+	seen := make(map[string]bool)
+	for _, audit := range audits {
+		key := audit.cfg.BucketURI + "|" + audit.prefix
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, audit)
+		}
+	}
+	return out
+}
@@ -0,0 +1,259 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package erasure
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/audittrail"
+	"github.com/moov-io/achgateway/internal/lineage"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeSigningKey(t *testing.T) (string, ed25519.PublicKey) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+
+	where := filepath.Join(t.TempDir(), "erasure.pem")
+	require.NoError(t, os.WriteFile(where, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	return where, public
+}
+
+type setup struct {
+	eraser    *Eraser
+	publicKey ed25519.PublicKey
+	odfiDir   string
+	audit     *service.AuditTrail
+	reports   *service.AuditTrail
+	lineage   lineage.Repository
+}
+
+func setupEraser(t *testing.T) setup {
+	t.Helper()
+
+	keyFile, publicKey := writeSigningKey(t)
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	s := setup{
+		publicKey: publicKey,
+		odfiDir:   t.TempDir(),
+		audit:     &service.AuditTrail{BucketURI: "file://" + t.TempDir()},
+		reports:   &service.AuditTrail{BucketURI: "file://" + t.TempDir()},
+		lineage:   lineage.NewRepository(db.DB),
+	}
+	cfg := &service.Config{
+		Inbound: service.Inbound{
+			ODFI: &service.ODFIFiles{
+				Storage: service.ODFIStorage{Directory: s.odfiDir},
+				Audit:   s.audit,
+			},
+		},
+		Erasure: &service.ErasureConfig{
+			SigningKeyFile: keyFile,
+			Reports:        s.reports,
+		},
+	}
+	var err error
+	s.eraser, err = New(log.NewTestLogger(), cfg, s.lineage)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.eraser.Close() })
+
+	s.eraser.now = func() time.Time {
+		return time.Date(2023, time.June, 1, 12, 0, 0, 0, time.UTC)
+	}
+	return s
+}
+
+func TestErasure__New(t *testing.T) {
+	eraser, err := New(log.NewTestLogger(), &service.Config{}, nil)
+	require.NoError(t, err)
+	require.Nil(t, eraser)
+
+	_, err = New(log.NewTestLogger(), &service.Config{
+		Erasure: &service.ErasureConfig{SigningKeyFile: filepath.Join(t.TempDir(), "missing.pem")},
+	}, nil)
+	require.ErrorContains(t, err, "reading signing key")
+}
+
+func TestErasure__Erase(t *testing.T) {
+	s := setupEraser(t)
+
+	returnFile := filepath.Join(s.odfiDir, "returned", "return-WEB.ach")
+	require.NoError(t, os.MkdirAll(filepath.Dir(returnFile), 0755))
+	require.NoError(t, os.WriteFile(returnFile, readTestFile(t, "return-WEB.ach"), 0600))
+
+	storage, err := audittrail.NewStorage(s.audit)
+	require.NoError(t, err)
+	defer storage.Close()
+	require.NoError(t, storage.SaveFile("odfi/ftp.bank.com/returned/2020-01-02/return-WEB.ach", readTestFile(t, "return-WEB.ach")))
+	require.NoError(t, storage.SaveFile("odfi/ftp.bank.com/returned/2023-05-30/return-WEB.ach", readTestFile(t, "return-WEB.ach")))
+
+	require.NoError(t, s.lineage.RecordTraceNumbers([]lineage.TraceNumber{
+		{ShardName: "live", FileID: "abc", Original: "091400600000099", Assigned: "091400600000001"},
+	}))
+
+	req := Request{
+		RoutingNumber: "091000019",
+		AccountNumber: "123456789",
+		AuditTrail:    true,
+		DryRun:        true,
+	}
+	report, err := s.eraser.Erase(req)
+	require.NoError(t, err)
+	require.True(t, report.DryRun)
+	require.Equal(t, "*****6789", report.AccountNumber)
+	require.Len(t, report.Files, 3)
+	require.Zero(t, report.Lineage.Removed)
+	require.NoError(t, Verify(*report, s.publicKey))
+
+	// Nothing changes on a dry run
+	bs, err := os.ReadFile(returnFile)
+	require.NoError(t, err)
+	require.Contains(t, string(bs), "Paul Jones")
+
+	req.DryRun = false
+	report, err = s.eraser.Erase(req)
+	require.NoError(t, err)
+	require.NoError(t, Verify(*report, s.publicKey))
+
+	actions := make(map[string]string)
+	for _, file := range report.Files {
+		require.Equal(t, 1, file.Entries)
+		actions[file.Path] = file.Action
+	}
+	require.Equal(t, map[string]string{
+		returnFile: ActionRedacted,
+		"odfi/ftp.bank.com/returned/2020-01-02/return-WEB.ach": ActionDeleted,
+		"odfi/ftp.bank.com/returned/2023-05-30/return-WEB.ach": ActionRetained,
+	}, actions)
+	require.ElementsMatch(t, []string{"091000017611242", "091400600000001"}, report.Lineage.TraceNumbers)
+	require.Equal(t, 1, report.Lineage.Removed)
+
+	bs, err = os.ReadFile(returnFile)
+	require.NoError(t, err)
+	require.NotContains(t, string(bs), "Paul Jones")
+	require.Contains(t, string(bs), "Bob Marley")
+
+	paths, err := storage.ListFiles("odfi/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"odfi/ftp.bank.com/returned/2023-05-30/return-WEB.ach"}, paths)
+
+	// Both reports were saved
+	reports, err := audittrail.NewStorage(s.reports)
+	require.NoError(t, err)
+	defer reports.Close()
+	paths, err = reports.ListFiles("erasure/2023-06-01/")
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+
+	// Erasing again finds nothing left outside of retention
+	report, err = s.eraser.Erase(req)
+	require.NoError(t, err)
+	require.Len(t, report.Files, 1)
+	require.Equal(t, ActionRetained, report.Files[0].Action)
+}
+
+type memoryStore map[string][]byte
+
+func (s memoryStore) RedactContents(redact func(key string, contents []byte) ([]byte, bool)) error {
+	for key, contents := range s {
+		if redacted, changed := redact(key, contents); changed {
+			s[key] = redacted
+		}
+	}
+	return nil
+}
+
+func TestErasure__EraseStores(t *testing.T) {
+	s := setupEraser(t)
+
+	staged := memoryStore{
+		"live/abc": readTestFile(t, "return-WEB.ach"),
+		"live/def": []byte("other"),
+	}
+	s.eraser.WithStore(SourceStagedFiles, staged).WithStore(SourceRepresentments, nil)
+
+	req := Request{
+		RoutingNumber: "091000019",
+		AccountNumber: "123456789",
+		DryRun:        true,
+	}
+	report, err := s.eraser.Erase(req)
+	require.NoError(t, err)
+	require.Len(t, report.Files, 1)
+	require.Equal(t, SourceStagedFiles, report.Files[0].Source)
+	require.Equal(t, "live/abc", report.Files[0].Path)
+	require.Contains(t, string(staged["live/abc"]), "Paul Jones")
+
+	req.DryRun = false
+	report, err = s.eraser.Erase(req)
+	require.NoError(t, err)
+	require.Len(t, report.Files, 1)
+	require.Equal(t, ActionRedacted, report.Files[0].Action)
+	require.NotContains(t, string(staged["live/abc"]), "Paul Jones")
+	require.Equal(t, "other", string(staged["live/def"]))
+}
+
+func TestErasure__Verify(t *testing.T) {
+	s := setupEraser(t)
+
+	report, err := s.eraser.Erase(Request{RoutingNumber: "091000019", AccountNumber: "123456789"})
+	require.NoError(t, err)
+
+	// Reports verify after being encoded and read back
+	bs, err := MarshalReport(report)
+	require.NoError(t, err)
+	var decoded Report
+	require.NoError(t, json.Unmarshal(bs, &decoded))
+	require.NoError(t, Verify(decoded, s.publicKey))
+
+	decoded.AccountNumber = "*****1234"
+	require.ErrorContains(t, Verify(decoded, s.publicKey), "invalid report signature")
+
+	other, _ := writeSigningKey(t)
+	otherKey, err := ReadSigningKey(other)
+	require.NoError(t, err)
+	require.ErrorContains(t, Verify(*report, otherKey.Public().(ed25519.PublicKey)), "different key")
+
+	report.Signature = nil
+	require.ErrorContains(t, Verify(*report, s.publicKey), "not signed")
+}
+
+func TestRequest__Validate(t *testing.T) {
+	require.NoError(t, Request{RoutingNumber: "091000019", AccountNumber: "123456789"}.Validate())
+	require.ErrorContains(t, Request{RoutingNumber: "09100001", AccountNumber: "123456789"}.Validate(), "invalid routingNumber")
+	require.ErrorContains(t, Request{RoutingNumber: "09100001A", AccountNumber: "123456789"}.Validate(), "invalid routingNumber")
+	require.ErrorContains(t, Request{RoutingNumber: "091000019"}.Validate(), "invalid accountNumber")
+}
@@ -0,0 +1,152 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package erasure
+
+import (
+	"bytes"
+	"strings"
+)
+
+// recordLength is the length of every Nacha record
+const recordLength = 94
+
+// Receiver identifies whose entries are redacted
+type Receiver struct {
+	RoutingNumber string
+	AccountNumber string
+}
+
+type redaction struct {
+	entries      int
+	traceNumbers []string
+}
+
+// redact replaces the receiver's account number, name and identification number in each of
+// their entries within a Nacha file, along with the free form text or corrected data in the
+// entry's addenda. The file is edited record by record rather than parsed and written again, so
+// everything else (including control totals, which don't cover these fields) is left untouched.
+//
+// Entries in returns and notifications of change are matched by the original RDFI in their
+// addenda since the entry itself is addressed to the ODFI. Data which isn't a Nacha file is
+// returned unchanged with no entries redacted.
+func redact(data []byte, receiver Receiver) ([]byte, redaction) {
+	var out redaction
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	records := make([][]byte, len(lines))
+	for i := range lines {
+		records[i] = bytes.TrimRight(lines[i], "\r\n")
+	}
+	if len(records) == 0 || len(records[0]) != recordLength || records[0][0] != '1' {
+		return data, out
+	}
+
+	redacted := make([][]byte, len(records))
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if len(record) != recordLength || record[0] != '6' {
+			continue
+		}
+		// Gather the entry's addenda records
+		end := i + 1
+		for end < len(records) && len(records[end]) == recordLength && records[end][0] == '7' {
+			end++
+		}
+		if !matches(receiver, record, records[i+1:end]) {
+			i = end - 1
+			continue
+		}
+
+		entry := append([]byte(nil), record...)
+		out.traceNumbers = appendTraceNumber(out.traceNumbers, string(record[79:94]))
+		overwrite(entry, 12, 29, "REDACTED") // DFI account number
+		overwrite(entry, 39, 54, "")         // identification number
+		overwrite(entry, 54, 76, "REDACTED") // individual or company name
+		redacted[i] = entry
+
+		for j := i + 1; j < end; j++ {
+			addenda := append([]byte(nil), records[j]...)
+			switch string(addenda[1:3]) {
+			case "05":
+				overwrite(addenda, 3, 83, "") // payment related information
+			case "98":
+				out.traceNumbers = appendTraceNumber(out.traceNumbers, string(addenda[6:21]))
+				overwrite(addenda, 35, 64, "REDACTED") // corrected data
+			case "99":
+				out.traceNumbers = appendTraceNumber(out.traceNumbers, string(addenda[6:21]))
+				overwrite(addenda, 35, 79, "") // addenda information
+			}
+			redacted[j] = addenda
+		}
+		out.entries++
+		i = end - 1
+	}
+	if out.entries == 0 {
+		return data, out
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	for i := range lines {
+		if redacted[i] == nil {
+			buf.Write(lines[i])
+			continue
+		}
+		buf.Write(redacted[i])
+		buf.Write(lines[i][len(records[i]):]) // line ending
+	}
+	return buf.Bytes(), out
+}
+
+// matches reports if the entry is for the receiver's account, either directly or as the
+// original entry of a return or correction.
+func matches(receiver Receiver, entry []byte, addenda [][]byte) bool {
+	if strings.TrimSpace(string(entry[12:29])) != receiver.AccountNumber {
+		return false
+	}
+	if string(entry[3:12]) == receiver.RoutingNumber {
+		return true
+	}
+	for i := range addenda {
+		switch string(addenda[i][1:3]) {
+		case "98", "99":
+			if string(addenda[i][27:35]) == receiver.RoutingNumber[:8] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// overwrite replaces record[start:end] with value, padded with spaces
+func overwrite(record []byte, start, end int, value string) {
+	copy(record[start:end], value+strings.Repeat(" ", end-start-len(value)))
+}
+
+func appendTraceNumber(traceNumbers []string, traceNumber string) []string {
+	traceNumber = strings.TrimSpace(traceNumber)
+	if traceNumber == "" {
+		return traceNumbers
+	}
+	for i := range traceNumbers {
+		if traceNumbers[i] == traceNumber {
+			return traceNumbers
+		}
+	}
+	return append(traceNumbers, traceNumber)
+}
@@ -0,0 +1,96 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package erasure
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readTestFile(t *testing.T, name string) []byte {
+	t.Helper()
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "testdata", name))
+	require.NoError(t, err)
+	return bs
+}
+
+func TestRedact__Entry(t *testing.T) {
+	data := readTestFile(t, "ppd-debit.ach")
+
+	out, found := redact(data, Receiver{RoutingNumber: "053200019", AccountNumber: "12345"})
+	require.Equal(t, 1, found.entries)
+	require.Equal(t, []string{"076401255655291"}, found.traceNumbers)
+	require.Len(t, out, len(data))
+
+	lines := strings.Split(string(out), "\n")
+	require.Equal(t, "627053200019REDACTED         0000010500               REDACTED              DD0076401255655291", lines[2])
+	require.NotContains(t, string(out), "Bachman Eric")
+
+	// Every other record is untouched
+	original := strings.Split(string(data), "\n")
+	for i := range lines {
+		if i != 2 {
+			require.Equal(t, original[i], lines[i])
+		}
+	}
+}
+
+func TestRedact__Return(t *testing.T) {
+	data := readTestFile(t, "return-WEB.ach")
+
+	// Returns are addressed to the ODFI, so the original RDFI comes from the addenda
+	out, found := redact(data, Receiver{RoutingNumber: "091000019", AccountNumber: "123456789"})
+	require.Equal(t, 1, found.entries)
+	require.Equal(t, []string{"091000017611242", "091400600000001"}, found.traceNumbers)
+	require.NotContains(t, string(out), "Paul Jones")
+	require.Contains(t, string(out), "Bob Marley")
+
+	_, found = redact(data, Receiver{RoutingNumber: "091400606", AccountNumber: "999999999"})
+	require.Zero(t, found.entries)
+}
+
+func TestRedact__Correction(t *testing.T) {
+	data := readTestFile(t, "cor-c01.ach")
+
+	out, found := redact(data, Receiver{RoutingNumber: "121042882", AccountNumber: "744-5678-99"})
+	require.Equal(t, 1, found.entries)
+	require.NotContains(t, string(out), "744-5678-99")
+	require.NotContains(t, string(out), "1918171614") // corrected account number
+}
+
+func TestRedact__CRLF(t *testing.T) {
+	data := []byte(strings.ReplaceAll(string(readTestFile(t, "ppd-debit.ach")), "\n", "\r\n"))
+
+	out, found := redact(data, Receiver{RoutingNumber: "053200019", AccountNumber: "12345"})
+	require.Equal(t, 1, found.entries)
+	require.Len(t, out, len(data))
+	require.Equal(t, strings.Count(string(data), "\r\n"), strings.Count(string(out), "\r\n"))
+}
+
+func TestRedact__NotNacha(t *testing.T) {
+	data := []byte(`{"accountNumber": "12345"}`)
+
+	out, found := redact(data, Receiver{RoutingNumber: "053200019", AccountNumber: "12345"})
+	require.Zero(t, found.entries)
+	require.Equal(t, data, out)
+}
@@ -0,0 +1,109 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package erasure
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Signature is an Ed25519 signature over the report's JSON encoding without its Signature
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publicKey"`
+	Value     string `json:"value"`
+}
+
+const algorithmEd25519 = "ed25519"
+
+// ReadSigningKey reads a PEM encoded Ed25519 private key in PKCS #8 form, like the output of
+// `openssl genpkey -algorithm ed25519`
+func ReadSigningKey(where string) (ed25519.PrivateKey, error) {
+	bs, err := os.ReadFile(where)
+	if err != nil {
+		return nil, fmt.Errorf("reading signing key: %v", err)
+	}
+	block, _ := pem.Decode(bs)
+	if block == nil {
+		return nil, errors.New("reading signing key: no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("reading signing key: %v", err)
+	}
+	signer, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("reading signing key: unexpected %T key, expected ed25519", key)
+	}
+	return signer, nil
+}
+
+// Sign sets the report's Signature
+func Sign(report *Report, key ed25519.PrivateKey) error {
+	report.Signature = nil
+	bs, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("signing report: %v", err)
+	}
+	report.Signature = &Signature{
+		Algorithm: algorithmEd25519,
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(key, bs)),
+	}
+	return nil
+}
+
+// Verify checks the report was signed by publicKey and hasn't changed since
+func Verify(report Report, publicKey ed25519.PublicKey) error {
+	sig := report.Signature
+	if sig == nil {
+		return errors.New("report is not signed")
+	}
+	if sig.Algorithm != algorithmEd25519 {
+		return fmt.Errorf("unknown signature algorithm %q", sig.Algorithm)
+	}
+	if sig.PublicKey != base64.StdEncoding.EncodeToString(publicKey) {
+		return errors.New("report was signed by a different key")
+	}
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return fmt.Errorf("decoding signature: %v", err)
+	}
+
+	report.Signature = nil
+	bs, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, bs, value) {
+		return errors.New("invalid report signature")
+	}
+	return nil
+}
+
+// MarshalReport encodes a report as indented JSON. The signature covers the compact encoding,
+// so reports can be reformatted and still verify.
+func MarshalReport(report *Report) ([]byte, error) {
+	return json.MarshalIndent(report, "", "  ")
+}
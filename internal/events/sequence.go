@@ -0,0 +1,186 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package events
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/database"
+)
+
+// Sequencer returns increasing sequence numbers for each shard
+type Sequencer interface {
+	Next(shardKey string) (int64, error)
+}
+
+// NewSequencer returns a Sequencer shared by every instance using db. Without a database the
+// sequence numbers are only increasing for this instance and start over when it restarts.
+func NewSequencer(db *sql.DB) Sequencer {
+	if db == nil {
+		return &memorySequencer{sequences: make(map[string]int64)}
+	}
+	return &sqlSequencer{db: db}
+}
+
+type memorySequencer struct {
+	mu        sync.Mutex
+	sequences map[string]int64
+}
+
+func (s *memorySequencer) Next(shardKey string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sequences[shardKey]++
+	return s.sequences[shardKey], nil
+}
+
+type sqlSequencer struct {
+	db *sql.DB
+}
+
+func (s *sqlSequencer) Next(shardKey string) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("sequencing %s: %v", shardKey, err)
+	}
+	defer tx.Rollback()
+
+	// The update locks the shard's row until we commit, so concurrent instances wait their turn
+	res, err := tx.Exec(`update event_sequences set sequence = sequence + 1 where shard_key = ?;`, shardKey)
+	if err != nil {
+		return 0, fmt.Errorf("sequencing %s: %v", shardKey, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_, err = tx.Exec(`insert into event_sequences (shard_key, sequence) values (?, 1);`, shardKey)
+		if err != nil {
+			if database.UniqueViolation(err) {
+				// Another instance sent the shard's first event, so increment theirs
+				tx.Rollback()
+				return s.Next(shardKey)
+			}
+			return 0, fmt.Errorf("sequencing %s: %v", shardKey, err)
+		}
+	}
+
+	var seq int64
+	if err := tx.QueryRow(`select sequence from event_sequences where shard_key = ? limit 1;`, shardKey).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("sequencing %s: %v", shardKey, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("sequencing %s: %v", shardKey, err)
+	}
+	return seq, nil
+}
+
+// sequencedEmitter sets Metadata on events about a shard before they're sent
+type sequencedEmitter struct {
+	underlying Emitter
+	sequencer  Sequencer
+
+	mu     sync.Mutex
+	shards map[string]*sync.Mutex
+}
+
+// Sequenced numbers events about a shard with sequencer before they're sent with underlying.
+// Events for each shard are handed to underlying in the order of their sequence numbers.
+func Sequenced(underlying Emitter, sequencer Sequencer) Emitter {
+	return &sequencedEmitter{
+		underlying: underlying,
+		sequencer:  sequencer,
+		shards:     make(map[string]*sync.Mutex),
+	}
+}
+
+func (e *sequencedEmitter) Send(evt models.Event) error {
+	return e.send(evt, e.underlying.Send)
+}
+
+func (e *sequencedEmitter) Deliver(evt models.Event) error {
+	return e.send(evt, func(evt models.Event) error {
+		return Deliver(e.underlying, evt)
+	})
+}
+
+func (e *sequencedEmitter) send(evt models.Event, send func(models.Event) error) error {
+	shardKey := eventShardKey(evt.Event)
+	if shardKey == "" {
+		return send(evt)
+	}
+
+	// Hold the shard's lock until the event is handed off so it isn't passed by a later one
+	lock := e.shardLock(shardKey)
+	lock.Lock()
+	defer lock.Unlock()
+
+	seq, err := e.sequencer.Next(shardKey)
+	if err != nil {
+		return fmt.Errorf("sending %T: %v", evt.Event, err)
+	}
+	evt.Metadata = &models.EventMetadata{
+		ShardKey: shardKey,
+		Sequence: seq,
+	}
+	return send(evt)
+}
+
+func (e *sequencedEmitter) shardLock(shardKey string) *sync.Mutex {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	lock, exists := e.shards[shardKey]
+	if !exists {
+		lock = &sync.Mutex{}
+		e.shards[shardKey] = lock
+	}
+	return lock
+}
+
+func (e *sequencedEmitter) Close() error {
+	return e.underlying.Close()
+}
+
+// eventShardKey returns the shard an event is about, or an empty string for other events
+func eventShardKey(evt interface{}) string {
+	switch e := evt.(type) {
+	case models.FileUploaded:
+		return e.ShardKey
+	case *models.FileUploaded:
+		return e.ShardKey
+	case models.CutoffTakenOver:
+		return e.ShardKey
+	case *models.CutoffTakenOver:
+		return e.ShardKey
+	case models.FileRejected:
+		return e.ShardKey
+	case *models.FileRejected:
+		return e.ShardKey
+	case models.FileRolledOver:
+		return e.ShardKey
+	case *models.FileRolledOver:
+		return e.ShardKey
+	case models.EntryAccepted:
+		return e.ShardKey
+	case *models.EntryAccepted:
+		return e.ShardKey
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package events
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequencer(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	sequencers := map[string]Sequencer{
+		"memory": NewSequencer(nil),
+		"sql":    NewSequencer(db.DB),
+	}
+	for name, seq := range sequencers {
+		t.Run(name, func(t *testing.T) {
+			for i := int64(1); i <= 3; i++ {
+				n, err := seq.Next("live")
+				require.NoError(t, err)
+				require.Equal(t, i, n)
+			}
+			n, err := seq.Next("testing")
+			require.NoError(t, err)
+			require.Equal(t, int64(1), n)
+		})
+	}
+}
+
+func TestSequencer__Shared(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	// Instances sharing a database never repeat a sequence number
+	first, second := NewSequencer(db.DB), NewSequencer(db.DB)
+
+	var mu sync.Mutex
+	seen := make(map[int64]bool)
+
+	var wg sync.WaitGroup
+	for _, seq := range []Sequencer{first, second} {
+		wg.Add(1)
+		go func(seq Sequencer) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				n, err := seq.Next("live")
+				require.NoError(t, err)
+
+				mu.Lock()
+				require.False(t, seen[n])
+				seen[n] = true
+				mu.Unlock()
+			}
+		}(seq)
+	}
+	wg.Wait()
+	require.Len(t, seen, 20)
+}
+
+func TestSequenced(t *testing.T) {
+	underlying := &countingEmitter{}
+	emitter := Sequenced(underlying, NewSequencer(nil))
+
+	require.NoError(t, emitter.Send(models.Event{Event: models.FileUploaded{ShardKey: "live"}}))
+	require.NoError(t, emitter.Send(models.Event{Event: models.CutoffTakenOver{ShardKey: "live"}}))
+	require.NoError(t, emitter.Send(models.Event{Event: models.ReturnFile{}}))
+	require.NoError(t, emitter.Close())
+
+	require.Len(t, underlying.sent, 3)
+	require.Equal(t, &models.EventMetadata{ShardKey: "live", Sequence: 1}, underlying.sent[0].Metadata)
+	require.Equal(t, &models.EventMetadata{ShardKey: "live", Sequence: 2}, underlying.sent[1].Metadata)
+	require.Nil(t, underlying.sent[2].Metadata)
+	require.True(t, underlying.closed)
+}
@@ -21,25 +21,54 @@ import (
 	"errors"
 
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/signing"
 	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base/log"
 )
 
 type Emitter interface {
 	Send(evt models.Event) error
+
+	// Close publishes any pending events and releases resources
+	Close() error
+}
+
+// deliverer is implemented by Emitters whose Send can return before the event is published
+type deliverer interface {
+	Deliver(evt models.Event) error
+}
+
+// Deliver sends evt and returns once it's published, with the error from publishing it. Callers
+// which only move on once an event was published, like deleting the file it came from, use
+// Deliver instead of Send as Send on emitters with Publishing configured returns once a
+// background worker picks up the event.
+func Deliver(emitter Emitter, evt models.Event) error {
+	if d, ok := emitter.(deliverer); ok {
+		return d.Deliver(evt)
+	}
+	return emitter.Send(evt)
 }
 
-func NewEmitter(logger log.Logger, cfg *service.EventsConfig) (Emitter, error) {
+// NewEmitter returns an Emitter for cfg which signs events with keys, when it's not nil.
+func NewEmitter(logger log.Logger, cfg *service.EventsConfig, keys *signing.Keyring) (Emitter, error) {
 	if cfg == nil {
 		return &MockEmitter{}, nil
 	}
+	emitter, err := newEmitter(logger, cfg, keys)
+	if err != nil || cfg.Publishing == nil {
+		return emitter, err
+	}
+	return newAsyncEmitter(logger, emitter, cfg.Publishing.WorkerCount()), nil
+}
+
+func newEmitter(logger log.Logger, cfg *service.EventsConfig, keys *signing.Keyring) (Emitter, error) {
 	if cfg.Stream != nil {
 		if cfg.Stream.Kafka != nil {
-			return newStreamService(logger, cfg.Transform, cfg.Stream.Kafka)
+			return newStreamService(logger, cfg.Transform, cfg.Stream.Kafka, keys)
 		}
 	}
 	if cfg.Webhook != nil {
-		return newWebhookService(logger, cfg.Transform, cfg.Webhook)
+		return newWebhookService(logger, cfg.Transform, cfg.Webhook, keys)
 	}
 	return nil, errors.New("unknown events config")
 }
@@ -49,3 +78,7 @@ type MockEmitter struct{}
 func (*MockEmitter) Send(evt models.Event) error {
 	return nil
 }
+
+func (*MockEmitter) Close() error {
+	return nil
+}
@@ -0,0 +1,171 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package events
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	eventsPublished = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "events_published",
+		Help: "Counter of events published by background workers",
+	}, nil)
+	eventPublishErrors = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "event_publish_errors",
+		Help: "Counter of errors from background workers publishing events",
+	}, nil)
+)
+
+var errEmitterClosed = errors.New("events: emitter closed")
+
+// asyncEmitter hands events to a pool of workers which publish them with the underlying
+// Emitter. Send only blocks while every worker is busy, which lets callers move on to the
+// next event and lets the underlying Emitter batch events sent at the same time.
+//
+// Sequenced events for a shard are always published by the same worker so they're published
+// in order.
+//
+// Errors from publishing are logged rather than returned to the caller, unless the event was
+// sent with Deliver.
+type asyncEmitter struct {
+	logger     log.Logger
+	underlying Emitter
+
+	events  chan asyncEvent
+	ordered []chan asyncEvent
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// asyncEvent is an event waiting on a worker. done receives the result of publishing it when
+// the event was sent with Deliver.
+type asyncEvent struct {
+	evt  models.Event
+	done chan error
+}
+
+func newAsyncEmitter(logger log.Logger, underlying Emitter, workers int) *asyncEmitter {
+	emitter := &asyncEmitter{
+		logger:     logger,
+		underlying: underlying,
+		events:     make(chan asyncEvent),
+	}
+	for i := 0; i < workers; i++ {
+		ordered := make(chan asyncEvent)
+		emitter.ordered = append(emitter.ordered, ordered)
+
+		emitter.wg.Add(1)
+		go emitter.work(ordered)
+	}
+	return emitter
+}
+
+func (e *asyncEmitter) work(ordered chan asyncEvent) {
+	defer e.wg.Done()
+
+	events := e.events
+	for events != nil || ordered != nil {
+		var job asyncEvent
+		var ok bool
+		select {
+		case job, ok = <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+		case job, ok = <-ordered:
+			if !ok {
+				ordered = nil
+				continue
+			}
+		}
+
+		err := e.underlying.Send(job.evt)
+		if err != nil {
+			eventPublishErrors.With().Add(1)
+			if job.done == nil {
+				e.logger.Warn().LogErrorf("problem publishing %T event: %v", job.evt.Event, err)
+			}
+		} else {
+			eventsPublished.With().Add(1)
+		}
+		if job.done != nil {
+			job.done <- err
+		}
+	}
+}
+
+func (e *asyncEmitter) Send(evt models.Event) error {
+	return e.enqueue(asyncEvent{evt: evt})
+}
+
+// Deliver waits for evt to be published and returns the error from publishing it
+func (e *asyncEmitter) Deliver(evt models.Event) error {
+	done := make(chan error, 1)
+	if err := e.enqueue(asyncEvent{evt: evt, done: done}); err != nil {
+		return err
+	}
+	return <-done
+}
+
+func (e *asyncEmitter) enqueue(job asyncEvent) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.closed {
+		return fmt.Errorf("sending %T: %w", job.evt.Event, errEmitterClosed)
+	}
+	if job.evt.Metadata != nil {
+		h := fnv.New32a()
+		h.Write([]byte(job.evt.Metadata.ShardKey))
+		e.ordered[int(h.Sum32()%uint32(len(e.ordered)))] <- job
+	} else {
+		e.events <- job
+	}
+	return nil
+}
+
+// Close waits for in-flight events to be published and then closes the underlying Emitter.
+func (e *asyncEmitter) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	close(e.events)
+	for i := range e.ordered {
+		close(e.ordered[i])
+	}
+	e.mu.Unlock()
+
+	e.wg.Wait()
+	return e.underlying.Close()
+}
@@ -0,0 +1,160 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package events
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+type countingEmitter struct {
+	mu      sync.Mutex
+	sent    []models.Event
+	closed  bool
+	err     error
+	block   chan struct{}
+	current int32
+	max     int32
+}
+
+func (e *countingEmitter) Send(evt models.Event) error {
+	n := atomic.AddInt32(&e.current, 1)
+	defer atomic.AddInt32(&e.current, -1)
+	for {
+		max := atomic.LoadInt32(&e.max)
+		if n <= max || atomic.CompareAndSwapInt32(&e.max, max, n) {
+			break
+		}
+	}
+	if e.block != nil {
+		<-e.block
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sent = append(e.sent, evt)
+	return e.err
+}
+
+func (e *countingEmitter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	return nil
+}
+
+func TestAsyncEmitter(t *testing.T) {
+	underlying := &countingEmitter{}
+	emitter := newAsyncEmitter(log.NewTestLogger(), underlying, 2)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, emitter.Send(models.Event{Event: models.FileUploaded{}}))
+	}
+	require.NoError(t, emitter.Close())
+
+	underlying.mu.Lock()
+	require.Len(t, underlying.sent, 10)
+	require.True(t, underlying.closed)
+	underlying.mu.Unlock()
+
+	// Sending after Close fails and closing again is a no-op
+	err := emitter.Send(models.Event{Event: models.FileUploaded{}})
+	require.ErrorIs(t, err, errEmitterClosed)
+	require.NoError(t, emitter.Close())
+}
+
+func TestAsyncEmitter__Concurrent(t *testing.T) {
+	underlying := &countingEmitter{
+		block: make(chan struct{}),
+	}
+	emitter := newAsyncEmitter(log.NewTestLogger(), underlying, 3)
+
+	// Each Send returns once a worker picks up the event
+	for i := 0; i < 3; i++ {
+		require.NoError(t, emitter.Send(models.Event{Event: models.FileUploaded{}}))
+	}
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&underlying.current) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(underlying.block)
+	require.NoError(t, emitter.Close())
+	require.Equal(t, int32(3), atomic.LoadInt32(&underlying.max))
+}
+
+func TestAsyncEmitter__Errors(t *testing.T) {
+	underlying := &countingEmitter{
+		err: errors.New("bad thing"),
+	}
+	emitter := newAsyncEmitter(log.NewTestLogger(), underlying, 1)
+
+	// Publishing errors are logged, not returned
+	require.NoError(t, emitter.Send(models.Event{Event: models.FileUploaded{}}))
+
+	// Unless the caller waits for the event to be delivered
+	err := Deliver(emitter, models.Event{Event: models.FileUploaded{}})
+	require.ErrorContains(t, err, "bad thing")
+
+	sequenced := Sequenced(emitter, NewSequencer(nil))
+	err = Deliver(sequenced, models.Event{Event: models.FileUploaded{ShardKey: "s1"}})
+	require.ErrorContains(t, err, "bad thing")
+	require.NoError(t, emitter.Close())
+
+	underlying.mu.Lock()
+	require.Len(t, underlying.sent, 3)
+	require.NotNil(t, underlying.sent[2].Metadata)
+	underlying.mu.Unlock()
+
+	err = Deliver(emitter, models.Event{Event: models.FileUploaded{}})
+	require.ErrorIs(t, err, errEmitterClosed)
+}
+
+func TestAsyncEmitter__Ordered(t *testing.T) {
+	underlying := &countingEmitter{}
+	emitter := newAsyncEmitter(log.NewTestLogger(), underlying, 4)
+
+	for i := 1; i <= 50; i++ {
+		for _, shardKey := range []string{"live", "testing"} {
+			err := emitter.Send(models.Event{
+				Event:    models.FileUploaded{ShardKey: shardKey},
+				Metadata: &models.EventMetadata{ShardKey: shardKey, Sequence: int64(i)},
+			})
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, emitter.Close())
+
+	// Each shard's events are published in order
+	underlying.mu.Lock()
+	defer underlying.mu.Unlock()
+
+	require.Len(t, underlying.sent, 100)
+	last := make(map[string]int64)
+	for _, evt := range underlying.sent {
+		require.Equal(t, last[evt.Metadata.ShardKey]+1, evt.Metadata.Sequence)
+		last[evt.Metadata.ShardKey] = evt.Metadata.Sequence
+	}
+}
@@ -20,9 +20,11 @@ package events
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/moov-io/achgateway/internal/incoming/stream"
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/signing"
 	"github.com/moov-io/achgateway/pkg/compliance"
 	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base/log"
@@ -33,9 +35,10 @@ import (
 type streamService struct {
 	transformConfig *models.TransformConfig
 	topic           *pubsub.Topic
+	keys            *signing.Keyring
 }
 
-func newStreamService(logger log.Logger, transformConfig *models.TransformConfig, cfg *service.KafkaConfig) (*streamService, error) {
+func newStreamService(logger log.Logger, transformConfig *models.TransformConfig, cfg *service.KafkaConfig, keys *signing.Keyring) (*streamService, error) {
 	topic, err := stream.Topic(logger, &service.Config{
 		Inbound: service.Inbound{
 			Kafka: cfg,
@@ -47,6 +50,7 @@ func newStreamService(logger log.Logger, transformConfig *models.TransformConfig
 	return &streamService{
 		topic:           topic,
 		transformConfig: transformConfig,
+		keys:            keys,
 	}, nil
 }
 
@@ -55,11 +59,36 @@ func (ss *streamService) Send(evt models.Event) error {
 	if err != nil {
 		return err
 	}
-	err = ss.topic.Send(context.Background(), &pubsub.Message{
+	msg := &pubsub.Message{
 		Body: bs,
-	})
+	}
+	// Sequenced events are keyed by shard so Kafka keeps each shard's events in order
+	if evt.Metadata != nil {
+		msg.Metadata = map[string]string{
+			stream.KeyMetadata: evt.Metadata.ShardKey,
+			"sequence":         strconv.FormatInt(evt.Metadata.Sequence, 10),
+		}
+	}
+	signature, err := ss.keys.Sign(bs)
+	if err != nil {
+		return err
+	}
+	if signature != "" {
+		if msg.Metadata == nil {
+			msg.Metadata = make(map[string]string)
+		}
+		msg.Metadata[signing.MetadataKey] = signature
+	}
+	err = ss.topic.Send(context.Background(), msg)
 	if err != nil {
 		return fmt.Errorf("error emitting %s: %v", evt.Type, err)
 	}
 	return nil
 }
+
+func (ss *streamService) Close() error {
+	if ss == nil || ss.topic == nil {
+		return nil
+	}
+	return ss.topic.Shutdown(context.Background())
+}
@@ -23,6 +23,7 @@ import (
 	"net/url"
 
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/signing"
 	"github.com/moov-io/achgateway/pkg/compliance"
 	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base/log"
@@ -36,9 +37,10 @@ type webhookService struct {
 	client          *retryablehttp.Client
 	endpoint        *url.URL
 	logger          log.Logger
+	keys            *signing.Keyring
 }
 
-func newWebhookService(logger log.Logger, transformConfig *models.TransformConfig, cfg *service.WebhookConfig) (*webhookService, error) {
+func newWebhookService(logger log.Logger, transformConfig *models.TransformConfig, cfg *service.WebhookConfig, keys *signing.Keyring) (*webhookService, error) {
 	if cfg == nil || cfg.Endpoint == "" {
 		return nil, nil
 	}
@@ -52,6 +54,7 @@ func newWebhookService(logger log.Logger, transformConfig *models.TransformConfi
 		client:          retryablehttp.NewClient(),
 		endpoint:        u,
 		logger:          logger,
+		keys:            keys,
 	}, nil
 }
 
@@ -60,10 +63,17 @@ func (w *webhookService) Send(evt models.Event) error {
 	if err != nil {
 		return err
 	}
+	signature, err := w.keys.Sign(bs)
+	if err != nil {
+		return err
+	}
 	req, err := retryablehttp.NewRequest("POST", w.endpoint.String(), bytes.NewReader(bs))
 	if err != nil {
 		return fmt.Errorf("error preparing request: %v", err)
 	}
+	if signature != "" {
+		req.Header.Set(signing.Header, signature)
+	}
 	resp, err := w.client.Do(req)
 	if err != nil {
 		w.logger.Info().Logf("problem sending event: %v", err)
@@ -73,3 +83,7 @@ func (w *webhookService) Send(evt models.Event) error {
 	}
 	return nil
 }
+
+func (w *webhookService) Close() error {
+	return nil
+}
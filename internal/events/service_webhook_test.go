@@ -21,11 +21,14 @@ import (
 	"io"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/signing"
 	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base"
 	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/database"
 	"github.com/moov-io/base/log"
 
 	"github.com/stretchr/testify/require"
@@ -51,7 +54,7 @@ func TestWebhookService(t *testing.T) {
 
 	svc, err := newWebhookService(log.NewNopLogger(), nil, &service.WebhookConfig{
 		Endpoint: "http://" + admin.BindAddr() + "/hook",
-	})
+	}, nil)
 	require.NoError(t, err)
 
 	shardKey, fileID := base.ID(), base.ID()
@@ -66,3 +69,39 @@ func TestWebhookService(t *testing.T) {
 	require.Equal(t, shardKey, body.ShardKey)
 	require.Equal(t, fileID, body.FileID)
 }
+
+func TestWebhookService__Signed(t *testing.T) {
+	admin := admin.NewServer(":0")
+	go admin.Listen()
+	t.Cleanup(func() { admin.Shutdown() })
+
+	var body []byte
+	var signature string
+	admin.AddHandler("/hook", func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(signing.Header)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	keys := signing.NewKeyring(signing.NewRepository(db.DB), &service.EventsSigning{})
+	key, err := keys.Create()
+	require.NoError(t, err)
+
+	svc, err := newWebhookService(log.NewNopLogger(), nil, &service.WebhookConfig{
+		Endpoint: "http://" + admin.BindAddr() + "/hook",
+	}, keys)
+	require.NoError(t, err)
+
+	err = svc.Send(models.Event{
+		Event: models.FileUploaded{
+			FileID:   base.ID(),
+			ShardKey: base.ID(),
+		},
+	})
+	require.NoError(t, err)
+
+	secrets := map[string]string{key.ID: key.Secret}
+	require.NoError(t, signing.Verify(signature, secrets, body, time.Minute, time.Now()))
+}
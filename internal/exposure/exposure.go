@@ -0,0 +1,131 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package exposure records the totals uploaded to and returned by each ODFI so the daily
+// position of each ODFI can be compared against its exposure limit.
+package exposure
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/moov-io/ach"
+)
+
+const (
+	KindUploaded = "uploaded"
+	KindReturned = "returned"
+)
+
+// Totals are the debits and credits (in cents) of one ODFI
+type Totals struct {
+	Debits  int
+	Credits int
+}
+
+// Position is an ODFI's activity on one day
+type Position struct {
+	ODFI string `json:"odfi"`
+	Date string `json:"date"` // YYYY-MM-DD
+
+	UploadedDebits  int `json:"uploadedDebits"`
+	UploadedCredits int `json:"uploadedCredits"`
+	ReturnedDebits  int `json:"returnedDebits"`
+	ReturnedCredits int `json:"returnedCredits"`
+
+	// NetExposure is what the ODFI has paid out or must recover for the originator: credits
+	// which weren't returned plus debits which were.
+	NetExposure int `json:"netExposure"`
+
+	// Limit is the ODFI's configured daily exposure limit, or zero without one
+	Limit     int  `json:"limit,omitempty"`
+	OverLimit bool `json:"overLimit"`
+}
+
+// Uploaded totals the entries of an uploaded file by the ODFI of each batch
+func Uploaded(file *ach.File) map[string]Totals {
+	out := make(map[string]Totals)
+	if file == nil {
+		return out
+	}
+	for i := range file.Batches {
+		odfi := strings.TrimSpace(file.Batches[i].GetHeader().ODFIIdentification)
+		for _, entry := range file.Batches[i].GetEntries() {
+			add(out, odfi, entry)
+		}
+	}
+	return out
+}
+
+// Returned totals the return entries of a file downloaded from an ODFI. Each return is sent to
+// the ODFI of the original entry, so returns are totaled by their receiving DFI.
+func Returned(file *ach.File) map[string]Totals {
+	out := make(map[string]Totals)
+	if file == nil {
+		return out
+	}
+	for i := range file.ReturnEntries {
+		for _, entry := range file.ReturnEntries[i].GetEntries() {
+			if entry.Addenda99 == nil {
+				continue
+			}
+			add(out, strings.TrimSpace(entry.RDFIIdentification), entry)
+		}
+	}
+	return out
+}
+
+func add(totals map[string]Totals, odfi string, entry *ach.EntryDetail) {
+	t := totals[odfi]
+	if entry.CreditOrDebit() == "C" {
+		t.Credits += entry.Amount
+	} else {
+		t.Debits += entry.Amount
+	}
+	totals[odfi] = t
+}
+
+// Limits are daily exposure limits keyed by ODFI routing number. Nine digit routing numbers match
+// the eight digit ODFI identification of entries.
+type Limits map[string]int
+
+func (l Limits) find(odfi string) int {
+	for routing, limit := range l {
+		routing = strings.TrimSpace(routing)
+		if routing == odfi || (len(routing) == 9 && routing[:8] == odfi) {
+			return limit
+		}
+	}
+	return 0
+}
+
+// apply computes the net exposure of each position and compares it to the ODFI's limit
+func apply(positions []Position, limits Limits) []Position {
+	for i := range positions {
+		p := &positions[i]
+		p.NetExposure = p.UploadedCredits - p.ReturnedCredits + p.ReturnedDebits
+		p.Limit = limits.find(p.ODFI)
+		p.OverLimit = p.Limit > 0 && p.NetExposure > p.Limit
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].Date == positions[j].Date {
+			return positions[i].ODFI < positions[j].ODFI
+		}
+		return positions[i].Date < positions[j].Date
+	})
+	return positions
+}
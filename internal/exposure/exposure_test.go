@@ -0,0 +1,84 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package exposure
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/pkg/rdfi"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readFile(t *testing.T, name string) *ach.File {
+	t.Helper()
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", name))
+	require.NoError(t, err)
+	return file
+}
+
+func TestUploaded(t *testing.T) {
+	require.Empty(t, Uploaded(nil))
+
+	totals := Uploaded(readFile(t, "two-micro-deposits.ach"))
+	require.Equal(t, map[string]Totals{
+		"12104288": {Debits: 120, Credits: 120},
+	}, totals)
+}
+
+func TestReturned(t *testing.T) {
+	require.Empty(t, Returned(nil))
+
+	returned, err := rdfi.Return(readFile(t, "two-micro-deposits.ach"), rdfi.Options{Code: "R03"})
+	require.NoError(t, err)
+	returned.ReturnEntries = returned.Batches
+
+	// Returns are sent back to the original ODFI
+	totals := Returned(returned)
+	require.Equal(t, map[string]Totals{
+		"12104288": {Debits: 120, Credits: 120},
+	}, totals)
+}
+
+func TestApply(t *testing.T) {
+	positions := apply([]Position{
+		{ODFI: "12104288", Date: "2022-08-02", UploadedCredits: 500},
+		{ODFI: "07640125", Date: "2022-08-01", UploadedDebits: 100, UploadedCredits: 1000, ReturnedDebits: 50, ReturnedCredits: 200},
+		{ODFI: "12104288", Date: "2022-08-01", UploadedCredits: 100},
+	}, Limits{"076401251": 800, "12104288": 1000})
+
+	require.Len(t, positions, 3)
+
+	require.Equal(t, "07640125", positions[0].ODFI)
+	require.Equal(t, "2022-08-01", positions[0].Date)
+	require.Equal(t, 850, positions[0].NetExposure)
+	require.Equal(t, 800, positions[0].Limit)
+	require.True(t, positions[0].OverLimit)
+
+	require.Equal(t, "12104288", positions[1].ODFI)
+	require.Equal(t, "2022-08-01", positions[1].Date)
+	require.Equal(t, 100, positions[1].NetExposure)
+	require.False(t, positions[1].OverLimit)
+
+	require.Equal(t, "2022-08-02", positions[2].Date)
+	require.Equal(t, 500, positions[2].NetExposure)
+	require.Equal(t, 1000, positions[2].Limit)
+}
@@ -0,0 +1,132 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package exposure
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type Repository interface {
+	// Record adds the totals of each ODFI to their activity on the day of when
+	Record(shardName, kind string, totals map[string]Totals, when time.Time) error
+
+	// Positions returns each ODFI's position on the days between from and to (YYYY-MM-DD),
+	// inclusive. Positions are limited to odfi when it's non-empty, which can be an eight digit
+	// ODFI identification or nine digit routing number.
+	Positions(from, to, odfi string, limits Limits) ([]Position, error)
+}
+
+// NewRepository returns a Repository stored in db, or nil when db is nil
+func NewRepository(db *sql.DB) Repository {
+	if db == nil {
+		return nil
+	}
+	return &sqlRepository{db: db, now: time.Now}
+}
+
+type sqlRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func (r *sqlRepository) Record(shardName, kind string, totals map[string]Totals, when time.Time) error {
+	if len(totals) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("recording %s exposure: %v", kind, err)
+	}
+	defer tx.Rollback()
+
+	query := `insert into odfi_activity (odfi, activity_date, shard_name, kind, debits, credits, created_at) values (?, ?, ?, ?, ?, ?, ?);`
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("recording %s exposure: %v", kind, err)
+	}
+	defer stmt.Close()
+
+	day := when.Format("2006-01-02")
+	now := r.now().UTC().Truncate(time.Second)
+	for odfi, t := range totals {
+		if _, err := stmt.Exec(odfi, day, shardName, kind, t.Debits, t.Credits, now); err != nil {
+			return fmt.Errorf("recording %s exposure of %s: %v", kind, odfi, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("recording %s exposure: %v", kind, err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) Positions(from, to, odfi string, limits Limits) ([]Position, error) {
+	query := `select odfi, activity_date, kind, sum(debits), sum(credits) from odfi_activity where activity_date >= ? and activity_date <= ?`
+	args := []interface{}{from, to}
+	if len(odfi) == 9 {
+		odfi = odfi[:8]
+	}
+	if odfi != "" {
+		query += ` and odfi = ?`
+		args = append(args, odfi)
+	}
+	query += ` group by odfi, activity_date, kind;`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("reading exposure: %v", err)
+	}
+	defer rows.Close()
+
+	type key struct{ odfi, date string }
+	positions := make(map[key]*Position)
+	var order []key
+	for rows.Next() {
+		var k key
+		var kind string
+		var debits, credits int
+		if err := rows.Scan(&k.odfi, &k.date, &kind, &debits, &credits); err != nil {
+			return nil, fmt.Errorf("reading exposure: %v", err)
+		}
+		p, exists := positions[k]
+		if !exists {
+			p = &Position{ODFI: k.odfi, Date: k.date}
+			positions[k] = p
+			order = append(order, k)
+		}
+		switch kind {
+		case KindUploaded:
+			p.UploadedDebits += debits
+			p.UploadedCredits += credits
+		case KindReturned:
+			p.ReturnedDebits += debits
+			p.ReturnedCredits += credits
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading exposure: %v", err)
+	}
+
+	out := make([]Position, 0, len(order))
+	for _, k := range order {
+		out = append(out, *positions[k])
+	}
+	return apply(out, limits), nil
+}
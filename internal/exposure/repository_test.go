@@ -0,0 +1,83 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package exposure
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository(t *testing.T) {
+	require.Nil(t, NewRepository(nil))
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := NewRepository(db.DB)
+
+	day1 := time.Date(2022, time.August, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	require.NoError(t, repo.Record("testing", KindUploaded, Uploaded(readFile(t, "ppd-debit.ach")), day1))
+	require.NoError(t, repo.Record("testing", KindUploaded, Uploaded(readFile(t, "two-micro-deposits.ach")), day1))
+	require.NoError(t, repo.Record("testing", KindUploaded, Uploaded(readFile(t, "two-micro-deposits.ach")), day1))
+	require.NoError(t, repo.Record("", KindReturned, map[string]Totals{"12104288": {Debits: 44, Credits: 76}}, day2))
+	require.NoError(t, repo.Record("", KindReturned, nil, day2))
+
+	positions, err := repo.Positions("2022-08-01", "2022-08-02", "", Limits{"121042882": 100})
+	require.NoError(t, err)
+	require.Len(t, positions, 3)
+
+	require.Equal(t, Position{
+		ODFI:           "07640125",
+		Date:           "2022-08-01",
+		UploadedDebits: 10500,
+	}, positions[0])
+
+	require.Equal(t, Position{
+		ODFI:            "12104288",
+		Date:            "2022-08-01",
+		UploadedDebits:  240,
+		UploadedCredits: 240,
+		NetExposure:     240,
+		Limit:           100,
+		OverLimit:       true,
+	}, positions[1])
+
+	require.Equal(t, Position{
+		ODFI:            "12104288",
+		Date:            "2022-08-02",
+		ReturnedDebits:  44,
+		ReturnedCredits: 76,
+		NetExposure:     -32,
+		Limit:           100,
+	}, positions[2])
+
+	// Limit positions to one ODFI and day
+	positions, err = repo.Positions("2022-08-02", "2022-08-02", "121042882", nil)
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	require.Equal(t, "2022-08-02", positions[0].Date)
+
+	positions, err = repo.Positions("2022-08-03", "2022-08-04", "", nil)
+	require.NoError(t, err)
+	require.Empty(t, positions)
+}
@@ -0,0 +1,204 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package fabricate implements `achgateway rdfi` which creates the return and correction
+// files a bank would send back for an uploaded file. Files are written locally or dropped
+// into an upload agent's return or inbound directory to test ODFI processing end-to-end.
+package fabricate
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/pkg/rdfi"
+	"github.com/moov-io/base/log"
+)
+
+const usage = `Usage: achgateway rdfi <return|correct> -file <path> [flags]
+
+Commands:
+  return   Create a return file for the entries of -file
+  correct  Create a notification of change (COR) file for the entries of -file
+
+The file is written into -out, or uploaded into the return (or inbound) path of -agent
+where achgateway's ODFI processors will pick it up.
+`
+
+// Run executes the rdfi subcommand named by args[0] and writes its output to out.
+func Run(args []string, out io.Writer, logger log.Logger, loadConfig service.ConfigLoader) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, usage)
+		return errors.New("missing command")
+	}
+
+	var create func(*ach.File, rdfi.Options) (*ach.File, error)
+	var prefix string
+	switch args[0] {
+	case "return":
+		create, prefix = rdfi.Return, "return"
+	case "correct":
+		create, prefix = rdfi.Correct, "correction"
+	default:
+		fmt.Fprint(out, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	fs := flag.NewFlagSet("achgateway rdfi "+args[0], flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprint(out, usage+"\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	flagFile := fs.String("file", "", "Uploaded file to respond to, in Nacha or JSON format")
+	flagCode := fs.String("code", "", "Return code (default R01) or change code (default C01)")
+	flagTraces := fs.String("trace", "", "Comma separated trace numbers to respond to, defaults to every entry")
+	flagCorrectedData := fs.String("corrected-data", "", "Corrected data for change codes without a default")
+	flagFilename := fs.String("filename", "", "Filename to write, defaults to <return|correction>-<timestamp>.ach")
+	flagOut := fs.String("out", ".", "Directory to write the file into when -agent is empty")
+	flagAgent := fs.String("agent", "", "Upload agent ID to drop the file onto")
+	flagDir := fs.String("dir", "return", "Agent path to upload into: return or inbound")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *flagFile == "" {
+		fs.Usage()
+		return errors.New("missing -file")
+	}
+
+	original, err := readFile(*flagFile)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	opts := rdfi.Options{
+		Code:          strings.ToUpper(*flagCode),
+		CorrectedData: *flagCorrectedData,
+		Now:           now,
+	}
+	if *flagTraces != "" {
+		for _, trace := range strings.Split(*flagTraces, ",") {
+			opts.TraceNumbers = append(opts.TraceNumbers, strings.TrimSpace(trace))
+		}
+	}
+
+	file, err := create(original, opts)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := ach.NewWriter(&buf).Write(file); err != nil {
+		return fmt.Errorf("writing %s file: %v", prefix, err)
+	}
+
+	filename := *flagFilename
+	if filename == "" {
+		filename = fmt.Sprintf("%s-%s.ach", prefix, now.Format("20060102-150405"))
+	}
+
+	var where string
+	if *flagAgent != "" {
+		cfg, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf("loading config: %v", err)
+		}
+		where, err = uploadFile(logger, cfg.Upload, *flagAgent, *flagDir, filename, buf.Bytes())
+		if err != nil {
+			return err
+		}
+	} else {
+		where = filepath.Join(*flagOut, filepath.Base(filename))
+		if err := os.WriteFile(where, buf.Bytes(), 0600); err != nil {
+			return err
+		}
+	}
+
+	entries := 0
+	for _, b := range file.Batches {
+		entries += len(b.GetEntries())
+	}
+	fmt.Fprintf(out, "Wrote %s file with %d entries to %s\n", prefix, entries, where)
+	return nil
+}
+
+func readFile(path string) (*ach.File, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		file, err := ach.FileFromJSON(bs)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", path, err)
+		}
+		return file, nil
+	}
+	file, err := ach.NewReader(bytes.NewReader(bs)).Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	return &file, nil
+}
+
+// uploadFile writes contents into the agent's return or inbound path and returns where it was written.
+func uploadFile(logger log.Logger, cfg service.UploadAgents, agentID, dir, filename string, contents []byte) (string, error) {
+	conf := cfg.Find(agentID)
+	if conf == nil {
+		return "", fmt.Errorf("upload: unknown Agent ID=%s", agentID)
+	}
+
+	// Agents only upload into their outbound path, so point a copy of the agent at the target path
+	agentCfg := *conf
+	agentCfg.ID = fmt.Sprintf("%s-rdfi-%s", conf.ID, dir)
+	switch dir {
+	case "return":
+		agentCfg.Paths.Outbound = conf.Paths.Return
+	case "inbound":
+		agentCfg.Paths.Outbound = conf.Paths.Inbound
+	default:
+		return "", fmt.Errorf("unknown -dir %q", dir)
+	}
+	if agentCfg.Paths.Outbound == "" {
+		return "", fmt.Errorf("agent %s has no %s path", conf.ID, dir)
+	}
+	cfg.Agents = []service.UploadAgent{agentCfg}
+
+	agent, err := upload.New(logger, cfg, agentCfg.ID)
+	if err != nil {
+		return "", err
+	}
+	defer agent.Close()
+
+	err = agent.UploadFile(upload.File{
+		Filename: filename,
+		Contents: upload.NewContents(contents),
+	})
+	if err != nil {
+		return "", fmt.Errorf("uploading %s: %v", filename, err)
+	}
+	return fmt.Sprintf("%s:%s", agent.Hostname(), filepath.Join(agent.OutboundPath(), filepath.Base(filename))), nil
+}
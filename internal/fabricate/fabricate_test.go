@@ -0,0 +1,117 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fabricate
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	ppdDebit = filepath.Join("..", "..", "testdata", "ppd-debit.ach")
+	ppdJSON  = filepath.Join("..", "..", "testdata", "ppd-valid.json")
+)
+
+func loadConfig() (*service.Config, error) {
+	return &service.Config{
+		Upload: service.UploadAgents{
+			Agents: []service.UploadAgent{
+				{
+					ID:   "mock",
+					Mock: &service.MockAgent{},
+					Paths: service.UploadPaths{
+						Return: "returned/",
+					},
+				},
+				{ID: "no-paths", Mock: &service.MockAgent{}},
+			},
+		},
+	}, nil
+}
+
+func TestRun__Return(t *testing.T) {
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	args := []string{"return", "-file", ppdDebit, "-code", "r02", "-out", dir, "-filename", "returned.ach"}
+	err := Run(args, &buf, log.NewNopLogger(), loadConfig)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "Wrote return file with 1 entries to")
+
+	file, err := ach.ReadFile(filepath.Join(dir, "returned.ach"))
+	require.NoError(t, err)
+	require.Equal(t, "R02", file.Batches[0].GetEntries()[0].Addenda99.ReturnCode)
+}
+
+func TestRun__Correct(t *testing.T) {
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	err := Run([]string{"correct", "-file", ppdJSON, "-code", "C02", "-out", dir}, &buf, log.NewNopLogger(), loadConfig)
+	require.NoError(t, err)
+
+	matches, err := filepath.Glob(filepath.Join(dir, "correction-*.ach"))
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+
+	file, err := ach.ReadFile(matches[0])
+	require.NoError(t, err)
+	require.Equal(t, ach.COR, file.Batches[0].GetHeader().StandardEntryClassCode)
+	require.Equal(t, "C02", file.Batches[0].GetEntries()[0].Addenda98.ChangeCode)
+}
+
+func TestRun__Agent(t *testing.T) {
+	var buf bytes.Buffer
+	err := Run([]string{"return", "-file", ppdDebit, "-agent", "mock"}, &buf, log.NewNopLogger(), loadConfig)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "to hostname:")
+
+	err = Run([]string{"return", "-file", ppdDebit, "-agent", "mock", "-dir", "other"}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, `unknown -dir "other"`)
+
+	err = Run([]string{"return", "-file", ppdDebit, "-agent", "no-paths"}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, "agent no-paths has no return path")
+
+	err = Run([]string{"return", "-file", ppdDebit, "-agent", "missing"}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, "unknown Agent ID=missing")
+}
+
+func TestRun__Errors(t *testing.T) {
+	var buf bytes.Buffer
+	err := Run(nil, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, "missing command")
+
+	err = Run([]string{"other"}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, `unknown command "other"`)
+
+	err = Run([]string{"return"}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, "missing -file")
+
+	err = Run([]string{"return", "-file", ppdDebit, "-code", "X01", "-out", t.TempDir()}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, "unknown return code X01")
+
+	err = Run([]string{"correct", "-file", ppdDebit, "-trace", "1,2", "-out", t.TempDir()}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, "no matching entries found")
+}
@@ -0,0 +1,221 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package fips restricts achgateway's TLS, SSH and hashing to FIPS-approved algorithms.
+//
+// The mode is process-wide and set once at startup from the FIPS config. Builds made with
+// GOEXPERIMENT=boringcrypto additionally use the BoringCrypto validated module.
+package fips
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/sshx"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var enabled atomic.Bool
+
+// Setup enables the FIPS mode when configured and verifies the config only uses approved algorithms.
+func Setup(cfg *service.Config) error {
+	if cfg == nil || cfg.FIPS == nil || !cfg.FIPS.Enabled {
+		enabled.Store(false)
+		return nil
+	}
+	if cfg.FIPS.RequireValidatedModule && !ValidatedModule() {
+		return errors.New("fips: achgateway was not built with a validated crypto module (GOEXPERIMENT=boringcrypto)")
+	}
+	enabled.Store(true)
+
+	return Verify(cfg)
+}
+
+// Enabled returns true when achgateway is restricted to FIPS-approved algorithms.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// ValidatedModule returns true when achgateway was built against BoringCrypto.
+func ValidatedModule() bool {
+	return boringCrypto
+}
+
+var (
+	// TLS 1.3 suites can't be restricted in Go's crypto/tls, so FIPS mode is limited to TLS 1.2.
+	tlsCipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	}
+	tlsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+	sshCiphers = []string{
+		"aes128-gcm@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+	}
+	sshKeyExchanges = []string{
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group-exchange-sha256",
+		"diffie-hellman-group14-sha256",
+	}
+	sshMACs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-256",
+	}
+	sshHostKeyAlgorithms = []string{
+		ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+		ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256,
+	}
+)
+
+// TLSConfig limits cfg to FIPS-approved versions, cipher suites and curves when FIPS mode is enabled.
+func TLSConfig(cfg *tls.Config) *tls.Config {
+	if cfg == nil || !Enabled() {
+		return cfg
+	}
+	cfg.MinVersion = tls.VersionTLS12
+	cfg.MaxVersion = tls.VersionTLS12
+	cfg.CipherSuites = tlsCipherSuites
+	cfg.CurvePreferences = tlsCurves
+	return cfg
+}
+
+// SSHConfig limits cfg to FIPS-approved ciphers, key exchanges, MACs and host key algorithms when FIPS mode is enabled.
+func SSHConfig(cfg *ssh.ClientConfig) *ssh.ClientConfig {
+	if cfg == nil || !Enabled() {
+		return cfg
+	}
+	cfg.Ciphers = sshCiphers
+	cfg.KeyExchanges = sshKeyExchanges
+	cfg.MACs = sshMACs
+	cfg.HostKeyAlgorithms = sshHostKeyAlgorithms
+	return cfg
+}
+
+// checkAlgorithms returns an error for configured SSH algorithms which aren't FIPS-approved
+func checkAlgorithms(cfg *service.SFTP) error {
+	if err := sshx.CheckAlgorithms("cipher", cfg.Ciphers, sshCiphers); err != nil {
+		return err
+	}
+	if err := sshx.CheckAlgorithms("key exchange", cfg.KeyExchanges, sshKeyExchanges); err != nil {
+		return err
+	}
+	if err := sshx.CheckAlgorithms("MAC", cfg.MACs, sshMACs); err != nil {
+		return err
+	}
+	return sshx.CheckAlgorithms("host key algorithm", cfg.HostKeyAlgorithms, sshHostKeyAlgorithms)
+}
+
+// CheckPublicKey returns an error if the SSH key type isn't FIPS-approved.
+func CheckPublicKey(key ssh.PublicKey) error {
+	if key == nil || !Enabled() {
+		return nil
+	}
+	switch key.Type() {
+	case ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521:
+		return nil
+	case ssh.KeyAlgoRSA:
+		if ck, ok := key.(ssh.CryptoPublicKey); ok {
+			if rsaKey, ok := ck.CryptoPublicKey().(interface{ Size() int }); ok && rsaKey.Size()*8 < 2048 {
+				return fmt.Errorf("%d bit RSA keys are not allowed in FIPS mode", rsaKey.Size()*8)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%s keys are not allowed in FIPS mode", key.Type())
+}
+
+// Hash returns a hex encoded digest of data. SHA-256 is used in FIPS mode, otherwise SHA-1.
+func Hash(data []byte) string {
+	if Enabled() {
+		return fmt.Sprintf("%x", sha256.Sum256(data))
+	}
+	return fmt.Sprintf("%x", sha1.Sum(data)) //nolint:gosec
+}
+
+// Wrap explains handshake errors caused by an endpoint requiring algorithms which are not FIPS-approved.
+func Wrap(endpoint string, err error) error {
+	if err == nil || !Enabled() {
+		return err
+	}
+	msg := err.Error()
+	negotiation := []string{
+		"no common algorithm",            // ssh
+		"handshake failure",              // tls alert
+		"protocol version not supported", // tls 1.3 only servers
+		"no cipher suite supported",
+		"unsupported elliptic curve",
+	}
+	for i := range negotiation {
+		if strings.Contains(msg, negotiation[i]) {
+			return fmt.Errorf("%s requires an algorithm which is not allowed in FIPS mode: %w", endpoint, err)
+		}
+	}
+	return err
+}
+
+// Verify returns an error for configured keys which are not allowed in FIPS mode.
+func Verify(cfg *service.Config) error {
+	if cfg == nil || !Enabled() {
+		return nil
+	}
+	for i := range cfg.Upload.Agents {
+		agent := cfg.Upload.Agents[i]
+		if agent.SFTP == nil {
+			continue
+		}
+		if agent.SFTP.HostPublicKey != "" {
+			key, err := sshx.ReadPubKey([]byte(agent.SFTP.HostPublicKey))
+			if err != nil {
+				return fmt.Errorf("fips: upload agent %s: reading HostPublicKey: %v", agent.ID, err)
+			}
+			if err := CheckPublicKey(key); err != nil {
+				return fmt.Errorf("fips: upload agent %s: HostPublicKey: %v", agent.ID, err)
+			}
+		}
+		for j, raw := range agent.SFTP.HostPublicKeys {
+			key, err := sshx.ReadPubKey([]byte(raw))
+			if err != nil {
+				return fmt.Errorf("fips: upload agent %s: reading HostPublicKeys[%d]: %v", agent.ID, j, err)
+			}
+			if err := CheckPublicKey(key); err != nil {
+				return fmt.Errorf("fips: upload agent %s: HostPublicKeys[%d]: %v", agent.ID, j, err)
+			}
+		}
+		if err := checkAlgorithms(agent.SFTP); err != nil {
+			return fmt.Errorf("fips: upload agent %s: %v in FIPS mode", agent.ID, err)
+		}
+		if agent.SFTP.ClientPrivateKey != "" {
+			signer, err := sshx.ReadSigner(agent.SFTP.ClientPrivateKey)
+			if err != nil {
+				return fmt.Errorf("fips: upload agent %s: reading ClientPrivateKey: %v", agent.ID, err)
+			}
+			if err := CheckPublicKey(signer.PublicKey()); err != nil {
+				return fmt.Errorf("fips: upload agent %s: ClientPrivateKey: %v", agent.ID, err)
+			}
+		}
+	}
+	return nil
+}
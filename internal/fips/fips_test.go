@@ -0,0 +1,154 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fips
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/sshx"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func enableFIPS(t *testing.T) {
+	t.Helper()
+
+	enabled.Store(true)
+	t.Cleanup(func() { enabled.Store(false) })
+}
+
+func TestFIPS__Disabled(t *testing.T) {
+	require.NoError(t, Setup(&service.Config{}))
+	require.False(t, Enabled())
+
+	cfg := TLSConfig(&tls.Config{MinVersion: tls.VersionTLS12})
+	require.Empty(t, cfg.CipherSuites)
+	require.Equal(t, uint16(0), cfg.MaxVersion)
+
+	sshConf := SSHConfig(&ssh.ClientConfig{})
+	require.Empty(t, sshConf.Ciphers)
+
+	require.Len(t, Hash([]byte("hello")), 40)
+
+	err := errors.New("ssh: no common algorithm for key exchange")
+	require.Equal(t, err, Wrap("sftp.bank.com:22", err))
+}
+
+func TestFIPS__RequireValidatedModule(t *testing.T) {
+	t.Cleanup(func() { enabled.Store(false) })
+
+	err := Setup(&service.Config{
+		FIPS: &service.FIPS{
+			Enabled:                true,
+			RequireValidatedModule: true,
+		},
+	})
+	if ValidatedModule() {
+		require.NoError(t, err)
+	} else {
+		require.ErrorContains(t, err, "not built with a validated crypto module")
+	}
+}
+
+func TestFIPS__Restrictions(t *testing.T) {
+	enableFIPS(t)
+
+	cfg := TLSConfig(&tls.Config{})
+	require.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	require.Equal(t, uint16(tls.VersionTLS12), cfg.MaxVersion)
+	require.NotContains(t, cfg.CipherSuites, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305)
+
+	sshConf := SSHConfig(&ssh.ClientConfig{})
+	require.NotContains(t, sshConf.Ciphers, "chacha20-poly1305@openssh.com")
+	require.NotContains(t, sshConf.KeyExchanges, "curve25519-sha256")
+	require.NotContains(t, sshConf.HostKeyAlgorithms, ssh.KeyAlgoED25519)
+
+	require.Len(t, Hash([]byte("hello")), 64)
+
+	err := Wrap("sftp.bank.com:22", errors.New("ssh: no common algorithm for key exchange"))
+	require.ErrorContains(t, err, "sftp.bank.com:22 requires an algorithm which is not allowed in FIPS mode")
+
+	err = errors.New("connection refused")
+	require.Equal(t, err, Wrap("sftp.bank.com:22", err))
+}
+
+func TestFIPS__CheckPublicKey(t *testing.T) {
+	enableFIPS(t)
+
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edKey, err := ssh.NewPublicKey(edPub)
+	require.NoError(t, err)
+	require.ErrorContains(t, CheckPublicKey(edKey), "ssh-ed25519 keys are not allowed in FIPS mode")
+
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecKey, err := ssh.NewPublicKey(&ecPriv.PublicKey)
+	require.NoError(t, err)
+	require.NoError(t, CheckPublicKey(ecKey))
+
+	bs, err := os.ReadFile(filepath.Join("..", "sshx", "testdata", "rsa-2048.pub"))
+	require.NoError(t, err)
+	rsaKey, err := sshx.ReadPubKey(bs)
+	require.NoError(t, err)
+	require.NoError(t, CheckPublicKey(rsaKey))
+}
+
+func TestFIPS__Verify(t *testing.T) {
+	enableFIPS(t)
+
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edKey, err := ssh.NewPublicKey(edPub)
+	require.NoError(t, err)
+
+	cfg := &service.Config{
+		Upload: service.UploadAgents{
+			Agents: []service.UploadAgent{
+				{
+					ID: "bank",
+					SFTP: &service.SFTP{
+						HostPublicKey: string(ssh.MarshalAuthorizedKey(edKey)),
+					},
+				},
+			},
+		},
+	}
+	require.ErrorContains(t, Verify(cfg), "upload agent bank: HostPublicKey: ssh-ed25519 keys are not allowed")
+
+	cfg.Upload.Agents[0].SFTP = &service.SFTP{
+		HostPublicKeys: []string{string(ssh.MarshalAuthorizedKey(edKey))},
+	}
+	require.ErrorContains(t, Verify(cfg), "upload agent bank: HostPublicKeys[0]: ssh-ed25519 keys are not allowed")
+
+	cfg.Upload.Agents[0].SFTP = &service.SFTP{Ciphers: []string{"aes256-ctr"}}
+	require.NoError(t, Verify(cfg))
+
+	cfg.Upload.Agents[0].SFTP.KeyExchanges = []string{"curve25519-sha256"}
+	require.ErrorContains(t, Verify(cfg), `upload agent bank: unsupported key exchange "curve25519-sha256" in FIPS mode`)
+}
@@ -0,0 +1,512 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package guardrails
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/base/log"
+)
+
+// Checker compares merged files against a shard's recent upload history.
+type Checker struct {
+	logger    log.Logger
+	shardName string
+	cfg       service.Guardrails
+	storage   storage.Chest
+
+	now func() time.Time
+}
+
+// New returns a Checker for the shard. A nil Checker is returned when guardrails are not configured.
+func New(logger log.Logger, shardName string, cfg *service.Guardrails, chest storage.Chest) (*Checker, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if chest == nil {
+		return nil, errors.New("guardrails: nil storage")
+	}
+	return &Checker{
+		logger:    logger,
+		shardName: shardName,
+		cfg:       *cfg,
+		storage:   chest,
+		now:       time.Now,
+	}, nil
+}
+
+// Result holds the outcome of checking a file against the guardrails.
+type Result struct {
+	// Held are reasons the file must not be uploaded until approved.
+	Held []string
+
+	// Flagged are reasons to notify operators while still uploading the file.
+	Flagged []string
+}
+
+// Check returns the reasons a file should be held or flagged. An empty Result means the file can be uploaded.
+func (c *Checker) Check(file *ach.File) (Result, error) {
+	var result Result
+	if c == nil || file == nil {
+		return result, nil
+	}
+
+	if c.cfg.MaxEntryAmount > 0 {
+		for i := range file.Batches {
+			entries := file.Batches[i].GetEntries()
+			for j := range entries {
+				if entries[j].Amount > c.cfg.MaxEntryAmount {
+					result.Held = append(result.Held, fmt.Sprintf("entry trace number %s has amount %d above cap of %d",
+						entries[j].TraceNumber, entries[j].Amount, c.cfg.MaxEntryAmount))
+				}
+			}
+		}
+	}
+
+	if c.cfg.MaxDebitMultiplier <= 0 && c.cfg.Velocity == nil {
+		return result, nil
+	}
+	hist, err := c.readHistory()
+	if err != nil {
+		return result, err
+	}
+
+	if c.cfg.MaxDebitMultiplier > 0 {
+		days := hist.previousDays(c.now(), c.cfg.History())
+		if len(days) >= c.cfg.MinimumHistory() {
+			median := medianDebits(days)
+			limit := float64(median) * c.cfg.MaxDebitMultiplier
+			if debits := file.Control.TotalDebitEntryDollarAmountInFile; median > 0 && float64(debits) > limit {
+				result.Held = append(result.Held, fmt.Sprintf("total debits of %d exceed %.1fx the median daily debits of %d",
+					debits, c.cfg.MaxDebitMultiplier, median))
+			}
+		}
+	}
+
+	if c.cfg.Velocity != nil {
+		velocityMu.Lock()
+		today, err := c.readVelocity()
+		velocityMu.Unlock()
+		if err != nil {
+			return result, err
+		}
+		reasons := c.checkVelocity(today, file)
+		if c.cfg.Velocity.Rejects() {
+			result.Held = append(result.Held, reasons...)
+		} else {
+			result.Flagged = append(result.Flagged, reasons...)
+		}
+	}
+
+	return result, nil
+}
+
+// checkVelocity adds the file's entries to what each receiving account has already
+// been sent today and returns a reason for every account over a limit.
+func (c *Checker) checkVelocity(today velocityTotals, file *ach.File) []string {
+	totals := make(map[string]accountTotals)
+	var order []string
+	for i := range file.Batches {
+		entries := file.Batches[i].GetEntries()
+		for j := range entries {
+			key := accountKey(entries[j])
+			if _, exists := totals[key]; !exists {
+				totals[key] = today.Accounts[key]
+				order = append(order, key)
+			}
+			acct := totals[key]
+			acct.Entries += 1
+			acct.Amount += entries[j].Amount
+			totals[key] = acct
+		}
+	}
+
+	var reasons []string
+	cfg := c.cfg.Velocity
+	for _, key := range order {
+		acct := totals[key]
+		if cfg.MaxEntries > 0 && acct.Entries > cfg.MaxEntries {
+			reasons = append(reasons, fmt.Sprintf("account %s has %d entries today above limit of %d",
+				key[:12], acct.Entries, cfg.MaxEntries))
+		}
+		if cfg.MaxAmount > 0 && acct.Amount > cfg.MaxAmount {
+			reasons = append(reasons, fmt.Sprintf("account %s has received %d today above limit of %d",
+				key[:12], acct.Amount, cfg.MaxAmount))
+		}
+	}
+	return reasons
+}
+
+// accountKey hashes the receiving account so account numbers are not kept in history.
+func accountKey(entry *ach.EntryDetail) string {
+	routing := entry.RDFIIdentification + entry.CheckDigit
+	account := strings.TrimSpace(entry.DFIAccountNumber)
+
+	sum := sha256.Sum256([]byte(routing + ":" + account))
+	return hex.EncodeToString(sum[:])
+}
+
+// Record adds an uploaded file's totals to the shard's history and its entries to what
+// each receiving account was sent today.
+func (c *Checker) Record(file *ach.File) error {
+	if c == nil || file == nil {
+		return nil
+	}
+	hist, err := c.readHistory()
+	if err != nil {
+		return err
+	}
+	day := c.now().Format("2006-01-02")
+	totals := hist.Days[day]
+	totals.Debits += file.Control.TotalDebitEntryDollarAmountInFile
+	totals.Credits += file.Control.TotalCreditEntryDollarAmountInFile
+	hist.Days[day] = totals
+
+	// Trim days which are no longer considered
+	cutoff := c.now().AddDate(0, 0, -1*c.cfg.History()).Format("2006-01-02")
+	for d := range hist.Days {
+		if d < cutoff {
+			delete(hist.Days, d)
+		}
+	}
+	if err := c.writeHistory(hist); err != nil {
+		return err
+	}
+	return c.recordVelocity(file)
+}
+
+// recordVelocity adds the file's entries to the totals shared by every shard, so an account's
+// limits can't be avoided by spreading files across shards.
+func (c *Checker) recordVelocity(file *ach.File) error {
+	velocityMu.Lock()
+	defer velocityMu.Unlock()
+
+	today, err := c.readVelocity()
+	if err != nil {
+		return err
+	}
+	for i := range file.Batches {
+		entries := file.Batches[i].GetEntries()
+		for j := range entries {
+			key := accountKey(entries[j])
+			acct := today.Accounts[key]
+			acct.Entries += 1
+			acct.Amount += entries[j].Amount
+			today.Accounts[key] = acct
+		}
+	}
+	return c.writeVelocity(today)
+}
+
+type history struct {
+	Days map[string]dailyTotals `json:"days"`
+}
+
+type dailyTotals struct {
+	Debits  int `json:"debits"`
+	Credits int `json:"credits"`
+}
+
+// velocityPath is outside every shard's directory as shards share the merging storage.
+const velocityPath = "guardrails/velocity.json"
+
+// velocityMu serializes each shard's Checker reading and updating velocityPath.
+var velocityMu sync.Mutex
+
+// velocityTotals are what each receiving account was sent on Day
+type velocityTotals struct {
+	Day string `json:"day"`
+
+	// Accounts are keyed by a hash of the receiving routing and account number
+	Accounts map[string]accountTotals `json:"accounts"`
+}
+
+type accountTotals struct {
+	Entries int `json:"entries"`
+	Amount  int `json:"amount"`
+}
+
+func (h history) previousDays(now time.Time, count int) []dailyTotals {
+	today := now.Format("2006-01-02")
+	earliest := now.AddDate(0, 0, -1*count).Format("2006-01-02")
+
+	var out []dailyTotals
+	for day, totals := range h.Days {
+		if day < today && day >= earliest {
+			out = append(out, totals)
+		}
+	}
+	return out
+}
+
+func medianDebits(days []dailyTotals) int {
+	if len(days) == 0 {
+		return 0
+	}
+	amounts := make([]int, len(days))
+	for i := range days {
+		amounts[i] = days[i].Debits
+	}
+	sort.Ints(amounts)
+
+	mid := len(amounts) / 2
+	if len(amounts)%2 == 0 {
+		return (amounts[mid-1] + amounts[mid]) / 2
+	}
+	return amounts[mid]
+}
+
+func (c *Checker) historyPath() string {
+	return filepath.Join("guardrails", c.shardName, "history.json")
+}
+
+func (c *Checker) readHistory() (history, error) {
+	hist := history{Days: make(map[string]dailyTotals)}
+
+	file, err := c.storage.Open(c.historyPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return hist, nil
+		}
+		return hist, fmt.Errorf("guardrails: opening history: %w", err)
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(&hist); err != nil && err != io.EOF {
+		return hist, fmt.Errorf("guardrails: reading history: %w", err)
+	}
+	if hist.Days == nil {
+		hist.Days = make(map[string]dailyTotals)
+	}
+	return hist, nil
+}
+
+func (c *Checker) writeHistory(hist history) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(hist); err != nil {
+		return fmt.Errorf("guardrails: encoding history: %w", err)
+	}
+	return c.storage.WriteFile(c.historyPath(), buf.Bytes())
+}
+
+// readVelocity returns today's account totals, which start over each day. The caller must hold velocityMu.
+func (c *Checker) readVelocity() (velocityTotals, error) {
+	today := velocityTotals{
+		Day:      c.now().Format("2006-01-02"),
+		Accounts: make(map[string]accountTotals),
+	}
+
+	file, err := c.storage.Open(velocityPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return today, nil
+		}
+		return today, fmt.Errorf("guardrails: opening velocity: %w", err)
+	}
+	defer file.Close()
+
+	var saved velocityTotals
+	if err := json.NewDecoder(file).Decode(&saved); err != nil && err != io.EOF {
+		return today, fmt.Errorf("guardrails: reading velocity: %w", err)
+	}
+	if saved.Day != today.Day || saved.Accounts == nil {
+		return today, nil
+	}
+	return saved, nil
+}
+
+// writeVelocity saves the account totals. The caller must hold velocityMu.
+func (c *Checker) writeVelocity(totals velocityTotals) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(totals); err != nil {
+		return fmt.Errorf("guardrails: encoding velocity: %w", err)
+	}
+	return c.storage.WriteFile(velocityPath, buf.Bytes())
+}
+
+// ReencryptVelocity rewrites the account totals shared by every shard with chest's current
+// encryption key, returning how many files were rewritten.
+func ReencryptVelocity(chest storage.Chest) (int, error) {
+	velocityMu.Lock()
+	defer velocityMu.Unlock()
+
+	return storage.Reencrypt(chest, velocityPath)
+}
+
+// HeldFile is a merged file which failed a guardrail check and awaits approval.
+type HeldFile struct {
+	Path    string
+	File    *ach.File
+	Reasons []string
+}
+
+// Name is the held file's name, which is unique within the shard
+func (h HeldFile) Name() string {
+	return filepath.Base(h.Path)
+}
+
+type discardedFile struct {
+	Reason      string    `json:"reason"`
+	DiscardedAt time.Time `json:"discardedAt"`
+}
+
+func (c *Checker) heldDir() string {
+	return filepath.Join("held", c.shardName)
+}
+
+// Hold saves the file until an operator approves it for upload.
+func (c *Checker) Hold(file *ach.File, reasons []string) (string, error) {
+	var buf bytes.Buffer
+	if err := ach.NewWriter(&buf).Write(file); err != nil {
+		return "", fmt.Errorf("guardrails: writing held file: %w", err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	path := filepath.Join(c.heldDir(), fmt.Sprintf("%s-%s.ach", c.now().Format("20060102-150405"), hex.EncodeToString(sum[:8])))
+	if err := c.storage.WriteFile(path, buf.Bytes()); err != nil {
+		return "", fmt.Errorf("guardrails: saving held file: %w", err)
+	}
+
+	// Keep why the file was held for operators reviewing it
+	buf.Reset()
+	if err := json.NewEncoder(&buf).Encode(reasons); err != nil {
+		return "", fmt.Errorf("guardrails: encoding reasons: %w", err)
+	}
+	if err := c.storage.WriteFile(strings.TrimSuffix(path, ".ach")+".reasons.json", buf.Bytes()); err != nil {
+		return "", fmt.Errorf("guardrails: saving reasons: %w", err)
+	}
+
+	// Keep the ValidateOpts so the file can be read again
+	if opts := file.GetValidation(); opts != nil {
+		buf.Reset()
+		if err := json.NewEncoder(&buf).Encode(opts); err != nil {
+			return "", fmt.Errorf("guardrails: encoding ValidateOpts: %w", err)
+		}
+		if err := c.storage.WriteFile(strings.TrimSuffix(path, ".ach")+".json", buf.Bytes()); err != nil {
+			return "", fmt.Errorf("guardrails: saving ValidateOpts: %w", err)
+		}
+	}
+
+	c.logger.Warn().With(log.Fields{
+		"shard": log.String(c.shardName),
+		"path":  log.String(path),
+	}).Logf("held file for approval: %s", strings.Join(reasons, "; "))
+
+	return path, nil
+}
+
+// HeldFiles returns each file waiting for approval.
+func (c *Checker) HeldFiles() ([]HeldFile, error) {
+	if c == nil {
+		return nil, nil
+	}
+	matches, err := c.storage.Glob(filepath.Join(c.heldDir(), "*.ach"))
+	if err != nil {
+		return nil, err
+	}
+	var out []HeldFile
+	for i := range matches {
+		file, err := c.readHeldFile(matches[i].RelativePath)
+		if err != nil {
+			return nil, fmt.Errorf("guardrails: reading %s: %w", matches[i].RelativePath, err)
+		}
+		out = append(out, HeldFile{
+			Path:    matches[i].RelativePath,
+			File:    file,
+			Reasons: c.readReasons(matches[i].RelativePath),
+		})
+	}
+	return out, nil
+}
+
+// readReasons returns why a file was held, which files held by older versions don't have
+func (c *Checker) readReasons(path string) []string {
+	fd, _ := c.storage.Open(strings.TrimSuffix(path, ".ach") + ".reasons.json")
+	if fd == nil {
+		return nil
+	}
+	defer fd.Close()
+
+	var reasons []string
+	if err := json.NewDecoder(fd).Decode(&reasons); err != nil {
+		c.logger.Warn().Logf("guardrails: problem reading reasons of %s: %v", path, err)
+	}
+	return reasons
+}
+
+func (c *Checker) readHeldFile(path string) (*ach.File, error) {
+	fd, err := c.storage.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	r := ach.NewReader(fd)
+	if optsFile, _ := c.storage.Open(strings.TrimSuffix(path, ".ach") + ".json"); optsFile != nil {
+		defer optsFile.Close()
+
+		var opts ach.ValidateOpts
+		if err := json.NewDecoder(optsFile).Decode(&opts); err == nil {
+			r.SetValidation(&opts)
+		}
+	}
+
+	file, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// Release removes a held file after it's been approved and uploaded.
+func (c *Checker) Release(held HeldFile) error {
+	return c.storage.ReplaceFile(held.Path, held.Path+".released")
+}
+
+// Discard removes a held file which won't be uploaded, keeping the operator's reason next to it.
+func (c *Checker) Discard(held HeldFile, reason string) error {
+	bs, err := json.Marshal(discardedFile{Reason: reason, DiscardedAt: c.now()})
+	if err != nil {
+		return fmt.Errorf("guardrails: encoding discard reason: %w", err)
+	}
+	if err := c.storage.WriteFile(strings.TrimSuffix(held.Path, ".ach")+".discarded.json", bs); err != nil {
+		return fmt.Errorf("guardrails: saving discard reason: %w", err)
+	}
+	if err := c.storage.ReplaceFile(held.Path, held.Path+".discarded"); err != nil {
+		return fmt.Errorf("guardrails: discarding %s: %w", held.Path, err)
+	}
+	c.logger.Warn().With(log.Fields{
+		"shard": log.String(c.shardName),
+		"path":  log.String(held.Path),
+	}).Logf("discarded held file: %s", reason)
+	return nil
+}
@@ -0,0 +1,219 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package guardrails
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func setupChecker(t *testing.T, cfg *service.Guardrails) *Checker {
+	t.Helper()
+
+	chest, err := storage.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+
+	checker, err := New(log.NewNopLogger(), "testing", cfg, chest)
+	require.NoError(t, err)
+	return checker
+}
+
+func readFile(t *testing.T) *ach.File {
+	t.Helper()
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	return file
+}
+
+func TestGuardrails__Nil(t *testing.T) {
+	checker, err := New(log.NewNopLogger(), "testing", nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, checker)
+
+	result, err := checker.Check(readFile(t))
+	require.NoError(t, err)
+	require.Empty(t, result.Held)
+	require.Empty(t, result.Flagged)
+	require.NoError(t, checker.Record(readFile(t)))
+}
+
+func TestGuardrails__MaxEntryAmount(t *testing.T) {
+	checker := setupChecker(t, &service.Guardrails{
+		MaxEntryAmount: 10000,
+	})
+
+	result, err := checker.Check(readFile(t))
+	require.NoError(t, err)
+	require.Len(t, result.Held, 1)
+	require.Contains(t, result.Held[0], "has amount 10500 above cap of 10000")
+}
+
+func TestGuardrails__MaxDebitMultiplier(t *testing.T) {
+	checker := setupChecker(t, &service.Guardrails{
+		MaxDebitMultiplier: 3.0,
+		MinimumHistoryDays: 2,
+	})
+	file := readFile(t)
+
+	// Build up history of small files
+	small := readFile(t)
+	small.Control.TotalDebitEntryDollarAmountInFile = 1000
+
+	start := time.Date(2022, time.August, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		checker.now = func() time.Time { return start.AddDate(0, 0, i) }
+
+		// Not enough history on the first day
+		result, err := checker.Check(file)
+		require.NoError(t, err)
+		if i < 2 {
+			require.Empty(t, result.Held)
+		} else {
+			require.Len(t, result.Held, 1)
+			require.Contains(t, result.Held[0], "total debits of 10500 exceed 3.0x the median daily debits of 1000")
+		}
+
+		require.NoError(t, checker.Record(small))
+	}
+}
+
+func TestGuardrails__Velocity(t *testing.T) {
+	cfg := &service.Guardrails{
+		Velocity: &service.Velocity{
+			MaxEntries: 1,
+		},
+	}
+	checker := setupChecker(t, cfg)
+	file := readFile(t)
+
+	result, err := checker.Check(file)
+	require.NoError(t, err)
+	require.Empty(t, result.Flagged)
+	require.NoError(t, checker.Record(file))
+
+	// The same deposit again on the same day is flagged
+	result, err = checker.Check(file)
+	require.NoError(t, err)
+	require.Empty(t, result.Held)
+	require.Len(t, result.Flagged, 1)
+	require.Contains(t, result.Flagged[0], "has 2 entries today above limit of 1")
+
+	// Rejecting holds the file instead
+	cfg.Velocity.Action = service.VelocityActionReject
+	checker.cfg = *cfg
+	result, err = checker.Check(file)
+	require.NoError(t, err)
+	require.Len(t, result.Held, 1)
+	require.Empty(t, result.Flagged)
+
+	// Limits reset the next day
+	checker.now = func() time.Time { return time.Now().AddDate(0, 0, 1) }
+	result, err = checker.Check(file)
+	require.NoError(t, err)
+	require.Empty(t, result.Held)
+}
+
+func TestGuardrails__VelocityAcrossShards(t *testing.T) {
+	chest, err := storage.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+
+	cfg := &service.Guardrails{
+		Velocity: &service.Velocity{
+			MaxAmount: 15000,
+			Action:    service.VelocityActionReject,
+		},
+	}
+	first, err := New(log.NewNopLogger(), "first", cfg, chest)
+	require.NoError(t, err)
+	second, err := New(log.NewNopLogger(), "second", cfg, chest)
+	require.NoError(t, err)
+
+	// Each shard's file is under the limit on its own
+	file := readFile(t)
+	result, err := first.Check(file)
+	require.NoError(t, err)
+	require.Empty(t, result.Held)
+	require.NoError(t, first.Record(file))
+
+	// Sending the same account another file from a different shard goes over
+	result, err = second.Check(file)
+	require.NoError(t, err)
+	require.Len(t, result.Held, 1)
+	require.Contains(t, result.Held[0], "has received 21000 today above limit of 15000")
+}
+
+func TestGuardrails__Hold(t *testing.T) {
+	checker := setupChecker(t, &service.Guardrails{
+		MaxEntryAmount: 100,
+	})
+	file := readFile(t)
+
+	path, err := checker.Hold(file, []string{"too large"})
+	require.NoError(t, err)
+	require.Contains(t, path, filepath.Join("held", "testing"))
+
+	held, err := checker.HeldFiles()
+	require.NoError(t, err)
+	require.Len(t, held, 1)
+	require.Equal(t, path, held[0].Path)
+	require.Equal(t, filepath.Base(path), held[0].Name())
+	require.Equal(t, []string{"too large"}, held[0].Reasons)
+	require.Equal(t, file.Control.TotalDebitEntryDollarAmountInFile, held[0].File.Control.TotalDebitEntryDollarAmountInFile)
+
+	require.NoError(t, checker.Release(held[0]))
+
+	held, err = checker.HeldFiles()
+	require.NoError(t, err)
+	require.Empty(t, held)
+}
+
+func TestGuardrails__Discard(t *testing.T) {
+	checker := setupChecker(t, &service.Guardrails{
+		MaxEntryAmount: 100,
+	})
+	path, err := checker.Hold(readFile(t), []string{"too large"})
+	require.NoError(t, err)
+
+	held, err := checker.HeldFiles()
+	require.NoError(t, err)
+	require.Len(t, held, 1)
+	require.NoError(t, checker.Discard(held[0], "duplicate of yesterday's payroll"))
+
+	held, err = checker.HeldFiles()
+	require.NoError(t, err)
+	require.Empty(t, held)
+
+	fd, err := checker.storage.Open(strings.TrimSuffix(path, ".ach") + ".discarded.json")
+	require.NoError(t, err)
+	defer fd.Close()
+
+	var discarded discardedFile
+	require.NoError(t, json.NewDecoder(fd).Decode(&discarded))
+	require.Equal(t, "duplicate of yesterday's payroll", discarded.Reason)
+}
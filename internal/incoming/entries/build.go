@@ -0,0 +1,260 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package entries
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base"
+)
+
+// Build creates an ACH file from sub using the shard's FileDefaults for the file and batch headers.
+// Entries are grouped into one batch per SEC code, in the order each code first appears.
+func Build(defaults *service.FileDefaults, sub *Submission, now time.Time) (*ach.File, error) {
+	if defaults == nil {
+		return nil, errors.New("nil FileDefaults")
+	}
+	if sub == nil || len(sub.Entries) == 0 {
+		return nil, errors.New("no entries")
+	}
+
+	odfi := strings.TrimSpace(defaults.ODFIIdentification)
+	if odfi == "" {
+		odfi = strings.TrimSpace(defaults.ImmediateOrigin)
+	}
+	if len(odfi) < 8 {
+		return nil, fmt.Errorf("invalid ODFI routing number %q", odfi)
+	}
+	description := sub.CompanyEntryDescription
+	if description == "" {
+		description = defaults.CompanyEntryDescription
+	}
+	if description == "" {
+		description = "PAYMENT"
+	}
+	effective, err := effectiveDate(sub.EffectiveEntryDate, now)
+	if err != nil {
+		return nil, err
+	}
+
+	file := ach.NewFile()
+	file.Header = ach.NewFileHeader()
+	file.Header.ImmediateDestination = defaults.ImmediateDestination
+	file.Header.ImmediateDestinationName = defaults.ImmediateDestinationName
+	file.Header.ImmediateOrigin = defaults.ImmediateOrigin
+	file.Header.ImmediateOriginName = defaults.ImmediateOriginName
+	file.Header.FileCreationDate = now.Format("060102")
+	file.Header.FileCreationTime = now.Format("1504")
+
+	// Split entries by SEC code, keeping the order they were first seen
+	var codes []string
+	grouped := make(map[string][]Entry)
+	for _, entry := range sub.Entries {
+		code := entry.secCode()
+		if _, exists := grouped[code]; !exists {
+			codes = append(codes, code)
+		}
+		grouped[code] = append(grouped[code], entry)
+	}
+
+	seq := 0
+	for i, code := range codes {
+		bh := ach.NewBatchHeader()
+		bh.StandardEntryClassCode = code
+		bh.CompanyName = truncate(defaults.CompanyName, 16)
+		bh.CompanyIdentification = truncate(defaults.CompanyIdentification, 10)
+		bh.CompanyEntryDescription = truncate(description, 10)
+		bh.EffectiveEntryDate = effective
+		bh.ODFIIdentification = odfi[:8]
+		bh.BatchNumber = i + 1
+
+		var credits, debits bool
+		entries := make([]*ach.EntryDetail, 0, len(grouped[code]))
+		for _, e := range grouped[code] {
+			seq++
+			ed, err := buildEntry(e, odfi, seq)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: %v", seq, err)
+			}
+			if ed.CreditOrDebit() == "C" {
+				credits = true
+			} else {
+				debits = true
+			}
+			entries = append(entries, ed)
+		}
+		bh.ServiceClassCode = serviceClassCode(credits, debits)
+
+		batch, err := ach.NewBatch(bh)
+		if err != nil {
+			return nil, fmt.Errorf("%s batch: %v", code, err)
+		}
+		for _, ed := range entries {
+			batch.AddEntry(ed)
+		}
+		if err := batch.Create(); err != nil {
+			return nil, fmt.Errorf("creating %s batch: %v", code, err)
+		}
+		file.AddBatch(batch)
+	}
+	if err := file.Create(); err != nil {
+		return nil, fmt.Errorf("creating file: %v", err)
+	}
+	if err := file.Validate(); err != nil {
+		return nil, fmt.Errorf("validating file: %v", err)
+	}
+	return file, nil
+}
+
+func buildEntry(e Entry, odfi string, seq int) (*ach.EntryDetail, error) {
+	if e.Amount <= 0 || e.Amount > 9999999999 {
+		return nil, fmt.Errorf("invalid amount %d", e.Amount)
+	}
+	rdfi := strings.TrimSpace(e.RoutingNumber)
+	if len(rdfi) != 9 {
+		return nil, fmt.Errorf("invalid routing number %q", e.RoutingNumber)
+	}
+	if strings.TrimSpace(e.AccountNumber) == "" {
+		return nil, errors.New("missing account number")
+	}
+	code, err := transactionCode(e.AccountType, e.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := ach.NewEntryDetail()
+	entry.TransactionCode = code
+	entry.SetRDFI(rdfi)
+	entry.DFIAccountNumber = strings.TrimSpace(e.AccountNumber)
+	entry.Amount = e.Amount
+	entry.IdentificationNumber = truncate(e.Identification, 15)
+	entry.IndividualName = truncate(e.Name, 22)
+	entry.SetTraceNumber(odfi[:8], seq)
+
+	info, err := e.addenda()
+	if err != nil {
+		return nil, err
+	}
+	if info != "" {
+		addenda := ach.NewAddenda05()
+		addenda.PaymentRelatedInformation = truncate(info, 80)
+		addenda.SequenceNumber = 1
+		addenda.EntryDetailSequenceNumber = seq
+		entry.AddAddenda05(addenda)
+		entry.AddendaRecordIndicator = 1
+	}
+	return entry, nil
+}
+
+func (e Entry) secCode() string {
+	code := strings.ToUpper(strings.TrimSpace(e.SECCode))
+	switch {
+	case code != "":
+		return code
+	case e.ChildSupport != nil, e.TaxPayment != nil:
+		return ach.CCD
+	}
+	return ach.PPD
+}
+
+// addenda returns the entry's payment related information, checking child support and tax
+// payments against the entry's amount.
+func (e Entry) addenda() (string, error) {
+	info := strings.TrimSpace(e.Addenda)
+	if e.ChildSupport == nil && e.TaxPayment == nil {
+		return info, nil
+	}
+	if info != "" || (e.ChildSupport != nil && e.TaxPayment != nil) {
+		return "", errors.New("only one of addenda, childSupport and taxPayment can be set")
+	}
+	if e.ChildSupport != nil {
+		if err := e.ChildSupport.Validate(); err != nil {
+			return "", fmt.Errorf("childSupport: %v", err)
+		}
+		if e.ChildSupport.Amount != e.Amount {
+			return "", fmt.Errorf("childSupport: amount %d doesn't match entry amount %d", e.ChildSupport.Amount, e.Amount)
+		}
+		return e.ChildSupport.String(), nil
+	}
+	if err := e.TaxPayment.Validate(); err != nil {
+		return "", fmt.Errorf("taxPayment: %v", err)
+	}
+	if total := e.TaxPayment.Total(); total != e.Amount {
+		return "", fmt.Errorf("taxPayment: amounts total %d which doesn't match entry amount %d", total, e.Amount)
+	}
+	return e.TaxPayment.String(), nil
+}
+
+func transactionCode(accountType, kind string) (int, error) {
+	savings := false
+	switch strings.ToLower(strings.TrimSpace(accountType)) {
+	case "", "checking":
+	case "savings":
+		savings = true
+	default:
+		return 0, fmt.Errorf("unknown account type %q", accountType)
+	}
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "credit":
+		if savings {
+			return ach.SavingsCredit, nil
+		}
+		return ach.CheckingCredit, nil
+	case "debit":
+		if savings {
+			return ach.SavingsDebit, nil
+		}
+		return ach.CheckingDebit, nil
+	}
+	return 0, fmt.Errorf("unknown entry type %q", kind)
+}
+
+func serviceClassCode(credits, debits bool) int {
+	switch {
+	case credits && debits:
+		return ach.MixedDebitsAndCredits
+	case debits:
+		return ach.DebitsOnly
+	}
+	return ach.CreditsOnly
+}
+
+func effectiveDate(value string, now time.Time) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return base.NewTime(now).AddBankingDay(1).Format("060102"), nil
+	}
+	when, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return "", fmt.Errorf("invalid effectiveEntryDate %q", value)
+	}
+	return when.Format("060102"), nil
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
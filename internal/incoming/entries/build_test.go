@@ -0,0 +1,146 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package entries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/addenda"
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testDefaults = &service.FileDefaults{
+	ImmediateDestination:  "231380104",
+	ImmediateOrigin:       "076401251",
+	CompanyName:           "Moov",
+	CompanyIdentification: "121042882",
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2021, time.June, 14, 10, 30, 0, 0, time.UTC) // Monday
+	sub := &Submission{
+		Entries: []Entry{
+			{Name: "Jane Doe", RoutingNumber: "231380104", AccountNumber: "12345", Amount: 1250, Type: "credit", Addenda: "October pay"},
+			{Name: "Acme Corp", RoutingNumber: "231380104", AccountNumber: "98765", Amount: 99, Type: "debit", SECCode: "ccd"},
+			{Name: "John Doe", RoutingNumber: "231380104", AccountNumber: "54321", AccountType: "savings", Amount: 500, Type: "debit"},
+		},
+	}
+	file, err := Build(testDefaults, sub, now)
+	require.NoError(t, err)
+	require.Equal(t, "231380104", file.Header.ImmediateDestination)
+	require.Len(t, file.Batches, 2)
+
+	ppd := file.Batches[0]
+	require.Equal(t, ach.PPD, ppd.GetHeader().StandardEntryClassCode)
+	require.Equal(t, ach.MixedDebitsAndCredits, ppd.GetHeader().ServiceClassCode)
+	require.Equal(t, "PAYMENT", ppd.GetHeader().CompanyEntryDescription)
+	require.Equal(t, "210615", ppd.GetHeader().EffectiveEntryDate)
+	require.Equal(t, "07640125", ppd.GetHeader().ODFIIdentification)
+
+	ppdEntries := ppd.GetEntries()
+	require.Len(t, ppdEntries, 2)
+	require.Equal(t, ach.CheckingCredit, ppdEntries[0].TransactionCode)
+	require.Equal(t, "October pay", ppdEntries[0].Addenda05[0].PaymentRelatedInformation)
+	require.Equal(t, ach.SavingsDebit, ppdEntries[1].TransactionCode)
+
+	ccd := file.Batches[1]
+	require.Equal(t, ach.CCD, ccd.GetHeader().StandardEntryClassCode)
+	require.Equal(t, ach.DebitsOnly, ccd.GetHeader().ServiceClassCode)
+	require.Equal(t, ach.CheckingDebit, ccd.GetEntries()[0].TransactionCode)
+}
+
+func TestBuild__EffectiveEntryDate(t *testing.T) {
+	sub := &Submission{
+		CompanyEntryDescription: "PAYROLL",
+		EffectiveEntryDate:      "2021-06-18",
+		Entries: []Entry{
+			{Name: "Jane Doe", RoutingNumber: "231380104", AccountNumber: "12345", Amount: 1250, Type: "credit"},
+		},
+	}
+	file, err := Build(testDefaults, sub, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, "210618", file.Batches[0].GetHeader().EffectiveEntryDate)
+	require.Equal(t, "PAYROLL", file.Batches[0].GetHeader().CompanyEntryDescription)
+	require.Equal(t, ach.CreditsOnly, file.Batches[0].GetHeader().ServiceClassCode)
+
+	sub.EffectiveEntryDate = "06/18/2021"
+	_, err = Build(testDefaults, sub, time.Now())
+	require.ErrorContains(t, err, "invalid effectiveEntryDate")
+}
+
+func TestBuild__Addenda(t *testing.T) {
+	childSupport := &addenda.ChildSupport{CaseID: "A123456", PayDate: "221014", Amount: 25000, SSN: "123456789", Name: "SMITH"}
+	taxPayment := &addenda.TaxPayment{
+		TaxpayerID: "123456789", TaxType: "94105", PeriodEnd: "220930",
+		Amounts: []addenda.TaxAmount{{Type: "T", Amount: 10000}, {Type: "P", Amount: 500}},
+	}
+	sub := &Submission{
+		Entries: []Entry{
+			{Name: "State DCSS", RoutingNumber: "231380104", AccountNumber: "12345", Amount: 25000, Type: "credit", ChildSupport: childSupport},
+			{Name: "IRS", RoutingNumber: "231380104", AccountNumber: "98765", Amount: 10500, Type: "credit", TaxPayment: taxPayment},
+		},
+	}
+	file, err := Build(testDefaults, sub, time.Now())
+	require.NoError(t, err)
+	require.Len(t, file.Batches, 1)
+	require.Equal(t, ach.CCD, file.Batches[0].GetHeader().StandardEntryClassCode)
+
+	entries := file.Batches[0].GetEntries()
+	require.Equal(t, childSupport.String(), entries[0].Addenda05[0].PaymentRelatedInformation)
+	require.Equal(t, taxPayment.String(), entries[1].Addenda05[0].PaymentRelatedInformation)
+	require.Empty(t, addenda.Check(file, []string{addenda.ChildSupportFormat, addenda.TaxPaymentFormat}))
+
+	// Amounts must match the entry's
+	sub.Entries[0].Amount = 100
+	_, err = Build(testDefaults, sub, time.Now())
+	require.ErrorContains(t, err, "entry 1: childSupport: amount 25000 doesn't match entry amount 100")
+
+	sub.Entries[0].Amount = 25000
+	sub.Entries[1].Amount = 100
+	_, err = Build(testDefaults, sub, time.Now())
+	require.ErrorContains(t, err, "entry 2: taxPayment: amounts total 10500 which doesn't match entry amount 100")
+
+	sub.Entries[1].Amount = 10500
+	sub.Entries[1].Addenda = "extra"
+	_, err = Build(testDefaults, sub, time.Now())
+	require.ErrorContains(t, err, "entry 2: only one of addenda, childSupport and taxPayment can be set")
+}
+
+func TestBuild__Errors(t *testing.T) {
+	_, err := Build(nil, &Submission{}, time.Now())
+	require.ErrorContains(t, err, "nil FileDefaults")
+
+	_, err = Build(testDefaults, &Submission{}, time.Now())
+	require.ErrorContains(t, err, "no entries")
+
+	cases := map[string]Entry{
+		"invalid amount 0":              {RoutingNumber: "231380104", AccountNumber: "1", Type: "credit"},
+		`invalid routing number "1234"`: {RoutingNumber: "1234", AccountNumber: "1", Amount: 1, Type: "credit"},
+		"missing account number":        {RoutingNumber: "231380104", Amount: 1, Type: "credit"},
+		`unknown account type "money"`:  {RoutingNumber: "231380104", AccountNumber: "1", AccountType: "money", Amount: 1, Type: "credit"},
+		`unknown entry type "transfer"`: {RoutingNumber: "231380104", AccountNumber: "1", Amount: 1, Type: "transfer"},
+	}
+	for expected, entry := range cases {
+		_, err := Build(testDefaults, &Submission{Entries: []Entry{entry}}, time.Now())
+		require.ErrorContains(t, err, "entry 1: "+expected)
+	}
+}
@@ -0,0 +1,150 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package entries builds ACH files from a simple list of entries submitted as CSV or JSON so
+// callers don't need an ACH library. File and batch headers come from the shard's FileDefaults.
+package entries
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/moov-io/achgateway/internal/addenda"
+)
+
+// Submission is a set of entries to build into one ACH file.
+type Submission struct {
+	// CompanyEntryDescription overrides FileDefaults.CompanyEntryDescription
+	CompanyEntryDescription string `json:"companyEntryDescription"`
+
+	// EffectiveEntryDate is YYYY-MM-DD, defaults to the next banking day
+	EffectiveEntryDate string `json:"effectiveEntryDate"`
+
+	Entries []Entry `json:"entries"`
+}
+
+// Entry is a single credit or debit to a receiver's account.
+type Entry struct {
+	Name           string `json:"name"`
+	RoutingNumber  string `json:"routingNumber"`
+	AccountNumber  string `json:"accountNumber"`
+	AccountType    string `json:"accountType"` // checking (default) or savings
+	Amount         int    `json:"amount"`      // in cents
+	Type           string `json:"type"`        // credit or debit
+	SECCode        string `json:"secCode"`     // defaults to PPD
+	Identification string `json:"identification"`
+	Addenda        string `json:"addenda"`
+
+	// ChildSupport and TaxPayment write the entry's addenda in the DED or TXP layout instead
+	// of Addenda, and default SECCode to CCD. They're only read from JSON.
+	ChildSupport *addenda.ChildSupport `json:"childSupport,omitempty"`
+	TaxPayment   *addenda.TaxPayment   `json:"taxPayment,omitempty"`
+}
+
+// csvColumns are the CSV header names, in the order written by the docs.
+var csvColumns = []string{"name", "routingNumber", "accountNumber", "accountType", "amount", "type", "secCode", "identification", "addenda"}
+
+// ReadJSON parses a Submission from JSON.
+func ReadJSON(r io.Reader) (*Submission, error) {
+	var sub Submission
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sub); err != nil {
+		return nil, fmt.Errorf("reading entries: %v", err)
+	}
+	return &sub, nil
+}
+
+// ReadCSV parses a Submission from CSV with a header row naming each column. The columns
+// name, routingNumber, accountNumber, amount and type are required.
+func ReadCSV(r io.Reader) (*Submission, error) {
+	rd := csv.NewReader(r)
+	rd.TrimLeadingSpace = true
+
+	header, err := rd.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading entries header: %v", err)
+	}
+	columns := make(map[string]int)
+	for i := range header {
+		name := strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+		if !knownColumn(name) {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"name", "routingNumber", "accountNumber", "amount", "type"} {
+		if _, exists := columns[required]; !exists {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	var sub Submission
+	for line := 2; ; line++ {
+		record, err := rd.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading entries: %v", err)
+		}
+		value := func(name string) string {
+			if i, exists := columns[name]; exists && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		amount, err := strconv.Atoi(value("amount"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount %q", line, value("amount"))
+		}
+		sub.Entries = append(sub.Entries, Entry{
+			Name:           value("name"),
+			RoutingNumber:  value("routingNumber"),
+			AccountNumber:  value("accountNumber"),
+			AccountType:    value("accountType"),
+			Amount:         amount,
+			Type:           value("type"),
+			SECCode:        value("secCode"),
+			Identification: value("identification"),
+			Addenda:        value("addenda"),
+		})
+	}
+	return &sub, nil
+}
+
+// Read parses CSV when contentType is text/csv and JSON otherwise.
+func Read(contentType string, bs []byte) (*Submission, error) {
+	if strings.HasPrefix(strings.ToLower(contentType), "text/csv") {
+		return ReadCSV(bytes.NewReader(bs))
+	}
+	return ReadJSON(bytes.NewReader(bs))
+}
+
+func knownColumn(name string) bool {
+	for i := range csvColumns {
+		if csvColumns[i] == name {
+			return true
+		}
+	}
+	return false
+}
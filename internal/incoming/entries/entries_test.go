@@ -0,0 +1,64 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package entries
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadJSON(t *testing.T) {
+	sub, err := Read("application/json", []byte(`{
+  "companyEntryDescription": "PAYROLL",
+  "entries": [
+    {"name": "Jane Doe", "routingNumber": "231380104", "accountNumber": "12345", "amount": 1250, "type": "credit", "addenda": "October pay"}
+  ]
+}`))
+	require.NoError(t, err)
+	require.Equal(t, "PAYROLL", sub.CompanyEntryDescription)
+	require.Len(t, sub.Entries, 1)
+	require.Equal(t, "Jane Doe", sub.Entries[0].Name)
+	require.Equal(t, 1250, sub.Entries[0].Amount)
+
+	_, err = ReadJSON(strings.NewReader(`{"entries": [{"amnt": 12}]}`))
+	require.ErrorContains(t, err, "unknown field")
+}
+
+func TestReadCSV(t *testing.T) {
+	body := "name,routingNumber,accountNumber,accountType,amount,type,secCode,addenda\n" +
+		"Jane Doe,231380104,12345,savings,1250,credit,,October pay\n" +
+		"Acme Corp, 231380104, 98765,,99,debit,CCD,\n"
+	sub, err := Read("text/csv; charset=utf-8", []byte(body))
+	require.NoError(t, err)
+	require.Len(t, sub.Entries, 2)
+	require.Equal(t, "savings", sub.Entries[0].AccountType)
+	require.Equal(t, "October pay", sub.Entries[0].Addenda)
+	require.Equal(t, "98765", sub.Entries[1].AccountNumber)
+	require.Equal(t, "CCD", sub.Entries[1].SECCode)
+
+	_, err = ReadCSV(strings.NewReader("name,routingNumber,accountNumber,amount\n"))
+	require.ErrorContains(t, err, "missing type column")
+
+	_, err = ReadCSV(strings.NewReader("name,routing\n"))
+	require.ErrorContains(t, err, `unknown column "routing"`)
+
+	_, err = ReadCSV(strings.NewReader("name,routingNumber,accountNumber,amount,type\nJane,231380104,12345,12.50,credit\n"))
+	require.ErrorContains(t, err, `line 2: invalid amount "12.50"`)
+}
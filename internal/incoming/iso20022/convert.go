@@ -0,0 +1,263 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iso20022
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+)
+
+// Convert creates an ACH file of credits from doc. Each PmtInf becomes one batch per SEC code.
+func Convert(cfg *service.ISO20022Config, doc *Document, now time.Time) (*ach.File, error) {
+	if cfg == nil {
+		return nil, errors.New("nil ISO20022Config")
+	}
+	if doc == nil {
+		return nil, errors.New("nil Document")
+	}
+
+	file := ach.NewFile()
+	file.Header = ach.NewFileHeader()
+	file.Header.ImmediateDestination = cfg.ImmediateDestination
+	file.Header.ImmediateDestinationName = cfg.ImmediateDestinationName
+	file.Header.ImmediateOrigin = cfg.ImmediateOrigin
+	file.Header.ImmediateOriginName = cfg.ImmediateOriginName
+	file.Header.FileCreationDate = now.Format("060102")
+	file.Header.FileCreationTime = now.Format("1504")
+
+	for _, pmt := range doc.CustomerCreditTransferIn.PaymentInformation {
+		batches, err := convertPayment(cfg, pmt, len(file.Batches), now)
+		if err != nil {
+			return nil, fmt.Errorf("PmtInf %s: %v", pmt.PaymentInformationID, err)
+		}
+		for i := range batches {
+			file.AddBatch(batches[i])
+		}
+	}
+	if err := file.Create(); err != nil {
+		return nil, fmt.Errorf("creating file: %v", err)
+	}
+	if err := file.Validate(); err != nil {
+		return nil, fmt.Errorf("validating file: %v", err)
+	}
+	return file, nil
+}
+
+func convertPayment(cfg *service.ISO20022Config, pmt PaymentInformation, batchCount int, now time.Time) ([]ach.Batcher, error) {
+	if pmt.PaymentMethod != "" && pmt.PaymentMethod != "TRF" {
+		return nil, fmt.Errorf("unsupported PmtMtd %s", pmt.PaymentMethod)
+	}
+	if len(pmt.Transactions) == 0 {
+		return nil, errors.New("no CdtTrfTxInf found")
+	}
+
+	odfi := routingNumber(pmt.DebtorAgent.MemberID)
+	if odfi == "" {
+		odfi = cfg.ODFIIdentification
+	}
+	if odfi == "" {
+		odfi = strings.TrimSpace(cfg.ImmediateOrigin)
+	}
+	if len(odfi) < 8 {
+		return nil, fmt.Errorf("invalid ODFI routing number %q", odfi)
+	}
+	companyID := pmt.Debtor.OrganisationID
+	if companyID == "" {
+		companyID = cfg.CompanyIdentification
+	}
+	if companyID == "" {
+		return nil, errors.New("missing Dbtr organisation ID and CompanyIdentification")
+	}
+	description := cfg.CompanyEntryDescription
+	if description == "" {
+		description = "PAYMENT"
+	}
+	effective, err := executionDate(pmt.RequestedExecution, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Split transactions by SEC code, keeping the order they were first seen
+	var codes []string
+	txs := make(map[string][]Transaction)
+	for _, tx := range pmt.Transactions {
+		code := secCode(cfg.SECCodes, pmt, tx)
+		if _, exists := txs[code]; !exists {
+			codes = append(codes, code)
+		}
+		txs[code] = append(txs[code], tx)
+	}
+
+	var out []ach.Batcher
+	for _, code := range codes {
+		bh := ach.NewBatchHeader()
+		bh.ServiceClassCode = ach.CreditsOnly
+		bh.StandardEntryClassCode = code
+		bh.CompanyName = truncate(pmt.Debtor.Name, 16)
+		bh.CompanyIdentification = truncate(companyID, 10)
+		bh.CompanyEntryDescription = truncate(description, 10)
+		bh.EffectiveEntryDate = effective
+		bh.ODFIIdentification = odfi[:8]
+		bh.BatchNumber = batchCount + len(out) + 1
+
+		batch, err := ach.NewBatch(bh)
+		if err != nil {
+			return nil, err
+		}
+		for i, tx := range txs[code] {
+			entry, err := convertTransaction(tx, odfi, i+1)
+			if err != nil {
+				return nil, fmt.Errorf("EndToEndId %s: %v", tx.EndToEndID, err)
+			}
+			batch.AddEntry(entry)
+		}
+		if err := batch.Create(); err != nil {
+			return nil, fmt.Errorf("creating %s batch: %v", code, err)
+		}
+		out = append(out, batch)
+	}
+	return out, nil
+}
+
+func convertTransaction(tx Transaction, odfi string, seq int) (*ach.EntryDetail, error) {
+	if tx.Amount.Currency != "USD" {
+		return nil, fmt.Errorf("unsupported currency %q", tx.Amount.Currency)
+	}
+	amount, err := parseAmount(tx.Amount.Value)
+	if err != nil {
+		return nil, err
+	}
+	rdfi := routingNumber(tx.CreditorAgent.MemberID)
+	if rdfi == "" {
+		return nil, fmt.Errorf("invalid CdtrAgt routing number %q", tx.CreditorAgent.MemberID)
+	}
+	if tx.CreditorAccount.ID == "" {
+		return nil, errors.New("missing CdtrAcct")
+	}
+
+	entry := ach.NewEntryDetail()
+	entry.TransactionCode = ach.CheckingCredit
+	if strings.EqualFold(tx.CreditorAccount.Type, "SVGS") {
+		entry.TransactionCode = ach.SavingsCredit
+	}
+	entry.SetRDFI(rdfi)
+	entry.DFIAccountNumber = tx.CreditorAccount.ID
+	entry.Amount = amount
+	entry.IdentificationNumber = truncate(tx.EndToEndID, 15)
+	entry.IndividualName = truncate(tx.Creditor.Name, 22)
+	entry.SetTraceNumber(odfi[:8], seq)
+
+	if info := strings.TrimSpace(strings.Join(tx.Remittance, " ")); info != "" {
+		addenda := ach.NewAddenda05()
+		addenda.PaymentRelatedInformation = truncate(info, 80)
+		addenda.SequenceNumber = 1
+		addenda.EntryDetailSequenceNumber = seq
+		entry.AddAddenda05(addenda)
+		entry.AddendaRecordIndicator = 1
+	}
+	return entry, nil
+}
+
+// secCode picks the SEC code for tx by category purpose, then the creditor's identification
+func secCode(cfg service.ISO20022SECCodes, pmt PaymentInformation, tx Transaction) string {
+	purpose := tx.PaymentTypeInfo.CategoryPurpose
+	if purpose == "" {
+		purpose = pmt.PaymentTypeInfo.CategoryPurpose
+	}
+	if code, exists := cfg.CategoryPurpose[purpose]; purpose != "" && exists {
+		return code
+	}
+	if tx.Creditor.OrganisationID != "" {
+		if cfg.Organisation != "" {
+			return cfg.Organisation
+		}
+		return ach.CCD
+	}
+	if cfg.Default != "" {
+		return cfg.Default
+	}
+	return ach.PPD
+}
+
+// routingNumber returns the 9 digit ABA routing number from a clearing system member ID,
+// which is sometimes prefixed with "USABA".
+func routingNumber(memberID string) string {
+	memberID = strings.TrimPrefix(strings.TrimSpace(memberID), "USABA")
+	if len(memberID) != 9 {
+		return ""
+	}
+	if _, err := strconv.ParseUint(memberID, 10, 64); err != nil {
+		return ""
+	}
+	return memberID
+}
+
+func executionDate(date ExecutionDate, now time.Time) (string, error) {
+	value := strings.TrimSpace(date.Value)
+	if date.Date != "" {
+		value = strings.TrimSpace(date.Date)
+	}
+	if date.DateTime != "" {
+		value = strings.TrimSpace(date.DateTime)
+	}
+	if value == "" {
+		return now.Format("060102"), nil
+	}
+	if len(value) > 10 {
+		value = value[:10] // ISODateTime starts with the date
+	}
+	when, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return "", fmt.Errorf("invalid ReqdExctnDt %q", value)
+	}
+	return when.Format("060102"), nil
+}
+
+// parseAmount converts a decimal amount (like 1234.5) into cents without floating point rounding
+func parseAmount(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	whole, frac, _ := strings.Cut(value, ".")
+	if len(frac) > 2 {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+
+	// Entry amounts are 10 digits
+	cents, err := strconv.ParseUint(whole+frac, 10, 64)
+	if err != nil || whole == "" || cents > 9999999999 {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if cents == 0 {
+		return 0, errors.New("zero amount")
+	}
+	return int(cents), nil
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,199 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iso20022
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() *service.ISO20022Config {
+	return &service.ISO20022Config{
+		ImmediateDestination: "231380104",
+		ImmediateOrigin:      "076401251",
+	}
+}
+
+func readDocument(t *testing.T) *Document {
+	t.Helper()
+
+	bs, err := os.ReadFile(filepath.Join("testdata", "pain001.xml"))
+	require.NoError(t, err)
+
+	doc, err := ReadBytes(bs)
+	require.NoError(t, err)
+	return doc
+}
+
+func TestRead(t *testing.T) {
+	doc := readDocument(t)
+	require.Equal(t, "ERP-20220812-0001", doc.CustomerCreditTransferIn.GroupHeader.MessageID)
+
+	pmts := doc.CustomerCreditTransferIn.PaymentInformation
+	require.Len(t, pmts, 1)
+	require.Equal(t, "SALA", pmts[0].PaymentTypeInfo.CategoryPurpose)
+	require.Equal(t, "1234567890", pmts[0].Debtor.OrganisationID)
+	require.Len(t, pmts[0].Transactions, 3)
+	require.Equal(t, "USD", pmts[0].Transactions[0].Amount.Currency)
+	require.Equal(t, "SVGS", pmts[0].Transactions[1].CreditorAccount.Type)
+
+	_, err := ReadBytes([]byte("<Document></Document>"))
+	require.ErrorContains(t, err, "no PmtInf found")
+
+	_, err = ReadBytes([]byte("{}"))
+	require.ErrorContains(t, err, "reading pain.001")
+}
+
+func TestConvert(t *testing.T) {
+	cfg := testConfig()
+	now := time.Date(2022, time.August, 12, 10, 30, 0, 0, time.UTC)
+
+	file, err := Convert(cfg, readDocument(t), now)
+	require.NoError(t, err)
+
+	// Write and read the file to ensure it's valid
+	var buf bytes.Buffer
+	require.NoError(t, ach.NewWriter(&buf).Write(file))
+	_, err = ach.NewReader(&buf).Read()
+	require.NoError(t, err)
+
+	require.Equal(t, "220812", file.Header.FileCreationDate)
+	require.Len(t, file.Batches, 2)
+
+	// Category purpose isn't mapped, so individuals are PPD and organisations CCD
+	ppd := file.Batches[0]
+	require.Equal(t, ach.PPD, ppd.GetHeader().StandardEntryClassCode)
+	require.Equal(t, ach.CreditsOnly, ppd.GetHeader().ServiceClassCode)
+	require.Equal(t, "Acme Corp", ppd.GetHeader().CompanyName)
+	require.Equal(t, "1234567890", ppd.GetHeader().CompanyIdentification)
+	require.Equal(t, "PAYMENT", ppd.GetHeader().CompanyEntryDescription)
+	require.Equal(t, "220815", ppd.GetHeader().EffectiveEntryDate)
+	require.Equal(t, "07640125", ppd.GetHeader().ODFIIdentification)
+
+	entries := ppd.GetEntries()
+	require.Len(t, entries, 2)
+	require.Equal(t, ach.CheckingCredit, entries[0].TransactionCode)
+	require.Equal(t, "23138010", entries[0].RDFIIdentification)
+	require.Equal(t, 250000, entries[0].Amount)
+	require.Equal(t, "Jane Doe", entries[0].IndividualName)
+	require.Equal(t, "EMP-1001", entries[0].IdentificationNumber)
+
+	require.Equal(t, ach.SavingsCredit, entries[1].TransactionCode)
+	require.Equal(t, "09100001", entries[1].RDFIIdentification)
+	require.Equal(t, 180050, entries[1].Amount)
+
+	ccd := file.Batches[1]
+	require.Equal(t, ach.CCD, ccd.GetHeader().StandardEntryClassCode)
+	require.Equal(t, 2, ccd.GetHeader().BatchNumber)
+	entries = ccd.GetEntries()
+	require.Len(t, entries, 1)
+	require.Equal(t, 25075, entries[0].Amount)
+	require.Len(t, entries[0].Addenda05, 1)
+	require.Equal(t, "Invoice 2022-118", entries[0].Addenda05[0].PaymentRelatedInformation)
+}
+
+func TestConvert__CategoryPurpose(t *testing.T) {
+	cfg := testConfig()
+	cfg.SECCodes = service.ISO20022SECCodes{
+		CategoryPurpose: map[string]string{
+			"SUPP": ach.PPD,
+		},
+		Default: ach.CCD,
+	}
+
+	file, err := Convert(cfg, readDocument(t), time.Now())
+	require.NoError(t, err)
+	require.Len(t, file.Batches, 2)
+
+	require.Equal(t, ach.CCD, file.Batches[0].GetHeader().StandardEntryClassCode)
+	require.Len(t, file.Batches[0].GetEntries(), 2)
+	require.Equal(t, ach.PPD, file.Batches[1].GetHeader().StandardEntryClassCode)
+}
+
+func TestConvert__Errors(t *testing.T) {
+	_, err := Convert(nil, readDocument(t), time.Now())
+	require.ErrorContains(t, err, "nil ISO20022Config")
+
+	doc := readDocument(t)
+	doc.CustomerCreditTransferIn.PaymentInformation[0].Transactions[0].Amount.Currency = "EUR"
+	_, err = Convert(testConfig(), doc, time.Now())
+	require.ErrorContains(t, err, `PmtInf PAYROLL-0812: EndToEndId EMP-1001: unsupported currency "EUR"`)
+
+	doc = readDocument(t)
+	doc.CustomerCreditTransferIn.PaymentInformation[0].Transactions[0].CreditorAgent.MemberID = "12345"
+	_, err = Convert(testConfig(), doc, time.Now())
+	require.ErrorContains(t, err, `invalid CdtrAgt routing number "12345"`)
+
+	doc = readDocument(t)
+	doc.CustomerCreditTransferIn.PaymentInformation[0].PaymentMethod = "CHK"
+	_, err = Convert(testConfig(), doc, time.Now())
+	require.ErrorContains(t, err, "unsupported PmtMtd CHK")
+
+	doc = readDocument(t)
+	doc.CustomerCreditTransferIn.PaymentInformation[0].Debtor.OrganisationID = ""
+	_, err = Convert(testConfig(), doc, time.Now())
+	require.ErrorContains(t, err, "missing Dbtr organisation ID and CompanyIdentification")
+}
+
+func TestParseAmount(t *testing.T) {
+	cases := map[string]int{
+		"1":           100,
+		"1.5":         150,
+		"1234.56":     123456,
+		"0.01":        1,
+		" 10.00 ":     1000,
+		"99999999.99": 9999999999,
+	}
+	for input, expected := range cases {
+		amount, err := parseAmount(input)
+		require.NoError(t, err, input)
+		require.Equal(t, expected, amount, input)
+	}
+
+	for _, input := range []string{"", "1.234", "-5", "abc", ".5", "0.00", "100000000.00"} {
+		_, err := parseAmount(input)
+		require.Error(t, err, input)
+	}
+}
+
+func TestExecutionDate(t *testing.T) {
+	now := time.Date(2022, time.August, 12, 10, 30, 0, 0, time.UTC)
+
+	date, err := executionDate(ExecutionDate{}, now)
+	require.NoError(t, err)
+	require.Equal(t, "220812", date)
+
+	date, err = executionDate(ExecutionDate{Date: "2022-08-16"}, now)
+	require.NoError(t, err)
+	require.Equal(t, "220816", date)
+
+	date, err = executionDate(ExecutionDate{DateTime: "2022-08-17T09:00:00"}, now)
+	require.NoError(t, err)
+	require.Equal(t, "220817", date)
+
+	_, err = executionDate(ExecutionDate{Value: "08/12/2022"}, now)
+	require.ErrorContains(t, err, "invalid ReqdExctnDt")
+}
@@ -0,0 +1,115 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package iso20022 converts ISO 20022 pain.001 (CustomerCreditTransferInitiation) messages
+// into ACH files so they can be submitted like any other file.
+package iso20022
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Document is the subset of a pain.001 message used to create ACH credits. Element names are
+// matched regardless of namespace so pain.001.001.03 and later versions are read the same way.
+type Document struct {
+	XMLName                  xml.Name                 `xml:"Document"`
+	CustomerCreditTransferIn CustomerCreditTransferIn `xml:"CstmrCdtTrfInitn"`
+}
+
+type CustomerCreditTransferIn struct {
+	GroupHeader        GroupHeader          `xml:"GrpHdr"`
+	PaymentInformation []PaymentInformation `xml:"PmtInf"`
+}
+
+type GroupHeader struct {
+	MessageID            string `xml:"MsgId"`
+	NumberOfTransactions string `xml:"NbOfTxs"`
+	ControlSum           string `xml:"CtrlSum"`
+}
+
+type PaymentInformation struct {
+	PaymentInformationID string          `xml:"PmtInfId"`
+	PaymentMethod        string          `xml:"PmtMtd"`
+	PaymentTypeInfo      PaymentTypeInfo `xml:"PmtTpInf"`
+	RequestedExecution   ExecutionDate   `xml:"ReqdExctnDt"`
+	Debtor               Party           `xml:"Dbtr"`
+	DebtorAccount        Account         `xml:"DbtrAcct"`
+	DebtorAgent          Agent           `xml:"DbtrAgt"`
+	Transactions         []Transaction   `xml:"CdtTrfTxInf"`
+}
+
+// ExecutionDate is an ISODate in pain.001.001.03 and a choice of Dt or DtTm in later versions
+type ExecutionDate struct {
+	Value    string `xml:",chardata"`
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type PaymentTypeInfo struct {
+	CategoryPurpose string `xml:"CtgyPurp>Cd"`
+}
+
+type Party struct {
+	Name           string `xml:"Nm"`
+	OrganisationID string `xml:"Id>OrgId>Othr>Id"`
+	PrivateID      string `xml:"Id>PrvtId>Othr>Id"`
+}
+
+type Account struct {
+	ID   string `xml:"Id>Othr>Id"`
+	Type string `xml:"Tp>Cd"`
+}
+
+type Agent struct {
+	MemberID string `xml:"FinInstnId>ClrSysMmbId>MmbId"`
+}
+
+type Transaction struct {
+	EndToEndID      string          `xml:"PmtId>EndToEndId"`
+	PaymentTypeInfo PaymentTypeInfo `xml:"PmtTpInf"`
+	Amount          Amount          `xml:"Amt>InstdAmt"`
+	CreditorAgent   Agent           `xml:"CdtrAgt"`
+	Creditor        Party           `xml:"Cdtr"`
+	CreditorAccount Account         `xml:"CdtrAcct"`
+	Remittance      []string        `xml:"RmtInf>Ustrd"`
+}
+
+type Amount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// Read parses a pain.001 message
+func Read(r io.Reader) (*Document, error) {
+	var doc Document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("reading pain.001: %v", err)
+	}
+	if len(doc.CustomerCreditTransferIn.PaymentInformation) == 0 {
+		return nil, errors.New("reading pain.001: no PmtInf found")
+	}
+	return &doc, nil
+}
+
+// ReadBytes parses a pain.001 message from bs
+func ReadBytes(bs []byte) (*Document, error) {
+	return Read(bytes.NewReader(bs))
+}
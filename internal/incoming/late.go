@@ -0,0 +1,41 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package incoming
+
+import (
+	"fmt"
+	"time"
+)
+
+// LateSubmissionError is returned for files submitted while their shard's cutoff is merging
+// when the shard rejects late submissions.
+type LateSubmissionError struct {
+	ShardName       string
+	CutoffStartedAt time.Time
+
+	// NextCutoff is the shard's next cutoff window, which is zero when it's unknown
+	NextCutoff time.Time
+}
+
+func (e *LateSubmissionError) Error() string {
+	msg := fmt.Sprintf("late submission: shard %s cutoff started merging at %s", e.ShardName, e.CutoffStartedAt.Format(time.RFC3339))
+	if !e.NextCutoff.IsZero() {
+		msg += fmt.Sprintf(", resubmit for the next cutoff at %s", e.NextCutoff.Format(time.RFC3339))
+	}
+	return msg
+}
@@ -21,12 +21,16 @@ import (
 	"errors"
 
 	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/pkg/cpa005"
 )
 
 type ACHFile struct {
 	FileID   string    `json:"id"`
 	ShardKey string    `json:"shardKey"`
 	File     *ach.File `json:"file"`
+
+	// Metadata is echoed back on events about the file, such as FileUploaded
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 func (f ACHFile) Validate() error {
@@ -42,6 +46,29 @@ func (f ACHFile) Validate() error {
 	return nil
 }
 
+// CPA005File is a Canadian EFT file accepted for a shard configured with CPA005
+type CPA005File struct {
+	FileID   string       `json:"id"`
+	ShardKey string       `json:"shardKey"`
+	File     *cpa005.File `json:"file"`
+
+	// Metadata is echoed back on events about the file, such as FileUploaded
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func (f CPA005File) Validate() error {
+	if f.FileID == "" {
+		return errors.New("missing fileID")
+	}
+	if f.ShardKey == "" {
+		return errors.New("missing shardKey")
+	}
+	if f.File == nil {
+		return errors.New("missing File")
+	}
+	return nil
+}
+
 type CancelACHFile struct {
 	FileID   string `json:"id"`
 	ShardKey string `json:"shardKey"`
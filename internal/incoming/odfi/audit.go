@@ -19,6 +19,9 @@ package odfi
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/moov-io/achgateway/internal/audittrail"
 	"github.com/moov-io/achgateway/internal/service"
@@ -39,6 +42,21 @@ func (as *AuditSaver) save(filepath string, data []byte) error {
 	return as.storage.SaveFile(filepath, data)
 }
 
+// path is where a downloaded file is saved: odfi/$hostname/$dir/$date/$filename. dir is the
+// directory holding the file relative to where the scan downloaded files, so it includes the
+// subdirectories of agents which read more than one level of their paths.
+func (as *AuditSaver) path(where string, report *scanReport) string {
+	dir, filename := filepath.Split(where)
+	dir = filepath.Base(dir)
+	if report != nil && report.dir != "" {
+		rel, err := filepath.Rel(report.dir, filepath.Dir(where))
+		if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			dir = filepath.ToSlash(rel)
+		}
+	}
+	return fmt.Sprintf("odfi/%s/%s/%s/%s", as.hostname, dir, time.Now().Format("2006-01-02"), filename)
+}
+
 func newAuditSaver(hostname string, cfg *service.AuditTrail) (*AuditSaver, error) {
 	if cfg == nil {
 		return nil, nil
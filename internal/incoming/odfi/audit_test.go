@@ -18,7 +18,10 @@
 package odfi
 
 import (
+	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -30,3 +33,19 @@ func TestAuditSaver(t *testing.T) {
 	saver = &AuditSaver{}
 	require.NoError(t, saver.save("foo.ach", nil))
 }
+
+func TestAuditSaver__Path(t *testing.T) {
+	saver := &AuditSaver{hostname: "ftp.bank.com"}
+	today := time.Now().Format("2006-01-02")
+
+	report := &scanReport{dir: filepath.Join("tmp", "download")}
+	where := filepath.Join("tmp", "download", "inbound", "RET.ach")
+	require.Equal(t, fmt.Sprintf("odfi/ftp.bank.com/inbound/%s/RET.ach", today), saver.path(where, report))
+
+	// Subdirectories read from the agent are kept
+	where = filepath.Join("tmp", "download", "inbound", "2024-05-01", "RET.ach")
+	require.Equal(t, fmt.Sprintf("odfi/ftp.bank.com/inbound/2024-05-01/%s/RET.ach", today), saver.path(where, report))
+
+	// Without a report only the parent directory is used
+	require.Equal(t, fmt.Sprintf("odfi/ftp.bank.com/2024-05-01/%s/RET.ach", today), saver.path(where, nil))
+}
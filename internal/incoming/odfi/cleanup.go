@@ -19,6 +19,7 @@ package odfi
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 
@@ -46,6 +47,23 @@ func Cleanup(logger log.Logger, agent upload.Agent, dl *downloadedFiles) error {
 	return el
 }
 
+// Archive moves files on remote servers under dir, keeping the path they were read from
+func Archive(logger log.Logger, agent upload.Agent, dl *downloadedFiles, dir string) error {
+	var el base.ErrorList
+	for _, path := range []string{agent.InboundPath(), agent.ReconciliationPath(), agent.ReturnPath()} {
+		if _, err := os.Stat(filepath.Join(dl.dir, path)); err != nil {
+			continue // skip if the directory doesn't exist
+		}
+		if err := moveFilesOnRemote(logger, agent, dl.dir, path, dir); err != nil {
+			el.Add(err)
+		}
+	}
+	if el.Empty() {
+		return nil
+	}
+	return el
+}
+
 // CleanupEmptyFiles deletes empty ACH files if file is older than value in config
 func CleanupEmptyFiles(logger log.Logger, agent upload.Agent, dl *downloadedFiles) error {
 	var el base.ErrorList
@@ -66,14 +84,14 @@ func CleanupEmptyFiles(logger log.Logger, agent upload.Agent, dl *downloadedFile
 // deleteFilesOnRemote deletes all files for a given directory
 func deleteFilesOnRemote(logger log.Logger, agent upload.Agent, localDir, suffix string) error {
 	baseDir := filepath.Join(localDir, suffix)
-	infos, err := os.ReadDir(baseDir)
+	files, err := listLocalFiles(baseDir)
 	if err != nil {
 		return fmt.Errorf("reading %s: %v", baseDir, err)
 	}
 
 	var el base.ErrorList
-	for i := range infos {
-		path := filepath.Join(suffix, filepath.Base(infos[i].Name()))
+	for _, rel := range files {
+		path := filepath.Join(suffix, rel)
 		if err := agent.Delete(path); err != nil {
 			// Ignore the error if it's about deleting a remote file that's gone
 			if os.IsNotExist(err) {
@@ -91,19 +109,44 @@ func deleteFilesOnRemote(logger log.Logger, agent upload.Agent, localDir, suffix
 	return el
 }
 
+// moveFilesOnRemote moves all files for a given directory under archiveDir
+func moveFilesOnRemote(logger log.Logger, agent upload.Agent, localDir, suffix, archiveDir string) error {
+	baseDir := filepath.Join(localDir, suffix)
+	files, err := listLocalFiles(baseDir)
+	if err != nil {
+		return fmt.Errorf("reading %s: %v", baseDir, err)
+	}
+
+	var el base.ErrorList
+	for _, rel := range files {
+		path := filepath.Join(suffix, rel)
+		archived := filepath.Join(archiveDir, path)
+		if err := upload.Move(agent, path, archived); err != nil {
+			el.Add(err)
+		} else {
+			logger.Logf("cleanup: moved remote file %s to %s", path, archived)
+		}
+	}
+
+	if el.Empty() {
+		return nil
+	}
+	return el
+}
+
 // deleteEmptyFiles deletes all empty files that are older than after (time.Duration)
 func deleteEmptyFiles(logger log.Logger, agent upload.Agent, localDir, suffix string) error {
 	baseDir := filepath.Join(localDir, suffix)
-	entries, err := os.ReadDir(baseDir)
+	files, err := listLocalFiles(baseDir)
 	if err != nil {
 		return fmt.Errorf("reading %s: %v", baseDir, err)
 	}
 
 	var el base.ErrorList
-	for i := range entries {
-		path := filepath.Join(suffix, filepath.Base(entries[i].Name()))
+	for _, rel := range files {
+		path := filepath.Join(suffix, rel)
 
-		info, err := entries[i].Info()
+		info, err := os.Stat(filepath.Join(baseDir, rel))
 		if err != nil {
 			logger.LogError(err)
 			continue
@@ -130,3 +173,24 @@ func deleteEmptyFiles(logger log.Logger, agent upload.Agent, localDir, suffix st
 	}
 	return el
 }
+
+// listLocalFiles returns the paths relative to baseDir of every file within it, including the
+// subdirectories of agents which read more than one level of their paths
+func listLocalFiles(baseDir string) ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(baseDir, func(where string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(baseDir, where)
+		if err != nil {
+			return err
+		}
+		out = append(out, rel)
+		return nil
+	})
+	return out, err
+}
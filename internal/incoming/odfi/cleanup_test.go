@@ -156,3 +156,19 @@ func Test_CleanupEmptyFiles_PopulatedFile(t *testing.T) {
 		t.Errorf("expected no deleted files, but got %q", agent.DeletedFile)
 	}
 }
+
+func TestCleanup__Subdirectories(t *testing.T) {
+	agent := &upload.MockAgent{}
+
+	dl := &downloadedFiles{dir: t.TempDir()}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dl.dir, agent.InboundPath()), 0777))
+	require.NoError(t, os.MkdirAll(filepath.Join(dl.dir, agent.ReconciliationPath()), 0777))
+
+	path := filepath.Join(dl.dir, agent.ReturnPath(), "2024-05-01")
+	require.NoError(t, os.MkdirAll(path, 0777))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "RET.ach"), []byte("data"), 0600))
+
+	require.NoError(t, Cleanup(log.NewNopLogger(), agent, dl))
+	require.Equal(t, filepath.Join("return", "2024-05-01", "RET.ach"), agent.DeletedFile)
+}
@@ -20,7 +20,6 @@ package odfi
 import (
 	"fmt"
 	"path/filepath"
-	"strings"
 
 	"github.com/moov-io/achgateway/internal/events"
 	"github.com/moov-io/achgateway/internal/service"
@@ -59,6 +58,15 @@ func (pc *correctionProcessor) Type() string {
 	return "correction"
 }
 
+func (pc *correctionProcessor) Name() string {
+	return "corrections"
+}
+
+// MatchesPath reports if path contains the PathMatcher value, or if there is none
+func (pc *correctionProcessor) MatchesPath(path string) bool {
+	return matchesPath(pc.cfg.PathMatcher, path)
+}
+
 func isCorrectionFile(file File) bool {
 	return len(file.ACHFile.NotificationOfChange) >= 0
 }
@@ -69,7 +77,7 @@ func (pc *correctionProcessor) Handle(file File) error {
 	}
 
 	// Ignore files if they don't contain the PathMatcher value
-	if pc.cfg.PathMatcher != "" && !strings.Contains(strings.ToLower(file.Filepath), pc.cfg.PathMatcher) {
+	if !pc.MatchesPath(file.Filepath) {
 		return nil // skip the file
 	}
 
@@ -107,15 +115,15 @@ func (pc *correctionProcessor) Handle(file File) error {
 			}).Log(fmt.Sprintf("odfi: correction batch %d entry %d code %s", i, j, changeCode.Code))
 		}
 	}
-	pc.sendEvent(msg)
-	return nil
+	return pc.sendEvent(msg)
 }
 
-func (pc *correctionProcessor) sendEvent(event interface{}) {
-	if pc.svc != nil {
-		err := pc.svc.Send(models.Event{Event: event})
-		if err != nil {
-			pc.logger.Logf("error sending correction event: %v", err)
-		}
+func (pc *correctionProcessor) sendEvent(event interface{}) error {
+	if pc.svc == nil {
+		return nil
 	}
+	if err := events.Deliver(pc.svc, models.Event{Event: event}); err != nil {
+		return fmt.Errorf("sending correction event: %v", err)
+	}
+	return nil
 }
@@ -35,7 +35,7 @@ func TestCorrections(t *testing.T) {
 		Webhook: &service.WebhookConfig{
 			Endpoint: "https://cb.moov.io/incoming",
 		},
-	})
+	}, nil)
 	require.NoError(t, err)
 
 	emitter := CorrectionEmitter(log.NewNopLogger(), cfg, eventsService)
@@ -0,0 +1,185 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/deliveries"
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+)
+
+type deliveryCorrelator struct {
+	logger  log.Logger
+	pattern *regexp.Regexp
+	timeout time.Duration
+	repo    deliveries.Repository
+	svc     events.Emitter
+	now     func() time.Time
+}
+
+// DeliveryCorrelator groups files an ODFI splits one delivery across by their filename and emits
+// a combined summary once every file has arrived. It's nil without a config or repository.
+func DeliveryCorrelator(logger log.Logger, cfg *service.ODFIDeliveries, repo deliveries.Repository, svc events.Emitter) *deliveryCorrelator {
+	if cfg == nil || repo == nil {
+		return nil
+	}
+	return &deliveryCorrelator{
+		logger:  logger,
+		pattern: regexp.MustCompile(cfg.FilenamePattern), // checked by Validate
+		timeout: cfg.DeliveryTimeout(),
+		repo:    repo,
+		svc:     svc,
+		now:     time.Now,
+	}
+}
+
+func (pc *deliveryCorrelator) Type() string {
+	return "delivery correlation"
+}
+
+func (pc *deliveryCorrelator) Name() string {
+	return "deliveries"
+}
+
+func (pc *deliveryCorrelator) Handle(file File) error {
+	if file.ACHFile == nil {
+		return nil
+	}
+	filename := filepath.Base(file.Filepath)
+	id, sequence, total, ok, err := pc.match(filename)
+	if !ok || err != nil {
+		return err
+	}
+
+	received := models.DeliveryFile{
+		Sequence:        sequence,
+		Filename:        filename,
+		ReceivedAt:      pc.now().UTC().Truncate(time.Second),
+		DeliverySummary: deliveries.Summarize(file.ACHFile),
+	}
+	delivery, err := pc.repo.Add(id, total, received)
+	if err != nil {
+		return err
+	}
+
+	logger := pc.logger.With(log.Fields{
+		"delivery": log.String(id),
+		"filepath": log.String(file.Filepath),
+	})
+	logger.Logf("odfi: received delivery file %d of %d", sequence, total)
+
+	// Deliveries which timed out still complete if their missing files show up later
+	if len(delivery.Missing()) > 0 || delivery.Status == deliveries.StatusComplete {
+		return nil
+	}
+	if err := pc.repo.SetStatus(id, deliveries.StatusComplete); err != nil {
+		return err
+	}
+	logger.Logf("odfi: delivery completed with %d files", total)
+
+	return pc.sendEvent(models.DeliveryCompleted{
+		DeliveryID:  id,
+		Files:       delivery.Files,
+		Summary:     delivery.Summary(),
+		CompletedAt: pc.now().UTC(),
+	})
+}
+
+// match returns the delivery ID, sequence and total parsed from filename, or false if the
+// filename isn't part of a delivery
+func (pc *deliveryCorrelator) match(filename string) (string, int, int, bool, error) {
+	matches := pc.pattern.FindStringSubmatch(filename)
+	if matches == nil {
+		return "", 0, 0, false, nil
+	}
+	group := func(name string) string {
+		return matches[pc.pattern.SubexpIndex(name)]
+	}
+	sequence, err := strconv.Atoi(group("sequence"))
+	if err != nil {
+		return "", 0, 0, false, fmt.Errorf("invalid sequence in %s: %v", filename, err)
+	}
+	total, err := strconv.Atoi(group("total"))
+	if err != nil {
+		return "", 0, 0, false, fmt.Errorf("invalid total in %s: %v", filename, err)
+	}
+	if total < 1 || sequence < 1 || sequence > total {
+		return "", 0, 0, false, fmt.Errorf("invalid sequence %d of %d in %s", sequence, total, filename)
+	}
+	return group("delivery"), sequence, total, true, nil
+}
+
+// AfterScan flags deliveries still missing files after the timeout
+func (pc *deliveryCorrelator) AfterScan() error {
+	pending, err := pc.repo.Pending(pc.now().Add(-pc.timeout))
+	if err != nil {
+		return err
+	}
+
+	var incomplete []string
+	for _, delivery := range pending {
+		if err := pc.repo.SetStatus(delivery.ID, deliveries.StatusIncomplete); err != nil {
+			return err
+		}
+		missing := delivery.Missing()
+		err := pc.sendEvent(models.DeliveryIncomplete{
+			DeliveryID:      delivery.ID,
+			Expected:        delivery.Total,
+			Missing:         missing,
+			Files:           delivery.Files,
+			Summary:         delivery.Summary(),
+			FirstReceivedAt: delivery.FirstReceivedAt,
+		})
+		if err != nil {
+			return err
+		}
+		incomplete = append(incomplete, fmt.Sprintf("%s missing %s", delivery.ID, formatSequences(missing)))
+	}
+	if len(incomplete) == 0 {
+		return nil
+	}
+	return errors.New("incomplete deliveries: " + strings.Join(incomplete, ", "))
+}
+
+func formatSequences(sequences []int) string {
+	out := make([]string, len(sequences))
+	for i := range sequences {
+		out[i] = strconv.Itoa(sequences[i])
+	}
+	return strings.Join(out, ",")
+}
+
+func (pc *deliveryCorrelator) sendEvent(event interface{}) error {
+	if pc.svc == nil {
+		return nil
+	}
+	if err := events.Deliver(pc.svc, models.Event{Event: event}); err != nil {
+		return fmt.Errorf("sending delivery event: %v", err)
+	}
+	return nil
+}
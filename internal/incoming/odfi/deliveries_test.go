@@ -0,0 +1,117 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/deliveries"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryCorrelator(t *testing.T) {
+	cfg := &service.ODFIDeliveries{
+		FilenamePattern: `RET_(?P<delivery>\d{8})_(?P<sequence>\d+)of(?P<total>\d+)\.ach`,
+		Timeout:         time.Hour,
+	}
+	require.Nil(t, DeliveryCorrelator(log.NewNopLogger(), nil, nil, nil))
+	require.Nil(t, DeliveryCorrelator(log.NewNopLogger(), cfg, nil, nil))
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	emitter := &recordingEmitter{}
+	pc := DeliveryCorrelator(log.NewNopLogger(), cfg, deliveries.NewRepository(db.DB), emitter)
+	var _ ScanProcessor = pc
+
+	debit, err := ach.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	correction, err := ach.ReadFile(filepath.Join("..", "..", "..", "testdata", "cor-c01.ach"))
+	require.NoError(t, err)
+
+	// Other files are skipped
+	require.NoError(t, pc.Handle(File{Filepath: "/odfi/inbound/ppd-debit.ach", ACHFile: debit}))
+	require.ErrorContains(t, pc.Handle(File{Filepath: "RET_20220601_3of2.ach", ACHFile: debit}), "invalid sequence 3 of 2")
+
+	require.NoError(t, pc.Handle(File{Filepath: "/odfi/returned/RET_20220601_2of2.ach", ACHFile: debit}))
+	require.NoError(t, pc.AfterScan())
+	require.Empty(t, emitter.events)
+
+	require.NoError(t, pc.Handle(File{Filepath: "/odfi/returned/RET_20220601_1of2.ach", ACHFile: correction}))
+	require.Len(t, emitter.events, 1)
+
+	completed, ok := emitter.events[0].Event.(models.DeliveryCompleted)
+	require.True(t, ok)
+	require.Equal(t, "20220601", completed.DeliveryID)
+	require.Len(t, completed.Files, 2)
+	require.Equal(t, "RET_20220601_1of2.ach", completed.Files[0].Filename)
+	require.Equal(t, models.DeliverySummary{Entries: 2, Corrections: 1, DebitTotal: 10500}, completed.Summary)
+
+	// Receiving a file again doesn't complete the delivery twice
+	require.NoError(t, pc.Handle(File{Filepath: "RET_20220601_1of2.ach", ACHFile: correction}))
+	require.Len(t, emitter.events, 1)
+}
+
+func TestDeliveryCorrelator__Incomplete(t *testing.T) {
+	cfg := &service.ODFIDeliveries{
+		FilenamePattern: `RET_(?P<delivery>\d{8})_(?P<sequence>\d+)of(?P<total>\d+)\.ach`,
+	}
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	emitter := &recordingEmitter{}
+	pc := DeliveryCorrelator(log.NewNopLogger(), cfg, deliveries.NewRepository(db.DB), emitter)
+
+	debit, err := ach.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	pc.now = func() time.Time { return time.Now().Add(-48 * time.Hour) }
+	require.NoError(t, pc.Handle(File{Filepath: "RET_20220601_2of3.ach", ACHFile: debit}))
+
+	// Process the files as part of a scan
+	pc.now = time.Now
+	err = SetupProcessors(pc).AfterScan()
+	require.ErrorContains(t, err, "delivery correlation: incomplete deliveries: 20220601 missing 1,3")
+	require.Len(t, emitter.events, 1)
+
+	incomplete, ok := emitter.events[0].Event.(models.DeliveryIncomplete)
+	require.True(t, ok)
+	require.Equal(t, 3, incomplete.Expected)
+	require.Equal(t, []int{1, 3}, incomplete.Missing)
+	require.Equal(t, models.DeliverySummary{Entries: 1, DebitTotal: 10500}, incomplete.Summary)
+
+	// Deliveries are only flagged once
+	require.NoError(t, pc.AfterScan())
+
+	// Late files still complete the delivery
+	require.NoError(t, pc.Handle(File{Filepath: "RET_20220601_1of3.ach", ACHFile: debit}))
+	require.NoError(t, pc.Handle(File{Filepath: "RET_20220601_3of3.ach", ACHFile: debit}))
+	require.Len(t, emitter.events, 2)
+
+	completed, ok := emitter.events[1].Event.(models.DeliveryCompleted)
+	require.True(t, ok)
+	require.Equal(t, models.DeliverySummary{Entries: 3, DebitTotal: 31500}, completed.Summary)
+}
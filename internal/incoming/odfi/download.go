@@ -64,6 +64,10 @@ type downloaderImpl struct {
 // These are designed to be deleted after all files are processed.
 type downloadedFiles struct {
 	dir string
+
+	// downloaded counts the files copied from the remote server
+	downloaded int
+	report     *scanReport
 }
 
 func (d *downloadedFiles) deleteFiles() error {
@@ -119,7 +123,8 @@ func (dl *downloaderImpl) setup(agent upload.Agent) (*downloadedFiles, error) {
 	}
 
 	return &downloadedFiles{
-		dir: dir,
+		dir:    dir,
+		report: &scanReport{dir: dir},
 	}, nil
 }
 
@@ -139,6 +144,8 @@ func (dl *downloaderImpl) CopyFilesFromRemote(agent upload.Agent) (*downloadedFi
 	if err := dl.writeFiles(filepath.Join(out.dir, agent.InboundPath()), files); err != nil {
 		return out, fmt.Errorf("problem saving inbound files: %v", err)
 	}
+	out.downloaded += len(files)
+	out.report.skipRemote(upload.SkippedFiles(agent))
 
 	// copy down files from out "reconciliation" directory
 	files, err = agent.GetReconciliationFiles()
@@ -150,6 +157,8 @@ func (dl *downloaderImpl) CopyFilesFromRemote(agent upload.Agent) (*downloadedFi
 	if err := dl.writeFiles(filepath.Join(out.dir, agent.ReconciliationPath()), files); err != nil {
 		return out, fmt.Errorf("problem saving reconciliation files: %v", err)
 	}
+	out.downloaded += len(files)
+	out.report.skipRemote(upload.SkippedFiles(agent))
 
 	// copy down files from out "return" directory
 	files, err = agent.GetReturnFiles()
@@ -161,6 +170,8 @@ func (dl *downloaderImpl) CopyFilesFromRemote(agent upload.Agent) (*downloadedFi
 	if err := dl.writeFiles(filepath.Join(out.dir, agent.ReturnPath()), files); err != nil {
 		return out, fmt.Errorf("problem saving return files: %v", err)
 	}
+	out.downloaded += len(files)
+	out.report.skipRemote(upload.SkippedFiles(agent))
 
 	return out, nil
 }
@@ -171,9 +182,26 @@ func (dl *downloaderImpl) writeFiles(dir string, files []upload.File) error {
 	var firstErr error
 	var errordFilenames []string
 
+	// Contents can be temporary files, which are removed once they're closed
+	defer func() {
+		for i := range files {
+			files[i].Close()
+		}
+	}()
+
 	os.MkdirAll(dir, 0777) // ignore errors
 	for i := range files {
-		f, err := os.Create(filepath.Join(dir, files[i].Filename))
+		// Files from subdirectories of the agent's paths are named by their relative path
+		where := filepath.Join(dir, filepath.FromSlash(files[i].Filename))
+		if !strings.HasPrefix(where, filepath.Clean(dir)+string(filepath.Separator)) {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("invalid filename %q", files[i].Filename)
+			}
+			errordFilenames = append(errordFilenames, files[i].Filename)
+			continue
+		}
+		os.MkdirAll(filepath.Dir(where), 0777) // ignore errors
+		f, err := os.Create(where)
 		if err != nil {
 			if firstErr == nil {
 				firstErr = err
@@ -182,6 +210,7 @@ func (dl *downloaderImpl) writeFiles(dir string, files []upload.File) error {
 			continue
 		}
 		if _, err = io.Copy(f, files[i].Contents); err != nil {
+			f.Close()
 			if firstErr == nil {
 				firstErr = err
 			}
@@ -189,6 +218,7 @@ func (dl *downloaderImpl) writeFiles(dir string, files []upload.File) error {
 			continue
 		}
 		if err := f.Sync(); err != nil {
+			f.Close()
 			return err
 		}
 		if err := f.Close(); err != nil {
@@ -197,7 +227,7 @@ func (dl *downloaderImpl) writeFiles(dir string, files []upload.File) error {
 		if err := files[i].Contents.Close(); err != nil {
 			return err
 		}
-		dl.logger.Logf("saved %s at %s", files[i].Filename, filepath.Join(dir, files[i].Filename))
+		dl.logger.Logf("saved %s at %s", files[i].Filename, where)
 	}
 	if len(errordFilenames) != 0 {
 		return fmt.Errorf("writeFiles problem on: %s: %v", strings.Join(errordFilenames, ", "), firstErr)
@@ -0,0 +1,84 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	// ConvertEBCDIC is a file decoded from EBCDIC (IBM code page 037)
+	ConvertEBCDIC = "ebcdic"
+
+	// ConvertLineEndings is a file whose CRLF or CR-only line endings were replaced with LF
+	ConvertLineEndings = "line_endings"
+)
+
+var (
+	filesConverted = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "inbound_files_converted",
+		Help: "Counter of inbound files converted to ASCII with LF line endings before parsing",
+	}, []string{"conversion"})
+)
+
+// normalizeContents converts files from legacy bank hosts into the ASCII text with LF line
+// endings the readers expect. The conversions made are returned, which are empty when bs
+// is returned unchanged.
+func normalizeContents(bs []byte) ([]byte, []string, error) {
+	var conversions []string
+	if isEBCDIC(bs) {
+		decoded, err := charmap.CodePage037.NewDecoder().Bytes(bs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decoding EBCDIC: %v", err)
+		}
+		// EBCDIC's NL character decodes to U+0085 rather than LF
+		bs = bytes.ReplaceAll(decoded, []byte("\u0085"), []byte("\n"))
+		conversions = append(conversions, ConvertEBCDIC)
+	}
+	if bytes.IndexByte(bs, '\r') >= 0 {
+		bs = bytes.ReplaceAll(bs, []byte("\r\n"), []byte("\n"))
+		bs = bytes.ReplaceAll(bs, []byte("\r"), []byte("\n"))
+		conversions = append(conversions, ConvertLineEndings)
+	}
+	for i := range conversions {
+		filesConverted.With("conversion", conversions[i]).Add(1)
+	}
+	return bs, conversions, nil
+}
+
+// isEBCDIC reports if bs starts with a record type in EBCDIC. Nacha records start with the digits
+// 1 through 9 (0xF1-0xF9) and CPA-005 files with an A (0xC1), none of which are ASCII.
+func isEBCDIC(bs []byte) bool {
+	for _, b := range bs {
+		switch {
+		case b == 0x40 || b == 0x15 || b == 0x25 || b == 0x0D:
+			// Skip leading spaces and line endings
+			continue
+		case b >= 0xF1 && b <= 0xF9, b == 0xC1:
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/audittrail"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/charmap"
+)
+
+type savingStorage struct {
+	audittrail.MockStorage
+
+	saved map[string][]byte
+}
+
+func (s *savingStorage) SaveFile(path string, data []byte) error {
+	s.saved[path] = data
+	return nil
+}
+
+func readReturnFile(t *testing.T) []byte {
+	t.Helper()
+
+	bs, err := os.ReadFile(filepath.Join("testdata", "return.ach"))
+	require.NoError(t, err)
+	return bytes.ReplaceAll(bytes.TrimSpace(bs), []byte("\r\n"), []byte("\n"))
+}
+
+func TestNormalizeContents(t *testing.T) {
+	expected := readReturnFile(t)
+
+	bs, conversions, err := normalizeContents(expected)
+	require.NoError(t, err)
+	require.Empty(t, conversions)
+	require.Equal(t, expected, bs)
+
+	// CR-only and CRLF line endings
+	for _, ending := range []string{"\r", "\r\n"} {
+		bs, conversions, err = normalizeContents(bytes.ReplaceAll(expected, []byte("\n"), []byte(ending)))
+		require.NoError(t, err)
+		require.Equal(t, []string{ConvertLineEndings}, conversions)
+		require.Equal(t, string(expected), string(bs))
+	}
+
+	// EBCDIC with NL or LF line endings
+	for _, ending := range []string{"\u0085", "\n"} {
+		encoded, err := charmap.CodePage037.NewEncoder().Bytes(bytes.ReplaceAll(expected, []byte("\n"), []byte(ending)))
+		require.NoError(t, err)
+		require.True(t, isEBCDIC(encoded))
+
+		bs, conversions, err = normalizeContents(encoded)
+		require.NoError(t, err)
+		require.Equal(t, []string{ConvertEBCDIC}, conversions)
+		require.Equal(t, string(expected), string(bs))
+	}
+
+	require.False(t, isEBCDIC(nil))
+	require.False(t, isEBCDIC(expected))
+	require.True(t, isEBCDIC([]byte{0x40, 0x15, 0xF1, 0xF0}))
+}
+
+func TestProcessor__EBCDIC(t *testing.T) {
+	expected := readReturnFile(t)
+	encoded, err := charmap.CodePage037.NewEncoder().Bytes(bytes.ReplaceAll(expected, []byte("\n"), []byte("\r\n")))
+	require.NoError(t, err)
+
+	dir := filepath.Join(t.TempDir(), "returned")
+	require.NoError(t, os.MkdirAll(dir, 0777))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "return.ach"), encoded, 0600))
+
+	proc := &MockProcessor{}
+	storage := &savingStorage{saved: make(map[string][]byte)}
+	auditSaver := &AuditSaver{
+		storage:  storage,
+		hostname: "ftp.foo.com",
+	}
+	require.NoError(t, processDir(dir, auditSaver, SetupProcessors(proc), nil))
+
+	require.NotNil(t, proc.HandledFile)
+	require.NotNil(t, proc.HandledFile.ACHFile)
+	require.Len(t, proc.HandledFile.ACHFile.ReturnEntries, 1)
+
+	// The audit trail has the file as it was downloaded
+	require.Len(t, storage.saved, 1)
+	for path, data := range storage.saved {
+		require.Contains(t, path, "odfi/ftp.foo.com/returned/")
+		require.Equal(t, encoded, data)
+	}
+}
@@ -0,0 +1,115 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+)
+
+// BAI2 type codes for each kind of entry
+const (
+	typeCodeTotalCredits = "100"
+	typeCodeTotalDebits  = "400"
+
+	typeCodeACHCredit      = "165" // Preauthorized ACH Credit
+	typeCodeACHDebit       = "455" // Preauthorized ACH Debit
+	typeCodeReturnedCredit = "266" // Return Item
+	typeCodeReturnedDebit  = "555" // Deposited Item Returned
+)
+
+// WriteBAI2 writes records as a BAI2 file with one group and one account. Each record is a
+// transaction detail (16) and the account's summary holds the credit and debit totals.
+func WriteBAI2(w io.Writer, cfg service.ODFIExportBAI2, records []Record, now time.Time) error {
+	currency := cfg.Currency
+	if currency == "" {
+		currency = "USD"
+	}
+	var credits, debits, creditCount, debitCount int
+	for i := range records {
+		if records[i].Credit {
+			credits += records[i].Amount
+			creditCount++
+		} else {
+			debits += records[i].Amount
+			debitCount++
+		}
+	}
+
+	var buf bytes.Buffer
+	lines := 0
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&buf, format+"\n", args...)
+		lines++
+	}
+
+	line("01,%s,%s,%s,%s,%s,,,2/", field(cfg.SenderID), field(cfg.ReceiverID), now.Format("060102"), now.Format("1504"), now.Format("150405"))
+	line("02,%s,%s,1,%s,%s,%s,2/", field(cfg.ReceiverID), field(cfg.SenderID), now.Format("060102"), now.Format("1504"), currency)
+
+	accountStart := lines
+	accountTotal := credits + debits
+	line("03,%s,%s,%s,%d,%d,,%s,%d,%d,/", field(cfg.AccountNumber), currency, typeCodeTotalCredits, credits, creditCount, typeCodeTotalDebits, debits, debitCount)
+	for i := range records {
+		rec := records[i]
+		accountTotal += rec.Amount
+		line("16,%s,%d,0,%s,%s,%s", typeCode(rec), rec.Amount, field(rec.TraceNumber), field(rec.IdentificationNumber), text(rec))
+	}
+	line("49,%d,%d/", accountTotal, lines-accountStart+1)
+	line("98,%d,1,%d/", accountTotal, lines) // every record after the file header
+	line("99,%d,1,%d/", accountTotal, lines+1)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func typeCode(rec Record) string {
+	switch {
+	case rec.Kind == KindReturn && rec.Credit:
+		return typeCodeReturnedCredit
+	case rec.Kind == KindReturn:
+		return typeCodeReturnedDebit
+	case rec.Credit:
+		return typeCodeACHCredit
+	}
+	return typeCodeACHDebit
+}
+
+// text is the free form text of a transaction detail, which runs until the end of the record
+func text(rec Record) string {
+	parts := []string{rec.SECCode, rec.CompanyName, rec.IndividualName}
+	if rec.ReturnCode != "" {
+		parts = append(parts, rec.ReturnCode, rec.OriginalTraceNumber)
+	}
+	var out []string
+	for i := range parts {
+		if p := strings.TrimSpace(parts[i]); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.NewReplacer("\n", " ", "\r", " ").Replace(strings.Join(out, " "))
+}
+
+// field removes the delimiters BAI2 uses between fields and records
+func field(s string) string {
+	return strings.NewReplacer(",", "", "/", "", "\n", "", "\r", "").Replace(strings.TrimSpace(s))
+}
@@ -0,0 +1,93 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testRecords() []Record {
+	return []Record{
+		{
+			Kind:                 KindReconciliation,
+			SECCode:              "PPD",
+			CompanyName:          "Moov",
+			EffectiveEntryDate:   "2023-10-10",
+			TransactionCode:      22,
+			Credit:               true,
+			Amount:               12345,
+			TraceNumber:          "273976360000001",
+			IndividualName:       "Jane Doe",
+			IdentificationNumber: "inv/1,2",
+			RoutingNumber:        "273976369",
+			AccountNumber:        "123456",
+		},
+		{
+			Kind:                KindReturn,
+			SECCode:             "PPD",
+			CompanyName:         "Moov",
+			TransactionCode:     27,
+			Amount:              500,
+			TraceNumber:         "273976360000002",
+			IndividualName:      "John Doe",
+			ReturnCode:          "R03",
+			OriginalTraceNumber: "273976360000001",
+		},
+	}
+}
+
+func TestWriteBAI2(t *testing.T) {
+	cfg := service.ODFIExportBAI2{
+		SenderID:      "273976369",
+		ReceiverID:    "MOOV",
+		AccountNumber: "987654321",
+	}
+	now := time.Date(2023, time.October, 11, 8, 30, 15, 0, time.UTC)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteBAI2(&buf, cfg, testRecords(), now))
+
+	expected := []string{
+		"01,273976369,MOOV,231011,0830,083015,,,2/",
+		"02,MOOV,273976369,1,231011,0830,USD,2/",
+		"03,987654321,USD,100,12345,1,,400,500,1,/",
+		"16,165,12345,0,273976360000001,inv12,PPD Moov Jane Doe",
+		"16,555,500,0,273976360000002,,PPD Moov John Doe R03 273976360000001",
+		"49,25690,4/",
+		"98,25690,1,6/",
+		"99,25690,1,8/",
+	}
+	require.Equal(t, strings.Join(expected, "\n")+"\n", buf.String())
+}
+
+func TestWriteBAI2__Empty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteBAI2(&buf, service.ODFIExportBAI2{Currency: "CAD"}, nil, time.Now()))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 6)
+	require.Equal(t, "03,,CAD,100,0,0,,400,0,0,/", lines[2])
+	require.Equal(t, "49,0,2/", lines[3])
+}
@@ -0,0 +1,111 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// columns are every CSV column which can be exported, in their default order
+var columns = []string{
+	"kind", "effectiveEntryDate", "secCode", "companyName", "companyIdentification", "companyEntryDescription",
+	"transactionCode", "creditDebit", "amount", "amountCents", "traceNumber", "individualName",
+	"identificationNumber", "routingNumber", "accountNumber", "returnCode", "originalTraceNumber",
+}
+
+// ValidateColumns returns an error if any of names can't be exported
+func ValidateColumns(names []string) error {
+	for i := range names {
+		if _, err := column(Record{}, names[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes records with a header row of names, or every column when names is empty
+func WriteCSV(w io.Writer, names []string, records []Record) error {
+	if len(names) == 0 {
+		names = columns
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(names); err != nil {
+		return err
+	}
+	row := make([]string, len(names))
+	for i := range records {
+		for j := range names {
+			value, err := column(records[i], names[j])
+			if err != nil {
+				return err
+			}
+			row[j] = value
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func column(rec Record, name string) (string, error) {
+	switch name {
+	case "kind":
+		return rec.Kind, nil
+	case "effectiveEntryDate":
+		return rec.EffectiveEntryDate, nil
+	case "secCode":
+		return rec.SECCode, nil
+	case "companyName":
+		return rec.CompanyName, nil
+	case "companyIdentification":
+		return rec.CompanyIdentification, nil
+	case "companyEntryDescription":
+		return rec.CompanyEntryDescription, nil
+	case "transactionCode":
+		return strconv.Itoa(rec.TransactionCode), nil
+	case "creditDebit":
+		if rec.Credit {
+			return "credit", nil
+		}
+		return "debit", nil
+	case "amount":
+		return fmt.Sprintf("%d.%02d", rec.Amount/100, rec.Amount%100), nil
+	case "amountCents":
+		return strconv.Itoa(rec.Amount), nil
+	case "traceNumber":
+		return rec.TraceNumber, nil
+	case "individualName":
+		return rec.IndividualName, nil
+	case "identificationNumber":
+		return rec.IdentificationNumber, nil
+	case "routingNumber":
+		return rec.RoutingNumber, nil
+	case "accountNumber":
+		return rec.AccountNumber, nil
+	case "returnCode":
+		return rec.ReturnCode, nil
+	case "originalTraceNumber":
+		return rec.OriginalTraceNumber, nil
+	}
+	return "", fmt.Errorf("unknown column %q", name)
+}
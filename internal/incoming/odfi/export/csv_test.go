@@ -0,0 +1,48 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package export
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, []string{"kind", "traceNumber", "creditDebit", "amount", "identificationNumber", "returnCode"}, testRecords()))
+
+	expected := "kind,traceNumber,creditDebit,amount,identificationNumber,returnCode\n" +
+		"reconciliation,273976360000001,credit,123.45,\"inv/1,2\",\n" +
+		"return,273976360000002,debit,5.00,,R03\n"
+	require.Equal(t, expected, buf.String())
+}
+
+func TestWriteCSV__DefaultColumns(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, nil, testRecords()))
+	require.Contains(t, buf.String(), "kind,effectiveEntryDate,secCode,companyName,")
+	require.Contains(t, buf.String(), "reconciliation,2023-10-10,PPD,Moov,")
+}
+
+func TestValidateColumns(t *testing.T) {
+	require.NoError(t, ValidateColumns(nil))
+	require.NoError(t, ValidateColumns(columns))
+	require.EqualError(t, ValidateColumns([]string{"amount", "balance"}), `unknown column "balance"`)
+}
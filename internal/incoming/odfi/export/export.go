@@ -0,0 +1,101 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package export writes the reconciliation and return entries of ODFI files in BAI2 or CSV
+// layouts for treasury systems which can't consume achgateway's JSON events.
+package export
+
+import (
+	"strings"
+	"time"
+
+	"github.com/moov-io/ach"
+)
+
+const (
+	KindReconciliation = "reconciliation"
+	KindReturn         = "return"
+)
+
+// Record is one reconciliation or return entry to export.
+type Record struct {
+	Kind string
+
+	SECCode                 string
+	CompanyName             string
+	CompanyIdentification   string
+	CompanyEntryDescription string
+	EffectiveEntryDate      string // YYYY-MM-DD when the batch's date is valid
+
+	TransactionCode      int
+	Credit               bool
+	Amount               int // in cents
+	TraceNumber          string
+	IndividualName       string
+	IdentificationNumber string
+	RoutingNumber        string
+	AccountNumber        string
+
+	// Returns only
+	ReturnCode          string
+	OriginalTraceNumber string
+}
+
+// Records returns every entry of batches as kind
+func Records(kind string, batches []ach.Batcher) []Record {
+	var out []Record
+	for i := range batches {
+		bh := batches[i].GetHeader()
+		if bh == nil {
+			continue
+		}
+		entries := batches[i].GetEntries()
+		for j := range entries {
+			ed := entries[j]
+			rec := Record{
+				Kind:                    kind,
+				SECCode:                 bh.StandardEntryClassCode,
+				CompanyName:             strings.TrimSpace(bh.CompanyName),
+				CompanyIdentification:   strings.TrimSpace(bh.CompanyIdentification),
+				CompanyEntryDescription: strings.TrimSpace(bh.CompanyEntryDescription),
+				EffectiveEntryDate:      effectiveDate(bh.EffectiveEntryDate),
+				TransactionCode:         ed.TransactionCode,
+				Credit:                  ed.CreditOrDebit() == "C",
+				Amount:                  ed.Amount,
+				TraceNumber:             ed.TraceNumber,
+				IndividualName:          strings.TrimSpace(ed.IndividualName),
+				IdentificationNumber:    strings.TrimSpace(ed.IdentificationNumber),
+				RoutingNumber:           ed.RDFIIdentification + ed.CheckDigit,
+				AccountNumber:           strings.TrimSpace(ed.DFIAccountNumber),
+			}
+			if ed.Addenda99 != nil {
+				rec.ReturnCode = ed.Addenda99.ReturnCode
+				rec.OriginalTraceNumber = ed.Addenda99.OriginalTrace
+			}
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+func effectiveDate(yymmdd string) string {
+	when, err := time.Parse("060102", yymmdd)
+	if err != nil {
+		return yymmdd
+	}
+	return when.Format("2006-01-02")
+}
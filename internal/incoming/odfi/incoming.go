@@ -18,10 +18,11 @@
 package odfi
 
 import (
+	"fmt"
 	"path/filepath"
-	"strings"
 
 	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/remittance"
 	"github.com/moov-io/achgateway/internal/service"
 	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base/log"
@@ -51,9 +52,18 @@ func (pc *incomingEmitter) Type() string {
 	return "incoming"
 }
 
+func (pc *incomingEmitter) Name() string {
+	return "incoming"
+}
+
+// MatchesPath reports if path contains the PathMatcher value, or if there is none
+func (pc *incomingEmitter) MatchesPath(path string) bool {
+	return matchesPath(pc.cfg.PathMatcher, path)
+}
+
 func (pc *incomingEmitter) Handle(file File) error {
 	// Ignore files if they don't contain the PathMatcher value
-	if pc.cfg.PathMatcher != "" && !strings.Contains(strings.ToLower(file.Filepath), pc.cfg.PathMatcher) {
+	if !pc.MatchesPath(file.Filepath) {
 		return nil // skip the file
 	}
 
@@ -75,19 +85,19 @@ func (pc *incomingEmitter) Handle(file File) error {
 		"filepath": log.String(file.Filepath),
 	}).Log("emitting IncomingFile event")
 
-	pc.sendEvent(models.IncomingFile{
-		Filename: filepath.Base(file.Filepath),
-		File:     file.ACHFile,
+	return pc.sendEvent(models.IncomingFile{
+		Filename:    filepath.Base(file.Filepath),
+		File:        file.ACHFile,
+		Remittances: remittance.File(file.ACHFile),
 	})
-
-	return nil
 }
 
-func (pc *incomingEmitter) sendEvent(event interface{}) {
-	if pc.svc != nil {
-		err := pc.svc.Send(models.Event{Event: event})
-		if err != nil {
-			pc.logger.Logf("error sending pre-note event: %v", err)
-		}
+func (pc *incomingEmitter) sendEvent(event interface{}) error {
+	if pc.svc == nil {
+		return nil
 	}
+	if err := events.Deliver(pc.svc, models.Event{Event: event}); err != nil {
+		return fmt.Errorf("sending incoming event: %v", err)
+	}
+	return nil
 }
@@ -18,10 +18,14 @@
 package odfi
 
 import (
+	"errors"
+	"path/filepath"
 	"testing"
 
+	"github.com/moov-io/ach"
 	"github.com/moov-io/achgateway/internal/events"
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base/log"
 
 	"github.com/stretchr/testify/require"
@@ -38,9 +42,49 @@ func TestIncoming(t *testing.T) {
 		Webhook: &service.WebhookConfig{
 			Endpoint: "https://cb.moov.io/incoming",
 		},
-	})
+	}, nil)
 	require.NoError(t, err)
 
 	emitter := IncomingEmitter(log.NewNopLogger(), cfg, recon, eventsService)
 	require.NotNil(t, emitter)
 }
+
+func TestIncoming__Remittances(t *testing.T) {
+	emitter := &recordingEmitter{}
+	incoming := IncomingEmitter(log.NewNopLogger(), service.ODFIIncoming{Enabled: true}, service.ODFIReconciliation{}, emitter)
+
+	file, err := ach.ReadFile(filepath.Join("testdata", "ccd-remittance.ach"))
+	require.NoError(t, err)
+	require.NoError(t, incoming.Handle(File{
+		Filepath: "ccd-remittance.ach",
+		ACHFile:  file,
+	}))
+
+	require.Len(t, emitter.events, 1)
+	evt, ok := emitter.events[0].Event.(models.IncomingFile)
+	require.True(t, ok)
+	require.Len(t, evt.Remittances, 1)
+
+	rem := evt.Remittances[0]
+	require.Equal(t, "121042880000001", rem.TraceNumber)
+	require.Equal(t, 150050, rem.Amount)
+	require.Len(t, rem.Invoices, 2)
+	require.Equal(t, "INV-1", rem.Invoices[0].Number)
+	require.Equal(t, 100050, rem.Invoices[0].PaidAmount)
+	require.Equal(t, 50000, rem.Invoices[1].PaidAmount)
+}
+
+func TestIncoming__SendError(t *testing.T) {
+	emitter := &recordingEmitter{err: errors.New("kafka unavailable")}
+	incoming := IncomingEmitter(log.NewNopLogger(), service.ODFIIncoming{Enabled: true}, service.ODFIReconciliation{}, emitter)
+
+	file, err := ach.ReadFile(filepath.Join("testdata", "ccd-remittance.ach"))
+	require.NoError(t, err)
+
+	// The file is processed again later instead of being treated as published
+	err = incoming.Handle(File{
+		Filepath: "ccd-remittance.ach",
+		ACHFile:  file,
+	})
+	require.ErrorContains(t, err, "kafka unavailable")
+}
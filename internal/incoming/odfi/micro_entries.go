@@ -0,0 +1,109 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/microentries"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+)
+
+type microEntryReturns struct {
+	logger log.Logger
+	repo   microentries.Repository
+	svc    events.Emitter
+}
+
+// MicroEntryReturns marks micro-entries as returned when any of their entries are returned
+// and sends a MicroEntryUpdated event. It's nil without a repository.
+func MicroEntryReturns(logger log.Logger, repo microentries.Repository, svc events.Emitter) *microEntryReturns {
+	if repo == nil {
+		return nil
+	}
+	return &microEntryReturns{
+		logger: logger,
+		repo:   repo,
+		svc:    svc,
+	}
+}
+
+func (pc *microEntryReturns) Type() string {
+	return "micro-entry returns"
+}
+
+func (pc *microEntryReturns) Name() string {
+	return "micro_entries"
+}
+
+func (pc *microEntryReturns) Handle(file File) error {
+	for i := range file.ACHFile.ReturnEntries {
+		bh := file.ACHFile.ReturnEntries[i].GetHeader()
+		if !strings.EqualFold(strings.TrimSpace(bh.CompanyEntryDescription), microentries.CompanyEntryDescription) {
+			continue
+		}
+		for _, entry := range file.ACHFile.ReturnEntries[i].GetEntries() {
+			if entry.Addenda99 == nil {
+				continue
+			}
+			if err := pc.handleReturn(strings.TrimSpace(entry.IdentificationNumber), entry.Addenda99.ReturnCode); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (pc *microEntryReturns) handleReturn(id, returnCode string) error {
+	micro, err := pc.repo.Get(id)
+	if err != nil {
+		return err
+	}
+	// Each entry is returned, so only the first return updates the micro-entries
+	if micro == nil || micro.Status == microentries.StatusReturned {
+		return nil
+	}
+
+	micro.Status = microentries.StatusReturned
+	micro.ReturnCode = returnCode
+	if err := pc.repo.Update(micro); err != nil {
+		return err
+	}
+	pc.logger.With(log.Fields{
+		"micro_entry_id": log.String(micro.ID),
+		"shard_key":      log.String(micro.ShardKey),
+	}).Logf("odfi: micro-entries returned with %s", returnCode)
+
+	if pc.svc == nil {
+		return nil
+	}
+	err = events.Deliver(pc.svc, models.Event{Event: models.MicroEntryUpdated{
+		MicroEntryID: micro.ID,
+		ShardKey:     micro.ShardKey,
+		Status:       micro.Status,
+		ReturnCode:   micro.ReturnCode,
+		UpdatedAt:    micro.UpdatedAt,
+	}})
+	if err != nil {
+		return fmt.Errorf("sending MicroEntryUpdated event: %v", err)
+	}
+	return nil
+}
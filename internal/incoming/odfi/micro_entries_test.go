@@ -0,0 +1,86 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/microentries"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/achgateway/pkg/rdfi"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMicroEntryReturns(t *testing.T) {
+	require.Nil(t, MicroEntryReturns(log.NewNopLogger(), nil, nil))
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := microentries.NewRepository(db.DB)
+
+	now := time.Now()
+	micro, err := microentries.New("testing", now)
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(micro))
+
+	file, err := microentries.Build(&service.FileDefaults{
+		ImmediateDestination:  "231380104",
+		ImmediateOrigin:       "076401251",
+		CompanyName:           "Moov",
+		CompanyIdentification: "121042882",
+	}, micro, microentries.Account{
+		Name:          "Jane Doe",
+		RoutingNumber: "231380104",
+		AccountNumber: "12345",
+	}, now)
+	require.NoError(t, err)
+
+	returned, err := rdfi.Return(file, rdfi.Options{Code: "R03"})
+	require.NoError(t, err)
+
+	// Read the returns like files downloaded from the ODFI
+	var buf bytes.Buffer
+	require.NoError(t, ach.NewWriter(&buf).Write(returned))
+	returns, err := ach.NewReader(&buf).Read()
+	require.NoError(t, err)
+
+	emitter := &recordingEmitter{}
+	pc := MicroEntryReturns(log.NewNopLogger(), repo, emitter)
+	require.NoError(t, pc.Handle(File{ACHFile: &returns}))
+
+	found, err := repo.Get(micro.ID)
+	require.NoError(t, err)
+	require.Equal(t, microentries.StatusReturned, found.Status)
+	require.Equal(t, "R03", found.ReturnCode)
+
+	// One event is sent for all three returned entries
+	require.Len(t, emitter.events, 1)
+	evt, ok := emitter.events[0].Event.(models.MicroEntryUpdated)
+	require.True(t, ok)
+	require.Equal(t, micro.ID, evt.MicroEntryID)
+	require.Equal(t, "testing", evt.ShardKey)
+	require.Equal(t, microentries.StatusReturned, evt.Status)
+	require.Equal(t, "R03", evt.ReturnCode)
+}
@@ -20,7 +20,6 @@ package odfi
 import (
 	"fmt"
 	"path/filepath"
-	"strings"
 
 	"github.com/moov-io/ach"
 	"github.com/moov-io/achgateway/internal/events"
@@ -60,9 +59,18 @@ func (pc *prenoteEmitter) Type() string {
 	return "prenote"
 }
 
+func (pc *prenoteEmitter) Name() string {
+	return "prenotes"
+}
+
+// MatchesPath reports if path contains the PathMatcher value, or if there is none
+func (pc *prenoteEmitter) MatchesPath(path string) bool {
+	return matchesPath(pc.cfg.PathMatcher, path)
+}
+
 func (pc *prenoteEmitter) Handle(file File) error {
 	// Ignore files if they don't contain the PathMatcher value
-	if pc.cfg.PathMatcher != "" && !strings.Contains(strings.ToLower(file.Filepath), pc.cfg.PathMatcher) {
+	if !pc.MatchesPath(file.Filepath) {
 		return nil // skip the file
 	}
 
@@ -93,7 +101,7 @@ func (pc *prenoteEmitter) Handle(file File) error {
 		}
 	}
 	if len(batches) > 0 {
-		pc.sendEvent(models.PrenoteFile{
+		return pc.sendEvent(models.PrenoteFile{
 			Filename: filepath.Base(file.Filepath),
 			File:     file.ACHFile,
 			Batches:  batches,
@@ -102,13 +110,14 @@ func (pc *prenoteEmitter) Handle(file File) error {
 	return nil
 }
 
-func (pc *prenoteEmitter) sendEvent(event interface{}) {
-	if pc.svc != nil {
-		err := pc.svc.Send(models.Event{Event: event})
-		if err != nil {
-			pc.logger.Logf("error sending pre-note event: %v", err)
-		}
+func (pc *prenoteEmitter) sendEvent(event interface{}) error {
+	if pc.svc == nil {
+		return nil
 	}
+	if err := events.Deliver(pc.svc, models.Event{Event: event}); err != nil {
+		return fmt.Errorf("sending pre-note event: %v", err)
+	}
+	return nil
 }
 
 func isPrenoteFile(file File) bool {
@@ -19,14 +19,17 @@ package odfi
 
 import (
 	"bytes"
-	"crypto/sha1" //nolint:gosec
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
-	"time"
+	"sort"
+	"strings"
 
 	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/fips"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/pkg/cpa005"
 	"github.com/moov-io/base"
 
 	"github.com/go-kit/kit/metrics/prometheus"
@@ -43,6 +46,9 @@ var (
 type File struct {
 	Filepath string
 	ACHFile  *ach.File
+
+	// CPA005File is set instead of ACHFile for Canadian EFT (CPA Standard 005) files
+	CPA005File *cpa005.File
 }
 
 type FileProcessor interface {
@@ -52,6 +58,31 @@ type FileProcessor interface {
 	Handle(file File) error
 }
 
+// CPA005Processor is implemented by processors which also handle CPA Standard 005 files.
+// Other processors are skipped for those files.
+type CPA005Processor interface {
+	HandleCPA005(file File) error
+}
+
+// ScanProcessor is implemented by processors which check on their state once every file
+// from a scan has been handled.
+type ScanProcessor interface {
+	AfterScan() error
+}
+
+// NamedProcessor is implemented by processors which are ordered and enabled for each shard
+// by config, using one of service.ODFIProcessorNames.
+type NamedProcessor interface {
+	Name() string
+}
+
+func processorName(pc FileProcessor) string {
+	if np, ok := pc.(NamedProcessor); ok {
+		return np.Name()
+	}
+	return ""
+}
+
 type Processors []FileProcessor
 
 func SetupProcessors(pcs ...FileProcessor) Processors {
@@ -65,11 +96,59 @@ func SetupProcessors(pcs ...FileProcessor) Processors {
 	return out
 }
 
+// Ordered returns the processors named in order first, followed by the others in their existing order
+func (pcs Processors) Ordered(order []string) Processors {
+	position := func(pc FileProcessor) int {
+		name := processorName(pc)
+		for i := range order {
+			if name != "" && strings.EqualFold(order[i], name) {
+				return i
+			}
+		}
+		return len(order)
+	}
+	out := append(Processors(nil), pcs...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return position(out[i]) < position(out[j])
+	})
+	return out
+}
+
+// ForShard returns the processors which handle a shard's files. Processors without a name always do.
+func (pcs Processors) ForShard(cfg *service.ShardODFIProcessors) Processors {
+	var out Processors
+	for i := range pcs {
+		if name := processorName(pcs[i]); name == "" || cfg.Runs(name) {
+			out = append(out, pcs[i])
+		}
+	}
+	return out
+}
+
 func (pcs Processors) HandleAll(file File) error {
+	return pcs.handleAll(file, nil)
+}
+
+func (pcs Processors) handleAll(file File, report *scanReport) error {
 	var el base.ErrorList
 	for i := range pcs {
 		proc := pcs[i]
-		if err := proc.Handle(file); err != nil {
+
+		var err error
+		cpa, supportsCPA005 := proc.(CPA005Processor)
+		if file.CPA005File != nil && !supportsCPA005 {
+			continue
+		}
+		if m, ok := proc.(PathMatcher); ok && !m.MatchesPath(file.Filepath) {
+			report.skip(file.Filepath, SkipPatternExcluded, proc.Type())
+			continue
+		}
+		if file.CPA005File != nil {
+			err = cpa.HandleCPA005(file)
+		} else {
+			err = proc.Handle(file)
+		}
+		if err != nil {
 			processingErrors.With("processor", fmt.Sprintf("%T", proc)).Add(1)
 
 			el.Add(fmt.Errorf("%s: %v", proc.Type(), err))
@@ -81,6 +160,22 @@ func (pcs Processors) HandleAll(file File) error {
 	return el
 }
 
+// AfterScan calls each ScanProcessor once the files of a scan are processed
+func (pcs Processors) AfterScan() error {
+	var el base.ErrorList
+	for i := range pcs {
+		if proc, ok := pcs[i].(ScanProcessor); ok {
+			if err := proc.AfterScan(); err != nil {
+				el.Add(fmt.Errorf("%s: %v", pcs[i].Type(), err))
+			}
+		}
+	}
+	if el.Empty() {
+		return nil
+	}
+	return el
+}
+
 func ProcessFiles(dl *downloadedFiles, auditSaver *AuditSaver, fileProcessors Processors) error {
 	var el base.ErrorList
 	entries, err := os.ReadDir(dl.dir)
@@ -97,14 +192,14 @@ func ProcessFiles(dl *downloadedFiles, auditSaver *AuditSaver, fileProcessors Pr
 		}
 
 		if info.Mode().IsDir() {
-			err = processDir(where, auditSaver, fileProcessors)
+			err = processDir(where, auditSaver, fileProcessors, dl.report)
 			if err != nil {
 				el.Add(fmt.Errorf("processDir %s: %v", info, err))
 				continue
 			}
 		}
 		if info.Mode().IsRegular() {
-			err = processFile(where, auditSaver, fileProcessors)
+			err = processFile(where, auditSaver, fileProcessors, dl.report)
 			if err != nil {
 				el.Add(fmt.Errorf("processfile - %s: %v", info, err))
 				continue
@@ -117,7 +212,7 @@ func ProcessFiles(dl *downloadedFiles, auditSaver *AuditSaver, fileProcessors Pr
 	return el
 }
 
-func processDir(dir string, auditSaver *AuditSaver, fileProcessors Processors) error {
+func processDir(dir string, auditSaver *AuditSaver, fileProcessors Processors, report *scanReport) error {
 	infos, err := os.ReadDir(dir)
 	if err != nil {
 		return fmt.Errorf("reading %s: %v", dir, err)
@@ -127,7 +222,14 @@ func processDir(dir string, auditSaver *AuditSaver, fileProcessors Processors) e
 	for i := range infos {
 		where := filepath.Join(dir, infos[i].Name())
 
-		if err := processFile(where, auditSaver, fileProcessors); err != nil {
+		// Subdirectories are downloaded from agents reading more than one level of their paths
+		if infos[i].IsDir() {
+			if err := processDir(where, auditSaver, fileProcessors, report); err != nil {
+				el.Add(err)
+			}
+			continue
+		}
+		if err := processFile(where, auditSaver, fileProcessors, report); err != nil {
 			el.Add(err)
 		}
 	}
@@ -138,12 +240,44 @@ func processDir(dir string, auditSaver *AuditSaver, fileProcessors Processors) e
 	return el
 }
 
-func processFile(path string, auditSaver *AuditSaver, fileProcessors Processors) error {
+func processFile(path string, auditSaver *AuditSaver, fileProcessors Processors, report *scanReport) error {
 	bs, err := os.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("problem opening %s: %v", path, err)
 	}
+	if dup, err := report.duplicate(path, bs); dup || err != nil {
+		return err
+	}
+	if err := processContents(path, bs, auditSaver, fileProcessors, report); err != nil {
+		return err
+	}
+	return report.markProcessed(bs)
+}
+
+// Reprocess passes a previously downloaded file through fileProcessors again, such as when
+// replaying events from files saved in the audit trail. The file isn't saved again.
+func Reprocess(path string, data []byte, fileProcessors Processors) error {
+	return processContents(path, data, nil, fileProcessors, nil)
+}
+
+func processContents(path string, bs []byte, auditSaver *AuditSaver, fileProcessors Processors, report *scanReport) error {
+	if len(bs) == 0 {
+		report.skip(path, SkipZeroByte, "")
+		return nil
+	}
+	// The audit trail keeps files as they were downloaded, before any conversion
+	original := bs
+	bs, conversions, err := normalizeContents(bs)
+	if err != nil {
+		return fmt.Errorf("problem converting %s: %v", path, err)
+	}
+	if cpa005.Detect(bs) {
+		return processCPA005Contents(path, bs, original, auditSaver, fileProcessors, report)
+	}
 	bs = bytes.TrimSpace(bs)
+	if len(conversions) == 0 {
+		original = bs
+	}
 
 	reader := ach.NewReader(bytes.NewReader(bs))
 	reader.SetValidation(&ach.ValidateOpts{
@@ -162,23 +296,20 @@ func processFile(path string, auditSaver *AuditSaver, fileProcessors Processors)
 	file.ID = hash(bs)
 	populateHashes(&file)
 
-	dir, filename := filepath.Split(path)
-	dir = filepath.Base(dir)
-
 	// Persist the file if needed
 	if auditSaver != nil {
-		path := fmt.Sprintf("odfi/%s/%s/%s/%s", auditSaver.hostname, dir, time.Now().Format("2006-01-02"), filename)
-		err = auditSaver.save(path, bs)
+		path := auditSaver.path(path, report)
+		err = auditSaver.save(path, original)
 		if err != nil {
 			return fmt.Errorf("audittrail %s error: %v", path, err)
 		}
 	}
 
 	// Pass the file off to our handler
-	err = fileProcessors.HandleAll(File{
+	err = fileProcessors.handleAll(File{
 		Filepath: path,
 		ACHFile:  &file,
-	})
+	}, report)
 	if err != nil {
 		return fmt.Errorf("processing %s error: %v", path, err)
 	}
@@ -186,6 +317,29 @@ func processFile(path string, auditSaver *AuditSaver, fileProcessors Processors)
 	return nil
 }
 
+func processCPA005Contents(path string, bs, original []byte, auditSaver *AuditSaver, fileProcessors Processors, report *scanReport) error {
+	file, err := cpa005.Read(bytes.NewReader(bs))
+	if err != nil {
+		return fmt.Errorf("problem parsing CPA-005 file %s: %v", path, err)
+	}
+
+	if auditSaver != nil {
+		path := auditSaver.path(path, report)
+		if err := auditSaver.save(path, original); err != nil {
+			return fmt.Errorf("audittrail %s error: %v", path, err)
+		}
+	}
+
+	err = fileProcessors.handleAll(File{
+		Filepath:   path,
+		CPA005File: file,
+	}, report)
+	if err != nil {
+		return fmt.Errorf("processing %s error: %v", path, err)
+	}
+	return nil
+}
+
 func populateHashes(file *ach.File) {
 	for i := range file.Batches {
 		entries := file.Batches[i].GetEntries()
@@ -196,5 +350,5 @@ func populateHashes(file *ach.File) {
 }
 
 func hash(data []byte) string {
-	return fmt.Sprintf("%x", sha1.Sum(data)) //nolint:gosec
+	return fips.Hash(data)
 }
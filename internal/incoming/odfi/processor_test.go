@@ -24,6 +24,8 @@ import (
 
 	"github.com/moov-io/ach"
 	"github.com/moov-io/achgateway/internal/audittrail"
+	"github.com/moov-io/achgateway/internal/fips"
+	"github.com/moov-io/achgateway/internal/service"
 	"github.com/stretchr/testify/require"
 )
 
@@ -42,7 +44,7 @@ func TestProcessor(t *testing.T) {
 	// By reading a file without ACH FileHeaders we still want to try and process
 	// Batches inside of it if any are found, so reading this kind of file shouldn't
 	// return an error from reading the file.
-	err = processDir(dir, auditSaver, processors)
+	err = processDir(dir, auditSaver, processors, nil)
 	require.NoError(t, err)
 
 	require.NotNil(t, proc.HandledFile)
@@ -51,7 +53,7 @@ func TestProcessor(t *testing.T) {
 
 	// Real world file
 	path := filepath.Join("..", "..", "..", "testdata", "HMBRAD_ACHEXPORT_1001_08_19_2022_09_10")
-	err = processFile(path, auditSaver, processors)
+	err = processFile(path, auditSaver, processors, nil)
 	require.ErrorContains(t, err, "record:FileHeader *ach.FieldError FileCreationDate  is a mandatory field")
 }
 
@@ -64,4 +66,55 @@ func TestProcessor_populateHashes(t *testing.T) {
 
 	entries := file.Batches[0].GetEntries()
 	require.Equal(t, "389723d3a8293a802169b5db27f288d32e96b9c6", entries[0].ID)
+
+	// FIPS mode opts into SHA-256
+	require.NoError(t, fips.Setup(&service.Config{FIPS: &service.FIPS{Enabled: true}}))
+	t.Cleanup(func() { fips.Setup(nil) })
+
+	populateHashes(file)
+	require.Equal(t, "b729489281c96d7510fad706074042cf0074c85ef828faf17804032b73e4701f", entries[0].ID)
+}
+
+func TestProcessors__Ordered(t *testing.T) {
+	returns, corrections := &returnEmitter{}, &correctionProcessor{}
+	mock := &MockProcessor{}
+	pcs := SetupProcessors(corrections, mock, returns)
+
+	require.Equal(t, pcs, pcs.Ordered(nil))
+	require.Equal(t, Processors{returns, corrections, mock}, pcs.Ordered([]string{"Returns"}))
+	require.Equal(t, Processors{returns, corrections, mock}, pcs.Ordered([]string{"returns", "corrections"}))
+}
+
+func TestProcessors__ForShard(t *testing.T) {
+	returns, recon := &returnEmitter{}, &creditReconciliation{}
+	mock := &MockProcessor{}
+	pcs := SetupProcessors(recon, returns, mock)
+
+	require.Equal(t, pcs, pcs.ForShard(nil))
+	require.Equal(t, Processors{returns, mock}, pcs.ForShard(&service.ShardODFIProcessors{
+		Disabled: []string{"reconciliation"},
+	}))
+	require.Equal(t, Processors{recon, mock}, pcs.ForShard(&service.ShardODFIProcessors{
+		Enabled: []string{"reconciliation"},
+	}))
+}
+
+func TestProcessors__Names(t *testing.T) {
+	pcs := []NamedProcessor{
+		&correctionProcessor{},
+		&prenoteEmitter{},
+		&creditReconciliation{},
+		&returnEmitter{},
+		&incomingEmitter{},
+		&microEntryReturns{},
+		&returnExposure{},
+		&deliveryCorrelator{},
+		&representer{},
+		&treasuryExporter{},
+	}
+	var names []string
+	for i := range pcs {
+		names = append(names, pcs[i].Name())
+	}
+	require.Equal(t, service.ODFIProcessorNames, names)
 }
@@ -19,6 +19,7 @@ package odfi
 
 import (
 	"errors"
+	"fmt"
 	"path/filepath"
 	"strings"
 
@@ -59,6 +60,15 @@ func (pc *creditReconciliation) Type() string {
 	return "CreditReconciliation"
 }
 
+func (pc *creditReconciliation) Name() string {
+	return "reconciliation"
+}
+
+// MatchesPath reports if path contains the PathMatcher value, which reconciliation files require
+func (pc *creditReconciliation) MatchesPath(path string) bool {
+	return pc.cfg.PathMatcher != "" && matchesPath(pc.cfg.PathMatcher, path)
+}
+
 func isReconciliationFile(cfg service.ODFIReconciliation, file File) bool {
 	if !cfg.Enabled {
 		return false
@@ -110,7 +120,7 @@ func (pc *creditReconciliation) Handle(file File) error {
 		}
 	}
 	if len(recons) > 0 {
-		pc.sendEvent(models.ReconciliationFile{
+		return pc.sendEvent(models.ReconciliationFile{
 			Filename:        filepath.Base(file.Filepath),
 			File:            file.ACHFile,
 			Reconciliations: recons,
@@ -119,11 +129,12 @@ func (pc *creditReconciliation) Handle(file File) error {
 	return nil
 }
 
-func (pc *creditReconciliation) sendEvent(event interface{}) {
-	if pc.svc != nil {
-		err := pc.svc.Send(models.Event{Event: event})
-		if err != nil {
-			pc.logger.Logf("error sending reconciliations event: %v", err)
-		}
+func (pc *creditReconciliation) sendEvent(event interface{}) error {
+	if pc.svc == nil {
+		return nil
 	}
+	if err := events.Deliver(pc.svc, models.Event{Event: event}); err != nil {
+		return fmt.Errorf("sending reconciliations event: %v", err)
+	}
+	return nil
 }
@@ -0,0 +1,244 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/representment"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/submissions"
+	"github.com/moov-io/achgateway/pkg/compliance"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	"gocloud.dev/pubsub"
+)
+
+type representer struct {
+	logger      log.Logger
+	cfg         *service.ODFIRepresentment
+	repo        representment.Repository
+	submissions submissions.Repository
+	publisher   *pubsub.Topic
+	svc         events.Emitter
+	now         func() time.Time
+}
+
+// Representer schedules debits returned with a retryable code to be presented again and submits
+// them into the pipeline once they're due, which is checked after each scan. The shard of each
+// returned entry is found from its submission. It's nil without a config or repository.
+func Representer(logger log.Logger, cfg *service.ODFIRepresentment, repo representment.Repository, subs submissions.Repository, publisher *pubsub.Topic, svc events.Emitter) *representer {
+	if cfg == nil || repo == nil || subs == nil {
+		return nil
+	}
+	return &representer{
+		logger:      logger,
+		cfg:         cfg,
+		repo:        repo,
+		submissions: subs,
+		publisher:   publisher,
+		svc:         svc,
+		now:         time.Now,
+	}
+}
+
+func (pc *representer) Type() string {
+	return "representment"
+}
+
+func (pc *representer) Name() string {
+	return "representment"
+}
+
+func (pc *representer) Handle(file File) error {
+	if file.ACHFile == nil {
+		return nil
+	}
+	var el base.ErrorList
+	for i := range file.ACHFile.ReturnEntries {
+		bh := file.ACHFile.ReturnEntries[i].GetHeader()
+		for _, entry := range file.ACHFile.ReturnEntries[i].GetEntries() {
+			if !representment.ReturnedDebit(entry) || !pc.cfg.Retries(entry.Addenda99.ReturnCode) {
+				continue
+			}
+			if err := pc.schedule(file.ACHFile, bh, entry); err != nil {
+				el.Add(err)
+			}
+		}
+	}
+	if el.Empty() {
+		return nil
+	}
+	return el
+}
+
+// schedule saves the next attempt of a returned entry, or sends an exhausted event when there are
+// no attempts left
+func (pc *representer) schedule(returns *ach.File, bh *ach.BatchHeader, entry *ach.EntryDetail) error {
+	traceNumber := entry.Addenda99.OriginalTrace
+	logger := pc.logger.With(log.Fields{
+		"trace_number": log.String(traceNumber),
+	})
+	sub, err := pc.submissions.FindTraceNumber(traceNumber)
+	if err != nil {
+		return fmt.Errorf("finding submission of %s: %v", traceNumber, err)
+	}
+	if sub == nil {
+		logger.Warn().Log("odfi: unable to re-present return without a submission")
+		return nil
+	}
+
+	// A submission of an earlier attempt continues its count
+	rep := &representment.Representment{
+		ID:                  base.ID(),
+		ShardKey:            sub.ShardKey,
+		OriginalTraceNumber: traceNumber,
+		ReturnCode:          entry.Addenda99.ReturnCode,
+		Attempt:             1,
+		Metadata:            sub.Metadata,
+	}
+	previous, err := pc.repo.Get(sub.FileID)
+	if err != nil {
+		return err
+	}
+	if previous != nil {
+		rep.OriginalTraceNumber = previous.OriginalTraceNumber
+		rep.Attempt = previous.Attempt + 1
+	}
+	if rep.Attempt > pc.cfg.Attempts() {
+		logger.Logf("odfi: returned entry has no re-presentments left after %d attempts", previous.Attempt)
+		return pc.sendEvent(previous, representment.StatusExhausted, rep.ReturnCode)
+	}
+
+	rep.ScheduledFor = pc.now().Add(pc.cfg.Interval(rep.Attempt))
+	seq, err := representment.Sequence()
+	if err != nil {
+		return err
+	}
+	file, err := representment.Build(returns, bh, entry, rep.ScheduledFor, seq)
+	if err != nil {
+		return fmt.Errorf("building representment of %s: %v", traceNumber, err)
+	}
+	rep.Contents, err = representment.Encode(file)
+	if err != nil {
+		return err
+	}
+	created, err := pc.repo.Create(rep)
+	if err != nil || !created {
+		return err
+	}
+	logger.With(log.Fields{
+		"representment_id": log.String(rep.ID),
+		"shard_key":        log.String(rep.ShardKey),
+	}).Logf("odfi: scheduled re-presentment %d of %s for %s", rep.Attempt, rep.ReturnCode, rep.ScheduledFor.Format(time.RFC3339))
+
+	return pc.sendEvent(rep, representment.StatusScheduled, rep.ReturnCode)
+}
+
+// AfterScan submits the re-presentments which are due
+func (pc *representer) AfterScan() error {
+	due, err := pc.repo.Due(pc.now())
+	if err != nil {
+		return err
+	}
+	var el base.ErrorList
+	for i := range due {
+		if err := pc.submit(due[i]); err != nil {
+			el.Add(err)
+		}
+	}
+	if el.Empty() {
+		return nil
+	}
+	return el
+}
+
+func (pc *representer) submit(rep *representment.Representment) error {
+	// Another instance may have submitted the entry already
+	taken, err := pc.repo.Submit(rep.ID)
+	if err != nil || !taken {
+		return err
+	}
+	if err := pc.publish(rep); err != nil {
+		if rerr := pc.repo.Release(rep.ID); rerr != nil {
+			pc.logger.Warn().LogErrorf("odfi: problem releasing representment %s: %v", rep.ID, rerr)
+		}
+		return fmt.Errorf("submitting representment %s: %v", rep.ID, err)
+	}
+	pc.logger.With(log.Fields{
+		"representment_id": log.String(rep.ID),
+		"shard_key":        log.String(rep.ShardKey),
+	}).Logf("odfi: submitted re-presentment %d of %s", rep.Attempt, rep.OriginalTraceNumber)
+
+	return pc.sendEvent(rep, representment.StatusSubmitted, rep.ReturnCode)
+}
+
+func (pc *representer) publish(rep *representment.Representment) error {
+	file, err := rep.File()
+	if err != nil {
+		return err
+	}
+	bs, err := compliance.Protect(nil, models.Event{
+		Event: incoming.ACHFile{
+			FileID:   rep.ID,
+			ShardKey: rep.ShardKey,
+			File:     file,
+			Metadata: rep.Metadata,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to protect incoming file event: %v", err)
+	}
+
+	meta := make(map[string]string)
+	meta["fileID"] = rep.ID
+	meta["shardKey"] = rep.ShardKey
+
+	return pc.publisher.Send(context.Background(), &pubsub.Message{
+		Body:     bs,
+		Metadata: meta,
+	})
+}
+
+func (pc *representer) sendEvent(rep *representment.Representment, status, returnCode string) error {
+	if pc.svc == nil {
+		return nil
+	}
+	err := events.Deliver(pc.svc, models.Event{Event: models.RepresentmentUpdated{
+		RepresentmentID:     rep.ID,
+		ShardKey:            rep.ShardKey,
+		OriginalTraceNumber: rep.OriginalTraceNumber,
+		Attempt:             rep.Attempt,
+		Status:              status,
+		ReturnCode:          returnCode,
+		ScheduledFor:        rep.ScheduledFor,
+		UpdatedAt:           pc.now().UTC().Truncate(time.Second),
+		Metadata:            rep.Metadata,
+	}})
+	if err != nil {
+		return fmt.Errorf("sending RepresentmentUpdated event: %v", err)
+	}
+	return nil
+}
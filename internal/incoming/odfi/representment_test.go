@@ -0,0 +1,159 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/representment"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/submissions"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/achgateway/pkg/rdfi"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+// returnAll returns every entry of file with code, read like files downloaded from the ODFI
+func returnAll(t *testing.T, file *ach.File, code string) *ach.File {
+	t.Helper()
+
+	returned, err := rdfi.Return(file, rdfi.Options{Code: code})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, ach.NewWriter(&buf).Write(returned))
+	out, err := ach.NewReader(&buf).Read()
+	require.NoError(t, err)
+	return &out
+}
+
+func TestRepresenter(t *testing.T) {
+	require.Nil(t, Representer(log.NewNopLogger(), nil, nil, nil, nil, nil))
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := representment.NewRepository(db.DB)
+	subs := submissions.NewRepository(db.DB)
+	topic, sub := streamtest.InmemStream(t)
+
+	debit, err := ach.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	metadata := map[string]string{"batchID": "abc123"}
+	require.NoError(t, subs.Record(submissions.Submission{
+		ShardName: "live",
+		ShardKey:  "live",
+		FileID:    "original",
+		Metadata:  metadata,
+	}, []string{debit.Batches[0].GetEntries()[0].TraceNumber}))
+
+	cfg := &service.ODFIRepresentment{
+		Intervals: []time.Duration{24 * time.Hour, 72 * time.Hour},
+	}
+	emitter := &recordingEmitter{}
+	pc := Representer(log.NewNopLogger(), cfg, repo, subs, topic, emitter)
+	var _ ScanProcessor = pc
+
+	now := time.Now()
+	pc.now = func() time.Time { return now }
+
+	// Other return codes aren't re-presented
+	require.NoError(t, pc.Handle(File{ACHFile: returnAll(t, debit, "R02")}))
+	require.Empty(t, emitter.events)
+
+	returns := returnAll(t, debit, "R01")
+	require.NoError(t, pc.Handle(File{ACHFile: returns}))
+	require.Len(t, emitter.events, 1)
+	scheduled, ok := emitter.events[0].Event.(models.RepresentmentUpdated)
+	require.True(t, ok)
+	require.Equal(t, representment.StatusScheduled, scheduled.Status)
+	require.Equal(t, "live", scheduled.ShardKey)
+	require.Equal(t, 1, scheduled.Attempt)
+	require.Equal(t, "R01", scheduled.ReturnCode)
+	require.Equal(t, "abc123", scheduled.Metadata["batchID"])
+
+	// Processing the returns again doesn't schedule another attempt
+	require.NoError(t, pc.Handle(File{ACHFile: returns}))
+	require.Len(t, emitter.events, 1)
+
+	// Nothing is submitted until the first interval passes
+	require.NoError(t, pc.AfterScan())
+	require.Len(t, emitter.events, 1)
+
+	submit := func(at time.Time) *incoming.ACHFile {
+		t.Helper()
+
+		pc.now = func() time.Time { return at }
+		require.NoError(t, pc.AfterScan())
+
+		msg, err := sub.Receive(context.Background())
+		require.NoError(t, err)
+		msg.Ack()
+
+		var file incoming.ACHFile
+		require.NoError(t, models.ReadEvent(msg.Body, &file))
+		require.Equal(t, "live", file.ShardKey)
+		require.Equal(t, metadata, file.Metadata)
+
+		// Record the submission like the pipeline does
+		require.NoError(t, subs.Record(submissions.Submission{
+			ShardName: "live",
+			ShardKey:  file.ShardKey,
+			FileID:    file.FileID,
+			Metadata:  file.Metadata,
+		}, []string{file.File.Batches[0].GetEntries()[0].TraceNumber}))
+		return &file
+	}
+	first := submit(now.Add(25 * time.Hour))
+	require.Equal(t, scheduled.RepresentmentID, first.FileID)
+	require.Equal(t, representment.CompanyEntryDescription, first.File.Batches[0].GetHeader().CompanyEntryDescription)
+
+	require.Len(t, emitter.events, 2)
+	submitted := emitter.events[1].Event.(models.RepresentmentUpdated)
+	require.Equal(t, representment.StatusSubmitted, submitted.Status)
+
+	// A return of the re-presented entry schedules the next attempt
+	require.NoError(t, pc.Handle(File{ACHFile: returnAll(t, first.File, "R09")}))
+	require.Len(t, emitter.events, 3)
+	second := emitter.events[2].Event.(models.RepresentmentUpdated)
+	require.Equal(t, representment.StatusScheduled, second.Status)
+	require.Equal(t, 2, second.Attempt)
+	require.Equal(t, "R09", second.ReturnCode)
+	require.Equal(t, scheduled.OriginalTraceNumber, second.OriginalTraceNumber)
+	require.WithinDuration(t, now.Add(25*time.Hour+72*time.Hour), second.ScheduledFor, time.Second)
+
+	last := submit(now.Add(100 * time.Hour))
+	require.Equal(t, second.RepresentmentID, last.FileID)
+
+	// No attempts are left after the second re-presentment is returned
+	require.NoError(t, pc.Handle(File{ACHFile: returnAll(t, last.File, "R01")}))
+	require.Len(t, emitter.events, 5)
+	exhausted := emitter.events[4].Event.(models.RepresentmentUpdated)
+	require.Equal(t, representment.StatusExhausted, exhausted.Status)
+	require.Equal(t, 2, exhausted.Attempt)
+	require.Equal(t, "R01", exhausted.ReturnCode)
+}
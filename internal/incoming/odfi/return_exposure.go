@@ -0,0 +1,64 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"time"
+
+	"github.com/moov-io/achgateway/internal/exposure"
+	"github.com/moov-io/base/log"
+)
+
+type returnExposure struct {
+	logger log.Logger
+	repo   exposure.Repository
+}
+
+// ReturnExposure adds returned entries to the daily totals of the ODFI they were originated from.
+// It's nil without a repository.
+func ReturnExposure(logger log.Logger, repo exposure.Repository) *returnExposure {
+	if repo == nil {
+		return nil
+	}
+	return &returnExposure{
+		logger: logger,
+		repo:   repo,
+	}
+}
+
+func (pc *returnExposure) Type() string {
+	return "return exposure"
+}
+
+func (pc *returnExposure) Name() string {
+	return "return_exposure"
+}
+
+func (pc *returnExposure) Handle(file File) error {
+	if file.ACHFile == nil || len(file.ACHFile.ReturnEntries) == 0 {
+		return nil
+	}
+	totals := exposure.Returned(file.ACHFile)
+	if err := pc.repo.Record("", exposure.KindReturned, totals, time.Now()); err != nil {
+		return err
+	}
+	pc.logger.With(log.Fields{
+		"filepath": log.String(file.Filepath),
+	}).Logf("odfi: recorded returns exposure of %d ODFIs", len(totals))
+	return nil
+}
@@ -0,0 +1,67 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/exposure"
+	"github.com/moov-io/achgateway/pkg/rdfi"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReturnExposure(t *testing.T) {
+	require.Nil(t, ReturnExposure(log.NewNopLogger(), nil))
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := exposure.NewRepository(db.DB)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	returned, err := rdfi.Return(file, rdfi.Options{Code: "R01"})
+	require.NoError(t, err)
+
+	// Read the returns like files downloaded from the ODFI
+	var buf bytes.Buffer
+	require.NoError(t, ach.NewWriter(&buf).Write(returned))
+	returns, err := ach.NewReader(&buf).Read()
+	require.NoError(t, err)
+
+	pc := ReturnExposure(log.NewNopLogger(), repo)
+	require.NoError(t, pc.Handle(File{ACHFile: &returns}))
+
+	// Files without returns are skipped
+	require.NoError(t, pc.Handle(File{ACHFile: file}))
+
+	today := time.Now().Format("2006-01-02")
+	positions, err := repo.Positions(today, today, "", nil)
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	require.Equal(t, "07640125", positions[0].ODFI)
+	require.Equal(t, 10500, positions[0].ReturnedDebits)
+	require.Equal(t, 0, positions[0].UploadedDebits)
+	require.Equal(t, 10500, positions[0].NetExposure)
+}
@@ -20,10 +20,10 @@ package odfi
 import (
 	"fmt"
 	"path/filepath"
-	"strings"
 
 	"github.com/moov-io/achgateway/internal/events"
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/submissions"
 	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base/log"
 
@@ -42,6 +42,9 @@ type returnEmitter struct {
 	logger log.Logger
 	svc    events.Emitter
 	cfg    service.ODFIReturns
+
+	// submissions finds the submitted files of returned entries for their metadata
+	submissions submissions.Repository
 }
 
 func ReturnEmitter(logger log.Logger, cfg service.ODFIReturns, svc events.Emitter) *returnEmitter {
@@ -55,10 +58,27 @@ func ReturnEmitter(logger log.Logger, cfg service.ODFIReturns, svc events.Emitte
 	}
 }
 
+// WithSubmissions includes the metadata of submitted files whose entries are returned
+func (pc *returnEmitter) WithSubmissions(repo submissions.Repository) *returnEmitter {
+	if pc != nil {
+		pc.submissions = repo
+	}
+	return pc
+}
+
 func (pc *returnEmitter) Type() string {
 	return "return"
 }
 
+func (pc *returnEmitter) Name() string {
+	return "returns"
+}
+
+// MatchesPath reports if path contains the PathMatcher value, or if there is none
+func (pc *returnEmitter) MatchesPath(path string) bool {
+	return matchesPath(pc.cfg.PathMatcher, path)
+}
+
 func isReturnFile(file File) bool {
 	return len(file.ACHFile.ReturnEntries) >= 0
 }
@@ -69,7 +89,7 @@ func (pc *returnEmitter) Handle(file File) error {
 	}
 
 	// Ignore files if they don't contain the PathMatcher value
-	if pc.cfg.PathMatcher != "" && !strings.Contains(strings.ToLower(file.Filepath), pc.cfg.PathMatcher) {
+	if !pc.MatchesPath(file.Filepath) {
 		return nil // skip the file
 	}
 
@@ -107,15 +127,90 @@ func (pc *returnEmitter) Handle(file File) error {
 			}).Log(fmt.Sprintf("odfi: return batch %d entry %d code %s", i, j, returnCode.Code))
 		}
 	}
-	pc.sendEvent(msg)
-	return nil
+	msg.Submissions = pc.returnedSubmissions(file)
+	return pc.sendEvent(msg)
 }
 
-func (pc *returnEmitter) sendEvent(event interface{}) {
-	if pc.svc != nil {
-		err := pc.svc.Send(models.Event{Event: event})
-		if err != nil {
-			pc.logger.Logf("error sending return event: %v", err)
+// returnedSubmissions finds the submitted file of each returned entry which had metadata
+func (pc *returnEmitter) returnedSubmissions(file File) []models.ReturnedSubmission {
+	if pc.submissions == nil {
+		return nil
+	}
+	var out []models.ReturnedSubmission
+	found := make(map[string]int)
+	for i := range file.ACHFile.ReturnEntries {
+		for _, entry := range file.ACHFile.ReturnEntries[i].GetEntries() {
+			if entry.Addenda99 == nil {
+				continue
+			}
+			traceNumber := entry.Addenda99.OriginalTrace
+			sub, err := pc.submissions.FindTraceNumber(traceNumber)
+			if err != nil {
+				pc.logger.Warn().LogErrorf("odfi: problem finding submission of returned trace number %s: %v", traceNumber, err)
+				continue
+			}
+			if sub == nil {
+				continue
+			}
+			key := sub.ShardKey + "/" + sub.FileID
+			idx, exists := found[key]
+			if !exists {
+				idx = len(out)
+				found[key] = idx
+				out = append(out, models.ReturnedSubmission{
+					FileID:   sub.FileID,
+					ShardKey: sub.ShardKey,
+					Metadata: sub.Metadata,
+				})
+			}
+			out[idx].TraceNumbers = append(out[idx].TraceNumbers, traceNumber)
 		}
 	}
+	return out
+}
+
+// HandleCPA005 emits a CPA005ReturnFile event for the returned credits and debits in a
+// CPA Standard 005 file. InvalidDataElementID holds the reason each item was returned.
+func (pc *returnEmitter) HandleCPA005(file File) error {
+	if !pc.MatchesPath(file.Filepath) {
+		return nil // skip the file
+	}
+
+	msg := models.CPA005ReturnFile{
+		Filename: filepath.Base(file.Filepath),
+		File:     file.CPA005File,
+	}
+	for _, t := range file.CPA005File.Transactions {
+		if !t.IsReturn() {
+			continue
+		}
+		msg.Returns = append(msg.Returns, t)
+		returnEntriesProcessed.With(
+			"origin", file.CPA005File.Header.OriginatorID,
+			"destination", file.CPA005File.Header.DestinationDataCentre,
+			"code", t.InvalidDataElementID,
+		).Add(1)
+	}
+	if len(msg.Returns) == 0 {
+		return nil
+	}
+
+	pc.logger.With(log.Fields{
+		"origin":      log.String(file.CPA005File.Header.OriginatorID),
+		"destination": log.String(file.CPA005File.Header.DestinationDataCentre),
+	}).Logf("odfi: processing CPA-005 return file with %d returns", len(msg.Returns))
+
+	return pc.sendEvent(msg)
+}
+
+// sendEvent returns once the event is published so the file isn't deleted or marked processed
+// without it
+func (pc *returnEmitter) sendEvent(event interface{}) error {
+	if pc.svc == nil {
+		return nil
+	}
+	if err := events.Deliver(pc.svc, models.Event{Event: event}); err != nil {
+		return fmt.Errorf("sending return event: %v", err)
+	}
+	return nil
 }
@@ -18,10 +18,16 @@
 package odfi
 
 import (
+	"path/filepath"
 	"testing"
 
+	"github.com/moov-io/achgateway/internal/audittrail"
 	"github.com/moov-io/achgateway/internal/events"
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/submissions"
+	"github.com/moov-io/achgateway/pkg/cpa005"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/database"
 	"github.com/moov-io/base/log"
 
 	"github.com/stretchr/testify/require"
@@ -35,9 +41,89 @@ func TestReturns(t *testing.T) {
 		Webhook: &service.WebhookConfig{
 			Endpoint: "https://cb.moov.io/incoming",
 		},
-	})
+	}, nil)
 	require.NoError(t, err)
 
 	emitter := ReturnEmitter(log.NewNopLogger(), cfg, eventsService)
 	require.NotNil(t, emitter)
 }
+
+type recordingEmitter struct {
+	events []models.Event
+	err    error
+}
+
+func (e *recordingEmitter) Send(evt models.Event) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.events = append(e.events, evt)
+	return nil
+}
+
+func (e *recordingEmitter) Close() error {
+	return nil
+}
+
+func TestReturns__Submissions(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := submissions.NewRepository(db.DB)
+
+	sub := submissions.Submission{
+		ShardName: "live",
+		ShardKey:  "acme",
+		FileID:    "payroll-0601",
+		Metadata:  map[string]string{"batchID": "123"},
+	}
+	require.NoError(t, repo.Record(sub, []string{"273976368613175"}))
+
+	emitter := &recordingEmitter{}
+	returns := ReturnEmitter(log.NewNopLogger(), service.ODFIReturns{Enabled: true}, emitter).WithSubmissions(repo)
+	auditSaver := &AuditSaver{
+		storage:  &audittrail.MockStorage{},
+		hostname: "ftp.foo.com",
+	}
+
+	path := filepath.Join("testdata", "return.ach")
+	require.NoError(t, processFile(path, auditSaver, SetupProcessors(returns), nil))
+
+	require.Len(t, emitter.events, 1)
+	evt, ok := emitter.events[0].Event.(models.ReturnFile)
+	require.True(t, ok)
+	require.Len(t, evt.Submissions, 1)
+	require.Equal(t, models.ReturnedSubmission{
+		FileID:       "payroll-0601",
+		ShardKey:     "acme",
+		Metadata:     map[string]string{"batchID": "123"},
+		TraceNumbers: []string{"273976368613175"},
+	}, evt.Submissions[0])
+
+	// Disabled processors are still nil
+	require.Nil(t, ReturnEmitter(log.NewNopLogger(), service.ODFIReturns{}, emitter).WithSubmissions(repo))
+}
+
+func TestReturns__CPA005(t *testing.T) {
+	emitter := &recordingEmitter{}
+	returns := ReturnEmitter(log.NewNopLogger(), service.ODFIReturns{Enabled: true}, emitter)
+
+	// Processors without CPA-005 support are skipped
+	mock := &MockProcessor{}
+	auditSaver := &AuditSaver{
+		storage:  &audittrail.MockStorage{},
+		hostname: "ftp.foo.com",
+	}
+
+	path := filepath.Join("..", "..", "..", "pkg", "cpa005", "testdata", "returns.005")
+	require.NoError(t, processFile(path, auditSaver, SetupProcessors(mock, returns), nil))
+	require.Nil(t, mock.HandledFile)
+
+	require.Len(t, emitter.events, 1)
+	evt, ok := emitter.events[0].Event.(models.CPA005ReturnFile)
+	require.True(t, ok)
+	require.Equal(t, "returns.005", evt.Filename)
+	require.Len(t, evt.Returns, 2)
+	require.Equal(t, cpa005.ReturnedCredit, evt.Returns[0].RecordType)
+	require.Equal(t, "905", evt.Returns[0].InvalidDataElementID)
+	require.Equal(t, 2500, evt.Returns[1].Amount)
+}
@@ -21,14 +21,29 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/moov-io/achgateway/internal/alerting"
-	"github.com/moov-io/achgateway/internal/consul"
+	"github.com/moov-io/achgateway/internal/drain"
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/leadership"
 	"github.com/moov-io/achgateway/internal/service"
 	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base/admin"
 	"github.com/moov-io/base/log"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	scanDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Name:    "odfi_scan_duration_seconds",
+		Help:    "Seconds spent downloading and processing a shard's ODFI files",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600},
+	}, []string{"shard"})
 )
 
 type Scheduler interface {
@@ -48,14 +63,17 @@ type PeriodicScheduler struct {
 	shutdown       context.Context
 	shutdownFunc   context.CancelFunc
 
-	consul     *consul.Client
+	elector    leadership.Elector
+	drain      *drain.Coordinator
 	downloader Downloader
 	processors Processors
+	processed  ProcessedFiles
+	events     events.Emitter
 
 	alerters alerting.Alerters
 }
 
-func NewPeriodicScheduler(logger log.Logger, cfg *service.Config, consul *consul.Client, processors Processors) (Scheduler, error) {
+func NewPeriodicScheduler(logger log.Logger, cfg *service.Config, elector leadership.Elector, drainer *drain.Coordinator, processors Processors, processed ProcessedFiles, svc events.Emitter) (Scheduler, error) {
 	if cfg.Inbound.ODFI == nil {
 		return nil, errors.New("missing Inbound ODFI config")
 	}
@@ -79,9 +97,12 @@ func NewPeriodicScheduler(logger log.Logger, cfg *service.Config, consul *consul
 		uploadAgents:   cfg.Upload,
 		ticker:         time.NewTicker(cfg.Inbound.ODFI.Interval),
 		inboundTrigger: make(chan manuallyTriggeredInbound, 1),
-		consul:         consul,
+		elector:        elector,
+		drain:          drainer,
 		downloader:     dl,
-		processors:     processors,
+		processors:     processors.Ordered(cfg.Inbound.ODFI.Processors.Order),
+		processed:      processed,
+		events:         svc,
 		shutdown:       ctx,
 		shutdownFunc:   cancelFunc,
 		alerters:       alerters,
@@ -115,52 +136,107 @@ func (s *PeriodicScheduler) Start() error {
 }
 
 func (s *PeriodicScheduler) tickAll() error {
+	// Shards which share an upload agent are scanned in order, but each agent is
+	// scanned alongside the others (up to MaxConcurrentScans at once).
+	var agentIDs []string
+	shardsByAgent := make(map[string][]*service.Shard)
 	for _, shardName := range s.odfi.ShardNames {
 		shard := s.sharding.Find(shardName)
 		if shard == nil {
 			s.logger.Error().Logf("unable to find shard=%s", shardName)
 			continue
 		}
+		if _, exists := shardsByAgent[shard.UploadAgent]; !exists {
+			agentIDs = append(agentIDs, shard.UploadAgent)
+		}
+		shardsByAgent[shard.UploadAgent] = append(shardsByAgent[shard.UploadAgent], shard)
+	}
 
-		logger := s.logger.With(log.Fields{
-			"shard": log.String(shardName),
-		})
+	var limit chan struct{}
+	if s.odfi.MaxConcurrentScans > 0 {
+		limit = make(chan struct{}, s.odfi.MaxConcurrentScans)
+	}
 
-		// Attempt to acquire leadership prior to processing
-		leaderKey := fmt.Sprintf("achgateway/odfi/%s", shardName)
-		s.logger.Logf("attempting to acquire ODFI leadership for %s", leaderKey)
+	var wg sync.WaitGroup
+	for _, agentID := range agentIDs {
+		wg.Add(1)
+		go func(shards []*service.Shard) {
+			defer wg.Done()
 
-		// Acquire leadership for this shard
-		err := consul.AcquireLock(logger, s.consul, leaderKey)
-		if err != nil {
-			logger.Info().Logf("skipping ODFI processing: %v", err)
-		} else {
-			s.logger.Info().Logf("starting odfi periodic processing for %s", shard.Name)
-			err := s.tick(shard)
-			if err != nil {
-				// Push this alert outside achgateway
-				s.alertOnError(err)
-				s.logger.Warn().Logf("error with odfi periodic processing: %v", err)
-			} else {
-				s.logger.Info().Logf("finished odfi periodic processing for %s", shard.Name)
+			if limit != nil {
+				limit <- struct{}{}
+				defer func() { <-limit }()
 			}
-		}
+			for i := range shards {
+				s.tickShard(shards[i])
+			}
+		}(shardsByAgent[agentID])
 	}
+	wg.Wait()
+
 	return nil
 }
 
+func (s *PeriodicScheduler) tickShard(shard *service.Shard) {
+	logger := s.logger.With(log.Fields{
+		"shard": log.String(shard.Name),
+	})
+
+	// Files are downloaded on the next tick after the agent's maintenance window
+	if until, ok := s.uploadAgents.Find(shard.UploadAgent).MaintenanceUntil(time.Now()); ok {
+		logger.Info().Logf("skipping ODFI processing while %s is in a maintenance window until %s", shard.UploadAgent, until.Format(time.RFC3339))
+		return
+	}
+
+	// Draining instances leave scans to the replica taking over leadership
+	done, err := s.drain.Begin()
+	if err != nil {
+		logger.Info().Logf("skipping ODFI processing: %v", err)
+		return
+	}
+	defer done()
+
+	// Attempt to acquire leadership prior to processing
+	leaderKey := fmt.Sprintf("achgateway/odfi/%s", shard.Name)
+	s.logger.Logf("attempting to acquire ODFI leadership for %s", leaderKey)
+
+	// Acquire leadership for this shard
+	err = leadership.AcquireLock(s.elector, leaderKey)
+	if err != nil {
+		logger.Info().Logf("skipping ODFI processing: %v", err)
+		return
+	}
+
+	s.logger.Info().Logf("starting odfi periodic processing for %s", shard.Name)
+	start := time.Now()
+	err = s.tick(shard)
+	scanDuration.With("shard", shard.Name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		// Push this alert outside achgateway
+		s.alertOnError(err)
+		s.logger.Warn().Logf("error with odfi periodic processing: %v", err)
+	} else {
+		s.logger.Info().Logf("finished odfi periodic processing for %s", shard.Name)
+	}
+}
+
 func (s *PeriodicScheduler) tick(shard *service.Shard) error {
 	agent, err := upload.New(s.logger, s.uploadAgents, shard.UploadAgent)
 	if err != nil {
 		return fmt.Errorf("agent: %v", err)
 	}
 	s.logger.Logf("start retrieving and processing of inbound files in %s", agent.Hostname())
+	startedAt := time.Now()
 
 	// Download and process files
 	dl, err := s.downloader.CopyFilesFromRemote(agent)
 	if err != nil {
 		return fmt.Errorf("ERROR: problem copying files: %v", err)
 	}
+	if dl.report != nil {
+		dl.report.processed = s.processed
+		dl.report.shard = shard.Name
+	}
 
 	// Setup presistor files into our configured audit trail
 	auditSaver, err := newAuditSaver(agent.Hostname(), s.odfi.Audit)
@@ -169,15 +245,21 @@ func (s *PeriodicScheduler) tick(shard *service.Shard) error {
 	}
 
 	// Run each processor over the files
-	if err := ProcessFiles(dl, auditSaver, s.processors); err != nil {
-		return fmt.Errorf("ERROR: processing files: %v", err)
+	processors := s.processors.ForShard(shard.ODFIProcessors)
+	processErr := ProcessFiles(dl, auditSaver, processors)
+	if err := processors.AfterScan(); err != nil {
+		s.alertOnError(err)
+		s.logger.Warn().Logf("problem after processing files: %v", err)
 	}
+	s.sendScanSummary(shard, agent, dl, startedAt)
+	if processErr != nil {
+		return fmt.Errorf("ERROR: processing files: %v", processErr)
+	}
+	upload.CommitListings(agent)
 
 	// Start our cleanup routines
-	if !s.odfi.Storage.KeepRemoteFiles {
-		if err := Cleanup(s.logger, agent, dl); err != nil {
-			return fmt.Errorf("ERROR: deleting remote files: %v", err)
-		}
+	if err := s.afterProcessing(shard, agent, dl); err != nil {
+		return err
 	}
 	if s.odfi.Storage.RemoveZeroByteFiles {
 		if err := CleanupEmptyFiles(s.logger, agent, dl); err != nil {
@@ -190,6 +272,64 @@ func (s *PeriodicScheduler) tick(shard *service.Shard) error {
 	return dl.deleteEmptyDirs(agent)
 }
 
+// afterProcessing deletes or moves the processed files on the remote server following the
+// agent's AfterProcessing config, otherwise files are deleted unless KeepRemoteFiles is set.
+func (s *PeriodicScheduler) afterProcessing(shard *service.Shard, agent upload.Agent, dl *downloadedFiles) error {
+	action := service.AfterProcessingDelete
+	if s.odfi.Storage.KeepRemoteFiles {
+		action = service.AfterProcessingNone
+	}
+	cfg := s.uploadAgents.Find(shard.UploadAgent)
+	if cfg != nil && cfg.AfterProcessing != nil {
+		action = cfg.AfterProcessing.Action
+	}
+
+	switch action {
+	case service.AfterProcessingDelete:
+		if err := Cleanup(s.logger, agent, dl); err != nil {
+			return fmt.Errorf("ERROR: deleting remote files: %v", err)
+		}
+	case service.AfterProcessingMove:
+		dir, err := upload.RenderPath(cfg.AfterProcessing.Directory, upload.PathData{
+			RoutingNumber: cfg.Paths.RoutingNumber,
+			Now:           time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("ERROR: archive directory: %v", err)
+		}
+		if err := Archive(s.logger, agent, dl, dir); err != nil {
+			return fmt.Errorf("ERROR: moving remote files: %v", err)
+		}
+	}
+	return nil
+}
+
+// sendScanSummary publishes which files the scan downloaded and skipped
+func (s *PeriodicScheduler) sendScanSummary(shard *service.Shard, agent upload.Agent, dl *downloadedFiles, startedAt time.Time) {
+	skipped := dl.report.Skipped()
+	if len(skipped) > 0 {
+		s.logger.With(log.Fields{
+			"shard": log.String(shard.Name),
+		}).Logf("skipped %d files during ODFI scan", len(skipped))
+	}
+	if !s.odfi.PublishScanSummary || s.events == nil {
+		return
+	}
+	err := s.events.Send(models.Event{
+		Event: models.ODFIScanCompleted{
+			ShardName:   shard.Name,
+			Hostname:    agent.Hostname(),
+			Downloaded:  dl.downloaded,
+			Skipped:     skipped,
+			StartedAt:   startedAt,
+			CompletedAt: time.Now(),
+		},
+	})
+	if err != nil {
+		s.logger.LogErrorf("problem sending ODFI scan summary: %v", err)
+	}
+}
+
 func (s *PeriodicScheduler) alertOnError(err error) {
 	if s == nil {
 		return
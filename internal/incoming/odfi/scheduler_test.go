@@ -18,10 +18,15 @@
 package odfi
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
 	"github.com/moov-io/base/log"
 
 	"github.com/stretchr/testify/require"
@@ -56,7 +61,7 @@ func TestScheduler(t *testing.T) {
 	}
 
 	processors := SetupProcessors(&MockProcessor{})
-	schd, err := NewPeriodicScheduler(cfg.Logger, cfg, nil, processors)
+	schd, err := NewPeriodicScheduler(cfg.Logger, cfg, nil, nil, processors, nil, nil)
 	require.NoError(t, err)
 	require.NotNil(t, schd)
 
@@ -73,3 +78,207 @@ func TestScheduler(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestScheduler__AfterProcessing(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "returned", "2024-05-01"), 0755))
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "return-WEB.ach"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "returned", "2024-05-01", "RET.ach"), bs, 0600))
+
+	cfg := &service.Config{
+		Logger: log.NewNopLogger(),
+		Inbound: service.Inbound{
+			ODFI: &service.ODFIFiles{
+				Interval:   10 * time.Second,
+				ShardNames: []string{"archived"},
+				Storage: service.ODFIStorage{
+					Directory:             t.TempDir(),
+					CleanupLocalDirectory: true,
+				},
+			},
+		},
+		Upload: service.UploadAgents{
+			Agents: []service.UploadAgent{
+				{
+					ID: "after-processing-test",
+					Filesystem: &service.Filesystem{
+						Directory: dir,
+					},
+					Paths: service.UploadPaths{
+						Inbound:        "inbound",
+						Outbound:       "outbound",
+						Reconciliation: "reconciliation",
+						Return:         "returned",
+						Depth:          1,
+					},
+					AfterProcessing: &service.AfterProcessing{
+						Action:    service.AfterProcessingMove,
+						Directory: "archive/{{ yyyy }}",
+					},
+				},
+			},
+		},
+	}
+
+	processors := SetupProcessors(&MockProcessor{})
+	schd, err := NewPeriodicScheduler(cfg.Logger, cfg, nil, nil, processors, nil, nil)
+	require.NoError(t, err)
+	ss, ok := schd.(*PeriodicScheduler)
+	require.True(t, ok)
+
+	shard := &service.Shard{
+		Name:        "archived",
+		UploadAgent: "after-processing-test",
+	}
+	require.NoError(t, ss.tick(shard))
+
+	_, err = os.Stat(filepath.Join(dir, "returned", "2024-05-01", "RET.ach"))
+	require.True(t, os.IsNotExist(err))
+
+	archived := filepath.Join(dir, "archive", time.Now().Format("2006"), "returned", "2024-05-01", "RET.ach")
+	moved, err := os.ReadFile(archived)
+	require.NoError(t, err)
+	require.Equal(t, bs, moved)
+}
+
+type concurrentDownloader struct {
+	t *testing.T
+
+	mu      sync.Mutex
+	current int
+	max     int
+	scanned int
+}
+
+func (dl *concurrentDownloader) CopyFilesFromRemote(agent upload.Agent) (*downloadedFiles, error) {
+	dl.mu.Lock()
+	dl.current++
+	if dl.current > dl.max {
+		dl.max = dl.current
+	}
+	dl.scanned++
+	dl.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	dl.mu.Lock()
+	dl.current--
+	dl.mu.Unlock()
+
+	return &downloadedFiles{dir: dl.t.TempDir()}, nil
+}
+
+func TestScheduler__ConcurrentAgents(t *testing.T) {
+	var agents []service.UploadAgent
+	var shards []service.Shard
+	var shardNames []string
+	for i := 0; i < 3; i++ {
+		agentID := fmt.Sprintf("concurrent-%d", i)
+		agents = append(agents, service.UploadAgent{
+			ID:   agentID,
+			Mock: &service.MockAgent{},
+		})
+		// two shards for each agent
+		for j := 0; j < 2; j++ {
+			name := fmt.Sprintf("%s-shard-%d", agentID, j)
+			shards = append(shards, service.Shard{
+				Name:        name,
+				UploadAgent: agentID,
+			})
+			shardNames = append(shardNames, name)
+		}
+	}
+
+	scan := func(t *testing.T, maxConcurrentScans int) *concurrentDownloader {
+		t.Helper()
+
+		cfg := &service.Config{
+			Inbound: service.Inbound{
+				ODFI: &service.ODFIFiles{
+					Interval:           10 * time.Second,
+					ShardNames:         shardNames,
+					MaxConcurrentScans: maxConcurrentScans,
+					Storage: service.ODFIStorage{
+						Directory:             t.TempDir(),
+						CleanupLocalDirectory: true,
+						KeepRemoteFiles:       true,
+					},
+				},
+			},
+			Sharding: service.Sharding{
+				Shards: shards,
+			},
+			Upload: service.UploadAgents{
+				Agents: agents,
+			},
+		}
+
+		schd, err := NewPeriodicScheduler(log.NewNopLogger(), cfg, nil, nil, SetupProcessors(&MockProcessor{}), nil, nil)
+		require.NoError(t, err)
+
+		ss, ok := schd.(*PeriodicScheduler)
+		require.True(t, ok)
+
+		dl := &concurrentDownloader{t: t}
+		ss.downloader = dl
+
+		require.NoError(t, ss.tickAll())
+		require.Equal(t, 6, dl.scanned)
+		return dl
+	}
+
+	t.Run("unbounded", func(t *testing.T) {
+		// Shards sharing an agent are scanned one after another
+		dl := scan(t, 0)
+		require.Equal(t, 3, dl.max)
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		dl := scan(t, 2)
+		require.Equal(t, 2, dl.max)
+	})
+}
+
+func TestScheduler__MaintenanceWindow(t *testing.T) {
+	now := time.Now().UTC()
+	cfg := &service.Config{
+		Inbound: service.Inbound{
+			ODFI: &service.ODFIFiles{
+				Interval:   10 * time.Second,
+				ShardNames: []string{"maintenance"},
+				Storage: service.ODFIStorage{
+					Directory: t.TempDir(),
+				},
+			},
+		},
+		Sharding: service.Sharding{
+			Shards: []service.Shard{{Name: "maintenance", UploadAgent: "mock"}},
+		},
+		Upload: service.UploadAgents{
+			Agents: []service.UploadAgent{
+				{
+					ID:   "mock",
+					Mock: &service.MockAgent{},
+					MaintenanceWindows: []service.MaintenanceWindow{
+						{
+							Start:    now.Add(-time.Minute).Format("15:04"),
+							Duration: time.Hour,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	schd, err := NewPeriodicScheduler(log.NewNopLogger(), cfg, nil, nil, SetupProcessors(&MockProcessor{}), nil, nil)
+	require.NoError(t, err)
+	ss, ok := schd.(*PeriodicScheduler)
+	require.True(t, ok)
+
+	dl := &concurrentDownloader{t: t}
+	ss.downloader = dl
+
+	require.NoError(t, ss.tickAll())
+	require.Equal(t, 0, dl.scanned)
+}
@@ -0,0 +1,150 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/pkg/models"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// SkipDirectory is a directory inside one of the agent's paths
+	SkipDirectory = upload.SkipDirectory
+
+	// SkipZeroByte is an empty file, which is downloaded but not processed
+	SkipZeroByte = "zero_byte"
+
+	// SkipPatternExcluded is a file a processor's PathMatcher didn't match
+	SkipPatternExcluded = "pattern_excluded"
+
+	// SkipDuplicate is a file with the same contents as one processed by an earlier scan
+	SkipDuplicate = "duplicate"
+)
+
+var (
+	filesSkipped = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "inbound_files_skipped",
+		Help: "Counter of remote files skipped while downloading or processing ODFI files",
+	}, []string{"reason"})
+)
+
+// PathMatcher is implemented by processors which only handle files whose path matches
+// their configuration. Other files are reported as skipped.
+type PathMatcher interface {
+	MatchesPath(path string) bool
+}
+
+func matchesPath(matcher, path string) bool {
+	return matcher == "" || strings.Contains(strings.ToLower(path), matcher)
+}
+
+// scanReport collects the files skipped during one scan. A nil scanReport records nothing.
+type scanReport struct {
+	// dir is where files were downloaded, which is removed from skipped paths
+	dir string
+
+	// processed remembers files across scans of shard, when set
+	processed ProcessedFiles
+	shard     string
+
+	mu      sync.Mutex
+	skipped []models.SkippedFile
+}
+
+// ProcessedFiles remembers which files were processed so the same file downloaded again,
+// such as after it's re-uploaded or failed to be deleted, is skipped.
+type ProcessedFiles interface {
+	Contains(member string) (bool, error)
+	Add(member string) (bool, error)
+}
+
+func (r *scanReport) processedKey(bs []byte) string {
+	return fmt.Sprintf("%s:%x", r.shard, sha256.Sum256(bs))
+}
+
+// duplicate returns true and skips path when its contents were already processed
+func (r *scanReport) duplicate(path string, bs []byte) (bool, error) {
+	if r == nil || r.processed == nil || len(bs) == 0 {
+		return false, nil
+	}
+	found, err := r.processed.Contains(r.processedKey(bs))
+	if err != nil {
+		return false, fmt.Errorf("checking processed files: %v", err)
+	}
+	if found {
+		r.skip(path, SkipDuplicate, "")
+	}
+	return found, nil
+}
+
+func (r *scanReport) markProcessed(bs []byte) error {
+	if r == nil || r.processed == nil || len(bs) == 0 {
+		return nil
+	}
+	if _, err := r.processed.Add(r.processedKey(bs)); err != nil {
+		return fmt.Errorf("remembering processed file: %v", err)
+	}
+	return nil
+}
+
+func (r *scanReport) skip(path, reason, processor string) {
+	if r == nil {
+		return
+	}
+	filesSkipped.With("reason", reason).Add(1)
+
+	if r.dir != "" {
+		if rel, err := filepath.Rel(r.dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.skipped = append(r.skipped, models.SkippedFile{
+		Path:      filepath.ToSlash(path),
+		Reason:    reason,
+		Processor: processor,
+	})
+}
+
+// skipRemote records the files an agent didn't download
+func (r *scanReport) skipRemote(files []upload.SkippedFile) {
+	for i := range files {
+		r.skip(strings.TrimPrefix(files[i].Path, "/"), files[i].Reason, "")
+	}
+}
+
+func (r *scanReport) Skipped() []models.SkippedFile {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]models.SkippedFile(nil), r.skipped...)
+}
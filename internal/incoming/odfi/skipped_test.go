@@ -0,0 +1,129 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/redis"
+	"github.com/moov-io/achgateway/internal/redis/redistest"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessFiles__Skipped(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "inbound"), 0777))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "inbound", "empty.ach"), nil, 0600))
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "inbound", "ppd-debit.ach"), bs, 0600))
+
+	dl := &downloadedFiles{dir: dir, report: &scanReport{dir: dir}}
+	dl.report.skipRemote([]upload.SkippedFile{{Path: "/inbound/archive", Reason: upload.SkipDirectory}})
+
+	mock := &MockProcessor{}
+	returns := ReturnEmitter(log.NewNopLogger(), service.ODFIReturns{Enabled: true, PathMatcher: "ret_"}, nil)
+	require.NoError(t, ProcessFiles(dl, nil, SetupProcessors(mock, returns)))
+
+	// The valid file is still processed by the mock
+	require.NotNil(t, mock.HandledFile)
+	require.Equal(t, filepath.Join(dir, "inbound", "ppd-debit.ach"), mock.HandledFile.Filepath)
+
+	require.ElementsMatch(t, []models.SkippedFile{
+		{Path: "inbound/archive", Reason: SkipDirectory},
+		{Path: "inbound/empty.ach", Reason: SkipZeroByte},
+		{Path: "inbound/ppd-debit.ach", Reason: SkipPatternExcluded, Processor: "return"},
+	}, dl.report.Skipped())
+
+	// A nil report records nothing
+	var report *scanReport
+	report.skip("inbound/empty.ach", SkipZeroByte, "")
+	require.Nil(t, report.Skipped())
+}
+
+func TestProcessFiles__Duplicate(t *testing.T) {
+	client, _ := redistest.NewClient(t, redis.Config{})
+	processed := client.Set("odfi-processed", time.Hour)
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	scan := func() (*MockProcessor, *downloadedFiles) {
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "inbound"), 0777))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "inbound", "ppd-debit.ach"), bs, 0600))
+
+		dl := &downloadedFiles{dir: dir, report: &scanReport{dir: dir, processed: processed, shard: "testing"}}
+		mock := &MockProcessor{}
+		require.NoError(t, ProcessFiles(dl, nil, SetupProcessors(mock)))
+		return mock, dl
+	}
+
+	mock, dl := scan()
+	require.NotNil(t, mock.HandledFile)
+	require.Empty(t, dl.report.Skipped())
+
+	// The same file downloaded again is skipped
+	mock, dl = scan()
+	require.Nil(t, mock.HandledFile)
+	require.Equal(t, []models.SkippedFile{
+		{Path: "inbound/ppd-debit.ach", Reason: SkipDuplicate},
+	}, dl.report.Skipped())
+
+	// Each shard remembers its own files
+	other := &scanReport{processed: processed, shard: "other"}
+	dup, err := other.duplicate("ppd-debit.ach", bs)
+	require.NoError(t, err)
+	require.False(t, dup)
+}
+
+func TestPeriodicScheduler__sendScanSummary(t *testing.T) {
+	emitter := &recordingEmitter{}
+	schd := &PeriodicScheduler{
+		logger: log.NewNopLogger(),
+		odfi:   &service.ODFIFiles{},
+		events: emitter,
+	}
+	shard := &service.Shard{Name: "testing"}
+	dl := &downloadedFiles{downloaded: 3, report: &scanReport{}}
+	dl.report.skip("returned/2022", SkipDirectory, "")
+
+	// Summaries are only sent when enabled
+	schd.sendScanSummary(shard, &upload.MockAgent{}, dl, time.Now())
+	require.Empty(t, emitter.events)
+
+	schd.odfi.PublishScanSummary = true
+	schd.sendScanSummary(shard, &upload.MockAgent{}, dl, time.Now())
+	require.Len(t, emitter.events, 1)
+
+	scan, ok := emitter.events[0].Event.(models.ODFIScanCompleted)
+	require.True(t, ok)
+	require.Equal(t, "testing", scan.ShardName)
+	require.Equal(t, "hostname", scan.Hostname)
+	require.Equal(t, 3, scan.Downloaded)
+	require.Equal(t, []models.SkippedFile{{Path: "returned/2022", Reason: SkipDirectory}}, scan.Skipped)
+}
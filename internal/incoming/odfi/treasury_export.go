@@ -0,0 +1,137 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/incoming/odfi/export"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+)
+
+type treasuryExporter struct {
+	logger       log.Logger
+	svc          events.Emitter
+	cfg          service.ODFIExport
+	recon        service.ODFIReconciliation
+	returns      service.ODFIReturns
+	uploadAgents service.UploadAgents
+}
+
+// TreasuryExporter writes the reconciliation and return entries of ODFI files as BAI2 or CSV and
+// uploads or publishes them. Files are matched the same way as the Reconciliation and Returns processors.
+func TreasuryExporter(logger log.Logger, cfg service.ODFIProcessors, uploadAgents service.UploadAgents, svc events.Emitter) (*treasuryExporter, error) {
+	if cfg.Export == nil {
+		return nil, nil
+	}
+	if err := export.ValidateColumns(cfg.Export.Columns); err != nil {
+		return nil, err
+	}
+	return &treasuryExporter{
+		logger:       logger,
+		svc:          svc,
+		cfg:          *cfg.Export,
+		recon:        cfg.Reconciliation,
+		returns:      cfg.Returns,
+		uploadAgents: uploadAgents,
+	}, nil
+}
+
+func (pc *treasuryExporter) Type() string {
+	return "TreasuryExport"
+}
+
+func (pc *treasuryExporter) Name() string {
+	return "export"
+}
+
+func (pc *treasuryExporter) Handle(file File) error {
+	if file.ACHFile == nil {
+		return errors.New("nil ach.File")
+	}
+
+	var records []export.Record
+	if isReconciliationFile(pc.recon, file) {
+		records = append(records, export.Records(export.KindReconciliation, file.ACHFile.Batches)...)
+	}
+	if pc.returns.Enabled && (pc.returns.PathMatcher == "" || strings.Contains(strings.ToLower(file.Filepath), pc.returns.PathMatcher)) {
+		records = append(records, export.Records(export.KindReturn, file.ACHFile.ReturnEntries)...)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	filename := strings.TrimSuffix(filepath.Base(file.Filepath), filepath.Ext(file.Filepath))
+	format := strings.ToLower(pc.cfg.Format)
+	switch format {
+	case service.ExportFormatBAI2:
+		filename += ".bai"
+		if err := export.WriteBAI2(&buf, pc.cfg.BAI2, records, time.Now()); err != nil {
+			return fmt.Errorf("writing bai2: %v", err)
+		}
+	case service.ExportFormatCSV:
+		filename += ".csv"
+		if err := export.WriteCSV(&buf, pc.cfg.Columns, records); err != nil {
+			return fmt.Errorf("writing csv: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown format %q", pc.cfg.Format)
+	}
+
+	pc.logger.With(log.Fields{
+		"filepath": log.String(file.Filepath),
+		"export":   log.String(filename),
+		"records":  log.Int(len(records)),
+	}).Log("odfi: exporting treasury file")
+
+	if pc.cfg.UploadAgent != "" {
+		agent, err := upload.New(pc.logger, pc.uploadAgents, pc.cfg.UploadAgent)
+		if err != nil {
+			return fmt.Errorf("export agent: %v", err)
+		}
+		err = agent.UploadFile(upload.File{
+			Filename: filename,
+			Contents: upload.NewContents(buf.Bytes()),
+		})
+		if err != nil {
+			return fmt.Errorf("uploading %s: %v", filename, err)
+		}
+	}
+	if pc.cfg.Publish && pc.svc != nil {
+		err := events.Deliver(pc.svc, models.Event{Event: models.TreasuryExportFile{
+			Filename:       filename,
+			SourceFilename: filepath.Base(file.Filepath),
+			Format:         format,
+			Contents:       buf.Bytes(),
+		}})
+		if err != nil {
+			return fmt.Errorf("sending treasury export event: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package odfi
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreasuryExporter(t *testing.T) {
+	exporter, err := TreasuryExporter(log.NewNopLogger(), service.ODFIProcessors{}, service.UploadAgents{}, nil)
+	require.NoError(t, err)
+	require.Nil(t, exporter)
+	require.Empty(t, SetupProcessors(exporter))
+
+	_, err = TreasuryExporter(log.NewNopLogger(), service.ODFIProcessors{
+		Export: &service.ODFIExport{Format: "csv", Columns: []string{"balance"}},
+	}, service.UploadAgents{}, nil)
+	require.EqualError(t, err, `unknown column "balance"`)
+}
+
+func TestTreasuryExporter__CSV(t *testing.T) {
+	emitter := &recordingEmitter{}
+	exporter, err := TreasuryExporter(log.NewNopLogger(), service.ODFIProcessors{
+		Returns: service.ODFIReturns{Enabled: true},
+		Export: &service.ODFIExport{
+			Format:  "csv",
+			Columns: []string{"kind", "traceNumber", "amountCents", "returnCode"},
+			Publish: true,
+		},
+	}, service.UploadAgents{}, emitter)
+	require.NoError(t, err)
+
+	path := filepath.Join("testdata", "return.ach")
+	require.NoError(t, processFile(path, nil, SetupProcessors(exporter), nil))
+
+	require.Len(t, emitter.events, 1)
+	evt, ok := emitter.events[0].Event.(models.TreasuryExportFile)
+	require.True(t, ok)
+	require.Equal(t, "return.csv", evt.Filename)
+	require.Equal(t, "return.ach", evt.SourceFilename)
+	require.Equal(t, "csv", evt.Format)
+
+	lines := strings.Split(strings.TrimSpace(string(evt.Contents)), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, "kind,traceNumber,amountCents,returnCode", lines[0])
+	require.Equal(t, "return,273976361273620,146,R02", lines[1])
+}
+
+func TestTreasuryExporter__BAI2(t *testing.T) {
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock-agent",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	exporter, err := TreasuryExporter(log.NewNopLogger(), service.ODFIProcessors{
+		Reconciliation: service.ODFIReconciliation{Enabled: true, PathMatcher: "testdata"},
+		Export: &service.ODFIExport{
+			Format: "bai2",
+			BAI2: service.ODFIExportBAI2{
+				SenderID:      "273976369",
+				ReceiverID:    "MOOV",
+				AccountNumber: "987654321",
+			},
+			UploadAgent: "mock-agent",
+		},
+	}, uploadAgents, nil)
+	require.NoError(t, err)
+
+	// Returns aren't enabled, so only the reconciliation entries are exported
+	path := filepath.Join("testdata", "forward.ach")
+	require.NoError(t, processFile(path, nil, SetupProcessors(exporter), nil))
+
+	agent, err := upload.New(log.NewNopLogger(), uploadAgents, "mock-agent")
+	require.NoError(t, err)
+	mock, ok := agent.(*upload.MockAgent)
+	require.True(t, ok)
+	require.NotNil(t, mock.UploadedFile)
+	require.Equal(t, "forward.bai", mock.UploadedFile.Filename)
+
+	bs, err := io.ReadAll(mock.UploadedFile.Contents)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(bs), "01,273976369,MOOV,"))
+	require.Contains(t, string(bs), "\n03,987654321,USD,100,")
+	require.Contains(t, string(bs), "\n16,")
+	require.Contains(t, string(bs), "\n99,")
+}
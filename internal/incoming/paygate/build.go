@@ -0,0 +1,85 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package paygate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming/entries"
+	"github.com/moov-io/achgateway/internal/service"
+)
+
+// Party is a transfer's customer and the account money moves from or to
+type Party struct {
+	Customer *Customer
+	Account  *Account
+}
+
+// Resolve reads the source and destination of t from customers
+func Resolve(ctx context.Context, customers Customers, organization string, t CreateTransfer) (source, destination Party, err error) {
+	if source.Customer, err = customers.Customer(ctx, organization, t.Source.CustomerID); err != nil {
+		return
+	}
+	if source.Account, err = customers.Account(ctx, organization, t.Source.CustomerID, t.Source.AccountID); err != nil {
+		return
+	}
+	if destination.Customer, err = customers.Customer(ctx, organization, t.Destination.CustomerID); err != nil {
+		return
+	}
+	destination.Account, err = customers.Account(ctx, organization, t.Destination.CustomerID, t.Destination.AccountID)
+	return
+}
+
+// Build creates an ACH file which debits the source account and credits the destination account.
+// Entries of business customers are CCD and all others are PPD.
+func Build(defaults *service.FileDefaults, transferID string, t CreateTransfer, source, destination Party, now time.Time) (*ach.File, error) {
+	if source.Customer == nil || source.Account == nil || destination.Customer == nil || destination.Account == nil {
+		return nil, errors.New("missing source or destination")
+	}
+	sub := &entries.Submission{
+		CompanyEntryDescription: t.Description,
+		Entries: []entries.Entry{
+			entry(transferID, t.Amount.Value, "debit", source),
+			entry(transferID, t.Amount.Value, "credit", destination),
+		},
+	}
+	if t.SameDay {
+		sub.EffectiveEntryDate = now.Format("2006-01-02")
+	}
+	return entries.Build(defaults, sub, now)
+}
+
+func entry(transferID string, amount int, kind string, party Party) entries.Entry {
+	secCode := ach.PPD
+	if party.Customer.Type == "business" {
+		secCode = ach.CCD
+	}
+	return entries.Entry{
+		Name:           party.Customer.Name(),
+		RoutingNumber:  party.Account.RoutingNumber,
+		AccountNumber:  party.Account.AccountNumber,
+		AccountType:    party.Account.Type,
+		Amount:         amount,
+		Type:           kind,
+		SECCode:        secCode,
+		Identification: transferID,
+	}
+}
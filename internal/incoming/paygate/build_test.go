@@ -0,0 +1,135 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package paygate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+// mockCustomersServer serves the moov-io/customers routes read by the Customers client
+func mockCustomersServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	customers := map[string]Customer{
+		"jane": {CustomerID: "jane", FirstName: "Jane", LastName: "Doe", Type: "individual"},
+		"acme": {CustomerID: "acme", FirstName: "Acme", LastName: "Corp", Type: "business"},
+	}
+	accounts := map[string]Account{
+		"jane": {AccountID: "jane-checking", RoutingNumber: "231380104", Type: "checking"},
+		"acme": {AccountID: "acme-savings", RoutingNumber: "273976369", Type: "savings"},
+	}
+
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/customers/{customerID}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Organization") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		cust, exists := customers[mux.Vars(r)["customerID"]]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(cust)
+	})
+	r.Methods("GET").Path("/customers/{customerID}/accounts").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acct := accounts[mux.Vars(r)["customerID"]]
+		json.NewEncoder(w).Encode([]Account{acct})
+	})
+	r.Methods("POST").Path("/customers/{customerID}/accounts/{accountID}/decrypt").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"accountNumber": mux.Vars(r)["customerID"] + "-123",
+		})
+	})
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCustomersClient(t *testing.T) {
+	server := mockCustomersServer(t)
+	client := NewCustomersClient(server.Client(), server.URL)
+	ctx := context.Background()
+
+	cust, err := client.Customer(ctx, "org", "jane")
+	require.NoError(t, err)
+	require.Equal(t, "Jane Doe", cust.Name())
+
+	_, err = client.Customer(ctx, "org", "missing")
+	require.ErrorContains(t, err, "reading customer missing: unexpected 404 Not Found")
+
+	acct, err := client.Account(ctx, "org", "jane", "jane-checking")
+	require.NoError(t, err)
+	require.Equal(t, "231380104", acct.RoutingNumber)
+	require.Equal(t, "jane-123", acct.AccountNumber)
+
+	_, err = client.Account(ctx, "org", "jane", "other")
+	require.ErrorContains(t, err, "account other not found for customer jane")
+}
+
+func TestBuild(t *testing.T) {
+	server := mockCustomersServer(t)
+	client := NewCustomersClient(server.Client(), server.URL)
+
+	xfer := CreateTransfer{
+		Amount:      Amount{Currency: "USD", Value: 1204},
+		Source:      Source{CustomerID: "jane", AccountID: "jane-checking"},
+		Destination: Destination{CustomerID: "acme", AccountID: "acme-savings"},
+		Description: "Invoice",
+		SameDay:     true,
+	}
+	source, destination, err := Resolve(context.Background(), client, "org", xfer)
+	require.NoError(t, err)
+
+	defaults := &service.FileDefaults{
+		ImmediateDestination:  "231380104",
+		ImmediateOrigin:       "076401251",
+		CompanyName:           "Moov",
+		CompanyIdentification: "121042882",
+	}
+	now := time.Date(2022, time.June, 1, 10, 0, 0, 0, time.UTC)
+
+	file, err := Build(defaults, "xfer1", xfer, source, destination, now)
+	require.NoError(t, err)
+	require.Len(t, file.Batches, 2)
+
+	debit := file.Batches[0]
+	require.Equal(t, ach.PPD, debit.GetHeader().StandardEntryClassCode)
+	require.Equal(t, "220601", debit.GetHeader().EffectiveEntryDate)
+	require.Equal(t, "Invoice", debit.GetHeader().CompanyEntryDescription)
+	require.Equal(t, ach.CheckingDebit, debit.GetEntries()[0].TransactionCode)
+	require.Equal(t, 1204, debit.GetEntries()[0].Amount)
+	require.Equal(t, "Jane Doe", debit.GetEntries()[0].IndividualName)
+
+	credit := file.Batches[1]
+	require.Equal(t, ach.CCD, credit.GetHeader().StandardEntryClassCode)
+	require.Equal(t, ach.SavingsCredit, credit.GetEntries()[0].TransactionCode)
+	require.Equal(t, "acme-123", credit.GetEntries()[0].DFIAccountNumber)
+}
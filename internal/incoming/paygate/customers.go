@@ -0,0 +1,126 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package paygate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Customer is the subset of a moov-io/customers Customer used to build entries
+type Customer struct {
+	CustomerID string `json:"customerID"`
+	FirstName  string `json:"firstName"`
+	LastName   string `json:"lastName"`
+	Type       string `json:"type"` // individual or business
+}
+
+func (c Customer) Name() string {
+	return strings.TrimSpace(c.FirstName + " " + c.LastName)
+}
+
+// Account is a customer's bank account with its decrypted account number
+type Account struct {
+	AccountID     string `json:"accountID"`
+	RoutingNumber string `json:"routingNumber"`
+	Type          string `json:"type"` // checking or savings
+	AccountNumber string `json:"-"`
+}
+
+// Customers reads customers and their accounts from moov-io/customers
+type Customers interface {
+	Customer(ctx context.Context, organization, customerID string) (*Customer, error)
+	Account(ctx context.Context, organization, customerID, accountID string) (*Account, error)
+}
+
+func NewCustomersClient(client *http.Client, endpoint string) Customers {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &customersClient{
+		client:   client,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+	}
+}
+
+type customersClient struct {
+	client   *http.Client
+	endpoint string
+}
+
+func (c *customersClient) Customer(ctx context.Context, organization, customerID string) (*Customer, error) {
+	var cust Customer
+	path := fmt.Sprintf("/customers/%s", url.PathEscape(customerID))
+	if err := c.do(ctx, "GET", organization, path, &cust); err != nil {
+		return nil, fmt.Errorf("reading customer %s: %v", customerID, err)
+	}
+	return &cust, nil
+}
+
+func (c *customersClient) Account(ctx context.Context, organization, customerID, accountID string) (*Account, error) {
+	var accounts []Account
+	path := fmt.Sprintf("/customers/%s/accounts", url.PathEscape(customerID))
+	if err := c.do(ctx, "GET", organization, path, &accounts); err != nil {
+		return nil, fmt.Errorf("listing accounts of customer %s: %v", customerID, err)
+	}
+	var account *Account
+	for i := range accounts {
+		if accounts[i].AccountID == accountID {
+			account = &accounts[i]
+			break
+		}
+	}
+	if account == nil {
+		return nil, fmt.Errorf("account %s not found for customer %s", accountID, customerID)
+	}
+
+	var decrypted struct {
+		AccountNumber string `json:"accountNumber"`
+	}
+	path = fmt.Sprintf("/customers/%s/accounts/%s/decrypt", url.PathEscape(customerID), url.PathEscape(accountID))
+	if err := c.do(ctx, "POST", organization, path, &decrypted); err != nil {
+		return nil, fmt.Errorf("decrypting account %s: %v", accountID, err)
+	}
+	account.AccountNumber = decrypted.AccountNumber
+	return account, nil
+}
+
+func (c *customersClient) do(ctx context.Context, method, organization, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Organization", organization)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bs, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected %s: %s", resp.Status, strings.TrimSpace(string(bs)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
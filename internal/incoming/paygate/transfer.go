@@ -0,0 +1,119 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package paygate reads transfers shaped like the legacy moov-io/paygate API and builds them
+// into ACH files, reading each transfer's customers and accounts from moov-io/customers.
+package paygate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateTransfer is the body of POST /transfers
+type CreateTransfer struct {
+	Amount      Amount      `json:"amount"`
+	Source      Source      `json:"source"`
+	Destination Destination `json:"destination"`
+	Description string      `json:"description"`
+	SameDay     bool        `json:"sameDay"`
+}
+
+func (t CreateTransfer) Validate() error {
+	if t.Amount.Currency != "USD" {
+		return fmt.Errorf("unsupported currency %q", t.Amount.Currency)
+	}
+	if t.Amount.Value <= 0 {
+		return fmt.Errorf("invalid amount %d", t.Amount.Value)
+	}
+	if t.Source.CustomerID == "" || t.Source.AccountID == "" {
+		return errors.New("missing source customerID or accountID")
+	}
+	if t.Destination.CustomerID == "" || t.Destination.AccountID == "" {
+		return errors.New("missing destination customerID or accountID")
+	}
+	return nil
+}
+
+// Amount is written as {"currency": "USD", "value": 1204} where value is in cents. Older
+// paygate clients send a string like "USD 12.04" which is also accepted.
+type Amount struct {
+	Currency string `json:"currency"`
+	Value    int    `json:"value"`
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return a.parse(s)
+	}
+	type amount Amount
+	var aa amount
+	if err := json.Unmarshal(data, &aa); err != nil {
+		return err
+	}
+	*a = Amount(aa)
+	return nil
+}
+
+func (a *Amount) parse(s string) error {
+	currency, value, found := strings.Cut(strings.TrimSpace(s), " ")
+	if !found {
+		return fmt.Errorf("invalid amount %q", s)
+	}
+	whole, frac, _ := strings.Cut(value, ".")
+	if len(frac) > 2 {
+		return fmt.Errorf("invalid amount %q", s)
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	cents, err := strconv.Atoi(whole + frac)
+	if err != nil || whole == "" {
+		return fmt.Errorf("invalid amount %q", s)
+	}
+	a.Currency = strings.ToUpper(currency)
+	a.Value = cents
+	return nil
+}
+
+type Source struct {
+	CustomerID string `json:"customerID"`
+	AccountID  string `json:"accountID"`
+}
+
+type Destination struct {
+	CustomerID string `json:"customerID"`
+	AccountID  string `json:"accountID"`
+}
+
+// Transfer is the response to POST /transfers
+type Transfer struct {
+	TransferID  string      `json:"transferID"`
+	Amount      Amount      `json:"amount"`
+	Source      Source      `json:"source"`
+	Destination Destination `json:"destination"`
+	Description string      `json:"description"`
+	Status      string      `json:"status"`
+	SameDay     bool        `json:"sameDay"`
+	Created     time.Time   `json:"created"`
+}
+
+// StatusPending is the status of transfers which are waiting to be uploaded
+const StatusPending = "pending"
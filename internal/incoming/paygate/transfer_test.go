@@ -0,0 +1,56 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package paygate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAmount__UnmarshalJSON(t *testing.T) {
+	var amt Amount
+	require.NoError(t, json.Unmarshal([]byte(`{"currency":"USD","value":1204}`), &amt))
+	require.Equal(t, Amount{Currency: "USD", Value: 1204}, amt)
+
+	require.NoError(t, json.Unmarshal([]byte(`"USD 12.04"`), &amt))
+	require.Equal(t, Amount{Currency: "USD", Value: 1204}, amt)
+
+	require.NoError(t, json.Unmarshal([]byte(`"usd 3.5"`), &amt))
+	require.Equal(t, Amount{Currency: "USD", Value: 350}, amt)
+
+	require.ErrorContains(t, json.Unmarshal([]byte(`"12.04"`), &amt), `invalid amount "12.04"`)
+	require.ErrorContains(t, json.Unmarshal([]byte(`"USD 1.234"`), &amt), `invalid amount "USD 1.234"`)
+}
+
+func TestCreateTransfer__Validate(t *testing.T) {
+	xfer := CreateTransfer{
+		Amount:      Amount{Currency: "USD", Value: 1204},
+		Source:      Source{CustomerID: "c1", AccountID: "a1"},
+		Destination: Destination{CustomerID: "c2", AccountID: "a2"},
+	}
+	require.NoError(t, xfer.Validate())
+
+	xfer.Amount.Currency = "CAD"
+	require.ErrorContains(t, xfer.Validate(), `unsupported currency "CAD"`)
+
+	xfer.Amount.Currency = "USD"
+	xfer.Destination.AccountID = ""
+	require.ErrorContains(t, xfer.Validate(), "missing destination customerID or accountID")
+}
@@ -0,0 +1,172 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sftpserver
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/pkg/sftp"
+)
+
+// submitFunc is called once a partner has finished uploading a file
+type submitFunc func(partner service.SFTPPartner, filename string, contents []byte) error
+
+// partnerFS serves a partner's directory as the root of their SFTP session
+type partnerFS struct {
+	logger  log.Logger
+	root    string
+	partner service.SFTPPartner
+	submit  submitFunc
+}
+
+func newPartnerFS(logger log.Logger, rootDir string, partner service.SFTPPartner, submit submitFunc) (*partnerFS, error) {
+	root := filepath.Join(rootDir, filepath.Base(partner.Username))
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &partnerFS{
+		logger:  logger,
+		root:    root,
+		partner: partner,
+		submit:  submit,
+	}, nil
+}
+
+func (fs *partnerFS) handlers() sftp.Handlers {
+	return sftp.Handlers{
+		FileGet:  fs,
+		FilePut:  fs,
+		FileCmd:  fs,
+		FileList: fs,
+	}
+}
+
+// resolve maps a path from the client onto the partner's directory. Cleaning the path as
+// absolute removes any ".." which would escape it.
+func (fs *partnerFS) resolve(p string) string {
+	return filepath.Join(fs.root, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+func (fs *partnerFS) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	return os.Open(fs.resolve(r.Filepath))
+}
+
+func (fs *partnerFS) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	where := fs.resolve(r.Filepath)
+	fd, err := os.OpenFile(where, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &upload{File: fd, fs: fs, filename: filepath.Base(where)}, nil
+}
+
+func (fs *partnerFS) Filecmd(r *sftp.Request) error {
+	switch r.Method {
+	case "Setstat":
+		return nil
+	case "Rename":
+		return os.Rename(fs.resolve(r.Filepath), fs.resolve(r.Target))
+	case "Rmdir", "Remove":
+		return os.Remove(fs.resolve(r.Filepath))
+	case "Mkdir":
+		return os.Mkdir(fs.resolve(r.Filepath), 0755)
+	}
+	// Links could point outside of the partner's directory
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+func (fs *partnerFS) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	where := fs.resolve(r.Filepath)
+	switch r.Method {
+	case "List":
+		entries, err := os.ReadDir(where)
+		if err != nil {
+			return nil, err
+		}
+		var infos []os.FileInfo
+		for i := range entries {
+			info, err := entries[i].Info()
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+		return listerAt(infos), nil
+
+	case "Stat":
+		info, err := os.Stat(where)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt([]os.FileInfo{info}), nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(out []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(out, l[offset:])
+	if n < len(out) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// upload submits the file once the partner closes it. Errors are returned to the partner's
+// client as a failed close and the file is renamed with a .rejected suffix.
+type upload struct {
+	*os.File
+
+	fs       *partnerFS
+	filename string
+}
+
+func (u *upload) Close() error {
+	if err := u.File.Close(); err != nil {
+		return err
+	}
+	bs, err := os.ReadFile(u.Name())
+	if err != nil {
+		return err
+	}
+	if len(bs) == 0 {
+		return nil // nothing was written
+	}
+
+	logger := u.fs.logger.Set("filename", log.String(u.filename))
+	if err := u.fs.submit(u.fs.partner, u.filename, bs); err != nil {
+		logger.Warn().Logf("rejected upload: %v", err)
+		if rerr := os.Rename(u.Name(), u.Name()+".rejected"); rerr != nil {
+			logger.Error().LogErrorf("renaming rejected upload: %v", rerr)
+		}
+		return fmt.Errorf("rejected %s: %v", u.filename, err)
+	}
+	logger.Info().Log("submitted upload")
+	return nil
+}
@@ -0,0 +1,39 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sftpserver
+
+import (
+	"github.com/moov-io/achgateway/internal/service"
+)
+
+type MockRepository struct {
+	Partners []service.SFTPPartner
+	Err      error
+}
+
+func (r *MockRepository) Lookup(username string) (*service.SFTPPartner, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	for i := range r.Partners {
+		if r.Partners[i].Username == username {
+			return &r.Partners[i], nil
+		}
+	}
+	return nil, nil
+}
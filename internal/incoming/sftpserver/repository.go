@@ -0,0 +1,70 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sftpserver
+
+import (
+	"database/sql"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/pkg/errors"
+)
+
+type Repository interface {
+	// Lookup returns the partner for username, or nil when none is found
+	Lookup(username string) (*service.SFTPPartner, error)
+}
+
+func NewRepository(db *sql.DB, static []service.SFTPPartner) Repository {
+	if db == nil {
+		return &MockRepository{Partners: static}
+	}
+	return &sqlRepository{db: db}
+}
+
+type sqlRepository struct {
+	db *sql.DB
+}
+
+func (r *sqlRepository) Lookup(username string) (*service.SFTPPartner, error) {
+	query := `
+		SELECT
+		    username, shard_key, public_key
+		FROM
+		     sftp_partners
+		WHERE
+		      username = ? LIMIT 1;
+  		`
+	var partner service.SFTPPartner
+	err := r.db.QueryRow(query, username).Scan(&partner.Username, &partner.ShardKey, &partner.PublicKey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "looking up sftp partner")
+	}
+	return &partner, nil
+}
+
+func (r *sqlRepository) write(partner service.SFTPPartner) error {
+	query := `INSERT INTO sftp_partners (username, shard_key, public_key) VALUES (?, ?, ?);`
+	_, err := r.db.Exec(query, partner.Username, partner.ShardKey, partner.PublicKey)
+	if err != nil {
+		return errors.Wrap(err, "writing sftp partner")
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package sftpserver runs an embedded SFTP server where partners upload ACH files. Each partner
+// authenticates with a public key, is chrooted to their own directory and has every uploaded
+// file submitted to their shard key.
+package sftpserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/pkg/sftp"
+	"gocloud.dev/pubsub"
+	"golang.org/x/crypto/ssh"
+)
+
+type Server struct {
+	logger    log.Logger
+	cfg       *service.SFTPServer
+	repo      Repository
+	publisher *pubsub.Topic
+
+	sshConfig *ssh.ServerConfig
+	listener  net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// NewServer reads the host key and prepares a Server. Call Start to accept connections.
+func NewServer(logger log.Logger, cfg *service.SFTPServer, repo Repository, pub *pubsub.Topic) (*Server, error) {
+	if cfg == nil {
+		return nil, errors.New("nil SFTPServer config")
+	}
+	if repo == nil {
+		return nil, errors.New("nil Repository")
+	}
+	if pub == nil {
+		return nil, errors.New("nil publisher")
+	}
+
+	pem, err := os.ReadFile(cfg.HostKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading host key: %v", err)
+	}
+	hostKey, err := ssh.ParsePrivateKey(pem)
+	if err != nil {
+		return nil, fmt.Errorf("parsing host key: %v", err)
+	}
+	if err := os.MkdirAll(cfg.RootDirectory, 0755); err != nil {
+		return nil, fmt.Errorf("creating root directory: %v", err)
+	}
+
+	srv := &Server{
+		logger:    logger.Set("service", log.String("sftp")),
+		cfg:       cfg,
+		repo:      repo,
+		publisher: pub,
+		conns:     make(map[net.Conn]struct{}),
+	}
+	srv.sshConfig = &ssh.ServerConfig{
+		PublicKeyCallback: srv.authenticate,
+	}
+	srv.sshConfig.AddHostKey(hostKey)
+	return srv, nil
+}
+
+// authenticate accepts the connection when key matches the partner's PublicKey
+func (s *Server) authenticate(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	partner, err := s.repo.Lookup(conn.User())
+	if err != nil {
+		s.logger.Error().LogErrorf("looking up partner %s: %v", conn.User(), err)
+		return nil, errors.New("unable to authenticate")
+	}
+	if partner == nil {
+		return nil, fmt.Errorf("unknown user %s", conn.User())
+	}
+	authorized, _, _, _, err := ssh.ParseAuthorizedKey([]byte(partner.PublicKey))
+	if err != nil {
+		s.logger.Error().LogErrorf("parsing public key of partner %s: %v", partner.Username, err)
+		return nil, errors.New("unable to authenticate")
+	}
+	if !bytes.Equal(authorized.Marshal(), key.Marshal()) {
+		return nil, fmt.Errorf("public key rejected for %s", conn.User())
+	}
+	return &ssh.Permissions{
+		Extensions: map[string]string{
+			"shardKey": partner.ShardKey,
+		},
+	}, nil
+}
+
+// Start listens on BindAddress and accepts connections in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.cfg.BindAddress)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %v", s.cfg.BindAddress, err)
+	}
+	s.listener = listener
+	s.logger.Info().Logf("listening on %s", listener.Addr())
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if s.isClosed() {
+					return
+				}
+				s.logger.Error().LogErrorf("accepting connection: %v", err)
+				continue
+			}
+			go s.handleConn(conn)
+		}
+	}()
+	return nil
+}
+
+// Addr is the address the server is listening on
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) track(conn net.Conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.closed {
+			return false
+		}
+		s.conns[conn] = struct{}{}
+	} else {
+		delete(s.conns, conn)
+	}
+	return true
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	if !s.track(conn, true) {
+		return
+	}
+	defer s.track(conn, false)
+
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.sshConfig)
+	if err != nil {
+		s.logger.Warn().Logf("handshake with %s failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	partner := service.SFTPPartner{
+		Username: sconn.User(),
+		ShardKey: sconn.Permissions.Extensions["shardKey"],
+	}
+	logger := s.logger.With(log.Fields{
+		"username":  log.String(partner.Username),
+		"shard_key": log.String(partner.ShardKey),
+	})
+	logger.Info().Logf("connection from %s", conn.RemoteAddr())
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			logger.Error().LogErrorf("accepting channel: %v", err)
+			return
+		}
+		go s.handleSession(logger, partner, channel, requests)
+	}
+}
+
+// handleSession serves the sftp subsystem, which is the only request accepted
+func (s *Server) handleSession(logger log.Logger, partner service.SFTPPartner, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		// The subsystem name is a length prefixed string
+		ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+		req.Reply(ok, nil)
+		if !ok {
+			continue
+		}
+
+		fs, err := newPartnerFS(logger, s.cfg.RootDirectory, partner, s.submit)
+		if err != nil {
+			logger.Error().LogErrorf("setting up directory: %v", err)
+			return
+		}
+		server := sftp.NewRequestServer(channel, fs.handlers())
+		if err := server.Serve(); err != nil && err != io.EOF {
+			logger.Warn().Logf("sftp session ended: %v", err)
+		}
+		server.Close()
+		return
+	}
+}
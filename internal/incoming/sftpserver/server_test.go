@@ -0,0 +1,176 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sftpserver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func writeHostKey(t *testing.T, dir string) string {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+
+	where := filepath.Join(dir, "host_key")
+	bs := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(where, bs, 0600))
+	return where
+}
+
+func clientKey(t *testing.T) (ssh.Signer, string) {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+	return signer, string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+}
+
+func dial(t *testing.T, srv *Server, username string, signer ssh.Signer) (*sftp.Client, error) {
+	t.Helper()
+
+	conn, err := ssh.Dial("tcp", srv.Addr().String(), &ssh.ClientConfig{
+		User:            username,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, nil
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	topic, sub := streamtest.InmemStream(t)
+
+	signer, publicKey := clientKey(t)
+	cfg := &service.SFTPServer{
+		BindAddress:   "127.0.0.1:0",
+		HostKeyFile:   writeHostKey(t, dir),
+		RootDirectory: filepath.Join(dir, "root"),
+		Partners: []service.SFTPPartner{
+			{Username: "acme", ShardKey: "acme-shard", PublicKey: publicKey},
+		},
+	}
+	srv, err := NewServer(log.NewTestLogger(), cfg, NewRepository(nil, cfg.Partners), topic)
+	require.NoError(t, err)
+	require.NoError(t, srv.Start())
+	t.Cleanup(srv.Shutdown)
+
+	client, err := dial(t, srv, "acme", signer)
+	require.NoError(t, err)
+
+	// Upload a file
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	fd, err := client.Create("/upload-1.ach")
+	require.NoError(t, err)
+	_, err = fd.Write(bs)
+	require.NoError(t, err)
+	require.NoError(t, fd.Close())
+
+	msg, err := sub.Receive(context.Background())
+	require.NoError(t, err)
+
+	var file incoming.ACHFile
+	require.NoError(t, models.ReadEvent(msg.Body, &file))
+	require.Equal(t, "upload-1", file.FileID)
+	require.Equal(t, "acme-shard", file.ShardKey)
+	require.NotNil(t, file.File)
+
+	// Files are kept in the partner's directory
+	_, err = os.Stat(filepath.Join(cfg.RootDirectory, "acme", "upload-1.ach"))
+	require.NoError(t, err)
+
+	// Paths can't escape the partner's directory
+	fd, err = client.Create("../../escape.ach")
+	require.NoError(t, err)
+	require.NoError(t, fd.Close())
+	_, err = os.Stat(filepath.Join(cfg.RootDirectory, "acme", "escape.ach"))
+	require.NoError(t, err)
+
+	// Invalid files are rejected
+	fd, err = client.Create("/invalid.ach")
+	require.NoError(t, err)
+	_, err = fd.Write([]byte("invalid"))
+	require.NoError(t, err)
+	require.Error(t, fd.Close())
+
+	_, err = os.Stat(filepath.Join(cfg.RootDirectory, "acme", "invalid.ach.rejected"))
+	require.NoError(t, err)
+}
+
+func TestServer__Auth(t *testing.T) {
+	dir := t.TempDir()
+	topic, _ := streamtest.InmemStream(t)
+
+	_, publicKey := clientKey(t)
+	cfg := &service.SFTPServer{
+		BindAddress:   "127.0.0.1:0",
+		HostKeyFile:   writeHostKey(t, dir),
+		RootDirectory: filepath.Join(dir, "root"),
+		Partners: []service.SFTPPartner{
+			{Username: "acme", ShardKey: "acme-shard", PublicKey: publicKey},
+		},
+	}
+	srv, err := NewServer(log.NewTestLogger(), cfg, NewRepository(nil, cfg.Partners), topic)
+	require.NoError(t, err)
+	require.NoError(t, srv.Start())
+	t.Cleanup(srv.Shutdown)
+
+	// A different key is rejected
+	other, _ := clientKey(t)
+	_, err = dial(t, srv, "acme", other)
+	require.ErrorContains(t, err, "unable to authenticate")
+
+	// Unknown users are rejected
+	_, err = dial(t, srv, "unknown", other)
+	require.ErrorContains(t, err, "unable to authenticate")
+}
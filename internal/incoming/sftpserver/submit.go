@@ -0,0 +1,69 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sftpserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/pkg/compliance"
+	"github.com/moov-io/achgateway/pkg/models"
+
+	"gocloud.dev/pubsub"
+)
+
+// submit reads an uploaded Nacha or JSON file and publishes it for the partner's shard key.
+// The fileID is the filename without its extension.
+func (s *Server) submit(partner service.SFTPPartner, filename string, contents []byte) error {
+	file, err := ach.NewReader(bytes.NewReader(contents)).Read()
+	if err != nil {
+		// attempt JSON decode
+		f, jsonErr := ach.FileFromJSON(contents)
+		if f == nil || jsonErr != nil {
+			return fmt.Errorf("reading file: %v", err)
+		}
+		file = *f
+	}
+
+	fileID := strings.TrimSuffix(filename, filepath.Ext(filename))
+	bs, err := compliance.Protect(nil, models.Event{
+		Event: incoming.ACHFile{
+			FileID:   fileID,
+			ShardKey: partner.ShardKey,
+			File:     &file,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to protect incoming file event: %v", err)
+	}
+
+	meta := make(map[string]string)
+	meta["fileID"] = fileID
+	meta["shardKey"] = partner.ShardKey
+
+	return s.publisher.Send(context.Background(), &pubsub.Message{
+		Body:     bs,
+		Metadata: meta,
+	})
+}
@@ -15,7 +15,9 @@ package stream
 
 import (
 	"context"
+	"crypto/tls"
 
+	"github.com/moov-io/achgateway/internal/fips"
 	"github.com/moov-io/achgateway/internal/service"
 	"github.com/moov-io/base/log"
 
@@ -25,6 +27,9 @@ import (
 	_ "gocloud.dev/pubsub/mempubsub"
 )
 
+// KeyMetadata is the pubsub.Message metadata used as the Kafka message key
+const KeyMetadata = "shardKey"
+
 func Topic(logger log.Logger, cfg *service.Config) (*pubsub.Topic, error) {
 	if cfg.Inbound.Kafka != nil {
 		return openKafkaTopic(logger, cfg.Inbound.Kafka)
@@ -39,6 +44,9 @@ func openKafkaTopic(logger log.Logger, cfg *service.KafkaConfig) (*pubsub.Topic,
 	config := kafkapubsub.MinimalConfig()
 	config.Version = minKafkaVersion
 	config.Net.TLS.Enable = cfg.TLS
+	if cfg.TLS && fips.Enabled() {
+		config.Net.TLS.Config = fips.TLSConfig(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
 
 	config.Net.SASL.Enable = cfg.Key != ""
 	config.Net.SASL.Mechanism = sarama.SASLMechanism("PLAIN")
@@ -53,6 +61,11 @@ func openKafkaTopic(logger log.Logger, cfg *service.KafkaConfig) (*pubsub.Topic,
 	config.Consumer.Offsets.Initial = sarama.OffsetNewest
 	config.Consumer.IsolationLevel = sarama.ReadCommitted
 
+	if cfg.Producer != nil {
+		config.Producer.Flush.Messages = cfg.Producer.BatchSize
+		config.Producer.Flush.Frequency = cfg.Producer.Linger
+	}
+
 	logger.Info().
 		Set("tls", log.Bool(cfg.TLS)).
 		Set("group", log.String(cfg.Group)).
@@ -61,5 +74,7 @@ func openKafkaTopic(logger log.Logger, cfg *service.KafkaConfig) (*pubsub.Topic,
 		Set("topic", log.String(cfg.Topic)).
 		Log("opening kafka topic")
 
-	return kafkapubsub.OpenTopic(cfg.Brokers, config, cfg.Topic, nil)
+	return kafkapubsub.OpenTopic(cfg.Brokers, config, cfg.Topic, &kafkapubsub.TopicOptions{
+		KeyName: KeyMetadata,
+	})
 }
@@ -19,8 +19,10 @@ package stream
 
 import (
 	"context"
+	"crypto/tls"
 	"time"
 
+	"github.com/moov-io/achgateway/internal/fips"
 	"github.com/moov-io/achgateway/internal/service"
 	"github.com/moov-io/base/log"
 
@@ -53,6 +55,9 @@ func createKafkaSubscription(logger log.Logger, cfg *service.KafkaConfig) (*pubs
 	config := kafkapubsub.MinimalConfig()
 	config.Version = minKafkaVersion
 	config.Net.TLS.Enable = cfg.TLS
+	if cfg.TLS && fips.Enabled() {
+		config.Net.TLS.Config = fips.TLSConfig(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
 
 	config.Net.SASL.Enable = cfg.Key != ""
 	config.Net.SASL.Mechanism = sarama.SASLMechanism("PLAIN")
@@ -0,0 +1,109 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/moov-io/ach"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+)
+
+// ConvertToJSONHandler reads a Nacha formatted file with the shard's ValidateOpts and responds
+// with the moov-io/ach JSON representation of it.
+func (c *FilesController) ConvertToJSONHandler(w http.ResponseWriter, r *http.Request) {
+	opts, ok := c.convertOptions(w, r)
+	if !ok {
+		return
+	}
+
+	bs, err := c.readBody(r)
+	if err != nil {
+		c.logger.LogErrorf("error reading file: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	reader := ach.NewReader(bytes.NewReader(bs))
+	reader.SetValidation(opts)
+	file, err := reader.Read()
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&file)
+}
+
+// ConvertToNachaHandler reads a moov-io/ach JSON file with the shard's ValidateOpts and responds
+// with the Nacha formatted file.
+func (c *FilesController) ConvertToNachaHandler(w http.ResponseWriter, r *http.Request) {
+	opts, ok := c.convertOptions(w, r)
+	if !ok {
+		return
+	}
+
+	bs, err := c.readBody(r)
+	if err != nil {
+		c.logger.LogErrorf("error reading file: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	file, err := ach.FileFromJSONWith(bs, opts)
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := ach.NewWriter(&buf).Write(file); err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// convertOptions returns the ValidateOpts of the shard the request's shardKey belongs to.
+// The response has been written when false is returned.
+func (c *FilesController) convertOptions(w http.ResponseWriter, r *http.Request) (*ach.ValidateOpts, bool) {
+	shardKey := mux.Vars(r)["shardKey"]
+	if shardKey == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+	shard, err := c.findShard(shardKey)
+	if err != nil {
+		c.logger.Warn().With(log.Fields{
+			"shard_key": log.String(shardKey),
+		}).Logf("finding shard: %v", err)
+		moovhttp.Problem(w, err)
+		return nil, false
+	}
+	return shard.ValidateOpts, true
+}
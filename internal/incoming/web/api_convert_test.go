@@ -0,0 +1,123 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func setupConvertRouter(t *testing.T) *mux.Router {
+	t.Helper()
+
+	topic, _ := streamtest.InmemStream(t)
+
+	repo := shards.NewMockRepository()
+	repo.Shards["strict"] = service.ShardMapping{ShardKey: "strict", ShardName: "strict"}
+	repo.Shards["relaxed"] = service.ShardMapping{ShardKey: "relaxed", ShardName: "relaxed"}
+	sharding := service.Sharding{
+		Shards: []service.Shard{
+			{Name: "strict"},
+			{Name: "relaxed", ValidateOpts: &ach.ValidateOpts{BypassDestinationValidation: true}},
+		},
+	}
+
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic).WithShards(repo, sharding)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+	return r
+}
+
+func TestConvertHandlers(t *testing.T) {
+	r := setupConvertRouter(t)
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	// Nacha to JSON
+	req := httptest.NewRequest("POST", "/shards/strict/convert/json", strings.NewReader(string(bs)))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	file, err := ach.FileFromJSON(w.Body.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, "076401251", file.Header.ImmediateDestination)
+
+	// JSON back to Nacha
+	req = httptest.NewRequest("POST", "/shards/strict/convert/nacha", strings.NewReader(w.Body.String()))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	nacha, err := ach.NewReader(strings.NewReader(w.Body.String())).Read()
+	require.NoError(t, err)
+	require.Len(t, nacha.Batches, len(file.Batches))
+	require.Equal(t, file.Batches[0].GetEntries()[0].TraceNumber, nacha.Batches[0].GetEntries()[0].TraceNumber)
+
+	// Invalid JSON
+	req = httptest.NewRequest("POST", "/shards/strict/convert/nacha", strings.NewReader("{"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "problem reading File")
+}
+
+func TestConvertHandlers__ValidateOpts(t *testing.T) {
+	r := setupConvertRouter(t)
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	// Replace the ImmediateDestination with a routing number that has an invalid check digit
+	body := strings.Replace(string(bs), "101 076401251", "101 123456789", 1)
+
+	req := httptest.NewRequest("POST", "/shards/strict/convert/json", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest("POST", "/shards/relaxed/convert/json", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	file, err := ach.FileFromJSONWith(w.Body.Bytes(), &ach.ValidateOpts{BypassDestinationValidation: true})
+	require.NoError(t, err)
+	require.Equal(t, "123456789", file.Header.ImmediateDestination)
+
+	// Unknown shardKey
+	req = httptest.NewRequest("POST", "/shards/other/convert/json", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "unknown shardKey=other")
+}
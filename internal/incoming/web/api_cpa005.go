@@ -0,0 +1,88 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/pkg/cpa005"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateCPA005Handler accepts a CPA Standard 005 file for a shard configured with CPA005.
+// It's merged and uploaded at the shard's cutoff like Nacha files.
+func (c *FilesController) CreateCPA005Handler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shardKey, fileID := vars["shardKey"], vars["fileID"]
+	if shardKey == "" || fileID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	metadata, err := readMetadata(r)
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+	logger := c.logger.With(log.Fields{
+		"shard_key": log.String(shardKey),
+		"file_id":   log.String(fileID),
+	})
+
+	shard, err := c.findShard(shardKey)
+	if err != nil {
+		logger.Warn().Logf("finding shard: %v", err)
+		moovhttp.Problem(w, err)
+		return
+	}
+	if shard.CPA005 == nil {
+		moovhttp.Problem(w, fmt.Errorf("shard %s doesn't accept CPA-005 files", shard.Name))
+		return
+	}
+
+	bs, err := c.readBody(r)
+	if err != nil {
+		logger.LogErrorf("error reading CPA-005 file: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	file, err := cpa005.Read(bytes.NewReader(bs))
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	err = c.publishEvent(shardKey, fileID, incoming.CPA005File{
+		FileID:   fileID,
+		ShardKey: shardKey,
+		File:     file,
+		Metadata: metadata,
+	})
+	if err != nil {
+		logger.LogErrorf("publishing file: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
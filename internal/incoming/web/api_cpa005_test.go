@@ -0,0 +1,97 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateCPA005Handler(t *testing.T) {
+	topic, sub := streamtest.InmemStream(t)
+
+	repo := shards.NewMockRepository()
+	repo.Shards["ca"] = service.ShardMapping{ShardKey: "ca", ShardName: "canada"}
+	repo.Shards["us"] = service.ShardMapping{ShardKey: "us", ShardName: "live"}
+	sharding := service.Sharding{
+		Shards: []service.Shard{
+			{
+				Name: "canada",
+				CPA005: &service.CPA005Config{
+					OriginatorID:          "MOOVCA0001",
+					DestinationDataCentre: "00120",
+				},
+			},
+			{
+				Name: "live",
+			},
+		},
+	}
+
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic).WithShards(repo, sharding)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "pkg", "cpa005", "testdata", "returns.005"))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/shards/ca/cpa005/f1", bytes.NewReader(bs))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	msg, err := sub.Receive(context.Background())
+	require.NoError(t, err)
+
+	evt, err := models.Read(msg.Body)
+	require.NoError(t, err)
+	file, ok := evt.Event.(*models.QueueCPA005File)
+	require.True(t, ok)
+	require.Equal(t, "f1", file.FileID)
+	require.Equal(t, "ca", file.ShardKey)
+	require.Len(t, file.File.Transactions, 2)
+
+	// Invalid file
+	req = httptest.NewRequest("POST", "/shards/ca/cpa005/f2", strings.NewReader("A000000001"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "record 1 is too short")
+
+	// Shard without CPA005
+	req = httptest.NewRequest("POST", "/shards/us/cpa005/f3", bytes.NewReader(bs))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "shard live doesn't accept CPA-005 files")
+}
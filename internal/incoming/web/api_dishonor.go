@@ -0,0 +1,118 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/dishonor"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateDishonoredReturnsHandler reads a received return file (Nacha or JSON) and publishes a file
+// dishonoring its returns with ?code= like files submitted to CreateFileHandler.
+func (c *FilesController) CreateDishonoredReturnsHandler(w http.ResponseWriter, r *http.Request) {
+	c.respondToReturns(w, r, dishonor.Dishonor)
+}
+
+// CreateContestedDishonoredReturnsHandler reads a received dishonored return file (Nacha or JSON) and
+// publishes a file contesting its dishonored returns with ?code= like files submitted to CreateFileHandler.
+func (c *FilesController) CreateContestedDishonoredReturnsHandler(w http.ResponseWriter, r *http.Request) {
+	c.respondToReturns(w, r, dishonor.Contest)
+}
+
+type respondFunc func(file *ach.File, opts dishonor.Options) (*ach.File, error)
+
+func (c *FilesController) respondToReturns(w http.ResponseWriter, r *http.Request, respond respondFunc) {
+	vars := mux.Vars(r)
+	shardKey, fileID := vars["shardKey"], vars["fileID"]
+	if shardKey == "" || fileID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	metadata, err := readMetadata(r)
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+	logger := c.logger.With(log.Fields{
+		"shard_key": log.String(shardKey),
+		"file_id":   log.String(fileID),
+	})
+
+	opts, err := dishonorOptions(r)
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	bs, err := c.readBody(r)
+	if err != nil {
+		logger.LogErrorf("error reading file: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	received, err := ach.NewReader(bytes.NewReader(bs)).Read()
+	if err != nil {
+		f, err := ach.FileFromJSON(bs)
+		if f == nil || err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = *f
+	}
+
+	file, err := respond(&received, opts)
+	if err != nil {
+		logger.Warn().Logf("responding to returns: %v", err)
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	if err := c.publishFile(shardKey, fileID, file, metadata); err != nil {
+		logger.LogErrorf("publishing file: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func dishonorOptions(r *http.Request) (dishonor.Options, error) {
+	query := r.URL.Query()
+	opts := dishonor.Options{
+		Code:               query.Get("code"),
+		TraceNumbers:       query["traceNumber"],
+		AddendaInformation: query.Get("addendaInformation"),
+	}
+	if value := query.Get("originalEntryReturned"); value != "" {
+		when, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid originalEntryReturned %q", value)
+		}
+		opts.OriginalEntryReturned = when
+	}
+	return opts, nil
+}
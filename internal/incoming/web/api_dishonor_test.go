@@ -0,0 +1,99 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateDishonoredReturnsHandler(t *testing.T) {
+	topic, sub := streamtest.InmemStream(t)
+
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "return-WEB.ach"))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/shards/s1/dishonored-returns/f1?code=R69&traceNumber=091000017611242", bytes.NewReader(bs))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	msg, err := sub.Receive(context.Background())
+	require.NoError(t, err)
+
+	var file incoming.ACHFile
+	require.NoError(t, models.ReadEvent(msg.Body, &file))
+	require.Equal(t, "f1", file.FileID)
+	require.Equal(t, "s1", file.ShardKey)
+
+	require.Len(t, file.File.Batches, 1)
+	entries := file.File.Batches[0].GetEntries()
+	require.Len(t, entries, 1)
+	require.Equal(t, ach.CategoryDishonoredReturn, entries[0].Category)
+	require.Equal(t, "R69", entries[0].Addenda99Dishonored.DishonoredReturnReasonCode)
+}
+
+func TestCreateDishonoredReturnsHandlerErr(t *testing.T) {
+	topic, _ := streamtest.InmemStream(t)
+
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "return-WEB.ach"))
+	require.NoError(t, err)
+
+	// Returns can't be dishonored with a normal return code
+	req := httptest.NewRequest("POST", "/shards/s1/dishonored-returns/f1?code=R01", bytes.NewReader(bs))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "invalid dishonored return code")
+
+	// A return file has nothing to contest
+	req = httptest.NewRequest("POST", "/shards/s1/contested-dishonored-returns/f1?code=R74", bytes.NewReader(bs))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "no matching entries found")
+
+	req = httptest.NewRequest("POST", "/shards/s1/contested-dishonored-returns/f1?code=R74&originalEntryReturned=08/10", bytes.NewReader(bs))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "invalid originalEntryReturned")
+}
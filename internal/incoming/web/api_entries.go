@@ -0,0 +1,104 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/incoming/entries"
+	"github.com/moov-io/achgateway/internal/service"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateEntriesHandler builds an ACH file from CSV (Content-Type: text/csv) or JSON entries using
+// the shard's FileDefaults and publishes it like files submitted to CreateFileHandler.
+func (c *FilesController) CreateEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shardKey, fileID := vars["shardKey"], vars["fileID"]
+	if shardKey == "" || fileID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	metadata, err := readMetadata(r)
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+	logger := c.logger.With(log.Fields{
+		"shard_key": log.String(shardKey),
+		"file_id":   log.String(fileID),
+	})
+
+	shard, err := c.findShard(shardKey)
+	if err != nil {
+		logger.Warn().Logf("finding shard: %v", err)
+		moovhttp.Problem(w, err)
+		return
+	}
+	if shard.FileDefaults == nil {
+		moovhttp.Problem(w, fmt.Errorf("shard %s has no FileDefaults configured", shard.Name))
+		return
+	}
+
+	bs, err := c.readBody(r)
+	if err != nil {
+		logger.LogErrorf("error reading entries: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	sub, err := entries.Read(r.Header.Get("Content-Type"), bs)
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+	file, err := entries.Build(shard.FileDefaults, sub, time.Now())
+	if err != nil {
+		logger.Warn().Logf("building file from entries: %v", err)
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	if err := c.publishFile(shardKey, fileID, file, metadata); err != nil {
+		logger.LogErrorf("publishing file: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// findShard returns the shard config for shardKey, falling back to the default shard
+func (c *FilesController) findShard(shardKey string) (*service.Shard, error) {
+	shardName, err := c.shardRepository.Lookup(shardKey)
+	if err != nil {
+		return nil, fmt.Errorf("looking up shardKey=%s: %v", shardKey, err)
+	}
+	if shard := c.sharding.Find(shardName); shard != nil {
+		return shard, nil
+	}
+	if shard := c.sharding.Find(c.sharding.Default); shard != nil {
+		return shard, nil
+	}
+	return nil, fmt.Errorf("shard %s not found", shardName)
+}
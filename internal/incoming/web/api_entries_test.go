@@ -0,0 +1,113 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateEntriesHandler(t *testing.T) {
+	topic, sub := streamtest.InmemStream(t)
+
+	repo := shards.NewMockRepository()
+	repo.Shards["s1"] = service.ShardMapping{ShardKey: "s1", ShardName: "live"}
+	repo.Shards["s2"] = service.ShardMapping{ShardKey: "s2", ShardName: "sandbox"}
+	sharding := service.Sharding{
+		Shards: []service.Shard{
+			{
+				Name: "live",
+				FileDefaults: &service.FileDefaults{
+					ImmediateDestination:  "231380104",
+					ImmediateOrigin:       "076401251",
+					CompanyName:           "Moov",
+					CompanyIdentification: "121042882",
+				},
+			},
+			{
+				Name: "sandbox",
+			},
+		},
+	}
+
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic).WithShards(repo, sharding)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	body := "name,routingNumber,accountNumber,amount,type\nJane Doe,231380104,12345,1250,credit\n"
+	req := httptest.NewRequest("POST", "/shards/s1/entries/f1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	msg, err := sub.Receive(context.Background())
+	require.NoError(t, err)
+
+	var file incoming.ACHFile
+	require.NoError(t, models.ReadEvent(msg.Body, &file))
+
+	require.Equal(t, "f1", file.FileID)
+	require.Equal(t, "s1", file.ShardKey)
+	require.Equal(t, "076401251", file.File.Header.ImmediateOrigin)
+	require.Equal(t, ach.PPD, file.File.Batches[0].GetHeader().StandardEntryClassCode)
+	require.Equal(t, 1250, file.File.Batches[0].GetEntries()[0].Amount)
+
+	// Invalid JSON entry
+	req = httptest.NewRequest("POST", "/shards/s1/entries/f2", strings.NewReader(`{"entries":[{"amount":0}]}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "invalid amount 0")
+
+	// Shard without FileDefaults
+	req = httptest.NewRequest("POST", "/shards/s2/entries/f3", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "shard sandbox has no FileDefaults configured")
+}
+
+func TestCreateEntriesHandler__Disabled(t *testing.T) {
+	topic, _ := streamtest.InmemStream(t)
+
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	req := httptest.NewRequest("POST", "/shards/s1/entries/f1", strings.NewReader(`{"entries":[]}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -20,15 +20,30 @@ package web
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/drain"
+	"github.com/moov-io/achgateway/internal/events"
 	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/incoming/paygate"
+	"github.com/moov-io/achgateway/internal/microentries"
+	"github.com/moov-io/achgateway/internal/redis"
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/achgateway/internal/staging"
+	"github.com/moov-io/achgateway/internal/submissions"
 	"github.com/moov-io/achgateway/pkg/compliance"
 	"github.com/moov-io/achgateway/pkg/models"
+	moovhttp "github.com/moov-io/base/http"
 	"github.com/moov-io/base/log"
 
 	"github.com/gorilla/mux"
@@ -36,17 +51,109 @@ import (
 )
 
 func NewFilesController(logger log.Logger, cfg service.HTTPConfig, pub *pubsub.Topic) *FilesController {
-	return &FilesController{
+	c := &FilesController{
 		logger:    logger,
 		cfg:       cfg,
 		publisher: pub,
 	}
+	if cfg.Paygate != nil {
+		client := &http.Client{Timeout: 30 * time.Second}
+		c.customers = paygate.NewCustomersClient(client, cfg.Paygate.CustomersEndpoint)
+	}
+	return c
 }
 
 type FilesController struct {
 	logger    log.Logger
 	cfg       service.HTTPConfig
 	publisher *pubsub.Topic
+
+	shardRepository shards.Repository
+	sharding        service.Sharding
+
+	customers paygate.Customers
+
+	drain *drain.Coordinator
+
+	submissionCheck func(shardKey string) error
+
+	microEntries     microentries.Repository
+	microEntryEvents events.Emitter
+
+	stagedFiles staging.Repository
+
+	rateLimit   *redis.RateLimiter
+	idempotency *redis.Set
+}
+
+// WithShards lets the controller resolve shard keys to their shard config, which is required
+// to build files from entries submitted on POST /shards/{shardKey}/entries/{fileID}.
+func (c *FilesController) WithShards(repo shards.Repository, sharding service.Sharding) *FilesController {
+	c.shardRepository = repo
+	c.sharding = sharding
+	return c
+}
+
+// WithDrain rejects submissions with 503 Service Unavailable once the instance is draining so
+// clients retry them on another instance.
+func (c *FilesController) WithDrain(d *drain.Coordinator) *FilesController {
+	c.drain = d
+	return c
+}
+
+// WithSubmissionCheck rejects new files with 409 Conflict when check returns an
+// *incoming.LateSubmissionError for the file's shard key.
+func (c *FilesController) WithSubmissionCheck(check func(shardKey string) error) *FilesController {
+	c.submissionCheck = check
+	return c
+}
+
+// WithRedis limits how many files each shard key submits when Redis.RateLimit is configured and
+// skips publishing identical retries of a file when Redis.Idempotency is configured.
+func (c *FilesController) WithRedis(client *redis.Client) *FilesController {
+	if client == nil {
+		return c
+	}
+	cfg := client.Config()
+	if cfg.RateLimit != nil {
+		c.rateLimit = client.RateLimiter("files", cfg.RateLimit.Requests, cfg.RateLimit.Interval)
+	}
+	if cfg.Idempotency != nil {
+		c.idempotency = client.Set("files", cfg.Idempotency.TTL)
+	}
+	return c
+}
+
+// accepting wraps handlers which submit files into the pipeline
+func (c *FilesController) accepting(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-c.drain.Draining():
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, drain.ErrDraining.Error(), http.StatusServiceUnavailable)
+			return
+		default:
+		}
+		if shardKey := mux.Vars(r)["shardKey"]; c.submissionCheck != nil && r.Method == http.MethodPost && shardKey != "" {
+			var late *incoming.LateSubmissionError
+			if err := c.submissionCheck(shardKey); errors.As(err, &late) {
+				http.Error(w, late.Error(), http.StatusConflict)
+				return
+			}
+		}
+		if shardKey := mux.Vars(r)["shardKey"]; c.rateLimit != nil && r.Method == http.MethodPost && shardKey != "" {
+			allowed, retryAfter, err := c.rateLimit.Allow(shardKey)
+			if err != nil {
+				// Submissions are accepted while Redis is unavailable
+				c.logger.Warn().Logf("checking rate limit of %s: %v", shardKey, err)
+			} else if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				http.Error(w, fmt.Sprintf("shard key %s is rate limited", shardKey), http.StatusTooManyRequests)
+				return
+			}
+		}
+		next(w, r)
+	}
 }
 
 func (c *FilesController) AppendRoutes(router *mux.Router) *mux.Router {
@@ -54,13 +161,131 @@ func (c *FilesController) AppendRoutes(router *mux.Router) *mux.Router {
 		Name("Files.create").
 		Methods("POST").
 		Path("/shards/{shardKey}/files/{fileID}").
-		HandlerFunc(c.CreateFileHandler)
+		HandlerFunc(c.accepting(c.CreateFileHandler))
 
 	router.
 		Name("Files.cancel").
 		Methods("DELETE").
 		Path("/shards/{shardKey}/files/{fileID}").
-		HandlerFunc(c.CancelFileHandler)
+		HandlerFunc(c.accepting(c.CancelFileHandler))
+
+	router.
+		Name("Files.createDishonoredReturns").
+		Methods("POST").
+		Path("/shards/{shardKey}/dishonored-returns/{fileID}").
+		HandlerFunc(c.accepting(c.CreateDishonoredReturnsHandler))
+
+	router.
+		Name("Files.createContestedDishonoredReturns").
+		Methods("POST").
+		Path("/shards/{shardKey}/contested-dishonored-returns/{fileID}").
+		HandlerFunc(c.accepting(c.CreateContestedDishonoredReturnsHandler))
+
+	if c.cfg.StagedFiles != nil && c.stagedFiles != nil {
+		router.
+			Name("StagedFiles.create").
+			Methods("POST").
+			Path("/shards/{shardKey}/staged-files/{fileID}").
+			HandlerFunc(c.StageFileHandler)
+
+		router.
+			Name("StagedFiles.get").
+			Methods("GET").
+			Path("/shards/{shardKey}/staged-files/{fileID}").
+			HandlerFunc(c.GetStagedFileHandler)
+
+		router.
+			Name("StagedFiles.commit").
+			Methods("POST").
+			Path("/shards/{shardKey}/staged-files/{fileID}/commit").
+			HandlerFunc(c.accepting(c.CommitStagedFileHandler))
+
+		router.
+			Name("StagedFiles.delete").
+			Methods("DELETE").
+			Path("/shards/{shardKey}/staged-files/{fileID}").
+			HandlerFunc(c.DeleteStagedFileHandler)
+	}
+
+	if c.cfg.ISO20022 != nil {
+		router.
+			Name("Files.createPain001").
+			Methods("POST").
+			Path("/shards/{shardKey}/pain001/{fileID}").
+			HandlerFunc(c.accepting(c.CreatePain001Handler))
+	}
+
+	if c.shardRepository != nil {
+		router.
+			Name("Files.createEntries").
+			Methods("POST").
+			Path("/shards/{shardKey}/entries/{fileID}").
+			HandlerFunc(c.accepting(c.CreateEntriesHandler))
+
+		router.
+			Name("Files.createCPA005").
+			Methods("POST").
+			Path("/shards/{shardKey}/cpa005/{fileID}").
+			HandlerFunc(c.accepting(c.CreateCPA005Handler))
+
+		router.
+			Name("Files.convertToJSON").
+			Methods("POST").
+			Path("/shards/{shardKey}/convert/json").
+			HandlerFunc(c.ConvertToJSONHandler)
+
+		router.
+			Name("Files.convertToNacha").
+			Methods("POST").
+			Path("/shards/{shardKey}/convert/nacha").
+			HandlerFunc(c.ConvertToNachaHandler)
+
+		router.
+			Name("Files.settlements").
+			Methods("POST").
+			Path("/shards/{shardKey}/settlements").
+			HandlerFunc(c.SettlementsHandler)
+
+		router.
+			Name("Files.remittances").
+			Methods("POST").
+			Path("/shards/{shardKey}/remittances").
+			HandlerFunc(c.RemittancesHandler)
+
+		if c.cfg.MicroEntries != nil && c.microEntries != nil {
+			router.
+				Name("MicroEntries.create").
+				Methods("POST").
+				Path("/shards/{shardKey}/micro-entries").
+				HandlerFunc(c.accepting(c.CreateMicroEntriesHandler))
+
+			router.
+				Name("MicroEntries.get").
+				Methods("GET").
+				Path("/shards/{shardKey}/micro-entries/{microEntryID}").
+				HandlerFunc(c.GetMicroEntriesHandler)
+
+			router.
+				Name("MicroEntries.verify").
+				Methods("POST").
+				Path("/shards/{shardKey}/micro-entries/{microEntryID}/verify").
+				HandlerFunc(c.VerifyMicroEntriesHandler)
+		}
+
+		if c.customers != nil {
+			router.
+				Name("Paygate.createTransfer").
+				Methods("POST").
+				Path("/transfers").
+				HandlerFunc(c.accepting(c.CreatePaygateTransferHandler))
+
+			router.
+				Name("Paygate.deleteTransfer").
+				Methods("DELETE").
+				Path("/transfers/{transferID}").
+				HandlerFunc(c.accepting(c.DeletePaygateTransferHandler))
+		}
+	}
 
 	return router
 }
@@ -72,6 +297,11 @@ func (c *FilesController) CreateFileHandler(w http.ResponseWriter, r *http.Reque
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	metadata, err := readMetadata(r)
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
 
 	bs, err := c.readBody(r)
 	if err != nil {
@@ -91,12 +321,39 @@ func (c *FilesController) CreateFileHandler(w http.ResponseWriter, r *http.Reque
 		file = *f
 	}
 
-	if err := c.publishFile(shardKey, fileID, &file); err != nil {
+	// Retries of a file which was already published aren't published again. The key is reserved
+	// before publishing so concurrent retries don't both publish.
+	idempotencyKey := fmt.Sprintf("%s/%s/%x", shardKey, fileID, sha256.Sum256(append(bs, r.Header.Get(MetadataHeader)...)))
+	reserved := false
+	if c.idempotency != nil {
+		added, err := c.idempotency.Add(idempotencyKey)
+		if err != nil {
+			c.logger.Warn().Logf("checking idempotency of %s: %v", fileID, err)
+		}
+		reserved = added
+		if err == nil && !added {
+			c.logger.With(log.Fields{
+				"shard_key": log.String(shardKey),
+				"file_id":   log.String(fileID),
+			}).Log("skipping file which was already published")
+
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	if err := c.publishFile(shardKey, fileID, &file, metadata); err != nil {
 		c.logger.With(log.Fields{
 			"shard_key": log.String(shardKey),
 			"file_id":   log.String(fileID),
 		}).LogErrorf("publishing file", err)
 
+		// Retries need to publish the file
+		if reserved {
+			if err := c.idempotency.Remove(idempotencyKey); err != nil {
+				c.logger.Warn().Logf("forgetting %s: %v", fileID, err)
+			}
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -119,13 +376,44 @@ func (c *FilesController) readBody(req *http.Request) ([]byte, error) {
 	return compliance.Reveal(c.cfg.Transform, bs)
 }
 
-func (c *FilesController) publishFile(shardKey, fileID string, file *ach.File) error {
+// MetadataHeader holds key/value metadata echoed back on events about a submitted file,
+// encoded like a query string (e.g. batchID=123&ledger=payroll).
+const MetadataHeader = "X-Metadata"
+
+func readMetadata(req *http.Request) (map[string]string, error) {
+	header := req.Header.Get(MetadataHeader)
+	if header == "" {
+		return nil, nil
+	}
+	values, err := url.ParseQuery(header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %v", MetadataHeader, err)
+	}
+	out := make(map[string]string)
+	for key := range values {
+		if len(values[key]) > 1 {
+			return nil, fmt.Errorf("invalid %s header: %s is repeated", MetadataHeader, key)
+		}
+		out[key] = values.Get(key)
+	}
+	if err := submissions.Validate(out); err != nil {
+		return nil, fmt.Errorf("invalid %s header: %v", MetadataHeader, err)
+	}
+	return out, nil
+}
+
+func (c *FilesController) publishFile(shardKey, fileID string, file *ach.File, metadata map[string]string) error {
+	return c.publishEvent(shardKey, fileID, incoming.ACHFile{
+		FileID:   fileID,
+		ShardKey: shardKey,
+		File:     file,
+		Metadata: metadata,
+	})
+}
+
+func (c *FilesController) publishEvent(shardKey, fileID string, event interface{}) error {
 	bs, err := compliance.Protect(c.cfg.Transform, models.Event{
-		Event: incoming.ACHFile{
-			FileID:   fileID,
-			ShardKey: shardKey,
-			File:     file,
-		},
+		Event: event,
 	})
 	if err != nil {
 		return fmt.Errorf("unable to protect incoming file event: %v", err)
@@ -20,15 +20,22 @@ package web
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/moov-io/achgateway/internal/drain"
 	"github.com/moov-io/achgateway/internal/incoming"
 	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/redis"
+	"github.com/moov-io/achgateway/internal/redis/redistest"
 	"github.com/moov-io/achgateway/internal/service"
 	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base/log"
@@ -65,6 +72,38 @@ func TestCreateFileHandler(t *testing.T) {
 	require.Equal(t, "231380104", file.File.Header.ImmediateDestination)
 }
 
+func TestCreateFileHandler__Metadata(t *testing.T) {
+	topic, sub := streamtest.InmemStream(t)
+
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	bs, _ := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-valid.json"))
+	req := httptest.NewRequest("POST", "/shards/s1/files/f1", bytes.NewReader(bs))
+	req.Header.Set(MetadataHeader, "batchID=abc123&ledgerRef=LR%209")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	msg, err := sub.Receive(context.Background())
+	require.NoError(t, err)
+
+	var file incoming.ACHFile
+	require.NoError(t, models.ReadEvent(msg.Body, &file))
+	require.Equal(t, map[string]string{"batchID": "abc123", "ledgerRef": "LR 9"}, file.Metadata)
+
+	// Keys can only be set once
+	req = httptest.NewRequest("POST", "/shards/s1/files/f2", bytes.NewReader(bs))
+	req.Header.Set(MetadataHeader, "batchID=1&batchID=2")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "batchID is repeated")
+}
+
 func TestCreateFileHandlerErr(t *testing.T) {
 	topic, _ := streamtest.InmemStream(t)
 
@@ -81,6 +120,106 @@ func TestCreateFileHandlerErr(t *testing.T) {
 	require.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestCreateFileHandler__Draining(t *testing.T) {
+	topic, _ := streamtest.InmemStream(t)
+
+	d := drain.New(log.NewNopLogger(), nil)
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic).WithDrain(d)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	bs, _ := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-valid.json"))
+	req := httptest.NewRequest("POST", "/shards/s1/files/f1", bytes.NewReader(bs))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Submissions are refused once draining
+	d.Drain()
+
+	req = httptest.NewRequest("POST", "/shards/s1/files/f2", bytes.NewReader(bs))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestCreateFileHandler__Redis(t *testing.T) {
+	topic, sub := streamtest.InmemStream(t)
+
+	client, _ := redistest.NewClient(t, redis.Config{
+		RateLimit:   &redis.RateLimitConfig{Requests: 3, Interval: time.Minute},
+		Idempotency: &redis.IdempotencyConfig{TTL: time.Hour},
+	})
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic).WithRedis(client)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	bs, _ := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-valid.json"))
+	post := func(path, metadata string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, bytes.NewReader(bs))
+		if metadata != "" {
+			req.Header.Set(MetadataHeader, metadata)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	receive := func() string {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		msg, err := sub.Receive(ctx)
+		require.NoError(t, err)
+		msg.Ack()
+
+		var file incoming.ACHFile
+		require.NoError(t, models.ReadEvent(msg.Body, &file))
+		return file.Metadata["batchID"]
+	}
+
+	require.Equal(t, http.StatusOK, post("/shards/s1/files/f1", "batchID=1").Code)
+	require.Equal(t, "1", receive())
+
+	// The retry isn't published again, but a changed file is
+	require.Equal(t, http.StatusOK, post("/shards/s1/files/f1", "batchID=1").Code)
+	require.Equal(t, http.StatusOK, post("/shards/s1/files/f1", "batchID=2").Code)
+	require.Equal(t, "2", receive())
+
+	// Each shard key is limited to three submissions a minute
+	w := post("/shards/s1/files/f2", "")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	require.Equal(t, http.StatusOK, post("/shards/s2/files/f2", "batchID=3").Code)
+	require.Equal(t, "3", receive())
+
+	// Concurrent retries publish the file once
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Equal(t, http.StatusOK, post("/shards/s3/files/f3", "batchID=4").Code)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, "4", receive())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := sub.Receive(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Files which failed to publish are published when they're retried
+	key := fmt.Sprintf("s3/f4/%x", sha256.Sum256(append(bs, "batchID=5"...)))
+	require.NoError(t, topic.Shutdown(context.Background()))
+	require.Equal(t, http.StatusInternalServerError, post("/shards/s3/files/f4", "batchID=5").Code)
+	seen, err := controller.idempotency.Contains(key)
+	require.NoError(t, err)
+	require.False(t, seen)
+}
+
 func TestCancelFileHandler(t *testing.T) {
 	topic, sub := streamtest.InmemStream(t)
 
@@ -105,3 +244,35 @@ func TestCancelFileHandler(t *testing.T) {
 	require.Equal(t, "f2", file.FileID)
 	require.Equal(t, "s2", file.ShardKey)
 }
+
+func TestCreateFileHandler__LateSubmission(t *testing.T) {
+	topic, _ := streamtest.InmemStream(t)
+
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic).
+		WithSubmissionCheck(func(shardKey string) error {
+			if shardKey == "late" {
+				return &incoming.LateSubmissionError{ShardName: "late", CutoffStartedAt: time.Now()}
+			}
+			return nil
+		})
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	bs, _ := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-valid.json"))
+	req := httptest.NewRequest("POST", "/shards/s1/files/f1", bytes.NewReader(bs))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("POST", "/shards/late/files/f2", bytes.NewReader(bs))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Contains(t, w.Body.String(), "late submission")
+
+	// Cancellations are still accepted
+	req = httptest.NewRequest("DELETE", "/shards/late/files/f2", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+}
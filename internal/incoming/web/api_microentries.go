@@ -0,0 +1,189 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/microentries"
+	"github.com/moov-io/achgateway/pkg/models"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+)
+
+// WithMicroEntries tracks micro-entries in repo for the /shards/{shardKey}/micro-entries routes,
+// which are added when Inbound.HTTP.MicroEntries is configured. events are sent as micro-entries
+// are verified.
+func (c *FilesController) WithMicroEntries(repo microentries.Repository, emitter events.Emitter) *FilesController {
+	c.microEntries = repo
+	c.microEntryEvents = emitter
+	return c
+}
+
+// CreateMicroEntriesHandler reads the customer's account and publishes a file of micro-entries
+// to it using the shard's FileDefaults. The response is the pending micro-entries.
+func (c *FilesController) CreateMicroEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	shardKey := mux.Vars(r)["shardKey"]
+	if shardKey == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	logger := c.logger.With(log.Fields{
+		"shard_key": log.String(shardKey),
+	})
+
+	shard, err := c.findShard(shardKey)
+	if err != nil {
+		logger.Warn().Logf("finding shard: %v", err)
+		moovhttp.Problem(w, err)
+		return
+	}
+	if shard.FileDefaults == nil {
+		moovhttp.Problem(w, fmt.Errorf("shard %s has no FileDefaults configured", shard.Name))
+		return
+	}
+
+	bs, err := c.readBody(r)
+	if err != nil {
+		logger.LogErrorf("error reading account: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var acct microentries.Account
+	if err := json.Unmarshal(bs, &acct); err != nil {
+		moovhttp.Problem(w, fmt.Errorf("reading account: %v", err))
+		return
+	}
+
+	now := time.Now()
+	micro, err := microentries.New(shardKey, now)
+	if err != nil {
+		moovhttp.InternalError(w, err)
+		return
+	}
+	file, err := microentries.Build(shard.FileDefaults, micro, acct, now)
+	if err != nil {
+		logger.Warn().Logf("building micro-entries: %v", err)
+		moovhttp.Problem(w, err)
+		return
+	}
+	logger = logger.With(log.Fields{
+		"micro_entry_id": log.String(micro.ID),
+	})
+
+	if err := c.microEntries.Create(micro); err != nil {
+		logger.LogErrorf("saving micro-entries: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := c.publishFile(shardKey, micro.ID, file, nil); err != nil {
+		logger.LogErrorf("publishing file: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(micro)
+}
+
+// GetMicroEntriesHandler responds with the status of micro-entries
+func (c *FilesController) GetMicroEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	micro, ok := c.findMicroEntries(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(micro)
+}
+
+type verifyMicroEntriesRequest struct {
+	Amounts []int `json:"amounts"`
+}
+
+// VerifyMicroEntriesHandler compares the amounts the customer saw to the credits sent. The response
+// is the micro-entries, which are verified or still pending when the amounts don't match. Verification
+// fails after Inbound.HTTP.MicroEntries.MaxAttempts incorrect guesses.
+func (c *FilesController) VerifyMicroEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	micro, ok := c.findMicroEntries(w, r)
+	if !ok {
+		return
+	}
+
+	var req verifyMicroEntriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		moovhttp.Problem(w, fmt.Errorf("reading amounts: %v", err))
+		return
+	}
+
+	micro, err := microentries.Verify(c.microEntries, micro.ID, req.Amounts, c.cfg.MicroEntries.Attempts())
+	if err != nil {
+		if errors.Is(err, microentries.ErrNotPending) {
+			moovhttp.Problem(w, fmt.Errorf("%v, status is %s", err, micro.Status))
+			return
+		}
+		c.logger.LogErrorf("verifying micro-entries: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if micro.Status != microentries.StatusPending && c.microEntryEvents != nil {
+		err := c.microEntryEvents.Send(models.Event{Event: models.MicroEntryUpdated{
+			MicroEntryID: micro.ID,
+			ShardKey:     micro.ShardKey,
+			Status:       micro.Status,
+			UpdatedAt:    micro.UpdatedAt,
+		}})
+		if err != nil {
+			c.logger.LogErrorf("sending MicroEntryUpdated event: %v", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(micro)
+}
+
+// findMicroEntries reads the micro-entries of the request. The response has been written when
+// false is returned.
+func (c *FilesController) findMicroEntries(w http.ResponseWriter, r *http.Request) (*microentries.MicroEntry, bool) {
+	vars := mux.Vars(r)
+	shardKey, id := vars["shardKey"], vars["microEntryID"]
+	if shardKey == "" || id == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+	micro, err := c.microEntries.Get(id)
+	if err != nil {
+		c.logger.LogErrorf("reading micro-entries: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	if micro == nil || micro.ShardKey != shardKey {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return micro, true
+}
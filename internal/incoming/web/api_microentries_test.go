@@ -0,0 +1,162 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/microentries"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+type microEntryEmitter struct {
+	updates []models.MicroEntryUpdated
+}
+
+func (e *microEntryEmitter) Send(evt models.Event) error {
+	if update, ok := evt.Event.(models.MicroEntryUpdated); ok {
+		e.updates = append(e.updates, update)
+	}
+	return nil
+}
+
+func (e *microEntryEmitter) Close() error {
+	return nil
+}
+
+func TestMicroEntriesHandlers(t *testing.T) {
+	topic, sub := streamtest.InmemStream(t)
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := microentries.NewRepository(db.DB)
+
+	shardRepo := shards.NewMockRepository()
+	shardRepo.Shards["s1"] = service.ShardMapping{ShardKey: "s1", ShardName: "live"}
+	sharding := service.Sharding{
+		Shards: []service.Shard{
+			{
+				Name: "live",
+				FileDefaults: &service.FileDefaults{
+					ImmediateDestination:  "231380104",
+					ImmediateOrigin:       "076401251",
+					CompanyName:           "Moov",
+					CompanyIdentification: "121042882",
+				},
+			},
+		},
+	}
+	cfg := service.HTTPConfig{
+		MicroEntries: &service.MicroEntriesConfig{MaxAttempts: 2},
+	}
+	emitter := &microEntryEmitter{}
+
+	controller := NewFilesController(log.NewNopLogger(), cfg, topic).
+		WithShards(shardRepo, sharding).
+		WithMicroEntries(repo, emitter)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	body := `{"name": "Jane Doe", "routingNumber": "231380104", "accountNumber": "12345"}`
+	req := httptest.NewRequest("POST", "/shards/s1/micro-entries", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotContains(t, w.Body.String(), "amounts")
+
+	var created microentries.MicroEntry
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	require.Equal(t, microentries.StatusPending, created.Status)
+
+	// The file is published under the micro-entries ID
+	msg, err := sub.Receive(context.Background())
+	require.NoError(t, err)
+	var file incoming.ACHFile
+	require.NoError(t, models.ReadEvent(msg.Body, &file))
+	require.Equal(t, created.ID, file.FileID)
+	require.Len(t, file.File.Batches[0].GetEntries(), 3)
+
+	req = httptest.NewRequest("GET", "/shards/s1/micro-entries/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Other shard keys can't read the micro-entries
+	req = httptest.NewRequest("GET", "/shards/s2/micro-entries/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	saved, err := repo.Get(created.ID)
+	require.NoError(t, err)
+	verify := func(amounts ...int) *httptest.ResponseRecorder {
+		bs, _ := json.Marshal(verifyMicroEntriesRequest{Amounts: amounts})
+		req := httptest.NewRequest("POST", fmt.Sprintf("/shards/s1/micro-entries/%s/verify", created.ID), bytes.NewReader(bs))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w = verify(saved.Amounts[0]+100, saved.Amounts[1])
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"status":"pending"`)
+	require.Empty(t, emitter.updates)
+
+	w = verify(saved.Amounts[1], saved.Amounts[0])
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"status":"verified"`)
+
+	require.Len(t, emitter.updates, 1)
+	require.Equal(t, created.ID, emitter.updates[0].MicroEntryID)
+	require.Equal(t, microentries.StatusVerified, emitter.updates[0].Status)
+
+	// Verified micro-entries can't be guessed again
+	w = verify(saved.Amounts[0], saved.Amounts[1])
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "status is verified")
+}
+
+func TestMicroEntriesHandlers__NotConfigured(t *testing.T) {
+	topic, _ := streamtest.InmemStream(t)
+
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic).
+		WithShards(shards.NewMockRepository(), service.Sharding{})
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	req := httptest.NewRequest("POST", "/shards/s1/micro-entries", strings.NewReader("{}"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
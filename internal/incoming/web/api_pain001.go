@@ -0,0 +1,76 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/incoming/iso20022"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+)
+
+// CreatePain001Handler converts an ISO 20022 pain.001 message into an ACH file and publishes it
+// like files submitted to CreateFileHandler.
+func (c *FilesController) CreatePain001Handler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shardKey, fileID := vars["shardKey"], vars["fileID"]
+	if shardKey == "" || fileID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	metadata, err := readMetadata(r)
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+	logger := c.logger.With(log.Fields{
+		"shard_key": log.String(shardKey),
+		"file_id":   log.String(fileID),
+	})
+
+	bs, err := c.readBody(r)
+	if err != nil {
+		logger.LogErrorf("error reading pain.001: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	doc, err := iso20022.ReadBytes(bs)
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+	file, err := iso20022.Convert(c.cfg.ISO20022, doc, time.Now())
+	if err != nil {
+		logger.Warn().Logf("converting pain.001 message %s: %v", doc.CustomerCreditTransferIn.GroupHeader.MessageID, err)
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	if err := c.publishFile(shardKey, fileID, file, metadata); err != nil {
+		logger.LogErrorf("publishing file: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
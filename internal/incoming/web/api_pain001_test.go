@@ -0,0 +1,91 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatePain001Handler(t *testing.T) {
+	topic, sub := streamtest.InmemStream(t)
+
+	cfg := service.HTTPConfig{
+		ISO20022: &service.ISO20022Config{
+			ImmediateDestination: "231380104",
+			ImmediateOrigin:      "076401251",
+		},
+	}
+	controller := NewFilesController(log.NewNopLogger(), cfg, topic)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	bs, _ := os.ReadFile(filepath.Join("..", "iso20022", "testdata", "pain001.xml"))
+	req := httptest.NewRequest("POST", "/shards/s1/pain001/f1", bytes.NewReader(bs))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	msg, err := sub.Receive(context.Background())
+	require.NoError(t, err)
+
+	var file incoming.ACHFile
+	require.NoError(t, models.ReadEvent(msg.Body, &file))
+
+	require.Equal(t, "f1", file.FileID)
+	require.Equal(t, "s1", file.ShardKey)
+	require.Equal(t, "231380104", file.File.Header.ImmediateDestination)
+	require.Equal(t, ach.PPD, file.File.Batches[0].GetHeader().StandardEntryClassCode)
+
+	// Invalid message
+	req = httptest.NewRequest("POST", "/shards/s1/pain001/f2", strings.NewReader("<Document></Document>"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "no PmtInf found")
+}
+
+func TestCreatePain001Handler__Disabled(t *testing.T) {
+	topic, _ := streamtest.InmemStream(t)
+
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	req := httptest.NewRequest("POST", "/shards/s1/pain001/f1", strings.NewReader("<Document></Document>"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
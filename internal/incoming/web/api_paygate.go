@@ -0,0 +1,133 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/incoming/paygate"
+	"github.com/moov-io/base"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+)
+
+// CreatePaygateTransferHandler accepts a transfer shaped like the legacy moov-io/paygate API.
+// The X-Organization header is used as the shardKey and the transferID is the fileID, which is
+// read from X-Idempotency-Key when set so retried requests replace the same file.
+func (c *FilesController) CreatePaygateTransferHandler(w http.ResponseWriter, r *http.Request) {
+	shardKey := r.Header.Get("X-Organization")
+	if shardKey == "" {
+		moovhttp.Problem(w, errors.New("missing X-Organization header"))
+		return
+	}
+	transferID := r.Header.Get("X-Idempotency-Key")
+	if transferID == "" {
+		transferID = base.ID()
+	}
+	logger := c.logger.With(log.Fields{
+		"shard_key": log.String(shardKey),
+		"file_id":   log.String(transferID),
+	})
+
+	shard, err := c.findShard(shardKey)
+	if err != nil {
+		logger.Warn().Logf("finding shard: %v", err)
+		moovhttp.Problem(w, err)
+		return
+	}
+	if shard.FileDefaults == nil {
+		moovhttp.Problem(w, fmt.Errorf("shard %s has no FileDefaults configured", shard.Name))
+		return
+	}
+
+	bs, err := c.readBody(r)
+	if err != nil {
+		logger.LogErrorf("error reading transfer: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req paygate.CreateTransfer
+	if err := json.Unmarshal(bs, &req); err != nil {
+		moovhttp.Problem(w, fmt.Errorf("reading transfer: %v", err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	source, destination, err := paygate.Resolve(r.Context(), c.customers, shardKey, req)
+	if err != nil {
+		logger.Warn().Logf("reading transfer customers: %v", err)
+		moovhttp.Problem(w, err)
+		return
+	}
+	now := time.Now()
+	file, err := paygate.Build(shard.FileDefaults, transferID, req, source, destination, now)
+	if err != nil {
+		logger.Warn().Logf("building file from transfer: %v", err)
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	if err := c.publishFile(shardKey, transferID, file, nil); err != nil {
+		logger.LogErrorf("publishing file: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(paygate.Transfer{
+		TransferID:  transferID,
+		Amount:      req.Amount,
+		Source:      req.Source,
+		Destination: req.Destination,
+		Description: req.Description,
+		Status:      paygate.StatusPending,
+		SameDay:     req.SameDay,
+		Created:     now,
+	})
+}
+
+// DeletePaygateTransferHandler cancels a transfer like DELETE /shards/{shardKey}/files/{fileID}
+func (c *FilesController) DeletePaygateTransferHandler(w http.ResponseWriter, r *http.Request) {
+	shardKey, transferID := r.Header.Get("X-Organization"), mux.Vars(r)["transferID"]
+	if shardKey == "" || transferID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := c.cancelFile(shardKey, transferID); err != nil {
+		c.logger.With(log.Fields{
+			"shard_key": log.String(shardKey),
+			"file_id":   log.String(transferID),
+		}).LogErrorf("canceling transfer: %v", err)
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,139 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/incoming/paygate"
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+type mockCustomers struct{}
+
+func (c *mockCustomers) Customer(ctx context.Context, organization, customerID string) (*paygate.Customer, error) {
+	if customerID == "missing" {
+		return nil, fmt.Errorf("reading customer %s: unexpected 404 Not Found", customerID)
+	}
+	return &paygate.Customer{CustomerID: customerID, FirstName: customerID, LastName: "Doe"}, nil
+}
+
+func (c *mockCustomers) Account(ctx context.Context, organization, customerID, accountID string) (*paygate.Account, error) {
+	return &paygate.Account{AccountID: accountID, RoutingNumber: "231380104", Type: "checking", AccountNumber: "12345"}, nil
+}
+
+func TestPaygateTransferHandlers(t *testing.T) {
+	topic, sub := streamtest.InmemStream(t)
+
+	repo := shards.NewMockRepository()
+	repo.Shards["org1"] = service.ShardMapping{ShardKey: "org1", ShardName: "live"}
+	sharding := service.Sharding{
+		Shards: []service.Shard{
+			{
+				Name: "live",
+				FileDefaults: &service.FileDefaults{
+					ImmediateDestination:  "231380104",
+					ImmediateOrigin:       "076401251",
+					CompanyName:           "Moov",
+					CompanyIdentification: "121042882",
+				},
+			},
+		},
+	}
+	cfg := service.HTTPConfig{
+		Paygate: &service.PaygateConfig{CustomersEndpoint: "http://customers:8087"},
+	}
+	controller := NewFilesController(log.NewNopLogger(), cfg, topic).WithShards(repo, sharding)
+	controller.customers = &mockCustomers{}
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	body := `{"amount":"USD 12.04","source":{"customerID":"jane","accountID":"a1"},"destination":{"customerID":"john","accountID":"a2"},"description":"Loan"}`
+	req := httptest.NewRequest("POST", "/transfers", strings.NewReader(body))
+	req.Header.Set("X-Organization", "org1")
+	req.Header.Set("X-Idempotency-Key", "xfer1")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var xfer paygate.Transfer
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&xfer))
+	require.Equal(t, "xfer1", xfer.TransferID)
+	require.Equal(t, paygate.Amount{Currency: "USD", Value: 1204}, xfer.Amount)
+	require.Equal(t, paygate.StatusPending, xfer.Status)
+
+	msg, err := sub.Receive(context.Background())
+	require.NoError(t, err)
+
+	var file incoming.ACHFile
+	require.NoError(t, models.ReadEvent(msg.Body, &file))
+	require.Equal(t, "xfer1", file.FileID)
+	require.Equal(t, "org1", file.ShardKey)
+
+	entries := file.File.Batches[0].GetEntries()
+	require.Len(t, entries, 2)
+	require.Equal(t, ach.CheckingDebit, entries[0].TransactionCode)
+	require.Equal(t, ach.CheckingCredit, entries[1].TransactionCode)
+
+	// Customers which can't be read
+	body = strings.Replace(body, `"jane"`, `"missing"`, 1)
+	req = httptest.NewRequest("POST", "/transfers", strings.NewReader(body))
+	req.Header.Set("X-Organization", "org1")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "reading customer missing")
+
+	// Missing X-Organization
+	req = httptest.NewRequest("POST", "/transfers", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "missing X-Organization header")
+
+	// Cancel the transfer
+	req = httptest.NewRequest("DELETE", "/transfers/xfer1", nil)
+	req.Header.Set("X-Organization", "org1")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	msg, err = sub.Receive(context.Background())
+	require.NoError(t, err)
+
+	var cancel incoming.CancelACHFile
+	require.NoError(t, models.ReadEvent(msg.Body, &cancel))
+	require.Equal(t, "xfer1", cancel.FileID)
+	require.Equal(t, "org1", cancel.ShardKey)
+}
@@ -0,0 +1,88 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/remittance"
+	"github.com/moov-io/achgateway/pkg/models"
+	moovhttp "github.com/moov-io/base/http"
+)
+
+type remittancesResponse struct {
+	Remittances []models.EntryRemittance `json:"remittances"`
+}
+
+// RemittancesHandler reads a Nacha or JSON file with the shard's ValidateOpts and responds with the
+// remittance data parsed from its CCD and CTX addenda. Results can be filtered with ?traceNumber=
+// and ?invoice= (an RMR reference number).
+func (c *FilesController) RemittancesHandler(w http.ResponseWriter, r *http.Request) {
+	opts, ok := c.convertOptions(w, r)
+	if !ok {
+		return
+	}
+
+	bs, err := c.readBody(r)
+	if err != nil {
+		c.logger.LogErrorf("error reading file: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	reader := ach.NewReader(bytes.NewReader(bs))
+	reader.SetValidation(opts)
+	file, err := reader.Read()
+	if err != nil {
+		f, jsonErr := ach.FileFromJSONWith(bs, opts)
+		if f == nil || jsonErr != nil {
+			moovhttp.Problem(w, err)
+			return
+		}
+		file = *f
+	}
+
+	traceNumber, invoice := r.URL.Query().Get("traceNumber"), r.URL.Query().Get("invoice")
+	remittances := make([]models.EntryRemittance, 0)
+	for _, rem := range remittance.File(&file) {
+		if traceNumber != "" && rem.TraceNumber != traceNumber {
+			continue
+		}
+		if invoice != "" && !hasInvoice(rem, invoice) {
+			continue
+		}
+		remittances = append(remittances, rem)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(remittancesResponse{
+		Remittances: remittances,
+	})
+}
+
+func hasInvoice(rem models.EntryRemittance, number string) bool {
+	for i := range rem.Invoices {
+		if rem.Invoices[i].Number == number {
+			return true
+		}
+	}
+	return false
+}
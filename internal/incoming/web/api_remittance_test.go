@@ -0,0 +1,78 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemittancesHandler(t *testing.T) {
+	topic, _ := streamtest.InmemStream(t)
+
+	repo := shards.NewMockRepository()
+	repo.Shards["testing"] = service.ShardMapping{ShardKey: "testing", ShardName: "testing"}
+	sharding := service.Sharding{
+		Shards: []service.Shard{{Name: "testing"}},
+	}
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic).WithShards(repo, sharding)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	bs, err := os.ReadFile(filepath.Join("..", "odfi", "testdata", "ccd-remittance.ach"))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/shards/testing/remittances?invoice=INV-2", bytes.NewReader(bs))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp remittancesResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Remittances, 1)
+	require.Equal(t, "121042880000001", resp.Remittances[0].TraceNumber)
+	require.Len(t, resp.Remittances[0].Invoices, 2)
+
+	// No entries match
+	req = httptest.NewRequest("POST", "/shards/testing/remittances?traceNumber=999", bytes.NewReader(bs))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `{"remittances":[]}`)
+
+	// Invalid file
+	req = httptest.NewRequest("POST", "/shards/testing/remittances", strings.NewReader("invalid"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "error")
+}
@@ -0,0 +1,116 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/schedule"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/settlement"
+	"github.com/moov-io/achgateway/pkg/models"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+)
+
+type settlementsResponse struct {
+	UploadedAt  time.Time                `json:"uploadedAt"`
+	Settlements []models.EntrySettlement `json:"settlements"`
+}
+
+// SettlementsHandler reads a Nacha or JSON file and responds with when each entry is expected to settle.
+// Entries are assumed to be uploaded at the shard's next cutoff window unless ?uploadedAt= (RFC3339) is set.
+func (c *FilesController) SettlementsHandler(w http.ResponseWriter, r *http.Request) {
+	shardKey := mux.Vars(r)["shardKey"]
+	if shardKey == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	shard, err := c.findShard(shardKey)
+	if err != nil {
+		c.logger.Warn().With(log.Fields{
+			"shard_key": log.String(shardKey),
+		}).Logf("finding shard: %v", err)
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	uploadedAt, err := settlementUploadTime(shard, r.URL.Query().Get("uploadedAt"), time.Now())
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	bs, err := c.readBody(r)
+	if err != nil {
+		c.logger.LogErrorf("error reading file: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	file, err := ach.NewReader(bytes.NewReader(bs)).Read()
+	if err != nil {
+		f, err := ach.FileFromJSON(bs)
+		if f == nil || err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		file = *f
+	}
+
+	calc, err := settlement.NewCalculator(shard.Settlement)
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settlementsResponse{
+		UploadedAt:  uploadedAt,
+		Settlements: calc.File(&file, uploadedAt),
+	})
+}
+
+// settlementUploadTime parses value or returns the shard's next cutoff window on a banking day
+func settlementUploadTime(shard *service.Shard, value string, now time.Time) (time.Time, error) {
+	if value != "" {
+		when, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid uploadedAt %q", value)
+		}
+		return when, nil
+	}
+	windows, err := schedule.Upcoming(shard.Cutoffs.Timezone, shard.Cutoffs.Windows, now, 14)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("shard cutoffs: %v", err)
+	}
+	for i := range windows {
+		if windows[i].IsBankingDay {
+			return windows[i].Time, nil
+		}
+	}
+	return time.Time{}, errors.New("no upcoming cutoff window")
+}
@@ -0,0 +1,98 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettlementsHandler(t *testing.T) {
+	topic, _ := streamtest.InmemStream(t)
+
+	repo := shards.NewMockRepository()
+	repo.Shards["testing"] = service.ShardMapping{ShardKey: "testing", ShardName: "testing"}
+	sharding := service.Sharding{
+		Shards: []service.Shard{
+			{
+				Name: "testing",
+				Cutoffs: service.Cutoffs{
+					Timezone: "America/New_York",
+					Windows:  []string{"17:00"},
+				},
+			},
+		},
+	}
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic).WithShards(repo, sharding)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	// The file's effective date has passed, so it settles on the upload day before the same-day cutoff
+	req := httptest.NewRequest("POST", "/shards/testing/settlements?uploadedAt=2023-10-10T14:00:00Z", strings.NewReader(string(bs)))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp settlementsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Settlements, 1)
+	require.Equal(t, "2008-07-30", resp.Settlements[0].EffectiveEntryDate)
+	require.Equal(t, "2023-10-10", resp.Settlements[0].SettlementDate)
+	require.True(t, resp.Settlements[0].SameDay)
+
+	// Default to the next cutoff window, which is after the same-day cutoff
+	req = httptest.NewRequest("POST", "/shards/testing/settlements", strings.NewReader(string(bs)))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	resp = settlementsResponse{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.True(t, resp.UploadedAt.After(time.Now()))
+	require.Equal(t, 17, resp.UploadedAt.Hour())
+	require.False(t, resp.Settlements[0].SameDay)
+
+	// Invalid requests
+	req = httptest.NewRequest("POST", "/shards/testing/settlements?uploadedAt=tomorrow", strings.NewReader(string(bs)))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), `invalid uploadedAt \"tomorrow\"`)
+
+	req = httptest.NewRequest("POST", "/shards/testing/settlements", strings.NewReader("not a file"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
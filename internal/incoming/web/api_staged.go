@@ -0,0 +1,215 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/staging"
+	moovhttp "github.com/moov-io/base/http"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+)
+
+// WithStagedFiles keeps files submitted to the /shards/{shardKey}/staged-files/{fileID} routes in
+// repo until they're committed. The routes are added when Inbound.HTTP.StagedFiles is configured.
+func (c *FilesController) WithStagedFiles(repo staging.Repository) *FilesController {
+	c.stagedFiles = repo
+	return c
+}
+
+// StageFileHandler validates and totals a file like those submitted to CreateFileHandler without
+// publishing it. The response is the staged file with its totals, which can be committed until it
+// expires. Staging a file again replaces it unless it was committed.
+func (c *FilesController) StageFileHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shardKey, fileID := vars["shardKey"], vars["fileID"]
+	if shardKey == "" || fileID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	metadata, err := readMetadata(r)
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+	logger := c.logger.With(log.Fields{
+		"shard_key": log.String(shardKey),
+		"file_id":   log.String(fileID),
+	})
+
+	bs, err := c.readBody(r)
+	if err != nil {
+		logger.LogErrorf("error reading file: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	file, err := ach.NewReader(bytes.NewReader(bs)).Read()
+	if err != nil {
+		f, jsonErr := ach.FileFromJSON(bs)
+		if f == nil || jsonErr != nil {
+			moovhttp.Problem(w, fmt.Errorf("reading file: %v", err))
+			return
+		}
+		file = *f
+	}
+
+	if n, err := c.stagedFiles.DeleteExpired(); err != nil {
+		logger.Warn().Logf("deleting expired staged files: %v", err)
+	} else if n > 0 {
+		logger.Logf("deleted %d expired staged files", n)
+	}
+
+	staged, err := staging.New(shardKey, fileID, &file, metadata, time.Now().Add(c.cfg.StagedFiles.Expires()))
+	if err != nil {
+		moovhttp.Problem(w, err)
+		return
+	}
+	saved, err := c.stagedFiles.Save(staged)
+	if err != nil {
+		logger.LogErrorf("staging file: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !saved {
+		http.Error(w, fmt.Sprintf("file %s was already committed", fileID), http.StatusConflict)
+		return
+	}
+	writeStagedFile(w, staged)
+}
+
+// GetStagedFileHandler responds with a staged file and its totals
+func (c *FilesController) GetStagedFileHandler(w http.ResponseWriter, r *http.Request) {
+	staged, ok := c.findStagedFile(w, r)
+	if !ok {
+		return
+	}
+	writeStagedFile(w, staged)
+}
+
+// CommitStagedFileHandler publishes a staged file for the next cutoff. Committing a file again
+// responds with it unchanged. Expired files respond with 410 Gone and need to be staged again.
+func (c *FilesController) CommitStagedFileHandler(w http.ResponseWriter, r *http.Request) {
+	staged, ok := c.findStagedFile(w, r)
+	if !ok {
+		return
+	}
+	logger := c.logger.With(log.Fields{
+		"shard_key": log.String(staged.ShardKey),
+		"file_id":   log.String(staged.FileID),
+	})
+
+	switch staged.Status {
+	case staging.StatusCommitted:
+		writeStagedFile(w, staged)
+		return
+	case staging.StatusExpired:
+		http.Error(w, fmt.Sprintf("staged file %s expired at %s", staged.FileID, staged.ExpiresAt.Format(time.RFC3339)), http.StatusGone)
+		return
+	}
+
+	file, err := staged.File()
+	if err != nil {
+		logger.LogErrorf("committing staged file: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Claim the file first so concurrent commits don't both publish it
+	committed, err := c.stagedFiles.Commit(staged.ShardKey, staged.FileID)
+	if err != nil {
+		logger.LogErrorf("committing staged file: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !committed {
+		// Another request committed the file or it expired since we read it
+		staged, ok = c.findStagedFile(w, r)
+		if !ok {
+			return
+		}
+		if staged.Status == staging.StatusExpired {
+			http.Error(w, fmt.Sprintf("staged file %s expired at %s", staged.FileID, staged.ExpiresAt.Format(time.RFC3339)), http.StatusGone)
+			return
+		}
+		writeStagedFile(w, staged)
+		return
+	}
+	if err := c.publishFile(staged.ShardKey, staged.FileID, file, staged.Metadata); err != nil {
+		logger.LogErrorf("publishing file: %v", err)
+		if err := c.stagedFiles.Uncommit(staged.ShardKey, staged.FileID, staged.Contents); err != nil {
+			logger.LogErrorf("file %s was not published but is committed: %v", staged.FileID, err)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	c.GetStagedFileHandler(w, r)
+}
+
+// DeleteStagedFileHandler discards a file which wasn't committed
+func (c *FilesController) DeleteStagedFileHandler(w http.ResponseWriter, r *http.Request) {
+	staged, ok := c.findStagedFile(w, r)
+	if !ok {
+		return
+	}
+	if staged.Status == staging.StatusCommitted {
+		http.Error(w, fmt.Sprintf("file %s was already committed", staged.FileID), http.StatusConflict)
+		return
+	}
+	if _, err := c.stagedFiles.Delete(staged.ShardKey, staged.FileID); err != nil {
+		c.logger.LogErrorf("deleting staged file: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// findStagedFile reads the staged file of the request. The response has been written when
+// false is returned.
+func (c *FilesController) findStagedFile(w http.ResponseWriter, r *http.Request) (*staging.StagedFile, bool) {
+	vars := mux.Vars(r)
+	shardKey, fileID := vars["shardKey"], vars["fileID"]
+	if shardKey == "" || fileID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+	staged, err := c.stagedFiles.Get(shardKey, fileID)
+	if err != nil {
+		c.logger.LogErrorf("reading staged file: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	if staged == nil {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return staged, true
+}
+
+func writeStagedFile(w http.ResponseWriter, staged *staging.StagedFile) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(staged)
+}
@@ -0,0 +1,199 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/achgateway/internal/staging"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStagedFilesHandlers(t *testing.T) {
+	topic, sub := streamtest.InmemStream(t)
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	cfg := service.HTTPConfig{
+		StagedFiles: &service.StagedFilesConfig{Expiration: time.Hour},
+	}
+	controller := NewFilesController(log.NewNopLogger(), cfg, topic).
+		WithStagedFiles(staging.NewRepository(db.DB))
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	do := func(method, path string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if body != nil {
+			req = httptest.NewRequest(method, path, bytes.NewReader(body))
+			req.Header.Set(MetadataHeader, "ledger=payroll")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	w := do("POST", "/shards/s1/staged-files/f1", bs)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var staged staging.StagedFile
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&staged))
+	require.Equal(t, staging.StatusStaged, staged.Status)
+	require.Equal(t, 1, staged.EntryCount)
+	require.Equal(t, 10500, staged.Batches[0].DebitTotal)
+	require.Equal(t, "payroll", staged.Metadata["ledger"])
+
+	w = do("GET", "/shards/s1/staged-files/f1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"status":"staged"`)
+
+	w = do("GET", "/shards/s2/staged-files/f1", nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// Invalid files aren't staged
+	w = do("POST", "/shards/s1/staged-files/f2", []byte("not a file"))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "reading file")
+
+	// Committing publishes the file with its metadata
+	w = do("POST", "/shards/s1/staged-files/f1/commit", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"status":"committed"`)
+
+	msg, err := sub.Receive(context.Background())
+	require.NoError(t, err)
+	var file incoming.ACHFile
+	require.NoError(t, models.ReadEvent(msg.Body, &file))
+	require.Equal(t, "f1", file.FileID)
+	require.Equal(t, "s1", file.ShardKey)
+	require.Equal(t, "payroll", file.Metadata["ledger"])
+	require.Len(t, file.File.Batches, 1)
+
+	// Committed files are left as they are
+	w = do("POST", "/shards/s1/staged-files/f1/commit", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = do("POST", "/shards/s1/staged-files/f1", bs)
+	require.Equal(t, http.StatusConflict, w.Code)
+	w = do("DELETE", "/shards/s1/staged-files/f1", nil)
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	// Uncommitted files can be discarded
+	w = do("POST", "/shards/s1/staged-files/f3", bs)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = do("DELETE", "/shards/s1/staged-files/f3", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = do("POST", "/shards/s1/staged-files/f3/commit", nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestStagedFilesHandlers__Expired(t *testing.T) {
+	topic, _ := streamtest.InmemStream(t)
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := staging.NewRepository(db.DB)
+
+	cfg := service.HTTPConfig{StagedFiles: &service.StagedFilesConfig{}}
+	controller := NewFilesController(log.NewNopLogger(), cfg, topic).WithStagedFiles(repo)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	file, err := ach.NewReader(bytes.NewReader(bs)).Read()
+	require.NoError(t, err)
+	staged, err := staging.New("s1", "f1", &file, nil, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	_, err = repo.Save(staged)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/shards/s1/staged-files/f1/commit", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusGone, w.Code)
+	require.Contains(t, w.Body.String(), "expired at")
+}
+
+func TestStagedFilesHandlers__PublishFailure(t *testing.T) {
+	topic, _ := streamtest.InmemStream(t)
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := staging.NewRepository(db.DB)
+
+	cfg := service.HTTPConfig{StagedFiles: &service.StagedFilesConfig{}}
+	controller := NewFilesController(log.NewNopLogger(), cfg, topic).WithStagedFiles(repo)
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	file, err := ach.NewReader(bytes.NewReader(bs)).Read()
+	require.NoError(t, err)
+	staged, err := staging.New("s1", "f1", &file, nil, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = repo.Save(staged)
+	require.NoError(t, err)
+
+	// Files which can't be published are left staged to be committed again
+	require.NoError(t, topic.Shutdown(context.Background()))
+	req := httptest.NewRequest("POST", "/shards/s1/staged-files/f1/commit", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	found, err := repo.Get("s1", "f1")
+	require.NoError(t, err)
+	require.Equal(t, staging.StatusStaged, found.Status)
+	require.Equal(t, staged.Contents, found.Contents)
+}
+
+func TestStagedFilesHandlers__NotConfigured(t *testing.T) {
+	topic, _ := streamtest.InmemStream(t)
+
+	controller := NewFilesController(log.NewNopLogger(), service.HTTPConfig{}, topic).
+		WithShards(shards.NewMockRepository(), service.Sharding{})
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	req := httptest.NewRequest("POST", "/shards/s1/staged-files/f1", bytes.NewReader([]byte("{}")))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,167 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package inspect
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/achgateway/pkg/compliance"
+	"github.com/moov-io/achgateway/pkg/models"
+)
+
+// readOptions describe how a file was protected before it was written.
+type readOptions struct {
+	// storageKey and storageEncoding match Upload.Merging.Storage.Encryption
+	storageKey      string
+	storageEncoding string
+
+	// transformKey and transformBase64 match a Transform block used for events and streams
+	transformKey    string
+	transformBase64 bool
+}
+
+func (opts *readOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&opts.storageKey, "key", "", "Base64 AES key files were written to storage with")
+	fs.StringVar(&opts.storageEncoding, "encoding", "", "Encoding of encrypted storage files, e.g. base64")
+	fs.StringVar(&opts.transformKey, "transform-key", "", "AES key an event or stream payload was encrypted with")
+	fs.BoolVar(&opts.transformBase64, "transform-base64", false, "Event or stream payload is base64 encoded")
+}
+
+func (opts *readOptions) transform() *models.TransformConfig {
+	if opts.transformKey == "" && !opts.transformBase64 {
+		return nil
+	}
+	cfg := &models.TransformConfig{}
+	if opts.transformKey != "" {
+		cfg.Encryption = &models.EncryptionConfig{
+			AES: &models.AESConfig{
+				Key: opts.transformKey,
+			},
+		}
+	}
+	if opts.transformBase64 {
+		cfg.Encoding = &models.EncodingConfig{
+			Base64: true,
+		}
+	}
+	return cfg
+}
+
+// read returns the contents of path with storage encryption and payload transforms removed.
+func (opts *readOptions) read(path string) ([]byte, error) {
+	var bs []byte
+	if opts.storageKey != "" {
+		dir, filename := filepath.Split(path)
+		chest, err := storage.New(storage.Config{
+			Filesystem: storage.FilesystemConfig{
+				Directory: dir,
+			},
+			Encryption: storage.EncryptionConfig{
+				AES: &storage.AESConfig{
+					Base64Key: opts.storageKey,
+				},
+				Encoding: opts.storageEncoding,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		file, err := chest.Open(filename)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting %s: %w", path, err)
+		}
+		if file == nil {
+			return nil, fmt.Errorf("%s not found", path)
+		}
+		defer file.Close()
+
+		bs, err = io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		bs, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bs, err := compliance.Reveal(opts.transform(), bs)
+	if err != nil {
+		return nil, fmt.Errorf("revealing %s: %w", path, err)
+	}
+	return bs, nil
+}
+
+// readACHFile reads path as a Nacha formatted file, JSON file or a QueueACHFile event.
+func (opts *readOptions) readACHFile(path string) (*ach.File, error) {
+	bs, err := opts.read(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseACHFile(bs)
+}
+
+func parseACHFile(bs []byte) (*ach.File, error) {
+	file, nachaErr := ach.NewReader(bytes.NewReader(bs)).Read()
+	if nachaErr == nil {
+		return &file, nil
+	}
+
+	if f, err := ach.FileFromJSON(bs); err == nil && f != nil {
+		return f, nil
+	}
+
+	var evt models.QueueACHFile
+	if err := models.ReadEvent(bs, &evt); err == nil && evt.File != nil {
+		return evt.File, nil
+	}
+
+	return nil, fmt.Errorf("unable to read ACH file: %w", nachaErr)
+}
+
+func runDecrypt(args []string, out io.Writer) error {
+	fs := newFlagSet("decrypt", out)
+	var opts readOptions
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("decrypt needs one file")
+	}
+	if opts.storageKey == "" && opts.transform() == nil {
+		return errors.New("missing -key or -transform-key / -transform-base64")
+	}
+
+	bs, err := opts.read(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(bs)
+	return err
+}
@@ -0,0 +1,170 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package inspect implements the `achgateway files` subcommands which read pending and
+// merged files from storage for incident response.
+package inspect
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+)
+
+const usage = `Usage: achgateway files <command> [flags]
+
+Commands:
+  list     List pending and merged files for a shard
+  decrypt  Decrypt a file or event payload and print it
+  show     Pretty-print the contents of an ACH file
+  diff     Show the differences between two ACH files
+
+Run "achgateway files <command> -help" for each command's flags.
+`
+
+// Run executes the files subcommand named by args[0] and writes its output to out.
+func Run(args []string, out io.Writer, loadConfig service.ConfigLoader) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, usage)
+		return errors.New("missing command")
+	}
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "list":
+		return runList(args, out, loadConfig)
+	case "decrypt":
+		return runDecrypt(args, out)
+	case "show":
+		return runShow(args, out)
+	case "diff":
+		return runDiff(args, out)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(out, usage)
+		return nil
+	}
+	fmt.Fprint(out, usage)
+	return fmt.Errorf("unknown command %q", cmd)
+}
+
+func newFlagSet(name string, out io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("achgateway files "+name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	return fs
+}
+
+func runList(args []string, out io.Writer, loadConfig service.ConfigLoader) error {
+	fs := newFlagSet("list", out)
+	flagShard := fs.String("shard", "", "Shard name to list files for")
+	flagMerged := fs.Bool("merged", false, "Include files isolated by previous cutoffs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *flagShard == "" {
+		return errors.New("missing -shard")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	chest, err := mergingStorage(cfg.Upload)
+	if err != nil {
+		return err
+	}
+
+	files, err := listFiles(chest, *flagShard, *flagMerged)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "Path\tStatus\tModified")
+	for i := range files {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", files[i].path, files[i].status, files[i].modTime.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// mergingStorage opens the storage pending files are written to.
+func mergingStorage(cfg service.UploadAgents) (storage.Chest, error) {
+	storageConfig := cfg.Merging.StorageConfig()
+
+	// Don't create an empty directory when pointed at the wrong place
+	if _, err := os.Stat(storageConfig.Filesystem.Directory); err != nil {
+		return nil, fmt.Errorf("opening merging storage: %w", err)
+	}
+	chest, err := storage.New(storageConfig)
+	if err != nil {
+		return nil, fmt.Errorf("opening merging storage: %w", err)
+	}
+	return chest, nil
+}
+
+type listedFile struct {
+	path    string
+	status  string
+	modTime time.Time
+}
+
+// listFiles returns the shard's pending files and, when merged is set, the files in
+// directories isolated by previous cutoffs.
+func listFiles(chest storage.Chest, shardName string, merged bool) ([]listedFile, error) {
+	patterns := []string{filepath.Join("mergable", shardName, "*")}
+	if merged {
+		patterns = append(patterns, shardName+"-*/*")
+	}
+
+	var out []listedFile
+	for _, pattern := range patterns {
+		matches, err := chest.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", pattern, err)
+		}
+		for i := range matches {
+			path := matches[i].RelativePath
+			status := "pending"
+			switch {
+			case strings.HasSuffix(path, ".canceled"):
+				status = "canceled"
+			case strings.HasSuffix(path, ".json"):
+				status = "validate opts"
+			case !strings.HasPrefix(path, "mergable"):
+				status = "merged"
+			}
+			out = append(out, listedFile{
+				path:    path,
+				status:  status,
+				modTime: matches[i].ModTime,
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].path < out[j].path })
+	return out, nil
+}
@@ -0,0 +1,202 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package inspect
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/achgateway/pkg/compliance"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base"
+
+	"github.com/stretchr/testify/require"
+)
+
+var noConfig = func() (*service.Config, error) {
+	return nil, nil
+}
+
+func TestRun(t *testing.T) {
+	var buf bytes.Buffer
+	require.ErrorContains(t, Run(nil, &buf, noConfig), "missing command")
+	require.Contains(t, buf.String(), "Usage: achgateway files")
+
+	buf.Reset()
+	require.ErrorContains(t, Run([]string{"other"}, &buf, noConfig), `unknown command "other"`)
+
+	buf.Reset()
+	require.NoError(t, Run([]string{"help"}, &buf, noConfig))
+}
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	chest, err := storage.NewFilesystem(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, chest.WriteFile("mergable/testing/pending.ach", []byte("pending")))
+	require.NoError(t, chest.WriteFile("mergable/testing/canceled.ach.canceled", []byte("canceled")))
+	require.NoError(t, chest.WriteFile("mergable/other/other.ach", []byte("other")))
+	require.NoError(t, chest.WriteFile("testing-20221014-103000/merged.ach", []byte("merged")))
+
+	loadConfig := func() (*service.Config, error) {
+		cfg := &service.Config{}
+		cfg.Upload.Merging.Storage.Filesystem.Directory = dir
+		return cfg, nil
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Run([]string{"list", "-shard", "testing"}, &buf, loadConfig))
+	out := buf.String()
+	require.Contains(t, out, "mergable/testing/pending.ach")
+	require.Contains(t, out, "canceled")
+	require.NotContains(t, out, "other.ach")
+	require.NotContains(t, out, "merged.ach")
+
+	buf.Reset()
+	require.NoError(t, Run([]string{"list", "-shard", "testing", "-merged"}, &buf, loadConfig))
+	require.Contains(t, buf.String(), "testing-20221014-103000/merged.ach")
+
+	require.ErrorContains(t, Run([]string{"list"}, &buf, loadConfig), "missing -shard")
+
+	// Missing storage isn't created
+	missing := filepath.Join(dir, "missing")
+	err = Run([]string{"list", "-shard", "testing"}, &buf, func() (*service.Config, error) {
+		cfg := &service.Config{}
+		cfg.Upload.Merging.Storage.Filesystem.Directory = missing
+		return cfg, nil
+	})
+	require.ErrorContains(t, err, "opening merging storage")
+	require.NoDirExists(t, missing)
+}
+
+func TestDecrypt__Storage(t *testing.T) {
+	dir := t.TempDir()
+	key := base64.RawStdEncoding.EncodeToString([]byte(strings.Repeat("1", 32)))
+
+	chest, err := storage.New(storage.Config{
+		Filesystem: storage.FilesystemConfig{Directory: dir},
+		Encryption: storage.EncryptionConfig{
+			AES:      &storage.AESConfig{Base64Key: key},
+			Encoding: "base64",
+		},
+	})
+	require.NoError(t, err)
+
+	nacha, err := os.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	require.NoError(t, chest.WriteFile("mergable/testing/file.ach", nacha))
+
+	path := filepath.Join(dir, "mergable", "testing", "file.ach")
+	onDisk, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotEqual(t, nacha, onDisk)
+
+	var buf bytes.Buffer
+	require.NoError(t, Run([]string{"decrypt", "-key", key, "-encoding", "base64", path}, &buf, noConfig))
+	require.Equal(t, string(nacha), buf.String())
+
+	buf.Reset()
+	require.NoError(t, Run([]string{"show", "-key", key, "-encoding", "base64", "-mask", path}, &buf, noConfig))
+	require.Contains(t, buf.String(), "076401255655291")
+	require.Contains(t, buf.String(), "*2345")
+	require.NotContains(t, buf.String(), "12345")
+
+	require.ErrorContains(t, Run([]string{"decrypt", path}, &buf, noConfig), "missing -key")
+}
+
+func TestDecrypt__Transform(t *testing.T) {
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	cfg := &models.TransformConfig{
+		Encryption: &models.EncryptionConfig{
+			AES: &models.AESConfig{Key: strings.Repeat("1", 16)},
+		},
+		Encoding: &models.EncodingConfig{Base64: true},
+	}
+	bs, err := compliance.Protect(cfg, models.Event{
+		Event: models.QueueACHFile{
+			FileID:   base.ID(),
+			ShardKey: "testing",
+			File:     file,
+		},
+	})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "event.json")
+	require.NoError(t, os.WriteFile(path, bs, 0600))
+
+	var buf bytes.Buffer
+	args := []string{"decrypt", "-transform-key", strings.Repeat("1", 16), "-transform-base64", path}
+	require.NoError(t, Run(args, &buf, noConfig))
+	require.Contains(t, buf.String(), `"QueueACHFile"`)
+
+	// Events holding a file can be shown as well
+	buf.Reset()
+	args[0] = "show"
+	require.NoError(t, Run(args, &buf, noConfig))
+	require.Contains(t, buf.String(), "Bachman Eric")
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	nacha, err := os.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	first := filepath.Join(dir, "first.ach")
+	require.NoError(t, os.WriteFile(first, nacha, 0600))
+
+	var buf bytes.Buffer
+	require.NoError(t, Run([]string{"diff", first, first}, &buf, noConfig))
+	require.Equal(t, "files are identical\n", buf.String())
+
+	// Change the amount of the only entry
+	second := filepath.Join(dir, "second.ach")
+	require.NoError(t, os.WriteFile(second, bytes.ReplaceAll(nacha, []byte("0000010500"), []byte("0000010600")), 0600))
+
+	buf.Reset()
+	require.NoError(t, Run([]string{"diff", first, second}, &buf, noConfig))
+	require.Contains(t, buf.String(), "Amount: 10500 -> 10600")
+	require.Contains(t, buf.String(), "TotalDebitEntryDollarAmountInFile: 10500 -> 10600")
+
+	require.ErrorContains(t, Run([]string{"diff", first}, &buf, noConfig), "diff needs two files")
+}
+
+func TestDiffFiles(t *testing.T) {
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	other, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	other.Batches[0].GetEntries()[0].TraceNumber = "076401250000001"
+
+	diffs := diffFiles(file, other, true)
+	require.Len(t, diffs, 2)
+	require.True(t, strings.HasPrefix(diffs[0], "- Entry 076401255655291"), diffs[0])
+	require.True(t, strings.HasPrefix(diffs[1], "+ Entry 076401250000001"), diffs[1])
+	require.Contains(t, diffs[1], "DFIAccountNumber=*2345 ")
+	require.Contains(t, diffs[1], "IndividualName=********Eric ")
+}
@@ -0,0 +1,209 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package inspect
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/ach/cmd/achcli/describe"
+)
+
+func runShow(args []string, out io.Writer) error {
+	fs := newFlagSet("show", out)
+	var opts readOptions
+	opts.register(fs)
+	flagMask := fs.Bool("mask", false, "Mask account numbers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("show needs at least one file")
+	}
+
+	for i, path := range fs.Args() {
+		file, err := opts.readACHFile(path)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "Describing ACH file '%s'\n\n", path)
+		describe.File(out, file, &describe.Opts{
+			MaskNames:          *flagMask,
+			MaskAccountNumbers: *flagMask,
+		})
+	}
+	return nil
+}
+
+func runDiff(args []string, out io.Writer) error {
+	fs := newFlagSet("diff", out)
+	var opts readOptions
+	opts.register(fs)
+	flagMask := fs.Bool("mask", false, "Mask account numbers and names")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("diff needs two files")
+	}
+
+	first, err := opts.readACHFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	second, err := opts.readACHFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	diffs := diffFiles(first, second, *flagMask)
+	if len(diffs) == 0 {
+		fmt.Fprintln(out, "files are identical")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", fs.Arg(0), fs.Arg(1))
+	for i := range diffs {
+		fmt.Fprintln(w, diffs[i])
+	}
+	return nil
+}
+
+// diffFiles compares the FileHeader, FileControl and each EntryDetail (matched by TraceNumber)
+// and returns a line for each record which was added, removed or changed.
+func diffFiles(first, second *ach.File, mask bool) []string {
+	var out []string
+
+	out = append(out, diffFields("FileHeader", fileHeaderFields(first), fileHeaderFields(second))...)
+	out = append(out, diffFields("FileControl", fileControlFields(first), fileControlFields(second))...)
+
+	oldEntries, oldOrder := entriesByTrace(first)
+	newEntries, newOrder := entriesByTrace(second)
+
+	for _, trace := range oldOrder {
+		old := oldEntries[trace]
+		updated, exists := newEntries[trace]
+		if !exists {
+			out = append(out, fmt.Sprintf("- Entry %s\t%s", trace, describeEntry(old, mask)))
+			continue
+		}
+		out = append(out, diffFields("Entry "+trace, entryFields(old, mask), entryFields(updated, mask))...)
+	}
+	for _, trace := range newOrder {
+		if _, exists := oldEntries[trace]; !exists {
+			out = append(out, fmt.Sprintf("+ Entry %s\t%s", trace, describeEntry(newEntries[trace], mask)))
+		}
+	}
+	return out
+}
+
+type field struct {
+	name  string
+	value string
+}
+
+func diffFields(record string, old, updated []field) []string {
+	var out []string
+	for i := range old {
+		if old[i].value != updated[i].value {
+			out = append(out, fmt.Sprintf("~ %s\t%s: %s -> %s", record, old[i].name, old[i].value, updated[i].value))
+		}
+	}
+	return out
+}
+
+func fileHeaderFields(file *ach.File) []field {
+	fh := file.Header
+	return []field{
+		{"ImmediateOrigin", fh.ImmediateOrigin},
+		{"ImmediateOriginName", fh.ImmediateOriginName},
+		{"ImmediateDestination", fh.ImmediateDestination},
+		{"ImmediateDestinationName", fh.ImmediateDestinationName},
+		{"FileCreationDate", fh.FileCreationDate},
+		{"FileCreationTime", fh.FileCreationTime},
+		{"FileIDModifier", fh.FileIDModifier},
+	}
+}
+
+func fileControlFields(file *ach.File) []field {
+	fc := file.Control
+	return []field{
+		{"BatchCount", fmt.Sprintf("%d", fc.BatchCount)},
+		{"EntryAddendaCount", fmt.Sprintf("%d", fc.EntryAddendaCount)},
+		{"TotalDebitEntryDollarAmountInFile", fmt.Sprintf("%d", fc.TotalDebitEntryDollarAmountInFile)},
+		{"TotalCreditEntryDollarAmountInFile", fmt.Sprintf("%d", fc.TotalCreditEntryDollarAmountInFile)},
+	}
+}
+
+func entryFields(entry *ach.EntryDetail, mask bool) []field {
+	account, name := entry.DFIAccountNumber, entry.IndividualName
+	if mask {
+		account, name = maskValue(account), maskValue(name)
+	}
+	return []field{
+		{"TransactionCode", fmt.Sprintf("%d", entry.TransactionCode)},
+		{"RDFIIdentification", entry.RDFIIdentification + entry.CheckDigit},
+		{"DFIAccountNumber", strings.TrimSpace(account)},
+		{"Amount", fmt.Sprintf("%d", entry.Amount)},
+		{"IdentificationNumber", strings.TrimSpace(entry.IdentificationNumber)},
+		{"IndividualName", strings.TrimSpace(name)},
+		{"AddendaRecordIndicator", fmt.Sprintf("%d", entry.AddendaRecordIndicator)},
+	}
+}
+
+func describeEntry(entry *ach.EntryDetail, mask bool) string {
+	fields := entryFields(entry, mask)
+	parts := make([]string, len(fields))
+	for i := range fields {
+		parts[i] = fmt.Sprintf("%s=%s", fields[i].name, fields[i].value)
+	}
+	return strings.Join(parts, " ")
+}
+
+func entriesByTrace(file *ach.File) (map[string]*ach.EntryDetail, []string) {
+	entries := make(map[string]*ach.EntryDetail)
+	var order []string
+	for _, batch := range file.Batches {
+		for _, entry := range batch.GetEntries() {
+			if _, exists := entries[entry.TraceNumber]; !exists {
+				order = append(order, entry.TraceNumber)
+			}
+			entries[entry.TraceNumber] = entry
+		}
+	}
+	return entries, order
+}
+
+// maskValue keeps the last four characters of value, like describe does for account numbers.
+func maskValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) <= 4 {
+		return strings.Repeat("*", len(value))
+	}
+	return strings.Repeat("*", len(value)-4) + value[len(value)-4:]
+}
@@ -0,0 +1,258 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package keys
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"golang.org/x/crypto/ssh"
+)
+
+// aesKeySize is the size of generated AES keys, which selects AES-256.
+const aesKeySize = 32
+
+// transformKeyChars are used for transform keys, which are read as raw bytes from the config.
+const transformKeyChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func runGenerateAES(args []string, out io.Writer) error {
+	fs := newFlagSet("generate aes", out)
+	flagFor := fs.String("for", "storage", "Where the key is used: storage (Upload.Merging.Storage) or transform (Events and Inbound Transform)")
+	flagKeyID := fs.String("key-id", "", "Optional KeyID written alongside encrypted data so the key can be found after rotating")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch *flagFor {
+	case "storage":
+		key, err := generateStorageKey()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "# Upload.Merging.Storage.Encryption")
+		fmt.Fprintln(out, "AES:")
+		if *flagKeyID != "" {
+			fmt.Fprintf(out, "  KeyID: %q\n", *flagKeyID)
+		}
+		fmt.Fprintf(out, "  Base64Key: %q\n", key)
+
+	case "transform":
+		key, err := generateTransformKey()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "# Events.Transform.Encryption or Inbound Transform.Encryption")
+		fmt.Fprintln(out, "AES:")
+		if *flagKeyID != "" {
+			fmt.Fprintf(out, "  KeyID: %q\n", *flagKeyID)
+		}
+		fmt.Fprintf(out, "  Key: %q\n", key)
+
+	default:
+		return fmt.Errorf("unknown -for %q", *flagFor)
+	}
+	return nil
+}
+
+// generateStorageKey returns a random AES key encoded as storage.AESConfig.Base64Key expects
+func generateStorageKey() (string, error) {
+	key := make([]byte, aesKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(key), nil
+}
+
+// generateTransformKey returns a random AES key of printable characters as models.AESConfig.Key expects
+func generateTransformKey() (string, error) {
+	max := big.NewInt(int64(len(transformKeyChars)))
+	key := make([]byte, aesKeySize)
+	for i := range key {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		key[i] = transformKeyChars[n.Int64()]
+	}
+	return string(key), nil
+}
+
+func runGenerateGPG(args []string, out io.Writer) error {
+	fs := newFlagSet("generate gpg", out)
+	flagName := fs.String("name", "achgateway", "Name of the key's identity")
+	flagEmail := fs.String("email", "", "Email of the key's identity")
+	flagComment := fs.String("comment", "", "Comment of the key's identity")
+	flagBits := fs.Int("bits", 4096, "RSA key size")
+	flagPassword := fs.String("password", "", "Password to protect the private key with")
+	flagOut := fs.String("out", "achgateway", "Filename prefix, writes <out>.pub and <out>.priv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	pub, priv, err := generateGPG(*flagName, *flagComment, *flagEmail, *flagBits, []byte(*flagPassword))
+	if err != nil {
+		return err
+	}
+	if err := writeKeyPair(*flagOut, pub, priv); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Wrote %s.pub and %s.priv\n\n", *flagOut, *flagOut)
+	fmt.Fprintln(out, "# Encrypt files with the public key (PreUpload.GPG and Audit.GPG)")
+	fmt.Fprintln(out, "GPG:")
+	fmt.Fprintf(out, "  KeyFile: %q\n", *flagOut+".pub")
+	fmt.Fprintln(out, "  # Sign files with the private key")
+	fmt.Fprintln(out, "  Signer:")
+	fmt.Fprintf(out, "    KeyFile: %q\n", *flagOut+".priv")
+	if *flagPassword != "" {
+		fmt.Fprintln(out, "    KeyPassword: <password>")
+	}
+	return nil
+}
+
+// generateGPG returns an armored public and private key. The private key is encrypted
+// with password when one is given.
+func generateGPG(name, comment, email string, bits int, password []byte) ([]byte, []byte, error) {
+	if name == "" && email == "" {
+		return nil, nil, errors.New("missing -name or -email")
+	}
+	entity, err := openpgp.NewEntity(name, comment, email, &packet.Config{
+		Algorithm: packet.PubKeyAlgoRSA,
+		RSABits:   bits,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating gpg key: %v", err)
+	}
+
+	var pub bytes.Buffer
+	w, err := armor.Encode(&pub, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := entity.Serialize(w); err != nil {
+		return nil, nil, err
+	}
+	w.Close()
+
+	if len(password) > 0 {
+		if err := entity.PrivateKey.Encrypt(password); err != nil {
+			return nil, nil, fmt.Errorf("encrypting private key: %v", err)
+		}
+		for i := range entity.Subkeys {
+			if err := entity.Subkeys[i].PrivateKey.Encrypt(password); err != nil {
+				return nil, nil, fmt.Errorf("encrypting private subkey: %v", err)
+			}
+		}
+	}
+
+	var priv bytes.Buffer
+	w, err = armor.Encode(&priv, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Identities were signed by NewEntity, so they don't need the (possibly encrypted) key again
+	if err := entity.SerializePrivateWithoutSigning(w, nil); err != nil {
+		return nil, nil, err
+	}
+	w.Close()
+
+	return pub.Bytes(), priv.Bytes(), nil
+}
+
+func runGenerateSSH(args []string, out io.Writer) error {
+	fs := newFlagSet("generate ssh", out)
+	flagType := fs.String("type", "ed25519", "Key type: ed25519 or rsa")
+	flagBits := fs.Int("bits", 4096, "RSA key size")
+	flagOut := fs.String("out", "achgateway", "Filename prefix, writes <out>.pub and <out>.priv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	pub, priv, err := generateSSH(*flagType, *flagBits)
+	if err != nil {
+		return err
+	}
+	if err := writeKeyPair(*flagOut, pub, priv); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Wrote %s.pub and %s.priv\n\n", *flagOut, *flagOut)
+	fmt.Fprintf(out, "Add %s.pub to the SFTP server's authorized_keys.\n\n", *flagOut)
+	fmt.Fprintln(out, "# Upload.Agents[].SFTP")
+	fmt.Fprintf(out, "ClientPrivateKey: %q\n", base64.StdEncoding.EncodeToString(priv))
+	fmt.Fprintln(out, "# When the keypair is the SFTP server's host key")
+	fmt.Fprintf(out, "HostPublicKey: %q\n", base64.StdEncoding.EncodeToString(pub))
+	return nil
+}
+
+// generateSSH returns a public key in authorized_keys format and a PEM encoded private key
+func generateSSH(keyType string, bits int) ([]byte, []byte, error) {
+	var public interface{}
+	var block *pem.Block
+
+	switch keyType {
+	case "ed25519":
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			return nil, nil, err
+		}
+		public = pub
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+
+	case "rsa":
+		priv, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, nil, err
+		}
+		public = &priv.PublicKey
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}
+
+	default:
+		return nil, nil, fmt.Errorf("unknown -type %q", keyType)
+	}
+
+	sshPub, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ssh.MarshalAuthorizedKey(sshPub), pem.EncodeToMemory(block), nil
+}
+
+func writeKeyPair(prefix string, pub, priv []byte) error {
+	if err := os.WriteFile(prefix+".pub", pub, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(prefix+".priv", priv, 0600)
+}
@@ -0,0 +1,64 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package keys implements `achgateway keys` which generates keys in the formats achgateway
+// reads and re-encrypts files on storage when rotating keys.
+package keys
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/moov-io/achgateway/internal/service"
+)
+
+const usage = `Usage: achgateway keys <command> [flags]
+
+Commands:
+  generate aes   Generate an AES key for storage or event/inbound transform encryption
+  generate gpg   Generate a GPG keypair for PreUpload, Audit and Inbound GPG settings
+  generate ssh   Generate an SSH keypair for SFTP ClientPrivateKey and HostPublicKey
+  rotate storage Re-encrypt files on Upload.Merging.Storage with a new AES key
+`
+
+// Run executes the keys subcommand named by args and writes its output to out.
+func Run(args []string, out io.Writer, loadConfig service.ConfigLoader) error {
+	if len(args) < 2 {
+		fmt.Fprint(out, usage)
+		return errors.New("missing command")
+	}
+	switch args[0] + " " + args[1] {
+	case "generate aes":
+		return runGenerateAES(args[2:], out)
+	case "generate gpg":
+		return runGenerateGPG(args[2:], out)
+	case "generate ssh":
+		return runGenerateSSH(args[2:], out)
+	case "rotate storage":
+		return runRotateStorage(args[2:], out, loadConfig)
+	}
+	fmt.Fprint(out, usage)
+	return fmt.Errorf("unknown command %q", args[0]+" "+args[1])
+}
+
+func newFlagSet(name string, out io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("achgateway keys "+name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	return fs
+}
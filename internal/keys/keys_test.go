@@ -0,0 +1,197 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package keys
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/sshx"
+	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/cryptfs"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAES(t *testing.T) {
+	key, err := generateStorageKey()
+	require.NoError(t, err)
+
+	decoded, err := base64.RawStdEncoding.DecodeString(key)
+	require.NoError(t, err)
+	require.Len(t, decoded, 32)
+
+	// storage accepts the key
+	_, err = storage.New(storage.Config{
+		Filesystem: storage.FilesystemConfig{Directory: t.TempDir()},
+		Encryption: storage.EncryptionConfig{
+			AES: &storage.AESConfig{Base64Key: key},
+		},
+	})
+	require.NoError(t, err)
+
+	key, err = generateTransformKey()
+	require.NoError(t, err)
+	require.Len(t, key, 32)
+
+	var buf bytes.Buffer
+	require.NoError(t, Run([]string{"generate", "aes", "-key-id", "2022-10"}, &buf, nil))
+	require.Contains(t, buf.String(), `KeyID: "2022-10"`)
+	require.Contains(t, buf.String(), "Base64Key: ")
+
+	buf.Reset()
+	require.NoError(t, Run([]string{"generate", "aes", "-for", "transform"}, &buf, nil))
+	require.Contains(t, buf.String(), "  Key: ")
+
+	err = Run([]string{"generate", "aes", "-for", "other"}, &buf, nil)
+	require.ErrorContains(t, err, `unknown -for "other"`)
+}
+
+func TestGenerateGPG(t *testing.T) {
+	if testing.Short() {
+		t.Skip("-short flag enabled")
+	}
+
+	prefix := filepath.Join(t.TempDir(), "audit")
+	args := []string{"generate", "gpg", "-email", "ops@moov.io", "-bits", "2048", "-password", "secret", "-out", prefix}
+
+	var buf bytes.Buffer
+	require.NoError(t, Run(args, &buf, nil))
+	require.Contains(t, buf.String(), prefix+".pub")
+
+	crypt, err := cryptfs.FromCryptor(cryptfs.NewGPGCryptorFile(prefix+".pub", prefix+".priv", []byte("secret")))
+	require.NoError(t, err)
+
+	encrypted, err := crypt.Disfigure([]byte("hello, world"))
+	require.NoError(t, err)
+	decrypted, err := crypt.Reveal(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, "hello, world", string(decrypted))
+
+	info, err := os.Stat(prefix + ".priv")
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestGenerateSSH(t *testing.T) {
+	for _, keyType := range []string{"ed25519", "rsa"} {
+		t.Run(keyType, func(t *testing.T) {
+			pub, priv, err := generateSSH(keyType, 2048)
+			require.NoError(t, err)
+
+			signer, err := sshx.ReadSigner(base64.StdEncoding.EncodeToString(priv))
+			require.NoError(t, err)
+
+			pubKey, err := sshx.ReadPubKey(pub)
+			require.NoError(t, err)
+			require.Equal(t, signer.PublicKey().Marshal(), pubKey.Marshal())
+
+			pubKey, err = sshx.ReadPubKey([]byte(base64.StdEncoding.EncodeToString(pub)))
+			require.NoError(t, err)
+			require.Equal(t, signer.PublicKey().Marshal(), pubKey.Marshal())
+		})
+	}
+
+	_, _, err := generateSSH("dsa", 0)
+	require.ErrorContains(t, err, `unknown -type "dsa"`)
+}
+
+func TestRotateStorage(t *testing.T) {
+	dir := t.TempDir()
+	oldKey, err := generateStorageKey()
+	require.NoError(t, err)
+	newKey, err := generateStorageKey()
+	require.NoError(t, err)
+
+	oldCfg := storage.Config{
+		Filesystem: storage.FilesystemConfig{Directory: dir},
+		Encryption: storage.EncryptionConfig{
+			AES: &storage.AESConfig{KeyID: "old", Base64Key: oldKey},
+		},
+	}
+	chest, err := storage.New(oldCfg)
+	require.NoError(t, err)
+	require.NoError(t, chest.MkdirAll(filepath.Join("mergable", "testing")))
+	require.NoError(t, chest.WriteFile(filepath.Join("mergable", "testing", "a.ach"), []byte("pending")))
+	require.NoError(t, chest.MkdirAll(filepath.Join("held", "testing")))
+	require.NoError(t, chest.WriteFile(filepath.Join("held", "testing", "b.ach"), []byte("held")))
+
+	loadConfig := func() (*service.Config, error) {
+		return &service.Config{
+			Upload: service.UploadAgents{
+				Merging: service.Merging{Storage: oldCfg},
+			},
+			Sharding: service.Sharding{
+				Shards: []service.Shard{{Name: "testing"}},
+			},
+		}, nil
+	}
+
+	var buf bytes.Buffer
+	err = Run([]string{"rotate", "storage", "-key", newKey, "-key-id", "new"}, &buf, loadConfig)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "re-encrypted 2 files for shard testing")
+	require.Contains(t, buf.String(), `- KeyID: "old"`)
+	require.NotContains(t, buf.String(), newKey)
+
+	// Files are readable with only the new key
+	newChest, err := storage.New(storage.Config{
+		Filesystem: storage.FilesystemConfig{Directory: dir},
+		Encryption: storage.EncryptionConfig{
+			AES: &storage.AESConfig{KeyID: "new", Base64Key: newKey},
+		},
+	})
+	require.NoError(t, err)
+	for path, expected := range map[string]string{
+		filepath.Join("mergable", "testing", "a.ach"): "pending",
+		filepath.Join("held", "testing", "b.ach"):     "held",
+	} {
+		file, err := newChest.Open(path)
+		require.NoError(t, err)
+		bs, err := io.ReadAll(file)
+		require.NoError(t, err)
+		file.Close()
+		require.Equal(t, expected, string(bs))
+	}
+
+	// errors
+	err = Run([]string{"rotate", "storage", "-key", newKey, "-key-id", "old"}, &buf, loadConfig)
+	require.ErrorContains(t, err, `"old" is already the current KeyID`)
+
+	err = Run([]string{"rotate", "storage", "-shard", "other"}, &buf, loadConfig)
+	require.ErrorContains(t, err, "shard other not found")
+
+	_, err = rotatedStorageConfig(storage.Config{}, newKey, "")
+	require.ErrorContains(t, err, "storage is not encrypted")
+}
+
+func TestRun(t *testing.T) {
+	var buf bytes.Buffer
+	err := Run(nil, &buf, nil)
+	require.ErrorContains(t, err, "missing command")
+	require.True(t, strings.HasPrefix(buf.String(), "Usage: achgateway keys"))
+
+	err = Run([]string{"generate", "rot13"}, &buf, nil)
+	require.ErrorContains(t, err, `unknown command "generate rot13"`)
+}
@@ -0,0 +1,136 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/moov-io/achgateway/internal/pipeline"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+)
+
+func runRotateStorage(args []string, out io.Writer, loadConfig service.ConfigLoader) error {
+	fs := newFlagSet("rotate storage", out)
+	flagKey := fs.String("key", "", "New Base64Key to encrypt files with, defaults to the configured Encryption.AES")
+	flagKeyID := fs.String("key-id", "", "KeyID of -key")
+	flagShard := fs.String("shard", "", "Only re-encrypt files for this shard")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %v", err)
+	}
+	storageCfg, err := rotatedStorageConfig(cfg.Upload.Merging.StorageConfig(), *flagKey, *flagKeyID)
+	if err != nil {
+		return err
+	}
+
+	shardNames, err := selectShards(cfg.Sharding, *flagShard)
+	if err != nil {
+		return err
+	}
+
+	// storage.New creates missing directories, so check first rather than leave an empty one behind
+	if _, err := os.Stat(storageCfg.Filesystem.Directory); err != nil {
+		return fmt.Errorf("reading storage: %v", err)
+	}
+	chest, err := storage.New(storageCfg)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, name := range shardNames {
+		n, err := pipeline.ReencryptShardFiles(chest, name)
+		total += n
+		if err != nil {
+			return fmt.Errorf("shard %s: %v", name, err)
+		}
+		fmt.Fprintf(out, "re-encrypted %d files for shard %s\n", n, name)
+	}
+	fmt.Fprintf(out, "re-encrypted %d files in %s\n", total, storageCfg.Filesystem.Directory)
+
+	if *flagKey != "" {
+		fmt.Fprintln(out, "\nUpdate Upload.Merging.Storage.Encryption before restarting achgateway:")
+		fmt.Fprintln(out, "AES:")
+		if *flagKeyID != "" {
+			fmt.Fprintf(out, "  KeyID: %q\n", *flagKeyID)
+		}
+		fmt.Fprintln(out, "  Base64Key: <-key>")
+		fmt.Fprintln(out, "# Keep until every instance has restarted with the new key and been re-encrypted again")
+		fmt.Fprintln(out, "PreviousAES:")
+		for _, prev := range storageCfg.Encryption.PreviousAES {
+			if prev.KeyID != "" {
+				fmt.Fprintf(out, "  - KeyID: %q\n    Base64Key: <previous key>\n", prev.KeyID)
+			} else {
+				fmt.Fprintln(out, "  - Base64Key: <previous key>")
+			}
+		}
+	}
+	return nil
+}
+
+// rotatedStorageConfig makes key the current storage key and keeps the configured keys
+// so existing files can be read. cfg is returned unchanged when key is empty.
+func rotatedStorageConfig(cfg storage.Config, key, keyID string) (storage.Config, error) {
+	current := cfg.Encryption.AES
+	if current == nil {
+		return cfg, errors.New("storage is not encrypted, set Upload.Merging.Storage.Encryption.AES")
+	}
+	if key == "" {
+		return cfg, nil
+	}
+	if keyID != "" && keyID == current.KeyID {
+		return cfg, fmt.Errorf("-key-id %q is already the current KeyID", keyID)
+	}
+	if key == current.Base64Key {
+		return cfg, errors.New("-key is already the current Base64Key")
+	}
+
+	previous := append([]storage.AESConfig{*current}, cfg.Encryption.PreviousAES...)
+	cfg.Encryption.AES = &storage.AESConfig{
+		KeyID:     keyID,
+		Base64Key: key,
+	}
+	cfg.Encryption.PreviousAES = previous
+	return cfg, nil
+}
+
+func selectShards(cfg service.Sharding, name string) ([]string, error) {
+	if name != "" {
+		shard := cfg.Find(name)
+		if shard == nil {
+			return nil, fmt.Errorf("shard %s not found", name)
+		}
+		return []string{shard.Name}, nil
+	}
+	var out []string
+	for i := range cfg.Shards {
+		out = append(out, cfg.Shards[i].Name)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no shards configured")
+	}
+	return out, nil
+}
@@ -0,0 +1,300 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kubelease
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moov-io/base/log"
+)
+
+// Client acquires and renews Leases on behalf of this replica
+type Client struct {
+	cfg    Config
+	logger log.Logger
+	client *http.Client
+	now    func() time.Time
+
+	mu   sync.Mutex
+	held map[string]bool // lease names currently held
+
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	wg           sync.WaitGroup
+}
+
+func NewClient(logger log.Logger, config *Config) (*Client, error) {
+	if config == nil {
+		return nil, errors.New("nil kubernetes lease config")
+	}
+	cfg, err := config.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if bs, err := os.ReadFile(cfg.CAFile); err == nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bs) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	} else if config.CAFile != "" {
+		return nil, fmt.Errorf("reading CAFile: %v", err)
+	}
+
+	c := &Client{
+		cfg: cfg,
+		logger: logger.With(log.Fields{
+			"identity":  log.String(cfg.Identity),
+			"namespace": log.String(cfg.Namespace),
+		}),
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   10 * time.Second,
+		},
+		now:      time.Now,
+		held:     make(map[string]bool),
+		shutdown: make(chan struct{}),
+	}
+
+	c.wg.Add(1)
+	go c.renewHeldLeases()
+
+	return c, nil
+}
+
+// Identity is the holder written to Leases acquired by this replica
+func (c *Client) Identity() string {
+	return c.cfg.Identity
+}
+
+// AcquireLock returns nil when this replica holds (or has now acquired) the Lease for key.
+func (c *Client) AcquireLock(key string) error {
+	name := leaseName(key)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := c.acquire(ctx, name)
+	c.mu.Lock()
+	c.held[name] = (err == nil)
+	c.mu.Unlock()
+	return err
+}
+
+func (c *Client) acquire(ctx context.Context, name string) error {
+	now := c.now()
+	current, err := c.getLease(ctx, name)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		lease := &lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   leaseMetadata{Name: name, Namespace: c.cfg.Namespace},
+			Spec: leaseSpec{
+				HolderIdentity:       c.cfg.Identity,
+				LeaseDurationSeconds: int(c.cfg.LeaseDuration.Seconds()),
+				AcquireTime:          microTime(now),
+				RenewTime:            microTime(now),
+			},
+		}
+		return c.writeLease(ctx, "POST", c.leasesURL(), lease, name)
+	}
+
+	spec := &current.Spec
+	if spec.HolderIdentity != c.cfg.Identity {
+		if spec.HolderIdentity != "" && !spec.expired(now) {
+			return fmt.Errorf("we are not the leader of %s (held by %s)", name, spec.HolderIdentity)
+		}
+		spec.HolderIdentity = c.cfg.Identity
+		spec.AcquireTime = microTime(now)
+		spec.LeaseTransitions++
+	}
+	spec.LeaseDurationSeconds = int(c.cfg.LeaseDuration.Seconds())
+	spec.RenewTime = microTime(now)
+	return c.writeLease(ctx, "PUT", c.leasesURL()+"/"+name, current, name)
+}
+
+// renewHeldLeases keeps leadership of each held Lease between calls to AcquireLock
+func (c *Client) renewHeldLeases() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.cfg.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			var names []string
+			for name, held := range c.held {
+				if held {
+					names = append(names, name)
+				}
+			}
+			c.mu.Unlock()
+
+			for _, name := range names {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				err := c.acquire(ctx, name)
+				cancel()
+				if err != nil {
+					c.logger.Warn().Logf("lost lease %s: %v", name, err)
+					c.mu.Lock()
+					c.held[name] = false
+					c.mu.Unlock()
+				}
+			}
+
+		case <-c.shutdown:
+			return
+		}
+	}
+}
+
+// Shutdown stops renewing and releases every held Lease so another replica can take over
+func (c *Client) Shutdown() {
+	if c == nil {
+		return
+	}
+	c.shutdownOnce.Do(func() {
+		close(c.shutdown)
+	})
+	c.wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, held := range c.held {
+		if !held {
+			continue
+		}
+		if err := c.release(name); err != nil {
+			c.logger.Warn().Logf("releasing lease %s: %v", name, err)
+		}
+		c.held[name] = false
+	}
+}
+
+func (c *Client) release(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	current, err := c.getLease(ctx, name)
+	if err != nil || current == nil || current.Spec.HolderIdentity != c.cfg.Identity {
+		return err
+	}
+	current.Spec.HolderIdentity = ""
+	return c.writeLease(ctx, "PUT", c.leasesURL()+"/"+name, current, name)
+}
+
+func (c *Client) leasesURL() string {
+	return fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", strings.TrimSuffix(c.cfg.APIServer, "/"), c.cfg.Namespace)
+}
+
+func (c *Client) getLease(ctx context.Context, name string) (*lease, error) {
+	resp, err := c.do(ctx, "GET", c.leasesURL()+"/"+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var out lease
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return nil, fmt.Errorf("reading lease %s: %v", name, err)
+		}
+		return &out, nil
+	case http.StatusNotFound:
+		return nil, nil
+	}
+	return nil, unexpected(resp, "getting lease "+name)
+}
+
+// writeLease creates or updates a Lease. Kubernetes rejects writes with a stale resourceVersion
+// as a conflict, which means another replica acquired the Lease first.
+func (c *Client) writeLease(ctx context.Context, method, url string, l *lease, name string) error {
+	bs, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, method, url, bs)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	case http.StatusConflict:
+		return fmt.Errorf("we are not the leader of %s (lease was updated by another replica)", name)
+	}
+	return unexpected(resp, "writing lease "+name)
+}
+
+func (c *Client) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	// Service account tokens are rotated, so read the token for every request
+	if token, err := os.ReadFile(c.cfg.TokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return c.client.Do(req)
+}
+
+func unexpected(resp *http.Response, action string) error {
+	bs, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s: unexpected %s: %s", action, resp.Status, strings.TrimSpace(string(bs)))
+}
+
+var invalidNameCharacters = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// leaseName converts a leader key (like achgateway/outbound/live) into a valid object name
+func leaseName(key string) string {
+	name := invalidNameCharacters.ReplaceAllString(strings.ToLower(key), "-")
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return strings.Trim(name, "-.")
+}
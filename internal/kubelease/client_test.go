@@ -0,0 +1,157 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kubelease
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPIServer stores Leases and rejects writes with a stale resourceVersion like Kubernetes
+type fakeAPIServer struct {
+	mu      sync.Mutex
+	leases  map[string]lease
+	version int
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := "/apis/coordination.k8s.io/v1/namespaces/achgateway/leases"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+	switch r.Method {
+	case "GET":
+		l, exists := s.leases[name]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(l)
+
+	case "POST", "PUT":
+		var l lease
+		json.NewDecoder(r.Body).Decode(&l)
+		existing, exists := s.leases[l.Metadata.Name]
+		if r.Method == "POST" && exists {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if r.Method == "PUT" && (!exists || existing.Metadata.ResourceVersion != l.Metadata.ResourceVersion) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.version++
+		l.Metadata.ResourceVersion = strconv.Itoa(s.version)
+		s.leases[l.Metadata.Name] = l
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(l)
+	}
+}
+
+func newTestClient(t *testing.T, server *httptest.Server, identity string) *Client {
+	t.Helper()
+
+	client, err := NewClient(log.NewTestLogger(), &Config{
+		Namespace:     "achgateway",
+		Identity:      identity,
+		LeaseDuration: time.Minute,
+		APIServer:     server.URL,
+		TokenFile:     "/does/not/exist",
+		CAFile:        "",
+	})
+	require.NoError(t, err)
+	t.Cleanup(client.Shutdown)
+	return client
+}
+
+func TestClient__AcquireLock(t *testing.T) {
+	api := &fakeAPIServer{leases: make(map[string]lease)}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	a := newTestClient(t, server, "pod-a")
+	b := newTestClient(t, server, "pod-b")
+
+	key := "achgateway/outbound/live"
+	require.NoError(t, a.AcquireLock(key))
+	require.NoError(t, a.AcquireLock(key)) // renewed
+	require.ErrorContains(t, b.AcquireLock(key), "we are not the leader of achgateway-outbound-live (held by pod-a)")
+
+	l := api.leases["achgateway-outbound-live"]
+	require.Equal(t, "pod-a", l.Spec.HolderIdentity)
+	require.Equal(t, 60, l.Spec.LeaseDurationSeconds)
+
+	// Expired leases are taken over
+	b.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	require.NoError(t, b.AcquireLock(key))
+	require.Equal(t, "pod-b", api.leases["achgateway-outbound-live"].Spec.HolderIdentity)
+	require.Equal(t, 1, api.leases["achgateway-outbound-live"].Spec.LeaseTransitions)
+	require.Error(t, a.AcquireLock(key))
+
+	// Released on shutdown
+	b.Shutdown()
+	require.Empty(t, api.leases["achgateway-outbound-live"].Spec.HolderIdentity)
+	require.NoError(t, a.AcquireLock(key))
+}
+
+func TestClient__Conflict(t *testing.T) {
+	api := &fakeAPIServer{leases: make(map[string]lease)}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	a := newTestClient(t, server, "pod-a")
+	require.NoError(t, a.AcquireLock("achgateway/odfi/live"))
+
+	// Another replica wrote the lease after we read it
+	l := api.leases["achgateway-odfi-live"]
+	l.Metadata.ResourceVersion = "stale"
+	err := a.writeLease(context.Background(), "PUT", a.leasesURL()+"/achgateway-odfi-live", &l, "achgateway-odfi-live")
+	require.ErrorContains(t, err, "lease was updated by another replica")
+}
+
+func TestLeaseName(t *testing.T) {
+	require.Equal(t, "achgateway-outbound-ftp-live", leaseName("achgateway/outbound/FTP_live"))
+	require.Equal(t, "achgateway-odfi-live", leaseName("/achgateway/odfi/live/"))
+}
+
+func TestConfig__Validate(t *testing.T) {
+	var cfg *Config
+	require.NoError(t, cfg.Validate())
+
+	cfg = &Config{LeaseDuration: time.Millisecond}
+	require.ErrorContains(t, cfg.Validate(), "invalid LeaseDuration 1ms")
+}
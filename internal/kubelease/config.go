@@ -0,0 +1,98 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package kubelease elects leaders with Kubernetes Leases (coordination.k8s.io/v1) so replicas
+// can agree on a single uploader for each shard without running Consul.
+package kubelease
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	defaultLeaseDuration = 60 * time.Second
+)
+
+type Config struct {
+	// Namespace the Leases are created in. Defaults to the namespace of the pod.
+	Namespace string
+
+	// Identity is written as the Lease's holder. Defaults to the hostname, which is the pod's name.
+	Identity string
+
+	// LeaseDuration is how long a Lease is held without being renewed. Held Leases are renewed
+	// every third of LeaseDuration. Defaults to 60s
+	LeaseDuration time.Duration
+
+	// APIServer is the Kubernetes API server, which defaults to the in-cluster address.
+	APIServer string
+
+	// TokenFile and CAFile default to the pod's service account
+	TokenFile string
+	CAFile    string
+}
+
+func (cfg *Config) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.LeaseDuration < 0 || (cfg.LeaseDuration > 0 && cfg.LeaseDuration < time.Second) {
+		return fmt.Errorf("invalid LeaseDuration %v", cfg.LeaseDuration)
+	}
+	return nil
+}
+
+// withDefaults fills in the in-cluster settings of the pod
+func (cfg Config) withDefaults() (Config, error) {
+	if cfg.Namespace == "" {
+		bs, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return cfg, fmt.Errorf("missing Namespace: %v", err)
+		}
+		cfg.Namespace = strings.TrimSpace(string(bs))
+	}
+	if cfg.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return cfg, fmt.Errorf("missing Identity: %v", err)
+		}
+		cfg.Identity = hostname
+	}
+	if cfg.LeaseDuration == 0 {
+		cfg.LeaseDuration = defaultLeaseDuration
+	}
+	if cfg.APIServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return cfg, errors.New("missing APIServer and not running in a Kubernetes cluster")
+		}
+		cfg.APIServer = "https://" + host + ":" + port
+	}
+	if cfg.TokenFile == "" {
+		cfg.TokenFile = serviceAccountDir + "/token"
+	}
+	if cfg.CAFile == "" {
+		cfg.CAFile = serviceAccountDir + "/ca.crt"
+	}
+	return cfg, nil
+}
@@ -0,0 +1,74 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kubelease
+
+import (
+	"time"
+)
+
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string    `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int       `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          microTime `json:"acquireTime,omitempty"`
+	RenewTime            microTime `json:"renewTime,omitempty"`
+	LeaseTransitions     int       `json:"leaseTransitions,omitempty"`
+}
+
+func (spec leaseSpec) expired(now time.Time) bool {
+	renewed := time.Time(spec.RenewTime)
+	return renewed.IsZero() || now.After(renewed.Add(time.Duration(spec.LeaseDurationSeconds)*time.Second))
+}
+
+// microTime is the RFC 3339 timestamp with microseconds used by Kubernetes
+type microTime time.Time
+
+const microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+func (t microTime) MarshalJSON() ([]byte, error) {
+	if time.Time(t).IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + time.Time(t).UTC().Format(microTimeFormat) + `"`), nil
+}
+
+func (t *microTime) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if value == "null" || value == `""` {
+		*t = microTime{}
+		return nil
+	}
+	when, err := time.Parse(`"`+time.RFC3339Nano+`"`, value)
+	if err != nil {
+		return err
+	}
+	*t = microTime(when)
+	return nil
+}
@@ -0,0 +1,202 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package leadership elects a single replica to upload each shard's files and process its ODFI
+// files. Leaders are elected with Consul sessions, Kubernetes Leases, database locks or Redis
+// keys, and without any of them every replica acts as the leader.
+package leadership
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/consul"
+	"github.com/moov-io/achgateway/internal/dblock"
+	"github.com/moov-io/achgateway/internal/kubelease"
+	"github.com/moov-io/achgateway/internal/redis"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	isLeader = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Name: "leadership_is_leader",
+		Help: "Gauge of 1 when this replica is the leader of a key, otherwise 0",
+	}, []string{"key"})
+)
+
+// Elector is implemented by each leader election backend
+type Elector interface {
+	// AcquireLock returns nil when this replica is the leader of key
+	AcquireLock(key string) error
+}
+
+// Consul elects leaders with Consul sessions, returning nil when client is nil
+func Consul(logger log.Logger, client *consul.Client) Elector {
+	if client == nil {
+		return nil
+	}
+	return &consulElector{logger: logger, client: client}
+}
+
+type consulElector struct {
+	logger log.Logger
+	client *consul.Client
+}
+
+func (e *consulElector) AcquireLock(key string) error {
+	return consul.AcquireLock(e.logger, e.client, key)
+}
+
+// Shutdown destroys the session, which releases every lock it holds
+func (e *consulElector) Shutdown() {
+	e.client.Shutdown()
+}
+
+// Kubernetes elects leaders with Kubernetes Leases, returning nil when client is nil
+func Kubernetes(client *kubelease.Client) Elector {
+	if client == nil {
+		return nil
+	}
+	return client
+}
+
+// Database elects leaders with locks in the achgateway database, returning nil when client is nil
+func Database(client *dblock.Client) Elector {
+	if client == nil {
+		return nil
+	}
+	return client
+}
+
+// Redis elects leaders with keys in Redis which expire unless they're renewed, returning nil when locker is nil
+func Redis(locker *redis.Locker) Elector {
+	if locker == nil {
+		return nil
+	}
+	return locker
+}
+
+// AcquireLock returns nil when this replica is the leader of key, which is always true when
+// elector is nil. The result is recorded for metrics and the admin API.
+func AcquireLock(elector Elector, key string) error {
+	if elector == nil {
+		return nil // no leader election, skip
+	}
+	err := elector.AcquireLock(key)
+	record(key, err)
+	return err
+}
+
+// Release gives up every key this replica leads so other replicas can take over, used when
+// the replica is about to stop. Electors release keys by stopping, so keys must not be
+// acquired again afterwards.
+func Release(elector Elector) {
+	if e, ok := elector.(interface{ Shutdown() }); ok {
+		e.Shutdown()
+	}
+
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	for key, status := range statuses {
+		if status.Leader {
+			status.Leader = false
+			status.Error = "released"
+			status.CheckedAt = time.Now()
+			statuses[key] = status
+			isLeader.With("key", key).Set(0)
+		}
+	}
+}
+
+// Status is the most recent election of a key by this replica
+type Status struct {
+	Key       string    `json:"key"`
+	Leader    bool      `json:"leader"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var (
+	statusMu sync.Mutex
+	statuses = make(map[string]Status)
+)
+
+func record(key string, err error) {
+	status := Status{
+		Key:       key,
+		Leader:    err == nil,
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+		isLeader.With("key", key).Set(0)
+	} else {
+		isLeader.With("key", key).Set(1)
+	}
+
+	statusMu.Lock()
+	statuses[key] = status
+	statusMu.Unlock()
+}
+
+// Statuses returns the most recent election of each key, sorted by key
+func Statuses() []Status {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	out := make([]Status, 0, len(statuses))
+	for _, status := range statuses {
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+type leadershipResponse struct {
+	SourceHostname string   `json:"sourceHostname"`
+	Keys           []Status `json:"keys"`
+}
+
+// RegisterAdminRoutes adds GET /leadership which lists the keys this replica has tried to lead
+func RegisterAdminRoutes(svc *admin.Server) {
+	svc.AddHandler("/leadership", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		resp := leadershipResponse{
+			Keys: Statuses(),
+		}
+		resp.SourceHostname, _ = os.Hostname()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,61 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package leadership
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockElector struct {
+	leader map[string]bool
+}
+
+func (e *mockElector) AcquireLock(key string) error {
+	if e.leader[key] {
+		return nil
+	}
+	return errors.New("we are not the leader of " + key)
+}
+
+func TestAcquireLock(t *testing.T) {
+	// Without an elector every replica is the leader
+	require.NoError(t, AcquireLock(nil, "achgateway/outbound/none"))
+	require.NoError(t, AcquireLock(Consul(nil, nil), "achgateway/outbound/none"))
+	require.NoError(t, AcquireLock(Kubernetes(nil), "achgateway/outbound/none"))
+	require.NoError(t, AcquireLock(Database(nil), "achgateway/outbound/none"))
+
+	elector := &mockElector{leader: map[string]bool{
+		"achgateway/outbound/live": true,
+	}}
+	require.NoError(t, AcquireLock(elector, "achgateway/outbound/live"))
+	require.ErrorContains(t, AcquireLock(elector, "achgateway/odfi/live"), "we are not the leader")
+
+	statuses := Statuses()
+	require.Len(t, statuses, 2)
+
+	require.Equal(t, "achgateway/odfi/live", statuses[0].Key)
+	require.False(t, statuses[0].Leader)
+	require.Equal(t, "we are not the leader of achgateway/odfi/live", statuses[0].Error)
+
+	require.Equal(t, "achgateway/outbound/live", statuses[1].Key)
+	require.True(t, statuses[1].Leader)
+	require.False(t, statuses[1].CheckedAt.IsZero())
+}
@@ -0,0 +1,198 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lineage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+)
+
+// maxSequence is the largest sequence number in the last seven digits of a trace number
+const maxSequence = 9999999
+
+// fileIDModifiers are the values of FileIDModifier in the order they're assigned
+const fileIDModifiers = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Assigner replaces trace numbers and file IDs of a shard's files as they're merged
+type Assigner struct {
+	shardName string
+	cfg       *service.Assignment
+
+	// repo records lineage, it's nil without a database
+	repo    Repository
+	counter Counter
+}
+
+// NewAssigner returns an Assigner for the shard, or nil when cfg preserves trace numbers and
+// file IDs. Without a Repository lineage isn't recorded and sequences are kept in memory.
+func NewAssigner(shardName string, cfg *service.Assignment, repo Repository) *Assigner {
+	if !cfg.AssignsTraceNumbers() && !cfg.AssignsFileIDs() {
+		return nil
+	}
+	a := &Assigner{
+		shardName: shardName,
+		cfg:       cfg,
+		repo:      repo,
+	}
+	if repo != nil {
+		a.counter = repo
+	} else {
+		a.counter = newMemoryCounter()
+	}
+	return a
+}
+
+// TraceNumbers replaces the trace number of each entry in file, which was submitted as fileID.
+// Entries keep the ODFI's routing number and are sorted by their new trace numbers within each
+// batch. IAT batches are left unchanged.
+func (a *Assigner) TraceNumbers(fileID string, file *ach.File) error {
+	if a == nil || file == nil || !a.cfg.AssignsTraceNumbers() {
+		return nil
+	}
+
+	var lineage []TraceNumber
+	offset := 0
+	for _, batch := range file.Batches {
+		entries := batch.GetEntries()
+		if len(entries) == 0 {
+			continue
+		}
+		odfi := strings.TrimSpace(batch.GetHeader().ODFIIdentification)
+		if len(odfi) != 8 {
+			return fmt.Errorf("batch %d: invalid ODFIIdentification %q", batch.GetHeader().BatchNumber, odfi)
+		}
+
+		first, err := a.firstSequence(fileID, odfi, offset, len(entries))
+		if err != nil {
+			return err
+		}
+		for i, entry := range entries {
+			seq := int((first-1+int64(i))%maxSequence) + 1
+			assigned := fmt.Sprintf("%s%07d", odfi, seq)
+
+			lineage = append(lineage, TraceNumber{
+				ShardName: a.shardName,
+				FileID:    fileID,
+				Original:  entry.TraceNumber,
+				Assigned:  assigned,
+			})
+			setTraceNumber(entry, assigned, seq)
+		}
+		// Sequences wrap around, but entries must be in ascending order
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].TraceNumber < entries[j].TraceNumber
+		})
+		offset += len(entries)
+	}
+
+	if a.repo != nil {
+		return a.repo.RecordTraceNumbers(lineage)
+	}
+	return nil
+}
+
+// firstSequence returns the sequence number of the first of n entries in a batch
+func (a *Assigner) firstSequence(fileID, odfi string, offset, n int) (int64, error) {
+	if strings.EqualFold(a.cfg.TraceNumbers, service.AssignSubmission) {
+		// Entries of a submission are numbered consecutively from a value derived from its ID
+		h := fnv.New32a()
+		h.Write([]byte(fileID))
+		return (int64(h.Sum32())+int64(offset))%maxSequence + 1, nil
+	}
+	first, err := a.counter.Reserve("trace:"+odfi, n)
+	if err != nil {
+		return 0, fmt.Errorf("assigning trace numbers: %v", err)
+	}
+	return first, nil
+}
+
+func setTraceNumber(entry *ach.EntryDetail, traceNumber string, seq int) {
+	entry.TraceNumber = traceNumber
+
+	// Addenda records repeat the entry's trace number or its sequence number
+	if entry.Addenda02 != nil {
+		entry.Addenda02.TraceNumber = traceNumber
+	}
+	for i := range entry.Addenda05 {
+		entry.Addenda05[i].EntryDetailSequenceNumber = seq
+	}
+	if entry.Addenda98 != nil {
+		entry.Addenda98.TraceNumber = traceNumber
+	}
+	if entry.Addenda99 != nil {
+		entry.Addenda99.TraceNumber = traceNumber
+	}
+	if entry.Addenda99Dishonored != nil {
+		entry.Addenda99Dishonored.TraceNumber = traceNumber
+	}
+	if entry.Addenda99Contested != nil {
+		entry.Addenda99Contested.TraceNumber = traceNumber
+	}
+}
+
+// FileID replaces the FileIDModifier of a merged file. Sequential modifiers count up from A for
+// each ImmediateOrigin and FileCreationDate, while submission modifiers are derived from the
+// trace numbers in the file so the same entries are always given the same modifier.
+func (a *Assigner) FileID(file *ach.File) error {
+	if a == nil || file == nil || !a.cfg.AssignsFileIDs() {
+		return nil
+	}
+
+	original := file.Header.FileIDModifier
+	var index int64
+	if strings.EqualFold(a.cfg.FileIDs, service.AssignSubmission) {
+		h := fnv.New32a()
+		for _, batch := range file.Batches {
+			for _, entry := range batch.GetEntries() {
+				h.Write([]byte(entry.TraceNumber))
+			}
+		}
+		index = int64(h.Sum32())
+	} else {
+		key := fmt.Sprintf("fileID:%s:%s", strings.TrimSpace(file.Header.ImmediateOrigin), file.Header.FileCreationDate)
+		n, err := a.counter.Reserve(key, 1)
+		if err != nil {
+			return fmt.Errorf("assigning file ID: %v", err)
+		}
+		index = n - 1
+	}
+	file.Header.FileIDModifier = string(fileIDModifiers[index%int64(len(fileIDModifiers))])
+
+	if a.repo == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := ach.NewWriter(&buf).Write(file); err != nil {
+		return fmt.Errorf("assigning file ID: %v", err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return a.repo.RecordFile(File{
+		ShardName: a.shardName,
+		SHA256:    hex.EncodeToString(sum[:]),
+		Original:  original,
+		Assigned:  file.Header.FileIDModifier,
+	})
+}
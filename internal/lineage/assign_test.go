@@ -0,0 +1,137 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lineage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readFile(t *testing.T) *ach.File {
+	t.Helper()
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	// Add a second entry with an addenda record
+	entry := *file.Batches[0].GetEntries()[0]
+	entry.TraceNumber = "076401255655292"
+	addenda := ach.NewAddenda05()
+	addenda.PaymentRelatedInformation = "invoice 123"
+	addenda.SequenceNumber = 1
+	addenda.EntryDetailSequenceNumber = 5655292
+	entry.AddAddenda05(addenda)
+	entry.AddendaRecordIndicator = 1
+	file.Batches[0].AddEntry(&entry)
+	require.NoError(t, file.Batches[0].Create())
+	require.NoError(t, file.Create())
+
+	return file
+}
+
+func TestAssigner__Preserve(t *testing.T) {
+	require.Nil(t, NewAssigner("live", nil, nil))
+	require.Nil(t, NewAssigner("live", &service.Assignment{TraceNumbers: "preserve"}, nil))
+
+	// nil Assigners leave files unchanged
+	var a *Assigner
+	file := readFile(t)
+	require.NoError(t, a.TraceNumbers("abc", file))
+	require.NoError(t, a.FileID(file))
+	require.Equal(t, "076401255655291", file.Batches[0].GetEntries()[0].TraceNumber)
+}
+
+func TestAssigner__Sequential(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	repo := NewRepository(db.DB)
+	a := NewAssigner("live", &service.Assignment{
+		TraceNumbers: service.AssignSequential,
+		FileIDs:      service.AssignSequential,
+	}, repo)
+
+	first, second := readFile(t), readFile(t)
+	require.NoError(t, a.TraceNumbers("first", first))
+	require.NoError(t, a.TraceNumbers("second", second))
+
+	entries := second.Batches[0].GetEntries()
+	require.Equal(t, "076401250000003", entries[0].TraceNumber)
+	require.Equal(t, "076401250000004", entries[1].TraceNumber)
+	require.Equal(t, 4, entries[1].Addenda05[0].EntryDetailSequenceNumber)
+	require.NoError(t, second.Validate())
+
+	lineage, err := repo.ListTraceNumbers("live", "second")
+	require.NoError(t, err)
+	require.Len(t, lineage, 2)
+	require.Equal(t, "076401255655291", lineage[0].Original)
+	require.Equal(t, "076401250000003", lineage[0].Assigned)
+
+	// Files merged the same day are given the next FileIDModifier
+	require.NoError(t, a.FileID(first))
+	require.NoError(t, a.FileID(second))
+	require.Equal(t, "A", first.Header.FileIDModifier)
+	require.Equal(t, "B", second.Header.FileIDModifier)
+
+	// Without a database sequences are only kept in memory
+	a = NewAssigner("live", &service.Assignment{TraceNumbers: service.AssignSequential}, nil)
+	file := readFile(t)
+	require.NoError(t, a.TraceNumbers("first", file))
+	require.Equal(t, "076401250000001", file.Batches[0].GetEntries()[0].TraceNumber)
+}
+
+func TestAssigner__Submission(t *testing.T) {
+	a := NewAssigner("live", &service.Assignment{
+		TraceNumbers: service.AssignSubmission,
+		FileIDs:      service.AssignSubmission,
+	}, nil)
+
+	traceNumbers := func(fileID string) []string {
+		file := readFile(t)
+		require.NoError(t, a.TraceNumbers(fileID, file))
+		require.NoError(t, file.Validate())
+
+		var out []string
+		for _, entry := range file.Batches[0].GetEntries() {
+			out = append(out, entry.TraceNumber)
+		}
+		return out
+	}
+
+	// The same submission is always given the same trace numbers
+	first := traceNumbers("abc")
+	require.Equal(t, first, traceNumbers("abc"))
+	require.NotEqual(t, first, traceNumbers("def"))
+	for i := range first {
+		require.Regexp(t, "^07640125[0-9]{7}$", first[i], fmt.Sprintf("entry %d", i))
+	}
+
+	one, two := readFile(t), readFile(t)
+	require.NoError(t, a.TraceNumbers("abc", one))
+	require.NoError(t, a.TraceNumbers("abc", two))
+	require.NoError(t, a.FileID(one))
+	require.NoError(t, a.FileID(two))
+	require.Equal(t, one.Header.FileIDModifier, two.Header.FileIDModifier)
+}
@@ -0,0 +1,264 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package lineage assigns trace numbers and file IDs as files are merged and records what was
+// assigned, so entries in an uploaded file can be traced back to the submitted file they came from.
+package lineage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/moov-io/base/database"
+)
+
+// TraceNumber is an entry's trace number replaced while merging the submitted file FileID
+type TraceNumber struct {
+	ShardName string    `json:"shardName"`
+	FileID    string    `json:"fileID"`
+	Original  string    `json:"original"`
+	Assigned  string    `json:"assigned"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// File is the FileIDModifier assigned to a merged file, identified by the SHA256 of its contents
+type File struct {
+	ShardName string    `json:"shardName"`
+	SHA256    string    `json:"sha256"`
+	Original  string    `json:"original"`
+	Assigned  string    `json:"assigned"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Counter hands out increasing values which are never handed out twice
+type Counter interface {
+	// Reserve returns the first of n consecutive values from the counter named key
+	Reserve(key string, n int) (int64, error)
+}
+
+type Repository interface {
+	Counter
+
+	RecordTraceNumbers(entries []TraceNumber) error
+	RecordFile(file File) error
+
+	// ListTraceNumbers returns the trace numbers assigned to entries of the submitted file
+	ListTraceNumbers(shardName, fileID string) ([]TraceNumber, error)
+
+	// FindTraceNumber returns each time traceNumber was assigned, most recent first
+	FindTraceNumber(shardName, traceNumber string) ([]TraceNumber, error)
+
+	// FindFile returns the FileIDModifier assigned to a merged file, or nil
+	FindFile(shardName, sha256 string) (*File, error)
+
+	// RemoveTraceNumbers deletes every record of the assigned trace numbers and returns how many were deleted
+	RemoveTraceNumbers(traceNumbers []string) (int, error)
+}
+
+// NewRepository returns a Repository stored in db, or nil when db is nil
+func NewRepository(db *sql.DB) Repository {
+	if db == nil {
+		return nil
+	}
+	return &sqlRepository{db: db, now: time.Now}
+}
+
+type sqlRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func (r *sqlRepository) Reserve(key string, n int) (int64, error) {
+	if n <= 0 {
+		return 0, errors.New("reserving zero values")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("reserving %s: %v", key, err)
+	}
+	defer tx.Rollback()
+
+	// The update locks the counter's row until we commit, so concurrent instances wait their turn
+	res, err := tx.Exec(`update assignment_counters set counter_value = counter_value + ? where counter_key = ?;`, n, key)
+	if err != nil {
+		return 0, fmt.Errorf("reserving %s: %v", key, err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		_, err = tx.Exec(`insert into assignment_counters (counter_key, counter_value) values (?, ?);`, key, n)
+		if err != nil {
+			if database.UniqueViolation(err) {
+				// Another instance created the counter, so reserve from theirs
+				tx.Rollback()
+				return r.Reserve(key, n)
+			}
+			return 0, fmt.Errorf("reserving %s: %v", key, err)
+		}
+	}
+
+	var last int64
+	if err := tx.QueryRow(`select counter_value from assignment_counters where counter_key = ? limit 1;`, key).Scan(&last); err != nil {
+		return 0, fmt.Errorf("reserving %s: %v", key, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("reserving %s: %v", key, err)
+	}
+	return last - int64(n) + 1, nil
+}
+
+func (r *sqlRepository) RecordTraceNumbers(entries []TraceNumber) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("recording trace numbers: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `insert into trace_number_lineage (shard_name, file_id, original_trace_number, assigned_trace_number, created_at) values (?, ?, ?, ?, ?);`
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("recording trace numbers: %v", err)
+	}
+	defer stmt.Close()
+
+	now := r.timestamp()
+	for i := range entries {
+		_, err := stmt.Exec(entries[i].ShardName, entries[i].FileID, entries[i].Original, entries[i].Assigned, now)
+		if err != nil {
+			return fmt.Errorf("recording trace number %s of %s: %v", entries[i].Assigned, entries[i].FileID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("recording trace numbers: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) RecordFile(file File) error {
+	query := `insert into file_id_lineage (shard_name, sha256, original_file_id_modifier, assigned_file_id_modifier, created_at) values (?, ?, ?, ?, ?);`
+	_, err := r.db.Exec(query, file.ShardName, file.SHA256, file.Original, file.Assigned, r.timestamp())
+	if err != nil && !database.UniqueViolation(err) {
+		// Merging the same files again creates an identical file, which is already recorded
+		return fmt.Errorf("recording file %s: %v", file.SHA256, err)
+	}
+	return nil
+}
+
+const traceNumberColumns = `shard_name, file_id, original_trace_number, assigned_trace_number, created_at`
+
+func (r *sqlRepository) ListTraceNumbers(shardName, fileID string) ([]TraceNumber, error) {
+	query := `select ` + traceNumberColumns + ` from trace_number_lineage where shard_name = ? and file_id = ? order by created_at desc, assigned_trace_number asc;`
+	return r.queryTraceNumbers(query, shardName, fileID)
+}
+
+func (r *sqlRepository) FindTraceNumber(shardName, traceNumber string) ([]TraceNumber, error) {
+	query := `select ` + traceNumberColumns + ` from trace_number_lineage where shard_name = ? and assigned_trace_number = ? order by created_at desc;`
+	return r.queryTraceNumbers(query, shardName, traceNumber)
+}
+
+func (r *sqlRepository) queryTraceNumbers(query string, args ...interface{}) ([]TraceNumber, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("reading trace numbers: %v", err)
+	}
+	defer rows.Close()
+
+	var out []TraceNumber
+	for rows.Next() {
+		var entry TraceNumber
+		if err := rows.Scan(&entry.ShardName, &entry.FileID, &entry.Original, &entry.Assigned, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("reading trace numbers: %v", err)
+		}
+		out = append(out, entry)
+	}
+	return out, rows.Err()
+}
+
+func (r *sqlRepository) FindFile(shardName, sha256 string) (*File, error) {
+	query := `select shard_name, sha256, original_file_id_modifier, assigned_file_id_modifier, created_at from file_id_lineage where shard_name = ? and sha256 = ? limit 1;`
+
+	var file File
+	err := r.db.QueryRow(query, shardName, sha256).Scan(&file.ShardName, &file.SHA256, &file.Original, &file.Assigned, &file.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading file %s: %v", sha256, err)
+	}
+	return &file, nil
+}
+
+func (r *sqlRepository) RemoveTraceNumbers(traceNumbers []string) (int, error) {
+	if len(traceNumbers) == 0 {
+		return 0, nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("removing trace numbers: %v", err)
+	}
+	defer tx.Rollback()
+
+	var removed int64
+	for i := range traceNumbers {
+		res, err := tx.Exec(`delete from trace_number_lineage where assigned_trace_number = ?;`, traceNumbers[i])
+		if err != nil {
+			return 0, fmt.Errorf("removing trace number %s: %v", traceNumbers[i], err)
+		}
+		n, _ := res.RowsAffected()
+		removed += n
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("removing trace numbers: %v", err)
+	}
+	return int(removed), nil
+}
+
+// timestamp is the current time in UTC with the precision DATETIME columns store
+func (r *sqlRepository) timestamp() time.Time {
+	return r.now().UTC().Truncate(time.Second)
+}
+
+// memoryCounter is used without a database. Its values start over when the instance restarts
+// and aren't shared with other instances.
+type memoryCounter struct {
+	mu       sync.Mutex
+	counters map[string]int64
+}
+
+func newMemoryCounter() *memoryCounter {
+	return &memoryCounter{counters: make(map[string]int64)}
+}
+
+func (c *memoryCounter) Reserve(key string, n int) (int64, error) {
+	if n <= 0 {
+		return 0, errors.New("reserving zero values")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	first := c.counters[key] + 1
+	c.counters[key] += int64(n)
+	return first, nil
+}
@@ -0,0 +1,122 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lineage
+
+import (
+	"testing"
+
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository__Nil(t *testing.T) {
+	require.Nil(t, NewRepository(nil))
+}
+
+func TestRepository__Reserve(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	for _, counter := range []Counter{NewRepository(db.DB), newMemoryCounter()} {
+		first, err := counter.Reserve("trace:07640125", 3)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), first)
+
+		first, err = counter.Reserve("trace:07640125", 2)
+		require.NoError(t, err)
+		require.Equal(t, int64(4), first)
+
+		// Counters are independent
+		first, err = counter.Reserve("trace:23138010", 1)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), first)
+
+		_, err = counter.Reserve("trace:07640125", 0)
+		require.Error(t, err)
+	}
+}
+
+func TestRepository(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	repo := NewRepository(db.DB)
+
+	err := repo.RecordTraceNumbers([]TraceNumber{
+		{ShardName: "live", FileID: "abc", Original: "076401255655291", Assigned: "076401250000001"},
+		{ShardName: "live", FileID: "abc", Original: "076401255655292", Assigned: "076401250000002"},
+		{ShardName: "live", FileID: "def", Original: "076401255655291", Assigned: "076401250000003"},
+	})
+	require.NoError(t, err)
+
+	entries, err := repo.ListTraceNumbers("live", "abc")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "076401255655291", entries[0].Original)
+	require.Equal(t, "076401250000001", entries[0].Assigned)
+	require.False(t, entries[0].CreatedAt.IsZero())
+
+	entries, err = repo.FindTraceNumber("live", "076401250000003")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "def", entries[0].FileID)
+
+	entries, err = repo.ListTraceNumbers("other", "abc")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	file := File{ShardName: "live", SHA256: "8c4f1a", Original: "A", Assigned: "C"}
+	require.NoError(t, repo.RecordFile(file))
+	require.NoError(t, repo.RecordFile(file)) // merged again
+
+	found, err := repo.FindFile("live", "8c4f1a")
+	require.NoError(t, err)
+	require.Equal(t, "C", found.Assigned)
+
+	found, err = repo.FindFile("live", "missing")
+	require.NoError(t, err)
+	require.Nil(t, found)
+}
+
+func TestRepository__RemoveTraceNumbers(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	repo := NewRepository(db.DB)
+
+	err := repo.RecordTraceNumbers([]TraceNumber{
+		{ShardName: "live", FileID: "abc", Original: "076401255655291", Assigned: "076401250000001"},
+		{ShardName: "live", FileID: "abc", Original: "076401255655292", Assigned: "076401250000002"},
+		{ShardName: "test", FileID: "def", Original: "076401255655291", Assigned: "076401250000001"},
+	})
+	require.NoError(t, err)
+
+	removed, err := repo.RemoveTraceNumbers(nil)
+	require.NoError(t, err)
+	require.Zero(t, removed)
+
+	removed, err = repo.RemoveTraceNumbers([]string{"076401250000001", "076401250000009"})
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+
+	entries, err := repo.ListTraceNumbers("live", "abc")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "076401250000002", entries[0].Assigned)
+}
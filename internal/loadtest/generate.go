@@ -0,0 +1,109 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package loadtest
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/moov-io/ach"
+)
+
+const (
+	odfiIdentification = "07640125"
+	routingNumber      = "076401251"
+)
+
+// Generator creates valid PPD files for submitting to achgateway. Trace numbers are unique
+// across every file from one Generator so they can all be merged together.
+type Generator struct {
+	EntriesPerFile int
+
+	trace uint64
+}
+
+func NewGenerator(entriesPerFile int) *Generator {
+	if entriesPerFile <= 0 {
+		entriesPerFile = 1
+	}
+	return &Generator{
+		EntriesPerFile: entriesPerFile,
+	}
+}
+
+// File returns a new PPD file with EntriesPerFile debits and credits.
+func (g *Generator) File() (*ach.File, error) {
+	now := time.Now()
+
+	fh := ach.NewFileHeader()
+	fh.ImmediateDestination = routingNumber
+	fh.ImmediateOrigin = routingNumber
+	fh.FileCreationDate = now.Format("060102")
+	fh.FileCreationTime = now.Format("1504")
+	fh.ImmediateDestinationName = "achdestname"
+	fh.ImmediateOriginName = "companyname"
+
+	file := ach.NewFile()
+	file.SetHeader(fh)
+
+	bh := ach.NewBatchHeader()
+	bh.ServiceClassCode = ach.MixedDebitsAndCredits
+	bh.CompanyName = "companyname"
+	bh.CompanyIdentification = "origid"
+	bh.StandardEntryClassCode = ach.PPD
+	bh.CompanyEntryDescription = "LOADTEST"
+	bh.EffectiveEntryDate = now.AddDate(0, 0, 1).Format("060102")
+	bh.ODFIIdentification = odfiIdentification
+
+	batch, err := ach.NewBatch(bh)
+	if err != nil {
+		return nil, fmt.Errorf("creating batch: %w", err)
+	}
+	for i := 0; i < g.EntriesPerFile; i++ {
+		batch.AddEntry(g.entry(i))
+	}
+	if err := batch.Create(); err != nil {
+		return nil, fmt.Errorf("creating batch: %w", err)
+	}
+	file.AddBatch(batch)
+
+	if err := file.Create(); err != nil {
+		return nil, fmt.Errorf("creating file: %w", err)
+	}
+	return file, nil
+}
+
+func (g *Generator) entry(i int) *ach.EntryDetail {
+	trace := atomic.AddUint64(&g.trace, 1) % 1e7
+
+	ed := ach.NewEntryDetail()
+	ed.TransactionCode = ach.CheckingDebit
+	if i%2 == 1 {
+		ed.TransactionCode = ach.CheckingCredit
+	}
+	ed.SetRDFI("231380104")
+	ed.DFIAccountNumber = fmt.Sprintf("%d", 10000000+rand.Int63n(89999999))
+	ed.Amount = int(100 + rand.Int63n(99900))
+	ed.IdentificationNumber = fmt.Sprintf("lt-%d", trace)
+	ed.IndividualName = "Load Test"
+	ed.SetTraceNumber(odfiIdentification, int(trace))
+	ed.Category = ach.CategoryForward
+	return ed
+}
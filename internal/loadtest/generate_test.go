@@ -0,0 +1,70 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package loadtest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/moov-io/ach"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator(t *testing.T) {
+	gen := NewGenerator(25)
+
+	first, err := gen.File()
+	require.NoError(t, err)
+	require.NoError(t, first.Validate())
+	require.Len(t, first.Batches, 1)
+	require.Len(t, first.Batches[0].GetEntries(), 25)
+
+	second, err := gen.File()
+	require.NoError(t, err)
+
+	// Trace numbers are unique across files so they merge together
+	traces := make(map[string]bool)
+	for _, file := range []*ach.File{first, second} {
+		for _, entry := range file.Batches[0].GetEntries() {
+			require.False(t, traces[entry.TraceNumber], entry.TraceNumber)
+			traces[entry.TraceNumber] = true
+		}
+	}
+
+	merged, err := ach.MergeFiles([]*ach.File{first, second})
+	require.NoError(t, err)
+	require.Len(t, merged, 1)
+
+	// Generated files are written and read back as Nacha files
+	var buf bytes.Buffer
+	require.NoError(t, ach.NewWriter(&buf).Write(second))
+	_, err = ach.NewReader(&buf).Read()
+	require.NoError(t, err)
+}
+
+func BenchmarkGenerator(b *testing.B) {
+	gen := NewGenerator(100)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := gen.File(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
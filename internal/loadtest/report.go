@@ -0,0 +1,181 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Report holds the outcome of a load test.
+type Report struct {
+	Duration time.Duration
+
+	mu      sync.Mutex
+	shards  map[string]*Latencies
+	failed  int
+	skipped int
+
+	// Cutoff is how long the manual cutoff took, if one was triggered
+	Cutoff time.Duration
+}
+
+func newReport() *Report {
+	return &Report{
+		shards: make(map[string]*Latencies),
+	}
+}
+
+func (r *Report) record(shardKey string, took time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		r.failed++
+		return
+	}
+	lat, exists := r.shards[shardKey]
+	if !exists {
+		lat = &Latencies{}
+		r.shards[shardKey] = lat
+	}
+	lat.Add(took)
+}
+
+func (r *Report) skip() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.skipped++
+}
+
+// Submitted returns how many files were accepted by achgateway.
+func (r *Report) Submitted() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := 0
+	for _, lat := range r.shards {
+		total += lat.Count()
+	}
+	return total
+}
+
+func (r *Report) Failed() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.failed
+}
+
+// Skipped returns how many files weren't sent because every worker was busy.
+func (r *Report) Skipped() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.skipped
+}
+
+// Shard returns the submission latencies for shardKey.
+func (r *Report) Shard(shardKey string) *Latencies {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.shards[shardKey]
+}
+
+// WriteTo prints a summary of submissions per shard and the cutoff duration.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	submitted, failed, skipped := r.Submitted(), r.Failed(), r.Skipped()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var written int64
+	printf := func(format string, args ...interface{}) error {
+		n, err := fmt.Fprintf(w, format, args...)
+		written += int64(n)
+		return err
+	}
+
+	rate := float64(submitted) / r.Duration.Seconds()
+	if err := printf("submitted %d files in %v (%.2f files/sec), %d failed, %d skipped\n", submitted, r.Duration.Round(time.Millisecond), rate, failed, skipped); err != nil {
+		return written, err
+	}
+
+	shardKeys := make([]string, 0, len(r.shards))
+	for key := range r.shards {
+		shardKeys = append(shardKeys, key)
+	}
+	sort.Strings(shardKeys)
+
+	for _, key := range shardKeys {
+		lat := r.shards[key]
+		err := printf("  %s: files=%d p50=%v p90=%v p99=%v max=%v\n", key, lat.Count(),
+			lat.Percentile(50), lat.Percentile(90), lat.Percentile(99), lat.Percentile(100))
+		if err != nil {
+			return written, err
+		}
+	}
+	if r.Cutoff > 0 {
+		if err := printf("cutoff took %v\n", r.Cutoff.Round(time.Millisecond)); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Latencies collects how long each submission took.
+type Latencies struct {
+	durations []time.Duration
+	sorted    bool
+}
+
+func (l *Latencies) Add(took time.Duration) {
+	l.durations = append(l.durations, took)
+	l.sorted = false
+}
+
+func (l *Latencies) Count() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.durations)
+}
+
+// Percentile returns the latency which p percent of submissions completed within.
+func (l *Latencies) Percentile(p float64) time.Duration {
+	if l.Count() == 0 {
+		return 0
+	}
+	if !l.sorted {
+		sort.Slice(l.durations, func(i, j int) bool { return l.durations[i] < l.durations[j] })
+		l.sorted = true
+	}
+	idx := int(p/100*float64(len(l.durations))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(l.durations) {
+		idx = len(l.durations) - 1
+	}
+	return l.durations[idx].Round(time.Microsecond)
+}
@@ -0,0 +1,232 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/pkg/client"
+	"github.com/moov-io/base"
+)
+
+// Config describes the traffic sent to a running achgateway instance.
+type Config struct {
+	// Endpoint is the base URL of achgateway's public HTTP server
+	Endpoint string
+
+	Shards         ShardSpread
+	FilesPerSecond float64
+	EntriesPerFile int
+	Duration       time.Duration
+
+	// Concurrency limits how many submissions are in flight at once
+	Concurrency int
+}
+
+func (cfg Config) Validate() error {
+	if cfg.Endpoint == "" {
+		return errors.New("missing endpoint")
+	}
+	if len(cfg.Shards) == 0 {
+		return errors.New("missing shards")
+	}
+	if cfg.FilesPerSecond <= 0 {
+		return errors.New("files per second must be positive")
+	}
+	if cfg.Duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+	if cfg.Concurrency <= 0 {
+		return errors.New("concurrency must be positive")
+	}
+	return nil
+}
+
+// WeightedShard is a shardKey and its share of the submitted files.
+type WeightedShard struct {
+	ShardKey string
+	Weight   int
+}
+
+type ShardSpread []WeightedShard
+
+// ParseShardSpread reads a comma separated list of shardKeys with optional weights,
+// for example "testing=3,sandbox=1". Shards without a weight have a weight of one.
+func ParseShardSpread(value string) (ShardSpread, error) {
+	var out ShardSpread
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		shard := WeightedShard{ShardKey: part, Weight: 1}
+		if idx := strings.Index(part, "="); idx > 0 {
+			weight, err := strconv.Atoi(part[idx+1:])
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid weight for %s", part)
+			}
+			shard = WeightedShard{ShardKey: part[:idx], Weight: weight}
+		}
+		out = append(out, shard)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no shards")
+	}
+	return out, nil
+}
+
+// pick returns the shardKey for the n-th file, spreading files by each shard's weight.
+func (ss ShardSpread) pick(n int) string {
+	total := 0
+	for i := range ss {
+		total += ss[i].Weight
+	}
+	n = n % total
+	for i := range ss {
+		if n < ss[i].Weight {
+			return ss[i].ShardKey
+		}
+		n -= ss[i].Weight
+	}
+	return ss[len(ss)-1].ShardKey
+}
+
+type submission struct {
+	shardKey string
+	file     *ach.File
+}
+
+// Run submits files at the configured rate until cfg.Duration passes or ctx is canceled.
+// Files which can't be sent because every worker is busy are counted as skipped rather
+// than delaying the following files.
+func Run(ctx context.Context, client *http.Client, cfg Config) (*Report, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	report := newReport()
+	gen := NewGenerator(cfg.EntriesPerFile)
+	work := make(chan submission)
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sub := range work {
+				start := time.Now()
+				err := submitFile(context.Background(), client, cfg.Endpoint, sub.shardKey, sub.file)
+				report.record(sub.shardKey, time.Since(start), err)
+			}
+		}()
+	}
+
+	started := time.Now()
+	interval := time.Duration(float64(time.Second) / cfg.FilesPerSecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var err error
+	for n := 0; ; n++ {
+		file, genErr := gen.File()
+		if genErr != nil {
+			err = genErr
+			break
+		}
+		sub := submission{shardKey: cfg.Shards.pick(n), file: file}
+
+		select {
+		case <-ctx.Done():
+		case work <- sub:
+		default:
+			report.skip()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	report.Duration = time.Since(started)
+	return report, err
+}
+
+func submitFile(ctx context.Context, client *http.Client, endpoint, shardKey string, file *ach.File) error {
+	var buf bytes.Buffer
+	if err := ach.NewWriter(&buf).Write(file); err != nil {
+		return err
+	}
+
+	address := fmt.Sprintf("%s/shards/%s/files/%s", strings.TrimSuffix(endpoint, "/"), shardKey, base.ID())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, &buf)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected %s response", resp.Status)
+	}
+	return nil
+}
+
+// TriggerCutoff runs a manual cutoff on the admin server for shardNames and returns how
+// long merging and uploading took.
+func TriggerCutoff(ctx context.Context, httpClient *http.Client, adminEndpoint string, shardNames []string) (time.Duration, error) {
+	c, err := client.New(client.Config{
+		AdminURL:   adminEndpoint,
+		HTTPClient: httpClient,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	_, err = c.TriggerCutoff(ctx, client.CutoffRequest{
+		ShardNames: shardNames,
+	})
+	return time.Since(start), err
+}
@@ -0,0 +1,161 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseShardSpread(t *testing.T) {
+	spread, err := ParseShardSpread("testing=3, sandbox")
+	require.NoError(t, err)
+	require.Equal(t, ShardSpread{
+		{ShardKey: "testing", Weight: 3},
+		{ShardKey: "sandbox", Weight: 1},
+	}, spread)
+
+	var picked []string
+	for i := 0; i < 8; i++ {
+		picked = append(picked, spread.pick(i))
+	}
+	require.Equal(t, []string{"testing", "testing", "testing", "sandbox", "testing", "testing", "testing", "sandbox"}, picked)
+
+	_, err = ParseShardSpread("testing=0")
+	require.ErrorContains(t, err, "invalid weight")
+
+	_, err = ParseShardSpread(" , ")
+	require.ErrorContains(t, err, "no shards")
+}
+
+func TestRun(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/") // /shards/{shardKey}/files/{fileID}
+		if r.Method != http.MethodPost || len(parts) != 5 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if _, err := ach.NewReader(r.Body).Read(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received[parts[2]]++
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	spread, err := ParseShardSpread("testing=2,sandbox=1")
+	require.NoError(t, err)
+
+	report, err := Run(context.Background(), server.Client(), Config{
+		Endpoint:       server.URL,
+		Shards:         spread,
+		FilesPerSecond: 100,
+		EntriesPerFile: 5,
+		Duration:       300 * time.Millisecond,
+		Concurrency:    4,
+	})
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Equal(t, 0, report.Failed())
+	require.Greater(t, report.Submitted(), 10)
+	require.Equal(t, received["testing"], report.Shard("testing").Count())
+	require.Equal(t, received["sandbox"], report.Shard("sandbox").Count())
+	require.Greater(t, received["testing"], received["sandbox"])
+
+	var buf bytes.Buffer
+	_, err = report.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "  sandbox: files=")
+	require.Contains(t, buf.String(), "  testing: files=")
+}
+
+func TestRun__Failures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	report, err := Run(context.Background(), server.Client(), Config{
+		Endpoint:       server.URL,
+		Shards:         ShardSpread{{ShardKey: "testing", Weight: 1}},
+		FilesPerSecond: 50,
+		Duration:       100 * time.Millisecond,
+		Concurrency:    1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, report.Submitted())
+	require.Greater(t, report.Failed(), 0)
+
+	_, err = Run(context.Background(), nil, Config{})
+	require.ErrorContains(t, err, "missing endpoint")
+}
+
+func TestTriggerCutoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ShardNames []string `json:"shardNames"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Method != http.MethodPut || r.URL.Path != "/trigger-cutoff" || len(body.ShardNames) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}))
+	t.Cleanup(server.Close)
+
+	took, err := TriggerCutoff(context.Background(), server.Client(), server.URL, []string{"testing"})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, took, 10*time.Millisecond)
+
+	_, err = TriggerCutoff(context.Background(), server.Client(), server.URL, nil)
+	require.ErrorContains(t, err, "400 Bad Request")
+}
+
+func TestLatencies(t *testing.T) {
+	var lat Latencies
+	require.Equal(t, time.Duration(0), lat.Percentile(50))
+
+	for i := 10; i > 0; i-- {
+		lat.Add(time.Duration(i) * time.Millisecond)
+	}
+	require.Equal(t, 10, lat.Count())
+	require.Equal(t, 5*time.Millisecond, lat.Percentile(50))
+	require.Equal(t, 9*time.Millisecond, lat.Percentile(90))
+	require.Equal(t, 10*time.Millisecond, lat.Percentile(100))
+	require.Equal(t, 1*time.Millisecond, lat.Percentile(0))
+}
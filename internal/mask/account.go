@@ -0,0 +1,19 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package mask
+
+import (
+	"strings"
+)
+
+// AccountNumber keeps the last four characters of an account number, turning
+// '123456789' into '*****6789'
+func AccountNumber(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= 4 {
+		return strings.Repeat("*", len(s))
+	}
+	return strings.Repeat("*", len(s)-4) + s[len(s)-4:]
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package mask
+
+import (
+	"bytes"
+)
+
+const nachaRecordLength = 94
+
+// Nacha masks the personal data of Nacha formatted contents so they can be shown to operators.
+// Entry account numbers keep their last four characters while names, identification numbers and
+// addenda text are replaced with '*'. Every record keeps its length and the other records are
+// left alone, so contents which don't parse are still masked.
+func Nacha(contents []byte) []byte {
+	out := append([]byte(nil), contents...)
+
+	iat := false
+	for _, record := range nachaRecords(out) {
+		if len(record) < nachaRecordLength {
+			continue
+		}
+		switch {
+		case record[0] == '5':
+			iat = string(record[50:53]) == "IAT"
+		case record[0] == '6' && iat:
+			maskAccountNumber(record[39:74])
+		case record[0] == '6':
+			maskAccountNumber(record[12:29])
+			maskAll(record[39:76]) // IdentificationNumber and IndividualName
+		case bytes.HasPrefix(record, []byte("705")), bytes.HasPrefix(record, []byte("71")):
+			maskAll(record[3:83]) // PaymentRelatedInformation and IAT names and addresses
+		}
+	}
+	return out
+}
+
+// nachaRecords splits contents by line, or every 94 characters when it has no line breaks
+func nachaRecords(contents []byte) [][]byte {
+	if bytes.IndexByte(contents, '\n') < 0 {
+		var out [][]byte
+		for len(contents) >= nachaRecordLength {
+			out = append(out, contents[:nachaRecordLength])
+			contents = contents[nachaRecordLength:]
+		}
+		return out
+	}
+	lines := bytes.Split(contents, []byte("\n"))
+	for i := range lines {
+		lines[i] = bytes.TrimSuffix(lines[i], []byte("\r"))
+	}
+	return lines
+}
+
+func maskAccountNumber(field []byte) {
+	masked := AccountNumber(string(field))
+	for i := range field {
+		if i < len(masked) {
+			field[i] = masked[i]
+		} else {
+			field[i] = ' '
+		}
+	}
+}
+
+func maskAll(field []byte) {
+	for i := range field {
+		if field[i] != ' ' {
+			field[i] = '*'
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package microentries originates micro-entries (two small credits and an offsetting debit) to
+// verify a customer's account and tracks them until the customer confirms the amounts or the
+// entries are returned.
+package microentries
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming/entries"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusVerified = "verified"
+	StatusFailed   = "failed"
+	StatusReturned = "returned"
+
+	// CompanyEntryDescription is required by Nacha on micro-entry batches
+	CompanyEntryDescription = "ACCTVERIFY"
+
+	// maxAmount is the largest credit in cents, Nacha requires micro-entry credits be under $1
+	maxAmount = 99
+)
+
+// MicroEntry tracks the micro-entries sent to an account. Its ID is also the fileID the entries
+// were submitted under and the IdentificationNumber of each entry, so returns can be matched.
+type MicroEntry struct {
+	ID       string `json:"microEntryID"`
+	ShardKey string `json:"shardKey"`
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+
+	// ReturnCode is set once the entries are returned
+	ReturnCode string `json:"returnCode,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// Amounts are the credits, in cents, the customer confirms. They're never included in responses.
+	Amounts [2]int `json:"-"`
+}
+
+// Account is the customer's account the micro-entries are sent to.
+type Account struct {
+	Name          string `json:"name"`
+	RoutingNumber string `json:"routingNumber"`
+	AccountNumber string `json:"accountNumber"`
+	AccountType   string `json:"accountType"` // checking (default) or savings
+	SECCode       string `json:"secCode"`     // defaults to PPD
+}
+
+// New returns a pending MicroEntry with random amounts
+func New(shardKey string, now time.Time) (*MicroEntry, error) {
+	var amounts [2]int
+	for i := range amounts {
+		n, err := rand.Int(rand.Reader, big.NewInt(maxAmount))
+		if err != nil {
+			return nil, fmt.Errorf("choosing amounts: %v", err)
+		}
+		amounts[i] = int(n.Int64()) + 1
+	}
+	return &MicroEntry{
+		ID:        base.ID()[:15],
+		ShardKey:  shardKey,
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Amounts:   amounts,
+	}, nil
+}
+
+// Build creates the file of two credits and a debit of their total to account using the shard's
+// FileDefaults for the file and batch headers.
+func Build(defaults *service.FileDefaults, micro *MicroEntry, acct Account, now time.Time) (*ach.File, error) {
+	if micro == nil {
+		return nil, errors.New("nil MicroEntry")
+	}
+	if strings.TrimSpace(acct.Name) == "" {
+		return nil, errors.New("missing name")
+	}
+	entry := func(amount int, kind string) entries.Entry {
+		return entries.Entry{
+			Name:           acct.Name,
+			RoutingNumber:  acct.RoutingNumber,
+			AccountNumber:  acct.AccountNumber,
+			AccountType:    acct.AccountType,
+			Amount:         amount,
+			Type:           kind,
+			SECCode:        acct.SECCode,
+			Identification: micro.ID,
+		}
+	}
+	return entries.Build(defaults, &entries.Submission{
+		CompanyEntryDescription: CompanyEntryDescription,
+		Entries: []entries.Entry{
+			entry(micro.Amounts[0], "credit"),
+			entry(micro.Amounts[1], "credit"),
+			entry(micro.Amounts[0]+micro.Amounts[1], "debit"),
+		},
+	}, now)
+}
+
+var (
+	ErrNotPending = errors.New("micro-entries are not pending verification")
+	ErrNotFound   = errors.New("micro-entries not found")
+)
+
+// Verify compares amounts (in any order) to the credits and records the attempt. Verification
+// fails once maxAttempts incorrect guesses are made.
+func Verify(repo Repository, id string, amounts []int, maxAttempts int) (*MicroEntry, error) {
+	micro, err := repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if micro == nil {
+		return nil, ErrNotFound
+	}
+	if micro.Status != StatusPending {
+		return micro, ErrNotPending
+	}
+
+	micro.Attempts++
+	if matches(micro.Amounts, amounts) {
+		micro.Status = StatusVerified
+	} else if micro.Attempts >= maxAttempts {
+		micro.Status = StatusFailed
+	}
+	if err := repo.Update(micro); err != nil {
+		return nil, err
+	}
+	return micro, nil
+}
+
+func matches(expected [2]int, amounts []int) bool {
+	if len(amounts) != len(expected) {
+		return false
+	}
+	got := append([]int(nil), amounts...)
+	want := []int{expected[0], expected[1]}
+	sort.Ints(got)
+	sort.Ints(want)
+	return got[0] == want[0] && got[1] == want[1]
+}
@@ -0,0 +1,167 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package microentries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testDefaults = &service.FileDefaults{
+	ImmediateDestination:  "231380104",
+	ImmediateOrigin:       "076401251",
+	CompanyName:           "Moov",
+	CompanyIdentification: "121042882",
+}
+
+var testAccount = Account{
+	Name:          "Jane Doe",
+	RoutingNumber: "231380104",
+	AccountNumber: "12345",
+}
+
+func TestNew(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		micro, err := New("testing", time.Now())
+		require.NoError(t, err)
+		require.Len(t, micro.ID, 15)
+		require.Equal(t, StatusPending, micro.Status)
+		for _, amount := range micro.Amounts {
+			require.True(t, amount >= 1 && amount <= 99, "amount %d", amount)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2021, time.June, 14, 10, 30, 0, 0, time.UTC)
+	micro, err := New("testing", now)
+	require.NoError(t, err)
+	micro.Amounts = [2]int{12, 34}
+
+	file, err := Build(testDefaults, micro, testAccount, now)
+	require.NoError(t, err)
+	require.Len(t, file.Batches, 1)
+
+	bh := file.Batches[0].GetHeader()
+	require.Equal(t, CompanyEntryDescription, bh.CompanyEntryDescription)
+	require.Equal(t, ach.MixedDebitsAndCredits, bh.ServiceClassCode)
+
+	entries := file.Batches[0].GetEntries()
+	require.Len(t, entries, 3)
+	require.Equal(t, ach.CheckingCredit, entries[0].TransactionCode)
+	require.Equal(t, 12, entries[0].Amount)
+	require.Equal(t, ach.CheckingCredit, entries[1].TransactionCode)
+	require.Equal(t, 34, entries[1].Amount)
+	require.Equal(t, ach.CheckingDebit, entries[2].TransactionCode)
+	require.Equal(t, 46, entries[2].Amount)
+	for _, entry := range entries {
+		require.Equal(t, micro.ID, entry.IdentificationNumber)
+	}
+
+	_, err = Build(testDefaults, micro, Account{RoutingNumber: "231380104", AccountNumber: "12345"}, now)
+	require.ErrorContains(t, err, "missing name")
+}
+
+func TestRepository(t *testing.T) {
+	require.Nil(t, NewRepository(nil))
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := NewRepository(db.DB)
+
+	found, err := repo.Get("missing")
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	micro, err := New("testing", time.Now())
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(micro))
+
+	found, err = repo.Get(micro.ID)
+	require.NoError(t, err)
+	require.Equal(t, micro.Amounts, found.Amounts)
+	require.Equal(t, StatusPending, found.Status)
+	require.Empty(t, found.ReturnCode)
+
+	found.Status = StatusReturned
+	found.ReturnCode = "R03"
+	require.NoError(t, repo.Update(found))
+
+	found, err = repo.Get(micro.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusReturned, found.Status)
+	require.Equal(t, "R03", found.ReturnCode)
+}
+
+func TestVerify(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := NewRepository(db.DB)
+
+	micro, err := New("testing", time.Now())
+	require.NoError(t, err)
+	micro.Amounts = [2]int{12, 34}
+	require.NoError(t, repo.Create(micro))
+
+	// Wrong guesses leave the micro-entries pending
+	found, err := Verify(repo, micro.ID, []int{12, 35}, 3)
+	require.NoError(t, err)
+	require.Equal(t, StatusPending, found.Status)
+	require.Equal(t, 1, found.Attempts)
+
+	// Amounts can be in any order
+	found, err = Verify(repo, micro.ID, []int{34, 12}, 3)
+	require.NoError(t, err)
+	require.Equal(t, StatusVerified, found.Status)
+	require.Equal(t, 2, found.Attempts)
+
+	_, err = Verify(repo, micro.ID, []int{12, 34}, 3)
+	require.ErrorIs(t, err, ErrNotPending)
+
+	_, err = Verify(repo, "missing", []int{12, 34}, 3)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestVerify__MaxAttempts(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := NewRepository(db.DB)
+
+	micro, err := New("testing", time.Now())
+	require.NoError(t, err)
+	micro.Amounts = [2]int{12, 34}
+	require.NoError(t, repo.Create(micro))
+
+	found, err := Verify(repo, micro.ID, []int{1, 2}, 2)
+	require.NoError(t, err)
+	require.Equal(t, StatusPending, found.Status)
+
+	found, err = Verify(repo, micro.ID, []int{12}, 2)
+	require.NoError(t, err)
+	require.Equal(t, StatusFailed, found.Status)
+
+	// The right amounts are too late
+	_, err = Verify(repo, micro.ID, []int{12, 34}, 2)
+	require.ErrorIs(t, err, ErrNotPending)
+}
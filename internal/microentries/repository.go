@@ -0,0 +1,92 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package microentries
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Repository interface {
+	Create(micro *MicroEntry) error
+
+	// Get returns the micro-entries with id, or nil
+	Get(id string) (*MicroEntry, error)
+
+	// Update saves the status, attempts and return code of micro
+	Update(micro *MicroEntry) error
+}
+
+// NewRepository returns a Repository stored in db, or nil when db is nil
+func NewRepository(db *sql.DB) Repository {
+	if db == nil {
+		return nil
+	}
+	return &sqlRepository{db: db, now: time.Now}
+}
+
+type sqlRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func (r *sqlRepository) Create(micro *MicroEntry) error {
+	micro.CreatedAt = r.timestamp()
+	micro.UpdatedAt = micro.CreatedAt
+
+	query := `insert into micro_entries (micro_entry_id, shard_key, credit_amount1, credit_amount2, status, attempts, return_code, created_at, updated_at) values (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	_, err := r.db.Exec(query, micro.ID, micro.ShardKey, micro.Amounts[0], micro.Amounts[1], micro.Status, micro.Attempts, micro.ReturnCode, micro.CreatedAt, micro.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("creating micro-entries %s: %v", micro.ID, err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) Get(id string) (*MicroEntry, error) {
+	query := `select micro_entry_id, shard_key, credit_amount1, credit_amount2, status, attempts, return_code, created_at, updated_at from micro_entries where micro_entry_id = ? limit 1;`
+
+	var micro MicroEntry
+	var returnCode sql.NullString
+	err := r.db.QueryRow(query, strings.TrimSpace(id)).Scan(&micro.ID, &micro.ShardKey, &micro.Amounts[0], &micro.Amounts[1], &micro.Status, &micro.Attempts, &returnCode, &micro.CreatedAt, &micro.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading micro-entries %s: %v", id, err)
+	}
+	micro.ReturnCode = returnCode.String
+	return &micro, nil
+}
+
+func (r *sqlRepository) Update(micro *MicroEntry) error {
+	micro.UpdatedAt = r.timestamp()
+
+	query := `update micro_entries set status = ?, attempts = ?, return_code = ?, updated_at = ? where micro_entry_id = ?;`
+	_, err := r.db.Exec(query, micro.Status, micro.Attempts, micro.ReturnCode, micro.UpdatedAt, micro.ID)
+	if err != nil {
+		return fmt.Errorf("updating micro-entries %s: %v", micro.ID, err)
+	}
+	return nil
+}
+
+// timestamp is the current time in UTC with the precision DATETIME columns store
+func (r *sqlRepository) timestamp() time.Time {
+	return r.now().UTC().Truncate(time.Second)
+}
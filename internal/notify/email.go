@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/fips"
 	"github.com/moov-io/achgateway/internal/service"
 
 	gomail "github.com/ory/mail/v3"
@@ -68,10 +69,10 @@ func setupGoMailClient(cfg *service.Email) (*gomail.Dialer, error) {
 	}
 
 	host, _, _ := net.SplitHostPort(uri.Host)
-	tlsConfig := &tls.Config{
+	tlsConfig := fips.TLSConfig(&tls.Config{
 		ServerName: host,
 		MinVersion: tls.VersionTLS12,
-	}
+	})
 
 	skipVerify, _ := strconv.ParseBool(uri.Query().Get("insecure_skip_verify"))
 	tlsConfig.InsecureSkipVerify = skipVerify
@@ -17,6 +17,15 @@ type NACHA struct {
 }
 
 func (n *NACHA) Format(buf *bytes.Buffer, res *transform.Result) error {
+	if len(res.Contents) > 0 {
+		// Contents were rendered by a conformance profile with newlines
+		if n.lineEnding != "" && n.lineEnding != "\n" {
+			buf.Write(bytes.ReplaceAll(res.Contents, []byte("\n"), []byte(n.lineEnding)))
+		} else {
+			buf.Write(res.Contents)
+		}
+		return nil
+	}
 	w := ach.NewWriter(buf)
 	if n.lineEnding != "" {
 		w.LineEnding = n.lineEnding
@@ -60,3 +60,16 @@ func TestNacha__CRLF(t *testing.T) {
 		t.Errorf("unexpected output:\n%v", s)
 	}
 }
+
+func TestNACHA__Contents(t *testing.T) {
+	enc := &NACHA{
+		lineEnding: "\r\n",
+	}
+
+	var buf bytes.Buffer
+	res := testResult(t)
+	res.Contents = []byte("101 RENDERED\n9000001\n")
+
+	require.NoError(t, enc.Format(&buf, res))
+	require.Equal(t, "101 RENDERED\r\n9000001\r\n", buf.String())
+}
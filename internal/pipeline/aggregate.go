@@ -18,26 +18,37 @@
 package pipeline
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/moov-io/ach"
 	"github.com/moov-io/achgateway/internal/alerting"
 	"github.com/moov-io/achgateway/internal/audittrail"
-	"github.com/moov-io/achgateway/internal/consul"
+	"github.com/moov-io/achgateway/internal/bufpool"
+	"github.com/moov-io/achgateway/internal/drain"
 	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/exposure"
+	"github.com/moov-io/achgateway/internal/guardrails"
 	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/leadership"
+	"github.com/moov-io/achgateway/internal/lineage"
 	"github.com/moov-io/achgateway/internal/notify"
 	"github.com/moov-io/achgateway/internal/output"
 	"github.com/moov-io/achgateway/internal/schedule"
+	"github.com/moov-io/achgateway/internal/screening"
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/achgateway/internal/submissions"
 	"github.com/moov-io/achgateway/internal/transform"
 	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/internal/uploadledger"
+	"github.com/moov-io/achgateway/pkg/cpa005"
 	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base"
 	"github.com/moov-io/base/log"
@@ -46,6 +57,7 @@ import (
 
 type aggregator struct {
 	logger       log.Logger
+	elector      leadership.Elector
 	eventEmitter events.Emitter
 	shard        service.Shard
 	uploadAgents service.UploadAgents
@@ -58,17 +70,85 @@ type aggregator struct {
 	preuploadTransformers []transform.PreUpload
 	outputFormatter       output.Formatter
 	alerters              alerting.Alerters
+	guardrails            *guardrails.Checker
+	screening             *screening.Service
+
+	// manifest is non-nil when a checksum or signature is uploaded with each file
+	manifest *manifester
+
+	// cpa005 is non-nil when the shard uploads CPA Standard 005 files instead of Nacha files
+	cpa005 *cpa005Merging
+
+	// cutoffLimit is shared by every shard's aggregator to bound concurrent cutoff processing
+	cutoffLimit cutoffLimiter
+
+	// deferred fires when a maintenance window holding back cutoffs ends
+	deferred <-chan time.Time
+
+	// drain stops new cutoffs once the instance is draining for termination
+	drain *drain.Coordinator
+
+	// uploads records each merged file before it's uploaded so it's never uploaded twice
+	uploads  uploadledger.Repository
+	hostname string
+
+	// lineage records trace numbers and file IDs assigned while merging
+	lineage lineage.Repository
+
+	// exposure records the totals uploaded to each ODFI
+	exposure exposure.Repository
+
+	// submissions holds the metadata files were submitted with
+	submissions submissions.Repository
+
+	// freeze is shared with the FileReceiver so snapshots can pause cutoffs
+	freeze *sync.RWMutex
+
+	// progress marks when a cutoff is merging so late submissions can be handled
+	progress cutoffProgress
+
+	// history times recent cutoffs and early is the cutoff started ahead of its window
+	history cutoffHistory
+	early   *earlyStart
+}
+
+// holdFreeze waits for any snapshot in progress and returns a func to release the hold
+func (xfagg *aggregator) holdFreeze() func() {
+	if xfagg.freeze == nil {
+		return func() {}
+	}
+	xfagg.freeze.RLock()
+	return xfagg.freeze.RUnlock
+}
+
+// cutoffLimiter bounds how many shards process a cutoff at the same time. A nil limiter is unbounded.
+type cutoffLimiter chan struct{}
+
+func newCutoffLimiter(max int) cutoffLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return make(cutoffLimiter, max)
+}
+
+// acquire blocks until a slot is free and returns a func to release it.
+func (l cutoffLimiter) acquire() func() {
+	if l == nil {
+		return func() {}
+	}
+	l <- struct{}{}
+	return func() { <-l }
 }
 
 func newAggregator(
 	logger log.Logger,
-	consul *consul.Client,
+	elector leadership.Elector,
 	eventEmitter events.Emitter,
 	shard service.Shard,
 	uploadAgents service.UploadAgents,
 	errorAlerting service.ErrorAlerting,
 ) (*aggregator, error) {
-	merger, err := NewMerging(logger, consul, shard, uploadAgents)
+	merger, err := NewMerging(logger, elector, shard, uploadAgents)
 	if err != nil {
 		return nil, fmt.Errorf("error creating xfer merger: %v", err)
 	}
@@ -85,6 +165,10 @@ func newAggregator(
 	if err != nil {
 		return nil, err
 	}
+	if profile := uploadAgents.ConformanceProfile(shard.UploadAgent); profile != nil {
+		// Profiles render the file before it's encrypted or formatted
+		preuploadTransformers = append([]transform.PreUpload{transform.NewConformance(profile)}, preuploadTransformers...)
+	}
 	logger.Info().With(log.Fields{
 		"shard": log.String(shard.Name),
 	}).Logf("setup %#v pre-upload transformers", preuploadTransformers)
@@ -108,8 +192,30 @@ func newAggregator(
 		return nil, fmt.Errorf("error setting up alerters: %v", err)
 	}
 
+	var chest storage.Chest
+	if mm, ok := merger.(*filesystemMerging); ok {
+		chest = mm.storage
+	}
+	checker, err := guardrails.New(logger, shard.Name, shard.Guardrails, chest)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up guardrails: %v", err)
+	}
+
+	screener, err := screening.New(logger, shard.Screening)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up screening: %v", err)
+	}
+
+	manifest, err := newManifester(shard.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+
 	return &aggregator{
 		logger:                logger,
+		elector:               elector,
 		eventEmitter:          eventEmitter,
 		shard:                 shard,
 		uploadAgents:          uploadAgents,
@@ -120,20 +226,46 @@ func newAggregator(
 		preuploadTransformers: preuploadTransformers,
 		outputFormatter:       outputFormatter,
 		alerters:              alerters,
+		manifest:              manifest,
+		guardrails:            checker,
+		screening:             screener,
+		cpa005:                newCPA005Merging(logger, elector, shard, uploadAgents, chest),
+		hostname:              hostname,
 	}, nil
 }
 
 func (xfagg *aggregator) Start(ctx context.Context) {
+	// Load pending files left from a previous run without holding up startup
+	if mm, ok := xfagg.merger.(*filesystemMerging); ok {
+		go mm.recoverPending(ctx)
+	}
+
+	// Check for cutoffs a lost leader left unfinished in shared storage
+	var takeovers <-chan time.Time
+	if xfagg.uploadAgents.Merging.SingleWriter {
+		ticker := time.NewTicker(xfagg.uploadAgents.Merging.TakeoverCheckInterval())
+		defer ticker.Stop()
+		takeovers = ticker.C
+	}
+
+	// Summarize pending files ahead of each cutoff
+	previewCutoff, previews := xfagg.schedulePreview(time.Time{})
+
+	// Predict if the next cutoff would miss the ODFI's deadline
+	var earlyChecks <-chan time.Time
+	if cfg := xfagg.shard.Cutoffs.EarlyStart; cfg != nil {
+		ticker := time.NewTicker(cfg.Interval())
+		defer ticker.Stop()
+		earlyChecks = ticker.C
+	}
+
 	for {
 		select {
 		// process automated cutoff time triggering
 		case day := <-xfagg.cutoffs.C:
 			// Run our regular routines
 			if day.IsBankingDay {
-				if err := xfagg.withEachFile(day.Time); err != nil {
-					err = xfagg.logger.LogErrorf("merging files: %v", err).Err()
-					xfagg.alertOnError(err)
-				}
+				xfagg.cutoff(day.Time)
 			}
 			if day.IsHoliday && !day.IsWeekend {
 				xfagg.notifyAboutHoliday(day)
@@ -143,6 +275,21 @@ func (xfagg *aggregator) Start(ctx context.Context) {
 		case waiter := <-xfagg.cutoffTrigger:
 			xfagg.manualCutoff(waiter)
 
+		case <-takeovers:
+			xfagg.takeOverCutoffs()
+
+		case <-previews:
+			xfagg.previewUpload(previewCutoff)
+			previewCutoff, previews = xfagg.schedulePreview(previewCutoff.Add(time.Minute))
+
+		case now := <-earlyChecks:
+			xfagg.checkEarlyStart(now)
+
+		// release cutoffs deferred by a maintenance window
+		case <-xfagg.deferred:
+			xfagg.deferred = nil
+			xfagg.cutoff(time.Now())
+
 		case <-ctx.Done():
 			xfagg.cutoffs.Stop()
 			xfagg.Shutdown()
@@ -161,14 +308,116 @@ func (xfagg *aggregator) Shutdown() {
 	}
 }
 
+// errFileFormat is returned for files in a format the shard doesn't upload. They're never retried.
+var errFileFormat = errors.New("file format not accepted by shard")
+
 func (xfagg *aggregator) acceptFile(msg incoming.ACHFile) error {
+	if xfagg.cpa005 != nil {
+		return fmt.Errorf("%w: shard %s only accepts CPA-005 files", errFileFormat, xfagg.shard.Name)
+	}
+	if err := xfagg.screenFile(msg); err != nil {
+		return err
+	}
 	return xfagg.merger.HandleXfer(msg)
 }
 
+// screenFile checks the file's receivers before it's merged and keeps an audit record of the decision.
+func (xfagg *aggregator) screenFile(msg incoming.ACHFile) error {
+	record, err := xfagg.screening.Screen(xfagg.shard.Name, msg)
+	if record != nil {
+		bs, jsonErr := json.Marshal(record)
+		if jsonErr != nil {
+			return fmt.Errorf("encoding screening record: %v", jsonErr)
+		}
+		path := fmt.Sprintf("screening/%s/%s/%s.json", xfagg.shard.Name, record.ScreenedAt.Format("2006-01-02"), msg.FileID)
+		if auditErr := xfagg.auditStorage.SaveFile(path, bs); auditErr != nil {
+			return fmt.Errorf("saving screening record: %v", auditErr)
+		}
+		screenedFiles.With("shard", xfagg.shard.Name, "outcome", record.Outcome).Add(1)
+	}
+	if err != nil {
+		if errors.Is(err, screening.ErrBlocked) {
+			xfagg.alertOnError(fmt.Errorf("fileID=%s: %w", msg.FileID, err))
+		}
+		return err
+	}
+	return nil
+}
+
+func (xfagg *aggregator) acceptCPA005File(msg incoming.CPA005File) error {
+	if xfagg.cpa005 == nil {
+		return fmt.Errorf("%w: shard %s doesn't accept CPA-005 files", errFileFormat, xfagg.shard.Name)
+	}
+	return xfagg.cpa005.handleFile(msg)
+}
+
 func (xfagg *aggregator) cancelFile(msg incoming.CancelACHFile) error {
+	if xfagg.cpa005 != nil {
+		return xfagg.cpa005.handleCancel(msg)
+	}
 	return xfagg.merger.HandleCancel(msg)
 }
 
+// mergeAndUpload merges the shard's pending files and uploads them for the cutoff window. Files
+// are numbered after those of early when it's non-nil.
+func (xfagg *aggregator) mergeAndUpload(window string, overrideGuardrails bool, early *earlyStart) (*processedFiles, error) {
+	if xfagg.cpa005 != nil {
+		return xfagg.cpa005.withEachMerged(xfagg.uploadCPA005File)
+	}
+	uploads := &cutoffUploads{}
+	processed, err := xfagg.merger.WithEachMerged(early.sequence(xfagg.checkAndUpload(window, overrideGuardrails, uploads)))
+	if processed != nil {
+		processed.window = window
+		processed.uploads = uploads
+	}
+	return processed, err
+}
+
+// cutoff merges and uploads pending files unless the shard's upload agent is in a maintenance
+// window. Deferred cutoffs are released together once the window ends.
+func (xfagg *aggregator) cutoff(when time.Time) {
+	if until, ok := xfagg.uploadAgents.Find(xfagg.shard.UploadAgent).MaintenanceUntil(time.Now()); ok {
+		if xfagg.deferred == nil {
+			xfagg.deferred = time.After(time.Until(until))
+		}
+		deferredCutoffs.With("shard", xfagg.shard.Name).Add(1)
+		xfagg.notifyDeferredCutoff(when, until)
+		return
+	}
+	done, err := xfagg.drain.Begin()
+	if err != nil {
+		xfagg.logger.Warn().Logf("skipping %s cutoff for shard %s: %v", when.Format("15:04"), xfagg.shard.Name, err)
+		return
+	}
+	defer done()
+
+	if err := xfagg.withEachFile(when); err != nil {
+		err = xfagg.logger.LogErrorf("merging files: %v", err).Err()
+		xfagg.alertOnError(err)
+	}
+}
+
+func (xfagg *aggregator) notifyDeferredCutoff(when, until time.Time) {
+	logger := xfagg.logger.With(log.Fields{
+		"shard": log.String(xfagg.shard.Name),
+	})
+	logger.Info().Logf("deferring %s cutoff until maintenance window ends at %s", when.Format("15:04"), until.Format(time.RFC3339))
+
+	uploadAgent := xfagg.uploadAgents.Find(xfagg.shard.UploadAgent)
+	notifier, err := notify.NewMultiSender(logger, xfagg.shard.Notifications, uploadAgent.Notifications)
+	if err != nil {
+		logger.Error().LogErrorf("ERROR creating deferred cutoff notifier: %v", err)
+		return
+	}
+	err = notifier.Info(&notify.Message{
+		Contents: fmt.Sprintf("%s cutoff for shard %s is deferred until %s while %s is in a maintenance window",
+			when.Format("15:04 MST"), xfagg.shard.Name, until.Format(time.RFC3339), uploadAgent.ID),
+	})
+	if err != nil {
+		logger.Error().LogErrorf("ERROR sending deferred cutoff notification: %v", err)
+	}
+}
+
 func (xfagg *aggregator) withEachFile(when time.Time) error {
 	window := when.Format("15:04")
 	tzname, _ := when.Zone()
@@ -180,16 +429,45 @@ func (xfagg *aggregator) withEachFile(when time.Time) error {
 		"shard": log.String(xfagg.shard.Name),
 	}).Logf("ended %s %s cutoff window processing", window, tzname)
 
-	processed, err := xfagg.merger.WithEachMerged(xfagg.runTransformers)
+	// Early starts are ahead of the window so there's no grace period to wait for
+	early := xfagg.earlyStartOf(when)
+	if early == nil || early.finished {
+		xfagg.waitForGracePeriod(when)
+	}
+
+	start := time.Now()
+	defer xfagg.cutoffLimit.acquire()()
+	cutoffQueueDuration.With("shard", xfagg.shard.Name).Observe(time.Since(start).Seconds())
+	defer xfagg.holdFreeze()()
+	defer xfagg.progress.begin()()
+
+	merging := time.Now()
+	processed, err := xfagg.mergeAndUpload(when.Format("2006-01-02 15:04 MST"), false, early)
+	if early != nil {
+		if early.finished {
+			xfagg.early = nil
+		} else {
+			early.finished = true
+		}
+	}
 	if err != nil {
 		xfagg.logger.LogErrorf("ERROR inside WithEachMerged: %v", err)
 		return fmt.Errorf("merging ACH files: %v", err)
 	}
+	if processed != nil {
+		xfagg.history.record(len(processed.fileIDs), time.Since(merging))
+	}
 
 	if err := xfagg.emitFilesUploaded(processed); err != nil {
 		xfagg.logger.LogErrorf("ERROR sending files uploaded event: %v", err)
 	}
 
+	// Files uploaded without their manifest aren't uploaded again, so the cutoff fails to alert on them
+	if processed != nil {
+		if err := processed.uploads.manifestError(); err != nil {
+			return fmt.Errorf("uploading manifests: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -198,7 +476,32 @@ func (xfagg *aggregator) manualCutoff(waiter manuallyTriggeredCutoff) {
 		"shard": log.String(xfagg.shard.Name),
 	}).Log("starting manual cutoff window processing")
 
-	if processed, err := xfagg.merger.WithEachMerged(xfagg.runTransformers); err != nil {
+	if until, ok := xfagg.uploadAgents.Find(xfagg.shard.UploadAgent).MaintenanceUntil(time.Now()); ok {
+		waiter.C <- fmt.Errorf("upload agent %s is in a maintenance window until %s", xfagg.shard.UploadAgent, until.Format(time.RFC3339))
+		return
+	}
+	done, err := xfagg.drain.Begin()
+	if err != nil {
+		waiter.C <- err
+		return
+	}
+	defer done()
+
+	start := time.Now()
+	defer xfagg.cutoffLimit.acquire()()
+	cutoffQueueDuration.With("shard", xfagg.shard.Name).Observe(time.Since(start).Seconds())
+	defer xfagg.holdFreeze()()
+	defer xfagg.progress.begin()()
+
+	if waiter.overrideGuardrails {
+		if err := xfagg.releaseHeldFiles(); err != nil {
+			xfagg.logger.LogErrorf("ERROR releasing held files: %v", err)
+			waiter.C <- err
+			return
+		}
+	}
+
+	if processed, err := xfagg.mergeAndUpload(manualWindow, waiter.overrideGuardrails, nil); err != nil {
 		xfagg.logger.LogErrorf("ERROR inside manual WithEachMerged: %v", err)
 		waiter.C <- err
 	} else {
@@ -206,6 +509,9 @@ func (xfagg *aggregator) manualCutoff(waiter manuallyTriggeredCutoff) {
 		if err := xfagg.emitFilesUploaded(processed); err != nil {
 			xfagg.logger.LogErrorf("ERROR sending manual files uploaded event: %v", err)
 		}
+		if processed != nil {
+			err = processed.uploads.manifestError()
+		}
 		waiter.C <- err
 	}
 
@@ -214,15 +520,201 @@ func (xfagg *aggregator) manualCutoff(waiter manuallyTriggeredCutoff) {
 	}).Log("ended manual cutoff window processing")
 }
 
+// takeOverCutoffs finishes uploading cutoffs which another instance was processing when it was
+// lost and sends events for the takeover along with the uploaded files.
+func (xfagg *aggregator) takeOverCutoffs() {
+	mm, ok := xfagg.merger.(*filesystemMerging)
+	if !ok {
+		return
+	}
+	defer xfagg.holdFreeze()()
+
+	done, err := xfagg.drain.Begin()
+	if err != nil {
+		return
+	}
+	defer done()
+
+	uploads := &cutoffUploads{}
+	takeovers, err := mm.takeOverCutoffs(xfagg.checkAndUpload(takeoverWindow, false, uploads))
+	if err != nil {
+		err = xfagg.logger.LogErrorf("taking over cutoffs: %v", err).Err()
+		xfagg.alertOnError(err)
+	}
+	if err := uploads.manifestError(); err != nil {
+		err = xfagg.logger.LogErrorf("taking over cutoffs: uploading manifests: %v", err).Err()
+		xfagg.alertOnError(err)
+	}
+	for _, t := range takeovers {
+		takenOverCutoffs.With("shard", xfagg.shard.Name).Add(1)
+
+		err := xfagg.eventEmitter.Send(models.Event{
+			Event: models.CutoffTakenOver{
+				ShardKey:       xfagg.shard.Name,
+				PreviousHolder: t.journal.Holder,
+				Holder:         mm.hostname,
+				StartedAt:      t.journal.StartedAt,
+				TakenOverAt:    t.takenOverAt,
+				FileIDs:        t.processed.fileIDs,
+			},
+		})
+		if err != nil {
+			xfagg.logger.LogErrorf("ERROR sending cutoff taken over event: %v", err)
+		}
+		t.processed.window = takeoverWindow
+		t.processed.uploads = uploads
+		if err := xfagg.emitFilesUploaded(t.processed); err != nil {
+			xfagg.logger.LogErrorf("ERROR sending files uploaded event: %v", err)
+		}
+	}
+}
+
+// checkAndUpload returns a WithEachMerged callback which holds files failing the shard's
+// guardrails and uploads the rest, adding them to uploads. Guardrails are skipped when override is true.
+func (xfagg *aggregator) checkAndUpload(window string, override bool, uploads *cutoffUploads) func(int, upload.Agent, *ach.File) error {
+	return func(index int, agent upload.Agent, outgoing *ach.File) error {
+		if xfagg.guardrails != nil && !override {
+			result, err := xfagg.guardrails.Check(outgoing)
+			if err != nil {
+				return fmt.Errorf("checking guardrails: %v", err)
+			}
+			if len(result.Held) > 0 {
+				return xfagg.holdFile(agent, outgoing, append(result.Held, result.Flagged...))
+			}
+			if len(result.Flagged) > 0 {
+				xfagg.flagFile(agent, outgoing, result.Flagged)
+			}
+		}
+
+		if err := xfagg.runTransformers(window, index, agent, outgoing, uploads); err != nil {
+			return err
+		}
+		if err := xfagg.guardrails.Record(outgoing); err != nil {
+			xfagg.logger.Warn().LogErrorf("problem recording guardrails history: %v", err)
+		}
+		return nil
+	}
+}
+
+func (xfagg *aggregator) holdFile(agent upload.Agent, file *ach.File, reasons []string) error {
+	path, err := xfagg.guardrails.Hold(file, reasons)
+	if err != nil {
+		return err
+	}
+	heldFiles.With("shard", xfagg.shard.Name).Add(1)
+
+	msg := &notify.Message{
+		Contents: fmt.Sprintf("HELD file for shard %s at %s: %s -- trigger a manual cutoff with overrideGuardrails to upload",
+			xfagg.shard.Name, path, strings.Join(reasons, "; ")),
+	}
+	if err := xfagg.sendCritical(agent, msg); err != nil {
+		xfagg.logger.Error().LogErrorf("problem sending held file notification: %v", err)
+	}
+
+	return fmt.Errorf("held file %s for approval: %s", path, strings.Join(reasons, "; "))
+}
+
+func (xfagg *aggregator) flagFile(agent upload.Agent, file *ach.File, reasons []string) {
+	flaggedFiles.With("shard", xfagg.shard.Name).Add(1)
+
+	msg := &notify.Message{
+		Contents: fmt.Sprintf("FLAGGED file for shard %s with %d debits and %d credits: %s",
+			xfagg.shard.Name, file.Control.TotalDebitEntryDollarAmountInFile, file.Control.TotalCreditEntryDollarAmountInFile,
+			strings.Join(reasons, "; ")),
+	}
+	if err := xfagg.sendCritical(agent, msg); err != nil {
+		xfagg.logger.Error().LogErrorf("problem sending flagged file notification: %v", err)
+	}
+}
+
+// reportNonconformance notifies operators of a merged file which wasn't uploaded because it
+// violates the ODFI's conformance profile.
+func (xfagg *aggregator) reportNonconformance(agent upload.Agent, cerr *transform.ConformanceError) {
+	nonconformingFiles.With("shard", xfagg.shard.Name, "profile", cerr.Profile).Add(1)
+
+	msg := &notify.Message{
+		Contents: fmt.Sprintf("NONCONFORMING file for shard %s was not uploaded to %s: %s",
+			xfagg.shard.Name, agent.Hostname(), cerr.Error()),
+	}
+	if err := xfagg.sendCritical(agent, msg); err != nil {
+		xfagg.logger.Error().LogErrorf("problem sending nonconforming file notification: %v", err)
+	}
+}
+
+// releaseHeldFiles uploads each file previously held by the guardrails.
+func (xfagg *aggregator) releaseHeldFiles() error {
+	held, err := xfagg.guardrails.HeldFiles()
+	if err != nil {
+		return err
+	}
+	if len(held) == 0 {
+		return nil
+	}
+
+	leaderKey := outboundLeaderKey(xfagg.shard.Name)
+	if err := leadership.AcquireLock(xfagg.elector, leaderKey); err != nil {
+		xfagg.logger.Warn().Logf("skipping release of held files: %v", err)
+		return nil
+	}
+
+	agent, err := upload.New(xfagg.logger, xfagg.uploadAgents, xfagg.shard.UploadAgent)
+	if err != nil {
+		return fmt.Errorf("agent: %v", err)
+	}
+
+	var el base.ErrorList
+	for i := range held {
+		if err := xfagg.runTransformers(manualWindow, i, agent, held[i].File, nil); err != nil {
+			el.Add(fmt.Errorf("uploading held file %s: %v", held[i].Path, err))
+			continue
+		}
+		if err := xfagg.guardrails.Record(held[i].File); err != nil {
+			xfagg.logger.Warn().LogErrorf("problem recording guardrails history: %v", err)
+		}
+		if err := xfagg.guardrails.Release(held[i]); err != nil {
+			el.Add(fmt.Errorf("releasing held file %s: %v", held[i].Path, err))
+		}
+		xfagg.logger.Info().Logf("uploaded approved held file %s", held[i].Path)
+	}
+	if el.Empty() {
+		return nil
+	}
+	return el
+}
+
 func (xfagg *aggregator) emitFilesUploaded(proc *processedFiles) error {
 	var el base.ErrorList
 	for i := range proc.fileIDs {
+		event := models.FileUploaded{
+			FileID:      proc.fileIDs[i],
+			ShardKey:    proc.shardKey,
+			UploadedAt:  time.Now(),
+			Settlements: proc.settlements[proc.fileIDs[i]],
+			Metadata:    xfagg.submissionMetadata(proc.fileIDs[i]),
+
+			CutoffWindow:    proc.window,
+			MergeDurationMs: proc.mergeDuration.Milliseconds(),
+		}
+		if summary, exists := proc.summaries[proc.fileIDs[i]]; exists {
+			event.EntryCount = summary.entryCount
+			event.Batches = summary.batches
+
+			if uploaded := proc.uploads.find(summary.firstTrace); uploaded != nil {
+				event.Filename = uploaded.filename
+				event.RemotePath = uploaded.remotePath
+				if uploaded.collision != nil {
+					event.Collision = &models.FilenameCollision{
+						Policy:   uploaded.collision.Policy,
+						Filename: uploaded.collision.Filename,
+					}
+				}
+				event.Size = uploaded.size
+				event.SHA256 = uploaded.sha256
+				event.UploadDurationMs = uploaded.duration.Milliseconds()
+			}
+		}
 		err := xfagg.eventEmitter.Send(models.Event{
-			Event: models.FileUploaded{
-				FileID:     proc.fileIDs[i],
-				ShardKey:   proc.shardKey,
-				UploadedAt: time.Now(),
-			},
+			Event: event,
 		})
 		if err != nil {
 			el.Add(err)
@@ -234,24 +726,31 @@ func (xfagg *aggregator) emitFilesUploaded(proc *processedFiles) error {
 	return el
 }
 
-func (xfagg *aggregator) runTransformers(index int, agent upload.Agent, outgoing *ach.File) error {
+func (xfagg *aggregator) runTransformers(window string, index int, agent upload.Agent, outgoing *ach.File, uploads *cutoffUploads) error {
 	result, err := transform.ForUpload(outgoing, xfagg.preuploadTransformers)
 	if err != nil {
+		var cerr *transform.ConformanceError
+		if errors.As(err, &cerr) {
+			xfagg.reportNonconformance(agent, cerr)
+		}
 		return err
 	}
-	return xfagg.uploadFile(index, agent, result)
+	return xfagg.uploadFile(window, index, agent, result, uploads)
 }
 
-func (xfagg *aggregator) uploadFile(index int, agent upload.Agent, res *transform.Result) error {
+func (xfagg *aggregator) uploadFile(window string, index int, agent upload.Agent, res *transform.Result, uploads *cutoffUploads) error {
 	if res == nil || res.File == nil {
 		return errors.New("uploadFile: nil Result / File")
 	}
 
+	company, secCode := sharedBatchFields(res.File)
 	data := upload.FilenameData{
-		RoutingNumber: res.File.Header.ImmediateDestination,
-		GPG:           len(res.Encrypted) > 0,
-		ShardName:     prepareShardName(xfagg.shard.Name),
-		Index:         index,
+		RoutingNumber:         res.File.Header.ImmediateDestination,
+		GPG:                   len(res.Encrypted) > 0,
+		ShardName:             prepareShardName(xfagg.shard.Name),
+		Index:                 index,
+		CompanyIdentification: strings.TrimSpace(company),
+		SECCode:               secCode,
 	}
 	filename, err := upload.RenderACHFilename(xfagg.shard.FilenameTemplate(), data)
 	if err != nil {
@@ -259,24 +758,63 @@ func (xfagg *aggregator) uploadFile(index int, agent upload.Agent, res *transfor
 		return fmt.Errorf("problem rendering filename template: %v", err)
 	}
 
-	var buf bytes.Buffer
-	if err := xfagg.outputFormatter.Format(&buf, res); err != nil {
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+
+	if err := xfagg.outputFormatter.Format(buf, res); err != nil {
 		uploadFilesErrors.With().Add(1)
 		return fmt.Errorf("problem formatting output: %v", err)
 	}
 
+	// Check the file hasn't been uploaded already
+	finished, skip, err := xfagg.beginUpload(window, filename, res.File)
+	if err != nil {
+		uploadFilesErrors.With("shard", xfagg.shard.Name).Add(1)
+		return err
+	}
+	if skip {
+		return nil
+	}
+
+	// Follow the agent's OnCollision when a file by this name is already on the remote server
+	agentConfig := xfagg.uploadAgents.Find(xfagg.shard.UploadAgent)
+	outgoing, collision, err := upload.AvoidCollision(agent, agentConfig, upload.File{
+		Filename:      filename,
+		Contents:      upload.NewContents(buf.Bytes()),
+		RoutingNumber: strings.TrimSpace(res.File.Header.ImmediateDestination),
+	})
+	if err != nil {
+		finished(err)
+		uploadFilesErrors.With("shard", xfagg.shard.Name).Add(1)
+		return fmt.Errorf("problem with filename collision: %v", err)
+	}
+	if collision != nil {
+		xfagg.logger.Warn().Logf("%s already exists on %s, following OnCollision=%s and uploading %s",
+			collision.Filename, agent.Hostname(), collision.Policy, outgoing.Filename)
+	}
+	filename = outgoing.Filename
+
 	// Record the file in our audit trail
 	path := fmt.Sprintf("outbound/%s/%s/%s", agent.Hostname(), time.Now().Format("2006-01-02"), filename)
 	if err := xfagg.auditStorage.SaveFile(path, buf.Bytes()); err != nil {
+		finished(err)
 		uploadFilesErrors.With().Add(1)
 		return fmt.Errorf("problem saving file in audit record: %v", err)
 	}
 
 	// Upload our file
-	err = agent.UploadFile(upload.File{
-		Filename: filename,
-		Contents: io.NopCloser(&buf),
-	})
+	uploaded := uploadedFile{
+		filename:   filename,
+		remotePath: upload.RemotePath(agentConfig, outgoing),
+		size:       int64(buf.Len()),
+		sha256:     hash(buf.Bytes()),
+		collision:  collision,
+	}
+
+	start := time.Now()
+	err = agent.UploadFile(outgoing)
+	uploaded.duration = time.Since(start)
+	finished(err)
 
 	// Send Slack/PD or whatever notifications after the file is uploaded
 	if err := xfagg.notifyAfterUpload(filename, res.File, agent, err); err != nil {
@@ -288,11 +826,172 @@ func (xfagg *aggregator) uploadFile(index int, agent upload.Agent, res *transfor
 		uploadFilesErrors.With("shard", xfagg.shard.Name).Add(1)
 	} else {
 		uploadedFilesCounter.With("shard", xfagg.shard.Name).Add(1)
+		xfagg.recordExposure(res.File)
+		uploads.add(res.File, uploaded)
+	}
+
+	// The file is with the ODFI now, so a missing manifest is retried and returned with the
+	// cutoff's uploads without failing the upload and sending the file again next cutoff
+	if err == nil && xfagg.manifest != nil {
+		if merr := xfagg.uploadManifestWithRetries(agent, outgoing, buf.Bytes()); merr != nil {
+			xfagg.logger.LogError(merr)
+			uploads.manifestFailed(merr)
+		}
 	}
 
 	return err
 }
 
+// manifestAttempts is how many times a manifest is uploaded before giving up
+const manifestAttempts = 3
+
+var manifestRetryDelay = 5 * time.Second
+
+// uploadManifestWithRetries waits longer after each failed attempt, but stops retrying once
+// the instance is draining for termination.
+func (xfagg *aggregator) uploadManifestWithRetries(agent upload.Agent, outgoing upload.File, contents []byte) error {
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		if err = xfagg.uploadManifest(agent, outgoing, contents); err == nil {
+			return nil
+		}
+		if attempt == manifestAttempts || !xfagg.waitToRetry(time.Duration(attempt)*manifestRetryDelay) {
+			break
+		}
+	}
+	uploadManifestErrors.With("shard", xfagg.shard.Name).Add(1)
+	return fmt.Errorf("%s was uploaded without its manifest after %d of %d attempts: %v", outgoing.Filename, attempt, manifestAttempts, err)
+}
+
+// waitToRetry waits for delay and returns false if the instance starts draining first
+func (xfagg *aggregator) waitToRetry(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-xfagg.drain.Draining():
+		return false
+	}
+}
+
+// uploadManifest uploads the checksum or signature of contents after outgoing was uploaded
+func (xfagg *aggregator) uploadManifest(agent upload.Agent, outgoing upload.File, contents []byte) error {
+	bs, err := xfagg.manifest.create(outgoing.Filename, contents)
+	if err != nil {
+		return fmt.Errorf("problem creating manifest: %v", err)
+	}
+	manifest := upload.File{
+		Filename:      xfagg.shard.Manifest.Filename(outgoing.Filename),
+		Contents:      upload.NewContents(bs),
+		RoutingNumber: outgoing.RoutingNumber,
+	}
+
+	path := fmt.Sprintf("outbound/%s/%s/%s", agent.Hostname(), time.Now().Format("2006-01-02"), manifest.Filename)
+	if err := xfagg.auditStorage.SaveFile(path, bs); err != nil {
+		return fmt.Errorf("problem saving manifest in audit record: %v", err)
+	}
+	if err := agent.UploadFile(manifest); err != nil {
+		return fmt.Errorf("problem uploading manifest %s: %v", manifest.Filename, err)
+	}
+	return nil
+}
+
+// recordExposure adds an uploaded file to the daily totals of each ODFI. The file has already
+// been uploaded so problems are only alerted on.
+func (xfagg *aggregator) recordExposure(file *ach.File) {
+	if xfagg.exposure == nil {
+		return
+	}
+	if err := xfagg.exposure.Record(xfagg.shard.Name, exposure.KindUploaded, exposure.Uploaded(file), time.Now()); err != nil {
+		xfagg.alertOnError(xfagg.logger.LogErrorf("problem recording exposure: %v", err).Err())
+	}
+}
+
+// uploadCPA005File saves file in the audit trail and uploads it. Pre-upload transformers and
+// output formatters only apply to Nacha files, so the file is uploaded as-is.
+func (xfagg *aggregator) uploadCPA005File(agent upload.Agent, file *cpa005.File) error {
+	data := upload.FilenameData{
+		RoutingNumber: file.Header.DestinationDataCentre,
+		ShardName:     prepareShardName(xfagg.shard.Name),
+	}
+	filename, err := upload.RenderACHFilename(xfagg.shard.FilenameTemplate(), data)
+	if err != nil {
+		uploadFilesErrors.With().Add(1)
+		return fmt.Errorf("problem rendering filename template: %v", err)
+	}
+
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+
+	if err := file.Write(buf); err != nil {
+		uploadFilesErrors.With().Add(1)
+		return fmt.Errorf("problem writing CPA-005 file: %v", err)
+	}
+
+	outgoing, collision, err := upload.AvoidCollision(agent, xfagg.uploadAgents.Find(xfagg.shard.UploadAgent), upload.File{
+		Filename:      filename,
+		Contents:      upload.NewContents(buf.Bytes()),
+		RoutingNumber: strings.TrimSpace(file.Header.DestinationDataCentre),
+	})
+	if err != nil {
+		uploadFilesErrors.With("shard", xfagg.shard.Name).Add(1)
+		return fmt.Errorf("problem with filename collision: %v", err)
+	}
+	if collision != nil {
+		xfagg.logger.Warn().Logf("%s already exists on %s, following OnCollision=%s and uploading %s",
+			collision.Filename, agent.Hostname(), collision.Policy, outgoing.Filename)
+	}
+	filename = outgoing.Filename
+
+	// Record the file in our audit trail
+	path := fmt.Sprintf("outbound/%s/%s/%s", agent.Hostname(), time.Now().Format("2006-01-02"), filename)
+	if err := xfagg.auditStorage.SaveFile(path, buf.Bytes()); err != nil {
+		uploadFilesErrors.With().Add(1)
+		return fmt.Errorf("problem saving file in audit record: %v", err)
+	}
+
+	err = agent.UploadFile(outgoing)
+
+	status := "SUCCESSFUL"
+	if err != nil {
+		status = "FAILED"
+	}
+	msg := &notify.Message{
+		Contents: fmt.Sprintf("%s upload of CPA-005 file %s to %s\n%d debits (%s) | %d credits (%s)",
+			status, filename, agent.Hostname(),
+			file.Trailer.TotalDebitCount, convertCents(file.Trailer.TotalDebitAmount),
+			file.Trailer.TotalCreditCount, convertCents(file.Trailer.TotalCreditAmount)),
+	}
+	if notifyErr := xfagg.notifyCPA005Upload(agent, msg, err); notifyErr != nil {
+		xfagg.alertOnError(xfagg.logger.LogError(notifyErr).Err())
+	}
+
+	if err != nil {
+		uploadFilesErrors.With("shard", xfagg.shard.Name).Add(1)
+	} else {
+		uploadedFilesCounter.With("shard", xfagg.shard.Name).Add(1)
+	}
+	return err
+}
+
+func (xfagg *aggregator) notifyCPA005Upload(agent upload.Agent, msg *notify.Message, uploadErr error) error {
+	notifier, err := xfagg.notifier(agent)
+	if err != nil {
+		return err
+	}
+	if uploadErr != nil {
+		return notifier.Critical(msg)
+	}
+	return notifier.Info(msg)
+}
+
+func convertCents(amount int) string {
+	return fmt.Sprintf("$%d.%02d", amount/100, amount%100)
+}
+
 func prepareShardName(shardName string) string {
 	return strings.ToUpper(strings.ReplaceAll(shardName, " ", "-"))
 }
@@ -305,19 +1004,9 @@ func (xfagg *aggregator) notifyAfterUpload(filename string, file *ach.File, agen
 		Hostname:  agent.Hostname(),
 	}
 
-	uploadAgent := xfagg.uploadAgents.Find(agent.ID())
-
-	if uploadAgent == nil {
-		return fmt.Errorf("no uploadAgent found for id=%s", agent.ID())
-	}
-
-	logger := xfagg.logger.With(log.Fields{
-		"shard": log.String(xfagg.shard.Name),
-	})
-
-	notifier, err := notify.NewMultiSender(logger, xfagg.shard.Notifications, uploadAgent.Notifications)
+	notifier, err := xfagg.notifier(agent)
 	if err != nil {
-		return fmt.Errorf("notify: unable to create multi-sender: %v", err)
+		return err
 	}
 
 	if uploadErr != nil {
@@ -333,6 +1022,32 @@ func (xfagg *aggregator) notifyAfterUpload(filename string, file *ach.File, agen
 	return nil
 }
 
+func (xfagg *aggregator) notifier(agent upload.Agent) (*notify.MultiSender, error) {
+	uploadAgent := xfagg.uploadAgents.Find(agent.ID())
+
+	if uploadAgent == nil {
+		return nil, fmt.Errorf("no uploadAgent found for id=%s", agent.ID())
+	}
+
+	logger := xfagg.logger.With(log.Fields{
+		"shard": log.String(xfagg.shard.Name),
+	})
+
+	notifier, err := notify.NewMultiSender(logger, xfagg.shard.Notifications, uploadAgent.Notifications)
+	if err != nil {
+		return nil, fmt.Errorf("notify: unable to create multi-sender: %v", err)
+	}
+	return notifier, nil
+}
+
+func (xfagg *aggregator) sendCritical(agent upload.Agent, msg *notify.Message) error {
+	notifier, err := xfagg.notifier(agent)
+	if err != nil {
+		return err
+	}
+	return notifier.Critical(msg)
+}
+
 func (xfagg *aggregator) notifyAboutHoliday(day *schedule.Day) {
 	logger := xfagg.logger.With(log.Fields{
 		"shard": log.String(xfagg.shard.Name),
@@ -20,14 +20,22 @@ package pipeline
 import (
 	"context"
 	"errors"
+	"fmt"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/drain"
 	"github.com/moov-io/achgateway/internal/events"
 	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/loadtest"
+	"github.com/moov-io/achgateway/internal/screening"
 	"github.com/moov-io/achgateway/internal/service"
 	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base"
 	"github.com/moov-io/base/log"
 
 	"github.com/stretchr/testify/require"
@@ -79,6 +87,80 @@ func TestAggregateACHFile(t *testing.T) {
 	require.Equal(t, "ppd-file1", merge.LatestFile.FileID)
 }
 
+func TestAggregateACHFile_Screening(t *testing.T) {
+	shard := service.Shard{
+		Name: "test",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/Los_Angeles",
+			Windows:  []string{"10:30"},
+		},
+		UploadAgent: "ftp-live",
+		Screening: &service.Screening{
+			Blocklist: &service.Blocklist{
+				Accounts: []string{"12345"},
+			},
+		},
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "ftp-live",
+				Mock: &service.MockAgent{},
+			},
+		},
+		DefaultAgentID: "ftp-live",
+	}
+	var errorAlerting service.ErrorAlerting
+
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, &events.MockEmitter{}, shard, uploadAgents, errorAlerting)
+	require.NoError(t, err)
+
+	merge := &MockXferMerging{}
+	xfagg.merger = merge
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	err = xfagg.acceptFile(incoming.ACHFile{
+		FileID:   "ppd-file1",
+		ShardKey: "test",
+		File:     file,
+	})
+	require.ErrorIs(t, err, screening.ErrBlocked)
+	require.Nil(t, merge.LatestFile)
+}
+
+func TestCutoffLimiter(t *testing.T) {
+	// nil limiters never block
+	var unbounded cutoffLimiter
+	unbounded.acquire()()
+	require.Nil(t, newCutoffLimiter(0))
+
+	limiter := newCutoffLimiter(2)
+
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer limiter.acquire()()
+
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(2), maxRunning)
+}
+
 func TestAggregate_notifyAfterUpload(t *testing.T) {
 	mockAgent := &upload.MockAgent{}
 
@@ -146,3 +228,141 @@ func TestAggregate_notifyAfterUploadErr(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func BenchmarkAggregate__cutoff(b *testing.B) {
+	shard := service.Shard{
+		Name: "testing",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/Los_Angeles",
+			Windows:  []string{"10:30"},
+		},
+		UploadAgent: "mock",
+	}
+	var errorAlerting service.ErrorAlerting
+
+	gen := loadtest.NewGenerator(100)
+	dir := b.TempDir()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		// Cutoffs isolate pending files into a directory named by the second, so each run gets its own storage
+		uploadAgents := service.UploadAgents{
+			Agents: []service.UploadAgent{
+				{
+					ID:   "mock",
+					Mock: &service.MockAgent{},
+				},
+			},
+			DefaultAgentID: "mock",
+		}
+		uploadAgents.Merging.Storage.Filesystem.Directory = filepath.Join(dir, fmt.Sprintf("%d", i))
+
+		xfagg, err := newAggregator(log.NewNopLogger(), nil, &events.MockEmitter{}, shard, uploadAgents, errorAlerting)
+		require.NoError(b, err)
+
+		for j := 0; j < 20; j++ {
+			file, err := gen.File()
+			require.NoError(b, err)
+			require.NoError(b, xfagg.acceptFile(incoming.ACHFile{FileID: base.ID(), ShardKey: "testing", File: file}))
+		}
+		b.StartTimer()
+
+		// Merge, format and upload every pending file
+		require.NoError(b, xfagg.withEachFile(time.Now()))
+	}
+}
+
+type countingMerging struct {
+	MockXferMerging
+	merges int
+}
+
+func (merge *countingMerging) WithEachMerged(f func(int, upload.Agent, *ach.File) error) (*processedFiles, error) {
+	merge.merges++
+	return &processedFiles{}, nil
+}
+
+func TestAggregate_MaintenanceWindow(t *testing.T) {
+	shard := service.Shard{
+		Name: "test",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/Los_Angeles",
+			Windows:  []string{"10:30"},
+		},
+		UploadAgent: "ftp-live",
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "ftp-live",
+				Mock: &service.MockAgent{},
+				MaintenanceWindows: []service.MaintenanceWindow{
+					{
+						Start:    time.Now().UTC().Add(-time.Minute).Format("15:04"),
+						Duration: time.Hour,
+					},
+				},
+			},
+		},
+	}
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, &events.MockEmitter{}, shard, uploadAgents, service.ErrorAlerting{})
+	require.NoError(t, err)
+
+	merge := &countingMerging{}
+	xfagg.merger = merge
+
+	// Cutoffs during the window are deferred
+	xfagg.cutoff(time.Now())
+	require.Equal(t, 0, merge.merges)
+	require.NotNil(t, xfagg.deferred)
+
+	waiter := manuallyTriggeredCutoff{C: make(chan error, 1)}
+	xfagg.manualCutoff(waiter)
+	require.ErrorContains(t, <-waiter.C, "ftp-live is in a maintenance window")
+
+	// Once the window is over the cutoff runs
+	xfagg.uploadAgents.Agents[0].MaintenanceWindows = nil
+	xfagg.cutoff(time.Now())
+	require.Equal(t, 1, merge.merges)
+}
+
+func TestAggregate_Draining(t *testing.T) {
+	shard := service.Shard{
+		Name: "test",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/Los_Angeles",
+			Windows:  []string{"10:30"},
+		},
+		UploadAgent: "ftp-live",
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "ftp-live",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, &events.MockEmitter{}, shard, uploadAgents, service.ErrorAlerting{})
+	require.NoError(t, err)
+
+	merge := &countingMerging{}
+	xfagg.merger = merge
+	xfagg.drain = drain.New(log.NewNopLogger(), nil)
+
+	xfagg.cutoff(time.Now())
+	require.Equal(t, 1, merge.merges)
+
+	// Draining instances don't start cutoffs
+	xfagg.drain.Drain()
+	<-xfagg.drain.Safe()
+
+	xfagg.cutoff(time.Now())
+	require.Equal(t, 1, merge.merges)
+
+	waiter := manuallyTriggeredCutoff{C: make(chan error, 1)}
+	xfagg.manualCutoff(waiter)
+	require.ErrorIs(t, <-waiter.C, drain.ErrDraining)
+}
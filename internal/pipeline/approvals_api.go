@@ -0,0 +1,134 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/moov-io/achgateway/internal/approvals"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+)
+
+type pendingApprovals struct {
+	Approvals []approvals.Approval `json:"approvals"`
+}
+
+// RequestApproval saves the action for a second operator to approve when approvals are configured
+// and reports if it did, otherwise the caller runs the action itself.
+func (fr *FileReceiver) RequestApproval(w http.ResponseWriter, r *http.Request, action, description string, execute func() error) bool {
+	if fr == nil || fr.approvals == nil {
+		return false
+	}
+	fr.requestApproval(w, r, action, description, execute)
+	return true
+}
+
+// requestApproval saves the action for a second operator to approve and responds with the pending approval.
+func (fr *FileReceiver) requestApproval(w http.ResponseWriter, r *http.Request, action, description string, execute func() error) {
+	op, err := fr.approvals.Authenticate(r)
+	if err != nil {
+		writeApprovalError(w, err)
+		return
+	}
+	approval, err := fr.approvals.Request(op, action, description, execute)
+	if err != nil {
+		writeApprovalError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(approval)
+}
+
+func (fr *FileReceiver) listApprovals() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if fr.approvals == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if _, err := fr.approvals.Authenticate(r); err != nil {
+			writeApprovalError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(pendingApprovals{
+			Approvals: fr.approvals.Pending(),
+		})
+	}
+}
+
+func (fr *FileReceiver) approveAction() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if fr.approvals == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		logger := fr.logger.With(log.Fields{
+			"route": log.String("approve_action"),
+		})
+
+		op, err := fr.approvals.Authenticate(r)
+		if err != nil {
+			writeApprovalError(w, err)
+			return
+		}
+		approval, err := fr.approvals.Approve(op, mux.Vars(r)["approvalID"])
+		if err != nil {
+			logger.Warn().Logf("%s was unable to approve: %v", op.Name, err)
+			writeApprovalError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if approval.Status == approvals.StatusFailed {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		json.NewEncoder(w).Encode(approval)
+	}
+}
+
+func writeApprovalError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, approvals.ErrUnauthorized):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, approvals.ErrForbidden):
+		w.WriteHeader(http.StatusForbidden)
+	case errors.Is(err, approvals.ErrNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,182 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/notify"
+	"github.com/moov-io/achgateway/internal/upload"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/base/log"
+)
+
+// cutoffHistorySize is how many of a shard's recent cutoffs are used for predictions
+const cutoffHistorySize = 20
+
+// cutoffHistory is how long a shard's recent cutoffs took to merge and upload their files.
+// It's only used from the aggregator's Start loop.
+type cutoffHistory struct {
+	samples []cutoffSample
+}
+
+type cutoffSample struct {
+	files    int
+	duration time.Duration
+}
+
+// record adds a finished cutoff, cutoffs without files don't say anything about the time per file
+func (h *cutoffHistory) record(files int, duration time.Duration) {
+	if files <= 0 {
+		return
+	}
+	h.samples = append(h.samples, cutoffSample{files: files, duration: duration})
+	if n := len(h.samples); n > cutoffHistorySize {
+		h.samples = h.samples[n-cutoffHistorySize:]
+	}
+}
+
+// perFile returns the average time each file of the recent cutoffs took
+func (h *cutoffHistory) perFile() (time.Duration, bool) {
+	var files int
+	var total time.Duration
+	for _, s := range h.samples {
+		files += s.files
+		total += s.duration
+	}
+	if files == 0 {
+		return 0, false
+	}
+	return total / time.Duration(files), true
+}
+
+// earlyStart is a cutoff started ahead of its window. The regular cutoff still runs at the
+// window for files submitted afterwards and numbers its files after the early ones.
+type earlyStart struct {
+	cutoff time.Time
+
+	// files is how many merged files the early start numbered
+	files    int
+	finished bool
+}
+
+// earlyStartOf returns the early start of the cutoff at when, or nil
+func (xfagg *aggregator) earlyStartOf(when time.Time) *earlyStart {
+	if xfagg.early != nil && xfagg.early.cutoff.Equal(when) {
+		return xfagg.early
+	}
+	return nil
+}
+
+// sequence offsets the Index of files merged after an early start so their filenames don't
+// collide with the files it uploaded.
+func (e *earlyStart) sequence(f func(int, upload.Agent, *ach.File) error) func(int, upload.Agent, *ach.File) error {
+	if e == nil {
+		return f
+	}
+	offset := 0
+	if e.finished {
+		offset = e.files
+	}
+	return func(index int, agent upload.Agent, file *ach.File) error {
+		if !e.finished && index+1 > e.files {
+			e.files = index + 1
+		}
+		return f(index+offset, agent, file)
+	}
+}
+
+// predictCutoff returns how long merging and uploading pending files is expected to take
+func (xfagg *aggregator) predictCutoff(pending int) (time.Duration, bool) {
+	perFile, ok := xfagg.history.perFile()
+	if !ok {
+		perFile = xfagg.shard.Cutoffs.EarlyStart.DurationPerFile
+	}
+	if perFile <= 0 {
+		return 0, false
+	}
+	return perFile * time.Duration(pending), true
+}
+
+// startsEarly returns the predicted duration of the cutoff at next and true when waiting
+// another check to start it would finish after the shard's deadline.
+func (xfagg *aggregator) startsEarly(now, next time.Time, pending int) (time.Duration, bool) {
+	cfg := xfagg.shard.Cutoffs.EarlyStart
+	if cfg == nil || pending == 0 || next.Sub(now) > cfg.MaxLead {
+		return 0, false
+	}
+	predicted, ok := xfagg.predictCutoff(pending)
+	if !ok {
+		return 0, false
+	}
+	deadline := next.Add(cfg.Deadline)
+	return predicted, now.Add(cfg.Interval()).Add(predicted).After(deadline)
+}
+
+// checkEarlyStart starts the next cutoff now if it's predicted to miss the shard's deadline
+func (xfagg *aggregator) checkEarlyStart(now time.Time) {
+	next := xfagg.nextCutoff(now)
+	if next.IsZero() || xfagg.earlyStartOf(next) != nil {
+		return
+	}
+	if _, ok := xfagg.uploadAgents.Find(xfagg.shard.UploadAgent).MaintenanceUntil(now); ok {
+		return
+	}
+	mm, ok := xfagg.merger.(*filesystemMerging)
+	if !ok {
+		return
+	}
+	matches, err := mm.getNonCanceledMatches(filepath.Join("mergable", xfagg.shard.Name))
+	if err != nil {
+		xfagg.logger.Warn().LogErrorf("skipping early start check: %v", err)
+		return
+	}
+	predicted, early := xfagg.startsEarly(now, next, len(matches))
+	if !early {
+		return
+	}
+
+	xfagg.early = &earlyStart{cutoff: next}
+	earlyStartedCutoffs.With("shard", xfagg.shard.Name).Add(1)
+	xfagg.notifyEarlyStart(next, len(matches), predicted)
+
+	xfagg.cutoff(next)
+}
+
+func (xfagg *aggregator) notifyEarlyStart(when time.Time, pending int, predicted time.Duration) {
+	logger := xfagg.logger.With(log.Fields{
+		"shard": log.String(xfagg.shard.Name),
+	})
+	deadline := when.Add(xfagg.shard.Cutoffs.EarlyStart.Deadline)
+	msg := fmt.Sprintf("starting %s cutoff for shard %s early, %d pending files are predicted to take %v and the deadline is %s",
+		when.Format("15:04 MST"), xfagg.shard.Name, pending, predicted.Round(time.Second), deadline.Format("15:04 MST"))
+	logger.Info().Log(msg)
+
+	uploadAgent := xfagg.uploadAgents.Find(xfagg.shard.UploadAgent)
+	notifier, err := notify.NewMultiSender(logger, xfagg.shard.Notifications, uploadAgent.Notifications)
+	if err != nil {
+		logger.Error().LogErrorf("ERROR creating early start notifier: %v", err)
+		return
+	}
+	if err := notifier.Info(&notify.Message{Contents: msg}); err != nil {
+		logger.Error().LogErrorf("ERROR sending early start notification: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCutoffHistory(t *testing.T) {
+	var history cutoffHistory
+	_, ok := history.perFile()
+	require.False(t, ok)
+
+	history.record(0, time.Minute)
+	_, ok = history.perFile()
+	require.False(t, ok)
+
+	history.record(10, 10*time.Second)
+	history.record(30, 70*time.Second)
+	perFile, ok := history.perFile()
+	require.True(t, ok)
+	require.Equal(t, 2*time.Second, perFile)
+
+	for i := 0; i < cutoffHistorySize; i++ {
+		history.record(1, time.Second)
+	}
+	require.Len(t, history.samples, cutoffHistorySize)
+	perFile, _ = history.perFile()
+	require.Equal(t, time.Second, perFile)
+}
+
+func TestAggregator__startsEarly(t *testing.T) {
+	xfagg := &aggregator{
+		shard: service.Shard{
+			Cutoffs: service.Cutoffs{
+				EarlyStart: &service.EarlyStart{
+					Deadline: 15 * time.Minute,
+					MaxLead:  time.Hour,
+				},
+			},
+		},
+	}
+	next := time.Date(2022, time.October, 14, 17, 0, 0, 0, time.UTC)
+
+	// Nothing to predict from
+	_, early := xfagg.startsEarly(next.Add(-30*time.Minute), next, 100)
+	require.False(t, early)
+
+	xfagg.history.record(10, 5*time.Minute) // 30s per file
+
+	// 100 files take 50m, which has to start by 16:25
+	predicted, early := xfagg.startsEarly(next.Add(-36*time.Minute), next, 100)
+	require.Equal(t, 50*time.Minute, predicted)
+	require.False(t, early)
+
+	predicted, early = xfagg.startsEarly(next.Add(-35*time.Minute), next, 100)
+	require.Equal(t, 50*time.Minute, predicted)
+	require.True(t, early)
+
+	// Small backlogs finish before the deadline
+	_, early = xfagg.startsEarly(next.Add(-5*time.Minute), next, 10)
+	require.False(t, early)
+
+	// Cutoffs aren't started more than MaxLead ahead
+	_, early = xfagg.startsEarly(next.Add(-90*time.Minute), next, 1000)
+	require.False(t, early)
+
+	_, early = xfagg.startsEarly(next.Add(-30*time.Minute), next, 0)
+	require.False(t, early)
+}
+
+func TestAggregator__predictCutoffDefault(t *testing.T) {
+	xfagg := &aggregator{
+		shard: service.Shard{
+			Cutoffs: service.Cutoffs{
+				EarlyStart: &service.EarlyStart{
+					MaxLead:         time.Hour,
+					DurationPerFile: 2 * time.Second,
+				},
+			},
+		},
+	}
+	predicted, ok := xfagg.predictCutoff(30)
+	require.True(t, ok)
+	require.Equal(t, time.Minute, predicted)
+
+	// Timed cutoffs are preferred
+	xfagg.history.record(30, 30*time.Second)
+	predicted, _ = xfagg.predictCutoff(30)
+	require.Equal(t, 30*time.Second, predicted)
+}
+
+func TestEarlyStart__sequence(t *testing.T) {
+	var indexes []int
+	f := func(index int, _ upload.Agent, _ *ach.File) error {
+		indexes = append(indexes, index)
+		return nil
+	}
+
+	var none *earlyStart
+	none.sequence(f)(3, nil, nil)
+	require.Equal(t, []int{3}, indexes)
+
+	early := &earlyStart{}
+	each := early.sequence(f)
+	each(0, nil, nil)
+	each(1, nil, nil)
+	require.Equal(t, 2, early.files)
+
+	early.finished = true
+	each = early.sequence(f)
+	each(0, nil, nil)
+	require.Equal(t, []int{3, 0, 1, 2}, indexes)
+	require.Equal(t, 2, early.files)
+}
+
+func TestAggregate_EarlyStart(t *testing.T) {
+	shard := service.Shard{
+		Name: "testing",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/New_York",
+			Windows:  []string{"17:00"},
+			EarlyStart: &service.EarlyStart{
+				MaxLead: time.Hour,
+			},
+		},
+		UploadAgent:              "mock-agent",
+		OutboundFilenameTemplate: `{{ .RoutingNumber }}-{{ .Index }}.ach`,
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock-agent",
+				Mock: &service.MockAgent{},
+			},
+		},
+		DefaultAgentID: "mock-agent",
+	}
+	uploadAgents.Merging.Storage.Filesystem.Directory = t.TempDir()
+
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, &events.MockEmitter{}, shard, uploadAgents, service.ErrorAlerting{})
+	require.NoError(t, err)
+
+	accept := func(fileID, name string) {
+		t.Helper()
+		file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", name))
+		require.NoError(t, err)
+		require.NoError(t, xfagg.acceptFile(incoming.ACHFile{FileID: fileID, ShardKey: "testing", File: file}))
+	}
+	accept("debit", "ppd-debit.ach")
+	accept("micro", "two-micro-deposits.ach")
+
+	// Start the cutoff early, which uploads both files
+	when := time.Now().Add(30 * time.Minute).Truncate(time.Minute)
+	xfagg.early = &earlyStart{cutoff: when}
+	require.NoError(t, xfagg.withEachFile(when))
+	require.True(t, xfagg.early.finished)
+	require.Equal(t, 2, xfagg.early.files)
+	require.Len(t, xfagg.history.samples, 1)
+	require.Equal(t, 2, xfagg.history.samples[0].files)
+
+	// Files submitted afterwards are numbered after the early files at the window. Cutoffs
+	// isolate pending files into a directory named by the second.
+	time.Sleep(time.Second)
+	accept("later", "ppd-debit.ach")
+	require.NoError(t, xfagg.withEachFile(when))
+	require.Nil(t, xfagg.early)
+
+	agent, err := upload.New(log.NewNopLogger(), uploadAgents, "mock-agent")
+	require.NoError(t, err)
+	mock, ok := agent.(*upload.MockAgent)
+	require.True(t, ok)
+	require.NotNil(t, mock.UploadedFile)
+	require.Regexp(t, `-2\.ach$`, mock.UploadedFile.Filename)
+}
@@ -0,0 +1,64 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"time"
+
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/mask"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+)
+
+// sendAcceptedEntries sends an EntryAccepted event for each entry of a file accepted into a shard
+func (fr *FileReceiver) sendAcceptedEntries(file incoming.ACHFile) {
+	if !fr.entryEvents || fr.eventEmitter == nil || file.File == nil {
+		return
+	}
+	logger := fr.logger.With(log.Fields{
+		"fileID":   log.String(file.FileID),
+		"shardKey": log.String(file.ShardKey),
+	})
+
+	now := time.Now()
+	for _, batch := range file.File.Batches {
+		bh := batch.GetHeader()
+		for _, entry := range batch.GetEntries() {
+			err := fr.eventEmitter.Send(models.Event{
+				Event: models.EntryAccepted{
+					FileID:          file.FileID,
+					ShardKey:        file.ShardKey,
+					EntryID:         entry.ID,
+					BatchNumber:     bh.BatchNumber,
+					SECCode:         bh.StandardEntryClassCode,
+					TransactionCode: entry.TransactionCode,
+					RoutingNumber:   entry.RDFIIdentification + entry.CheckDigit,
+					AccountNumber:   mask.AccountNumber(entry.DFIAccountNumber),
+					Amount:          entry.Amount,
+					TraceNumber:     entry.TraceNumber,
+					AcceptedAt:      now,
+					Metadata:        file.Metadata,
+				},
+			})
+			if err != nil {
+				logger.Error().LogErrorf("problem sending EntryAccepted event for trace number %s: %v", entry.TraceNumber, err)
+			}
+		}
+	}
+}
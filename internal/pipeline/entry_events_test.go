@@ -0,0 +1,73 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+type entryEmitter struct {
+	events.MockEmitter
+	accepted []models.EntryAccepted
+}
+
+func (e *entryEmitter) Send(evt models.Event) error {
+	if accepted, ok := evt.Event.(models.EntryAccepted); ok {
+		e.accepted = append(e.accepted, accepted)
+	}
+	return nil
+}
+
+func TestFileReceiver__EntryAccepted(t *testing.T) {
+	emitter := &entryEmitter{}
+	fr := rejectionsFileReceiver(t, emitter)
+
+	// Disabled by default
+	require.NoError(t, fr.processACHFile(lateFile(t, "file1")))
+	require.Empty(t, emitter.accepted)
+
+	fr.entryEvents = true
+	file := lateFile(t, "file2")
+	require.NoError(t, fr.processACHFile(file))
+
+	entries := file.File.Batches[0].GetEntries()
+	require.Len(t, emitter.accepted, len(entries))
+
+	accepted := emitter.accepted[0]
+	require.Equal(t, "file2", accepted.FileID)
+	require.Equal(t, "testing", accepted.ShardKey)
+	require.Equal(t, file.File.Batches[0].GetHeader().StandardEntryClassCode, accepted.SECCode)
+	require.Equal(t, entries[0].TraceNumber, accepted.TraceNumber)
+	require.Equal(t, entries[0].Amount, accepted.Amount)
+	require.Equal(t, entries[0].RDFIIdentification+entries[0].CheckDigit, accepted.RoutingNumber)
+	require.NotEqual(t, entries[0].DFIAccountNumber, accepted.AccountNumber)
+	require.Contains(t, accepted.AccountNumber, "*")
+	require.False(t, accepted.AcceptedAt.IsZero())
+
+	// Rejected files don't have entries accepted
+	emitter.accepted = nil
+	invalid := lateFile(t, "file3")
+	invalid.File.Batches[0].GetEntries()[0].DFIAccountNumber = ""
+	require.NoError(t, fr.processACHFile(invalid))
+	require.Empty(t, emitter.accepted)
+}
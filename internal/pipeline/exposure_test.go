@@ -0,0 +1,76 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/exposure"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/transform"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregate_Exposure(t *testing.T) {
+	shard := service.Shard{
+		Name: "test",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/Los_Angeles",
+			Windows:  []string{"10:30"},
+		},
+		UploadAgent: "mock-agent",
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock-agent",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, &events.MockEmitter{}, shard, uploadAgents, service.ErrorAlerting{})
+	require.NoError(t, err)
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	xfagg.exposure = exposure.NewRepository(db.DB)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "two-micro-deposits.ach"))
+	require.NoError(t, err)
+
+	agent := &upload.MockAgent{}
+	require.NoError(t, xfagg.uploadFile("2022-06-01 10:30 PDT", 0, agent, &transform.Result{File: file}, nil))
+	require.NotNil(t, agent.UploadedFile)
+
+	today := time.Now().Format("2006-01-02")
+	positions, err := xfagg.exposure.Positions(today, today, "", nil)
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	require.Equal(t, "12104288", positions[0].ODFI)
+	require.Equal(t, 120, positions[0].UploadedCredits)
+	require.Equal(t, 120, positions[0].UploadedDebits)
+
+}
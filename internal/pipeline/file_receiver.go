@@ -20,10 +20,18 @@ package pipeline
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
+	"sync"
 
+	"github.com/moov-io/achgateway/internal/approvals"
+	"github.com/moov-io/achgateway/internal/drain"
+	"github.com/moov-io/achgateway/internal/events"
 	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/rejections"
+	"github.com/moov-io/achgateway/internal/screening"
 	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/achgateway/internal/submissions"
 	"github.com/moov-io/achgateway/pkg/compliance"
 	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base/admin"
@@ -45,6 +53,25 @@ type FileReceiver struct {
 	streamFiles *pubsub.Subscription
 
 	transformConfig *models.TransformConfig
+
+	approvals    *approvals.Service
+	eventEmitter events.Emitter
+
+	// entryEvents sends an EntryAccepted event for each entry of accepted files
+	entryEvents bool
+
+	// submissions records the metadata of accepted files
+	submissions submissions.Repository
+
+	// rejections keeps rejected ACH files for review
+	rejections rejections.Repository
+
+	// drain stops consuming streamFiles once the instance is draining. Files already accepted
+	// over HTTP are still read from httpFiles since they only exist in memory.
+	drain *drain.Coordinator
+
+	// freeze is held by snapshots to pause accepting files and starting cutoffs
+	freeze sync.RWMutex
 }
 
 func newFileReceiver(
@@ -55,8 +82,10 @@ func newFileReceiver(
 	httpFiles *pubsub.Subscription,
 	streamFiles *pubsub.Subscription,
 	transformConfig *models.TransformConfig,
+	approvals *approvals.Service,
+	eventEmitter events.Emitter,
 ) *FileReceiver {
-	return &FileReceiver{
+	fr := &FileReceiver{
 		logger:           logger,
 		defaultShardName: defaultShardName,
 		shardRepository:  shardRepository,
@@ -64,7 +93,15 @@ func newFileReceiver(
 		httpFiles:        httpFiles,
 		streamFiles:      streamFiles,
 		transformConfig:  transformConfig,
+		approvals:        approvals,
+		eventEmitter:     eventEmitter,
+	}
+	for _, agg := range shardAggregators {
+		if agg != nil {
+			agg.freeze = &fr.freeze
+		}
 	}
+	return fr
 }
 
 func (fr *FileReceiver) Start(ctx context.Context) {
@@ -72,6 +109,13 @@ func (fr *FileReceiver) Start(ctx context.Context) {
 		// Create a context that will be shutdown by its parent or after a read iteration
 		innerCtx, cancelFunc := context.WithCancel(ctx)
 
+		streamFiles, draining := fr.streamFiles, fr.drain.Draining()
+		select {
+		case <-draining:
+			streamFiles, draining = nil, nil
+		default:
+		}
+
 		select {
 		case err := <-fr.handleMessage(innerCtx, fr.httpFiles):
 			incomingHTTPFiles.With().Add(1)
@@ -80,13 +124,16 @@ func (fr *FileReceiver) Start(ctx context.Context) {
 				fr.logger.LogErrorf("error handling http file: %v", err)
 			}
 
-		case err := <-fr.handleMessage(innerCtx, fr.streamFiles):
+		case err := <-fr.handleMessage(innerCtx, streamFiles):
 			incomingStreamFiles.With().Add(1)
 			if err != nil {
 				streamFileProcessingErrors.With().Add(1)
 				fr.logger.LogErrorf("error handling stream file: %v", err)
 			}
 
+		// stop receiving from streamFiles
+		case <-draining:
+
 		case <-ctx.Done():
 			cancelFunc()
 			fr.Shutdown()
@@ -115,15 +162,35 @@ func (fr *FileReceiver) Shutdown() {
 			fr.logger.LogErrorf("problem shutting down stream file subscription: %v", err)
 		}
 	}
+	if err := fr.approvals.Close(); err != nil {
+		fr.logger.LogErrorf("problem closing approvals: %v", err)
+	}
 }
 
 func (fr *FileReceiver) RegisterAdminRoutes(r *admin.Server) {
 	r.AddHandler("/trigger-cutoff", fr.triggerManualCutoff())
 
 	r.AddHandler("/shards", fr.listShards())
+	r.AddHandler("/recovery", fr.listRecoveryStatus())
+
+	r.AddHandler("/approvals", fr.listApprovals())
+	r.Subrouter("/approvals").HandleFunc("/{approvalID}", fr.approveAction())
+
+	r.AddHandler("/rejections", fr.listRejections())
+	rejected := r.Subrouter("/rejections")
+	rejected.HandleFunc("/{rejectionID}", fr.getRejection())
+	rejected.HandleFunc("/{rejectionID}/resubmit", fr.resubmitRejection())
+	rejected.HandleFunc("/{rejectionID}/discard", fr.discardRejection())
 
 	sub := r.Subrouter("/shards/{shardName}")
 	sub.HandleFunc("/files", fr.listShardFiles())
+	sub.HandleFunc("/reencrypt", fr.reencryptShardFiles())
+	sub.HandleFunc("/uploads", fr.listShardUploads())
+	sub.HandleFunc("/uploads/{sha256}", fr.clearShardUpload())
+	sub.HandleFunc("/lineage", fr.getShardLineage())
+	sub.HandleFunc("/held", fr.listHeldFiles())
+	sub.HandleFunc("/held/{name}", fr.getHeldFile())
+	sub.HandleFunc("/held/{name}/discard", fr.discardHeldFile())
 	sub.PathPrefix("/files/{filepath}").Handler(fr.getShardFile())
 }
 
@@ -159,6 +226,9 @@ func (fr *FileReceiver) handleMessage(ctx context.Context, sub *pubsub.Subscript
 		select {
 		case msg := <-receiver:
 			if msg != nil {
+				done := fr.drain.Track()
+				defer done()
+
 				out <- fr.processMessage(msg)
 				return
 			} else {
@@ -175,6 +245,9 @@ func (fr *FileReceiver) handleMessage(ctx context.Context, sub *pubsub.Subscript
 }
 
 func (fr *FileReceiver) processMessage(msg *pubsub.Message) error {
+	fr.freeze.RLock()
+	defer fr.freeze.RUnlock()
+
 	data := msg.Body
 	var err error
 
@@ -209,6 +282,14 @@ func (fr *FileReceiver) processMessage(msg *pubsub.Message) error {
 		msg.Ack()
 		return nil
 
+	case *models.QueueCPA005File:
+		err = fr.processCPA005File(incoming.CPA005File(*evt))
+		if err != nil {
+			return err
+		}
+		msg.Ack()
+		return nil
+
 	case *models.CancelACHFile:
 		err = fr.cancelACHFile(evt)
 		if err != nil {
@@ -222,11 +303,10 @@ func (fr *FileReceiver) processMessage(msg *pubsub.Message) error {
 	return nil
 }
 
-func (fr *FileReceiver) getAggregator(shardKey string) *aggregator {
+func (fr *FileReceiver) getAggregator(shardKey string) (*aggregator, error) {
 	shardName, err := fr.shardRepository.Lookup(shardKey)
 	if err != nil {
-		fr.logger.Error().LogErrorf("problem looking up shardKey=%s: %v", shardKey, err)
-		return nil
+		return nil, fmt.Errorf("problem looking up shardKey=%s: %v", shardKey, err)
 	}
 
 	agg, exists := fr.shardAggregators[shardName]
@@ -234,30 +314,31 @@ func (fr *FileReceiver) getAggregator(shardKey string) *aggregator {
 		agg, exists = fr.shardAggregators[fr.defaultShardName]
 		if !exists {
 			filesMissingShardAggregators.With("shard", shardName).Add(1)
-			fr.logger.Error().LogErrorf("missing shardAggregator for shardKey=%s shardName=%s", shardKey, shardName)
-			return nil
+			return nil, fmt.Errorf("missing shardAggregator for shardKey=%s shardName=%s", shardKey, shardName)
 		}
 	}
 	if agg == nil {
-		fr.logger.Error().LogErrorf("nil shardAggregator for shardKey=%s shardName=%s", shardKey, shardName)
-		return nil
+		return nil, fmt.Errorf("nil shardAggregator for shardKey=%s shardName=%s", shardKey, shardName)
 	}
-	return agg
+	return agg, nil
 }
 
 func (fr *FileReceiver) processACHFile(file incoming.ACHFile) error {
-	if file.FileID == "" || file.ShardKey == "" {
-		return errors.New("missing fileID or shardKey")
+	if err := file.Validate(); err != nil {
+		fr.logger.Error().LogErrorf("invalid ACHFile: %v", err)
+		fr.reject(file.FileID, file.ShardKey, file.Metadata, file.File, nil, rejectionReason(models.RejectionMissingField, err))
+		return nil
 	}
-
-	err := file.Validate()
-	if err != nil {
+	if err := submissions.Validate(file.Metadata); err != nil {
 		fr.logger.Error().LogErrorf("invalid ACHFile: %v", err)
+		fr.reject(file.FileID, file.ShardKey, file.Metadata, file.File, nil, rejectionReason(models.RejectionMetadata, err))
 		return nil
 	}
 
-	agg := fr.getAggregator(file.ShardKey)
-	if agg == nil {
+	agg, err := fr.getAggregator(file.ShardKey)
+	if err != nil {
+		fr.logger.Error().LogError(err)
+		fr.reject(file.FileID, file.ShardKey, file.Metadata, file.File, nil, rejectionReason(models.RejectionUnknownShard, err))
 		return nil
 	}
 
@@ -268,25 +349,101 @@ func (fr *FileReceiver) processACHFile(file incoming.ACHFile) error {
 	})
 	logger.Log("begin handling of received ACH file")
 
+	if err := file.File.Validate(); err != nil {
+		logger.Error().LogErrorf("rejected invalid file under shardName=%s: %v", agg.shard.Name, err)
+		fr.reject(file.FileID, file.ShardKey, file.Metadata, file.File, agg, validationReasons(file.File, err)...)
+		return nil
+	}
+	if reasons := agg.addendaReasons(file.File); len(reasons) > 0 {
+		logger.Error().Logf("rejected file under shardName=%s with %d invalid addenda", agg.shard.Name, len(reasons))
+		fr.reject(file.FileID, file.ShardKey, file.Metadata, file.File, agg, reasons...)
+		return nil
+	}
+	if fr.handleLateSubmission(agg, file.FileID, file.ShardKey, file.Metadata, file.File) {
+		return nil
+	}
+	if err := fr.recordSubmission(agg, file.FileID, file.ShardKey, file.Metadata, traceNumbers(file.File)); err != nil {
+		return logger.Error().LogErrorf("problem recording metadata: %v", err).Err()
+	}
+
 	err = agg.acceptFile(file)
+	if errors.Is(err, screening.ErrBlocked) || errors.Is(err, errFileFormat) {
+		// Blocked files are never merged, so don't retry them.
+		logger.Error().LogErrorf("rejected file under shardName=%s: %v", agg.shard.Name, err)
+		code := models.RejectionFileFormat
+		if errors.Is(err, screening.ErrBlocked) {
+			code = models.RejectionBlocked
+		}
+		fr.reject(file.FileID, file.ShardKey, file.Metadata, file.File, agg, rejectionReason(code, err))
+		return nil
+	}
 	if err != nil {
 		return logger.Error().LogErrorf("problem accepting file under shardName=%s", agg.shard.Name).Err()
 	}
 
 	// Record the file as accepted
 	pendingFiles.With("shard", agg.shard.Name).Add(1)
+	fr.sendAcceptedEntries(file)
 	logger.Log("finished handling ACH file")
 
 	return nil
 }
 
+func (fr *FileReceiver) processCPA005File(file incoming.CPA005File) error {
+	if err := file.Validate(); err != nil {
+		return err
+	}
+	if err := submissions.Validate(file.Metadata); err != nil {
+		fr.logger.Error().LogErrorf("invalid CPA005File: %v", err)
+		fr.reject(file.FileID, file.ShardKey, file.Metadata, nil, nil, rejectionReason(models.RejectionMetadata, err))
+		return nil
+	}
+
+	agg, err := fr.getAggregator(file.ShardKey)
+	if err != nil {
+		fr.logger.Error().LogError(err)
+		fr.reject(file.FileID, file.ShardKey, file.Metadata, nil, nil, rejectionReason(models.RejectionUnknownShard, err))
+		return nil
+	}
+
+	logger := fr.logger.With(log.Fields{
+		"fileID":    log.String(file.FileID),
+		"shardName": log.String(agg.shard.Name),
+		"shardKey":  log.String(file.ShardKey),
+	})
+	logger.Log("begin handling of received CPA-005 file")
+
+	if fr.handleLateSubmission(agg, file.FileID, file.ShardKey, file.Metadata, nil) {
+		return nil
+	}
+	if err := fr.recordSubmission(agg, file.FileID, file.ShardKey, file.Metadata, nil); err != nil {
+		return logger.Error().LogErrorf("problem recording metadata: %v", err).Err()
+	}
+
+	err = agg.acceptCPA005File(file)
+	if errors.Is(err, errFileFormat) {
+		logger.Error().LogErrorf("rejected file under shardName=%s: %v", agg.shard.Name, err)
+		fr.reject(file.FileID, file.ShardKey, file.Metadata, nil, agg, rejectionReason(models.RejectionFileFormat, err))
+		return nil
+	}
+	if err != nil {
+		return logger.Error().LogErrorf("problem accepting CPA-005 file under shardName=%s: %v", agg.shard.Name, err).Err()
+	}
+
+	pendingFiles.With("shard", agg.shard.Name).Add(1)
+	logger.Log("finished handling CPA-005 file")
+
+	return nil
+}
+
 func (fr *FileReceiver) cancelACHFile(cancel *models.CancelACHFile) error {
 	if cancel == nil || cancel.FileID == "" || cancel.ShardKey == "" {
 		return errors.New("missing fileID or shardKey")
 	}
 
-	agg := fr.getAggregator(cancel.ShardKey)
-	if agg == nil {
+	agg, err := fr.getAggregator(cancel.ShardKey)
+	if err != nil {
+		fr.logger.Error().LogError(err)
 		return nil
 	}
 
@@ -299,7 +456,7 @@ func (fr *FileReceiver) cancelACHFile(cancel *models.CancelACHFile) error {
 
 	evt := incoming.CancelACHFile(*cancel)
 
-	err := agg.cancelFile(evt)
+	err = agg.cancelFile(evt)
 	if err != nil {
 		return logger.Error().LogErrorf("problem canceling file: %v", err).Err()
 	}
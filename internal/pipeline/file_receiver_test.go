@@ -40,7 +40,7 @@ func testFileReceiver(t *testing.T) *FileReceiver {
 	_, streamFiles := streamtest.InmemStream(t)
 	cfg := &models.TransformConfig{}
 
-	fileRec := newFileReceiver(logger, shard, shardRepo, shardAggregators, httpFiles, streamFiles, cfg)
+	fileRec := newFileReceiver(logger, shard, shardRepo, shardAggregators, httpFiles, streamFiles, cfg, nil, nil)
 	go fileRec.Start(context.Background())
 	t.Cleanup(func() { fileRec.Shutdown() })
 
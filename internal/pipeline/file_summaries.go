@@ -0,0 +1,131 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"time"
+
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/pkg/models"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/base"
+)
+
+// fileSummary totals the entries of a submitted file for its FileUploaded event
+type fileSummary struct {
+	// firstTrace is the trace number of the file's first entry as it was merged, which is
+	// used to find the uploaded file holding the entries.
+	firstTrace string
+
+	entryCount int
+	batches    []models.BatchTotals
+}
+
+func summarizeFile(file *ach.File, firstTrace string) fileSummary {
+	summary := fileSummary{firstTrace: firstTrace}
+	summary.entryCount, summary.batches = models.TotalBatches(file)
+	return summary
+}
+
+// firstTraceNumber returns the trace number of file's first entry, or an empty string without entries
+func firstTraceNumber(file *ach.File) string {
+	if file == nil {
+		return ""
+	}
+	for _, batch := range file.Batches {
+		if entries := batch.GetEntries(); len(entries) > 0 {
+			return entries[0].TraceNumber
+		}
+	}
+	for _, batch := range file.IATBatches {
+		if len(batch.Entries) > 0 {
+			return batch.Entries[0].TraceNumber
+		}
+	}
+	return ""
+}
+
+// uploadedFile is a merged file written to the ODFI
+type uploadedFile struct {
+	filename   string
+	remotePath string
+	size       int64
+	sha256     string
+	duration   time.Duration
+
+	// collision is set when the file was renamed or overwrote a remote file
+	collision *upload.Collision
+
+	traceNumbers map[string]bool
+}
+
+// cutoffUploads collects the files uploaded by a cutoff so each submitted file's FileUploaded
+// event can describe the file holding its entries. A nil cutoffUploads discards them.
+type cutoffUploads struct {
+	files []uploadedFile
+
+	// manifestErrors are manifests which failed to upload after their file was uploaded
+	manifestErrors base.ErrorList
+}
+
+func (u *cutoffUploads) manifestFailed(err error) {
+	if u == nil || err == nil {
+		return
+	}
+	u.manifestErrors.Add(err)
+}
+
+// manifestError returns the manifests which weren't uploaded, if any
+func (u *cutoffUploads) manifestError() error {
+	if u == nil || u.manifestErrors.Empty() {
+		return nil
+	}
+	return u.manifestErrors
+}
+
+func (u *cutoffUploads) add(file *ach.File, uploaded uploadedFile) {
+	if u == nil || file == nil {
+		return
+	}
+	uploaded.traceNumbers = make(map[string]bool)
+	for _, batch := range file.Batches {
+		for _, entry := range batch.GetEntries() {
+			uploaded.traceNumbers[entry.TraceNumber] = true
+		}
+	}
+	for _, batch := range file.IATBatches {
+		for _, entry := range batch.Entries {
+			uploaded.traceNumbers[entry.TraceNumber] = true
+		}
+	}
+	u.files = append(u.files, uploaded)
+}
+
+// find returns the uploaded file with an entry of traceNumber
+func (u *cutoffUploads) find(traceNumber string) *uploadedFile {
+	if u == nil || traceNumber == "" {
+		return nil
+	}
+	for i := range u.files {
+		if u.files[i].traceNumbers[traceNumber] {
+			return &u.files[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/transform"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+
+	"github.com/moov-io/ach"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeFile(t *testing.T) {
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "two-micro-deposits.ach"))
+	require.NoError(t, err)
+
+	summary := summarizeFile(file, "121042880000001")
+	require.Equal(t, "121042880000001", summary.firstTrace)
+	require.Equal(t, 6, summary.entryCount)
+	require.Len(t, summary.batches, 2)
+
+	var debits, credits int
+	for i := range summary.batches {
+		require.Equal(t, i+1, summary.batches[i].BatchNumber)
+		require.Equal(t, ach.PPD, summary.batches[i].SECCode)
+		require.Equal(t, 3, summary.batches[i].EntryCount)
+		debits += summary.batches[i].DebitTotal
+		credits += summary.batches[i].CreditTotal
+	}
+	require.Equal(t, file.Control.TotalDebitEntryDollarAmountInFile, debits)
+	require.Equal(t, file.Control.TotalCreditEntryDollarAmountInFile, credits)
+}
+
+func TestCutoffUploads(t *testing.T) {
+	var uploads *cutoffUploads
+	uploads.add(&ach.File{}, uploadedFile{})
+	require.Nil(t, uploads.find("121042880000001"))
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	uploads = &cutoffUploads{}
+	uploads.add(file, uploadedFile{filename: "uploaded.ach"})
+	require.Nil(t, uploads.find(""))
+	require.Nil(t, uploads.find("000000000000000"))
+
+	found := uploads.find(firstTraceNumber(file))
+	require.NotNil(t, found)
+	require.Equal(t, "uploaded.ach", found.filename)
+}
+
+func TestAggregate_FileUploadedDetails(t *testing.T) {
+	shard := service.Shard{
+		Name: "testing",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/New_York",
+			Windows:  []string{"17:00"},
+		},
+		UploadAgent: "mock",
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+				Paths: service.UploadPaths{
+					Outbound: "outbound/{{ routingNumber }}",
+				},
+			},
+		},
+		DefaultAgentID: "mock",
+	}
+	uploadAgents.Merging.Storage.Filesystem.Directory = t.TempDir()
+
+	emitter := &submissionEmitter{}
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, emitter, shard, uploadAgents, service.ErrorAlerting{})
+	require.NoError(t, err)
+
+	// Files for different ODFIs are merged and uploaded separately
+	for fileID, name := range map[string]string{"debit": "ppd-debit.ach", "micro": "two-micro-deposits.ach"} {
+		file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", name))
+		require.NoError(t, err)
+		require.NoError(t, xfagg.acceptFile(incoming.ACHFile{FileID: fileID, ShardKey: "testing", File: file}))
+	}
+
+	when := time.Date(2022, time.October, 14, 17, 0, 0, 0, time.UTC)
+	processed, err := xfagg.mergeAndUpload(when.Format("2006-01-02 15:04 MST"), false, nil)
+	require.NoError(t, err)
+	require.NoError(t, xfagg.emitFilesUploaded(processed))
+
+	require.Len(t, emitter.events, 2)
+	uploaded := make(map[string]models.FileUploaded)
+	for i := range emitter.events {
+		evt, ok := emitter.events[i].Event.(models.FileUploaded)
+		require.True(t, ok)
+		uploaded[evt.FileID] = evt
+	}
+
+	debit := uploaded["debit"]
+	require.Equal(t, "2022-10-14 17:00 UTC", debit.CutoffWindow)
+	require.Equal(t, 1, debit.EntryCount)
+	require.Len(t, debit.Batches, 1)
+	require.Equal(t, ach.PPD, debit.Batches[0].SECCode)
+	require.Equal(t, 1, debit.Batches[0].EntryCount)
+	require.Greater(t, debit.Batches[0].DebitTotal, 0)
+	require.True(t, strings.HasPrefix(debit.RemotePath, "outbound/076401251/"), debit.RemotePath)
+	require.Equal(t, filepath.Base(debit.RemotePath), debit.Filename)
+	require.Greater(t, debit.Size, int64(0))
+	require.Len(t, debit.SHA256, 64)
+
+	micro := uploaded["micro"]
+	require.Equal(t, 6, micro.EntryCount)
+	require.True(t, strings.HasPrefix(micro.RemotePath, "outbound/121042882/"), micro.RemotePath)
+	require.NotEqual(t, debit.SHA256, micro.SHA256)
+	require.Equal(t, debit.MergeDurationMs, micro.MergeDurationMs)
+}
+
+func TestAggregate_FilenameCollision(t *testing.T) {
+	shard := service.Shard{
+		Name: "testing",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/New_York",
+			Windows:  []string{"17:00"},
+		},
+		UploadAgent:              "mock",
+		OutboundFilenameTemplate: "FILE.ach",
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+				Paths: service.UploadPaths{
+					Outbound: "outbound",
+				},
+				OnCollision: service.CollisionSuffix,
+			},
+		},
+		DefaultAgentID: "mock",
+	}
+	uploadAgents.Merging.Storage.Filesystem.Directory = t.TempDir()
+
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, &submissionEmitter{}, shard, uploadAgents, service.ErrorAlerting{})
+	require.NoError(t, err)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	agent := &upload.MockAgent{
+		ExistingFiles: map[string]bool{"outbound/FILE.ach": true},
+	}
+	uploads := &cutoffUploads{}
+	require.NoError(t, xfagg.uploadFile("manual", 0, agent, &transform.Result{File: file}, uploads))
+
+	require.Equal(t, "FILE-1.ach", agent.UploadedFile.Filename)
+	uploaded := uploads.find(firstTraceNumber(file))
+	require.NotNil(t, uploaded)
+	require.Equal(t, "FILE-1.ach", uploaded.filename)
+	require.Equal(t, "outbound/FILE-1.ach", uploaded.remotePath)
+	require.Equal(t, &upload.Collision{Policy: service.CollisionSuffix, Filename: "FILE.ach"}, uploaded.collision)
+
+	// Uploads fail instead when the policy is error
+	xfagg.uploadAgents.Agents[0].OnCollision = service.CollisionError
+	agent.UploadedFile = nil
+	err = xfagg.uploadFile("manual", 0, agent, &transform.Result{File: file}, &cutoffUploads{})
+	require.ErrorContains(t, err, "outbound/FILE.ach already exists")
+	require.Nil(t, agent.UploadedFile)
+}
@@ -0,0 +1,155 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"sync"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/schedule"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+)
+
+// cutoffProgress records when the cutoff an aggregator is running started merging
+type cutoffProgress struct {
+	mu        sync.Mutex
+	startedAt time.Time
+}
+
+// begin marks a cutoff as merging and returns a func to call once it's finished
+func (p *cutoffProgress) begin() func() {
+	p.mu.Lock()
+	p.startedAt = time.Now()
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		p.startedAt = time.Time{}
+		p.mu.Unlock()
+	}
+}
+
+// merging returns when the current cutoff started, or false when no cutoff is merging
+func (p *cutoffProgress) merging() (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.startedAt, !p.startedAt.IsZero()
+}
+
+// waitForGracePeriod delays merging the files for the window at when until the shard's grace period is over
+func (xfagg *aggregator) waitForGracePeriod(when time.Time) {
+	grace := xfagg.shard.Cutoffs.LateSubmissions.Grace()
+	if grace <= 0 {
+		return
+	}
+	if wait := time.Until(when.Add(grace)); wait > 0 {
+		xfagg.logger.Info().With(log.Fields{
+			"shard": log.String(xfagg.shard.Name),
+		}).Logf("waiting %v grace period for late submissions", wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+}
+
+// lateSubmission returns an error for files submitted while the shard's cutoff is merging when
+// the shard has a late submission policy. Shards without a policy return nil.
+func (xfagg *aggregator) lateSubmission() *incoming.LateSubmissionError {
+	if xfagg.shard.Cutoffs.LateSubmissions == nil || xfagg.shard.Cutoffs.LateSubmissions.Policy == "" {
+		return nil
+	}
+	startedAt, merging := xfagg.progress.merging()
+	if !merging {
+		return nil
+	}
+	return &incoming.LateSubmissionError{
+		ShardName:       xfagg.shard.Name,
+		CutoffStartedAt: startedAt,
+		NextCutoff:      xfagg.nextCutoff(startedAt),
+	}
+}
+
+// nextCutoff returns the first banking day cutoff window after the one started at startedAt
+func (xfagg *aggregator) nextCutoff(startedAt time.Time) time.Time {
+	days, err := schedule.Upcoming(xfagg.shard.Cutoffs.Timezone, xfagg.shard.Cutoffs.Windows, startedAt.Add(time.Minute), 7)
+	if err != nil {
+		return time.Time{}
+	}
+	for _, day := range days {
+		if day.IsBankingDay {
+			return day.Time
+		}
+	}
+	return time.Time{}
+}
+
+// CheckSubmission returns an *incoming.LateSubmissionError when a file submitted for shardKey
+// would be rejected as late. Other problems are left for when the file is processed.
+func (fr *FileReceiver) CheckSubmission(shardKey string) error {
+	agg, err := fr.getAggregator(shardKey)
+	if err != nil {
+		return nil
+	}
+	if late := agg.lateSubmission(); late != nil && agg.shard.Cutoffs.LateSubmissions.Policy == service.LateSubmissionsReject {
+		return late
+	}
+	return nil
+}
+
+// handleLateSubmission applies the shard's late submission policy to file, returning true if
+// the file was rejected. file is nil for CPA-005 files.
+func (fr *FileReceiver) handleLateSubmission(agg *aggregator, fileID, shardKey string, metadata map[string]string, file *ach.File) bool {
+	late := agg.lateSubmission()
+	if late == nil {
+		return false
+	}
+	logger := fr.logger.With(log.Fields{
+		"fileID":    log.String(fileID),
+		"shardName": log.String(agg.shard.Name),
+		"shardKey":  log.String(shardKey),
+	})
+	lateSubmissions.With("shard", agg.shard.Name, "policy", agg.shard.Cutoffs.LateSubmissions.Policy).Add(1)
+
+	switch agg.shard.Cutoffs.LateSubmissions.Policy {
+	case service.LateSubmissionsReject:
+		logger.Warn().Logf("rejecting file: %v", late)
+		fr.reject(fileID, shardKey, metadata, file, agg, rejectionReason(models.RejectionLateSubmission, late))
+		return true
+
+	case service.LateSubmissionsNextWindow:
+		logger.Info().Logf("file arrived after cutoff started at %s, rolling over to the next cutoff", late.CutoffStartedAt.Format(time.RFC3339))
+		if fr.eventEmitter == nil {
+			return false
+		}
+		evt := models.FileRolledOver{
+			FileID:          fileID,
+			ShardKey:        shardKey,
+			CutoffStartedAt: late.CutoffStartedAt,
+			Metadata:        metadata,
+		}
+		if !late.NextCutoff.IsZero() {
+			evt.NextCutoff = &late.NextCutoff
+		}
+		if err := fr.eventEmitter.Send(models.Event{Event: evt}); err != nil {
+			logger.Error().LogErrorf("problem sending FileRolledOver event: %v", err)
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+type lateEmitter struct {
+	events.MockEmitter
+	rejected   []models.FileRejected
+	rolledOver []models.FileRolledOver
+}
+
+func (e *lateEmitter) Send(evt models.Event) error {
+	switch v := evt.Event.(type) {
+	case models.FileRejected:
+		e.rejected = append(e.rejected, v)
+	case models.FileRolledOver:
+		e.rolledOver = append(e.rolledOver, v)
+	}
+	return nil
+}
+
+func lateFile(t *testing.T, fileID string) incoming.ACHFile {
+	t.Helper()
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	return incoming.ACHFile{
+		FileID:   fileID,
+		ShardKey: "testing",
+		File:     file,
+	}
+}
+
+func TestLateSubmissions__Reject(t *testing.T) {
+	emitter := &lateEmitter{}
+	fr := rejectionsFileReceiver(t, emitter)
+	agg := fr.shardAggregators["testing"]
+	agg.shard.Cutoffs.LateSubmissions = &service.LateSubmissions{Policy: service.LateSubmissionsReject}
+
+	// Nothing is late before a cutoff starts
+	require.NoError(t, fr.CheckSubmission("testing"))
+
+	done := agg.progress.begin()
+
+	err := fr.CheckSubmission("testing")
+	var late *incoming.LateSubmissionError
+	require.True(t, errors.As(err, &late))
+	require.Equal(t, "testing", late.ShardName)
+	require.False(t, late.NextCutoff.IsZero())
+
+	require.NoError(t, fr.processACHFile(lateFile(t, "file1")))
+	require.Len(t, emitter.rejected, 1)
+	require.Equal(t, "file1", emitter.rejected[0].FileID)
+	require.Equal(t, models.RejectionLateSubmission, emitter.rejected[0].Reasons[0].Code)
+
+	done()
+	require.NoError(t, fr.CheckSubmission("testing"))
+}
+
+func TestLateSubmissions__NextWindow(t *testing.T) {
+	emitter := &lateEmitter{}
+	fr := rejectionsFileReceiver(t, emitter)
+	agg := fr.shardAggregators["testing"]
+	agg.shard.Cutoffs.LateSubmissions = &service.LateSubmissions{Policy: service.LateSubmissionsNextWindow}
+
+	done := agg.progress.begin()
+	defer done()
+
+	// Files are accepted for the next window so the HTTP check allows them
+	require.NoError(t, fr.CheckSubmission("testing"))
+
+	err := fr.processACHFile(lateFile(t, "file1"))
+	require.NoError(t, err)
+	require.Empty(t, emitter.rejected)
+
+	require.Len(t, emitter.rolledOver, 1)
+	rolled := emitter.rolledOver[0]
+	require.Equal(t, "file1", rolled.FileID)
+	require.Equal(t, "testing", rolled.ShardKey)
+	require.NotNil(t, rolled.NextCutoff)
+	require.True(t, rolled.NextCutoff.After(rolled.CutoffStartedAt))
+}
+
+func TestLateSubmissions__NoPolicy(t *testing.T) {
+	emitter := &lateEmitter{}
+	fr := rejectionsFileReceiver(t, emitter)
+	agg := fr.shardAggregators["testing"]
+
+	done := agg.progress.begin()
+	defer done()
+
+	require.Nil(t, agg.lateSubmission())
+	require.NoError(t, fr.processACHFile(lateFile(t, "file1")))
+	require.Empty(t, emitter.rejected)
+	require.Empty(t, emitter.rolledOver)
+}
+
+func TestLateSubmissions__GracePeriod(t *testing.T) {
+	fr := rejectionsFileReceiver(t, nil)
+	agg := fr.shardAggregators["testing"]
+	agg.shard.Cutoffs.LateSubmissions = &service.LateSubmissions{GracePeriod: 50 * time.Millisecond}
+
+	start := time.Now()
+	agg.waitForGracePeriod(start)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// Windows which started long ago don't wait
+	start = time.Now()
+	agg.waitForGracePeriod(start.Add(-time.Minute))
+	require.Less(t, time.Since(start), 50*time.Millisecond)
+}
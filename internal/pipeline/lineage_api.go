@@ -0,0 +1,76 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/moov-io/achgateway/internal/lineage"
+	"github.com/moov-io/base/log"
+)
+
+type lineageResponse struct {
+	TraceNumbers []lineage.TraceNumber `json:"traceNumbers"`
+	File         *lineage.File         `json:"file,omitempty"`
+}
+
+// getShardLineage looks up the trace numbers assigned to a submitted file (?fileID=), the
+// submitted file an assigned trace number came from (?traceNumber=) or the FileIDModifier
+// assigned to a merged file (?sha256=).
+func (fr *FileReceiver) getShardLineage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := fr.logger.With(log.Fields{
+			"route": log.String("get_lineage"),
+		})
+
+		agg := fr.lookupAggregator(logger, r)
+		if agg == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if agg.lineage == nil {
+			logger.Warn().Log("lineage requires a database")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var resp lineageResponse
+		var err error
+		query := r.URL.Query()
+		switch {
+		case query.Get("fileID") != "":
+			resp.TraceNumbers, err = agg.lineage.ListTraceNumbers(agg.shard.Name, query.Get("fileID"))
+		case query.Get("traceNumber") != "":
+			resp.TraceNumbers, err = agg.lineage.FindTraceNumber(agg.shard.Name, query.Get("traceNumber"))
+		case query.Get("sha256") != "":
+			resp.File, err = agg.lineage.FindFile(agg.shard.Name, query.Get("sha256"))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			logger.Error().LogErrorf("problem reading %s lineage: %v", agg.shard.Name, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
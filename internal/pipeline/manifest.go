@@ -0,0 +1,63 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/moov-io/achgateway/internal/gpgx"
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// manifester creates the companion file uploaded after each outbound file
+type manifester struct {
+	cfg        *service.ManifestConfig
+	signingKey openpgp.EntityList
+}
+
+func newManifester(cfg *service.ManifestConfig) (*manifester, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	m := &manifester{cfg: cfg}
+	if cfg.Format == service.ManifestPGP {
+		if cfg.Signer == nil {
+			return nil, errors.New("manifest: missing signer")
+		}
+		key, err := gpgx.ReadPrivateKeyFile(cfg.Signer.KeyFile, []byte(cfg.Signer.Password()))
+		if err != nil {
+			return nil, fmt.Errorf("manifest: reading signing key: %v", err)
+		}
+		m.signingKey = key
+	}
+	return m, nil
+}
+
+// create returns the manifest of contents, which must be exactly what's uploaded as filename
+func (m *manifester) create(filename string, contents []byte) ([]byte, error) {
+	switch m.cfg.Format {
+	case service.ManifestSHA256:
+		return []byte(fmt.Sprintf("%s  %s\n", hash(contents), filename)), nil
+	case service.ManifestPGP:
+		return gpgx.Sign(contents, m.signingKey)
+	}
+	return nil, fmt.Errorf("unknown manifest format %q", m.cfg.Format)
+}
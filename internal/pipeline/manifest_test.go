@@ -0,0 +1,197 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/drain"
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/transform"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base/log"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest__SHA256(t *testing.T) {
+	m, err := newManifester(&service.ManifestConfig{Format: service.ManifestSHA256})
+	require.NoError(t, err)
+
+	bs, err := m.create("20220601-1030-121042882.ach", []byte("hello, world\n"))
+	require.NoError(t, err)
+	require.Equal(t, "853ff93762a06ddbf722c4ebe9ddd66d8f63ddaea97f521c3ecc20da7c976020  20220601-1030-121042882.ach\n", string(bs))
+
+	none, err := newManifester(nil)
+	require.NoError(t, err)
+	require.Nil(t, none)
+}
+
+func TestManifest__PGP(t *testing.T) {
+	entity, err := openpgp.NewEntity("achgateway", "", "test@example.com", nil)
+	require.NoError(t, err)
+
+	var key bytes.Buffer
+	w, err := armor.Encode(&key, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(w, nil))
+	require.NoError(t, w.Close())
+
+	keyFile := filepath.Join(t.TempDir(), "key.priv")
+	require.NoError(t, os.WriteFile(keyFile, key.Bytes(), 0600))
+
+	m, err := newManifester(&service.ManifestConfig{
+		Format: service.ManifestPGP,
+		Signer: &service.Signer{
+			KeyFile: keyFile,
+		},
+	})
+	require.NoError(t, err)
+
+	contents := []byte("hello, world\n")
+	sig, err := m.create("file.ach", contents)
+	require.NoError(t, err)
+
+	keyring := openpgp.EntityList{entity}
+	_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(contents), bytes.NewReader(sig), nil)
+	require.NoError(t, err)
+
+	// Signatures don't verify other contents
+	_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader([]byte("other")), bytes.NewReader(sig), nil)
+	require.Error(t, err)
+}
+
+func TestAggregate_UploadManifest(t *testing.T) {
+	shard := service.Shard{
+		Name: "test",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/Los_Angeles",
+			Windows:  []string{"10:30"},
+		},
+		UploadAgent: "mock-agent",
+		Manifest: &service.ManifestConfig{
+			Format: service.ManifestSHA256,
+		},
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock-agent",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, &events.MockEmitter{}, shard, uploadAgents, service.ErrorAlerting{})
+	require.NoError(t, err)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	var expected bytes.Buffer
+	require.NoError(t, ach.NewWriter(&expected).Write(file))
+
+	agent := &upload.MockAgent{}
+	require.NoError(t, xfagg.uploadFile("2022-06-01 10:30 PDT", 0, agent, &transform.Result{File: file}, nil))
+
+	// The manifest is uploaded after the file
+	require.NotNil(t, agent.UploadedFile)
+	require.Regexp(t, `\.ach\.sha256$`, agent.UploadedFile.Filename)
+
+	bs, err := io.ReadAll(agent.UploadedFile.Contents)
+	require.NoError(t, err)
+	filename := agent.UploadedFile.Filename[:len(agent.UploadedFile.Filename)-len(".sha256")]
+	require.Equal(t, hash(expected.Bytes())+"  "+filename+"\n", string(bs))
+}
+
+// manifestFailingAgent uploads ACH files but fails every manifest
+type manifestFailingAgent struct {
+	upload.MockAgent
+	manifests int
+}
+
+func (a *manifestFailingAgent) UploadFile(f upload.File) error {
+	if filepath.Ext(f.Filename) == ".sha256" {
+		a.manifests++
+		return errors.New("connection reset")
+	}
+	return a.MockAgent.UploadFile(f)
+}
+
+func TestAggregate_UploadManifestFailure(t *testing.T) {
+	delay := manifestRetryDelay
+	manifestRetryDelay = time.Millisecond
+	t.Cleanup(func() { manifestRetryDelay = delay })
+
+	shard := service.Shard{
+		Name: "test",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/Los_Angeles",
+			Windows:  []string{"10:30"},
+		},
+		UploadAgent: "mock-agent",
+		Manifest: &service.ManifestConfig{
+			Format: service.ManifestSHA256,
+		},
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock-agent",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, &events.MockEmitter{}, shard, uploadAgents, service.ErrorAlerting{})
+	require.NoError(t, err)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	// The file was uploaded, so the upload succeeds after the manifest's attempts
+	agent := &manifestFailingAgent{}
+	uploads := &cutoffUploads{}
+	require.NoError(t, xfagg.uploadFile("2022-06-01 10:30 PDT", 0, agent, &transform.Result{File: file}, uploads))
+	require.Equal(t, manifestAttempts, agent.manifests)
+	require.NotNil(t, agent.UploadedFile)
+	require.Regexp(t, `\.ach$`, agent.UploadedFile.Filename)
+
+	// The manifest's failure is returned with the cutoff's uploads
+	require.Len(t, uploads.files, 1)
+	require.ErrorContains(t, uploads.manifestError(), "uploaded without its manifest after 3 of 3 attempts: problem uploading manifest")
+
+	// Retries stop once the instance is draining
+	manifestRetryDelay = time.Hour
+	xfagg.drain = drain.New(log.NewNopLogger(), nil)
+	xfagg.drain.Drain()
+
+	agent = &manifestFailingAgent{}
+	uploads = &cutoffUploads{}
+	require.NoError(t, xfagg.uploadFile("2022-06-01 10:30 PDT", 1, agent, &transform.Result{File: file}, uploads))
+	require.Equal(t, 1, agent.manifests)
+	require.ErrorContains(t, uploads.manifestError(), "after 1 of 3 attempts")
+}
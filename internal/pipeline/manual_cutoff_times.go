@@ -19,18 +19,28 @@ package pipeline
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"sort"
+	"sync"
 
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base"
 	"github.com/moov-io/base/log"
 )
 
 type manuallyTriggeredCutoff struct {
 	C chan error
+
+	overrideGuardrails bool
 }
 
 type manualCutoffBody struct {
 	ShardNames []string `json:"shardNames"`
+
+	// OverrideGuardrails uploads files held by guardrails and skips guardrail
+	// checks on files merged during this cutoff.
+	OverrideGuardrails bool `json:"overrideGuardrails"`
 }
 
 type shardResponses struct {
@@ -46,6 +56,20 @@ func (ss shardResponses) hasErrors() bool {
 	return false
 }
 
+func (ss shardResponses) errors() error {
+	var el base.ErrorList
+	for name, err := range ss.Shards {
+		if err != nil {
+			el.Add(fmt.Errorf("%s: %s", name, *err))
+		}
+	}
+	if el.Empty() {
+		return nil
+	}
+	sort.Slice(el, func(i, j int) bool { return el[i].Error() < el[j].Error() })
+	return el
+}
+
 func (fr *FileReceiver) triggerManualCutoff() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPut {
@@ -56,38 +80,17 @@ func (fr *FileReceiver) triggerManualCutoff() http.HandlerFunc {
 		var body manualCutoffBody
 		json.NewDecoder(r.Body).Decode(&body)
 
-		responses := shardResponses{
-			Shards: make(map[string]*string),
-		}
-
-		for _, xfagg := range fr.shardAggregators {
-			logger := fr.logger.With(log.Fields{
-				"shard": log.String(xfagg.shard.Name),
+		// Releasing held files needs a second operator when approvals are enabled
+		if body.OverrideGuardrails && fr.approvals != nil {
+			desc := fmt.Sprintf("manual cutoff overriding guardrails for shards %v", body.ShardNames)
+			fr.requestApproval(w, r, "trigger-cutoff", desc, func() error {
+				return fr.manualCutoff(body).errors()
 			})
-
-			waiter, err := processManualCutoff(logger, body.ShardNames, xfagg.shard, xfagg)
-			if err != nil {
-				errString := err.Error()
-				responses.Shards[xfagg.shard.Name] = &errString
-				continue
-			}
-			if waiter == nil {
-				logger.Info().Log("skipping manual trigger")
-				continue
-			}
-			if err := <-waiter.C; err != nil {
-				logger.Error().LogErrorf("ERROR when triggering shard: %v", err)
-				xfagg.alertOnError(err)
-
-				errString := err.Error()
-				responses.Shards[xfagg.shard.Name] = &errString
-
-			} else {
-				logger.Info().Log("successful manual trigger")
-				responses.Shards[xfagg.shard.Name] = nil
-			}
+			return
 		}
 
+		responses := fr.manualCutoff(body)
+
 		// Write the response headers
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		if responses.hasErrors() {
@@ -100,15 +103,62 @@ func (fr *FileReceiver) triggerManualCutoff() http.HandlerFunc {
 	}
 }
 
-func processManualCutoff(logger log.Logger, shardNames []string, shard service.Shard, xfagg *aggregator) (*manuallyTriggeredCutoff, error) {
-	if !exists(shardNames, shard.Name) {
+func (fr *FileReceiver) manualCutoff(body manualCutoffBody) shardResponses {
+	responses := shardResponses{
+		Shards: make(map[string]*string),
+	}
+
+	// Trigger every shard at once, each aggregator limits how many cutoffs run concurrently.
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, xfagg := range fr.shardAggregators {
+		logger := fr.logger.With(log.Fields{
+			"shard": log.String(xfagg.shard.Name),
+		})
+		if !exists(body.ShardNames, xfagg.shard.Name) {
+			logger.Info().Log("skipping manual trigger")
+			continue
+		}
+
+		wg.Add(1)
+		go func(xfagg *aggregator) {
+			defer wg.Done()
+
+			var errString *string
+			waiter, err := processManualCutoff(logger, body, xfagg.shard, xfagg)
+			if err == nil && waiter != nil {
+				err = <-waiter.C
+			}
+			if err != nil {
+				logger.Error().LogErrorf("ERROR when triggering shard: %v", err)
+				xfagg.alertOnError(err)
+
+				msg := err.Error()
+				errString = &msg
+			} else {
+				logger.Info().Log("successful manual trigger")
+			}
+
+			mu.Lock()
+			responses.Shards[xfagg.shard.Name] = errString
+			mu.Unlock()
+		}(xfagg)
+	}
+	wg.Wait()
+
+	return responses
+}
+
+func processManualCutoff(logger log.Logger, body manualCutoffBody, shard service.Shard, xfagg *aggregator) (*manuallyTriggeredCutoff, error) {
+	if !exists(body.ShardNames, shard.Name) {
 		return nil, nil
 	}
 
 	logger.Info().Log("found shard to manually trigger")
 
 	waiter := manuallyTriggeredCutoff{
-		C: make(chan error, 1),
+		C:                  make(chan error, 1),
+		overrideGuardrails: body.OverrideGuardrails,
 	}
 	xfagg.cutoffTrigger <- waiter
 	return &waiter, nil
@@ -22,9 +22,12 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/moov-io/achgateway/internal/approvals"
 	"github.com/moov-io/achgateway/internal/service"
 	"github.com/moov-io/base/log"
 
@@ -90,6 +93,96 @@ func TestFileReceiver__ManualCutoffErr(t *testing.T) {
 	require.Equal(t, *resp.Shards["testing"], "bad thing")
 }
 
+func TestFileReceiver__ManualCutoffApproval(t *testing.T) {
+	fr, wg := setupFileReceiver(t, nil)
+
+	var err error
+	fr.approvals, err = approvals.New(log.NewNopLogger(), &service.Approvals{
+		Operators: []service.Operator{
+			{Name: "alice", Token: "alice-token", Roles: []string{"requester", "approver"}},
+			{Name: "bob", Token: "bob-token", Roles: []string{"approver"}},
+		},
+	})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Path("/trigger-cutoff").HandlerFunc(fr.triggerManualCutoff())
+	router.Path("/approvals/{approvalID}").HandlerFunc(fr.approveAction())
+
+	// Overriding guardrails is held for approval
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/trigger-cutoff", strings.NewReader(`{"overrideGuardrails": true}`))
+	req.Header.Set("Authorization", "Bearer alice-token")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	var approval approvals.Approval
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&approval))
+	require.Equal(t, approvals.StatusPending, approval.Status)
+
+	// The requester can't approve their own action
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("PUT", "/approvals/"+approval.ID, nil)
+	req.Header.Set("Authorization", "Bearer alice-token")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("PUT", "/approvals/"+approval.ID, nil)
+	req.Header.Set("Authorization", "Bearer bob-token")
+	router.ServeHTTP(w, req)
+
+	wg.Wait()
+	w.Flush()
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&approval))
+	require.Equal(t, approvals.StatusApproved, approval.Status)
+	require.Equal(t, "bob", approval.ApprovedBy)
+}
+
+func TestFileReceiver__ManualCutoffConcurrent(t *testing.T) {
+	fr := &FileReceiver{
+		logger:           log.NewNopLogger(),
+		shardAggregators: make(map[string]*aggregator),
+	}
+
+	// Each shard only responds once every shard has been triggered
+	var triggered sync.WaitGroup
+	names := []string{"one", "two", "three"}
+	for _, name := range names {
+		triggered.Add(1)
+
+		cutoffTrigger := make(chan manuallyTriggeredCutoff)
+		fr.shardAggregators[name] = &aggregator{
+			shard: service.Shard{
+				Name: name,
+			},
+			merger:        &MockXferMerging{},
+			cutoffTrigger: cutoffTrigger,
+		}
+		go func() {
+			waiter := <-cutoffTrigger
+			triggered.Done()
+
+			done := make(chan struct{})
+			go func() {
+				triggered.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+				waiter.C <- nil
+			case <-time.After(5 * time.Second):
+				waiter.C <- errors.New("shards were not triggered concurrently")
+			}
+		}()
+	}
+
+	responses := fr.manualCutoff(manualCutoffBody{})
+	require.Len(t, responses.Shards, 3)
+	require.NoError(t, responses.errors())
+}
+
 func setupFileReceiver(t *testing.T, waiterResponse error) (*FileReceiver, *sync.WaitGroup) {
 	t.Helper()
 
@@ -18,24 +18,30 @@
 package pipeline
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/moov-io/ach"
-	"github.com/moov-io/achgateway/internal/consul"
+	"github.com/moov-io/achgateway/internal/bufpool"
+	"github.com/moov-io/achgateway/internal/guardrails"
 	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/leadership"
+	"github.com/moov-io/achgateway/internal/lineage"
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/settlement"
 	"github.com/moov-io/achgateway/internal/storage"
 	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base"
 	"github.com/moov-io/base/log"
-	"github.com/moov-io/base/strx"
 )
 
 // XferMerging represents logic for accepting ACH files to be merged together.
@@ -52,25 +58,38 @@ type XferMerging interface {
 	WithEachMerged(f func(int, upload.Agent, *ach.File) error) (*processedFiles, error)
 }
 
-func NewMerging(logger log.Logger, consul *consul.Client, shard service.Shard, cfg service.UploadAgents) (XferMerging, error) {
-	dir := strx.Or(
-		cfg.Merging.Storage.Filesystem.Directory,
-		cfg.Merging.Directory,
-		"storage", // default directory
-	)
-	cfg.Merging.Storage.Filesystem.Directory = dir
+func NewMerging(logger log.Logger, elector leadership.Elector, shard service.Shard, cfg service.UploadAgents) (XferMerging, error) {
+	cfg.Merging.Storage = cfg.Merging.StorageConfig()
+	dir := cfg.Merging.Storage.Filesystem.Directory
 
 	storage, err := storage.New(cfg.Merging.Storage)
 	if err != nil {
 		return nil, fmt.Errorf("problem creating %s: %w", dir, err)
 	}
 
+	var incremental *incrementalMerge
+	if shard.Mergable.Incremental != nil {
+		incremental = newIncrementalMerge(shard.Mergable.Conditions)
+	}
+
+	var parsed *parsedFiles
+	if shard.Mergable.ParsedCache != nil {
+		parsed = newParsedFiles(shard.Mergable.ParsedCache.MaxFileCount())
+	}
+
+	hostname, _ := os.Hostname()
+
 	return &filesystemMerging{
-		logger:  logger,
-		cfg:     cfg,
-		storage: storage,
-		shard:   shard,
-		consul:  consul,
+		logger:      logger,
+		hostname:    hostname,
+		cfg:         cfg,
+		storage:     storage,
+		shard:       shard,
+		elector:     elector,
+		incremental: incremental,
+		parsed:      parsed,
+		assigner:    lineage.NewAssigner(shard.Name, shard.Mergable.Assignment, nil),
+		recovery:    newRecoveryProgress(shard.Name, incremental != nil),
 	}, nil
 }
 
@@ -79,20 +98,68 @@ type filesystemMerging struct {
 	cfg     service.UploadAgents
 	storage storage.Chest
 	shard   service.Shard
-	consul  *consul.Client
+	elector leadership.Elector
+
+	// incremental is non-nil when files are merged as they're accepted
+	incremental *incrementalMerge
+	recovery    *recoveryProgress
+
+	// parsed is non-nil when accepted files are kept parsed until they're merged
+	parsed *parsedFiles
+
+	// assigner is non-nil when trace numbers or file IDs are replaced as files are merged
+	assigner *lineage.Assigner
+
+	// hostname is recorded in cutoff journals as the instance processing the cutoff
+	hostname string
+
+	// cutoff is held while pending files are isolated, merged and uploaded so they
+	// aren't re-encrypted back into mergable/ underneath the cutoff
+	cutoff sync.Mutex
 }
 
 func (m *filesystemMerging) HandleXfer(xfer incoming.ACHFile) error {
+	if m.incremental != nil {
+		m.incremental.mu.Lock()
+		defer m.incremental.mu.Unlock()
+	}
+
 	if err := m.writeACHFile(xfer); err != nil {
 		return m.logger.LogErrorf("problem writing ACH file: %v", err).Err()
 	}
+	m.parsed.add(xfer.FileID, xfer.File)
+
+	if m.incremental != nil {
+		// The file is saved, so if merging fails here it's read from storage at cutoff instead
+		if err := m.mergeIncrementally(xfer.FileID); err != nil {
+			m.logger.Warn().With(log.Fields{
+				"fileID":   log.String(xfer.FileID),
+				"shardKey": log.String(xfer.ShardKey),
+			}).Logf("problem merging file incrementally: %v", err)
+		}
+	}
 	return nil
 }
 
+// mergeIncrementally reads back the saved file and merges it into the shard's pending set.
+// The caller must hold m.incremental.mu.
+func (m *filesystemMerging) mergeIncrementally(fileID string) error {
+	if m.incremental.contains(fileID) {
+		return nil
+	}
+	file, err := m.loadFile(filepath.Join("mergable", m.shard.Name, fmt.Sprintf("%s.ach", fileID)))
+	if err != nil {
+		return err
+	}
+	return m.incremental.add(fileID, file)
+}
+
 func (m *filesystemMerging) writeACHFile(xfer incoming.ACHFile) error {
 	// First, write the Nacha formatted file to disk
-	var buf bytes.Buffer
-	if err := ach.NewWriter(&buf).Write(xfer.File); err != nil {
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+
+	if err := ach.NewWriter(buf).Write(xfer.File); err != nil {
 		return err
 	}
 	path := filepath.Join("mergable", m.shard.Name, fmt.Sprintf("%s.ach", xfer.FileID))
@@ -103,7 +170,7 @@ func (m *filesystemMerging) writeACHFile(xfer incoming.ACHFile) error {
 	// Second, write ValidateOpts to disk as well
 	if opts := xfer.File.GetValidation(); opts != nil {
 		buf.Reset()
-		if err := json.NewEncoder(&buf).Encode(opts); err != nil {
+		if err := json.NewEncoder(buf).Encode(opts); err != nil {
 			m.logger.Warn().With(log.Fields{
 				"fileID":   log.String(xfer.FileID),
 				"shardKey": log.String(xfer.ShardKey),
@@ -124,8 +191,45 @@ func (m *filesystemMerging) writeACHFile(xfer incoming.ACHFile) error {
 func (m *filesystemMerging) HandleCancel(cancel incoming.CancelACHFile) error {
 	path := filepath.Join("mergable", m.shard.Name, fmt.Sprintf("%s.ach", cancel.FileID))
 
+	if m.incremental != nil {
+		m.incremental.mu.Lock()
+		defer m.incremental.mu.Unlock()
+	}
+
 	// Write the canceled File
-	return m.storage.ReplaceFile(path, path+".canceled")
+	if err := m.storage.ReplaceFile(path, path+".canceled"); err != nil {
+		return err
+	}
+	m.parsed.remove(cancel.FileID)
+
+	if m.incremental != nil {
+		if err := m.incremental.cancel(cancel.FileID); err != nil {
+			m.logger.Warn().With(log.Fields{
+				"fileID":   log.String(cancel.FileID),
+				"shardKey": log.String(cancel.ShardKey),
+			}).Logf("problem re-merging files after cancel: %v", err)
+		}
+	}
+	return nil
+}
+
+func outboundLeaderKey(shardName string) string {
+	return fmt.Sprintf("achgateway/outbound/%s", shardName)
+}
+
+// leadsCutoff returns true when this instance merges the shard's pending files at cutoff. With
+// SingleWriter storage is shared, so only the shard's leader merges and uploads them.
+func leadsCutoff(logger log.Logger, elector leadership.Elector, cfg service.UploadAgents, shard service.Shard) bool {
+	if !cfg.Merging.SingleWriter {
+		return true
+	}
+	if err := leadership.AcquireLock(elector, outboundLeaderKey(shard.Name)); err != nil {
+		logger.Info().With(log.Fields{
+			"shard": log.String(shard.Name),
+		}).Logf("skipping cutoff, leaving pending files for the leader: %v", err)
+		return false
+	}
+	return true
 }
 
 func (m *filesystemMerging) isolateMergableDir() (string, error) {
@@ -166,20 +270,48 @@ func (m *filesystemMerging) getNonCanceledMatches(path string) ([]string, error)
 type processedFiles struct {
 	shardKey string
 	fileIDs  []string
+
+	// settlements are the expected settlement dates of each file's entries, keyed by fileID
+	settlements map[string][]models.EntrySettlement
+
+	// summaries total each file's entries, keyed by fileID
+	summaries map[string]fileSummary
+
+	// window identifies the cutoff, uploads are the files it wrote to the ODFI and
+	// mergeDuration is how long merging took before the first upload.
+	window        string
+	uploads       *cutoffUploads
+	mergeDuration time.Duration
 }
 
 func newProcessedFiles(shardKey string, matches []string) *processedFiles {
 	processed := &processedFiles{shardKey: shardKey}
 
 	for i := range matches {
-		// each match follows $path/$fileID.ach
-		fileID := strings.TrimSuffix(filepath.Base(matches[i]), ".ach")
-		processed.fileIDs = append(processed.fileIDs, fileID)
+		processed.fileIDs = append(processed.fileIDs, fileIDFromPath(matches[i]))
 	}
 
 	return processed
 }
 
+// fileIDFromPath returns the fileID from a $path/$fileID.ach filepath
+func fileIDFromPath(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".ach")
+}
+
+// loadFile returns the file at path, using the already parsed file when it's cached.
+func (m *filesystemMerging) loadFile(path string) (*ach.File, error) {
+	if m.parsed == nil {
+		return m.readFile(path)
+	}
+	if file := m.parsed.take(fileIDFromPath(path)); file != nil {
+		parsedFileCacheHits.With("shard", m.shard.Name).Add(1)
+		return file, nil
+	}
+	parsedFileCacheMisses.With("shard", m.shard.Name).Add(1)
+	return m.readFile(path)
+}
+
 func (m *filesystemMerging) readFile(path string) (*ach.File, error) {
 	file, err := m.storage.Open(path)
 	if err != nil {
@@ -211,15 +343,119 @@ func (m *filesystemMerging) readFile(path string) (*ach.File, error) {
 	return &f, nil
 }
 
+// mergeMatches reads and merges the files at each path in matches. Files which were already
+// merged incrementally are reused as long as all of them are still found in matches, otherwise
+// every file is read from storage and merged again.
+//
+// The trace number of each read file's first entry is returned by fileID, after trace numbers are assigned.
+func (m *filesystemMerging) mergeMatches(logger log.Logger, matches []string, premergedIDs map[string]bool, premerged []*ach.File) ([]*ach.File, map[string]string, base.ErrorList) {
+	var files []*ach.File
+	var el base.ErrorList
+	traces := make(map[string]string)
+
+	toRead := matches
+	reused := false
+	if len(premergedIDs) > 0 {
+		onDisk := make(map[string]bool, len(matches))
+		var missing []string
+		for i := range matches {
+			fileID := fileIDFromPath(matches[i])
+			onDisk[fileID] = true
+			if !premergedIDs[fileID] {
+				missing = append(missing, matches[i])
+			}
+		}
+		reused = true
+		for fileID := range premergedIDs {
+			if !onDisk[fileID] {
+				reused = false
+				break
+			}
+		}
+		if reused {
+			logger.Logf("reusing %d incrementally merged files, reading %d more", len(premergedIDs), len(missing))
+			files = premerged
+			toRead = missing
+		} else {
+			incrementalMergeFallbacks.With("shard", m.shard.Name).Add(1)
+			logger.Warn().Logf("incrementally merged files don't match storage, merging all %d files", len(matches))
+		}
+	}
+
+	for i := range toRead {
+		file, err := m.loadFile(toRead[i])
+		if err != nil {
+			el.Add(fmt.Errorf("problem reading %s: %v", toRead[i], err))
+			continue
+		}
+		if err := m.assigner.TraceNumbers(fileIDFromPath(toRead[i]), file); err != nil {
+			el.Add(fmt.Errorf("problem assigning trace numbers of %s: %v", toRead[i], err))
+			continue
+		}
+		if file != nil {
+			traces[fileIDFromPath(toRead[i])] = firstTraceNumber(file)
+			files = append(files, file)
+		}
+	}
+
+	// Incrementally merged files are already final unless more were read
+	if !reused || len(toRead) > 0 {
+		// Combine Batches into one file, force ascending TraceNumbers starting from the first EntryDetail.
+		// Also allow for custom merge conditions (max dollar amount per file, etc)
+		merged, err := mergeFiles(files, m.shard.Mergable.Conditions)
+		if err != nil {
+			el.Add(fmt.Errorf("unable to merge files: %v", err))
+		}
+		files = merged
+	}
+
+	// Files which can't be split are left combined so pending files aren't lost
+	split, err := splitFiles(files, m.shard.Mergable.SplitFiles, m.shard.Mergable.Conditions)
+	if err != nil {
+		el.Add(fmt.Errorf("unable to split files: %v", err))
+		return files, traces, el
+	}
+	return split, traces, el
+}
+
 func (m *filesystemMerging) WithEachMerged(f func(int, upload.Agent, *ach.File) error) (*processedFiles, error) {
 	processed := &processedFiles{}
 
+	if !leadsCutoff(m.logger, m.elector, m.cfg, m.shard) {
+		return processed, nil
+	}
+	m.cutoff.Lock()
+	defer m.cutoff.Unlock()
+
+	start := time.Now()
+
 	// move the current directory so it's isolated and easier to debug later on
-	dir, err := m.isolateMergableDir()
+	var dir string
+	var err error
+	var premergedIDs map[string]bool
+	var premerged []*ach.File
+	if m.incremental != nil {
+		// Isolate and take the incremental state together so files accepted
+		// during the cutoff are left for the next one.
+		m.incremental.mu.Lock()
+		dir, err = m.isolateMergableDir()
+		if err == nil {
+			premergedIDs, premerged = m.incremental.take()
+		}
+		m.incremental.mu.Unlock()
+	} else {
+		dir, err = m.isolateMergableDir()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("problem isolating newdir=%s error=%v", dir, err)
 	}
 
+	// Record the cutoff so another instance can finish it if we're lost before uploading
+	journal := m.newJournal(dir)
+	if err := m.writeJournal(journal); err != nil {
+		return nil, fmt.Errorf("problem writing cutoff journal: %v", err)
+	}
+
 	matches, err := m.getNonCanceledMatches(dir)
 	if err != nil {
 		return nil, fmt.Errorf("problem with %s glob: %v", dir, err)
@@ -228,29 +464,7 @@ func (m *filesystemMerging) WithEachMerged(f func(int, upload.Agent, *ach.File)
 	logger := m.logger.Set("shardName", log.String(m.shard.Name))
 	logger.Logf("found %d matching ACH files: %#v", len(matches), matches)
 
-	var files []*ach.File
-	var el base.ErrorList
-	for i := range matches {
-		file, err := m.readFile(matches[i])
-		if err != nil {
-			el.Add(fmt.Errorf("problem reading %s: %v", matches[i], err))
-			continue
-		}
-		if file != nil {
-			files = append(files, file)
-		}
-	}
-
-	// Combine Batches into one file, force ascending TraceNumbers starting from the first EntryDetail.
-	// Also allow for custom merge conditions (max dollar amount per file, etc)
-	if m.shard.Mergable.Conditions != nil {
-		files, err = ach.MergeFilesWith(files, *m.shard.Mergable.Conditions)
-	} else {
-		files, err = ach.MergeFiles(files)
-	}
-	if err != nil {
-		el.Add(fmt.Errorf("unable to merge files: %v", err))
-	}
+	files, traces, el := m.mergeMatches(logger, matches, premergedIDs, premerged)
 
 	if len(matches) > 0 {
 		logger.Logf("merged %d files into %d files", len(matches), len(files))
@@ -274,60 +488,185 @@ func (m *filesystemMerging) WithEachMerged(f func(int, upload.Agent, *ach.File)
 	}
 	logger.Logf("found %T agent", agent)
 
+	// Write our files to the mergable directory before uploading any of them
+	paths, saveErrors := m.saveMergedFiles(dir, files)
+	el = append(el, saveErrors...)
+	if journal != nil && len(files) > 0 {
+		journal.State = journalMerged
+		if err := m.writeJournal(journal); err != nil {
+			el.Add(fmt.Errorf("problem writing cutoff journal: %v", err))
+		}
+	}
+	mergeDuration := time.Since(start)
+
 	// Write each file to our remote agent
 	successfulRemoteWrites := 0
+	indexer := newFileIndexer(m.shard.Mergable.SplitFiles)
 	for i := range files {
-		// Optionally Flatten Batches
-		if m.shard.Mergable.FlattenBatches != nil {
-			if file, err := files[i].FlattenBatches(); err != nil {
-				el.Add(err)
-			} else {
-				files[i] = file
-			}
-		}
-
-		// Write our file to the mergable directory
-		if err := m.saveMergedFile(dir, files[i]); err != nil {
-			el.Add(fmt.Errorf("problem writing merged file: %v", err))
-		}
+		index := indexer.index(i, files[i])
 
 		// Perform the file upload if we are the shard leader
-		leaderKey := fmt.Sprintf("achgateway/outbound/%s", m.shard.Name)
+		leaderKey := outboundLeaderKey(m.shard.Name)
 		logger.Logf("attempting to acquire outbound leadership for %s", leaderKey)
 
 		// Acquire leadership for this shard
-		err := consul.AcquireLock(logger, m.consul, leaderKey)
+		err := leadership.AcquireLock(m.elector, leaderKey)
 		if err != nil {
 			logger.Warn().Logf("skipping file upload: %v", err)
 		} else {
 			logger.Info().Log("we are the leader")
 
-			if err := f(i, agent, files[i]); err != nil {
+			if err := f(index, agent, files[i]); err != nil {
 				el.Add(fmt.Errorf("problem from callback: %v", err))
 			} else {
 				successfulRemoteWrites++
+				if err := m.markUploaded(journal, paths[i]); err != nil {
+					el.Add(err)
+				}
 			}
 		}
 	}
 
 	logger.Logf("wrote %d of %d files to remote agent", successfulRemoteWrites, len(files))
 
+	// The cutoff is finished even with errors, they're alerted on instead of retried
+	if err := m.finishJournal(journal); err != nil {
+		el.Add(fmt.Errorf("problem finishing cutoff journal: %v", err))
+	}
+
 	if !el.Empty() {
 		return nil, el
 	}
 
-	return newProcessedFiles(m.shard.Name, matches), nil
+	processed = newProcessedFiles(m.shard.Name, matches)
+	processed.mergeDuration = mergeDuration
+	m.addSummaries(logger, processed, matches, traces)
+	if m.shard.Settlement != nil {
+		m.addSettlements(logger, processed, matches, time.Now())
+	}
+	return processed, nil
+}
+
+// addSummaries totals the entries of each uploaded file as it was submitted. Files not in traces
+// weren't given new trace numbers, so their first trace number is read from storage too.
+func (m *filesystemMerging) addSummaries(logger log.Logger, processed *processedFiles, matches []string, traces map[string]string) {
+	processed.summaries = make(map[string]fileSummary, len(matches))
+	for i := range matches {
+		file, err := m.readFile(matches[i])
+		if err != nil {
+			logger.Warn().LogErrorf("skipping summary of %s: %v", matches[i], err)
+			continue
+		}
+		fileID := fileIDFromPath(matches[i])
+		first, exists := traces[fileID]
+		if !exists {
+			first = firstTraceNumber(file)
+		}
+		processed.summaries[fileID] = summarizeFile(file, first)
+	}
+}
+
+// addSettlements computes when the entries of each uploaded file are expected to settle.
+// Files are read again from storage as merging changes their batches and trace numbers.
+func (m *filesystemMerging) addSettlements(logger log.Logger, processed *processedFiles, matches []string, uploadedAt time.Time) {
+	calc, err := settlement.NewCalculator(m.shard.Settlement)
+	if err != nil {
+		logger.Warn().LogErrorf("skipping settlement dates: %v", err)
+		return
+	}
+	processed.settlements = make(map[string][]models.EntrySettlement, len(matches))
+	for i := range matches {
+		file, err := m.readFile(matches[i])
+		if err != nil {
+			logger.Warn().LogErrorf("skipping settlement dates of %s: %v", matches[i], err)
+			continue
+		}
+		processed.settlements[fileIDFromPath(matches[i])] = calc.File(file, uploadedAt)
+	}
+}
+
+// errCutoffRunning is returned when files can't be re-encrypted because the shard is in a cutoff.
+var errCutoffRunning = errors.New("cutoff is running")
+
+// reencryptFiles rewrites the shard's pending and isolated files with the current
+// storage encryption key. This is used after rotating the key.
+func (m *filesystemMerging) reencryptFiles() (int, error) {
+	if !m.cutoff.TryLock() {
+		return 0, errCutoffRunning
+	}
+	defer m.cutoff.Unlock()
+
+	return ReencryptShardFiles(m.storage, m.shard.Name)
+}
+
+// ReencryptShardFiles rewrites a shard's pending, isolated, held and guardrails history files,
+// along with the guardrails velocity shared by each shard, on chest with its current encryption key.
+// It returns how many files were rewritten.
+func ReencryptShardFiles(chest storage.Chest, shardName string) (int, error) {
+	patterns := []string{
+		filepath.Join("mergable", shardName, "*.ach"),
+		filepath.Join("mergable", shardName, "*.json"),
+		filepath.Join(shardName+"-*", "*.ach"),
+		filepath.Join(shardName+"-*", "*.json"),
+		filepath.Join(shardName+"-*", "uploaded", "*.ach"),
+		filepath.Join("mergable", shardName, "*.005"),
+		filepath.Join(shardName+"-*", "*.005"),
+		filepath.Join(shardName+"-*", "uploaded", "*.005"),
+		filepath.Join("held", shardName, "*.ach"),
+		filepath.Join("guardrails", shardName, "*.json"),
+	}
+	total := 0
+	for i := range patterns {
+		n, err := storage.Reencrypt(chest, patterns[i])
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("re-encrypting %s: %w", patterns[i], err)
+		}
+	}
+	n, err := guardrails.ReencryptVelocity(chest)
+	total += n
+	if err != nil {
+		return total, fmt.Errorf("re-encrypting guardrails velocity: %w", err)
+	}
+	return total, nil
+}
+
+// saveMergedFiles optionally flattens the batches and assigns the FileIDModifier of each file,
+// then writes them into dir and returns the path of each file.
+func (m *filesystemMerging) saveMergedFiles(dir string, files []*ach.File) ([]string, base.ErrorList) {
+	var el base.ErrorList
+	paths := make([]string, len(files))
+	for i := range files {
+		if m.shard.Mergable.FlattenBatches != nil {
+			if file, err := files[i].FlattenBatches(); err != nil {
+				el.Add(err)
+			} else {
+				files[i] = file
+			}
+		}
+		if err := m.assigner.FileID(files[i]); err != nil {
+			el.Add(fmt.Errorf("problem assigning file ID: %v", err))
+		}
+		path, err := m.saveMergedFile(dir, files[i])
+		if err != nil {
+			el.Add(fmt.Errorf("problem writing merged file: %v", err))
+		}
+		paths[i] = path
+	}
+	return paths, el
 }
 
-func (m *filesystemMerging) saveMergedFile(dir string, file *ach.File) error {
-	var buf bytes.Buffer
-	if err := ach.NewWriter(&buf).Write(file); err != nil {
-		return fmt.Errorf("unable to buffer ACH file: %v", err)
+func (m *filesystemMerging) saveMergedFile(dir string, file *ach.File) (string, error) {
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+
+	if err := ach.NewWriter(buf).Write(file); err != nil {
+		return "", fmt.Errorf("unable to buffer ACH file: %v", err)
 	}
 
 	path := filepath.Join(dir, fmt.Sprintf("%s.ach", hash(buf.Bytes())))
 
-	return m.storage.WriteFile(path, buf.Bytes())
+	return path, m.storage.WriteFile(path, buf.Bytes())
 }
 
 func hash(data []byte) string {
@@ -0,0 +1,217 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/bufpool"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/leadership"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/pkg/cpa005"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+)
+
+// cpa005Merging holds the CPA Standard 005 files accepted for a shard until its cutoff and then
+// merges them into one file for upload. Files are kept in the same storage as Nacha files.
+type cpa005Merging struct {
+	logger  log.Logger
+	cfg     service.UploadAgents
+	storage storage.Chest
+	shard   service.Shard
+	elector leadership.Elector
+}
+
+func newCPA005Merging(logger log.Logger, elector leadership.Elector, shard service.Shard, cfg service.UploadAgents, chest storage.Chest) *cpa005Merging {
+	if shard.CPA005 == nil {
+		return nil
+	}
+	return &cpa005Merging{
+		logger:  logger,
+		cfg:     cfg,
+		storage: chest,
+		shard:   shard,
+		elector: elector,
+	}
+}
+
+func (m *cpa005Merging) handleFile(xfer incoming.CPA005File) error {
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+
+	if err := xfer.File.Write(buf); err != nil {
+		return fmt.Errorf("invalid CPA-005 file: %v", err)
+	}
+	path := filepath.Join("mergable", m.shard.Name, fmt.Sprintf("%s.005", xfer.FileID))
+	return m.storage.WriteFile(path, buf.Bytes())
+}
+
+func (m *cpa005Merging) handleCancel(cancel incoming.CancelACHFile) error {
+	path := filepath.Join("mergable", m.shard.Name, fmt.Sprintf("%s.005", cancel.FileID))
+	return m.storage.ReplaceFile(path, path+".canceled")
+}
+
+// withEachMerged isolates the pending files, merges them into one file and passes it to f.
+func (m *cpa005Merging) withEachMerged(f func(upload.Agent, *cpa005.File) error) (*processedFiles, error) {
+	if !leadsCutoff(m.logger, m.elector, m.cfg, m.shard) {
+		return &processedFiles{}, nil
+	}
+
+	dir := fmt.Sprintf("%s-%v", m.shard.Name, time.Now().Format("20060102-150405"))
+	if err := m.storage.ReplaceDir(filepath.Join("mergable", m.shard.Name), dir); err != nil {
+		return nil, fmt.Errorf("problem isolating newdir=%s error=%v", dir, err)
+	}
+
+	matches, err := m.pendingFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("problem with %s glob: %v", dir, err)
+	}
+	logger := m.logger.Set("shardName", log.String(m.shard.Name))
+	logger.Logf("found %d matching CPA-005 files: %#v", len(matches), matches)
+
+	if len(matches) == 0 {
+		return &processedFiles{}, m.storage.RmdirAll(dir)
+	}
+
+	var el base.ErrorList
+	processed := &processedFiles{shardKey: m.shard.Name}
+	var files []*cpa005.File
+	for i := range matches {
+		file, err := m.readFile(matches[i])
+		if err != nil {
+			el.Add(fmt.Errorf("problem reading %s: %v", matches[i], err))
+			continue
+		}
+		files = append(files, file)
+		processed.fileIDs = append(processed.fileIDs, strings.TrimSuffix(filepath.Base(matches[i]), ".005"))
+	}
+	if len(files) == 0 {
+		return nil, el
+	}
+
+	creationNumber, err := m.nextFileCreationNumber()
+	if err != nil {
+		return nil, fmt.Errorf("file creation number: %v", err)
+	}
+	merged, err := cpa005.Merge(cpa005.Header{
+		OriginatorID:          m.shard.CPA005.OriginatorID,
+		FileCreationNumber:    creationNumber,
+		CreationDate:          time.Now(),
+		DestinationDataCentre: m.shard.CPA005.DestinationDataCentre,
+		Currency:              m.shard.CPA005.CurrencyCode(),
+	}, files...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to merge files: %v", err)
+	}
+	logger.Logf("merged %d files into file creation number %d", len(files), creationNumber)
+
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+	if err := merged.Write(buf); err != nil {
+		return nil, fmt.Errorf("problem writing merged file: %v", err)
+	}
+	uploaded := filepath.Join(dir, "uploaded")
+	m.storage.MkdirAll(uploaded)
+	if err := m.storage.WriteFile(filepath.Join(uploaded, fmt.Sprintf("%04d.005", creationNumber)), buf.Bytes()); err != nil {
+		el.Add(fmt.Errorf("problem writing merged file: %v", err))
+	}
+
+	agent, err := upload.New(m.logger, m.cfg, m.shard.UploadAgent)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %v", err)
+	}
+
+	leaderKey := outboundLeaderKey(m.shard.Name)
+	if err := leadership.AcquireLock(m.elector, leaderKey); err != nil {
+		logger.Warn().Logf("skipping file upload: %v", err)
+	} else if err := f(agent, merged); err != nil {
+		el.Add(fmt.Errorf("problem from callback: %v", err))
+	}
+
+	if !el.Empty() {
+		return nil, el
+	}
+	return processed, nil
+}
+
+func (m *cpa005Merging) pendingFiles(dir string) ([]string, error) {
+	positive, err := m.storage.Glob(dir + "/*.005")
+	if err != nil {
+		return nil, err
+	}
+	negative, err := m.storage.Glob(dir + "/*.005.canceled")
+	if err != nil {
+		return nil, err
+	}
+	canceled := make(map[string]bool)
+	for i := range negative {
+		canceled[strings.TrimSuffix(negative[i].RelativePath, ".canceled")] = true
+	}
+	var out []string
+	for i := range positive {
+		if !canceled[positive[i].RelativePath] {
+			out = append(out, positive[i].RelativePath)
+		}
+	}
+	return out, nil
+}
+
+func (m *cpa005Merging) readFile(path string) (*cpa005.File, error) {
+	fd, err := m.storage.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	return cpa005.Read(fd)
+}
+
+// nextFileCreationNumber returns the shard's next file creation number. CPA-005 requires each
+// file from an originator to use the next number, wrapping from 9999 back to 1.
+func (m *cpa005Merging) nextFileCreationNumber() (int, error) {
+	path := filepath.Join("cpa005", m.shard.Name, "file-creation-number")
+
+	previous := 0
+	if fd, err := m.storage.Open(path); err == nil && fd != nil {
+		bs, err := io.ReadAll(fd)
+		fd.Close()
+		if err != nil {
+			return 0, err
+		}
+		previous, err = strconv.Atoi(strings.TrimSpace(string(bs)))
+		if err != nil {
+			return 0, fmt.Errorf("reading %s: %v", path, err)
+		}
+	}
+	next := previous%9999 + 1
+
+	m.storage.MkdirAll(filepath.Dir(path))
+	if err := m.storage.WriteFile(path, []byte(strconv.Itoa(next))); err != nil {
+		return 0, err
+	}
+	return next, nil
+}
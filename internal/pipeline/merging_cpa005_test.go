@@ -0,0 +1,160 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/pkg/cpa005"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func cpa005TestFile(t *testing.T, trace string, amount int) *cpa005.File {
+	t.Helper()
+
+	file := &cpa005.File{
+		Header: cpa005.Header{
+			OriginatorID:          "SUBMITTER1",
+			FileCreationNumber:    1,
+			CreationDate:          time.Now(),
+			DestinationDataCentre: "00120",
+			Currency:              "CAD",
+		},
+		Transactions: []*cpa005.Transaction{
+			{
+				RecordType:      cpa005.Credit,
+				TransactionCode: "200",
+				Amount:          amount,
+				DueDate:         time.Now(),
+				InstitutionID:   "000112345",
+				AccountNumber:   "1234567",
+				ItemTraceNumber: trace,
+				PayeeName:       "JANE DOE",
+			},
+		},
+	}
+	file.Create()
+	return file
+}
+
+func setupCPA005Aggregator(t *testing.T) (*aggregator, service.UploadAgents) {
+	t.Helper()
+
+	shard := service.Shard{
+		Name: "canada",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/Toronto",
+			Windows:  []string{"10:30"},
+		},
+		UploadAgent: "mock-agent",
+		CPA005: &service.CPA005Config{
+			OriginatorID:          "MOOVCA0001",
+			DestinationDataCentre: "00120",
+		},
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock-agent",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	uploadAgents.Merging.Storage.Filesystem.Directory = t.TempDir()
+
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, &events.MockEmitter{}, shard, uploadAgents, service.ErrorAlerting{})
+	require.NoError(t, err)
+	require.NotNil(t, xfagg.cpa005)
+
+	return xfagg, uploadAgents
+}
+
+func TestAggregator__CPA005(t *testing.T) {
+	xfagg, uploadAgents := setupCPA005Aggregator(t)
+
+	require.NoError(t, xfagg.acceptCPA005File(incoming.CPA005File{FileID: "f1", ShardKey: "canada", File: cpa005TestFile(t, "1", 100)}))
+	require.NoError(t, xfagg.acceptCPA005File(incoming.CPA005File{FileID: "f2", ShardKey: "canada", File: cpa005TestFile(t, "2", 250)}))
+	require.NoError(t, xfagg.acceptCPA005File(incoming.CPA005File{FileID: "f3", ShardKey: "canada", File: cpa005TestFile(t, "3", 999)}))
+	require.NoError(t, xfagg.cancelFile(incoming.CancelACHFile{FileID: "f3", ShardKey: "canada"}))
+
+	// Nacha files are rejected
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	err = xfagg.acceptFile(incoming.ACHFile{FileID: "ach1", ShardKey: "canada", File: file})
+	require.ErrorIs(t, err, errFileFormat)
+
+	processed, err := xfagg.mergeAndUpload("16:20", false, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"f1", "f2"}, processed.fileIDs)
+
+	agent, err := upload.New(log.NewNopLogger(), uploadAgents, "mock-agent")
+	require.NoError(t, err)
+	mock, ok := agent.(*upload.MockAgent)
+	require.True(t, ok)
+	require.NotNil(t, mock.UploadedFile)
+	require.Regexp(t, `^\d{8}-\d{6}-00120\.005$`, mock.UploadedFile.Filename)
+
+	uploaded, err := cpa005.Read(mock.UploadedFile.Contents)
+	require.NoError(t, err)
+	require.Equal(t, "MOOVCA0001", uploaded.Header.OriginatorID)
+	require.Equal(t, 1, uploaded.Header.FileCreationNumber)
+	require.Len(t, uploaded.Transactions, 2)
+	require.Equal(t, 350, uploaded.Trailer.TotalCreditAmount)
+
+	// The next file uses the next file creation number
+	n, err := xfagg.cpa005.nextFileCreationNumber()
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+}
+
+func TestCPA005Merging__FileCreationNumber(t *testing.T) {
+	xfagg, uploadAgents := setupCPA005Aggregator(t)
+
+	path := filepath.Join(uploadAgents.Merging.Storage.Filesystem.Directory, "cpa005", "canada")
+	require.NoError(t, os.MkdirAll(path, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "file-creation-number"), []byte("9999"), 0600))
+
+	n, err := xfagg.cpa005.nextFileCreationNumber()
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestAggregator__CPA005Rejected(t *testing.T) {
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, &events.MockEmitter{}, service.Shard{
+		Name: "test",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/Los_Angeles",
+			Windows:  []string{"10:30"},
+		},
+		UploadAgent: "mock-agent",
+	}, service.UploadAgents{}, service.ErrorAlerting{})
+	require.NoError(t, err)
+
+	err = xfagg.acceptCPA005File(incoming.CPA005File{FileID: "f1", ShardKey: "test", File: cpa005TestFile(t, "1", 100)})
+	require.ErrorIs(t, err, errFileFormat)
+}
@@ -0,0 +1,252 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/leadership"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+)
+
+const (
+	journalIsolated = "isolated"
+	journalMerged   = "merged"
+)
+
+// cutoffJournal records how far a cutoff has gotten. Journals are only kept when merging storage
+// is shared (SingleWriter) so the next leader can finish a cutoff if its instance is lost.
+//
+// Journals are written to cutoffs/$shard/$dir.json and renamed with a .complete suffix once the
+// cutoff is finished. Each uploaded file is marked with an empty $hash.ach.uploaded file.
+type cutoffJournal struct {
+	Holder    string    `json:"holder"`
+	Directory string    `json:"directory"`
+	State     string    `json:"state"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// takeover is a cutoff finished by this instance after its previous holder was lost
+type takeover struct {
+	journal     cutoffJournal
+	takenOverAt time.Time
+	processed   *processedFiles
+}
+
+func (m *filesystemMerging) newJournal(dir string) *cutoffJournal {
+	if !m.cfg.Merging.SingleWriter {
+		return nil
+	}
+	return &cutoffJournal{
+		Holder:    m.hostname,
+		Directory: dir,
+		State:     journalIsolated,
+		StartedAt: time.Now(),
+	}
+}
+
+func (m *filesystemMerging) journalPath(dir string) string {
+	return filepath.Join("cutoffs", m.shard.Name, dir+".json")
+}
+
+func (m *filesystemMerging) writeJournal(journal *cutoffJournal) error {
+	if journal == nil {
+		return nil
+	}
+	bs, err := json.Marshal(journal)
+	if err != nil {
+		return err
+	}
+	return m.storage.WriteFile(m.journalPath(journal.Directory), bs)
+}
+
+func (m *filesystemMerging) readJournal(path string) (*cutoffJournal, error) {
+	fd, err := m.storage.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	bs, err := io.ReadAll(fd)
+	if err != nil {
+		return nil, err
+	}
+	var journal cutoffJournal
+	if err := json.Unmarshal(bs, &journal); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	return &journal, nil
+}
+
+func (m *filesystemMerging) markUploaded(journal *cutoffJournal, path string) error {
+	if journal == nil || path == "" {
+		return nil
+	}
+	if err := m.storage.WriteFile(path+".uploaded", nil); err != nil {
+		return fmt.Errorf("problem marking %s uploaded: %v", path, err)
+	}
+	return nil
+}
+
+func (m *filesystemMerging) finishJournal(journal *cutoffJournal) error {
+	if journal == nil {
+		return nil
+	}
+	path := m.journalPath(journal.Directory)
+	return m.storage.ReplaceFile(path, path+".complete")
+}
+
+// takeOverCutoffs finishes each cutoff left unfinished in shared storage, which happens when the
+// instance processing it was lost. Cutoffs are only taken over by the shard's leader, so a cutoff
+// still being processed by a live leader is left alone.
+func (m *filesystemMerging) takeOverCutoffs(f func(int, upload.Agent, *ach.File) error) ([]takeover, error) {
+	if !m.cfg.Merging.SingleWriter {
+		return nil, nil
+	}
+	matches, err := m.storage.Glob(filepath.Join("cutoffs", m.shard.Name, "*.json"))
+	if err != nil || len(matches) == 0 {
+		return nil, err
+	}
+	if err := leadership.AcquireLock(m.elector, outboundLeaderKey(m.shard.Name)); err != nil {
+		return nil, nil
+	}
+	m.cutoff.Lock()
+	defer m.cutoff.Unlock()
+
+	var out []takeover
+	var el base.ErrorList
+	for i := range matches {
+		journal, err := m.readJournal(matches[i].RelativePath)
+		if err != nil {
+			el.Add(err)
+			continue
+		}
+		m.logger.Warn().With(log.Fields{
+			"shard":  log.String(m.shard.Name),
+			"holder": log.String(journal.Holder),
+		}).Logf("taking over %s cutoff %s started at %v", journal.State, journal.Directory, journal.StartedAt)
+
+		processed, err := m.finishCutoff(journal, f)
+		if err != nil {
+			el.Add(fmt.Errorf("taking over %s: %v", journal.Directory, err))
+			continue
+		}
+		out = append(out, takeover{
+			journal:     *journal,
+			takenOverAt: time.Now(),
+			processed:   processed,
+		})
+	}
+	if el.Empty() {
+		return out, nil
+	}
+	return out, el
+}
+
+// finishCutoff uploads the merged files of journal which weren't uploaded yet. Cutoffs lost
+// before every merged file was saved are merged again, as none of their files were uploaded.
+func (m *filesystemMerging) finishCutoff(journal *cutoffJournal, f func(int, upload.Agent, *ach.File) error) (*processedFiles, error) {
+	logger := m.logger.Set("shardName", log.String(m.shard.Name))
+
+	matches, err := m.getNonCanceledMatches(journal.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("problem with %s glob: %v", journal.Directory, err)
+	}
+
+	// Trace numbers assigned before the journal was merged aren't known, so those uploaded
+	// files are only found when trace numbers are kept.
+	var traces map[string]string
+	dir := filepath.Join(journal.Directory, "uploaded")
+	if journal.State == journalIsolated {
+		if err := m.storage.RmdirAll(dir); err != nil {
+			return nil, err
+		}
+		files, assigned, el := m.mergeMatches(logger, matches, nil, nil)
+		if !el.Empty() {
+			return nil, el
+		}
+		if _, el := m.saveMergedFiles(dir, files); !el.Empty() {
+			return nil, el
+		}
+		traces = assigned
+		journal.State = journalMerged
+		if err := m.writeJournal(journal); err != nil {
+			return nil, err
+		}
+	}
+
+	merged, err := m.storage.Glob(filepath.Join(dir, "*.ach"))
+	if err != nil {
+		return nil, err
+	}
+	uploaded, err := m.storage.Glob(filepath.Join(dir, "*.ach.uploaded"))
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool)
+	for i := range uploaded {
+		done[strings.TrimSuffix(uploaded[i].RelativePath, ".uploaded")] = true
+	}
+
+	agent, err := upload.New(m.logger, m.cfg, m.shard.UploadAgent)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %v", err)
+	}
+
+	var el base.ErrorList
+	indexer := newFileIndexer(m.shard.Mergable.SplitFiles)
+	for i := range merged {
+		path := merged[i].RelativePath
+		if done[path] {
+			continue
+		}
+		file, err := m.readFile(path)
+		if err != nil {
+			el.Add(fmt.Errorf("problem reading %s: %v", path, err))
+			continue
+		}
+		if err := f(indexer.index(i, file), agent, file); err != nil {
+			el.Add(fmt.Errorf("problem from callback: %v", err))
+			continue
+		}
+		if err := m.markUploaded(journal, path); err != nil {
+			el.Add(err)
+		}
+	}
+	if !el.Empty() {
+		return nil, el // leave the journal so the upload is retried
+	}
+	if err := m.finishJournal(journal); err != nil {
+		return nil, err
+	}
+
+	processed := newProcessedFiles(m.shard.Name, matches)
+	m.addSummaries(logger, processed, matches, traces)
+	if m.shard.Settlement != nil {
+		m.addSettlements(logger, processed, matches, time.Now())
+	}
+	return processed, nil
+}
@@ -0,0 +1,202 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerging__TakeOverCutoffs(t *testing.T) {
+	shard := service.Shard{
+		Name:        "testing",
+		UploadAgent: "mock",
+	}
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	cfg.Merging.Storage.Filesystem.Directory = t.TempDir()
+	cfg.Merging.SingleWriter = true
+
+	leaderKey := outboundLeaderKey(shard.Name)
+	lost, err := NewMerging(log.NewNopLogger(), leaderOf{leaderKey: true}, shard, cfg)
+	require.NoError(t, err)
+	follower, err := NewMerging(log.NewNopLogger(), leaderOf{}, shard, cfg)
+	require.NoError(t, err)
+	next, err := NewMerging(log.NewNopLogger(), leaderOf{leaderKey: true}, shard, cfg)
+	require.NoError(t, err)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	xfer := incoming.ACHFile{FileID: base.ID(), ShardKey: "testing", File: file}
+	require.NoError(t, lost.HandleXfer(xfer))
+
+	// The leader is lost right after isolating its pending files
+	mm := lost.(*filesystemMerging)
+	mm.hostname = "achgateway-0"
+	dir, err := mm.isolateMergableDir()
+	require.NoError(t, err)
+	require.NoError(t, mm.writeJournal(mm.newJournal(dir)))
+
+	var uploads int
+	uploadFile := func(_ int, _ upload.Agent, _ *ach.File) error {
+		uploads++
+		return nil
+	}
+
+	// Only the new leader takes over
+	takeovers, err := follower.(*filesystemMerging).takeOverCutoffs(uploadFile)
+	require.NoError(t, err)
+	require.Empty(t, takeovers)
+
+	// Failed uploads leave the cutoff to be taken over again
+	takeovers, err = next.(*filesystemMerging).takeOverCutoffs(func(_ int, _ upload.Agent, _ *ach.File) error {
+		return errors.New("connection reset")
+	})
+	require.ErrorContains(t, err, "connection reset")
+	require.Empty(t, takeovers)
+
+	journal, err := mm.readJournal(mm.journalPath(dir))
+	require.NoError(t, err)
+	require.Equal(t, journalMerged, journal.State)
+
+	takeovers, err = next.(*filesystemMerging).takeOverCutoffs(uploadFile)
+	require.NoError(t, err)
+	require.Len(t, takeovers, 1)
+	require.Equal(t, 1, uploads)
+	require.Equal(t, "achgateway-0", takeovers[0].journal.Holder)
+	require.Equal(t, []string{xfer.FileID}, takeovers[0].processed.fileIDs)
+
+	// Finished cutoffs aren't taken over again
+	takeovers, err = next.(*filesystemMerging).takeOverCutoffs(uploadFile)
+	require.NoError(t, err)
+	require.Empty(t, takeovers)
+	require.Equal(t, 1, uploads)
+}
+
+func TestMerging__TakeOverPartialUpload(t *testing.T) {
+	shard := service.Shard{
+		Name:        "testing",
+		UploadAgent: "mock",
+	}
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	cfg.Merging.Storage.Filesystem.Directory = t.TempDir()
+	cfg.Merging.SingleWriter = true
+
+	merger, err := NewMerging(log.NewNopLogger(), nil, shard, cfg)
+	require.NoError(t, err)
+	mm := merger.(*filesystemMerging)
+
+	for _, name := range []string{"ppd-debit.ach", "two-micro-deposits.ach"} {
+		file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", name))
+		require.NoError(t, err)
+		require.NoError(t, merger.HandleXfer(incoming.ACHFile{FileID: base.ID(), ShardKey: "testing", File: file}))
+	}
+
+	// Save the merged files and upload one of them before the leader is lost
+	dir, err := mm.isolateMergableDir()
+	require.NoError(t, err)
+	journal := mm.newJournal(dir)
+	journal.StartedAt = time.Now().Add(-time.Minute)
+	matches, err := mm.getNonCanceledMatches(dir)
+	require.NoError(t, err)
+	files, _, el := mm.mergeMatches(log.NewNopLogger(), matches, nil, nil)
+	require.True(t, el.Empty())
+	require.Len(t, files, 2)
+	paths, el := mm.saveMergedFiles(filepath.Join(dir, "uploaded"), files)
+	require.True(t, el.Empty())
+	journal.State = journalMerged
+	require.NoError(t, mm.writeJournal(journal))
+	require.NoError(t, mm.markUploaded(journal, paths[0]))
+
+	var uploads int
+	takeovers, err := mm.takeOverCutoffs(func(_ int, _ upload.Agent, _ *ach.File) error {
+		uploads++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, takeovers, 1)
+	require.Equal(t, 1, uploads)
+	require.Len(t, takeovers[0].processed.fileIDs, 2)
+}
+
+func TestMerging__SingleWriterJournal(t *testing.T) {
+	shard := service.Shard{
+		Name:        "testing",
+		UploadAgent: "mock",
+	}
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	cfg.Merging.Storage.Filesystem.Directory = t.TempDir()
+	cfg.Merging.SingleWriter = true
+
+	merger, err := NewMerging(log.NewNopLogger(), nil, shard, cfg)
+	require.NoError(t, err)
+	mm := merger.(*filesystemMerging)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	require.NoError(t, merger.HandleXfer(incoming.ACHFile{FileID: base.ID(), ShardKey: "testing", File: file}))
+
+	_, err = merger.WithEachMerged(func(_ int, _ upload.Agent, _ *ach.File) error {
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Finished cutoffs keep their journal and mark each uploaded file
+	pending, err := mm.storage.Glob(filepath.Join("cutoffs", "testing", "*.json"))
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	complete, err := mm.storage.Glob(filepath.Join("cutoffs", "testing", "*.json.complete"))
+	require.NoError(t, err)
+	require.Len(t, complete, 1)
+
+	uploaded, err := mm.storage.Glob(filepath.Join("testing-*", "uploaded", "*.ach.uploaded"))
+	require.NoError(t, err)
+	require.Len(t, uploaded, 1)
+}
@@ -0,0 +1,159 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"sync"
+
+	"github.com/moov-io/ach"
+)
+
+// incrementalMerge holds a shard's pending files merged together as they're
+// accepted. Files are grouped by their FileHeader origin and destination as
+// only those can be merged together, so each new file is only merged with its
+// own group.
+type incrementalMerge struct {
+	mu         sync.Mutex
+	conditions *ach.Conditions
+
+	order  []string             // fileIDs in the order they were added
+	files  map[string]*ach.File // fileID to the file as accepted
+	groups map[string][]*ach.File
+
+	// generation is incremented each time a cutoff takes the merged files
+	generation uint64
+}
+
+func newIncrementalMerge(conditions *ach.Conditions) *incrementalMerge {
+	im := &incrementalMerge{
+		conditions: conditions,
+	}
+	im.reset()
+	return im
+}
+
+func (im *incrementalMerge) reset() {
+	im.order = nil
+	im.files = make(map[string]*ach.File)
+	im.groups = make(map[string][]*ach.File)
+}
+
+func groupKey(file *ach.File) string {
+	return file.Header.ImmediateDestination + "/" + file.Header.ImmediateOrigin
+}
+
+// contains reports if fileID has been merged. The caller must hold im.mu.
+func (im *incrementalMerge) contains(fileID string) bool {
+	_, exists := im.files[fileID]
+	return exists
+}
+
+// add merges file into its group. The caller must hold im.mu.
+func (im *incrementalMerge) add(fileID string, file *ach.File) error {
+	if im.contains(fileID) {
+		return nil
+	}
+
+	key := groupKey(file)
+	merged, err := mergeFiles(append(im.groups[key], file), im.conditions)
+	if err != nil {
+		return err
+	}
+	im.groups[key] = merged
+	im.files[fileID] = file
+	im.order = append(im.order, fileID)
+	return nil
+}
+
+// cancel removes fileID and re-merges what remains of its group. The caller must hold im.mu.
+func (im *incrementalMerge) cancel(fileID string) error {
+	file, exists := im.files[fileID]
+	if !exists {
+		return nil
+	}
+	delete(im.files, fileID)
+	for i := range im.order {
+		if im.order[i] == fileID {
+			im.order = append(im.order[:i], im.order[i+1:]...)
+			break
+		}
+	}
+
+	key := groupKey(file)
+	var remaining []*ach.File
+	for _, id := range im.order {
+		if f := im.files[id]; groupKey(f) == key {
+			remaining = append(remaining, f)
+		}
+	}
+	if len(remaining) == 0 {
+		delete(im.groups, key)
+		return nil
+	}
+	merged, err := mergeFiles(remaining, im.conditions)
+	if err != nil {
+		// Forget the whole group so its files are read from storage at cutoff
+		im.drop(key)
+		return err
+	}
+	im.groups[key] = merged
+	return nil
+}
+
+func (im *incrementalMerge) drop(key string) {
+	var order []string
+	for _, id := range im.order {
+		if groupKey(im.files[id]) == key {
+			delete(im.files, id)
+		} else {
+			order = append(order, id)
+		}
+	}
+	im.order = order
+	delete(im.groups, key)
+}
+
+// take returns the merged files and the fileIDs they contain, then starts
+// over for the next cutoff. The caller must hold im.mu.
+func (im *incrementalMerge) take() (map[string]bool, []*ach.File) {
+	fileIDs := make(map[string]bool, len(im.order))
+	for _, id := range im.order {
+		fileIDs[id] = true
+	}
+
+	var merged []*ach.File
+	seen := make(map[string]bool)
+	for _, id := range im.order {
+		key := groupKey(im.files[id])
+		if !seen[key] {
+			seen[key] = true
+			merged = append(merged, im.groups[key]...)
+		}
+	}
+
+	im.reset()
+	im.generation++
+	return fileIDs, merged
+}
+
+func mergeFiles(files []*ach.File, conditions *ach.Conditions) ([]*ach.File, error) {
+	if conditions != nil {
+		return ach.MergeFilesWith(files, *conditions)
+	}
+	return ach.MergeFiles(files)
+}
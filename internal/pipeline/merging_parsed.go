@@ -0,0 +1,82 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"sync"
+
+	"github.com/moov-io/ach"
+)
+
+// parsedFiles holds accepted files as they were parsed so merging can skip reading and
+// parsing them from storage. Merging mutates the files it's given (batches are shared and
+// renumbered) so each file is handed out once by take and dropped from the cache.
+type parsedFiles struct {
+	mu    sync.Mutex
+	max   int
+	files map[string]*ach.File // fileID to the accepted file
+}
+
+func newParsedFiles(max int) *parsedFiles {
+	return &parsedFiles{
+		max:   max,
+		files: make(map[string]*ach.File),
+	}
+}
+
+// add keeps file for fileID unless the cache is full, in which case it's read from storage later.
+func (pf *parsedFiles) add(fileID string, file *ach.File) {
+	if pf == nil || file == nil {
+		return
+	}
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	if _, exists := pf.files[fileID]; !exists && len(pf.files) >= pf.max {
+		return
+	}
+	pf.files[fileID] = file
+}
+
+// take returns the parsed file for fileID and removes it from the cache.
+func (pf *parsedFiles) take(fileID string) *ach.File {
+	if pf == nil {
+		return nil
+	}
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	file := pf.files[fileID]
+	delete(pf.files, fileID)
+	return file
+}
+
+// remove drops fileID from the cache without returning it, such as after a cancel.
+func (pf *parsedFiles) remove(fileID string) {
+	pf.take(fileID)
+}
+
+func (pf *parsedFiles) count() int {
+	if pf == nil {
+		return 0
+	}
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	return len(pf.files)
+}
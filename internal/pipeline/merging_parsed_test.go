@@ -0,0 +1,130 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsedFiles(t *testing.T) {
+	pf := newParsedFiles(2)
+
+	first, second, third := &ach.File{}, &ach.File{}, &ach.File{}
+	pf.add("first", first)
+	pf.add("second", second)
+	pf.add("third", third) // over the limit
+	require.Equal(t, 2, pf.count())
+
+	require.Same(t, first, pf.take("first"))
+	require.Nil(t, pf.take("first"))
+	require.Nil(t, pf.take("third"))
+
+	pf.remove("second")
+	require.Equal(t, 0, pf.count())
+
+	// nil caches are disabled
+	var disabled *parsedFiles
+	disabled.add("first", first)
+	require.Nil(t, disabled.take("first"))
+	require.Equal(t, 0, disabled.count())
+}
+
+func TestMerging__ParsedCache(t *testing.T) {
+	shard := service.Shard{
+		Name:        "testing",
+		UploadAgent: "mock",
+		Mergable: service.MergableConfig{
+			ParsedCache: &service.ParsedFileCache{},
+		},
+	}
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	cfg.Merging.Storage.Filesystem.Directory = t.TempDir()
+
+	merger, err := NewMerging(log.NewNopLogger(), nil, shard, cfg)
+	require.NoError(t, err)
+	m, ok := merger.(*filesystemMerging)
+	require.True(t, ok)
+	require.NotNil(t, m.parsed)
+
+	trace := 0
+	newXfer := func(t *testing.T) incoming.ACHFile {
+		t.Helper()
+
+		file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+		require.NoError(t, err)
+		for _, entry := range file.Batches[0].GetEntries() {
+			trace++
+			entry.TraceNumber = fmt.Sprintf("%s%07d", file.Batches[0].GetHeader().ODFIIdentification, trace)
+		}
+		return incoming.ACHFile{
+			FileID:   base.ID(),
+			ShardKey: "testing",
+			File:     file,
+		}
+	}
+
+	cached, canceled := newXfer(t), newXfer(t)
+	require.NoError(t, m.HandleXfer(cached))
+	require.NoError(t, m.HandleXfer(canceled))
+	require.NoError(t, m.HandleCancel(incoming.CancelACHFile{FileID: canceled.FileID, ShardKey: "testing"}))
+	require.Equal(t, 1, m.parsed.count())
+
+	// Corrupt the stored file, merging should use the parsed file instead of storage
+	path := filepath.Join("mergable", shard.Name, fmt.Sprintf("%s.ach", cached.FileID))
+	require.NoError(t, m.storage.WriteFile(path, []byte("not a nacha file")))
+
+	// A pending file which isn't cached (e.g. written before a restart) is read from storage
+	restarted := newXfer(t)
+	require.NoError(t, m.writeACHFile(restarted))
+
+	var uploaded []*ach.File
+	processed, err := m.WithEachMerged(func(_ int, _ upload.Agent, file *ach.File) error {
+		uploaded = append(uploaded, file)
+		return nil
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{cached.FileID, restarted.FileID}, processed.fileIDs)
+
+	require.Len(t, uploaded, 1)
+	entries := 0
+	for _, batch := range uploaded[0].Batches {
+		entries += len(batch.GetEntries())
+	}
+	require.Equal(t, 2*len(restarted.File.Batches[0].GetEntries()), entries)
+
+	// Merged files are no longer cached
+	require.Equal(t, 0, m.parsed.count())
+}
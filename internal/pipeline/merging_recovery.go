@@ -0,0 +1,160 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/moov-io/base/log"
+)
+
+const (
+	recoveryDisabled    = "disabled"
+	recoveryPending     = "pending"
+	recoveryRunning     = "recovering"
+	recoveryFinished    = "finished"
+	recoveryInterrupted = "interrupted"
+	recoveryFailed      = "failed"
+)
+
+// recoveryStatus describes how far a shard is through loading its pending files
+// into the incremental merge after a restart.
+type recoveryStatus struct {
+	Shard      string     `json:"shard"`
+	State      string     `json:"state"`
+	Total      int        `json:"total"`
+	Recovered  int        `json:"recovered"`
+	Failed     int        `json:"failed"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+type recoveryProgress struct {
+	mu     sync.RWMutex
+	status recoveryStatus
+}
+
+func newRecoveryProgress(shardName string, enabled bool) *recoveryProgress {
+	state := recoveryDisabled
+	if enabled {
+		state = recoveryPending
+	}
+	return &recoveryProgress{
+		status: recoveryStatus{
+			Shard: shardName,
+			State: state,
+		},
+	}
+}
+
+func (p *recoveryProgress) Status() recoveryStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status
+}
+
+func (p *recoveryProgress) start(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	p.status.State = recoveryRunning
+	p.status.Total = total
+	p.status.StartedAt = &now
+}
+
+func (p *recoveryProgress) record(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil {
+		p.status.Failed++
+	} else {
+		p.status.Recovered++
+	}
+}
+
+func (p *recoveryProgress) finish(state string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	p.status.State = state
+	p.status.FinishedAt = &now
+	if err != nil {
+		p.status.Error = err.Error()
+	}
+}
+
+// recoverPending loads the shard's pending files from storage into the incremental merge
+// after a restart. Each file is loaded on its own so accepting files and cutoffs aren't
+// blocked while recovering. If a cutoff happens first it merges the remaining files from
+// storage and recovery stops.
+func (m *filesystemMerging) recoverPending(ctx context.Context) {
+	if m.incremental == nil {
+		return
+	}
+	logger := m.logger.With(log.Fields{
+		"shard": log.String(m.shard.Name),
+	})
+
+	m.incremental.mu.Lock()
+	generation := m.incremental.generation
+	m.incremental.mu.Unlock()
+
+	matches, err := m.getNonCanceledMatches(filepath.Join("mergable", m.shard.Name))
+	if err != nil {
+		m.recovery.finish(recoveryFailed, err)
+		logger.Error().LogErrorf("problem listing pending files for recovery: %v", err)
+		return
+	}
+	m.recovery.start(len(matches))
+	logger.Info().Logf("recovering %d pending files", len(matches))
+
+	for i := range matches {
+		select {
+		case <-ctx.Done():
+			m.recovery.finish(recoveryInterrupted, ctx.Err())
+			return
+		default:
+		}
+
+		m.incremental.mu.Lock()
+		if m.incremental.generation != generation {
+			m.incremental.mu.Unlock()
+			m.recovery.finish(recoveryInterrupted, nil)
+			logger.Info().Logf("cutoff processed pending files during recovery, stopping after %d of %d", i, len(matches))
+			return
+		}
+		err := m.mergeIncrementally(fileIDFromPath(matches[i]))
+		m.incremental.mu.Unlock()
+
+		if err != nil {
+			logger.Warn().Logf("problem recovering %s: %v", matches[i], err)
+		}
+		m.recovery.record(err)
+	}
+
+	m.recovery.finish(recoveryFinished, nil)
+	status := m.recovery.Status()
+	logger.Info().Logf("recovered %d pending files (%d failed)", status.Recovered, status.Failed)
+}
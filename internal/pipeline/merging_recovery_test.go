@@ -0,0 +1,125 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func setupRecoveryMerging(t *testing.T, pending int) *filesystemMerging {
+	t.Helper()
+
+	shard := service.Shard{
+		Name: "testing",
+		Mergable: service.MergableConfig{
+			Incremental: &service.IncrementalMerging{},
+		},
+	}
+	var cfg service.UploadAgents
+	cfg.Merging.Storage.Filesystem.Directory = t.TempDir()
+
+	merger, err := NewMerging(log.NewNopLogger(), nil, shard, cfg)
+	require.NoError(t, err)
+	m, ok := merger.(*filesystemMerging)
+	require.True(t, ok)
+
+	// Write pending files as if they were accepted before a restart
+	for i := 0; i < pending; i++ {
+		file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+		require.NoError(t, err)
+		require.NoError(t, m.writeACHFile(incoming.ACHFile{
+			FileID:   base.ID(),
+			ShardKey: "testing",
+			File:     file,
+		}))
+	}
+	return m
+}
+
+func TestMerging__RecoverPending(t *testing.T) {
+	m := setupRecoveryMerging(t, 3)
+	require.Equal(t, recoveryPending, m.recovery.Status().State)
+
+	m.recoverPending(context.Background())
+
+	status := m.recovery.Status()
+	require.Equal(t, recoveryFinished, status.State)
+	require.Equal(t, 3, status.Total)
+	require.Equal(t, 3, status.Recovered)
+	require.Equal(t, 0, status.Failed)
+	require.NotNil(t, status.StartedAt)
+	require.NotNil(t, status.FinishedAt)
+	require.Len(t, m.incremental.files, 3)
+
+	// Recovering again doesn't merge files twice
+	m.recoverPending(context.Background())
+	require.Len(t, m.incremental.files, 3)
+}
+
+func TestMerging__RecoverPendingCanceled(t *testing.T) {
+	m := setupRecoveryMerging(t, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.recoverPending(ctx)
+
+	status := m.recovery.Status()
+	require.Equal(t, recoveryInterrupted, status.State)
+	require.Equal(t, 2, status.Total)
+	require.Equal(t, 0, status.Recovered)
+	require.Empty(t, m.incremental.files)
+}
+
+func TestFileReceiver__RecoveryStatus(t *testing.T) {
+	m := setupRecoveryMerging(t, 1)
+	m.recoverPending(context.Background())
+
+	fr := &FileReceiver{
+		shardAggregators: map[string]*aggregator{
+			"testing": {merger: m},
+			"other":   {merger: &filesystemMerging{recovery: newRecoveryProgress("other", false)}},
+		},
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/recovery", nil)
+	fr.listRecoveryStatus()(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp recoveryResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Shards, 2)
+	require.Equal(t, "other", resp.Shards[0].Shard)
+	require.Equal(t, recoveryDisabled, resp.Shards[0].State)
+	require.Equal(t, "testing", resp.Shards[1].Shard)
+	require.Equal(t, recoveryFinished, resp.Shards[1].State)
+	require.Equal(t, 1, resp.Shards[1].Recovered)
+}
@@ -0,0 +1,119 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/moov-io/ach"
+)
+
+// splitFiles separates the batches of merged files into groups (by company or SEC code) and
+// merges each group on its own, so no file contains batches from two groups. Files are returned
+// in the order each group was first found.
+func splitFiles(files []*ach.File, split *service.SplitFiles, conditions *ach.Conditions) ([]*ach.File, error) {
+	if split == nil {
+		return files, nil
+	}
+
+	var keys []string
+	pieces := make(map[string][]*ach.File)
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+		byKey := make(map[string]*ach.File)
+		for _, batch := range file.Batches {
+			key := split.BatchKey(batch.GetHeader())
+			piece, exists := byKey[key]
+			if !exists {
+				if _, seen := pieces[key]; !seen {
+					keys = append(keys, key)
+				}
+				piece = ach.NewFile()
+				piece.Header = file.Header
+				piece.SetValidation(file.GetValidation())
+				byKey[key] = piece
+				pieces[key] = append(pieces[key], piece)
+			}
+			piece.AddBatch(batch)
+		}
+		for _, piece := range byKey {
+			if err := piece.Create(); err != nil {
+				return nil, fmt.Errorf("splitting file: %v", err)
+			}
+		}
+	}
+
+	var out []*ach.File
+	for _, key := range keys {
+		merged, err := mergeFiles(pieces[key], conditions)
+		if err != nil {
+			return nil, fmt.Errorf("merging %s files: %v", key, err)
+		}
+		out = append(out, merged...)
+	}
+	return out, nil
+}
+
+// fileIndexer numbers the files uploaded during a cutoff. Split files are numbered from zero
+// within each group so every group has its own filename sequence.
+type fileIndexer struct {
+	split  *service.SplitFiles
+	counts map[string]int
+}
+
+func newFileIndexer(split *service.SplitFiles) *fileIndexer {
+	return &fileIndexer{
+		split:  split,
+		counts: make(map[string]int),
+	}
+}
+
+// index returns the index of file, which is the i-th file of the cutoff
+func (x *fileIndexer) index(i int, file *ach.File) int {
+	if x.split == nil || file == nil || len(file.Batches) == 0 {
+		return i
+	}
+	key := x.split.BatchKey(file.Batches[0].GetHeader())
+	n := x.counts[key]
+	x.counts[key]++
+	return n
+}
+
+// sharedBatchFields returns the CompanyIdentification and SECCode of file's batches when
+// every batch has the same value, otherwise they're empty.
+func sharedBatchFields(file *ach.File) (company string, secCode string) {
+	if file == nil || len(file.Batches) == 0 {
+		return "", ""
+	}
+	bh := file.Batches[0].GetHeader()
+	company, secCode = bh.CompanyIdentification, bh.StandardEntryClassCode
+	for _, batch := range file.Batches[1:] {
+		bh = batch.GetHeader()
+		if bh.CompanyIdentification != company {
+			company = ""
+		}
+		if bh.StandardEntryClassCode != secCode {
+			secCode = ""
+		}
+	}
+	return company, secCode
+}
@@ -0,0 +1,135 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func companyFile(t *testing.T, company string, seq int) *ach.File {
+	t.Helper()
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	bh := file.Batches[0].GetHeader()
+	bh.CompanyIdentification = company
+	for _, entry := range file.Batches[0].GetEntries() {
+		entry.SetTraceNumber(bh.ODFIIdentification, seq)
+	}
+	require.NoError(t, file.Batches[0].Create())
+	require.NoError(t, file.Create())
+	return file
+}
+
+func TestMerging__SplitFiles(t *testing.T) {
+	shard := service.Shard{
+		Name:        "testing",
+		UploadAgent: "mock",
+		Mergable: service.MergableConfig{
+			SplitFiles: &service.SplitFiles{By: service.SplitByCompany},
+		},
+	}
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	cfg.Merging.Storage.Filesystem.Directory = t.TempDir()
+
+	merger, err := NewMerging(log.NewNopLogger(), nil, shard, cfg)
+	require.NoError(t, err)
+
+	for i, company := range []string{"company1", "company2", "company1"} {
+		require.NoError(t, merger.HandleXfer(incoming.ACHFile{
+			FileID:   base.ID(),
+			ShardKey: "testing",
+			File:     companyFile(t, company, i+1),
+		}))
+	}
+
+	uploaded := make(map[string]int)
+	_, err = merger.WithEachMerged(func(index int, _ upload.Agent, file *ach.File) error {
+		company, secCode := sharedBatchFields(file)
+		require.Equal(t, ach.PPD, secCode)
+		uploaded[fmt.Sprintf("%s-%d", company, index)] = len(file.Batches[0].GetEntries())
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Each company's files are numbered from zero
+	require.Equal(t, map[string]int{
+		"company1-0": 2,
+		"company2-0": 1,
+	}, uploaded)
+}
+
+func TestSplitFiles(t *testing.T) {
+	// Merge the files together like a cutoff would first
+	merged, err := mergeFiles([]*ach.File{
+		companyFile(t, "company1", 1),
+		companyFile(t, "company2", 2),
+		companyFile(t, "company1", 3),
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, merged, 1)
+
+	company, _ := sharedBatchFields(merged[0])
+	require.Equal(t, "", company)
+
+	files, err := splitFiles(merged, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	files, err = splitFiles(merged, &service.SplitFiles{By: service.SplitByCompany}, nil)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	company, _ = sharedBatchFields(files[0])
+	require.Equal(t, "company1", company)
+	company, _ = sharedBatchFields(files[1])
+	require.Equal(t, "company2", company)
+	for i := range files {
+		require.NoError(t, files[i].Validate())
+	}
+
+	// Every batch is PPD
+	files, err = splitFiles(merged, &service.SplitFiles{By: service.SplitBySECCode}, nil)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	indexer := newFileIndexer(&service.SplitFiles{By: service.SplitByCompany})
+	require.Equal(t, 0, indexer.index(0, companyFile(t, "company1", 1)))
+	require.Equal(t, 0, indexer.index(1, companyFile(t, "company2", 1)))
+	require.Equal(t, 1, indexer.index(2, companyFile(t, "company1", 2)))
+	require.Equal(t, 3, newFileIndexer(nil).index(3, merged[0]))
+}
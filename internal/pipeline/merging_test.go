@@ -27,11 +27,14 @@ import (
 
 	"github.com/moov-io/ach"
 	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/loadtest"
 	"github.com/moov-io/achgateway/internal/service"
 	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/achgateway/internal/upload"
 	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base"
 	"github.com/moov-io/base/log"
+	"github.com/moov-io/cryptfs"
 
 	"github.com/stretchr/testify/require"
 )
@@ -132,3 +135,386 @@ func TestMerging__writeACHFile(t *testing.T) {
 	require.Equal(t, "ABCDEFGHIJ", merged[0].Header.ImmediateOrigin)
 	require.Equal(t, "123456780", merged[0].Header.ImmediateDestination)
 }
+
+func TestMerging__Incremental(t *testing.T) {
+	shard := service.Shard{
+		Name:        "testing",
+		UploadAgent: "mock",
+		Mergable: service.MergableConfig{
+			Incremental: &service.IncrementalMerging{},
+		},
+	}
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	cfg.Merging.Storage.Filesystem.Directory = t.TempDir()
+
+	merger, err := NewMerging(log.NewNopLogger(), nil, shard, cfg)
+	require.NoError(t, err)
+	m, ok := merger.(*filesystemMerging)
+	require.True(t, ok)
+	require.NotNil(t, m.incremental)
+
+	trace := 0
+	newXfer := func(t *testing.T) incoming.ACHFile {
+		t.Helper()
+
+		file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+		require.NoError(t, err)
+		for _, entry := range file.Batches[0].GetEntries() {
+			trace++
+			entry.TraceNumber = fmt.Sprintf("%s%07d", file.Batches[0].GetHeader().ODFIIdentification, trace)
+		}
+		return incoming.ACHFile{
+			FileID:   base.ID(),
+			ShardKey: "testing",
+			File:     file,
+		}
+	}
+
+	first, second, canceled := newXfer(t), newXfer(t), newXfer(t)
+	require.NoError(t, m.HandleXfer(first))
+	require.NoError(t, m.HandleXfer(second))
+	require.NoError(t, m.HandleXfer(canceled))
+	require.NoError(t, m.HandleCancel(incoming.CancelACHFile{FileID: canceled.FileID, ShardKey: "testing"}))
+	require.Len(t, m.incremental.files, 2)
+
+	// A pending file which isn't in memory (e.g. written before a restart) is read at cutoff
+	restarted := newXfer(t)
+	require.NoError(t, m.writeACHFile(restarted))
+
+	var uploaded []*ach.File
+	processed, err := m.WithEachMerged(func(_ int, _ upload.Agent, file *ach.File) error {
+		uploaded = append(uploaded, file)
+		return nil
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{first.FileID, second.FileID, restarted.FileID}, processed.fileIDs)
+
+	require.Len(t, uploaded, 1)
+	entries := 0
+	for _, batch := range uploaded[0].Batches {
+		entries += len(batch.GetEntries())
+	}
+	require.Equal(t, 3*len(first.File.Batches[0].GetEntries()), entries)
+
+	// Files accepted after the cutoff start over
+	require.Empty(t, m.incremental.files)
+	require.NoError(t, m.HandleXfer(newXfer(t)))
+	require.Len(t, m.incremental.files, 1)
+}
+
+func TestMerging__IncrementalOutOfSync(t *testing.T) {
+	im := newIncrementalMerge(nil)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	require.NoError(t, im.add("foo", file))
+
+	premergedIDs, premerged := im.take()
+	require.Equal(t, map[string]bool{"foo": true}, premergedIDs)
+	require.Len(t, premerged, 1)
+
+	fs, err := storage.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+	m := &filesystemMerging{
+		logger:  log.NewNopLogger(),
+		shard:   service.Shard{Name: "testing"},
+		storage: fs,
+	}
+
+	// foo.ach is no longer in storage so all matches are merged again
+	files, _, el := m.mergeMatches(m.logger, nil, premergedIDs, premerged)
+	require.True(t, el.Empty())
+	require.Empty(t, files)
+}
+
+func BenchmarkMerging(b *testing.B) {
+	for _, tc := range []struct {
+		name     string
+		mergable service.MergableConfig
+	}{
+		{name: "storage"},
+		{name: "incremental", mergable: service.MergableConfig{Incremental: &service.IncrementalMerging{}}},
+		{name: "parsed-cache", mergable: service.MergableConfig{ParsedCache: &service.ParsedFileCache{}}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			benchmarkMerging(b, tc.mergable, 50, 100)
+		})
+	}
+}
+
+// benchmarkMerging accepts pending files with the given number of entries each and then merges them as a cutoff would.
+func benchmarkMerging(b *testing.B, mergable service.MergableConfig, files, entries int) {
+	b.Helper()
+
+	shard := service.Shard{
+		Name:        "testing",
+		UploadAgent: "mock",
+		Mergable:    mergable,
+	}
+	gen := loadtest.NewGenerator(entries)
+	dir := b.TempDir()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		// Cutoffs isolate pending files into a directory named by the second, so each run gets its own storage
+		cfg := service.UploadAgents{
+			Agents: []service.UploadAgent{
+				{
+					ID:   "mock",
+					Mock: &service.MockAgent{},
+				},
+			},
+		}
+		cfg.Merging.Storage.Filesystem.Directory = filepath.Join(dir, fmt.Sprintf("%d", i))
+
+		merger, err := NewMerging(log.NewNopLogger(), nil, shard, cfg)
+		require.NoError(b, err)
+
+		xfers := make([]incoming.ACHFile, files)
+		for j := range xfers {
+			file, err := gen.File()
+			require.NoError(b, err)
+			xfers[j] = incoming.ACHFile{FileID: base.ID(), ShardKey: "testing", File: file}
+		}
+		b.StartTimer()
+
+		for j := range xfers {
+			require.NoError(b, merger.HandleXfer(xfers[j]))
+		}
+		_, err = merger.WithEachMerged(func(_ int, _ upload.Agent, _ *ach.File) error {
+			return nil
+		})
+		require.NoError(b, err)
+	}
+}
+
+func TestMerging__Settlements(t *testing.T) {
+	shard := service.Shard{
+		Name:        "testing",
+		UploadAgent: "mock",
+		Settlement:  &service.SettlementConfig{},
+	}
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	cfg.Merging.Storage.Filesystem.Directory = t.TempDir()
+
+	merger, err := NewMerging(log.NewNopLogger(), nil, shard, cfg)
+	require.NoError(t, err)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	xfer := incoming.ACHFile{
+		FileID:   base.ID(),
+		ShardKey: "testing",
+		File:     file,
+	}
+	require.NoError(t, merger.HandleXfer(xfer))
+
+	processed, err := merger.WithEachMerged(func(_ int, _ upload.Agent, _ *ach.File) error {
+		return nil
+	})
+	require.NoError(t, err)
+
+	settlements := processed.settlements[xfer.FileID]
+	require.Len(t, settlements, len(file.Batches[0].GetEntries()))
+	require.Equal(t, file.Batches[0].GetEntries()[0].TraceNumber, settlements[0].TraceNumber)
+	require.NotEmpty(t, settlements[0].SettlementDate)
+}
+
+func TestMerging__Assignment(t *testing.T) {
+	shard := service.Shard{
+		Name:        "testing",
+		UploadAgent: "mock",
+		Mergable: service.MergableConfig{
+			Assignment: &service.Assignment{
+				TraceNumbers: service.AssignSequential,
+				FileIDs:      service.AssignSequential,
+			},
+		},
+	}
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	cfg.Merging.Storage.Filesystem.Directory = t.TempDir()
+
+	merger, err := NewMerging(log.NewNopLogger(), nil, shard, cfg)
+	require.NoError(t, err)
+
+	// Both files have the same trace number, which would otherwise be merged into separate files
+	for i := 0; i < 2; i++ {
+		file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+		require.NoError(t, err)
+		require.NoError(t, merger.HandleXfer(incoming.ACHFile{
+			FileID:   base.ID(),
+			ShardKey: "testing",
+			File:     file,
+		}))
+	}
+
+	var uploaded []*ach.File
+	_, err = merger.WithEachMerged(func(_ int, _ upload.Agent, file *ach.File) error {
+		uploaded = append(uploaded, file)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, uploaded, 1)
+	require.Equal(t, "A", uploaded[0].Header.FileIDModifier)
+
+	var traceNumbers []string
+	for _, batch := range uploaded[0].Batches {
+		for _, entry := range batch.GetEntries() {
+			traceNumbers = append(traceNumbers, entry.TraceNumber)
+		}
+	}
+	require.Equal(t, []string{"076401250000001", "076401250000002"}, traceNumbers)
+}
+
+type leaderOf map[string]bool
+
+func (l leaderOf) AcquireLock(key string) error {
+	if l[key] {
+		return nil
+	}
+	return fmt.Errorf("we are not the leader of %s", key)
+}
+
+func TestMerging__SingleWriter(t *testing.T) {
+	shard := service.Shard{
+		Name:        "testing",
+		UploadAgent: "mock",
+	}
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	cfg.Merging.Storage.Filesystem.Directory = t.TempDir()
+	cfg.Merging.SingleWriter = true
+
+	// Both instances share storage but only the leader merges at cutoff
+	leader, err := NewMerging(log.NewNopLogger(), leaderOf{"achgateway/outbound/testing": true}, shard, cfg)
+	require.NoError(t, err)
+	follower, err := NewMerging(log.NewNopLogger(), leaderOf{}, shard, cfg)
+	require.NoError(t, err)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	first := incoming.ACHFile{FileID: base.ID(), ShardKey: "testing", File: file}
+	second := incoming.ACHFile{FileID: base.ID(), ShardKey: "testing", File: file}
+	require.NoError(t, leader.HandleXfer(first))
+	require.NoError(t, follower.HandleXfer(second))
+
+	var uploads int
+	uploadFile := func(_ int, _ upload.Agent, _ *ach.File) error {
+		uploads++
+		return nil
+	}
+
+	processed, err := follower.WithEachMerged(uploadFile)
+	require.NoError(t, err)
+	require.Empty(t, processed.fileIDs)
+	require.Equal(t, 0, uploads)
+
+	processed, err = leader.WithEachMerged(uploadFile)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{first.FileID, second.FileID}, processed.fileIDs)
+	require.NotZero(t, uploads)
+}
+
+func TestMerging__ReencryptDuringCutoff(t *testing.T) {
+	shard := service.Shard{
+		Name:        "testing",
+		UploadAgent: "mock",
+	}
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	cfg.Merging.Storage.Filesystem.Directory = t.TempDir()
+
+	merger, err := NewMerging(log.NewNopLogger(), nil, shard, cfg)
+	require.NoError(t, err)
+	mm, ok := merger.(*filesystemMerging)
+	require.True(t, ok)
+
+	newKey := func(id, key string) storage.Key {
+		aes, err := cryptfs.NewAESCryptor([]byte(key))
+		require.NoError(t, err)
+		crypt, err := cryptfs.New(aes)
+		require.NoError(t, err)
+		return storage.Key{ID: id, Crypt: crypt}
+	}
+	first, second := newKey("first", "1111111111111111"), newKey("second", "2222222222222222")
+
+	// Write the pending file with the first key, then rotate to the second
+	underlying := mm.storage
+	mm.storage = storage.NewEncryptedWithKeys(underlying, first, nil)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	require.NoError(t, merger.HandleXfer(incoming.ACHFile{
+		FileID:   base.ID(),
+		ShardKey: "testing",
+		File:     file,
+	}))
+	mm.storage = storage.NewEncryptedWithKeys(underlying, second, []storage.Key{first})
+
+	// Hold the cutoff open while its files are uploaded
+	uploading, release := make(chan struct{}), make(chan struct{})
+	cutoffErr := make(chan error, 1)
+	go func() {
+		_, err := merger.WithEachMerged(func(_ int, _ upload.Agent, _ *ach.File) error {
+			close(uploading)
+			<-release
+			return nil
+		})
+		cutoffErr <- err
+	}()
+	select {
+	case <-uploading:
+	case err := <-cutoffErr:
+		t.Fatalf("cutoff finished before uploading: %v", err)
+	}
+
+	_, err = mm.reencryptFiles()
+	require.ErrorIs(t, err, errCutoffRunning)
+
+	close(release)
+	require.NoError(t, <-cutoffErr)
+
+	// Once the cutoff is done the isolated files are rewritten but none return to mergable/
+	n, err := mm.reencryptFiles()
+	require.NoError(t, err)
+	require.NotZero(t, n)
+
+	matches, err := mm.storage.Glob(filepath.Join("mergable", "testing", "*.ach"))
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
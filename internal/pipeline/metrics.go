@@ -49,15 +49,89 @@ var (
 		Name: "files_missing_shard_aggregators",
 		Help: "Counter of ACH files unable to be matched with a shard aggregator",
 	}, []string{"shard"})
+	rejectedFiles = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "rejected_files",
+		Help: "Counter of submitted ACH files rejected before merging",
+	}, []string{"shard", "code"})
+	lateSubmissions = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "late_submissions",
+		Help: "Counter of files submitted while their shard's cutoff was merging",
+	}, []string{"shard", "policy"})
 
 	uploadedFilesCounter = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
 		Name: "ach_uploaded_files",
 		Help: "Counter of ACH files uploaded through the pipeline to the ODFI",
 	}, []string{"shard"})
+	heldFiles = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "ach_held_files",
+		Help: "Counter of merged ACH files held for approval by guardrails",
+	}, []string{"shard"})
+
+	flaggedFiles = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "ach_flagged_files",
+		Help: "Counter of merged ACH files uploaded with guardrail warnings",
+	}, []string{"shard"})
+
+	nonconformingFiles = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "ach_nonconforming_files",
+		Help: "Counter of merged ACH files not uploaded for violating their ODFI's conformance profile",
+	}, []string{"shard", "profile"})
+
+	cutoffQueueDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Name:    "cutoff_queue_duration_seconds",
+		Help:    "Seconds a shard waited for a free slot before processing its cutoff",
+		Buckets: []float64{0.1, 1, 5, 15, 30, 60, 120, 300},
+	}, []string{"shard"})
+
+	deferredCutoffs = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "cutoffs_deferred",
+		Help: "Counter of cutoffs deferred because the upload agent was in a maintenance window",
+	}, []string{"shard"})
+
+	duplicateUploads = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "duplicate_uploads",
+		Help: "Counter of merged ACH files not uploaded because the upload ledger already recorded them",
+	}, []string{"shard", "status"})
+
+	earlyStartedCutoffs = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "cutoffs_started_early",
+		Help: "Counter of cutoffs started ahead of their window because they were predicted to miss the deadline",
+	}, []string{"shard"})
+
+	takenOverCutoffs = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "cutoffs_taken_over",
+		Help: "Counter of cutoffs finished after the instance processing them was lost",
+	}, []string{"shard"})
+
+	incrementalMergeFallbacks = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "incremental_merge_fallbacks",
+		Help: "Counter of cutoffs which merged every pending file because the incremental merge was out of sync",
+	}, []string{"shard"})
+
+	parsedFileCacheHits = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "parsed_file_cache_hits",
+		Help: "Counter of pending ACH files merged without reading and parsing them from storage",
+	}, []string{"shard"})
+
+	parsedFileCacheMisses = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "parsed_file_cache_misses",
+		Help: "Counter of pending ACH files read and parsed from storage while the parsed file cache was enabled",
+	}, []string{"shard"})
+
+	screenedFiles = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "ach_screened_files",
+		Help: "Counter of ACH files screened before merging",
+	}, []string{"shard", "outcome"})
+
 	uploadFilesErrors = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
 		Name: "ach_upload_errors",
 		Help: "Counter of errors encountered when attempting ACH files upload",
 	}, []string{"shard"})
+
+	uploadManifestErrors = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "ach_upload_manifest_errors",
+		Help: "Counter of uploaded ACH files whose manifest couldn't be uploaded",
+	}, []string{"shard"})
 )
 
 func init() {
@@ -21,10 +21,12 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -170,6 +172,85 @@ func (fr *FileReceiver) getShardFile() http.HandlerFunc {
 	}
 }
 
+type reencryptResponse struct {
+	Rewritten      int    `json:"rewritten"`
+	Error          string `json:"error,omitempty"`
+	SourceHostname string
+}
+
+func (fr *FileReceiver) reencryptShardFiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		logger := fr.logger.With(log.Fields{
+			"route": log.String("reencrypt_files"),
+		})
+
+		agg := fr.lookupAggregator(logger, r)
+		if agg == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mm, ok := agg.merger.(*filesystemMerging)
+		if !ok {
+			logger.Warn().Logf("storage not found for shard %s", agg.shard.Name)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		n, err := mm.reencryptFiles()
+		resp := reencryptResponse{Rewritten: n}
+		resp.SourceHostname, _ = os.Hostname()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if errors.Is(err, errCutoffRunning) {
+			logger.Warn().Logf("not re-encrypting %s files: %v", agg.shard.Name, err)
+			resp.Error = err.Error()
+			w.WriteHeader(http.StatusConflict)
+		} else if err != nil {
+			logger.Error().LogErrorf("problem re-encrypting %s files: %v", agg.shard.Name, err)
+			resp.Error = err.Error()
+			w.WriteHeader(http.StatusInternalServerError)
+		} else {
+			logger.Info().Logf("re-encrypted %d files for %s", n, agg.shard.Name)
+			w.WriteHeader(http.StatusOK)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+type recoveryResponse struct {
+	Shards         []recoveryStatus `json:"shards"`
+	SourceHostname string
+}
+
+func (fr *FileReceiver) listRecoveryStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var resp recoveryResponse
+		for _, agg := range fr.shardAggregators {
+			if mm, ok := agg.merger.(*filesystemMerging); ok && mm.recovery != nil {
+				resp.Shards = append(resp.Shards, mm.recovery.Status())
+			}
+		}
+		sort.Slice(resp.Shards, func(i, j int) bool {
+			return resp.Shards[i].Shard < resp.Shards[j].Shard
+		})
+		resp.SourceHostname, _ = os.Hostname()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
 func marshalFile(contents storage.File) (string, error) {
 	file, err := ach.NewReader(contents).Read()
 
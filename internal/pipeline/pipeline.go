@@ -21,10 +21,17 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/moov-io/achgateway/internal/consul"
+	"github.com/moov-io/achgateway/internal/approvals"
+	"github.com/moov-io/achgateway/internal/drain"
 	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/exposure"
+	"github.com/moov-io/achgateway/internal/leadership"
+	"github.com/moov-io/achgateway/internal/lineage"
+	"github.com/moov-io/achgateway/internal/rejections"
 	"github.com/moov-io/achgateway/internal/service"
 	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/achgateway/internal/submissions"
+	"github.com/moov-io/achgateway/internal/uploadledger"
 	"github.com/moov-io/achgateway/pkg/models"
 	"github.com/moov-io/base/log"
 
@@ -35,22 +42,43 @@ func Start(
 	ctx context.Context,
 	logger log.Logger,
 	cfg *service.Config,
-	consul *consul.Client,
+	elector leadership.Elector,
+	drainer *drain.Coordinator,
 	shardRepository shards.Repository,
+	uploads uploadledger.Repository,
+	sequencer events.Sequencer,
+	eventEmitter events.Emitter,
+	lineageRepo lineage.Repository,
+	exposureRepo exposure.Repository,
+	submissionRepo submissions.Repository,
+	rejectionRepo rejections.Repository,
 	httpFiles, streamFiles *pubsub.Subscription) (*FileReceiver, error) {
 
-	eventEmitter, err := events.NewEmitter(logger, cfg.Events)
-	if err != nil {
-		return nil, fmt.Errorf("pipeline: error creating event emitter: %v", err)
+	// The emitter is shared with other senders of events, so it's closed by the caller
+	if eventEmitter == nil {
+		eventEmitter = &events.MockEmitter{}
+	}
+	if cfg.Events != nil && cfg.Events.Sequence {
+		eventEmitter = events.Sequenced(eventEmitter, sequencer)
 	}
 
 	// register each shard's aggregator
+	limiter := newCutoffLimiter(cfg.Sharding.MaxConcurrentCutoffs)
 	shardAggregators := make(map[string]*aggregator)
 	for i := range cfg.Sharding.Shards {
-		xfagg, err := newAggregator(logger, consul, eventEmitter, cfg.Sharding.Shards[i], cfg.Upload, cfg.Errors)
+		xfagg, err := newAggregator(logger, elector, eventEmitter, cfg.Sharding.Shards[i], cfg.Upload, cfg.Errors)
 		if err != nil {
 			return nil, fmt.Errorf("problem starting shard=%s: %v", cfg.Sharding.Shards[i].Name, err)
 		}
+		xfagg.cutoffLimit = limiter
+		xfagg.drain = drainer
+		xfagg.uploads = uploads
+		xfagg.lineage = lineageRepo
+		xfagg.exposure = exposureRepo
+		xfagg.submissions = submissionRepo
+		if mm, ok := xfagg.merger.(*filesystemMerging); ok {
+			mm.assigner = lineage.NewAssigner(cfg.Sharding.Shards[i].Name, cfg.Sharding.Shards[i].Mergable.Assignment, lineageRepo)
+		}
 
 		go xfagg.Start(ctx)
 
@@ -62,7 +90,16 @@ func Start(
 	if cfg.Inbound.Kafka != nil && cfg.Inbound.Kafka.Transform != nil {
 		transformConfig = cfg.Inbound.Kafka.Transform
 	}
-	receiver := newFileReceiver(logger, cfg.Sharding.Default, shardRepository, shardAggregators, httpFiles, streamFiles, transformConfig)
+	approvalService, err := approvals.New(logger, cfg.Admin.Approvals)
+	if err != nil {
+		return nil, fmt.Errorf("pipeline: error setting up approvals: %v", err)
+	}
+
+	receiver := newFileReceiver(logger, cfg.Sharding.Default, shardRepository, shardAggregators, httpFiles, streamFiles, transformConfig, approvalService, eventEmitter)
+	receiver.drain = drainer
+	receiver.entryEvents = cfg.Events != nil && cfg.Events.EntryAccepted
+	receiver.submissions = submissionRepo
+	receiver.rejections = rejectionRepo
 	go receiver.Start(ctx)
 
 	return receiver, nil
@@ -0,0 +1,198 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/addenda"
+	"github.com/moov-io/achgateway/internal/notify"
+	"github.com/moov-io/achgateway/internal/rejections"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+)
+
+// reject sends a FileRejected event for a submitted file which won't be uploaded. agg is the
+// shard the file was submitted for, which is notified when it has NotifyRejections set. ACH files
+// are kept for review when there's a Database.
+func (fr *FileReceiver) reject(fileID, shardKey string, metadata map[string]string, file *ach.File, agg *aggregator, reasons ...models.RejectionReason) {
+	logger := fr.logger.With(log.Fields{
+		"fileID":   log.String(fileID),
+		"shardKey": log.String(shardKey),
+	})
+
+	shardName := ""
+	if agg != nil {
+		shardName = agg.shard.Name
+		if agg.shard.NotifyRejections {
+			agg.notifyRejectedFile(fileID, reasons)
+		}
+	}
+	rejectedFiles.With("shard", shardName, "code", reasons[0].Code).Add(1)
+
+	rejectionID := ""
+	if file != nil && fr.rejections != nil {
+		rej, err := rejections.New(fileID, shardKey, shardName, file, metadata, reasons)
+		if err == nil {
+			err = fr.rejections.Save(rej)
+		}
+		if err != nil {
+			logger.Error().LogErrorf("problem keeping rejected file: %v", err)
+		} else {
+			rejectionID = rej.ID
+		}
+	}
+
+	if fr.eventEmitter == nil {
+		return
+	}
+	err := fr.eventEmitter.Send(models.Event{
+		Event: models.FileRejected{
+			FileID:      fileID,
+			ShardKey:    shardKey,
+			Reasons:     reasons,
+			RejectedAt:  time.Now(),
+			RejectionID: rejectionID,
+			Metadata:    metadata,
+		},
+	})
+	if err != nil {
+		logger.Error().LogErrorf("problem sending FileRejected event: %v", err)
+	}
+}
+
+func (xfagg *aggregator) notifyRejectedFile(fileID string, reasons []models.RejectionReason) {
+	logger := xfagg.logger.With(log.Fields{
+		"shard":  log.String(xfagg.shard.Name),
+		"fileID": log.String(fileID),
+	})
+
+	uploadAgent := xfagg.uploadAgents.Find(xfagg.shard.UploadAgent)
+	if uploadAgent == nil {
+		logger.Warn().Log("skipping rejected file notification without an upload agent")
+		return
+	}
+	notifier, err := notify.NewMultiSender(logger, xfagg.shard.Notifications, uploadAgent.Notifications)
+	if err != nil {
+		logger.Error().LogErrorf("ERROR creating rejected file notifier: %v", err)
+		return
+	}
+	err = notifier.Info(&notify.Message{
+		Contents: fmt.Sprintf("file %s for shard %s was rejected: %s", fileID, xfagg.shard.Name, reasons[0].Message),
+	})
+	if err != nil {
+		logger.Error().LogErrorf("ERROR sending rejected file notification: %v", err)
+	}
+}
+
+func rejectionReason(code string, err error) models.RejectionReason {
+	return models.RejectionReason{
+		Code:    code,
+		Message: err.Error(),
+	}
+}
+
+// addendaReasons checks file against the addenda formats of the shard's conformance profile, so
+// files which would be held back at the cutoff are rejected when they're submitted.
+func (xfagg *aggregator) addendaReasons(file *ach.File) []models.RejectionReason {
+	profile := xfagg.uploadAgents.ConformanceProfile(xfagg.shard.UploadAgent)
+	if profile == nil {
+		return nil
+	}
+	var out []models.RejectionReason
+	for _, problem := range addenda.Check(file, profile.AddendaFormats) {
+		reason := rejectionReason(models.RejectionInvalidAddenda, fmt.Errorf("%s addenda: %v", problem.Format, problem.Err))
+		reason.Record = "Addenda05"
+		reason.BatchNumber = problem.BatchNumber
+		reason.TraceNumber = problem.TraceNumber
+		out = append(out, reason)
+	}
+	return out
+}
+
+// validationReasons locates the records of file which failed validation. Problems found only
+// by validating the whole file, like mismatched control totals, are reported from err.
+func validationReasons(file *ach.File, err error) []models.RejectionReason {
+	var out []models.RejectionReason
+	add := func(record string, batchNumber int, traceNumber string, err error) {
+		reason := rejectionReason(models.RejectionInvalidFile, err)
+		reason.Record = record
+		reason.BatchNumber = batchNumber
+		reason.TraceNumber = traceNumber
+		reason.Field, reason.Value = errorField(err)
+		out = append(out, reason)
+	}
+
+	if err := file.Header.ValidateWith(file.GetValidation()); err != nil {
+		add("FileHeader", 0, "", err)
+	}
+	for _, batch := range file.Batches {
+		bh := batch.GetHeader()
+		found := len(out)
+		if err := bh.Validate(); err != nil {
+			add("BatchHeader", bh.BatchNumber, "", err)
+		}
+		for _, entry := range batch.GetEntries() {
+			if err := entry.Validate(); err != nil {
+				add("EntryDetail", bh.BatchNumber, entry.TraceNumber, err)
+			}
+			for _, addenda := range entry.Addenda05 {
+				if err := addenda.Validate(); err != nil {
+					add("Addenda05", bh.BatchNumber, entry.TraceNumber, err)
+				}
+			}
+		}
+		// Otherwise the batch's control record or entry order is invalid
+		if found == len(out) {
+			if err := batch.Validate(); err != nil {
+				add("Batch", bh.BatchNumber, "", err)
+			}
+		}
+	}
+	if len(out) == 0 {
+		add("File", 0, "", err)
+	}
+	return out
+}
+
+// errorField returns the field named by a moov-io/ach validation error
+func errorField(err error) (string, string) {
+	var fieldErr *ach.FieldError
+	if errors.As(err, &fieldErr) {
+		return fieldErr.FieldName, value(fieldErr.Value)
+	}
+	var batchErr *ach.BatchError
+	if errors.As(err, &batchErr) {
+		return batchErr.FieldName, value(batchErr.FieldValue)
+	}
+	var fileErr ach.FileError
+	if errors.As(err, &fileErr) {
+		return fileErr.FieldName, fileErr.Value
+	}
+	return "", ""
+}
+
+func value(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
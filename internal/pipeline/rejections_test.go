@@ -0,0 +1,192 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/addenda"
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/incoming/entries"
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/shards"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+type rejectionEmitter struct {
+	events.MockEmitter
+	rejected []models.FileRejected
+}
+
+func (e *rejectionEmitter) Send(evt models.Event) error {
+	if rejected, ok := evt.Event.(models.FileRejected); ok {
+		e.rejected = append(e.rejected, rejected)
+	}
+	return nil
+}
+
+func rejectionsFileReceiver(t *testing.T, emitter events.Emitter) *FileReceiver {
+	t.Helper()
+	return rejectionsFileReceiverWith(t, emitter, service.UploadAgents{}, "")
+}
+
+func rejectionsFileReceiverWith(t *testing.T, emitter events.Emitter, uploadAgents service.UploadAgents, uploadAgent string) *FileReceiver {
+	t.Helper()
+
+	shardRepo := shards.NewMockRepository()
+	shardRepo.Shards["testing"] = service.ShardMapping{ShardKey: "testing", ShardName: "testing"}
+
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, emitter, service.Shard{
+		Name: "testing",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/New_York",
+			Windows:  []string{"17:00"},
+		},
+		UploadAgent: uploadAgent,
+	}, uploadAgents, service.ErrorAlerting{})
+	require.NoError(t, err)
+
+	_, httpFiles := streamtest.InmemStream(t)
+	_, streamFiles := streamtest.InmemStream(t)
+	shardAggregators := map[string]*aggregator{"testing": xfagg}
+
+	return newFileReceiver(log.NewNopLogger(), "", shardRepo, shardAggregators, httpFiles, streamFiles, &models.TransformConfig{}, nil, emitter)
+}
+
+func TestFileReceiver__RejectUnknownShard(t *testing.T) {
+	emitter := &rejectionEmitter{}
+	fr := rejectionsFileReceiver(t, emitter)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	err = fr.processACHFile(incoming.ACHFile{
+		FileID:   "file1",
+		ShardKey: "other",
+		File:     file,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, emitter.rejected, 1)
+	rejected := emitter.rejected[0]
+	require.Equal(t, "file1", rejected.FileID)
+	require.Equal(t, "other", rejected.ShardKey)
+	require.Len(t, rejected.Reasons, 1)
+	require.Equal(t, models.RejectionUnknownShard, rejected.Reasons[0].Code)
+	require.False(t, rejected.RejectedAt.IsZero())
+}
+
+func TestFileReceiver__RejectInvalidFile(t *testing.T) {
+	emitter := &rejectionEmitter{}
+	fr := rejectionsFileReceiver(t, emitter)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	entry := file.Batches[0].GetEntries()[0]
+	entry.DFIAccountNumber = ""
+
+	err = fr.processACHFile(incoming.ACHFile{
+		FileID:   "file1",
+		ShardKey: "testing",
+		File:     file,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, emitter.rejected, 1)
+	reasons := emitter.rejected[0].Reasons
+	require.Len(t, reasons, 1)
+
+	require.Equal(t, models.RejectionInvalidFile, reasons[0].Code)
+	require.Equal(t, "EntryDetail", reasons[0].Record)
+	require.Equal(t, 1, reasons[0].BatchNumber)
+	require.Equal(t, entry.TraceNumber, reasons[0].TraceNumber)
+	require.Equal(t, "DFIAccountNumber", reasons[0].Field)
+}
+
+func TestFileReceiver__RejectInvalidAddenda(t *testing.T) {
+	emitter := &rejectionEmitter{}
+	fr := rejectionsFileReceiverWith(t, emitter, service.UploadAgents{
+		Agents: []service.UploadAgent{{ID: "bank", ConformanceProfile: "bank"}},
+		ConformanceProfiles: []service.ConformanceProfile{
+			{Name: "bank", AddendaFormats: []string{"DED", "TXP"}},
+		},
+	}, "bank")
+
+	defaults := &service.FileDefaults{
+		ImmediateOrigin:       "121042882",
+		ImmediateDestination:  "231380104",
+		CompanyName:           "Acme Payroll",
+		CompanyIdentification: "1234567890",
+	}
+	child := entries.Entry{
+		Name:          "State Disbursement Unit",
+		RoutingNumber: "231380104",
+		AccountNumber: "12345678",
+		Amount:        25000,
+		Type:          "credit",
+		SECCode:       "CCD",
+		Addenda:       addenda.ChildSupport{CaseID: "A123456", PayDate: "221014", Amount: 25000, SSN: "123456789", Name: "SMITH"}.String(),
+	}
+	tax := child
+	tax.Amount = 100000
+	tax.Addenda = `TXP*123456789*94105*221231*T*1000.00\`
+
+	file, err := entries.Build(defaults, &entries.Submission{Entries: []entries.Entry{child, tax}}, time.Now())
+	require.NoError(t, err)
+
+	err = fr.processACHFile(incoming.ACHFile{
+		FileID:   "file1",
+		ShardKey: "testing",
+		File:     file,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, emitter.rejected, 1)
+	reasons := emitter.rejected[0].Reasons
+	require.Len(t, reasons, 1)
+
+	require.Equal(t, models.RejectionInvalidAddenda, reasons[0].Code)
+	require.Equal(t, "Addenda05", reasons[0].Record)
+	require.Equal(t, 1, reasons[0].BatchNumber)
+	require.Equal(t, file.Batches[0].GetEntries()[1].TraceNumber, reasons[0].TraceNumber)
+	require.Contains(t, reasons[0].Message, `TXP addenda: amount "1000.00" must be 1 to 10 digits`)
+}
+
+func TestValidationReasons__Batch(t *testing.T) {
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	file.Batches[0].GetControl().TotalDebitEntryDollarAmount += 1
+	err = file.Validate()
+	require.Error(t, err)
+
+	reasons := validationReasons(file, err)
+	require.Len(t, reasons, 1)
+	require.Equal(t, "Batch", reasons[0].Record)
+	require.Equal(t, 1, reasons[0].BatchNumber)
+	require.Equal(t, "TotalDebitEntryDollarAmount", reasons[0].Field)
+}
@@ -0,0 +1,426 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/guardrails"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/mask"
+	"github.com/moov-io/achgateway/internal/rejections"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/log"
+)
+
+// maxReviewRejections is how many rejected files are listed for review at once
+const maxReviewRejections = 100
+
+type heldFileResponse struct {
+	Name       string               `json:"name"`
+	Reasons    []string             `json:"reasons"`
+	EntryCount int                  `json:"entryCount"`
+	Batches    []models.BatchTotals `json:"batches,omitempty"`
+
+	// Contents is the file in Nacha format with personal data masked
+	Contents string `json:"contents,omitempty"`
+}
+
+type listHeldFilesResponse struct {
+	Files          []heldFileResponse `json:"files"`
+	SourceHostname string             `json:"sourceHostname"`
+}
+
+func newHeldFileResponse(held guardrails.HeldFile) heldFileResponse {
+	resp := heldFileResponse{
+		Name:    held.Name(),
+		Reasons: held.Reasons,
+	}
+	resp.EntryCount, resp.Batches = models.TotalBatches(held.File)
+	return resp
+}
+
+type discardBody struct {
+	Reason string `json:"reason"`
+}
+
+// readDiscardBody returns the reason an operator gave for discarding a file
+func readDiscardBody(r *http.Request) (string, error) {
+	if r.Method != http.MethodPost {
+		return "", errors.New("files are discarded with POST")
+	}
+	var body discardBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return "", errors.New("reading request: missing reason")
+	}
+	if strings.TrimSpace(body.Reason) == "" {
+		return "", errors.New("missing reason")
+	}
+	return strings.TrimSpace(body.Reason), nil
+}
+
+// listHeldFiles returns the files the shard's guardrails are holding for approval
+func (fr *FileReceiver) listHeldFiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := fr.logger.With(log.Fields{
+			"route": log.String("list_held_files"),
+		})
+
+		agg := fr.lookupAggregator(logger, r)
+		if agg == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		held, err := agg.guardrails.HeldFiles()
+		if err != nil {
+			logger.Error().LogErrorf("problem listing %s held files: %v", agg.shard.Name, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		resp := listHeldFilesResponse{Files: []heldFileResponse{}}
+		for i := range held {
+			resp.Files = append(resp.Files, newHeldFileResponse(held[i]))
+		}
+		resp.SourceHostname, _ = os.Hostname()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// findHeldFile returns the held file named in the request, or nil
+func (fr *FileReceiver) findHeldFile(logger log.Logger, agg *aggregator, r *http.Request) (*guardrails.HeldFile, error) {
+	held, err := agg.guardrails.HeldFiles()
+	if err != nil {
+		return nil, err
+	}
+	name := mux.Vars(r)["name"]
+	for i := range held {
+		if held[i].Name() == name {
+			return &held[i], nil
+		}
+	}
+	logger.Warn().Logf("held file %s not found", name)
+	return nil, nil
+}
+
+// getHeldFile returns a held file with its personal data masked
+func (fr *FileReceiver) getHeldFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := fr.logger.With(log.Fields{
+			"route": log.String("get_held_file"),
+		})
+
+		agg := fr.lookupAggregator(logger, r)
+		if agg == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		held, err := fr.findHeldFile(logger, agg, r)
+		if err != nil {
+			logger.Error().LogErrorf("problem reading %s held files: %v", agg.shard.Name, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if held == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := ach.NewWriter(&buf).Write(held.File); err != nil {
+			logger.Error().LogErrorf("problem writing held file %s: %v", held.Name(), err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		resp := newHeldFileResponse(*held)
+		resp.Contents = string(mask.Nacha(buf.Bytes()))
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// discardHeldFile removes a held file so it's never uploaded, recording the operator's reason
+func (fr *FileReceiver) discardHeldFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := fr.logger.With(log.Fields{
+			"route": log.String("discard_held_file"),
+		})
+
+		reason, err := readDiscardBody(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		agg := fr.lookupAggregator(logger, r)
+		if agg == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		held, err := fr.findHeldFile(logger, agg, r)
+		if err != nil {
+			logger.Error().LogErrorf("problem reading %s held files: %v", agg.shard.Name, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if held == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		discard := func() error {
+			return agg.guardrails.Discard(*held, reason)
+		}
+		desc := fmt.Sprintf("discard held file %s from shard %s: %s", held.Name(), agg.shard.Name, reason)
+		if fr.RequestApproval(w, r, "discard-held-file", desc, discard) {
+			return
+		}
+		if err := discard(); err != nil {
+			logger.Error().LogErrorf("problem discarding held file %s: %v", held.Name(), err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+type listRejectionsResponse struct {
+	Rejections     []*rejections.Rejection `json:"rejections"`
+	SourceHostname string                  `json:"sourceHostname"`
+}
+
+type getRejectionResponse struct {
+	*rejections.Rejection
+
+	// Contents is the file in Nacha format with personal data masked
+	Contents string `json:"contents,omitempty"`
+}
+
+// listRejections returns the rejected files waiting for review
+func (fr *FileReceiver) listRejections() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := fr.logger.With(log.Fields{
+			"route": log.String("list_rejections"),
+		})
+		if fr.rejections == nil {
+			logger.Warn().Log("rejected files require a database")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		found, err := fr.rejections.List(maxReviewRejections)
+		if err != nil {
+			logger.Error().LogErrorf("problem listing rejected files: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		resp := listRejectionsResponse{Rejections: append([]*rejections.Rejection{}, found...)}
+		resp.SourceHostname, _ = os.Hostname()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// lookupRejection returns the rejection named in the request, writing the response when there
+// isn't one
+func (fr *FileReceiver) lookupRejection(logger log.Logger, w http.ResponseWriter, r *http.Request) *rejections.Rejection {
+	if fr.rejections == nil {
+		logger.Warn().Log("rejected files require a database")
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	id := mux.Vars(r)["rejectionID"]
+	rej, err := fr.rejections.Get(id)
+	if err != nil {
+		logger.Error().LogErrorf("problem reading rejection %s: %v", id, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil
+	}
+	if rej == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+	return rej
+}
+
+// getRejection returns a rejected file and why it was rejected, with its personal data masked
+func (fr *FileReceiver) getRejection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := fr.logger.With(log.Fields{
+			"route": log.String("get_rejection"),
+		})
+
+		rej := fr.lookupRejection(logger, w, r)
+		if rej == nil {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(getRejectionResponse{
+			Rejection: rej,
+			Contents:  string(mask.Nacha(rej.Contents)),
+		})
+	}
+}
+
+// resubmitRejection submits a rejected file again. A request body replaces the file with edited
+// Nacha contents. Files which are rejected again are kept as a new rejection.
+func (fr *FileReceiver) resubmitRejection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		logger := fr.logger.With(log.Fields{
+			"route": log.String("resubmit_rejection"),
+		})
+
+		rej := fr.lookupRejection(logger, w, r)
+		if rej == nil {
+			return
+		}
+		if rej.Status != rejections.StatusRejected {
+			http.Error(w, "rejection was already "+rej.Status, http.StatusConflict)
+			return
+		}
+
+		contents, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(bytes.TrimSpace(contents)) == 0 {
+			contents = rej.Contents
+		}
+		file, err := rej.File(contents)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resubmit := func() error {
+			return fr.resubmit(logger, rej, file)
+		}
+		desc := fmt.Sprintf("resubmit file %s from rejection %s", rej.FileID, rej.ID)
+		if fr.RequestApproval(w, r, "resubmit-rejection", desc, resubmit) {
+			return
+		}
+		if err := resubmit(); err != nil {
+			if errors.Is(err, errRejectionResolved) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			logger.Error().LogErrorf("problem resubmitting rejection %s: %v", rej.ID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// errRejectionResolved is returned when a rejection was resubmitted or discarded by someone else first
+var errRejectionResolved = errors.New("rejection was already resolved")
+
+// resubmit processes file in place of the rejected file, keeping the rejection when that fails
+func (fr *FileReceiver) resubmit(logger log.Logger, rej *rejections.Rejection, file *ach.File) error {
+	taken, err := fr.rejections.Resubmit(rej.ID)
+	if err != nil {
+		return err
+	}
+	if !taken {
+		return errRejectionResolved
+	}
+
+	fr.freeze.RLock()
+	err = fr.processACHFile(incoming.ACHFile{
+		FileID:   rej.FileID,
+		ShardKey: rej.ShardKey,
+		File:     file,
+		Metadata: rej.Metadata,
+	})
+	fr.freeze.RUnlock()
+	if err != nil {
+		if err := fr.rejections.Release(rej.ID, rej.Contents); err != nil {
+			logger.Error().LogErrorf("problem releasing rejection %s: %v", rej.ID, err)
+		}
+		return err
+	}
+	logger.Info().Logf("resubmitted file %s from rejection %s", rej.FileID, rej.ID)
+	return nil
+}
+
+// discardRejection removes a rejected file's contents, recording the operator's reason
+func (fr *FileReceiver) discardRejection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := fr.logger.With(log.Fields{
+			"route": log.String("discard_rejection"),
+		})
+
+		reason, err := readDiscardBody(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rej := fr.lookupRejection(logger, w, r)
+		if rej == nil {
+			return
+		}
+		if rej.Status != rejections.StatusRejected {
+			http.Error(w, "rejection was already "+rej.Status, http.StatusConflict)
+			return
+		}
+
+		discard := func() error {
+			discarded, err := fr.rejections.Discard(rej.ID, reason)
+			if err != nil {
+				return err
+			}
+			if !discarded {
+				return errRejectionResolved
+			}
+			logger.Info().Logf("discarded rejection %s: %s", rej.ID, reason)
+			return nil
+		}
+		desc := fmt.Sprintf("discard file %s from rejection %s: %s", rej.FileID, rej.ID, reason)
+		if fr.RequestApproval(w, r, "discard-rejection", desc, discard) {
+			return
+		}
+		if err := discard(); err != nil {
+			if errors.Is(err, errRejectionResolved) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			logger.Error().LogErrorf("problem discarding rejection %s: %v", rej.ID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
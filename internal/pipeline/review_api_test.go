@@ -0,0 +1,245 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/approvals"
+	"github.com/moov-io/achgateway/internal/guardrails"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/rejections"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func reviewRouter(fr *FileReceiver) *mux.Router {
+	router := mux.NewRouter()
+	router.Path("/shards/{shardName}/held").HandlerFunc(fr.listHeldFiles())
+	router.Path("/shards/{shardName}/held/{name}").HandlerFunc(fr.getHeldFile())
+	router.Path("/shards/{shardName}/held/{name}/discard").HandlerFunc(fr.discardHeldFile())
+	router.Path("/rejections").HandlerFunc(fr.listRejections())
+	router.Path("/rejections/{rejectionID}").HandlerFunc(fr.getRejection())
+	router.Path("/rejections/{rejectionID}/resubmit").HandlerFunc(fr.resubmitRejection())
+	router.Path("/rejections/{rejectionID}/discard").HandlerFunc(fr.discardRejection())
+	return router
+}
+
+func serveReview(t *testing.T, router *mux.Router, method, path, body string, resp interface{}) int {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	if resp != nil && w.Code == http.StatusOK {
+		require.NoError(t, json.NewDecoder(w.Body).Decode(resp))
+	}
+	return w.Code
+}
+
+func TestReview__HeldFiles(t *testing.T) {
+	fr := rejectionsFileReceiver(t, nil)
+	router := reviewRouter(fr)
+
+	chest, err := storage.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+	agg := fr.shardAggregators["testing"]
+	agg.guardrails, err = guardrails.New(log.NewNopLogger(), "testing", &service.Guardrails{MaxEntryAmount: 100}, chest)
+	require.NoError(t, err)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	path, err := agg.guardrails.Hold(file, []string{"entry above 1.00"})
+	require.NoError(t, err)
+	name := filepath.Base(path)
+
+	var listed listHeldFilesResponse
+	require.Equal(t, http.StatusOK, serveReview(t, router, "GET", "/shards/testing/held", "", &listed))
+	require.Len(t, listed.Files, 1)
+	require.Equal(t, name, listed.Files[0].Name)
+	require.Equal(t, []string{"entry above 1.00"}, listed.Files[0].Reasons)
+	require.Equal(t, 1, listed.Files[0].EntryCount)
+	require.Empty(t, listed.Files[0].Contents)
+
+	var held heldFileResponse
+	require.Equal(t, http.StatusOK, serveReview(t, router, "GET", "/shards/testing/held/"+name, "", &held))
+	require.Contains(t, held.Contents, "companyname")
+	require.NotContains(t, held.Contents, "Bachman Eric")
+
+	require.Equal(t, http.StatusNotFound, serveReview(t, router, "GET", "/shards/testing/held/missing.ach", "", nil))
+	require.Equal(t, http.StatusNotFound, serveReview(t, router, "GET", "/shards/other/held", "", nil))
+
+	// Discarding requires a reason
+	require.Equal(t, http.StatusBadRequest, serveReview(t, router, "POST", "/shards/testing/held/"+name+"/discard", `{}`, nil))
+	require.Equal(t, http.StatusOK, serveReview(t, router, "POST", "/shards/testing/held/"+name+"/discard", `{"reason": "sent twice"}`, nil))
+
+	require.Equal(t, http.StatusOK, serveReview(t, router, "GET", "/shards/testing/held", "", &listed))
+	require.Empty(t, listed.Files)
+}
+
+func TestReview__DiscardApproval(t *testing.T) {
+	fr := rejectionsFileReceiver(t, nil)
+
+	var err error
+	fr.approvals, err = approvals.New(log.NewNopLogger(), &service.Approvals{
+		Operators: []service.Operator{
+			{Name: "alice", Token: "alice-token", Roles: []string{"requester"}},
+			{Name: "bob", Token: "bob-token", Roles: []string{"approver"}},
+		},
+	})
+	require.NoError(t, err)
+
+	router := reviewRouter(fr)
+	router.Path("/approvals/{approvalID}").HandlerFunc(fr.approveAction())
+
+	chest, err := storage.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+	agg := fr.shardAggregators["testing"]
+	agg.guardrails, err = guardrails.New(log.NewNopLogger(), "testing", &service.Guardrails{MaxEntryAmount: 100}, chest)
+	require.NoError(t, err)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	path, err := agg.guardrails.Hold(file, []string{"entry above 1.00"})
+	require.NoError(t, err)
+	discardPath := "/shards/testing/held/" + filepath.Base(path) + "/discard"
+
+	serveAs := func(method, path, body, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	requireHeld := func(count int) {
+		t.Helper()
+		held, err := agg.guardrails.HeldFiles()
+		require.NoError(t, err)
+		require.Len(t, held, count)
+	}
+
+	// Discarding without an operator is refused
+	w := serveAs("POST", discardPath, `{"reason": "sent twice"}`, "")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	requireHeld(1)
+
+	// The file is kept until a second operator approves
+	w = serveAs("POST", discardPath, `{"reason": "sent twice"}`, "alice-token")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	requireHeld(1)
+
+	var approval approvals.Approval
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&approval))
+	require.Equal(t, approvals.StatusPending, approval.Status)
+
+	w = serveAs("PUT", "/approvals/"+approval.ID, "", "bob-token")
+	require.Equal(t, http.StatusOK, w.Code)
+	requireHeld(0)
+}
+
+func TestReview__Rejections(t *testing.T) {
+	emitter := &rejectionEmitter{}
+	fr := rejectionsFileReceiver(t, emitter)
+	router := reviewRouter(fr)
+
+	// Rejected files are only kept with a database
+	require.Equal(t, http.StatusBadRequest, serveReview(t, router, "GET", "/rejections", "", nil))
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	fr.rejections = rejections.NewRepository(db.DB)
+
+	merger := &MockXferMerging{}
+	fr.shardAggregators["testing"].merger = merger
+
+	reject := func(fileID string) string {
+		file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+		require.NoError(t, err)
+		file.Batches[0].GetEntries()[0].DFIAccountNumber = ""
+
+		err = fr.processACHFile(incoming.ACHFile{
+			FileID:   fileID,
+			ShardKey: "testing",
+			File:     file,
+		})
+		require.NoError(t, err)
+
+		rejected := emitter.rejected[len(emitter.rejected)-1]
+		require.Equal(t, fileID, rejected.FileID)
+		require.NotEmpty(t, rejected.RejectionID)
+		return rejected.RejectionID
+	}
+	first := reject("file1")
+
+	var listed listRejectionsResponse
+	require.Equal(t, http.StatusOK, serveReview(t, router, "GET", "/rejections", "", &listed))
+	require.Len(t, listed.Rejections, 1)
+	require.Equal(t, first, listed.Rejections[0].ID)
+
+	var found getRejectionResponse
+	require.Equal(t, http.StatusOK, serveReview(t, router, "GET", "/rejections/"+first, "", &found))
+	require.Equal(t, "file1", found.FileID)
+	require.Equal(t, "testing", found.ShardName)
+	require.Equal(t, "EntryDetail", found.Reasons[0].Record)
+	require.Contains(t, found.Contents, "companyname")
+	require.NotContains(t, found.Contents, "Bachman Eric")
+
+	require.Equal(t, http.StatusNotFound, serveReview(t, router, "GET", "/rejections/missing", "", nil))
+
+	// Resubmit the file after fixing it
+	require.Equal(t, http.StatusBadRequest, serveReview(t, router, "POST", "/rejections/"+first+"/resubmit", "invalid", nil))
+
+	fixed, err := os.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, serveReview(t, router, "POST", "/rejections/"+first+"/resubmit", string(fixed), nil))
+	require.NotNil(t, merger.LatestFile)
+	require.Equal(t, "file1", merger.LatestFile.FileID)
+	require.Equal(t, "12345", merger.LatestFile.File.Batches[0].GetEntries()[0].DFIAccountNumber[:5])
+
+	require.Equal(t, http.StatusConflict, serveReview(t, router, "POST", "/rejections/"+first+"/resubmit", "", nil))
+	require.Equal(t, http.StatusOK, serveReview(t, router, "GET", "/rejections/"+first, "", &found))
+	require.Equal(t, rejections.StatusResubmitted, found.Status)
+
+	// Discard another rejected file
+	second := reject("file2")
+	require.Equal(t, http.StatusBadRequest, serveReview(t, router, "POST", "/rejections/"+second+"/discard", `{"reason": " "}`, nil))
+	require.Equal(t, http.StatusOK, serveReview(t, router, "POST", "/rejections/"+second+"/discard", `{"reason": "sent again as file3"}`, nil))
+	require.Equal(t, http.StatusConflict, serveReview(t, router, "POST", "/rejections/"+second+"/discard", `{"reason": "again"}`, nil))
+
+	found = getRejectionResponse{}
+	require.Equal(t, http.StatusOK, serveReview(t, router, "GET", "/rejections/"+second, "", &found))
+	require.Equal(t, rejections.StatusDiscarded, found.Status)
+	require.Equal(t, "sent again as file3", found.DiscardReason)
+	require.Empty(t, found.Contents)
+
+	require.Equal(t, http.StatusOK, serveReview(t, router, "GET", "/rejections", "", &listed))
+	require.Empty(t, listed.Rejections)
+}
@@ -0,0 +1,141 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+)
+
+// SimulationResult describes what a cutoff would have uploaded for the files given to Simulate.
+type SimulationResult struct {
+	Shard string
+
+	// Accepted are the fileIDs which were screened and saved for merging
+	Accepted []string
+	// Rejected maps fileIDs to the reason they weren't accepted
+	Rejected map[string]string
+
+	Files  []SimulatedFile
+	Errors []string
+}
+
+// SimulatedFile is an outbound file as it would have been uploaded.
+type SimulatedFile struct {
+	// Filename is rendered from the shard's OutboundFilenameTemplate
+	Filename string
+	// Contents are after PreUpload transforms and Output formatting
+	Contents []byte
+	// File is the merged file before PreUpload transforms
+	File *ach.File
+}
+
+// Simulate accepts files into a shard and runs a cutoff entirely offline. Files are merged on
+// a temporary directory and uploaded to a mock agent so merge conditions, filename templates,
+// pre-upload transforms, output formats and guardrails can be tested before deploying them.
+//
+// Notifications, audit trails and HTTP screening are turned off for the simulation.
+func Simulate(logger log.Logger, shard service.Shard, uploadAgents service.UploadAgents, files []incoming.ACHFile) (*SimulationResult, error) {
+	dir, err := os.MkdirTemp("", "achgateway-simulate-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating simulation directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mock := &upload.MockAgent{}
+	shard.UploadAgent = mock.ID()
+	shard.Notifications = nil
+	shard.Audit = nil
+	if shard.Screening != nil {
+		screening := *shard.Screening
+		screening.HTTP = nil
+		shard.Screening = &screening
+		if screening.Blocklist == nil {
+			shard.Screening = nil
+		}
+	}
+
+	uploadAgents.Agents = []service.UploadAgent{
+		{ID: mock.ID(), Mock: &service.MockAgent{}},
+	}
+	uploadAgents.Retry = nil
+	uploadAgents.Merging = service.Merging{
+		Storage: storage.Config{
+			Filesystem: storage.FilesystemConfig{
+				Directory: dir,
+			},
+		},
+	}
+
+	xfagg, err := newAggregator(logger, nil, nil, shard, uploadAgents, service.ErrorAlerting{})
+	if err != nil {
+		return nil, err
+	}
+	defer xfagg.Shutdown()
+
+	result := &SimulationResult{
+		Shard:    shard.Name,
+		Rejected: make(map[string]string),
+	}
+	for i := range files {
+		files[i].ShardKey = shard.Name
+		if err := xfagg.acceptFile(files[i]); err != nil {
+			result.Rejected[files[i].FileID] = err.Error()
+			continue
+		}
+		result.Accepted = append(result.Accepted, files[i].FileID)
+	}
+
+	checkAndUpload := xfagg.checkAndUpload("simulation", false, nil)
+	_, err = xfagg.merger.WithEachMerged(func(index int, agent upload.Agent, file *ach.File) error {
+		if err := checkAndUpload(index, agent, file); err != nil {
+			return err
+		}
+		if mock, ok := agent.(*upload.MockAgent); ok && mock.UploadedFile != nil {
+			bs, err := io.ReadAll(mock.UploadedFile.Contents)
+			if err != nil {
+				return err
+			}
+			result.Files = append(result.Files, SimulatedFile{
+				Filename: mock.UploadedFile.Filename,
+				Contents: bs,
+				File:     file,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		if el, ok := err.(base.ErrorList); ok {
+			for i := range el {
+				result.Errors = append(result.Errors, el[i].Error())
+			}
+		} else {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulate(t *testing.T) {
+	shard := service.Shard{
+		Name: "testing",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/New_York",
+			Windows:  []string{"17:00"},
+		},
+		UploadAgent:              "ftp-live",
+		OutboundFilenameTemplate: `SIM-{{ .ShardName }}-{{ .Index }}.ach`,
+		Notifications: &service.Notifications{
+			Email: []service.Email{{ID: "testing", From: "noreply@moov.io"}},
+		},
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID: "ftp-live",
+				FTP: &service.FTP{
+					Hostname: "ftp.bank.com:21",
+				},
+			},
+		},
+	}
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	result, err := Simulate(log.NewNopLogger(), shard, uploadAgents, []incoming.ACHFile{
+		{FileID: "ppd-debit", File: file},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "testing", result.Shard)
+	require.Equal(t, []string{"ppd-debit"}, result.Accepted)
+	require.Empty(t, result.Rejected)
+	require.Empty(t, result.Errors)
+
+	require.Len(t, result.Files, 1)
+	require.Equal(t, "SIM-TESTING-0.ach", result.Files[0].Filename)
+	require.NotNil(t, result.Files[0].File)
+
+	uploaded, err := ach.NewReader(bytes.NewReader(result.Files[0].Contents)).Read()
+	require.NoError(t, err)
+	require.Equal(t, file.Control.TotalDebitEntryDollarAmountInFile, uploaded.Control.TotalDebitEntryDollarAmountInFile)
+}
+
+func TestSimulate__Guardrails(t *testing.T) {
+	shard := service.Shard{
+		Name: "testing",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/New_York",
+			Windows:  []string{"17:00"},
+		},
+		UploadAgent: "mock",
+		Guardrails: &service.Guardrails{
+			MaxEntryAmount: 1,
+		},
+	}
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	result, err := Simulate(log.NewNopLogger(), shard, service.UploadAgents{}, []incoming.ACHFile{
+		{FileID: "ppd-debit", File: file},
+	})
+	require.NoError(t, err)
+	require.Empty(t, result.Files)
+	require.Len(t, result.Errors, 1)
+	require.True(t, strings.Contains(result.Errors[0], "held file"), result.Errors[0])
+}
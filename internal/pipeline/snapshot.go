@@ -0,0 +1,49 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"sort"
+
+	"github.com/moov-io/achgateway/internal/snapshot"
+)
+
+// Freeze stops accepting files and starting cutoffs until the returned func is called. Cutoffs
+// already in progress finish first.
+func (fr *FileReceiver) Freeze() func() {
+	fr.freeze.Lock()
+	return fr.freeze.Unlock
+}
+
+// SnapshotShards returns each shard handled by this instance along with its merging storage
+func (fr *FileReceiver) SnapshotShards() []snapshot.Shard {
+	var out []snapshot.Shard
+	for _, agg := range fr.shardAggregators {
+		if agg == nil {
+			continue
+		}
+		out = append(out, snapshot.Shard{
+			Config:  agg.shard,
+			Storage: fr.getStorage(agg),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Config.Name < out[j].Config.Name
+	})
+	return out
+}
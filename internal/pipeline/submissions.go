@@ -0,0 +1,63 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/submissions"
+)
+
+// recordSubmission saves the metadata of a file being accepted so it can be sent with the
+// file's FileUploaded event and the ReturnFile events of its entries.
+func (fr *FileReceiver) recordSubmission(agg *aggregator, fileID, shardKey string, metadata map[string]string, traceNumbers []string) error {
+	if fr.submissions == nil || len(metadata) == 0 {
+		return nil
+	}
+	return fr.submissions.Record(submissions.Submission{
+		ShardName: agg.shard.Name,
+		ShardKey:  shardKey,
+		FileID:    fileID,
+		Metadata:  metadata,
+	}, traceNumbers)
+}
+
+func traceNumbers(file *ach.File) []string {
+	var out []string
+	for _, batch := range file.Batches {
+		for _, entry := range batch.GetEntries() {
+			out = append(out, entry.TraceNumber)
+		}
+	}
+	return out
+}
+
+// submissionMetadata returns the metadata a file accepted into the shard was submitted with, or nil
+func (xfagg *aggregator) submissionMetadata(fileID string) map[string]string {
+	if xfagg.submissions == nil {
+		return nil
+	}
+	sub, err := xfagg.submissions.Get(xfagg.shard.Name, fileID)
+	if err != nil {
+		xfagg.logger.Warn().LogErrorf("problem reading metadata of fileID=%s: %v", fileID, err)
+		return nil
+	}
+	if sub == nil {
+		return nil
+	}
+	return sub.Metadata
+}
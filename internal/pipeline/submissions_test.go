@@ -0,0 +1,89 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/submissions"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+type submissionEmitter struct {
+	events.MockEmitter
+	events []models.Event
+}
+
+func (e *submissionEmitter) Send(evt models.Event) error {
+	e.events = append(e.events, evt)
+	return nil
+}
+
+func TestFileReceiver__SubmissionMetadata(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := submissions.NewRepository(db.DB)
+
+	emitter := &submissionEmitter{}
+	fr := rejectionsFileReceiver(t, emitter)
+	fr.entryEvents = true
+	fr.submissions = repo
+	agg := fr.shardAggregators["testing"]
+	agg.submissions = repo
+
+	metadata := map[string]string{"batchID": "123", "ledger": "payroll"}
+	file := lateFile(t, "file1")
+	file.Metadata = metadata
+	require.NoError(t, fr.processACHFile(file))
+
+	require.Len(t, emitter.events, 1)
+	accepted, ok := emitter.events[0].Event.(models.EntryAccepted)
+	require.True(t, ok)
+	require.Equal(t, metadata, accepted.Metadata)
+
+	sub, err := repo.FindTraceNumber(file.File.Batches[0].GetEntries()[0].TraceNumber)
+	require.NoError(t, err)
+	require.Equal(t, "file1", sub.FileID)
+	require.Equal(t, "testing", sub.ShardName)
+
+	// FileUploaded events read the recorded metadata
+	emitter.events = nil
+	require.NoError(t, agg.emitFilesUploaded(&processedFiles{shardKey: "testing", fileIDs: []string{"file1", "file2"}}))
+	require.Len(t, emitter.events, 2)
+	require.Equal(t, metadata, emitter.events[0].Event.(models.FileUploaded).Metadata)
+	require.Nil(t, emitter.events[1].Event.(models.FileUploaded).Metadata)
+}
+
+func TestFileReceiver__RejectMetadata(t *testing.T) {
+	emitter := &rejectionEmitter{}
+	fr := rejectionsFileReceiver(t, emitter)
+
+	file := lateFile(t, "file1")
+	file.Metadata = map[string]string{"memo": strings.Repeat("a", 500)}
+	require.NoError(t, fr.processACHFile(file))
+
+	require.Len(t, emitter.rejected, 1)
+	rejected := emitter.rejected[0]
+	require.Equal(t, models.RejectionMetadata, rejected.Reasons[0].Code)
+	require.Equal(t, file.Metadata, rejected.Metadata)
+}
@@ -0,0 +1,103 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/uploadledger"
+	"github.com/moov-io/base/log"
+)
+
+const (
+	// manualWindow is recorded in the upload ledger for files uploaded by a manual cutoff
+	manualWindow = "manual"
+
+	// takeoverWindow is recorded for files uploaded while taking over a lost leader's cutoff
+	takeoverWindow = "takeover"
+)
+
+// beginUpload records the intent to upload file in the upload ledger. skip is true when the file
+// was already uploaded, and the returned func records the outcome of the upload.
+//
+// Files recorded but never confirmed were lost part way through an upload and may already be at
+// the ODFI, so they're refused until an operator clears them from the ledger.
+func (xfagg *aggregator) beginUpload(window, filename string, file *ach.File) (func(error), bool, error) {
+	if xfagg.uploads == nil {
+		return func(error) {}, false, nil
+	}
+
+	sum, err := fileSHA256(file)
+	if err != nil {
+		return nil, false, fmt.Errorf("hashing %s: %v", filename, err)
+	}
+	logger := xfagg.logger.With(log.Fields{
+		"shard":    log.String(xfagg.shard.Name),
+		"filename": log.String(filename),
+		"sha256":   log.String(sum),
+	})
+
+	prior, err := xfagg.uploads.Begin(uploadledger.Entry{
+		ShardName: xfagg.shard.Name,
+		SHA256:    sum,
+		Window:    window,
+		Filename:  filename,
+		Holder:    xfagg.hostname,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if prior != nil {
+		duplicateUploads.With("shard", xfagg.shard.Name, "status", prior.Status).Add(1)
+
+		if prior.Status == uploadledger.StatusUploaded {
+			logger.Warn().Logf("skipping upload, file was already uploaded as %s by %s at %v",
+				prior.Filename, prior.Holder, prior.UploadedAt.Format(time.RFC3339))
+			return nil, true, nil
+		}
+		return nil, false, fmt.Errorf("refusing to upload %s (sha256 %s), %s started uploading it as %s at %v without confirming it was uploaded -- "+
+			"check the ODFI for the file and clear it from the upload ledger", filename, sum, prior.Holder, prior.Filename, prior.CreatedAt.Format(time.RFC3339))
+	}
+
+	return func(uploadErr error) {
+		if uploadErr != nil {
+			if err := xfagg.uploads.Abandon(xfagg.shard.Name, sum); err != nil {
+				logger.Error().LogErrorf("problem abandoning failed upload: %v", err)
+			}
+			return
+		}
+		if err := xfagg.uploads.Confirm(xfagg.shard.Name, sum); err != nil {
+			xfagg.alertOnError(logger.Error().LogErrorf("file was uploaded but %v", err).Err())
+		}
+	}, false, nil
+}
+
+// fileSHA256 hashes the Nacha formatted file, before it's encrypted or otherwise transformed
+func fileSHA256(file *ach.File) (string, error) {
+	var buf bytes.Buffer
+	if err := ach.NewWriter(&buf).Write(file); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
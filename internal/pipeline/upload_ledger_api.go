@@ -0,0 +1,114 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"github.com/moov-io/achgateway/internal/uploadledger"
+	"github.com/moov-io/base/log"
+)
+
+type listUploadsResponse struct {
+	Uploads        []uploadledger.Entry `json:"uploads"`
+	SourceHostname string               `json:"sourceHostname"`
+}
+
+// listShardUploads returns the shard's most recent uploads from the upload ledger
+func (fr *FileReceiver) listShardUploads() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := fr.logger.With(log.Fields{
+			"route": log.String("list_uploads"),
+		})
+
+		agg := fr.lookupAggregator(logger, r)
+		if agg == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if agg.uploads == nil {
+			logger.Warn().Log("upload ledger requires a database")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		entries, err := agg.uploads.List(agg.shard.Name, 100)
+		if err != nil {
+			logger.Error().LogErrorf("problem listing %s uploads: %v", agg.shard.Name, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		resp := listUploadsResponse{Uploads: entries}
+		resp.SourceHostname, _ = os.Hostname()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// clearShardUpload removes an unconfirmed upload from the ledger so the file is uploaded again.
+// Operators should check the file isn't at the ODFI first.
+func (fr *FileReceiver) clearShardUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		logger := fr.logger.With(log.Fields{
+			"route": log.String("clear_upload"),
+		})
+
+		agg := fr.lookupAggregator(logger, r)
+		if agg == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if agg.uploads == nil {
+			logger.Warn().Log("upload ledger requires a database")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		sum := mux.Vars(r)["sha256"]
+		clear := func() error {
+			if err := agg.uploads.Abandon(agg.shard.Name, sum); err != nil {
+				return err
+			}
+			logger.Info().Logf("cleared unconfirmed upload %s from %s upload ledger", sum, agg.shard.Name)
+			return nil
+		}
+
+		// Clearing an upload lets the file be uploaded again, so it needs a second operator
+		desc := fmt.Sprintf("clear upload %s from shard %s so it's uploaded again", sum, agg.shard.Name)
+		if fr.RequestApproval(w, r, "clear-upload", desc, clear) {
+			return
+		}
+		if err := clear(); err != nil {
+			logger.Error().LogErrorf("problem clearing %s upload: %v", agg.shard.Name, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
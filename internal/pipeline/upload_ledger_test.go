@@ -0,0 +1,94 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/transform"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/achgateway/internal/uploadledger"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregate_UploadLedger(t *testing.T) {
+	shard := service.Shard{
+		Name: "test",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/Los_Angeles",
+			Windows:  []string{"10:30"},
+		},
+		UploadAgent: "mock-agent",
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock-agent",
+				Mock: &service.MockAgent{},
+			},
+		},
+	}
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, &events.MockEmitter{}, shard, uploadAgents, service.ErrorAlerting{})
+	require.NoError(t, err)
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	xfagg.uploads = uploadledger.NewRepository(db.DB)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	agent := &upload.MockAgent{}
+	require.NoError(t, xfagg.uploadFile("2022-06-01 10:30 PDT", 0, agent, &transform.Result{File: file}, nil))
+	require.NotNil(t, agent.UploadedFile)
+
+	// The same file isn't uploaded again, like when a cutoff is retried
+	agent.UploadedFile = nil
+	require.NoError(t, xfagg.uploadFile(takeoverWindow, 0, agent, &transform.Result{File: file}, nil))
+	require.Nil(t, agent.UploadedFile)
+
+	entries, err := xfagg.uploads.List("test", 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, uploadledger.StatusUploaded, entries[0].Status)
+	require.Equal(t, "2022-06-01 10:30 PDT", entries[0].Window)
+
+	// Files left pending by a lost upload are refused
+	other, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "two-micro-deposits.ach"))
+	require.NoError(t, err)
+	sum, err := fileSHA256(other)
+	require.NoError(t, err)
+	_, err = xfagg.uploads.Begin(uploadledger.Entry{ShardName: "test", SHA256: sum, Holder: "achgateway-0"})
+	require.NoError(t, err)
+
+	err = xfagg.uploadFile(takeoverWindow, 0, agent, &transform.Result{File: other}, nil)
+	require.ErrorContains(t, err, "achgateway-0 started uploading it")
+	require.Nil(t, agent.UploadedFile)
+
+	// Once cleared by an operator the file is uploaded
+	require.NoError(t, xfagg.uploads.Abandon("test", sum))
+	require.NoError(t, xfagg.uploadFile(takeoverWindow, 0, agent, &transform.Result{File: other}, nil))
+	require.NotNil(t, agent.UploadedFile)
+}
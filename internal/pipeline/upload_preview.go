@@ -0,0 +1,158 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/leadership"
+	"github.com/moov-io/achgateway/internal/notify"
+	"github.com/moov-io/achgateway/internal/schedule"
+
+	"github.com/moov-io/base/log"
+)
+
+// nextPreview returns the next banking day cutoff at or after after which is at least the
+// shard's preview lead time away from now, and how long to wait until its preview is sent.
+func (xfagg *aggregator) nextPreview(now, after time.Time) (time.Time, time.Duration, bool) {
+	if xfagg.shard.UploadPreview == nil {
+		return time.Time{}, 0, false
+	}
+	lead := xfagg.shard.UploadPreview.LeadTime
+
+	from := now.Add(lead)
+	if after.After(from) {
+		from = after
+	}
+	days, err := schedule.Upcoming(xfagg.shard.Cutoffs.Timezone, xfagg.shard.Cutoffs.Windows, from, 8)
+	if err != nil {
+		xfagg.logger.Warn().LogErrorf("skipping upload previews: %v", err)
+		return time.Time{}, 0, false
+	}
+	for _, day := range days {
+		if day.IsBankingDay {
+			return day.Time, day.Time.Add(-lead).Sub(now), true
+		}
+	}
+	return time.Time{}, 0, false
+}
+
+// schedulePreview returns the next cutoff a preview is sent for and a channel which fires when
+// it's time to send it. The channel is nil without upload previews.
+func (xfagg *aggregator) schedulePreview(after time.Time) (time.Time, <-chan time.Time) {
+	cutoff, wait, ok := xfagg.nextPreview(time.Now(), after)
+	if !ok {
+		return time.Time{}, nil
+	}
+	return cutoff, time.After(wait)
+}
+
+// pendingTotals are the entries and amounts (in cents) of files waiting for the next cutoff
+type pendingTotals struct {
+	Files   int
+	Entries int
+	Debits  int
+	Credits int
+}
+
+// pendingTotals reads the shard's pending files from storage without taking them from
+// incremental merging or the parsed file cache.
+func (m *filesystemMerging) pendingTotals() (pendingTotals, error) {
+	var totals pendingTotals
+
+	matches, err := m.getNonCanceledMatches(filepath.Join("mergable", m.shard.Name))
+	if err != nil {
+		return totals, err
+	}
+	for i := range matches {
+		file, err := m.readFile(matches[i])
+		if err != nil {
+			return totals, fmt.Errorf("problem reading %s: %v", matches[i], err)
+		}
+		if file == nil {
+			continue
+		}
+		totals.Files++
+		for _, batch := range file.Batches {
+			for _, entry := range batch.GetEntries() {
+				totals.Entries++
+				switch entry.CreditOrDebit() {
+				case "C":
+					totals.Credits += entry.Amount
+				case "D":
+					totals.Debits += entry.Amount
+				}
+			}
+		}
+	}
+	return totals, nil
+}
+
+func previewMessage(shardName string, cutoff time.Time, totals pendingTotals) string {
+	return fmt.Sprintf("About to upload %d entries totaling %s (debits %s, credits %s) from %d files for shard %s at the %s cutoff",
+		totals.Entries, convertCents(totals.Debits+totals.Credits), convertCents(totals.Debits), convertCents(totals.Credits),
+		totals.Files, shardName, cutoff.Format("15:04 MST"))
+}
+
+// previewUpload sends an Info notification summarizing the files pending for cutoff. Files
+// accepted or canceled afterwards aren't included.
+func (xfagg *aggregator) previewUpload(cutoff time.Time) {
+	logger := xfagg.logger.With(log.Fields{
+		"shard": log.String(xfagg.shard.Name),
+	})
+
+	mm, ok := xfagg.merger.(*filesystemMerging)
+	if !ok {
+		return
+	}
+	if xfagg.uploadAgents.Merging.SingleWriter {
+		// Only the shard's leader uploads files from shared storage
+		if err := leadership.AcquireLock(mm.elector, outboundLeaderKey(xfagg.shard.Name)); err != nil {
+			return
+		}
+	}
+
+	totals, err := mm.pendingTotals()
+	if err != nil {
+		xfagg.alertOnError(logger.Error().LogErrorf("problem reading files for upload preview: %v", err).Err())
+		return
+	}
+	if totals.Files == 0 {
+		logger.Info().Logf("skipping upload preview of %s cutoff without pending files", cutoff.Format("15:04"))
+		return
+	}
+
+	uploadAgent := xfagg.uploadAgents.Find(xfagg.shard.UploadAgent)
+	if uploadAgent == nil {
+		logger.Warn().Logf("skipping upload preview, upload agent %s not found", xfagg.shard.UploadAgent)
+		return
+	}
+	notifier, err := notify.NewMultiSender(logger, xfagg.shard.Notifications, uploadAgent.Notifications)
+	if err != nil {
+		logger.Error().LogErrorf("ERROR creating upload preview notifier: %v", err)
+		return
+	}
+	err = notifier.Info(&notify.Message{
+		Contents: previewMessage(xfagg.shard.Name, cutoff, totals),
+	})
+	if err != nil {
+		logger.Error().LogErrorf("ERROR sending upload preview notification: %v", err)
+	}
+}
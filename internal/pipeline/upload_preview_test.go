@@ -0,0 +1,144 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadPreview__nextPreview(t *testing.T) {
+	xfagg := &aggregator{
+		logger: log.NewTestLogger(),
+		shard: service.Shard{
+			Cutoffs: service.Cutoffs{
+				Timezone: "America/New_York",
+				Windows:  []string{"10:30", "16:00"},
+			},
+		},
+	}
+	_, _, ok := xfagg.nextPreview(time.Now(), time.Time{})
+	require.False(t, ok)
+
+	xfagg.shard.UploadPreview = &service.UploadPreview{LeadTime: 15 * time.Minute}
+
+	loc, _ := time.LoadLocation("America/New_York")
+	now := time.Date(2022, time.June, 1, 10, 0, 0, 0, loc) // Wednesday
+
+	cutoff, wait, ok := xfagg.nextPreview(now, time.Time{})
+	require.True(t, ok)
+	require.Equal(t, "2022-06-01 10:30", cutoff.Format("2006-01-02 15:04"))
+	require.Equal(t, 15*time.Minute, wait)
+
+	// The 10:30 preview would have been sent already
+	cutoff, wait, ok = xfagg.nextPreview(now.Add(20*time.Minute), time.Time{})
+	require.True(t, ok)
+	require.Equal(t, "2022-06-01 16:00", cutoff.Format("2006-01-02 15:04"))
+	require.Equal(t, 5*time.Hour+25*time.Minute, wait)
+
+	// Previews are sent once per cutoff
+	cutoff, _, ok = xfagg.nextPreview(now.Add(15*time.Minute), cutoff.Add(time.Minute))
+	require.True(t, ok)
+	require.Equal(t, "2022-06-02 10:30", cutoff.Format("2006-01-02 15:04"))
+
+	// Weekends are skipped
+	cutoff, _, ok = xfagg.nextPreview(time.Date(2022, time.June, 3, 17, 0, 0, 0, loc), time.Time{})
+	require.True(t, ok)
+	require.Equal(t, "2022-06-06 10:30", cutoff.Format("2006-01-02 15:04"))
+}
+
+func TestUploadPreview__previewUpload(t *testing.T) {
+	var messages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		messages = append(messages, body.Text)
+	}))
+	t.Cleanup(server.Close)
+
+	shard := service.Shard{
+		Name: "testing",
+		Cutoffs: service.Cutoffs{
+			Timezone: "America/New_York",
+			Windows:  []string{"10:30"},
+		},
+		UploadAgent: "mock",
+		Notifications: &service.Notifications{
+			Slack: []service.Slack{
+				{ID: "treasury", WebhookURL: server.URL},
+			},
+		},
+		UploadPreview: &service.UploadPreview{LeadTime: 15 * time.Minute},
+	}
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock",
+				Mock: &service.MockAgent{},
+				Notifications: &service.UploadNotifiers{
+					Slack: []string{"treasury"},
+				},
+			},
+		},
+	}
+	uploadAgents.Merging.Storage.Filesystem.Directory = t.TempDir()
+
+	xfagg, err := newAggregator(log.NewNopLogger(), nil, &events.MockEmitter{}, shard, uploadAgents, service.ErrorAlerting{})
+	require.NoError(t, err)
+
+	loc, _ := time.LoadLocation("America/New_York")
+	cutoff := time.Date(2022, time.June, 1, 10, 30, 0, 0, loc)
+
+	// Nothing is sent without pending files
+	xfagg.previewUpload(cutoff)
+	require.Empty(t, messages)
+
+	for _, name := range []string{"ppd-debit.ach", "two-micro-deposits.ach"} {
+		file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", name))
+		require.NoError(t, err)
+		require.NoError(t, xfagg.merger.HandleXfer(incoming.ACHFile{
+			FileID:   base.ID(),
+			ShardKey: "testing",
+			File:     file,
+		}))
+	}
+
+	totals, err := xfagg.merger.(*filesystemMerging).pendingTotals()
+	require.NoError(t, err)
+	require.Equal(t, pendingTotals{Files: 2, Entries: 7, Debits: 10620, Credits: 120}, totals)
+
+	xfagg.previewUpload(cutoff)
+	require.Equal(t, []string{
+		"About to upload 7 entries totaling $107.40 (debits $106.20, credits $1.20) from 2 files for shard testing at the 10:30 EDT cutoff",
+	}, messages)
+}
@@ -0,0 +1,140 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/moov-io/base/log"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Client sends commands to a Redis server, or to the nodes of a Redis Cluster, over a pool
+// of connections which are redialed after they fail.
+type Client struct {
+	cfg    Config
+	logger log.Logger
+
+	rdb goredis.UniversalClient
+}
+
+func NewClient(logger log.Logger, config *Config) (*Client, error) {
+	if config == nil {
+		return nil, errors.New("nil redis config")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	cfg := config.withDefaults()
+
+	c := &Client{
+		cfg: cfg,
+		logger: logger.With(log.Fields{
+			"redis": log.String(cfg.Address),
+		}),
+		rdb: newUniversalClient(cfg),
+	}
+	if err := c.Ping(context.Background()); err != nil {
+		c.rdb.Close()
+		return nil, fmt.Errorf("connecting to %s: %v", cfg.Address, err)
+	}
+	return c, nil
+}
+
+func newUniversalClient(cfg Config) goredis.UniversalClient {
+	var tlsConfig *tls.Config
+	if cfg.TLS {
+		host, _, _ := net.SplitHostPort(cfg.Address)
+		tlsConfig = &tls.Config{
+			ServerName: host,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	if cfg.Cluster {
+		return goredis.NewClusterClient(&goredis.ClusterOptions{
+			Addrs:        []string{cfg.Address},
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			TLSConfig:    tlsConfig,
+			DialTimeout:  cfg.Timeout,
+			ReadTimeout:  cfg.Timeout,
+			WriteTimeout: cfg.Timeout,
+			PoolSize:     cfg.PoolSize,
+		})
+	}
+	return goredis.NewClient(&goredis.Options{
+		Addr:         cfg.Address,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		DB:           cfg.Database,
+		TLSConfig:    tlsConfig,
+		DialTimeout:  cfg.Timeout,
+		ReadTimeout:  cfg.Timeout,
+		WriteTimeout: cfg.Timeout,
+		PoolSize:     cfg.PoolSize,
+	})
+}
+
+// Config returns the client's config with defaults filled in
+func (c *Client) Config() Config {
+	return c.cfg
+}
+
+// Key returns name with the configured KeyPrefix
+func (c *Client) Key(name string) string {
+	return c.cfg.KeyPrefix + name
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	return c.rdb.Ping(ctx).Err()
+}
+
+// SetNX sets key to value when it doesn't exist, returning true when it was set
+func (c *Client) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return c.rdb.SetNX(ctx, c.Key(key), value, ttl).Result()
+}
+
+// Get returns the value of key, or false when it doesn't exist
+func (c *Client) Get(ctx context.Context, key string) (string, bool, error) {
+	value, err := c.rdb.Get(ctx, c.Key(key)).Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (c *Client) Del(ctx context.Context, key string) error {
+	return c.rdb.Del(ctx, c.Key(key)).Err()
+}
+
+// Close closes every connection in the pool
+func (c *Client) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.rdb.Close()
+}
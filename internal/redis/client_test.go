@@ -0,0 +1,189 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/redis"
+	"github.com/moov-io/achgateway/internal/redis/redistest"
+	"github.com/moov-io/base/log"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig__Validate(t *testing.T) {
+	var cfg *redis.Config
+	require.NoError(t, cfg.Validate())
+
+	require.ErrorContains(t, (&redis.Config{}).Validate(), "missing Address")
+	require.ErrorContains(t, (&redis.Config{Address: "redis:6379", Database: -1}).Validate(), "invalid Database")
+	require.ErrorContains(t, (&redis.Config{
+		Address: "redis:6379",
+		Locks:   &redis.LockConfig{LockDuration: time.Millisecond},
+	}).Validate(), "locks: invalid LockDuration")
+	require.ErrorContains(t, (&redis.Config{
+		Address:   "redis:6379",
+		RateLimit: &redis.RateLimitConfig{Interval: time.Second},
+	}).Validate(), "rate limit: invalid Requests 0")
+	require.ErrorContains(t, (&redis.Config{
+		Address:     "redis:6379",
+		Idempotency: &redis.IdempotencyConfig{},
+	}).Validate(), "idempotency: invalid TTL")
+	require.ErrorContains(t, (&redis.Config{
+		Address:        "redis:6379",
+		ProcessedFiles: &redis.ProcessedFilesConfig{},
+	}).Validate(), "processed files: invalid TTL")
+}
+
+func TestClient(t *testing.T) {
+	client, server := redistest.NewClient(t, redis.Config{Password: "secret", Database: 2})
+	ctx := context.Background()
+
+	require.Equal(t, "achgateway:foo", client.Key("foo"))
+
+	set, err := client.SetNX(ctx, "foo", "bar", time.Minute)
+	require.NoError(t, err)
+	require.True(t, set)
+
+	set, err = client.SetNX(ctx, "foo", "baz", time.Minute)
+	require.NoError(t, err)
+	require.False(t, set)
+
+	value, found, err := client.Get(ctx, "foo")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "bar", value)
+
+	server.FastForward(time.Minute)
+	_, found, err = client.Get(ctx, "foo")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	set, err = client.SetNX(ctx, "foo", "baz", time.Minute)
+	require.NoError(t, err)
+	require.True(t, set)
+	require.Equal(t, []string{"achgateway:foo"}, server.DB(2).Keys())
+	require.NoError(t, client.Del(ctx, "foo"))
+	require.Empty(t, server.DB(2).Keys())
+
+	// Connections are redialed after the server restarts
+	server.Close()
+	require.Error(t, client.Ping(ctx))
+	require.NoError(t, server.Restart())
+	require.NoError(t, client.Ping(ctx))
+}
+
+func TestClient__Auth(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireUserAuth("achgateway", "secret")
+
+	_, err := redis.NewClient(log.NewTestLogger(), &redis.Config{
+		Address:  server.Addr(),
+		Username: "achgateway",
+		Password: "wrong",
+	})
+	require.ErrorContains(t, err, "WRONGPASS")
+}
+
+func TestClient__Cluster(t *testing.T) {
+	require.ErrorContains(t, (&redis.Config{Address: "redis:6379", Cluster: true, Database: 1}).Validate(), "only has database 0")
+
+	// miniredis answers CLUSTER SLOTS as a single node holding every slot
+	server := miniredis.RunT(t)
+	client, err := redis.NewClient(log.NewTestLogger(), &redis.Config{
+		Address: server.Addr(),
+		Cluster: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	ctx := context.Background()
+	set, err := client.SetNX(ctx, "foo", "bar", time.Minute)
+	require.NoError(t, err)
+	require.True(t, set)
+	require.Equal(t, []string{"achgateway:foo"}, server.Keys())
+}
+
+func TestClient__Unavailable(t *testing.T) {
+	_, err := redis.NewClient(log.NewTestLogger(), &redis.Config{
+		Address: "127.0.0.1:1",
+		Timeout: time.Second,
+	})
+	require.ErrorContains(t, err, "connecting to 127.0.0.1:1")
+}
+
+func TestSet(t *testing.T) {
+	client, server := redistest.NewClient(t, redis.Config{KeyPrefix: "test:"})
+	set := client.Set("files", time.Hour)
+
+	found, err := set.Contains("abc")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	added, err := set.Add("abc")
+	require.NoError(t, err)
+	require.True(t, added)
+	added, err = set.Add("abc")
+	require.NoError(t, err)
+	require.False(t, added)
+
+	found, err = set.Contains("abc")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []string{"test:set:files:abc"}, server.Keys())
+
+	require.NoError(t, set.Remove("abc"))
+	added, err = set.Add("abc")
+	require.NoError(t, err)
+	require.True(t, added)
+
+	server.FastForward(time.Hour)
+	found, err = set.Contains("abc")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRateLimiter(t *testing.T) {
+	client, server := redistest.NewClient(t, redis.Config{})
+	limiter := client.RateLimiter("files", 2, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err := limiter.Allow("s1")
+		require.NoError(t, err)
+		require.True(t, allowed)
+	}
+	allowed, retryAfter, err := limiter.Allow("s1")
+	require.NoError(t, err)
+	require.False(t, allowed)
+	require.Equal(t, time.Minute, retryAfter)
+
+	// Keys are counted separately
+	allowed, _, err = limiter.Allow("s2")
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	// Windows reset once they expire
+	server.FastForward(time.Minute)
+	allowed, _, err = limiter.Allow("s1")
+	require.NoError(t, err)
+	require.True(t, allowed)
+}
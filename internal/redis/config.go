@@ -0,0 +1,182 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package redis keeps state shared by replicas which doesn't belong in the achgateway database,
+// such as short-lived locks, rate limit counters and recently seen keys. Values expire on their
+// own, so replicas don't contend for rows which are only kept for a few minutes.
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/mask"
+)
+
+const (
+	defaultKeyPrefix    = "achgateway:"
+	defaultTimeout      = 5 * time.Second
+	defaultPoolSize     = 10
+	defaultLockDuration = 30 * time.Second
+)
+
+type Config struct {
+	// Address is the host:port of the Redis server, or of any node when Cluster is set
+	Address string
+
+	// Cluster connects to a Redis Cluster and discovers its other nodes from Address
+	Cluster bool
+
+	// Username and Password authenticate with AUTH. Username is only used by Redis 6 ACLs.
+	Username string
+	Password string
+
+	// Database is selected on each connection. Redis Cluster only has database 0.
+	Database int
+
+	// TLS connects with TLS when set
+	TLS bool
+
+	// KeyPrefix is prepended to every key so instances can share a server. Defaults to "achgateway:"
+	KeyPrefix string
+
+	// Timeout bounds connecting and each command. Defaults to 5s
+	Timeout time.Duration
+
+	// PoolSize is how many connections are kept open to each node. Defaults to 10
+	PoolSize int
+
+	// Locks elects leaders with keys which expire unless they're renewed, when set
+	Locks *LockConfig
+
+	// RateLimit bounds how many files each shard key submits over HTTP across every replica, when set
+	RateLimit *RateLimitConfig
+
+	// Idempotency remembers the files submitted over HTTP so identical retries aren't published again, when set
+	Idempotency *IdempotencyConfig
+
+	// ProcessedFiles remembers the ODFI files which were processed so copies downloaded again are skipped, when set
+	ProcessedFiles *ProcessedFilesConfig
+}
+
+type LockConfig struct {
+	// Identity is written as the holder of each lock. Defaults to the hostname.
+	Identity string
+
+	// LockDuration is how long a lock is held without being renewed. Held locks are renewed
+	// every third of LockDuration. Defaults to 30s
+	LockDuration time.Duration
+}
+
+type RateLimitConfig struct {
+	// Requests is how many submissions are accepted for each shard key within Interval
+	Requests int
+	Interval time.Duration
+}
+
+type IdempotencyConfig struct {
+	// TTL is how long a submitted file is remembered
+	TTL time.Duration
+}
+
+type ProcessedFilesConfig struct {
+	// TTL is how long a processed file is remembered
+	TTL time.Duration
+}
+
+func (cfg *Config) MarshalJSON() ([]byte, error) {
+	type Aux Config
+	aux := Aux(*cfg)
+	aux.Password = mask.Password(cfg.Password)
+	return json.Marshal(aux)
+}
+
+func (cfg *Config) String() string {
+	return fmt.Sprintf("Redis{Address=%s, Username=%s, Password=%s, Database=%d}",
+		cfg.Address, cfg.Username, mask.Password(cfg.Password), cfg.Database)
+}
+
+func (cfg *Config) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Address == "" {
+		return errors.New("missing Address")
+	}
+	if cfg.Database < 0 {
+		return fmt.Errorf("invalid Database %d", cfg.Database)
+	}
+	if cfg.Cluster && cfg.Database != 0 {
+		return fmt.Errorf("invalid Database %d: Redis Cluster only has database 0", cfg.Database)
+	}
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("invalid Timeout %v", cfg.Timeout)
+	}
+	if cfg.PoolSize < 0 {
+		return fmt.Errorf("invalid PoolSize %d", cfg.PoolSize)
+	}
+	if l := cfg.Locks; l != nil {
+		if l.LockDuration < 0 || (l.LockDuration > 0 && l.LockDuration < time.Second) {
+			return fmt.Errorf("locks: invalid LockDuration %v", l.LockDuration)
+		}
+	}
+	if rl := cfg.RateLimit; rl != nil {
+		if rl.Requests <= 0 {
+			return fmt.Errorf("rate limit: invalid Requests %d", rl.Requests)
+		}
+		if rl.Interval < time.Millisecond {
+			return fmt.Errorf("rate limit: invalid Interval %v", rl.Interval)
+		}
+	}
+	if cfg.Idempotency != nil && cfg.Idempotency.TTL < time.Second {
+		return fmt.Errorf("idempotency: invalid TTL %v", cfg.Idempotency.TTL)
+	}
+	if cfg.ProcessedFiles != nil && cfg.ProcessedFiles.TTL < time.Second {
+		return fmt.Errorf("processed files: invalid TTL %v", cfg.ProcessedFiles.TTL)
+	}
+	return nil
+}
+
+func (cfg Config) withDefaults() Config {
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = defaultKeyPrefix
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.PoolSize == 0 {
+		cfg.PoolSize = defaultPoolSize
+	}
+	return cfg
+}
+
+func (cfg LockConfig) withDefaults() (LockConfig, error) {
+	if cfg.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return cfg, fmt.Errorf("missing Identity: %v", err)
+		}
+		cfg.Identity = hostname
+	}
+	if cfg.LockDuration == 0 {
+		cfg.LockDuration = defaultLockDuration
+	}
+	return cfg, nil
+}
@@ -0,0 +1,206 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/moov-io/base/log"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Scripts which only change a lock while it's held by ARGV[1]
+var (
+	renewScript   = goredis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`)
+	releaseScript = goredis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`)
+)
+
+// Locker acquires and renews locks on behalf of this replica. Each lock is a key holding the
+// replica's identity which expires unless it's renewed, so locks of a replica which stops
+// without releasing them are taken over after LockDuration.
+type Locker struct {
+	client *Client
+	cfg    LockConfig
+	logger log.Logger
+
+	mu   sync.Mutex
+	held map[string]bool // lock keys currently held
+
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	wg           sync.WaitGroup
+}
+
+func NewLocker(logger log.Logger, client *Client, config *LockConfig) (*Locker, error) {
+	if client == nil {
+		return nil, errors.New("redis locks require a redis client")
+	}
+	if config == nil {
+		return nil, errors.New("nil redis lock config")
+	}
+	cfg, err := config.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Locker{
+		client: client,
+		cfg:    cfg,
+		logger: logger.With(log.Fields{
+			"identity": log.String(cfg.Identity),
+		}),
+		held:     make(map[string]bool),
+		shutdown: make(chan struct{}),
+	}
+
+	l.wg.Add(1)
+	go l.renewHeldLocks()
+
+	return l, nil
+}
+
+// Identity is the holder written to locks acquired by this replica
+func (l *Locker) Identity() string {
+	return l.cfg.Identity
+}
+
+// AcquireLock returns nil when this replica holds (or has now acquired) the lock for key
+func (l *Locker) AcquireLock(key string) error {
+	err := l.acquire(key)
+
+	l.mu.Lock()
+	l.held[key] = (err == nil)
+	l.mu.Unlock()
+	return err
+}
+
+func (l *Locker) acquire(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.client.cfg.Timeout)
+	defer cancel()
+
+	set, err := l.client.SetNX(ctx, l.key(key), l.cfg.Identity, l.cfg.LockDuration)
+	if err != nil {
+		return fmt.Errorf("acquiring lock %s: %v", key, err)
+	}
+	if set {
+		return nil
+	}
+
+	// Renew the lock when we already hold it, such as after restarting with the same identity
+	renewed, err := l.eval(ctx, renewScript, key, l.cfg.LockDuration.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("renewing lock %s: %v", key, err)
+	}
+	if renewed {
+		return nil
+	}
+	holder, _, err := l.client.Get(ctx, l.key(key))
+	if err != nil {
+		return fmt.Errorf("reading lock %s: %v", key, err)
+	}
+	return fmt.Errorf("we are not the leader of %s (held by %s)", key, holder)
+}
+
+func (l *Locker) key(key string) string {
+	return "locks:" + key
+}
+
+// eval runs script against the lock for key and returns true when it changed the lock
+func (l *Locker) eval(ctx context.Context, script *goredis.Script, key string, args ...interface{}) (bool, error) {
+	keys := []string{l.client.Key(l.key(key))}
+	n, err := script.Run(ctx, l.client.rdb, keys, append([]interface{}{l.cfg.Identity}, args...)...).Int64()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (l *Locker) heldKeys() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var keys []string
+	for key, held := range l.held {
+		if held {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// renewHeldLocks keeps leadership of each held lock between calls to AcquireLock
+func (l *Locker) renewHeldLocks() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.cfg.LockDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.renew()
+
+		case <-l.shutdown:
+			return
+		}
+	}
+}
+
+func (l *Locker) renew() {
+	for _, key := range l.heldKeys() {
+		ctx, cancel := context.WithTimeout(context.Background(), l.client.cfg.Timeout)
+		renewed, err := l.eval(ctx, renewScript, key, l.cfg.LockDuration.Milliseconds())
+		cancel()
+
+		if err != nil || !renewed {
+			l.logger.Warn().Logf("lost lock %s: renewed=%v error=%v", key, renewed, err)
+			l.mu.Lock()
+			l.held[key] = false
+			l.mu.Unlock()
+		}
+	}
+}
+
+// Shutdown stops renewing and releases every held lock so another replica can take over
+func (l *Locker) Shutdown() {
+	if l == nil {
+		return
+	}
+	l.shutdownOnce.Do(func() {
+		close(l.shutdown)
+	})
+	l.wg.Wait()
+
+	for _, key := range l.heldKeys() {
+		ctx, cancel := context.WithTimeout(context.Background(), l.client.cfg.Timeout)
+		if _, err := l.eval(ctx, releaseScript, key); err != nil {
+			l.logger.Warn().Logf("releasing lock %s: %v", key, err)
+		}
+		cancel()
+
+		l.mu.Lock()
+		l.held[key] = false
+		l.mu.Unlock()
+	}
+}
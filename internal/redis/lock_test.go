@@ -0,0 +1,60 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/redis"
+	"github.com/moov-io/achgateway/internal/redis/redistest"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocker(t *testing.T) {
+	client, server := redistest.NewClient(t, redis.Config{})
+	logger := log.NewTestLogger()
+
+	a, err := redis.NewLocker(logger, client, &redis.LockConfig{Identity: "a", LockDuration: time.Minute})
+	require.NoError(t, err)
+	t.Cleanup(a.Shutdown)
+	b, err := redis.NewLocker(logger, client, &redis.LockConfig{Identity: "b", LockDuration: time.Minute})
+	require.NoError(t, err)
+	t.Cleanup(b.Shutdown)
+
+	require.NoError(t, a.AcquireLock("shard-1"))
+	require.NoError(t, a.AcquireLock("shard-1"), "held locks are renewed")
+	require.EqualError(t, b.AcquireLock("shard-1"), "we are not the leader of shard-1 (held by a)")
+	require.NoError(t, b.AcquireLock("shard-2"))
+
+	// Locks which aren't renewed are taken over
+	server.FastForward(time.Minute)
+	require.NoError(t, b.AcquireLock("shard-1"))
+	require.Error(t, a.AcquireLock("shard-1"))
+
+	// Shutdown releases held locks
+	b.Shutdown()
+	require.NoError(t, a.AcquireLock("shard-1"))
+	require.NoError(t, a.AcquireLock("shard-2"))
+	require.Equal(t, "a", a.Identity())
+
+	_, err = redis.NewLocker(logger, nil, &redis.LockConfig{})
+	require.ErrorContains(t, err, "require a redis client")
+}
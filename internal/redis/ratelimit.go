@@ -0,0 +1,79 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// RateLimiter counts requests for each key in fixed windows of Interval, shared by every
+// replica using the same Redis server.
+type RateLimiter struct {
+	client   *Client
+	name     string
+	requests int
+	interval time.Duration
+}
+
+// RateLimiter returns the RateLimiter called name which allows requests for each key per interval
+func (c *Client) RateLimiter(name string, requests int, interval time.Duration) *RateLimiter {
+	return &RateLimiter{
+		client:   c,
+		name:     name,
+		requests: requests,
+		interval: interval,
+	}
+}
+
+// Allow counts a request for key. Requests past the limit aren't allowed and are returned how
+// long until the window resets.
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rl.client.cfg.Timeout)
+	defer cancel()
+
+	k := rl.client.Key("ratelimit:" + rl.name + ":" + key)
+
+	// The counter is created with the window's expiration so INCR keeps it
+	var incr *goredis.IntCmd
+	var pttl *goredis.DurationCmd
+	_, err := rl.client.rdb.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.SetNX(ctx, k, 0, rl.interval)
+		incr = pipe.Incr(ctx, k)
+		pttl = pipe.PTTL(ctx, k)
+		return nil
+	})
+	if err != nil {
+		return false, 0, err
+	}
+
+	// A counter which expired between SET and INCR was created without an expiration
+	retryAfter := pttl.Val()
+	if retryAfter < 0 {
+		if err := rl.client.rdb.PExpire(ctx, k, rl.interval).Err(); err != nil {
+			return false, 0, err
+		}
+		retryAfter = rl.interval
+	}
+	if incr.Val() > int64(rl.requests) {
+		return false, retryAfter, nil
+	}
+	return true, 0, nil
+}
@@ -0,0 +1,50 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package redistest connects redis clients to miniredis, an in-memory Redis server, so tests
+// don't need a Redis server running.
+package redistest
+
+import (
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/redis"
+	"github.com/moov-io/base/log"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// NewClient returns a redis.Client connected to a new miniredis server which requires the
+// config's credentials. Both are closed when the test finishes.
+func NewClient(t *testing.T, cfg redis.Config) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	switch {
+	case cfg.Username != "":
+		server.RequireUserAuth(cfg.Username, cfg.Password)
+	case cfg.Password != "":
+		server.RequireAuth(cfg.Password)
+	}
+	cfg.Address = server.Addr()
+
+	client, err := redis.NewClient(log.NewTestLogger(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client, server
+}
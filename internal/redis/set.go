@@ -0,0 +1,65 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+// Set remembers members for TTL after each is added. Members are kept as their own keys so
+// each one expires separately, which a Redis set can't do.
+type Set struct {
+	client *Client
+	name   string
+	ttl    time.Duration
+}
+
+// Set returns the Set called name
+func (c *Client) Set(name string, ttl time.Duration) *Set {
+	return &Set{client: c, name: name, ttl: ttl}
+}
+
+func (s *Set) key(member string) string {
+	return "set:" + s.name + ":" + member
+}
+
+// Contains returns true when member was added within the Set's TTL
+func (s *Set) Contains(member string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.cfg.Timeout)
+	defer cancel()
+
+	_, found, err := s.client.Get(ctx, s.key(member))
+	return found, err
+}
+
+// Add remembers member, returning false when it was already in the Set
+func (s *Set) Add(member string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.cfg.Timeout)
+	defer cancel()
+
+	return s.client.SetNX(ctx, s.key(member), "1", s.ttl)
+}
+
+// Remove forgets member, such as after what it reserved failed
+func (s *Set) Remove(member string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.cfg.Timeout)
+	defer cancel()
+
+	return s.client.Del(ctx, s.key(member))
+}
@@ -0,0 +1,96 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package rejections keeps ACH files rejected when they were submitted, so operators can review
+// why, fix them and submit them again, or discard them.
+package rejections
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/achgateway/pkg/models"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/base"
+)
+
+const (
+	StatusRejected    = "rejected"
+	StatusResubmitted = "resubmitted"
+	StatusDiscarded   = "discarded"
+)
+
+// Rejection is a submitted file which wasn't accepted along with the reasons it was rejected
+type Rejection struct {
+	ID        string `json:"rejectionID"`
+	FileID    string `json:"fileID"`
+	ShardKey  string `json:"shardKey"`
+	ShardName string `json:"shardName,omitempty"`
+	Status    string `json:"status"`
+
+	Reasons  []models.RejectionReason `json:"reasons"`
+	Metadata map[string]string        `json:"metadata,omitempty"`
+
+	// DiscardReason is why an operator discarded the file
+	DiscardReason string `json:"discardReason,omitempty"`
+
+	CreatedAt  time.Time  `json:"createdAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+
+	// Contents is the file in Nacha format, which is removed once the file is resubmitted or discarded
+	Contents []byte `json:"-"`
+
+	// ValidateOpts is how the file was validated when it was submitted
+	ValidateOpts *ach.ValidateOpts `json:"-"`
+}
+
+// New keeps file for review. Invalid files are written as they are.
+func New(fileID, shardKey, shardName string, file *ach.File, metadata map[string]string, reasons []models.RejectionReason) (*Rejection, error) {
+	var buf bytes.Buffer
+	w := ach.NewWriter(&buf)
+	w.BypassValidation = true
+	if err := w.Write(file); err != nil {
+		return nil, fmt.Errorf("writing rejected file %s: %v", fileID, err)
+	}
+	return &Rejection{
+		ID:           base.ID(),
+		FileID:       fileID,
+		ShardKey:     shardKey,
+		ShardName:    shardName,
+		Status:       StatusRejected,
+		Reasons:      reasons,
+		Metadata:     metadata,
+		Contents:     buf.Bytes(),
+		ValidateOpts: file.GetValidation(),
+	}, nil
+}
+
+// File reads contents, such as the edited contents of a rejected file, with the ValidateOpts the
+// file was submitted with. Files which are still invalid return an error.
+func (r *Rejection) File(contents []byte) (*ach.File, error) {
+	reader := ach.NewReader(bytes.NewReader(contents))
+	if r.ValidateOpts != nil {
+		reader.SetValidation(r.ValidateOpts)
+	}
+	file, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading rejected file %s: %v", r.FileID, err)
+	}
+	return &file, nil
+}
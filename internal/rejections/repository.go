@@ -0,0 +1,213 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package rejections
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moov-io/ach"
+)
+
+type Repository interface {
+	// Save keeps a rejected file for review
+	Save(rej *Rejection) error
+
+	// Get returns the rejection, or nil
+	Get(id string) (*Rejection, error)
+
+	// List returns up to limit rejections waiting for review, most recent first
+	List(limit int) ([]*Rejection, error)
+
+	// Resubmit takes a rejection waiting for review and removes its contents, returning false
+	// when it was already resubmitted or discarded
+	Resubmit(id string) (bool, error)
+
+	// Release makes a rejection taken with Resubmit wait for review again with contents, for
+	// when submitting it failed
+	Release(id string, contents []byte) error
+
+	// Discard removes the contents of a rejection waiting for review and records why, returning
+	// false when it was already resubmitted or discarded
+	Discard(id, reason string) (bool, error)
+
+	// RedactContents calls redact with the ID and contents of each rejection waiting for review
+	// and saves the contents it returns when changed, for erasure requests
+	RedactContents(redact func(key string, contents []byte) ([]byte, bool)) error
+}
+
+// NewRepository returns a Repository stored in db, or nil when db is nil
+func NewRepository(db *sql.DB) Repository {
+	if db == nil {
+		return nil
+	}
+	return &sqlRepository{db: db, now: time.Now}
+}
+
+type sqlRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func (r *sqlRepository) Save(rej *Rejection) error {
+	if rej == nil || rej.ID == "" {
+		return errors.New("missing rejectionID")
+	}
+	reasons, err := json.Marshal(rej.Reasons)
+	if err != nil {
+		return fmt.Errorf("encoding rejection %s reasons: %v", rej.ID, err)
+	}
+	metadata, err := json.Marshal(rej.Metadata)
+	if err != nil {
+		return fmt.Errorf("encoding rejection %s metadata: %v", rej.ID, err)
+	}
+	opts, err := json.Marshal(rej.ValidateOpts)
+	if err != nil {
+		return fmt.Errorf("encoding rejection %s ValidateOpts: %v", rej.ID, err)
+	}
+	rej.CreatedAt = r.timestamp()
+
+	query := `insert into rejected_files (rejection_id, file_id, shard_key, shard_name, status, reasons, metadata, contents, validate_opts, discard_reason, created_at)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, '', ?);`
+	_, err = r.db.Exec(query, rej.ID, rej.FileID, rej.ShardKey, rej.ShardName, StatusRejected, string(reasons), string(metadata), string(rej.Contents), string(opts), rej.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("saving rejection %s: %v", rej.ID, err)
+	}
+	return nil
+}
+
+const selectRejections = `select rejection_id, file_id, shard_key, shard_name, status, reasons, metadata, contents, validate_opts, discard_reason, created_at, resolved_at from rejected_files`
+
+func (r *sqlRepository) Get(id string) (*Rejection, error) {
+	rows, err := r.db.Query(selectRejections+` where rejection_id = ? limit 1;`, strings.TrimSpace(id))
+	if err != nil {
+		return nil, fmt.Errorf("reading rejection %s: %v", id, err)
+	}
+	found, err := scanRejections(rows)
+	if err != nil {
+		return nil, fmt.Errorf("reading rejection %s: %v", id, err)
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return found[0], nil
+}
+
+func (r *sqlRepository) List(limit int) ([]*Rejection, error) {
+	rows, err := r.db.Query(selectRejections+` where status = ? order by created_at desc limit ?;`, StatusRejected, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing rejections: %v", err)
+	}
+	found, err := scanRejections(rows)
+	if err != nil {
+		return nil, fmt.Errorf("listing rejections: %v", err)
+	}
+	return found, nil
+}
+
+func scanRejections(rows *sql.Rows) ([]*Rejection, error) {
+	defer rows.Close()
+
+	var out []*Rejection
+	for rows.Next() {
+		var rej Rejection
+		var reasons, metadata, contents, opts string
+		var resolved sql.NullTime
+		err := rows.Scan(&rej.ID, &rej.FileID, &rej.ShardKey, &rej.ShardName, &rej.Status, &reasons, &metadata, &contents, &opts, &rej.DiscardReason, &rej.CreatedAt, &resolved)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(reasons), &rej.Reasons); err != nil {
+			return nil, fmt.Errorf("rejection %s reasons: %v", rej.ID, err)
+		}
+		if err := json.Unmarshal([]byte(metadata), &rej.Metadata); err != nil {
+			return nil, fmt.Errorf("rejection %s metadata: %v", rej.ID, err)
+		}
+		var validateOpts *ach.ValidateOpts
+		if err := json.Unmarshal([]byte(opts), &validateOpts); err != nil {
+			return nil, fmt.Errorf("rejection %s ValidateOpts: %v", rej.ID, err)
+		}
+		rej.ValidateOpts = validateOpts
+		rej.Contents = []byte(contents)
+		if resolved.Valid {
+			rej.ResolvedAt = &resolved.Time
+		}
+		out = append(out, &rej)
+	}
+	return out, rows.Err()
+}
+
+func (r *sqlRepository) Resubmit(id string) (bool, error) {
+	query := `update rejected_files set status = ?, contents = '', resolved_at = ? where rejection_id = ? and status = ?;`
+	return r.resolve(id, query, StatusResubmitted, r.timestamp(), id, StatusRejected)
+}
+
+func (r *sqlRepository) Release(id string, contents []byte) error {
+	query := `update rejected_files set status = ?, contents = ?, resolved_at = null where rejection_id = ?;`
+	if _, err := r.db.Exec(query, StatusRejected, string(contents), id); err != nil {
+		return fmt.Errorf("releasing rejection %s: %v", id, err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) Discard(id, reason string) (bool, error) {
+	query := `update rejected_files set status = ?, contents = '', discard_reason = ?, resolved_at = ? where rejection_id = ? and status = ?;`
+	return r.resolve(id, query, StatusDiscarded, reason, r.timestamp(), id, StatusRejected)
+}
+
+func (r *sqlRepository) resolve(id, query string, args ...interface{}) (bool, error) {
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		return false, fmt.Errorf("resolving rejection %s: %v", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("resolving rejection %s: %v", id, err)
+	}
+	return n > 0, nil
+}
+
+func (r *sqlRepository) RedactContents(redact func(key string, contents []byte) ([]byte, bool)) error {
+	rows, err := r.db.Query(selectRejections+` where status = ?;`, StatusRejected)
+	if err != nil {
+		return fmt.Errorf("reading rejections: %v", err)
+	}
+	found, err := scanRejections(rows)
+	if err != nil {
+		return fmt.Errorf("reading rejections: %v", err)
+	}
+	for _, rej := range found {
+		redacted, changed := redact(rej.ID, rej.Contents)
+		if !changed {
+			continue
+		}
+		if _, err := r.db.Exec(`update rejected_files set contents = ? where rejection_id = ?;`, string(redacted), rej.ID); err != nil {
+			return fmt.Errorf("redacting rejection %s: %v", rej.ID, err)
+		}
+	}
+	return nil
+}
+
+// timestamp is the current time in UTC with the precision DATETIME columns store
+func (r *sqlRepository) timestamp() time.Time {
+	return r.now().UTC().Truncate(time.Second)
+}
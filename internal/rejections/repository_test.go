@@ -0,0 +1,124 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package rejections
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/pkg/models"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository(t *testing.T) {
+	require.Nil(t, NewRepository(nil))
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	repo := NewRepository(db.DB).(*sqlRepository)
+	now := time.Date(2022, time.October, 14, 10, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	file.SetValidation(&ach.ValidateOpts{AllowMissingFileControl: true})
+	file.Batches[0].GetEntries()[0].DFIAccountNumber = ""
+
+	// Invalid files are kept as they are
+	rej, err := New("f1", "testing", "live", file, map[string]string{"batch": "payroll"}, []models.RejectionReason{
+		{Code: models.RejectionInvalidFile, Message: "missing DFIAccountNumber"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.Save(rej))
+
+	found, err := repo.Get(rej.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusRejected, found.Status)
+	require.Equal(t, "live", found.ShardName)
+	require.Equal(t, "payroll", found.Metadata["batch"])
+	require.Equal(t, rej.Reasons, found.Reasons)
+	require.Equal(t, rej.Contents, found.Contents)
+	require.True(t, found.ValidateOpts.AllowMissingFileControl)
+	require.Equal(t, now, found.CreatedAt)
+
+	// Files are read with the ValidateOpts they were submitted with
+	contents, err := found.File(found.Contents)
+	require.NoError(t, err)
+	require.True(t, contents.GetValidation().AllowMissingFileControl)
+	_, err = found.File([]byte("invalid"))
+	require.ErrorContains(t, err, "reading rejected file f1")
+
+	missing, err := repo.Get("missing")
+	require.NoError(t, err)
+	require.Nil(t, missing)
+
+	listed, err := repo.List(10)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+
+	// Resubmitted files are taken once
+	taken, err := repo.Resubmit(rej.ID)
+	require.NoError(t, err)
+	require.True(t, taken)
+	taken, err = repo.Resubmit(rej.ID)
+	require.NoError(t, err)
+	require.False(t, taken)
+
+	found, err = repo.Get(rej.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusResubmitted, found.Status)
+	require.Empty(t, found.Contents)
+	require.Equal(t, now, *found.ResolvedAt)
+
+	listed, err = repo.List(10)
+	require.NoError(t, err)
+	require.Empty(t, listed)
+
+	// Files which couldn't be resubmitted wait for review again
+	require.NoError(t, repo.Release(rej.ID, rej.Contents))
+	found, err = repo.Get(rej.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusRejected, found.Status)
+	require.Equal(t, rej.Contents, found.Contents)
+	require.Nil(t, found.ResolvedAt)
+
+	err = repo.RedactContents(func(key string, contents []byte) ([]byte, bool) {
+		require.Equal(t, rej.ID, key)
+		return []byte("redacted"), true
+	})
+	require.NoError(t, err)
+
+	discarded, err := repo.Discard(rej.ID, "sent again as f2")
+	require.NoError(t, err)
+	require.True(t, discarded)
+	discarded, err = repo.Discard(rej.ID, "sent again as f2")
+	require.NoError(t, err)
+	require.False(t, discarded)
+
+	found, err = repo.Get(rej.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusDiscarded, found.Status)
+	require.Equal(t, "sent again as f2", found.DiscardReason)
+	require.Empty(t, found.Contents)
+}
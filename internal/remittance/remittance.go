@@ -0,0 +1,160 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package remittance parses the addenda of CCD and CTX entries, which often carry EDI 820 (or
+// STP 820) remittance advice describing the invoices an entry pays.
+package remittance
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/pkg/models"
+)
+
+// File returns the remittance of each CCD and CTX entry in file with Addenda05 records
+func File(file *ach.File) []models.EntryRemittance {
+	if file == nil {
+		return nil
+	}
+	var out []models.EntryRemittance
+	for _, batch := range file.Batches {
+		secCode := batch.GetHeader().StandardEntryClassCode
+		if secCode != ach.CCD && secCode != ach.CTX {
+			continue
+		}
+		for _, entry := range batch.GetEntries() {
+			if len(entry.Addenda05) == 0 {
+				continue
+			}
+			var text strings.Builder
+			for _, addenda := range entry.Addenda05 {
+				text.WriteString(addenda.PaymentRelatedInformation)
+			}
+			rem := Parse(text.String())
+			rem.EntryID = entry.ID
+			rem.TraceNumber = entry.TraceNumber
+			rem.SECCode = secCode
+			rem.Amount = entry.Amount
+			out = append(out, rem)
+		}
+	}
+	return out
+}
+
+// Parse reads the EDI 820 segments of text. Segments end with ~ or \ and elements are separated
+// by *. Text which isn't EDI is only returned as the Text of the remittance.
+func Parse(text string) models.EntryRemittance {
+	out := models.EntryRemittance{
+		Text: strings.TrimSpace(text),
+	}
+	var invoice *models.RemittanceInvoice
+	for _, segment := range segments(out.Text) {
+		elements := strings.Split(segment, "*")
+		element := func(i int) string {
+			if i < len(elements) {
+				return strings.TrimSpace(elements[i])
+			}
+			return ""
+		}
+		switch strings.ToUpper(element(0)) {
+		case "TRN":
+			out.ReferenceNumber = element(2)
+
+		case "N1":
+			switch element(1) {
+			case "PR":
+				out.Payer = element(2)
+			case "PE":
+				out.Payee = element(2)
+			}
+
+		case "RMR":
+			out.Invoices = append(out.Invoices, models.RemittanceInvoice{
+				Qualifier:      element(1),
+				Number:         element(2),
+				PaidAmount:     amount(element(4)),
+				InvoiceAmount:  amount(element(5)),
+				DiscountAmount: amount(element(6)),
+			})
+			invoice = &out.Invoices[len(out.Invoices)-1]
+
+		case "REF":
+			ref := models.RemittanceReference{
+				Qualifier:   element(1),
+				Value:       element(2),
+				Description: element(3),
+			}
+			// References following an RMR segment belong to that invoice
+			if invoice != nil {
+				invoice.References = append(invoice.References, ref)
+			} else {
+				out.References = append(out.References, ref)
+			}
+
+		case "DTM":
+			if invoice != nil && element(1) == "003" {
+				invoice.Date = date(element(2))
+			}
+		}
+	}
+	return out
+}
+
+func segments(text string) []string {
+	if !strings.Contains(text, "*") {
+		return nil
+	}
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == '~' || r == '\\' || r == '\n'
+	})
+}
+
+// amount converts a decimal dollar amount (like 1234.5) into cents, returning zero when invalid
+func amount(value string) int {
+	negative := strings.HasPrefix(value, "-")
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(value, "-"), ".")
+	if len(frac) > 2 {
+		return 0
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	cents, err := strconv.Atoi(whole + frac + strings.Repeat("0", 2-len(frac)))
+	if err != nil {
+		return 0
+	}
+	if negative {
+		return -cents
+	}
+	return cents
+}
+
+// date converts CCYYMMDD or YYMMDD into YYYY-MM-DD
+func date(value string) string {
+	layout := "20060102"
+	if len(value) == 6 {
+		layout = "060102"
+	}
+	when, err := time.Parse(layout, value)
+	if err != nil {
+		return ""
+	}
+	return when.Format("2006-01-02")
+}
@@ -0,0 +1,112 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package remittance
+
+import (
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	text := "ISA*00*          *00*          *ZZ*ACME           *ZZ*BIGCO          *220601*1200*U*00401*000000001*0*P*>~" +
+		"ST*820*0001~BPR*C*1500.5*C*ACH*CTX~TRN*1*REASSOC123~N1*PR*ACME CORP~N1*PE*BIG CO~REF*VV*VENDOR-9~" +
+		"RMR*IV*INV-1**1000.5*1100*99.5~DTM*003*20220515~REF*PO*PO-77*Widgets~" +
+		"RMR*IV*INV-2**500~SE*11*0001~"
+
+	rem := Parse(text)
+	require.Equal(t, "REASSOC123", rem.ReferenceNumber)
+	require.Equal(t, "ACME CORP", rem.Payer)
+	require.Equal(t, "BIG CO", rem.Payee)
+	require.Equal(t, []models.RemittanceReference{{Qualifier: "VV", Value: "VENDOR-9"}}, rem.References)
+
+	require.Len(t, rem.Invoices, 2)
+	require.Equal(t, models.RemittanceInvoice{
+		Qualifier:      "IV",
+		Number:         "INV-1",
+		PaidAmount:     100050,
+		InvoiceAmount:  110000,
+		DiscountAmount: 9950,
+		Date:           "2022-05-15",
+		References: []models.RemittanceReference{
+			{Qualifier: "PO", Value: "PO-77", Description: "Widgets"},
+		},
+	}, rem.Invoices[0])
+	require.Equal(t, 50000, rem.Invoices[1].PaidAmount)
+}
+
+func TestParse__CCDPlus(t *testing.T) {
+	rem := Parse(`RMR*IV*0123456789**-12.34\ `)
+	require.Equal(t, "RMR*IV*0123456789**-12.34\\", rem.Text)
+	require.Len(t, rem.Invoices, 1)
+	require.Equal(t, "0123456789", rem.Invoices[0].Number)
+	require.Equal(t, -1234, rem.Invoices[0].PaidAmount)
+
+	// Free form text is kept as-is
+	rem = Parse("Invoice 1234 for May")
+	require.Equal(t, "Invoice 1234 for May", rem.Text)
+	require.Empty(t, rem.Invoices)
+}
+
+func TestFile(t *testing.T) {
+	bh := ach.NewBatchHeader()
+	bh.ServiceClassCode = ach.CreditsOnly
+	bh.StandardEntryClassCode = ach.CCD
+	bh.CompanyName = "Acme Corp"
+	bh.CompanyIdentification = "121042882"
+	bh.CompanyEntryDescription = "PAYMENT"
+	bh.EffectiveEntryDate = "220601"
+	bh.ODFIIdentification = "12104288"
+
+	entry := ach.NewEntryDetail()
+	entry.ID = "entry1"
+	entry.TransactionCode = ach.CheckingCredit
+	entry.SetRDFI("231380104")
+	entry.DFIAccountNumber = "12345"
+	entry.Amount = 100050
+	entry.IndividualName = "Big Co"
+	entry.SetTraceNumber("12104288", 1)
+
+	addenda := ach.NewAddenda05()
+	addenda.PaymentRelatedInformation = "RMR*IV*INV-1**1000.5~"
+	addenda.SequenceNumber = 1
+	addenda.EntryDetailSequenceNumber = 1
+	entry.AddAddenda05(addenda)
+	entry.AddendaRecordIndicator = 1
+
+	batch, err := ach.NewBatch(bh)
+	require.NoError(t, err)
+	batch.AddEntry(entry)
+	require.NoError(t, batch.Create())
+
+	file := ach.NewFile()
+	file.AddBatch(batch)
+
+	remittances := File(file)
+	require.Len(t, remittances, 1)
+	require.Equal(t, "entry1", remittances[0].EntryID)
+	require.Equal(t, "121042880000001", remittances[0].TraceNumber)
+	require.Equal(t, ach.CCD, remittances[0].SECCode)
+	require.Equal(t, 100050, remittances[0].Amount)
+	require.Equal(t, "INV-1", remittances[0].Invoices[0].Number)
+
+	require.Empty(t, File(nil))
+}
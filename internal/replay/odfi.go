@@ -0,0 +1,115 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package replay
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/audittrail"
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/incoming/odfi"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+	"github.com/moov-io/cryptfs"
+)
+
+// replayODFI passes each ODFI file saved in the audit trail between opts.from and opts.to through
+// the configured processors again. Files are saved as odfi/$hostname/$dir/$date/$filename where
+// hostname belongs to the shard's upload agent.
+func replayODFI(logger log.Logger, cfg *service.Config, shard service.Shard, opts options, emitter events.Emitter) error {
+	odfiConfig := cfg.Inbound.ODFI
+	if odfiConfig == nil || odfiConfig.Audit == nil {
+		return fmt.Errorf("odfi: no audit trail configured to replay files from")
+	}
+	agent := cfg.Upload.Find(shard.UploadAgent)
+	if agent == nil {
+		return fmt.Errorf("odfi: upload agent %s not found", shard.UploadAgent)
+	}
+
+	auditStorage, err := audittrail.NewStorage(odfiConfig.Audit)
+	if err != nil {
+		return fmt.Errorf("odfi: audit: %w", err)
+	}
+	defer auditStorage.Close()
+
+	var decryptor *cryptfs.FS
+	if odfiConfig.Audit.GPG != nil {
+		if opts.gpgPrivateKey == "" {
+			return fmt.Errorf("odfi: audit trail files are encrypted, -gpg-private-key is required")
+		}
+		decryptor, err = cryptfs.FromCryptor(cryptfs.NewGPGDecryptorFile(opts.gpgPrivateKey, []byte(opts.gpgPassword)))
+		if err != nil {
+			return fmt.Errorf("odfi: reading gpg private key: %w", err)
+		}
+	}
+
+	processors := odfi.SetupProcessors(
+		odfi.CorrectionEmitter(logger, odfiConfig.Processors.Corrections, emitter),
+		odfi.PrenoteEmitter(logger, odfiConfig.Processors.Prenotes, emitter),
+		odfi.CreditReconciliationEmitter(logger, odfiConfig.Processors.Reconciliation, emitter),
+		odfi.ReturnEmitter(logger, odfiConfig.Processors.Returns, emitter),
+		odfi.IncomingEmitter(logger, odfiConfig.Processors.Incoming, odfiConfig.Processors.Reconciliation, emitter),
+	)
+
+	paths, err := auditStorage.ListFiles(path.Join("odfi", agent.Hostname()) + "/")
+	if err != nil {
+		return fmt.Errorf("odfi: %w", err)
+	}
+	sort.Strings(paths)
+
+	for _, where := range paths {
+		// The date is the directory holding the file
+		when, err := time.ParseInLocation("2006-01-02", path.Base(path.Dir(where)), time.Local)
+		if err != nil || !opts.containsDay(when) {
+			continue
+		}
+
+		bs, err := readAuditFile(auditStorage, where, decryptor)
+		if err != nil {
+			return fmt.Errorf("odfi: %w", err)
+		}
+		if err := odfi.Reprocess(where, bs, processors); err != nil {
+			return fmt.Errorf("odfi: %w", err)
+		}
+	}
+	return nil
+}
+
+func readAuditFile(auditStorage audittrail.Storage, where string, decryptor *cryptfs.FS) ([]byte, error) {
+	file, err := auditStorage.GetFile(where)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	bs, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", where, err)
+	}
+	if decryptor != nil {
+		bs, err = decryptor.Reveal(bs)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting %s: %w", where, err)
+		}
+	}
+	return bs, nil
+}
@@ -0,0 +1,232 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package replay implements `achgateway events replay` which publishes events again for files
+// achgateway has already handled, so downstream consumers can recover after losing data.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/signing"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+)
+
+const usage = `Usage: achgateway events replay -shard <name> -from <date> [flags]
+
+Events are derived from files on storage and in the audit trail:
+  uploaded  FileUploaded events for files merged by cutoffs on this instance's storage
+  odfi      Correction, return, prenote, reconciliation and incoming events for ODFI files in the audit trail
+`
+
+// Run executes the events subcommand named by args[0] and writes its output to out.
+func Run(args []string, out io.Writer, logger log.Logger, loadConfig service.ConfigLoader) error {
+	if len(args) == 0 || args[0] != "replay" {
+		fmt.Fprint(out, usage)
+		if len(args) == 0 {
+			return errors.New("missing command")
+		}
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	fs := flag.NewFlagSet("achgateway events replay", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprint(out, usage+"\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	var opts options
+	fs.StringVar(&opts.shardName, "shard", "", "Shard name to replay events for")
+	flagFrom := fs.String("from", "", "Replay files handled at or after this date (2006-01-02) or time (RFC3339)")
+	flagTo := fs.String("to", "", "Replay files handled up to and including this date or time, defaults to now")
+	flagSources := fs.String("sources", "uploaded,odfi", "Comma separated sources to replay events from")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "Print events instead of publishing them")
+	fs.StringVar(&opts.gpgPrivateKey, "gpg-private-key", "", "Private key to decrypt audit trail files with, when Audit.GPG is configured")
+	fs.StringVar(&opts.gpgPassword, "gpg-password", "", "Password for -gpg-private-key")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if opts.shardName == "" {
+		return errors.New("missing -shard")
+	}
+	var err error
+	opts.from, opts.to, err = parseRange(*flagFrom, *flagTo, time.Now())
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	shard := cfg.Sharding.Find(opts.shardName)
+	if shard == nil {
+		return fmt.Errorf("shard %s not found", opts.shardName)
+	}
+
+	emitter, err := newEmitter(logger, cfg, out, opts.dryRun)
+	if err != nil {
+		return err
+	}
+	counter := &countingEmitter{underlying: emitter}
+
+	var runErr error
+	for _, source := range strings.Split(*flagSources, ",") {
+		switch strings.TrimSpace(source) {
+		case "uploaded":
+			runErr = replayUploaded(cfg.Upload, *shard, opts, counter)
+		case "odfi":
+			runErr = replayODFI(logger, cfg, *shard, opts, counter)
+		default:
+			runErr = fmt.Errorf("unknown source %q", source)
+		}
+		if runErr != nil {
+			break
+		}
+	}
+
+	// Wait for events to be published before reporting what was sent
+	if err := emitter.Close(); err != nil && runErr == nil {
+		runErr = fmt.Errorf("closing emitter: %w", err)
+	}
+	fmt.Fprintf(out, "replayed %d events for shard %s between %s and %s\n", counter.sent, opts.shardName,
+		opts.from.Format(time.RFC3339), opts.to.Format(time.RFC3339))
+
+	return runErr
+}
+
+type options struct {
+	shardName string
+	from, to  time.Time
+	dryRun    bool
+
+	gpgPrivateKey string
+	gpgPassword   string
+}
+
+func (opts options) contains(when time.Time) bool {
+	return !when.Before(opts.from) && !when.After(opts.to)
+}
+
+// containsDay reports if any part of the day starting at day is within the range. Audit trail
+// files are only saved with the date they were handled.
+func (opts options) containsDay(day time.Time) bool {
+	return day.AddDate(0, 0, 1).After(opts.from) && !day.After(opts.to)
+}
+
+// parseRange reads -from and -to as dates or RFC3339 timestamps. Dates given for -to are
+// inclusive, so they're moved to the end of that day.
+func parseRange(from, to string, now time.Time) (time.Time, time.Time, error) {
+	if from == "" {
+		return time.Time{}, time.Time{}, errors.New("missing -from")
+	}
+	start, _, err := parseTime(from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("-from: %w", err)
+	}
+
+	end := now
+	if to != "" {
+		var isDate bool
+		end, isDate, err = parseTime(to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("-to: %w", err)
+		}
+		if isDate {
+			end = end.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, errors.New("-to is before -from")
+	}
+	return start, end, nil
+}
+
+func parseTime(value string) (time.Time, bool, error) {
+	if when, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return when, true, nil
+	}
+	when, err := time.Parse(time.RFC3339, value)
+	return when, false, err
+}
+
+func newEmitter(logger log.Logger, cfg *service.Config, out io.Writer, dryRun bool) (events.Emitter, error) {
+	if dryRun {
+		return &printingEmitter{out: out}, nil
+	}
+	if cfg.Events == nil {
+		return nil, errors.New("no Events configured to publish to, use -dry-run to print events")
+	}
+
+	// Replayed events are signed with the same keys as when they were first sent
+	var keys *signing.Keyring
+	if cfg.Events.Signing != nil {
+		db, err := database.New(context.Background(), logger, cfg.Database)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to database for signing keys: %w", err)
+		}
+		keys = signing.NewKeyring(signing.NewRepository(db), cfg.Events.Signing)
+	}
+	emitter, err := events.NewEmitter(logger, cfg.Events, keys)
+	if err != nil {
+		return nil, fmt.Errorf("creating events emitter: %w", err)
+	}
+	return emitter, nil
+}
+
+// printingEmitter writes each event as a line of JSON for -dry-run.
+type printingEmitter struct {
+	out io.Writer
+}
+
+func (e *printingEmitter) Send(evt models.Event) error {
+	return json.NewEncoder(e.out).Encode(evt)
+}
+
+func (e *printingEmitter) Close() error {
+	return nil
+}
+
+type countingEmitter struct {
+	underlying events.Emitter
+	sent       int
+}
+
+func (e *countingEmitter) Send(evt models.Event) error {
+	if err := e.underlying.Send(evt); err != nil {
+		return err
+	}
+	e.sent++
+	return nil
+}
+
+func (e *countingEmitter) Close() error {
+	return e.underlying.Close()
+}
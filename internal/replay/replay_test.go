@@ -0,0 +1,186 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package replay
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/audittrail"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRange(t *testing.T) {
+	now := time.Date(2022, time.October, 14, 12, 0, 0, 0, time.Local)
+
+	from, to, err := parseRange("2022-10-13", "", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2022, time.October, 13, 0, 0, 0, 0, time.Local), from)
+	require.Equal(t, now, to)
+
+	// Dates for -to include the whole day
+	_, to, err = parseRange("2022-10-13", "2022-10-13", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2022, time.October, 13, 23, 59, 59, 999999999, time.Local), to)
+
+	from, to, err = parseRange("2022-10-13T10:00:00Z", "2022-10-13T11:00:00Z", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, to.Sub(from))
+
+	_, _, err = parseRange("", "", now)
+	require.ErrorContains(t, err, "missing -from")
+
+	_, _, err = parseRange("2022-10-14", "2022-10-13", now)
+	require.ErrorContains(t, err, "-to is before -from")
+
+	_, _, err = parseRange("yesterday", "", now)
+	require.ErrorContains(t, err, "-from")
+}
+
+func testConfig(t *testing.T) *service.Config {
+	t.Helper()
+
+	cfg := &service.Config{
+		Sharding: service.Sharding{
+			Shards: []service.Shard{
+				{
+					Name:        "testing",
+					UploadAgent: "mock",
+				},
+			},
+		},
+		Upload: service.UploadAgents{
+			Agents: []service.UploadAgent{
+				{
+					ID:   "mock",
+					Mock: &service.MockAgent{},
+				},
+			},
+		},
+	}
+	cfg.Upload.Merging.Storage.Filesystem.Directory = t.TempDir()
+	return cfg
+}
+
+func TestReplay__Uploaded(t *testing.T) {
+	cfg := testConfig(t)
+
+	chest, err := storage.NewFilesystem(cfg.Upload.Merging.Storage.Filesystem.Directory)
+	require.NoError(t, err)
+
+	// Files merged and uploaded by a cutoff
+	require.NoError(t, chest.WriteFile("testing-20221013-103000/first.ach", []byte("first")))
+	require.NoError(t, chest.WriteFile("testing-20221013-103000/second.ach", []byte("second")))
+	require.NoError(t, chest.WriteFile("testing-20221013-103000/canceled.ach", []byte("canceled")))
+	require.NoError(t, chest.WriteFile("testing-20221013-103000/canceled.ach.canceled", []byte("canceled")))
+	require.NoError(t, chest.WriteFile("testing-20221013-103000/uploaded/merged.ach", []byte("merged")))
+
+	// A cutoff outside of the range and a pending file
+	require.NoError(t, chest.WriteFile("testing-20221010-103000/old.ach", []byte("old")))
+	require.NoError(t, chest.WriteFile("testing-20221010-103000/uploaded/merged.ach", []byte("merged")))
+	require.NoError(t, chest.WriteFile("mergable/testing/pending.ach", []byte("pending")))
+
+	loadConfig := func() (*service.Config, error) { return cfg, nil }
+
+	var buf bytes.Buffer
+	args := []string{"replay", "-shard", "testing", "-from", "2022-10-13", "-to", "2022-10-13", "-sources", "uploaded", "-dry-run"}
+	require.NoError(t, Run(args, &buf, log.NewTestLogger(), loadConfig))
+
+	out := buf.String()
+	require.Contains(t, out, `"fileID":"first"`)
+	require.Contains(t, out, `"fileID":"second"`)
+	require.NotContains(t, out, `"fileID":"canceled"`)
+	require.NotContains(t, out, `"fileID":"old"`)
+	require.NotContains(t, out, `"fileID":"pending"`)
+	require.Contains(t, out, "replayed 2 events for shard testing")
+}
+
+func TestReplay__ODFI(t *testing.T) {
+	cfg := testConfig(t)
+
+	bucket := t.TempDir()
+	cfg.Inbound.ODFI = &service.ODFIFiles{
+		Processors: service.ODFIProcessors{
+			Corrections: service.ODFICorrections{
+				Enabled: true,
+			},
+		},
+		Audit: &service.AuditTrail{
+			BucketURI: "file://" + bucket,
+		},
+	}
+
+	// Save a correction file like the ODFI scheduler does
+	auditStorage, err := audittrail.NewStorage(cfg.Inbound.ODFI.Audit)
+	require.NoError(t, err)
+	bs, err := os.ReadFile(filepath.Join("..", "..", "testdata", "cor-c01.ach"))
+	require.NoError(t, err)
+	require.NoError(t, auditStorage.SaveFile("odfi/hostname/correction/2022-10-13/cor-c01.ach", bs))
+	require.NoError(t, auditStorage.SaveFile("odfi/hostname/correction/2022-10-01/old.ach", bs))
+	require.NoError(t, auditStorage.SaveFile("odfi/other/correction/2022-10-13/other.ach", bs))
+	require.NoError(t, auditStorage.Close())
+
+	loadConfig := func() (*service.Config, error) { return cfg, nil }
+	args := []string{"replay", "-shard", "testing", "-from", "2022-10-13", "-to", "2022-10-13", "-sources", "odfi", "-dry-run"}
+
+	var buf bytes.Buffer
+	require.NoError(t, Run(args, &buf, log.NewTestLogger(), loadConfig))
+
+	out := buf.String()
+	require.Equal(t, 1, strings.Count(out, `"type":"CorrectionFile"`), out)
+	require.Contains(t, out, `"filename":"cor-c01.ach"`)
+	require.Contains(t, out, "replayed 1 events for shard testing")
+
+	// Encrypted files need the private key
+	cfg.Inbound.ODFI.Audit.GPG = &service.GPG{
+		KeyFile: filepath.Join("..", "gpgx", "testdata", "key.pub"),
+	}
+	err = Run(args, &buf, log.NewTestLogger(), loadConfig)
+	require.ErrorContains(t, err, "-gpg-private-key is required")
+}
+
+func TestReplay__Errors(t *testing.T) {
+	cfg := testConfig(t)
+	loadConfig := func() (*service.Config, error) { return cfg, nil }
+
+	var buf bytes.Buffer
+	require.ErrorContains(t, Run(nil, &buf, log.NewTestLogger(), loadConfig), "missing command")
+	require.ErrorContains(t, Run([]string{"other"}, &buf, log.NewTestLogger(), loadConfig), `unknown command "other"`)
+	require.ErrorContains(t, Run([]string{"replay", "-from", "2022-10-13"}, &buf, log.NewTestLogger(), loadConfig), "missing -shard")
+
+	err := Run([]string{"replay", "-shard", "missing", "-from", "2022-10-13"}, &buf, log.NewTestLogger(), loadConfig)
+	require.ErrorContains(t, err, "shard missing not found")
+
+	// Events must be configured unless printing them
+	err = Run([]string{"replay", "-shard", "testing", "-from", "2022-10-13"}, &buf, log.NewTestLogger(), loadConfig)
+	require.ErrorContains(t, err, "no Events configured")
+
+	err = Run([]string{"replay", "-shard", "testing", "-from", "2022-10-13", "-sources", "odfi", "-dry-run"}, &buf, log.NewTestLogger(), loadConfig)
+	require.ErrorContains(t, err, "no audit trail configured")
+
+	err = Run([]string{"replay", "-shard", "testing", "-from", "2022-10-13", "-sources", "other", "-dry-run"}, &buf, log.NewTestLogger(), loadConfig)
+	require.ErrorContains(t, err, `unknown source "other"`)
+}
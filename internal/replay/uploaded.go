@@ -0,0 +1,117 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package replay
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/achgateway/pkg/models"
+)
+
+// isolatedDirLayout matches the timestamp cutoffs add to a shard's isolated directory.
+const isolatedDirLayout = "20060102-150405"
+
+// replayUploaded sends FileUploaded for each pending file merged by a cutoff between opts.from and opts.to.
+// Cutoffs leave their files on storage in a "$shard-$timestamp" directory with the merged files
+// in an "uploaded" directory inside it.
+func replayUploaded(cfg service.UploadAgents, shard service.Shard, opts options, emitter events.Emitter) error {
+	storageConfig := cfg.Merging.StorageConfig()
+	if _, err := os.Stat(storageConfig.Filesystem.Directory); err != nil {
+		return fmt.Errorf("opening merging storage: %w", err)
+	}
+	chest, err := storage.New(storageConfig)
+	if err != nil {
+		return fmt.Errorf("opening merging storage: %w", err)
+	}
+
+	// CPA-005 shards keep .005 files instead of Nacha files
+	ext := ".ach"
+	if shard.CPA005 != nil {
+		ext = ".005"
+	}
+
+	dirs, err := chest.Glob(shard.Name + "-*")
+	if err != nil {
+		return fmt.Errorf("listing isolated directories: %w", err)
+	}
+	for i := range dirs {
+		dir := dirs[i].RelativePath
+		when, err := time.ParseInLocation(isolatedDirLayout, strings.TrimPrefix(dir, shard.Name+"-"), time.Local)
+		if err != nil || !opts.contains(when) {
+			continue
+		}
+
+		uploaded, err := chest.Glob(filepath.Join(dir, "uploaded", "*"+ext))
+		if err != nil {
+			return fmt.Errorf("listing %s: %w", dir, err)
+		}
+		if len(uploaded) == 0 {
+			continue // nothing was merged
+		}
+
+		fileIDs, err := mergedFileIDs(chest, dir, ext)
+		if err != nil {
+			return err
+		}
+		for _, fileID := range fileIDs {
+			err := emitter.Send(models.Event{
+				Event: models.FileUploaded{
+					FileID:     fileID,
+					ShardKey:   shard.Name,
+					UploadedAt: when,
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("sending FileUploaded for %s: %w", fileID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// mergedFileIDs returns the fileID of each file in dir with the ext extension which wasn't canceled.
+func mergedFileIDs(chest storage.Chest, dir, ext string) ([]string, error) {
+	matches, err := chest.Glob(filepath.Join(dir, "*"+ext))
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", dir, err)
+	}
+	canceled, err := chest.Glob(filepath.Join(dir, "*.canceled"))
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", dir, err)
+	}
+	isCanceled := make(map[string]bool)
+	for i := range canceled {
+		isCanceled[strings.TrimSuffix(canceled[i].RelativePath, ".canceled")] = true
+	}
+
+	var out []string
+	for i := range matches {
+		if isCanceled[matches[i].RelativePath] {
+			continue
+		}
+		out = append(out, strings.TrimSuffix(filepath.Base(matches[i].RelativePath), ext))
+	}
+	return out, nil
+}
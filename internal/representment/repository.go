@@ -0,0 +1,185 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package representment
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moov-io/base/database"
+)
+
+type Repository interface {
+	// Create schedules rep. false is returned when the attempt was already scheduled, like when
+	// a return file is processed twice.
+	Create(rep *Representment) (bool, error)
+
+	// Get returns the representment with id, or nil
+	Get(id string) (*Representment, error)
+
+	// Due returns the scheduled representments whose time has come, oldest first
+	Due(now time.Time) ([]*Representment, error)
+
+	// Submit marks a scheduled representment as submitted. false is returned when it's no
+	// longer scheduled, like when another instance submitted it first.
+	Submit(id string) (bool, error)
+
+	// Release schedules a representment taken with Submit again, for when submitting failed
+	Release(id string) error
+
+	// RedactContents calls redact with the ID and contents of each representment and saves the
+	// contents it returns when changed, for erasure requests
+	RedactContents(redact func(key string, contents []byte) ([]byte, bool)) error
+}
+
+// NewRepository returns a Repository stored in db, or nil when db is nil
+func NewRepository(db *sql.DB) Repository {
+	if db == nil {
+		return nil
+	}
+	return &sqlRepository{db: db, now: time.Now}
+}
+
+type sqlRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func (r *sqlRepository) Create(rep *Representment) (bool, error) {
+	metadata, err := json.Marshal(rep.Metadata)
+	if err != nil {
+		return false, fmt.Errorf("encoding representment %s metadata: %v", rep.ID, err)
+	}
+	rep.Status = StatusScheduled
+	rep.CreatedAt = r.timestamp()
+	rep.ScheduledFor = rep.ScheduledFor.UTC().Truncate(time.Second)
+
+	query := `insert into representments (representment_id, shard_key, original_trace_number, return_code, attempt, status, contents, metadata, scheduled_for, created_at) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	_, err = r.db.Exec(query, rep.ID, rep.ShardKey, rep.OriginalTraceNumber, rep.ReturnCode, rep.Attempt, rep.Status, string(rep.Contents), string(metadata), rep.ScheduledFor, rep.CreatedAt)
+	if err != nil {
+		if database.UniqueViolation(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("creating representment %s: %v", rep.ID, err)
+	}
+	return true, nil
+}
+
+const selectRepresentments = `select representment_id, shard_key, original_trace_number, return_code, attempt, status, contents, metadata, scheduled_for, submitted_at, created_at from representments`
+
+func (r *sqlRepository) Get(id string) (*Representment, error) {
+	rows, err := r.db.Query(selectRepresentments+` where representment_id = ? limit 1;`, strings.TrimSpace(id))
+	if err != nil {
+		return nil, fmt.Errorf("reading representment %s: %v", id, err)
+	}
+	found, err := scanRepresentments(rows)
+	if err != nil {
+		return nil, fmt.Errorf("reading representment %s: %v", id, err)
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return found[0], nil
+}
+
+func (r *sqlRepository) Due(now time.Time) ([]*Representment, error) {
+	query := selectRepresentments + ` where status = ? and scheduled_for <= ? order by scheduled_for asc;`
+	rows, err := r.db.Query(query, StatusScheduled, now.UTC().Truncate(time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("reading due representments: %v", err)
+	}
+	found, err := scanRepresentments(rows)
+	if err != nil {
+		return nil, fmt.Errorf("reading due representments: %v", err)
+	}
+	return found, nil
+}
+
+func scanRepresentments(rows *sql.Rows) ([]*Representment, error) {
+	defer rows.Close()
+
+	var out []*Representment
+	for rows.Next() {
+		var rep Representment
+		var contents, metadata string
+		var submitted sql.NullTime
+		err := rows.Scan(&rep.ID, &rep.ShardKey, &rep.OriginalTraceNumber, &rep.ReturnCode, &rep.Attempt, &rep.Status, &contents, &metadata, &rep.ScheduledFor, &submitted, &rep.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rep.Contents = []byte(contents)
+		if err := json.Unmarshal([]byte(metadata), &rep.Metadata); err != nil {
+			return nil, fmt.Errorf("representment %s metadata: %v", rep.ID, err)
+		}
+		if submitted.Valid {
+			rep.SubmittedAt = &submitted.Time
+		}
+		out = append(out, &rep)
+	}
+	return out, rows.Err()
+}
+
+func (r *sqlRepository) Submit(id string) (bool, error) {
+	query := `update representments set status = ?, submitted_at = ? where representment_id = ? and status = ?;`
+	res, err := r.db.Exec(query, StatusSubmitted, r.timestamp(), id, StatusScheduled)
+	if err != nil {
+		return false, fmt.Errorf("submitting representment %s: %v", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("submitting representment %s: %v", id, err)
+	}
+	return n > 0, nil
+}
+
+func (r *sqlRepository) Release(id string) error {
+	query := `update representments set status = ?, submitted_at = null where representment_id = ? and status = ?;`
+	if _, err := r.db.Exec(query, StatusScheduled, id, StatusSubmitted); err != nil {
+		return fmt.Errorf("releasing representment %s: %v", id, err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) RedactContents(redact func(key string, contents []byte) ([]byte, bool)) error {
+	rows, err := r.db.Query(selectRepresentments + `;`)
+	if err != nil {
+		return fmt.Errorf("reading representments: %v", err)
+	}
+	found, err := scanRepresentments(rows)
+	if err != nil {
+		return fmt.Errorf("reading representments: %v", err)
+	}
+	for _, rep := range found {
+		redacted, changed := redact(rep.ID, rep.Contents)
+		if !changed {
+			continue
+		}
+		if _, err := r.db.Exec(`update representments set contents = ? where representment_id = ?;`, string(redacted), rep.ID); err != nil {
+			return fmt.Errorf("redacting representment %s: %v", rep.ID, err)
+		}
+	}
+	return nil
+}
+
+// timestamp is the current time in UTC with the precision DATETIME columns store
+func (r *sqlRepository) timestamp() time.Time {
+	return r.now().UTC().Truncate(time.Second)
+}
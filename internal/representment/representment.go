@@ -0,0 +1,173 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package representment re-presents debits returned for insufficient or uncollected funds
+// (R01 and R09). Nacha allows a returned entry to be re-presented up to two times, each under
+// the company entry description RETRY PYMT.
+package representment
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/moov-io/ach"
+)
+
+const (
+	StatusScheduled = "scheduled"
+	StatusSubmitted = "submitted"
+
+	// StatusExhausted is sent in events for returns with no attempts left. It's never stored.
+	StatusExhausted = "exhausted"
+
+	// CompanyEntryDescription is required by Nacha on re-presented batches
+	CompanyEntryDescription = "RETRY PYMT"
+)
+
+// Representment is one attempt at re-presenting a returned debit. Its ID is also the fileID the
+// entry is submitted under, so a return of the re-presented entry can be matched to it.
+type Representment struct {
+	ID       string `json:"representmentID"`
+	ShardKey string `json:"shardKey"`
+
+	// OriginalTraceNumber is the trace number of the entry's first presentment
+	OriginalTraceNumber string `json:"originalTraceNumber"`
+
+	// ReturnCode is what the previous presentment was returned with
+	ReturnCode string `json:"returnCode"`
+
+	// Attempt counts the re-presentments of the entry, starting at 1
+	Attempt int    `json:"attempt"`
+	Status  string `json:"status"`
+
+	ScheduledFor time.Time  `json:"scheduledFor"`
+	SubmittedAt  *time.Time `json:"submittedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+
+	// Metadata was attached to the original file and is submitted again with the entry
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Contents is the file of the re-presented entry in Nacha format
+	Contents []byte `json:"-"`
+}
+
+// File reads the file of the re-presented entry
+func (r *Representment) File() (*ach.File, error) {
+	file, err := ach.NewReader(bytes.NewReader(r.Contents)).Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading representment %s: %v", r.ID, err)
+	}
+	return &file, nil
+}
+
+// ReturnedDebit reports if entry is the return of a debit to a checking or savings account
+func ReturnedDebit(entry *ach.EntryDetail) bool {
+	if entry == nil || entry.Addenda99 == nil {
+		return false
+	}
+	return entry.TransactionCode == ach.CheckingReturnNOCDebit || entry.TransactionCode == ach.SavingsReturnNOCDebit
+}
+
+// Build creates the file re-presenting a debit returned in batch bh of returns. The headers of
+// the return are swapped back so the file is sent from the originator again. seq is the entry's
+// trace number sequence, which should differ from earlier presentments so returns are matched.
+func Build(returns *ach.File, bh *ach.BatchHeader, returned *ach.EntryDetail, effective time.Time, seq int) (*ach.File, error) {
+	if returns == nil || bh == nil {
+		return nil, errors.New("nil File or BatchHeader")
+	}
+	if !ReturnedDebit(returned) {
+		return nil, errors.New("entry is not a returned debit")
+	}
+	txCode := ach.CheckingDebit
+	if returned.TransactionCode == ach.SavingsReturnNOCDebit {
+		txCode = ach.SavingsDebit
+	}
+	odfi := returned.RDFIIdentification
+	rdfi := returned.Addenda99.OriginalDFI
+	if len(rdfi) < 8 {
+		return nil, fmt.Errorf("invalid original RDFI %q", rdfi)
+	}
+	rdfi = rdfi[:8]
+	now := time.Now()
+
+	out := ach.NewFile()
+	out.Header = ach.NewFileHeader()
+	out.Header.ImmediateDestination = returns.Header.ImmediateOrigin
+	out.Header.ImmediateDestinationName = returns.Header.ImmediateOriginName
+	out.Header.ImmediateOrigin = returns.Header.ImmediateDestination
+	out.Header.ImmediateOriginName = returns.Header.ImmediateDestinationName
+	out.Header.FileCreationDate = now.Format("060102")
+	out.Header.FileCreationTime = now.Format("1504")
+	out.Header.FileIDModifier = "A"
+
+	header := *bh
+	header.ID = ""
+	header.ServiceClassCode = ach.DebitsOnly
+	header.CompanyEntryDescription = CompanyEntryDescription
+	header.ODFIIdentification = odfi
+	header.EffectiveEntryDate = effective.Format("060102")
+	header.SettlementDate = ""
+	header.BatchNumber = 1
+
+	batch, err := ach.NewBatch(&header)
+	if err != nil {
+		return nil, fmt.Errorf("batch: %v", err)
+	}
+	entry := ach.NewEntryDetail()
+	entry.TransactionCode = txCode
+	entry.RDFIIdentification = rdfi
+	entry.CheckDigit = strconv.Itoa(entry.CalculateCheckDigit(rdfi))
+	entry.DFIAccountNumber = returned.DFIAccountNumber
+	entry.Amount = returned.Amount
+	entry.IdentificationNumber = returned.IdentificationNumber
+	entry.IndividualName = returned.IndividualName
+	entry.DiscretionaryData = returned.DiscretionaryData
+	entry.SetTraceNumber(odfi, seq)
+	batch.AddEntry(entry)
+
+	if err := batch.Create(); err != nil {
+		return nil, fmt.Errorf("creating batch: %v", err)
+	}
+	out.AddBatch(batch)
+	if err := out.Create(); err != nil {
+		return nil, fmt.Errorf("creating file: %v", err)
+	}
+	return out, nil
+}
+
+// Sequence picks a random trace number sequence for Build
+func Sequence() (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(9999999))
+	if err != nil {
+		return 0, fmt.Errorf("choosing trace number: %v", err)
+	}
+	return int(n.Int64()) + 1, nil
+}
+
+// Encode writes file in Nacha format, as Contents are stored
+func Encode(file *ach.File) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ach.NewWriter(&buf).Write(file); err != nil {
+		return nil, fmt.Errorf("writing file: %v", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,173 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package representment
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/pkg/rdfi"
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readReturns(t *testing.T, code string) *ach.File {
+	t.Helper()
+
+	debit, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	returned, err := rdfi.Return(debit, rdfi.Options{Code: code})
+	require.NoError(t, err)
+
+	// Read the returns like files downloaded from the ODFI
+	bs, err := Encode(returned)
+	require.NoError(t, err)
+	rep := &Representment{Contents: bs}
+	file, err := rep.File()
+	require.NoError(t, err)
+	return file
+}
+
+func TestBuild(t *testing.T) {
+	returns := readReturns(t, "R01")
+	require.Len(t, returns.ReturnEntries, 1)
+
+	bh := returns.ReturnEntries[0].GetHeader()
+	returned := returns.ReturnEntries[0].GetEntries()[0]
+	require.True(t, ReturnedDebit(returned))
+
+	effective := time.Date(2022, time.June, 3, 0, 0, 0, 0, time.UTC)
+	file, err := Build(returns, bh, returned, effective, 42)
+	require.NoError(t, err)
+	require.NoError(t, file.Validate())
+
+	// The file comes from the originator again
+	require.Equal(t, "076401251", file.Header.ImmediateDestination)
+	require.Equal(t, "076401251", file.Header.ImmediateOrigin)
+
+	require.Len(t, file.Batches, 1)
+	header := file.Batches[0].GetHeader()
+	require.Equal(t, CompanyEntryDescription, header.CompanyEntryDescription)
+	require.Equal(t, ach.DebitsOnly, header.ServiceClassCode)
+	require.Equal(t, "companyname", header.CompanyName)
+	require.Equal(t, "220603", header.EffectiveEntryDate)
+
+	entries := file.Batches[0].GetEntries()
+	require.Len(t, entries, 1)
+	require.Equal(t, ach.CheckingDebit, entries[0].TransactionCode)
+	require.Equal(t, "05320001", entries[0].RDFIIdentification)
+	require.Equal(t, returned.DFIAccountNumber, entries[0].DFIAccountNumber)
+	require.Equal(t, 10500, entries[0].Amount)
+	require.Equal(t, "076401250000042", entries[0].TraceNumber)
+	require.Nil(t, entries[0].Addenda99)
+}
+
+func TestBuild__Errors(t *testing.T) {
+	returns := readReturns(t, "R01")
+	bh := returns.ReturnEntries[0].GetHeader()
+
+	_, err := Build(nil, bh, nil, time.Now(), 1)
+	require.ErrorContains(t, err, "nil File or BatchHeader")
+
+	credit := ach.NewEntryDetail()
+	credit.TransactionCode = ach.CheckingReturnNOCCredit
+	credit.Addenda99 = ach.NewAddenda99()
+	require.False(t, ReturnedDebit(credit))
+	_, err = Build(returns, bh, credit, time.Now(), 1)
+	require.ErrorContains(t, err, "entry is not a returned debit")
+}
+
+func TestRepository(t *testing.T) {
+	require.Nil(t, NewRepository(nil))
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := NewRepository(db.DB)
+
+	found, err := repo.Get("missing")
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	now := time.Now()
+	rep := &Representment{
+		ID:                  "rep1",
+		ShardKey:            "live",
+		OriginalTraceNumber: "076401255655291",
+		ReturnCode:          "R01",
+		Attempt:             1,
+		ScheduledFor:        now.Add(time.Hour),
+		Metadata:            map[string]string{"batchID": "abc123"},
+		Contents:            []byte("contents"),
+	}
+	created, err := repo.Create(rep)
+	require.NoError(t, err)
+	require.True(t, created)
+
+	// The same attempt is only scheduled once
+	again := *rep
+	again.ID = "rep2"
+	created, err = repo.Create(&again)
+	require.NoError(t, err)
+	require.False(t, created)
+
+	found, err = repo.Get("rep1")
+	require.NoError(t, err)
+	require.Equal(t, StatusScheduled, found.Status)
+	require.Equal(t, "abc123", found.Metadata["batchID"])
+	require.Equal(t, "contents", string(found.Contents))
+	require.Nil(t, found.SubmittedAt)
+
+	due, err := repo.Due(now)
+	require.NoError(t, err)
+	require.Empty(t, due)
+
+	due, err = repo.Due(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, "rep1", due[0].ID)
+
+	taken, err := repo.Submit("rep1")
+	require.NoError(t, err)
+	require.True(t, taken)
+	taken, err = repo.Submit("rep1")
+	require.NoError(t, err)
+	require.False(t, taken)
+
+	found, err = repo.Get("rep1")
+	require.NoError(t, err)
+	require.Equal(t, StatusSubmitted, found.Status)
+	require.NotNil(t, found.SubmittedAt)
+
+	// Released representments are due again
+	require.NoError(t, repo.Release("rep1"))
+	due, err = repo.Due(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+
+	err = repo.RedactContents(func(key string, contents []byte) ([]byte, bool) {
+		require.Equal(t, "rep1", key)
+		return []byte("redacted"), true
+	})
+	require.NoError(t, err)
+	found, err = repo.Get("rep1")
+	require.NoError(t, err)
+	require.Equal(t, "redacted", string(found.Contents))
+}
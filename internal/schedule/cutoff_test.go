@@ -84,3 +84,45 @@ func TestCutoffTimes__Holiday(t *testing.T) {
 	require.False(t, cutoff.IsWeekend)
 	require.True(t, cutoff.FirstWindow)
 }
+
+func TestUpcoming(t *testing.T) {
+	// Friday before Labor Day (Monday, Sept 5th 2022)
+	from := time.Date(2022, time.September, 2, 12, 0, 0, 0, time.UTC)
+
+	days, err := Upcoming("America/New_York", []string{"16:20", "11:00"}, from, 5)
+	require.NoError(t, err)
+
+	// Friday's 11:00 window (15:00 UTC) has not passed yet
+	require.Len(t, days, 10)
+	require.Equal(t, "2022-09-02 11:00 EDT", days[0].Time.Format("2006-01-02 15:04 MST"))
+	require.True(t, days[0].FirstWindow)
+	require.True(t, days[0].IsBankingDay)
+	require.False(t, days[1].FirstWindow)
+
+	// Saturday
+	require.True(t, days[2].IsWeekend)
+	require.False(t, days[2].IsBankingDay)
+
+	// Labor Day
+	require.Equal(t, "2022-09-05", days[6].Time.Format("2006-01-02"))
+	require.True(t, days[6].IsHoliday)
+	require.False(t, days[6].IsWeekend)
+	require.False(t, days[6].IsBankingDay)
+
+	// Tuesday's last window, Wednesday's windows are after the 5 days
+	require.Equal(t, "2022-09-06 16:20 EDT", days[9].Time.Format("2006-01-02 15:04 MST"))
+	require.True(t, days[9].IsBankingDay)
+}
+
+func TestUpcomingErr(t *testing.T) {
+	now := time.Now()
+
+	_, err := Upcoming("UTC", nil, now, 1)
+	require.ErrorContains(t, err, "missing cutoff times")
+
+	_, err = Upcoming("bad_zone", []string{"16:20"}, now, 1)
+	require.ErrorContains(t, err, "unknown timezone")
+
+	_, err = Upcoming("UTC", []string{"bad:time"}, now, 1)
+	require.ErrorContains(t, err, "timestamp=bad:time")
+}
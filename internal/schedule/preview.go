@@ -0,0 +1,63 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/moov-io/base"
+)
+
+// Upcoming returns each cutoff window between from and the following days, including the
+// windows on weekends and holidays which CutoffTimes skips (see Day.IsBankingDay).
+func Upcoming(tz string, timestamps []string, from time.Time, days int) ([]*Day, error) {
+	if len(timestamps) == 0 {
+		return nil, errors.New("missing cutoff times")
+	}
+	location := time.UTC
+	if tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone %q: %v", tz, err)
+		}
+		location = l
+	}
+
+	windows := make([]time.Time, len(timestamps))
+	for i := range timestamps {
+		when, err := time.Parse("15:04", timestamps[i])
+		if err != nil {
+			return nil, fmt.Errorf("timestamp=%s error=%v", timestamps[i], err)
+		}
+		windows[i] = when
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Before(windows[j]) })
+
+	from = from.In(location)
+	until := from.AddDate(0, 0, days)
+
+	var out []*Day
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, location)
+	for date := start; date.Before(until); date = date.AddDate(0, 0, 1) {
+		for i := range windows {
+			when := time.Date(date.Year(), date.Month(), date.Day(), windows[i].Hour(), windows[i].Minute(), 0, 0, location)
+			if when.Before(from) || !when.Before(until) {
+				continue
+			}
+			t := base.NewTime(when)
+			out = append(out, &Day{
+				Time:         when,
+				IsBankingDay: t.IsBankingDay(),
+				IsHoliday:    t.IsHoliday(),
+				IsWeekend:    t.IsWeekend(),
+				FirstWindow:  i == 0,
+			})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,69 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package screening
+
+import (
+	"context"
+	"strings"
+
+	"github.com/moov-io/achgateway/internal/service"
+)
+
+type blocklist struct {
+	names    map[string]bool
+	accounts map[string]bool
+}
+
+func newBlocklist(cfg *service.Blocklist) *blocklist {
+	b := &blocklist{
+		names:    make(map[string]bool),
+		accounts: make(map[string]bool),
+	}
+	for i := range cfg.Names {
+		b.names[normalizeName(cfg.Names[i])] = true
+	}
+	for i := range cfg.Accounts {
+		b.accounts[strings.TrimSpace(cfg.Accounts[i])] = true
+	}
+	return b
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func (b *blocklist) Screen(_ context.Context, req Request) (*Response, error) {
+	out := &Response{}
+	for _, entry := range req.Entries {
+		switch {
+		case b.names[normalizeName(entry.Name)]:
+			out.Decisions = append(out.Decisions, Decision{
+				TraceNumber: entry.TraceNumber,
+				Blocked:     true,
+				Reason:      "receiver name is blocklisted",
+			})
+		case b.accounts[entry.AccountNumber]:
+			out.Decisions = append(out.Decisions, Decision{
+				TraceNumber: entry.TraceNumber,
+				Blocked:     true,
+				Reason:      "receiver account is blocklisted",
+			})
+		}
+	}
+	return out, nil
+}
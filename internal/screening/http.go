@@ -0,0 +1,80 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package screening
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+type httpScreener struct {
+	client   *retryablehttp.Client
+	endpoint *url.URL
+}
+
+func newHTTPScreener(cfg *service.HTTPScreening) (*httpScreener, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("screening: %v", err)
+	}
+	client := retryablehttp.NewClient()
+	client.Logger = nil
+	client.RetryMax = 2
+	client.HTTPClient.Timeout = cfg.RequestTimeout()
+
+	return &httpScreener{
+		client:   client,
+		endpoint: u,
+	}, nil
+}
+
+func (s *httpScreener) Screen(ctx context.Context, req Request) (*Response, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(req); err != nil {
+		return nil, fmt.Errorf("encoding screening request: %v", err)
+	}
+	r, err := retryablehttp.NewRequest("POST", s.endpoint.String(), &body)
+	if err != nil {
+		return nil, fmt.Errorf("preparing screening request: %v", err)
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(r)
+	if err != nil {
+		return nil, fmt.Errorf("screening request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected screening response status: %s", resp.Status)
+	}
+
+	var out Response
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("reading screening response: %v", err)
+	}
+	return &out, nil
+}
@@ -0,0 +1,207 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package screening
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+)
+
+// ErrBlocked is returned when one or more entries in a file were blocked by screening.
+var ErrBlocked = errors.New("blocked by screening")
+
+// Screener decides if the receivers of a file's entries are allowed.
+//
+// Implementations can be external (HTTP) or in-process (Blocklist).
+type Screener interface {
+	Screen(ctx context.Context, req Request) (*Response, error)
+}
+
+type Request struct {
+	ShardName string  `json:"shardName"`
+	FileID    string  `json:"fileID"`
+	Entries   []Entry `json:"entries"`
+}
+
+type Entry struct {
+	TraceNumber   string `json:"traceNumber"`
+	Name          string `json:"name"`
+	RoutingNumber string `json:"routingNumber"`
+	AccountNumber string `json:"accountNumber"`
+	Amount        int    `json:"amount"`
+}
+
+type Response struct {
+	Decisions []Decision `json:"decisions"`
+}
+
+// Decision is the screening result for one entry. Entries without a decision are allowed.
+type Decision struct {
+	TraceNumber string `json:"traceNumber"`
+	Blocked     bool   `json:"blocked"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+const (
+	OutcomePassed       = "passed"
+	OutcomeBlocked      = "blocked"
+	OutcomeFailedOpen   = "failed-open"
+	OutcomeFailedClosed = "failed-closed"
+)
+
+// Record is the audit record of a screening decision for a file.
+type Record struct {
+	ShardName  string     `json:"shardName"`
+	FileID     string     `json:"fileID"`
+	ScreenedAt time.Time  `json:"screenedAt"`
+	Outcome    string     `json:"outcome"`
+	Error      string     `json:"error,omitempty"`
+	Decisions  []Decision `json:"decisions,omitempty"`
+}
+
+type Service struct {
+	logger   log.Logger
+	screener Screener
+	failOpen bool
+	timeout  time.Duration
+}
+
+// New returns a Service for the shard's screening config. A nil Service is returned when
+// screening is not configured.
+func New(logger log.Logger, cfg *service.Screening) (*Service, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	var screeners multi
+	if cfg.HTTP != nil {
+		hs, err := newHTTPScreener(cfg.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		screeners = append(screeners, hs)
+	}
+	if cfg.Blocklist != nil {
+		screeners = append(screeners, newBlocklist(cfg.Blocklist))
+	}
+	if len(screeners) == 0 {
+		return nil, errors.New("screening: no screeners configured")
+	}
+	return NewService(logger, screeners, cfg.FailOpen), nil
+}
+
+// NewService wraps a Screener, which allows in-process implementations to be used.
+func NewService(logger log.Logger, screener Screener, failOpen bool) *Service {
+	return &Service{
+		logger:   logger,
+		screener: screener,
+		failOpen: failOpen,
+		timeout:  30 * time.Second,
+	}
+}
+
+// Screen checks each entry in the file. The returned Record should be saved for auditing.
+//
+// ErrBlocked is returned (wrapped) when any entry is blocked. Other errors mean the screener
+// failed and the file was not accepted (fail-closed).
+func (s *Service) Screen(shardName string, file incoming.ACHFile) (*Record, error) {
+	if s == nil || file.File == nil {
+		return nil, nil
+	}
+
+	req := Request{
+		ShardName: shardName,
+		FileID:    file.FileID,
+	}
+	for _, b := range file.File.Batches {
+		entries := b.GetEntries()
+		for i := range entries {
+			req.Entries = append(req.Entries, Entry{
+				TraceNumber:   entries[i].TraceNumber,
+				Name:          strings.TrimSpace(entries[i].IndividualName),
+				RoutingNumber: entries[i].RDFIIdentification + entries[i].CheckDigit,
+				AccountNumber: strings.TrimSpace(entries[i].DFIAccountNumber),
+				Amount:        entries[i].Amount,
+			})
+		}
+	}
+
+	record := &Record{
+		ShardName:  shardName,
+		FileID:     file.FileID,
+		ScreenedAt: time.Now(),
+	}
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), s.timeout)
+	defer cancelFunc()
+
+	resp, err := s.screener.Screen(ctx, req)
+	if err != nil {
+		record.Error = err.Error()
+		if s.failOpen {
+			record.Outcome = OutcomeFailedOpen
+			s.logger.Warn().With(log.Fields{
+				"shard":  log.String(shardName),
+				"fileID": log.String(file.FileID),
+			}).Logf("accepting file after screening failure: %v", err)
+			return record, nil
+		}
+		record.Outcome = OutcomeFailedClosed
+		return record, fmt.Errorf("screening failed: %w", err)
+	}
+
+	var reasons []string
+	if resp != nil {
+		for _, d := range resp.Decisions {
+			if d.Blocked {
+				record.Decisions = append(record.Decisions, d)
+				reasons = append(reasons, fmt.Sprintf("trace number %s: %s", d.TraceNumber, d.Reason))
+			}
+		}
+	}
+	if len(reasons) > 0 {
+		record.Outcome = OutcomeBlocked
+		return record, fmt.Errorf("%w: %s", ErrBlocked, strings.Join(reasons, "; "))
+	}
+	record.Outcome = OutcomePassed
+	return record, nil
+}
+
+// multi runs each Screener and combines their decisions.
+type multi []Screener
+
+func (m multi) Screen(ctx context.Context, req Request) (*Response, error) {
+	out := &Response{}
+	for i := range m {
+		resp, err := m[i].Screen(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if resp != nil {
+			out.Decisions = append(out.Decisions, resp.Decisions...)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,135 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package screening
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readFile(t *testing.T) incoming.ACHFile {
+	t.Helper()
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	return incoming.ACHFile{
+		FileID:   "file1",
+		ShardKey: "testing",
+		File:     file,
+	}
+}
+
+func TestScreening__Nil(t *testing.T) {
+	svc, err := New(log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.Nil(t, svc)
+
+	record, err := svc.Screen("testing", readFile(t))
+	require.NoError(t, err)
+	require.Nil(t, record)
+}
+
+func TestScreening__Blocklist(t *testing.T) {
+	svc, err := New(log.NewNopLogger(), &service.Screening{
+		Blocklist: &service.Blocklist{
+			Names: []string{"bachman  eric"},
+		},
+	})
+	require.NoError(t, err)
+
+	record, err := svc.Screen("testing", readFile(t))
+	require.ErrorIs(t, err, ErrBlocked)
+	require.Equal(t, OutcomeBlocked, record.Outcome)
+	require.Len(t, record.Decisions, 1)
+	require.Equal(t, "receiver name is blocklisted", record.Decisions[0].Reason)
+
+	svc, err = New(log.NewNopLogger(), &service.Screening{
+		Blocklist: &service.Blocklist{
+			Accounts: []string{"987654321"},
+		},
+	})
+	require.NoError(t, err)
+
+	record, err = svc.Screen("testing", readFile(t))
+	require.NoError(t, err)
+	require.Equal(t, OutcomePassed, record.Outcome)
+}
+
+func TestScreening__HTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var resp Response
+		for _, entry := range req.Entries {
+			resp.Decisions = append(resp.Decisions, Decision{
+				TraceNumber: entry.TraceNumber,
+				Blocked:     entry.AccountNumber == "12345",
+				Reason:      "sanctions match",
+			})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	svc, err := New(log.NewNopLogger(), &service.Screening{
+		HTTP: &service.HTTPScreening{
+			Endpoint: server.URL,
+		},
+	})
+	require.NoError(t, err)
+
+	record, err := svc.Screen("testing", readFile(t))
+	require.ErrorIs(t, err, ErrBlocked)
+	require.Contains(t, err.Error(), "sanctions match")
+	require.Equal(t, "file1", record.FileID)
+}
+
+type failingScreener struct{}
+
+func (failingScreener) Screen(_ context.Context, _ Request) (*Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestScreening__FailureModes(t *testing.T) {
+	svc := NewService(log.NewNopLogger(), failingScreener{}, false)
+	record, err := svc.Screen("testing", readFile(t))
+	require.ErrorContains(t, err, "connection refused")
+	require.NotErrorIs(t, err, ErrBlocked)
+	require.Equal(t, OutcomeFailedClosed, record.Outcome)
+
+	svc = NewService(log.NewNopLogger(), failingScreener{}, true)
+	record, err = svc.Screen("testing", readFile(t))
+	require.NoError(t, err)
+	require.Equal(t, OutcomeFailedOpen, record.Outcome)
+	require.Equal(t, "connection refused", record.Error)
+}
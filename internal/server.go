@@ -27,7 +27,10 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/moov-io/achgateway/internal/fips"
+	"github.com/moov-io/achgateway/internal/leadership"
 	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
 	"github.com/moov-io/base/admin"
 	"github.com/moov-io/base/log"
 
@@ -40,7 +43,16 @@ func (env *Environment) RunServers(terminationListener chan error) func() {
 
 	// register the admin routes
 	env.registerConfigRoute()
+	env.registerSnapshotRoute()
+	env.registerExposureRoute()
+	env.registerErasureRoute()
+	env.registerSigningKeysRoutes()
 	env.FileReceiver.RegisterAdminRoutes(env.AdminServer)
+	leadership.RegisterAdminRoutes(env.AdminServer)
+	env.Drain.RegisterAdminRoutes(env.AdminServer)
+	if env.Config.Upload.FaultInjection != nil {
+		upload.RegisterFaultRoutes(env.AdminServer)
+	}
 
 	_, shutdownPublicServer := bootHTTPServer("public", env.PublicRouter, terminationListener, env.Logger, env.Config.Inbound.HTTP)
 
@@ -55,11 +67,11 @@ func bootHTTPServer(name string, routes *mux.Router, errs chan<- error, logger l
 	serve := &http.Server{
 		Addr:    config.BindAddress,
 		Handler: routes,
-		TLSConfig: &tls.Config{
+		TLSConfig: fips.TLSConfig(&tls.Config{
 			InsecureSkipVerify:       false,
 			PreferServerCipherSuites: true,
 			MinVersion:               tls.VersionTLS12,
-		},
+		}),
 		ReadTimeout:       30 * time.Second,
 		ReadHeaderTimeout: 30 * time.Second,
 		WriteTimeout:      30 * time.Second,
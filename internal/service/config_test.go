@@ -26,6 +26,10 @@ import (
 	"github.com/moov-io/base/log"
 	"github.com/stretchr/testify/require"
 
+	"github.com/moov-io/achgateway/internal/consul"
+	"github.com/moov-io/achgateway/internal/dblock"
+	"github.com/moov-io/achgateway/internal/kubelease"
+	"github.com/moov-io/achgateway/internal/redis"
 	"github.com/moov-io/achgateway/internal/service"
 )
 
@@ -40,3 +44,46 @@ func Test_ConfigLoading(t *testing.T) {
 	// Validate config
 	require.NoError(t, gc.ACHGateway.Validate())
 }
+
+func TestConfig__Electors(t *testing.T) {
+	cfg := &service.Config{
+		Consul:        &consul.Config{Address: "http://127.0.0.1:8500"},
+		DatabaseLocks: &dblock.Config{},
+	}
+	require.ErrorContains(t, cfg.Validate(), "only one of Consul, KubernetesLease, DatabaseLocks or Redis.Locks")
+
+	cfg.Consul = nil
+	require.ErrorContains(t, cfg.Validate(), "database locks: missing Database")
+
+	cfg.DatabaseLocks = nil
+	cfg.Redis = &redis.Config{Locks: &redis.LockConfig{}}
+	require.ErrorContains(t, cfg.Validate(), "redis: missing Address")
+
+	cfg.KubernetesLease = &kubelease.Config{}
+	require.ErrorContains(t, cfg.Validate(), "only one of Consul")
+}
+
+func TestConfig__SingleWriter(t *testing.T) {
+	cfg := &service.Config{
+		Sharding: service.Sharding{
+			Shards: []service.Shard{
+				{
+					Name: "live",
+					Cutoffs: service.Cutoffs{
+						Timezone: "America/New_York",
+						Windows:  []string{"17:00"},
+					},
+					UploadAgent: "mock",
+					Mergable: service.MergableConfig{
+						Incremental: &service.IncrementalMerging{},
+					},
+				},
+			},
+		},
+	}
+	cfg.Upload.Agents = []service.UploadAgent{{ID: "mock", Mock: &service.MockAgent{}}}
+	cfg.Upload.Merging.SingleWriter = true
+
+	err := cfg.Validate()
+	require.ErrorContains(t, err, "shard live can't use Incremental merging with SingleWriter")
+}
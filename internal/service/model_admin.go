@@ -17,10 +17,117 @@
 
 package service
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/mask"
+)
+
 type Admin struct {
 	BindAddress string
+
+	// Approvals requires a second operator to confirm sensitive admin actions.
+	Approvals *Approvals
 }
 
 func (cfg Admin) Validate() error {
+	if err := cfg.Approvals.Validate(); err != nil {
+		return fmt.Errorf("approvals: %v", err)
+	}
+	return nil
+}
+
+const (
+	RoleRequester = "requester"
+	RoleApprover  = "approver"
+)
+
+type Approvals struct {
+	Operators []Operator
+
+	// Expiration is how long a request waits for approval before it's discarded.
+	Expiration time.Duration
+
+	// Audit stores a record of every request and approval.
+	Audit *AuditTrail
+}
+
+func (cfg *Approvals) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	names := make(map[string]bool)
+	tokens := make(map[string]bool)
+	var requesters, approvers int
+	for i := range cfg.Operators {
+		op := cfg.Operators[i]
+		if op.Name == "" || op.Token == "" {
+			return fmt.Errorf("operator[%d]: missing name or token", i)
+		}
+		if names[op.Name] || tokens[op.Token] {
+			return fmt.Errorf("operator[%d]: duplicate name or token", i)
+		}
+		names[op.Name], tokens[op.Token] = true, true
+
+		for _, role := range op.Roles {
+			switch strings.ToLower(role) {
+			case RoleRequester:
+				requesters++
+			case RoleApprover:
+				approvers++
+			default:
+				return fmt.Errorf("operator[%d]: unknown role %q", i, role)
+			}
+		}
+	}
+	if requesters == 0 || approvers == 0 || len(cfg.Operators) < 2 {
+		return errors.New("at least two operators with requester and approver roles are required")
+	}
+	if cfg.Expiration < 0 {
+		return errors.New("negative expiration")
+	}
+	if err := cfg.Audit.Validate(); err != nil {
+		return fmt.Errorf("audit: %v", err)
+	}
 	return nil
 }
+
+func (cfg *Approvals) ExpiresAfter() time.Duration {
+	if cfg == nil || cfg.Expiration == 0 {
+		return time.Hour
+	}
+	return cfg.Expiration
+}
+
+type Operator struct {
+	Name string
+
+	// Token is sent by the operator as "Authorization: Bearer <token>".
+	Token string
+
+	Roles []string
+}
+
+func (op Operator) HasRole(role string) bool {
+	for i := range op.Roles {
+		if strings.EqualFold(op.Roles[i], role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (op Operator) MarshalJSON() ([]byte, error) {
+	type Aux Operator
+	aux := Aux(op)
+	aux.Token = mask.Password(op.Token)
+	return json.Marshal(aux)
+}
+
+func (op Operator) String() string {
+	return fmt.Sprintf("Operator{Name=%s, Token=%s, Roles=%v}", op.Name, mask.Password(op.Token), op.Roles)
+}
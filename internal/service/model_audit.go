@@ -20,6 +20,7 @@ package service
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 
 	"github.com/moov-io/achgateway/internal/mask"
@@ -56,6 +57,10 @@ func (cfg *Signer) Password() string {
 	return strx.Or(os.Getenv("PIPELINE_SIGNING_KEY_PASSWORD"), cfg.KeyPassword)
 }
 
+func (cfg *Signer) String() string {
+	return fmt.Sprintf("Signer{KeyFile=%s, KeyPassword=%s}", cfg.KeyFile, mask.Password(cfg.Password()))
+}
+
 func (cfg *Signer) MarshalJSON() ([]byte, error) {
 	type Aux struct {
 		KeyFile     string
@@ -20,15 +20,23 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/moov-io/achgateway/internal/consul"
+	"github.com/moov-io/achgateway/internal/dblock"
+	"github.com/moov-io/achgateway/internal/kubelease"
+	"github.com/moov-io/achgateway/internal/redis"
 	"github.com/moov-io/base/database"
 	"github.com/moov-io/base/log"
 )
 
 type GlobalConfig struct {
 	ACHGateway Config
+
+	// Tenants are additional gateways run by the same process, selected by name with the
+	// TENANTS environment variable. Each has its own streams, database and shards.
+	Tenants map[string]Config
 }
 
 type Config struct {
@@ -36,15 +44,57 @@ type Config struct {
 	Clients  *ClientConfig
 	Database database.DatabaseConfig
 	Consul   *consul.Config
+
+	// KubernetesLease elects leaders with Kubernetes Leases instead of Consul
+	KubernetesLease *kubelease.Config
+
+	// DatabaseLocks elects leaders with locks in the database, splitting shards across replicas
+	DatabaseLocks *dblock.Config
+
+	// Redis keeps short-lived state shared by replicas, such as leader locks and rate limits
+	Redis *redis.Config
+
 	Admin    Admin
 	Inbound  Inbound
 	Events   *EventsConfig
 	Sharding Sharding
 	Upload   UploadAgents
 	Errors   ErrorAlerting
+	FIPS     *FIPS
+
+	// Exposure reports the daily net exposure of each ODFI
+	Exposure *ExposureConfig
+
+	// Erasure redacts a receiver's personal data on request
+	Erasure *ErasureConfig
 }
 
+// ConfigLoader reads achgateway's configuration for subcommands which only need it for some operations.
+type ConfigLoader func() (*Config, error)
+
 func (cfg *Config) Validate() error {
+	electors := 0
+	redisLocks := cfg.Redis != nil && cfg.Redis.Locks != nil
+	for _, configured := range []bool{cfg.Consul != nil, cfg.KubernetesLease != nil, cfg.DatabaseLocks != nil, redisLocks} {
+		if configured {
+			electors++
+		}
+	}
+	if electors > 1 {
+		return errors.New("only one of Consul, KubernetesLease, DatabaseLocks or Redis.Locks can be configured")
+	}
+	if err := cfg.KubernetesLease.Validate(); err != nil {
+		return fmt.Errorf("kubernetes lease: %v", err)
+	}
+	if err := cfg.DatabaseLocks.Validate(); err != nil {
+		return fmt.Errorf("database locks: %v", err)
+	}
+	if cfg.DatabaseLocks != nil && cfg.Database.MySQL == nil && cfg.Database.SQLite == nil {
+		return errors.New("database locks: missing Database")
+	}
+	if err := cfg.Redis.Validate(); err != nil {
+		return fmt.Errorf("redis: %v", err)
+	}
 	if err := cfg.Admin.Validate(); err != nil {
 		return fmt.Errorf("admin: %v", err)
 	}
@@ -60,8 +110,28 @@ func (cfg *Config) Validate() error {
 	if err := cfg.Upload.Validate(); err != nil {
 		return fmt.Errorf("upload: %v", err)
 	}
+	if cfg.Upload.Merging.SingleWriter {
+		// Incremental merging only knows about files accepted by this instance
+		for _, shard := range cfg.Sharding.Shards {
+			if shard.Mergable.Incremental != nil {
+				return fmt.Errorf("upload: shard %s can't use Incremental merging with SingleWriter", shard.Name)
+			}
+		}
+	}
 	if err := cfg.Errors.Validate(); err != nil {
 		return fmt.Errorf("errors: %v", err)
 	}
+	if err := cfg.FIPS.Validate(); err != nil {
+		return fmt.Errorf("fips: %v", err)
+	}
+	if err := cfg.Exposure.Validate(); err != nil {
+		return fmt.Errorf("exposure: %v", err)
+	}
+	if err := cfg.Erasure.Validate(); err != nil {
+		return fmt.Errorf("erasure: %v", err)
+	}
+	if cfg.Exposure != nil && cfg.Database.MySQL == nil && cfg.Database.SQLite == nil {
+		return errors.New("exposure: missing Database")
+	}
 	return nil
 }
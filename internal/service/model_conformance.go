@@ -0,0 +1,117 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ConformanceProfile describes the file format quirks of an ODFI. Merged files uploaded
+// through an agent with the profile are checked and rendered to match it.
+type ConformanceProfile struct {
+	Name string
+
+	// LineLength right-pads each record with spaces, zero leaves records at 94 characters
+	LineLength int
+
+	// BlockingFactor is how many records are in each block filled with 9s,
+	// 10 by default and 1 writes no filler records.
+	BlockingFactor int
+
+	// Uppercase coerces every record to uppercase
+	Uppercase bool
+
+	// AllowedSECCodes rejects files with batches of other SEC codes when set
+	AllowedSECCodes []string
+
+	// RequireAddenda are SEC codes whose entries must each have an addenda record
+	RequireAddenda []string
+
+	// AddendaFormats are government mandated addenda layouts checked in each CCD entry's
+	// addenda which starts with their segment identifier: DED (child support) and TXP (tax
+	// payments). Files submitted for shards using the agent are checked too.
+	AddendaFormats []string
+}
+
+func (cfg ConformanceProfile) Validate() error {
+	if cfg.Name == "" {
+		return errors.New("missing Name")
+	}
+	if cfg.LineLength != 0 && cfg.LineLength < 94 {
+		return fmt.Errorf("LineLength %d is shorter than 94", cfg.LineLength)
+	}
+	if cfg.BlockingFactor < 0 {
+		return fmt.Errorf("invalid BlockingFactor %d", cfg.BlockingFactor)
+	}
+	for _, code := range append(cfg.AllowedSECCodes, cfg.RequireAddenda...) {
+		if len(strings.TrimSpace(code)) != 3 {
+			return fmt.Errorf("invalid SEC code %q", code)
+		}
+	}
+	for _, format := range cfg.AddendaFormats {
+		switch strings.ToUpper(strings.TrimSpace(format)) {
+		case "DED", "TXP":
+		default:
+			return fmt.Errorf("unknown addenda format %q", format)
+		}
+	}
+	return nil
+}
+
+// Blocking returns the number of records in each block
+func (cfg ConformanceProfile) Blocking() int {
+	if cfg.BlockingFactor <= 0 {
+		return 10
+	}
+	return cfg.BlockingFactor
+}
+
+// ConformanceProfile returns the profile used by the agent, or nil without one
+func (ua UploadAgents) ConformanceProfile(agentID string) *ConformanceProfile {
+	agent := ua.Find(agentID)
+	if agent == nil || agent.ConformanceProfile == "" {
+		return nil
+	}
+	for i := range ua.ConformanceProfiles {
+		if ua.ConformanceProfiles[i].Name == agent.ConformanceProfile {
+			return &ua.ConformanceProfiles[i]
+		}
+	}
+	return nil
+}
+
+func (ua UploadAgents) validateConformanceProfiles() error {
+	names := make(map[string]bool)
+	for i := range ua.ConformanceProfiles {
+		if err := ua.ConformanceProfiles[i].Validate(); err != nil {
+			return fmt.Errorf("conformance profile[%d]: %v", i, err)
+		}
+		if names[ua.ConformanceProfiles[i].Name] {
+			return fmt.Errorf("duplicate conformance profile %s", ua.ConformanceProfiles[i].Name)
+		}
+		names[ua.ConformanceProfiles[i].Name] = true
+	}
+	for i := range ua.Agents {
+		if name := ua.Agents[i].ConformanceProfile; name != "" && !names[name] {
+			return fmt.Errorf("agent %s: unknown conformance profile %s", ua.Agents[i].ID, name)
+		}
+	}
+	return nil
+}
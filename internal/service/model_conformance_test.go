@@ -0,0 +1,75 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformanceProfile__Validate(t *testing.T) {
+	profile := ConformanceProfile{
+		Name:            "bank",
+		LineLength:      96,
+		AllowedSECCodes: []string{"PPD", "CCD"},
+		RequireAddenda:  []string{"CTX"},
+	}
+	require.NoError(t, profile.Validate())
+	require.Equal(t, 10, profile.Blocking())
+
+	profile.LineLength = 80
+	require.ErrorContains(t, profile.Validate(), "shorter than 94")
+
+	profile.LineLength = 0
+	profile.AllowedSECCodes = []string{"PPDX"}
+	require.ErrorContains(t, profile.Validate(), `invalid SEC code "PPDX"`)
+
+	profile.AllowedSECCodes = nil
+	profile.AddendaFormats = []string{"ded", "TXP"}
+	require.NoError(t, profile.Validate())
+
+	profile.AddendaFormats = []string{"STP820"}
+	require.ErrorContains(t, profile.Validate(), `unknown addenda format "STP820"`)
+}
+
+func TestUploadAgents__ConformanceProfile(t *testing.T) {
+	cfg := UploadAgents{
+		Agents: []UploadAgent{
+			{ID: "ftp", ConformanceProfile: "bank"},
+			{ID: "sftp"},
+		},
+		ConformanceProfiles: []ConformanceProfile{
+			{Name: "bank", BlockingFactor: 1},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	profile := cfg.ConformanceProfile("ftp")
+	require.NotNil(t, profile)
+	require.Equal(t, 1, profile.Blocking())
+	require.Nil(t, cfg.ConformanceProfile("sftp"))
+	require.Nil(t, cfg.ConformanceProfile("missing"))
+
+	cfg.Agents[1].ConformanceProfile = "other"
+	require.ErrorContains(t, cfg.Validate(), "agent sftp: unknown conformance profile other")
+
+	cfg.Agents[1].ConformanceProfile = ""
+	cfg.ConformanceProfiles = append(cfg.ConformanceProfiles, ConformanceProfile{Name: "bank"})
+	require.ErrorContains(t, cfg.Validate(), "duplicate conformance profile bank")
+}
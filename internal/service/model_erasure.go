@@ -0,0 +1,92 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/mask"
+)
+
+// ErasureConfig enables POST /erasure on the admin server, which redacts a receiver's personal
+// data from the files and records achgateway keeps and returns a signed report of the changes.
+type ErasureConfig struct {
+	// SigningKeyFile is a PEM encoded Ed25519 private key (PKCS #8) reports are signed with
+	SigningKeyFile string
+
+	// Retention is how long audit trail copies must be kept. Older copies holding the receiver's
+	// entries are deleted when a request includes the audit trail, newer ones are only reported.
+	Retention time.Duration
+
+	// Decryption reads audit trail files encrypted with GPG
+	Decryption *ErasureDecryption
+
+	// Reports saves a copy of every report
+	Reports *AuditTrail
+}
+
+// ErasureDecryption is the GPG private key matching the audit trail's encryption key
+type ErasureDecryption struct {
+	KeyFile     string
+	KeyPassword string
+}
+
+func (cfg *ErasureConfig) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.SigningKeyFile == "" {
+		return errors.New("missing SigningKeyFile")
+	}
+	if cfg.Retention < 0 {
+		return errors.New("negative Retention")
+	}
+	if cfg.Decryption != nil && cfg.Decryption.KeyFile == "" {
+		return errors.New("decryption: missing KeyFile")
+	}
+	if err := cfg.Reports.Validate(); err != nil {
+		return fmt.Errorf("reports: %v", err)
+	}
+	return nil
+}
+
+// RetainFor defaults to two years, which is how long the Nacha Rules require entries to be kept
+func (cfg *ErasureConfig) RetainFor() time.Duration {
+	if cfg == nil || cfg.Retention == 0 {
+		return 2 * 365 * 24 * time.Hour
+	}
+	return cfg.Retention
+}
+
+func (cfg *ErasureDecryption) String() string {
+	return fmt.Sprintf("ErasureDecryption{KeyFile=%s, KeyPassword=%s}", cfg.KeyFile, mask.Password(cfg.KeyPassword))
+}
+
+func (cfg *ErasureDecryption) MarshalJSON() ([]byte, error) {
+	type Aux struct {
+		KeyFile     string
+		KeyPassword string
+	}
+	return json.Marshal(Aux{
+		KeyFile:     cfg.KeyFile,
+		KeyPassword: mask.Password(cfg.KeyPassword),
+	})
+}
@@ -0,0 +1,52 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErasureConfig__Validate(t *testing.T) {
+	var cfg *ErasureConfig
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, 2*365*24*time.Hour, cfg.RetainFor())
+
+	cfg = &ErasureConfig{}
+	require.ErrorContains(t, cfg.Validate(), "missing SigningKeyFile")
+
+	cfg.SigningKeyFile = "erasure.pem"
+	require.NoError(t, cfg.Validate())
+
+	cfg.Retention = -1 * time.Hour
+	require.ErrorContains(t, cfg.Validate(), "negative Retention")
+
+	cfg.Retention = 7 * 365 * 24 * time.Hour
+	cfg.Decryption = &ErasureDecryption{}
+	require.ErrorContains(t, cfg.Validate(), "missing KeyFile")
+
+	cfg.Decryption.KeyFile = "audit.key"
+	cfg.Reports = &AuditTrail{}
+	require.ErrorContains(t, cfg.Validate(), "reports: missing bucket_uri")
+
+	cfg.Reports.BucketURI = "mem://"
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, 7*365*24*time.Hour, cfg.RetainFor())
+}
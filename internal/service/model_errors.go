@@ -18,8 +18,12 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+
+	"github.com/moov-io/achgateway/internal/mask"
 )
 
 type ErrorAlerting struct {
@@ -45,6 +49,21 @@ type PagerDutyAlerting struct {
 	RoutingKey string
 }
 
+func (cfg PagerDutyAlerting) MarshalJSON() ([]byte, error) {
+	type Aux PagerDutyAlerting
+	aux := Aux(cfg)
+	aux.ApiKey = mask.Password(cfg.ApiKey)
+	aux.RoutingKey = mask.Password(cfg.RoutingKey)
+	return json.Marshal(aux)
+}
+
+func (cfg PagerDutyAlerting) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("PagerDutyAlerting{ApiKey=%s, ", mask.Password(cfg.ApiKey)))
+	buf.WriteString(fmt.Sprintf("RoutingKey=%s}", mask.Password(cfg.RoutingKey)))
+	return buf.String()
+}
+
 func (cfg PagerDutyAlerting) Validate() error {
 	if cfg.ApiKey == "" {
 		return errors.New("pagerduty error alerting: apiKey is missing")
@@ -65,6 +84,20 @@ type SlackAlerting struct {
 	ChannelID string
 }
 
+func (cfg SlackAlerting) MarshalJSON() ([]byte, error) {
+	type Aux SlackAlerting
+	aux := Aux(cfg)
+	aux.AccessToken = mask.Password(cfg.AccessToken)
+	return json.Marshal(aux)
+}
+
+func (cfg SlackAlerting) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("SlackAlerting{AccessToken=%s, ", mask.Password(cfg.AccessToken)))
+	buf.WriteString(fmt.Sprintf("ChannelID=%s}", cfg.ChannelID))
+	return buf.String()
+}
+
 func (cfg SlackAlerting) Validate() error {
 	if cfg.AccessToken == "" {
 		return errors.New("slack error alerting: access token is missing")
@@ -18,15 +18,31 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/moov-io/achgateway/internal/mask"
 	"github.com/moov-io/achgateway/pkg/models"
 )
 
 type EventsConfig struct {
-	Stream    *EventsStream
-	Webhook   *WebhookConfig
-	Transform *models.TransformConfig
+	Stream     *EventsStream
+	Webhook    *WebhookConfig
+	Transform  *models.TransformConfig
+	Publishing *EventsPublishing
+
+	// Sequence numbers the events about each shard in the order they happened, which is set in
+	// each event's metadata. Instances sharing a Database share sequence numbers.
+	Sequence bool
+
+	// EntryAccepted sends an EntryAccepted event for each entry of an ACH file as it's accepted
+	// into a shard, so fraud and limit checks don't have to wait for FileUploaded.
+	EntryAccepted bool
+
+	// Signing adds an HMAC signature of each event from keys managed with the admin API.
+	Signing *EventsSigning
 }
 
 func (cfg *EventsConfig) Validate() error {
@@ -39,9 +55,66 @@ func (cfg *EventsConfig) Validate() error {
 	if err := cfg.Webhook.Validate(); err != nil {
 		return err
 	}
+	if err := cfg.Publishing.Validate(); err != nil {
+		return fmt.Errorf("publishing: %v", err)
+	}
+	if err := cfg.Signing.Validate(); err != nil {
+		return fmt.Errorf("signing: %v", err)
+	}
 	return nil
 }
 
+// EventsSigning signs events with keys stored in the Database so every instance signs with the
+// same keys. Each signature names the key used so consumers can verify during rotations.
+type EventsSigning struct {
+	// RotationGracePeriod is how long keys replaced by a rotation keep signing events alongside
+	// the new key, for consumers to pick up the new key's secret.
+	RotationGracePeriod time.Duration
+}
+
+func (cfg *EventsSigning) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.RotationGracePeriod < 0 {
+		return errors.New("negative RotationGracePeriod")
+	}
+	return nil
+}
+
+func (cfg *EventsSigning) GracePeriod() time.Duration {
+	if cfg == nil || cfg.RotationGracePeriod == 0 {
+		return 24 * time.Hour
+	}
+	return cfg.RotationGracePeriod
+}
+
+// EventsPublishing hands events off to a pool of workers so callers don't wait for
+// each event to be published before moving on.
+type EventsPublishing struct {
+	// Workers is how many events are published at the same time. Events can be
+	// published out of order when more than one worker is used, except for each
+	// shard's events when Sequence is enabled.
+	Workers int
+}
+
+func (cfg *EventsPublishing) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Workers < 0 {
+		return errors.New("negative Workers")
+	}
+	return nil
+}
+
+func (cfg *EventsPublishing) WorkerCount() int {
+	if cfg == nil || cfg.Workers == 0 {
+		return 4
+	}
+	return cfg.Workers
+}
+
 type EventsStream struct {
 	Kafka *KafkaConfig
 }
@@ -60,6 +133,17 @@ type WebhookConfig struct {
 	Endpoint string
 }
 
+func (cfg *WebhookConfig) MarshalJSON() ([]byte, error) {
+	type Aux WebhookConfig
+	aux := Aux(*cfg)
+	aux.Endpoint = mask.URI(cfg.Endpoint)
+	return json.Marshal(aux)
+}
+
+func (cfg *WebhookConfig) String() string {
+	return fmt.Sprintf("Webhook{Endpoint=%s}", mask.URI(cfg.Endpoint))
+}
+
 func (cfg *WebhookConfig) Validate() error {
 	if cfg == nil {
 		return nil
@@ -0,0 +1,48 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ExposureConfig records the totals uploaded to and returned from each ODFI so the daily net
+// exposure of each ODFI can be reported over GET /exposure on the admin server.
+type ExposureConfig struct {
+	// Limits are daily net exposure limits in cents keyed by ODFI routing number
+	Limits map[string]int
+}
+
+func (cfg *ExposureConfig) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	for routing, limit := range cfg.Limits {
+		if len(routing) != 8 && len(routing) != 9 {
+			return fmt.Errorf("invalid ODFI routing number %q", routing)
+		}
+		if _, err := strconv.ParseUint(routing, 10, 64); err != nil {
+			return fmt.Errorf("invalid ODFI routing number %q", routing)
+		}
+		if limit < 0 {
+			return fmt.Errorf("negative limit %d for %s", limit, routing)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExposureConfig__Validate(t *testing.T) {
+	var cfg *ExposureConfig
+	require.NoError(t, cfg.Validate())
+
+	cfg = &ExposureConfig{
+		Limits: map[string]int{
+			"987654320": 1000000,
+			"12345678":  500,
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Limits["1234"] = 100
+	require.ErrorContains(t, cfg.Validate(), `invalid ODFI routing number "1234"`)
+	delete(cfg.Limits, "1234")
+
+	cfg.Limits["12345678"] = -1
+	require.ErrorContains(t, cfg.Validate(), "negative limit -1 for 12345678")
+}
+
+func TestConfig__ExposureDatabase(t *testing.T) {
+	cfg := &Config{
+		Exposure: &ExposureConfig{},
+	}
+	require.ErrorContains(t, cfg.Validate(), "exposure: missing Database")
+}
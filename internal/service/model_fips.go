@@ -0,0 +1,42 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"errors"
+)
+
+// FIPS restricts achgateway to FIPS-approved algorithms for deployments subject to FedRAMP or FIPS 140 requirements.
+type FIPS struct {
+	// Enabled limits TLS, SSH and hashing to FIPS-approved algorithms.
+	Enabled bool
+
+	// RequireValidatedModule fails startup unless achgateway was built against a FIPS validated
+	// crypto module (GOEXPERIMENT=boringcrypto).
+	RequireValidatedModule bool
+}
+
+func (cfg *FIPS) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.RequireValidatedModule && !cfg.Enabled {
+		return errors.New("RequireValidatedModule needs Enabled")
+	}
+	return nil
+}
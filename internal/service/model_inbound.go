@@ -18,10 +18,15 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/moov-io/achgateway/internal/mask"
 	"github.com/moov-io/achgateway/pkg/models"
 )
 
@@ -31,9 +36,22 @@ type Inbound struct {
 	Kafka *KafkaConfig
 	ODFI  *ODFIFiles
 	Audit *AuditTrail
+	SFTP  *SFTPServer
 }
 
 func (cfg Inbound) Validate() error {
+	if err := cfg.HTTP.ISO20022.Validate(); err != nil {
+		return fmt.Errorf("http: iso20022: %v", err)
+	}
+	if err := cfg.HTTP.Paygate.Validate(); err != nil {
+		return fmt.Errorf("http: paygate: %v", err)
+	}
+	if err := cfg.HTTP.MicroEntries.Validate(); err != nil {
+		return fmt.Errorf("http: micro entries: %v", err)
+	}
+	if err := cfg.HTTP.StagedFiles.Validate(); err != nil {
+		return fmt.Errorf("http: staged files: %v", err)
+	}
 	if err := cfg.InMem.Validate(); err != nil {
 		return fmt.Errorf("inmem: %v", err)
 	}
@@ -43,6 +61,9 @@ func (cfg Inbound) Validate() error {
 	if err := cfg.ODFI.Validate(); err != nil {
 		return fmt.Errorf("odfi: %v", err)
 	}
+	if err := cfg.SFTP.Validate(); err != nil {
+		return fmt.Errorf("sftp: %v", err)
+	}
 	return nil
 }
 
@@ -52,6 +73,18 @@ type HTTPConfig struct {
 
 	Transform    *models.TransformConfig
 	MaxBodyBytes int64
+
+	// ISO20022 accepts pain.001 messages on POST /shards/{shardKey}/pain001/{fileID} when set
+	ISO20022 *ISO20022Config
+
+	// Paygate accepts transfers from legacy moov-io/paygate clients on /transfers when set
+	Paygate *PaygateConfig
+
+	// MicroEntries originates and verifies micro-entries for account validation when set
+	MicroEntries *MicroEntriesConfig
+
+	// StagedFiles accepts files which are validated and totaled, then committed in a second request, when set
+	StagedFiles *StagedFilesConfig
 }
 
 type InMemory struct {
@@ -78,9 +111,37 @@ type KafkaConfig struct {
 	// offsets on read" which leads to "at-most-once" delivery.
 	AutoCommit bool
 
+	// Producer tunes how messages are batched when publishing
+	Producer *KafkaProducer
+
 	Transform *models.TransformConfig
 }
 
+// KafkaProducer controls batching of published messages. Messages are sent once BatchSize
+// of them are waiting or the oldest has waited Linger, whichever comes first.
+type KafkaProducer struct {
+	BatchSize int
+	Linger    time.Duration
+}
+
+func (cfg *KafkaConfig) MarshalJSON() ([]byte, error) {
+	type Aux KafkaConfig
+	aux := Aux(*cfg)
+	aux.Key = mask.Password(cfg.Key)
+	aux.Secret = mask.Password(cfg.Secret)
+	return json.Marshal(aux)
+}
+
+func (cfg *KafkaConfig) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("Kafka{Brokers=%v, ", cfg.Brokers))
+	buf.WriteString(fmt.Sprintf("Key=%s, ", mask.Password(cfg.Key)))
+	buf.WriteString(fmt.Sprintf("Secret=%s, ", mask.Password(cfg.Secret)))
+	buf.WriteString(fmt.Sprintf("Group=%s, ", cfg.Group))
+	buf.WriteString(fmt.Sprintf("Topic=%s}", cfg.Topic))
+	return buf.String()
+}
+
 func (cfg *KafkaConfig) Validate() error {
 	if cfg == nil {
 		return nil
@@ -91,6 +152,9 @@ func (cfg *KafkaConfig) Validate() error {
 	if cfg.Topic == "" {
 		return errors.New("missing topic")
 	}
+	if cfg.Producer != nil && (cfg.Producer.BatchSize < 0 || cfg.Producer.Linger < 0) {
+		return errors.New("negative producer BatchSize or Linger")
+	}
 	return nil
 }
 
@@ -100,6 +164,15 @@ type ODFIFiles struct {
 	ShardNames []string
 	Storage    ODFIStorage
 	Audit      *AuditTrail
+
+	// MaxConcurrentScans limits how many upload agents are scanned at the same time.
+	// Shards sharing an upload agent are always scanned one after another.
+	// Zero scans every agent at once.
+	MaxConcurrentScans int
+
+	// PublishScanSummary sends an ODFIScanCompleted event after each scan listing the
+	// files which were skipped
+	PublishScanSummary bool
 }
 
 func (cfg *ODFIFiles) Validate() error {
@@ -115,6 +188,9 @@ func (cfg *ODFIFiles) Validate() error {
 	if len(cfg.ShardNames) == 0 {
 		return errors.New("missing shard names")
 	}
+	if cfg.MaxConcurrentScans < 0 {
+		return errors.New("negative MaxConcurrentScans")
+	}
 	return nil
 }
 
@@ -124,12 +200,154 @@ type ODFIProcessors struct {
 	Reconciliation ODFIReconciliation
 	Prenotes       ODFIPrenotes
 	Returns        ODFIReturns
+
+	// Export writes the reconciliation and return entries found in ODFI files as BAI2 or CSV
+	Export *ODFIExport
+
+	// Deliveries correlates files an ODFI splits one delivery across
+	Deliveries *ODFIDeliveries
+
+	// Representment re-presents debits returned for insufficient or uncollected funds
+	Representment *ODFIRepresentment
+
+	// Order lists processors by name in the order they handle each file. Processors
+	// not listed run afterwards in their default order (see ODFIProcessorNames).
+	Order []string
 }
 
 func (cfg ODFIProcessors) Validate() error {
+	if err := validateProcessorNames(cfg.Order); err != nil {
+		return fmt.Errorf("order: %v", err)
+	}
+	if err := cfg.Export.Validate(); err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+	if err := cfg.Deliveries.Validate(); err != nil {
+		return fmt.Errorf("deliveries: %v", err)
+	}
+	if err := cfg.Representment.Validate(); err != nil {
+		return fmt.Errorf("representment: %v", err)
+	}
 	return nil
 }
 
+// ODFIDeliveries correlates files an ODFI sends as one logical delivery, like returns split
+// into RET_20220601_1of3.ach, RET_20220601_2of3.ach and RET_20220601_3of3.ach.
+type ODFIDeliveries struct {
+	// FilenamePattern is a regular expression with "delivery", "sequence" and "total" named groups.
+	// Example: RET_(?P<delivery>\d{8})_(?P<sequence>\d+)of(?P<total>\d+)\.ach
+	FilenamePattern string
+
+	// Timeout is how long after its first file a delivery is flagged as incomplete. Defaults to 24h
+	Timeout time.Duration
+}
+
+func (cfg *ODFIDeliveries) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	re, err := regexp.Compile(cfg.FilenamePattern)
+	if err != nil {
+		return fmt.Errorf("invalid FilenamePattern: %v", err)
+	}
+	for _, group := range []string{"delivery", "sequence", "total"} {
+		if re.SubexpIndex(group) < 0 {
+			return fmt.Errorf("FilenamePattern is missing the %q group", group)
+		}
+	}
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("negative Timeout %v", cfg.Timeout)
+	}
+	return nil
+}
+
+func (cfg *ODFIDeliveries) DeliveryTimeout() time.Duration {
+	if cfg == nil || cfg.Timeout <= 0 {
+		return 24 * time.Hour
+	}
+	return cfg.Timeout
+}
+
+// ODFIRepresentment schedules returned debits to be presented again. Nacha only allows entries
+// returned with R01 (insufficient funds) or R09 (uncollected funds) to be re-presented, at most
+// twice. Requires a Database.
+type ODFIRepresentment struct {
+	// ReturnCodes are the codes whose entries are re-presented. Defaults to R01 and R09
+	ReturnCodes []string
+
+	// MaxAttempts is how many times an entry is re-presented. Defaults to 2
+	MaxAttempts int
+
+	// Intervals are how long after each return its entry is re-presented, starting with the
+	// first attempt. The last interval is used for later attempts. Defaults to 48h
+	Intervals []time.Duration
+}
+
+// maxRepresentments is the limit Nacha sets on re-presenting an entry
+const maxRepresentments = 2
+
+func (cfg *ODFIRepresentment) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	for _, code := range cfg.ReturnCodes {
+		if code != "R01" && code != "R09" {
+			return fmt.Errorf("return code %q can't be re-presented", code)
+		}
+	}
+	if cfg.MaxAttempts < 0 {
+		return fmt.Errorf("negative MaxAttempts %d", cfg.MaxAttempts)
+	}
+	if cfg.MaxAttempts > maxRepresentments {
+		return fmt.Errorf("MaxAttempts %d is more than the %d Nacha allows", cfg.MaxAttempts, maxRepresentments)
+	}
+	for _, interval := range cfg.Intervals {
+		if interval <= 0 {
+			return fmt.Errorf("invalid interval %v", interval)
+		}
+	}
+	return nil
+}
+
+// Retries reports if entries returned with code are re-presented
+func (cfg *ODFIRepresentment) Retries(code string) bool {
+	if cfg == nil {
+		return false
+	}
+	codes := cfg.ReturnCodes
+	if len(codes) == 0 {
+		codes = []string{"R01", "R09"}
+	}
+	for i := range codes {
+		if strings.EqualFold(codes[i], code) {
+			return true
+		}
+	}
+	return false
+}
+
+// Attempts returns MaxAttempts or its default
+func (cfg *ODFIRepresentment) Attempts() int {
+	if cfg == nil || cfg.MaxAttempts <= 0 {
+		return maxRepresentments
+	}
+	return cfg.MaxAttempts
+}
+
+// Interval returns how long to wait before an attempt, which starts at 1
+func (cfg *ODFIRepresentment) Interval(attempt int) time.Duration {
+	if cfg == nil || len(cfg.Intervals) == 0 {
+		return 48 * time.Hour
+	}
+	if attempt > len(cfg.Intervals) {
+		attempt = len(cfg.Intervals)
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+	return cfg.Intervals[attempt-1]
+}
+
 type ODFICorrections struct {
 	Enabled     bool
 	PathMatcher string
@@ -160,6 +378,57 @@ type ODFIReturns struct {
 	PathMatcher string
 }
 
+const (
+	ExportFormatBAI2 = "bai2"
+	ExportFormatCSV  = "csv"
+)
+
+// ODFIExport converts reconciliation and return entries into files treasury systems can read.
+// Files matching the Reconciliation and Returns processors' PathMatcher values are exported.
+type ODFIExport struct {
+	// Format is either "bai2" or "csv"
+	Format string
+
+	// Columns are the CSV columns written, in order. Defaults to every column.
+	Columns []string
+
+	BAI2 ODFIExportBAI2
+
+	// UploadAgent is the ID of the upload agent exported files are uploaded to (in its outbound path)
+	UploadAgent string
+
+	// Publish sends each exported file as a TreasuryExportFile event
+	Publish bool
+}
+
+type ODFIExportBAI2 struct {
+	SenderID      string
+	ReceiverID    string
+	AccountNumber string
+
+	// Currency defaults to USD
+	Currency string
+}
+
+func (cfg *ODFIExport) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	switch strings.ToLower(cfg.Format) {
+	case ExportFormatBAI2:
+		if cfg.BAI2.SenderID == "" || cfg.BAI2.ReceiverID == "" || cfg.BAI2.AccountNumber == "" {
+			return errors.New("bai2: missing SenderID, ReceiverID or AccountNumber")
+		}
+	case ExportFormatCSV:
+	default:
+		return fmt.Errorf("unknown format %q", cfg.Format)
+	}
+	if cfg.UploadAgent == "" && !cfg.Publish {
+		return errors.New("missing UploadAgent or Publish")
+	}
+	return nil
+}
+
 type ODFIStorage struct {
 	// Directory is the local filesystem path for downloading files into
 	Directory string
@@ -0,0 +1,106 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestODFIExport__Validate(t *testing.T) {
+	var cfg *ODFIExport
+	require.NoError(t, cfg.Validate())
+
+	cfg = &ODFIExport{Format: "ofx"}
+	require.ErrorContains(t, cfg.Validate(), `unknown format "ofx"`)
+
+	cfg.Format = "BAI2"
+	require.ErrorContains(t, cfg.Validate(), "bai2: missing SenderID, ReceiverID or AccountNumber")
+
+	cfg.BAI2 = ODFIExportBAI2{SenderID: "273976369", ReceiverID: "MOOV", AccountNumber: "987654321"}
+	require.ErrorContains(t, cfg.Validate(), "missing UploadAgent or Publish")
+
+	cfg.UploadAgent = "treasury"
+	require.NoError(t, cfg.Validate())
+
+	processors := ODFIProcessors{Export: &ODFIExport{Format: "csv"}}
+	require.ErrorContains(t, processors.Validate(), "export: missing UploadAgent or Publish")
+}
+
+func TestODFIDeliveries__Validate(t *testing.T) {
+	var cfg *ODFIDeliveries
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, 24*time.Hour, cfg.DeliveryTimeout())
+
+	cfg = &ODFIDeliveries{
+		FilenamePattern: `RET_(?P<delivery>\d{8})_(?P<sequence>\d+)of(?P<total>\d+)\.ach`,
+		Timeout:         2 * time.Hour,
+	}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, 2*time.Hour, cfg.DeliveryTimeout())
+
+	cfg.FilenamePattern = `RET_(?P<delivery>\d{8})_(?P<sequence>\d+)\.ach`
+	require.ErrorContains(t, cfg.Validate(), `FilenamePattern is missing the "total" group`)
+
+	cfg.FilenamePattern = `RET_(`
+	require.ErrorContains(t, cfg.Validate(), "invalid FilenamePattern")
+
+	processors := ODFIProcessors{Deliveries: &ODFIDeliveries{}}
+	require.ErrorContains(t, processors.Validate(), `deliveries: FilenamePattern is missing the "delivery" group`)
+}
+
+func TestODFIRepresentment__Validate(t *testing.T) {
+	var cfg *ODFIRepresentment
+	require.NoError(t, cfg.Validate())
+	require.False(t, cfg.Retries("R01"))
+	require.Equal(t, 2, cfg.Attempts())
+	require.Equal(t, 48*time.Hour, cfg.Interval(1))
+
+	cfg = &ODFIRepresentment{}
+	require.True(t, cfg.Retries("R01"))
+	require.True(t, cfg.Retries("R09"))
+	require.False(t, cfg.Retries("R02"))
+
+	cfg = &ODFIRepresentment{
+		ReturnCodes: []string{"R01"},
+		MaxAttempts: 1,
+		Intervals:   []time.Duration{24 * time.Hour, 72 * time.Hour},
+	}
+	require.NoError(t, cfg.Validate())
+	require.False(t, cfg.Retries("R09"))
+	require.Equal(t, 1, cfg.Attempts())
+	require.Equal(t, 24*time.Hour, cfg.Interval(1))
+	require.Equal(t, 72*time.Hour, cfg.Interval(2))
+	require.Equal(t, 72*time.Hour, cfg.Interval(3))
+
+	cfg.ReturnCodes = []string{"R02"}
+	require.ErrorContains(t, cfg.Validate(), `return code "R02" can't be re-presented`)
+
+	cfg.ReturnCodes = nil
+	cfg.MaxAttempts = 3
+	require.ErrorContains(t, cfg.Validate(), "MaxAttempts 3 is more than the 2 Nacha allows")
+
+	cfg.MaxAttempts = 0
+	cfg.Intervals = []time.Duration{0}
+	require.ErrorContains(t, cfg.Validate(), "invalid interval 0s")
+
+	processors := ODFIProcessors{Representment: &ODFIRepresentment{MaxAttempts: -1}}
+	require.ErrorContains(t, processors.Validate(), "representment: negative MaxAttempts -1")
+}
@@ -0,0 +1,84 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/moov-io/ach"
+)
+
+// ISO20022Config converts ISO 20022 pain.001 credit transfer initiation messages into ACH files.
+type ISO20022Config struct {
+	// ImmediateDestination and ImmediateOrigin are written to the file header of converted files
+	ImmediateDestination     string
+	ImmediateDestinationName string
+	ImmediateOrigin          string
+	ImmediateOriginName      string
+
+	// ODFIIdentification is the ODFI's routing number used for batches when a payment's
+	// debtor agent has no routing number. Defaults to ImmediateOrigin
+	ODFIIdentification string
+
+	// CompanyIdentification is used for batches when the debtor has no organisation ID.
+	CompanyIdentification string
+
+	// CompanyEntryDescription is written to each batch header. Defaults to "PAYMENT"
+	CompanyEntryDescription string
+
+	SECCodes ISO20022SECCodes
+}
+
+// ISO20022SECCodes chooses the Standard Entry Class code of each converted credit transfer.
+// CategoryPurpose is checked first, then whether the creditor is identified as an organisation.
+type ISO20022SECCodes struct {
+	// CategoryPurpose maps ISO 20022 category purpose codes (e.g. SALA, SUPP) to a SEC code
+	CategoryPurpose map[string]string
+
+	// Organisation is used when the creditor has an organisation ID. Defaults to CCD
+	Organisation string
+
+	// Default is used for every other credit transfer. Defaults to PPD
+	Default string
+}
+
+func (cfg *ISO20022Config) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.ImmediateDestination == "" || cfg.ImmediateOrigin == "" {
+		return errors.New("missing ImmediateDestination or ImmediateOrigin")
+	}
+	for purpose, code := range cfg.SECCodes.CategoryPurpose {
+		if !supportedISO20022SECCode(code) {
+			return fmt.Errorf("unsupported SEC code %q for category purpose %s", code, purpose)
+		}
+	}
+	if code := cfg.SECCodes.Organisation; code != "" && !supportedISO20022SECCode(code) {
+		return fmt.Errorf("unsupported Organisation SEC code %q", code)
+	}
+	if code := cfg.SECCodes.Default; code != "" && !supportedISO20022SECCode(code) {
+		return fmt.Errorf("unsupported Default SEC code %q", code)
+	}
+	return nil
+}
+
+func supportedISO20022SECCode(code string) bool {
+	return code == ach.PPD || code == ach.CCD
+}
@@ -0,0 +1,43 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestISO20022Config__Validate(t *testing.T) {
+	var cfg *ISO20022Config
+	require.NoError(t, cfg.Validate())
+
+	cfg = &ISO20022Config{}
+	require.ErrorContains(t, cfg.Validate(), "missing ImmediateDestination or ImmediateOrigin")
+
+	cfg.ImmediateDestination = "231380104"
+	cfg.ImmediateOrigin = "076401251"
+	require.NoError(t, cfg.Validate())
+
+	cfg.SECCodes.CategoryPurpose = map[string]string{"SALA": "WEB"}
+	require.ErrorContains(t, cfg.Validate(), `unsupported SEC code "WEB" for category purpose SALA`)
+
+	cfg.SECCodes.CategoryPurpose = map[string]string{"SALA": "PPD"}
+	cfg.SECCodes.Default = "CTX"
+	require.ErrorContains(t, cfg.Validate(), `unsupported Default SEC code "CTX"`)
+}
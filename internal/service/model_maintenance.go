@@ -0,0 +1,136 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaintenanceWindow is a recurring period when an agent's remote server is unavailable. Cutoffs
+// during a window are deferred until it ends and ODFI files aren't downloaded.
+type MaintenanceWindow struct {
+	// Days the window starts on (like "Sunday"), every day when empty
+	Days []string
+
+	// Start is the time of day (HH:MM) in Timezone when the window starts
+	Start    string
+	Duration time.Duration
+
+	// Timezone defaults to UTC
+	Timezone string
+}
+
+func (cfg MaintenanceWindow) Validate() error {
+	if _, err := time.Parse("15:04", cfg.Start); err != nil {
+		return fmt.Errorf("invalid Start %q", cfg.Start)
+	}
+	if cfg.Duration <= 0 || cfg.Duration > 7*24*time.Hour {
+		return fmt.Errorf("invalid Duration %v", cfg.Duration)
+	}
+	for _, day := range cfg.Days {
+		if _, ok := parseWeekday(day); !ok {
+			return fmt.Errorf("unknown day %q", day)
+		}
+	}
+	if _, err := cfg.location(); err != nil {
+		return fmt.Errorf("invalid Timezone: %v", err)
+	}
+	return nil
+}
+
+func (cfg MaintenanceWindow) location() (*time.Location, error) {
+	if cfg.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(cfg.Timezone)
+}
+
+// Until returns when the window ends if now is within it
+func (cfg MaintenanceWindow) Until(now time.Time) (time.Time, bool) {
+	loc, err := cfg.location()
+	if err != nil {
+		return time.Time{}, false
+	}
+	start, err := time.Parse("15:04", cfg.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	local := now.In(loc)
+
+	// Check each day a window could have started on and still include now
+	days := int(cfg.Duration / (24 * time.Hour))
+	for i := 0; i <= days+1; i++ {
+		day := local.AddDate(0, 0, -i)
+		begins := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc)
+		if !cfg.startsOn(begins.Weekday()) {
+			continue
+		}
+		ends := begins.Add(cfg.Duration)
+		if !local.Before(begins) && local.Before(ends) {
+			return ends, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (cfg MaintenanceWindow) startsOn(weekday time.Weekday) bool {
+	if len(cfg.Days) == 0 {
+		return true
+	}
+	for _, day := range cfg.Days {
+		if wd, ok := parseWeekday(day); ok && wd == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		if strings.EqualFold(name, wd.String()) || strings.EqualFold(name, wd.String()[:3]) {
+			return wd, true
+		}
+	}
+	return time.Sunday, false
+}
+
+// MaintenanceUntil returns when the agent's maintenance ends if now is within one of its
+// windows. Windows which overlap or follow each other are treated as one.
+func (cfg *UploadAgent) MaintenanceUntil(now time.Time) (time.Time, bool) {
+	if cfg == nil {
+		return time.Time{}, false
+	}
+	var until time.Time
+	when := now
+	for i := 0; i < 100; i++ { // bounded in case windows are back to back forever
+		extended := false
+		for _, window := range cfg.MaintenanceWindows {
+			if ends, ok := window.Until(when); ok && ends.After(until) {
+				until = ends
+				extended = true
+			}
+		}
+		if !extended {
+			break
+		}
+		when = until
+	}
+	return until, !until.IsZero()
+}
@@ -0,0 +1,91 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceWindow__Validate(t *testing.T) {
+	window := MaintenanceWindow{
+		Days:     []string{"Sunday", "wed"},
+		Start:    "02:00",
+		Duration: 2 * time.Hour,
+		Timezone: "America/New_York",
+	}
+	require.NoError(t, window.Validate())
+
+	window.Days = []string{"Someday"}
+	require.ErrorContains(t, window.Validate(), `unknown day "Someday"`)
+
+	window.Days = nil
+	window.Start = "2am"
+	require.ErrorContains(t, window.Validate(), "invalid Start")
+
+	window.Start = "02:00"
+	window.Duration = 0
+	require.ErrorContains(t, window.Validate(), "invalid Duration")
+}
+
+func TestMaintenanceWindow__Until(t *testing.T) {
+	nyc, _ := time.LoadLocation("America/New_York")
+	window := MaintenanceWindow{
+		Days:     []string{"Saturday"},
+		Start:    "22:00",
+		Duration: 4 * time.Hour,
+		Timezone: "America/New_York",
+	}
+
+	// 2022-10-15 is a Saturday
+	until, ok := window.Until(time.Date(2022, time.October, 15, 23, 0, 0, 0, nyc))
+	require.True(t, ok)
+	require.Equal(t, time.Date(2022, time.October, 16, 2, 0, 0, 0, nyc), until)
+
+	// windows continue past midnight
+	_, ok = window.Until(time.Date(2022, time.October, 16, 1, 59, 0, 0, nyc))
+	require.True(t, ok)
+
+	_, ok = window.Until(time.Date(2022, time.October, 16, 2, 0, 0, 0, nyc))
+	require.False(t, ok)
+	_, ok = window.Until(time.Date(2022, time.October, 14, 23, 0, 0, 0, nyc))
+	require.False(t, ok)
+}
+
+func TestUploadAgent__MaintenanceUntil(t *testing.T) {
+	var agent *UploadAgent
+	_, ok := agent.MaintenanceUntil(time.Now())
+	require.False(t, ok)
+
+	agent = &UploadAgent{
+		MaintenanceWindows: []MaintenanceWindow{
+			{Start: "01:00", Duration: time.Hour},
+			{Start: "02:00", Duration: 30 * time.Minute},
+		},
+	}
+
+	// back to back windows are combined
+	until, ok := agent.MaintenanceUntil(time.Date(2022, time.October, 14, 1, 30, 0, 0, time.UTC))
+	require.True(t, ok)
+	require.Equal(t, time.Date(2022, time.October, 14, 2, 30, 0, 0, time.UTC), until)
+
+	_, ok = agent.MaintenanceUntil(time.Date(2022, time.October, 14, 3, 0, 0, 0, time.UTC))
+	require.False(t, ok)
+}
@@ -0,0 +1,47 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"fmt"
+)
+
+// MicroEntriesConfig enables originating micro-entries to verify accounts over
+// POST /shards/{shardKey}/micro-entries
+type MicroEntriesConfig struct {
+	// MaxAttempts is how many times the amounts can be guessed before verification fails. Defaults to 3
+	MaxAttempts int
+}
+
+func (cfg *MicroEntriesConfig) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.MaxAttempts < 0 {
+		return fmt.Errorf("negative MaxAttempts %d", cfg.MaxAttempts)
+	}
+	return nil
+}
+
+// Attempts returns MaxAttempts or its default
+func (cfg *MicroEntriesConfig) Attempts() int {
+	if cfg == nil || cfg.MaxAttempts <= 0 {
+		return 3
+	}
+	return cfg.MaxAttempts
+}
@@ -0,0 +1,37 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMicroEntriesConfig__Validate(t *testing.T) {
+	var cfg *MicroEntriesConfig
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, 3, cfg.Attempts())
+
+	cfg = &MicroEntriesConfig{MaxAttempts: 5}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, 5, cfg.Attempts())
+
+	cfg.MaxAttempts = -1
+	require.ErrorContains(t, cfg.Validate(), "negative MaxAttempts -1")
+}
@@ -18,10 +18,15 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
 	"text/template"
 	"time"
+
+	"github.com/moov-io/achgateway/internal/mask"
 )
 
 var (
@@ -84,7 +89,10 @@ func (cfg Notifications) FindSlacks(ids []string) []Slack {
 	return out
 }
 
-func (cfg Notifications) Validate() error {
+func (cfg *Notifications) Validate() error {
+	if cfg == nil {
+		return nil
+	}
 	for i := range cfg.Email {
 		e := cfg.Email[i]
 		if e.From == "" || len(e.To) == 0 || e.ConnectionURI == "" || e.CompanyName == "" {
@@ -123,6 +131,22 @@ type Email struct {
 	CompanyName string
 }
 
+func (cfg Email) MarshalJSON() ([]byte, error) {
+	type Aux Email
+	aux := Aux(cfg)
+	aux.ConnectionURI = mask.URI(cfg.ConnectionURI)
+	return json.Marshal(aux)
+}
+
+func (cfg Email) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("Email{ID=%s, ", cfg.ID))
+	buf.WriteString(fmt.Sprintf("From=%s, ", cfg.From))
+	buf.WriteString(fmt.Sprintf("To=%v, ", cfg.To))
+	buf.WriteString(fmt.Sprintf("ConnectionURI=%s}", mask.URI(cfg.ConnectionURI)))
+	return buf.String()
+}
+
 func (cfg Email) Tmpl() *template.Template {
 	if cfg.Template == "" {
 		return DefaultEmailTemplate
@@ -137,6 +161,23 @@ type PagerDuty struct {
 	ServiceKey string
 }
 
+func (cfg PagerDuty) MarshalJSON() ([]byte, error) {
+	type Aux PagerDuty
+	aux := Aux(cfg)
+	aux.ApiKey = mask.Password(cfg.ApiKey)
+	aux.ServiceKey = mask.Password(cfg.ServiceKey)
+	return json.Marshal(aux)
+}
+
+func (cfg PagerDuty) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("PagerDuty{ID=%s, ", cfg.ID))
+	buf.WriteString(fmt.Sprintf("ApiKey=%s, ", mask.Password(cfg.ApiKey)))
+	buf.WriteString(fmt.Sprintf("From=%s, ", cfg.From))
+	buf.WriteString(fmt.Sprintf("ServiceKey=%s}", mask.Password(cfg.ServiceKey)))
+	return buf.String()
+}
+
 func (cfg PagerDuty) Validate() error {
 	if cfg.ID == "" {
 		return errors.New("pagerduty: missing id")
@@ -153,6 +194,20 @@ type Slack struct {
 	WebhookURL string
 }
 
+func (cfg Slack) MarshalJSON() ([]byte, error) {
+	type Aux Slack
+	aux := Aux(cfg)
+	aux.WebhookURL = mask.Password(cfg.WebhookURL)
+	return json.Marshal(aux)
+}
+
+func (cfg Slack) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("Slack{ID=%s, ", cfg.ID))
+	buf.WriteString(fmt.Sprintf("WebhookURL=%s}", mask.Password(cfg.WebhookURL)))
+	return buf.String()
+}
+
 func (cfg Slack) Validate() error {
 	if cfg.ID == "" {
 		return errors.New("slack: missing id")
@@ -0,0 +1,105 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ODFIProcessorNames are the ODFI processors, in the order they handle files unless
+// ODFIProcessors.Order says otherwise.
+var ODFIProcessorNames = []string{
+	"corrections",
+	"prenotes",
+	"reconciliation",
+	"returns",
+	"incoming",
+	"micro_entries",
+	"return_exposure",
+	"deliveries",
+	"representment",
+	"export",
+}
+
+func validateProcessorNames(names []string) error {
+	seen := make(map[string]bool)
+	for i := range names {
+		name := strings.ToLower(names[i])
+		if !knownProcessor(name) {
+			return fmt.Errorf("unknown processor %q", names[i])
+		}
+		if seen[name] {
+			return fmt.Errorf("processor %s is listed twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func knownProcessor(name string) bool {
+	for i := range ODFIProcessorNames {
+		if ODFIProcessorNames[i] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ShardODFIProcessors chooses which ODFI processors handle a shard's files, such as only running
+// reconciliation for shards which receive reconciliation files.
+type ShardODFIProcessors struct {
+	// Enabled are the only processors run when set
+	Enabled []string
+
+	// Disabled processors are never run
+	Disabled []string
+}
+
+func (cfg *ShardODFIProcessors) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if err := validateProcessorNames(cfg.Enabled); err != nil {
+		return fmt.Errorf("enabled: %v", err)
+	}
+	if err := validateProcessorNames(cfg.Disabled); err != nil {
+		return fmt.Errorf("disabled: %v", err)
+	}
+	return nil
+}
+
+// Runs returns true if the named processor handles the shard's files
+func (cfg *ShardODFIProcessors) Runs(name string) bool {
+	if cfg == nil {
+		return true
+	}
+	if len(cfg.Enabled) > 0 && !containsFold(cfg.Enabled, name) {
+		return false
+	}
+	return !containsFold(cfg.Disabled, name)
+}
+
+func containsFold(names []string, name string) bool {
+	for i := range names {
+		if strings.EqualFold(names[i], name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestODFIProcessors__Order(t *testing.T) {
+	cfg := ODFIProcessors{Order: []string{"Returns", "corrections"}}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Order = []string{"returns", "refunds"}
+	require.ErrorContains(t, cfg.Validate(), `order: unknown processor "refunds"`)
+
+	cfg.Order = []string{"returns", "Returns"}
+	require.ErrorContains(t, cfg.Validate(), "order: processor returns is listed twice")
+}
+
+func TestShardODFIProcessors(t *testing.T) {
+	var cfg *ShardODFIProcessors
+	require.NoError(t, cfg.Validate())
+	require.True(t, cfg.Runs("reconciliation"))
+
+	cfg = &ShardODFIProcessors{Enabled: []string{"Reconciliation", "returns"}}
+	require.NoError(t, cfg.Validate())
+	require.True(t, cfg.Runs("reconciliation"))
+	require.False(t, cfg.Runs("corrections"))
+
+	cfg = &ShardODFIProcessors{Disabled: []string{"reconciliation"}}
+	require.True(t, cfg.Runs("corrections"))
+	require.False(t, cfg.Runs("reconciliation"))
+
+	cfg.Disabled = []string{"recon"}
+	require.ErrorContains(t, cfg.Validate(), `disabled: unknown processor "recon"`)
+}
@@ -0,0 +1,45 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// PaygateConfig accepts transfers shaped like the legacy moov-io/paygate API on /transfers so
+// paygate clients can submit payments to achgateway while they migrate.
+type PaygateConfig struct {
+	// CustomersEndpoint is the moov-io/customers server which the customers and accounts
+	// of each transfer are read from. Example: http://customers:8087
+	CustomersEndpoint string
+}
+
+func (cfg *PaygateConfig) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.CustomersEndpoint == "" {
+		return errors.New("missing CustomersEndpoint")
+	}
+	if u, err := url.Parse(cfg.CustomersEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid CustomersEndpoint %q", cfg.CustomersEndpoint)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaygateConfig__Validate(t *testing.T) {
+	var cfg *PaygateConfig
+	require.NoError(t, cfg.Validate())
+
+	cfg = &PaygateConfig{}
+	require.ErrorContains(t, cfg.Validate(), "missing CustomersEndpoint")
+
+	cfg.CustomersEndpoint = "customers:8087"
+	require.ErrorContains(t, cfg.Validate(), `invalid CustomersEndpoint "customers:8087"`)
+
+	cfg.CustomersEndpoint = "http://customers:8087"
+	require.NoError(t, cfg.Validate())
+}
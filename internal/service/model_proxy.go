@@ -0,0 +1,92 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/moov-io/achgateway/internal/mask"
+)
+
+// Proxy is a SOCKS5 or HTTP CONNECT proxy which FTP and SFTP agents connect to servers through,
+// for networks where egress to a bank must go through one.
+type Proxy struct {
+	// Address is the proxy's URL, like socks5://proxy.example.com:1080 or http://proxy.example.com:3128
+	Address string
+
+	// Username and Password authenticate with the proxy when it requires them
+	Username string
+	Password string
+}
+
+func (cfg *Proxy) MarshalJSON() ([]byte, error) {
+	type Aux struct {
+		Address  string
+		Username string
+		Password string
+	}
+	return json.Marshal(Aux{
+		Address:  cfg.Address,
+		Username: cfg.Username,
+		Password: mask.Password(cfg.Password),
+	})
+}
+
+func (cfg *Proxy) String() string {
+	if cfg == nil {
+		return "Proxy{}"
+	}
+	return fmt.Sprintf("Proxy{Address=%s, Username=%s, Password=%s}", cfg.Address, cfg.Username, mask.Password(cfg.Password))
+}
+
+func (cfg *Proxy) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	u, err := cfg.URL()
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "socks5", "http":
+	default:
+		return fmt.Errorf("unsupported Address scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return fmt.Errorf("Address %q needs a host and port", cfg.Address)
+	}
+	if cfg.Password != "" && cfg.Username == "" {
+		return errors.New("missing Username")
+	}
+	return nil
+}
+
+// URL parses Address
+func (cfg *Proxy) URL() (*url.URL, error) {
+	if cfg == nil || cfg.Address == "" {
+		return nil, errors.New("missing Address")
+	}
+	u, err := url.Parse(cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Address: %v", err)
+	}
+	return u, nil
+}
@@ -0,0 +1,73 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy__Validate(t *testing.T) {
+	var cfg *Proxy
+	require.NoError(t, cfg.Validate())
+
+	cfg = &Proxy{Address: "socks5://proxy.example.com:1080"}
+	require.NoError(t, cfg.Validate())
+
+	cfg = &Proxy{Address: "http://proxy.example.com:3128", Username: "moov", Password: "secret"}
+	require.NoError(t, cfg.Validate())
+
+	cfg = &Proxy{}
+	require.ErrorContains(t, cfg.Validate(), "missing Address")
+
+	cfg = &Proxy{Address: "https://proxy.example.com:443"}
+	require.ErrorContains(t, cfg.Validate(), `unsupported Address scheme "https"`)
+
+	cfg = &Proxy{Address: "socks5://proxy.example.com"}
+	require.ErrorContains(t, cfg.Validate(), "needs a host and port")
+
+	cfg = &Proxy{Address: "socks5://proxy.example.com:1080", Password: "secret"}
+	require.ErrorContains(t, cfg.Validate(), "missing Username")
+
+	agents := UploadAgents{
+		Agents: []UploadAgent{
+			{
+				ID:   "sftp",
+				SFTP: &SFTP{Proxy: &Proxy{Address: "proxy:1080"}},
+			},
+		},
+	}
+	require.ErrorContains(t, agents.Validate(), "agent sftp: sftp: proxy:")
+}
+
+func TestProxy__MarshalJSON(t *testing.T) {
+	cfg := &SFTP{
+		Hostname: "sftp.bank.com:22",
+		Proxy: &Proxy{
+			Address:  "socks5://proxy.example.com:1080",
+			Username: "moov",
+			Password: "secret-password",
+		},
+	}
+	bs, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	require.Contains(t, string(bs), `"Address":"socks5://proxy.example.com:1080"`)
+	require.NotContains(t, string(bs), "secret-password")
+}
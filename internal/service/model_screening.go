@@ -0,0 +1,83 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Screening checks the receivers of each entry against sanctions or internal blocklists
+// before a file is accepted for merging.
+type Screening struct {
+	HTTP      *HTTPScreening
+	Blocklist *Blocklist
+
+	// FailOpen accepts files when the screener cannot be reached. By default files are
+	// left unaccepted so they're retried later.
+	FailOpen bool
+}
+
+func (cfg *Screening) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.HTTP == nil && cfg.Blocklist == nil {
+		return errors.New("missing HTTP or Blocklist")
+	}
+	if err := cfg.HTTP.Validate(); err != nil {
+		return fmt.Errorf("http: %v", err)
+	}
+	return nil
+}
+
+// HTTPScreening sends each file's entries to an external service for a decision.
+type HTTPScreening struct {
+	Endpoint string
+	Timeout  time.Duration
+}
+
+func (cfg *HTTPScreening) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Endpoint == "" {
+		return errors.New("missing endpoint")
+	}
+	if cfg.Timeout < 0 {
+		return errors.New("negative timeout")
+	}
+	return nil
+}
+
+func (cfg *HTTPScreening) RequestTimeout() time.Duration {
+	if cfg == nil || cfg.Timeout == 0 {
+		return 10 * time.Second
+	}
+	return cfg.Timeout
+}
+
+// Blocklist rejects entries sent to any of the listed receivers.
+type Blocklist struct {
+	// Names are compared case-insensitively against each entry's IndividualName.
+	Names []string
+
+	// Accounts are compared against each entry's DFIAccountNumber.
+	Accounts []string
+}
@@ -0,0 +1,135 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// secretFieldNames matches config fields which hold credentials and must never be
+// written to logs or the /config admin endpoint unmasked.
+var secretFieldNames = regexp.MustCompile(`(?i)((password|secret|token|apikey|servicekey|routingkey|privatekey|base64key|keypem|connectionuri|webhookurl)$|^key$)`)
+
+type secretField struct {
+	path  string
+	value string
+	owner reflect.Value
+}
+
+// fillSecrets populates every string and []byte field reachable from v with a value
+// unique to its path and returns the fields considered secret.
+func fillSecrets(v reflect.Value, path string, depth int) []secretField {
+	if depth > 16 {
+		return nil
+	}
+	var out []secretField
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		out = append(out, fillSecrets(v.Elem(), path, depth+1)...)
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			fv := v.Field(i)
+			if !fv.CanSet() || field.Tag.Get("json") == "-" {
+				continue
+			}
+			fieldPath := path + "." + field.Name
+
+			value := "sentinel(" + fieldPath + ")"
+			if strings.HasSuffix(field.Name, "URI") || strings.HasSuffix(field.Name, "URL") {
+				value = "smtps://user:" + value + "@localhost:25"
+			}
+			switch {
+			case fv.Kind() == reflect.String:
+				fv.SetString(value)
+			case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8:
+				fv.SetBytes([]byte(value))
+			default:
+				out = append(out, fillSecrets(fv, fieldPath, depth+1)...)
+				continue
+			}
+			if secretFieldNames.MatchString(field.Name) {
+				out = append(out, secretField{
+					path:  fieldPath,
+					value: strings.TrimPrefix(strings.TrimSuffix(value, "@localhost:25"), "smtps://user:"),
+					owner: v,
+				})
+			}
+		}
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			v.Set(reflect.ValueOf([]string{path}).Convert(v.Type()))
+			return nil
+		}
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		out = append(out, fillSecrets(v.Index(0), path+"[0]", depth+1)...)
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		v.Set(reflect.MakeMap(v.Type()))
+		elem := reflect.New(v.Type().Elem()).Elem()
+		out = append(out, fillSecrets(elem, path+"[k]", depth+1)...)
+		v.SetMapIndex(reflect.ValueOf("k").Convert(v.Type().Key()), elem)
+	}
+	return out
+}
+
+func TestConfig__SecretsMasked(t *testing.T) {
+	var cfg Config
+	secrets := fillSecrets(reflect.ValueOf(&cfg), "Config", 0)
+	require.Greater(t, len(secrets), 10)
+
+	bs, err := json.Marshal(&cfg)
+	require.NoError(t, err)
+	for _, secret := range secrets {
+		require.NotContains(t, string(bs), secret.value, "%s leaked in JSON", secret.path)
+
+		encoded := base64.StdEncoding.EncodeToString([]byte(secret.value))
+		require.NotContains(t, string(bs), encoded, "%s leaked in JSON", secret.path)
+	}
+
+	// Every type in achgateway which holds a secret needs a masked String() method
+	for _, secret := range secrets {
+		owner := secret.owner.Addr().Interface()
+		if !strings.HasPrefix(secret.owner.Type().PkgPath(), "github.com/moov-io/achgateway") {
+			continue // other modules mask their own types
+		}
+
+		stringer, ok := owner.(fmt.Stringer)
+		require.True(t, ok, "%T holds %s and needs a masked String()", owner, secret.path)
+		require.NotContains(t, stringer.String(), secret.value, "%s leaked in String()", secret.path)
+
+		require.NotContains(t, fmt.Sprintf("%v", owner), secret.value, "%s leaked with %%v", secret.path)
+		require.NotContains(t, fmt.Sprintf("%+v", owner), secret.value, "%s leaked with %%+v", secret.path)
+	}
+}
@@ -0,0 +1,88 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// SFTPServer runs an embedded SFTP server which partners upload ACH files to. Each partner
+// authenticates with a public key and is chrooted to RootDirectory/<username>. Uploaded files
+// are submitted to the partner's shard key like files from HTTP or Kafka.
+type SFTPServer struct {
+	BindAddress string
+
+	// HostKeyFile is the path to a PEM encoded private key which identifies the server
+	HostKeyFile string
+
+	// RootDirectory holds a directory of uploads for each partner
+	RootDirectory string
+
+	// Partners are used when no database is configured, otherwise partners are read
+	// from the sftp_partners table.
+	Partners []SFTPPartner
+}
+
+// SFTPPartner is an external partner allowed to upload files over SFTP
+type SFTPPartner struct {
+	Username string
+	ShardKey string
+
+	// PublicKey is in the authorized_keys format (e.g. "ssh-ed25519 AAAA...")
+	PublicKey string
+}
+
+func (cfg *SFTPServer) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.BindAddress == "" {
+		return errors.New("missing BindAddress")
+	}
+	if cfg.HostKeyFile == "" {
+		return errors.New("missing HostKeyFile")
+	}
+	if cfg.RootDirectory == "" {
+		return errors.New("missing RootDirectory")
+	}
+	for i := range cfg.Partners {
+		if err := cfg.Partners[i].Validate(); err != nil {
+			return fmt.Errorf("partner[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (p SFTPPartner) Validate() error {
+	if p.Username == "" {
+		return errors.New("missing Username")
+	}
+	// Usernames are directory names under RootDirectory
+	if p.Username != filepath.Base(p.Username) || p.Username == "." || p.Username == ".." {
+		return fmt.Errorf("invalid Username %q", p.Username)
+	}
+	if p.ShardKey == "" {
+		return errors.New("missing ShardKey")
+	}
+	if p.PublicKey == "" {
+		return errors.New("missing PublicKey")
+	}
+	return nil
+}
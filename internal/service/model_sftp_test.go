@@ -0,0 +1,47 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSFTPServer__Validate(t *testing.T) {
+	var cfg *SFTPServer
+	require.NoError(t, cfg.Validate())
+
+	cfg = &SFTPServer{}
+	require.ErrorContains(t, cfg.Validate(), "missing BindAddress")
+
+	cfg.BindAddress = ":2022"
+	cfg.HostKeyFile = "/conf/host_key"
+	cfg.RootDirectory = "/data/sftp"
+	require.NoError(t, cfg.Validate())
+
+	cfg.Partners = []SFTPPartner{{Username: "../acme", ShardKey: "acme", PublicKey: "ssh-ed25519 AAAA"}}
+	require.ErrorContains(t, cfg.Validate(), `partner[0]: invalid Username "../acme"`)
+
+	cfg.Partners[0].Username = "acme"
+	cfg.Partners[0].PublicKey = ""
+	require.ErrorContains(t, cfg.Validate(), "partner[0]: missing PublicKey")
+
+	cfg.Partners[0].PublicKey = "ssh-ed25519 AAAA"
+	require.NoError(t, cfg.Validate())
+}
@@ -36,12 +36,20 @@ var (
 	//  - 20191010-0830-987654320.ach
 	//  - 20191010-0830-987654320.ach.gpg (GPG encrypted)
 	DefaultFilenameTemplate = `{{ date "20060102" }}-{{ date "150405" }}-{{ .RoutingNumber }}.ach{{ if .GPG }}.gpg{{ end }}`
+
+	// DefaultCPA005FilenameTemplate is used for shards uploading CPA Standard 005 files where
+	// .RoutingNumber is the destination data centre. Example: 20191010-083000-00120.005
+	DefaultCPA005FilenameTemplate = `{{ date "20060102" }}-{{ date "150405" }}-{{ .RoutingNumber }}.005`
 )
 
 type Sharding struct {
 	Shards   []Shard
 	Mappings map[string]ShardMapping
 	Default  string
+
+	// MaxConcurrentCutoffs limits how many shards process their cutoff at the same time.
+	// Zero allows every shard to run at once.
+	MaxConcurrentCutoffs int
 }
 
 type ShardMapping struct {
@@ -59,6 +67,9 @@ func (cfg Sharding) Find(name string) *Shard {
 }
 
 func (cfg Sharding) Validate() error {
+	if cfg.MaxConcurrentCutoffs < 0 {
+		return errors.New("negative MaxConcurrentCutoffs")
+	}
 	for i := range cfg.Shards {
 		if err := cfg.Shards[i].Validate(); err != nil {
 			return fmt.Errorf("shard[%d]: %v", i, err)
@@ -77,6 +88,30 @@ type Shard struct {
 	Output                   *Output
 	Notifications            *Notifications
 	Audit                    *AuditTrail
+	Guardrails               *Guardrails
+	Screening                *Screening
+	FileDefaults             *FileDefaults
+
+	// ValidateOpts are used when converting files for the shard on /shards/{shardKey}/convert/...
+	ValidateOpts *ach.ValidateOpts
+
+	// CPA005 makes the shard accept and upload Canadian EFT (CPA Standard 005) files instead of Nacha files
+	CPA005 *CPA005Config
+
+	// Settlement adds expected settlement dates of each entry to FileUploaded events
+	Settlement *SettlementConfig
+
+	// NotifyRejections sends an Info notification when a file submitted for the shard is rejected
+	NotifyRejections bool
+
+	// UploadPreview sends an Info notification summarizing pending files shortly before each cutoff
+	UploadPreview *UploadPreview
+
+	// ODFIProcessors chooses which processors handle the shard's ODFI files, by default all of them
+	ODFIProcessors *ShardODFIProcessors
+
+	// Manifest uploads a checksum or signature file alongside each outbound file
+	Manifest *ManifestConfig
 }
 
 func (cfg Shard) Validate() error {
@@ -92,21 +127,69 @@ func (cfg Shard) Validate() error {
 	if cfg.UploadAgent == "" {
 		return errors.New("missing upload agent")
 	}
+	if err := cfg.Mergable.ParsedCache.Validate(); err != nil {
+		return fmt.Errorf("mergable: parsed cache: %v", err)
+	}
+	if err := cfg.Mergable.Assignment.Validate(); err != nil {
+		return fmt.Errorf("mergable: assignment: %v", err)
+	}
+	if err := cfg.Mergable.SplitFiles.Validate(); err != nil {
+		return fmt.Errorf("mergable: split files: %v", err)
+	}
+	if split := cfg.Mergable.SplitFiles; split != nil && !strings.Contains(cfg.FilenameTemplate(), split.TemplateField()) {
+		// Each group's files are numbered from zero, so their filenames need to differ
+		return fmt.Errorf("mergable: split files: OutboundFilenameTemplate must include {{ %s }}", split.TemplateField())
+	}
+	if cfg.Mergable.Incremental != nil && cfg.Mergable.Assignment.AssignsTraceNumbers() {
+		// Files are merged as they're accepted, before trace numbers would be assigned
+		return errors.New("mergable: assignment: TraceNumbers can't be assigned with Incremental merging")
+	}
 	if err := cfg.Output.Validate(); err != nil {
 		return fmt.Errorf("output: %v", err)
 	}
 	if err := cfg.Notifications.Validate(); err != nil {
 		return fmt.Errorf("notifications: %v", err)
 	}
+	if err := cfg.UploadPreview.Validate(); err != nil {
+		return fmt.Errorf("upload preview: %v", err)
+	}
 	if err := cfg.Audit.Validate(); err != nil {
 		return fmt.Errorf("audit: %v", err)
 	}
+	if err := cfg.Guardrails.Validate(); err != nil {
+		return fmt.Errorf("guardrails: %v", err)
+	}
+	if err := cfg.Screening.Validate(); err != nil {
+		return fmt.Errorf("screening: %v", err)
+	}
+	if err := cfg.FileDefaults.Validate(); err != nil {
+		return fmt.Errorf("file defaults: %v", err)
+	}
+	if err := cfg.CPA005.Validate(); err != nil {
+		return fmt.Errorf("cpa005: %v", err)
+	}
+	if err := cfg.Settlement.Validate(); err != nil {
+		return fmt.Errorf("settlement: %v", err)
+	}
+	if err := cfg.ODFIProcessors.Validate(); err != nil {
+		return fmt.Errorf("odfi processors: %v", err)
+	}
+	if err := cfg.Manifest.Validate(); err != nil {
+		return fmt.Errorf("manifest: %v", err)
+	}
 	return nil
 }
 
 type Cutoffs struct {
 	Timezone string
 	Windows  []string
+
+	// LateSubmissions decides what happens to files submitted while a cutoff is merging
+	LateSubmissions *LateSubmissions
+
+	// EarlyStart merges and uploads ahead of a window when its pending files are predicted
+	// to miss the ODFI's deadline
+	EarlyStart *EarlyStart
 }
 
 func (cfg Cutoffs) Location() *time.Location {
@@ -124,9 +207,100 @@ func (cfg Cutoffs) Validate() error {
 	if len(cfg.Windows) == 0 {
 		return errors.New("no windows")
 	}
+	if err := cfg.LateSubmissions.Validate(); err != nil {
+		return fmt.Errorf("late submissions: %v", err)
+	}
+	if err := cfg.EarlyStart.Validate(); err != nil {
+		return fmt.Errorf("early start: %v", err)
+	}
+	return nil
+}
+
+// EarlyStart starts a cutoff before its window when merging and uploading the pending files
+// is predicted to finish after Deadline. Predictions use how long the shard's recent cutoffs
+// took per file.
+type EarlyStart struct {
+	// Deadline is how long after each window the ODFI stops accepting files for it
+	Deadline time.Duration
+
+	// MaxLead is the earliest a cutoff is started before its window
+	MaxLead time.Duration
+
+	// CheckInterval is how often pending files are checked within MaxLead of a window
+	CheckInterval time.Duration
+
+	// DurationPerFile predicts cutoffs until the shard has timed some of its own
+	DurationPerFile time.Duration
+}
+
+func (cfg *EarlyStart) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Deadline < 0 || cfg.Deadline > 24*time.Hour {
+		return fmt.Errorf("Deadline of %v must be between 0s and 24h", cfg.Deadline)
+	}
+	if cfg.MaxLead <= 0 || cfg.MaxLead > 12*time.Hour {
+		return fmt.Errorf("MaxLead of %v must be between 1s and 12h", cfg.MaxLead)
+	}
+	if cfg.CheckInterval < 0 {
+		return fmt.Errorf("negative CheckInterval %v", cfg.CheckInterval)
+	}
+	if cfg.DurationPerFile < 0 {
+		return fmt.Errorf("negative DurationPerFile %v", cfg.DurationPerFile)
+	}
 	return nil
 }
 
+// Interval returns CheckInterval, which defaults to one minute
+func (cfg *EarlyStart) Interval() time.Duration {
+	if cfg == nil || cfg.CheckInterval <= 0 {
+		return time.Minute
+	}
+	return cfg.CheckInterval
+}
+
+const (
+	// LateSubmissionsNextWindow accepts late files into the next cutoff and sends a FileRolledOver event
+	LateSubmissionsNextWindow = "next-window"
+
+	// LateSubmissionsReject rejects late files, with 409 Conflict over HTTP and a FileRejected event otherwise
+	LateSubmissionsReject = "reject"
+)
+
+// LateSubmissions is the policy for files submitted after a cutoff window has started merging.
+// Without a policy they're silently left for the next cutoff.
+type LateSubmissions struct {
+	Policy string
+
+	// GracePeriod waits after each cutoff window before merging so files submitted shortly after
+	// the window are still included. Uploads are delayed by the same amount.
+	GracePeriod time.Duration
+}
+
+func (cfg *LateSubmissions) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	switch cfg.Policy {
+	case "", LateSubmissionsNextWindow, LateSubmissionsReject:
+	default:
+		return fmt.Errorf("unknown Policy %q", cfg.Policy)
+	}
+	if cfg.GracePeriod < 0 || cfg.GracePeriod > time.Hour {
+		return fmt.Errorf("GracePeriod of %v must be between 0s and 1h", cfg.GracePeriod)
+	}
+	return nil
+}
+
+// Grace returns how long to wait after a cutoff window before merging
+func (cfg *LateSubmissions) Grace() time.Duration {
+	if cfg == nil {
+		return 0
+	}
+	return cfg.GracePeriod
+}
+
 type PreUpload struct {
 	GPG *GPG
 }
@@ -141,13 +315,348 @@ func (cfg *PreUpload) Validate() error {
 	return nil
 }
 
+// Guardrails compare each merged file against the shard's recent upload history and
+// hold files which look anomalous until an operator approves them.
+type Guardrails struct {
+	// MaxEntryAmount is the largest amount (in cents) allowed for a single EntryDetail.
+	MaxEntryAmount int
+
+	// MaxDebitMultiplier holds files whose total debit amount is more than this
+	// multiple of the median daily debit total over the shard's history.
+	MaxDebitMultiplier float64
+
+	// HistoryDays is how many previous days are considered when computing the median.
+	HistoryDays int
+
+	// MinimumHistoryDays is the number of days with uploads required before
+	// MaxDebitMultiplier is enforced.
+	MinimumHistoryDays int
+
+	// Velocity limits how much can be sent to one receiving account per day
+	// across the files uploaded by every shard with Guardrails.
+	Velocity *Velocity
+}
+
+const (
+	VelocityActionFlag   = "flag"
+	VelocityActionReject = "reject"
+)
+
+type Velocity struct {
+	// MaxEntries is the number of entries allowed to one account per day.
+	MaxEntries int
+
+	// MaxAmount is the total amount (in cents) allowed to one account per day.
+	MaxAmount int
+
+	// Action is either "flag" (notify but upload) or "reject" (hold the file). Defaults to flag.
+	Action string
+}
+
+func (cfg *Velocity) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.MaxEntries < 0 {
+		return errors.New("negative MaxEntries")
+	}
+	if cfg.MaxAmount < 0 {
+		return errors.New("negative MaxAmount")
+	}
+	switch strings.ToLower(cfg.Action) {
+	case "", VelocityActionFlag, VelocityActionReject:
+	default:
+		return fmt.Errorf("unknown action %q", cfg.Action)
+	}
+	return nil
+}
+
+func (cfg *Velocity) Rejects() bool {
+	return cfg != nil && strings.EqualFold(cfg.Action, VelocityActionReject)
+}
+
+func (cfg *Guardrails) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.MaxEntryAmount < 0 {
+		return errors.New("negative MaxEntryAmount")
+	}
+	if cfg.MaxDebitMultiplier < 0 {
+		return errors.New("negative MaxDebitMultiplier")
+	}
+	if cfg.HistoryDays < 0 || cfg.MinimumHistoryDays < 0 {
+		return errors.New("negative history days")
+	}
+	if err := cfg.Velocity.Validate(); err != nil {
+		return fmt.Errorf("velocity: %v", err)
+	}
+	return nil
+}
+
+func (cfg *Guardrails) History() int {
+	if cfg == nil || cfg.HistoryDays == 0 {
+		return 30
+	}
+	return cfg.HistoryDays
+}
+
+func (cfg *Guardrails) MinimumHistory() int {
+	if cfg == nil || cfg.MinimumHistoryDays == 0 {
+		return 5
+	}
+	return cfg.MinimumHistoryDays
+}
+
 type MergableConfig struct {
 	Conditions     *ach.Conditions
 	FlattenBatches *FlattenBatches
+	Incremental    *IncrementalMerging
+	ParsedCache    *ParsedFileCache
+
+	// Assignment replaces trace numbers and file IDs as files are merged
+	Assignment *Assignment
+
+	// SplitFiles uploads separate files for each originating company or SEC code
+	SplitFiles *SplitFiles
 }
 
 type FlattenBatches struct{}
 
+// IncrementalMerging keeps pending files merged in memory as they arrive so a
+// cutoff only needs to finalize and upload them.
+type IncrementalMerging struct{}
+
+// ParsedFileCache keeps accepted files parsed in memory so merging doesn't read
+// and parse them from storage again.
+type ParsedFileCache struct {
+	// MaxFiles limits how many parsed files are kept, files beyond it are read from storage.
+	MaxFiles int
+}
+
+// UploadPreview notifies the shard's Notifications of the entries and totals about to be
+// uploaded, LeadTime before each cutoff.
+type UploadPreview struct {
+	LeadTime time.Duration
+}
+
+func (cfg *UploadPreview) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.LeadTime < time.Minute || cfg.LeadTime > 24*time.Hour {
+		return fmt.Errorf("LeadTime %v must be between 1m and 24h", cfg.LeadTime)
+	}
+	return nil
+}
+
+const (
+	SplitByCompany = "company"
+	SplitBySECCode = "sec-code"
+)
+
+// SplitFiles separates merged files by "company" (the batch's CompanyIdentification) or
+// "sec-code" (its StandardEntryClassCode). Each group is merged on its own and numbered from
+// zero with .Index in filename templates.
+type SplitFiles struct {
+	By string
+}
+
+func (cfg *SplitFiles) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	switch strings.ToLower(cfg.By) {
+	case SplitByCompany, SplitBySECCode:
+	default:
+		return fmt.Errorf("unknown By %q", cfg.By)
+	}
+	return nil
+}
+
+// BatchKey returns the group a batch is uploaded with
+func (cfg *SplitFiles) BatchKey(bh *ach.BatchHeader) string {
+	if cfg == nil || bh == nil {
+		return ""
+	}
+	if strings.EqualFold(cfg.By, SplitBySECCode) {
+		return bh.StandardEntryClassCode
+	}
+	return strings.TrimSpace(bh.CompanyIdentification)
+}
+
+// TemplateField is the filename template field which differs between each group's files
+func (cfg *SplitFiles) TemplateField() string {
+	if cfg != nil && strings.EqualFold(cfg.By, SplitBySECCode) {
+		return ".SECCode"
+	}
+	return ".CompanyIdentification"
+}
+
+const (
+	AssignPreserve   = "preserve"
+	AssignSequential = "sequential"
+	AssignSubmission = "submission"
+)
+
+// Assignment sets how entry trace numbers and the FileIDModifier of merged files are assigned.
+// Each is either "preserve" (default) to keep what was submitted, "sequential" for increasing
+// values per ODFI or "submission" for values derived from the submitted file's ID.
+type Assignment struct {
+	TraceNumbers string
+	FileIDs      string
+}
+
+func (cfg *Assignment) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	for _, strategy := range []string{cfg.TraceNumbers, cfg.FileIDs} {
+		switch strings.ToLower(strategy) {
+		case "", AssignPreserve, AssignSequential, AssignSubmission:
+		default:
+			return fmt.Errorf("unknown strategy %q", strategy)
+		}
+	}
+	return nil
+}
+
+// AssignsTraceNumbers is true when submitted trace numbers are replaced
+func (cfg *Assignment) AssignsTraceNumbers() bool {
+	return cfg != nil && replaces(cfg.TraceNumbers)
+}
+
+// AssignsFileIDs is true when the FileIDModifier of merged files is replaced
+func (cfg *Assignment) AssignsFileIDs() bool {
+	return cfg != nil && replaces(cfg.FileIDs)
+}
+
+func replaces(strategy string) bool {
+	switch strings.ToLower(strategy) {
+	case AssignSequential, AssignSubmission:
+		return true
+	}
+	return false
+}
+
+func (cfg *ParsedFileCache) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.MaxFiles < 0 {
+		return errors.New("negative MaxFiles")
+	}
+	return nil
+}
+
+func (cfg *ParsedFileCache) MaxFileCount() int {
+	if cfg == nil || cfg.MaxFiles <= 0 {
+		return 10000
+	}
+	return cfg.MaxFiles
+}
+
+// FileDefaults are the file and batch header values used when building ACH files from
+// entries submitted to the shard on POST /shards/{shardKey}/entries/{fileID}
+type FileDefaults struct {
+	ImmediateDestination     string
+	ImmediateDestinationName string
+	ImmediateOrigin          string
+	ImmediateOriginName      string
+
+	// ODFIIdentification is the ODFI's routing number. Defaults to ImmediateOrigin
+	ODFIIdentification string
+
+	CompanyName             string
+	CompanyIdentification   string
+	CompanyEntryDescription string
+}
+
+func (cfg *FileDefaults) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.ImmediateDestination == "" || cfg.ImmediateOrigin == "" {
+		return errors.New("missing ImmediateDestination or ImmediateOrigin")
+	}
+	if cfg.CompanyName == "" || cfg.CompanyIdentification == "" {
+		return errors.New("missing CompanyName or CompanyIdentification")
+	}
+	return nil
+}
+
+// CPA005Config holds the header values of CPA Standard 005 files merged and uploaded for a shard
+type CPA005Config struct {
+	// OriginatorID is the 10 character ID assigned by the ODFI
+	OriginatorID string
+
+	// DestinationDataCentre is the 5 digit data centre of the ODFI
+	DestinationDataCentre string
+
+	// Currency is CAD (default) or USD
+	Currency string
+}
+
+func (cfg *CPA005Config) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.OriginatorID == "" || len(cfg.OriginatorID) > 10 {
+		return fmt.Errorf("invalid OriginatorID %q", cfg.OriginatorID)
+	}
+	if len(cfg.DestinationDataCentre) != 5 {
+		return fmt.Errorf("invalid DestinationDataCentre %q", cfg.DestinationDataCentre)
+	}
+	switch cfg.Currency {
+	case "", "CAD", "USD":
+	default:
+		return fmt.Errorf("invalid Currency %q", cfg.Currency)
+	}
+	return nil
+}
+
+// CurrencyCode returns the currency of uploaded files
+func (cfg *CPA005Config) CurrencyCode() string {
+	if cfg == nil || cfg.Currency == "" {
+		return "CAD"
+	}
+	return cfg.Currency
+}
+
+// SettlementConfig holds the rules used to compute when uploaded entries settle
+type SettlementConfig struct {
+	// SameDayCutoff is the last time (HH:MM in Timezone) the ODFI accepts Same-Day entries.
+	// Defaults to the Federal Reserve's final Same-Day window of 16:45 Eastern.
+	SameDayCutoff string
+
+	// Timezone of SameDayCutoff, defaults to America/New_York
+	Timezone string
+
+	// SameDayLimit is the largest entry amount (in cents) eligible for Same-Day settlement.
+	// Defaults to Nacha's $1,000,000 limit.
+	SameDayLimit int
+}
+
+func (cfg *SettlementConfig) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.SameDayCutoff != "" {
+		if _, err := time.Parse("15:04", cfg.SameDayCutoff); err != nil {
+			return fmt.Errorf("invalid SameDayCutoff %q", cfg.SameDayCutoff)
+		}
+	}
+	if cfg.Timezone != "" {
+		if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+			return fmt.Errorf("unknown Timezone=%q", cfg.Timezone)
+		}
+	}
+	if cfg.SameDayLimit < 0 {
+		return errors.New("negative SameDayLimit")
+	}
+	return nil
+}
+
 type Output struct {
 	Format string
 }
@@ -156,7 +665,61 @@ func (cfg *Output) Validate() error {
 	return nil
 }
 
+const (
+	// ManifestSHA256 writes the file's hex encoded SHA-256 checksum in the format of sha256sum
+	ManifestSHA256 = "sha256"
+
+	// ManifestPGP writes an armored detached PGP signature of the file
+	ManifestPGP = "pgp"
+)
+
+// ManifestConfig describes the companion file uploaded after each outbound file. It's generated
+// from the exact bytes uploaded, so after any encryption and output formatting.
+type ManifestConfig struct {
+	// Format is either "sha256" or "pgp"
+	Format string
+
+	// Extension is appended to the outbound filename, by default .sha256 or .sig
+	Extension string
+
+	// Signer is the private key used by the "pgp" format
+	Signer *Signer
+}
+
+func (cfg *ManifestConfig) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	switch cfg.Format {
+	case ManifestSHA256:
+	case ManifestPGP:
+		if cfg.Signer == nil || cfg.Signer.KeyFile == "" {
+			return errors.New("pgp: missing signer key file")
+		}
+	default:
+		return fmt.Errorf("unknown Format %q", cfg.Format)
+	}
+	if cfg.Extension != "" && !strings.HasPrefix(cfg.Extension, ".") {
+		return fmt.Errorf("Extension %q must start with a period", cfg.Extension)
+	}
+	return nil
+}
+
+// Filename returns the name of the manifest uploaded with filename
+func (cfg *ManifestConfig) Filename(filename string) string {
+	if cfg.Extension != "" {
+		return filename + cfg.Extension
+	}
+	if cfg.Format == ManifestPGP {
+		return filename + ".sig"
+	}
+	return filename + ".sha256"
+}
+
 func (cfg *Shard) FilenameTemplate() string {
+	if cfg != nil && cfg.CPA005 != nil && cfg.OutboundFilenameTemplate == "" {
+		return strings.TrimSpace(DefaultCPA005FilenameTemplate)
+	}
 	if cfg == nil || cfg.OutboundFilenameTemplate == "" {
 		return strings.TrimSpace(DefaultFilenameTemplate)
 	}
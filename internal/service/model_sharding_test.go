@@ -20,7 +20,9 @@ package service
 import (
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/moov-io/ach"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 )
@@ -46,3 +48,204 @@ outboundFilenameTemplate: |+
 
 	require.Equal(t, "{{ .ShardName }}-{{ .Index }}.ach", cfg.FilenameTemplate())
 }
+
+func TestFileDefaults__Validate(t *testing.T) {
+	var cfg *FileDefaults
+	require.NoError(t, cfg.Validate())
+
+	cfg = &FileDefaults{
+		ImmediateDestination: "231380104",
+		ImmediateOrigin:      "076401251",
+	}
+	require.ErrorContains(t, cfg.Validate(), "missing CompanyName or CompanyIdentification")
+
+	cfg.CompanyName = "Moov"
+	cfg.CompanyIdentification = "121042882"
+	require.NoError(t, cfg.Validate())
+
+	cfg.ImmediateOrigin = ""
+	require.ErrorContains(t, cfg.Validate(), "missing ImmediateDestination or ImmediateOrigin")
+}
+
+func TestCPA005Config__Validate(t *testing.T) {
+	var cfg *CPA005Config
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "CAD", cfg.CurrencyCode())
+
+	cfg = &CPA005Config{
+		OriginatorID:          "MOOVCA0001",
+		DestinationDataCentre: "00120",
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Currency = "EUR"
+	require.ErrorContains(t, cfg.Validate(), `invalid Currency "EUR"`)
+
+	cfg.Currency = "USD"
+	cfg.OriginatorID = "TOO-LONG-ORIGINATOR"
+	require.ErrorContains(t, cfg.Validate(), "invalid OriginatorID")
+
+	shard := &Shard{CPA005: &CPA005Config{}}
+	require.Equal(t, DefaultCPA005FilenameTemplate, shard.FilenameTemplate())
+}
+
+func TestSettlementConfig__Validate(t *testing.T) {
+	var cfg *SettlementConfig
+	require.NoError(t, cfg.Validate())
+
+	cfg = &SettlementConfig{}
+	require.NoError(t, cfg.Validate())
+
+	cfg.SameDayCutoff = "4:45pm"
+	require.ErrorContains(t, cfg.Validate(), `invalid SameDayCutoff "4:45pm"`)
+
+	cfg.SameDayCutoff = "14:45"
+	cfg.Timezone = "Mars/Olympus"
+	require.ErrorContains(t, cfg.Validate(), `unknown Timezone="Mars/Olympus"`)
+
+	cfg.Timezone = "America/Chicago"
+	cfg.SameDayLimit = -1
+	require.ErrorContains(t, cfg.Validate(), "negative SameDayLimit")
+}
+
+func TestAssignment__Validate(t *testing.T) {
+	var cfg *Assignment
+	require.NoError(t, cfg.Validate())
+	require.False(t, cfg.AssignsTraceNumbers())
+
+	cfg = &Assignment{TraceNumbers: "Sequential", FileIDs: AssignPreserve}
+	require.NoError(t, cfg.Validate())
+	require.True(t, cfg.AssignsTraceNumbers())
+	require.False(t, cfg.AssignsFileIDs())
+
+	cfg.FileIDs = "random"
+	require.ErrorContains(t, cfg.Validate(), `unknown strategy "random"`)
+
+	shard := Shard{
+		Name: "testing",
+		Cutoffs: Cutoffs{
+			Timezone: "America/New_York",
+			Windows:  []string{"17:00"},
+		},
+		UploadAgent: "ftp",
+		Mergable: MergableConfig{
+			Incremental: &IncrementalMerging{},
+			Assignment:  &Assignment{TraceNumbers: AssignSubmission},
+		},
+	}
+	require.ErrorContains(t, shard.Validate(), "can't be assigned with Incremental merging")
+}
+
+func TestSplitFiles__Validate(t *testing.T) {
+	var cfg *SplitFiles
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "", cfg.BatchKey(&ach.BatchHeader{CompanyIdentification: "121042882"}))
+
+	cfg = &SplitFiles{By: "Company"}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "121042882", cfg.BatchKey(&ach.BatchHeader{CompanyIdentification: "121042882 ", StandardEntryClassCode: ach.PPD}))
+	require.Equal(t, ".CompanyIdentification", cfg.TemplateField())
+
+	cfg.By = SplitBySECCode
+	require.Equal(t, ach.PPD, cfg.BatchKey(&ach.BatchHeader{CompanyIdentification: "121042882", StandardEntryClassCode: ach.PPD}))
+	require.Equal(t, ".SECCode", cfg.TemplateField())
+
+	cfg.By = "odfi"
+	require.ErrorContains(t, cfg.Validate(), `unknown By "odfi"`)
+
+	shard := Shard{
+		Name: "testing",
+		Cutoffs: Cutoffs{
+			Timezone: "America/New_York",
+			Windows:  []string{"17:00"},
+		},
+		UploadAgent: "ftp",
+		Mergable: MergableConfig{
+			SplitFiles: &SplitFiles{By: SplitByCompany},
+		},
+	}
+	require.ErrorContains(t, shard.Validate(), "OutboundFilenameTemplate must include {{ .CompanyIdentification }}")
+
+	shard.OutboundFilenameTemplate = `{{ .CompanyIdentification }}-{{ .Index }}.ach`
+	require.NoError(t, shard.Validate())
+}
+
+func TestUploadPreview__Validate(t *testing.T) {
+	var cfg *UploadPreview
+	require.NoError(t, cfg.Validate())
+
+	cfg = &UploadPreview{LeadTime: 15 * time.Minute}
+	require.NoError(t, cfg.Validate())
+
+	cfg.LeadTime = 0
+	require.ErrorContains(t, cfg.Validate(), "LeadTime 0s must be between 1m and 24h")
+
+	cfg.LeadTime = 48 * time.Hour
+	require.ErrorContains(t, cfg.Validate(), "LeadTime 48h0m0s must be between 1m and 24h")
+}
+
+func TestLateSubmissions__Validate(t *testing.T) {
+	var cfg *LateSubmissions
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, time.Duration(0), cfg.Grace())
+
+	cfg = &LateSubmissions{Policy: LateSubmissionsReject, GracePeriod: 2 * time.Minute}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, 2*time.Minute, cfg.Grace())
+
+	cfg.Policy = "drop"
+	require.ErrorContains(t, cfg.Validate(), `unknown Policy "drop"`)
+
+	cfg.Policy = LateSubmissionsNextWindow
+	cfg.GracePeriod = 2 * time.Hour
+	require.ErrorContains(t, cfg.Validate(), "must be between 0s and 1h")
+}
+
+func TestManifestConfig__Validate(t *testing.T) {
+	var cfg *ManifestConfig
+	require.NoError(t, cfg.Validate())
+
+	cfg = &ManifestConfig{Format: ManifestSHA256}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "file.ach.sha256", cfg.Filename("file.ach"))
+
+	cfg.Extension = "sum"
+	require.ErrorContains(t, cfg.Validate(), `Extension "sum" must start with a period`)
+
+	cfg = &ManifestConfig{Format: ManifestPGP}
+	require.ErrorContains(t, cfg.Validate(), "pgp: missing signer key file")
+
+	cfg.Signer = &Signer{KeyFile: "key.priv"}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "file.ach.sig", cfg.Filename("file.ach"))
+
+	cfg.Extension = ".asc"
+	require.Equal(t, "file.ach.asc", cfg.Filename("file.ach"))
+
+	cfg.Format = "md5"
+	require.ErrorContains(t, cfg.Validate(), `unknown Format "md5"`)
+}
+
+func TestEarlyStart__Validate(t *testing.T) {
+	var cfg *EarlyStart
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, time.Minute, cfg.Interval())
+
+	cfg = &EarlyStart{Deadline: 15 * time.Minute, MaxLead: time.Hour}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, time.Minute, cfg.Interval())
+
+	cfg.CheckInterval = 30 * time.Second
+	require.Equal(t, 30*time.Second, cfg.Interval())
+
+	cfg.MaxLead = 0
+	require.ErrorContains(t, cfg.Validate(), "MaxLead of 0s must be between 1s and 12h")
+
+	cfg.MaxLead = time.Hour
+	cfg.Deadline = -time.Minute
+	require.ErrorContains(t, cfg.Validate(), "Deadline of -1m0s must be between 0s and 24h")
+
+	cfg.Deadline = 0
+	cfg.DurationPerFile = -time.Second
+	require.ErrorContains(t, cfg.Validate(), "negative DurationPerFile")
+}
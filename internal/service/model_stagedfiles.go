@@ -0,0 +1,48 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+// StagedFilesConfig enables the two-phase submission API on /shards/{shardKey}/staged-files/{fileID},
+// where files are staged and totaled before they're committed for the next cutoff.
+type StagedFilesConfig struct {
+	// Expiration is how long a staged file can be committed for. Defaults to 24h
+	Expiration time.Duration
+}
+
+func (cfg *StagedFilesConfig) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Expiration < 0 {
+		return fmt.Errorf("negative Expiration %v", cfg.Expiration)
+	}
+	return nil
+}
+
+// Expires returns Expiration or its default
+func (cfg *StagedFilesConfig) Expires() time.Duration {
+	if cfg == nil || cfg.Expiration <= 0 {
+		return 24 * time.Hour
+	}
+	return cfg.Expiration
+}
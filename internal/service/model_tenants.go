@@ -0,0 +1,123 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultTenant selects the ACHGateway config alongside Tenants
+const DefaultTenant = "default"
+
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// SelectTenants returns the config of each named tenant. Names are matched without regard to
+// case since config keys are read in lowercase.
+func (cfg *GlobalConfig) SelectTenants(names []string) (map[string]*Config, error) {
+	tenants := make(map[string]*Config)
+	for i := range names {
+		name := strings.ToLower(strings.TrimSpace(names[i]))
+		if name == "" {
+			continue
+		}
+		if !tenantName.MatchString(name) {
+			return nil, fmt.Errorf("invalid tenant name %q", name)
+		}
+		if _, exists := tenants[name]; exists {
+			return nil, fmt.Errorf("tenant %s is selected twice", name)
+		}
+
+		if name == DefaultTenant {
+			tenants[name] = &cfg.ACHGateway
+			continue
+		}
+		tenant, exists := cfg.Tenants[name]
+		if !exists {
+			return nil, fmt.Errorf("tenant %s is not configured", name)
+		}
+		tenants[name] = &tenant
+	}
+	return tenants, nil
+}
+
+// ValidateTenants checks the tenants run by one process don't share listeners, streams,
+// databases or directories, which would mix their files.
+func ValidateTenants(tenants map[string]*Config) error {
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	seen := make(map[string]string)
+	claim := func(tenant, kind, value string) error {
+		if value == "" {
+			return nil
+		}
+		key := kind + "=" + value
+		if other, exists := seen[key]; exists {
+			return fmt.Errorf("tenants %s and %s share %s %s", other, tenant, kind, value)
+		}
+		seen[key] = tenant
+		return nil
+	}
+
+	for _, name := range names {
+		cfg := tenants[name]
+		if cfg == nil {
+			return fmt.Errorf("tenant %s: missing config", name)
+		}
+		claims := [][2]string{
+			{"Admin.BindAddress", cfg.Admin.BindAddress},
+			{"Inbound.HTTP.BindAddress", cfg.Inbound.HTTP.BindAddress},
+			{"Upload.Merging.Directory", cleanPath(cfg.Upload.Merging.Directory)},
+		}
+		if cfg.Inbound.SFTP != nil {
+			claims = append(claims, [2]string{"Inbound.SFTP.BindAddress", cfg.Inbound.SFTP.BindAddress})
+		}
+		if cfg.Inbound.InMem != nil {
+			claims = append(claims, [2]string{"Inbound.InMem.URL", cfg.Inbound.InMem.URL})
+		}
+		if cfg.Inbound.ODFI != nil {
+			claims = append(claims, [2]string{"Inbound.ODFI.Storage.Directory", cleanPath(cfg.Inbound.ODFI.Storage.Directory)})
+		}
+		if cfg.Database.SQLite != nil {
+			claims = append(claims, [2]string{"Database.SQLite.Path", cleanPath(cfg.Database.SQLite.Path)})
+		}
+		if cfg.Database.MySQL != nil {
+			claims = append(claims, [2]string{"database", cfg.Database.MySQL.Address + "/" + cfg.Database.DatabaseName})
+		}
+		for _, c := range claims {
+			if err := claim(name, c[0], c[1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func cleanPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return filepath.Clean(path)
+}
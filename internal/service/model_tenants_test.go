@@ -0,0 +1,78 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobalConfig__SelectTenants(t *testing.T) {
+	cfg := &GlobalConfig{
+		ACHGateway: Config{Admin: Admin{BindAddress: ":9494"}},
+		Tenants: map[string]Config{
+			"sandbox": {Admin: Admin{BindAddress: ":9595"}},
+		},
+	}
+
+	tenants, err := cfg.SelectTenants([]string{"default", " Sandbox ", ""})
+	require.NoError(t, err)
+	require.Len(t, tenants, 2)
+	require.Equal(t, ":9494", tenants[DefaultTenant].Admin.BindAddress)
+	require.Equal(t, ":9595", tenants["sandbox"].Admin.BindAddress)
+
+	_, err = cfg.SelectTenants([]string{"pilot"})
+	require.ErrorContains(t, err, "tenant pilot is not configured")
+
+	_, err = cfg.SelectTenants([]string{"sandbox", "sandbox"})
+	require.ErrorContains(t, err, "tenant sandbox is selected twice")
+
+	_, err = cfg.SelectTenants([]string{"sand box"})
+	require.ErrorContains(t, err, `invalid tenant name "sand box"`)
+}
+
+func TestValidateTenants(t *testing.T) {
+	tenants := map[string]*Config{
+		"default": {
+			Admin:    Admin{BindAddress: ":9494"},
+			Inbound:  Inbound{HTTP: HTTPConfig{BindAddress: ":8484"}},
+			Database: database.DatabaseConfig{DatabaseName: "achgateway", MySQL: &database.MySQLConfig{Address: "tcp(mysql:3306)"}},
+		},
+		"sandbox": {
+			Admin:    Admin{BindAddress: ":9495"},
+			Inbound:  Inbound{HTTP: HTTPConfig{BindAddress: ":8485"}},
+			Database: database.DatabaseConfig{DatabaseName: "sandbox", MySQL: &database.MySQLConfig{Address: "tcp(mysql:3306)"}},
+		},
+	}
+	require.NoError(t, ValidateTenants(tenants))
+
+	tenants["sandbox"].Inbound.HTTP.BindAddress = ":8484"
+	require.ErrorContains(t, ValidateTenants(tenants), "tenants default and sandbox share Inbound.HTTP.BindAddress :8484")
+
+	tenants["sandbox"].Inbound.HTTP.BindAddress = ":8485"
+	tenants["sandbox"].Database.DatabaseName = "achgateway"
+	require.ErrorContains(t, ValidateTenants(tenants), "share database tcp(mysql:3306)/achgateway")
+
+	tenants["sandbox"].Database.DatabaseName = "sandbox"
+	tenants["default"].Upload.Merging.Directory = "storage/"
+	tenants["sandbox"].Upload.Merging.Directory = "./storage"
+	require.ErrorContains(t, ValidateTenants(tenants), "share Upload.Merging.Directory storage")
+}
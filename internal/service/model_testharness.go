@@ -0,0 +1,149 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/mask"
+)
+
+// ACHTestHarness points an upload agent at moov-io/ach-test-harness, which replies to uploaded
+// files with returns and corrections for entries matching its Responses.
+// See https://github.com/moov-io/ach-test-harness
+type ACHTestHarness struct {
+	// FTP is the harness's FTP server as host:port, defaults to localhost:2222
+	FTP      string
+	Username string
+	Password string
+
+	// AdminEndpoint is the harness's admin HTTP server. When set the Responses are
+	// provisioned on the harness as achgateway starts.
+	AdminEndpoint string
+
+	Responses []ACHTestHarnessResponse
+}
+
+// ACHTestHarnessResponse replies to each uploaded entry matching Match with Action
+type ACHTestHarnessResponse struct {
+	Match  ACHTestHarnessMatch  `json:"match"`
+	Action ACHTestHarnessAction `json:"action"`
+}
+
+type ACHTestHarnessMatch struct {
+	AccountNumber  string                `json:"accountNumber,omitempty"`
+	RoutingNumber  string                `json:"routingNumber,omitempty"`
+	TraceNumber    string                `json:"traceNumber,omitempty"`
+	IndividualName string                `json:"individualName,omitempty"`
+	EntryType      string                `json:"entryType,omitempty"` // credit, debit or prenote
+	Amount         *ACHTestHarnessAmount `json:"amount,omitempty"`
+}
+
+// ACHTestHarnessAmount matches an exact Value or an amount between Min and Max (in cents)
+type ACHTestHarnessAmount struct {
+	Value int `json:"value,omitempty"`
+	Min   int `json:"min,omitempty"`
+	Max   int `json:"max,omitempty"`
+}
+
+type ACHTestHarnessAction struct {
+	Delay      *time.Duration            `json:"delay,omitempty"`
+	Return     *ACHTestHarnessReturn     `json:"return,omitempty"`
+	Correction *ACHTestHarnessCorrection `json:"correction,omitempty"`
+}
+
+type ACHTestHarnessReturn struct {
+	Code string `json:"code"`
+}
+
+type ACHTestHarnessCorrection struct {
+	Code string `json:"code"`
+	Data string `json:"data"`
+}
+
+func (cfg *ACHTestHarness) MarshalJSON() ([]byte, error) {
+	type Aux ACHTestHarness
+	aux := Aux(*cfg)
+	aux.Password = mask.Password(cfg.Password)
+	return json.Marshal(aux)
+}
+
+func (cfg *ACHTestHarness) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("ACHTestHarness{FTP=%s, ", cfg.Address()))
+	buf.WriteString(fmt.Sprintf("Username=%s, ", cfg.Username))
+	buf.WriteString(fmt.Sprintf("Password=%s, ", mask.Password(cfg.Password)))
+	buf.WriteString(fmt.Sprintf("AdminEndpoint=%s}", cfg.AdminEndpoint))
+	return buf.String()
+}
+
+func (cfg *ACHTestHarness) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if len(cfg.Responses) > 0 && cfg.AdminEndpoint == "" {
+		return errors.New("missing AdminEndpoint to provision Responses")
+	}
+	for i := range cfg.Responses {
+		action := cfg.Responses[i].Action
+		if action.Return == nil && action.Correction == nil {
+			return fmt.Errorf("response[%d]: missing return or correction", i)
+		}
+		if action.Return != nil && action.Correction != nil {
+			return fmt.Errorf("response[%d]: only one of return or correction is allowed", i)
+		}
+	}
+	return nil
+}
+
+// Address returns the host:port of the harness's FTP server
+func (cfg *ACHTestHarness) Address() string {
+	if cfg == nil || cfg.FTP == "" {
+		return "localhost:2222"
+	}
+	return cfg.FTP
+}
+
+// WithTestHarness returns the agent's config with FTP and Paths pointed at its TestHarness,
+// using the harness's default credentials and paths for anything not configured.
+func (cfg UploadAgent) WithTestHarness() *UploadAgent {
+	if cfg.TestHarness == nil {
+		return &cfg
+	}
+	ftp := &FTP{
+		Hostname: cfg.TestHarness.Address(),
+		Username: cfg.TestHarness.Username,
+		Password: cfg.TestHarness.Password,
+	}
+	if ftp.Username == "" && ftp.Password == "" {
+		ftp.Username, ftp.Password = "admin", "secret"
+	}
+	cfg.FTP = ftp
+	cfg.SFTP = nil
+	if cfg.Paths.Outbound == "" {
+		cfg.Paths.Outbound = "outbound"
+	}
+	if cfg.Paths.Return == "" {
+		cfg.Paths.Return = "returned"
+	}
+	return &cfg
+}
@@ -0,0 +1,70 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestACHTestHarness__Validate(t *testing.T) {
+	var cfg *ACHTestHarness
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "localhost:2222", cfg.Address())
+
+	cfg = &ACHTestHarness{
+		Responses: []ACHTestHarnessResponse{
+			{Match: ACHTestHarnessMatch{AccountNumber: "987654321"}},
+		},
+	}
+	require.ErrorContains(t, cfg.Validate(), "missing AdminEndpoint")
+
+	cfg.AdminEndpoint = "http://localhost:3334"
+	require.ErrorContains(t, cfg.Validate(), "response[0]: missing return or correction")
+
+	cfg.Responses[0].Action.Return = &ACHTestHarnessReturn{Code: "R03"}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Responses[0].Action.Correction = &ACHTestHarnessCorrection{Code: "C01", Data: "123456789"}
+	require.ErrorContains(t, cfg.Validate(), "only one of return or correction")
+}
+
+func TestUploadAgent__WithTestHarness(t *testing.T) {
+	agent := UploadAgent{
+		ID:          "harness",
+		TestHarness: &ACHTestHarness{FTP: "harness:2222"},
+		Paths: UploadPaths{
+			Return: "returns",
+		},
+	}
+	require.Equal(t, "harness:2222", agent.Hostname())
+
+	cfg := agent.WithTestHarness()
+	require.Equal(t, "harness:2222", cfg.FTP.Hostname)
+	require.Equal(t, "admin", cfg.FTP.Username)
+	require.Equal(t, "secret", cfg.FTP.Password)
+	require.Equal(t, "outbound", cfg.Paths.Outbound)
+	require.Equal(t, "returns", cfg.Paths.Return)
+	require.Nil(t, agent.FTP)
+
+	agents := UploadAgents{
+		Agents: []UploadAgent{{ID: "harness", TestHarness: &ACHTestHarness{Responses: make([]ACHTestHarnessResponse, 1)}}},
+	}
+	require.ErrorContains(t, agents.Validate(), "agent harness: test harness: missing AdminEndpoint")
+}
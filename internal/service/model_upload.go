@@ -20,11 +20,20 @@ package service
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/moov-io/achgateway/internal/as2"
 	"github.com/moov-io/achgateway/internal/mask"
+	"github.com/moov-io/achgateway/internal/sshx"
 	"github.com/moov-io/achgateway/internal/storage"
 )
 
@@ -33,6 +42,21 @@ type UploadAgents struct {
 	Merging        Merging
 	Retry          *UploadRetry
 	DefaultAgentID string
+
+	// FaultInjection makes upload agents fail on demand to test retries and runbooks.
+	// It must not be enabled in production.
+	FaultInjection *FaultInjection
+
+	// ShareConnections reuses one FTP or SFTP connection for agents with identical
+	// connection settings, such as shards which only differ by their paths.
+	ShareConnections bool
+
+	// ConformanceProfiles are the file format quirks of each ODFI, used by agents by name
+	ConformanceProfiles []ConformanceProfile
+
+	// Tenant is set by the Environment when running as one of several tenants in a process,
+	// so agents with the same ID aren't shared between tenants.
+	Tenant string `json:"-"`
 }
 
 func (ua UploadAgents) Find(id string) *UploadAgent {
@@ -48,6 +72,63 @@ func (ua UploadAgents) Validate() error {
 	if err := ua.Retry.Validate(); err != nil {
 		return fmt.Errorf("retry: %v", err)
 	}
+	if err := ua.FaultInjection.Validate(); err != nil {
+		return fmt.Errorf("fault injection: %v", err)
+	}
+	if ua.Merging.TakeoverInterval < 0 {
+		return fmt.Errorf("merging: invalid TakeoverInterval %v", ua.Merging.TakeoverInterval)
+	}
+	if err := ua.validateConformanceProfiles(); err != nil {
+		return err
+	}
+	for i := range ua.Agents {
+		if err := ua.Agents[i].FTP.Validate(); err != nil {
+			return fmt.Errorf("agent %s: ftp: %v", ua.Agents[i].ID, err)
+		}
+		if err := ua.Agents[i].SFTP.Validate(); err != nil {
+			return fmt.Errorf("agent %s: sftp: %v", ua.Agents[i].ID, err)
+		}
+		if err := ua.Agents[i].S3.Validate(); err != nil {
+			return fmt.Errorf("agent %s: s3: %v", ua.Agents[i].ID, err)
+		}
+		if err := ua.Agents[i].HTTPS.Validate(); err != nil {
+			return fmt.Errorf("agent %s: https: %v", ua.Agents[i].ID, err)
+		}
+		if err := ua.Agents[i].AS2.Validate(); err != nil {
+			return fmt.Errorf("agent %s: as2: %v", ua.Agents[i].ID, err)
+		}
+		if err := ua.Agents[i].Filesystem.Validate(); err != nil {
+			return fmt.Errorf("agent %s: filesystem: %v", ua.Agents[i].ID, err)
+		}
+		if err := ua.Agents[i].TestHarness.Validate(); err != nil {
+			return fmt.Errorf("agent %s: test harness: %v", ua.Agents[i].ID, err)
+		}
+		if err := ua.Agents[i].Downloads.Validate(); err != nil {
+			return fmt.Errorf("agent %s: downloads: %v", ua.Agents[i].ID, err)
+		}
+		if err := ua.Agents[i].Paths.Validate(); err != nil {
+			return fmt.Errorf("agent %s: paths: %v", ua.Agents[i].ID, err)
+		}
+		if err := ua.Agents[i].AfterProcessing.Validate(); err != nil {
+			return fmt.Errorf("agent %s: after processing: %v", ua.Agents[i].ID, err)
+		}
+		if ap := ua.Agents[i].AfterProcessing; ap != nil && ap.Action == AfterProcessingMove && ua.Agents[i].HTTPS != nil {
+			return fmt.Errorf("agent %s: after processing: HTTPS agents can't move files", ua.Agents[i].ID)
+		}
+		switch ua.Agents[i].OnCollision {
+		case "", CollisionError, CollisionOverwrite, CollisionSuffix:
+		default:
+			return fmt.Errorf("agent %s: unknown OnCollision %q", ua.Agents[i].ID, ua.Agents[i].OnCollision)
+		}
+		if ua.Agents[i].OnCollision != "" && (ua.Agents[i].HTTPS != nil || ua.Agents[i].AS2 != nil) {
+			return fmt.Errorf("agent %s: OnCollision: HTTPS and AS2 agents can't check for remote files", ua.Agents[i].ID)
+		}
+		for j := range ua.Agents[i].MaintenanceWindows {
+			if err := ua.Agents[i].MaintenanceWindows[j].Validate(); err != nil {
+				return fmt.Errorf("agent %s: maintenance window[%d]: %v", ua.Agents[i].ID, j, err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -55,14 +136,158 @@ type UploadAgent struct {
 	ID            string
 	FTP           *FTP
 	SFTP          *SFTP
+	S3            *S3
+	HTTPS         *HTTPS
+	AS2           *AS2
+	Filesystem    *Filesystem
 	Mock          *MockAgent
 	Paths         UploadPaths
 	Notifications *UploadNotifiers
 
+	// TestHarness connects the agent to moov-io/ach-test-harness instead of FTP or SFTP
+	TestHarness *ACHTestHarness
+
 	// AllowedIPs is a comma separated list of IP addresses and CIDR ranges
 	// where connections are allowed. If this value is non-empty remote servers
 	// not within these ranges will not be connected to.
 	AllowedIPs string
+
+	// MaintenanceWindows are recurring periods when the remote server is unavailable
+	MaintenanceWindows []MaintenanceWindow
+
+	// ConformanceProfile names the UploadAgents.ConformanceProfiles entry merged files are rendered with
+	ConformanceProfile string
+
+	// Downloads limits how much of the inbound, reconciliation and return files read at once are held in memory
+	Downloads *Downloads
+
+	// AfterProcessing is done with the inbound, reconciliation and return files on the remote
+	// server once they're processed, instead of following ODFIStorage.KeepRemoteFiles
+	AfterProcessing *AfterProcessing
+
+	// OnCollision is done when an outbound file has the same name as a file on the remote server:
+	// error, overwrite or suffix. Remote files aren't checked when it's empty.
+	OnCollision string
+}
+
+const (
+	// CollisionError fails the upload, leaving the remote file as-is
+	CollisionError = "error"
+
+	// CollisionOverwrite replaces the remote file
+	CollisionOverwrite = "overwrite"
+
+	// CollisionSuffix uploads the file with a sequence number after its name, like FILE-1.ach
+	CollisionSuffix = "suffix"
+)
+
+const (
+	AfterProcessingNone   = "none"
+	AfterProcessingDelete = "delete"
+	AfterProcessingMove   = "move"
+)
+
+// AfterProcessing deletes or moves remote files once the ODFI scan which downloaded them
+// succeeds, so they aren't downloaded again.
+type AfterProcessing struct {
+	// Action is none, delete or move
+	Action string
+
+	// Directory holds moved files under the path they were read from, so inbound/RET.ach is
+	// moved to archive/inbound/RET.ach with a Directory of archive/. It can be templated like
+	// the agent's Paths, such as archive/{{ yyyy }}/{{ mm }}/.
+	Directory string
+}
+
+func (cfg *AfterProcessing) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	switch cfg.Action {
+	case AfterProcessingNone, AfterProcessingDelete:
+		if cfg.Directory != "" {
+			return fmt.Errorf("Directory is only used with the %s Action", AfterProcessingMove)
+		}
+	case AfterProcessingMove:
+		if cfg.Directory == "" {
+			return errors.New("missing Directory")
+		}
+	default:
+		return fmt.Errorf("unknown Action %q", cfg.Action)
+	}
+	return nil
+}
+
+// DefaultMaxInMemoryDownloads is how many bytes of downloaded files are held in memory by default
+const DefaultMaxInMemoryDownloads = 32 * 1024 * 1024
+
+// Downloads keeps the files read from one directory in memory until MaxInMemorySize bytes are
+// used, then writes the rest to temporary files which are removed once they're processed.
+type Downloads struct {
+	// MaxInMemorySize is in bytes, where 0 uses DefaultMaxInMemoryDownloads and a negative
+	// value writes every file to disk
+	MaxInMemorySize int64
+
+	// TempDir holds the temporary files, by default the OS temp directory
+	TempDir string
+}
+
+func (cfg *Downloads) Validate() error {
+	if cfg == nil || cfg.TempDir == "" {
+		return nil
+	}
+	info, err := os.Stat(cfg.TempDir)
+	if err != nil {
+		return fmt.Errorf("TempDir: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("TempDir %s is not a directory", cfg.TempDir)
+	}
+	return nil
+}
+
+// InMemoryLimit returns how many bytes of files are held in memory
+func (cfg *Downloads) InMemoryLimit() int64 {
+	if cfg == nil || cfg.MaxInMemorySize == 0 {
+		return DefaultMaxInMemoryDownloads
+	}
+	if cfg.MaxInMemorySize < 0 {
+		return 0
+	}
+	return cfg.MaxInMemorySize
+}
+
+// Directory returns where temporary files are written
+func (cfg *Downloads) Directory() string {
+	if cfg == nil || cfg.TempDir == "" {
+		return os.TempDir()
+	}
+	return cfg.TempDir
+}
+
+// Hostname returns the remote server the agent connects to.
+func (cfg *UploadAgent) Hostname() string {
+	switch {
+	case cfg == nil:
+		return ""
+	case cfg.TestHarness != nil:
+		return cfg.TestHarness.Address()
+	case cfg.FTP != nil:
+		return cfg.FTP.Hostname
+	case cfg.SFTP != nil:
+		return cfg.SFTP.Hostname
+	case cfg.S3 != nil:
+		return cfg.S3.Hostname()
+	case cfg.HTTPS != nil:
+		return cfg.HTTPS.Hostname()
+	case cfg.AS2 != nil:
+		return cfg.AS2.Hostname()
+	case cfg.Filesystem != nil:
+		return "localhost"
+	case cfg.Mock != nil:
+		return "hostname"
+	}
+	return ""
 }
 
 func (cfg *UploadAgent) SplitAllowedIPs() []string {
@@ -77,9 +302,65 @@ type FTP struct {
 	Username string
 	Password string
 
-	CAFilepath   string
-	DialTimeout  time.Duration
+	CAFilepath  string
+	DialTimeout time.Duration
+
+	// DisabledEPSV opens data connections with PASV instead of EPSV, for servers and firewalls
+	// which reject EPSV. Data connections are always passive.
 	DisabledEPSV bool
+
+	// DataPortRange is the lowest and highest port data connections are opened to, like "50000-50100",
+	// when a firewall only allows those. Transfers fail when the server offers a port outside of it.
+	DataPortRange string
+
+	// CacheListings remembers the size and modification time of files already processed so
+	// later scans only download new or modified files.
+	CacheListings bool
+
+	// MaxBytesPerSecond limits how fast the agent uploads and downloads files, shared by all
+	// of its transfers. Zero doesn't limit them.
+	MaxBytesPerSecond int64
+
+	// DownloadWorkers is how many files are downloaded at once when reading a directory. Each
+	// worker after the first opens its own connection. Defaults to one.
+	DownloadWorkers int
+
+	// Proxy is dialed for the control and data connections instead of the server directly
+	Proxy *Proxy
+
+	// TLS encrypts the control and data connections. Connections use implicit TLS when only
+	// CAFilepath is set.
+	TLS *FTPTLS
+}
+
+const (
+	// FTPExplicitTLS connects without TLS and upgrades the connection with AUTH TLS, usually on port 21
+	FTPExplicitTLS = "explicit"
+
+	// FTPImplicitTLS connects with TLS from the start, usually on port 990
+	FTPImplicitTLS = "implicit"
+)
+
+type FTPTLS struct {
+	// Mode is explicit or implicit
+	Mode string
+
+	// SessionReuse resumes the control connection's TLS session on each data connection,
+	// which servers like vsftpd with require_ssl_reuse require.
+	SessionReuse bool
+}
+
+func (cfg *FTPTLS) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	switch cfg.Mode {
+	case FTPExplicitTLS, FTPImplicitTLS:
+		return nil
+	case "":
+		return errors.New("missing Mode")
+	}
+	return fmt.Errorf("unknown Mode %q", cfg.Mode)
 }
 
 func (cfg *FTP) MarshalJSON() ([]byte, error) {
@@ -88,21 +369,125 @@ func (cfg *FTP) MarshalJSON() ([]byte, error) {
 		Username string
 		Password string
 
-		CAFilepath   string
-		DialTimeout  time.Duration
-		DisabledEPSV bool
+		CAFilepath    string
+		DialTimeout   time.Duration
+		DisabledEPSV  bool
+		DataPortRange string
+
+		CacheListings     bool
+		MaxBytesPerSecond int64
+		DownloadWorkers   int
+
+		Proxy *Proxy
+		TLS   *FTPTLS
 	}
 	return json.Marshal(Aux{
 		Hostname: cfg.Hostname,
 		Username: cfg.Username,
 		Password: mask.Password(cfg.Password),
 
-		CAFilepath:   cfg.CAFilepath,
-		DialTimeout:  cfg.DialTimeout,
-		DisabledEPSV: cfg.DisabledEPSV,
+		CAFilepath:    cfg.CAFilepath,
+		DialTimeout:   cfg.DialTimeout,
+		DisabledEPSV:  cfg.DisabledEPSV,
+		DataPortRange: cfg.DataPortRange,
+
+		CacheListings:     cfg.CacheListings,
+		MaxBytesPerSecond: cfg.MaxBytesPerSecond,
+		DownloadWorkers:   cfg.DownloadWorkers,
+
+		Proxy: cfg.Proxy,
+		TLS:   cfg.TLS,
 	})
 }
 
+func (cfg *FTP) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if err := validateDownloadWorkers(cfg.DownloadWorkers); err != nil {
+		return err
+	}
+	if err := cfg.Proxy.Validate(); err != nil {
+		return fmt.Errorf("proxy: %v", err)
+	}
+	if err := cfg.TLS.Validate(); err != nil {
+		return fmt.Errorf("tls: %v", err)
+	}
+	if _, _, err := cfg.DataPorts(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DataPorts returns the lowest and highest ports of DataPortRange, which are zero when it's empty
+func (cfg *FTP) DataPorts() (int, int, error) {
+	if cfg == nil || cfg.DataPortRange == "" {
+		return 0, 0, nil
+	}
+	lo, hi, found := strings.Cut(cfg.DataPortRange, "-")
+	if !found {
+		hi = lo // a single port
+	}
+	low, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid DataPortRange %q", cfg.DataPortRange)
+	}
+	high, err := strconv.Atoi(strings.TrimSpace(hi))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid DataPortRange %q", cfg.DataPortRange)
+	}
+	if low < 1 || high > 65535 || low > high {
+		return 0, 0, fmt.Errorf("invalid DataPortRange %q", cfg.DataPortRange)
+	}
+	return low, high, nil
+}
+
+// TLSMode is explicit or implicit when connections are encrypted, otherwise it's empty
+func (cfg *FTP) TLSMode() string {
+	if cfg == nil {
+		return ""
+	}
+	if cfg.TLS != nil {
+		return cfg.TLS.Mode
+	}
+	if cfg.CAFilepath != "" {
+		return FTPImplicitTLS
+	}
+	return ""
+}
+
+// Address is the Hostname to connect to, with port 21 (or 990 for implicit TLS) when it doesn't have one
+func (cfg *FTP) Address() string {
+	if cfg == nil {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(cfg.Hostname); err == nil {
+		return cfg.Hostname
+	}
+	if cfg.TLSMode() == FTPImplicitTLS {
+		return net.JoinHostPort(cfg.Hostname, "990")
+	}
+	return net.JoinHostPort(cfg.Hostname, "21")
+}
+
+// Workers is how many files are downloaded at once
+func (cfg *FTP) Workers() int {
+	if cfg == nil || cfg.DownloadWorkers < 1 {
+		return 1
+	}
+	return cfg.DownloadWorkers
+}
+
+// maxDownloadWorkers bounds DownloadWorkers so a typo doesn't open hundreds of connections
+const maxDownloadWorkers = 32
+
+func validateDownloadWorkers(workers int) error {
+	if workers < 0 || workers > maxDownloadWorkers {
+		return fmt.Errorf("DownloadWorkers of %d must be between 0 and %d", workers, maxDownloadWorkers)
+	}
+	return nil
+}
+
 func (cfg *FTP) CAFile() string {
 	if cfg == nil {
 		return ""
@@ -140,13 +525,84 @@ type SFTP struct {
 	ClientPrivateKey string
 	HostPublicKey    string
 
+	// HostPublicKeys are more host keys accepted along with HostPublicKey, so a server can rotate
+	// its key while both the old and new key are listed.
+	HostPublicKeys []string
+
+	// KnownHostsFile is an OpenSSH known_hosts file read each time a connection is opened. Its
+	// keys, @cert-authority and @revoked lines are used along with the other host key settings.
+	KnownHostsFile string
+
+	// HostCertificateAuthority is one or more SSH CA public keys trusted to sign the server's
+	// host certificate, with known_hosts style "@cert-authority <hosts> <key>" lines limiting
+	// a CA to certain hosts. HostPublicKey is still accepted when the server presents a plain key.
+	HostCertificateAuthority string
+
+	// ClientCertificate is an OpenSSH user certificate for ClientPrivateKey (e.g. id_ed25519-cert.pub)
+	// presented instead of the plain public key when authenticating.
+	ClientCertificate string
+
+	// ClientCertificateFile is read for the certificate each time a connection is opened instead,
+	// so short-lived certificates renewed on disk are picked up without restarting.
+	ClientCertificateFile string
+
 	DialTimeout           time.Duration
 	MaxConnectionsPerFile int
 	MaxPacketSize         int
 
+	// ConnectRetry controls how failed connections to the server are retried
+	ConnectRetry *SFTPConnectRetry
+
+	// MaxConnections is how many connections the agent opens to the server so uploads and
+	// downloads run in parallel. Defaults to one, which runs one operation at a time.
+	MaxConnections int
+
+	// MaxBytesPerSecond limits how fast the agent uploads and downloads files, shared by all
+	// of its connections. Zero doesn't limit them.
+	MaxBytesPerSecond int64
+
+	// DownloadWorkers is how many files are downloaded at once over the connection reading a
+	// directory. Defaults to one.
+	DownloadWorkers int
+
+	// KeepaliveInterval sends an SSH keepalive on each connection this often so connections
+	// the server or network dropped are noticed and replaced before they're used. A connection
+	// is closed after KeepaliveMaxMissed keepalives (default 3) in a row go unanswered.
+	KeepaliveInterval  time.Duration
+	KeepaliveMaxMissed int
+
+	// ConcurrentWrites uploads each file with up to MaxConnectionsPerFile
+	// write requests in flight instead of one at a time.
+	ConcurrentWrites bool
+
 	// SkipDirectoryCreation will configure achgateway to create
 	// directories on the remote server prior to uploading files.
 	SkipDirectoryCreation bool
+
+	// AtomicUploads writes each file under a temporary name and renames it to the final
+	// filename once it's completely written, so the ODFI never picks up a partial file.
+	// Temporary files are named .<filename>.tmp in the outbound path, or written to
+	// StagingPath when set, which must be on the same filesystem as the outbound path.
+	AtomicUploads bool
+	StagingPath   string
+
+	// CacheListings remembers the size and modification time of files already processed so
+	// later scans only download new or modified files.
+	CacheListings bool
+
+	// DisableResumableUploads starts failed uploads over instead of reconnecting and writing
+	// the rest of the file, for servers which don't support writing at an offset.
+	DisableResumableUploads bool
+
+	// Proxy is dialed for SSH connections instead of the server directly
+	Proxy *Proxy
+
+	// Ciphers, KeyExchanges, MACs and HostKeyAlgorithms replace the SSH algorithms offered to the
+	// server, in order of preference, to meet an FI's algorithm policy. Empty lists keep the defaults.
+	Ciphers           []string
+	KeyExchanges      []string
+	MACs              []string
+	HostKeyAlgorithms []string
 }
 
 func (cfg *SFTP) MarshalJSON() ([]byte, error) {
@@ -157,29 +613,112 @@ func (cfg *SFTP) MarshalJSON() ([]byte, error) {
 		Password         string
 		ClientPrivateKey string
 		HostPublicKey    string
+		HostPublicKeys   []string
+		KnownHostsFile   string
+
+		HostCertificateAuthority string
+		ClientCertificate        string
+		ClientCertificateFile    string
 
 		DialTimeout           time.Duration
+		ConnectRetry          *SFTPConnectRetry
 		MaxConnectionsPerFile int
 		MaxPacketSize         int
+		MaxConnections        int
+		MaxBytesPerSecond     int64
+		DownloadWorkers       int
+		KeepaliveInterval     time.Duration
+		KeepaliveMaxMissed    int
+		ConcurrentWrites      bool
 
 		SkipDirectoryCreation bool
+
+		AtomicUploads bool
+		StagingPath   string
+		CacheListings bool
+
+		DisableResumableUploads bool
+
+		Proxy *Proxy
+
+		Ciphers           []string
+		KeyExchanges      []string
+		MACs              []string
+		HostKeyAlgorithms []string
 	}
 	return json.Marshal(Aux{
 		Hostname: cfg.Hostname,
 		Username: cfg.Username,
 
 		Password:         mask.Password(cfg.Password),
-		ClientPrivateKey: cfg.ClientPrivateKey,
+		ClientPrivateKey: mask.Password(cfg.ClientPrivateKey),
 		HostPublicKey:    cfg.HostPublicKey,
+		HostPublicKeys:   cfg.HostPublicKeys,
+		KnownHostsFile:   cfg.KnownHostsFile,
+
+		HostCertificateAuthority: cfg.HostCertificateAuthority,
+		ClientCertificate:        cfg.ClientCertificate,
+		ClientCertificateFile:    cfg.ClientCertificateFile,
 
 		DialTimeout:           cfg.DialTimeout,
+		ConnectRetry:          cfg.ConnectRetry,
 		MaxConnectionsPerFile: cfg.MaxConnectionsPerFile,
 		MaxPacketSize:         cfg.MaxPacketSize,
+		MaxConnections:        cfg.MaxConnections,
+		MaxBytesPerSecond:     cfg.MaxBytesPerSecond,
+		DownloadWorkers:       cfg.DownloadWorkers,
+		KeepaliveInterval:     cfg.KeepaliveInterval,
+		KeepaliveMaxMissed:    cfg.KeepaliveMaxMissed,
+		ConcurrentWrites:      cfg.ConcurrentWrites,
 
 		SkipDirectoryCreation: cfg.SkipDirectoryCreation,
+
+		AtomicUploads: cfg.AtomicUploads,
+		StagingPath:   cfg.StagingPath,
+		CacheListings: cfg.CacheListings,
+
+		DisableResumableUploads: cfg.DisableResumableUploads,
+
+		Proxy: cfg.Proxy,
+
+		Ciphers:           cfg.Ciphers,
+		KeyExchanges:      cfg.KeyExchanges,
+		MACs:              cfg.MACs,
+		HostKeyAlgorithms: cfg.HostKeyAlgorithms,
 	})
 }
 
+func (cfg *SFTP) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.ClientCertificate != "" && cfg.ClientCertificateFile != "" {
+		return errors.New("only one of ClientCertificate and ClientCertificateFile can be set")
+	}
+	if err := cfg.ConnectRetry.Validate(); err != nil {
+		return fmt.Errorf("connect retry: %v", err)
+	}
+	if err := validateDownloadWorkers(cfg.DownloadWorkers); err != nil {
+		return err
+	}
+	if err := cfg.Proxy.Validate(); err != nil {
+		return fmt.Errorf("proxy: %v", err)
+	}
+	if err := sshx.CheckAlgorithms("cipher", cfg.Ciphers, sshx.SupportedCiphers); err != nil {
+		return err
+	}
+	if err := sshx.CheckAlgorithms("key exchange", cfg.KeyExchanges, sshx.SupportedKeyExchanges); err != nil {
+		return err
+	}
+	if err := sshx.CheckAlgorithms("MAC", cfg.MACs, sshx.SupportedMACs); err != nil {
+		return err
+	}
+	if err := sshx.CheckAlgorithms("host key algorithm", cfg.HostKeyAlgorithms, sshx.SupportedHostKeyAlgorithms); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (cfg *SFTP) Timeout() time.Duration {
 	if cfg == nil || cfg.DialTimeout == 0*time.Second {
 		return 10 * time.Second
@@ -187,13 +726,46 @@ func (cfg *SFTP) Timeout() time.Duration {
 	return cfg.DialTimeout
 }
 
-func (cfg *SFTP) MaxConnections() int {
+// RequestsPerFile is how many requests pkg/sftp sends at once for each file
+func (cfg *SFTP) RequestsPerFile() int {
 	if cfg == nil || cfg.MaxConnectionsPerFile == 0 {
+		if cfg != nil && cfg.ConcurrentWrites {
+			return 64 // pkg/sftp's default
+		}
 		return 1 // pkg/sftp's default is 64
 	}
 	return cfg.MaxConnectionsPerFile
 }
 
+// ResumesUploads is true when failed uploads continue from the bytes the server has
+func (cfg *SFTP) ResumesUploads() bool {
+	return cfg != nil && !cfg.DisableResumableUploads
+}
+
+// PoolSize is how many connections are opened to the server
+func (cfg *SFTP) PoolSize() int {
+	if cfg == nil || cfg.MaxConnections < 1 {
+		return 1
+	}
+	return cfg.MaxConnections
+}
+
+// Workers is how many files are downloaded at once
+func (cfg *SFTP) Workers() int {
+	if cfg == nil || cfg.DownloadWorkers < 1 {
+		return 1
+	}
+	return cfg.DownloadWorkers
+}
+
+// MaxMissedKeepalives is how many keepalives in a row can go unanswered before a connection is closed
+func (cfg *SFTP) MaxMissedKeepalives() int {
+	if cfg == nil || cfg.KeepaliveMaxMissed < 1 {
+		return 3
+	}
+	return cfg.KeepaliveMaxMissed
+}
+
 func (cfg *SFTP) PacketSize() int {
 	if cfg == nil || cfg.MaxPacketSize == 0 {
 		return 20480
@@ -207,17 +779,550 @@ func (cfg *SFTP) String() string {
 	buf.WriteString(fmt.Sprintf("Username=%s, ", cfg.Username))
 	buf.WriteString(fmt.Sprintf("Password=%s, ", mask.Password(cfg.Password)))
 	buf.WriteString(fmt.Sprintf("ClientPrivateKey:%v, ", cfg.ClientPrivateKey != ""))
-	buf.WriteString(fmt.Sprintf("HostPublicKey:%v}, ", cfg.HostPublicKey != ""))
+	buf.WriteString(fmt.Sprintf("HostPublicKey:%v, ", cfg.HostPublicKey != ""))
+	buf.WriteString(fmt.Sprintf("HostPublicKeys:%d, ", len(cfg.HostPublicKeys)))
+	buf.WriteString(fmt.Sprintf("KnownHostsFile=%s, ", cfg.KnownHostsFile))
+	buf.WriteString(fmt.Sprintf("HostCertificateAuthority:%v, ", cfg.HostCertificateAuthority != ""))
+	buf.WriteString(fmt.Sprintf("ClientCertificate:%v, ", cfg.ClientCertificate != ""))
+	buf.WriteString(fmt.Sprintf("ClientCertificateFile=%s}, ", cfg.ClientCertificateFile))
+	return buf.String()
+}
+
+// SFTPConnectRetry controls how connecting to an SFTP server is retried. Each failed attempt waits
+// Backoff, doubling after every attempt up to MaxBackoff, plus or minus up to Jitter.
+type SFTPConnectRetry struct {
+	// MaxAttempts is how many times connecting is tried. Defaults to 3
+	MaxAttempts int
+
+	// Backoff is the wait after the first failed attempt. Defaults to 250ms
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Jitter     time.Duration
+
+	// Deadline limits how long all attempts together take, including waiting between them
+	Deadline time.Duration
+}
+
+func (cfg *SFTPConnectRetry) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.MaxAttempts < 0 {
+		return fmt.Errorf("negative MaxAttempts %d", cfg.MaxAttempts)
+	}
+	if cfg.Backoff < 0 || cfg.MaxBackoff < 0 || cfg.Jitter < 0 || cfg.Deadline < 0 {
+		return errors.New("durations can't be negative")
+	}
+	if cfg.MaxBackoff > 0 && cfg.MaxBackoff < cfg.InitialBackoff() {
+		return fmt.Errorf("MaxBackoff %v is less than Backoff %v", cfg.MaxBackoff, cfg.InitialBackoff())
+	}
+	return nil
+}
+
+// Attempts returns MaxAttempts or its default
+func (cfg *SFTPConnectRetry) Attempts() int {
+	if cfg == nil || cfg.MaxAttempts <= 0 {
+		return 3
+	}
+	return cfg.MaxAttempts
+}
+
+// InitialBackoff returns Backoff or its default
+func (cfg *SFTPConnectRetry) InitialBackoff() time.Duration {
+	if cfg == nil || cfg.Backoff <= 0 {
+		return 250 * time.Millisecond
+	}
+	return cfg.Backoff
+}
+
+// S3 uploads files to an S3 compatible bucket (AWS S3, MinIO). Each of the agent's Paths is a
+// key prefix within the bucket.
+type S3 struct {
+	Bucket string
+	Region string
+
+	// Endpoint overrides the AWS endpoint to connect with other S3 compatible servers.
+	// Example: http://minio:9000
+	Endpoint string
+
+	// ForcePathStyle addresses objects as <endpoint>/<bucket>/<key>, which MinIO requires
+	ForcePathStyle bool
+
+	// AccessKeyID and SecretAccessKey are optional, otherwise credentials are read
+	// from the environment (AWS_ACCESS_KEY_ID, instance roles, etc)
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+func (cfg *S3) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Bucket == "" {
+		return errors.New("missing Bucket")
+	}
+	if cfg.Region == "" {
+		return errors.New("missing Region")
+	}
+	if (cfg.AccessKeyID == "") != (cfg.SecretAccessKey == "") {
+		return errors.New("AccessKeyID and SecretAccessKey must both be set")
+	}
+	if cfg.Endpoint != "" {
+		if _, err := url.Parse(cfg.Endpoint); err != nil {
+			return fmt.Errorf("invalid Endpoint: %v", err)
+		}
+	}
+	return nil
+}
+
+// Hostname returns the bucket's name
+func (cfg *S3) Hostname() string {
+	if cfg == nil {
+		return ""
+	}
+	return cfg.Bucket
+}
+
+// EndpointHost returns the host (and port) of Endpoint
+func (cfg *S3) EndpointHost() string {
+	if cfg == nil || cfg.Endpoint == "" {
+		return ""
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func (cfg *S3) MarshalJSON() ([]byte, error) {
+	type Aux struct {
+		Bucket         string
+		Region         string
+		Endpoint       string
+		ForcePathStyle bool
+
+		AccessKeyID     string
+		SecretAccessKey string
+	}
+	return json.Marshal(Aux{
+		Bucket:         cfg.Bucket,
+		Region:         cfg.Region,
+		Endpoint:       cfg.Endpoint,
+		ForcePathStyle: cfg.ForcePathStyle,
+
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: mask.Password(cfg.SecretAccessKey),
+	})
+}
+
+func (cfg *S3) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("S3{Bucket=%s, ", cfg.Bucket))
+	buf.WriteString(fmt.Sprintf("Region=%s, ", cfg.Region))
+	buf.WriteString(fmt.Sprintf("Endpoint=%s, ", cfg.Endpoint))
+	buf.WriteString(fmt.Sprintf("AccessKeyID=%s, ", cfg.AccessKeyID))
+	buf.WriteString(fmt.Sprintf("SecretAccessKey=%s}", mask.Password(cfg.SecretAccessKey)))
+	return buf.String()
+}
+
+// HTTPS exchanges files with an ODFI's REST API. Outbound files are POSTed to the Outbound
+// path and files are listed and downloaded with GET requests on the other paths.
+type HTTPS struct {
+	// BaseURL is joined with each path. Example: https://files.bank.com/api/v1/
+	BaseURL string
+
+	// BearerToken is sent in the Authorization header of each request when set
+	BearerToken string
+
+	// ClientCertFile and ClientKeyFile are the certificate and key presented for mTLS
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// CAFile verifies the server's certificate, otherwise system roots are used
+	CAFile string
+
+	// Timeout of each request, 30s by default
+	Timeout time.Duration
+}
+
+func (cfg *HTTPS) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return fmt.Errorf("invalid BaseURL: %v", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("BaseURL %s must be an https:// URL", cfg.BaseURL)
+	}
+	if (cfg.ClientCertFile == "") != (cfg.ClientKeyFile == "") {
+		return errors.New("ClientCertFile and ClientKeyFile must both be set")
+	}
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("invalid Timeout %v", cfg.Timeout)
+	}
+	return nil
+}
+
+// Hostname returns the host (and port) of BaseURL
+func (cfg *HTTPS) Hostname() string {
+	if cfg == nil {
+		return ""
+	}
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func (cfg *HTTPS) RequestTimeout() time.Duration {
+	if cfg == nil || cfg.Timeout <= 0 {
+		return 30 * time.Second
+	}
+	return cfg.Timeout
+}
+
+func (cfg *HTTPS) MarshalJSON() ([]byte, error) {
+	type Aux struct {
+		BaseURL        string
+		BearerToken    string
+		ClientCertFile string
+		ClientKeyFile  string
+		CAFile         string
+		Timeout        time.Duration
+	}
+	return json.Marshal(Aux{
+		BaseURL:        cfg.BaseURL,
+		BearerToken:    mask.Password(cfg.BearerToken),
+		ClientCertFile: cfg.ClientCertFile,
+		ClientKeyFile:  cfg.ClientKeyFile,
+		CAFile:         cfg.CAFile,
+		Timeout:        cfg.Timeout,
+	})
+}
+
+func (cfg *HTTPS) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("HTTPS{BaseURL=%s, ", cfg.BaseURL))
+	buf.WriteString(fmt.Sprintf("BearerToken=%s, ", mask.Password(cfg.BearerToken)))
+	buf.WriteString(fmt.Sprintf("ClientCertFile=%s, ", cfg.ClientCertFile))
+	buf.WriteString(fmt.Sprintf("ClientKeyFile=%s, ", cfg.ClientKeyFile))
+	buf.WriteString(fmt.Sprintf("CAFile=%s, ", cfg.CAFile))
+	buf.WriteString(fmt.Sprintf("Timeout=%v}", cfg.Timeout))
 	return buf.String()
 }
 
+// AS2 exchanges files with a trading partner over AS2 (RFC 4130). Outbound files are sent as
+// messages to URL and messages the partner POSTs to /as2/{agentID} are written under Inbox.
+type AS2 struct {
+	// URL of the partner's AS2 server
+	URL string
+
+	// AS2From is our AS2 identifier and AS2To is the partner's
+	AS2From string
+	AS2To   string
+
+	// CertFile and KeyFile are our PEM encoded certificate and RSA key, used to sign messages
+	// and MDNs and to decrypt messages from the partner
+	CertFile string
+	KeyFile  string
+
+	// PartnerCertFile is the partner's PEM encoded certificate, used to encrypt messages
+	// and verify their signatures
+	PartnerCertFile string
+
+	// SigningAlgorithm is sha1, sha256 (default), sha384, sha512 or none
+	SigningAlgorithm string
+
+	// EncryptionAlgorithm is aes128-cbc, aes192-cbc, aes256-cbc (default), des-ede3-cbc or none
+	EncryptionAlgorithm string
+
+	MDN AS2MDN
+
+	// ReceiptHosts are where the partner may ask for async MDNs of their messages to be sent,
+	// besides the host of URL
+	ReceiptHosts []string
+
+	// Inbox is the local directory files from the partner are written into, Paths are relative to it
+	Inbox string
+
+	// Timeout of each request, 30s by default
+	Timeout time.Duration
+}
+
+// AS2MDN configures the receipts requested for each message sent to the partner
+type AS2MDN struct {
+	// Mode is sync (default) to read the MDN from the response, async for the partner to
+	// POST it to AsyncURL later, or none
+	Mode string
+
+	// AsyncURL is where the partner sends async MDNs, usually https://<achgateway>/as2/<agentID>/mdn
+	AsyncURL string
+
+	// Signed requests signed MDNs and rejects unsigned ones
+	Signed bool
+}
+
+func (cfg *AS2) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("URL %s must be an http:// or https:// URL", cfg.URL)
+	}
+	if cfg.AS2From == "" || cfg.AS2To == "" {
+		return errors.New("AS2From and AS2To must both be set")
+	}
+	if cfg.Inbox == "" {
+		return errors.New("missing Inbox")
+	}
+	if cfg.PartnerCertFile == "" {
+		return errors.New("missing PartnerCertFile")
+	}
+	if cfg.Signing() != "" && !as2.ValidDigest(cfg.Signing()) {
+		return fmt.Errorf("unknown SigningAlgorithm %q", cfg.SigningAlgorithm)
+	}
+	if cfg.Encryption() != "" && !as2.ValidEncryption(cfg.Encryption()) {
+		return fmt.Errorf("unknown EncryptionAlgorithm %q", cfg.EncryptionAlgorithm)
+	}
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return errors.New("CertFile and KeyFile must both be set")
+	}
+	switch cfg.MDNMode() {
+	case "sync", "none":
+	case "async":
+		if u, err := url.Parse(cfg.MDN.AsyncURL); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid MDN AsyncURL %q", cfg.MDN.AsyncURL)
+		}
+	default:
+		return fmt.Errorf("unknown MDN Mode %q", cfg.MDN.Mode)
+	}
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("invalid Timeout %v", cfg.Timeout)
+	}
+	return nil
+}
+
+// AllowsReceiptURL reports if an async MDN can be POSTed to raw, which must be an http(s) URL
+// on the host of URL or one of ReceiptHosts
+func (cfg *AS2) AllowsReceiptURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return false
+	}
+	hosts := append([]string{cfg.Hostname()}, cfg.ReceiptHosts...)
+	for _, host := range hosts {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host != "" && strings.EqualFold(host, u.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hostname returns the host (and port) of URL
+func (cfg *AS2) Hostname() string {
+	if cfg == nil {
+		return ""
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// Signing returns the signing digest, empty when messages are unsigned
+func (cfg *AS2) Signing() string {
+	alg := strings.ToLower(strings.TrimSpace(cfg.SigningAlgorithm))
+	switch alg {
+	case "":
+		return as2.SHA256
+	case "none":
+		return ""
+	}
+	return alg
+}
+
+// Encryption returns the encryption algorithm, empty when messages are unencrypted
+func (cfg *AS2) Encryption() string {
+	alg := strings.ToLower(strings.TrimSpace(cfg.EncryptionAlgorithm))
+	switch alg {
+	case "":
+		return as2.AES256CBC
+	case "none":
+		return ""
+	}
+	return alg
+}
+
+func (cfg *AS2) MDNMode() string {
+	if cfg.MDN.Mode == "" {
+		return "sync"
+	}
+	return strings.ToLower(cfg.MDN.Mode)
+}
+
+func (cfg *AS2) RequestTimeout() time.Duration {
+	if cfg == nil || cfg.Timeout <= 0 {
+		return 30 * time.Second
+	}
+	return cfg.Timeout
+}
+
+// Filesystem hands files off through a directory on this machine, such as an NFS mount
+// watched by a bank's appliance. Paths are directories within Directory.
+type Filesystem struct {
+	Directory string
+
+	// Permissions of written files in octal, 0640 by default
+	Permissions string
+}
+
+func (cfg *Filesystem) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Directory == "" {
+		return errors.New("missing Directory")
+	}
+	if cfg.Permissions != "" {
+		perm, err := strconv.ParseUint(cfg.Permissions, 8, 32)
+		if err != nil || perm > 0777 {
+			return fmt.Errorf("invalid Permissions %q", cfg.Permissions)
+		}
+	}
+	return nil
+}
+
+// FileMode returns the permissions of written files
+func (cfg *Filesystem) FileMode() os.FileMode {
+	if cfg != nil && cfg.Permissions != "" {
+		if perm, err := strconv.ParseUint(cfg.Permissions, 8, 32); err == nil && perm <= 0777 {
+			return os.FileMode(perm)
+		}
+	}
+	return 0640
+}
+
 type MockAgent struct{}
 
+// UploadPaths are the remote directories of an agent. Each can be a template evaluated for
+// every transfer, such as outbound/{{ yyyy }}/{{ mm }}/{{ dd }}/ or inbound/{{ routingNumber }}/
 type UploadPaths struct {
 	Inbound        string
 	Outbound       string
 	Reconciliation string
 	Return         string
+
+	// RoutingNumber fills {{ routingNumber }} when reading files and when an uploaded file
+	// doesn't have an ImmediateDestination.
+	RoutingNumber string
+
+	// InboundFilter, ReconciliationFilter and ReturnFilter choose which files in each path are
+	// downloaded, for servers which keep other files (like PDF statements) alongside them.
+	InboundFilter        *FilenameFilter
+	ReconciliationFilter *FilenameFilter
+	ReturnFilter         *FilenameFilter
+
+	// Depth is how many levels of subdirectories in the Inbound, Reconciliation and Return paths
+	// are read, for servers which organize files into dated directories (inbound/2024-05-01/).
+	// Zero only reads the files directly in each path.
+	Depth int
+}
+
+func (cfg UploadPaths) Validate() error {
+	if cfg.Depth < 0 {
+		return fmt.Errorf("negative Depth %d", cfg.Depth)
+	}
+	if err := cfg.InboundFilter.Validate(); err != nil {
+		return fmt.Errorf("inbound filter: %v", err)
+	}
+	if err := cfg.ReconciliationFilter.Validate(); err != nil {
+		return fmt.Errorf("reconciliation filter: %v", err)
+	}
+	if err := cfg.ReturnFilter.Validate(); err != nil {
+		return fmt.Errorf("return filter: %v", err)
+	}
+	return nil
+}
+
+// FilenameFilter matches filenames against glob patterns (like *.ach) or regular expressions
+// written between slashes (like /^RET_\d{8}\.ach$/).
+type FilenameFilter struct {
+	// Include are the patterns of files downloaded. Every file is included when empty.
+	Include []string
+
+	// Exclude are patterns of included files which are skipped anyway
+	Exclude []string
+}
+
+func (cfg *FilenameFilter) Validate() error {
+	_, err := cfg.Matcher()
+	return err
+}
+
+// Matcher compiles the filter into a func reporting if a filename is downloaded. Every
+// filename matches a nil filter.
+func (cfg *FilenameFilter) Matcher() (func(name string) bool, error) {
+	if cfg == nil {
+		return func(string) bool { return true }, nil
+	}
+	include, err := compileFilenamePatterns(cfg.Include)
+	if err != nil {
+		return nil, fmt.Errorf("include: %v", err)
+	}
+	exclude, err := compileFilenamePatterns(cfg.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("exclude: %v", err)
+	}
+	return func(name string) bool {
+		if len(include) > 0 && !anyFilenameMatch(include, name) {
+			return false
+		}
+		return !anyFilenameMatch(exclude, name)
+	}, nil
+}
+
+func compileFilenamePatterns(patterns []string) ([]func(string) bool, error) {
+	var out []func(string) bool
+	for _, pattern := range patterns {
+		if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %s: %v", pattern, err)
+			}
+			out = append(out, re.MatchString)
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		pattern := pattern
+		out = append(out, func(name string) bool {
+			matched, _ := path.Match(pattern, name)
+			return matched
+		})
+	}
+	return out, nil
+}
+
+func anyFilenameMatch(matchers []func(string) bool, name string) bool {
+	for i := range matchers {
+		if matchers[i](name) {
+			return true
+		}
+	}
+	return false
 }
 
 type UploadNotifiers struct {
@@ -229,6 +1334,34 @@ type UploadNotifiers struct {
 type Merging struct {
 	Storage   storage.Config
 	Directory string // fallback config for Storage.Filesystem.Directory
+
+	// SingleWriter is set when Storage is shared by every instance (like a ReadWriteMany volume).
+	// Each instance writes the files it accepts and only the shard's leader merges and uploads
+	// them at cutoff, other instances leave the pending files untouched.
+	SingleWriter bool
+
+	// TakeoverInterval is how often instances check for cutoffs left unfinished by a lost
+	// leader when SingleWriter is set. Defaults to 10s
+	TakeoverInterval time.Duration
+}
+
+func (cfg Merging) TakeoverCheckInterval() time.Duration {
+	if cfg.TakeoverInterval > 0 {
+		return cfg.TakeoverInterval
+	}
+	return 10 * time.Second
+}
+
+// StorageConfig returns Storage with the fallback Directory (or the default "storage") applied.
+func (cfg Merging) StorageConfig() storage.Config {
+	out := cfg.Storage
+	if out.Filesystem.Directory == "" {
+		out.Filesystem.Directory = cfg.Directory
+	}
+	if out.Filesystem.Directory == "" {
+		out.Filesystem.Directory = "storage" // default directory
+	}
+	return out
 }
 
 type UploadRetry struct {
@@ -248,3 +1381,51 @@ func (cfg *UploadRetry) Validate() error {
 	}
 	return nil
 }
+
+type FaultInjection struct {
+	// Faults are injected from startup. Faults can also be changed with /agents/{agentID}/faults
+	// on the admin server.
+	Faults []AgentFaults
+}
+
+func (cfg *FaultInjection) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	for i := range cfg.Faults {
+		if err := cfg.Faults[i].Validate(); err != nil {
+			return fmt.Errorf("faults[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// AgentFaults are the failures injected into an upload agent's network calls
+type AgentFaults struct {
+	AgentID string `json:"agentID"`
+
+	// ConnectionFailure fails each call as if connecting to the server timed out
+	ConnectionFailure bool `json:"connectionFailure"`
+
+	// Delay is added before each call to simulate a slow server
+	Delay time.Duration `json:"delay"`
+
+	// PartialWrite uploads the first half of each file and then fails
+	PartialWrite bool `json:"partialWrite"`
+
+	// Count is how many calls faults are injected into before they're cleared, zero is unlimited
+	Count int `json:"count"`
+}
+
+func (cfg AgentFaults) Validate() error {
+	if cfg.AgentID == "" {
+		return errors.New("missing AgentID")
+	}
+	if cfg.Delay < 0 {
+		return fmt.Errorf("negative Delay %v", cfg.Delay)
+	}
+	if cfg.Count < 0 {
+		return fmt.Errorf("negative Count %d", cfg.Count)
+	}
+	return nil
+}
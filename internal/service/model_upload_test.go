@@ -19,8 +19,11 @@ package service
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -32,9 +35,402 @@ func TestFTPMasking(t *testing.T) {
 	require.True(t, strings.Contains(string(bs), `,"Password":"s****t",`))
 }
 
+func TestFTP__DataPorts(t *testing.T) {
+	cfg := &FTP{}
+	lo, hi, err := cfg.DataPorts()
+	require.NoError(t, err)
+	require.Equal(t, 0, lo)
+	require.Equal(t, 0, hi)
+
+	cfg.DataPortRange = "50000-50100"
+	lo, hi, err = cfg.DataPorts()
+	require.NoError(t, err)
+	require.Equal(t, 50000, lo)
+	require.Equal(t, 50100, hi)
+	require.NoError(t, cfg.Validate())
+
+	cfg.DataPortRange = "50000"
+	lo, hi, err = cfg.DataPorts()
+	require.NoError(t, err)
+	require.Equal(t, 50000, lo)
+	require.Equal(t, 50000, hi)
+
+	for _, invalid := range []string{"50100-50000", "0-10", "50000-70000", "high-low"} {
+		cfg.DataPortRange = invalid
+		require.ErrorContains(t, cfg.Validate(), "invalid DataPortRange", invalid)
+	}
+}
+
+func TestFTP__TLS(t *testing.T) {
+	cfg := &FTP{Hostname: "ftp.bank.com"}
+	require.Equal(t, "", cfg.TLSMode())
+	require.Equal(t, "ftp.bank.com:21", cfg.Address())
+
+	// CAFilepath alone keeps using implicit TLS
+	cfg.CAFilepath = "/certs/bank.pem"
+	require.Equal(t, FTPImplicitTLS, cfg.TLSMode())
+	require.Equal(t, "ftp.bank.com:990", cfg.Address())
+
+	cfg.TLS = &FTPTLS{Mode: FTPExplicitTLS, SessionReuse: true}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, FTPExplicitTLS, cfg.TLSMode())
+	require.Equal(t, "ftp.bank.com:21", cfg.Address())
+
+	cfg.Hostname = "ftp.bank.com:2121"
+	require.Equal(t, "ftp.bank.com:2121", cfg.Address())
+
+	cfg.TLS.Mode = ""
+	require.ErrorContains(t, cfg.Validate(), "tls: missing Mode")
+	cfg.TLS.Mode = "ssl"
+	require.ErrorContains(t, cfg.Validate(), `tls: unknown Mode "ssl"`)
+}
+
 func TestSFTPMasking(t *testing.T) {
 	cfg := &SFTP{Password: "secret"}
 	bs, err := json.Marshal(cfg)
 	require.NoError(t, err)
 	require.True(t, strings.Contains(string(bs), `,"Password":"s****t",`))
 }
+
+func TestSFTP__PoolSize(t *testing.T) {
+	var cfg *SFTP
+	require.Equal(t, 1, cfg.PoolSize())
+
+	cfg = &SFTP{MaxConnections: -1}
+	require.Equal(t, 1, cfg.PoolSize())
+
+	cfg = &SFTP{MaxConnections: 4}
+	require.Equal(t, 4, cfg.PoolSize())
+}
+
+func TestSFTP__MaxMissedKeepalives(t *testing.T) {
+	var cfg *SFTP
+	require.Equal(t, 3, cfg.MaxMissedKeepalives())
+
+	cfg = &SFTP{KeepaliveMaxMissed: 5}
+	require.Equal(t, 5, cfg.MaxMissedKeepalives())
+}
+
+func TestSFTP__ValidateClientCertificate(t *testing.T) {
+	cfg := &SFTP{ClientCertificate: "ssh-ed25519-cert-v01@openssh.com AAAA..."}
+	require.NoError(t, cfg.Validate())
+
+	cfg.ClientCertificateFile = "/run/secrets/id_ed25519-cert.pub"
+	require.ErrorContains(t, cfg.Validate(), "only one of ClientCertificate and ClientCertificateFile")
+
+	cfg.ClientCertificate = ""
+	require.NoError(t, cfg.Validate())
+}
+
+func TestSFTP__ValidateAlgorithms(t *testing.T) {
+	cfg := &SFTP{
+		Ciphers:           []string{"aes256-ctr", "aes128-ctr"},
+		KeyExchanges:      []string{"ecdh-sha2-nistp384", "diffie-hellman-group-exchange-sha256"},
+		MACs:              []string{"hmac-sha2-256-etm@openssh.com"},
+		HostKeyAlgorithms: []string{"rsa-sha2-512", "ssh-ed25519"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Ciphers = append(cfg.Ciphers, "aes256-gcm@openssh.com")
+	require.ErrorContains(t, cfg.Validate(), `unsupported cipher "aes256-gcm@openssh.com"`)
+
+	cfg.Ciphers = nil
+	cfg.MACs = []string{"hmac-md5"}
+	require.ErrorContains(t, cfg.Validate(), `unsupported MAC "hmac-md5"`)
+}
+
+func TestSFTPConnectRetry(t *testing.T) {
+	var cfg *SFTPConnectRetry
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, 3, cfg.Attempts())
+	require.Equal(t, 250*time.Millisecond, cfg.InitialBackoff())
+
+	cfg = &SFTPConnectRetry{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: 10 * time.Second}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, 5, cfg.Attempts())
+
+	cfg.MaxBackoff = 100 * time.Millisecond
+	require.ErrorContains(t, cfg.Validate(), "MaxBackoff 100ms is less than Backoff 1s")
+
+	cfg = &SFTPConnectRetry{Jitter: -time.Second}
+	require.ErrorContains(t, cfg.Validate(), "can't be negative")
+}
+
+func TestS3Masking(t *testing.T) {
+	cfg := &S3{AccessKeyID: "AKIA", SecretAccessKey: "secret"}
+	bs, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	require.True(t, strings.Contains(string(bs), `"SecretAccessKey":"s****t"`))
+	require.NotContains(t, cfg.String(), "secret")
+}
+
+func TestS3__Validate(t *testing.T) {
+	var cfg *S3
+	require.NoError(t, cfg.Validate())
+
+	cfg = &S3{Region: "us-east-1"}
+	require.ErrorContains(t, cfg.Validate(), "missing Bucket")
+
+	cfg = &S3{Bucket: "ach"}
+	require.ErrorContains(t, cfg.Validate(), "missing Region")
+
+	cfg = &S3{Bucket: "ach", Region: "us-east-1", AccessKeyID: "AKIA"}
+	require.ErrorContains(t, cfg.Validate(), "must both be set")
+
+	cfg = &S3{Bucket: "ach", Region: "us-east-1", Endpoint: "http://minio :9000"}
+	require.ErrorContains(t, cfg.Validate(), "invalid Endpoint")
+
+	cfg = &S3{Bucket: "ach", Region: "us-east-1", Endpoint: "http://minio:9000", ForcePathStyle: true}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "minio:9000", cfg.EndpointHost())
+}
+
+func TestUploadAgent__Hostname(t *testing.T) {
+	var agent *UploadAgent
+	require.Equal(t, "", agent.Hostname())
+
+	agent = &UploadAgent{SFTP: &SFTP{Hostname: "sftp.dev.com"}}
+	require.Equal(t, "sftp.dev.com", agent.Hostname())
+
+	agent = &UploadAgent{FTP: &FTP{Hostname: "ftp.dev.com"}}
+	require.Equal(t, "ftp.dev.com", agent.Hostname())
+
+	agent = &UploadAgent{S3: &S3{Bucket: "ach-drop"}}
+	require.Equal(t, "ach-drop", agent.Hostname())
+
+	agent = &UploadAgent{HTTPS: &HTTPS{BaseURL: "https://files.bank.com:8443/api/"}}
+	require.Equal(t, "files.bank.com:8443", agent.Hostname())
+
+	agent = &UploadAgent{AS2: &AS2{URL: "https://as2.bank.com:4080/as2"}}
+	require.Equal(t, "as2.bank.com:4080", agent.Hostname())
+
+	agent = &UploadAgent{Filesystem: &Filesystem{Directory: "/mnt/bank"}}
+	require.Equal(t, "localhost", agent.Hostname())
+}
+
+func TestMerging__StorageConfig(t *testing.T) {
+	var cfg Merging
+	require.Equal(t, "storage", cfg.StorageConfig().Filesystem.Directory)
+
+	cfg.Directory = "fallback"
+	require.Equal(t, "fallback", cfg.StorageConfig().Filesystem.Directory)
+
+	cfg.Storage.Filesystem.Directory = "merging"
+	require.Equal(t, "merging", cfg.StorageConfig().Filesystem.Directory)
+}
+
+func TestFaultInjection__Validate(t *testing.T) {
+	var cfg *FaultInjection
+	require.NoError(t, cfg.Validate())
+
+	cfg = &FaultInjection{
+		Faults: []AgentFaults{{AgentID: "ftp-live", ConnectionFailure: true, Count: 3}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Faults = append(cfg.Faults, AgentFaults{ConnectionFailure: true})
+	require.ErrorContains(t, cfg.Validate(), "faults[1]: missing AgentID")
+
+	cfg.Faults[1] = AgentFaults{AgentID: "ftp-live", Delay: -time.Second}
+	require.ErrorContains(t, cfg.Validate(), "negative Delay")
+}
+
+func TestHTTPS__Validate(t *testing.T) {
+	cfg := &HTTPS{BaseURL: "https://files.bank.com/api/v1/"}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "files.bank.com", cfg.Hostname())
+
+	cfg.BaseURL = "http://files.bank.com"
+	require.ErrorContains(t, cfg.Validate(), "must be an https:// URL")
+
+	cfg.BaseURL = "https://files.bank.com"
+	cfg.ClientCertFile = "client.pem"
+	require.ErrorContains(t, cfg.Validate(), "must both be set")
+}
+
+func TestAS2__Validate(t *testing.T) {
+	cfg := &AS2{
+		URL:             "https://as2.bank.com/as2",
+		AS2From:         "ACHGATEWAY",
+		AS2To:           "BANK",
+		CertFile:        "ours.crt",
+		KeyFile:         "ours.key",
+		PartnerCertFile: "bank.crt",
+		Inbox:           "as2-inbox",
+	}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "sha256", cfg.Signing())
+	require.Equal(t, "aes256-cbc", cfg.Encryption())
+	require.Equal(t, "sync", cfg.MDNMode())
+
+	cfg.SigningAlgorithm, cfg.EncryptionAlgorithm = "none", "none"
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, "", cfg.Signing())
+	require.Equal(t, "", cfg.Encryption())
+
+	cfg.SigningAlgorithm = "md5"
+	require.ErrorContains(t, cfg.Validate(), "unknown SigningAlgorithm")
+
+	cfg.SigningAlgorithm = "SHA1"
+	cfg.MDN.Mode = "async"
+	require.ErrorContains(t, cfg.Validate(), "invalid MDN AsyncURL")
+
+	cfg.MDN.AsyncURL = "https://achgateway.example.com/as2/bank/mdn"
+	require.NoError(t, cfg.Validate())
+
+	cfg.AS2To = ""
+	require.ErrorContains(t, cfg.Validate(), "AS2From and AS2To must both be set")
+}
+
+func TestFilesystem__Validate(t *testing.T) {
+	cfg := &Filesystem{}
+	require.ErrorContains(t, cfg.Validate(), "missing Directory")
+
+	cfg.Directory = "/mnt/bank"
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, os.FileMode(0640), cfg.FileMode())
+
+	cfg.Permissions = "0660"
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, os.FileMode(0660), cfg.FileMode())
+
+	cfg.Permissions = "rw-rw----"
+	require.ErrorContains(t, cfg.Validate(), "invalid Permissions")
+
+	cfg.Permissions = "01777"
+	require.ErrorContains(t, cfg.Validate(), "invalid Permissions")
+}
+
+func TestDownloads(t *testing.T) {
+	var cfg *Downloads
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, int64(DefaultMaxInMemoryDownloads), cfg.InMemoryLimit())
+	require.Equal(t, os.TempDir(), cfg.Directory())
+
+	dir := t.TempDir()
+	cfg = &Downloads{MaxInMemorySize: 1024, TempDir: dir}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, int64(1024), cfg.InMemoryLimit())
+	require.Equal(t, dir, cfg.Directory())
+
+	cfg.MaxInMemorySize = -1
+	require.Equal(t, int64(0), cfg.InMemoryLimit())
+
+	cfg.TempDir = filepath.Join(dir, "missing")
+	require.ErrorContains(t, cfg.Validate(), "TempDir")
+}
+
+func TestDownloadWorkers(t *testing.T) {
+	var ftp *FTP
+	require.Equal(t, 1, ftp.Workers())
+	var sftp *SFTP
+	require.Equal(t, 1, sftp.Workers())
+
+	ftp = &FTP{DownloadWorkers: 4}
+	require.NoError(t, ftp.Validate())
+	require.Equal(t, 4, ftp.Workers())
+
+	sftp = &SFTP{DownloadWorkers: 8}
+	require.NoError(t, sftp.Validate())
+	require.Equal(t, 8, sftp.Workers())
+
+	sftp.DownloadWorkers = 100
+	require.ErrorContains(t, sftp.Validate(), "DownloadWorkers of 100 must be between 0 and 32")
+
+	ftp.DownloadWorkers = -1
+	require.ErrorContains(t, ftp.Validate(), "DownloadWorkers of -1")
+}
+
+func TestFilenameFilter(t *testing.T) {
+	var cfg *FilenameFilter
+	require.NoError(t, cfg.Validate())
+	matches, err := cfg.Matcher()
+	require.NoError(t, err)
+	require.True(t, matches("statement.pdf"))
+
+	cfg = &FilenameFilter{
+		Include: []string{"*.ach", `/^RET_\d{8}\.txt$/`},
+		Exclude: []string{"TEST_*"},
+	}
+	require.NoError(t, cfg.Validate())
+	matches, err = cfg.Matcher()
+	require.NoError(t, err)
+	require.True(t, matches("20220601.ach"))
+	require.True(t, matches("RET_20220601.txt"))
+	require.False(t, matches("RET_report.txt"))
+	require.False(t, matches("statement.pdf"))
+	require.False(t, matches("TEST_20220601.ach"))
+
+	// Only excluding keeps every other file
+	cfg = &FilenameFilter{Exclude: []string{"*.pdf"}}
+	matches, err = cfg.Matcher()
+	require.NoError(t, err)
+	require.True(t, matches("return.ach"))
+	require.False(t, matches("statement.pdf"))
+
+	cfg = &FilenameFilter{Include: []string{"/RET_(/"}}
+	require.ErrorContains(t, cfg.Validate(), "include: invalid regular expression /RET_(/")
+
+	cfg = &FilenameFilter{Exclude: []string{"[a-"}}
+	require.ErrorContains(t, cfg.Validate(), `exclude: invalid pattern "[a-"`)
+
+	agents := UploadAgents{Agents: []UploadAgent{{
+		ID:    "odfi",
+		Paths: UploadPaths{ReturnFilter: cfg},
+	}}}
+	require.ErrorContains(t, agents.Validate(), "agent odfi: paths: return filter: exclude")
+}
+
+func TestUploadPaths__Depth(t *testing.T) {
+	require.NoError(t, UploadPaths{Depth: 2}.Validate())
+	require.ErrorContains(t, UploadPaths{Depth: -1}.Validate(), "negative Depth -1")
+}
+
+func TestAfterProcessing(t *testing.T) {
+	var cfg *AfterProcessing
+	require.NoError(t, cfg.Validate())
+
+	require.NoError(t, (&AfterProcessing{Action: AfterProcessingNone}).Validate())
+	require.NoError(t, (&AfterProcessing{Action: AfterProcessingDelete}).Validate())
+	require.NoError(t, (&AfterProcessing{Action: AfterProcessingMove, Directory: "archive/"}).Validate())
+
+	require.ErrorContains(t, (&AfterProcessing{}).Validate(), `unknown Action ""`)
+	require.ErrorContains(t, (&AfterProcessing{Action: AfterProcessingMove}).Validate(), "missing Directory")
+	require.ErrorContains(t, (&AfterProcessing{Action: AfterProcessingDelete, Directory: "archive/"}).Validate(), "only used with the move Action")
+
+	agents := UploadAgents{Agents: []UploadAgent{{
+		ID:              "odfi",
+		HTTPS:           &HTTPS{BaseURL: "https://bank.com"},
+		AfterProcessing: &AfterProcessing{Action: AfterProcessingMove, Directory: "archive/"},
+	}}}
+	require.ErrorContains(t, agents.Validate(), "agent odfi: after processing: HTTPS agents can't move files")
+}
+
+func TestUploadAgent__OnCollision(t *testing.T) {
+	agents := UploadAgents{Agents: []UploadAgent{{
+		ID:          "odfi",
+		SFTP:        &SFTP{Hostname: "sftp.bank.com", Username: "moov", Password: "secret"},
+		OnCollision: CollisionSuffix,
+	}}}
+	require.NoError(t, agents.Validate())
+
+	agents.Agents[0].OnCollision = "rename"
+	require.ErrorContains(t, agents.Validate(), `agent odfi: unknown OnCollision "rename"`)
+
+	agents.Agents[0].SFTP = nil
+	agents.Agents[0].HTTPS = &HTTPS{BaseURL: "https://bank.com"}
+	agents.Agents[0].OnCollision = CollisionError
+	require.ErrorContains(t, agents.Validate(), "agent odfi: OnCollision: HTTPS and AS2 agents can't check for remote files")
+}
+
+func TestAS2__AllowsReceiptURL(t *testing.T) {
+	cfg := &AS2{
+		URL:          "https://as2.bank.com:4080/as2",
+		ReceiptHosts: []string{"mdn.bank.com"},
+	}
+	require.True(t, cfg.AllowsReceiptURL("https://as2.bank.com/mdn"))
+	require.True(t, cfg.AllowsReceiptURL("http://MDN.bank.com:8080/receipts"))
+	require.False(t, cfg.AllowsReceiptURL("http://169.254.169.254/latest/meta-data"))
+	require.False(t, cfg.AllowsReceiptURL("ftp://as2.bank.com/mdn"))
+	require.False(t, cfg.AllowsReceiptURL("mailto:ops@bank.com"))
+}
@@ -0,0 +1,154 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package settlement computes when uploaded ACH entries are expected to settle from the time
+// they're uploaded, Same-Day eligibility and the Federal Reserve's holiday calendar.
+package settlement
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base"
+)
+
+const (
+	defaultSameDayCutoff = "16:45"
+	defaultTimezone      = "America/New_York"
+	defaultSameDayLimit  = 100000000 // $1,000,000
+)
+
+// Calculator computes expected settlement dates.
+type Calculator struct {
+	location      *time.Location
+	sameDayCutoff time.Time
+	sameDayLimit  int
+}
+
+// NewCalculator returns a Calculator for cfg, which can be nil for the defaults
+func NewCalculator(cfg *service.SettlementConfig) (*Calculator, error) {
+	if cfg == nil {
+		cfg = &service.SettlementConfig{}
+	}
+	cutoff, timezone, limit := cfg.SameDayCutoff, cfg.Timezone, cfg.SameDayLimit
+	if cutoff == "" {
+		cutoff = defaultSameDayCutoff
+	}
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+	if limit == 0 {
+		limit = defaultSameDayLimit
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q: %v", timezone, err)
+	}
+	when, err := time.Parse("15:04", cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("invalid same-day cutoff %q: %v", cutoff, err)
+	}
+	return &Calculator{
+		location:      location,
+		sameDayCutoff: when,
+		sameDayLimit:  limit,
+	}, nil
+}
+
+// Estimate is when an entry is expected to settle.
+type Estimate struct {
+	// Date is midnight of the settlement date in the calculator's timezone
+	Date    time.Time
+	SameDay bool
+}
+
+// Entry returns when an entry with effectiveEntryDate (YYMMDD) uploaded at uploadedAt settles.
+//
+// Entries effective after the upload date settle on their effective date, or the following banking day.
+// Otherwise eligible entries uploaded on a banking day before the same-day cutoff settle that day and
+// the rest settle on the next banking day.
+func (c *Calculator) Entry(uploadedAt time.Time, effectiveEntryDate string, secCode string, amount int) Estimate {
+	uploadedAt = uploadedAt.In(c.location)
+	uploadDay := c.date(uploadedAt)
+
+	if effective, err := time.ParseInLocation("060102", effectiveEntryDate, c.location); err == nil {
+		effective = c.date(effective)
+		if effective.After(uploadDay) {
+			return Estimate{Date: c.bankingDayOnOrAfter(effective)}
+		}
+	}
+
+	cutoff := time.Date(uploadedAt.Year(), uploadedAt.Month(), uploadedAt.Day(), c.sameDayCutoff.Hour(), c.sameDayCutoff.Minute(), 0, 0, c.location)
+	eligible := secCode != ach.IAT && amount <= c.sameDayLimit
+	if eligible && uploadedAt.Before(cutoff) && c.isBankingDay(uploadDay) {
+		return Estimate{Date: uploadDay, SameDay: true}
+	}
+	return Estimate{Date: c.nextBankingDay(uploadDay)}
+}
+
+// File returns the expected settlement of each entry in file uploaded at uploadedAt
+func (c *Calculator) File(file *ach.File, uploadedAt time.Time) []models.EntrySettlement {
+	if file == nil {
+		return nil
+	}
+	var out []models.EntrySettlement
+	for i := range file.Batches {
+		bh := file.Batches[i].GetHeader()
+		if bh == nil {
+			continue
+		}
+		effective := bh.EffectiveEntryDate
+		if when, err := time.Parse("060102", effective); err == nil {
+			effective = when.Format("2006-01-02")
+		}
+		entries := file.Batches[i].GetEntries()
+		for j := range entries {
+			estimate := c.Entry(uploadedAt, bh.EffectiveEntryDate, bh.StandardEntryClassCode, entries[j].Amount)
+			out = append(out, models.EntrySettlement{
+				EntryID:            entries[j].ID,
+				TraceNumber:        entries[j].TraceNumber,
+				EffectiveEntryDate: effective,
+				SettlementDate:     estimate.Date.Format("2006-01-02"),
+				SameDay:            estimate.SameDay,
+			})
+		}
+	}
+	return out
+}
+
+func (c *Calculator) date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.location)
+}
+
+func (c *Calculator) isBankingDay(day time.Time) bool {
+	return base.NewTime(day.Add(12 * time.Hour)).IsBankingDay()
+}
+
+func (c *Calculator) bankingDayOnOrAfter(day time.Time) time.Time {
+	if c.isBankingDay(day) {
+		return day
+	}
+	return c.nextBankingDay(day)
+}
+
+func (c *Calculator) nextBankingDay(day time.Time) time.Time {
+	return c.date(base.NewTime(day.Add(12 * time.Hour)).AddBankingDay(1).Time)
+}
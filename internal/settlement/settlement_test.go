@@ -0,0 +1,104 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package settlement
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculator__Entry(t *testing.T) {
+	calc, err := NewCalculator(nil)
+	require.NoError(t, err)
+
+	eastern, _ := time.LoadLocation("America/New_York")
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2023, time.October, day, hour, minute, 0, 0, eastern)
+	}
+	check := func(t *testing.T, estimate Estimate, date string, sameDay bool) {
+		t.Helper()
+		require.Equal(t, date, estimate.Date.Format("2006-01-02"))
+		require.Equal(t, sameDay, estimate.SameDay)
+	}
+
+	// Tuesday before and after the same-day cutoff
+	check(t, calc.Entry(at(10, 10, 0), "231010", ach.PPD, 100), "2023-10-10", true)
+	check(t, calc.Entry(at(10, 16, 44), "231010", ach.PPD, 100), "2023-10-10", true)
+	check(t, calc.Entry(at(10, 16, 45), "231010", ach.PPD, 100), "2023-10-11", false)
+
+	// Stale and invalid effective dates are processed as soon as possible
+	check(t, calc.Entry(at(10, 10, 0), "231001", ach.PPD, 100), "2023-10-10", true)
+	check(t, calc.Entry(at(10, 10, 0), "", ach.PPD, 100), "2023-10-10", true)
+
+	// Future effective dates, a Saturday settles on Monday
+	check(t, calc.Entry(at(10, 10, 0), "231011", ach.PPD, 100), "2023-10-11", false)
+	check(t, calc.Entry(at(10, 10, 0), "231014", ach.PPD, 100), "2023-10-16", false)
+
+	// Entries which aren't eligible for Same-Day settle the next banking day
+	check(t, calc.Entry(at(10, 10, 0), "231010", ach.IAT, 100), "2023-10-11", false)
+	check(t, calc.Entry(at(10, 10, 0), "231010", ach.PPD, 100000001), "2023-10-11", false)
+
+	// Friday after the cutoff skips the weekend and Columbus Day
+	check(t, calc.Entry(at(6, 18, 0), "231006", ach.PPD, 100), "2023-10-10", false)
+	check(t, calc.Entry(at(8, 10, 0), "231008", ach.PPD, 100), "2023-10-10", false)
+
+	// Upload times in other timezones are converted
+	check(t, calc.Entry(at(10, 15, 0).UTC(), "231010", ach.PPD, 100), "2023-10-10", true)
+}
+
+func TestCalculator__Config(t *testing.T) {
+	calc, err := NewCalculator(&service.SettlementConfig{
+		SameDayCutoff: "13:00",
+		Timezone:      "America/Chicago",
+		SameDayLimit:  5000,
+	})
+	require.NoError(t, err)
+
+	central, _ := time.LoadLocation("America/Chicago")
+	when := time.Date(2023, time.October, 10, 12, 30, 0, 0, central)
+	require.True(t, calc.Entry(when, "231010", ach.CCD, 5000).SameDay)
+	require.False(t, calc.Entry(when, "231010", ach.CCD, 5001).SameDay)
+	require.False(t, calc.Entry(when.Add(time.Hour), "231010", ach.CCD, 5000).SameDay)
+
+	_, err = NewCalculator(&service.SettlementConfig{Timezone: "Mars/Olympus"})
+	require.ErrorContains(t, err, "unknown timezone")
+}
+
+func TestCalculator__File(t *testing.T) {
+	calc, err := NewCalculator(nil)
+	require.NoError(t, err)
+
+	file, err := ach.ReadFile("../../testdata/ppd-debit.ach")
+	require.NoError(t, err)
+	file.Batches[0].GetHeader().EffectiveEntryDate = "231010"
+
+	eastern, _ := time.LoadLocation("America/New_York")
+	settlements := calc.File(file, time.Date(2023, time.October, 10, 9, 0, 0, 0, eastern))
+	require.Len(t, settlements, len(file.Batches[0].GetEntries()))
+	require.Equal(t, file.Batches[0].GetEntries()[0].TraceNumber, settlements[0].TraceNumber)
+	require.Equal(t, "2023-10-10", settlements[0].EffectiveEntryDate)
+	require.Equal(t, "2023-10-10", settlements[0].SettlementDate)
+	require.True(t, settlements[0].SameDay)
+
+	require.Empty(t, calc.File(nil, time.Now()))
+}
@@ -0,0 +1,133 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package signing
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Repository interface {
+	// Create saves a new key
+	Create(key *Key) error
+
+	// Rotate saves key and sets every other unexpired key to expire at expiresAt
+	Rotate(key *Key, expiresAt time.Time) error
+
+	// Revoke stops keyID from signing, returning false when it doesn't exist
+	Revoke(keyID string, revokedAt time.Time) (bool, error)
+
+	// List returns every key with its secret, oldest first
+	List() ([]Key, error)
+}
+
+// NewRepository returns a Repository stored in db, or nil when db is nil
+func NewRepository(db *sql.DB) Repository {
+	if db == nil {
+		return nil
+	}
+	return &sqlRepository{db: db}
+}
+
+type sqlRepository struct {
+	db *sql.DB
+}
+
+func (r *sqlRepository) Create(key *Key) error {
+	query := `insert into signing_keys (key_id, secret, created_at) values (?, ?, ?);`
+	_, err := r.db.Exec(query, key.ID, key.Secret, key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("creating signing key %s: %v", key.ID, err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) Rotate(key *Key, expiresAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("rotating signing keys: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `update signing_keys set expires_at = ? where revoked_at is null and (expires_at is null or expires_at > ?);`
+	if _, err := tx.Exec(query, expiresAt, expiresAt); err != nil {
+		return fmt.Errorf("rotating signing keys: %v", err)
+	}
+	query = `insert into signing_keys (key_id, secret, created_at) values (?, ?, ?);`
+	if _, err := tx.Exec(query, key.ID, key.Secret, key.CreatedAt); err != nil {
+		return fmt.Errorf("rotating signing keys: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("rotating signing keys: %v", err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) Revoke(keyID string, revokedAt time.Time) (bool, error) {
+	keyID = strings.TrimSpace(keyID)
+
+	query := `update signing_keys set revoked_at = ? where key_id = ? and revoked_at is null;`
+	res, err := r.db.Exec(query, revokedAt, keyID)
+	if err != nil {
+		return false, fmt.Errorf("revoking signing key %s: %v", keyID, err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return true, nil
+	}
+
+	// Keys which were already revoked still exist
+	var found string
+	err = r.db.QueryRow(`select key_id from signing_keys where key_id = ? limit 1;`, keyID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("revoking signing key %s: %v", keyID, err)
+	}
+	return true, nil
+}
+
+func (r *sqlRepository) List() ([]Key, error) {
+	rows, err := r.db.Query(`select key_id, secret, created_at, expires_at, revoked_at from signing_keys order by created_at, key_id;`)
+	if err != nil {
+		return nil, fmt.Errorf("listing signing keys: %v", err)
+	}
+	defer rows.Close()
+
+	var out []Key
+	for rows.Next() {
+		var key Key
+		var expiresAt, revokedAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Secret, &key.CreatedAt, &expiresAt, &revokedAt); err != nil {
+			return nil, fmt.Errorf("listing signing keys: %v", err)
+		}
+		key.CreatedAt = key.CreatedAt.UTC()
+		if expiresAt.Valid {
+			when := expiresAt.Time.UTC()
+			key.ExpiresAt = &when
+		}
+		if revokedAt.Valid {
+			when := revokedAt.Time.UTC()
+			key.RevokedAt = &when
+		}
+		out = append(out, key)
+	}
+	return out, rows.Err()
+}
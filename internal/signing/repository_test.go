@@ -0,0 +1,67 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package signing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository(t *testing.T) {
+	require.Nil(t, NewRepository(nil))
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := NewRepository(db.DB)
+
+	created := time.Date(2022, time.October, 14, 15, 4, 0, 0, time.UTC)
+	first := &Key{ID: "first", Secret: "secret1", CreatedAt: created}
+	require.NoError(t, repo.Create(first))
+
+	// Rotating never extends when a key expires
+	second := &Key{ID: "second", Secret: "secret2", CreatedAt: created.Add(time.Minute)}
+	require.NoError(t, repo.Rotate(second, created.Add(time.Hour)))
+	third := &Key{ID: "third", Secret: "secret3", CreatedAt: created.Add(2 * time.Minute)}
+	require.NoError(t, repo.Rotate(third, created.Add(2*time.Hour)))
+
+	found, err := repo.Revoke("second", created.Add(3*time.Minute))
+	require.NoError(t, err)
+	require.True(t, found)
+
+	found, err = repo.Revoke("missing", created)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	keys, err := repo.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+
+	require.Equal(t, "secret1", keys[0].Secret)
+	require.Equal(t, created, keys[0].CreatedAt)
+	require.Equal(t, created.Add(time.Hour), *keys[0].ExpiresAt)
+	require.Nil(t, keys[0].RevokedAt)
+
+	require.Equal(t, created.Add(2*time.Hour), *keys[1].ExpiresAt)
+	require.Equal(t, created.Add(3*time.Minute), *keys[1].RevokedAt)
+
+	require.Nil(t, keys[2].ExpiresAt)
+}
@@ -0,0 +1,272 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package signing signs events with HMAC-SHA256 keys which are created, rotated and revoked
+// while achgateway runs. Every signature names its key so consumers know which secret to use.
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base"
+)
+
+const (
+	// Header is set on webhook requests
+	Header = "ACHGateway-Signature"
+
+	// MetadataKey is set on stream messages
+	MetadataKey = "signature"
+)
+
+// cacheTTL is how long signing keys are reused before being read again, which is how long
+// other instances take to notice a key was created, rotated or revoked.
+const cacheTTL = 10 * time.Second
+
+type Status string
+
+const (
+	StatusActive   Status = "active"
+	StatusExpiring Status = "expiring"
+	StatusExpired  Status = "expired"
+	StatusRevoked  Status = "revoked"
+)
+
+var ErrNotFound = errors.New("signing key not found")
+
+// Key is an HMAC secret used to sign events. Active and expiring keys sign every event.
+type Key struct {
+	ID string `json:"keyID"`
+
+	// Secret is only returned when the key is created
+	Secret string `json:"secret,omitempty"`
+
+	Status    Status     `json:"status"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+func (k Key) status(now time.Time) Status {
+	switch {
+	case k.RevokedAt != nil:
+		return StatusRevoked
+	case k.ExpiresAt == nil:
+		return StatusActive
+	case now.Before(*k.ExpiresAt):
+		return StatusExpiring
+	}
+	return StatusExpired
+}
+
+// Keyring signs events with the keys in a Repository shared by every instance
+type Keyring struct {
+	repo  Repository
+	grace time.Duration
+	now   func() time.Time
+
+	mu       sync.Mutex
+	keys     []Key
+	loadedAt time.Time
+}
+
+func NewKeyring(repo Repository, cfg *service.EventsSigning) *Keyring {
+	return &Keyring{
+		repo:  repo,
+		grace: cfg.GracePeriod(),
+		now:   time.Now,
+	}
+}
+
+// Create adds a key which signs events alongside any existing keys
+func (k *Keyring) Create() (*Key, error) {
+	key, err := k.newKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := k.repo.Create(key); err != nil {
+		return nil, err
+	}
+	k.reset()
+	return key, nil
+}
+
+// Rotate adds a key and expires every other key after the grace period, which keep signing
+// events until then.
+func (k *Keyring) Rotate() (*Key, error) {
+	key, err := k.newKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := k.repo.Rotate(key, key.CreatedAt.Add(k.grace)); err != nil {
+		return nil, err
+	}
+	k.reset()
+	return key, nil
+}
+
+// Revoke stops a key from signing events right away
+func (k *Keyring) Revoke(keyID string) error {
+	found, err := k.repo.Revoke(keyID, k.timestamp())
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	k.reset()
+	return nil
+}
+
+// List returns every key without its secret
+func (k *Keyring) List() ([]Key, error) {
+	keys, err := k.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	now := k.now()
+	for i := range keys {
+		keys[i].Secret = ""
+		keys[i].Status = keys[i].status(now)
+	}
+	return keys, nil
+}
+
+// Sign returns the signature of body to send with it, which is empty when no keys sign events.
+// A nil Keyring doesn't sign.
+func (k *Keyring) Sign(body []byte) (string, error) {
+	if k == nil {
+		return "", nil
+	}
+	keys, err := k.signingKeys()
+	if err != nil {
+		return "", fmt.Errorf("signing: %v", err)
+	}
+	return Signature(keys, k.now(), body), nil
+}
+
+// signingKeys returns the active and expiring keys, reading them again after cacheTTL
+func (k *Keyring) signingKeys() ([]Key, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := k.now()
+	if k.keys == nil || now.Sub(k.loadedAt) >= cacheTTL {
+		keys, err := k.repo.List()
+		if err != nil {
+			return nil, err
+		}
+		k.keys, k.loadedAt = keys, now
+	}
+
+	var out []Key
+	for _, key := range k.keys {
+		if status := key.status(now); status == StatusActive || status == StatusExpiring {
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
+
+func (k *Keyring) reset() {
+	k.mu.Lock()
+	k.keys = nil
+	k.mu.Unlock()
+}
+
+func (k *Keyring) newKey() (*Key, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generating signing key: %v", err)
+	}
+	return &Key{
+		ID:        base.ID(),
+		Secret:    hex.EncodeToString(secret),
+		Status:    StatusActive,
+		CreatedAt: k.timestamp(),
+	}, nil
+}
+
+// timestamp is the current time in UTC with the precision DATETIME columns store
+func (k *Keyring) timestamp() time.Time {
+	return k.now().UTC().Truncate(time.Second)
+}
+
+// Signature formats "t=<unix seconds>,v1=<key ID>:<hex HMAC-SHA256>" with one v1 for each key.
+// The HMAC covers the timestamp, a period and body, so signed events can't be replayed later.
+func Signature(keys []Key, timestamp time.Time, body []byte) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+
+	var buf strings.Builder
+	buf.WriteString("t=" + t)
+	for _, key := range keys {
+		buf.WriteString(fmt.Sprintf(",v1=%s:%s", key.ID, mac(key.Secret, t, body)))
+	}
+	return buf.String()
+}
+
+// Verify checks header has a signature of body from one of secrets, keyed by key ID, and was
+// signed within tolerance of now.
+func Verify(header string, secrets map[string]string, body []byte, tolerance time.Duration, now time.Time) error {
+	var t string
+	var signatures [][2]string
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "t":
+			t = value
+		case "v1":
+			if keyID, sig, ok := strings.Cut(value, ":"); ok {
+				signatures = append(signatures, [2]string{keyID, sig})
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return errors.New("missing signature timestamp")
+	}
+	if diff := now.Sub(time.Unix(unix, 0)); diff > tolerance || diff < -tolerance {
+		return fmt.Errorf("signature timestamp is %v from now", diff.Truncate(time.Second))
+	}
+	for _, sig := range signatures {
+		secret, exists := secrets[sig[0]]
+		if exists && hmac.Equal([]byte(mac(secret, t, body)), []byte(sig[1])) {
+			return nil
+		}
+	}
+	return errors.New("no valid signature from a known key")
+}
+
+func mac(secret, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,138 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package signing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testKeyring(t *testing.T, grace time.Duration) (*Keyring, *time.Time) {
+	t.Helper()
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	now := time.Date(2022, time.October, 14, 15, 4, 0, 0, time.UTC)
+	keys := NewKeyring(NewRepository(db.DB), &service.EventsSigning{RotationGracePeriod: grace})
+	keys.now = func() time.Time { return now }
+	return keys, &now
+}
+
+func TestKeyring(t *testing.T) {
+	keys, now := testKeyring(t, time.Hour)
+	body := []byte(`{"type":"FileUploaded"}`)
+
+	// Nothing is signed without keys
+	sig, err := keys.Sign(body)
+	require.NoError(t, err)
+	require.Empty(t, sig)
+
+	first, err := keys.Create()
+	require.NoError(t, err)
+	require.Len(t, first.Secret, 64)
+	require.Equal(t, StatusActive, first.Status)
+
+	sig, err = keys.Sign(body)
+	require.NoError(t, err)
+	require.Contains(t, sig, "v1="+first.ID+":")
+	require.NoError(t, Verify(sig, map[string]string{first.ID: first.Secret}, body, time.Minute, *now))
+
+	// Rotated keys sign alongside the new key until the grace period passes
+	*now = now.Add(time.Minute)
+	second, err := keys.Rotate()
+	require.NoError(t, err)
+	sig, err = keys.Sign(body)
+	require.NoError(t, err)
+	require.NoError(t, Verify(sig, map[string]string{first.ID: first.Secret}, body, time.Minute, *now))
+	require.NoError(t, Verify(sig, map[string]string{second.ID: second.Secret}, body, time.Minute, *now))
+
+	list, err := keys.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, StatusExpiring, list[0].Status)
+	require.Equal(t, now.Add(time.Hour), *list[0].ExpiresAt)
+	require.Equal(t, StatusActive, list[1].Status)
+	for i := range list {
+		require.Empty(t, list[i].Secret)
+	}
+
+	*now = now.Add(time.Hour)
+	sig, err = keys.Sign(body)
+	require.NoError(t, err)
+	require.NotContains(t, sig, first.ID)
+	require.ErrorContains(t, Verify(sig, map[string]string{first.ID: first.Secret}, body, time.Minute, *now), "no valid signature")
+
+	// Revoked keys stop signing right away
+	require.NoError(t, keys.Revoke(second.ID))
+	sig, err = keys.Sign(body)
+	require.NoError(t, err)
+	require.Empty(t, sig)
+
+	require.ErrorIs(t, keys.Revoke("missing"), ErrNotFound)
+	require.NoError(t, keys.Revoke(second.ID))
+
+	list, err = keys.List()
+	require.NoError(t, err)
+	require.Equal(t, StatusExpired, list[0].Status)
+	require.Equal(t, StatusRevoked, list[1].Status)
+}
+
+func TestKeyring__Cache(t *testing.T) {
+	keys, now := testKeyring(t, time.Hour)
+	other := NewKeyring(keys.repo, nil)
+	other.now = keys.now
+
+	key, err := keys.Create()
+	require.NoError(t, err)
+	sig, err := other.Sign(nil)
+	require.NoError(t, err)
+	require.Contains(t, sig, key.ID)
+
+	// Other instances notice a revoked key after cacheTTL
+	require.NoError(t, keys.Revoke(key.ID))
+	sig, err = other.Sign(nil)
+	require.NoError(t, err)
+	require.Contains(t, sig, key.ID)
+
+	*now = now.Add(cacheTTL)
+	sig, err = other.Sign(nil)
+	require.NoError(t, err)
+	require.Empty(t, sig)
+}
+
+func TestVerify(t *testing.T) {
+	key := Key{ID: "key1", Secret: "secret"}
+	body := []byte("body")
+	now := time.Unix(1665760000, 0)
+
+	sig := Signature([]Key{key}, now, body)
+	require.Equal(t, "t=1665760000,v1=key1:"+mac("secret", "1665760000", body), sig)
+
+	secrets := map[string]string{key.ID: key.Secret}
+	require.NoError(t, Verify(sig, secrets, body, time.Minute, now.Add(time.Minute)))
+	require.ErrorContains(t, Verify(sig, secrets, body, time.Minute, now.Add(2*time.Minute)), "signature timestamp is 2m0s from now")
+	require.ErrorContains(t, Verify(sig, secrets, []byte("other"), time.Minute, now), "no valid signature")
+	require.ErrorContains(t, Verify(sig, map[string]string{"key2": "secret"}, body, time.Minute, now), "no valid signature")
+	require.ErrorContains(t, Verify("v1=key1:abc", secrets, body, time.Minute, now), "missing signature timestamp")
+}
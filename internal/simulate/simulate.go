@@ -0,0 +1,240 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package simulate implements `achgateway shards simulate` which runs sample files through a
+// shard's merging and cutoff offline so configuration changes can be reviewed before deploying.
+package simulate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/pipeline"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+)
+
+const usage = `Usage: achgateway shards simulate -shard <name> -files <dir> -out <dir>
+
+Accepts each .ach (Nacha) and .json file in -files into the shard, runs a cutoff offline and
+writes the files which would have been uploaded into -out along with summary.json.
+
+Merge conditions, filename templates, pre-upload transforms, output formats, guardrails and
+blocklist screening from the config are applied. Nothing is uploaded, no notifications are sent
+and the audit trail and merging storage are not written to.
+`
+
+// Run executes the shards subcommand named by args[0] and writes its output to out.
+func Run(args []string, out io.Writer, logger log.Logger, loadConfig service.ConfigLoader) error {
+	if len(args) == 0 || args[0] != "simulate" {
+		fmt.Fprint(out, usage)
+		if len(args) == 0 {
+			return errors.New("missing command")
+		}
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	fs := flag.NewFlagSet("achgateway shards simulate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprint(out, usage+"\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	flagShard := fs.String("shard", "", "Shard name to simulate")
+	flagFiles := fs.String("files", "", "Directory of sample ACH files")
+	flagOut := fs.String("out", "simulation", "Directory to write outbound files and summary.json into")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *flagShard == "" {
+		return errors.New("missing -shard")
+	}
+	if *flagFiles == "" {
+		return errors.New("missing -files")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %v", err)
+	}
+	shard := cfg.Sharding.Find(*flagShard)
+	if shard == nil {
+		return fmt.Errorf("shard %s not found", *flagShard)
+	}
+
+	files, err := readFiles(*flagFiles)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no .ach or .json files found in %s", *flagFiles)
+	}
+
+	result, err := pipeline.Simulate(logger, *shard, cfg.Upload, files)
+	if err != nil {
+		return fmt.Errorf("simulating %s: %v", shard.Name, err)
+	}
+
+	sum := summarize(result)
+	if err := writeResult(*flagOut, result, sum); err != nil {
+		return err
+	}
+	return sum.print(out, *flagOut)
+}
+
+// readFiles parses each .ach and .json file in dir, the filename without its extension is used as the fileID.
+func readFiles(dir string) ([]incoming.ACHFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []incoming.ACHFile
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".ach" && ext != ".json") {
+			continue
+		}
+		bs, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var file *ach.File
+		if ext == ".json" {
+			file, err = ach.FileFromJSON(bs)
+		} else {
+			var f ach.File
+			f, err = ach.NewReader(bytes.NewReader(bs)).Read()
+			file = &f
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", entry.Name(), err)
+		}
+
+		out = append(out, incoming.ACHFile{
+			FileID: strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())),
+			File:   file,
+		})
+	}
+	return out, nil
+}
+
+type summary struct {
+	Shard    string            `json:"shard"`
+	Accepted []string          `json:"accepted"`
+	Rejected map[string]string `json:"rejected,omitempty"`
+	Files    []fileSummary     `json:"files"`
+	Errors   []string          `json:"errors,omitempty"`
+}
+
+type fileSummary struct {
+	Filename             string `json:"filename"`
+	ImmediateDestination string `json:"immediateDestination"`
+	ImmediateOrigin      string `json:"immediateOrigin"`
+	Batches              int    `json:"batches"`
+	Entries              int    `json:"entries"`
+	TotalDebitAmount     int    `json:"totalDebitAmount"`
+	TotalCreditAmount    int    `json:"totalCreditAmount"`
+}
+
+func summarize(result *pipeline.SimulationResult) summary {
+	sum := summary{
+		Shard:    result.Shard,
+		Accepted: result.Accepted,
+		Rejected: result.Rejected,
+		Errors:   result.Errors,
+	}
+	for _, f := range result.Files {
+		fs := fileSummary{
+			Filename: f.Filename,
+		}
+		if f.File != nil {
+			fs.ImmediateDestination = f.File.Header.ImmediateDestination
+			fs.ImmediateOrigin = f.File.Header.ImmediateOrigin
+			fs.Batches = len(f.File.Batches)
+			fs.Entries = f.File.Control.EntryAddendaCount
+			fs.TotalDebitAmount = f.File.Control.TotalDebitEntryDollarAmountInFile
+			fs.TotalCreditAmount = f.File.Control.TotalCreditEntryDollarAmountInFile
+		}
+		sum.Files = append(sum.Files, fs)
+	}
+	return sum
+}
+
+func writeResult(dir string, result *pipeline.SimulationResult, sum summary) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, f := range result.Files {
+		path := filepath.Join(dir, filepath.Base(f.Filename))
+		if err := os.WriteFile(path, f.Contents, 0600); err != nil {
+			return fmt.Errorf("writing %s: %v", path, err)
+		}
+	}
+	bs, err := json.MarshalIndent(sum, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "summary.json"), bs, 0600)
+}
+
+func (sum summary) print(w io.Writer, dir string) error {
+	fmt.Fprintf(w, "Shard %s: accepted %d files, rejected %d, would upload %d files\n\n",
+		sum.Shard, len(sum.Accepted), len(sum.Rejected), len(sum.Files))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Filename\tDestination\tOrigin\tBatches\tEntries\tDebits\tCredits")
+	for _, f := range sum.Files {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\n", f.Filename, f.ImmediateDestination, f.ImmediateOrigin,
+			f.Batches, f.Entries, f.TotalDebitAmount, f.TotalCreditAmount)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(sum.Rejected) > 0 {
+		fmt.Fprintln(w, "\nRejected:")
+		ids := make([]string, 0, len(sum.Rejected))
+		for id := range sum.Rejected {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			fmt.Fprintf(w, "  %s: %s\n", id, sum.Rejected[id])
+		}
+	}
+	if len(sum.Errors) > 0 {
+		fmt.Fprintln(w, "\nErrors:")
+		for _, e := range sum.Errors {
+			fmt.Fprintf(w, "  %s\n", e)
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\nWrote files and summary.json to %s\n", dir)
+	return err
+}
@@ -0,0 +1,92 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package simulate
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	filesDir := t.TempDir()
+	bs, err := os.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(filesDir, "first.ach"), bs, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(filesDir, "notes.txt"), []byte("skipped"), 0600))
+
+	bs, err = os.ReadFile(filepath.Join("..", "..", "testdata", "ppd-valid.json"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(filesDir, "second.json"), bs, 0600))
+
+	loadConfig := func() (*service.Config, error) {
+		return &service.Config{
+			Sharding: service.Sharding{
+				Shards: []service.Shard{
+					{
+						Name: "testing",
+						Cutoffs: service.Cutoffs{
+							Timezone: "America/New_York",
+							Windows:  []string{"17:00"},
+						},
+						UploadAgent:              "ftp-live",
+						OutboundFilenameTemplate: `{{ .ShardName }}-{{ .Index }}.ach`,
+					},
+				},
+			},
+		}, nil
+	}
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	args := []string{"simulate", "-shard", "testing", "-files", filesDir, "-out", outDir}
+
+	var buf bytes.Buffer
+	err = Run(args, &buf, log.NewNopLogger(), loadConfig)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "Shard testing: accepted 2 files, rejected 0")
+
+	bs, err = os.ReadFile(filepath.Join(outDir, "summary.json"))
+	require.NoError(t, err)
+
+	var sum summary
+	require.NoError(t, json.Unmarshal(bs, &sum))
+	require.ElementsMatch(t, []string{"first", "second"}, sum.Accepted)
+	require.NotEmpty(t, sum.Files)
+
+	for _, f := range sum.Files {
+		_, err := os.Stat(filepath.Join(outDir, f.Filename))
+		require.NoError(t, err)
+	}
+
+	// errors
+	err = Run([]string{"simulate", "-shard", "other", "-files", filesDir}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, "shard other not found")
+
+	err = Run([]string{"simulate", "-shard", "testing", "-files", t.TempDir()}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, "no .ach or .json files found")
+
+	err = Run([]string{"simulate", "-shard", "testing"}, &buf, log.NewNopLogger(), loadConfig)
+	require.ErrorContains(t, err, "missing -files")
+}
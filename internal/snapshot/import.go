@@ -0,0 +1,174 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snapshot
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Result summarizes what Import restored
+type Result struct {
+	// Files is how many pending and held files were written
+	Files int `json:"files"`
+
+	// ExistingFiles were already on this instance's storage and left alone
+	ExistingFiles int `json:"existingFiles"`
+
+	// SkippedShards aren't handled by this instance, so their files weren't imported
+	SkippedShards []string `json:"skippedShards,omitempty"`
+
+	Mappings  int `json:"mappings"`
+	Sequences int `json:"sequences"`
+	Counters  int `json:"counters"`
+
+	// Conflicts are shard mappings which differ from this environment's and were left alone
+	Conflicts []string `json:"conflicts,omitempty"`
+
+	// SkippedDatabase is set when the snapshot has database state but this instance has no database
+	SkippedDatabase bool `json:"skippedDatabase,omitempty"`
+}
+
+// Import restores a snapshot written by Export. Files are written to the storage of the matching
+// shard in shards unless they already exist, mappings are added when their shard key isn't mapped
+// and counters are raised to the snapshot's values. Shard configuration isn't changed. db may be nil.
+func Import(r io.Reader, db *sql.DB, shards []Shard) (*Result, error) {
+	manifest, contents, err := Read(r)
+	if err != nil {
+		return nil, err
+	}
+	if !manifest.Contents {
+		for _, state := range manifest.Shards {
+			if len(state.Pending) > 0 || len(state.Held) > 0 {
+				return nil, fmt.Errorf("snapshot from %s doesn't include file contents", manifest.SourceHostname)
+			}
+		}
+	}
+
+	// Check every file before writing any of them
+	for _, state := range manifest.Shards {
+		for _, file := range state.files() {
+			if err := checkFile(state.Name, file, contents); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := &Result{}
+	for _, state := range manifest.Shards {
+		shard := findShard(shards, state.Name)
+		if shard == nil || shard.Storage == nil {
+			result.SkippedShards = append(result.SkippedShards, state.Name)
+			continue
+		}
+		for _, file := range state.files() {
+			existing, err := shard.Storage.Glob(file.Path)
+			if err != nil {
+				return result, fmt.Errorf("checking for %s: %v", file.Path, err)
+			}
+			if len(existing) > 0 {
+				result.ExistingFiles++
+				continue
+			}
+			if err := shard.Storage.WriteFile(file.Path, contents[filepath.Join("files", file.Path)]); err != nil {
+				return result, fmt.Errorf("writing %s: %v", file.Path, err)
+			}
+			result.Files++
+		}
+	}
+
+	if len(manifest.Mappings)+len(manifest.Sequences)+len(manifest.Counters) == 0 {
+		return result, nil
+	}
+	if db == nil {
+		result.SkippedDatabase = true
+		return result, nil
+	}
+	for _, mapping := range manifest.Mappings {
+		added, conflict, err := importMapping(db, mapping)
+		if err != nil {
+			return result, err
+		}
+		if added {
+			result.Mappings++
+		}
+		if conflict != "" {
+			result.Conflicts = append(result.Conflicts, conflict)
+		}
+	}
+	for _, counter := range manifest.Sequences {
+		raised, err := importCounter(db, counterTables[0].table, counterTables[0].key, counterTables[0].value, counter)
+		if err != nil {
+			return result, err
+		}
+		if raised {
+			result.Sequences++
+		}
+	}
+	for _, counter := range manifest.Counters {
+		raised, err := importCounter(db, counterTables[1].table, counterTables[1].key, counterTables[1].value, counter)
+		if err != nil {
+			return result, err
+		}
+		if raised {
+			result.Counters++
+		}
+	}
+	return result, nil
+}
+
+// checkFile verifies file belongs to the shard's directories and matches its recorded checksum
+func checkFile(shardName string, file File, contents map[string][]byte) error {
+	path := filepath.Clean(file.Path)
+	if path != file.Path || strings.HasPrefix(path, "..") || filepath.IsAbs(path) {
+		return fmt.Errorf("invalid path %s", file.Path)
+	}
+	dir := filepath.Dir(path)
+	if dir != filepath.Join("mergable", shardName) && dir != filepath.Join("held", shardName) {
+		return fmt.Errorf("%s is outside of shard %s", file.Path, shardName)
+	}
+
+	bs, exists := contents[filepath.Join("files", path)]
+	if !exists {
+		return fmt.Errorf("missing contents of %s", file.Path)
+	}
+	sum := sha256.Sum256(bs)
+	if hex.EncodeToString(sum[:]) != file.SHA256 {
+		return fmt.Errorf("checksum mismatch for %s", file.Path)
+	}
+	return nil
+}
+
+func (s ShardState) files() []File {
+	out := make([]File, 0, len(s.Pending)+len(s.Held))
+	return append(append(out, s.Pending...), s.Held...)
+}
+
+func findShard(shards []Shard, name string) *Shard {
+	for i := range shards {
+		if shards[i].Config.Name == name {
+			return &shards[i]
+		}
+	}
+	return nil
+}
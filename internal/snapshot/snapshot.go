@@ -0,0 +1,257 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package snapshot exports the state achgateway keeps outside its configuration — pending and
+// held files, shard mappings and sequence counters — as a portable archive, and imports it into
+// another environment.
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+)
+
+// Version is written in each Manifest and incremented when the archive's layout changes
+const Version = 1
+
+const manifestName = "manifest.json"
+
+// Manifest describes the state in a snapshot archive. File contents are stored in the archive
+// under files/ when the snapshot was taken with Contents.
+type Manifest struct {
+	Version        int       `json:"version"`
+	CreatedAt      time.Time `json:"createdAt"`
+	SourceHostname string    `json:"sourceHostname"`
+	Contents       bool      `json:"contents"`
+
+	Shards []ShardState `json:"shards"`
+
+	Mappings  []service.ShardMapping `json:"mappings"`
+	Sequences []Counter              `json:"sequences"`
+	Counters  []Counter              `json:"counters"`
+}
+
+// ShardState is a shard's configuration (with secrets masked) and the files it's waiting to upload
+type ShardState struct {
+	Name   string        `json:"name"`
+	Config service.Shard `json:"config"`
+
+	Pending []File `json:"pending"`
+	Held    []File `json:"held"`
+}
+
+// File is a file on a shard's merging storage
+type File struct {
+	Path    string    `json:"path"`
+	Size    int       `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	SHA256  string    `json:"sha256"`
+}
+
+// Counter is an event sequence or trace number/file ID assignment counter
+type Counter struct {
+	Key   string `json:"key"`
+	Value int64  `json:"value"`
+}
+
+// Shard is a shard handled by this instance and the merging storage holding its files
+type Shard struct {
+	Config  service.Shard
+	Storage storage.Chest
+}
+
+// Options control what Export includes
+type Options struct {
+	// Contents includes each file's contents, decrypted, so they can be imported elsewhere.
+	// Support bundles should leave them out as they contain account numbers.
+	Contents bool
+}
+
+// Export writes a gzipped tar archive of db and each shard's files to w. The caller is
+// responsible for pausing changes to them while Export runs. db may be nil.
+func Export(w io.Writer, db *sql.DB, shards []Shard, opts Options) (*Manifest, error) {
+	hostname, _ := os.Hostname()
+	manifest := &Manifest{
+		Version:        Version,
+		CreatedAt:      time.Now().UTC(),
+		SourceHostname: hostname,
+		Contents:       opts.Contents,
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, shard := range shards {
+		state := ShardState{
+			Name:   shard.Config.Name,
+			Config: shard.Config,
+		}
+		var err error
+		state.Pending, err = exportFiles(tw, shard.Storage, opts, pendingPatterns(shard.Config.Name)...)
+		if err != nil {
+			return nil, fmt.Errorf("exporting %s pending files: %v", shard.Config.Name, err)
+		}
+		state.Held, err = exportFiles(tw, shard.Storage, opts, heldPattern(shard.Config.Name))
+		if err != nil {
+			return nil, fmt.Errorf("exporting %s held files: %v", shard.Config.Name, err)
+		}
+		manifest.Shards = append(manifest.Shards, state)
+	}
+
+	if db != nil {
+		if err := exportTables(db, manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	bs, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, manifestName, bs, manifest.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func pendingPatterns(shardName string) []string {
+	return []string{
+		filepath.Join("mergable", shardName, "*.ach"),
+		filepath.Join("mergable", shardName, "*.json"),
+		filepath.Join("mergable", shardName, "*.005"),
+	}
+}
+
+func heldPattern(shardName string) string {
+	return filepath.Join("held", shardName, "*.ach")
+}
+
+func exportFiles(tw *tar.Writer, chest storage.Chest, opts Options, patterns ...string) ([]File, error) {
+	out := []File{}
+	if chest == nil {
+		return out, nil
+	}
+	for _, pattern := range patterns {
+		matches, err := chest.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			bs, err := readFile(chest, match.RelativePath)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %v", match.RelativePath, err)
+			}
+			sum := sha256.Sum256(bs)
+			out = append(out, File{
+				Path:    match.RelativePath,
+				Size:    len(bs),
+				ModTime: match.ModTime,
+				SHA256:  hex.EncodeToString(sum[:]),
+			})
+			if opts.Contents {
+				if err := writeEntry(tw, filepath.Join("files", match.RelativePath), bs, match.ModTime); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func readFile(chest storage.Chest, path string) ([]byte, error) {
+	fd, err := chest.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return io.ReadAll(fd)
+}
+
+func writeEntry(tw *tar.Writer, name string, bs []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    filepath.ToSlash(name),
+		Mode:    0600,
+		Size:    int64(len(bs)),
+		ModTime: modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(bs)
+	return err
+}
+
+// Read returns the Manifest and file contents from an archive written by Export
+func Read(r io.Reader) (*Manifest, map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading snapshot: %v", err)
+	}
+	defer gz.Close()
+
+	var manifest *Manifest
+	contents := make(map[string][]byte)
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading snapshot: %v", err)
+		}
+		bs, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %v", hdr.Name, err)
+		}
+		if hdr.Name == manifestName {
+			manifest = &Manifest{}
+			if err := json.Unmarshal(bs, manifest); err != nil {
+				return nil, nil, fmt.Errorf("reading manifest: %v", err)
+			}
+			continue
+		}
+		contents[filepath.FromSlash(hdr.Name)] = bs
+	}
+	if manifest == nil {
+		return nil, nil, errors.New("snapshot is missing its manifest")
+	}
+	if manifest.Version != Version {
+		return nil, nil, fmt.Errorf("unsupported snapshot version %d", manifest.Version)
+	}
+	return manifest, contents, nil
+}
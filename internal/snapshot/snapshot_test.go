@@ -0,0 +1,178 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snapshot
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/events"
+	"github.com/moov-io/achgateway/internal/lineage"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/storage"
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testShard(t *testing.T, name string) Shard {
+	t.Helper()
+
+	chest, err := storage.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+
+	return Shard{
+		Config:  service.Shard{Name: name},
+		Storage: chest,
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	source := testShard(t, "testing")
+	require.NoError(t, source.Storage.WriteFile(filepath.Join("mergable", "testing", "a.ach"), []byte("pending a")))
+	require.NoError(t, source.Storage.WriteFile(filepath.Join("mergable", "testing", "b.ach"), []byte("pending b")))
+	require.NoError(t, source.Storage.WriteFile(filepath.Join("held", "testing", "c.ach"), []byte("held c")))
+
+	sourceDB := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { sourceDB.Close() })
+
+	_, err := sourceDB.DB.Exec(`insert into shard_mappings (shard_key, shard_name) values ('key1', 'testing');`)
+	require.NoError(t, err)
+	sequencer := events.NewSequencer(sourceDB.DB)
+	for i := 0; i < 5; i++ {
+		_, err := sequencer.Next("testing")
+		require.NoError(t, err)
+	}
+	_, err = lineage.NewRepository(sourceDB.DB).Reserve("trace:12345678", 100)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	manifest, err := Export(&buf, sourceDB.DB, []Shard{source}, Options{Contents: true})
+	require.NoError(t, err)
+	require.Len(t, manifest.Shards, 1)
+	require.Len(t, manifest.Shards[0].Pending, 2)
+	require.Len(t, manifest.Shards[0].Held, 1)
+	require.Equal(t, []Counter{{Key: "testing", Value: 5}}, manifest.Sequences)
+	require.Equal(t, []Counter{{Key: "trace:12345678", Value: 100}}, manifest.Counters)
+
+	// Import into an environment which already has some state
+	dest := testShard(t, "testing")
+	require.NoError(t, dest.Storage.WriteFile(filepath.Join("mergable", "testing", "a.ach"), []byte("kept")))
+
+	destDB := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { destDB.Close() })
+	_, err = destDB.DB.Exec(`insert into event_sequences (shard_key, sequence) values ('testing', 2);`)
+	require.NoError(t, err)
+
+	result, err := Import(bytes.NewReader(buf.Bytes()), destDB.DB, []Shard{dest})
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Files)
+	require.Equal(t, 1, result.ExistingFiles)
+	require.Equal(t, 1, result.Mappings)
+	require.Equal(t, 1, result.Sequences)
+	require.Equal(t, 1, result.Counters)
+	require.Empty(t, result.SkippedShards)
+
+	bs, err := readFile(dest.Storage, filepath.Join("mergable", "testing", "a.ach"))
+	require.NoError(t, err)
+	require.Equal(t, "kept", string(bs))
+	bs, err = readFile(dest.Storage, filepath.Join("held", "testing", "c.ach"))
+	require.NoError(t, err)
+	require.Equal(t, "held c", string(bs))
+
+	// Sequences keep increasing from the source environment
+	next, err := events.NewSequencer(destDB.DB).Next("testing")
+	require.NoError(t, err)
+	require.Equal(t, int64(6), next)
+
+	// Importing again changes nothing
+	result, err = Import(bytes.NewReader(buf.Bytes()), destDB.DB, []Shard{dest})
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Files)
+	require.Equal(t, 3, result.ExistingFiles)
+	require.Equal(t, 0, result.Sequences)
+}
+
+func TestSnapshot__Conflicts(t *testing.T) {
+	source := testShard(t, "testing")
+	require.NoError(t, source.Storage.WriteFile(filepath.Join("mergable", "testing", "a.ach"), []byte("pending a")))
+
+	sourceDB := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { sourceDB.Close() })
+	_, err := sourceDB.DB.Exec(`insert into shard_mappings (shard_key, shard_name) values ('key1', 'testing');`)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = Export(&buf, sourceDB.DB, []Shard{source}, Options{Contents: true})
+	require.NoError(t, err)
+
+	destDB := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { destDB.Close() })
+	_, err = destDB.DB.Exec(`insert into shard_mappings (shard_key, shard_name) values ('key1', 'other');`)
+	require.NoError(t, err)
+
+	// The shard isn't handled here and the mapping is left alone
+	result, err := Import(bytes.NewReader(buf.Bytes()), destDB.DB, []Shard{testShard(t, "other")})
+	require.NoError(t, err)
+	require.Equal(t, []string{"testing"}, result.SkippedShards)
+	require.Equal(t, 0, result.Mappings)
+	require.Len(t, result.Conflicts, 1)
+
+	// Without a database only files are imported
+	result, err = Import(bytes.NewReader(buf.Bytes()), nil, []Shard{testShard(t, "testing")})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Files)
+	require.True(t, result.SkippedDatabase)
+}
+
+func TestSnapshot__WithoutContents(t *testing.T) {
+	source := testShard(t, "testing")
+	require.NoError(t, source.Storage.WriteFile(filepath.Join("mergable", "testing", "a.ach"), []byte("pending a")))
+
+	var buf bytes.Buffer
+	manifest, err := Export(&buf, nil, []Shard{source}, Options{})
+	require.NoError(t, err)
+	require.Len(t, manifest.Shards[0].Pending, 1)
+	require.Equal(t, 9, manifest.Shards[0].Pending[0].Size)
+
+	_, contents, err := Read(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Empty(t, contents)
+
+	_, err = Import(bytes.NewReader(buf.Bytes()), nil, []Shard{testShard(t, "testing")})
+	require.ErrorContains(t, err, "doesn't include file contents")
+}
+
+func TestCheckFile(t *testing.T) {
+	contents := map[string][]byte{
+		filepath.Join("files", "mergable", "testing", "a.ach"): []byte("a"),
+	}
+	file := File{
+		Path:   filepath.Join("mergable", "testing", "a.ach"),
+		SHA256: "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb",
+	}
+	require.NoError(t, checkFile("testing", file, contents))
+	require.ErrorContains(t, checkFile("other", file, contents), "outside of shard")
+
+	file.SHA256 = "00"
+	require.ErrorContains(t, checkFile("testing", file, contents), "checksum mismatch")
+
+	file.Path = filepath.Join("mergable", "..", "..", "etc", "passwd")
+	require.ErrorContains(t, checkFile("testing", file, contents), "invalid path")
+}
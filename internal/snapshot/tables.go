@@ -0,0 +1,148 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snapshot
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/database"
+)
+
+// counterTables hold values which must never go backwards, keyed by their first column
+var counterTables = []struct {
+	table, key, value string
+}{
+	{table: "event_sequences", key: "shard_key", value: "sequence"},
+	{table: "assignment_counters", key: "counter_key", value: "counter_value"},
+}
+
+// exportTables reads every table in one transaction so they're consistent with each other
+func exportTables(db *sql.DB, manifest *Manifest) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("exporting tables: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`select shard_key, shard_name from shard_mappings order by shard_key;`)
+	if err != nil {
+		return fmt.Errorf("exporting shard_mappings: %v", err)
+	}
+	defer rows.Close()
+
+	manifest.Mappings = []service.ShardMapping{}
+	for rows.Next() {
+		var mapping service.ShardMapping
+		if err := rows.Scan(&mapping.ShardKey, &mapping.ShardName); err != nil {
+			return fmt.Errorf("exporting shard_mappings: %v", err)
+		}
+		manifest.Mappings = append(manifest.Mappings, mapping)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("exporting shard_mappings: %v", err)
+	}
+
+	manifest.Sequences, err = exportCounters(tx, counterTables[0].table, counterTables[0].key, counterTables[0].value)
+	if err != nil {
+		return err
+	}
+	manifest.Counters, err = exportCounters(tx, counterTables[1].table, counterTables[1].key, counterTables[1].value)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func exportCounters(tx *sql.Tx, table, key, value string) ([]Counter, error) {
+	//nolint:gosec
+	rows, err := tx.Query(fmt.Sprintf(`select %s, %s from %s order by %s;`, key, value, table, key))
+	if err != nil {
+		return nil, fmt.Errorf("exporting %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	out := []Counter{}
+	for rows.Next() {
+		var counter Counter
+		if err := rows.Scan(&counter.Key, &counter.Value); err != nil {
+			return nil, fmt.Errorf("exporting %s: %v", table, err)
+		}
+		out = append(out, counter)
+	}
+	return out, rows.Err()
+}
+
+// importMapping adds mapping unless its shard key is already mapped. A shard key mapped to a
+// different shard is returned as a conflict and left alone.
+func importMapping(db *sql.DB, mapping service.ShardMapping) (bool, string, error) {
+	var existing string
+	err := db.QueryRow(`select shard_name from shard_mappings where shard_key = ? limit 1;`, mapping.ShardKey).Scan(&existing)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = db.Exec(`insert into shard_mappings (shard_key, shard_name) values (?, ?);`, mapping.ShardKey, mapping.ShardName)
+		if err != nil {
+			if database.UniqueViolation(err) {
+				return importMapping(db, mapping)
+			}
+			return false, "", fmt.Errorf("importing shard mapping %s: %v", mapping.ShardKey, err)
+		}
+		return true, "", nil
+
+	case err != nil:
+		return false, "", fmt.Errorf("importing shard mapping %s: %v", mapping.ShardKey, err)
+
+	case existing != mapping.ShardName:
+		return false, fmt.Sprintf("shardKey %s is mapped to %s instead of %s", mapping.ShardKey, existing, mapping.ShardName), nil
+	}
+	return false, "", nil
+}
+
+// importCounter raises the counter to counter.Value when it's lower, so sequences and assigned
+// trace numbers keep increasing from where the source environment left off.
+func importCounter(db *sql.DB, table, key, value string, counter Counter) (bool, error) {
+	//nolint:gosec
+	res, err := db.Exec(fmt.Sprintf(`update %s set %s = ? where %s = ? and %s < ?;`, table, value, key, value), counter.Value, counter.Key, counter.Value)
+	if err != nil {
+		return false, fmt.Errorf("importing %s %s: %v", table, counter.Key, err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return true, nil
+	}
+
+	var current int64
+	//nolint:gosec
+	err = db.QueryRow(fmt.Sprintf(`select %s from %s where %s = ? limit 1;`, value, table, key), counter.Key).Scan(&current)
+	if err == nil {
+		return false, nil // already at or past counter.Value
+	}
+	if err != sql.ErrNoRows {
+		return false, fmt.Errorf("importing %s %s: %v", table, counter.Key, err)
+	}
+
+	//nolint:gosec
+	_, err = db.Exec(fmt.Sprintf(`insert into %s (%s, %s) values (?, ?);`, table, key, value), counter.Key, counter.Value)
+	if err != nil {
+		if database.UniqueViolation(err) {
+			return importCounter(db, table, key, value, counter)
+		}
+		return false, fmt.Errorf("importing %s %s: %v", table, counter.Key, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package sshx
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Algorithms which golang.org/x/crypto/ssh implements for clients, including legacy ones it
+// doesn't enable by default.
+var (
+	SupportedCiphers = []string{
+		"aes128-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+		"aes128-cbc", "3des-cbc",
+		"arcfour256", "arcfour128", "arcfour",
+	}
+	SupportedKeyExchanges = []string{
+		"curve25519-sha256", "curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group-exchange-sha256", "diffie-hellman-group-exchange-sha1",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group14-sha1", "diffie-hellman-group1-sha1",
+	}
+	SupportedMACs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-256", "hmac-sha1", "hmac-sha1-96",
+	}
+	SupportedHostKeyAlgorithms = []string{
+		ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01, ssh.CertAlgoDSAv01,
+		ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01, ssh.CertAlgoED25519v01,
+		ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+		ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA, ssh.KeyAlgoDSA,
+		ssh.KeyAlgoED25519,
+	}
+)
+
+// CheckAlgorithms returns an error for the first of names which isn't in supported. kind names
+// the algorithms in the error, like "cipher".
+func CheckAlgorithms(kind string, names, supported []string) error {
+	for _, name := range names {
+		if !contains(supported, name) {
+			return fmt.Errorf("unsupported %s %q", kind, name)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for i := range values {
+		if values[i] == v {
+			return true
+		}
+	}
+	return false
+}
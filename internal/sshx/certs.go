@@ -0,0 +1,187 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package sshx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Authority is an SSH certificate authority trusted to sign host keys. Hosts limits which
+// hostnames the authority signs for, an empty list trusts it for every host.
+type Authority struct {
+	Key   ssh.PublicKey
+	Hosts []string
+}
+
+// Matches reports if the authority signs host keys for hostname. Hosts are patterns as used in
+// OpenSSH's known_hosts file where * and ? are wildcards and a leading ! excludes matching hosts.
+func (a Authority) Matches(hostname string) bool {
+	if len(a.Hosts) == 0 {
+		return true
+	}
+	matched := false
+	for _, pattern := range a.Hosts {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		if matchPattern(pattern, hostname) {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+func matchPattern(pattern, hostname string) bool {
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(hostname))
+	return ok
+}
+
+// ReadAuthorities parses certificate authority public keys, one per line. Lines are either a
+// public key or a known_hosts "@cert-authority <hosts> <key>" line. data may be base64 encoded.
+func ReadAuthorities(data []byte) ([]Authority, error) {
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data))); len(decoded) > 0 && err == nil {
+		data = decoded
+	}
+
+	var out []Authority
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "@") {
+			marker, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+			if err != nil {
+				return nil, fmt.Errorf("reading certificate authority: %v", err)
+			}
+			if marker != "cert-authority" {
+				return nil, fmt.Errorf("unexpected @%s marker for certificate authority", marker)
+			}
+			out = append(out, Authority{Key: key, Hosts: hosts})
+			continue
+		}
+		key, err := ReadPubKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("reading certificate authority: %v", err)
+		}
+		out = append(out, Authority{Key: key})
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no certificate authorities found")
+	}
+	return out, nil
+}
+
+// ReadCertificate parses an OpenSSH certificate, like the contents of id_ed25519-cert.pub
+func ReadCertificate(raw string) (*ssh.Certificate, error) {
+	key, err := ReadPubKey([]byte(strings.TrimSpace(raw)))
+	if err != nil {
+		return nil, err
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s key is not a certificate", key.Type())
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, errors.New("certificate is not a user certificate")
+	}
+	return cert, nil
+}
+
+// CertificateValid returns an error when now is outside of cert's validity period. Servers only
+// reply "unable to authenticate" to an expired certificate, so this is checked before dialing.
+func CertificateValid(cert *ssh.Certificate, now time.Time) error {
+	if cert == nil {
+		return errors.New("nil certificate")
+	}
+	unix := uint64(now.Unix())
+	if unix < cert.ValidAfter {
+		return fmt.Errorf("certificate is not valid until %v", time.Unix(int64(cert.ValidAfter), 0).UTC().Format(time.RFC3339))
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity && unix >= cert.ValidBefore {
+		return fmt.Errorf("certificate expired at %v", CertificateExpiry(cert).Format(time.RFC3339))
+	}
+	return nil
+}
+
+// CertificateExpiry returns when cert stops being valid, which is the zero time for certificates
+// that never expire.
+func CertificateExpiry(cert *ssh.Certificate) time.Time {
+	if cert == nil || cert.ValidBefore > math.MaxInt64 {
+		return time.Time{}
+	}
+	return time.Unix(int64(cert.ValidBefore), 0).UTC()
+}
+
+// CertHostKeyCallback returns an ssh.HostKeyCallback accepting host certificates which are signed
+// by one of authorities for the hostname being dialed and valid right now. Plain host keys are
+// passed to fallback, or rejected when fallback is nil.
+func CertHostKeyCallback(authorities []Authority, fallback ssh.HostKeyCallback) ssh.HostKeyCallback {
+	checker := &ssh.CertChecker{
+		IsHostAuthority: func(auth ssh.PublicKey, address string) bool {
+			hostname, _, err := net.SplitHostPort(address)
+			if err != nil {
+				hostname = address
+			}
+			for _, authority := range authorities {
+				if bytes.Equal(authority.Key.Marshal(), auth.Marshal()) && authority.Matches(hostname) {
+					return true
+				}
+			}
+			return false
+		},
+		HostKeyFallback: fallback,
+	}
+	return checker.CheckHostKey
+}
+
+var certAlgorithms = map[string]string{
+	ssh.KeyAlgoRSASHA512: ssh.CertAlgoRSASHA512v01,
+	ssh.KeyAlgoRSASHA256: ssh.CertAlgoRSASHA256v01,
+	ssh.KeyAlgoRSA:       ssh.CertAlgoRSAv01,
+	ssh.KeyAlgoECDSA256:  ssh.CertAlgoECDSA256v01,
+	ssh.KeyAlgoECDSA384:  ssh.CertAlgoECDSA384v01,
+	ssh.KeyAlgoECDSA521:  ssh.CertAlgoECDSA521v01,
+	ssh.KeyAlgoED25519:   ssh.CertAlgoED25519v01,
+}
+
+// PreferCertAlgorithms puts the certificate form of each host key algorithm ahead of the plain
+// algorithms so servers present their host certificate. An empty list is left to ssh's defaults,
+// which already prefer certificates.
+func PreferCertAlgorithms(algorithms []string) []string {
+	if len(algorithms) == 0 {
+		return algorithms
+	}
+	var out []string
+	for _, algo := range algorithms {
+		if cert, ok := certAlgorithms[algo]; ok {
+			out = append(out, cert)
+		}
+	}
+	return append(out, algorithms...)
+}
+
+// CertSigner returns a signer presenting cert when authenticating with signer's private key
+func CertSigner(cert *ssh.Certificate, signer ssh.Signer) (ssh.Signer, error) {
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("client certificate doesn't match the private key: %v", err)
+	}
+	return certSigner, nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package sshx
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func testSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+	return signer
+}
+
+func testCert(t *testing.T, ca ssh.Signer, key ssh.PublicKey, certType uint32, principals []string, validBefore time.Time) *ssh.Certificate {
+	t.Helper()
+
+	cert := &ssh.Certificate{
+		Key:             key,
+		Serial:          1,
+		CertType:        certType,
+		KeyId:           "testing",
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(time.Now().Add(-time.Hour).Unix()),
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	require.NoError(t, cert.SignCert(rand.Reader, ca))
+	return cert
+}
+
+func TestReadAuthorities(t *testing.T) {
+	ca1, ca2 := testSigner(t), testSigner(t)
+
+	data := fmt.Sprintf("# bank CAs\n%s\n@cert-authority *.bank.com,!old.bank.com %s",
+		ssh.MarshalAuthorizedKey(ca1.PublicKey()), ssh.MarshalAuthorizedKey(ca2.PublicKey()))
+
+	for _, raw := range []string{data, base64.StdEncoding.EncodeToString([]byte(data))} {
+		authorities, err := ReadAuthorities([]byte(raw))
+		require.NoError(t, err)
+		require.Len(t, authorities, 2)
+
+		require.Equal(t, ca1.PublicKey().Marshal(), authorities[0].Key.Marshal())
+		require.True(t, authorities[0].Matches("sftp.example.com"))
+
+		require.Equal(t, []string{"*.bank.com", "!old.bank.com"}, authorities[1].Hosts)
+		require.True(t, authorities[1].Matches("SFTP.bank.com"))
+		require.False(t, authorities[1].Matches("old.bank.com"))
+		require.False(t, authorities[1].Matches("sftp.example.com"))
+	}
+
+	_, err := ReadAuthorities([]byte("# nothing here\n"))
+	require.ErrorContains(t, err, "no certificate authorities")
+
+	_, err = ReadAuthorities([]byte("@revoked * " + string(ssh.MarshalAuthorizedKey(ca1.PublicKey()))))
+	require.ErrorContains(t, err, "unexpected @revoked")
+}
+
+func TestCertHostKeyCallback(t *testing.T) {
+	ca, other := testSigner(t), testSigner(t)
+	hostKey := testSigner(t).PublicKey()
+
+	authorities := []Authority{{Key: ca.PublicKey(), Hosts: []string{"*.bank.com"}}}
+	callback := CertHostKeyCallback(authorities, nil)
+
+	valid := testCert(t, ca, hostKey, ssh.HostCert, []string{"sftp.bank.com"}, time.Now().Add(time.Hour))
+	require.NoError(t, callback("sftp.bank.com:22", nil, valid))
+
+	// Certificates are only trusted for the hosts the CA signs for and the cert's principals
+	require.ErrorContains(t, callback("sftp.example.com:22", nil, valid), "no authorities")
+	wrongPrincipal := testCert(t, ca, hostKey, ssh.HostCert, []string{"other.bank.com"}, time.Now().Add(time.Hour))
+	require.ErrorContains(t, callback("sftp.bank.com:22", nil, wrongPrincipal), "principal")
+
+	expired := testCert(t, ca, hostKey, ssh.HostCert, []string{"sftp.bank.com"}, time.Now().Add(-time.Minute))
+	require.ErrorContains(t, callback("sftp.bank.com:22", nil, expired), "expired")
+
+	untrusted := testCert(t, other, hostKey, ssh.HostCert, []string{"sftp.bank.com"}, time.Now().Add(time.Hour))
+	require.Error(t, callback("sftp.bank.com:22", nil, untrusted))
+
+	userCert := testCert(t, ca, hostKey, ssh.UserCert, []string{"sftp.bank.com"}, time.Now().Add(time.Hour))
+	require.Error(t, callback("sftp.bank.com:22", nil, userCert))
+
+	// Plain keys need a fallback
+	require.ErrorContains(t, callback("sftp.bank.com:22", nil, hostKey), "non-certificate")
+	callback = CertHostKeyCallback(authorities, ssh.FixedHostKey(hostKey))
+	require.NoError(t, callback("sftp.bank.com:22", nil, hostKey))
+}
+
+func TestReadCertificate(t *testing.T) {
+	ca, client := testSigner(t), testSigner(t)
+
+	cert := testCert(t, ca, client.PublicKey(), ssh.UserCert, []string{"achgateway"}, time.Now().Add(time.Hour))
+	raw := string(ssh.MarshalAuthorizedKey(cert))
+
+	parsed, err := ReadCertificate(raw)
+	require.NoError(t, err)
+	require.Equal(t, []string{"achgateway"}, parsed.ValidPrincipals)
+
+	signer, err := CertSigner(parsed, client)
+	require.NoError(t, err)
+	require.Equal(t, cert.Marshal(), signer.PublicKey().Marshal())
+
+	_, err = CertSigner(parsed, testSigner(t))
+	require.ErrorContains(t, err, "doesn't match")
+
+	_, err = ReadCertificate(string(ssh.MarshalAuthorizedKey(client.PublicKey())))
+	require.ErrorContains(t, err, "not a certificate")
+
+	hostCert := testCert(t, ca, client.PublicKey(), ssh.HostCert, nil, time.Now().Add(time.Hour))
+	_, err = ReadCertificate(string(ssh.MarshalAuthorizedKey(hostCert)))
+	require.ErrorContains(t, err, "not a user certificate")
+}
+
+func TestCertificateValid(t *testing.T) {
+	ca, user := testSigner(t), testSigner(t)
+	now := time.Now()
+
+	cert := testCert(t, ca, user.PublicKey(), ssh.UserCert, []string{"achgateway"}, now.Add(time.Hour))
+	require.NoError(t, CertificateValid(cert, now))
+	require.Equal(t, now.Add(time.Hour).Unix(), CertificateExpiry(cert).Unix())
+
+	err := CertificateValid(cert, now.Add(2*time.Hour))
+	require.ErrorContains(t, err, "certificate expired at")
+
+	err = CertificateValid(cert, now.Add(-2*time.Hour))
+	require.ErrorContains(t, err, "certificate is not valid until")
+
+	cert.ValidBefore = ssh.CertTimeInfinity
+	require.NoError(t, CertificateValid(cert, now.Add(24*365*time.Hour)))
+	require.True(t, CertificateExpiry(cert).IsZero())
+}
+
+func TestPreferCertAlgorithms(t *testing.T) {
+	require.Empty(t, PreferCertAlgorithms(nil))
+	require.Equal(t, []string{
+		ssh.CertAlgoECDSA256v01, ssh.CertAlgoRSASHA256v01, ssh.KeyAlgoECDSA256, ssh.KeyAlgoRSASHA256,
+	}, PreferCertAlgorithms([]string{ssh.KeyAlgoECDSA256, ssh.KeyAlgoRSASHA256}))
+}
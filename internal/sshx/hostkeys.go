@@ -0,0 +1,183 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package sshx
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// HostKey is a plain host key accepted for servers. Hosts are known_hosts patterns limiting which
+// servers may present the key, an empty list accepts it from every server.
+type HostKey struct {
+	Key   ssh.PublicKey
+	Hosts []string
+}
+
+// KnownHosts are the host keys and certificate authorities trusted for servers, along with keys
+// which were revoked and are never accepted.
+type KnownHosts struct {
+	Keys        []HostKey
+	Authorities []Authority
+	Revoked     []ssh.PublicKey
+}
+
+// ReadKnownHosts parses an OpenSSH known_hosts file. Host patterns with wildcards, negation,
+// non-standard ports ([host]:port) and hashed hostnames are supported along with the
+// @cert-authority and @revoked markers.
+func ReadKnownHosts(data []byte) (*KnownHosts, error) {
+	out := &KnownHosts{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		marker, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(text))
+		if err != nil {
+			return nil, fmt.Errorf("known_hosts line %d: %v", line, err)
+		}
+		switch marker {
+		case "":
+			out.Keys = append(out.Keys, HostKey{Key: key, Hosts: hosts})
+		case "cert-authority":
+			out.Authorities = append(out.Authorities, Authority{Key: key, Hosts: hosts})
+		case "revoked":
+			out.Revoked = append(out.Revoked, key)
+		default:
+			return nil, fmt.Errorf("known_hosts line %d: unknown @%s marker", line, marker)
+		}
+	}
+	return out, nil
+}
+
+// Merge adds the keys, authorities and revocations of other
+func (kh *KnownHosts) Merge(other *KnownHosts) {
+	if other == nil {
+		return
+	}
+	kh.Keys = append(kh.Keys, other.Keys...)
+	kh.Authorities = append(kh.Authorities, other.Authorities...)
+	kh.Revoked = append(kh.Revoked, other.Revoked...)
+}
+
+// HostKeyCallback returns an ssh.HostKeyCallback accepting any of the plain keys listed for the
+// server being dialed, so a server can rotate its key while both keys are listed. Host certificates
+// are checked against Authorities like CertHostKeyCallback. Revoked keys, and certificates for or
+// signed by one, are rejected.
+func (kh *KnownHosts) HostKeyCallback() ssh.HostKeyCallback {
+	plain := func(address string, remote net.Addr, key ssh.PublicKey) error {
+		hostname, port := splitAddress(address)
+		for i := range kh.Keys {
+			if bytes.Equal(kh.Keys[i].Key.Marshal(), key.Marshal()) && matchHosts(kh.Keys[i].Hosts, hostname, port) {
+				return nil
+			}
+		}
+		return fmt.Errorf("ssh: host key %s %s is not one of the %d known host keys", key.Type(), ssh.FingerprintSHA256(key), kh.keysFor(hostname, port))
+	}
+	callback := plain
+	if len(kh.Authorities) > 0 {
+		callback = CertHostKeyCallback(kh.Authorities, plain)
+	}
+	return func(address string, remote net.Addr, key ssh.PublicKey) error {
+		if kh.revoked(key) {
+			return fmt.Errorf("ssh: host key %s %s is revoked", key.Type(), ssh.FingerprintSHA256(key))
+		}
+		if cert, ok := key.(*ssh.Certificate); ok && (kh.revoked(cert.Key) || kh.revoked(cert.SignatureKey)) {
+			return fmt.Errorf("ssh: host certificate %s is revoked", ssh.FingerprintSHA256(cert.Key))
+		}
+		return callback(address, remote, key)
+	}
+}
+
+func (kh *KnownHosts) keysFor(hostname, port string) int {
+	var n int
+	for i := range kh.Keys {
+		if matchHosts(kh.Keys[i].Hosts, hostname, port) {
+			n++
+		}
+	}
+	return n
+}
+
+func (kh *KnownHosts) revoked(key ssh.PublicKey) bool {
+	for i := range kh.Revoked {
+		if bytes.Equal(kh.Revoked[i].Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitAddress(address string) (string, string) {
+	hostname, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, ""
+	}
+	return hostname, port
+}
+
+// matchHosts reports if the server at hostname and port matches patterns as OpenSSH would. An
+// empty list matches every server.
+func matchHosts(patterns []string, hostname, port string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	// known_hosts only lists the port when it's not the default
+	name := hostname
+	if port != "" && port != "22" {
+		name = fmt.Sprintf("[%s]:%s", hostname, port)
+	}
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+
+		var ok bool
+		switch {
+		case strings.HasPrefix(pattern, "|1|"):
+			ok = matchHashed(pattern, name)
+		case strings.HasPrefix(pattern, "["):
+			// Ports are matched literally as path.Match would read the brackets as a character class
+			ok = strings.EqualFold(pattern, name)
+		default:
+			ok = name == hostname && matchPattern(pattern, hostname)
+		}
+		if ok {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchHashed compares name against a hashed known_hosts entry, |1|<salt>|<hmac-sha1>
+func matchHashed(pattern, name string) bool {
+	parts := strings.Split(pattern, "|")
+	if len(parts) != 4 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(name))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
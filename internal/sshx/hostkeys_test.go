@@ -0,0 +1,91 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package sshx
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func authorizedKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+func TestReadKnownHosts(t *testing.T) {
+	oldKey, newKey := testSigner(t).PublicKey(), testSigner(t).PublicKey()
+	ca, revoked := testSigner(t).PublicKey(), testSigner(t).PublicKey()
+
+	data := strings.Join([]string{
+		"# sftp.bank.com is rotating its host key",
+		"sftp.bank.com " + authorizedKey(oldKey),
+		knownhosts.HashHostname("sftp.bank.com") + " " + authorizedKey(newKey),
+		"@cert-authority *.bank.com " + authorizedKey(ca),
+		"@revoked * " + authorizedKey(revoked),
+	}, "\n")
+	kh, err := ReadKnownHosts([]byte(data))
+	require.NoError(t, err)
+	require.Len(t, kh.Keys, 2)
+	require.Len(t, kh.Authorities, 1)
+	require.Len(t, kh.Revoked, 1)
+
+	_, err = ReadKnownHosts([]byte("sftp.bank.com ssh-ed25519 not-a-key"))
+	require.ErrorContains(t, err, "known_hosts line 1")
+}
+
+func TestKnownHosts__HostKeyCallback(t *testing.T) {
+	oldKey, newKey, other := testSigner(t).PublicKey(), testSigner(t).PublicKey(), testSigner(t).PublicKey()
+
+	kh := &KnownHosts{
+		Keys: []HostKey{
+			{Key: oldKey, Hosts: []string{"sftp.bank.com"}},
+			{Key: newKey, Hosts: []string{knownhosts.HashHostname("sftp.bank.com")}},
+			{Key: other, Hosts: []string{"[sftp.bank.com]:2222", "*.example.com", "!old.example.com"}},
+		},
+	}
+	callback := kh.HostKeyCallback()
+
+	// Both keys are accepted during a rotation
+	require.NoError(t, callback("sftp.bank.com:22", nil, oldKey))
+	require.NoError(t, callback("sftp.bank.com:22", nil, newKey))
+	require.ErrorContains(t, callback("sftp.bank.com:22", nil, other), "is not one of the 2 known host keys")
+
+	// Keys are only accepted from the hosts they're listed for
+	require.NoError(t, callback("sftp.bank.com:2222", nil, other))
+	require.Error(t, callback("sftp.bank.com:2222", nil, oldKey))
+	require.NoError(t, callback("sftp.example.com:22", nil, other))
+	require.Error(t, callback("old.example.com:22", nil, other))
+
+	// Keys without hosts are accepted from every server
+	kh = &KnownHosts{Keys: []HostKey{{Key: oldKey}, {Key: newKey}}}
+	require.NoError(t, kh.HostKeyCallback()("10.0.0.1:22", nil, newKey))
+
+	// Revoked keys are rejected even when listed
+	kh.Revoked = []ssh.PublicKey{oldKey}
+	require.ErrorContains(t, kh.HostKeyCallback()("sftp.bank.com:22", nil, oldKey), "is revoked")
+}
+
+func TestKnownHosts__Certificates(t *testing.T) {
+	ca := testSigner(t)
+	hostKey := testSigner(t).PublicKey()
+	cert := testCert(t, ca, hostKey, ssh.HostCert, []string{"sftp.bank.com"}, time.Now().Add(time.Hour))
+
+	data := fmt.Sprintf("@cert-authority *.bank.com %s", authorizedKey(ca.PublicKey()))
+	kh, err := ReadKnownHosts([]byte(data))
+	require.NoError(t, err)
+
+	callback := kh.HostKeyCallback()
+	require.NoError(t, callback("sftp.bank.com:22", nil, cert))
+	require.Error(t, callback("sftp.bank.com:22", nil, hostKey))
+
+	// Revoking the host's key rejects its certificate
+	kh.Revoked = []ssh.PublicKey{hostKey}
+	require.ErrorContains(t, kh.HostKeyCallback()("sftp.bank.com:22", nil, cert), "revoked")
+}
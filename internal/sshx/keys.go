@@ -34,3 +34,11 @@ func ReadPubKey(data []byte) (ssh.PublicKey, error) {
 	}
 	return ssh.ParsePublicKey(data)
 }
+
+func ReadSigner(raw string) (ssh.Signer, error) {
+	decoded, err := base64.StdEncoding.DecodeString(raw)
+	if len(decoded) > 0 && err == nil {
+		return ssh.ParsePrivateKey(decoded)
+	}
+	return ssh.ParsePrivateKey([]byte(raw))
+}
@@ -0,0 +1,229 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package staging
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/achgateway/pkg/models"
+)
+
+type Repository interface {
+	// Save stages file, replacing an uncommitted file with the same ID. false is returned when the
+	// file was already committed.
+	Save(file *StagedFile) (bool, error)
+
+	// Get returns the staged file, or nil
+	Get(shardKey, fileID string) (*StagedFile, error)
+
+	// Commit marks the file committed before it's published, dropping its contents. false is
+	// returned when it's already committed or expired, and the file shouldn't be published.
+	Commit(shardKey, fileID string) (bool, error)
+
+	// Uncommit restores a committed file's contents after it couldn't be published
+	Uncommit(shardKey, fileID string, contents []byte) error
+
+	// Delete removes an uncommitted file, returning false when there was none
+	Delete(shardKey, fileID string) (bool, error)
+
+	// DeleteExpired removes uncommitted files which expired
+	DeleteExpired() (int64, error)
+
+	// RedactContents calls redact with the "<shardKey>/<fileID>" and contents of each uncommitted
+	// file and saves the contents it returns when changed, for erasure requests
+	RedactContents(redact func(key string, contents []byte) ([]byte, bool)) error
+}
+
+// NewRepository returns a Repository stored in db, or nil when db is nil
+func NewRepository(db *sql.DB) Repository {
+	if db == nil {
+		return nil
+	}
+	return &sqlRepository{db: db, now: time.Now}
+}
+
+type sqlRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// summary is stored as JSON in the summary column
+type summary struct {
+	EntryCount int                  `json:"entryCount"`
+	Batches    []models.BatchTotals `json:"batches"`
+}
+
+func (r *sqlRepository) Save(file *StagedFile) (bool, error) {
+	totals, err := json.Marshal(summary{EntryCount: file.EntryCount, Batches: file.Batches})
+	if err != nil {
+		return false, fmt.Errorf("encoding staged file %s: %v", file.FileID, err)
+	}
+	metadata, err := json.Marshal(file.Metadata)
+	if err != nil {
+		return false, fmt.Errorf("encoding staged file %s metadata: %v", file.FileID, err)
+	}
+	file.CreatedAt = r.timestamp()
+	file.ExpiresAt = file.ExpiresAt.UTC().Truncate(time.Second)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("staging file %s: %v", file.FileID, err)
+	}
+	defer tx.Rollback()
+
+	var committed sql.NullTime
+	query := `select committed_at from staged_files where shard_key = ? and file_id = ? limit 1;`
+	err = tx.QueryRow(query, file.ShardKey, file.FileID).Scan(&committed)
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("staging file %s: %v", file.FileID, err)
+	}
+	if committed.Valid {
+		return false, nil
+	}
+	if _, err := tx.Exec(`delete from staged_files where shard_key = ? and file_id = ?;`, file.ShardKey, file.FileID); err != nil {
+		return false, fmt.Errorf("staging file %s: %v", file.FileID, err)
+	}
+	query = `insert into staged_files (shard_key, file_id, contents, summary, metadata, created_at, expires_at) values (?, ?, ?, ?, ?, ?, ?);`
+	_, err = tx.Exec(query, file.ShardKey, file.FileID, string(file.Contents), string(totals), string(metadata), file.CreatedAt, file.ExpiresAt)
+	if err != nil {
+		return false, fmt.Errorf("staging file %s: %v", file.FileID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("staging file %s: %v", file.FileID, err)
+	}
+	file.Status = StatusStaged
+	return true, nil
+}
+
+func (r *sqlRepository) Get(shardKey, fileID string) (*StagedFile, error) {
+	query := `select contents, summary, metadata, created_at, expires_at, committed_at from staged_files where shard_key = ? and file_id = ? limit 1;`
+
+	file := &StagedFile{ShardKey: shardKey, FileID: fileID}
+	var contents, totals, metadata string
+	var committed sql.NullTime
+	err := r.db.QueryRow(query, shardKey, fileID).Scan(&contents, &totals, &metadata, &file.CreatedAt, &file.ExpiresAt, &committed)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading staged file %s: %v", fileID, err)
+	}
+	file.Contents = []byte(contents)
+
+	var sum summary
+	if err := json.Unmarshal([]byte(totals), &sum); err != nil {
+		return nil, fmt.Errorf("reading staged file %s: %v", fileID, err)
+	}
+	file.EntryCount, file.Batches = sum.EntryCount, sum.Batches
+	if err := json.Unmarshal([]byte(metadata), &file.Metadata); err != nil {
+		return nil, fmt.Errorf("reading staged file %s metadata: %v", fileID, err)
+	}
+	if committed.Valid {
+		file.CommittedAt = &committed.Time
+	}
+	file.Status = file.status(r.now())
+	return file, nil
+}
+
+func (r *sqlRepository) Commit(shardKey, fileID string) (bool, error) {
+	now := r.timestamp()
+	query := `update staged_files set committed_at = ?, contents = '' where shard_key = ? and file_id = ? and committed_at is null and expires_at >= ?;`
+	res, err := r.db.Exec(query, now, shardKey, fileID, now)
+	if err != nil {
+		return false, fmt.Errorf("committing staged file %s: %v", fileID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("committing staged file %s: %v", fileID, err)
+	}
+	return n > 0, nil
+}
+
+func (r *sqlRepository) Uncommit(shardKey, fileID string, contents []byte) error {
+	query := `update staged_files set committed_at = null, contents = ? where shard_key = ? and file_id = ?;`
+	if _, err := r.db.Exec(query, string(contents), shardKey, fileID); err != nil {
+		return fmt.Errorf("uncommitting staged file %s: %v", fileID, err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) Delete(shardKey, fileID string) (bool, error) {
+	query := `delete from staged_files where shard_key = ? and file_id = ? and committed_at is null;`
+	res, err := r.db.Exec(query, shardKey, fileID)
+	if err != nil {
+		return false, fmt.Errorf("deleting staged file %s: %v", fileID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("deleting staged file %s: %v", fileID, err)
+	}
+	return n > 0, nil
+}
+
+func (r *sqlRepository) DeleteExpired() (int64, error) {
+	query := `delete from staged_files where committed_at is null and expires_at < ?;`
+	res, err := r.db.Exec(query, r.timestamp())
+	if err != nil {
+		return 0, fmt.Errorf("deleting expired staged files: %v", err)
+	}
+	return res.RowsAffected()
+}
+
+func (r *sqlRepository) RedactContents(redact func(key string, contents []byte) ([]byte, bool)) error {
+	type staged struct {
+		shardKey, fileID, contents string
+	}
+	rows, err := r.db.Query(`select shard_key, file_id, contents from staged_files where committed_at is null;`)
+	if err != nil {
+		return fmt.Errorf("reading staged files: %v", err)
+	}
+	var files []staged
+	for rows.Next() {
+		var f staged
+		if err := rows.Scan(&f.shardKey, &f.fileID, &f.contents); err != nil {
+			rows.Close()
+			return fmt.Errorf("reading staged files: %v", err)
+		}
+		files = append(files, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading staged files: %v", err)
+	}
+
+	// Files are updated once they've all been read so SQLite isn't written while reading
+	for _, f := range files {
+		redacted, changed := redact(f.shardKey+"/"+f.fileID, []byte(f.contents))
+		if !changed {
+			continue
+		}
+		query := `update staged_files set contents = ? where shard_key = ? and file_id = ? and committed_at is null;`
+		if _, err := r.db.Exec(query, string(redacted), f.shardKey, f.fileID); err != nil {
+			return fmt.Errorf("redacting staged file %s: %v", f.fileID, err)
+		}
+	}
+	return nil
+}
+
+// timestamp is the current time in UTC with the precision DATETIME columns store
+func (r *sqlRepository) timestamp() time.Time {
+	return r.now().UTC().Truncate(time.Second)
+}
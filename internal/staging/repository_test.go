@@ -0,0 +1,177 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package staging
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	repo := NewRepository(db.DB).(*sqlRepository)
+	now := time.Date(2022, time.October, 14, 10, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	staged, err := New("s1", "f1", file, map[string]string{"batch": "payroll"}, now.Add(time.Hour))
+	require.NoError(t, err)
+	saved, err := repo.Save(staged)
+	require.NoError(t, err)
+	require.True(t, saved)
+
+	found, err := repo.Get("s1", "f1")
+	require.NoError(t, err)
+	require.Equal(t, StatusStaged, found.Status)
+	require.Equal(t, 1, found.EntryCount)
+	require.Equal(t, staged.Batches, found.Batches)
+	require.Equal(t, "payroll", found.Metadata["batch"])
+
+	contents, err := found.File()
+	require.NoError(t, err)
+	require.Equal(t, file.Batches[0].GetEntries()[0].Amount, contents.Batches[0].GetEntries()[0].Amount)
+
+	missing, err := repo.Get("s2", "f1")
+	require.NoError(t, err)
+	require.Nil(t, missing)
+
+	// Files can be staged again until they're committed
+	saved, err = repo.Save(staged)
+	require.NoError(t, err)
+	require.True(t, saved)
+
+	committed, err := repo.Commit("s1", "f1")
+	require.NoError(t, err)
+	require.True(t, committed)
+
+	committed, err = repo.Commit("s1", "f1")
+	require.NoError(t, err)
+	require.False(t, committed)
+
+	found, err = repo.Get("s1", "f1")
+	require.NoError(t, err)
+	require.Equal(t, StatusCommitted, found.Status)
+	require.Equal(t, now, *found.CommittedAt)
+	require.Empty(t, found.Contents)
+
+	// Files which couldn't be published are staged again
+	require.NoError(t, repo.Uncommit("s1", "f1", staged.Contents))
+	found, err = repo.Get("s1", "f1")
+	require.NoError(t, err)
+	require.Equal(t, StatusStaged, found.Status)
+	require.Equal(t, staged.Contents, found.Contents)
+
+	committed, err = repo.Commit("s1", "f1")
+	require.NoError(t, err)
+	require.True(t, committed)
+
+	saved, err = repo.Save(staged)
+	require.NoError(t, err)
+	require.False(t, saved)
+
+	deleted, err := repo.Delete("s1", "f1")
+	require.NoError(t, err)
+	require.False(t, deleted)
+}
+
+func TestRepository__Expiration(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	repo := NewRepository(db.DB).(*sqlRepository)
+	now := time.Date(2022, time.October, 14, 10, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	for _, fileID := range []string{"f1", "f2"} {
+		staged, err := New("s1", fileID, file, nil, now.Add(time.Hour))
+		require.NoError(t, err)
+		_, err = repo.Save(staged)
+		require.NoError(t, err)
+	}
+	deleted, err := repo.Delete("s1", "f2")
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	now = now.Add(2 * time.Hour)
+	found, err := repo.Get("s1", "f1")
+	require.NoError(t, err)
+	require.Equal(t, StatusExpired, found.Status)
+
+	committed, err := repo.Commit("s1", "f1")
+	require.NoError(t, err)
+	require.False(t, committed)
+
+	n, err := repo.DeleteExpired()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	found, err = repo.Get("s1", "f1")
+	require.NoError(t, err)
+	require.Nil(t, found)
+}
+
+func TestRepository__RedactContents(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	repo := NewRepository(db.DB)
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	for _, fileID := range []string{"f1", "f2"} {
+		staged, err := New("s1", fileID, file, nil, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		_, err = repo.Save(staged)
+		require.NoError(t, err)
+	}
+	committed, err := repo.Commit("s1", "f2")
+	require.NoError(t, err)
+	require.True(t, committed)
+
+	// Committed files have no contents left to redact
+	var keys []string
+	err = repo.RedactContents(func(key string, contents []byte) ([]byte, bool) {
+		keys = append(keys, key)
+		return []byte("redacted"), true
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"s1/f1"}, keys)
+
+	found, err := repo.Get("s1", "f1")
+	require.NoError(t, err)
+	require.Equal(t, "redacted", string(found.Contents))
+}
+
+func TestNew__Invalid(t *testing.T) {
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	file.Batches[0].GetEntries()[0].Amount = 1
+
+	_, err = New("s1", "f1", file, nil, time.Now())
+	require.ErrorContains(t, err, "invalid file")
+}
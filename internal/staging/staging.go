@@ -0,0 +1,98 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package staging holds files submitted with the two-phase API. Files are validated and totaled
+// when they're staged and only published into the pipeline once they're committed, so the totals
+// can be approved first. Uncommitted files expire.
+package staging
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/achgateway/pkg/models"
+
+	"github.com/moov-io/ach"
+)
+
+const (
+	StatusStaged    = "staged"
+	StatusCommitted = "committed"
+	StatusExpired   = "expired"
+)
+
+// StagedFile is a validated file waiting to be committed
+type StagedFile struct {
+	FileID   string `json:"fileID"`
+	ShardKey string `json:"shardKey"`
+	Status   string `json:"status"`
+
+	// EntryCount and Batches total the file's entries. Amounts are in cents.
+	EntryCount int                  `json:"entryCount"`
+	Batches    []models.BatchTotals `json:"batches,omitempty"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	CommittedAt *time.Time `json:"committedAt,omitempty"`
+
+	// Contents is the file in Nacha format
+	Contents []byte `json:"-"`
+}
+
+// New validates file and totals its entries for staging until expiresAt
+func New(shardKey, fileID string, file *ach.File, metadata map[string]string, expiresAt time.Time) (*StagedFile, error) {
+	if err := file.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid file: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := ach.NewWriter(&buf).Write(file); err != nil {
+		return nil, fmt.Errorf("writing file: %v", err)
+	}
+	staged := &StagedFile{
+		FileID:    fileID,
+		ShardKey:  shardKey,
+		Status:    StatusStaged,
+		Metadata:  metadata,
+		ExpiresAt: expiresAt,
+		Contents:  buf.Bytes(),
+	}
+	staged.EntryCount, staged.Batches = models.TotalBatches(file)
+	return staged, nil
+}
+
+// File reads the staged file's contents
+func (f *StagedFile) File() (*ach.File, error) {
+	file, err := ach.NewReader(bytes.NewReader(f.Contents)).Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading staged file %s: %v", f.FileID, err)
+	}
+	return &file, nil
+}
+
+// status is the file's status at now
+func (f *StagedFile) status(now time.Time) string {
+	switch {
+	case f.CommittedAt != nil:
+		return StatusCommitted
+	case now.After(f.ExpiresAt):
+		return StatusExpired
+	}
+	return StatusStaged
+}
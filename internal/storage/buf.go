@@ -2,11 +2,16 @@ package storage
 
 import (
 	"bytes"
+
+	"github.com/moov-io/achgateway/internal/bufpool"
 )
 
 type buffer struct {
 	b *bytes.Buffer
 
+	// pooled buffers are returned to bufpool on Close
+	pooled bool
+
 	filename string
 	fullpath string
 }
@@ -24,6 +29,12 @@ func (b *buffer) Read(data []byte) (int, error) {
 }
 
 func (b *buffer) Close() error {
+	if b.pooled {
+		bufpool.Put(b.b)
+		b.b = new(bytes.Buffer)
+		b.pooled = false
+		return nil
+	}
 	b.b.Reset()
 	return nil
 }
@@ -1,5 +1,12 @@
 package storage
 
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/moov-io/achgateway/internal/mask"
+)
+
 type Config struct {
 	Filesystem FilesystemConfig
 	Encryption EncryptionConfig
@@ -12,8 +19,24 @@ type FilesystemConfig struct {
 type EncryptionConfig struct {
 	AES      *AESConfig
 	Encoding string
+
+	// PreviousAES are retired keys which are only used to read files written before
+	// a key rotation. Remove them once every file has been re-encrypted.
+	PreviousAES []AESConfig
 }
 
 type AESConfig struct {
+	KeyID     string
 	Base64Key string
 }
+
+func (cfg AESConfig) MarshalJSON() ([]byte, error) {
+	type Aux AESConfig
+	aux := Aux(cfg)
+	aux.Base64Key = mask.Password(cfg.Base64Key)
+	return json.Marshal(aux)
+}
+
+func (cfg AESConfig) String() string {
+	return fmt.Sprintf("AES{KeyID=%s, Base64Key=%s}", cfg.KeyID, mask.Password(cfg.Base64Key))
+}
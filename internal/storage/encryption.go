@@ -2,20 +2,43 @@ package storage
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
 
+	"github.com/moov-io/achgateway/internal/bufpool"
 	"github.com/moov-io/cryptfs"
 )
 
+var (
+	// keyIDHeader is written at the start of files encrypted with a named key so the
+	// key can be found again after it has been rotated.
+	keyIDHeader = []byte("achgateway-key-id:")
+)
+
+// Key is an encryption key for a Chest along with its identifier.
+type Key struct {
+	ID    string
+	Crypt *cryptfs.FS
+}
+
 type encrypted struct {
-	crypt      *cryptfs.FS
+	current    Key
+	previous   []Key
 	underlying Chest
 }
 
 func NewEncrypted(underlying Chest, crypt *cryptfs.FS) Chest {
+	return NewEncryptedWithKeys(underlying, Key{Crypt: crypt}, nil)
+}
+
+// NewEncryptedWithKeys returns a Chest which writes files with current and reads
+// files encrypted with current or any of the previous keys.
+func NewEncryptedWithKeys(underlying Chest, current Key, previous []Key) Chest {
 	return &encrypted{
+		current:    current,
+		previous:   previous,
 		underlying: underlying,
-		crypt:      crypt,
 	}
 }
 
@@ -31,17 +54,30 @@ func (e *encrypted) Open(path string) (File, error) {
 		}
 	}()
 
-	bs, err := io.ReadAll(file)
+	raw := bufpool.Get()
+	if _, err := raw.ReadFrom(file); err != nil {
+		bufpool.Put(raw)
+		return nil, err
+	}
+
+	bs, err := e.reveal(raw.Bytes())
 	if err != nil {
+		bufpool.Put(raw)
 		return nil, err
 	}
 
-	if e.crypt != nil {
-		bs, err = e.crypt.Reveal(bs)
-		if err != nil {
-			return nil, err
-		}
+	// Unencrypted contents are a suffix of what we read, so hand the pooled
+	// buffer to the caller rather than copying it.
+	if n := len(bs); n > 0 && sharesTail(raw.Bytes(), bs) {
+		raw.Next(raw.Len() - n)
+		return &buffer{
+			b:        raw,
+			pooled:   true,
+			filename: file.Filename(),
+			fullpath: file.FullPath(),
+		}, nil
 	}
+	bufpool.Put(raw)
 
 	return &buffer{
 		b:        bytes.NewBuffer(bs),
@@ -50,6 +86,71 @@ func (e *encrypted) Open(path string) (File, error) {
 	}, nil
 }
 
+// sharesTail reports if sub is a suffix slice of buf's backing array.
+func sharesTail(buf, sub []byte) bool {
+	if len(buf) == 0 || len(sub) == 0 || len(sub) > len(buf) {
+		return false
+	}
+	return &buf[len(buf)-1] == &sub[len(sub)-1]
+}
+
+func (e *encrypted) reveal(bs []byte) ([]byte, error) {
+	keyID, contents, found := splitKeyIDHeader(bs)
+	if found {
+		key := e.findKey(keyID)
+		if key == nil {
+			return nil, fmt.Errorf("unknown encryption key id %q", keyID)
+		}
+		if key.Crypt == nil {
+			return contents, nil
+		}
+		return key.Crypt.Reveal(contents)
+	}
+
+	// Files without a key id were written before key ids were configured, so try
+	// each key until one succeeds.
+	if e.current.Crypt == nil {
+		return bs, nil
+	}
+	out, err := e.current.Crypt.Reveal(bs)
+	if err == nil {
+		return out, nil
+	}
+	for i := range e.previous {
+		if e.previous[i].Crypt == nil {
+			continue
+		}
+		if prev, perr := e.previous[i].Crypt.Reveal(bs); perr == nil {
+			return prev, nil
+		}
+	}
+	return nil, err
+}
+
+func (e *encrypted) findKey(keyID string) *Key {
+	if e.current.ID == keyID {
+		return &e.current
+	}
+	for i := range e.previous {
+		if e.previous[i].ID == keyID {
+			return &e.previous[i]
+		}
+	}
+	return nil
+}
+
+func splitKeyIDHeader(bs []byte) (string, []byte, bool) {
+	if !bytes.HasPrefix(bs, keyIDHeader) {
+		return "", bs, false
+	}
+	rest := bs[len(keyIDHeader):]
+	idx := bytes.IndexByte(rest, '\n')
+	if idx < 0 {
+		return "", bs, false
+	}
+	return string(rest[:idx]), rest[idx+1:], true
+}
+
 func (e *encrypted) Glob(pattern string) ([]FileStat, error) {
 	return e.underlying.Glob(pattern)
 }
@@ -72,11 +173,83 @@ func (e *encrypted) RmdirAll(path string) error {
 
 func (e *encrypted) WriteFile(path string, contents []byte) error {
 	var err error
-	if e.crypt != nil {
-		contents, err = e.crypt.Disfigure(contents)
+	if e.current.Crypt != nil {
+		contents, err = e.current.Crypt.Disfigure(contents)
 		if err != nil {
 			return err
 		}
 	}
+	if e.current.ID != "" {
+		buf := bufpool.Get()
+		defer bufpool.Put(buf)
+
+		buf.Grow(len(keyIDHeader) + len(e.current.ID) + 1 + len(contents))
+		buf.Write(keyIDHeader)
+		buf.WriteString(e.current.ID)
+		buf.WriteByte('\n')
+		buf.Write(contents)
+		contents = buf.Bytes()
+	}
 	return e.underlying.WriteFile(path, contents)
 }
+
+// reencrypt rewrites each file matching pattern which is not already encrypted
+// with the current key. It returns how many files were rewritten.
+func (e *encrypted) reencrypt(pattern string) (int, error) {
+	matches, err := e.underlying.Glob(pattern)
+	if err != nil {
+		return 0, err
+	}
+	rewritten := 0
+	for i := range matches {
+		path := matches[i].RelativePath
+
+		raw, err := readAll(e.underlying, path)
+		if err != nil {
+			return rewritten, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if len(raw) == 0 {
+			continue // nothing to protect (e.g. canceled markers)
+		}
+		if keyID, _, found := splitKeyIDHeader(raw); found && keyID == e.current.ID {
+			continue
+		} else if !found && e.current.ID == "" && e.current.Crypt != nil {
+			if _, err := e.current.Crypt.Reveal(raw); err == nil {
+				continue
+			}
+		}
+
+		plaintext, err := e.reveal(raw)
+		if err != nil {
+			return rewritten, fmt.Errorf("decrypting %s: %w", path, err)
+		}
+		if err := e.WriteFile(path, plaintext); err != nil {
+			return rewritten, fmt.Errorf("writing %s: %w", path, err)
+		}
+		rewritten++
+	}
+	return rewritten, nil
+}
+
+func readAll(chest Chest, path string) ([]byte, error) {
+	file, err := chest.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+// Reencrypt rewrites every file matching pattern with the Chest's current encryption key.
+// Chests which are not encrypted are left unchanged.
+func Reencrypt(chest Chest, pattern string) (int, error) {
+	if chest == nil {
+		return 0, errors.New("nil Chest")
+	}
+	enc, ok := chest.(*encrypted)
+	if !ok {
+		return 0, nil
+	}
+	return enc.reencrypt(pattern)
+}
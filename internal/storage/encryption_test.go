@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"io"
 	"testing"
 
 	"github.com/moov-io/cryptfs"
@@ -30,3 +31,90 @@ func TestEncrypted(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, []byte("nacha"), decrypted)
 }
+
+func TestEncrypted__KeyRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	chest, err := NewFilesystem(dir)
+	require.NoError(t, err)
+
+	newKey := func(t *testing.T, id, key string) Key {
+		t.Helper()
+
+		aes, err := cryptfs.NewAESCryptor([]byte(key))
+		require.NoError(t, err)
+		crypt, err := cryptfs.New(aes)
+		require.NoError(t, err)
+
+		return Key{ID: id, Crypt: crypt}
+	}
+	legacy := newKey(t, "", "1111111111111111")
+	first := newKey(t, "first", "2222222222222222")
+	second := newKey(t, "second", "3333333333333333")
+
+	// Write files with the legacy (unnamed) and first keys
+	require.NoError(t, NewEncrypted(chest, legacy.Crypt).WriteFile("mergable/a.ach", []byte("legacy")))
+	require.NoError(t, NewEncryptedWithKeys(chest, first, nil).WriteFile("mergable/b.ach", []byte("first")))
+
+	// Rotate to the second key, files are still readable
+	rotated := NewEncryptedWithKeys(chest, second, []Key{first, legacy})
+	require.Equal(t, "legacy", readContents(t, rotated, "mergable/a.ach"))
+	require.Equal(t, "first", readContents(t, rotated, "mergable/b.ach"))
+
+	n, err := Reencrypt(rotated, "mergable/*.ach")
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	// Running again has nothing to do
+	n, err = Reencrypt(rotated, "mergable/*.ach")
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// Previous keys can be dropped after re-encryption
+	final := NewEncryptedWithKeys(chest, second, nil)
+	require.Equal(t, "legacy", readContents(t, final, "mergable/a.ach"))
+	require.Equal(t, "first", readContents(t, final, "mergable/b.ach"))
+}
+
+func readContents(t *testing.T, chest Chest, path string) string {
+	t.Helper()
+
+	file, err := chest.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	bs, err := io.ReadAll(file)
+	require.NoError(t, err)
+	return string(bs)
+}
+
+func TestEncrypted__Plaintext(t *testing.T) {
+	dir := t.TempDir()
+
+	chest, err := NewFilesystem(dir)
+	require.NoError(t, err)
+
+	// A named key without a Crypt only writes the key id header
+	plain := NewEncryptedWithKeys(chest, Key{ID: "plain"}, nil)
+	require.NoError(t, plain.WriteFile("mergable/a.ach", []byte("nacha")))
+
+	raw, err := readAll(chest, "mergable/a.ach")
+	require.NoError(t, err)
+	require.Equal(t, "achgateway-key-id:plain\nnacha", string(raw))
+
+	for i := 0; i < 3; i++ {
+		file, err := plain.Open("mergable/a.ach")
+		require.NoError(t, err)
+
+		bs, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, "nacha", string(bs))
+
+		require.NoError(t, file.Close())
+		require.NoError(t, file.Close())
+
+		bs, err = io.ReadAll(file)
+		require.NoError(t, err)
+		require.Empty(t, bs)
+	}
+}
@@ -30,25 +30,41 @@ func New(cfg Config) (Chest, error) {
 	}
 
 	if cfg.Encryption.AES != nil {
-		enc, err := createBase64AESCryptor(cfg.Encryption.AES.Base64Key)
+		current, err := createKey(*cfg.Encryption.AES, cfg.Encryption.Encoding)
 		if err != nil {
-			return nil, fmt.Errorf("error creating AES cryptor: %w", err)
+			return nil, err
 		}
-
-		fs, err := cryptfs.New(enc)
-		if err != nil {
-			return nil, fmt.Errorf("error creating cryptfs: %w", err)
+		var previous []Key
+		for i := range cfg.Encryption.PreviousAES {
+			key, err := createKey(cfg.Encryption.PreviousAES[i], cfg.Encryption.Encoding)
+			if err != nil {
+				return nil, fmt.Errorf("previous key[%d]: %w", i, err)
+			}
+			previous = append(previous, key)
 		}
+		return NewEncryptedWithKeys(underlying, current, previous), nil
+	}
 
-		switch strings.ToLower(cfg.Encryption.Encoding) {
-		case "base64":
-			fs.SetCoder(cryptfs.Base64())
-		}
+	return underlying, nil
+}
 
-		return NewEncrypted(underlying, fs), nil
+func createKey(cfg AESConfig, encoding string) (Key, error) {
+	enc, err := createBase64AESCryptor(cfg.Base64Key)
+	if err != nil {
+		return Key{}, fmt.Errorf("error creating AES cryptor: %w", err)
 	}
 
-	return underlying, nil
+	fs, err := cryptfs.New(enc)
+	if err != nil {
+		return Key{}, fmt.Errorf("error creating cryptfs: %w", err)
+	}
+
+	switch strings.ToLower(encoding) {
+	case "base64":
+		fs.SetCoder(cryptfs.Base64())
+	}
+
+	return Key{ID: cfg.KeyID, Crypt: fs}, nil
 }
 
 func createBase64AESCryptor(key string) (*cryptfs.AESCryptor, error) {
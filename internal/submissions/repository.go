@@ -0,0 +1,132 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package submissions
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/base/database"
+)
+
+type Repository interface {
+	// Record saves sub along with the trace numbers of its entries. Submissions recorded before
+	// are left as they are.
+	Record(sub Submission, traceNumbers []string) error
+
+	// Get returns the submission accepted into shardName, or nil
+	Get(shardName, fileID string) (*Submission, error)
+
+	// FindTraceNumber returns the most recent submission with an entry of traceNumber, or nil.
+	// Trace numbers replaced while merging are also found by the number they were assigned.
+	FindTraceNumber(traceNumber string) (*Submission, error)
+}
+
+// NewRepository returns a Repository stored in db, or nil when db is nil
+func NewRepository(db *sql.DB) Repository {
+	if db == nil {
+		return nil
+	}
+	return &sqlRepository{db: db, now: time.Now}
+}
+
+type sqlRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func (r *sqlRepository) Record(sub Submission, traceNumbers []string) error {
+	if sub.ShardName == "" || sub.FileID == "" {
+		return errors.New("missing shardName or fileID")
+	}
+	bs, err := json.Marshal(sub.Metadata)
+	if err != nil {
+		return fmt.Errorf("encoding submission %s metadata: %v", sub.FileID, err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("recording submission %s: %v", sub.FileID, err)
+	}
+	defer tx.Rollback()
+
+	query := `insert into submission_metadata (shard_name, file_id, shard_key, metadata, created_at) values (?, ?, ?, ?, ?);`
+	if _, err := tx.Exec(query, sub.ShardName, sub.FileID, sub.ShardKey, string(bs), r.now().UTC().Truncate(time.Second)); err != nil {
+		if database.UniqueViolation(err) {
+			return nil // the submission was redelivered
+		}
+		return fmt.Errorf("recording submission %s: %v", sub.FileID, err)
+	}
+
+	stmt, err := tx.Prepare(`insert into submission_trace_numbers (shard_name, file_id, trace_number) values (?, ?, ?);`)
+	if err != nil {
+		return fmt.Errorf("recording submission %s trace numbers: %v", sub.FileID, err)
+	}
+	defer stmt.Close()
+
+	for i := range traceNumbers {
+		if _, err := stmt.Exec(sub.ShardName, sub.FileID, traceNumbers[i]); err != nil {
+			return fmt.Errorf("recording submission %s trace numbers: %v", sub.FileID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("recording submission %s: %v", sub.FileID, err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) Get(shardName, fileID string) (*Submission, error) {
+	query := `select shard_name, file_id, shard_key, metadata, created_at from submission_metadata where shard_name = ? and file_id = ? limit 1;`
+	return scanSubmission(r.db.QueryRow(query, shardName, fileID))
+}
+
+func (r *sqlRepository) FindTraceNumber(traceNumber string) (*Submission, error) {
+	query := `select m.shard_name, m.file_id, m.shard_key, m.metadata, m.created_at from submission_trace_numbers t
+join submission_metadata m on m.shard_name = t.shard_name and m.file_id = t.file_id
+where t.trace_number = ? order by m.created_at desc limit 1;`
+	sub, err := scanSubmission(r.db.QueryRow(query, traceNumber))
+	if sub != nil || err != nil {
+		return sub, err
+	}
+
+	// Find the submitted trace number when merging assigned a new one
+	query = `select m.shard_name, m.file_id, m.shard_key, m.metadata, m.created_at from trace_number_lineage l
+join submission_trace_numbers t on t.shard_name = l.shard_name and t.file_id = l.file_id and t.trace_number = l.original_trace_number
+join submission_metadata m on m.shard_name = t.shard_name and m.file_id = t.file_id
+where l.assigned_trace_number = ? order by l.created_at desc limit 1;`
+	return scanSubmission(r.db.QueryRow(query, traceNumber))
+}
+
+func scanSubmission(row *sql.Row) (*Submission, error) {
+	var sub Submission
+	var metadata string
+	err := row.Scan(&sub.ShardName, &sub.FileID, &sub.ShardKey, &metadata, &sub.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading submission: %v", err)
+	}
+	if err := json.Unmarshal([]byte(metadata), &sub.Metadata); err != nil {
+		return nil, fmt.Errorf("reading submission %s metadata: %v", sub.FileID, err)
+	}
+	return &sub, nil
+}
@@ -0,0 +1,95 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package submissions
+
+import (
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/lineage"
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmissions__Repository(t *testing.T) {
+	require.Nil(t, NewRepository(nil))
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := NewRepository(db.DB)
+
+	got, err := repo.Get("live", "missing")
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	sub := Submission{
+		ShardName: "live",
+		ShardKey:  "acme",
+		FileID:    "payroll-0601",
+		Metadata:  map[string]string{"batchID": "123", "ledger": "payroll"},
+	}
+	require.NoError(t, repo.Record(sub, []string{"076401255655291", "076401255655292"}))
+
+	got, err = repo.Get("live", "payroll-0601")
+	require.NoError(t, err)
+	require.Equal(t, "acme", got.ShardKey)
+	require.Equal(t, sub.Metadata, got.Metadata)
+	require.False(t, got.CreatedAt.IsZero())
+
+	// Redelivered submissions keep their first metadata
+	again := sub
+	again.Metadata = map[string]string{"batchID": "456"}
+	require.NoError(t, repo.Record(again, []string{"076401255655291"}))
+
+	got, err = repo.FindTraceNumber("076401255655292")
+	require.NoError(t, err)
+	require.Equal(t, "payroll-0601", got.FileID)
+	require.Equal(t, "123", got.Metadata["batchID"])
+
+	got, err = repo.FindTraceNumber("076401259999999")
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	require.ErrorContains(t, repo.Record(Submission{ShardKey: "acme"}, nil), "missing shardName or fileID")
+}
+
+func TestSubmissions__FindAssignedTraceNumber(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+	repo := NewRepository(db.DB)
+
+	sub := Submission{
+		ShardName: "live",
+		ShardKey:  "acme",
+		FileID:    "payroll-0601",
+		Metadata:  map[string]string{"batchID": "123"},
+	}
+	require.NoError(t, repo.Record(sub, []string{"076401255655291"}))
+
+	// Merging replaced the trace number
+	err := lineage.NewRepository(db.DB).RecordTraceNumbers([]lineage.TraceNumber{
+		{ShardName: "live", FileID: "payroll-0601", Original: "076401255655291", Assigned: "076401250000001"},
+	})
+	require.NoError(t, err)
+
+	got, err := repo.FindTraceNumber("076401250000001")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.Equal(t, "payroll-0601", got.FileID)
+	require.Equal(t, sub.Metadata, got.Metadata)
+}
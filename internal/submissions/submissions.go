@@ -0,0 +1,68 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package submissions records the metadata attached to submitted files so it can be echoed back
+// on events about the file, such as when it's uploaded or its entries are returned.
+package submissions
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Submission is a file accepted into a shard with metadata attached by the submitter
+type Submission struct {
+	ShardName string            `json:"shardName"`
+	ShardKey  string            `json:"shardKey"`
+	FileID    string            `json:"fileID"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+const (
+	maxKeys        = 20
+	maxKeyLength   = 64
+	maxValueLength = 256
+)
+
+// Validate checks metadata is small enough to be recorded and sent with events
+func Validate(metadata map[string]string) error {
+	if len(metadata) > maxKeys {
+		return fmt.Errorf("metadata has %d keys, at most %d are allowed", len(metadata), maxKeys)
+	}
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if strings.TrimSpace(key) == "" {
+			return errors.New("metadata has an empty key")
+		}
+		if len(key) > maxKeyLength {
+			return fmt.Errorf("metadata key %q is longer than %d characters", key[:maxKeyLength], maxKeyLength)
+		}
+		if len(metadata[key]) > maxValueLength {
+			return fmt.Errorf("metadata %s is longer than %d characters", key, maxValueLength)
+		}
+	}
+	return nil
+}
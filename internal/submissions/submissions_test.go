@@ -0,0 +1,41 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package submissions
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, Validate(nil))
+	require.NoError(t, Validate(map[string]string{"batchID": "123"}))
+
+	require.ErrorContains(t, Validate(map[string]string{" ": "123"}), "empty key")
+	require.ErrorContains(t, Validate(map[string]string{strings.Repeat("a", 65): "123"}), "longer than 64 characters")
+	require.ErrorContains(t, Validate(map[string]string{"memo": strings.Repeat("a", 257)}), "metadata memo is longer than 256 characters")
+
+	many := make(map[string]string)
+	for i := 0; i < 21; i++ {
+		many[fmt.Sprintf("key%d", i)] = "value"
+	}
+	require.ErrorContains(t, Validate(many), "metadata has 21 keys, at most 20 are allowed")
+}
@@ -0,0 +1,89 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/config"
+	"github.com/moov-io/base/log"
+)
+
+// TenantsEnv lists the tenants to run, separated by commas. When it's unset only the
+// ACHGateway config is run, otherwise it's run when "default" is listed.
+const TenantsEnv = "TENANTS"
+
+// LoadTenants reads the config of each tenant listed in TENANTS, or returns nil when it's unset.
+func LoadTenants(logger log.Logger) (map[string]*service.Config, error) {
+	value := strings.TrimSpace(os.Getenv(TenantsEnv))
+	if value == "" {
+		return nil, nil
+	}
+
+	configService := config.NewService(logger)
+
+	global := &service.GlobalConfig{}
+	if err := configService.Load(global); err != nil {
+		return nil, err
+	}
+	tenants, err := global.SelectTenants(strings.Split(value, ","))
+	if err != nil {
+		return nil, err
+	}
+	if len(tenants) == 0 {
+		return nil, fmt.Errorf("no tenants found in %s", TenantsEnv)
+	}
+	if err := service.ValidateTenants(tenants); err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+// NewTenantEnvironments creates an Environment for each tenant, ordered by name. Environments
+// already created are shut down if one fails.
+func NewTenantEnvironments(logger log.Logger, tenants map[string]*service.Config) ([]*Environment, error) {
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var envs []*Environment
+	for _, name := range names {
+		env, err := NewEnvironment(&Environment{
+			Logger: logger.Set("tenant", log.String(name)),
+			Config: tenants[name],
+			Tenant: name,
+		})
+		if err != nil {
+			if env != nil {
+				env.Shutdown()
+			}
+			for i := range envs {
+				envs[i].Shutdown()
+			}
+			return nil, fmt.Errorf("tenant %s: %w", name, err)
+		}
+		envs = append(envs, env)
+	}
+	return envs, nil
+}
@@ -0,0 +1,67 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/base/config"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTenants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(path, []byte(`
+Tenants:
+  Sandbox:
+    Admin:
+      BindAddress: ":9595"
+    Inbound:
+      HTTP:
+        BindAddress: ":8585"
+  Pilot:
+    Admin:
+      BindAddress: ":9494"
+`), 0600)
+	require.NoError(t, err)
+	t.Setenv(config.APP_CONFIG, path)
+
+	t.Setenv(TenantsEnv, "")
+	tenants, err := LoadTenants(log.NewTestLogger())
+	require.NoError(t, err)
+	require.Nil(t, tenants)
+
+	t.Setenv(TenantsEnv, "default,sandbox")
+	tenants, err = LoadTenants(log.NewTestLogger())
+	require.NoError(t, err)
+	require.Len(t, tenants, 2)
+	require.Equal(t, ":8484", tenants["default"].Inbound.HTTP.BindAddress)
+	require.Equal(t, ":8585", tenants["sandbox"].Inbound.HTTP.BindAddress)
+
+	t.Setenv(TenantsEnv, "default,pilot")
+	_, err = LoadTenants(log.NewTestLogger())
+	require.ErrorContains(t, err, "tenants default and pilot share Admin.BindAddress :9494")
+
+	t.Setenv(TenantsEnv, "staging")
+	_, err = LoadTenants(log.NewTestLogger())
+	require.ErrorContains(t, err, "tenant staging is not configured")
+}
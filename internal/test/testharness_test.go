@@ -0,0 +1,120 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/testharness"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestACHTestHarness uploads a file to moov-io/ach-test-harness and waits for its scripted return.
+// Set ACH_TEST_HARNESS_FTP (host:port) and ACH_TEST_HARNESS_ADMIN (http://host:port) to run it.
+func TestACHTestHarness(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test via -short")
+	}
+	ftpAddress, adminEndpoint := os.Getenv("ACH_TEST_HARNESS_FTP"), os.Getenv("ACH_TEST_HARNESS_ADMIN")
+	if ftpAddress == "" || adminEndpoint == "" {
+		t.Skip("ACH_TEST_HARNESS_FTP and ACH_TEST_HARNESS_ADMIN are required")
+	}
+
+	accountNumber := fmt.Sprintf("%d", time.Now().UnixNano()%1e12)
+	uploadAgents := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID: "ach-test-harness",
+				TestHarness: &service.ACHTestHarness{
+					FTP:           ftpAddress,
+					AdminEndpoint: adminEndpoint,
+					Responses: []service.ACHTestHarnessResponse{
+						{
+							Match: service.ACHTestHarnessMatch{AccountNumber: accountNumber},
+							Action: service.ACHTestHarnessAction{
+								Return: &service.ACHTestHarnessReturn{Code: "R03"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	require.NoError(t, uploadAgents.Validate())
+
+	logger := log.NewTestLogger()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, testharness.ProvisionAll(ctx, logger, http.DefaultClient, uploadAgents))
+
+	agent, err := upload.New(logger, uploadAgents, "ach-test-harness")
+	require.NoError(t, err)
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	entry := file.Batches[0].GetEntries()[0]
+	entry.DFIAccountNumber = accountNumber
+	require.NoError(t, file.Create())
+
+	var buf bytes.Buffer
+	require.NoError(t, ach.NewWriter(&buf).Write(file))
+	filename := fmt.Sprintf("harness-%d.ach", time.Now().UnixNano())
+	require.NoError(t, agent.UploadFile(upload.File{
+		Filename: filename,
+		Contents: io.NopCloser(&buf),
+	}))
+
+	// Wait for the harness to write the return
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		files, err := agent.GetReturnFiles()
+		require.NoError(t, err)
+
+		for i := range files {
+			returned, err := ach.NewReader(files[i].Contents).Read()
+			files[i].Close()
+			if err != nil {
+				continue
+			}
+			for _, batch := range returned.ReturnEntries {
+				for _, ed := range batch.GetEntries() {
+					if ed.Addenda99 != nil && ed.Addenda99.OriginalTrace == entry.TraceNumber && strings.TrimSpace(ed.DFIAccountNumber) == accountNumber {
+						require.Equal(t, "R03", ed.Addenda99.ReturnCode)
+						return
+					}
+				}
+			}
+		}
+		time.Sleep(time.Second)
+	}
+	t.Fatalf("no return found for %s", entry.TraceNumber)
+}
@@ -38,6 +38,7 @@ import (
 	"github.com/moov-io/achgateway/internal/consul"
 	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
 	"github.com/moov-io/achgateway/internal/incoming/web"
+	"github.com/moov-io/achgateway/internal/leadership"
 	"github.com/moov-io/achgateway/internal/pipeline"
 	"github.com/moov-io/achgateway/internal/service"
 	"github.com/moov-io/achgateway/internal/shards"
@@ -159,7 +160,7 @@ func TestUploads(t *testing.T) {
 	fileController.AppendRoutes(r)
 
 	outboundPath := setupTestDirectory(t, cfg)
-	fileReceiver, err := pipeline.Start(ctx, logger, cfg, consulClient, shardRepo, httpSub, streamSub)
+	fileReceiver, err := pipeline.Start(ctx, logger, cfg, leadership.Consul(logger, consulClient), nil, shardRepo, nil, nil, nil, nil, nil, nil, nil, httpSub, streamSub)
 	require.NoError(t, err)
 	t.Cleanup(func() { fileReceiver.Shutdown() })
 
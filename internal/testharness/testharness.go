@@ -0,0 +1,83 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package testharness provisions response scenarios on moov-io/ach-test-harness so upload agents
+// pointed at the harness receive scripted returns and corrections for the files they upload.
+package testharness
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+)
+
+// Provision replaces the harness's responses with cfg.Responses
+func Provision(ctx context.Context, client *http.Client, cfg *service.ACHTestHarness) error {
+	if cfg == nil || cfg.AdminEndpoint == "" || len(cfg.Responses) == 0 {
+		return nil
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	bs, err := json.Marshal(cfg.Responses)
+	if err != nil {
+		return fmt.Errorf("encoding responses: %v", err)
+	}
+	address := strings.TrimSuffix(cfg.AdminEndpoint, "/") + "/responses"
+	req, err := http.NewRequestWithContext(ctx, "PUT", address, bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("provisioning responses: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("provisioning responses: unexpected %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// ProvisionAll provisions the responses of every upload agent pointed at a test harness
+func ProvisionAll(ctx context.Context, logger log.Logger, client *http.Client, cfg service.UploadAgents) error {
+	for i := range cfg.Agents {
+		harness := cfg.Agents[i].TestHarness
+		if harness == nil || len(harness.Responses) == 0 {
+			continue
+		}
+		if err := Provision(ctx, client, harness); err != nil {
+			return fmt.Errorf("agent %s: %v", cfg.Agents[i].ID, err)
+		}
+		logger.Info().With(log.Fields{
+			"agent": log.String(cfg.Agents[i].ID),
+		}).Logf("provisioned %d ach-test-harness responses", len(harness.Responses))
+	}
+	return nil
+}
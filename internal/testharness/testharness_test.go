@@ -0,0 +1,84 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testharness
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvision(t *testing.T) {
+	var received []service.ACHTestHarnessResponse
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "PUT", r.Method)
+		require.Equal(t, "/responses", r.URL.Path)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(svr.Close)
+
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{ID: "ftp"},
+			{
+				ID: "harness",
+				TestHarness: &service.ACHTestHarness{
+					AdminEndpoint: svr.URL + "/",
+					Responses: []service.ACHTestHarnessResponse{
+						{
+							Match:  service.ACHTestHarnessMatch{AccountNumber: "987654321"},
+							Action: service.ACHTestHarnessAction{Return: &service.ACHTestHarnessReturn{Code: "R03"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	require.NoError(t, ProvisionAll(context.Background(), log.NewNopLogger(), svr.Client(), cfg))
+	require.Len(t, received, 1)
+	require.Equal(t, "987654321", received[0].Match.AccountNumber)
+	require.Equal(t, "R03", received[0].Action.Return.Code)
+}
+
+func TestProvision__Error(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid response", http.StatusBadRequest)
+	}))
+	t.Cleanup(svr.Close)
+
+	err := Provision(context.Background(), svr.Client(), &service.ACHTestHarness{
+		AdminEndpoint: svr.URL,
+		Responses: []service.ACHTestHarnessResponse{
+			{Action: service.ACHTestHarnessAction{Return: &service.ACHTestHarnessReturn{Code: "R01"}}},
+		},
+	})
+	require.ErrorContains(t, err, "unexpected 400 Bad Request: invalid response")
+
+	// Nothing to provision
+	require.NoError(t, Provision(context.Background(), nil, nil))
+	require.NoError(t, Provision(context.Background(), nil, &service.ACHTestHarness{AdminEndpoint: svr.URL}))
+}
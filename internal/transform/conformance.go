@@ -0,0 +1,125 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/addenda"
+	"github.com/moov-io/achgateway/internal/service"
+)
+
+// Conformance renders files to match an ODFI's ConformanceProfile. It must run before other
+// transformers so they (and the output formatter) use the rendered Contents.
+type Conformance struct {
+	cfg service.ConformanceProfile
+}
+
+func NewConformance(cfg *service.ConformanceProfile) *Conformance {
+	if cfg == nil {
+		return nil
+	}
+	return &Conformance{cfg: *cfg}
+}
+
+// ConformanceError lists the reasons a file doesn't conform to a profile
+type ConformanceError struct {
+	Profile    string
+	Violations []string
+}
+
+func (e *ConformanceError) Error() string {
+	return fmt.Sprintf("file does not conform to %s profile: %s", e.Profile, strings.Join(e.Violations, "; "))
+}
+
+func (c *Conformance) Transform(res *Result) (*Result, error) {
+	if c == nil || res == nil || res.File == nil {
+		return res, nil
+	}
+	if violations := c.Check(res.File); len(violations) > 0 {
+		return res, &ConformanceError{Profile: c.cfg.Name, Violations: violations}
+	}
+
+	var buf bytes.Buffer
+	if err := ach.NewWriter(&buf).Write(res.File); err != nil {
+		return res, err
+	}
+	res.Contents = c.Render(buf.Bytes())
+	return res, nil
+}
+
+// Check returns each way the file violates the profile
+func (c *Conformance) Check(file *ach.File) []string {
+	var violations []string
+	for i := range file.Batches {
+		bh := file.Batches[i].GetHeader()
+		code := bh.StandardEntryClassCode
+		if len(c.cfg.AllowedSECCodes) > 0 && !containsCode(c.cfg.AllowedSECCodes, code) {
+			violations = append(violations, fmt.Sprintf("batch %d has SEC code %s which is not allowed", bh.BatchNumber, code))
+		}
+		if containsCode(c.cfg.RequireAddenda, code) {
+			entries := file.Batches[i].GetEntries()
+			for j := range entries {
+				if entries[j].AddendaRecordIndicator != 1 {
+					violations = append(violations, fmt.Sprintf("entry trace number %s in %s batch %d is missing an addenda",
+						entries[j].TraceNumber, code, bh.BatchNumber))
+				}
+			}
+		}
+	}
+	for _, problem := range addenda.Check(file, c.cfg.AddendaFormats) {
+		violations = append(violations, problem.Error())
+	}
+	return violations
+}
+
+// Render rewrites the records of a Nacha formatted file with the profile's padding, blocking and case
+func (c *Conformance) Render(contents []byte) []byte {
+	lines := strings.Split(strings.TrimRight(string(contents), "\r\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+
+	// Replace the writer's filler records with blocks of the profile's size
+	filler := strings.Repeat("9", 94)
+	for len(lines) > 1 && lines[len(lines)-1] == filler {
+		lines = lines[:len(lines)-1]
+	}
+	for blocking := c.cfg.Blocking(); len(lines)%blocking != 0; {
+		lines = append(lines, filler)
+	}
+
+	var buf bytes.Buffer
+	for i := range lines {
+		line := lines[i]
+		if c.cfg.Uppercase {
+			line = strings.ToUpper(line)
+		}
+		if n := c.cfg.LineLength - len(line); n > 0 {
+			line += strings.Repeat(" ", n)
+		}
+		buf.WriteString(line + "\n")
+	}
+	return buf.Bytes()
+}
+
+func (c *Conformance) String() string {
+	if c == nil {
+		return "Conformance: <nil>"
+	}
+	return fmt.Sprintf("Conformance{%s}", c.cfg.Name)
+}
+
+func containsCode(codes []string, code string) bool {
+	for i := range codes {
+		if strings.EqualFold(strings.TrimSpace(codes[i]), code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package transform
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	conf := NewConformance(&service.ConformanceProfile{
+		Name:            "bank",
+		LineLength:      96,
+		BlockingFactor:  1,
+		Uppercase:       true,
+		AllowedSECCodes: []string{"PPD", "CCD"},
+	})
+	res, err := ForUpload(file, []PreUpload{conf})
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(res.Contents), "\n"), "\n")
+	require.Len(t, lines, 5) // header, batch header, entry, batch control and file control
+	for i := range lines {
+		require.Len(t, lines[i], 96)
+		require.Equal(t, strings.ToUpper(lines[i]), lines[i])
+	}
+	require.True(t, strings.HasPrefix(lines[len(lines)-1], "9000001"))
+}
+
+func TestConformance__Render(t *testing.T) {
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, ach.NewWriter(&buf).Write(file))
+
+	// The default profile matches the writer's output
+	conf := NewConformance(&service.ConformanceProfile{Name: "default"})
+	require.Equal(t, buf.String(), string(conf.Render(buf.Bytes())))
+
+	conf = NewConformance(&service.ConformanceProfile{Name: "blocks", BlockingFactor: 4})
+	rendered := strings.Split(strings.TrimSuffix(string(conf.Render(buf.Bytes())), "\n"), "\n")
+	require.Len(t, rendered, 8)
+	require.Equal(t, strings.Repeat("9", 94), rendered[7])
+}
+
+func TestConformance__Violations(t *testing.T) {
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	conf := NewConformance(&service.ConformanceProfile{
+		Name:            "strict",
+		AllowedSECCodes: []string{"CCD"},
+		RequireAddenda:  []string{"PPD"},
+	})
+	_, err = conf.Transform(&Result{File: file})
+
+	var cerr *ConformanceError
+	require.True(t, errors.As(err, &cerr))
+	require.Equal(t, "strict", cerr.Profile)
+	require.Len(t, cerr.Violations, 2)
+	require.Contains(t, cerr.Violations[0], "SEC code PPD which is not allowed")
+	require.Contains(t, cerr.Violations[1], "missing an addenda")
+
+	// Nil profiles don't change anything
+	var none *Conformance
+	res, err := none.Transform(&Result{File: file})
+	require.NoError(t, err)
+	require.Empty(t, res.Contents)
+}
+
+func TestConformance__AddendaFormats(t *testing.T) {
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	addenda := ach.NewAddenda05()
+	addenda.PaymentRelatedInformation = `DED*CS*A123456*221014*25000*123456789*N*SMITH\`
+	entry := file.Batches[0].GetEntries()[0]
+	entry.AddAddenda05(addenda)
+
+	conf := NewConformance(&service.ConformanceProfile{
+		Name:           "gov",
+		AddendaFormats: []string{"DED"},
+	})
+	_, err = conf.Transform(&Result{File: file})
+
+	var cerr *ConformanceError
+	require.True(t, errors.As(err, &cerr))
+	require.Len(t, cerr.Violations, 1)
+	require.Contains(t, cerr.Violations[0], "DED addenda: only CCD entries can have DED addenda, found PPD")
+}
@@ -48,7 +48,9 @@ func NewGPGEncryptor(cfg *service.GPG) (*GPGEncryption, error) {
 
 func (morph *GPGEncryption) Transform(res *Result) (*Result, error) {
 	var buf bytes.Buffer
-	if err := ach.NewWriter(&buf).Write(res.File); err != nil {
+	if len(res.Contents) > 0 {
+		buf.Write(res.Contents)
+	} else if err := ach.NewWriter(&buf).Write(res.File); err != nil {
 		return res, err
 	}
 
@@ -16,6 +16,9 @@ type Result struct {
 	File      *ach.File
 	Original  []byte
 	Encrypted []byte
+
+	// Contents are the Nacha formatted File when a conformance profile has rendered it
+	Contents []byte
 }
 
 type PreUpload interface {
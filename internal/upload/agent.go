@@ -44,23 +44,61 @@ func New(logger log.Logger, cfg service.UploadAgents, id string) (Agent, error)
 
 	// lookup cached
 	for i := range createdAgents.agents {
-		if createdAgents.agents[i].ID() == id {
-			return createdAgents.agents[i], nil
+		if createdAgents.agents[i].tenant == cfg.Tenant && createdAgents.agents[i].ID() == id {
+			return createdAgents.agents[i].Agent, nil
 		}
 	}
 
 	// Create the new agent
 	var agent Agent
+	var sessions *sessionPool
+	if cfg.ShareConnections {
+		sessions = sharedSessions
+	}
 	if conf := cfg.Find(id); conf != nil {
+		if err := ValidatePaths(conf.Paths); err != nil {
+			return nil, fmt.Errorf("upload: agent %s: %v", id, err)
+		}
+		if conf.TestHarness != nil {
+			conf = conf.WithTestHarness()
+		}
 		if conf.FTP != nil {
-			aa, err := newFTPTransferAgent(logger, conf)
+			aa, err := newFTPTransferAgent(logger, conf, sessions)
 			if err != nil {
 				return nil, err
 			}
 			agent = aa
 		}
 		if conf.SFTP != nil {
-			aa, err := newSFTPTransferAgent(logger, conf)
+			aa, err := newSFTPTransferAgent(logger, conf, sessions)
+			if err != nil {
+				return nil, err
+			}
+			agent = aa
+		}
+		if conf.S3 != nil {
+			aa, err := newS3TransferAgent(logger, conf)
+			if err != nil {
+				return nil, err
+			}
+			agent = aa
+		}
+		if conf.HTTPS != nil {
+			aa, err := newHTTPSTransferAgent(logger, conf)
+			if err != nil {
+				return nil, err
+			}
+			agent = aa
+		}
+		if conf.AS2 != nil {
+			aa, err := newAS2TransferAgent(logger, conf)
+			if err != nil {
+				return nil, err
+			}
+			agent = aa
+		}
+		if conf.Filesystem != nil {
+			aa, err := newFilesystemTransferAgent(logger, conf)
 			if err != nil {
 				return nil, err
 			}
@@ -73,6 +111,10 @@ func New(logger log.Logger, cfg service.UploadAgents, id string) (Agent, error)
 	if agent == nil {
 		return nil, fmt.Errorf("upload: unknown Agent ID=%s", id)
 	}
+	// Inject faults beneath the RetryAgent so retries see them
+	if cfg.FaultInjection != nil {
+		agent = newFaultAgent(logger, agent, id, cfg.FaultInjection)
+	}
 	if cfg.Retry != nil {
 		retr, err := newRetryAgent(logger, agent, cfg.Retry)
 		if err != nil {
@@ -80,16 +122,22 @@ func New(logger log.Logger, cfg service.UploadAgents, id string) (Agent, error)
 		}
 		agent = retr
 	}
-	createdAgents.register(agent)
+	createdAgents.register(cfg.Tenant, agent)
 	return agent, nil
 }
 
 type CreatedAgents struct {
 	mu          sync.Mutex
-	agents      []Agent
+	agents      []createdAgent
 	adminServer *admin.Server
 }
 
+// createdAgent is an Agent cached for the tenant whose config it was created from
+type createdAgent struct {
+	Agent
+	tenant string
+}
+
 func RegisterAdminServer(svc *admin.Server) {
 	createdAgents.mu.Lock()
 	defer createdAgents.mu.Unlock()
@@ -99,9 +147,9 @@ func RegisterAdminServer(svc *admin.Server) {
 	}
 }
 
-func (as *CreatedAgents) register(agent Agent) {
+func (as *CreatedAgents) register(tenant string, agent Agent) {
 	// track agent
-	as.agents = append(as.agents, agent)
+	as.agents = append(as.agents, createdAgent{Agent: agent, tenant: tenant})
 
 	// register liveness probe
 	if as.adminServer != nil {
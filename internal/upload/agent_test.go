@@ -33,7 +33,7 @@ func TestAgent(t *testing.T) {
 	// check Agent was registered
 	require.Len(t, createdAgents.agents, 1)
 
-	_, ok := createdAgents.agents[0].(*MockAgent)
+	_, ok := createdAgents.agents[0].Agent.(*MockAgent)
 	require.True(t, ok)
 
 	// setup a second (retrying) agent
@@ -55,9 +55,33 @@ func TestAgent(t *testing.T) {
 	// check Agent was registered
 	require.Len(t, createdAgents.agents, 2)
 
-	retr, ok := createdAgents.agents[1].(*RetryAgent)
+	retr, ok := createdAgents.agents[1].Agent.(*RetryAgent)
 	require.True(t, ok)
 
 	_, ok = retr.underlying.(*MockAgent)
 	require.True(t, ok)
 }
+
+func TestAgent__Tenants(t *testing.T) {
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "mock-agent",
+				Mock: &service.MockAgent{},
+			},
+		},
+		Tenant: "sandbox",
+	}
+	sandbox, err := New(log.NewNopLogger(), cfg, "mock-agent")
+	require.NoError(t, err)
+
+	again, err := New(log.NewNopLogger(), cfg, "mock-agent")
+	require.NoError(t, err)
+	require.Same(t, sandbox, again)
+
+	// Agents with the same ID aren't shared across tenants
+	cfg.Tenant = "pilot"
+	pilot, err := New(log.NewNopLogger(), cfg, "mock-agent")
+	require.NoError(t, err)
+	require.NotSame(t, sandbox, pilot)
+}
@@ -0,0 +1,382 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/as2"
+	"github.com/moov-io/achgateway/internal/fips"
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	as2AgentUp = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Name: "as2_agent_up",
+		Help: "Status of AS2 agent connection",
+	}, []string{"hostname"})
+
+	as2MDNs = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "as2_mdns_received",
+		Help: "Counter of MDN receipts read for messages sent to AS2 partners",
+	}, []string{"agent", "mode", "status"})
+)
+
+// AS2TransferAgent is an implementation of Agent which exchanges files with a trading partner
+// over AS2 (RFC 4130).
+//
+// Uploaded files are sent as one message each to the partner's URL and the MDN receipt (read from
+// the response, or POSTed to /as2/{agentID}/mdn later) is checked. AS2 only pushes files, so files
+// from the partner are POSTed to /as2/{agentID} and read from the agent's Inbox directory.
+type AS2TransferAgent struct {
+	client  *http.Client
+	cfg     service.UploadAgent
+	partner *as2.Partner
+	logger  log.Logger
+
+	// skipped are the entries passed over by the last readFiles call
+	mu      sync.Mutex
+	skipped []SkippedFile
+}
+
+func newAS2TransferAgent(logger log.Logger, cfg *service.UploadAgent) (*AS2TransferAgent, error) {
+	if cfg == nil || cfg.AS2 == nil {
+		return nil, errors.New("nil AS2 config")
+	}
+	if err := cfg.AS2.Validate(); err != nil {
+		return nil, fmt.Errorf("as2: %v", err)
+	}
+	if err := rejectOutboundIPRange(cfg.SplitAllowedIPs(), cfg.AS2.Hostname()); err != nil {
+		return nil, fmt.Errorf("as2: %s is not whitelisted: %v", cfg.AS2.Hostname(), err)
+	}
+	partner, err := loadAS2Partner(cfg.AS2)
+	if err != nil {
+		return nil, fmt.Errorf("as2: %v", err)
+	}
+	return &AS2TransferAgent{
+		client:  as2Client(cfg.AS2),
+		cfg:     *cfg,
+		partner: partner,
+		logger:  logger,
+	}, nil
+}
+
+// loadAS2Partner reads our certificate and key along with the partner's certificate
+func loadAS2Partner(cfg *service.AS2) (*as2.Partner, error) {
+	partner := &as2.Partner{
+		AS2From:             cfg.AS2From,
+		AS2To:               cfg.AS2To,
+		SigningAlgorithm:    cfg.Signing(),
+		EncryptionAlgorithm: cfg.Encryption(),
+	}
+	bs, err := os.ReadFile(cfg.PartnerCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading PartnerCertFile: %v", err)
+	}
+	if partner.PartnerCertificate, err = as2.ParseCertificate(bs); err != nil {
+		return nil, fmt.Errorf("parsing PartnerCertFile: %v", err)
+	}
+	if bs, err = os.ReadFile(cfg.CertFile); err != nil {
+		return nil, fmt.Errorf("reading CertFile: %v", err)
+	}
+	if partner.Certificate, err = as2.ParseCertificate(bs); err != nil {
+		return nil, fmt.Errorf("parsing CertFile: %v", err)
+	}
+	if bs, err = os.ReadFile(cfg.KeyFile); err != nil {
+		return nil, fmt.Errorf("reading KeyFile: %v", err)
+	}
+	if partner.Key, err = as2.ParsePrivateKey(bs); err != nil {
+		return nil, fmt.Errorf("parsing KeyFile: %v", err)
+	}
+	return partner, nil
+}
+
+func as2Client(cfg *service.AS2) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = fips.TLSConfig(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+	})
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.RequestTimeout(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (agent *AS2TransferAgent) ID() string {
+	return agent.cfg.ID
+}
+
+// Ping checks the partner's server responds, AS2 servers commonly reject GET requests so
+// any response other than a server error is accepted
+func (agent *AS2TransferAgent) Ping() error {
+	if agent == nil || agent.cfg.AS2 == nil {
+		return errors.New("nil AS2TransferAgent")
+	}
+	resp, err := agent.client.Get(agent.cfg.AS2.URL)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			err = fmt.Errorf("as2: unexpected %s", resp.Status)
+		}
+	}
+	if err != nil {
+		as2AgentUp.With("hostname", agent.Hostname()).Set(0)
+	} else {
+		as2AgentUp.With("hostname", agent.Hostname()).Set(1)
+	}
+	return err
+}
+
+func (agent *AS2TransferAgent) Close() error {
+	if agent == nil || agent.client == nil {
+		return nil
+	}
+	agent.client.CloseIdleConnections()
+	return nil
+}
+
+func (agent *AS2TransferAgent) InboundPath() string {
+	return currentPaths(agent.cfg.Paths, "").Inbound
+}
+
+func (agent *AS2TransferAgent) OutboundPath() string {
+	return currentPaths(agent.cfg.Paths, "").Outbound
+}
+
+func (agent *AS2TransferAgent) ReconciliationPath() string {
+	return currentPaths(agent.cfg.Paths, "").Reconciliation
+}
+
+func (agent *AS2TransferAgent) ReturnPath() string {
+	return currentPaths(agent.cfg.Paths, "").Return
+}
+
+func (agent *AS2TransferAgent) Hostname() string {
+	if agent == nil {
+		return ""
+	}
+	return agent.cfg.AS2.Hostname()
+}
+
+// UploadFile sends File to the partner as an AS2 message and checks the MDN when it's returned
+// synchronously. Async MDNs are checked once the partner POSTs them.
+//
+// The File's contents will always be closed
+func (agent *AS2TransferAgent) UploadFile(f File) error {
+	defer f.Close()
+
+	payload, err := io.ReadAll(f.Contents)
+	if err != nil {
+		return fmt.Errorf("as2: reading %s: %v", f.Filename, err)
+	}
+	mode := agent.cfg.AS2.MDNMode()
+	receipt := as2.ReceiptOptions{
+		Disabled: mode == "none",
+		Signed:   agent.cfg.AS2.MDN.Signed,
+	}
+	if mode == "async" {
+		receipt.AsyncURL = agent.cfg.AS2.MDN.AsyncURL
+	}
+	msg, err := agent.partner.NewMessage(f.Filename, payload, receipt)
+	if err != nil {
+		return fmt.Errorf("as2: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, agent.cfg.AS2.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return err
+	}
+	req.Header = msg.Header
+	if mode == "async" {
+		// Track the message before sending so a fast MDN isn't missed
+		pendingMDNs.add(agent.cfg.ID, msg)
+	}
+	resp, err := agent.client.Do(req)
+	if err != nil {
+		pendingMDNs.remove(msg.MessageID)
+		return fmt.Errorf("as2: sending %s: %v", filepath.Base(f.Filename), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		pendingMDNs.remove(msg.MessageID)
+		return fmt.Errorf("as2: sending %s: unexpected %s", filepath.Base(f.Filename), resp.Status)
+	}
+	if mode != "sync" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("as2: reading MDN for %s: %v", filepath.Base(f.Filename), err)
+	}
+	mdn, err := agent.partner.ReadMDN(resp.Header, body, agent.cfg.AS2.MDN.Signed)
+	if err != nil {
+		as2MDNs.With("agent", agent.cfg.ID, "mode", mode, "status", "invalid").Add(1)
+		return fmt.Errorf("as2: reading MDN for %s: %v", filepath.Base(f.Filename), err)
+	}
+	if err := checkMDN(mdn, msg.MessageID, msg.MIC); err != nil {
+		as2MDNs.With("agent", agent.cfg.ID, "mode", mode, "status", "failed").Add(1)
+		return fmt.Errorf("as2: %s: %v", filepath.Base(f.Filename), err)
+	}
+	as2MDNs.With("agent", agent.cfg.ID, "mode", mode, "status", "processed").Add(1)
+
+	agent.logger.Info().With(log.Fields{
+		"filename":   log.String(filepath.Base(f.Filename)),
+		"message_id": log.String(msg.MessageID),
+	}).Log("as2: partner processed message")
+	return nil
+}
+
+// checkMDN returns an error unless mdn successfully acknowledges the message
+func checkMDN(mdn *as2.MDN, messageID, mic string) error {
+	if mdn.OriginalMessageID != messageID {
+		return fmt.Errorf("MDN is for message %s, not %s", mdn.OriginalMessageID, messageID)
+	}
+	if err := mdn.Err(); err != nil {
+		return err
+	}
+	if !mdn.MatchesMIC(mic) {
+		return fmt.Errorf("MDN has Received-Content-MIC %q but %q was sent", mdn.MIC, mic)
+	}
+	return nil
+}
+
+func (agent *AS2TransferAgent) inbox() localDirectory {
+	return localDirectory{root: agent.cfg.AS2.Inbox, perm: 0600, downloads: agent.cfg.Downloads}
+}
+
+func (agent *AS2TransferAgent) Delete(path string) error {
+	if path == "" || strings.HasSuffix(path, "/") {
+		return fmt.Errorf("AS2TransferAgent: invalid path %v", path)
+	}
+	if err := agent.inbox().remove(path); err != nil {
+		return fmt.Errorf("as2: %v", err)
+	}
+	return nil
+}
+
+func (agent *AS2TransferAgent) Move(oldpath, newpath string) error {
+	if err := agent.inbox().move(oldpath, newpath); err != nil {
+		return fmt.Errorf("as2: %v", err)
+	}
+	return nil
+}
+
+func (agent *AS2TransferAgent) GetInboundFiles() ([]File, error) {
+	return agent.readFiles(agent.InboundPath(), agent.cfg.Paths.InboundFilter)
+}
+
+func (agent *AS2TransferAgent) GetReconciliationFiles() ([]File, error) {
+	return agent.readFiles(agent.ReconciliationPath(), agent.cfg.Paths.ReconciliationFilter)
+}
+
+func (agent *AS2TransferAgent) GetReturnFiles() ([]File, error) {
+	return agent.readFiles(agent.ReturnPath(), agent.cfg.Paths.ReturnFilter)
+}
+
+func (agent *AS2TransferAgent) readFiles(dir string, filter *service.FilenameFilter) ([]File, error) {
+	files, skipped, err := agent.inbox().readFiles(dir, filter, agent.cfg.Paths.Depth)
+
+	agent.mu.Lock()
+	agent.skipped = skipped
+	agent.mu.Unlock()
+
+	if err != nil {
+		return nil, fmt.Errorf("as2: %v", err)
+	}
+	return files, nil
+}
+
+func (agent *AS2TransferAgent) SkippedFiles() []SkippedFile {
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	return agent.skipped
+}
+
+// pendingMDNs are messages sent with an async MDN requested which haven't been acknowledged
+var pendingMDNs = &mdnTracker{
+	messages: make(map[string]pendingMDN),
+}
+
+// pendingMDNExpiration is how long an unacknowledged message is remembered
+const pendingMDNExpiration = 7 * 24 * time.Hour
+
+type pendingMDN struct {
+	agentID string
+	mic     string
+	sentAt  time.Time
+}
+
+type mdnTracker struct {
+	mu       sync.Mutex
+	messages map[string]pendingMDN
+}
+
+func (t *mdnTracker) add(agentID string, msg *as2.Outgoing) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for id, pending := range t.messages {
+		if now.Sub(pending.sentAt) > pendingMDNExpiration {
+			delete(t.messages, id)
+		}
+	}
+	t.messages[msg.MessageID] = pendingMDN{
+		agentID: agentID,
+		mic:     msg.MIC,
+		sentAt:  now,
+	}
+}
+
+func (t *mdnTracker) remove(messageID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.messages, messageID)
+}
+
+// take returns and forgets the pending message
+func (t *mdnTracker) take(messageID string) (pendingMDN, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending, exists := t.messages[messageID]
+	delete(t.messages, messageID)
+	return pending, exists
+}
@@ -0,0 +1,235 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/moov-io/achgateway/internal/as2"
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/gorilla/mux"
+	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	as2MessagesReceived = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "as2_messages_received",
+		Help: "Counter of AS2 messages received from partners",
+	}, []string{"agent", "status"})
+)
+
+// Responses for messages and MDNs which can't be read. Details are only logged so callers
+// can't learn why decryption or verification failed.
+const (
+	errAS2MessageRejected = "unable to process AS2 message"
+	errAS2MDNRejected     = "unable to process AS2 MDN"
+)
+
+// maxAS2MessageSize limits the size of messages and MDNs accepted from partners
+const maxAS2MessageSize = 100 * 1024 * 1024
+
+// AppendAS2Routes adds the endpoints AS2 partners send files and async MDNs to:
+//
+//	POST /as2/{agentID}       messages, written to the agent's Inbox
+//	POST /as2/{agentID}/mdn   async MDNs for messages we sent
+func AppendAS2Routes(logger log.Logger, cfg service.UploadAgents, r *mux.Router) error {
+	receivers := make(map[string]*as2Receiver)
+	for i := range cfg.Agents {
+		conf := cfg.Agents[i]
+		if conf.AS2 == nil {
+			continue
+		}
+		if err := conf.AS2.Validate(); err != nil {
+			return fmt.Errorf("agent %s: as2: %v", conf.ID, err)
+		}
+		partner, err := loadAS2Partner(conf.AS2)
+		if err != nil {
+			return fmt.Errorf("agent %s: as2: %v", conf.ID, err)
+		}
+		receivers[conf.ID] = &as2Receiver{
+			cfg:     conf,
+			partner: partner,
+			client:  as2Client(conf.AS2),
+			logger:  logger.Set("agent", log.String(conf.ID)),
+		}
+	}
+	if len(receivers) == 0 {
+		return nil
+	}
+	lookup := func(w http.ResponseWriter, r *http.Request) (*as2Receiver, []byte) {
+		recv, exists := receivers[mux.Vars(r)["agentID"]]
+		if !exists {
+			http.NotFound(w, r)
+			return nil, nil
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAS2MessageSize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("reading body: %v", err), http.StatusBadRequest)
+			return nil, nil
+		}
+		return recv, body
+	}
+	r.Methods("POST").Path("/as2/{agentID}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if recv, body := lookup(w, r); recv != nil {
+			recv.receiveMessage(w, r, body)
+		}
+	})
+	r.Methods("POST").Path("/as2/{agentID}/mdn").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if recv, body := lookup(w, r); recv != nil {
+			recv.receiveMDN(w, r, body)
+		}
+	})
+	return nil
+}
+
+type as2Receiver struct {
+	cfg     service.UploadAgent
+	partner *as2.Partner
+	client  *http.Client
+	logger  log.Logger
+}
+
+func (recv *as2Receiver) receiveMessage(w http.ResponseWriter, r *http.Request, body []byte) {
+	msg, err := recv.partner.ReadMessage(r.Header, body)
+	if err == nil {
+		err = recv.save(msg)
+	}
+	logger := recv.logger.With(log.Fields{
+		"message_id": log.String(msg.MessageID),
+		"filename":   log.String(msg.Filename),
+	})
+	if err != nil {
+		as2MessagesReceived.With("agent", recv.cfg.ID, "status", "failed").Add(1)
+		logger.Error().LogErrorf("as2: problem receiving message: %v", err)
+	} else {
+		as2MessagesReceived.With("agent", recv.cfg.ID, "status", "processed").Add(1)
+		logger.Info().Log("as2: received message")
+	}
+
+	if msg.Receipt.Disabled {
+		if err != nil {
+			http.Error(w, errAS2MessageRejected, http.StatusBadRequest)
+		}
+		return
+	}
+	header, mdn, mdnErr := recv.partner.NewMDN(msg, err)
+	if mdnErr != nil {
+		logger.Error().LogErrorf("as2: problem creating MDN: %v", mdnErr)
+		http.Error(w, "unable to create MDN", http.StatusInternalServerError)
+		return
+	}
+	if msg.Receipt.AsyncURL != "" {
+		// Anyone can POST to this endpoint, so MDNs are only sent to the partner's hosts and for
+		// messages whose signature was verified
+		if msg.Signed && recv.cfg.AS2.AllowsReceiptURL(msg.Receipt.AsyncURL) {
+			go recv.sendAsyncMDN(logger, msg.Receipt.AsyncURL, header, mdn)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		logger.Warn().Logf("as2: not sending async MDN to %s, returning it instead", msg.Receipt.AsyncURL)
+	}
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(mdn)
+}
+
+// save writes the message's payload into the agent's inbound path
+func (recv *as2Receiver) save(msg *as2.Message) error {
+	filename := msg.Filename
+	if filename == "" || filename == "." || filename == ".." || strings.HasPrefix(filename, ".") {
+		filename = strings.Trim(msg.MessageID, "<>") + ".ach"
+	}
+	filename = filepath.Base(filename)
+
+	inbox := localDirectory{root: recv.cfg.AS2.Inbox, perm: 0600}
+	inbound := currentPaths(recv.cfg.Paths, "").Inbound
+
+	// Partners resend messages when they miss our MDN, which is fine when nothing changed
+	if where, err := inbox.path(filepath.Join(inbound, filename)); err == nil {
+		if existing, err := os.ReadFile(where); err == nil {
+			if bytes.Equal(existing, msg.Payload) {
+				return nil
+			}
+			return &as2.DispositionError{
+				Description: "unexpected-processing-error",
+				Err:         fmt.Errorf("a different %s was already received", filename),
+			}
+		}
+	}
+	if err := inbox.write(inbound, filename, msg.Payload); err != nil {
+		return &as2.DispositionError{Description: "unexpected-processing-error", Err: err}
+	}
+	return nil
+}
+
+func (recv *as2Receiver) sendAsyncMDN(logger log.Logger, url string, header http.Header, body []byte) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		logger.Error().LogErrorf("as2: problem sending async MDN: %v", err)
+		return
+	}
+	req.Header = header
+	resp, err := recv.client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			err = fmt.Errorf("unexpected %s", resp.Status)
+		}
+	}
+	if err != nil {
+		logger.Error().LogErrorf("as2: problem sending async MDN to %s: %v", url, err)
+	}
+}
+
+func (recv *as2Receiver) receiveMDN(w http.ResponseWriter, r *http.Request, body []byte) {
+	mdn, err := recv.partner.ReadMDN(r.Header, body, recv.cfg.AS2.MDN.Signed)
+	if err != nil {
+		as2MDNs.With("agent", recv.cfg.ID, "mode", "async", "status", "invalid").Add(1)
+		recv.logger.Error().LogErrorf("as2: problem reading async MDN: %v", err)
+		http.Error(w, errAS2MDNRejected, http.StatusBadRequest)
+		return
+	}
+	logger := recv.logger.Set("message_id", log.String(mdn.OriginalMessageID))
+
+	pending, exists := pendingMDNs.take(mdn.OriginalMessageID)
+	if !exists || pending.agentID != recv.cfg.ID {
+		as2MDNs.With("agent", recv.cfg.ID, "mode", "async", "status", "unknown").Add(1)
+		logger.Warn().Log("as2: async MDN for unknown message")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err := checkMDN(mdn, mdn.OriginalMessageID, pending.mic); err != nil {
+		as2MDNs.With("agent", recv.cfg.ID, "mode", "async", "status", "failed").Add(1)
+		logger.Error().LogErrorf("as2: partner did not process message: %v", err)
+	} else {
+		as2MDNs.With("agent", recv.cfg.ID, "mode", "async", "status", "processed").Add(1)
+		logger.Info().Log("as2: partner processed message")
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,356 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/as2"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+// writeAS2Keys writes a self-signed certificate and key for name, returning their paths
+func writeAS2Keys(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+	return certFile, keyFile
+}
+
+// as2Partner is a trading partner's AS2 server
+type as2Partner struct {
+	t       *testing.T
+	partner *as2.Partner
+
+	mu       sync.Mutex
+	received map[string][]byte
+	failure  error
+}
+
+func (p *as2Partner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	msg, err := p.partner.ReadMessage(r.Header, body)
+
+	p.mu.Lock()
+	if err == nil {
+		err = p.failure
+	}
+	if err == nil {
+		p.received[msg.Filename] = msg.Payload
+	}
+	p.mu.Unlock()
+
+	header, mdn, mdnErr := p.partner.NewMDN(msg, err)
+	require.NoError(p.t, mdnErr)
+	if url := msg.Receipt.AsyncURL; url != "" {
+		go func() {
+			req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(mdn))
+			req.Header = header
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return
+	}
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	w.Write(mdn)
+}
+
+func setupAS2(t *testing.T) (*service.UploadAgent, *as2Partner, *httptest.Server) {
+	t.Helper()
+
+	dir := t.TempDir()
+	ourCert, ourKey := writeAS2Keys(t, dir, "achgateway")
+	theirCert, theirKey := writeAS2Keys(t, dir, "partner")
+
+	partnerCfg := &service.AS2{
+		AS2From:         "ODFI BANK",
+		AS2To:           "ACHGATEWAY",
+		CertFile:        theirCert,
+		KeyFile:         theirKey,
+		PartnerCertFile: ourCert,
+	}
+	loaded, err := loadAS2Partner(partnerCfg)
+	require.NoError(t, err)
+
+	partner := &as2Partner{t: t, partner: loaded, received: make(map[string][]byte)}
+	server := httptest.NewServer(partner)
+	t.Cleanup(server.Close)
+
+	cfg := &service.UploadAgent{
+		ID: "as2-partner",
+		AS2: &service.AS2{
+			URL:             server.URL + "/as2",
+			AS2From:         "ACHGATEWAY",
+			AS2To:           "ODFI BANK",
+			CertFile:        ourCert,
+			KeyFile:         ourKey,
+			PartnerCertFile: theirCert,
+			MDN: service.AS2MDN{
+				Signed: true,
+			},
+			Inbox: filepath.Join(dir, "inbox"),
+		},
+		Paths: service.UploadPaths{
+			Inbound: "inbound",
+			Return:  "returned",
+		},
+	}
+	return cfg, partner, server
+}
+
+func TestAS2__UploadFile(t *testing.T) {
+	cfg, partner, _ := setupAS2(t)
+
+	agent, err := newAS2TransferAgent(log.NewTestLogger(), cfg)
+	require.NoError(t, err)
+	defer agent.Close()
+	require.NoError(t, agent.Ping())
+
+	err = agent.UploadFile(File{
+		Filename: "20220601-0001.ach",
+		Contents: io.NopCloser(strings.NewReader("ach file contents")),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "ach file contents", string(partner.received["20220601-0001.ach"]))
+
+	// Partner fails to process the message
+	partner.failure = &as2.DispositionError{Description: "unexpected-processing-error"}
+	err = agent.UploadFile(File{
+		Filename: "20220601-0002.ach",
+		Contents: io.NopCloser(strings.NewReader("ach file contents")),
+	})
+	require.ErrorContains(t, err, "processed/error: unexpected-processing-error")
+}
+
+func TestAS2__AsyncMDN(t *testing.T) {
+	cfg, partner, _ := setupAS2(t)
+
+	router := mux.NewRouter()
+	ours := httptest.NewServer(router)
+	defer ours.Close()
+
+	cfg.AS2.MDN.Mode = "async"
+	cfg.AS2.MDN.AsyncURL = ours.URL + "/as2/as2-partner/mdn"
+	require.NoError(t, AppendAS2Routes(log.NewTestLogger(), service.UploadAgents{Agents: []service.UploadAgent{*cfg}}, router))
+
+	agent, err := newAS2TransferAgent(log.NewTestLogger(), cfg)
+	require.NoError(t, err)
+
+	err = agent.UploadFile(File{
+		Filename: "20220601-0001.ach",
+		Contents: io.NopCloser(strings.NewReader("ach file contents")),
+	})
+	require.NoError(t, err)
+
+	// The partner POSTs the MDN after responding
+	require.Eventually(t, func() bool {
+		pendingMDNs.mu.Lock()
+		defer pendingMDNs.mu.Unlock()
+		for _, pending := range pendingMDNs.messages {
+			if pending.agentID == cfg.ID {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	partner.mu.Lock()
+	defer partner.mu.Unlock()
+	require.Equal(t, "ach file contents", string(partner.received["20220601-0001.ach"]))
+}
+
+func TestAS2__Receive(t *testing.T) {
+	cfg, partner, _ := setupAS2(t)
+
+	router := mux.NewRouter()
+	require.NoError(t, AppendAS2Routes(log.NewTestLogger(), service.UploadAgents{Agents: []service.UploadAgent{*cfg}}, router))
+
+	send := func(agentID string, filename, contents string) *httptest.ResponseRecorder {
+		msg, err := partner.partner.NewMessage(filename, []byte(contents), as2.ReceiptOptions{Signed: true})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/as2/"+agentID, bytes.NewReader(msg.Body))
+		req.Header = msg.Header
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		w.Flush()
+		require.Equal(t, http.StatusOK, w.Code)
+
+		mdn, err := partner.partner.ReadMDN(w.Result().Header, w.Body.Bytes(), true)
+		require.NoError(t, err)
+		require.Equal(t, msg.MessageID, mdn.OriginalMessageID)
+		return w
+	}
+	send(cfg.ID, "return.ach", "returned entries")
+
+	// Resending the same file is accepted
+	send(cfg.ID, "return.ach", "returned entries")
+
+	// Unknown agent
+	req := httptest.NewRequest(http.MethodPost, "/as2/other", strings.NewReader("body"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	agent, err := newAS2TransferAgent(log.NewTestLogger(), cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(cfg.AS2.Inbox, "inbound", "archive"), 0750))
+	files, err := agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "return.ach", files[0].Filename)
+	bs, _ := io.ReadAll(files[0].Contents)
+	require.Equal(t, "returned entries", string(bs))
+	require.Equal(t, []SkippedFile{{Path: "inbound/archive", Reason: SkipDirectory}}, agent.SkippedFiles())
+
+	// Nothing has been received here
+	files, err = agent.GetReturnFiles()
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	require.NoError(t, agent.Delete("inbound/return.ach"))
+	require.NoError(t, agent.Delete("inbound/return.ach"))
+	require.Error(t, agent.Delete("../../etc/passwd"))
+
+	files, err = agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func TestAS2__ReceiveAsyncMDN(t *testing.T) {
+	cfg, partner, _ := setupAS2(t)
+
+	router := mux.NewRouter()
+	require.NoError(t, AppendAS2Routes(log.NewTestLogger(), service.UploadAgents{Agents: []service.UploadAgent{*cfg}}, router))
+
+	mdns := make(chan []byte, 10)
+	receipts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mdns <- body
+	}))
+	defer receipts.Close()
+
+	send := func(header http.Header, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/as2/"+cfg.ID, bytes.NewReader(body))
+		req.Header = header
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Signed messages get their MDN sent to the partner's host
+	msg, err := partner.partner.NewMessage("async.ach", []byte("entries"), as2.ReceiptOptions{AsyncURL: receipts.URL})
+	require.NoError(t, err)
+	w := send(msg.Header, msg.Body)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Body.Bytes())
+	select {
+	case <-mdns:
+	case <-time.After(5 * time.Second):
+		t.Fatal("async MDN not sent")
+	}
+
+	// Other hosts get the MDN in the response
+	msg, err = partner.partner.NewMessage("other.ach", []byte("entries"), as2.ReceiptOptions{AsyncURL: "http://internal.example.com/admin"})
+	require.NoError(t, err)
+	w = send(msg.Header, msg.Body)
+	require.Equal(t, http.StatusOK, w.Code)
+	mdn, err := partner.partner.ReadMDN(w.Result().Header, w.Body.Bytes(), false)
+	require.NoError(t, err)
+	require.Equal(t, msg.MessageID, mdn.OriginalMessageID)
+
+	// Messages which fail verification don't send an MDN anywhere
+	header := msg.Header.Clone()
+	header.Set("Message-ID", "<forged@example.com>")
+	w = send(header, []byte("not encrypted"))
+	require.Equal(t, http.StatusOK, w.Code)
+	mdn, err = partner.partner.ReadMDN(w.Result().Header, w.Body.Bytes(), false)
+	require.NoError(t, err)
+	require.Error(t, mdn.Err())
+
+	// Without a receipt the reason isn't returned
+	header.Del("Disposition-Notification-To")
+	w = send(header, []byte("not encrypted"))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, errAS2MessageRejected, strings.TrimSpace(w.Body.String()))
+
+	select {
+	case <-mdns:
+		t.Fatal("unexpected async MDN")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestAS2__Diagnose(t *testing.T) {
+	cfg, _, _ := setupAS2(t)
+	agents := service.UploadAgents{Agents: []service.UploadAgent{*cfg}}
+
+	diag, err := Diagnose(log.NewTestLogger(), agents, cfg.ID, DoctorOptions{})
+	require.NoError(t, err)
+	require.False(t, diag.Failed())
+
+	statuses := make(map[string]CheckStatus)
+	for _, check := range diag.Checks {
+		statuses[check.Name] = check.Status
+	}
+	require.Equal(t, CheckOK, statuses["certificates"])
+	require.Equal(t, CheckOK, statuses["connect"])
+	require.Equal(t, CheckOK, statuses["inbound path"])
+	require.Equal(t, CheckSkipped, statuses["transfer"])
+}
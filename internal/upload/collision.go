@@ -0,0 +1,109 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// maxCollisionSuffix is the highest sequence number tried for a file which collides
+const maxCollisionSuffix = 99
+
+var (
+	filenameCollisions = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "upload_filename_collisions",
+		Help: "Counter of outbound files with the same name as a file on the remote server",
+	}, []string{"hostname", "policy"})
+)
+
+// FileChecker is implemented by Agents which can check for a file on the remote server.
+type FileChecker interface {
+	Exists(path string) (bool, error)
+}
+
+// Exists returns true when path is a file on agent's remote server, or an error if agent
+// can't check for files.
+func Exists(agent Agent, path string) (bool, error) {
+	if fc, ok := agent.(FileChecker); ok {
+		return fc.Exists(path)
+	}
+	return false, fmt.Errorf("agent %s can't check for remote files", agent.ID())
+}
+
+// Collision describes an outbound file which had the same name as a file on the remote server.
+type Collision struct {
+	// Policy is the agent's OnCollision which was followed
+	Policy string
+
+	// Filename is the name which was already taken
+	Filename string
+}
+
+// AvoidCollision checks for a file on agent's remote server where f would be uploaded and
+// follows cfg.OnCollision when there is one. f is returned renamed when a suffix is added.
+// Nothing is checked when OnCollision is empty.
+func AvoidCollision(agent Agent, cfg *service.UploadAgent, f File) (File, *Collision, error) {
+	if cfg == nil || cfg.OnCollision == "" {
+		return f, nil, nil
+	}
+	exists, err := Exists(agent, RemotePath(cfg, f))
+	if err != nil {
+		return f, nil, fmt.Errorf("checking for %s: %v", f.Filename, err)
+	}
+	if !exists {
+		return f, nil, nil
+	}
+	filenameCollisions.With("hostname", agent.Hostname(), "policy", cfg.OnCollision).Add(1)
+	collision := &Collision{Policy: cfg.OnCollision, Filename: f.Filename}
+
+	switch cfg.OnCollision {
+	case service.CollisionError:
+		return f, collision, fmt.Errorf("%s already exists on %s", RemotePath(cfg, f), agent.Hostname())
+
+	case service.CollisionSuffix:
+		for seq := 1; seq <= maxCollisionSuffix; seq++ {
+			renamed := f
+			renamed.Filename = suffixFilename(f.Filename, seq)
+
+			exists, err := Exists(agent, RemotePath(cfg, renamed))
+			if err != nil {
+				return f, collision, fmt.Errorf("checking for %s: %v", renamed.Filename, err)
+			}
+			if !exists {
+				return renamed, collision, nil
+			}
+		}
+		return f, collision, fmt.Errorf("%s and its %d suffixes already exist on %s", f.Filename, maxCollisionSuffix, agent.Hostname())
+	}
+	return f, collision, nil
+}
+
+// suffixFilename adds seq before the extensions of filename, so FILE.ach.gpg becomes FILE-1.ach.gpg
+func suffixFilename(filename string, seq int) string {
+	name, ext := filename, ""
+	if idx := strings.Index(filename, "."); idx > 0 {
+		name, ext = filename[:idx], filename[idx:]
+	}
+	return fmt.Sprintf("%s-%d%s", name, seq, ext)
+}
@@ -0,0 +1,95 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvoidCollision(t *testing.T) {
+	agent, dir := setupFilesystem(t)
+	cfg := &agent.cfg
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "outbound"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "outbound", "FILE.ach"), []byte("existing"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "outbound", "FILE-1.ach"), []byte("existing"), 0600))
+
+	f := File{Filename: "FILE.ach"}
+
+	// Remote files aren't checked without a policy
+	out, collision, err := AvoidCollision(agent, cfg, f)
+	require.NoError(t, err)
+	require.Nil(t, collision)
+	require.Equal(t, "FILE.ach", out.Filename)
+
+	cfg.OnCollision = service.CollisionError
+	_, collision, err = AvoidCollision(agent, cfg, f)
+	require.ErrorContains(t, err, "outbound/FILE.ach already exists")
+	require.Equal(t, &Collision{Policy: service.CollisionError, Filename: "FILE.ach"}, collision)
+
+	cfg.OnCollision = service.CollisionOverwrite
+	out, collision, err = AvoidCollision(agent, cfg, f)
+	require.NoError(t, err)
+	require.Equal(t, service.CollisionOverwrite, collision.Policy)
+	require.Equal(t, "FILE.ach", out.Filename)
+
+	cfg.OnCollision = service.CollisionSuffix
+	out, collision, err = AvoidCollision(agent, cfg, f)
+	require.NoError(t, err)
+	require.Equal(t, &Collision{Policy: service.CollisionSuffix, Filename: "FILE.ach"}, collision)
+	require.Equal(t, "FILE-2.ach", out.Filename)
+
+	// Files which don't collide are left alone
+	out, collision, err = AvoidCollision(agent, cfg, File{Filename: "OTHER.ach"})
+	require.NoError(t, err)
+	require.Nil(t, collision)
+	require.Equal(t, "OTHER.ach", out.Filename)
+}
+
+func TestAvoidCollision__Unsupported(t *testing.T) {
+	cfg := &service.UploadAgent{OnCollision: service.CollisionError}
+	_, _, err := AvoidCollision(&HTTPSTransferAgent{}, cfg, File{Filename: "FILE.ach"})
+	require.ErrorContains(t, err, "can't check for remote files")
+}
+
+func TestSuffixFilename(t *testing.T) {
+	require.Equal(t, "FILE-1.ach", suffixFilename("FILE.ach", 1))
+	require.Equal(t, "FILE-12.ach.gpg", suffixFilename("FILE.ach.gpg", 12))
+	require.Equal(t, "FILE-3", suffixFilename("FILE", 3))
+	require.Equal(t, ".hidden-1", suffixFilename(".hidden", 1))
+}
+
+func TestExists__S3(t *testing.T) {
+	agent := newTestS3Agent(t)
+	require.NoError(t, agent.bucket.WriteAll(context.Background(), "outbound/FILE.ach", []byte("file"), nil))
+
+	exists, err := Exists(agent, "outbound/FILE.ach")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	exists, err = Exists(agent, "outbound/OTHER.ach")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
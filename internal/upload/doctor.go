@@ -0,0 +1,864 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/as2"
+	"github.com/moov-io/achgateway/internal/fips"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/sshx"
+	"github.com/moov-io/base/log"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/pkg/sftp"
+	"gocloud.dev/blob"
+	"golang.org/x/crypto/ssh"
+)
+
+// CheckStatus is the outcome of a single diagnostic check
+type CheckStatus string
+
+const (
+	CheckOK      CheckStatus = "ok"
+	CheckWarning CheckStatus = "warning"
+	CheckFailed  CheckStatus = "failed"
+	CheckSkipped CheckStatus = "skipped"
+)
+
+// Check is one step Diagnose performed against a remote server
+type Check struct {
+	Name     string
+	Status   CheckStatus
+	Detail   string
+	Duration time.Duration
+}
+
+// Diagnosis is the report of every check Diagnose performed for an Agent
+type Diagnosis struct {
+	AgentID  string
+	Hostname string
+	Checks   []Check
+}
+
+func (d *Diagnosis) add(name string, status CheckStatus, detail string, dur time.Duration) {
+	d.Checks = append(d.Checks, Check{
+		Name:     name,
+		Status:   status,
+		Detail:   detail,
+		Duration: dur,
+	})
+}
+
+// Failed returns true if any check failed
+func (d *Diagnosis) Failed() bool {
+	if d == nil {
+		return false
+	}
+	for i := range d.Checks {
+		if d.Checks[i].Status == CheckFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteTo prints the diagnosis as a table of checks
+func (d *Diagnosis) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Agent:    %s\n", d.AgentID)
+	fmt.Fprintf(&buf, "Hostname: %s\n\n", d.Hostname)
+
+	var failed, warnings int
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDURATION\tDETAIL")
+	for _, c := range d.Checks {
+		dur := "-"
+		if c.Duration > 0 {
+			dur = c.Duration.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Name, c.Status, dur, c.Detail)
+
+		switch c.Status {
+		case CheckFailed:
+			failed++
+		case CheckWarning:
+			warnings++
+		}
+	}
+	tw.Flush()
+	fmt.Fprintf(&buf, "\n%d checks, %d failed, %d warnings\n", len(d.Checks), failed, warnings)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// DoctorOptions controls which checks Diagnose performs
+type DoctorOptions struct {
+	// TransferSize is how many bytes are written to and read back from the
+	// outbound path to measure throughput. Defaults to 1MiB.
+	TransferSize int64
+
+	// SkipTransfer turns off writing a temporary file to the remote server.
+	SkipTransfer bool
+}
+
+func (opts DoctorOptions) transferSize() int64 {
+	if opts.TransferSize > 0 {
+		return opts.TransferSize
+	}
+	return 1024 * 1024
+}
+
+// Diagnose connects to the remote server of an Agent with its configured credentials and
+// checks host keys, each configured path and transfer throughput.
+//
+// Agents are created for each call rather than shared with New so a diagnosis never
+// disrupts the connections of a running instance.
+func Diagnose(logger log.Logger, cfg service.UploadAgents, id string, opts DoctorOptions) (*Diagnosis, error) {
+	conf := cfg.Find(id)
+	if conf == nil {
+		return nil, fmt.Errorf("upload: unknown Agent ID=%s", id)
+	}
+	diag := &Diagnosis{
+		AgentID:  id,
+		Hostname: conf.Hostname(),
+	}
+	switch {
+	case conf.FTP != nil:
+		diagnoseFTP(logger, conf, opts, diag)
+	case conf.SFTP != nil:
+		diagnoseSFTP(logger, conf, opts, diag)
+	case conf.S3 != nil:
+		diagnoseS3(logger, conf, opts, diag)
+	case conf.HTTPS != nil:
+		diagnoseHTTPS(logger, conf, opts, diag)
+	case conf.AS2 != nil:
+		diagnoseAS2(logger, conf, opts, diag)
+	case conf.Filesystem != nil:
+		diagnoseFilesystem(logger, conf, opts, diag)
+	case conf.Mock != nil:
+		diag.Hostname = (&MockAgent{}).Hostname()
+		diag.add("connect", CheckOK, "mock agent", 0)
+	default:
+		return nil, fmt.Errorf("upload: Agent ID=%s has no FTP, SFTP, S3, HTTPS, AS2, Filesystem or Mock config", id)
+	}
+	return diag, nil
+}
+
+type doctorPath struct {
+	name string
+	path string
+}
+
+func doctorPaths(paths service.UploadPaths) []doctorPath {
+	return []doctorPath{
+		{name: "inbound", path: paths.Inbound},
+		{name: "outbound", path: paths.Outbound},
+		{name: "reconciliation", path: paths.Reconciliation},
+		{name: "return", path: paths.Return},
+	}
+}
+
+func doctorFilename() string {
+	return fmt.Sprintf(".achgateway-doctor-%d.tmp", time.Now().UnixNano())
+}
+
+// doctorContents returns random data so throughput isn't skewed by compression
+func doctorContents(size int64) []byte {
+	data := make([]byte, size)
+	r := rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec
+	r.Read(data)
+	return data
+}
+
+func throughput(size int64, dur time.Duration) string {
+	if dur <= 0 {
+		dur = time.Nanosecond
+	}
+	mbps := float64(size) / dur.Seconds() / (1024 * 1024)
+	return fmt.Sprintf("%d bytes in %v (%.2f MiB/s)", size, dur.Round(time.Millisecond), mbps)
+}
+
+func allowedIPsCheck(conf *service.UploadAgent, hostname string, diag *Diagnosis) bool {
+	start := time.Now()
+	if err := rejectOutboundIPRange(conf.SplitAllowedIPs(), hostname); err != nil {
+		diag.add("allowed ips", CheckFailed, err.Error(), time.Since(start))
+		return false
+	}
+	if conf.AllowedIPs == "" {
+		diag.add("allowed ips", CheckOK, "hostname resolved, no allowed_ips configured", time.Since(start))
+	} else {
+		diag.add("allowed ips", CheckOK, "hostname resolved within allowed_ips", time.Since(start))
+	}
+	return true
+}
+
+func skipRemaining(diag *Diagnosis, paths service.UploadPaths, reason string) {
+	for _, p := range doctorPaths(paths) {
+		if p.path != "" {
+			diag.add(p.name+" path", CheckSkipped, reason, 0)
+		}
+	}
+	diag.add("transfer", CheckSkipped, reason, 0)
+}
+
+func diagnoseSFTP(logger log.Logger, conf *service.UploadAgent, opts DoctorOptions, diag *Diagnosis) {
+	if !allowedIPsCheck(conf, conf.SFTP.Hostname, diag) {
+		diag.add("host key", CheckSkipped, "hostname is not allowed", 0)
+		diag.add("connect", CheckSkipped, "hostname is not allowed", 0)
+		skipRemaining(diag, conf.Paths, "hostname is not allowed")
+		return
+	}
+	if !hostKeyCheck(conf.SFTP, diag) {
+		diag.add("connect", CheckSkipped, "host key did not match", 0)
+		skipRemaining(diag, conf.Paths, "host key did not match")
+		return
+	}
+	if !clientCertificateCheck(conf.SFTP, diag) {
+		diag.add("connect", CheckSkipped, "client certificate is not valid", 0)
+		skipRemaining(diag, conf.Paths, "client certificate is not valid")
+		return
+	}
+
+	agent := &SFTPTransferAgent{cfg: *conf, logger: logger}
+	agent.conns = newSFTPPool(logger, agent.cfg)
+	defer agent.Close()
+
+	start := time.Now()
+	client, release, err := agent.connection()
+	if err != nil {
+		diag.add("connect", CheckFailed, err.Error(), time.Since(start))
+		skipRemaining(diag, conf.Paths, "unable to connect")
+		return
+	}
+	defer release()
+	diag.add("connect", CheckOK, fmt.Sprintf("authenticated as %s", conf.SFTP.Username), time.Since(start))
+
+	for _, p := range doctorPaths(currentPaths(conf.Paths, "")) {
+		if p.path == "" {
+			continue
+		}
+		start := time.Now()
+		status, detail := sftpPathCheck(client, p.path)
+		diag.add(p.name+" path", status, detail, time.Since(start))
+	}
+
+	if opts.SkipTransfer {
+		diag.add("transfer", CheckSkipped, "skipped by request", 0)
+		return
+	}
+	start = time.Now()
+	status, detail := sftpTransferCheck(client, currentPaths(conf.Paths, "").Outbound, opts.transferSize())
+	diag.add("transfer", status, detail, time.Since(start))
+}
+
+// clientCertificateCheck reports who the client certificate is for and when it expires, when
+// one is configured.
+func clientCertificateCheck(cfg *service.SFTP, diag *Diagnosis) bool {
+	if cfg.ClientCertificate == "" && cfg.ClientCertificateFile == "" {
+		return true
+	}
+	start := time.Now()
+	cert, err := clientCertificate(cfg)
+	if err != nil {
+		diag.add("client certificate", CheckFailed, err.Error(), time.Since(start))
+		return false
+	}
+	detail := fmt.Sprintf("principals %s", strings.Join(cert.ValidPrincipals, ","))
+	if expires := sshx.CertificateExpiry(cert); !expires.IsZero() {
+		detail += fmt.Sprintf(", expires %s", expires.Format(time.RFC3339))
+	}
+	diag.add("client certificate", CheckOK, detail, time.Since(start))
+	return true
+}
+
+// hostKeyCheck performs an SSH handshake to read the server's host key and compares it
+// against the configured key. The handshake is aborted before authenticating.
+func hostKeyCheck(cfg *service.SFTP, diag *Diagnosis) bool {
+	start := time.Now()
+	key, err := probeHostKey(cfg)
+	if err != nil {
+		diag.add("host key", CheckFailed, err.Error(), time.Since(start))
+		return false
+	}
+	fingerprint := ssh.FingerprintSHA256(key)
+
+	if cfg.HostCertificateAuthority != "" || len(cfg.HostPublicKeys) > 0 || cfg.KnownHostsFile != "" {
+		return hostKeysCheck(cfg, key, diag, start)
+	}
+	if cfg.HostPublicKey == "" {
+		diag.add("host key", CheckWarning, fmt.Sprintf("%s %s is not validated, set host_public_key", key.Type(), fingerprint), time.Since(start))
+		return true
+	}
+	expected, err := sshx.ReadPubKey([]byte(cfg.HostPublicKey))
+	if err != nil {
+		diag.add("host key", CheckFailed, fmt.Sprintf("problem parsing host_public_key: %v", err), time.Since(start))
+		return false
+	}
+	if !bytes.Equal(expected.Marshal(), key.Marshal()) {
+		diag.add("host key", CheckFailed, fmt.Sprintf("server presented %s %s but host_public_key is %s %s",
+			key.Type(), fingerprint, expected.Type(), ssh.FingerprintSHA256(expected)), time.Since(start))
+		return false
+	}
+	diag.add("host key", CheckOK, fmt.Sprintf("%s %s matches host_public_key", key.Type(), fingerprint), time.Since(start))
+	return true
+}
+
+// hostKeysCheck verifies key is one of the configured or known_hosts keys, or a host certificate
+// signed by a trusted authority.
+func hostKeysCheck(cfg *service.SFTP, key ssh.PublicKey, diag *Diagnosis, start time.Time) bool {
+	hostKeys, err := sftpHostKeys(cfg)
+	if err != nil {
+		diag.add("host key", CheckFailed, err.Error(), time.Since(start))
+		return false
+	}
+	if err := hostKeys.HostKeyCallback()(cfg.Hostname, nil, key); err != nil {
+		diag.add("host key", CheckFailed, fmt.Sprintf("%s %s: %v", key.Type(), ssh.FingerprintSHA256(key), err), time.Since(start))
+		return false
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		if len(hostKeys.Authorities) > 0 {
+			diag.add("host key", CheckWarning, fmt.Sprintf("server presented plain %s %s which is a known host key",
+				key.Type(), ssh.FingerprintSHA256(key)), time.Since(start))
+			return true
+		}
+		diag.add("host key", CheckOK, fmt.Sprintf("%s %s is one of %d known host keys",
+			key.Type(), ssh.FingerprintSHA256(key), len(hostKeys.Keys)), time.Since(start))
+		return true
+	}
+	detail := fmt.Sprintf("certificate %s signed by %s", ssh.FingerprintSHA256(cert.Key), ssh.FingerprintSHA256(cert.SignatureKey))
+	if cert.ValidBefore != ssh.CertTimeInfinity {
+		detail += fmt.Sprintf(" valid until %s", time.Unix(int64(cert.ValidBefore), 0).UTC().Format(time.RFC3339))
+	}
+	diag.add("host key", CheckOK, detail, time.Since(start))
+	return true
+}
+
+var errHostKeyCaptured = errors.New("host key captured")
+
+func probeHostKey(cfg *service.SFTP) (ssh.PublicKey, error) {
+	sshConf := ssh.Config{}
+	sshConf.SetDefaults()
+	sshConf.KeyExchanges = append(
+		sshConf.KeyExchanges,
+		"diffie-hellman-group-exchange-sha256",
+	)
+
+	var captured ssh.PublicKey
+	conf := &ssh.ClientConfig{
+		Config:  sshConf,
+		User:    cfg.Username,
+		Timeout: cfg.Timeout(),
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			captured = key
+			return errHostKeyCaptured
+		},
+	}
+	conf.SetDefaults()
+	fips.SSHConfig(conf)
+	sshAlgorithms(conf, cfg)
+	if cfg.HostCertificateAuthority != "" || cfg.KnownHostsFile != "" {
+		conf.HostKeyAlgorithms = sshx.PreferCertAlgorithms(conf.HostKeyAlgorithms)
+	}
+
+	client, err := sshDial(cfg, conf)
+	if client != nil {
+		client.Close()
+	}
+	if captured != nil {
+		return captured, nil
+	}
+	if err == nil {
+		err = errors.New("no host key presented")
+	}
+	return nil, fips.Wrap(cfg.Hostname, err)
+}
+
+func sftpPathCheck(client *sftp.Client, dir string) (CheckStatus, string) {
+	info, err := client.Stat(dir)
+	if err != nil {
+		return CheckFailed, fmt.Sprintf("%s: %v", dir, err)
+	}
+	if !info.IsDir() {
+		return CheckFailed, fmt.Sprintf("%s is not a directory", dir)
+	}
+	items, err := client.ReadDir(dir)
+	if err != nil {
+		return CheckFailed, fmt.Sprintf("%s (%v) is not readable: %v", dir, info.Mode(), err)
+	}
+	return CheckOK, fmt.Sprintf("%s (%v) readable, %d entries", dir, info.Mode(), len(items))
+}
+
+func sftpTransferCheck(client *sftp.Client, dir string, size int64) (CheckStatus, string) {
+	if dir == "" {
+		return CheckSkipped, "no outbound path configured"
+	}
+	data := doctorContents(size)
+	filename := path.Join(dir, doctorFilename())
+
+	start := time.Now()
+	fd, err := client.Create(filename)
+	if err != nil {
+		return CheckFailed, fmt.Sprintf("unable to create %s: %v", filename, err)
+	}
+	if _, err := fd.ReadFrom(bytes.NewReader(data)); err != nil {
+		fd.Close()
+		client.Remove(filename)
+		return CheckFailed, fmt.Sprintf("unable to write %s: %v", filename, err)
+	}
+	if err := fd.Close(); err != nil {
+		client.Remove(filename)
+		return CheckFailed, fmt.Sprintf("unable to write %s: %v", filename, err)
+	}
+	uploaded := time.Since(start)
+
+	start = time.Now()
+	fd, err = client.Open(filename)
+	if err != nil {
+		client.Remove(filename)
+		return CheckFailed, fmt.Sprintf("unable to open %s: %v", filename, err)
+	}
+	h := sha256.New()
+	_, err = fd.WriteTo(h)
+	fd.Close()
+	downloaded := time.Since(start)
+
+	removeErr := client.Remove(filename)
+
+	return transferResult(data, h.Sum(nil), uploaded, downloaded, err, removeErr, filename)
+}
+
+func transferResult(data, downloaded []byte, upDur, downDur time.Duration, readErr, removeErr error, filename string) (CheckStatus, string) {
+	if readErr != nil {
+		return CheckFailed, fmt.Sprintf("unable to read %s: %v", filename, readErr)
+	}
+	expected := sha256.Sum256(data)
+	if !bytes.Equal(expected[:], downloaded) {
+		return CheckFailed, fmt.Sprintf("contents of %s changed after upload", filename)
+	}
+	size := int64(len(data))
+	detail := fmt.Sprintf("upload %s, download %s", throughput(size, upDur), throughput(size, downDur))
+	if removeErr != nil {
+		return CheckWarning, fmt.Sprintf("%s, unable to delete %s: %v", detail, filename, removeErr)
+	}
+	return CheckOK, detail
+}
+
+func diagnoseFTP(logger log.Logger, conf *service.UploadAgent, opts DoctorOptions, diag *Diagnosis) {
+	if !allowedIPsCheck(conf, conf.FTP.Hostname, diag) {
+		diag.add("tls", CheckSkipped, "hostname is not allowed", 0)
+		diag.add("connect", CheckSkipped, "hostname is not allowed", 0)
+		skipRemaining(diag, conf.Paths, "hostname is not allowed")
+		return
+	}
+
+	start := time.Now()
+	if mode := conf.FTP.TLSMode(); mode == "" {
+		diag.add("tls", CheckWarning, "no ca_file or tls configured, connection is not encrypted", 0)
+	} else if _, err := ftpTLSConfig(conf.FTP, nil); err != nil {
+		diag.add("tls", CheckFailed, err.Error(), time.Since(start))
+		diag.add("connect", CheckSkipped, "invalid tls config", 0)
+		skipRemaining(diag, conf.Paths, "invalid tls config")
+		return
+	} else {
+		diag.add("tls", CheckOK, fmt.Sprintf("using %s tls", mode), time.Since(start))
+	}
+
+	agent := &FTPTransferAgent{cfg: *conf, logger: logger, tlsSessions: newFTPSessionCache(conf.FTP)}
+	defer agent.Close()
+
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	start = time.Now()
+	conn, err := agent.connection()
+	if err != nil {
+		diag.add("connect", CheckFailed, err.Error(), time.Since(start))
+		skipRemaining(diag, conf.Paths, "unable to connect")
+		return
+	}
+	diag.add("connect", CheckOK, fmt.Sprintf("authenticated as %s", conf.FTP.Username), time.Since(start))
+
+	wd, err := conn.CurrentDir()
+	if err != nil {
+		diag.add("working directory", CheckFailed, err.Error(), 0)
+		skipRemaining(diag, conf.Paths, "unable to read working directory")
+		return
+	}
+
+	for _, p := range doctorPaths(currentPaths(conf.Paths, "")) {
+		if p.path == "" {
+			continue
+		}
+		start := time.Now()
+		status, detail := ftpPathCheck(conn, wd, p.path)
+		diag.add(p.name+" path", status, detail, time.Since(start))
+	}
+
+	if opts.SkipTransfer {
+		diag.add("transfer", CheckSkipped, "skipped by request", 0)
+		return
+	}
+	start = time.Now()
+	status, detail := ftpTransferCheck(conn, wd, currentPaths(conf.Paths, "").Outbound, opts.transferSize())
+	diag.add("transfer", status, detail, time.Since(start))
+}
+
+func ftpPathCheck(conn *ftp.ServerConn, wd, dir string) (CheckStatus, string) {
+	if err := conn.ChangeDir(dir); err != nil {
+		return CheckFailed, fmt.Sprintf("%s: %v", dir, err)
+	}
+	defer conn.ChangeDir(wd)
+
+	items, err := conn.NameList("")
+	if err != nil && !strings.Contains(err.Error(), "No files found") {
+		return CheckFailed, fmt.Sprintf("%s is not readable: %v", dir, err)
+	}
+	return CheckOK, fmt.Sprintf("%s readable, %d entries", dir, len(items))
+}
+
+func ftpTransferCheck(conn *ftp.ServerConn, wd, dir string, size int64) (CheckStatus, string) {
+	if dir == "" {
+		return CheckSkipped, "no outbound path configured"
+	}
+	if err := conn.ChangeDir(dir); err != nil {
+		return CheckFailed, fmt.Sprintf("%s: %v", dir, err)
+	}
+	defer conn.ChangeDir(wd)
+
+	data := doctorContents(size)
+	filename := doctorFilename()
+
+	start := time.Now()
+	if err := conn.Stor(filename, bytes.NewReader(data)); err != nil {
+		return CheckFailed, fmt.Sprintf("unable to write %s: %v", path.Join(dir, filename), err)
+	}
+	uploaded := time.Since(start)
+
+	start = time.Now()
+	h := sha256.New()
+	resp, err := conn.Retr(filename)
+	if err == nil {
+		_, err = io.Copy(h, resp)
+		resp.Close()
+	}
+	downloaded := time.Since(start)
+
+	removeErr := conn.Delete(filename)
+
+	return transferResult(data, h.Sum(nil), uploaded, downloaded, err, removeErr, path.Join(dir, filename))
+}
+
+func diagnoseS3(logger log.Logger, conf *service.UploadAgent, opts DoctorOptions, diag *Diagnosis) {
+	if host := conf.S3.EndpointHost(); host != "" && !allowedIPsCheck(conf, host, diag) {
+		diag.add("connect", CheckSkipped, "endpoint is not allowed", 0)
+		skipRemaining(diag, conf.Paths, "endpoint is not allowed")
+		return
+	}
+
+	start := time.Now()
+	bucket, err := openS3Bucket(conf.S3)
+	if err != nil {
+		diag.add("connect", CheckFailed, err.Error(), time.Since(start))
+		skipRemaining(diag, conf.Paths, "unable to open bucket")
+		return
+	}
+	agent := &S3TransferAgent{bucket: bucket, cfg: *conf, logger: logger}
+	defer agent.Close()
+
+	diagnoseS3Agent(agent, opts, diag, start)
+}
+
+func diagnoseS3Agent(agent *S3TransferAgent, opts DoctorOptions, diag *Diagnosis, start time.Time) {
+	paths := currentPaths(agent.cfg.Paths, "")
+	if err := agent.Ping(); err != nil {
+		diag.add("connect", CheckFailed, err.Error(), time.Since(start))
+		skipRemaining(diag, paths, "unable to access bucket")
+		return
+	}
+	diag.add("connect", CheckOK, fmt.Sprintf("bucket %s is accessible", agent.cfg.S3.Bucket), time.Since(start))
+
+	for _, p := range doctorPaths(paths) {
+		if p.path == "" {
+			continue
+		}
+		start := time.Now()
+		status, detail := s3PathCheck(agent.bucket, p.path)
+		diag.add(p.name+" path", status, detail, time.Since(start))
+	}
+
+	if opts.SkipTransfer {
+		diag.add("transfer", CheckSkipped, "skipped by request", 0)
+		return
+	}
+	start = time.Now()
+	status, detail := s3TransferCheck(agent.bucket, paths.Outbound, opts.transferSize())
+	diag.add("transfer", status, detail, time.Since(start))
+}
+
+func s3PathCheck(bucket *blob.Bucket, dir string) (CheckStatus, string) {
+	page, _, err := bucket.ListPage(context.Background(), blob.FirstPageToken, 1000, &blob.ListOptions{
+		Prefix:    s3Prefix(dir),
+		Delimiter: "/",
+	})
+	if err != nil {
+		return CheckFailed, fmt.Sprintf("%s is not readable: %v", dir, err)
+	}
+	return CheckOK, fmt.Sprintf("%s readable, %d entries", dir, len(page))
+}
+
+func s3TransferCheck(bucket *blob.Bucket, dir string, size int64) (CheckStatus, string) {
+	if dir == "" {
+		return CheckSkipped, "no outbound path configured"
+	}
+	ctx := context.Background()
+	data := doctorContents(size)
+	key := s3Key(dir, doctorFilename())
+
+	start := time.Now()
+	if err := bucket.WriteAll(ctx, key, data, nil); err != nil {
+		return CheckFailed, fmt.Sprintf("unable to write %s: %v", key, err)
+	}
+	uploaded := time.Since(start)
+
+	start = time.Now()
+	h := sha256.New()
+	r, err := bucket.NewReader(ctx, key, nil)
+	if err == nil {
+		_, err = io.Copy(h, r)
+		r.Close()
+	}
+	downloaded := time.Since(start)
+
+	removeErr := bucket.Delete(ctx, key)
+
+	return transferResult(data, h.Sum(nil), uploaded, downloaded, err, removeErr, key)
+}
+
+func diagnoseHTTPS(logger log.Logger, conf *service.UploadAgent, opts DoctorOptions, diag *Diagnosis) {
+	if !allowedIPsCheck(conf, conf.HTTPS.Hostname(), diag) {
+		diag.add("connect", CheckSkipped, "hostname is not allowed", 0)
+		skipRemaining(diag, conf.Paths, "hostname is not allowed")
+		return
+	}
+
+	start := time.Now()
+	client, err := httpsClient(conf.HTTPS)
+	if err != nil {
+		diag.add("connect", CheckFailed, err.Error(), time.Since(start))
+		skipRemaining(diag, conf.Paths, "invalid tls config")
+		return
+	}
+	agent := &HTTPSTransferAgent{client: client, cfg: *conf, logger: logger}
+	defer agent.Close()
+
+	paths := currentPaths(conf.Paths, "")
+	if err := agent.Ping(); err != nil {
+		diag.add("connect", CheckFailed, err.Error(), time.Since(start))
+		skipRemaining(diag, paths, "unable to connect")
+		return
+	}
+	diag.add("connect", CheckOK, fmt.Sprintf("%s is reachable", conf.HTTPS.BaseURL), time.Since(start))
+
+	for _, p := range doctorPaths(paths) {
+		if p.path == "" || p.name == "outbound" {
+			continue // outbound paths only accept uploads
+		}
+		start := time.Now()
+		if entries, err := agent.list(p.path); err != nil {
+			diag.add(p.name+" path", CheckFailed, fmt.Sprintf("%s is not readable: %v", p.path, err), time.Since(start))
+		} else {
+			diag.add(p.name+" path", CheckOK, fmt.Sprintf("%s readable, %d entries", p.path, len(entries)), time.Since(start))
+		}
+	}
+
+	if opts.SkipTransfer {
+		diag.add("transfer", CheckSkipped, "skipped by request", 0)
+		return
+	}
+	start = time.Now()
+	status, detail := httpsTransferCheck(agent, paths.Outbound, opts.transferSize())
+	diag.add("transfer", status, detail, time.Since(start))
+}
+
+func httpsTransferCheck(agent *HTTPSTransferAgent, dir string, size int64) (CheckStatus, string) {
+	if dir == "" {
+		return CheckSkipped, "no outbound path configured"
+	}
+	data := doctorContents(size)
+	filename := doctorFilename()
+
+	start := time.Now()
+	err := agent.UploadFile(File{
+		Filename: filename,
+		Contents: NewContents(data),
+	})
+	if err != nil {
+		return CheckFailed, fmt.Sprintf("unable to write %s: %v", path.Join(dir, filename), err)
+	}
+	uploaded := time.Since(start)
+
+	start = time.Now()
+	h := sha256.New()
+	r, err := agent.readFile(newSpooler(agent.cfg.Downloads), agent.endpoint(dir, filename))
+	if err == nil {
+		_, err = io.Copy(h, r)
+		r.Close()
+	}
+	downloaded := time.Since(start)
+
+	removeErr := agent.Delete(path.Join(dir, filename))
+
+	return transferResult(data, h.Sum(nil), uploaded, downloaded, err, removeErr, path.Join(dir, filename))
+}
+
+func diagnoseAS2(logger log.Logger, conf *service.UploadAgent, opts DoctorOptions, diag *Diagnosis) {
+	paths := currentPaths(conf.Paths, "")
+
+	start := time.Now()
+	partner, err := loadAS2Partner(conf.AS2)
+	if err != nil {
+		diag.add("certificates", CheckFailed, err.Error(), time.Since(start))
+		diag.add("connect", CheckSkipped, "invalid certificates", 0)
+		skipRemaining(diag, paths, "invalid certificates")
+		return
+	}
+	diag.add("certificates", certificateExpiry(partner), certificateDetail(partner), time.Since(start))
+
+	if !allowedIPsCheck(conf, conf.AS2.Hostname(), diag) {
+		diag.add("connect", CheckSkipped, "hostname is not allowed", 0)
+	} else {
+		agent := &AS2TransferAgent{client: as2Client(conf.AS2), cfg: *conf, partner: partner, logger: logger}
+		defer agent.Close()
+
+		start = time.Now()
+		if err := agent.Ping(); err != nil {
+			diag.add("connect", CheckFailed, err.Error(), time.Since(start))
+		} else {
+			diag.add("connect", CheckOK, fmt.Sprintf("%s is reachable", conf.AS2.URL), time.Since(start))
+		}
+	}
+
+	// Files from the partner are read from the local Inbox
+	for _, p := range doctorPaths(paths) {
+		if p.path == "" || p.name == "outbound" {
+			continue
+		}
+		start := time.Now()
+		dir := filepath.Join(conf.AS2.Inbox, p.path)
+		if entries, err := os.ReadDir(dir); err != nil && !os.IsNotExist(err) {
+			diag.add(p.name+" path", CheckFailed, fmt.Sprintf("%s is not readable: %v", dir, err), time.Since(start))
+		} else {
+			diag.add(p.name+" path", CheckOK, fmt.Sprintf("%s readable, %d entries", dir, len(entries)), time.Since(start))
+		}
+	}
+
+	// Every AS2 message is delivered to the partner, so never send a test file
+	diag.add("transfer", CheckSkipped, "AS2 messages can't be removed once sent", 0)
+}
+
+// certificateExpiry warns when our certificate or the partner's expires within 30 days
+func certificateExpiry(partner *as2.Partner) CheckStatus {
+	soon := time.Now().Add(30 * 24 * time.Hour)
+	for _, cert := range []*x509.Certificate{partner.Certificate, partner.PartnerCertificate} {
+		if time.Now().After(cert.NotAfter) {
+			return CheckFailed
+		}
+		if soon.After(cert.NotAfter) {
+			return CheckWarning
+		}
+	}
+	return CheckOK
+}
+
+func certificateDetail(partner *as2.Partner) string {
+	return fmt.Sprintf("our certificate expires %s, partner certificate expires %s",
+		partner.Certificate.NotAfter.Format("2006-01-02"), partner.PartnerCertificate.NotAfter.Format("2006-01-02"))
+}
+
+func diagnoseFilesystem(logger log.Logger, conf *service.UploadAgent, opts DoctorOptions, diag *Diagnosis) {
+	paths := currentPaths(conf.Paths, "")
+
+	start := time.Now()
+	agent := &FilesystemTransferAgent{
+		cfg:    *conf,
+		dir:    localDirectory{root: conf.Filesystem.Directory, perm: conf.Filesystem.FileMode(), downloads: conf.Downloads},
+		logger: logger,
+	}
+	if err := agent.Ping(); err != nil {
+		diag.add("connect", CheckFailed, err.Error(), time.Since(start))
+		skipRemaining(diag, paths, "directory is unavailable")
+		return
+	}
+	diag.add("connect", CheckOK, fmt.Sprintf("%s is a directory", conf.Filesystem.Directory), time.Since(start))
+
+	for _, p := range doctorPaths(paths) {
+		if p.path == "" {
+			continue
+		}
+		start := time.Now()
+		dir := filepath.Join(conf.Filesystem.Directory, p.path)
+		if entries, err := os.ReadDir(dir); err != nil {
+			diag.add(p.name+" path", CheckFailed, fmt.Sprintf("%s is not readable: %v", dir, err), time.Since(start))
+		} else {
+			diag.add(p.name+" path", CheckOK, fmt.Sprintf("%s readable, %d entries", dir, len(entries)), time.Since(start))
+		}
+	}
+
+	if opts.SkipTransfer {
+		diag.add("transfer", CheckSkipped, "skipped by request", 0)
+		return
+	}
+	start = time.Now()
+	status, detail := filesystemTransferCheck(agent, paths.Outbound, opts.transferSize())
+	diag.add("transfer", status, detail, time.Since(start))
+}
+
+func filesystemTransferCheck(agent *FilesystemTransferAgent, dir string, size int64) (CheckStatus, string) {
+	if dir == "" {
+		return CheckSkipped, "no outbound path configured"
+	}
+	data := doctorContents(size)
+	filename := doctorFilename()
+
+	start := time.Now()
+	if err := agent.dir.write(dir, filename, data); err != nil {
+		return CheckFailed, fmt.Sprintf("unable to write %s: %v", filepath.Join(dir, filename), err)
+	}
+	uploaded := time.Since(start)
+
+	start = time.Now()
+	var downloaded []byte
+	where, err := agent.dir.path(filepath.Join(dir, filename))
+	if err == nil {
+		var bs []byte
+		if bs, err = os.ReadFile(where); err == nil {
+			sum := sha256.Sum256(bs)
+			downloaded = sum[:]
+		}
+	}
+	readDur := time.Since(start)
+
+	removeErr := agent.Delete(filepath.Join(dir, filename))
+
+	return transferResult(data, downloaded, uploaded, readDur, err, removeErr, filepath.Join(dir, filename))
+}
@@ -0,0 +1,190 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+	"goftp.io/server"
+)
+
+func findCheck(t *testing.T, diag *Diagnosis, name string) Check {
+	t.Helper()
+
+	for _, c := range diag.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("missing %q check", name)
+	return Check{}
+}
+
+func TestDiagnose__Mock(t *testing.T) {
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{ID: "mock", Mock: &service.MockAgent{}},
+		},
+	}
+	diag, err := Diagnose(log.NewNopLogger(), cfg, "mock", DoctorOptions{})
+	require.NoError(t, err)
+	require.False(t, diag.Failed())
+	require.Equal(t, "hostname", diag.Hostname)
+
+	_, err = Diagnose(log.NewNopLogger(), cfg, "missing", DoctorOptions{})
+	require.ErrorContains(t, err, "unknown Agent ID=missing")
+}
+
+func TestDiagnose__FTP(t *testing.T) {
+	svc, err := createTestFTPServer(t)
+	require.NoError(t, err)
+	defer svc.Shutdown()
+
+	auth, ok := svc.Auth.(*server.SimpleAuth)
+	require.True(t, ok)
+
+	conf := service.UploadAgent{
+		ID: "ftp",
+		FTP: &service.FTP{
+			Hostname: fmt.Sprintf("%s:%d", svc.Hostname, svc.Port),
+			Username: auth.Name,
+			Password: auth.Password,
+		},
+		Paths: service.UploadPaths{
+			Inbound:  "inbound",
+			Outbound: "outbound",
+			Return:   "missing",
+		},
+	}
+	cfg := service.UploadAgents{Agents: []service.UploadAgent{conf}}
+
+	diag, err := Diagnose(log.NewNopLogger(), cfg, "ftp", DoctorOptions{TransferSize: 4096})
+	require.NoError(t, err)
+	require.True(t, diag.Failed())
+
+	require.Equal(t, CheckWarning, findCheck(t, diag, "tls").Status)
+	require.Equal(t, CheckOK, findCheck(t, diag, "connect").Status)
+	require.Equal(t, CheckOK, findCheck(t, diag, "inbound path").Status)
+	require.Equal(t, CheckOK, findCheck(t, diag, "outbound path").Status)
+	require.Equal(t, CheckFailed, findCheck(t, diag, "return path").Status)
+
+	transfer := findCheck(t, diag, "transfer")
+	require.Equal(t, CheckOK, transfer.Status, transfer.Detail)
+	require.Contains(t, transfer.Detail, "4096 bytes")
+
+	// the temporary file is removed
+	matches, err := filepath.Glob(filepath.Join(rootFTPPath, "outbound", ".achgateway-doctor-*"))
+	require.NoError(t, err)
+	require.Empty(t, matches)
+
+	// skip the transfer
+	diag, err = Diagnose(log.NewNopLogger(), cfg, "ftp", DoctorOptions{SkipTransfer: true})
+	require.NoError(t, err)
+	require.Equal(t, CheckSkipped, findCheck(t, diag, "transfer").Status)
+}
+
+func TestDiagnose__FTPUnreachable(t *testing.T) {
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID: "ftp",
+				FTP: &service.FTP{
+					Hostname:    "localhost:1",
+					DialTimeout: time.Second,
+				},
+				Paths: service.UploadPaths{Outbound: "outbound"},
+			},
+		},
+	}
+	diag, err := Diagnose(log.NewNopLogger(), cfg, "ftp", DoctorOptions{})
+	require.NoError(t, err)
+	require.True(t, diag.Failed())
+	require.Equal(t, CheckFailed, findCheck(t, diag, "connect").Status)
+	require.Equal(t, CheckSkipped, findCheck(t, diag, "outbound path").Status)
+	require.Equal(t, CheckSkipped, findCheck(t, diag, "transfer").Status)
+}
+
+func TestDiagnose__SFTP(t *testing.T) {
+	deployment := spawnSFTP(t)
+	defer deployment.close(t)
+
+	conf := *deployment.agent.cfg.SFTP
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID:   "sftp",
+				SFTP: &conf,
+				Paths: service.UploadPaths{
+					Inbound:  "/upload/",
+					Outbound: "/upload/",
+				},
+			},
+		},
+	}
+	diag, err := Diagnose(log.NewNopLogger(), cfg, "sftp", DoctorOptions{TransferSize: 4096})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	diag.WriteTo(&buf)
+	require.False(t, diag.Failed(), buf.String())
+	require.Equal(t, CheckWarning, findCheck(t, diag, "host key").Status)
+	require.Contains(t, findCheck(t, diag, "host key").Detail, "SHA256:")
+	require.Equal(t, CheckOK, findCheck(t, diag, "transfer").Status)
+
+	// a different host key fails
+	pub, err := os.ReadFile(filepath.Join("..", "sshx", "testdata", "rsa-2048.pub"))
+	require.NoError(t, err)
+	conf.HostPublicKey = string(pub)
+	diag, err = Diagnose(log.NewNopLogger(), cfg, "sftp", DoctorOptions{})
+	require.NoError(t, err)
+	require.True(t, diag.Failed())
+	require.Equal(t, CheckFailed, findCheck(t, diag, "host key").Status)
+	require.Equal(t, CheckSkipped, findCheck(t, diag, "connect").Status)
+}
+
+func TestDiagnosis__WriteTo(t *testing.T) {
+	diag := &Diagnosis{
+		AgentID:  "ftp",
+		Hostname: "ftp.bank.com:21",
+	}
+	diag.add("connect", CheckOK, "authenticated as moov", 25*time.Millisecond)
+	diag.add("outbound path", CheckFailed, "outbound: 550 not found", 0)
+	diag.add("tls", CheckWarning, "no ca_file configured", 0)
+
+	var buf bytes.Buffer
+	_, err := diag.WriteTo(&buf)
+	require.NoError(t, err)
+
+	out := buf.String()
+	require.Contains(t, out, "Agent:    ftp\n")
+	require.Contains(t, out, "Hostname: ftp.bank.com:21\n")
+	require.Contains(t, out, "connect        ok       25ms      authenticated as moov")
+	require.Contains(t, out, "outbound path  failed   -         outbound: 550 not found")
+	require.True(t, strings.HasSuffix(out, "3 checks, 1 failed, 1 warnings\n"))
+	require.True(t, diag.Failed())
+}
+
+func TestDoctor__contents(t *testing.T) {
+	require.Len(t, doctorContents(1024), 1024)
+	require.Equal(t, int64(1024*1024), DoctorOptions{}.transferSize())
+	require.True(t, strings.HasPrefix(doctorFilename(), ".achgateway-doctor-"))
+
+	status, detail := transferResult([]byte("a"), []byte("b"), time.Second, time.Second, nil, nil, "x")
+	require.Equal(t, CheckFailed, status)
+	require.Contains(t, detail, "changed after upload")
+
+	status, _ = transferResult([]byte("a"), nil, time.Second, time.Second, os.ErrNotExist, nil, "x")
+	require.Equal(t, CheckFailed, status)
+}
@@ -0,0 +1,285 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package upload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+)
+
+// injectedFaults are shared by every FaultAgent so they can be changed from the admin server
+var injectedFaults = &faultRegistry{
+	faults: make(map[string]*service.AgentFaults),
+}
+
+type faultRegistry struct {
+	mu     sync.Mutex
+	faults map[string]*service.AgentFaults
+}
+
+func (r *faultRegistry) set(faults service.AgentFaults) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.faults[faults.AgentID] = &faults
+}
+
+func (r *faultRegistry) clear(agentID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.faults, agentID)
+}
+
+func (r *faultRegistry) list() []service.AgentFaults {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]service.AgentFaults, 0, len(r.faults))
+	for _, f := range r.faults {
+		out = append(out, *f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AgentID < out[j].AgentID })
+	return out
+}
+
+// take returns the agent's faults for one call, counting it against their Count
+func (r *faultRegistry) take(agentID string) *service.AgentFaults {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	faults, exists := r.faults[agentID]
+	if !exists {
+		return nil
+	}
+	out := *faults
+	if faults.Count > 0 {
+		faults.Count--
+		if faults.Count == 0 {
+			delete(r.faults, agentID)
+		}
+	}
+	return &out
+}
+
+// injectedTimeout looks like a network timeout, so RetryAgent retries it
+type injectedTimeout struct {
+	op string
+}
+
+func (e injectedTimeout) Error() string {
+	return fmt.Sprintf("%s: injected connection failure: i/o timeout", e.op)
+}
+
+func (e injectedTimeout) Timeout() bool {
+	return true
+}
+
+// FaultAgent injects the failures set for its agent ID into each network call.
+type FaultAgent struct {
+	logger     log.Logger
+	agentID    string
+	underlying Agent
+}
+
+func newFaultAgent(logger log.Logger, underlying Agent, agentID string, cfg *service.FaultInjection) *FaultAgent {
+	for i := range cfg.Faults {
+		if cfg.Faults[i].AgentID == agentID {
+			injectedFaults.set(cfg.Faults[i])
+		}
+	}
+	logger.Warn().Logf("fault injection is enabled for agent %s", agentID)
+
+	return &FaultAgent{
+		logger:     logger,
+		agentID:    agentID,
+		underlying: underlying,
+	}
+}
+
+func (fa *FaultAgent) ID() string {
+	return fa.underlying.ID()
+}
+
+func (fa *FaultAgent) String() string {
+	return fmt.Sprintf("FaultAgent{%T}", fa.underlying)
+}
+
+// inject delays the call and returns an error when it should fail. The faults are returned
+// so UploadFile can check for partial writes.
+func (fa *FaultAgent) inject(op string) (*service.AgentFaults, error) {
+	faults := injectedFaults.take(fa.agentID)
+	if faults == nil {
+		return nil, nil
+	}
+	if faults.Delay > 0 {
+		fa.logger.Info().Logf("injecting %v delay into %s", faults.Delay, op)
+		time.Sleep(faults.Delay)
+	}
+	if faults.ConnectionFailure {
+		fa.logger.Info().Logf("injecting connection failure into %s", op)
+		return faults, injectedTimeout{op: op}
+	}
+	return faults, nil
+}
+
+func (fa *FaultAgent) SkippedFiles() []SkippedFile {
+	return SkippedFiles(fa.underlying)
+}
+
+func (fa *FaultAgent) CommitListings() {
+	CommitListings(fa.underlying)
+}
+
+func (fa *FaultAgent) GetInboundFiles() ([]File, error) {
+	if _, err := fa.inject("GetInboundFiles"); err != nil {
+		return nil, err
+	}
+	return fa.underlying.GetInboundFiles()
+}
+
+func (fa *FaultAgent) GetReconciliationFiles() ([]File, error) {
+	if _, err := fa.inject("GetReconciliationFiles"); err != nil {
+		return nil, err
+	}
+	return fa.underlying.GetReconciliationFiles()
+}
+
+func (fa *FaultAgent) GetReturnFiles() ([]File, error) {
+	if _, err := fa.inject("GetReturnFiles"); err != nil {
+		return nil, err
+	}
+	return fa.underlying.GetReturnFiles()
+}
+
+func (fa *FaultAgent) UploadFile(f File) error {
+	faults, err := fa.inject("UploadFile")
+	if err != nil {
+		return err
+	}
+	if faults == nil || !faults.PartialWrite {
+		return fa.underlying.UploadFile(f)
+	}
+
+	bs, err := io.ReadAll(f.Contents)
+	if err != nil {
+		return err
+	}
+	half := bs[:len(bs)/2]
+	fa.logger.Info().Logf("injecting partial write of %d of %d bytes into %s", len(half), len(bs), f.Filename)
+
+	err = fa.underlying.UploadFile(File{
+		Filename: f.Filename,
+		Contents: io.NopCloser(bytes.NewReader(half)),
+	})
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("UploadFile: injected partial write of %d of %d bytes: connection reset by peer", len(half), len(bs))
+}
+
+func (fa *FaultAgent) Delete(path string) error {
+	if _, err := fa.inject("Delete"); err != nil {
+		return err
+	}
+	return fa.underlying.Delete(path)
+}
+
+func (fa *FaultAgent) Move(oldpath, newpath string) error {
+	if _, err := fa.inject("Move"); err != nil {
+		return err
+	}
+	return Move(fa.underlying, oldpath, newpath)
+}
+
+func (fa *FaultAgent) Exists(path string) (bool, error) {
+	if _, err := fa.inject("Exists"); err != nil {
+		return false, err
+	}
+	return Exists(fa.underlying, path)
+}
+
+func (fa *FaultAgent) InboundPath() string {
+	return fa.underlying.InboundPath()
+}
+
+func (fa *FaultAgent) OutboundPath() string {
+	return fa.underlying.OutboundPath()
+}
+
+func (fa *FaultAgent) ReconciliationPath() string {
+	return fa.underlying.ReconciliationPath()
+}
+
+func (fa *FaultAgent) ReturnPath() string {
+	return fa.underlying.ReturnPath()
+}
+
+func (fa *FaultAgent) Hostname() string {
+	return fa.underlying.Hostname()
+}
+
+func (fa *FaultAgent) Ping() error {
+	if _, err := fa.inject("Ping"); err != nil {
+		return err
+	}
+	return fa.underlying.Ping()
+}
+
+func (fa *FaultAgent) Close() error {
+	return fa.underlying.Close()
+}
+
+// RegisterFaultRoutes adds routes to the admin server for injecting agent faults:
+//
+//	GET /agents/faults lists the injected faults
+//	PUT /agents/{agentID}/faults replaces the agent's faults with the AgentFaults in the body
+//	DELETE /agents/{agentID}/faults clears the agent's faults
+func RegisterFaultRoutes(svc *admin.Server) {
+	svc.AddHandler("/agents/faults", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(injectedFaults.list())
+	})
+	svc.Subrouter("/agents/{agentID}").HandleFunc("/faults", func(w http.ResponseWriter, r *http.Request) {
+		agentID := mux.Vars(r)["agentID"]
+
+		switch r.Method {
+		case http.MethodPut:
+			var faults service.AgentFaults
+			if err := json.NewDecoder(r.Body).Decode(&faults); err != nil {
+				http.Error(w, fmt.Sprintf("reading faults: %v", err), http.StatusBadRequest)
+				return
+			}
+			faults.AgentID = agentID
+			if err := faults.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			injectedFaults.set(faults)
+
+		case http.MethodDelete:
+			injectedFaults.clear(agentID)
+
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package upload
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/admin"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultAgent(t *testing.T) {
+	t.Cleanup(func() { injectedFaults.clear("faulty") })
+
+	mock := &MockAgent{}
+	agent := newFaultAgent(log.NewNopLogger(), mock, "faulty", &service.FaultInjection{
+		Faults: []service.AgentFaults{
+			{AgentID: "faulty", ConnectionFailure: true, Count: 2},
+		},
+	})
+
+	// Connection failures look like timeouts and clear after Count calls
+	err := agent.Ping()
+	require.True(t, os.IsTimeout(err))
+	_, err = agent.GetInboundFiles()
+	require.ErrorContains(t, err, "injected connection failure")
+	require.NoError(t, agent.Ping())
+
+	// Partial writes upload half of the file
+	injectedFaults.set(service.AgentFaults{AgentID: "faulty", PartialWrite: true, Delay: 10 * time.Millisecond})
+	start := time.Now()
+	err = agent.UploadFile(File{
+		Filename: "20220601-1620.ach",
+		Contents: io.NopCloser(strings.NewReader("0123456789")),
+	})
+	require.ErrorContains(t, err, "injected partial write of 5 of 10 bytes")
+	require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	bs, _ := io.ReadAll(mock.UploadedFile.Contents)
+	require.Equal(t, "01234", string(bs))
+
+	injectedFaults.clear("faulty")
+	require.NoError(t, agent.UploadFile(File{
+		Filename: "20220601-1620.ach",
+		Contents: io.NopCloser(strings.NewReader("0123456789")),
+	}))
+}
+
+func TestFaultAgent__Retry(t *testing.T) {
+	t.Cleanup(func() { injectedFaults.clear("faulty") })
+
+	agent := newFaultAgent(log.NewNopLogger(), &MockAgent{}, "faulty", &service.FaultInjection{})
+	retr, err := newRetryAgent(log.NewNopLogger(), agent, &service.UploadRetry{
+		Interval:   time.Millisecond,
+		MaxRetries: 3,
+	})
+	require.NoError(t, err)
+
+	// Failures within MaxRetries are retried
+	injectedFaults.set(service.AgentFaults{AgentID: "faulty", ConnectionFailure: true, Count: 2})
+	require.NoError(t, retr.UploadFile(File{
+		Filename: "20220601-1620.ach",
+		Contents: io.NopCloser(strings.NewReader("0123456789")),
+	}))
+
+	injectedFaults.set(service.AgentFaults{AgentID: "faulty", ConnectionFailure: true})
+	_, err = retr.GetReturnFiles()
+	require.ErrorContains(t, err, "GetReturnFiles: injected connection failure")
+}
+
+func TestFaultRoutes(t *testing.T) {
+	t.Cleanup(func() { injectedFaults.clear("faulty") })
+
+	svc := admin.NewServer(":0")
+	go svc.Listen()
+	t.Cleanup(func() { svc.Shutdown() })
+	RegisterFaultRoutes(svc)
+
+	address := "http://" + svc.BindAddr()
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(method, address+path, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := do("PUT", "/agents/faulty/faults", `{"connectionFailure": true, "count": 3}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = do("PUT", "/agents/faulty/faults", `{"count": -1}`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = do("GET", "/agents/faults", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var faults []service.AgentFaults
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&faults))
+	require.Len(t, faults, 1)
+	require.Equal(t, "faulty", faults[0].AgentID)
+	require.True(t, faults[0].ConnectionFailure)
+	require.Equal(t, 3, faults[0].Count)
+
+	resp = do("DELETE", "/agents/faulty/faults", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, injectedFaults.list())
+}
@@ -5,12 +5,16 @@
 package upload
 
 import (
+	"bytes"
 	"io"
 )
 
 type File struct {
 	Filename string
 	Contents io.ReadCloser
+
+	// RoutingNumber fills {{ routingNumber }} in a templated OutboundPath
+	RoutingNumber string
 }
 
 func (f File) Close() error {
@@ -19,3 +23,17 @@ func (f File) Close() error {
 	}
 	return nil
 }
+
+// NewContents returns data as File.Contents which can seek, so resumed SFTP uploads read it
+// again instead of keeping a copy.
+func NewContents(data []byte) io.ReadCloser {
+	return contents{bytes.NewReader(data)}
+}
+
+type contents struct {
+	*bytes.Reader
+}
+
+func (contents) Close() error {
+	return nil
+}
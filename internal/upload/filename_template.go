@@ -23,6 +23,11 @@ type FilenameData struct {
 
 	// ShardName is the name of a shard uploading this file
 	ShardName string
+
+	// CompanyIdentification and SECCode are set when every batch in the file has the same value,
+	// such as when shards split their files by company or SEC code.
+	CompanyIdentification string
+	SECCode               string
 }
 
 var filenameFunctions template.FuncMap = map[string]interface{}{
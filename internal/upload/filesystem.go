@@ -0,0 +1,199 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	filesystemAgentUp = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Name: "filesystem_agent_up",
+		Help: "Status of filesystem agent directory access",
+	}, []string{"agent"})
+)
+
+// FilesystemTransferAgent is an implementation of Agent which hands files off through a local
+// or NFS-mounted directory. Outbound files are written into <Directory>/<Outbound> under a hidden
+// name and renamed once complete, files are read from the other paths and deleted once processed.
+type FilesystemTransferAgent struct {
+	cfg    service.UploadAgent
+	dir    localDirectory
+	logger log.Logger
+
+	// skipped are the entries passed over by the last readFiles call
+	mu      sync.Mutex
+	skipped []SkippedFile
+}
+
+func newFilesystemTransferAgent(logger log.Logger, cfg *service.UploadAgent) (*FilesystemTransferAgent, error) {
+	if cfg == nil || cfg.Filesystem == nil {
+		return nil, errors.New("nil Filesystem config")
+	}
+	if err := cfg.Filesystem.Validate(); err != nil {
+		return nil, fmt.Errorf("filesystem: %v", err)
+	}
+	agent := &FilesystemTransferAgent{
+		cfg: *cfg,
+		dir: localDirectory{
+			root: cfg.Filesystem.Directory,
+			perm: cfg.Filesystem.FileMode(),
+
+			downloads: cfg.Downloads,
+		},
+		logger: logger,
+	}
+	if err := agent.Ping(); err != nil {
+		return nil, err
+	}
+	return agent, nil
+}
+
+func (agent *FilesystemTransferAgent) ID() string {
+	return agent.cfg.ID
+}
+
+// Ping checks the Directory exists, which fails when a network mount is missing
+func (agent *FilesystemTransferAgent) Ping() error {
+	if agent == nil || agent.cfg.Filesystem == nil {
+		return errors.New("nil FilesystemTransferAgent")
+	}
+	info, err := os.Stat(agent.cfg.Filesystem.Directory)
+	if err == nil && !info.IsDir() {
+		err = fmt.Errorf("%s is not a directory", agent.cfg.Filesystem.Directory)
+	}
+	if err != nil {
+		filesystemAgentUp.With("agent", agent.cfg.ID).Set(0)
+		return fmt.Errorf("filesystem: %v", err)
+	}
+	filesystemAgentUp.With("agent", agent.cfg.ID).Set(1)
+	return nil
+}
+
+func (agent *FilesystemTransferAgent) Close() error {
+	return nil
+}
+
+func (agent *FilesystemTransferAgent) InboundPath() string {
+	return currentPaths(agent.cfg.Paths, "").Inbound
+}
+
+func (agent *FilesystemTransferAgent) OutboundPath() string {
+	return currentPaths(agent.cfg.Paths, "").Outbound
+}
+
+func (agent *FilesystemTransferAgent) ReconciliationPath() string {
+	return currentPaths(agent.cfg.Paths, "").Reconciliation
+}
+
+func (agent *FilesystemTransferAgent) ReturnPath() string {
+	return currentPaths(agent.cfg.Paths, "").Return
+}
+
+func (agent *FilesystemTransferAgent) Hostname() string {
+	return "localhost"
+}
+
+func (agent *FilesystemTransferAgent) Delete(path string) error {
+	if path == "" || strings.HasSuffix(path, "/") {
+		return fmt.Errorf("FilesystemTransferAgent: invalid path %v", path)
+	}
+	if err := agent.dir.remove(path); err != nil {
+		return fmt.Errorf("filesystem: %v", err)
+	}
+	return nil
+}
+
+func (agent *FilesystemTransferAgent) Move(oldpath, newpath string) error {
+	if err := agent.dir.move(oldpath, newpath); err != nil {
+		return fmt.Errorf("filesystem: %v", err)
+	}
+	return nil
+}
+
+func (agent *FilesystemTransferAgent) Exists(path string) (bool, error) {
+	exists, err := agent.dir.exists(path)
+	if err != nil {
+		return false, fmt.Errorf("filesystem: %v", err)
+	}
+	return exists, nil
+}
+
+// UploadFile writes the content of File into the OutboundPath
+//
+// The File's contents will always be closed
+func (agent *FilesystemTransferAgent) UploadFile(f File) error {
+	defer f.Close()
+
+	contents, err := io.ReadAll(f.Contents)
+	if err != nil {
+		return fmt.Errorf("filesystem: reading %s: %v", f.Filename, err)
+	}
+	outbound := currentPaths(agent.cfg.Paths, f.RoutingNumber).Outbound
+
+	// Take the base of f.Filename to avoid accepting a write like '../../../../etc/passwd'.
+	filename := filepath.Base(f.Filename)
+	if err := agent.dir.write(outbound, filename, contents); err != nil {
+		return fmt.Errorf("filesystem: writing %s: %v", filepath.Join(outbound, filename), err)
+	}
+	return nil
+}
+
+func (agent *FilesystemTransferAgent) GetInboundFiles() ([]File, error) {
+	return agent.readFiles(agent.InboundPath(), agent.cfg.Paths.InboundFilter)
+}
+
+func (agent *FilesystemTransferAgent) GetReconciliationFiles() ([]File, error) {
+	return agent.readFiles(agent.ReconciliationPath(), agent.cfg.Paths.ReconciliationFilter)
+}
+
+func (agent *FilesystemTransferAgent) GetReturnFiles() ([]File, error) {
+	return agent.readFiles(agent.ReturnPath(), agent.cfg.Paths.ReturnFilter)
+}
+
+func (agent *FilesystemTransferAgent) readFiles(dir string, filter *service.FilenameFilter) ([]File, error) {
+	files, skipped, err := agent.dir.readFiles(dir, filter, agent.cfg.Paths.Depth)
+
+	agent.mu.Lock()
+	agent.skipped = skipped
+	agent.mu.Unlock()
+
+	if err != nil {
+		return nil, fmt.Errorf("filesystem: %v", err)
+	}
+	return files, nil
+}
+
+func (agent *FilesystemTransferAgent) SkippedFiles() []SkippedFile {
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	return agent.skipped
+}
@@ -0,0 +1,203 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func setupFilesystem(t *testing.T) (*FilesystemTransferAgent, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := &service.UploadAgent{
+		ID: "appliance",
+		Filesystem: &service.Filesystem{
+			Directory: dir,
+		},
+		Paths: service.UploadPaths{
+			Inbound:        "inbound",
+			Outbound:       "outbound",
+			Reconciliation: "reconciliation",
+			Return:         "returned",
+		},
+	}
+	agent, err := newFilesystemTransferAgent(log.NewTestLogger(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { agent.Close() })
+
+	return agent, dir
+}
+
+func TestFilesystem__MissingDirectory(t *testing.T) {
+	cfg := &service.UploadAgent{
+		Filesystem: &service.Filesystem{
+			Directory: filepath.Join(t.TempDir(), "missing"),
+		},
+	}
+	_, err := newFilesystemTransferAgent(log.NewTestLogger(), cfg)
+	require.ErrorContains(t, err, "no such file or directory")
+}
+
+func TestFilesystem__UploadFile(t *testing.T) {
+	agent, dir := setupFilesystem(t)
+	require.Equal(t, "localhost", agent.Hostname())
+
+	err := agent.UploadFile(File{
+		Filename: "../../20210104-0917.ach",
+		Contents: io.NopCloser(bytes.NewReader([]byte("nacha contents"))),
+	})
+	require.NoError(t, err)
+
+	bs, err := os.ReadFile(filepath.Join(dir, "outbound", "20210104-0917.ach"))
+	require.NoError(t, err)
+	require.Equal(t, "nacha contents", string(bs))
+
+	info, err := os.Stat(filepath.Join(dir, "outbound", "20210104-0917.ach"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Join(dir, "outbound"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestFilesystem__GetFiles(t *testing.T) {
+	agent, dir := setupFilesystem(t)
+
+	files, err := agent.GetReturnFiles()
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "returned", "archive"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "returned", "return.ach"), []byte("return"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "returned", ".partial.ach"), []byte("still writing"), 0600))
+
+	files, err = agent.GetReturnFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "return.ach", files[0].Filename)
+
+	bs, err := io.ReadAll(files[0].Contents)
+	require.NoError(t, err)
+	require.Equal(t, "return", string(bs))
+	require.NoError(t, files[0].Close())
+
+	skipped := agent.SkippedFiles()
+	require.Len(t, skipped, 1)
+	require.Equal(t, "archive", filepath.Base(skipped[0].Path))
+
+	require.NoError(t, agent.Delete(filepath.Join("returned", "return.ach")))
+	require.NoError(t, agent.Delete(filepath.Join("returned", "return.ach")))
+
+	files, err = agent.GetReturnFiles()
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	require.Error(t, agent.Delete("../outside.ach"))
+}
+
+func TestFilesystem__Depth(t *testing.T) {
+	agent, dir := setupFilesystem(t)
+	agent.cfg.Paths.Depth = 1
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "inbound", "2024-05-01", "archive"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "inbound", "top.ach"), []byte("top"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "inbound", "2024-05-01", "nested.ach"), []byte("nested"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "inbound", "2024-05-01", "archive", "old.ach"), []byte("old"), 0600))
+
+	files, err := agent.GetInboundFiles()
+	require.NoError(t, err)
+	var names []string
+	for i := range files {
+		names = append(names, files[i].Filename)
+		require.NoError(t, files[i].Close())
+	}
+	require.ElementsMatch(t, []string{"top.ach", "2024-05-01/nested.ach"}, names)
+
+	skipped := agent.SkippedFiles()
+	require.Len(t, skipped, 1)
+	require.Equal(t, SkipDirectory, skipped[0].Reason)
+	require.Equal(t, "archive", filepath.Base(skipped[0].Path))
+
+	require.NoError(t, agent.Delete(filepath.Join("inbound", "2024-05-01", "nested.ach")))
+	_, err = os.Stat(filepath.Join(dir, "inbound", "2024-05-01", "nested.ach"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestFilesystem__FilenameFilters(t *testing.T) {
+	agent, dir := setupFilesystem(t)
+	agent.cfg.Paths.ReturnFilter = &service.FilenameFilter{
+		Include: []string{"*.ach", `/^RET_\d+\.txt$/`},
+		Exclude: []string{"*_test.ach"},
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "returned"), 0755))
+	for _, name := range []string{"return.ach", "RET_20220601.txt", "statement.pdf", "return_test.ach", "RET_report.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "returned", name), []byte(name), 0600))
+	}
+
+	files, err := agent.GetReturnFiles()
+	require.NoError(t, err)
+	var names []string
+	for i := range files {
+		names = append(names, files[i].Filename)
+		require.NoError(t, files[i].Close())
+	}
+	require.ElementsMatch(t, []string{"return.ach", "RET_20220601.txt"}, names)
+
+	skipped := agent.SkippedFiles()
+	require.Len(t, skipped, 3)
+	for i := range skipped {
+		require.Equal(t, SkipFiltered, skipped[i].Reason)
+	}
+
+	// Other paths aren't filtered
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "inbound"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "inbound", "statement.pdf"), []byte("pdf"), 0600))
+	files, err = agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.NoError(t, files[0].Close())
+}
+
+func TestFilesystem__Diagnose(t *testing.T) {
+	agent, _ := setupFilesystem(t)
+	agents := service.UploadAgents{Agents: []service.UploadAgent{agent.cfg}}
+
+	diag, err := Diagnose(log.NewTestLogger(), agents, agent.ID(), DoctorOptions{})
+	require.NoError(t, err)
+
+	statuses := make(map[string]CheckStatus)
+	for _, check := range diag.Checks {
+		statuses[check.Name] = check.Status
+	}
+	require.Equal(t, CheckOK, statuses["connect"])
+	require.Equal(t, CheckFailed, statuses["inbound path"])
+	require.Equal(t, CheckOK, statuses["transfer"])
+}
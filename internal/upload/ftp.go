@@ -5,17 +5,20 @@
 package upload
 
 import (
-	"bytes"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/textproto"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/moov-io/achgateway/internal/fips"
 	"github.com/moov-io/achgateway/internal/service"
 
 	"github.com/go-kit/kit/metrics/prometheus"
@@ -37,20 +40,47 @@ type FTPTransferAgent struct {
 	cfg    service.UploadAgent
 	logger log.Logger
 	mu     sync.Mutex // protects all read/write methods
+
+	// skipped are the files passed over by the last readFiles call
+	skipped []SkippedFile
+
+	// session is the connection shared with other agents, when enabled
+	session *sharedSession
+
+	// listings are the remote files already processed, when CacheListings is enabled
+	listings *listingCache
+
+	// throttle limits the agent's transfer rate, when MaxBytesPerSecond is set
+	throttle *throttle
+
+	// tlsSessions are resumed by data connections, when TLS.SessionReuse is set
+	tlsSessions tls.ClientSessionCache
 }
 
-func newFTPTransferAgent(logger log.Logger, cfg *service.UploadAgent) (*FTPTransferAgent, error) {
+// newFTPTransferAgent connects to the FTP server, reusing a connection from sessions
+// when it's non-nil.
+func newFTPTransferAgent(logger log.Logger, cfg *service.UploadAgent, sessions *sessionPool) (*FTPTransferAgent, error) {
 	if cfg == nil || cfg.FTP == nil {
 		return nil, errors.New("nil FTP config")
 	}
 	agent := &FTPTransferAgent{
-		cfg:    *cfg,
-		logger: logger,
+		cfg:         *cfg,
+		logger:      logger,
+		listings:    newListingCache(cfg.FTP.CacheListings, cfg.FTP.Hostname),
+		throttle:    newThrottle(cfg.FTP.MaxBytesPerSecond),
+		tlsSessions: newFTPSessionCache(cfg.FTP),
 	}
 
 	if err := rejectOutboundIPRange(cfg.SplitAllowedIPs(), cfg.FTP.Hostname); err != nil {
 		return nil, fmt.Errorf("ftp: %s is not whitelisted: %v", cfg.FTP.Hostname, err)
 	}
+	if sessions != nil {
+		agent.session = sessions.acquire(ftpSessionKey(cfg.FTP))
+		if n := sessions.agents(agent.session.key); n > 1 {
+			logger.Info().Logf("ftp: agent %s is sharing a connection to %s with %d other agents", cfg.ID, cfg.FTP.Hostname, n-1)
+		}
+		defer agent.lock()()
+	}
 
 	_, err := agent.connection() // initial connection
 
@@ -61,6 +91,20 @@ func (agent *FTPTransferAgent) ID() string {
 	return agent.cfg.ID
 }
 
+// lock must be held while using the connection. Agents sharing a connection also hold
+// the session's lock as FTP commands change its working directory.
+func (agent *FTPTransferAgent) lock() func() {
+	agent.mu.Lock()
+	if agent.session == nil {
+		return agent.mu.Unlock
+	}
+	agent.session.mu.Lock()
+	return func() {
+		agent.session.mu.Unlock()
+		agent.mu.Unlock()
+	}
+}
+
 // connection returns an ftp.ServerConn which is connected to the remote server.
 // This function will attempt to establish a new connection if none exists already.
 //
@@ -71,64 +115,152 @@ func (agent *FTPTransferAgent) connection() (*ftp.ServerConn, error) {
 		return nil, errors.New("nil agent / config")
 	}
 
-	if agent.conn != nil {
+	current := &agent.conn
+	if agent.session != nil {
+		current = &agent.session.ftpConn
+	}
+	if *current != nil {
 		// Verify the connection works and f not drop through and reconnect
-		if err := agent.conn.NoOp(); err == nil {
-			return agent.conn, nil
+		if err := (*current).NoOp(); err == nil {
+			return *current, nil
 		} else {
 			// Our connection is having issues, so retry connecting
-			agent.conn.Quit()
+			(*current).Quit()
 		}
 	}
 
+	conn, err := agent.dial()
+	if err != nil {
+		return nil, err
+	}
+	*current = conn
+
+	return conn, nil
+}
+
+// dial opens and logs into a new connection to the server
+func (agent *FTPTransferAgent) dial() (*ftp.ServerConn, error) {
 	// Setup our FTP connection
 	opts := []ftp.DialOption{
 		ftp.DialWithTimeout(agent.cfg.FTP.Timeout()),
 		ftp.DialWithDisabledEPSV(agent.cfg.FTP.DisableEPSV()),
 	}
-	tlsOpt, err := tlsDialOption(agent.cfg.FTP.CAFile())
+	tlsConfig, err := ftpTLSConfig(agent.cfg.FTP, agent.tlsSessions)
 	if err != nil {
 		return nil, err
 	}
-	if tlsOpt != nil {
-		opts = append(opts, *tlsOpt)
+	switch agent.cfg.FTP.TLSMode() {
+	case service.FTPExplicitTLS:
+		opts = append(opts, ftp.DialWithExplicitTLS(tlsConfig))
+	case service.FTPImplicitTLS:
+		opts = append(opts, ftp.DialWithTLS(tlsConfig))
+	}
+	dial, err := ftpDialer(agent.cfg.FTP, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if dial != nil {
+		opts = append(opts, ftp.DialWithDialFunc(dial))
 	}
 
 	// Make the first connection
-	conn, err := ftp.Dial(agent.cfg.FTP.Hostname, opts...)
+	conn, err := ftp.Dial(agent.cfg.FTP.Address(), opts...)
 	if err != nil {
-		return nil, err
+		return nil, fips.Wrap(agent.cfg.FTP.Hostname, err)
 	}
 	if err := conn.Login(agent.cfg.FTP.Username, agent.cfg.FTP.Password); err != nil {
+		conn.Quit()
 		return nil, err
 	}
-	agent.conn = conn
+	return conn, nil
+}
 
-	return agent.conn, nil
+// newFTPSessionCache returns the cache of TLS sessions for cfg's connections when they're reused
+func newFTPSessionCache(cfg *service.FTP) tls.ClientSessionCache {
+	if cfg == nil || cfg.TLS == nil || !cfg.TLS.SessionReuse {
+		return nil
+	}
+	return tls.NewLRUClientSessionCache(0)
 }
 
-func tlsDialOption(caFilePath string) (*ftp.DialOption, error) {
-	if caFilePath == "" {
+// ftpTLSConfig returns the config for encrypted connections to cfg's server, or nil when they
+// aren't encrypted. Certificates are verified against the host of Hostname with the system's
+// roots and CAFilepath. Data connections resume sessions from the control connection when
+// sessions is non-nil.
+func ftpTLSConfig(cfg *service.FTP, sessions tls.ClientSessionCache) (*tls.Config, error) {
+	if cfg.TLSMode() == "" {
 		return nil, nil
 	}
-	bs, err := os.ReadFile(caFilePath)
-	if err != nil {
-		return nil, fmt.Errorf("tlsDialOption: failed to read %s: %v", caFilePath, err)
-	}
 	pool, err := x509.SystemCertPool()
 	if pool == nil || err != nil {
 		pool = x509.NewCertPool()
 	}
-	ok := pool.AppendCertsFromPEM(bs)
-	if !ok {
-		return nil, fmt.Errorf("tlsDialOption: problem with AppendCertsFromPEM from %s", caFilePath)
+	if caFilePath := cfg.CAFile(); caFilePath != "" {
+		bs, err := os.ReadFile(caFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("ftp tls: failed to read %s: %v", caFilePath, err)
+		}
+		if ok := pool.AppendCertsFromPEM(bs); !ok {
+			return nil, fmt.Errorf("ftp tls: problem with AppendCertsFromPEM from %s", caFilePath)
+		}
 	}
-	cfg := &tls.Config{
-		RootCAs:    pool,
-		MinVersion: tls.VersionTLS12,
+	host, _, err := net.SplitHostPort(cfg.Address())
+	if err != nil {
+		return nil, fmt.Errorf("ftp tls: %v", err)
+	}
+	return fips.TLSConfig(&tls.Config{
+		RootCAs:            pool,
+		ServerName:         host,
+		MinVersion:         tls.VersionTLS12,
+		ClientSessionCache: sessions,
+	}), nil
+}
+
+// ftpDialer opens control and data connections through cfg.Proxy and checks data connections
+// are within cfg.DataPortRange. It's nil when the ftp library can dial connections itself.
+//
+// The library leaves TLS to the dial func when one is given, so connections are wrapped with
+// tlsConfig. The control connection is the first one dialed and explicit TLS control
+// connections are left for the library to upgrade after AUTH TLS.
+func ftpDialer(cfg *service.FTP, tlsConfig *tls.Config) (dialFunc, error) {
+	dial, err := proxyDialer(cfg.Proxy, cfg.Timeout())
+	if err != nil {
+		return nil, err
 	}
-	opt := ftp.DialWithTLS(cfg)
-	return &opt, nil
+	low, high, err := cfg.DataPorts()
+	if err != nil {
+		return nil, err
+	}
+	if dial == nil {
+		if high == 0 {
+			return nil, nil
+		}
+		dialer := &net.Dialer{Timeout: cfg.Timeout()}
+		dial = dialer.Dial
+	}
+
+	control := true
+	return func(network, addr string) (net.Conn, error) {
+		if !control && high > 0 {
+			_, p, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			if port, err := strconv.Atoi(p); err != nil || port < low || port > high {
+				return nil, fmt.Errorf("ftp: server offered data connection port %s outside of DataPortRange %s", p, cfg.DataPortRange)
+			}
+		}
+		conn, err := dial(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig == nil || (control && cfg.TLSMode() == service.FTPExplicitTLS) {
+			control = false
+			return conn, nil
+		}
+		control = false
+		return tls.Client(conn, tlsConfig), nil
+	}, nil
 }
 
 func (agent *FTPTransferAgent) Ping() error {
@@ -136,8 +268,7 @@ func (agent *FTPTransferAgent) Ping() error {
 		return errors.New("nil FTPTransferAgent")
 	}
 
-	agent.mu.Lock()
-	defer agent.mu.Unlock()
+	defer agent.lock()()
 
 	conn, err := agent.connection()
 	agent.record(err)
@@ -162,12 +293,14 @@ func (agent *FTPTransferAgent) record(err error) {
 }
 
 func (agent *FTPTransferAgent) Close() error {
+	if agent != nil && agent.session != nil {
+		return agent.closeSession()
+	}
 	if agent == nil || agent.conn == nil {
 		return nil
 	}
 
-	agent.mu.Lock()
-	defer agent.mu.Unlock()
+	defer agent.lock()()
 
 	conn, err := agent.connection()
 	if err != nil {
@@ -176,20 +309,34 @@ func (agent *FTPTransferAgent) Close() error {
 	return conn.Quit()
 }
 
+// closeSession quits the shared connection once no other agents are using it
+func (agent *FTPTransferAgent) closeSession() error {
+	if !agent.session.pool.release(agent.session) {
+		return nil
+	}
+	defer agent.lock()()
+
+	if conn := agent.session.ftpConn; conn != nil {
+		agent.session.ftpConn = nil
+		return conn.Quit()
+	}
+	return nil
+}
+
 func (agent *FTPTransferAgent) InboundPath() string {
-	return agent.cfg.Paths.Inbound
+	return currentPaths(agent.cfg.Paths, "").Inbound
 }
 
 func (agent *FTPTransferAgent) OutboundPath() string {
-	return agent.cfg.Paths.Outbound
+	return currentPaths(agent.cfg.Paths, "").Outbound
 }
 
 func (agent *FTPTransferAgent) ReconciliationPath() string {
-	return agent.cfg.Paths.Reconciliation
+	return currentPaths(agent.cfg.Paths, "").Reconciliation
 }
 
 func (agent *FTPTransferAgent) ReturnPath() string {
-	return agent.cfg.Paths.Return
+	return currentPaths(agent.cfg.Paths, "").Return
 }
 
 func (agent *FTPTransferAgent) Hostname() string {
@@ -200,8 +347,7 @@ func (agent *FTPTransferAgent) Hostname() string {
 }
 
 func (agent *FTPTransferAgent) Delete(path string) error {
-	agent.mu.Lock()
-	defer agent.mu.Unlock()
+	defer agent.lock()()
 
 	if path == "" || strings.HasSuffix(path, "/") {
 		return fmt.Errorf("FTPTransferAgent: invalid path %v", path)
@@ -219,14 +365,47 @@ func (agent *FTPTransferAgent) Delete(path string) error {
 	return nil
 }
 
+func (agent *FTPTransferAgent) Move(oldpath, newpath string) error {
+	defer agent.lock()()
+
+	conn, err := agent.connection()
+	if err != nil {
+		return err
+	}
+
+	agent.makeDirs(conn, filepath.Dir(newpath))
+	if err := conn.Rename(oldpath, newpath); err != nil {
+		return fmt.Errorf("ftp: move %s: %v", oldpath, err)
+	}
+	return nil
+}
+
+func (agent *FTPTransferAgent) Exists(path string) (bool, error) {
+	defer agent.lock()()
+
+	conn, err := agent.connection()
+	if err != nil {
+		return false, err
+	}
+
+	// Servers reply to SIZE with 550 (or sometimes 450) when the file doesn't exist
+	if _, err := conn.FileSize(path); err != nil {
+		var perr *textproto.Error
+		if errors.As(err, &perr) && (perr.Code == ftp.StatusFileUnavailable || perr.Code == ftp.StatusFileActionIgnored) {
+			return false, nil
+		}
+		return false, fmt.Errorf("ftp: checking %s: %v", path, err)
+	}
+	return true, nil
+}
+
 // uploadFile saves the content of File at the given filename in the OutboundPath directory
 //
 // The File's contents will always be closed
 func (agent *FTPTransferAgent) UploadFile(f File) error {
 	defer f.Close()
 
-	agent.mu.Lock()
-	defer agent.mu.Unlock()
+	defer agent.lock()()
 
 	conn, err := agent.connection()
 	if err != nil {
@@ -238,7 +417,12 @@ func (agent *FTPTransferAgent) UploadFile(f File) error {
 	if err != nil {
 		return err
 	}
-	if err := conn.ChangeDir(agent.cfg.Paths.Outbound); err != nil {
+	outbound := currentPaths(agent.cfg.Paths, f.RoutingNumber).Outbound
+	if templatedPath(agent.cfg.Paths.Outbound) {
+		// Templated paths (like dated directories) are created as they're needed
+		agent.makeDirs(conn, outbound)
+	}
+	if err := conn.ChangeDir(outbound); err != nil {
 		return err
 	}
 	defer func(path string) {
@@ -250,24 +434,43 @@ func (agent *FTPTransferAgent) UploadFile(f File) error {
 
 	// Write file contents into path
 	// Take the base of f.Filename and our (out of band) OutboundPath to avoid accepting a write like '../../../../etc/passwd'.
-	return conn.Stor(filepath.Base(f.Filename), f.Contents)
+	return conn.Stor(filepath.Base(f.Filename), agent.throttle.reader(f.Contents))
+}
+
+// makeDirs creates each directory of dir. Directories which exist fail to be created again,
+// so errors are left for ChangeDir to return.
+func (agent *FTPTransferAgent) makeDirs(conn *ftp.ServerConn, dir string) {
+	var parent string
+	if strings.HasPrefix(dir, "/") {
+		parent = "/"
+	}
+	for _, part := range strings.Split(filepath.ToSlash(dir), "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		parent = filepath.ToSlash(filepath.Join(parent, part))
+		conn.MakeDir(parent)
+	}
 }
 
 func (agent *FTPTransferAgent) GetInboundFiles() ([]File, error) {
-	return agent.readFiles(agent.cfg.Paths.Inbound)
+	return agent.readFiles(agent.InboundPath(), agent.cfg.Paths.InboundFilter)
 }
 
 func (agent *FTPTransferAgent) GetReconciliationFiles() ([]File, error) {
-	return agent.readFiles(agent.cfg.Paths.Reconciliation)
+	return agent.readFiles(agent.ReconciliationPath(), agent.cfg.Paths.ReconciliationFilter)
 }
 
 func (agent *FTPTransferAgent) GetReturnFiles() ([]File, error) {
-	return agent.readFiles(agent.cfg.Paths.Return)
+	return agent.readFiles(agent.ReturnPath(), agent.cfg.Paths.ReturnFilter)
 }
 
-func (agent *FTPTransferAgent) readFiles(path string) ([]File, error) {
-	agent.mu.Lock()
-	defer agent.mu.Unlock()
+func (agent *FTPTransferAgent) readFiles(path string, filter *service.FilenameFilter) ([]File, error) {
+	matches, err := filter.Matcher()
+	if err != nil {
+		return nil, err
+	}
+	defer agent.lock()()
 
 	conn, err := agent.connection()
 	if err != nil {
@@ -289,47 +492,165 @@ func (agent *FTPTransferAgent) readFiles(path string) ([]File, error) {
 		return nil, err
 	}
 
-	items, err := conn.NameList("")
+	agent.skipped = nil
+
+	listing := agent.listings.list(path)
+	listed, err := agent.listFiles(conn, path, listing)
 	if err != nil {
 		return nil, err
 	}
-	var files []File
-	for i := range items {
-		resp, err := conn.Retr(items[i])
+	var items []string
+	for _, name := range listed {
+		if !matches(filepath.Base(name)) {
+			agent.skipped = append(agent.skipped, SkippedFile{
+				Path:   filepath.Join(path, name),
+				Reason: SkipFiltered,
+			})
+			continue
+		}
+		items = append(items, name)
+	}
+
+	spool := newSpooler(agent.cfg.Downloads)
+
+	// FTP connections transfer one file at a time, so each extra worker has its own connection
+	conns := agent.downloadConns(conn, wd, path, len(items))
+	defer func() {
+		for _, extra := range conns[1:] {
+			extra.Quit()
+		}
+	}()
+
+	var mu sync.Mutex
+	downloaded := make([]*File, len(items))
+	err = downloadEach(len(items), len(conns), func(worker, i int) error {
+		resp, err := conns[worker].Retr(items[i])
 		if err != nil {
-			return nil, fmt.Errorf("problem retrieving %s: %v", items[i], err)
+			return fmt.Errorf("problem retrieving %s: %v", items[i], err)
 		}
 
-		r, err := agent.readResponse(resp)
+		r, err := agent.readResponse(spool, resp)
 		if err != nil {
-			return nil, fmt.Errorf("problem reading %s: %v", items[i], err)
+			return fmt.Errorf("problem reading %s: %v", items[i], err)
 		}
 		if r != nil {
-			files = append(files, File{
+			downloaded[i] = &File{
 				Filename: items[i],
 				Contents: r,
+			}
+		} else {
+			mu.Lock()
+			agent.skipped = append(agent.skipped, SkippedFile{
+				Path:   filepath.Join(path, items[i]),
+				Reason: SkipDirectory,
 			})
+			mu.Unlock()
 		}
+		return nil
+	})
+	files := collectDownloads(downloaded)
+	if err != nil {
+		closeFiles(files)
+		return nil, err
 	}
+	listing.done()
+
 	return files, nil
 }
 
-func (*FTPTransferAgent) readResponse(resp *ftp.Response) (io.ReadCloser, error) {
+// downloadConns returns conn and the extra connections, already in path, used to download
+// files in parallel. Fewer workers are used when extra connections can't be opened.
+func (agent *FTPTransferAgent) downloadConns(conn *ftp.ServerConn, wd, path string, files int) []*ftp.ServerConn {
+	conns := []*ftp.ServerConn{conn}
+	for len(conns) < agent.cfg.FTP.Workers() && len(conns) < files {
+		extra, err := agent.dial()
+		if err == nil {
+			if err = extra.ChangeDir(wd); err == nil {
+				err = extra.ChangeDir(path)
+			}
+			if err != nil {
+				extra.Quit()
+			}
+		}
+		if err != nil {
+			agent.logger.Warn().Logf("ftp: downloading with %d connections: %v", len(conns), err)
+			break
+		}
+		conns = append(conns, extra)
+	}
+	return conns
+}
+
+// listFiles returns the names in the current directory to download. Cached listings need
+// each file's size and modification time and subdirectories need each entry's type, so they're
+// read with LIST instead of NLST. Files in subdirectories are named relative to the current
+// directory.
+func (agent *FTPTransferAgent) listFiles(conn *ftp.ServerConn, dir string, listing *listing) ([]string, error) {
+	if agent.listings == nil && agent.cfg.Paths.Depth == 0 {
+		return conn.NameList("")
+	}
+	var items []string
+	var list func(rel string, level int) error
+	list = func(rel string, level int) error {
+		entries, err := conn.List(rel)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			name := filepath.Join(rel, entry.Name)
+			switch {
+			case entry.Name == "." || entry.Name == "..":
+				continue
+			case entry.Type == ftp.EntryTypeFolder && level < agent.cfg.Paths.Depth:
+				if err := list(name, level+1); err != nil {
+					return err
+				}
+			case entry.Type == ftp.EntryTypeFolder:
+				agent.skipped = append(agent.skipped, SkippedFile{
+					Path:   filepath.Join(dir, name),
+					Reason: SkipDirectory,
+				})
+			case entry.Type == ftp.EntryTypeFile && listing.unchanged(name, int64(entry.Size), entry.Time):
+				continue
+			default:
+				items = append(items, name)
+			}
+		}
+		return nil
+	}
+	if err := list("", 0); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (agent *FTPTransferAgent) CommitListings() {
+	agent.listings.commit()
+}
+
+func (agent *FTPTransferAgent) SkippedFiles() []SkippedFile {
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	return agent.skipped
+}
+
+func (agent *FTPTransferAgent) readResponse(spool *spooler, resp *ftp.Response) (io.ReadCloser, error) {
 	defer resp.Close()
 
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, resp)
+	r, n, err := spool.spool(agent.throttle.reader(resp))
+	if err != nil {
+		return nil, fmt.Errorf("n=%d error=%v", n, err)
+	}
 	// If there was nothing downloaded and no error then assume it's a directory.
 	//
 	// The FTP client doesn't have a STAT command, so we can't quite ensure this
 	// was a directory.
 	//
 	// See https://github.com/moovfinancial/paygate/issues/494
-	if n == 0 && err == nil {
+	if n == 0 {
+		r.Close()
 		return nil, nil
 	}
-	if err != nil {
-		return nil, fmt.Errorf("n=%d error=%v", n, err)
-	}
-	return io.NopCloser(&buf), nil
+	return r, nil
 }
@@ -6,13 +6,22 @@ package upload
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -39,7 +48,7 @@ func port() int {
 	return int(30000 + (portSource.Int63() % 9999))
 }
 
-func createTestFTPServer(t *testing.T) (*server.Server, error) {
+func createTestFTPServer(t *testing.T, configure ...func(opts *server.ServerOpts)) (*server.Server, error) {
 	t.Helper()
 	if testing.Short() {
 		t.Skip("skipping due to -short")
@@ -63,6 +72,9 @@ func createTestFTPServer(t *testing.T) (*server.Server, error) {
 		Port:     port(),
 		Logger:   &server.DiscardLogger{},
 	}
+	for i := range configure {
+		configure[i](opts)
+	}
 	svc := server.NewServer(opts)
 	if svc == nil {
 		return nil, errors.New("nil FTP server")
@@ -152,7 +164,7 @@ func createTestFTPAgent(t *testing.T) (*server.Server, *FTPTransferAgent) {
 			Return:         "returned",
 		},
 	}
-	agent, err := newFTPTransferAgent(log.NewNopLogger(), cfg)
+	agent, err := newFTPTransferAgent(log.NewNopLogger(), cfg, nil)
 	if err != nil {
 		svc.Shutdown()
 		t.Fatalf("problem creating Agent: %v", err)
@@ -202,7 +214,136 @@ func TestFTPAgent_Hostname(t *testing.T) {
 	}
 }
 
-func TestFTP__tlsDialOption(t *testing.T) {
+// writeFTPSCertificate writes a self-signed certificate for localhost, returning the paths of it and its key
+func writeFTPSCertificate(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(crand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "ftps.crt"), filepath.Join(dir, "ftps.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+// resumedSessions counts the TLS sessions offered to the server for resumption
+type resumedSessions struct {
+	tls.ClientSessionCache
+
+	mu      sync.Mutex
+	resumed int
+}
+
+func (c *resumedSessions) Get(key string) (*tls.ClientSessionState, bool) {
+	session, ok := c.ClientSessionCache.Get(key)
+	if ok {
+		c.mu.Lock()
+		c.resumed++
+		c.mu.Unlock()
+	}
+	return session, ok
+}
+
+func TestFTP__ExplicitTLS(t *testing.T) {
+	// The test server only encrypts data connections after AUTH TLS, so implicit TLS isn't covered
+	certFile, keyFile := writeFTPSCertificate(t)
+	svc, err := createTestFTPServer(t, func(opts *server.ServerOpts) {
+		opts.TLS = true
+		opts.ExplicitFTPS = true
+		opts.ForceTLS = true
+		opts.CertFile = certFile
+		opts.KeyFile = keyFile
+	})
+	require.NoError(t, err)
+	defer svc.Shutdown()
+
+	cfg := &service.UploadAgent{
+		FTP: &service.FTP{
+			Hostname:   fmt.Sprintf("localhost:%d", svc.Port),
+			Username:   "moov",
+			Password:   "password",
+			CAFilepath: certFile,
+			TLS: &service.FTPTLS{
+				Mode:         service.FTPExplicitTLS,
+				SessionReuse: true,
+			},
+		},
+		Paths: service.UploadPaths{
+			Inbound: "inbound",
+		},
+	}
+	sessions := &resumedSessions{ClientSessionCache: newFTPSessionCache(cfg.FTP)}
+	agent := &FTPTransferAgent{cfg: *cfg, logger: log.NewNopLogger(), tlsSessions: sessions}
+	defer agent.Close()
+
+	// Data connections are encrypted and resume the control connection's session
+	files, err := agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	require.Greater(t, sessions.resumed, 0)
+
+	// Certificates are verified against the hostname
+	cfg.FTP.Hostname = fmt.Sprintf("127.0.0.1:%d", svc.Port)
+	other := &FTPTransferAgent{cfg: *cfg, logger: log.NewNopLogger()}
+	defer other.Close()
+	_, err = other.GetInboundFiles()
+	require.ErrorContains(t, err, "certificate")
+}
+
+func TestFTP__DataPortRange(t *testing.T) {
+	low := port()
+	svc, err := createTestFTPServer(t, func(opts *server.ServerOpts) {
+		opts.PassivePorts = fmt.Sprintf("%d-%d", low, low+5)
+	})
+	require.NoError(t, err)
+	defer svc.Shutdown()
+
+	for _, disabledEPSV := range []bool{false, true} {
+		cfg := service.UploadAgent{
+			FTP: &service.FTP{
+				Hostname:      fmt.Sprintf("localhost:%d", svc.Port),
+				Username:      "moov",
+				Password:      "password",
+				DisabledEPSV:  disabledEPSV,
+				DataPortRange: fmt.Sprintf("%d-%d", low, low+5),
+			},
+			Paths: service.UploadPaths{
+				Inbound: "inbound",
+			},
+		}
+		agent := &FTPTransferAgent{cfg: cfg, logger: log.NewNopLogger()}
+		files, err := agent.GetInboundFiles()
+		require.NoError(t, err)
+		require.Len(t, files, 3)
+		agent.Close()
+
+		// Ports the server offers outside of the range are refused
+		cfg.FTP.DataPortRange = fmt.Sprintf("%d-%d", low+10, low+20)
+		agent = &FTPTransferAgent{cfg: cfg, logger: log.NewNopLogger()}
+		_, err = agent.GetInboundFiles()
+		require.ErrorContains(t, err, "outside of DataPortRange")
+		agent.Close()
+	}
+}
+
+func TestFTP__ftpTLSConfig(t *testing.T) {
 	if testing.Short() {
 		return // skip network calls
 	}
@@ -211,11 +352,9 @@ func TestFTP__tlsDialOption(t *testing.T) {
 	require.NoError(t, err)
 	defer os.Remove(cafile)
 
-	opt, err := tlsDialOption(cafile)
+	cfg, err := ftpTLSConfig(&service.FTP{Hostname: "google.com", CAFilepath: cafile}, nil)
 	require.NoError(t, err)
-	if opt == nil {
-		t.Fatal("nil tls DialOption")
-	}
+	require.NotNil(t, cfg)
 }
 
 func TestFTP__getInboundFiles(t *testing.T) {
@@ -258,6 +397,56 @@ func TestFTP__getInboundFiles(t *testing.T) {
 	}
 }
 
+func TestFTP__CacheListings(t *testing.T) {
+	svc, agent := createTestFTPAgent(t)
+	defer agent.Close()
+	defer svc.Shutdown()
+
+	agent.listings = newListingCache(true, agent.Hostname())
+
+	files, err := agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	// Unchanged files aren't downloaded once committed
+	CommitListings(agent)
+	files, err = agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	files, err = agent.GetReturnFiles()
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+}
+
+func TestFTP__DownloadWorkers(t *testing.T) {
+	svc, agent := createTestFTPAgent(t)
+	defer agent.Close()
+	defer svc.Shutdown()
+
+	expected, err := agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Len(t, expected, 3)
+
+	// Files are downloaded over several connections and returned in the same order
+	agent.cfg.FTP.DownloadWorkers = 3
+	files, err := agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Len(t, files, len(expected))
+	for i := range files {
+		require.Equal(t, expected[i].Filename, files[i].Filename)
+
+		want, err := io.ReadAll(expected[i].Contents)
+		require.NoError(t, err)
+		got, err := io.ReadAll(files[i].Contents)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+
+	// The agent's own connection still works afterwards
+	require.NoError(t, agent.Ping())
+}
+
 func TestFTP__getReconciliationFiles(t *testing.T) {
 	svc, agent := createTestFTPAgent(t)
 	defer agent.Close()
@@ -349,7 +538,7 @@ func TestFTP__uploadFile(t *testing.T) {
 	if resp == nil {
 		t.Fatal("nil File response")
 	}
-	r, _ := agent.readResponse(resp)
+	r, _ := agent.readResponse(newSpooler(nil), resp)
 	if r == nil {
 		t.Fatal("failed to read file")
 	}
@@ -403,3 +592,23 @@ func TestFTP__DeleteMissing(t *testing.T) {
 	err := agent.Delete("/missing.txt")
 	require.NoError(t, err)
 }
+
+func TestFTP__Exists(t *testing.T) {
+	svc, agent := createTestFTPAgent(t)
+	defer agent.Close()
+	defer svc.Shutdown()
+
+	exists, err := agent.Exists("/missing.txt")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	f := File{
+		Filename: "archive/exists.ach",
+		Contents: io.NopCloser(strings.NewReader("file")),
+	}
+	require.NoError(t, agent.UploadFile(f))
+
+	exists, err = agent.Exists(RemotePath(&agent.cfg, f))
+	require.NoError(t, err)
+	require.True(t, exists)
+}
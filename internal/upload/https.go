@@ -0,0 +1,389 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/moov-io/achgateway/internal/fips"
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	httpsAgentUp = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Name: "https_agent_up",
+		Help: "Status of HTTPS agent connection",
+	}, []string{"hostname"})
+)
+
+// HTTPSTransferAgent is an implementation of Agent which exchanges files with an ODFI's REST API.
+//
+// Files are uploaded as multipart/form-data (the "file" field) with POST <BaseURL>/<Outbound>.
+// Each other path is listed with GET <BaseURL>/<path>, which responds with JSON like
+// {"files":[{"name":"20220601.ach"}]}, and files are downloaded with GET <BaseURL>/<path>/<name>
+// and removed with DELETE.
+type HTTPSTransferAgent struct {
+	client *http.Client
+	cfg    service.UploadAgent
+	logger log.Logger
+
+	// skipped are the entries passed over by the last readFiles call
+	mu      sync.Mutex
+	skipped []SkippedFile
+}
+
+func newHTTPSTransferAgent(logger log.Logger, cfg *service.UploadAgent) (*HTTPSTransferAgent, error) {
+	if cfg == nil || cfg.HTTPS == nil {
+		return nil, errors.New("nil HTTPS config")
+	}
+	if err := cfg.HTTPS.Validate(); err != nil {
+		return nil, fmt.Errorf("https: %v", err)
+	}
+	if err := rejectOutboundIPRange(cfg.SplitAllowedIPs(), cfg.HTTPS.Hostname()); err != nil {
+		return nil, fmt.Errorf("https: %s is not whitelisted: %v", cfg.HTTPS.Hostname(), err)
+	}
+	client, err := httpsClient(cfg.HTTPS)
+	if err != nil {
+		return nil, fmt.Errorf("https: %v", err)
+	}
+	return &HTTPSTransferAgent{
+		client: client,
+		cfg:    *cfg,
+		logger: logger,
+	}, nil
+}
+
+func httpsClient(cfg *service.HTTPS) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.CAFile != "" {
+		bs, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CAFile: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if pool == nil || err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bs) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = fips.TLSConfig(tlsConfig)
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.RequestTimeout(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// Don't follow redirects which could send our bearer token elsewhere
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+func (agent *HTTPSTransferAgent) ID() string {
+	return agent.cfg.ID
+}
+
+// endpoint returns the URL of BaseURL joined with each element
+func (agent *HTTPSTransferAgent) endpoint(elem ...string) string {
+	u, err := url.Parse(agent.cfg.HTTPS.BaseURL)
+	if err != nil {
+		return agent.cfg.HTTPS.BaseURL
+	}
+	u.Path = path.Join(append([]string{"/", u.Path}, elem...)...)
+	return u.String()
+}
+
+func (agent *HTTPSTransferAgent) do(method, endpoint string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token := agent.cfg.HTTPS.BearerToken; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := agent.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return resp, fmt.Errorf("%s %s: unexpected %s", method, req.URL.Path, resp.Status)
+	}
+	return resp, nil
+}
+
+func (agent *HTTPSTransferAgent) Ping() error {
+	if agent == nil || agent.cfg.HTTPS == nil {
+		return errors.New("nil HTTPSTransferAgent")
+	}
+	// List a path the agent reads from, or the BaseURL when it only uploads
+	var err error
+	if dir := agent.pingPath(); dir != "" {
+		_, err = agent.list(dir)
+	} else {
+		var resp *http.Response
+		if resp, err = agent.do(http.MethodGet, agent.endpoint(), nil, ""); err == nil {
+			resp.Body.Close()
+		}
+	}
+	if err != nil {
+		httpsAgentUp.With("hostname", agent.Hostname()).Set(0)
+	} else {
+		httpsAgentUp.With("hostname", agent.Hostname()).Set(1)
+	}
+	return err
+}
+
+func (agent *HTTPSTransferAgent) pingPath() string {
+	for _, dir := range []string{agent.InboundPath(), agent.ReturnPath(), agent.ReconciliationPath()} {
+		if dir != "" {
+			return dir
+		}
+	}
+	return ""
+}
+
+func (agent *HTTPSTransferAgent) Close() error {
+	if agent == nil || agent.client == nil {
+		return nil
+	}
+	agent.client.CloseIdleConnections()
+	return nil
+}
+
+func (agent *HTTPSTransferAgent) InboundPath() string {
+	return currentPaths(agent.cfg.Paths, "").Inbound
+}
+
+func (agent *HTTPSTransferAgent) OutboundPath() string {
+	return currentPaths(agent.cfg.Paths, "").Outbound
+}
+
+func (agent *HTTPSTransferAgent) ReconciliationPath() string {
+	return currentPaths(agent.cfg.Paths, "").Reconciliation
+}
+
+func (agent *HTTPSTransferAgent) ReturnPath() string {
+	return currentPaths(agent.cfg.Paths, "").Return
+}
+
+func (agent *HTTPSTransferAgent) Hostname() string {
+	if agent == nil {
+		return ""
+	}
+	return agent.cfg.HTTPS.Hostname()
+}
+
+func (agent *HTTPSTransferAgent) Delete(path string) error {
+	if path == "" || strings.HasSuffix(path, "/") {
+		return fmt.Errorf("HTTPSTransferAgent: invalid path %v", path)
+	}
+	resp, err := agent.do(http.MethodDelete, agent.endpoint(path), nil, "")
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// UploadFile POSTs the content of File to the OutboundPath
+//
+// The File's contents will always be closed
+func (agent *HTTPSTransferAgent) UploadFile(f File) error {
+	defer f.Close()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	// Take the base of f.Filename to avoid accepting a write like '../../../../etc/passwd'.
+	part, err := w.CreateFormFile("file", filepath.Base(f.Filename))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, f.Contents); err != nil {
+		return fmt.Errorf("https: reading %s: %v", f.Filename, err)
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	outbound := currentPaths(agent.cfg.Paths, f.RoutingNumber).Outbound
+	resp, err := agent.do(http.MethodPost, agent.endpoint(outbound), &body, w.FormDataContentType())
+	if err != nil {
+		return fmt.Errorf("https: uploading %s: %v", filepath.Base(f.Filename), err)
+	}
+	return resp.Body.Close()
+}
+
+func (agent *HTTPSTransferAgent) GetInboundFiles() ([]File, error) {
+	return agent.readFiles(agent.InboundPath(), agent.cfg.Paths.InboundFilter)
+}
+
+func (agent *HTTPSTransferAgent) GetReconciliationFiles() ([]File, error) {
+	return agent.readFiles(agent.ReconciliationPath(), agent.cfg.Paths.ReconciliationFilter)
+}
+
+func (agent *HTTPSTransferAgent) GetReturnFiles() ([]File, error) {
+	return agent.readFiles(agent.ReturnPath(), agent.cfg.Paths.ReturnFilter)
+}
+
+type httpsListing struct {
+	Files []httpsEntry `json:"files"`
+}
+
+type httpsEntry struct {
+	Name string `json:"name"`
+
+	// Type is "file" (the default) or "directory"
+	Type string `json:"type"`
+}
+
+func (agent *HTTPSTransferAgent) list(dir string) ([]httpsEntry, error) {
+	resp, err := agent.do(http.MethodGet, agent.endpoint(dir), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var listing httpsListing
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("reading %s listing: %v", dir, err)
+	}
+	return listing.Files, nil
+}
+
+func (agent *HTTPSTransferAgent) readFiles(dir string, filter *service.FilenameFilter) ([]File, error) {
+	var skipped []SkippedFile
+	defer func() {
+		agent.mu.Lock()
+		agent.skipped = skipped
+		agent.mu.Unlock()
+	}()
+
+	matches, err := filter.Matcher()
+	if err != nil {
+		return nil, err
+	}
+	spool := newSpooler(agent.cfg.Downloads)
+
+	// Directories are listed up to Paths.Depth levels and their files named relative to dir
+	var files []File
+	var read func(rel string, level int) error
+	read = func(rel string, level int) error {
+		entries, err := agent.list(path.Join(dir, rel))
+		if err != nil {
+			return fmt.Errorf("https: listing %s: %v", path.Join(dir, rel), err)
+		}
+		for i := range entries {
+			name := entries[i].Name
+			valid := name != "" && name != "." && name != ".." && name == path.Base(name)
+			if strings.EqualFold(entries[i].Type, "directory") {
+				if valid && level < agent.cfg.Paths.Depth {
+					if err := read(path.Join(rel, name), level+1); err != nil {
+						return err
+					}
+					continue
+				}
+				skipped = append(skipped, SkippedFile{
+					Path:   path.Join(dir, rel, name),
+					Reason: SkipDirectory,
+				})
+				continue
+			}
+			if !valid {
+				return fmt.Errorf("https: invalid filename %q in %s listing", name, path.Join(dir, rel))
+			}
+			if !matches(name) {
+				skipped = append(skipped, SkippedFile{
+					Path:   path.Join(dir, rel, name),
+					Reason: SkipFiltered,
+				})
+				continue
+			}
+
+			contents, err := agent.readFile(spool, agent.endpoint(dir, rel, name))
+			if err != nil {
+				return fmt.Errorf("https: problem reading %s: %v", path.Join(dir, rel, name), err)
+			}
+			files = append(files, File{
+				Filename: path.Join(rel, name),
+				Contents: contents,
+			})
+		}
+		return nil
+	}
+	if err := read("", 0); err != nil {
+		closeFiles(files)
+		return nil, err
+	}
+	return files, nil
+}
+
+func (agent *HTTPSTransferAgent) SkippedFiles() []SkippedFile {
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	return agent.skipped
+}
+
+func (agent *HTTPSTransferAgent) readFile(spool *spooler, endpoint string) (io.ReadCloser, error) {
+	resp, err := agent.do(http.MethodGet, endpoint, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	contents, _, err := spool.spool(resp.Body)
+	return contents, err
+}
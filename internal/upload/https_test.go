@@ -0,0 +1,192 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fileDropServer is a minimal REST file exchange API
+type fileDropServer struct {
+	mu    sync.Mutex
+	files map[string]string // path -> contents
+}
+
+func (s *fileDropServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer secret-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := strings.TrimPrefix(r.URL.Path, "/api/")
+	switch r.Method {
+	case http.MethodGet:
+		if contents, exists := s.files[p]; exists {
+			io.WriteString(w, contents)
+			return
+		}
+		var listing httpsListing
+		for name := range s.files {
+			if dir, base := filepath.Split(name); strings.TrimSuffix(dir, "/") == p {
+				listing.Files = append(listing.Files, httpsEntry{Name: base})
+			}
+		}
+		if p == "inbound" {
+			listing.Files = append(listing.Files, httpsEntry{Name: "archive", Type: "directory"})
+		}
+		json.NewEncoder(w).Encode(listing)
+
+	case http.MethodPost:
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bs, _ := io.ReadAll(file)
+		s.files[p+"/"+header.Filename] = string(bs)
+		w.WriteHeader(http.StatusCreated)
+
+	case http.MethodDelete:
+		if _, exists := s.files[p]; !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.files, p)
+	}
+}
+
+func newTestHTTPSAgent(t *testing.T) (*fileDropServer, *HTTPSTransferAgent) {
+	t.Helper()
+
+	handler := &fileDropServer{
+		files: map[string]string{
+			"inbound/20220601.ach":  "inbound file",
+			"returned/20220601.ach": "return file",
+		},
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	// Trust the test server's certificate
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, ca, 0600))
+
+	certs := filepath.Join("..", "..", "dev", "consul", "certs")
+	agent, err := newHTTPSTransferAgent(log.NewNopLogger(), &service.UploadAgent{
+		ID: "https",
+		HTTPS: &service.HTTPS{
+			BaseURL:        srv.URL + "/api/",
+			BearerToken:    "secret-token",
+			ClientCertFile: filepath.Join(certs, "dc1-server-consul-0.pem"),
+			ClientKeyFile:  filepath.Join(certs, "dc1-server-consul-0-key.pem"),
+			CAFile:         caFile,
+		},
+		Paths: service.UploadPaths{
+			Inbound:  "inbound",
+			Outbound: "outbound/{{ routingNumber }}",
+			Return:   "returned",
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { agent.Close() })
+
+	return handler, agent
+}
+
+func TestHTTPSAgent(t *testing.T) {
+	handler, agent := newTestHTTPSAgent(t)
+	require.Equal(t, "https", agent.ID())
+	require.Contains(t, agent.Hostname(), "127.0.0.1:")
+	require.NoError(t, agent.Ping())
+
+	err := agent.UploadFile(File{
+		Filename:      "../20220601-1200.ach",
+		Contents:      io.NopCloser(strings.NewReader("nacha")),
+		RoutingNumber: "123456789",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "nacha", handler.files["outbound/123456789/20220601-1200.ach"])
+
+	files, err := agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "20220601.ach", files[0].Filename)
+	bs, _ := io.ReadAll(files[0].Contents)
+	require.Equal(t, "inbound file", string(bs))
+	require.Equal(t, []SkippedFile{{Path: "inbound/archive", Reason: SkipDirectory}}, agent.SkippedFiles())
+
+	files, err = agent.GetReturnFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	files, err = agent.GetReconciliationFiles()
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	require.NoError(t, agent.Delete("inbound/20220601.ach"))
+	require.NoError(t, agent.Delete("inbound/20220601.ach")) // already deleted
+	require.NotContains(t, handler.files, "inbound/20220601.ach")
+	require.Error(t, agent.Delete("inbound/"))
+}
+
+func TestHTTPSAgent__Unauthorized(t *testing.T) {
+	_, agent := newTestHTTPSAgent(t)
+	agent.cfg.HTTPS.BearerToken = "wrong"
+
+	err := agent.Ping()
+	require.ErrorContains(t, err, "401 Unauthorized")
+}
+
+func TestHTTPSAgent__Diagnose(t *testing.T) {
+	_, agent := newTestHTTPSAgent(t)
+	agent.cfg.Paths.Outbound = "outbound"
+
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{agent.cfg},
+	}
+	diag, err := Diagnose(log.NewNopLogger(), cfg, "https", DoctorOptions{TransferSize: 1024})
+	require.NoError(t, err)
+	for _, check := range diag.Checks {
+		require.Equal(t, CheckOK, check.Status, "%s: %s", check.Name, check.Detail)
+	}
+	require.Len(t, diag.Checks, 5) // allowed ips, connect, inbound, return and transfer
+}
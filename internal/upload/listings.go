@@ -0,0 +1,130 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	remoteFilesUnchanged = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "remote_files_unchanged",
+		Help: "Counter of remote files not downloaded because they're unchanged since a previous scan",
+	}, []string{"hostname"})
+)
+
+// ListingCommitter is implemented by Agents which cache remote directory listings so later
+// reads only download files which are new or have changed size or modification time.
+type ListingCommitter interface {
+	// CommitListings remembers the files listed by reads since the last commit. It's called
+	// once those files are processed, so files from a failed scan are downloaded again.
+	CommitListings()
+}
+
+// CommitListings commits agent's cached listings, if it keeps them.
+func CommitListings(agent Agent) {
+	if lc, ok := agent.(ListingCommitter); ok {
+		lc.CommitListings()
+	}
+}
+
+// remoteFile is what's compared to tell if a listed file changed
+type remoteFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// listingCache holds the files of each remote directory as of the last committed scan.
+// A nil listingCache downloads every file.
+type listingCache struct {
+	hostname string
+
+	mu        sync.Mutex
+	committed map[string]map[string]remoteFile
+	pending   map[string]map[string]remoteFile
+}
+
+func newListingCache(enabled bool, hostname string) *listingCache {
+	if !enabled {
+		return nil
+	}
+	return &listingCache{
+		hostname:  hostname,
+		committed: make(map[string]map[string]remoteFile),
+		pending:   make(map[string]map[string]remoteFile),
+	}
+}
+
+// listing records the files read from dir. Files found unchanged since the last commit
+// aren't downloaded.
+type listing struct {
+	cache *listingCache
+	dir   string
+	files map[string]remoteFile
+}
+
+func (c *listingCache) list(dir string) *listing {
+	return &listing{cache: c, dir: dir, files: make(map[string]remoteFile)}
+}
+
+// unchanged records name as listed and returns true when it matches the committed listing
+func (l *listing) unchanged(name string, size int64, modTime time.Time) bool {
+	if l == nil || l.cache == nil {
+		return false
+	}
+	file := remoteFile{size: size, modTime: modTime}
+	l.files[name] = file
+
+	l.cache.mu.Lock()
+	defer l.cache.mu.Unlock()
+
+	if committed, exists := l.cache.committed[l.dir][name]; exists && committed.size == size && committed.modTime.Equal(modTime) {
+		remoteFilesUnchanged.With("hostname", l.cache.hostname).Add(1)
+		return true
+	}
+	return false
+}
+
+// done saves the listing to be committed. Incomplete listings, such as after an error,
+// are discarded instead.
+func (l *listing) done() {
+	if l == nil || l.cache == nil {
+		return
+	}
+	l.cache.mu.Lock()
+	defer l.cache.mu.Unlock()
+
+	l.cache.pending[l.dir] = l.files
+}
+
+func (c *listingCache) commit() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for dir, files := range c.pending {
+		c.committed[dir] = files
+	}
+	c.pending = make(map[string]map[string]remoteFile)
+}
@@ -0,0 +1,65 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListingCache(t *testing.T) {
+	modTime := time.Date(2022, time.June, 1, 10, 0, 0, 0, time.UTC)
+
+	// Disabled caches download everything
+	var cache *listingCache
+	listing := cache.list("returned")
+	require.False(t, listing.unchanged("ret.ach", 100, modTime))
+	listing.done()
+	cache.commit()
+
+	cache = newListingCache(true, "sftp.bank.com")
+	listing = cache.list("returned")
+	require.False(t, listing.unchanged("ret.ach", 100, modTime))
+	listing.done()
+
+	// Nothing is skipped until the listing is committed
+	listing = cache.list("returned")
+	require.False(t, listing.unchanged("ret.ach", 100, modTime))
+	listing.done()
+	cache.commit()
+
+	listing = cache.list("returned")
+	require.True(t, listing.unchanged("ret.ach", 100, modTime))
+	require.False(t, listing.unchanged("ret.ach", 200, modTime))
+	require.False(t, listing.unchanged("new.ach", 100, modTime))
+	require.False(t, cache.list("inbound").unchanged("ret.ach", 100, modTime))
+
+	// Incomplete listings aren't committed
+	cache.commit()
+	listing = cache.list("returned")
+	require.True(t, listing.unchanged("ret.ach", 100, modTime))
+	require.False(t, listing.unchanged("ret.ach", 100, modTime.Add(time.Minute)))
+	listing.done()
+	cache.commit()
+
+	listing = cache.list("returned")
+	require.False(t, listing.unchanged("ret.ach", 100, modTime))
+	require.True(t, listing.unchanged("ret.ach", 100, modTime.Add(time.Minute)))
+}
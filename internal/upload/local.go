@@ -0,0 +1,201 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/moov-io/achgateway/internal/service"
+)
+
+// localDirectory reads and writes an agent's paths within a directory on this machine,
+// which AS2 and filesystem agents share.
+type localDirectory struct {
+	root string
+	perm os.FileMode
+
+	// downloads limits how much of the files read are held in memory
+	downloads *service.Downloads
+}
+
+// path returns where p is within the root, refusing paths which escape it
+func (d localDirectory) path(p string) (string, error) {
+	root := filepath.Clean(d.root)
+	where := filepath.Join(root, p)
+	if where != root && !strings.HasPrefix(where, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is outside of %s", p, d.root)
+	}
+	return where, nil
+}
+
+// write creates dir/filename with contents under a hidden name and renames it once it's
+// complete, so processes watching dir never read a partial file.
+func (d localDirectory) write(dir, filename string, contents []byte) error {
+	where, err := d.path(filepath.Join(dir, filepath.Base(filename)))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(where), 0750); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(where), "."+filepath.Base(where)+".tmp")
+	fd, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, d.perm)
+	if err != nil {
+		return err
+	}
+	if _, err := fd.Write(contents); err != nil {
+		fd.Close()
+		os.Remove(tmp)
+		return err
+	}
+	// Network filesystems may not have the contents until they're synced
+	if err := fd.Sync(); err != nil {
+		fd.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := fd.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, where); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// readFiles returns the files within dir and up to depth levels of its subdirectories which match
+// filter. Files in subdirectories are named by their path relative to dir. Deeper directories are
+// skipped and hidden files, which are still being written, are ignored. A missing dir has no files.
+func (d localDirectory) readFiles(dir string, filter *service.FilenameFilter, depth int) ([]File, []SkippedFile, error) {
+	matches, err := filter.Matcher()
+	if err != nil {
+		return nil, nil, err
+	}
+	where, err := d.path(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	spool := newSpooler(d.downloads)
+
+	var files []File
+	var skipped []SkippedFile
+	var read func(rel string, level int) error
+	read = func(rel string, level int) error {
+		entries, err := os.ReadDir(filepath.Join(where, rel))
+		if err != nil {
+			if os.IsNotExist(err) && rel == "" {
+				return nil
+			}
+			return fmt.Errorf("reading %s: %v", filepath.Join(dir, rel), err)
+		}
+		for i := range entries {
+			name := filepath.Join(rel, entries[i].Name())
+			if entries[i].IsDir() {
+				if level < depth {
+					if err := read(name, level+1); err != nil {
+						return err
+					}
+					continue
+				}
+				skipped = append(skipped, SkippedFile{
+					Path:   filepath.Join(dir, name),
+					Reason: SkipDirectory,
+				})
+				continue
+			}
+			if strings.HasPrefix(entries[i].Name(), ".") {
+				continue
+			}
+			if !matches(entries[i].Name()) {
+				skipped = append(skipped, SkippedFile{
+					Path:   filepath.Join(dir, name),
+					Reason: SkipFiltered,
+				})
+				continue
+			}
+			fd, err := os.Open(filepath.Join(where, name))
+			if err != nil {
+				return fmt.Errorf("problem reading %s: %v", filepath.Join(dir, name), err)
+			}
+			contents, _, err := spool.spool(fd)
+			fd.Close()
+			if err != nil {
+				return fmt.Errorf("problem reading %s: %v", filepath.Join(dir, name), err)
+			}
+			files = append(files, File{
+				Filename: filepath.ToSlash(name),
+				Contents: contents,
+			})
+		}
+		return nil
+	}
+	if err := read("", 0); err != nil {
+		closeFiles(files)
+		return nil, skipped, err
+	}
+	return files, skipped, nil
+}
+
+// move renames oldpath to newpath, creating the directories of newpath
+func (d localDirectory) move(oldpath, newpath string) error {
+	from, err := d.path(oldpath)
+	if err != nil {
+		return err
+	}
+	to, err := d.path(newpath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0750); err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+// exists returns true when p is a file
+func (d localDirectory) exists(p string) (bool, error) {
+	where, err := d.path(p)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(where)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
+
+// remove deletes p, which is fine if it's already gone
+func (d localDirectory) remove(p string) error {
+	where, err := d.path(p)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(where); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
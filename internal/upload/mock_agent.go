@@ -14,9 +14,11 @@ type MockAgent struct {
 	InboundFiles        []File
 	ReconciliationFiles []File
 	ReturnFiles         []File
-	UploadedFile        *File        // non-nil on file upload
-	DeletedFile         string       // filepath of last deleted file
-	mu                  sync.RWMutex // protects all fields
+	UploadedFile        *File             // non-nil on file upload
+	DeletedFile         string            // filepath of last deleted file
+	MovedFiles          map[string]string // oldpath to newpath of moved files
+	ExistingFiles       map[string]bool   // remote paths Exists returns true for
+	mu                  sync.RWMutex      // protects all fields
 
 	Err error
 }
@@ -65,6 +67,24 @@ func (a *MockAgent) Delete(path string) error {
 	return nil
 }
 
+func (a *MockAgent) Move(oldpath, newpath string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.MovedFiles == nil {
+		a.MovedFiles = make(map[string]string)
+	}
+	a.MovedFiles[oldpath] = newpath
+	return nil
+}
+
+func (a *MockAgent) Exists(path string) (bool, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.ExistingFiles[path], nil
+}
+
 func (a *MockAgent) InboundPath() string {
 	return "inbound/"
 }
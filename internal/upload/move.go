@@ -0,0 +1,37 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"fmt"
+)
+
+// Mover is implemented by Agents which can move files on the remote server, creating the
+// directories of newpath as needed.
+type Mover interface {
+	Move(oldpath, newpath string) error
+}
+
+// Move moves oldpath to newpath on agent's remote server, or returns an error if agent
+// can't move files.
+func Move(agent Agent, oldpath, newpath string) error {
+	if m, ok := agent.(Mover); ok {
+		return m.Move(oldpath, newpath)
+	}
+	return fmt.Errorf("agent %s can't move files", agent.ID())
+}
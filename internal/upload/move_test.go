@@ -0,0 +1,68 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/gcerrors"
+)
+
+func TestMove__Filesystem(t *testing.T) {
+	agent, dir := setupFilesystem(t)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "returned"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "returned", "RET.ach"), []byte("return"), 0600))
+
+	require.NoError(t, Move(agent, "returned/RET.ach", "archive/returned/RET.ach"))
+
+	bs, err := os.ReadFile(filepath.Join(dir, "archive", "returned", "RET.ach"))
+	require.NoError(t, err)
+	require.Equal(t, "return", string(bs))
+
+	_, err = os.Stat(filepath.Join(dir, "returned", "RET.ach"))
+	require.True(t, os.IsNotExist(err))
+
+	require.ErrorContains(t, Move(agent, "returned/RET.ach", "../archive/RET.ach"), "outside of")
+}
+
+func TestMove__S3(t *testing.T) {
+	agent := newTestS3Agent(t)
+	ctx := context.Background()
+
+	require.NoError(t, agent.bucket.WriteAll(ctx, "returned/RET.ach", []byte("return"), nil))
+	require.NoError(t, Move(agent, "returned/RET.ach", "archive/returned/RET.ach"))
+
+	bs, err := agent.bucket.ReadAll(ctx, "archive/returned/RET.ach")
+	require.NoError(t, err)
+	require.Equal(t, "return", string(bs))
+
+	_, err = agent.bucket.ReadAll(ctx, "returned/RET.ach")
+	require.Equal(t, gcerrors.NotFound, gcerrors.Code(err))
+
+	require.ErrorContains(t, agent.Move("returned/", "archive/"), "invalid path")
+}
+
+func TestMove__Unsupported(t *testing.T) {
+	agent := &HTTPSTransferAgent{}
+	require.ErrorContains(t, Move(agent, "inbound/a.ach", "archive/inbound/a.ach"), "can't move files")
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/moov-io/achgateway/internal/service"
+)
+
+// PathData fills templated UploadPaths, such as outbound/{{ yyyy }}/{{ mm }}/{{ dd }}/
+type PathData struct {
+	// RoutingNumber is the uploaded file's ImmediateDestination, otherwise the Paths.RoutingNumber
+	RoutingNumber string
+
+	Now time.Time
+}
+
+func pathFunctions(data PathData) template.FuncMap {
+	return template.FuncMap{
+		"yyyy": func() string { return data.Now.Format("2006") },
+		"yy":   func() string { return data.Now.Format("06") },
+		"mm":   func() string { return data.Now.Format("01") },
+		"dd":   func() string { return data.Now.Format("02") },
+		"date": func(pattern string) string {
+			return data.Now.Format(pattern)
+		},
+		"routingNumber": func() string {
+			// Only keep letters and digits so file headers can't escape the configured path
+			return strings.Map(func(r rune) rune {
+				if unicode.IsLetter(r) || unicode.IsDigit(r) {
+					return r
+				}
+				return -1
+			}, data.RoutingNumber)
+		},
+		"env":   filenameFunctions["env"],
+		"lower": filenameFunctions["lower"],
+		"upper": filenameFunctions["upper"],
+	}
+}
+
+// RenderPath evaluates a templated remote path. Paths without a template are returned as-is.
+func RenderPath(raw string, data PathData) (string, error) {
+	if !templatedPath(raw) {
+		return raw, nil
+	}
+	t, err := template.New("path").Funcs(pathFunctions(data)).Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// ValidatePaths checks each templated path renders
+func ValidatePaths(paths service.UploadPaths) error {
+	data := PathData{RoutingNumber: "123456789", Now: time.Now()}
+	for _, p := range doctorPaths(paths) {
+		if _, err := RenderPath(p.path, data); err != nil {
+			return fmt.Errorf("%s path: %v", p.name, err)
+		}
+	}
+	return nil
+}
+
+func templatedPath(raw string) bool {
+	return strings.Contains(raw, "{{")
+}
+
+// RemotePath returns where an agent configured with cfg writes the file uploaded as f, such
+// as the object key for S3 agents.
+func RemotePath(cfg *service.UploadAgent, f File) string {
+	if cfg == nil {
+		return ""
+	}
+	outbound := currentPaths(cfg.Paths, f.RoutingNumber).Outbound
+	if cfg.S3 != nil {
+		return s3Key(outbound, filepath.Base(f.Filename))
+	}
+	return path.Join(outbound, filepath.Base(f.Filename))
+}
+
+// currentPaths renders templated paths for a transfer happening now. Paths are checked by
+// ValidatePaths when agents are created, so a path which fails to render is left as-is.
+func currentPaths(paths service.UploadPaths, routingNumber string) service.UploadPaths {
+	data := PathData{RoutingNumber: routingNumber, Now: time.Now()}
+	if data.RoutingNumber == "" {
+		data.RoutingNumber = paths.RoutingNumber
+	}
+	render := func(raw string) string {
+		if out, err := RenderPath(raw, data); err == nil {
+			return out
+		}
+		return raw
+	}
+	paths.Inbound = render(paths.Inbound)
+	paths.Outbound = render(paths.Outbound)
+	paths.Reconciliation = render(paths.Reconciliation)
+	paths.Return = render(paths.Return)
+	return paths
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package upload
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderPath(t *testing.T) {
+	data := PathData{
+		RoutingNumber: "123456789",
+		Now:           time.Date(2022, time.June, 1, 10, 30, 0, 0, time.UTC),
+	}
+
+	path, err := RenderPath("outbound", data)
+	require.NoError(t, err)
+	require.Equal(t, "outbound", path)
+
+	path, err = RenderPath("outbound/{{ yyyy }}/{{ mm }}/{{ dd }}/", data)
+	require.NoError(t, err)
+	require.Equal(t, "outbound/2022/06/01/", path)
+
+	path, err = RenderPath(`inbound/{{ routingNumber }}/{{ date "20060102" }}`, data)
+	require.NoError(t, err)
+	require.Equal(t, "inbound/123456789/20220601", path)
+
+	path, err = RenderPath("{{ yy }}{{ mm }}/{{ .RoutingNumber }}", data)
+	require.NoError(t, err)
+	require.Equal(t, "2206/123456789", path)
+
+	// Routing numbers can't add path segments
+	data.RoutingNumber = " ../../etc"
+	path, err = RenderPath("inbound/{{ routingNumber }}", data)
+	require.NoError(t, err)
+	require.Equal(t, "inbound/etc", path)
+
+	_, err = RenderPath("outbound/{{ yyyy ", data)
+	require.Error(t, err)
+
+	_, err = RenderPath("outbound/{{ hour }}", data)
+	require.ErrorContains(t, err, `function "hour" not defined`)
+}
+
+func TestValidatePaths(t *testing.T) {
+	require.NoError(t, ValidatePaths(service.UploadPaths{
+		Inbound:  "inbound/{{ routingNumber }}",
+		Outbound: "outbound/{{ yyyy }}/{{ mm }}/{{ dd }}",
+		Return:   "returned",
+	}))
+
+	err := ValidatePaths(service.UploadPaths{
+		Reconciliation: "reconciliation/{{ month }}",
+	})
+	require.ErrorContains(t, err, "reconciliation path")
+}
+
+func TestCurrentPaths(t *testing.T) {
+	paths := service.UploadPaths{
+		Inbound:       "inbound/{{ routingNumber }}",
+		Outbound:      "outbound/{{ routingNumber }}",
+		Return:        "returned",
+		RoutingNumber: "987654320",
+	}
+	current := currentPaths(paths, "")
+	require.Equal(t, "inbound/987654320", current.Inbound)
+	require.Equal(t, "outbound/987654320", current.Outbound)
+	require.Equal(t, "returned", current.Return)
+	require.Equal(t, "", current.Reconciliation)
+
+	current = currentPaths(paths, "123456789")
+	require.Equal(t, "outbound/123456789", current.Outbound)
+}
+
+func TestS3Agent__TemplatedPaths(t *testing.T) {
+	agent := newTestS3Agent(t)
+	agent.cfg.Paths.Outbound = "outbound/{{ routingNumber }}/{{ yyyy }}"
+
+	err := agent.UploadFile(File{
+		Filename:      "20220601-1200.ach",
+		Contents:      io.NopCloser(strings.NewReader("nacha")),
+		RoutingNumber: "123456789",
+	})
+	require.NoError(t, err)
+
+	key := "outbound/123456789/" + time.Now().Format("2006") + "/20220601-1200.ach"
+	exists, err := agent.bucket.Exists(context.Background(), key)
+	require.NoError(t, err)
+	require.True(t, exists)
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package upload
+
+import (
+	"io"
+	"time"
+
+	"github.com/moov-io/base/log"
+)
+
+// progressInterval is how often a progressReader logs while a file is uploaded
+var progressInterval = 30 * time.Second
+
+// progressReader counts the bytes read from r and periodically logs how much of a
+// file has been uploaded so long transfers can be followed.
+type progressReader struct {
+	r        io.Reader
+	logger   log.Logger
+	filename string
+
+	read    int64
+	started time.Time
+	logged  time.Time
+}
+
+func newProgressReader(logger log.Logger, filename string, r io.Reader) *progressReader {
+	now := time.Now()
+	return &progressReader{
+		r:        r,
+		logger:   logger,
+		filename: filename,
+		started:  now,
+		logged:   now,
+	}
+}
+
+func (p *progressReader) Read(data []byte) (int, error) {
+	n, err := p.r.Read(data)
+	p.read += int64(n)
+
+	if now := time.Now(); now.Sub(p.logged) >= progressInterval {
+		p.logged = now
+		p.logger.Info().With(log.Fields{
+			"filename": log.String(p.filename),
+			"bytes":    log.Int64(p.read),
+		}).Logf("uploading %s: %d bytes sent at %.2f MB/s", p.filename, p.read, p.throughput()/1e6)
+	}
+	return n, err
+}
+
+// throughput returns the average bytes per second read so far
+func (p *progressReader) throughput() float64 {
+	elapsed := time.Since(p.started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.read) / elapsed
+}
@@ -0,0 +1,34 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package upload
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressReader(t *testing.T) {
+	interval := progressInterval
+	progressInterval = 0
+	t.Cleanup(func() { progressInterval = interval })
+
+	p := newProgressReader(log.NewNopLogger(), "upload.ach", strings.NewReader("test data"))
+	p.started = time.Now().Add(-1 * time.Second)
+
+	bs, err := io.ReadAll(p)
+	require.NoError(t, err)
+	require.Equal(t, "test data", string(bs))
+	require.Equal(t, int64(9), p.read)
+
+	throughput := p.throughput()
+	require.Greater(t, throughput, 0.0)
+	require.LessOrEqual(t, throughput, 9.0)
+}
@@ -0,0 +1,119 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+
+	"golang.org/x/net/proxy"
+)
+
+// dialFunc opens a TCP connection to addr
+type dialFunc func(network, addr string) (net.Conn, error)
+
+// proxyDialer returns a dialFunc which connects through cfg, or nil when there's no proxy
+// and connections are made directly.
+func proxyDialer(cfg *service.Proxy, timeout time.Duration) (dialFunc, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	u, err := cfg.URL()
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %v", err)
+	}
+	forward := &net.Dialer{Timeout: timeout}
+
+	switch u.Scheme {
+	case "socks5":
+		var auth *proxy.Auth
+		if cfg.Username != "" {
+			auth = &proxy.Auth{User: cfg.Username, Password: cfg.Password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", u.Host, auth, forward)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %v", err)
+		}
+		return func(network, addr string) (net.Conn, error) {
+			conn, err := dialer.Dial(network, addr)
+			if err != nil {
+				return nil, fmt.Errorf("proxy: socks5 %s: %v", u.Host, err)
+			}
+			return conn, nil
+		}, nil
+
+	case "http":
+		return func(network, addr string) (net.Conn, error) {
+			return httpConnect(forward, u.Host, cfg, addr, timeout)
+		}, nil
+	}
+	return nil, fmt.Errorf("proxy: unsupported scheme %q", u.Scheme)
+}
+
+// httpConnect opens a tunnel to addr with an HTTP CONNECT request to the proxy at host
+func httpConnect(forward *net.Dialer, host string, cfg *service.Proxy, addr string, timeout time.Duration) (net.Conn, error) {
+	conn, err := forward.Dial("tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if cfg.Username != "" {
+		creds := base64.StdEncoding.EncodeToString([]byte(cfg.Username + ":" + cfg.Password))
+		req += "Proxy-Authorization: Basic " + creds + "\r\n"
+	}
+	if _, err := conn.Write([]byte(req + "\r\n")); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy: CONNECT %s: %v", addr, err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy: CONNECT %s: %v", addr, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy: CONNECT %s: %s", addr, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+
+	// The server may have already written to the tunnel, which br has read
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
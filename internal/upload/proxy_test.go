@@ -0,0 +1,358 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+	"goftp.io/server"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestProxyDialer(t *testing.T) {
+	dial, err := proxyDialer(nil, time.Second)
+	require.NoError(t, err)
+	require.Nil(t, dial)
+
+	_, err = proxyDialer(&service.Proxy{Address: "ftp://proxy:21"}, time.Second)
+	require.ErrorContains(t, err, `unsupported scheme "ftp"`)
+
+	// Echo back whatever is sent
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	echo := func(t *testing.T, cfg *service.Proxy) error {
+		t.Helper()
+
+		dial, err := proxyDialer(cfg, time.Second)
+		require.NoError(t, err)
+
+		conn, err := dial("tcp", listener.Addr().String())
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("hello"))
+		require.NoError(t, err)
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(conn, buf)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(buf))
+		return nil
+	}
+
+	for _, scheme := range []string{"socks5", "http"} {
+		t.Run(scheme, func(t *testing.T) {
+			var tunnels int32
+			addr := testProxy(t, scheme, "moov", "secret", &tunnels)
+
+			err := echo(t, &service.Proxy{Address: scheme + "://" + addr, Username: "moov", Password: "secret"})
+			require.NoError(t, err)
+			require.Equal(t, int32(1), atomic.LoadInt32(&tunnels))
+
+			err = echo(t, &service.Proxy{Address: scheme + "://" + addr, Username: "moov", Password: "wrong"})
+			require.ErrorContains(t, err, "proxy:")
+			require.Equal(t, int32(1), atomic.LoadInt32(&tunnels))
+		})
+	}
+}
+
+func TestSFTP__Proxy(t *testing.T) {
+	conf := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	conf.AddHostKey(certTestSigner(t))
+	hostname := sftpTestServer(t, conf, nil)
+
+	for _, scheme := range []string{"socks5", "http"} {
+		t.Run(scheme, func(t *testing.T) {
+			var tunnels int32
+			addr := testProxy(t, scheme, "", "", &tunnels)
+
+			dir := t.TempDir()
+			agent, err := newSFTPTransferAgent(log.NewNopLogger(), &service.UploadAgent{
+				Paths: service.UploadPaths{
+					Inbound:  dir,
+					Outbound: dir,
+				},
+				SFTP: &service.SFTP{
+					Hostname: hostname,
+					Username: "achgateway",
+					Password: "password",
+					Proxy: &service.Proxy{
+						Address: scheme + "://" + addr,
+					},
+				},
+			}, nil)
+			require.NoError(t, err)
+			defer agent.Close()
+
+			err = agent.UploadFile(File{
+				Filename: "upload.ach",
+				Contents: io.NopCloser(strings.NewReader("contents")),
+			})
+			require.NoError(t, err)
+
+			files, err := agent.GetInboundFiles()
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+			require.Equal(t, int32(1), atomic.LoadInt32(&tunnels))
+		})
+	}
+}
+
+func TestFTP__Proxy(t *testing.T) {
+	svc, err := createTestFTPServer(t)
+	require.NoError(t, err)
+	defer svc.Shutdown()
+
+	var tunnels int32
+	addr := testProxy(t, "socks5", "", "", &tunnels)
+
+	agent, err := newFTPTransferAgent(log.NewNopLogger(), &service.UploadAgent{
+		FTP: &service.FTP{
+			Hostname: fmt.Sprintf("%s:%d", svc.Hostname, svc.Port),
+			Username: "moov",
+			Password: "password",
+			Proxy: &service.Proxy{
+				Address: "socks5://" + addr,
+			},
+		},
+		Paths: service.UploadPaths{
+			Inbound:  "inbound",
+			Outbound: "outbound",
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	files, err := agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	// The control connection and a data connection for each listing and download
+	require.Greater(t, atomic.LoadInt32(&tunnels), int32(2))
+
+	err = agent.UploadFile(File{
+		Filename: "proxied.ach",
+		Contents: io.NopCloser(strings.NewReader("contents")),
+	})
+	require.NoError(t, err)
+	os.Remove(filepath.Join(rootFTPPath, "outbound", "proxied.ach"))
+}
+
+func TestFTP__ProxyExplicitTLS(t *testing.T) {
+	certFile, keyFile := writeFTPSCertificate(t)
+	svc, err := createTestFTPServer(t, func(opts *server.ServerOpts) {
+		opts.TLS = true
+		opts.ExplicitFTPS = true
+		opts.ForceTLS = true
+		opts.CertFile = certFile
+		opts.KeyFile = keyFile
+	})
+	require.NoError(t, err)
+	defer svc.Shutdown()
+
+	var tunnels int32
+	addr := testProxy(t, "socks5", "", "", &tunnels)
+
+	agent, err := newFTPTransferAgent(log.NewNopLogger(), &service.UploadAgent{
+		FTP: &service.FTP{
+			Hostname:   fmt.Sprintf("%s:%d", svc.Hostname, svc.Port),
+			Username:   "moov",
+			Password:   "password",
+			CAFilepath: certFile,
+			Proxy: &service.Proxy{
+				Address: "socks5://" + addr,
+			},
+			TLS: &service.FTPTLS{
+				Mode: service.FTPExplicitTLS,
+			},
+		},
+		Paths: service.UploadPaths{
+			Inbound: "inbound",
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	// The control connection is upgraded after AUTH TLS and data connections start with TLS
+	files, err := agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+}
+
+// testProxy runs a SOCKS5 or HTTP CONNECT proxy which requires username and password when set,
+// counting the tunnels it opens.
+func testProxy(t *testing.T, scheme, username, password string, tunnels *int32) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	handshake := socks5Handshake
+	if scheme == "http" {
+		handshake = httpConnectHandshake
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+
+				br := bufio.NewReader(conn)
+				upstream, err := handshake(br, conn, username, password)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				atomic.AddInt32(tunnels, 1)
+
+				// FTP data connections end when the client closes its side
+				go func() {
+					io.Copy(upstream, br)
+					upstream.Close()
+				}()
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func httpConnectHandshake(br *bufio.Reader, conn net.Conn, username, password string) (net.Conn, error) {
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, err
+	}
+	if username != "" {
+		want := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+		if req.Header.Get("Proxy-Authorization") != want {
+			conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+			return nil, fmt.Errorf("bad credentials")
+		}
+	}
+	upstream, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+		return nil, err
+	}
+	conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	return upstream, nil
+}
+
+func socks5Handshake(br *bufio.Reader, conn net.Conn, username, password string) (net.Conn, error) {
+	// Greeting: version, methods
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, err
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(br, methods); err != nil {
+		return nil, err
+	}
+	if username == "" {
+		conn.Write([]byte{5, 0})
+	} else {
+		conn.Write([]byte{5, 2})
+
+		// Username/password auth (RFC 1929)
+		readField := func() string {
+			n, _ := br.ReadByte()
+			field := make([]byte, n)
+			io.ReadFull(br, field)
+			return string(field)
+		}
+		br.ReadByte()
+		user, pass := readField(), readField()
+		if user != username || pass != password {
+			conn.Write([]byte{1, 1})
+			return nil, fmt.Errorf("bad credentials")
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	// Request: version, command, reserved, address type
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(br, request); err != nil {
+		return nil, err
+	}
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(br, ip)
+		host = net.IP(ip).String()
+	case 3:
+		n, _ := br.ReadByte()
+		name := make([]byte, n)
+		io.ReadFull(br, name)
+		host = string(name)
+	case 4:
+		ip := make([]byte, 16)
+		io.ReadFull(br, ip)
+		host = net.IP(ip).String()
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(br, port); err != nil {
+		return nil, err
+	}
+	upstream, err := net.Dial("tcp", net.JoinHostPort(host, fmt.Sprintf("%d", binary.BigEndian.Uint16(port))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return nil, err
+	}
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	return upstream, nil
+}
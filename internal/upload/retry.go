@@ -90,6 +90,14 @@ func (rt *RetryAgent) GetReturnFiles() ([]File, error) {
 	return rt.retryFiles(rt.underlying.GetReturnFiles)
 }
 
+func (rt *RetryAgent) SkippedFiles() []SkippedFile {
+	return SkippedFiles(rt.underlying)
+}
+
+func (rt *RetryAgent) CommitListings() {
+	CommitListings(rt.underlying)
+}
+
 func (rt *RetryAgent) UploadFile(f File) error {
 	backoff, err := rt.newBackoff()
 	if err != nil {
@@ -112,6 +120,35 @@ func (rt *RetryAgent) Delete(path string) error {
 	})
 }
 
+func (rt *RetryAgent) Move(oldpath, newpath string) error {
+	backoff, err := rt.newBackoff()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	return retry.Do(ctx, backoff, func(ctx context.Context) error {
+		return isRetryableError(Move(rt.underlying, oldpath, newpath))
+	})
+}
+
+func (rt *RetryAgent) Exists(path string) (bool, error) {
+	backoff, err := rt.newBackoff()
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	ctx := context.Background()
+	err = retry.Do(ctx, backoff, func(ctx context.Context) error {
+		ok, err := Exists(rt.underlying, path)
+		if err := isRetryableError(err); err != nil {
+			return err
+		}
+		exists = ok
+		return nil
+	})
+	return exists, err
+}
+
 // Non-Network calls, so pass-through
 func (rt *RetryAgent) InboundPath() string {
 	return rt.underlying.InboundPath()
@@ -0,0 +1,318 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/moov-io/base/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/s3blob"
+	"gocloud.dev/gcerrors"
+)
+
+var (
+	s3AgentUp = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Name: "s3_agent_up",
+		Help: "Status of S3 agent bucket access",
+	}, []string{"bucket"})
+)
+
+// S3TransferAgent is an implementation of Agent which reads and writes objects in an
+// S3 compatible bucket. Each of the configured paths is used as a key prefix.
+type S3TransferAgent struct {
+	bucket *blob.Bucket
+	cfg    service.UploadAgent
+	logger log.Logger
+
+	// skipped are the objects passed over by the last readFiles call
+	mu      sync.Mutex
+	skipped []SkippedFile
+}
+
+func newS3TransferAgent(logger log.Logger, cfg *service.UploadAgent) (*S3TransferAgent, error) {
+	if cfg == nil || cfg.S3 == nil {
+		return nil, errors.New("nil S3 config")
+	}
+	if host := cfg.S3.EndpointHost(); host != "" {
+		if err := rejectOutboundIPRange(cfg.SplitAllowedIPs(), host); err != nil {
+			return nil, fmt.Errorf("s3: %s is not whitelisted: %v", host, err)
+		}
+	}
+	bucket, err := openS3Bucket(cfg.S3)
+	if err != nil {
+		return nil, fmt.Errorf("s3: opening bucket %s: %v", cfg.S3.Bucket, err)
+	}
+	return &S3TransferAgent{
+		bucket: bucket,
+		cfg:    *cfg,
+		logger: logger,
+	}, nil
+}
+
+func openS3Bucket(cfg *service.S3) (*blob.Bucket, error) {
+	conf := aws.NewConfig().WithRegion(cfg.Region).WithS3ForcePathStyle(cfg.ForcePathStyle)
+	if cfg.Endpoint != "" {
+		conf = conf.WithEndpoint(cfg.Endpoint)
+	}
+	if cfg.AccessKeyID != "" {
+		conf = conf.WithCredentials(credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.SecretAccessKey, ""))
+	}
+	sess, err := session.NewSession(conf)
+	if err != nil {
+		return nil, err
+	}
+	return s3blob.OpenBucket(context.Background(), sess, cfg.Bucket, nil)
+}
+
+func (agent *S3TransferAgent) ID() string {
+	return agent.cfg.ID
+}
+
+func (agent *S3TransferAgent) Ping() error {
+	if agent == nil || agent.bucket == nil {
+		return errors.New("nil S3TransferAgent")
+	}
+	ok, err := agent.bucket.IsAccessible(context.Background())
+	if err == nil && !ok {
+		err = fmt.Errorf("bucket %s is not accessible", agent.cfg.S3.Bucket)
+	}
+	if err != nil {
+		s3AgentUp.With("bucket", agent.cfg.S3.Bucket).Set(0)
+	} else {
+		s3AgentUp.With("bucket", agent.cfg.S3.Bucket).Set(1)
+	}
+	return err
+}
+
+func (agent *S3TransferAgent) Close() error {
+	if agent == nil || agent.bucket == nil {
+		return nil
+	}
+	return agent.bucket.Close()
+}
+
+func (agent *S3TransferAgent) InboundPath() string {
+	return currentPaths(agent.cfg.Paths, "").Inbound
+}
+
+func (agent *S3TransferAgent) OutboundPath() string {
+	return currentPaths(agent.cfg.Paths, "").Outbound
+}
+
+func (agent *S3TransferAgent) ReconciliationPath() string {
+	return currentPaths(agent.cfg.Paths, "").Reconciliation
+}
+
+func (agent *S3TransferAgent) ReturnPath() string {
+	return currentPaths(agent.cfg.Paths, "").Return
+}
+
+func (agent *S3TransferAgent) Hostname() string {
+	if agent == nil {
+		return ""
+	}
+	return agent.cfg.S3.Hostname()
+}
+
+// s3Key returns the object key for name under prefix. Keys don't start with a slash.
+func s3Key(prefix, name string) string {
+	return strings.TrimPrefix(path.Join(prefix, name), "/")
+}
+
+// s3Prefix returns the prefix listing objects "in" dir
+func s3Prefix(dir string) string {
+	dir = strings.Trim(dir, "/")
+	if dir == "" || dir == "." {
+		return ""
+	}
+	return dir + "/"
+}
+
+func (agent *S3TransferAgent) Delete(path string) error {
+	if path == "" || strings.HasSuffix(path, "/") {
+		return fmt.Errorf("S3TransferAgent: invalid path %v", path)
+	}
+	err := agent.bucket.Delete(context.Background(), s3Key("", path))
+	if err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+		return err
+	}
+	return nil
+}
+
+// Move copies oldpath to newpath and deletes oldpath, since objects can't be renamed
+func (agent *S3TransferAgent) Move(oldpath, newpath string) error {
+	if oldpath == "" || strings.HasSuffix(oldpath, "/") {
+		return fmt.Errorf("S3TransferAgent: invalid path %v", oldpath)
+	}
+	ctx := context.Background()
+	if err := agent.bucket.Copy(ctx, s3Key("", newpath), s3Key("", oldpath), nil); err != nil {
+		return err
+	}
+	return agent.Delete(oldpath)
+}
+
+func (agent *S3TransferAgent) Exists(path string) (bool, error) {
+	if path == "" || strings.HasSuffix(path, "/") {
+		return false, fmt.Errorf("S3TransferAgent: invalid path %v", path)
+	}
+	return agent.bucket.Exists(context.Background(), s3Key("", path))
+}
+
+// UploadFile saves the content of File as an object under the OutboundPath prefix
+//
+// The File's contents will always be closed
+func (agent *S3TransferAgent) UploadFile(f File) error {
+	defer f.Close()
+
+	// Take the base of f.Filename and our (out of band) OutboundPath to avoid accepting a write like '../../../../etc/passwd'.
+	key := s3Key(currentPaths(agent.cfg.Paths, f.RoutingNumber).Outbound, filepath.Base(f.Filename))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := agent.bucket.NewWriter(ctx, key, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, f.Contents); err != nil {
+		cancel() // abort the upload
+		w.Close()
+		return fmt.Errorf("uploading %s: %v", key, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("uploading %s: %v", key, err)
+	}
+	return nil
+}
+
+func (agent *S3TransferAgent) GetInboundFiles() ([]File, error) {
+	return agent.readFiles(agent.InboundPath(), agent.cfg.Paths.InboundFilter)
+}
+
+func (agent *S3TransferAgent) GetReconciliationFiles() ([]File, error) {
+	return agent.readFiles(agent.ReconciliationPath(), agent.cfg.Paths.ReconciliationFilter)
+}
+
+func (agent *S3TransferAgent) GetReturnFiles() ([]File, error) {
+	return agent.readFiles(agent.ReturnPath(), agent.cfg.Paths.ReturnFilter)
+}
+
+// readFiles returns the objects directly under dir, like files in a directory
+func (agent *S3TransferAgent) readFiles(dir string, filter *service.FilenameFilter) ([]File, error) {
+	matches, err := filter.Matcher()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	prefix := s3Prefix(dir)
+
+	var files []File
+	var skipped []SkippedFile
+	defer func() {
+		agent.mu.Lock()
+		agent.skipped = skipped
+		agent.mu.Unlock()
+	}()
+
+	spool := newSpooler(agent.cfg.Downloads)
+
+	// Common prefixes are listed up to Paths.Depth levels and their objects named relative to prefix
+	var list func(under string, level int) error
+	list = func(under string, level int) error {
+		iter := agent.bucket.List(&blob.ListOptions{
+			Prefix:    under,
+			Delimiter: "/",
+		})
+		for {
+			obj, err := iter.Next(ctx)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("listing %s: %v", under, err)
+			}
+			if obj.IsDir {
+				if level < agent.cfg.Paths.Depth {
+					if err := list(obj.Key, level+1); err != nil {
+						return err
+					}
+					continue
+				}
+				skipped = append(skipped, SkippedFile{
+					Path:   strings.TrimSuffix(obj.Key, "/"),
+					Reason: SkipDirectory,
+				})
+				continue
+			}
+			if !matches(strings.TrimPrefix(obj.Key, under)) {
+				skipped = append(skipped, SkippedFile{
+					Path:   obj.Key,
+					Reason: SkipFiltered,
+				})
+				continue
+			}
+
+			contents, err := agent.readObject(ctx, spool, obj.Key)
+			if err != nil {
+				return fmt.Errorf("problem reading %s: %v", obj.Key, err)
+			}
+			files = append(files, File{
+				Filename: strings.TrimPrefix(obj.Key, prefix),
+				Contents: contents,
+			})
+		}
+	}
+	if err := list(prefix, 0); err != nil {
+		closeFiles(files)
+		return nil, err
+	}
+	return files, nil
+}
+
+func (agent *S3TransferAgent) SkippedFiles() []SkippedFile {
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	return agent.skipped
+}
+
+func (agent *S3TransferAgent) readObject(ctx context.Context, spool *spooler, key string) (io.ReadCloser, error) {
+	r, err := agent.bucket.NewReader(ctx, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	contents, _, err := spool.spool(r)
+	return contents, err
+}
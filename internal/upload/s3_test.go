@@ -0,0 +1,181 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob/memblob"
+)
+
+func newTestS3Agent(t *testing.T) *S3TransferAgent {
+	t.Helper()
+
+	agent := &S3TransferAgent{
+		bucket: memblob.OpenBucket(nil),
+		cfg: service.UploadAgent{
+			ID: "s3",
+			S3: &service.S3{
+				Bucket: "ach-drop",
+				Region: "us-east-1",
+			},
+			Paths: service.UploadPaths{
+				Inbound:        "/inbound/",
+				Outbound:       "outbound",
+				Reconciliation: "reconciliation/",
+				Return:         "returned",
+			},
+		},
+		logger: log.NewNopLogger(),
+	}
+	t.Cleanup(func() { agent.Close() })
+	return agent
+}
+
+func TestS3Agent(t *testing.T) {
+	agent := newTestS3Agent(t)
+	require.Equal(t, "s3", agent.ID())
+	require.Equal(t, "ach-drop", agent.Hostname())
+	require.NoError(t, agent.Ping())
+
+	// Uploads are written under the outbound prefix
+	err := agent.UploadFile(File{
+		Filename: "../../20220601-1200.ach",
+		Contents: io.NopCloser(strings.NewReader("nacha")),
+	})
+	require.NoError(t, err)
+
+	bs, err := agent.bucket.ReadAll(context.Background(), "outbound/20220601-1200.ach")
+	require.NoError(t, err)
+	require.Equal(t, "nacha", string(bs))
+
+	// Only objects directly under a prefix are read
+	ctx := context.Background()
+	require.NoError(t, agent.bucket.WriteAll(ctx, "inbound/forward.ach", []byte("forward"), nil))
+	require.NoError(t, agent.bucket.WriteAll(ctx, "inbound/archive/old.ach", []byte("old"), nil))
+	require.NoError(t, agent.bucket.WriteAll(ctx, "inbound/empty.ach", nil, nil))
+	require.NoError(t, agent.bucket.WriteAll(ctx, "returned/ret.ach", []byte("return"), nil))
+
+	files, err := agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Equal(t, "empty.ach", files[0].Filename)
+	require.Equal(t, "forward.ach", files[1].Filename)
+	bs, err = io.ReadAll(files[1].Contents)
+	require.NoError(t, err)
+	require.Equal(t, "forward", string(bs))
+	files[1].Close()
+
+	// Nested prefixes are reported as skipped directories
+	require.Equal(t, []SkippedFile{{Path: "inbound/archive", Reason: SkipDirectory}}, SkippedFiles(agent))
+
+	files, err = agent.GetReturnFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "ret.ach", files[0].Filename)
+	require.Empty(t, SkippedFiles(agent))
+
+	files, err = agent.GetReconciliationFiles()
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	// Delete
+	require.NoError(t, agent.Delete("inbound/forward.ach"))
+	require.NoError(t, agent.Delete("/returned/ret.ach"))
+	require.NoError(t, agent.Delete("returned/missing.ach"))
+	require.ErrorContains(t, agent.Delete("inbound/"), "invalid path")
+
+	files, err = agent.GetInboundFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+}
+
+func TestS3Agent__New(t *testing.T) {
+	cfg := service.UploadAgents{
+		Agents: []service.UploadAgent{
+			{
+				ID: "s3-new",
+				S3: &service.S3{
+					Bucket:          "ach-drop",
+					Region:          "us-east-1",
+					Endpoint:        "http://127.0.0.1:9000",
+					ForcePathStyle:  true,
+					AccessKeyID:     "minio",
+					SecretAccessKey: "minio123",
+				},
+			},
+		},
+	}
+	agent, err := New(log.NewNopLogger(), cfg, "s3-new")
+	require.NoError(t, err)
+	require.IsType(t, &S3TransferAgent{}, agent)
+	require.Equal(t, "ach-drop", agent.Hostname())
+
+	cfg.Agents[0].ID = "s3-blocked"
+	cfg.Agents[0].AllowedIPs = "10.0.0.0/8"
+	_, err = New(log.NewNopLogger(), cfg, "s3-blocked")
+	require.ErrorContains(t, err, "is not whitelisted")
+}
+
+func TestS3__keys(t *testing.T) {
+	require.Equal(t, "outbound/a.ach", s3Key("/outbound/", "a.ach"))
+	require.Equal(t, "a.ach", s3Key("", "a.ach"))
+
+	require.Equal(t, "inbound/", s3Prefix("/inbound/"))
+	require.Equal(t, "", s3Prefix("/"))
+	require.Equal(t, "", s3Prefix(""))
+}
+
+func TestDiagnose__S3(t *testing.T) {
+	agent := newTestS3Agent(t)
+
+	diag := &Diagnosis{AgentID: "s3"}
+	diagnoseS3Agent(agent, DoctorOptions{TransferSize: 4096}, diag, time.Now())
+	require.False(t, diag.Failed())
+	require.Equal(t, CheckOK, findCheck(t, diag, "connect").Status)
+	require.Equal(t, CheckOK, findCheck(t, diag, "inbound path").Status)
+	require.Equal(t, CheckOK, findCheck(t, diag, "transfer").Status)
+
+	// The transfer's object is removed
+	files, err := agent.readFiles("outbound", nil)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func TestSkippedFiles(t *testing.T) {
+	require.Nil(t, SkippedFiles(&MockAgent{}))
+
+	agent := newTestS3Agent(t)
+	require.NoError(t, agent.bucket.WriteAll(context.Background(), "returned/2022/ret.ach", []byte("return"), nil))
+
+	retr, err := newRetryAgent(log.NewNopLogger(), agent, &service.UploadRetry{Interval: time.Millisecond, MaxRetries: 1})
+	require.NoError(t, err)
+
+	files, err := retr.GetReturnFiles()
+	require.NoError(t, err)
+	require.Empty(t, files)
+	require.Equal(t, []SkippedFile{{Path: "returned/2022", Reason: SkipDirectory}}, SkippedFiles(retr))
+}
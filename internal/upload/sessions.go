@@ -0,0 +1,136 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"sync"
+
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// sharedSessions are the connections reused by agents with identical FTP or SFTP configs
+// when Upload.ShareConnections is enabled, as shards often use the same bank host with
+// different paths.
+var sharedSessions = &sessionPool{
+	sessions: make(map[string]*sharedSession),
+}
+
+type sessionPool struct {
+	mu       sync.Mutex
+	sessions map[string]*sharedSession
+}
+
+// sharedSession is one authenticated connection used by several agents.
+//
+// FTP agents hold mu for each operation since the control connection (and its working
+// directory) can only be used by one caller at a time. SFTP agents take connections from a
+// shared pool, only holding mu to find it, and lock the remote path when writing to it.
+type sharedSession struct {
+	pool *sessionPool
+	key  string
+	refs int
+
+	mu       sync.Mutex
+	ftpConn  *ftp.ServerConn
+	sftpPool *sftpPool
+
+	pathsMu sync.Mutex
+	paths   map[string]*sync.Mutex
+}
+
+func (p *sessionPool) acquire(key string) *sharedSession {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sess, exists := p.sessions[key]
+	if !exists {
+		sess = &sharedSession{
+			pool:  p,
+			key:   key,
+			paths: make(map[string]*sync.Mutex),
+		}
+		p.sessions[key] = sess
+	}
+	sess.refs++
+	return sess
+}
+
+// release drops an agent's reference to sess and reports if it was the last one,
+// in which case the caller closes the connection.
+func (p *sessionPool) release(sess *sharedSession) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sess.refs--
+	if sess.refs > 0 {
+		return false
+	}
+	delete(p.sessions, sess.key)
+	return true
+}
+
+// agents returns how many agents are using the session for key
+func (p *sessionPool) agents(key string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if sess, exists := p.sessions[key]; exists {
+		return sess.refs
+	}
+	return 0
+}
+
+// lockPath serializes operations on one remote directory and returns the unlock func
+func (sess *sharedSession) lockPath(dir string) func() {
+	dir = path.Clean("/" + dir)
+
+	sess.pathsMu.Lock()
+	mu, exists := sess.paths[dir]
+	if !exists {
+		mu = &sync.Mutex{}
+		sess.paths[dir] = mu
+	}
+	sess.pathsMu.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// ftpSessionKey identifies agents which can share a connection, which requires every
+// connection setting to match.
+func ftpSessionKey(cfg *service.FTP) string {
+	type plain service.FTP // without MarshalJSON's masking
+	return sessionKey("ftp", plain(*cfg))
+}
+
+func sftpSessionKey(cfg *service.SFTP) string {
+	type plain service.SFTP
+	return sessionKey("sftp", plain(*cfg))
+}
+
+func sessionKey(protocol string, cfg interface{}) string {
+	bs, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(bs)
+	return protocol + ":" + hex.EncodeToString(sum[:])
+}
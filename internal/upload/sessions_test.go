@@ -0,0 +1,123 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+	"goftp.io/server"
+)
+
+func TestSessionPool(t *testing.T) {
+	pool := &sessionPool{sessions: make(map[string]*sharedSession)}
+
+	first := pool.acquire("ftp:abc")
+	second := pool.acquire("ftp:abc")
+	other := pool.acquire("ftp:def")
+	require.Same(t, first, second)
+	require.NotSame(t, first, other)
+	require.Equal(t, 2, pool.agents("ftp:abc"))
+
+	require.False(t, pool.release(first))
+	require.True(t, pool.release(second))
+	require.Equal(t, 0, pool.agents("ftp:abc"))
+	require.Equal(t, 1, pool.agents("ftp:def"))
+}
+
+func TestSharedSession__lockPath(t *testing.T) {
+	pool := &sessionPool{sessions: make(map[string]*sharedSession)}
+	sess := pool.acquire("sftp:abc")
+
+	unlock := sess.lockPath("inbound/")
+	require.Len(t, sess.paths, 1)
+
+	// Other paths aren't blocked
+	sess.lockPath("/outbound")()
+
+	// The same path is a single lock
+	locked := make(chan struct{})
+	go func() {
+		sess.lockPath("/inbound")()
+		close(locked)
+	}()
+	unlock()
+	<-locked
+	require.Len(t, sess.paths, 2)
+}
+
+func TestSessionKey(t *testing.T) {
+	cfg := &service.FTP{Hostname: "ftp.bank.com:21", Username: "moov", Password: "secret"}
+	require.Equal(t, ftpSessionKey(cfg), ftpSessionKey(&service.FTP{Hostname: "ftp.bank.com:21", Username: "moov", Password: "secret"}))
+	require.NotEqual(t, ftpSessionKey(cfg), ftpSessionKey(&service.FTP{Hostname: "ftp.bank.com:21", Username: "moov", Password: "other"}))
+
+	sftp := &service.SFTP{Hostname: "ftp.bank.com:21", Username: "moov", Password: "secret"}
+	require.NotEqual(t, ftpSessionKey(cfg), sftpSessionKey(sftp))
+}
+
+func TestFTPAgent__SharedConnection(t *testing.T) {
+	svc, err := createTestFTPServer(t)
+	require.NoError(t, err)
+	defer svc.Shutdown()
+
+	auth, ok := svc.Auth.(*server.SimpleAuth)
+	require.True(t, ok)
+
+	pool := &sessionPool{sessions: make(map[string]*sharedSession)}
+	newAgent := func(inbound string) *FTPTransferAgent {
+		cfg := &service.UploadAgent{
+			FTP: &service.FTP{
+				Hostname: fmt.Sprintf("%s:%d", svc.Hostname, svc.Port),
+				Username: auth.Name,
+				Password: auth.Password,
+			},
+			Paths: service.UploadPaths{
+				Inbound:  inbound,
+				Outbound: "outbound",
+			},
+		}
+		agent, err := newFTPTransferAgent(log.NewNopLogger(), cfg, pool)
+		require.NoError(t, err)
+		return agent
+	}
+	first, second := newAgent("inbound"), newAgent("returned")
+	require.Same(t, first.session, second.session)
+	require.Nil(t, first.conn)
+	require.Nil(t, second.conn)
+
+	files, err := first.GetInboundFiles()
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	files, err = second.GetInboundFiles()
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	// The connection stays open until the last agent closes
+	require.NoError(t, first.Close())
+	require.NotNil(t, second.session.ftpConn)
+	require.NoError(t, second.Ping())
+
+	require.NoError(t, second.Close())
+	require.Nil(t, second.session.ftpConn)
+	require.Equal(t, 0, pool.agents(second.session.key))
+}
@@ -5,8 +5,6 @@
 package upload
 
 import (
-	"bytes"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -16,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/moov-io/achgateway/internal/fips"
 	"github.com/moov-io/achgateway/internal/service"
 	"github.com/moov-io/achgateway/internal/sshx"
 	"github.com/moov-io/base/log"
@@ -23,6 +22,7 @@ import (
 	"github.com/go-kit/kit/metrics/prometheus"
 	"github.com/pkg/sftp"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/sethvargo/go-retry"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -36,28 +36,65 @@ var (
 		Name: "sftp_connection_retries",
 		Help: "Counter of SFTP connection retry attempts",
 	}, []string{"hostname"})
+
+	sftpUploadedBytes = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "sftp_uploaded_bytes",
+		Help: "Counter of bytes uploaded to SFTP servers",
+	}, []string{"hostname"})
+	sftpUploadThroughput = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Name:    "sftp_upload_throughput_bytes_per_second",
+		Help:    "Histogram of each uploaded file's average throughput in bytes per second",
+		Buckets: stdprometheus.ExponentialBuckets(16*1024, 4, 8), // 16KB/s to 256MB/s
+	}, []string{"hostname"})
 )
 
 type SFTPTransferAgent struct {
-	conn   *ssh.Client
-	client *sftp.Client
 	cfg    service.UploadAgent
 	logger log.Logger
-	mu     sync.Mutex // protects all read/write methods
+
+	// conns are the agent's connections, unless they're shared with other agents
+	conns *sftpPool
+
+	// skipped are the files passed over by the last readFiles call
+	mu      sync.Mutex
+	skipped []SkippedFile
+
+	// session is the connection shared with other agents, when enabled
+	session *sharedSession
+
+	// listings are the remote files already processed, when CacheListings is enabled
+	listings *listingCache
+
+	// throttle limits the agent's transfer rate, when MaxBytesPerSecond is set
+	throttle *throttle
 }
 
-func newSFTPTransferAgent(logger log.Logger, cfg *service.UploadAgent) (*SFTPTransferAgent, error) {
+// newSFTPTransferAgent connects to the SFTP server, reusing a connection from sessions
+// when it's non-nil.
+func newSFTPTransferAgent(logger log.Logger, cfg *service.UploadAgent, sessions *sessionPool) (*SFTPTransferAgent, error) {
 	if cfg == nil || cfg.SFTP == nil {
 		return nil, errors.New("nil SFTP config")
 	}
 
 	agent := &SFTPTransferAgent{cfg: *cfg, logger: logger}
+	agent.conns = newSFTPPool(logger, agent.cfg)
+	agent.listings = newListingCache(cfg.SFTP.CacheListings, cfg.SFTP.Hostname)
+	agent.throttle = newThrottle(cfg.SFTP.MaxBytesPerSecond)
 
 	if err := rejectOutboundIPRange(cfg.SplitAllowedIPs(), cfg.SFTP.Hostname); err != nil {
 		return nil, fmt.Errorf("sftp: %s is not whitelisted: %v", cfg.SFTP.Hostname, err)
 	}
+	if sessions != nil {
+		agent.session = sessions.acquire(sftpSessionKey(cfg.SFTP))
+		if n := sessions.agents(agent.session.key); n > 1 {
+			logger.Info().Logf("sftp: agent %s is sharing a connection to %s with %d other agents", cfg.ID, cfg.SFTP.Hostname, n-1)
+		}
+	}
 
-	_, err := agent.connection()
+	_, release, err := agent.connection()
+	if err == nil {
+		release()
+	}
 
 	agent.record(err) // track up metric for remote server
 
@@ -68,45 +105,35 @@ func (agent *SFTPTransferAgent) ID() string {
 	return agent.cfg.ID
 }
 
-// connection returns an sftp.Client which is connected to the remote server.
-// This function will attempt to establish a new connection if none exists already.
+// connection returns an sftp.Client which is connected to the remote server, taken from the
+// agent's pool of connections. A new connection is established when none are idle and it blocks
+// while MaxConnections are in use.
 //
-// connection must be called within a mutex lock.
-func (agent *SFTPTransferAgent) connection() (*sftp.Client, error) {
+// release must be called once the caller is finished with the client.
+func (agent *SFTPTransferAgent) connection() (*sftp.Client, func(), error) {
 	if agent == nil {
-		return nil, errors.New("nil agent / config")
-	}
-
-	if agent.client != nil {
-		// Verify the connection works and if not drop through and reconnect
-		if _, err := agent.client.Getwd(); err == nil {
-			return agent.client, nil
-		} else {
-			// Our connection is having issues, so retry connecting
-			agent.client.Close()
-		}
+		return nil, nil, errors.New("nil agent / config")
 	}
-
-	conn, stdin, stdout, err := sftpConnect(agent.logger, agent.cfg)
+	pool := agent.pool()
+	conn, err := pool.get()
 	if err != nil {
-		return nil, fmt.Errorf("upload: %v", err)
+		return nil, nil, err
 	}
-	agent.conn = conn
+	return conn.client, func() { pool.put(conn) }, nil
+}
 
-	// Setup our SFTP client
-	var opts = []sftp.ClientOption{
-		sftp.MaxConcurrentRequestsPerFile(agent.cfg.SFTP.MaxConnections()),
-		sftp.MaxPacket(agent.cfg.SFTP.PacketSize()),
-	}
-	// client, err := sftp.NewClient(conn, opts...)
-	client, err := sftp.NewClientPipe(stdout, stdin, opts...)
-	if err != nil {
-		go conn.Close()
-		return nil, fmt.Errorf("upload: sftp connect: %v", err)
+// pool returns the connections shared with other agents, or the agent's own
+func (agent *SFTPTransferAgent) pool() *sftpPool {
+	if agent.session == nil {
+		return agent.conns
 	}
-	agent.client = client
+	agent.session.mu.Lock()
+	defer agent.session.mu.Unlock()
 
-	return agent.client, nil
+	if agent.session.sftpPool == nil {
+		agent.session.sftpPool = newSFTPPool(agent.logger, agent.cfg)
+	}
+	return agent.session.sftpPool
 }
 
 var (
@@ -133,13 +160,18 @@ func sftpConnect(logger log.Logger, cfg service.UploadAgent) (*ssh.Client, io.Wr
 		Timeout: cfg.SFTP.Timeout(),
 	}
 	conf.SetDefaults()
+	fips.SSHConfig(conf)
+	sshAlgorithms(conf, cfg.SFTP)
 
-	if cfg.SFTP.HostPublicKey != "" {
-		pubKey, err := sshx.ReadPubKey([]byte(cfg.SFTP.HostPublicKey))
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("problem parsing ssh public key: %v", err)
+	hostKeys, err := sftpHostKeys(cfg.SFTP)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if hostKeys != nil {
+		conf.HostKeyCallback = hostKeys.HostKeyCallback()
+		if len(hostKeys.Authorities) > 0 {
+			conf.HostKeyAlgorithms = sshx.PreferCertAlgorithms(conf.HostKeyAlgorithms)
 		}
-		conf.HostKeyCallback = ssh.FixedHostKey(pubKey)
 	} else {
 		hostKeyCallbackOnce.Do(func() {
 			hostKeyCallback(logger)
@@ -155,24 +187,24 @@ func sftpConnect(logger log.Logger, cfg service.UploadAgent) (*ssh.Client, io.Wr
 		if err != nil {
 			return nil, nil, nil, fmt.Errorf("sftpConnect: failed to read client private key: %v", err)
 		}
+		cert, err := clientCertificate(cfg.SFTP)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("sftpConnect: %v", err)
+		}
+		if cert != nil {
+			signer, err = sshx.CertSigner(cert, signer)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("sftpConnect: %v", err)
+			}
+		}
 		conf.Auth = append(conf.Auth, ssh.PublicKeys(signer))
 	default:
 		return nil, nil, nil, errors.New("sftpConnect: no auth method provided")
 	}
 
 	// Connect to the remote server
-	var client *ssh.Client
-	var err error
-	for i := 0; i < 3; i++ {
-		if client == nil {
-			if i > 0 {
-				sftpConnectionRetries.With("hostname", cfg.SFTP.Hostname).Add(1)
-			}
-			client, err = ssh.Dial("tcp", cfg.SFTP.Hostname, conf) // retry connection
-			time.Sleep(250 * time.Millisecond)
-		}
-	}
-	if client == nil && err != nil {
+	client, err := sshDialRetrying(cfg.SFTP, conf)
+	if err != nil {
 		return nil, nil, nil, fmt.Errorf("sftpConnect: %v", err)
 	}
 
@@ -199,12 +231,170 @@ func sftpConnect(logger log.Logger, cfg service.UploadAgent) (*ssh.Client, io.Wr
 	return client, pw, pr, nil
 }
 
+// sshAlgorithms replaces the algorithms offered to the server with the configured lists
+func sshAlgorithms(conf *ssh.ClientConfig, cfg *service.SFTP) {
+	if len(cfg.Ciphers) > 0 {
+		conf.Ciphers = cfg.Ciphers
+	}
+	if len(cfg.KeyExchanges) > 0 {
+		conf.KeyExchanges = cfg.KeyExchanges
+	}
+	if len(cfg.MACs) > 0 {
+		conf.MACs = cfg.MACs
+	}
+	if len(cfg.HostKeyAlgorithms) > 0 {
+		conf.HostKeyAlgorithms = cfg.HostKeyAlgorithms
+	}
+}
+
+// sftpHostKeys returns what the server's host key is verified against, which is nil when none
+// of HostPublicKey, HostPublicKeys, HostCertificateAuthority or KnownHostsFile are set.
+// KnownHostsFile is read every time so keys added or revoked on disk are used.
+func sftpHostKeys(cfg *service.SFTP) (*sshx.KnownHosts, error) {
+	if cfg.HostPublicKey == "" && len(cfg.HostPublicKeys) == 0 && cfg.HostCertificateAuthority == "" && cfg.KnownHostsFile == "" {
+		return nil, nil
+	}
+	out := &sshx.KnownHosts{}
+	for _, raw := range append([]string{cfg.HostPublicKey}, cfg.HostPublicKeys...) {
+		if raw == "" {
+			continue
+		}
+		pubKey, err := sshx.ReadPubKey([]byte(raw))
+		if err != nil {
+			return nil, fmt.Errorf("problem parsing ssh public key: %v", err)
+		}
+		out.Keys = append(out.Keys, sshx.HostKey{Key: pubKey})
+	}
+	if cfg.HostCertificateAuthority != "" {
+		authorities, err := sshx.ReadAuthorities([]byte(cfg.HostCertificateAuthority))
+		if err != nil {
+			return nil, fmt.Errorf("problem parsing host certificate authority: %v", err)
+		}
+		out.Authorities = authorities
+	}
+	if cfg.KnownHostsFile != "" {
+		bs, err := os.ReadFile(cfg.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read known hosts: %v", err)
+		}
+		known, err := sshx.ReadKnownHosts(bs)
+		if err != nil {
+			return nil, fmt.Errorf("problem parsing known hosts: %v", err)
+		}
+		out.Merge(known)
+	}
+	return out, nil
+}
+
 func readSigner(raw string) (ssh.Signer, error) {
-	decoded, err := base64.StdEncoding.DecodeString(raw)
-	if len(decoded) > 0 && err == nil {
-		return ssh.ParsePrivateKey(decoded)
+	return sshx.ReadSigner(raw)
+}
+
+// clientCertificate reads the user certificate presented with ClientPrivateKey, which is nil when
+// none is configured. ClientCertificateFile is read every time so renewed certificates are used.
+func clientCertificate(cfg *service.SFTP) (*ssh.Certificate, error) {
+	raw := cfg.ClientCertificate
+	if cfg.ClientCertificateFile != "" {
+		bs, err := os.ReadFile(cfg.ClientCertificateFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client certificate: %v", err)
+		}
+		raw = string(bs)
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	cert, err := sshx.ReadCertificate(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client certificate: %v", err)
+	}
+	if err := sshx.CertificateValid(cert, time.Now()); err != nil {
+		return nil, fmt.Errorf("client certificate: %v", err)
+	}
+	return cert, nil
+}
+
+// sshDialRetrying connects to the server, retrying failed attempts as configured by ConnectRetry.
+// When every attempt fails the error lists each of them.
+func sshDialRetrying(cfg *service.SFTP, conf *ssh.ClientConfig) (*ssh.Client, error) {
+	backoff, err := connectBackoff(cfg.ConnectRetry)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	var deadline time.Time
+	if cfg.ConnectRetry != nil && cfg.ConnectRetry.Deadline > 0 {
+		deadline = start.Add(cfg.ConnectRetry.Deadline)
+	}
+
+	var failures []string
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			sftpConnectionRetries.With("hostname", cfg.Hostname).Add(1)
+		}
+		dialConf := *conf
+		if remaining := time.Until(deadline); !deadline.IsZero() && remaining < dialConf.Timeout {
+			dialConf.Timeout = remaining
+			if remaining <= 0 {
+				dialConf.Timeout = time.Millisecond // a zero timeout would wait forever
+			}
+		}
+		client, err := sshDial(cfg, &dialConf)
+		if err == nil {
+			return client, nil
+		}
+		err = fips.Wrap(cfg.Hostname, err)
+		failures = append(failures, fmt.Sprintf("attempt %d: %v", attempt, err))
+
+		wait, stop := backoff.Next()
+		if !deadline.IsZero() && time.Now().Add(wait).After(deadline) {
+			stop = true
+		}
+		if stop {
+			if attempt == 1 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%d attempts failed in %v: %s", attempt, time.Since(start).Round(time.Millisecond), strings.Join(failures, "; "))
+		}
+		time.Sleep(wait)
+	}
+}
+
+// connectBackoff returns how long to wait after each failed connection attempt, which stops
+// once cfg.Attempts have been made.
+func connectBackoff(cfg *service.SFTPConnectRetry) (retry.Backoff, error) {
+	backoff, err := retry.NewExponential(cfg.InitialBackoff())
+	if err != nil {
+		return nil, fmt.Errorf("connect retry: %v", err)
+	}
+	if cfg != nil && cfg.MaxBackoff > 0 {
+		backoff = retry.WithCappedDuration(cfg.MaxBackoff, backoff)
+	}
+	if cfg != nil && cfg.Jitter > 0 {
+		backoff = retry.WithJitter(cfg.Jitter, backoff)
+	}
+	return retry.WithMaxRetries(uint64(cfg.Attempts()-1), backoff), nil
+}
+
+// sshDial connects to the server, through cfg.Proxy when one is set
+func sshDial(cfg *service.SFTP, conf *ssh.ClientConfig) (*ssh.Client, error) {
+	dial, err := proxyDialer(cfg.Proxy, cfg.Timeout())
+	if err != nil {
+		return nil, err
+	}
+	if dial == nil {
+		return ssh.Dial("tcp", cfg.Hostname, conf)
+	}
+	conn, err := dial("tcp", cfg.Hostname)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, cfg.Hostname, conf)
+	if err != nil {
+		conn.Close()
+		return nil, err
 	}
-	return ssh.ParsePrivateKey([]byte(raw))
+	return ssh.NewClient(c, chans, reqs), nil
 }
 
 func (agent *SFTPTransferAgent) Ping() error {
@@ -212,20 +402,23 @@ func (agent *SFTPTransferAgent) Ping() error {
 		return errors.New("nil SFTPTransferAgent")
 	}
 
-	agent.mu.Lock()
-	defer agent.mu.Unlock()
-
-	conn, err := agent.connection()
+	pool := agent.pool()
+	conn, err := pool.get()
 	agent.record(err)
 	if err != nil {
 		return err
 	}
 
-	_, err = conn.ReadDir(".")
+	err = conn.check(agent.cfg.SFTP.Timeout(), func(client *sftp.Client) error {
+		_, err := client.ReadDir(".")
+		return err
+	})
 	agent.record(err)
 	if err != nil {
+		pool.discard(conn)
 		return fmt.Errorf("sftp: ping %v", err)
 	}
+	pool.put(conn)
 	return nil
 }
 
@@ -244,29 +437,49 @@ func (agent *SFTPTransferAgent) Close() error {
 	if agent == nil {
 		return nil
 	}
-	if agent.client != nil {
-		agent.client.Close()
+	if agent.session != nil {
+		return agent.closeSession()
 	}
-	if agent.conn != nil {
-		agent.conn.Close()
+	agent.conns.close()
+	return nil
+}
+
+// closeSession disconnects the shared connection once no other agents are using it
+func (agent *SFTPTransferAgent) closeSession() error {
+	if !agent.session.pool.release(agent.session) {
+		return nil
 	}
+	agent.session.mu.Lock()
+	defer agent.session.mu.Unlock()
+
+	agent.session.sftpPool.close()
+	agent.session.sftpPool = nil
 	return nil
 }
 
+// lockPath serializes writes to dir with other agents sharing the connection. Reads don't take
+// the lock since each operation has its own connection from the pool.
+func (agent *SFTPTransferAgent) lockPath(dir string) func() {
+	if agent.session == nil {
+		return func() {}
+	}
+	return agent.session.lockPath(dir)
+}
+
 func (agent *SFTPTransferAgent) InboundPath() string {
-	return agent.cfg.Paths.Inbound
+	return currentPaths(agent.cfg.Paths, "").Inbound
 }
 
 func (agent *SFTPTransferAgent) OutboundPath() string {
-	return agent.cfg.Paths.Outbound
+	return currentPaths(agent.cfg.Paths, "").Outbound
 }
 
 func (agent *SFTPTransferAgent) ReconciliationPath() string {
-	return agent.cfg.Paths.Reconciliation
+	return currentPaths(agent.cfg.Paths, "").Reconciliation
 }
 
 func (agent *SFTPTransferAgent) ReturnPath() string {
-	return agent.cfg.Paths.Return
+	return currentPaths(agent.cfg.Paths, "").Return
 }
 
 func (agent *SFTPTransferAgent) Hostname() string {
@@ -277,13 +490,13 @@ func (agent *SFTPTransferAgent) Hostname() string {
 }
 
 func (agent *SFTPTransferAgent) Delete(path string) error {
-	agent.mu.Lock()
-	defer agent.mu.Unlock()
+	defer agent.lockPath(filepath.Dir(path))()
 
-	conn, err := agent.connection()
+	conn, release, err := agent.connection()
 	if err != nil {
 		return err
 	}
+	defer release()
 
 	info, err := conn.Stat(path)
 	if err != nil && !os.IsNotExist(err) {
@@ -297,116 +510,319 @@ func (agent *SFTPTransferAgent) Delete(path string) error {
 	return nil // not found
 }
 
+func (agent *SFTPTransferAgent) Move(oldpath, newpath string) error {
+	defer agent.lockPath(filepath.Dir(oldpath))()
+
+	conn, release, err := agent.connection()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := conn.MkdirAll(filepath.Dir(newpath)); err != nil {
+		return fmt.Errorf("sftp: problem creating parent dir %s: %v", filepath.Dir(newpath), err)
+	}
+	if err := sftpRename(conn, oldpath, newpath); err != nil {
+		return fmt.Errorf("sftp: move: %v", err)
+	}
+	return nil
+}
+
+func (agent *SFTPTransferAgent) Exists(path string) (bool, error) {
+	defer agent.lockPath(filepath.Dir(path))()
+
+	conn, release, err := agent.connection()
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	info, err := conn.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("sftp: checking %s: %v", path, err)
+	}
+	return !info.IsDir(), nil
+}
+
 // uploadFile saves the content of File at the given filename in the OutboundPath directory
 //
 // The File's contents will always be closed
 func (agent *SFTPTransferAgent) UploadFile(f File) error {
 	defer f.Close()
 
-	agent.mu.Lock()
-	defer agent.mu.Unlock()
+	outbound := currentPaths(agent.cfg.Paths, f.RoutingNumber).Outbound
+	defer agent.lockPath(outbound)()
 
-	conn, err := agent.connection()
+	conn, release, err := agent.connection()
 	if err != nil {
 		return err
 	}
+	defer func() { release() }() // resumed uploads switch connections
+
+	// Take the base of f.Filename and our (out of band) OutboundPath to avoid accepting a write like '../../../../etc/passwd'.
+	pathToWrite := filepath.Join(outbound, filepath.Base(f.Filename))
+
+	// Atomic uploads are written under a temporary name and renamed once complete
+	writePath := pathToWrite
+	staged := agent.cfg.SFTP != nil && agent.cfg.SFTP.AtomicUploads
+	if staged {
+		writePath = sftpTempPath(agent.cfg.SFTP, pathToWrite)
+	}
 
 	// Create OutboundPath if it doesn't exist and we're told to create it
 	if agent.cfg.SFTP != nil && !agent.cfg.SFTP.SkipDirectoryCreation {
-		info, err := conn.Stat(agent.cfg.Paths.Outbound)
-		if info == nil || (err != nil && os.IsNotExist(err)) {
-			if err := conn.Mkdir(agent.cfg.Paths.Outbound); err != nil {
-				return fmt.Errorf("sftp: problem creating parent dir %s: %v", agent.cfg.Paths.Outbound, err)
+		dirs := []string{outbound}
+		if dir := filepath.Dir(writePath); dir != filepath.Clean(outbound) {
+			dirs = append(dirs, dir)
+		}
+		for _, dir := range dirs {
+			info, err := conn.Stat(dir)
+			if info == nil || (err != nil && os.IsNotExist(err)) {
+				if err := conn.MkdirAll(dir); err != nil {
+					return fmt.Errorf("sftp: problem creating parent dir %s: %v", dir, err)
+				}
 			}
 		}
 	}
 
-	// Take the base of f.Filename and our (out of band) OutboundPath to avoid accepting a write like '../../../../etc/passwd'.
-	pathToWrite := filepath.Join(agent.cfg.Paths.Outbound, filepath.Base(f.Filename))
-
-	fd, err := conn.OpenFile(pathToWrite, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	fd, err := conn.OpenFile(writePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
 	if err != nil {
-		return fmt.Errorf("sftp: problem creating %s: %v", pathToWrite, err)
+		return fmt.Errorf("sftp: problem creating %s: %v", writePath, err)
+	}
+	progress := newProgressReader(agent.logger, f.Filename, agent.throttle.reader(f.Contents))
+
+	// Partial temporary files are never renamed, so don't leave them behind
+	cleanup := func() {
+		if staged {
+			conn.Remove(writePath)
+		}
+	}
+
+	var n int64
+	if agent.cfg.SFTP != nil && agent.cfg.SFTP.ConcurrentWrites {
+		n, err = fd.ReadFromWithConcurrency(progress, agent.cfg.SFTP.RequestsPerFile())
+	} else if agent.cfg.SFTP.ResumesUploads() {
+		// Keep what's been read so a failed upload can continue where the server left off
+		replay, rerr := newReplayReader(progress, f.Contents)
+		if rerr != nil {
+			fd.Close()
+			cleanup()
+			return fmt.Errorf("sftp: problem reading %s: %v", f.Filename, rerr)
+		}
+		n, err = io.Copy(fd, replay)
+
+		for attempt := 1; err != nil && attempt <= maxUploadResumes; attempt++ {
+			fd.Close()
+			release()
+			release = func() {}
+
+			agent.logger.Warn().Logf("sftp: resuming upload of %s (attempt %d) after: %v", f.Filename, attempt, err)
+			sftpUploadResumes.With("hostname", agent.Hostname()).Add(1)
+
+			next, nextRelease, nextFd, offset, rerr := agent.reopen(writePath, replay)
+			if rerr != nil {
+				// The failed connection may be gone, so the partial file is removed over another
+				if c, r, cerr := agent.connection(); cerr == nil {
+					conn, release = c, r
+					cleanup()
+				}
+				return fmt.Errorf("sftp: problem copying (n=%d) %s: %v (resuming: %v)", n, f.Filename, err, rerr)
+			}
+			conn, release, fd = next, nextRelease, nextFd
+			n, err = io.Copy(fd, replay)
+			n += offset
+		}
+	} else {
+		n, err = io.Copy(fd, progress)
 	}
-	n, err := io.Copy(fd, f.Contents)
 	if err != nil {
 		fd.Close()
+		if staged || (agent.cfg.SFTP != nil && agent.cfg.SFTP.ConcurrentWrites) {
+			// Concurrent writes can leave holes in the file after an error, so don't leave it behind
+			conn.Remove(writePath)
+		}
 		return fmt.Errorf("sftp: problem copying (n=%d) %s: %v", n, f.Filename, err)
 	}
 	if err := fd.Sync(); err != nil {
 		// Skip sync if the remote server doesn't support it
 		if !strings.Contains(err.Error(), "SSH_FX_OP_UNSUPPORTED") {
+			fd.Close()
+			cleanup()
 			return fmt.Errorf("sftp: problem with sync on %s: %v", f.Filename, err)
 		}
 	}
 	if err := fd.Chmod(0600); err != nil {
+		fd.Close()
+		cleanup()
 		return fmt.Errorf("sftp: problem with chmod on %s: %v", f.Filename, err)
 	}
 	if err := fd.Close(); err != nil {
+		cleanup()
 		return fmt.Errorf("sftp: problem closing %s: %v", f.Filename, err)
 	}
+	if staged {
+		if err := sftpRename(conn, writePath, pathToWrite); err != nil {
+			cleanup()
+			return fmt.Errorf("sftp: problem renaming %s to %s: %v", writePath, pathToWrite, err)
+		}
+	}
+
+	throughput := progress.throughput()
+	sftpUploadedBytes.With("hostname", agent.Hostname()).Add(float64(n))
+	sftpUploadThroughput.With("hostname", agent.Hostname()).Observe(throughput)
+	agent.logger.Info().With(log.Fields{
+		"filename":   log.String(f.Filename),
+		"bytes":      log.Int64(n),
+		"throughput": log.Float64(throughput),
+	}).Logf("sftp: uploaded %s (%d bytes) in %v at %.2f MB/s", f.Filename, n, time.Since(progress.started).Truncate(time.Millisecond), throughput/1e6)
+
 	return nil
 }
 
+// sftpTempPath is where an atomic upload of path is written before it's renamed
+func sftpTempPath(cfg *service.SFTP, path string) string {
+	name := "." + filepath.Base(path) + ".tmp"
+	if cfg.StagingPath != "" {
+		return filepath.Join(cfg.StagingPath, name)
+	}
+	return filepath.Join(filepath.Dir(path), name)
+}
+
+// sftpRename moves oldpath to newpath, replacing newpath when the server supports
+// POSIX renames. Plain SFTP renames fail when newpath exists.
+func sftpRename(conn *sftp.Client, oldpath, newpath string) error {
+	if _, ok := conn.HasExtension("posix-rename@openssh.com"); ok {
+		return conn.PosixRename(oldpath, newpath)
+	}
+	return conn.Rename(oldpath, newpath)
+}
+
 func (agent *SFTPTransferAgent) GetInboundFiles() ([]File, error) {
-	return agent.readFiles(agent.cfg.Paths.Inbound)
+	return agent.readFiles(agent.InboundPath(), agent.cfg.Paths.InboundFilter)
 }
 
 func (agent *SFTPTransferAgent) GetReconciliationFiles() ([]File, error) {
-	return agent.readFiles(agent.cfg.Paths.Reconciliation)
+	return agent.readFiles(agent.ReconciliationPath(), agent.cfg.Paths.ReconciliationFilter)
 }
 
 func (agent *SFTPTransferAgent) GetReturnFiles() ([]File, error) {
-	return agent.readFiles(agent.cfg.Paths.Return)
+	return agent.readFiles(agent.ReturnPath(), agent.cfg.Paths.ReturnFilter)
 }
 
-func (agent *SFTPTransferAgent) readFiles(dir string) ([]File, error) {
+func (agent *SFTPTransferAgent) SkippedFiles() []SkippedFile {
 	agent.mu.Lock()
 	defer agent.mu.Unlock()
 
-	conn, err := agent.connection()
+	return agent.skipped
+}
+
+func (agent *SFTPTransferAgent) readFiles(dir string, filter *service.FilenameFilter) ([]File, error) {
+	conn, release, err := agent.connection()
 	if err != nil {
 		return nil, err
 	}
+	defer release()
 
-	infos, err := conn.ReadDir(dir)
+	var skipped []SkippedFile
+	defer func() {
+		agent.mu.Lock()
+		agent.skipped = skipped
+		agent.mu.Unlock()
+	}()
+
+	matches, err := filter.Matcher()
 	if err != nil {
-		return nil, fmt.Errorf("sftp: readdir %s: %v", dir, err)
+		return nil, err
+	}
+	listing := agent.listings.list(dir)
+	spool := newSpooler(agent.cfg.Downloads)
+
+	// Subdirectories are listed up to Paths.Depth levels and their files named relative to dir
+	var names []string
+	var list func(rel string, level int) error
+	list = func(rel string, level int) error {
+		infos, err := conn.ReadDir(filepath.Join(dir, rel))
+		if err != nil {
+			return fmt.Errorf("sftp: readdir %s: %v", filepath.Join(dir, rel), err)
+		}
+		for i := range infos {
+			name := filepath.Join(rel, infos[i].Name())
+			if infos[i].IsDir() && level < agent.cfg.Paths.Depth {
+				if err := list(name, level+1); err != nil {
+					return err
+				}
+				continue
+			}
+			if infos[i].Mode().IsRegular() && listing.unchanged(name, infos[i].Size(), infos[i].ModTime()) {
+				continue
+			}
+			if !infos[i].IsDir() && !matches(infos[i].Name()) {
+				skipped = append(skipped, SkippedFile{
+					Path:   filepath.Join(dir, name),
+					Reason: SkipFiltered,
+				})
+				continue
+			}
+			names = append(names, name)
+		}
+		return nil
+	}
+	if err := list("", 0); err != nil {
+		return nil, err
 	}
 
-	var files []File
-	for i := range infos {
-		fd, err := conn.Open(filepath.Join(dir, infos[i].Name()))
+	// Files are downloaded in parallel over the one connection, which pkg/sftp allows
+	var mu sync.Mutex
+	downloaded := make([]*File, len(names))
+	err = downloadEach(len(names), agent.cfg.SFTP.Workers(), func(_, i int) error {
+		fd, err := conn.Open(filepath.Join(dir, names[i]))
 		if err != nil {
-			return nil, fmt.Errorf("sftp: open %s: %v", infos[i].Name(), err)
+			return fmt.Errorf("sftp: open %s: %v", names[i], err)
 		}
+		defer fd.Close()
 
-		// skip this file descriptor if it's a directory - we only reading one level deep
+		// skip this file descriptor if it's a directory deeper than Paths.Depth
 		info, err := fd.Stat()
 		if err != nil {
-			fd.Close()
-			return nil, fmt.Errorf("sftp: stat %s: %v", infos[i].Name(), err)
+			return fmt.Errorf("sftp: stat %s: %v", names[i], err)
 		}
 		if info.IsDir() {
-			fd.Close()
-			continue
+			mu.Lock()
+			skipped = append(skipped, SkippedFile{
+				Path:   filepath.Join(dir, names[i]),
+				Reason: SkipDirectory,
+			})
+			mu.Unlock()
+			return nil
 		}
 
-		// download the remote file to our local directory
-		var buf bytes.Buffer
-		if n, err := io.Copy(&buf, fd); err != nil {
-			fd.Close()
-			if err != nil && !strings.Contains(err.Error(), sftp.ErrInternalInconsistency.Error()) {
-				return nil, fmt.Errorf("sftp: read (n=%d) %s: %v", n, infos[i].Name(), err)
+		// download the remote file into memory or a temporary file
+		contents, n, err := spool.spool(agent.throttle.reader(fd))
+		if err != nil {
+			if !strings.Contains(err.Error(), sftp.ErrInternalInconsistency.Error()) {
+				return fmt.Errorf("sftp: read (n=%d) %s: %v", n, names[i], err)
 			}
-			return nil, fmt.Errorf("sftp: read (n=%d) on %s: %v", n, infos[i].Name(), err)
-		} else {
-			fd.Close()
+			return fmt.Errorf("sftp: read (n=%d) on %s: %v", n, names[i], err)
 		}
-		files = append(files, File{
-			Filename: infos[i].Name(),
-			Contents: io.NopCloser(&buf),
-		})
+		downloaded[i] = &File{
+			Filename: filepath.ToSlash(names[i]),
+			Contents: contents,
+		}
+		return nil
+	})
+	files := collectDownloads(downloaded)
+	if err != nil {
+		closeFiles(files)
+		return nil, err
 	}
+	listing.done()
+
 	return files, nil
 }
+
+func (agent *SFTPTransferAgent) CommitListings() {
+	agent.listings.commit()
+}
@@ -0,0 +1,290 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/log"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/pkg/sftp"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	sftpConnectionsInUse = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Name: "sftp_connections_in_use",
+		Help: "How many of an SFTP agent's pooled connections are in use",
+	}, []string{"hostname"})
+
+	sftpKeepaliveFailures = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "sftp_keepalive_failures",
+		Help: "Counter of SSH keepalives to an SFTP server that failed or went unanswered",
+	}, []string{"hostname"})
+
+	sftpHealthCheckFailures = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "sftp_health_check_failures",
+		Help: "Counter of idle SFTP connections dropped because they failed or didn't answer a health check",
+	}, []string{"hostname"})
+)
+
+// sftpPool holds up to SFTP.MaxConnections connections to one server. Each operation takes a
+// connection for its duration, so that many uploads and downloads run in parallel and any more
+// wait for a connection to be returned.
+//
+// The pool's mutex is only held to take or return an idle connection. Health checks and the
+// operations themselves run without it, so a hung server only holds up the caller using it.
+type sftpPool struct {
+	logger log.Logger
+	cfg    service.UploadAgent
+
+	// slots has an entry for each connection in use
+	slots chan struct{}
+
+	mu   sync.Mutex
+	idle []*sftpConn
+
+	// generation increases on close, so connections in use at the time are closed when returned
+	generation int
+}
+
+// sftpConn is one SSH connection and the SFTP client running over it
+type sftpConn struct {
+	ssh        *ssh.Client
+	client     *sftp.Client
+	generation int
+
+	// done is closed when the connection is, which stops keepalives
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newSFTPPool(logger log.Logger, cfg service.UploadAgent) *sftpPool {
+	return &sftpPool{
+		logger: logger,
+		cfg:    cfg,
+		slots:  make(chan struct{}, cfg.SFTP.PoolSize()),
+	}
+}
+
+// get returns a working connection, reusing an idle one when possible. It blocks while every
+// connection is in use. The connection must be returned with put.
+func (p *sftpPool) get() (*sftpConn, error) {
+	if p == nil {
+		return nil, errors.New("nil sftp connection pool")
+	}
+	p.slots <- struct{}{}
+
+	for {
+		p.mu.Lock()
+		var conn *sftpConn
+		if n := len(p.idle); n > 0 {
+			conn, p.idle = p.idle[n-1], p.idle[:n-1]
+		}
+		generation := p.generation
+		p.mu.Unlock()
+
+		if conn == nil {
+			conn, err := dialSFTP(p.logger, p.cfg)
+			if err != nil {
+				<-p.slots
+				return nil, err
+			}
+			conn.generation = generation
+			p.inUse(1)
+			return conn, nil
+		}
+
+		// Verify the connection works and if not drop it and try the next
+		err := conn.check(p.cfg.SFTP.Timeout(), func(client *sftp.Client) error {
+			_, err := client.Getwd()
+			return err
+		})
+		if err == nil {
+			p.inUse(1)
+			return conn, nil
+		}
+		p.logger.Warn().Logf("dropping idle SFTP connection: %v", err)
+		sftpHealthCheckFailures.With("hostname", p.cfg.SFTP.Hostname).Add(1)
+		conn.close()
+	}
+}
+
+// put returns a connection taken with get
+func (p *sftpPool) put(conn *sftpConn) {
+	p.mu.Lock()
+	if conn.generation == p.generation {
+		p.idle = append(p.idle, conn)
+	} else {
+		conn.close()
+	}
+	p.mu.Unlock()
+
+	p.inUse(-1)
+	<-p.slots
+}
+
+// discard closes a connection taken with get instead of returning it, for when it stopped working
+func (p *sftpPool) discard(conn *sftpConn) {
+	conn.close()
+
+	p.inUse(-1)
+	<-p.slots
+}
+
+// close disconnects idle connections and those in use once they're returned. The pool can
+// still be used afterwards, which opens new connections.
+func (p *sftpPool) close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.generation++
+	p.mu.Unlock()
+
+	for i := range idle {
+		idle[i].close()
+	}
+}
+
+func (p *sftpPool) inUse(delta float64) {
+	sftpConnectionsInUse.With("hostname", p.cfg.SFTP.Hostname).Add(delta)
+}
+
+func (conn *sftpConn) close() {
+	conn.closeOnce.Do(func() {
+		if conn.done != nil {
+			close(conn.done)
+		}
+	})
+	// Closing the SSH connection first stops the client waiting on an unresponsive server
+	if conn.ssh != nil {
+		conn.ssh.Close()
+	}
+	if conn.client != nil {
+		conn.client.Close()
+	}
+}
+
+// check runs op and waits up to timeout for it to finish. The connection is closed when op
+// doesn't finish in time, which also stops op from waiting on the server.
+func (conn *sftpConn) check(timeout time.Duration, op func(*sftp.Client) error) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- op(conn.client)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-timer.C:
+		conn.close()
+		return fmt.Errorf("no reply after %v", timeout)
+	}
+}
+
+// keepalive sends an SSH keepalive every interval and closes the connection once maxMissed
+// in a row fail, so the pool dials a new connection instead of uploading over a dead one.
+func (conn *sftpConn) keepalive(logger log.Logger, hostname string, interval time.Duration, maxMissed int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	missed := 0
+	for {
+		select {
+		case <-conn.done:
+			return
+		case <-ticker.C:
+		}
+
+		err := sendKeepalive(conn.ssh, interval)
+		if err == nil {
+			missed = 0
+			continue
+		}
+		missed++
+		sftpKeepaliveFailures.With("hostname", hostname).Add(1)
+
+		if missed >= maxMissed {
+			logger.Warn().Logf("closing SFTP connection after %d missed keepalives: %v", missed, err)
+			conn.close()
+			return
+		}
+	}
+}
+
+// sendKeepalive waits up to timeout for the server to answer a keepalive. Servers reply with
+// a failure for requests they don't support, which still shows the connection works.
+func sendKeepalive(client *ssh.Client, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		errs <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("no keepalive reply after %v", timeout)
+	}
+}
+
+// dialSFTP opens a new connection to the server
+func dialSFTP(logger log.Logger, cfg service.UploadAgent) (*sftpConn, error) {
+	conn, stdin, stdout, err := sftpConnect(logger, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("upload: %v", err)
+	}
+
+	// Setup our SFTP client
+	var opts = []sftp.ClientOption{
+		sftp.MaxConcurrentRequestsPerFile(cfg.SFTP.RequestsPerFile()),
+		sftp.UseConcurrentWrites(cfg.SFTP.ConcurrentWrites),
+	}
+	if size := cfg.SFTP.PacketSize(); size > 32768 {
+		// Larger packets aren't supported by every server, but operators can opt into them
+		opts = append(opts, sftp.MaxPacketUnchecked(size))
+	} else {
+		opts = append(opts, sftp.MaxPacket(size))
+	}
+	client, err := sftp.NewClientPipe(stdout, stdin, opts...)
+	if err != nil {
+		go conn.Close()
+		return nil, fmt.Errorf("upload: sftp connect: %v", err)
+	}
+	sc := &sftpConn{ssh: conn, client: client, done: make(chan struct{})}
+	if interval := cfg.SFTP.KeepaliveInterval; interval > 0 {
+		go sc.keepalive(logger, cfg.SFTP.Hostname, interval, cfg.SFTP.MaxMissedKeepalives())
+	}
+	return sc, nil
+}
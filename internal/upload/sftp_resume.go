@@ -0,0 +1,138 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-kit/kit/metrics/prometheus"
+	"github.com/pkg/sftp"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// maxUploadResumes is how many times a failed upload continues before giving up
+	maxUploadResumes = 3
+
+	// maxReplayBuffer is how much of a reader which can't seek is kept to continue from. The
+	// server falls behind what was written by about one packet, so this is plenty.
+	maxReplayBuffer = 1 << 20
+)
+
+var (
+	sftpUploadResumes = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: "sftp_upload_resumes",
+		Help: "Counter of SFTP uploads continued after a write failed partway through",
+	}, []string{"hostname"})
+)
+
+// replayReader reads r so reading can start over from an earlier offset. When the contents
+// underneath r can seek, like aggregated files, they're seeked back. Otherwise only the last
+// maxReplayBuffer bytes read are kept.
+type replayReader struct {
+	r      io.Reader
+	seeker io.Seeker
+	start  int64
+
+	window []byte // the bytes read before read, when contents can't seek
+	read   int64  // bytes read from r
+	pos    int64  // offset of the next Read
+}
+
+// newReplayReader reads r, which wraps contents
+func newReplayReader(r io.Reader, contents io.Reader) (*replayReader, error) {
+	rr := &replayReader{r: r}
+	if seeker, ok := contents.(io.Seeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		rr.seeker, rr.start = seeker, start
+	}
+	return rr, nil
+}
+
+func (rr *replayReader) Read(p []byte) (int, error) {
+	if rr.pos < rr.read {
+		n := copy(p, rr.window[int64(len(rr.window))-(rr.read-rr.pos):])
+		rr.pos += int64(n)
+		return n, nil
+	}
+	n, err := rr.r.Read(p)
+	rr.read += int64(n)
+	rr.pos = rr.read
+	if rr.seeker == nil {
+		rr.window = append(rr.window, p[:n]...)
+		if len(rr.window) > 2*maxReplayBuffer {
+			rr.window = append(rr.window[:0], rr.window[len(rr.window)-maxReplayBuffer:]...)
+		}
+	}
+	return n, err
+}
+
+// rewind makes the next Read start at offset, which must have already been read
+func (rr *replayReader) rewind(offset int64) error {
+	if offset < 0 || offset > rr.read {
+		return fmt.Errorf("offset %d is past the %d bytes read", offset, rr.read)
+	}
+	if rr.seeker != nil {
+		if _, err := rr.seeker.Seek(rr.start+offset, io.SeekStart); err != nil {
+			return fmt.Errorf("seeking to offset %d: %v", offset, err)
+		}
+		rr.read, rr.pos = offset, offset
+		return nil
+	}
+	if kept := rr.read - int64(len(rr.window)); offset < kept {
+		return fmt.Errorf("offset %d is before the %d bytes kept", offset, len(rr.window))
+	}
+	rr.pos = offset
+	return nil
+}
+
+// reopen connects again after a failed write and opens path positioned after the bytes the
+// server has, so the upload continues instead of starting over.
+func (agent *SFTPTransferAgent) reopen(path string, replay *replayReader) (*sftp.Client, func(), *sftp.File, int64, error) {
+	conn, release, err := agent.connection()
+	if err != nil {
+		return nil, func() {}, nil, 0, err
+	}
+	info, err := conn.Stat(path)
+	if err != nil {
+		release()
+		return nil, func() {}, nil, 0, fmt.Errorf("stat %s: %v", path, err)
+	}
+	offset := info.Size()
+	if err := replay.rewind(offset); err != nil {
+		release()
+		return nil, func() {}, nil, 0, err
+	}
+
+	fd, err := conn.OpenFile(path, os.O_WRONLY)
+	if err != nil {
+		release()
+		return nil, func() {}, nil, 0, fmt.Errorf("opening %s: %v", path, err)
+	}
+	if _, err := fd.Seek(offset, io.SeekStart); err != nil {
+		fd.Close()
+		release()
+		return nil, func() {}, nil, 0, fmt.Errorf("seeking %s: %v", path, err)
+	}
+	return conn, release, fd, offset, nil
+}
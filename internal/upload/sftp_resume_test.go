@@ -0,0 +1,66 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayReader__Seeker(t *testing.T) {
+	data := bytes.Repeat([]byte("abcdefghij"), 10)
+	contents := NewContents(data)
+
+	rr, err := newReplayReader(contents, contents)
+	require.NoError(t, err)
+
+	buf := make([]byte, 40)
+	_, err = io.ReadFull(rr, buf)
+	require.NoError(t, err)
+	require.Empty(t, rr.window)
+
+	require.NoError(t, rr.rewind(25))
+	rest, err := io.ReadAll(rr)
+	require.NoError(t, err)
+	require.Equal(t, data[25:], rest)
+
+	require.ErrorContains(t, rr.rewind(int64(len(data)+1)), "past the")
+}
+
+func TestReplayReader__Buffered(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), maxReplayBuffer/4)
+	rr, err := newReplayReader(bytes.NewBuffer(data), bytes.NewBuffer(nil))
+	require.NoError(t, err)
+
+	read := int64(len(data) - 100)
+	_, err = io.CopyN(io.Discard, rr, read)
+	require.NoError(t, err)
+
+	// Only the most recent bytes are kept
+	require.LessOrEqual(t, len(rr.window), 2*maxReplayBuffer)
+	require.ErrorContains(t, rr.rewind(0), "before the")
+
+	offset := read - 50
+	require.NoError(t, rr.rewind(offset))
+	rest, err := io.ReadAll(rr)
+	require.NoError(t, err)
+	require.Equal(t, data[offset:], rest)
+}
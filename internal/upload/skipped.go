@@ -0,0 +1,47 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+const (
+	// SkipDirectory is a directory inside a remote path deeper than the agent reads, see Paths.Depth
+	SkipDirectory = "directory"
+
+	// SkipFiltered is a file which doesn't match the FilenameFilter of its path
+	SkipFiltered = "filtered"
+)
+
+// SkippedFile is a remote file an Agent listed but didn't download
+type SkippedFile struct {
+	Path   string
+	Reason string
+}
+
+// SkipReporter is implemented by Agents which report the remote files skipped by their
+// most recent GetInboundFiles, GetReconciliationFiles or GetReturnFiles call.
+type SkipReporter interface {
+	SkippedFiles() []SkippedFile
+}
+
+// SkippedFiles returns the files skipped by agent's most recent read, or nil if agent
+// doesn't report them.
+func SkippedFiles(agent Agent) []SkippedFile {
+	if sr, ok := agent.(SkipReporter); ok {
+		return sr.SkippedFiles()
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/moov-io/achgateway/internal/bufpool"
+	"github.com/moov-io/achgateway/internal/service"
+)
+
+// spooler holds the files read from one directory in memory until its limit is used and writes
+// the rest to temporary files, so a large drop of files doesn't exhaust memory. Files downloaded
+// in parallel take turns holding what's left of the limit while they're read.
+type spooler struct {
+	dir string
+
+	mu        sync.Mutex
+	remaining int64
+}
+
+func newSpooler(cfg *service.Downloads) *spooler {
+	return &spooler{
+		dir:       cfg.Directory(),
+		remaining: cfg.InMemoryLimit(),
+	}
+}
+
+// spool reads r and returns its contents along with how many bytes were read.
+func (s *spooler) spool(r io.Reader) (io.ReadCloser, int64, error) {
+	s.mu.Lock()
+	reserved := s.remaining
+	s.remaining = 0
+	s.mu.Unlock()
+
+	buf := bufpool.Get()
+	n, err := io.CopyN(buf, r, reserved+1)
+	if errors.Is(err, io.EOF) {
+		s.release(reserved - n)
+		return bufpool.NewReadCloser(buf), n, nil
+	}
+	s.release(reserved)
+	if err != nil {
+		bufpool.Put(buf)
+		return nil, n, err
+	}
+
+	// The file is larger than what's left in memory
+	fd, err := os.CreateTemp(s.dir, "achgateway-download-*")
+	if err != nil {
+		bufpool.Put(buf)
+		return nil, n, fmt.Errorf("creating temp file: %v", err)
+	}
+	spooled := &spooledFile{fd: fd}
+
+	// Remove the file right away where the OS allows it, so it's never left behind
+	if os.Remove(fd.Name()) == nil {
+		spooled.removed = true
+	}
+
+	n, err = io.Copy(fd, io.MultiReader(buf, r))
+	bufpool.Put(buf)
+	if err != nil {
+		spooled.Close()
+		return nil, n, err
+	}
+	if _, err := fd.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return nil, n, fmt.Errorf("rewinding temp file: %v", err)
+	}
+	return spooled, n, nil
+}
+
+func (s *spooler) release(n int64) {
+	s.mu.Lock()
+	s.remaining += n
+	s.mu.Unlock()
+}
+
+// spooledFile is a downloaded file written to disk, which is removed once it's closed
+type spooledFile struct {
+	mu      sync.Mutex
+	fd      *os.File
+	removed bool
+	closed  bool
+}
+
+func (f *spooledFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, io.EOF
+	}
+	return f.fd.Read(p)
+}
+
+func (f *spooledFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	err := f.fd.Close()
+	if !f.removed {
+		if rerr := os.Remove(f.fd.Name()); rerr != nil && !os.IsNotExist(rerr) && err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpooler(t *testing.T) {
+	dir := t.TempDir()
+	spool := newSpooler(&service.Downloads{MaxInMemorySize: 10, TempDir: dir})
+
+	// Small files are held in memory until the limit is used
+	small, n, err := spool.spool(strings.NewReader("hello"))
+	require.NoError(t, err)
+	require.Equal(t, int64(5), n)
+	require.False(t, spooled(small))
+	require.Equal(t, int64(5), spool.remaining)
+
+	large, n, err := spool.spool(strings.NewReader("hello, world"))
+	require.NoError(t, err)
+	require.Equal(t, int64(12), n)
+	require.True(t, spooled(large))
+
+	// An oversized file doesn't use up what's left in memory
+	exact, _, err := spool.spool(strings.NewReader("12345"))
+	require.NoError(t, err)
+	require.False(t, spooled(exact))
+	require.Equal(t, int64(0), spool.remaining)
+
+	next, _, err := spool.spool(strings.NewReader("a"))
+	require.NoError(t, err)
+	require.True(t, spooled(next))
+
+	for contents, expected := range map[io.ReadCloser]string{small: "hello", large: "hello, world", exact: "12345", next: "a"} {
+		bs, err := io.ReadAll(contents)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(bs))
+		require.NoError(t, contents.Close())
+		require.NoError(t, contents.Close())
+	}
+
+	// Temp files are gone once closed
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestSpooler__Disk(t *testing.T) {
+	spool := newSpooler(&service.Downloads{MaxInMemorySize: -1, TempDir: t.TempDir()})
+
+	contents, n, err := spool.spool(strings.NewReader(""))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+	require.False(t, spooled(contents))
+
+	contents, _, err = spool.spool(strings.NewReader("x"))
+	require.NoError(t, err)
+	require.True(t, spooled(contents))
+	require.NoError(t, contents.Close())
+
+	read, err := contents.Read(make([]byte, 1))
+	require.Equal(t, 0, read)
+	require.ErrorIs(t, err, io.EOF)
+
+	spool = newSpooler(&service.Downloads{MaxInMemorySize: -1, TempDir: filepath.Join(t.TempDir(), "missing")})
+	_, _, err = spool.spool(strings.NewReader("x"))
+	require.ErrorContains(t, err, "creating temp file")
+}
+
+func TestFilesystem__SpooledDownloads(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "inbound"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "inbound", "returns.ach"), []byte(strings.Repeat("1", 100)), 0600))
+
+	local := localDirectory{root: dir, perm: 0600, downloads: &service.Downloads{MaxInMemorySize: 10, TempDir: t.TempDir()}}
+	files, _, err := local.readFiles("inbound", nil, 0)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.True(t, spooled(files[0].Contents))
+
+	bs, err := io.ReadAll(files[0].Contents)
+	require.NoError(t, err)
+	require.Len(t, bs, 100)
+	require.NoError(t, files[0].Close())
+}
+
+func spooled(contents io.ReadCloser) bool {
+	_, ok := contents.(*spooledFile)
+	return ok
+}
@@ -0,0 +1,93 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// throttle limits the bytes per second transferred by an agent. Every upload and download
+// of the agent shares the same limit. A nil throttle doesn't limit anything.
+type throttle struct {
+	rate  int64 // bytes per second
+	sleep func(time.Duration)
+
+	mu   sync.Mutex
+	next time.Time // when the bytes transferred so far are allowed
+}
+
+func newThrottle(bytesPerSecond int64) *throttle {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &throttle{
+		rate:  bytesPerSecond,
+		sleep: time.Sleep,
+	}
+}
+
+// reader limits the rate data is read from r
+func (t *throttle) reader(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &throttledReader{r: r, throttle: t}
+}
+
+// chunk is the most read at once, so transfers sharing the limit take turns every ~100ms
+func (t *throttle) chunk() int {
+	if size := t.rate / 10; size > 512 {
+		return int(size)
+	}
+	return 512
+}
+
+// wait blocks until n more bytes are allowed
+func (t *throttle) wait(n int) {
+	if n <= 0 {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(time.Duration(int64(n) * int64(time.Second) / t.rate))
+	delay := t.next.Sub(now)
+	t.mu.Unlock()
+
+	if delay > 0 {
+		t.sleep(delay)
+	}
+}
+
+type throttledReader struct {
+	r        io.Reader
+	throttle *throttle
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if size := tr.throttle.chunk(); len(p) > size {
+		p = p[:size]
+	}
+	n, err := tr.r.Read(p)
+	tr.throttle.wait(n)
+	return n, err
+}
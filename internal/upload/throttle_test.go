@@ -0,0 +1,55 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottle(t *testing.T) {
+	var th *throttle
+	r := strings.NewReader("contents")
+	require.Equal(t, r, th.reader(r))
+	require.Nil(t, newThrottle(0))
+
+	th = newThrottle(10000)
+
+	var slept time.Duration
+	th.sleep = func(d time.Duration) {
+		slept = d // the latest delay includes every earlier read
+	}
+
+	// Reads are split into chunks to share the limit
+	n, err := th.reader(bytes.NewReader(make([]byte, 5000))).Read(make([]byte, 5000))
+	require.NoError(t, err)
+	require.Equal(t, 1000, n)
+
+	// Readers of an agent share its limit
+	_, err = io.Copy(io.Discard, th.reader(bytes.NewReader(make([]byte, 10000))))
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, th.reader(bytes.NewReader(make([]byte, 9000))))
+	require.NoError(t, err)
+
+	require.InDelta(t, 2*time.Second, slept, float64(100*time.Millisecond))
+}
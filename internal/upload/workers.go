@@ -0,0 +1,92 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"sync"
+)
+
+// downloadEach calls download for files 0 through n-1 with up to workers running at once. Each
+// call is given the number of its worker, from zero, so workers can hold their own connection.
+// No more downloads are started after one fails and the first error is returned.
+func downloadEach(n, workers int, download func(worker, i int) error) error {
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := download(0, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := range jobs {
+				if err := download(worker, i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}(w)
+	}
+	for i := 0; i < n && !failed(); i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return firstErr
+}
+
+// collectDownloads returns the files which were downloaded, in the order they were listed
+func collectDownloads(downloaded []*File) []File {
+	var files []File
+	for i := range downloaded {
+		if downloaded[i] != nil {
+			files = append(files, *downloaded[i])
+		}
+	}
+	return files
+}
+
+// closeFiles releases the contents of files which won't be returned
+func closeFiles(files []File) {
+	for i := range files {
+		files[i].Close()
+	}
+}
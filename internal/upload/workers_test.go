@@ -0,0 +1,102 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadEach(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+	workers := make(map[int]bool)
+	var running, most int32
+
+	err := downloadEach(20, 4, func(worker, i int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			prev := atomic.LoadInt32(&most)
+			if n <= prev || atomic.CompareAndSwapInt32(&most, prev, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		seen[i] = true
+		workers[worker] = true
+		mu.Unlock()
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, seen, 20)
+	require.LessOrEqual(t, int(most), 4)
+	for worker := range workers {
+		require.True(t, worker >= 0 && worker < 4)
+	}
+}
+
+func TestDownloadEach__Sequential(t *testing.T) {
+	var order []int
+	err := downloadEach(3, 1, func(worker, i int) error {
+		require.Equal(t, 0, worker)
+		order = append(order, i)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2}, order)
+
+	// Workers beyond the number of files aren't started
+	err = downloadEach(1, 8, func(worker, i int) error {
+		require.Equal(t, 0, worker)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestDownloadEach__Error(t *testing.T) {
+	var calls int32
+	err := downloadEach(100, 2, func(worker, i int) error {
+		atomic.AddInt32(&calls, 1)
+		if i == 3 {
+			return errors.New("bad file")
+		}
+		return nil
+	})
+	require.ErrorContains(t, err, "bad file")
+	require.Less(t, int(atomic.LoadInt32(&calls)), 100)
+
+	err = downloadEach(5, 1, func(worker, i int) error {
+		return errors.New("first")
+	})
+	require.ErrorContains(t, err, "first")
+}
+
+func TestCollectDownloads(t *testing.T) {
+	files := collectDownloads([]*File{{Filename: "a.ach"}, nil, {Filename: "c.ach"}})
+	require.Len(t, files, 2)
+	require.Equal(t, "a.ach", files[0].Filename)
+	require.Equal(t, "c.ach", files[1].Filename)
+}
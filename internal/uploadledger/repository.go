@@ -0,0 +1,175 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package uploadledger records each merged file before it's uploaded so the same file is never
+// uploaded to the ODFI twice, even when an instance crashes or another instance takes over.
+package uploadledger
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/moov-io/base/database"
+)
+
+const (
+	// StatusPending is recorded before a file is uploaded. Files left pending were lost part way
+	// through an upload and could already be at the ODFI.
+	StatusPending = "pending"
+
+	// StatusUploaded is recorded once the upload agent accepted the file
+	StatusUploaded = "uploaded"
+)
+
+// Entry is a merged file's upload to an ODFI
+type Entry struct {
+	ShardName  string     `json:"shardName"`
+	SHA256     string     `json:"sha256"`
+	Window     string     `json:"window"`
+	Filename   string     `json:"filename"`
+	Holder     string     `json:"holder"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UploadedAt *time.Time `json:"uploadedAt,omitempty"`
+}
+
+type Repository interface {
+	// Begin records the intent to upload entry. When the file was recorded before the earlier
+	// Entry is returned instead and nothing is changed.
+	Begin(entry Entry) (*Entry, error)
+
+	// Confirm marks a file as uploaded
+	Confirm(shardName, sha256 string) error
+
+	// Abandon removes a pending file so it's uploaded again, such as after the upload failed
+	Abandon(shardName, sha256 string) error
+
+	// List returns the shard's most recent files
+	List(shardName string, limit int) ([]Entry, error)
+}
+
+// NewRepository returns a Repository stored in db, or nil when db is nil
+func NewRepository(db *sql.DB) Repository {
+	if db == nil {
+		return nil
+	}
+	return &sqlRepository{db: db, now: time.Now}
+}
+
+type sqlRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func (r *sqlRepository) Begin(entry Entry) (*Entry, error) {
+	if entry.ShardName == "" || entry.SHA256 == "" {
+		return nil, errors.New("missing ShardName or SHA256")
+	}
+	query := `insert into upload_ledger (shard_name, sha256, cutoff_window, filename, holder, status, created_at) values (?, ?, ?, ?, ?, ?, ?);`
+	_, err := r.db.Exec(query, entry.ShardName, entry.SHA256, entry.Window, entry.Filename, entry.Holder, StatusPending, r.timestamp())
+	if err == nil {
+		return nil, nil
+	}
+	if !database.UniqueViolation(err) {
+		return nil, fmt.Errorf("recording upload of %s: %v", entry.Filename, err)
+	}
+
+	prior, err := r.find(entry.ShardName, entry.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	if prior == nil {
+		// Abandoned between our insert and read, so try again
+		return r.Begin(entry)
+	}
+	return prior, nil
+}
+
+func (r *sqlRepository) Confirm(shardName, sha256 string) error {
+	query := `update upload_ledger set status = ?, uploaded_at = ? where shard_name = ? and sha256 = ?;`
+	_, err := r.db.Exec(query, StatusUploaded, r.timestamp(), shardName, sha256)
+	if err != nil {
+		return fmt.Errorf("confirming upload of %s: %v", sha256, err)
+	}
+	return nil
+}
+
+func (r *sqlRepository) Abandon(shardName, sha256 string) error {
+	query := `delete from upload_ledger where shard_name = ? and sha256 = ? and status = ?;`
+	_, err := r.db.Exec(query, shardName, sha256, StatusPending)
+	if err != nil {
+		return fmt.Errorf("abandoning upload of %s: %v", sha256, err)
+	}
+	return nil
+}
+
+const entryColumns = `shard_name, sha256, cutoff_window, filename, holder, status, created_at, uploaded_at`
+
+func (r *sqlRepository) find(shardName, sha256 string) (*Entry, error) {
+	query := `select ` + entryColumns + ` from upload_ledger where shard_name = ? and sha256 = ? limit 1;`
+	entry, err := scanEntry(r.db.QueryRow(query, shardName, sha256))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading upload of %s: %v", sha256, err)
+	}
+	return entry, nil
+}
+
+func (r *sqlRepository) List(shardName string, limit int) ([]Entry, error) {
+	query := `select ` + entryColumns + ` from upload_ledger where shard_name = ? order by created_at desc limit ?;`
+	rows, err := r.db.Query(query, shardName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing uploads: %v", err)
+	}
+	defer rows.Close()
+
+	var out []Entry
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listing uploads: %v", err)
+		}
+		out = append(out, *entry)
+	}
+	return out, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEntry(row scanner) (*Entry, error) {
+	var entry Entry
+	var uploadedAt sql.NullTime
+	err := row.Scan(&entry.ShardName, &entry.SHA256, &entry.Window, &entry.Filename, &entry.Holder, &entry.Status, &entry.CreatedAt, &uploadedAt)
+	if err != nil {
+		return nil, err
+	}
+	if uploadedAt.Valid {
+		entry.UploadedAt = &uploadedAt.Time
+	}
+	return &entry, nil
+}
+
+// timestamp is the current time in UTC with the precision DATETIME columns store
+func (r *sqlRepository) timestamp() time.Time {
+	return r.now().UTC().Truncate(time.Second)
+}
@@ -0,0 +1,82 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package uploadledger
+
+import (
+	"testing"
+
+	"github.com/moov-io/base/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository__Nil(t *testing.T) {
+	require.Nil(t, NewRepository(nil))
+}
+
+func TestRepository(t *testing.T) {
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	repo := NewRepository(db.DB)
+
+	entry := Entry{
+		ShardName: "live",
+		SHA256:    "8c4f1a",
+		Window:    "16:20",
+		Filename:  "20220601-1620-231380104.ach",
+		Holder:    "achgateway-0",
+	}
+	prior, err := repo.Begin(entry)
+	require.NoError(t, err)
+	require.Nil(t, prior)
+
+	// Recording the file again returns the pending upload
+	entry.Holder = "achgateway-1"
+	prior, err = repo.Begin(entry)
+	require.NoError(t, err)
+	require.NotNil(t, prior)
+	require.Equal(t, StatusPending, prior.Status)
+	require.Equal(t, "achgateway-0", prior.Holder)
+	require.Nil(t, prior.UploadedAt)
+
+	// Abandoned files can be recorded again
+	require.NoError(t, repo.Abandon("live", "8c4f1a"))
+	prior, err = repo.Begin(entry)
+	require.NoError(t, err)
+	require.Nil(t, prior)
+
+	require.NoError(t, repo.Confirm("live", "8c4f1a"))
+	prior, err = repo.Begin(entry)
+	require.NoError(t, err)
+	require.Equal(t, StatusUploaded, prior.Status)
+	require.NotNil(t, prior.UploadedAt)
+
+	// Uploaded files are never abandoned
+	require.NoError(t, repo.Abandon("live", "8c4f1a"))
+
+	entries, err := repo.List("live", 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "achgateway-1", entries[0].Holder)
+	require.Equal(t, StatusUploaded, entries[0].Status)
+
+	entries, err = repo.List("other", 10)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
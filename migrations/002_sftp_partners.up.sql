@@ -0,0 +1,5 @@
+CREATE TABLE sftp_partners(
+       username VARCHAR(50) PRIMARY KEY,
+       shard_key VARCHAR(50) NOT NULL,
+       public_key TEXT NOT NULL
+);
@@ -0,0 +1,10 @@
+CREATE TABLE leadership_locks(
+       lock_key VARCHAR(200) PRIMARY KEY,
+       holder VARCHAR(200) NOT NULL,
+       expires_at DATETIME NOT NULL
+);
+
+CREATE TABLE leadership_members(
+       identity VARCHAR(200) PRIMARY KEY,
+       expires_at DATETIME NOT NULL
+);
@@ -0,0 +1,11 @@
+CREATE TABLE upload_ledger(
+       shard_name VARCHAR(100) NOT NULL,
+       sha256 VARCHAR(64) NOT NULL,
+       cutoff_window VARCHAR(40) NOT NULL,
+       filename VARCHAR(200) NOT NULL,
+       holder VARCHAR(200) NOT NULL,
+       status VARCHAR(20) NOT NULL,
+       created_at DATETIME NOT NULL,
+       uploaded_at DATETIME,
+       PRIMARY KEY (shard_name, sha256)
+);
@@ -0,0 +1,4 @@
+CREATE TABLE event_sequences(
+       shard_key VARCHAR(100) PRIMARY KEY,
+       sequence BIGINT NOT NULL
+);
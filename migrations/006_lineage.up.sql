@@ -0,0 +1,24 @@
+CREATE TABLE assignment_counters(
+       counter_key VARCHAR(100) PRIMARY KEY,
+       counter_value BIGINT NOT NULL
+);
+
+CREATE TABLE trace_number_lineage(
+       shard_name VARCHAR(100) NOT NULL,
+       file_id VARCHAR(100) NOT NULL,
+       original_trace_number VARCHAR(15) NOT NULL,
+       assigned_trace_number VARCHAR(15) NOT NULL,
+       created_at DATETIME NOT NULL
+);
+
+CREATE INDEX trace_number_lineage_file_idx ON trace_number_lineage (shard_name, file_id);
+CREATE INDEX trace_number_lineage_assigned_idx ON trace_number_lineage (shard_name, assigned_trace_number);
+
+CREATE TABLE file_id_lineage(
+       shard_name VARCHAR(100) NOT NULL,
+       sha256 VARCHAR(64) NOT NULL,
+       original_file_id_modifier VARCHAR(1) NOT NULL,
+       assigned_file_id_modifier VARCHAR(1) NOT NULL,
+       created_at DATETIME NOT NULL,
+       PRIMARY KEY (shard_name, sha256)
+);
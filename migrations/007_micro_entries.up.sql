@@ -0,0 +1,13 @@
+CREATE TABLE micro_entries(
+       micro_entry_id VARCHAR(15) PRIMARY KEY,
+       shard_key VARCHAR(100) NOT NULL,
+       credit_amount1 INTEGER NOT NULL,
+       credit_amount2 INTEGER NOT NULL,
+       status VARCHAR(20) NOT NULL,
+       attempts INTEGER NOT NULL,
+       return_code VARCHAR(3),
+       created_at DATETIME NOT NULL,
+       updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX micro_entries_shard_key_idx ON micro_entries (shard_key);
@@ -0,0 +1,11 @@
+CREATE TABLE odfi_activity(
+       odfi VARCHAR(9) NOT NULL,
+       activity_date VARCHAR(10) NOT NULL,
+       shard_name VARCHAR(100) NOT NULL,
+       kind VARCHAR(20) NOT NULL,
+       debits BIGINT NOT NULL,
+       credits BIGINT NOT NULL,
+       created_at DATETIME NOT NULL
+);
+
+CREATE INDEX odfi_activity_date_idx ON odfi_activity (activity_date, odfi);
@@ -0,0 +1,22 @@
+CREATE TABLE odfi_deliveries(
+       delivery_id VARCHAR(100) PRIMARY KEY,
+       total INTEGER NOT NULL,
+       status VARCHAR(20) NOT NULL,
+       first_received_at DATETIME NOT NULL,
+       updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX odfi_deliveries_status_idx ON odfi_deliveries (status, first_received_at);
+
+CREATE TABLE odfi_delivery_files(
+       delivery_id VARCHAR(100) NOT NULL,
+       sequence INTEGER NOT NULL,
+       filename VARCHAR(255) NOT NULL,
+       entries INTEGER NOT NULL,
+       returns INTEGER NOT NULL,
+       corrections INTEGER NOT NULL,
+       debit_total BIGINT NOT NULL,
+       credit_total BIGINT NOT NULL,
+       received_at DATETIME NOT NULL,
+       PRIMARY KEY (delivery_id, sequence)
+);
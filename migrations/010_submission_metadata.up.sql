@@ -0,0 +1,16 @@
+CREATE TABLE submission_metadata(
+       shard_name VARCHAR(100) NOT NULL,
+       file_id VARCHAR(100) NOT NULL,
+       shard_key VARCHAR(100) NOT NULL,
+       metadata TEXT NOT NULL,
+       created_at DATETIME NOT NULL,
+       PRIMARY KEY (shard_name, file_id)
+);
+
+CREATE TABLE submission_trace_numbers(
+       shard_name VARCHAR(100) NOT NULL,
+       file_id VARCHAR(100) NOT NULL,
+       trace_number VARCHAR(15) NOT NULL
+);
+
+CREATE INDEX submission_trace_numbers_trace_number_idx ON submission_trace_numbers (trace_number);
@@ -0,0 +1,7 @@
+CREATE TABLE signing_keys(
+       key_id VARCHAR(100) PRIMARY KEY,
+       secret VARCHAR(100) NOT NULL,
+       created_at DATETIME NOT NULL,
+       expires_at DATETIME,
+       revoked_at DATETIME
+);
@@ -0,0 +1,13 @@
+CREATE TABLE staged_files(
+       shard_key VARCHAR(100) NOT NULL,
+       file_id VARCHAR(100) NOT NULL,
+       contents MEDIUMTEXT NOT NULL,
+       summary TEXT NOT NULL,
+       metadata TEXT NOT NULL,
+       created_at DATETIME NOT NULL,
+       expires_at DATETIME NOT NULL,
+       committed_at DATETIME,
+       PRIMARY KEY (shard_key, file_id)
+);
+
+CREATE INDEX staged_files_expires_at_idx ON staged_files (expires_at);
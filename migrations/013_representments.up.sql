@@ -0,0 +1,16 @@
+CREATE TABLE representments(
+       representment_id VARCHAR(100) PRIMARY KEY,
+       shard_key VARCHAR(100) NOT NULL,
+       original_trace_number VARCHAR(15) NOT NULL,
+       return_code VARCHAR(3) NOT NULL,
+       attempt INTEGER NOT NULL,
+       status VARCHAR(20) NOT NULL,
+       contents MEDIUMTEXT NOT NULL,
+       metadata TEXT NOT NULL,
+       scheduled_for DATETIME NOT NULL,
+       submitted_at DATETIME,
+       created_at DATETIME NOT NULL
+);
+
+CREATE UNIQUE INDEX representments_attempt_idx ON representments (shard_key, original_trace_number, attempt);
+CREATE INDEX representments_status_idx ON representments (status, scheduled_for);
@@ -0,0 +1,16 @@
+CREATE TABLE rejected_files(
+       rejection_id VARCHAR(100) PRIMARY KEY,
+       file_id VARCHAR(100) NOT NULL,
+       shard_key VARCHAR(100) NOT NULL,
+       shard_name VARCHAR(100) NOT NULL,
+       status VARCHAR(20) NOT NULL,
+       reasons TEXT NOT NULL,
+       metadata TEXT NOT NULL,
+       contents MEDIUMTEXT NOT NULL,
+       validate_opts TEXT NOT NULL,
+       discard_reason TEXT NOT NULL,
+       created_at DATETIME NOT NULL,
+       resolved_at DATETIME
+);
+
+CREATE INDEX rejected_files_status_idx ON rejected_files (status, created_at);
@@ -0,0 +1,206 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/moov-io/base"
+)
+
+// CutoffRequest picks the shards of a manually triggered cutoff
+type CutoffRequest struct {
+	// ShardNames to trigger, or every shard when empty
+	ShardNames []string `json:"shardNames"`
+
+	// OverrideGuardrails uploads files held by guardrails and skips guardrail checks on files
+	// merged during this cutoff
+	OverrideGuardrails bool `json:"overrideGuardrails"`
+}
+
+// CutoffResponse has the outcome of a manually triggered cutoff
+type CutoffResponse struct {
+	// Shards has the error of each triggered shard, which is nil on success
+	Shards map[string]*string `json:"shards"`
+
+	// AwaitingApproval is set when a second operator needs to approve the cutoff
+	AwaitingApproval bool `json:"-"`
+}
+
+// Err combines the errors of every shard which failed, or returns nil
+func (r *CutoffResponse) Err() error {
+	if r == nil {
+		return nil
+	}
+	var el base.ErrorList
+	for name, err := range r.Shards {
+		if err != nil {
+			el.Add(fmt.Errorf("%s: %s", name, *err))
+		}
+	}
+	if el.Empty() {
+		return nil
+	}
+	sort.Slice(el, func(i, j int) bool { return el[i].Error() < el[j].Error() })
+	return el
+}
+
+// TriggerCutoff merges and uploads pending files of the shards in req, waiting until they're
+// uploaded. Shards which failed are returned in the response along with an error.
+func (c *Client) TriggerCutoff(ctx context.Context, req CutoffRequest) (*CutoffResponse, error) {
+	bs, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encoding cutoff request: %v", err)
+	}
+	var resp CutoffResponse
+	status, err := c.do(ctx, request{
+		admin:  true,
+		method: http.MethodPut,
+		path:   "/trigger-cutoff",
+		body:   bs,
+	}, &resp)
+	if err != nil {
+		// Shard errors respond with 400 and the errors of each shard
+		if e, ok := err.(*Error); ok && e.StatusCode == http.StatusBadRequest {
+			if json.Unmarshal([]byte(e.Message), &resp) == nil && len(resp.Shards) > 0 {
+				return &resp, resp.Err()
+			}
+		}
+		return nil, err
+	}
+	if status == http.StatusAccepted {
+		resp.Shards = nil
+		resp.AwaitingApproval = true
+	}
+	return &resp, nil
+}
+
+// TriggerInbound downloads and processes ODFI files, waiting until they're processed
+func (c *Client) TriggerInbound(ctx context.Context) error {
+	_, err := c.do(ctx, request{
+		admin:  true,
+		method: http.MethodPut,
+		path:   "/trigger-inbound",
+	}, nil)
+	return err
+}
+
+// ListShards returns the names of the shards an instance aggregates files for
+func (c *Client) ListShards(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Shards []struct {
+			Name string `json:"name"`
+		} `json:"shards"`
+	}
+	_, err := c.do(ctx, request{
+		admin:  true,
+		method: http.MethodGet,
+		path:   "/shards",
+		retry:  true,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(resp.Shards))
+	for i := range resp.Shards {
+		out = append(out, resp.Shards[i].Name)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// PendingFile is a file waiting for its shard's next cutoff
+type PendingFile struct {
+	Filename string
+	Path     string
+	ModTime  time.Time
+}
+
+// ListPendingFiles returns the files of a shard waiting for its next cutoff on the instance
+// which responds. SourceHostname is that instance.
+func (c *Client) ListPendingFiles(ctx context.Context, shardName string) (files []PendingFile, sourceHostname string, err error) {
+	var resp struct {
+		Files          []PendingFile `json:"files"`
+		SourceHostname string
+	}
+	_, err = c.do(ctx, request{
+		admin:  true,
+		method: http.MethodGet,
+		path:   fmt.Sprintf("/shards/%s/files", url.PathEscape(shardName)),
+		retry:  true,
+	}, &resp)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Files, resp.SourceHostname, nil
+}
+
+// PendingFileContents is a pending file in Nacha format
+type PendingFileContents struct {
+	Filename       string
+	Contents       []byte
+	ModTime        time.Time
+	SourceHostname string
+}
+
+// GetPendingFile returns a file listed by ListPendingFiles. path is relative to the shard,
+// which is the file's Filename.
+func (c *Client) GetPendingFile(ctx context.Context, shardName, path string) (*PendingFileContents, error) {
+	var resp struct {
+		Filename       string
+		ContentsBase64 string
+		ModTime        time.Time
+		SourceHostname string
+	}
+	_, err := c.do(ctx, request{
+		admin:  true,
+		method: http.MethodGet,
+		path:   fmt.Sprintf("/shards/%s/files/%s", url.PathEscape(shardName), escapePath(path)),
+		retry:  true,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := base64.StdEncoding.DecodeString(resp.ContentsBase64)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", path, err)
+	}
+	return &PendingFileContents{
+		Filename:       resp.Filename,
+		Contents:       contents,
+		ModTime:        resp.ModTime,
+		SourceHostname: resp.SourceHostname,
+	}, nil
+}
+
+// escapePath escapes each segment of path
+func escapePath(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
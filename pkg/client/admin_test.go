@@ -0,0 +1,99 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient__TriggerCutoff(t *testing.T) {
+	var body CutoffRequest
+	c := testClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/trigger-cutoff", r.URL.Path)
+		require.Equal(t, http.MethodPut, r.Method)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		switch {
+		case body.OverrideGuardrails:
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"approvalID":"a1"}`))
+		case len(body.ShardNames) > 1:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"shards":{"live":null,"testing":"upload failed"}}`))
+		default:
+			w.Write([]byte(`{"shards":{"live":null}}`))
+		}
+	}))
+	ctx := context.Background()
+
+	resp, err := c.TriggerCutoff(ctx, CutoffRequest{ShardNames: []string{"live"}})
+	require.NoError(t, err)
+	require.Contains(t, resp.Shards, "live")
+	require.Nil(t, resp.Shards["live"])
+	require.False(t, resp.AwaitingApproval)
+
+	resp, err = c.TriggerCutoff(ctx, CutoffRequest{ShardNames: []string{"live", "testing"}})
+	require.EqualError(t, err, "testing: upload failed")
+	require.Equal(t, "upload failed", *resp.Shards["testing"])
+
+	resp, err = c.TriggerCutoff(ctx, CutoffRequest{OverrideGuardrails: true})
+	require.NoError(t, err)
+	require.True(t, resp.AwaitingApproval)
+	require.True(t, body.OverrideGuardrails)
+}
+
+func TestClient__PendingFiles(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/shards", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"shards":[{"name":"testing"},{"name":"live"}]}`))
+	})
+	mux.HandleFunc("/shards/live/files", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"files":[{"Filename":"f1.ach","Path":"mergable/live/f1.ach","ModTime":"2022-06-01T14:00:00Z"}],"SourceHostname":"achgateway-0"}`))
+	})
+	mux.HandleFunc("/shards/live/files/f1.ach", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Filename":"f1.ach","ContentsBase64":"MTAx","Valid":{},"ModTime":"2022-06-01T14:00:00Z","SourceHostname":"achgateway-0"}`))
+	})
+	c := testClient(t, mux)
+	ctx := context.Background()
+
+	shards, err := c.ListShards(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"live", "testing"}, shards)
+
+	files, hostname, err := c.ListPendingFiles(ctx, "live")
+	require.NoError(t, err)
+	require.Equal(t, "achgateway-0", hostname)
+	require.Len(t, files, 1)
+	require.Equal(t, "f1.ach", files[0].Filename)
+	require.Equal(t, 2022, files[0].ModTime.Year())
+
+	file, err := c.GetPendingFile(ctx, "live", files[0].Filename)
+	require.NoError(t, err)
+	require.Equal(t, "101", string(file.Contents))
+	require.Equal(t, "achgateway-0", file.SourceHostname)
+
+	_, err = c.GetPendingFile(ctx, "live", "missing.ach")
+	require.True(t, IsNotFound(err))
+
+	require.Equal(t, "a%20b/c.ach", escapePath("/a b/c.ach"))
+}
@@ -0,0 +1,296 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package client is a Go client for ACHGateway's HTTP API and admin routes.
+//
+// Files are submitted under a fileID chosen by the caller, which makes submissions idempotent:
+// submitting or canceling a file again with the same fileID replaces the pending file rather than
+// adding another one. Requests which are safe to repeat are retried on connection errors, 429 and
+// 5xx responses, waiting as long as a Retry-After header asks (like while an instance drains).
+// Admin triggers are never retried.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/moov-io/achgateway/pkg/compliance"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// Version of the client, which is sent in the User-Agent of each request. It changes when the
+// client's API does.
+const Version = "v1"
+
+// Config of a Client. BaseURL and AdminURL can be left empty when a Client only calls the
+// other server's routes.
+type Config struct {
+	// BaseURL is the address of the HTTP API, like http://achgateway:8484
+	BaseURL string
+
+	// AdminURL is the address of the admin server, like http://achgateway:9494
+	AdminURL string
+
+	// HTTPClient sends requests, which defaults to http.DefaultClient. Triggers wait until the
+	// cutoff or inbound processing finishes, so set deadlines of each call on its context.
+	HTTPClient *http.Client
+
+	// Headers are added to every request, such as credentials for approvals
+	Headers http.Header
+
+	// MaxRetries of requests which are safe to repeat. Zero uses the default of 3 and
+	// negative values disable retries.
+	MaxRetries int
+
+	// RetryWaitMin and RetryWaitMax bound the exponential backoff between retries
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+
+	// Transform protects request bodies like Inbound.HTTP.Transform expects
+	Transform *models.TransformConfig
+}
+
+func (cfg Config) maxRetries() int {
+	switch {
+	case cfg.MaxRetries < 0:
+		return 0
+	case cfg.MaxRetries == 0:
+		return 3
+	}
+	return cfg.MaxRetries
+}
+
+func (cfg Config) retryWaitMin() time.Duration {
+	if cfg.RetryWaitMin > 0 {
+		return cfg.RetryWaitMin
+	}
+	return 250 * time.Millisecond
+}
+
+func (cfg Config) retryWaitMax() time.Duration {
+	if cfg.RetryWaitMax > 0 {
+		return cfg.RetryWaitMax
+	}
+	return 10 * time.Second
+}
+
+type Client struct {
+	cfg Config
+
+	baseURL  *url.URL
+	adminURL *url.URL
+
+	// retrying sends requests which are safe to repeat, once only sends the others
+	retrying *retryablehttp.Client
+	once     *retryablehttp.Client
+}
+
+// New returns a Client of the ACHGateway instances at cfg's addresses
+func New(cfg Config) (*Client, error) {
+	var err error
+	c := &Client{cfg: cfg}
+	if c.baseURL, err = parseURL(cfg.BaseURL); err != nil {
+		return nil, fmt.Errorf("BaseURL: %v", err)
+	}
+	if c.adminURL, err = parseURL(cfg.AdminURL); err != nil {
+		return nil, fmt.Errorf("AdminURL: %v", err)
+	}
+	if c.baseURL == nil && c.adminURL == nil {
+		return nil, errors.New("missing BaseURL and AdminURL")
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	newClient := func(retries int) *retryablehttp.Client {
+		cc := retryablehttp.NewClient()
+		cc.HTTPClient = httpClient
+		cc.Logger = nil
+		cc.RetryMax = retries
+		cc.RetryWaitMin = cfg.retryWaitMin()
+		cc.RetryWaitMax = cfg.retryWaitMax()
+		cc.ErrorHandler = retryablehttp.PassthroughErrorHandler
+		return cc
+	}
+	c.retrying = newClient(cfg.maxRetries())
+	c.once = newClient(0)
+
+	return c, nil
+}
+
+func parseURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(strings.TrimSuffix(raw, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute URL", raw)
+	}
+	return u, nil
+}
+
+// NewFileID returns a random fileID for submitting a file. Keep the fileID to retry a submission
+// or cancel the file later.
+func NewFileID() string {
+	return base.ID()
+}
+
+// Error is a response from ACHGateway with an unsuccessful status code
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+
+	// Message is the error ACHGateway responded with, if any
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%s %s: %d %s: %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound reports if err is a 404 response, like when the route isn't enabled
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports if err is a 409 response, like a file submitted after its shard's
+// last cutoff of the day or a staged file which was already committed
+func IsConflict(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusConflict
+}
+
+type request struct {
+	admin  bool
+	method string
+	path   string
+	query  url.Values
+
+	header http.Header
+	body   []byte
+
+	// retry is set on requests which are safe to repeat
+	retry bool
+}
+
+// do sends req and decodes the JSON response into out when it isn't nil. The status code of
+// successful responses is returned.
+func (c *Client) do(ctx context.Context, req request, out interface{}) (int, error) {
+	address := c.baseURL
+	if req.admin {
+		address = c.adminURL
+	}
+	if address == nil {
+		if req.admin {
+			return 0, errors.New("missing AdminURL")
+		}
+		return 0, errors.New("missing BaseURL")
+	}
+	// Paths are escaped by the caller
+	endpoint := address.String() + req.path
+	if len(req.query) > 0 {
+		endpoint += "?" + req.query.Encode()
+	}
+
+	var body interface{}
+	if req.body != nil {
+		body = req.body
+	}
+	r, err := retryablehttp.NewRequest(req.method, endpoint, body)
+	if err != nil {
+		return 0, fmt.Errorf("preparing %s %s: %v", req.method, req.path, err)
+	}
+	r = r.WithContext(ctx)
+	for key, values := range c.cfg.Headers {
+		r.Header[key] = values
+	}
+	for key, values := range req.header {
+		r.Header[key] = values
+	}
+	r.Header.Set("User-Agent", fmt.Sprintf("achgateway-client/%s", Version))
+
+	cc := c.once
+	if req.retry {
+		cc = c.retrying
+	}
+	resp, err := cc.Do(r)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s: %w", req.method, req.path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, readError(req, resp)
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+			return resp.StatusCode, fmt.Errorf("reading %s %s response: %v", req.method, req.path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// readError reads the message of an unsuccessful response, which is either JSON with an
+// error field or plain text
+func readError(req request, resp *http.Response) error {
+	e := &Error{
+		Method:     req.method,
+		Path:       req.path,
+		StatusCode: resp.StatusCode,
+	}
+	bs, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	bs = bytes.TrimSpace(bs)
+
+	var problem struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(bs, &problem); err == nil && problem.Error != "" {
+		e.Message = problem.Error
+	} else {
+		e.Message = string(bs)
+	}
+	return e
+}
+
+// protect applies the client's Transform to a request body
+func (c *Client) protect(bs []byte) ([]byte, error) {
+	out, err := compliance.ProtectBytes(c.cfg.Transform, bs)
+	if err != nil {
+		return nil, fmt.Errorf("protecting request body: %v", err)
+	}
+	return out, nil
+}
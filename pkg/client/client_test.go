@@ -0,0 +1,134 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/moov-io/achgateway/pkg/compliance"
+	"github.com/moov-io/achgateway/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c, err := New(Config{
+		BaseURL:      server.URL,
+		AdminURL:     server.URL + "/",
+		RetryWaitMin: time.Millisecond,
+		RetryWaitMax: 5 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	return c
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{})
+	require.ErrorContains(t, err, "missing BaseURL and AdminURL")
+
+	_, err = New(Config{BaseURL: "achgateway:8484"})
+	require.ErrorContains(t, err, "BaseURL")
+
+	c, err := New(Config{AdminURL: "http://achgateway:9494"})
+	require.NoError(t, err)
+	require.ErrorContains(t, c.CancelFile(context.Background(), "s1", "f1"), "missing BaseURL")
+
+	require.NotEqual(t, NewFileID(), NewFileID())
+}
+
+func TestClient__Retries(t *testing.T) {
+	var calls int32
+	c := testClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "achgateway-client/"+Version, r.Header.Get("User-Agent"))
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "server is draining", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	ctx := context.Background()
+
+	require.NoError(t, c.CancelFile(ctx, "s1", "f1"))
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// Triggers are sent once
+	atomic.StoreInt32(&calls, 0)
+	err := c.TriggerInbound(ctx)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	var e *Error
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, http.StatusServiceUnavailable, e.StatusCode)
+	require.Equal(t, "server is draining", e.Message)
+	require.Equal(t, "PUT /trigger-inbound: 503 Service Unavailable: server is draining", err.Error())
+}
+
+func TestClient__Errors(t *testing.T) {
+	c := testClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/shards/s1/files/late":
+			http.Error(w, "shard s1 has no cutoffs left today", http.StatusConflict)
+		case "/trigger-inbound":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"agent: connection refused"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	ctx := context.Background()
+
+	err := c.CancelFile(ctx, "s1", "late")
+	require.True(t, IsConflict(err))
+	require.False(t, IsNotFound(err))
+
+	err = c.TriggerInbound(ctx)
+	require.Equal(t, "PUT /trigger-inbound: 400 Bad Request: agent: connection refused", err.Error())
+
+	require.True(t, IsNotFound(c.CancelFile(ctx, "s2", "f1")))
+}
+
+func TestReadEvent(t *testing.T) {
+	transform := &models.TransformConfig{
+		Encoding: &models.EncodingConfig{Base64: true},
+	}
+	bs, err := compliance.Protect(transform, models.Event{Event: models.FileUploaded{
+		FileID:   "f1",
+		ShardKey: "s1",
+	}})
+	require.NoError(t, err)
+
+	evt, err := ReadEvent(transform, bs)
+	require.NoError(t, err)
+	uploaded, ok := evt.Event.(*models.FileUploaded)
+	require.True(t, ok)
+	require.Equal(t, "f1", uploaded.FileID)
+
+	_, err = ReadEvent(nil, []byte("not json"))
+	require.ErrorContains(t, err, "reading event")
+}
@@ -0,0 +1,41 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package client
+
+import (
+	"fmt"
+
+	"github.com/moov-io/achgateway/pkg/compliance"
+	"github.com/moov-io/achgateway/pkg/models"
+)
+
+// ReadEvent reads an event sent by ACHGateway, such as a message from the events stream or the
+// body of a webhook. Events are revealed with transform first, which should match the sink's
+// Transform config. The Event field is one of the models package's event types, like
+// models.ReturnFile or models.FileUploaded.
+func ReadEvent(transform *models.TransformConfig, data []byte) (*models.Event, error) {
+	bs, err := compliance.Reveal(transform, data)
+	if err != nil {
+		return nil, fmt.Errorf("revealing event: %v", err)
+	}
+	evt, err := models.Read(bs)
+	if err != nil {
+		return nil, fmt.Errorf("reading event: %v", err)
+	}
+	return evt, nil
+}
@@ -0,0 +1,183 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/pkg/models"
+)
+
+// MetadataHeader holds the metadata of a submitted file
+const MetadataHeader = "X-Metadata"
+
+// SubmitOptions are optional settings of a submitted file
+type SubmitOptions struct {
+	// Metadata is echoed back on events about the file
+	Metadata map[string]string
+}
+
+// CreateFile submits file to the shard for its next cutoff. Submitting a file again with the
+// same fileID replaces the pending file, so failed submissions can be retried safely.
+func (c *Client) CreateFile(ctx context.Context, shardKey, fileID string, file *ach.File, opts *SubmitOptions) error {
+	req, err := c.fileRequest(http.MethodPost, fmt.Sprintf("/shards/%s/files/%s", url.PathEscape(shardKey), url.PathEscape(fileID)), file, opts)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(ctx, req, nil)
+	return err
+}
+
+// CancelFile cancels a file submitted with fileID which hasn't been uploaded yet
+func (c *Client) CancelFile(ctx context.Context, shardKey, fileID string) error {
+	_, err := c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   fmt.Sprintf("/shards/%s/files/%s", url.PathEscape(shardKey), url.PathEscape(fileID)),
+		retry:  true,
+	}, nil)
+	return err
+}
+
+// Staged file statuses
+const (
+	StagedFileStaged    = "staged"
+	StagedFileCommitted = "committed"
+	StagedFileExpired   = "expired"
+)
+
+// StagedFile is a file kept by ACHGateway until it's committed, which needs
+// Inbound.HTTP.StagedFiles to be configured
+type StagedFile struct {
+	FileID   string `json:"fileID"`
+	ShardKey string `json:"shardKey"`
+	Status   string `json:"status"`
+
+	// EntryCount and Batches total the file's entries. Amounts are in cents.
+	EntryCount int                  `json:"entryCount"`
+	Batches    []models.BatchTotals `json:"batches,omitempty"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	CommittedAt *time.Time `json:"committedAt,omitempty"`
+}
+
+// StageFile validates and totals file without submitting it. Staging a file again replaces it
+// until it's committed.
+func (c *Client) StageFile(ctx context.Context, shardKey, fileID string, file *ach.File, opts *SubmitOptions) (*StagedFile, error) {
+	req, err := c.fileRequest(http.MethodPost, stagedFilePath(shardKey, fileID), file, opts)
+	if err != nil {
+		return nil, err
+	}
+	var staged StagedFile
+	if _, err := c.do(ctx, req, &staged); err != nil {
+		return nil, err
+	}
+	return &staged, nil
+}
+
+// GetStagedFile returns a staged file and its status. nil is returned when it's not found.
+func (c *Client) GetStagedFile(ctx context.Context, shardKey, fileID string) (*StagedFile, error) {
+	var staged StagedFile
+	_, err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   stagedFilePath(shardKey, fileID),
+		retry:  true,
+	}, &staged)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &staged, nil
+}
+
+// CommitStagedFile submits a staged file for the next cutoff. Committing a file again returns it
+// unchanged.
+func (c *Client) CommitStagedFile(ctx context.Context, shardKey, fileID string) (*StagedFile, error) {
+	var staged StagedFile
+	_, err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   stagedFilePath(shardKey, fileID) + "/commit",
+		retry:  true,
+	}, &staged)
+	if err != nil {
+		return nil, err
+	}
+	return &staged, nil
+}
+
+// DeleteStagedFile discards a file which wasn't committed
+func (c *Client) DeleteStagedFile(ctx context.Context, shardKey, fileID string) error {
+	_, err := c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   stagedFilePath(shardKey, fileID),
+		retry:  true,
+	}, nil)
+	return err
+}
+
+func stagedFilePath(shardKey, fileID string) string {
+	return fmt.Sprintf("/shards/%s/staged-files/%s", url.PathEscape(shardKey), url.PathEscape(fileID))
+}
+
+// fileRequest prepares a request with file as its JSON body. Requests with a file are keyed by
+// their fileID, so they're retried.
+func (c *Client) fileRequest(method, path string, file *ach.File, opts *SubmitOptions) (request, error) {
+	if file == nil {
+		return request{}, errors.New("nil File")
+	}
+	bs, err := json.Marshal(file)
+	if err != nil {
+		return request{}, fmt.Errorf("encoding file: %v", err)
+	}
+	bs, err = c.protect(bs)
+	if err != nil {
+		return request{}, err
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	if opts != nil && len(opts.Metadata) > 0 {
+		header.Set(MetadataHeader, encodeMetadata(opts.Metadata))
+	}
+	return request{
+		method: method,
+		path:   path,
+		header: header,
+		body:   bs,
+		retry:  true,
+	}, nil
+}
+
+// encodeMetadata writes metadata like a query string
+func encodeMetadata(metadata map[string]string) string {
+	values := make(url.Values)
+	for key, value := range metadata {
+		values.Set(key, value)
+	}
+	return values.Encode()
+}
@@ -0,0 +1,148 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package client
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/internal/incoming/stream/streamtest"
+	"github.com/moov-io/achgateway/internal/incoming/web"
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/staging"
+	"github.com/moov-io/achgateway/pkg/compliance"
+	"github.com/moov-io/achgateway/pkg/models"
+	"github.com/moov-io/base/database"
+	"github.com/moov-io/base/log"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient__Files(t *testing.T) {
+	topic, sub := streamtest.InmemStream(t)
+
+	db := database.CreateTestSQLiteDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	transform := &models.TransformConfig{
+		Encoding: &models.EncodingConfig{Base64: true},
+	}
+	cfg := service.HTTPConfig{
+		Transform:   transform,
+		StagedFiles: &service.StagedFilesConfig{Expiration: time.Hour},
+	}
+	controller := web.NewFilesController(log.NewNopLogger(), cfg, topic).
+		WithStagedFiles(staging.NewRepository(db.DB))
+	r := mux.NewRouter()
+	controller.AppendRoutes(r)
+
+	c := testClient(t, r)
+	c.cfg.Transform = transform
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	receive := func() []byte {
+		t.Helper()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		msg, err := sub.Receive(ctx)
+		require.NoError(t, err)
+		msg.Ack()
+
+		bs, err := compliance.Reveal(transform, msg.Body)
+		require.NoError(t, err)
+		return bs
+	}
+
+	ctx := context.Background()
+	err = c.CreateFile(ctx, "s1", "f1", file, &SubmitOptions{
+		Metadata: map[string]string{"batchID": "abc123"},
+	})
+	require.NoError(t, err)
+
+	var created incoming.ACHFile
+	require.NoError(t, models.ReadEvent(receive(), &created))
+	require.Equal(t, "f1", created.FileID)
+	require.Equal(t, "s1", created.ShardKey)
+	require.Equal(t, "abc123", created.Metadata["batchID"])
+	require.Len(t, created.File.Batches, 1)
+
+	require.NoError(t, c.CancelFile(ctx, "s1", "f1"))
+	var canceled incoming.CancelACHFile
+	require.NoError(t, models.ReadEvent(receive(), &canceled))
+	require.Equal(t, "f1", canceled.FileID)
+
+	require.ErrorContains(t, c.CreateFile(ctx, "s1", "f2", nil, nil), "nil File")
+
+	// Staged files
+	staged, err := c.GetStagedFile(ctx, "s1", "f3")
+	require.NoError(t, err)
+	require.Nil(t, staged)
+
+	staged, err = c.StageFile(ctx, "s1", "f3", file, nil)
+	require.NoError(t, err)
+	require.Equal(t, StagedFileStaged, staged.Status)
+	require.Equal(t, 1, staged.EntryCount)
+	require.Equal(t, 10500, staged.Batches[0].DebitTotal)
+
+	staged, err = c.CommitStagedFile(ctx, "s1", "f3")
+	require.NoError(t, err)
+	require.Equal(t, StagedFileCommitted, staged.Status)
+	require.NotNil(t, staged.CommittedAt)
+
+	var committed incoming.ACHFile
+	require.NoError(t, models.ReadEvent(receive(), &committed))
+	require.Equal(t, "f3", committed.FileID)
+
+	staged, err = c.GetStagedFile(ctx, "s1", "f3")
+	require.NoError(t, err)
+	require.Equal(t, StagedFileCommitted, staged.Status)
+
+	// Committed files can't be replaced or deleted
+	_, err = c.StageFile(ctx, "s1", "f3", file, nil)
+	require.True(t, IsConflict(err))
+	require.True(t, IsConflict(c.DeleteStagedFile(ctx, "s1", "f3")))
+
+	_, err = c.StageFile(ctx, "s1", "f4", file, nil)
+	require.NoError(t, err)
+	require.NoError(t, c.DeleteStagedFile(ctx, "s1", "f4"))
+}
+
+func TestEncodeMetadata(t *testing.T) {
+	require.Equal(t, "batchID=abc+123&ledger=payroll", encodeMetadata(map[string]string{
+		"ledger":  "payroll",
+		"batchID": "abc 123",
+	}))
+
+	// Request bodies are protected like the server reveals them
+	c := &Client{cfg: Config{Transform: &models.TransformConfig{
+		Encoding: &models.EncodingConfig{Base64: true},
+	}}}
+	bs, err := c.protect([]byte("body"))
+	require.NoError(t, err)
+	revealed, err := compliance.Reveal(c.cfg.Transform, bs)
+	require.NoError(t, err)
+	require.Equal(t, "body", string(revealed))
+}
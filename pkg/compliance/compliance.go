@@ -28,6 +28,11 @@ func Protect(cfg *models.TransformConfig, evt models.Event) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	return ProtectBytes(cfg, bs)
+}
+
+// ProtectBytes encrypts and encodes bs like Protect, such as for request bodies read with Reveal
+func ProtectBytes(cfg *models.TransformConfig, bs []byte) ([]byte, error) {
 	// Return early if there are no encode/encrypt actions to take
 	if cfg == nil {
 		return bs, nil
@@ -34,7 +34,7 @@ func newCryptor(cfg *models.EncryptionConfig) (cryptor, error) {
 		return &mockCryptor{}, nil
 
 	case cfg.AES != nil:
-		return newAESCryptor(cfg.AES)
+		return newAESCryptor(cfg.AES, cfg.PreviousAES)
 	}
 	return nil, errors.New("unknown encryption")
 }
@@ -18,25 +18,120 @@
 package compliance
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"io"
 
 	"github.com/moov-io/achgateway/pkg/models"
 )
 
+var (
+	// keyIDMarker prefixes messages which were encrypted with an AESConfig that has a KeyID.
+	// The marker is followed by one byte for the length of the KeyID and then the KeyID itself.
+	keyIDMarker = []byte("\x00kid")
+)
+
 type aesCryptor struct {
-	cfg *models.AESConfig
+	cfg      *models.AESConfig
+	previous []*models.AESConfig
 }
 
-func newAESCryptor(cfg *models.AESConfig) (*aesCryptor, error) {
-	return &aesCryptor{cfg}, nil
+func newAESCryptor(cfg *models.AESConfig, previous []*models.AESConfig) (*aesCryptor, error) {
+	if cfg == nil {
+		return nil, errors.New("nil AES config")
+	}
+	keys := append([]*models.AESConfig{cfg}, previous...)
+	seen := make(map[string]bool)
+	for i := range keys {
+		if keys[i] == nil {
+			return nil, fmt.Errorf("nil AES key at index %d", i)
+		}
+		if len(keys[i].KeyID) > 255 {
+			return nil, fmt.Errorf("AES KeyID %q is too long", keys[i].KeyID)
+		}
+		if keys[i].KeyID != "" {
+			if seen[keys[i].KeyID] {
+				return nil, fmt.Errorf("duplicate AES KeyID %q", keys[i].KeyID)
+			}
+			seen[keys[i].KeyID] = true
+		}
+	}
+	return &aesCryptor{
+		cfg:      cfg,
+		previous: previous,
+	}, nil
 }
 
 func (c *aesCryptor) Encrypt(data []byte) ([]byte, error) {
-	cphr, err := aes.NewCipher([]byte(c.cfg.Key))
+	out, err := encryptAES(c.cfg.Key, data)
+	if err != nil {
+		return nil, err
+	}
+	if c.cfg.KeyID == "" {
+		return out, nil
+	}
+	buf := make([]byte, 0, len(keyIDMarker)+1+len(c.cfg.KeyID)+len(out))
+	buf = append(buf, keyIDMarker...)
+	buf = append(buf, byte(len(c.cfg.KeyID)))
+	buf = append(buf, c.cfg.KeyID...)
+	buf = append(buf, out...)
+	return buf, nil
+}
+
+func (c *aesCryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	keyID, ciphertext, found := splitKeyID(ciphertext)
+	if found {
+		key := c.findKey(keyID)
+		if key == nil {
+			return nil, fmt.Errorf("unknown AES KeyID %q", keyID)
+		}
+		return decryptAES(key.Key, ciphertext)
+	}
+
+	// Messages without a KeyID could have been encrypted with any of our keys,
+	// so try them in order. GCM authentication rejects the wrong keys.
+	plaintext, err := decryptAES(c.cfg.Key, ciphertext)
+	if err == nil {
+		return plaintext, nil
+	}
+	for i := range c.previous {
+		if pt, perr := decryptAES(c.previous[i].Key, ciphertext); perr == nil {
+			return pt, nil
+		}
+	}
+	return nil, err
+}
+
+func (c *aesCryptor) findKey(keyID string) *models.AESConfig {
+	if c.cfg.KeyID == keyID {
+		return c.cfg
+	}
+	for i := range c.previous {
+		if c.previous[i].KeyID == keyID {
+			return c.previous[i]
+		}
+	}
+	return nil
+}
+
+func splitKeyID(data []byte) (string, []byte, bool) {
+	if !bytes.HasPrefix(data, keyIDMarker) || len(data) <= len(keyIDMarker) {
+		return "", data, false
+	}
+	rest := data[len(keyIDMarker):]
+	size := int(rest[0])
+	if len(rest) < 1+size {
+		return "", data, false
+	}
+	return string(rest[1 : 1+size]), rest[1+size:], true
+}
+
+func encryptAES(key string, data []byte) ([]byte, error) {
+	cphr, err := aes.NewCipher([]byte(key))
 	if err != nil {
 		return nil, err
 	}
@@ -52,8 +147,8 @@ func (c *aesCryptor) Encrypt(data []byte) ([]byte, error) {
 	return out, nil
 }
 
-func (c *aesCryptor) Decrypt(ciphertext []byte) ([]byte, error) {
-	cphr, err := aes.NewCipher([]byte(c.cfg.Key))
+func decryptAES(key string, ciphertext []byte) ([]byte, error) {
+	cphr, err := aes.NewCipher([]byte(key))
 	if err != nil {
 		return nil, err
 	}
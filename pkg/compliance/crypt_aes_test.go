@@ -46,3 +46,45 @@ func TestCryptor__AES(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "hello, world", string(dec2))
 }
+
+func TestCryptor__AESRotation(t *testing.T) {
+	oldKey := &models.AESConfig{KeyID: "2021", Key: strings.Repeat("1", 16)}
+	newKey := &models.AESConfig{KeyID: "2022", Key: strings.Repeat("2", 16)}
+	legacyKey := &models.AESConfig{Key: strings.Repeat("3", 16)}
+
+	before, err := newCryptor(&models.EncryptionConfig{AES: oldKey})
+	require.NoError(t, err)
+	inflight, err := before.Encrypt([]byte("hello, world"))
+	require.NoError(t, err)
+
+	legacy, err := newCryptor(&models.EncryptionConfig{AES: legacyKey})
+	require.NoError(t, err)
+	unlabeled, err := legacy.Encrypt([]byte("no key id"))
+	require.NoError(t, err)
+
+	after, err := newCryptor(&models.EncryptionConfig{
+		AES:         newKey,
+		PreviousAES: []*models.AESConfig{oldKey, legacyKey},
+	})
+	require.NoError(t, err)
+
+	dec, err := after.Decrypt(inflight)
+	require.NoError(t, err)
+	require.Equal(t, "hello, world", string(dec))
+
+	dec, err = after.Decrypt(unlabeled)
+	require.NoError(t, err)
+	require.Equal(t, "no key id", string(dec))
+
+	_, err = newCryptor(&models.EncryptionConfig{
+		AES:         newKey,
+		PreviousAES: []*models.AESConfig{newKey},
+	})
+	require.ErrorContains(t, err, "duplicate AES KeyID")
+
+	// Once the old key is removed in-flight messages can no longer be read
+	removed, err := newCryptor(&models.EncryptionConfig{AES: newKey})
+	require.NoError(t, err)
+	_, err = removed.Decrypt(inflight)
+	require.ErrorContains(t, err, `unknown AES KeyID "2021"`)
+}
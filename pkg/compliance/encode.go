@@ -18,7 +18,6 @@
 package compliance
 
 import (
-	"bytes"
 	"encoding/base64"
 	"errors"
 
@@ -54,9 +53,9 @@ func (*mockCoder) Decode(data []byte) ([]byte, error) {
 type base64Coder struct{}
 
 func (*base64Coder) Encode(data []byte) ([]byte, error) {
-	var buf bytes.Buffer
-	buf.WriteString(base64.RawStdEncoding.EncodeToString(data))
-	return buf.Bytes(), nil
+	dst := make([]byte, base64.RawStdEncoding.EncodedLen(len(data)))
+	base64.RawStdEncoding.Encode(dst, data)
+	return dst, nil
 }
 
 func (*base64Coder) Decode(data []byte) ([]byte, error) {
@@ -0,0 +1,290 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package cpa005 reads and writes Payments Canada CPA Standard 005 files, the fixed width
+// format used for Canadian EFT (AFT) credits and debits and their returns.
+//
+// Files are made of 1464 character logical records. An "A" header record is followed by
+// transaction records which each hold up to six 240 character segments, and a "Z" trailer
+// record closes the file with its totals.
+package cpa005
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// RecordLength is the length of each logical record
+const RecordLength = 1464
+
+const (
+	segmentLength     = 240
+	segmentsPerRecord = 6
+)
+
+// Record types of transaction records
+const (
+	Credit         = "C"
+	Debit          = "D"
+	CreditReversal = "E"
+	DebitReversal  = "F"
+	ReturnedCredit = "I"
+	ReturnedDebit  = "J"
+)
+
+// File is a CPA-005 file.
+type File struct {
+	Header       Header         `json:"header"`
+	Transactions []*Transaction `json:"transactions"`
+	Trailer      Trailer        `json:"trailer"`
+}
+
+// Header is the "A" record of a file.
+type Header struct {
+	// OriginatorID is the 10 character ID assigned by the originator's FI
+	OriginatorID string `json:"originatorID"`
+
+	// FileCreationNumber must increase by one for each file sent by the originator (1-9999)
+	FileCreationNumber int `json:"fileCreationNumber"`
+
+	CreationDate time.Time `json:"creationDate"`
+
+	// DestinationDataCentre is the 5 digit data centre of the FI receiving the file
+	DestinationDataCentre string `json:"destinationDataCentre"`
+
+	// Currency is CAD or USD
+	Currency string `json:"currency"`
+}
+
+// Transaction is one segment of a "C", "D", "E", "F", "I" or "J" record.
+type Transaction struct {
+	RecordType string `json:"recordType"`
+
+	// TransactionCode is the 3 digit CPA transaction code, e.g. 200 for payroll deposits
+	TransactionCode string `json:"transactionCode"`
+
+	// Amount is in cents
+	Amount int `json:"amount"`
+
+	DueDate time.Time `json:"dueDate"`
+
+	// InstitutionID is "0" followed by the 3 digit institution number and 5 digit transit number
+	InstitutionID string `json:"institutionID"`
+	AccountNumber string `json:"accountNumber"`
+
+	ItemTraceNumber       string `json:"itemTraceNumber"`
+	StoredTransactionType string `json:"storedTransactionType"`
+
+	OriginatorShortName string `json:"originatorShortName"`
+	PayeeName           string `json:"payeeName"`
+	OriginatorLongName  string `json:"originatorLongName"`
+	OriginatorUserID    string `json:"originatorUserID"`
+	CrossReference      string `json:"crossReference"`
+
+	// ReturnInstitutionID and ReturnAccountNumber are where returned items are sent
+	ReturnInstitutionID string `json:"returnInstitutionID"`
+	ReturnAccountNumber string `json:"returnAccountNumber"`
+
+	SundryInformation string `json:"sundryInformation"`
+	SettlementCode    string `json:"settlementCode"`
+
+	// InvalidDataElementID identifies why the item was returned on "I" and "J" records
+	InvalidDataElementID string `json:"invalidDataElementID"`
+}
+
+// IsReturn is true for returned credits and debits
+func (t *Transaction) IsReturn() bool {
+	return t.RecordType == ReturnedCredit || t.RecordType == ReturnedDebit
+}
+
+// Trailer is the "Z" record of a file.
+type Trailer struct {
+	TotalDebitAmount  int `json:"totalDebitAmount"`
+	TotalDebitCount   int `json:"totalDebitCount"`
+	TotalCreditAmount int `json:"totalCreditAmount"`
+	TotalCreditCount  int `json:"totalCreditCount"`
+
+	// Error corrections are the "E" (credit reversal) and "F" (debit reversal) records
+	TotalCreditReversalAmount int `json:"totalCreditReversalAmount"`
+	TotalCreditReversalCount  int `json:"totalCreditReversalCount"`
+	TotalDebitReversalAmount  int `json:"totalDebitReversalAmount"`
+	TotalDebitReversalCount   int `json:"totalDebitReversalCount"`
+}
+
+// Create computes the file's trailer from its transactions.
+func (f *File) Create() {
+	var trailer Trailer
+	for _, t := range f.Transactions {
+		switch t.RecordType {
+		case Debit, ReturnedDebit:
+			trailer.TotalDebitAmount += t.Amount
+			trailer.TotalDebitCount++
+		case Credit, ReturnedCredit:
+			trailer.TotalCreditAmount += t.Amount
+			trailer.TotalCreditCount++
+		case CreditReversal:
+			trailer.TotalCreditReversalAmount += t.Amount
+			trailer.TotalCreditReversalCount++
+		case DebitReversal:
+			trailer.TotalDebitReversalAmount += t.Amount
+			trailer.TotalDebitReversalCount++
+		}
+	}
+	f.Trailer = trailer
+}
+
+// Validate checks the header, each transaction and that the trailer matches the transactions.
+func (f *File) Validate() error {
+	if f == nil {
+		return errors.New("nil File")
+	}
+	if err := f.Header.Validate(); err != nil {
+		return fmt.Errorf("header: %v", err)
+	}
+	if len(f.Transactions) == 0 {
+		return errors.New("no transactions")
+	}
+	traces := make(map[string]bool)
+	for i, t := range f.Transactions {
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("transaction %d: %v", i+1, err)
+		}
+		if t.ItemTraceNumber != "" {
+			if traces[t.ItemTraceNumber] {
+				return fmt.Errorf("transaction %d: duplicate item trace number %s", i+1, t.ItemTraceNumber)
+			}
+			traces[t.ItemTraceNumber] = true
+		}
+	}
+
+	expected := File{Transactions: f.Transactions}
+	expected.Create()
+	if expected.Trailer != f.Trailer {
+		return fmt.Errorf("trailer totals %+v don't match transactions %+v", f.Trailer, expected.Trailer)
+	}
+	return nil
+}
+
+func (h Header) Validate() error {
+	if h.OriginatorID == "" || len(h.OriginatorID) > 10 {
+		return fmt.Errorf("invalid OriginatorID %q", h.OriginatorID)
+	}
+	if h.FileCreationNumber < 1 || h.FileCreationNumber > 9999 {
+		return fmt.Errorf("invalid FileCreationNumber %d", h.FileCreationNumber)
+	}
+	if h.CreationDate.IsZero() {
+		return errors.New("missing CreationDate")
+	}
+	if !numeric(h.DestinationDataCentre, 5) {
+		return fmt.Errorf("invalid DestinationDataCentre %q", h.DestinationDataCentre)
+	}
+	if h.Currency != "CAD" && h.Currency != "USD" {
+		return fmt.Errorf("invalid Currency %q", h.Currency)
+	}
+	return nil
+}
+
+func (t *Transaction) Validate() error {
+	switch t.RecordType {
+	case Credit, Debit, CreditReversal, DebitReversal, ReturnedCredit, ReturnedDebit:
+	default:
+		return fmt.Errorf("unknown record type %q", t.RecordType)
+	}
+	if !numeric(t.TransactionCode, 3) {
+		return fmt.Errorf("invalid TransactionCode %q", t.TransactionCode)
+	}
+	if t.Amount <= 0 || t.Amount > 9999999999 {
+		return fmt.Errorf("invalid Amount %d", t.Amount)
+	}
+	if t.DueDate.IsZero() {
+		return errors.New("missing DueDate")
+	}
+	if !numeric(t.InstitutionID, 9) {
+		return fmt.Errorf("invalid InstitutionID %q", t.InstitutionID)
+	}
+	if t.AccountNumber == "" {
+		return errors.New("missing AccountNumber")
+	}
+	fields := []struct {
+		name  string
+		value string
+		max   int
+	}{
+		{"AccountNumber", t.AccountNumber, 12},
+		{"ItemTraceNumber", t.ItemTraceNumber, 22},
+		{"StoredTransactionType", t.StoredTransactionType, 3},
+		{"OriginatorShortName", t.OriginatorShortName, 15},
+		{"PayeeName", t.PayeeName, 30},
+		{"OriginatorLongName", t.OriginatorLongName, 30},
+		{"OriginatorUserID", t.OriginatorUserID, 10},
+		{"CrossReference", t.CrossReference, 19},
+		{"ReturnInstitutionID", t.ReturnInstitutionID, 9},
+		{"ReturnAccountNumber", t.ReturnAccountNumber, 12},
+		{"SundryInformation", t.SundryInformation, 15},
+		{"SettlementCode", t.SettlementCode, 2},
+		{"InvalidDataElementID", t.InvalidDataElementID, 11},
+	}
+	for _, field := range fields {
+		if len(field.value) > field.max {
+			return fmt.Errorf("%s is longer than %d characters", field.name, field.max)
+		}
+	}
+	return nil
+}
+
+// Merge combines the transactions of files into one file with header.
+func Merge(header Header, files ...*File) (*File, error) {
+	out := &File{Header: header}
+	for i := range files {
+		if files[i] == nil {
+			continue
+		}
+		out.Transactions = append(out.Transactions, files[i].Transactions...)
+	}
+	out.Create()
+	if err := out.Validate(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func numeric(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
+
+// formatDate returns t as 0YYDDD (year and day of the year)
+func formatDate(t time.Time) string {
+	return fmt.Sprintf("0%s%03d", t.Format("06"), t.YearDay())
+}
+
+func parseDate(value string) (time.Time, error) {
+	if !numeric(value, 6) || value[0] != '0' {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	year, _ := strconv.Atoi(value[1:3])
+	day, _ := strconv.Atoi(value[3:])
+	if day < 1 || day > 366 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return time.Date(2000+year, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, day-1), nil
+}
@@ -0,0 +1,180 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cpa005
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testHeader(creationNumber int) Header {
+	return Header{
+		OriginatorID:          "MOOVCA0001",
+		FileCreationNumber:    creationNumber,
+		CreationDate:          time.Date(2021, time.June, 14, 0, 0, 0, 0, time.UTC),
+		DestinationDataCentre: "00120",
+		Currency:              "CAD",
+	}
+}
+
+func testTransaction(recordType string, amount int, trace string) *Transaction {
+	return &Transaction{
+		RecordType:          recordType,
+		TransactionCode:     "200",
+		Amount:              amount,
+		DueDate:             time.Date(2021, time.June, 15, 0, 0, 0, 0, time.UTC),
+		InstitutionID:       "000112345",
+		AccountNumber:       "1234567",
+		ItemTraceNumber:     trace,
+		OriginatorShortName: "MOOV",
+		PayeeName:           "JANE DOE",
+		OriginatorLongName:  "MOOV FINANCIAL INC",
+		CrossReference:      "INV-1",
+	}
+}
+
+func TestFile__RoundTrip(t *testing.T) {
+	f := &File{Header: testHeader(12)}
+	for i := 0; i < 7; i++ {
+		f.Transactions = append(f.Transactions, testTransaction(Credit, 100+i, "00012345000000000"+string(rune('0'+i))))
+	}
+	f.Transactions = append(f.Transactions, testTransaction(Debit, 5000, "000123450000000009"))
+	f.Create()
+
+	var buf bytes.Buffer
+	require.NoError(t, f.Write(&buf))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 5) // A, two C records, D and Z
+	for i := range lines {
+		require.Len(t, lines[i], RecordLength)
+	}
+	require.True(t, strings.HasPrefix(lines[0], "A000000001MOOVCA00010012021165"))
+	require.True(t, strings.HasPrefix(lines[4], "Z000000005"))
+
+	read, err := Read(&buf)
+	require.NoError(t, err)
+	require.Equal(t, f.Header, read.Header)
+	require.Equal(t, f.Trailer, read.Trailer)
+	require.Len(t, read.Transactions, 8)
+	require.Equal(t, f.Transactions[0], read.Transactions[0])
+	require.Equal(t, Debit, read.Transactions[7].RecordType)
+	require.Equal(t, 5000, read.Trailer.TotalDebitAmount)
+	require.Equal(t, 7, read.Trailer.TotalCreditCount)
+}
+
+func TestRead__Unbroken(t *testing.T) {
+	f := &File{
+		Header:       testHeader(1),
+		Transactions: []*Transaction{testTransaction(Credit, 100, "1")},
+	}
+	f.Create()
+
+	var buf bytes.Buffer
+	require.NoError(t, f.Write(&buf))
+
+	// Some FIs send records back to back without line endings
+	read, err := Read(strings.NewReader(strings.ReplaceAll(buf.String(), "\n", "")))
+	require.NoError(t, err)
+	require.Len(t, read.Transactions, 1)
+}
+
+func TestRead__Returns(t *testing.T) {
+	fd, err := os.Open(filepath.Join("testdata", "returns.005"))
+	require.NoError(t, err)
+	defer fd.Close()
+
+	f, err := Read(fd)
+	require.NoError(t, err)
+	require.Len(t, f.Transactions, 2)
+
+	require.Equal(t, ReturnedCredit, f.Transactions[0].RecordType)
+	require.True(t, f.Transactions[0].IsReturn())
+	require.Equal(t, "905", f.Transactions[0].InvalidDataElementID)
+	require.Equal(t, ReturnedDebit, f.Transactions[1].RecordType)
+	require.Equal(t, 2500, f.Trailer.TotalDebitAmount)
+}
+
+func TestRead__Errors(t *testing.T) {
+	f := &File{
+		Header:       testHeader(1),
+		Transactions: []*Transaction{testTransaction(Credit, 100, "1")},
+	}
+	f.Create()
+
+	var buf bytes.Buffer
+	require.NoError(t, f.Write(&buf))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	_, err := Read(strings.NewReader(lines[0]))
+	require.ErrorContains(t, err, "missing header or trailer record")
+
+	_, err = Read(strings.NewReader(strings.Join([]string{lines[1], lines[0], lines[2]}, "\n")))
+	require.ErrorContains(t, err, "record 1: invalid record count")
+
+	// Trailer totals which don't match
+	trailer := lines[2][:24] + "0000000000000100000001" + lines[2][46:]
+	_, err = Read(strings.NewReader(strings.Join([]string{lines[0], lines[1], trailer}, "\n")))
+	require.ErrorContains(t, err, "trailer totals")
+}
+
+func TestFile__Validate(t *testing.T) {
+	f := &File{Header: testHeader(1)}
+	require.ErrorContains(t, f.Validate(), "no transactions")
+
+	f.Header.FileCreationNumber = 0
+	require.ErrorContains(t, f.Validate(), "invalid FileCreationNumber 0")
+
+	f.Header = testHeader(1)
+	f.Header.Currency = "EUR"
+	require.ErrorContains(t, f.Validate(), `invalid Currency "EUR"`)
+
+	f.Header = testHeader(1)
+	f.Transactions = []*Transaction{testTransaction(Credit, 100, "1"), testTransaction(Credit, 100, "1")}
+	f.Create()
+	require.ErrorContains(t, f.Validate(), "transaction 2: duplicate item trace number 1")
+
+	f.Transactions[1].ItemTraceNumber = "2"
+	f.Transactions[1].InstitutionID = "12345"
+	require.ErrorContains(t, f.Validate(), `transaction 2: invalid InstitutionID "12345"`)
+
+	f.Transactions[1].InstitutionID = "000112345"
+	f.Transactions[1].PayeeName = strings.Repeat("A", 31)
+	require.ErrorContains(t, f.Validate(), "transaction 2: PayeeName is longer than 30 characters")
+}
+
+func TestMerge(t *testing.T) {
+	a := &File{Header: testHeader(1), Transactions: []*Transaction{testTransaction(Credit, 100, "1")}}
+	b := &File{Header: testHeader(7), Transactions: []*Transaction{testTransaction(Debit, 250, "2"), testTransaction(Credit, 50, "3")}}
+
+	merged, err := Merge(testHeader(42), a, b, nil)
+	require.NoError(t, err)
+	require.Equal(t, 42, merged.Header.FileCreationNumber)
+	require.Len(t, merged.Transactions, 3)
+	require.Equal(t, 150, merged.Trailer.TotalCreditAmount)
+	require.Equal(t, 250, merged.Trailer.TotalDebitAmount)
+
+	_, err = Merge(testHeader(42), a, a)
+	require.ErrorContains(t, err, "duplicate item trace number")
+}
@@ -0,0 +1,211 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cpa005
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Read parses a CPA-005 file. Logical records can be on their own lines or written back to back.
+func Read(r io.Reader) (*File, error) {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	records, err := splitRecords(bs)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, errors.New("missing header or trailer record")
+	}
+
+	f := &File{}
+	for i, record := range records {
+		recordType := record[0:1]
+		if n, err := strconv.Atoi(record[1:10]); err != nil || n != i+1 {
+			return nil, fmt.Errorf("record %d: invalid record count %q", i+1, record[1:10])
+		}
+		if i == 0 {
+			if recordType != "A" {
+				return nil, fmt.Errorf("record 1: expected header record, found %q", recordType)
+			}
+			if err := readHeader(f, record); err != nil {
+				return nil, fmt.Errorf("record 1: %v", err)
+			}
+			continue
+		}
+		if originator := strings.TrimSpace(record[10:20]); originator != f.Header.OriginatorID {
+			return nil, fmt.Errorf("record %d: originator ID %q doesn't match header", i+1, originator)
+		}
+		if i == len(records)-1 {
+			if recordType != "Z" {
+				return nil, fmt.Errorf("record %d: expected trailer record, found %q", i+1, recordType)
+			}
+			if err := readTrailer(f, record); err != nil {
+				return nil, fmt.Errorf("record %d: %v", i+1, err)
+			}
+			continue
+		}
+		for n := 0; n < segmentsPerRecord; n++ {
+			start := 24 + n*segmentLength
+			segment := record[start : start+segmentLength]
+			if empty(segment) {
+				break
+			}
+			t, err := readSegment(recordType, segment)
+			if err != nil {
+				return nil, fmt.Errorf("record %d segment %d: %v", i+1, n+1, err)
+			}
+			f.Transactions = append(f.Transactions, t)
+		}
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func splitRecords(bs []byte) ([]string, error) {
+	bs = bytes.ReplaceAll(bs, []byte("\r"), nil)
+	var lines []string
+	if bytes.Contains(bs, []byte("\n")) {
+		for _, line := range strings.Split(string(bs), "\n") {
+			if strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+	} else {
+		s := strings.TrimRight(string(bs), " ")
+		for len(s) > RecordLength {
+			lines = append(lines, s[:RecordLength])
+			s = s[RecordLength:]
+		}
+		if s != "" {
+			lines = append(lines, s)
+		}
+	}
+	var out []string
+	for i, line := range lines {
+		if len(line) > RecordLength {
+			return nil, fmt.Errorf("record %d is longer than %d characters", i+1, RecordLength)
+		}
+		if len(line) < 24 {
+			return nil, fmt.Errorf("record %d is too short", i+1)
+		}
+		out = append(out, pad(line, RecordLength))
+	}
+	return out, nil
+}
+
+func readHeader(f *File, record string) error {
+	creationNumber, err := strconv.Atoi(record[20:24])
+	if err != nil {
+		return fmt.Errorf("invalid file creation number %q", record[20:24])
+	}
+	created, err := parseDate(record[24:30])
+	if err != nil {
+		return fmt.Errorf("creation date: %v", err)
+	}
+	f.Header = Header{
+		OriginatorID:          strings.TrimSpace(record[10:20]),
+		FileCreationNumber:    creationNumber,
+		CreationDate:          created,
+		DestinationDataCentre: record[30:35],
+		Currency:              record[55:58],
+	}
+	return nil
+}
+
+func readTrailer(f *File, record string) error {
+	var values [8]int
+	offset := 24
+	for i := range values {
+		width := 14
+		if i%2 == 1 {
+			width = 8
+		}
+		n, err := strconv.Atoi(record[offset : offset+width])
+		if err != nil {
+			return fmt.Errorf("invalid trailer total %q", record[offset:offset+width])
+		}
+		values[i] = n
+		offset += width
+	}
+	f.Trailer = Trailer{
+		TotalDebitAmount:          values[0],
+		TotalDebitCount:           values[1],
+		TotalCreditAmount:         values[2],
+		TotalCreditCount:          values[3],
+		TotalCreditReversalAmount: values[4],
+		TotalCreditReversalCount:  values[5],
+		TotalDebitReversalAmount:  values[6],
+		TotalDebitReversalCount:   values[7],
+	}
+	return nil
+}
+
+func readSegment(recordType, segment string) (*Transaction, error) {
+	amount, err := strconv.Atoi(segment[3:13])
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %q", segment[3:13])
+	}
+	due, err := parseDate(segment[13:19])
+	if err != nil {
+		return nil, fmt.Errorf("due date: %v", err)
+	}
+	field := func(start, length int) string {
+		return strings.TrimSpace(segment[start : start+length])
+	}
+	return &Transaction{
+		RecordType:            recordType,
+		TransactionCode:       segment[0:3],
+		Amount:                amount,
+		DueDate:               due,
+		InstitutionID:         segment[19:28],
+		AccountNumber:         field(28, 12),
+		ItemTraceNumber:       field(40, 22),
+		StoredTransactionType: field(62, 3),
+		OriginatorShortName:   field(65, 15),
+		PayeeName:             field(80, 30),
+		OriginatorLongName:    field(110, 30),
+		OriginatorUserID:      field(140, 10),
+		CrossReference:        field(150, 19),
+		ReturnInstitutionID:   field(169, 9),
+		ReturnAccountNumber:   field(178, 12),
+		SundryInformation:     field(190, 15),
+		SettlementCode:        field(227, 2),
+		InvalidDataElementID:  field(229, 11),
+	}, nil
+}
+
+// empty is true for unused segments, which are blank or zero filled
+func empty(segment string) bool {
+	return strings.Trim(segment, " 0") == ""
+}
+
+// Detect is true when bs looks like a CPA-005 file, which starts with the first "A" record.
+// Nacha files start with a "1" record.
+func Detect(bs []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(bs, " \r\n"), []byte("A000000001"))
+}
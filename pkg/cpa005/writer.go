@@ -0,0 +1,85 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cpa005
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Write validates f and writes it with each logical record on its own line.
+func (f *File) Write(w io.Writer) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	count := 0
+	writeRecord := func(recordType, body string) error {
+		count++
+		record := fmt.Sprintf("%s%09d%-10s%04d%s", recordType, count, f.Header.OriginatorID, f.Header.FileCreationNumber, body)
+		if _, err := bw.WriteString(pad(record, RecordLength) + "\n"); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	header := fmt.Sprintf("%s%s%20s%s", formatDate(f.Header.CreationDate), f.Header.DestinationDataCentre, "", f.Header.Currency)
+	if err := writeRecord("A", header); err != nil {
+		return err
+	}
+
+	// Consecutive transactions of the same type share a record
+	for i := 0; i < len(f.Transactions); {
+		recordType := f.Transactions[i].RecordType
+		var body strings.Builder
+		for n := 0; n < segmentsPerRecord && i < len(f.Transactions) && f.Transactions[i].RecordType == recordType; n++ {
+			body.WriteString(formatSegment(f.Transactions[i]))
+			i++
+		}
+		if err := writeRecord(recordType, body.String()); err != nil {
+			return err
+		}
+	}
+
+	t := f.Trailer
+	trailer := fmt.Sprintf("%014d%08d%014d%08d%014d%08d%014d%08d",
+		t.TotalDebitAmount, t.TotalDebitCount, t.TotalCreditAmount, t.TotalCreditCount,
+		t.TotalCreditReversalAmount, t.TotalCreditReversalCount, t.TotalDebitReversalAmount, t.TotalDebitReversalCount)
+	if err := writeRecord("Z", trailer); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func formatSegment(t *Transaction) string {
+	return fmt.Sprintf("%s%010d%s%s%-12s%-22s%-3s%-15s%-30s%-30s%-10s%-19s%-9s%-12s%-15s%22s%-2s%-11s",
+		t.TransactionCode, t.Amount, formatDate(t.DueDate), t.InstitutionID, t.AccountNumber,
+		t.ItemTraceNumber, t.StoredTransactionType, t.OriginatorShortName, t.PayeeName,
+		t.OriginatorLongName, t.OriginatorUserID, t.CrossReference, t.ReturnInstitutionID,
+		t.ReturnAccountNumber, t.SundryInformation, "", t.SettlementCode, t.InvalidDataElementID)
+}
+
+func pad(s string, n int) string {
+	if len(s) >= n {
+		return s
+	}
+	return s + strings.Repeat(" ", n-len(s))
+}
@@ -26,11 +26,22 @@ import (
 
 	"github.com/moov-io/ach"
 	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/pkg/cpa005"
 )
 
 type Event struct {
 	Event interface{} `json:"event"`
 	Type  string      `json:"type"`
+
+	// Metadata is set on events about a shard when Events.Sequence is enabled
+	Metadata *EventMetadata `json:"metadata,omitempty"`
+}
+
+// EventMetadata orders the events about a shard. Sequence increases by one for each event about
+// the shard in the order they happened, even when they're sent by different instances.
+type EventMetadata struct {
+	ShardKey string `json:"shardKey"`
+	Sequence int64  `json:"sequence"`
 }
 
 func (evt Event) Bytes() []byte {
@@ -41,18 +52,21 @@ func (evt Event) Bytes() []byte {
 
 func (evt Event) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&struct {
-		Event interface{} `json:"event"`
-		Type  string      `json:"type"`
+		Event    interface{}    `json:"event"`
+		Type     string         `json:"type"`
+		Metadata *EventMetadata `json:"metadata,omitempty"`
 	}{
-		Event: evt.Event,
-		Type:  reflect.TypeOf(evt.Event).Name(),
+		Event:    evt.Event,
+		Type:     reflect.TypeOf(evt.Event).Name(),
+		Metadata: evt.Metadata,
 	})
 }
 
 // Read will unmarshal an event and return the wrapper for it.
 func Read(data []byte) (*Event, error) {
 	var eventType struct {
-		Type string `json:"type"`
+		Type     string         `json:"type"`
+		Metadata *EventMetadata `json:"metadata"`
 	}
 	err := json.Unmarshal(data, &eventType)
 	if err != nil {
@@ -75,6 +89,32 @@ func Read(data []byte) (*Event, error) {
 		evt = &QueueACHFile{}
 	case "CancelACHFile":
 		evt = &CancelACHFile{}
+	case "CPA005File", "QueueCPA005File":
+		evt = &QueueCPA005File{}
+	case "CPA005ReturnFile":
+		evt = &CPA005ReturnFile{}
+	case "TreasuryExportFile":
+		evt = &TreasuryExportFile{}
+	case "FileUploaded":
+		evt = &FileUploaded{}
+	case "CutoffTakenOver":
+		evt = &CutoffTakenOver{}
+	case "FileRejected":
+		evt = &FileRejected{}
+	case "FileRolledOver":
+		evt = &FileRolledOver{}
+	case "EntryAccepted":
+		evt = &EntryAccepted{}
+	case "MicroEntryUpdated":
+		evt = &MicroEntryUpdated{}
+	case "RepresentmentUpdated":
+		evt = &RepresentmentUpdated{}
+	case "DeliveryCompleted":
+		evt = &DeliveryCompleted{}
+	case "DeliveryIncomplete":
+		evt = &DeliveryIncomplete{}
+	case "ODFIScanCompleted":
+		evt = &ODFIScanCompleted{}
 	}
 
 	err = ReadEvent(data, evt)
@@ -82,8 +122,9 @@ func Read(data []byte) (*Event, error) {
 		return nil, fmt.Errorf("reading event: %v", err)
 	}
 	return &Event{
-		Event: evt,
-		Type:  eventType.Type,
+		Event:    evt,
+		Type:     eventType.Type,
+		Metadata: eventType.Metadata,
 	}, nil
 }
 
@@ -123,6 +164,9 @@ func (evt *CorrectionFile) SetValidation(opts *ach.ValidateOpts) {
 type IncomingFile struct {
 	Filename string    `json:"filename"`
 	File     *ach.File `json:"file"`
+
+	// Remittances are parsed from the addenda of CCD and CTX entries
+	Remittances []EntryRemittance `json:"remittances,omitempty"`
 }
 
 func (evt *IncomingFile) SetValidation(opts *ach.ValidateOpts) {
@@ -173,6 +217,18 @@ type ReturnFile struct {
 	Filename string    `json:"filename"`
 	File     *ach.File `json:"file"`
 	Returns  []Batch   `json:"returns"`
+
+	// Submissions are the submitted files with metadata whose entries were returned
+	Submissions []ReturnedSubmission `json:"submissions,omitempty"`
+}
+
+// ReturnedSubmission is a file submitted with metadata. TraceNumbers are the original trace
+// numbers of its returned entries.
+type ReturnedSubmission struct {
+	FileID       string            `json:"fileID"`
+	ShardKey     string            `json:"shardKey"`
+	Metadata     map[string]string `json:"metadata"`
+	TraceNumbers []string          `json:"traceNumbers"`
 }
 
 func (evt *ReturnFile) SetValidation(opts *ach.ValidateOpts) {
@@ -193,6 +249,27 @@ func (evt *QueueACHFile) SetValidation(opts *ach.ValidateOpts) {
 	evt.File.SetValidation(opts)
 }
 
+// QueueCPA005File is an event that achgateway receives to enqueue a Canadian EFT (CPA Standard 005)
+// file for upload at a later cutoff time. The shard must be configured with CPA005.
+type QueueCPA005File incoming.CPA005File
+
+// CPA005ReturnFile is an event for when a CPA Standard 005 file from the ODFI contains
+// returned credits or debits.
+type CPA005ReturnFile struct {
+	Filename string                `json:"filename"`
+	File     *cpa005.File          `json:"file"`
+	Returns  []*cpa005.Transaction `json:"returns"`
+}
+
+// TreasuryExportFile is an event holding the reconciliation and return entries of an ODFI file
+// written as BAI2 or CSV for treasury systems. Contents are base64 encoded in JSON.
+type TreasuryExportFile struct {
+	Filename       string `json:"filename"`
+	SourceFilename string `json:"sourceFilename"`
+	Format         string `json:"format"`
+	Contents       []byte `json:"contents"`
+}
+
 // CancelACHFile is an event that achgateway receives to cancel uploading a file to the ODFI.
 type CancelACHFile incoming.CancelACHFile
 
@@ -204,4 +281,360 @@ type FileUploaded struct {
 	ShardKey   string    `json:"shardKey"`
 	Filename   string    `json:"filename"`
 	UploadedAt time.Time `json:"uploadedAt"`
+
+	// RemotePath, Size and SHA256 describe the uploaded file holding this file's entries, as
+	// written to the ODFI. When the entries were split across several files it's the first.
+	RemotePath string `json:"remotePath,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	SHA256     string `json:"sha256,omitempty"`
+
+	// Collision is set when a file with the same name was already on the remote server
+	Collision *FilenameCollision `json:"collision,omitempty"`
+
+	// EntryCount and Batches total the file's entries as they were submitted
+	EntryCount int           `json:"entryCount"`
+	Batches    []BatchTotals `json:"batches,omitempty"`
+
+	// CutoffWindow identifies the cutoff which uploaded the file, such as "2022-10-14 17:00 EDT" or "manual"
+	CutoffWindow string `json:"cutoffWindow,omitempty"`
+
+	// MergeDurationMs is how long the cutoff spent merging pending files and UploadDurationMs
+	// how long writing the uploaded file to the ODFI took, in milliseconds.
+	MergeDurationMs  int64 `json:"mergeDurationMs,omitempty"`
+	UploadDurationMs int64 `json:"uploadDurationMs,omitempty"`
+
+	// Settlements are the expected settlement dates of each entry in the file,
+	// included when the shard has Settlement configured.
+	Settlements []EntrySettlement `json:"settlements,omitempty"`
+
+	// Metadata was attached to the file when it was submitted
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// BatchTotals sums the entries of one batch. Amounts are in cents.
+type BatchTotals struct {
+	BatchNumber           int    `json:"batchNumber"`
+	SECCode               string `json:"secCode"`
+	CompanyIdentification string `json:"companyIdentification"`
+	EffectiveEntryDate    string `json:"effectiveEntryDate"`
+	EntryCount            int    `json:"entryCount"`
+	DebitTotal            int    `json:"debitTotal"`
+	CreditTotal           int    `json:"creditTotal"`
+}
+
+// TotalBatches sums the entries of each batch in file, returning how many entries there are
+func TotalBatches(file *ach.File) (int, []BatchTotals) {
+	var entryCount int
+	var out []BatchTotals
+	for _, batch := range file.Batches {
+		bh := batch.GetHeader()
+		totals := BatchTotals{
+			BatchNumber:           bh.BatchNumber,
+			SECCode:               bh.StandardEntryClassCode,
+			CompanyIdentification: bh.CompanyIdentification,
+			EffectiveEntryDate:    bh.EffectiveEntryDate,
+		}
+		for _, entry := range batch.GetEntries() {
+			totals.add(entry.CreditOrDebit(), entry.Amount)
+		}
+		entryCount += totals.EntryCount
+		out = append(out, totals)
+	}
+	for _, batch := range file.IATBatches {
+		totals := BatchTotals{
+			BatchNumber:        batch.Header.BatchNumber,
+			SECCode:            batch.Header.StandardEntryClassCode,
+			EffectiveEntryDate: batch.Header.EffectiveEntryDate,
+		}
+		for _, entry := range batch.Entries {
+			ed := &ach.EntryDetail{TransactionCode: entry.TransactionCode}
+			totals.add(ed.CreditOrDebit(), entry.Amount)
+		}
+		entryCount += totals.EntryCount
+		out = append(out, totals)
+	}
+	return entryCount, out
+}
+
+func (totals *BatchTotals) add(creditOrDebit string, amount int) {
+	totals.EntryCount++
+	switch creditOrDebit {
+	case "C":
+		totals.CreditTotal += amount
+	case "D":
+		totals.DebitTotal += amount
+	}
+}
+
+// CutoffTakenOver is an event sent when an instance finishes a cutoff which another instance
+// was processing when it was lost. FileUploaded events are sent for each file in the cutoff.
+type CutoffTakenOver struct {
+	ShardKey string `json:"shardKey"`
+
+	// PreviousHolder is the instance which started the cutoff and Holder finished it
+	PreviousHolder string `json:"previousHolder"`
+	Holder         string `json:"holder"`
+
+	StartedAt   time.Time `json:"startedAt"`
+	TakenOverAt time.Time `json:"takenOverAt"`
+
+	FileIDs []string `json:"fileIDs"`
+}
+
+// FileRejected is an event sent when a submitted file won't be uploaded, such as when it fails
+// validation or its shardKey isn't mapped to a shard. Submitters over a stream never see the
+// error achgateway logs, so Reasons describe each problem found.
+type FileRejected struct {
+	FileID     string            `json:"fileID"`
+	ShardKey   string            `json:"shardKey"`
+	Reasons    []RejectionReason `json:"reasons"`
+	RejectedAt time.Time         `json:"rejectedAt"`
+
+	// RejectionID is set when the file is kept for operators to review
+	RejectionID string `json:"rejectionID,omitempty"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+const (
+	RejectionMissingField   = "missing_field"
+	RejectionInvalidFile    = "invalid_file"
+	RejectionUnknownShard   = "unknown_shard"
+	RejectionFileFormat     = "file_format"
+	RejectionBlocked        = "blocked"
+	RejectionLateSubmission = "late_submission"
+	RejectionMetadata       = "invalid_metadata"
+	RejectionInvalidAddenda = "invalid_addenda"
+)
+
+// RejectionReason is one problem with a rejected file. Record, BatchNumber and TraceNumber locate
+// the problem within the file and Field is the offending field, when they're known.
+type RejectionReason struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	Record      string `json:"record,omitempty"`
+	BatchNumber int    `json:"batchNumber,omitempty"`
+	TraceNumber string `json:"traceNumber,omitempty"`
+	Field       string `json:"field,omitempty"`
+	Value       string `json:"value,omitempty"`
+}
+
+// FileRolledOver is an event sent when a file is submitted after its shard's cutoff started merging
+// and is left for the next cutoff instead, for shards with the next-window late submission policy.
+type FileRolledOver struct {
+	FileID          string    `json:"fileID"`
+	ShardKey        string    `json:"shardKey"`
+	CutoffStartedAt time.Time `json:"cutoffStartedAt"`
+
+	// NextCutoff is omitted when the shard has no upcoming banking day cutoff
+	NextCutoff *time.Time `json:"nextCutoff,omitempty"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// EntryAccepted is an event sent for each entry of a file accepted into a shard when
+// Events.EntryAccepted is enabled. AccountNumber is masked to its last four digits.
+type EntryAccepted struct {
+	FileID   string `json:"fileID"`
+	ShardKey string `json:"shardKey"`
+
+	EntryID         string `json:"entryID,omitempty"`
+	BatchNumber     int    `json:"batchNumber"`
+	SECCode         string `json:"secCode"`
+	TransactionCode int    `json:"transactionCode"`
+	RoutingNumber   string `json:"routingNumber"`
+	AccountNumber   string `json:"accountNumber"`
+	Amount          int    `json:"amount"`
+	TraceNumber     string `json:"traceNumber"`
+
+	AcceptedAt time.Time `json:"acceptedAt"`
+
+	// Metadata was attached to the entry's file when it was submitted
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// MicroEntryUpdated is an event sent when micro-entries sent to verify an account are verified,
+// fail verification or are returned.
+type MicroEntryUpdated struct {
+	MicroEntryID string `json:"microEntryID"`
+	ShardKey     string `json:"shardKey"`
+
+	// Status is verified, failed or returned
+	Status string `json:"status"`
+
+	// ReturnCode is set when the micro-entries were returned
+	ReturnCode string `json:"returnCode,omitempty"`
+
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// RepresentmentUpdated is an event sent for each attempt to re-present a returned debit. It's
+// sent when the attempt is scheduled, when its entry is submitted, and when the entry is returned
+// again with no attempts left.
+type RepresentmentUpdated struct {
+	RepresentmentID string `json:"representmentID"`
+	ShardKey        string `json:"shardKey"`
+
+	// OriginalTraceNumber is the trace number of the entry's first presentment
+	OriginalTraceNumber string `json:"originalTraceNumber"`
+
+	// Attempt counts the re-presentments of the entry, starting at 1
+	Attempt int `json:"attempt"`
+
+	// Status is scheduled, submitted or exhausted
+	Status string `json:"status"`
+
+	// ReturnCode is what the previous presentment was returned with
+	ReturnCode string `json:"returnCode"`
+
+	ScheduledFor time.Time `json:"scheduledFor"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+
+	// Metadata was attached to the entry's original file when it was submitted
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// DeliveryCompleted is an event sent once every file of a delivery an ODFI split across several
+// files has been processed.
+type DeliveryCompleted struct {
+	DeliveryID string          `json:"deliveryID"`
+	Files      []DeliveryFile  `json:"files"`
+	Summary    DeliverySummary `json:"summary"`
+
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// DeliveryIncomplete is an event sent when files of a split delivery are still missing once it
+// times out. A DeliveryCompleted event follows if the missing files arrive later.
+type DeliveryIncomplete struct {
+	DeliveryID string `json:"deliveryID"`
+
+	// Expected is how many files make up the delivery and Missing are the sequence numbers not received
+	Expected int   `json:"expected"`
+	Missing  []int `json:"missing"`
+
+	Files   []DeliveryFile  `json:"files"`
+	Summary DeliverySummary `json:"summary"`
+
+	FirstReceivedAt time.Time `json:"firstReceivedAt"`
+}
+
+// DeliveryFile is one file of a split delivery
+type DeliveryFile struct {
+	Sequence   int       `json:"sequence"`
+	Filename   string    `json:"filename"`
+	ReceivedAt time.Time `json:"receivedAt"`
+
+	DeliverySummary
+}
+
+// DeliverySummary totals the entries of delivery files. Amounts are in cents.
+type DeliverySummary struct {
+	Entries     int `json:"entries"`
+	Returns     int `json:"returns"`
+	Corrections int `json:"corrections"`
+	DebitTotal  int `json:"debitTotal"`
+	CreditTotal int `json:"creditTotal"`
+}
+
+// Add includes other in the summary's totals
+func (s *DeliverySummary) Add(other DeliverySummary) {
+	s.Entries += other.Entries
+	s.Returns += other.Returns
+	s.Corrections += other.Corrections
+	s.DebitTotal += other.DebitTotal
+	s.CreditTotal += other.CreditTotal
+}
+
+// ODFIScanCompleted is an event sent after each scan of a shard's ODFI files when
+// Inbound.ODFI.PublishScanSummary is enabled.
+type ODFIScanCompleted struct {
+	ShardName string `json:"shardName"`
+	Hostname  string `json:"hostname"`
+
+	// Downloaded is how many files were copied from the remote server
+	Downloaded int           `json:"downloaded"`
+	Skipped    []SkippedFile `json:"skipped"`
+
+	StartedAt   time.Time `json:"startedAt"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// SkippedFile is a file an ODFI scan didn't download or process.
+type SkippedFile struct {
+	// Path is relative to the remote server's directories, like "returned/RET_20220601.ach"
+	Path string `json:"path"`
+
+	// Reason is one of directory, filtered, zero_byte, pattern_excluded or duplicate
+	Reason string `json:"reason"`
+
+	// Processor is set when the processor's PathMatcher excluded the file
+	Processor string `json:"processor,omitempty"`
+}
+
+// FilenameCollision describes an uploaded file whose name was taken on the remote server.
+type FilenameCollision struct {
+	// Policy is the upload agent's OnCollision which was followed, overwrite or suffix
+	Policy string `json:"policy"`
+
+	// Filename is the name which was taken. The event's Filename is what was uploaded.
+	Filename string `json:"filename"`
+}
+
+// EntrySettlement is when an uploaded entry is expected to settle.
+type EntrySettlement struct {
+	EntryID     string `json:"entryID,omitempty"`
+	TraceNumber string `json:"traceNumber"`
+
+	// EffectiveEntryDate is the batch's effective date as YYYY-MM-DD
+	EffectiveEntryDate string `json:"effectiveEntryDate"`
+
+	// SettlementDate is YYYY-MM-DD
+	SettlementDate string `json:"settlementDate"`
+	SameDay        bool   `json:"sameDay"`
+}
+
+// EntryRemittance is the remittance data of a CCD or CTX entry parsed from its addenda. Addenda
+// carrying EDI 820 (or STP 820) segments fill in the structured fields.
+type EntryRemittance struct {
+	EntryID     string `json:"entryID,omitempty"`
+	TraceNumber string `json:"traceNumber"`
+	SECCode     string `json:"secCode"`
+	Amount      int    `json:"amount"`
+
+	// Payer and Payee are the names from N1*PR and N1*PE segments
+	Payer string `json:"payer,omitempty"`
+	Payee string `json:"payee,omitempty"`
+
+	// ReferenceNumber is the reassociation trace number of the TRN segment
+	ReferenceNumber string `json:"referenceNumber,omitempty"`
+
+	References []RemittanceReference `json:"references,omitempty"`
+	Invoices   []RemittanceInvoice   `json:"invoices,omitempty"`
+
+	// Text is every addenda's payment related information joined together
+	Text string `json:"text"`
+}
+
+// RemittanceInvoice is a document paid by the entry, read from an RMR segment. Amounts are in cents.
+type RemittanceInvoice struct {
+	// Qualifier is the type of Number, e.g. IV for an invoice or PO for a purchase order
+	Qualifier      string `json:"qualifier"`
+	Number         string `json:"number"`
+	PaidAmount     int    `json:"paidAmount"`
+	InvoiceAmount  int    `json:"invoiceAmount,omitempty"`
+	DiscountAmount int    `json:"discountAmount,omitempty"`
+
+	// Date is the invoice date (DTM*003) as YYYY-MM-DD
+	Date string `json:"date,omitempty"`
+
+	References []RemittanceReference `json:"references,omitempty"`
+}
+
+// RemittanceReference is read from a REF segment
+type RemittanceReference struct {
+	Qualifier   string `json:"qualifier"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
 }
@@ -20,11 +20,14 @@ package models
 import (
 	"bytes"
 	"encoding/json"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/moov-io/ach"
+	"github.com/moov-io/achgateway/internal/incoming"
+	"github.com/moov-io/achgateway/pkg/cpa005"
 	"github.com/moov-io/base"
 
 	"github.com/stretchr/testify/require"
@@ -60,6 +63,19 @@ func TestEvent(t *testing.T) {
 		ShardKey:   base.ID(),
 		UploadedAt: time.Now(),
 	}, `"type":"FileUploaded"`)
+
+	check(t, TreasuryExportFile{
+		Filename: "RECON_20231010.bai",
+		Format:   "bai2",
+		Contents: []byte("01,"),
+	}, `"type":"TreasuryExportFile"`, `"contents":"MDEs"`)
+
+	check(t, CutoffTakenOver{
+		ShardKey:       "live",
+		PreviousHolder: "achgateway-0",
+		Holder:         "achgateway-1",
+		FileIDs:        []string{base.ID()},
+	}, `"type":"CutoffTakenOver"`, `"previousHolder":"achgateway-0"`)
 }
 
 func TestRead(t *testing.T) {
@@ -83,6 +99,238 @@ func TestRead(t *testing.T) {
 	require.Equal(t, orig.ShardKey, cancel.ShardKey)
 }
 
+func TestRead__Metadata(t *testing.T) {
+	bs := (Event{
+		Event: FileUploaded{
+			FileID:   base.ID(),
+			ShardKey: "live",
+		},
+		Metadata: &EventMetadata{
+			ShardKey: "live",
+			Sequence: 42,
+		},
+	}).Bytes()
+	require.Contains(t, string(bs), `"metadata":{"shardKey":"live","sequence":42}`)
+
+	evt, err := Read(bs)
+	require.NoError(t, err)
+	require.Equal(t, "FileUploaded", evt.Type)
+	require.Equal(t, int64(42), evt.Metadata.Sequence)
+
+	uploaded, ok := evt.Event.(*FileUploaded)
+	require.True(t, ok)
+	require.Equal(t, "live", uploaded.ShardKey)
+
+	// Events without metadata leave it out
+	bs = (Event{Event: FileUploaded{ShardKey: "live"}}).Bytes()
+	require.NotContains(t, string(bs), "metadata")
+}
+
+func TestRead__FileRejected(t *testing.T) {
+	bs := (Event{
+		Event: FileRejected{
+			FileID:   base.ID(),
+			ShardKey: "live",
+			Reasons: []RejectionReason{
+				{Code: RejectionInvalidFile, Record: "EntryDetail", BatchNumber: 1, Field: "DFIAccountNumber"},
+			},
+			RejectedAt: time.Now(),
+		},
+	}).Bytes()
+
+	evt, err := Read(bs)
+	require.NoError(t, err)
+	require.Equal(t, "FileRejected", evt.Type)
+
+	rejected, ok := evt.Event.(*FileRejected)
+	require.True(t, ok)
+	require.Equal(t, "live", rejected.ShardKey)
+	require.Len(t, rejected.Reasons, 1)
+	require.Equal(t, "DFIAccountNumber", rejected.Reasons[0].Field)
+}
+
+func TestRead__FileRolledOver(t *testing.T) {
+	next := time.Now().Add(24 * time.Hour)
+	bs := (Event{
+		Event: FileRolledOver{
+			FileID:          base.ID(),
+			ShardKey:        "live",
+			CutoffStartedAt: time.Now(),
+			NextCutoff:      &next,
+		},
+	}).Bytes()
+
+	evt, err := Read(bs)
+	require.NoError(t, err)
+	require.Equal(t, "FileRolledOver", evt.Type)
+
+	rolled, ok := evt.Event.(*FileRolledOver)
+	require.True(t, ok)
+	require.Equal(t, "live", rolled.ShardKey)
+	require.NotNil(t, rolled.NextCutoff)
+}
+
+func TestRead__EntryAccepted(t *testing.T) {
+	bs := (Event{
+		Event: EntryAccepted{
+			FileID:          base.ID(),
+			ShardKey:        "live",
+			BatchNumber:     1,
+			SECCode:         "PPD",
+			TransactionCode: 27,
+			RoutingNumber:   "231380104",
+			AccountNumber:   "*****6789",
+			Amount:          12500,
+			TraceNumber:     "121042880000001",
+			AcceptedAt:      time.Now(),
+		},
+	}).Bytes()
+
+	evt, err := Read(bs)
+	require.NoError(t, err)
+	require.Equal(t, "EntryAccepted", evt.Type)
+
+	entry, ok := evt.Event.(*EntryAccepted)
+	require.True(t, ok)
+	require.Equal(t, "*****6789", entry.AccountNumber)
+	require.Equal(t, 12500, entry.Amount)
+}
+
+func TestRead__MicroEntryUpdated(t *testing.T) {
+	bs := (Event{
+		Event: MicroEntryUpdated{
+			MicroEntryID: "0123456789abcde",
+			ShardKey:     "live",
+			Status:       "returned",
+			ReturnCode:   "R03",
+			UpdatedAt:    time.Now(),
+		},
+	}).Bytes()
+
+	evt, err := Read(bs)
+	require.NoError(t, err)
+	require.Equal(t, "MicroEntryUpdated", evt.Type)
+
+	update, ok := evt.Event.(*MicroEntryUpdated)
+	require.True(t, ok)
+	require.Equal(t, "returned", update.Status)
+	require.Equal(t, "R03", update.ReturnCode)
+}
+
+func TestRead__RepresentmentUpdated(t *testing.T) {
+	bs := (Event{
+		Event: RepresentmentUpdated{
+			RepresentmentID:     "514aaa804bc0806590b02c33a4dd4c87882bbcd5",
+			ShardKey:            "live",
+			OriginalTraceNumber: "076401255655291",
+			Attempt:             1,
+			Status:              "scheduled",
+			ReturnCode:          "R01",
+			ScheduledFor:        time.Now().Add(48 * time.Hour),
+			UpdatedAt:           time.Now(),
+		},
+	}).Bytes()
+
+	evt, err := Read(bs)
+	require.NoError(t, err)
+	require.Equal(t, "RepresentmentUpdated", evt.Type)
+
+	update, ok := evt.Event.(*RepresentmentUpdated)
+	require.True(t, ok)
+	require.Equal(t, "scheduled", update.Status)
+	require.Equal(t, 1, update.Attempt)
+	require.Equal(t, "076401255655291", update.OriginalTraceNumber)
+}
+
+func TestRead__Deliveries(t *testing.T) {
+	file := DeliveryFile{
+		Sequence: 1,
+		Filename: "RET_20220601_1of2.ach",
+		DeliverySummary: DeliverySummary{
+			Entries: 2,
+			Returns: 2,
+		},
+	}
+	bs := (Event{
+		Event: DeliveryIncomplete{
+			DeliveryID: "20220601",
+			Expected:   2,
+			Missing:    []int{2},
+			Files:      []DeliveryFile{file},
+			Summary:    file.DeliverySummary,
+		},
+	}).Bytes()
+
+	evt, err := Read(bs)
+	require.NoError(t, err)
+	require.Equal(t, "DeliveryIncomplete", evt.Type)
+
+	incomplete, ok := evt.Event.(*DeliveryIncomplete)
+	require.True(t, ok)
+	require.Equal(t, []int{2}, incomplete.Missing)
+	require.Equal(t, 2, incomplete.Files[0].Returns)
+
+	bs = (Event{
+		Event: DeliveryCompleted{
+			DeliveryID: "20220601",
+			Files:      []DeliveryFile{file, file},
+		},
+	}).Bytes()
+
+	evt, err = Read(bs)
+	require.NoError(t, err)
+	completed, ok := evt.Event.(*DeliveryCompleted)
+	require.True(t, ok)
+	require.Len(t, completed.Files, 2)
+
+	var summary DeliverySummary
+	summary.Add(file.DeliverySummary)
+	summary.Add(file.DeliverySummary)
+	require.Equal(t, 4, summary.Entries)
+}
+
+func TestRead__CPA005(t *testing.T) {
+	fd, err := os.Open(filepath.Join("..", "cpa005", "testdata", "returns.005"))
+	require.NoError(t, err)
+	defer fd.Close()
+
+	file, err := cpa005.Read(fd)
+	require.NoError(t, err)
+
+	bs := (Event{
+		Event: incoming.CPA005File{
+			FileID:   base.ID(),
+			ShardKey: "canada",
+			File:     file,
+		},
+	}).Bytes()
+
+	evt, err := Read(bs)
+	require.NoError(t, err)
+	require.Equal(t, "CPA005File", evt.Type)
+
+	queued, ok := evt.Event.(*QueueCPA005File)
+	require.True(t, ok)
+	require.Equal(t, "canada", queued.ShardKey)
+	require.Equal(t, file.Header, queued.File.Header)
+	require.Len(t, queued.File.Transactions, 2)
+
+	bs = (Event{
+		Event: CPA005ReturnFile{
+			Filename: "returns.005",
+			File:     file,
+			Returns:  file.Transactions,
+		},
+	}).Bytes()
+
+	evt, err = Read(bs)
+	require.NoError(t, err)
+
+	returns, ok := evt.Event.(*CPA005ReturnFile)
+	require.True(t, ok)
+	require.Equal(t, "905", returns.Returns[0].InvalidDataElementID)
+}
+
 func TestPartialReconciliationFile(t *testing.T) {
 	file, err := ach.ReadFile(filepath.Join("testdata", "partial-recon.ach"))
 	require.NotNil(t, err)
@@ -108,3 +356,27 @@ func TestPartialReconciliationFile(t *testing.T) {
 	batches := evt.File.Batches
 	require.Len(t, batches, 2)
 }
+
+func TestRead__ODFIScanCompleted(t *testing.T) {
+	bs := (Event{
+		Event: ODFIScanCompleted{
+			ShardName:  "testing",
+			Hostname:   "ftp.bank.com",
+			Downloaded: 2,
+			Skipped: []SkippedFile{
+				{Path: "inbound/archive", Reason: "directory"},
+				{Path: "returned/RET_20220601.ach", Reason: "pattern_excluded", Processor: "return"},
+			},
+		},
+	}).Bytes()
+
+	evt, err := Read(bs)
+	require.NoError(t, err)
+	require.Equal(t, "ODFIScanCompleted", evt.Type)
+
+	scan, ok := evt.Event.(*ODFIScanCompleted)
+	require.True(t, ok)
+	require.Equal(t, 2, scan.Downloaded)
+	require.Len(t, scan.Skipped, 2)
+	require.Equal(t, "return", scan.Skipped[1].Processor)
+}
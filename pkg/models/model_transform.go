@@ -19,6 +19,7 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/moov-io/base/mask"
 )
@@ -34,17 +35,31 @@ type EncodingConfig struct {
 
 type EncryptionConfig struct {
 	AES *AESConfig
+
+	// PreviousAES are keys which are no longer used to encrypt messages but are still
+	// accepted when decrypting. Keep a rotated key here until every message encrypted
+	// with it has been consumed.
+	PreviousAES []*AESConfig
 }
 
 type AESConfig struct {
-	Key string
+	// KeyID is an optional identifier written alongside encrypted messages so the
+	// matching key can be found after rotation.
+	KeyID string
+	Key   string
 }
 
 func (cfg *AESConfig) MarshalJSON() ([]byte, error) {
 	type Aux struct {
-		Key string
+		KeyID string `json:",omitempty"`
+		Key   string
 	}
 	return json.Marshal(Aux{
-		Key: mask.Password(cfg.Key),
+		KeyID: cfg.KeyID,
+		Key:   mask.Password(cfg.Key),
 	})
 }
+
+func (cfg *AESConfig) String() string {
+	return fmt.Sprintf("AES{KeyID=%s, Key=%s}", cfg.KeyID, mask.Password(cfg.Key))
+}
@@ -0,0 +1,285 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package rdfi fabricates the return and notification of change (COR) files a receiving
+// bank would send back for an uploaded file. It's intended for testing return and
+// correction handling end-to-end without a bank.
+package rdfi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/moov-io/ach"
+)
+
+// Options controls which entries are responded to and how.
+type Options struct {
+	// Code is the return (e.g. R01) or change (e.g. C01) code used for each entry.
+	Code string
+
+	// TraceNumbers limits the response to these entries. Every entry is used when empty.
+	TraceNumbers []string
+
+	// CorrectedData is written into each Addenda98. Common change codes have made up
+	// defaults which are valid for the code.
+	CorrectedData string
+
+	// Now is used for file creation and effective entry dates. Defaults to time.Now()
+	Now time.Time
+}
+
+func (opts Options) now() time.Time {
+	if opts.Now.IsZero() {
+		return time.Now()
+	}
+	return opts.Now
+}
+
+func (opts Options) includes(traceNumber string) bool {
+	if len(opts.TraceNumbers) == 0 {
+		return true
+	}
+	for i := range opts.TraceNumbers {
+		if opts.TraceNumbers[i] == traceNumber {
+			return true
+		}
+	}
+	return false
+}
+
+// Return creates a file returning each entry of file with opts.Code (default R01).
+func Return(file *ach.File, opts Options) (*ach.File, error) {
+	if opts.Code == "" {
+		opts.Code = "R01"
+	}
+	if ach.LookupReturnCode(opts.Code) == nil {
+		return nil, fmt.Errorf("unknown return code %s", opts.Code)
+	}
+	return respond(file, opts, "", func(original *ach.EntryDetail, entry *ach.EntryDetail) error {
+		addenda := ach.NewAddenda99()
+		addenda.ReturnCode = opts.Code
+		addenda.OriginalTrace = original.TraceNumber
+		addenda.OriginalDFI = original.RDFIIdentification
+		addenda.TraceNumber = entry.TraceNumber
+		if opts.Code == "R14" || opts.Code == "R15" {
+			addenda.DateOfDeath = opts.now().AddDate(0, 0, -7).Format("060102")
+		}
+		entry.Category = ach.CategoryReturn
+		entry.Addenda99 = addenda
+		return nil
+	})
+}
+
+// Correct creates a notification of change file for each entry of file with opts.Code (default C01).
+func Correct(file *ach.File, opts Options) (*ach.File, error) {
+	if opts.Code == "" {
+		opts.Code = "C01"
+	}
+	if ach.LookupChangeCode(opts.Code) == nil {
+		return nil, fmt.Errorf("unknown change code %s", opts.Code)
+	}
+	return respond(file, opts, ach.COR, func(original *ach.EntryDetail, entry *ach.EntryDetail) error {
+		data := opts.CorrectedData
+		if data == "" {
+			var err error
+			data, err = defaultCorrectedData(opts.Code, original)
+			if err != nil {
+				return err
+			}
+		}
+		addenda := ach.NewAddenda98()
+		addenda.ChangeCode = opts.Code
+		addenda.OriginalTrace = original.TraceNumber
+		addenda.OriginalDFI = original.RDFIIdentification
+		addenda.CorrectedData = data
+		addenda.TraceNumber = entry.TraceNumber
+
+		entry.Amount = 0
+		entry.Category = ach.CategoryNOC
+		entry.Addenda98 = addenda
+		return nil
+	})
+}
+
+// correctedRoutingNumber is a valid routing number used in made up corrections
+const correctedRoutingNumber = "091000019"
+
+func defaultCorrectedData(code string, entry *ach.EntryDetail) (string, error) {
+	account := "12345678"
+	if entry.DFIAccountNumberField() == account {
+		account = "87654321"
+	}
+	txCode := swapAccountType(entry.TransactionCode)
+
+	var cd ach.CorrectedData
+	switch code {
+	case "C01":
+		cd.AccountNumber = account
+	case "C02":
+		cd.RoutingNumber = correctedRoutingNumber
+	case "C03":
+		cd.RoutingNumber, cd.AccountNumber = correctedRoutingNumber, account
+	case "C04":
+		cd.Name = "CORRECTED NAME"
+	case "C05":
+		cd.TransactionCode = txCode
+	case "C06":
+		cd.AccountNumber, cd.TransactionCode = account, txCode
+	case "C07":
+		cd.RoutingNumber, cd.AccountNumber, cd.TransactionCode = correctedRoutingNumber, account, txCode
+	case "C09":
+		cd.Identification = "CORRECTEDID"
+	default:
+		return "", fmt.Errorf("CorrectedData is required for %s", code)
+	}
+	return ach.WriteCorrectionData(code, &cd), nil
+}
+
+// swapAccountType returns the checking transaction code for savings codes and vice versa
+func swapAccountType(code int) int {
+	switch {
+	case code >= ach.CheckingCredit && code <= ach.CheckingPrenoteDebit:
+		return code + 10
+	case code >= ach.SavingsCredit && code <= ach.SavingsPrenoteDebit:
+		return code - 10
+	}
+	return code
+}
+
+// returnTransactionCode is the code used by the RDFI when returning or correcting an entry with code
+func returnTransactionCode(code int) (int, error) {
+	switch code {
+	case ach.CheckingCredit, ach.CheckingPrenoteCredit, ach.CheckingZeroDollarRemittanceCredit:
+		return ach.CheckingReturnNOCCredit, nil
+	case ach.CheckingDebit, ach.CheckingPrenoteDebit, ach.CheckingZeroDollarRemittanceDebit:
+		return ach.CheckingReturnNOCDebit, nil
+	case ach.SavingsCredit, ach.SavingsPrenoteCredit, ach.SavingsZeroDollarRemittanceCredit:
+		return ach.SavingsReturnNOCCredit, nil
+	case ach.SavingsDebit, ach.SavingsPrenoteDebit, ach.SavingsZeroDollarRemittanceDebit:
+		return ach.SavingsReturnNOCDebit, nil
+	case ach.GLCredit, ach.GLPrenoteCredit, ach.GLZeroDollarRemittanceCredit:
+		return ach.GLReturnNOCCredit, nil
+	case ach.GLDebit, ach.GLPrenoteDebit, ach.GLZeroDollarRemittanceDebit:
+		return ach.GLReturnNOCDebit, nil
+	case ach.LoanCredit, ach.LoanPrenoteCredit, ach.LoanZeroDollarRemittanceCredit:
+		return ach.LoanReturnNOCCredit, nil
+	case ach.LoanDebit:
+		return ach.LoanReturnNOCDebit, nil
+	}
+	return 0, fmt.Errorf("unable to respond to transaction code %d", code)
+}
+
+type addendaFunc func(original *ach.EntryDetail, entry *ach.EntryDetail) error
+
+// respond builds a file from the RDFI back to the originator with an entry for each
+// matching entry of file. The entry's addenda is set by addenda and batches are written
+// with secCode when it's non-empty.
+func respond(file *ach.File, opts Options, secCode string, addenda addendaFunc) (*ach.File, error) {
+	if file == nil {
+		return nil, errors.New("nil File")
+	}
+	now := opts.now()
+
+	out := ach.NewFile()
+	out.Header = ach.NewFileHeader()
+	out.Header.ImmediateDestination = file.Header.ImmediateOrigin
+	out.Header.ImmediateDestinationName = file.Header.ImmediateOriginName
+	out.Header.ImmediateOrigin = file.Header.ImmediateDestination
+	out.Header.ImmediateOriginName = file.Header.ImmediateDestinationName
+	out.Header.FileCreationDate = now.Format("060102")
+	out.Header.FileCreationTime = now.Format("1504")
+	out.Header.FileIDModifier = "A"
+	out.SetValidation(file.GetValidation())
+
+	for _, batch := range file.Batches {
+		bh := batch.GetHeader()
+
+		// Each RDFI sends its own batch back, so group entries by the RDFI they were sent to
+		var rdfis []string
+		entries := make(map[string][]*ach.EntryDetail)
+		for _, entry := range batch.GetEntries() {
+			if !opts.includes(entry.TraceNumber) {
+				continue
+			}
+			if _, exists := entries[entry.RDFIIdentification]; !exists {
+				rdfis = append(rdfis, entry.RDFIIdentification)
+			}
+			entries[entry.RDFIIdentification] = append(entries[entry.RDFIIdentification], entry)
+		}
+
+		for _, rdfi := range rdfis {
+			header := *bh
+			header.ID = ""
+			header.ODFIIdentification = rdfi
+			header.EffectiveEntryDate = now.Format("060102")
+			header.SettlementDate = ""
+			header.BatchNumber = len(out.Batches) + 1
+			if secCode != "" {
+				header.StandardEntryClassCode = secCode
+			}
+
+			b, err := ach.NewBatch(&header)
+			if err != nil {
+				return nil, fmt.Errorf("batch %s: %v", bh.ID, err)
+			}
+			for i, original := range entries[rdfi] {
+				entry, err := respondToEntry(bh.ODFIIdentification, rdfi, i+1, original)
+				if err != nil {
+					return nil, fmt.Errorf("entry %s: %v", original.TraceNumber, err)
+				}
+				if err := addenda(original, entry); err != nil {
+					return nil, fmt.Errorf("entry %s: %v", original.TraceNumber, err)
+				}
+				b.AddEntry(entry)
+			}
+			if err := b.Create(); err != nil {
+				return nil, fmt.Errorf("creating batch: %v", err)
+			}
+			out.AddBatch(b)
+		}
+	}
+	if len(out.Batches) == 0 {
+		return nil, errors.New("no matching entries found")
+	}
+	if err := out.Create(); err != nil {
+		return nil, fmt.Errorf("creating file: %v", err)
+	}
+	return out, nil
+}
+
+func respondToEntry(odfi, rdfi string, seq int, original *ach.EntryDetail) (*ach.EntryDetail, error) {
+	txCode, err := returnTransactionCode(original.TransactionCode)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := ach.NewEntryDetail()
+	entry.TransactionCode = txCode
+	entry.RDFIIdentification = odfi
+	entry.CheckDigit = strconv.Itoa(entry.CalculateCheckDigit(odfi))
+	entry.DFIAccountNumber = original.DFIAccountNumber
+	entry.Amount = original.Amount
+	entry.IdentificationNumber = original.IdentificationNumber
+	entry.IndividualName = original.IndividualName
+	entry.DiscretionaryData = original.DiscretionaryData
+	entry.AddendaRecordIndicator = 1
+	entry.SetTraceNumber(rdfi, seq)
+	return entry, nil
+}
@@ -0,0 +1,170 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package rdfi
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moov-io/ach"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readFile(t *testing.T) *ach.File {
+	t.Helper()
+
+	file, err := ach.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+	return file
+}
+
+// roundTrip writes file as Nacha and reads it back to ensure it's a valid file
+func roundTrip(t *testing.T, file *ach.File) *ach.File {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, ach.NewWriter(&buf).Write(file))
+
+	out, err := ach.NewReader(&buf).Read()
+	require.NoError(t, err)
+	return &out
+}
+
+func TestReturn(t *testing.T) {
+	original := readFile(t)
+	now := time.Date(2022, time.August, 12, 10, 30, 0, 0, time.UTC)
+
+	file, err := Return(original, Options{Code: "R03", Now: now})
+	require.NoError(t, err)
+	file = roundTrip(t, file)
+
+	require.Equal(t, original.Header.ImmediateOrigin, file.Header.ImmediateDestination)
+	require.Equal(t, original.Header.ImmediateDestination, file.Header.ImmediateOrigin)
+	require.Equal(t, "220812", file.Header.FileCreationDate)
+
+	require.Len(t, file.Batches, 1)
+	bh := file.Batches[0].GetHeader()
+	require.Equal(t, ach.PPD, bh.StandardEntryClassCode)
+	require.Equal(t, "05320001", bh.ODFIIdentification)
+	require.Equal(t, "220812", bh.EffectiveEntryDate)
+
+	entries := file.Batches[0].GetEntries()
+	require.Len(t, entries, 1)
+	require.Equal(t, ach.CheckingReturnNOCDebit, entries[0].TransactionCode)
+	require.Equal(t, "07640125", entries[0].RDFIIdentification)
+	require.Equal(t, 10500, entries[0].Amount)
+
+	addenda := entries[0].Addenda99
+	require.NotNil(t, addenda)
+	require.Equal(t, "R03", addenda.ReturnCode)
+	require.Equal(t, original.Batches[0].GetEntries()[0].TraceNumber, addenda.OriginalTrace)
+	require.Equal(t, "05320001", addenda.OriginalDFI)
+}
+
+func TestReturn__DateOfDeath(t *testing.T) {
+	file, err := Return(readFile(t), Options{Code: "R14"})
+	require.NoError(t, err)
+
+	addenda := file.Batches[0].GetEntries()[0].Addenda99
+	require.NotEmpty(t, addenda.DateOfDeath)
+}
+
+func TestReturn__Errors(t *testing.T) {
+	_, err := Return(nil, Options{})
+	require.ErrorContains(t, err, "nil File")
+
+	_, err = Return(readFile(t), Options{Code: "R99"})
+	require.ErrorContains(t, err, "unknown return code R99")
+
+	_, err = Return(readFile(t), Options{Code: "C01"})
+	require.ErrorContains(t, err, "unknown return code C01")
+
+	_, err = Return(readFile(t), Options{TraceNumbers: []string{"123"}})
+	require.ErrorContains(t, err, "no matching entries found")
+}
+
+func TestCorrect(t *testing.T) {
+	original := readFile(t)
+
+	cases := map[string]func(cd *ach.CorrectedData){
+		"C01": func(cd *ach.CorrectedData) { require.Equal(t, "12345678", cd.AccountNumber) },
+		"C02": func(cd *ach.CorrectedData) { require.Equal(t, correctedRoutingNumber, cd.RoutingNumber) },
+		"C03": func(cd *ach.CorrectedData) {
+			require.Equal(t, correctedRoutingNumber, cd.RoutingNumber)
+			require.Equal(t, "12345678", cd.AccountNumber)
+		},
+		"C04": func(cd *ach.CorrectedData) { require.Equal(t, "CORRECTED NAME", cd.Name) },
+		"C05": func(cd *ach.CorrectedData) { require.Equal(t, ach.SavingsDebit, cd.TransactionCode) },
+		"C06": func(cd *ach.CorrectedData) {
+			require.Equal(t, "12345678", cd.AccountNumber)
+			require.Equal(t, ach.SavingsDebit, cd.TransactionCode)
+		},
+		"C07": func(cd *ach.CorrectedData) {
+			require.Equal(t, correctedRoutingNumber, cd.RoutingNumber)
+			require.Equal(t, ach.SavingsDebit, cd.TransactionCode)
+		},
+		"C09": func(cd *ach.CorrectedData) { require.Equal(t, "CORRECTEDID", cd.Identification) },
+	}
+	for code, check := range cases {
+		t.Run(code, func(t *testing.T) {
+			file, err := Correct(original, Options{Code: code})
+			require.NoError(t, err)
+			file = roundTrip(t, file)
+
+			require.Len(t, file.Batches, 1)
+			require.Equal(t, ach.COR, file.Batches[0].GetHeader().StandardEntryClassCode)
+
+			entry := file.Batches[0].GetEntries()[0]
+			require.Equal(t, 0, entry.Amount)
+			require.Equal(t, ach.CheckingReturnNOCDebit, entry.TransactionCode)
+
+			addenda := entry.Addenda98
+			require.NotNil(t, addenda)
+			require.Equal(t, code, addenda.ChangeCode)
+			require.Equal(t, original.Batches[0].GetEntries()[0].TraceNumber, addenda.OriginalTrace)
+
+			cd := addenda.ParseCorrectedData()
+			require.NotNil(t, cd)
+			check(cd)
+		})
+	}
+}
+
+func TestCorrect__CorrectedData(t *testing.T) {
+	_, err := Correct(readFile(t), Options{Code: "C10"})
+	require.ErrorContains(t, err, "CorrectedData is required for C10")
+
+	file, err := Correct(readFile(t), Options{Code: "C10", CorrectedData: "NEW COMPANY NAME"})
+	require.NoError(t, err)
+	require.Equal(t, "NEW COMPANY NAME", file.Batches[0].GetEntries()[0].Addenda98.CorrectedData)
+
+	_, err = Correct(readFile(t), Options{Code: "R01"})
+	require.ErrorContains(t, err, "unknown change code R01")
+}
+
+func TestReturnTransactionCode(t *testing.T) {
+	code, err := returnTransactionCode(ach.SavingsCredit)
+	require.NoError(t, err)
+	require.Equal(t, ach.SavingsReturnNOCCredit, code)
+
+	_, err = returnTransactionCode(ach.CheckingReturnNOCDebit)
+	require.ErrorContains(t, err, "unable to respond to transaction code 26")
+}
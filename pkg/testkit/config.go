@@ -0,0 +1,199 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testkit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"text/template"
+)
+
+const (
+	// ShardName is the shard in configs created by NewConfig
+	ShardName = "testing"
+
+	// ShardKey is mapped to ShardName in configs created by NewConfig
+	ShardKey = "testing"
+)
+
+// Config describes an achgateway instance using testkit servers and streams.
+type Config struct {
+	AdminBindAddress string
+	HTTPBindAddress  string
+
+	// Directory holds merging and ODFI storage
+	Directory string
+
+	// Stream is consumed for inbound files when set
+	Stream *Stream
+
+	Shards []Shard
+
+	// Mappings are shard keys and the shard name files submitted with them are merged into
+	Mappings map[string]string
+
+	Agents []Agent
+}
+
+// Shard is a shard which uploads to UploadAgent at each cutoff window.
+type Shard struct {
+	Name        string
+	Timezone    string
+	Windows     []string
+	UploadAgent string
+}
+
+// Agent is an upload agent connected to one of FTP or SFTP.
+type Agent struct {
+	ID   string
+	FTP  *FTPServer
+	SFTP *SFTPServer
+}
+
+// NewConfig returns a config with the ShardName shard, which is mapped from ShardKey and
+// uploads to agent. Its cutoff is 17:00 in America/New_York and storage is under a temporary
+// directory. Fields can be changed before rendering the config.
+func NewConfig(t testing.TB, agent Agent) *Config {
+	t.Helper()
+
+	return &Config{
+		AdminBindAddress: ":9494",
+		HTTPBindAddress:  ":8484",
+		Directory:        t.TempDir(),
+		Shards: []Shard{
+			{
+				Name:        ShardName,
+				Timezone:    "America/New_York",
+				Windows:     []string{"17:00"},
+				UploadAgent: agent.ID,
+			},
+		},
+		Mappings: map[string]string{
+			ShardKey: ShardName,
+		},
+		Agents: []Agent{agent},
+	}
+}
+
+// YAML renders the config as an achgateway config file.
+func (cfg *Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := configTemplate.Execute(&buf, cfg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Write renders the config into a temporary file and returns its path, which can be set
+// as APP_CONFIG when starting achgateway.
+func (cfg *Config) Write(t testing.TB) string {
+	t.Helper()
+
+	bs, err := cfg.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	where := filepath.Join(t.TempDir(), "achgateway.yml")
+	if err := os.WriteFile(where, bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return where
+}
+
+var configTemplate = template.Must(template.New("config").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+	"join":  filepath.Join,
+}).Parse(`ACHGateway:
+  Admin:
+    BindAddress: {{ quote .AdminBindAddress }}
+  Inbound:
+    HTTP:
+      BindAddress: {{ quote .HTTPBindAddress }}
+{{- if .Stream }}
+    InMem:
+      URL: {{ quote .Stream.URL }}
+{{- end }}
+    ODFI:
+      Interval: "1m"
+      Processors:
+        Corrections:
+          Enabled: true
+        Incoming:
+          Enabled: true
+        Prenotes:
+          Enabled: true
+        Reconciliation:
+          Enabled: true
+        Returns:
+          Enabled: true
+      ShardNames:
+{{- range .Shards }}
+        - {{ quote .Name }}
+{{- end }}
+      Storage:
+        Directory: {{ quote (join .Directory "odfi") }}
+        CleanupLocalDirectory: true
+  Sharding:
+    Shards:
+{{- range .Shards }}
+      - Name: {{ quote .Name }}
+        Cutoffs:
+          Timezone: {{ quote .Timezone }}
+          Windows:
+{{- range .Windows }}
+            - {{ quote . }}
+{{- end }}
+        UploadAgent: {{ quote .UploadAgent }}
+{{- end }}
+{{- if .Mappings }}
+    Mappings:
+{{- range $key, $name := .Mappings }}
+      {{ quote $key }}:
+        ShardKey: {{ quote $key }}
+        ShardName: {{ quote $name }}
+{{- end }}
+{{- end }}
+  Upload:
+    Agents:
+{{- range .Agents }}
+      - ID: {{ quote .ID }}
+{{- if .FTP }}
+        FTP:
+          Hostname: {{ quote .FTP.Hostname }}
+          Username: {{ quote .FTP.Username }}
+          Password: {{ quote .FTP.Password }}
+{{- end }}
+{{- if .SFTP }}
+        SFTP:
+          Hostname: {{ quote .SFTP.Hostname }}
+          Username: {{ quote .SFTP.Username }}
+          Password: {{ quote .SFTP.Password }}
+          HostPublicKey: {{ quote .SFTP.HostPublicKey }}
+{{- end }}
+        Paths:
+          Inbound: "/inbound/"
+          Outbound: "/outbound/"
+          Reconciliation: "/reconciliation/"
+          Return: "/returned/"
+{{- end }}
+    Merging:
+      Directory: {{ quote (join .Directory "storage") }}
+`))
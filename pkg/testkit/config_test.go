@@ -0,0 +1,71 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testkit
+
+import (
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/base/config"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	ftp := NewFTPServer(t)
+	sftp := NewSFTPServer(t)
+	stream := NewStream(t)
+
+	cfg := NewConfig(t, Agent{ID: "ftp", FTP: ftp})
+	cfg.Stream = stream
+	cfg.Agents = append(cfg.Agents, Agent{ID: "sftp", SFTP: sftp})
+	cfg.Shards = append(cfg.Shards, Shard{
+		Name:        "sftp",
+		Timezone:    "UTC",
+		Windows:     []string{"10:30", "14:00"},
+		UploadAgent: "sftp",
+	})
+	cfg.Mappings["sftp-key"] = "sftp"
+
+	// Load the file as achgateway does when APP_CONFIG is set
+	t.Setenv(config.APP_CONFIG, cfg.Write(t))
+	global := &service.GlobalConfig{}
+	err := config.LoadEnvironmentFile(log.NewTestLogger(), config.APP_CONFIG, global)
+	require.NoError(t, err)
+
+	conf := &global.ACHGateway
+	require.NoError(t, conf.Validate())
+
+	require.Equal(t, stream.URL, conf.Inbound.InMem.URL)
+	require.Equal(t, []string{ShardName, "sftp"}, conf.Inbound.ODFI.ShardNames)
+
+	require.Len(t, conf.Sharding.Shards, 2)
+	require.Equal(t, ShardName, conf.Sharding.Shards[0].Name)
+	require.Equal(t, "America/New_York", conf.Sharding.Shards[0].Cutoffs.Timezone)
+	require.Equal(t, []string{"10:30", "14:00"}, conf.Sharding.Shards[1].Cutoffs.Windows)
+	require.Equal(t, "sftp", conf.Sharding.Shards[1].UploadAgent)
+
+	require.Equal(t, ShardName, conf.Sharding.Mappings[ShardKey].ShardName)
+	require.Equal(t, "sftp", conf.Sharding.Mappings["sftp-key"].ShardName)
+
+	require.Len(t, conf.Upload.Agents, 2)
+	require.Equal(t, ftp.Hostname, conf.Upload.Agents[0].FTP.Hostname)
+	require.Equal(t, "/outbound/", conf.Upload.Agents[0].Paths.Outbound)
+	require.Equal(t, sftp.HostPublicKey, conf.Upload.Agents[1].SFTP.HostPublicKey)
+}
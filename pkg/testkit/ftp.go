@@ -0,0 +1,100 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testkit
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"goftp.io/server"
+	"goftp.io/server/driver/file"
+)
+
+// FTPServer is an FTP server serving files from RootDir. It is shutdown when the test completes.
+type FTPServer struct {
+	// Hostname is host:port as used in the FTP upload agent config
+	Hostname string
+
+	Username string
+	Password string
+
+	// RootDir holds the inbound, outbound, reconciliation and returned directories
+	RootDir string
+
+	t testing.TB
+}
+
+// NewFTPServer starts an FTP server on a random local port.
+func NewFTPServer(t testing.TB) *FTPServer {
+	t.Helper()
+
+	root := t.TempDir()
+	makeAgentPaths(t, root)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	srv := &FTPServer{
+		Hostname: net.JoinHostPort("127.0.0.1", strconv.Itoa(port)),
+		Username: "achgateway",
+		Password: "password",
+		RootDir:  root,
+		t:        t,
+	}
+	svc := server.NewServer(&server.ServerOpts{
+		Auth: &server.SimpleAuth{
+			Name:     srv.Username,
+			Password: srv.Password,
+		},
+		Factory: &file.DriverFactory{
+			RootPath: root,
+			Perm:     server.NewSimplePerm("achgateway", "achgateway"),
+		},
+		Hostname: "127.0.0.1",
+		Port:     port,
+		Logger:   &server.DiscardLogger{},
+	})
+	go svc.Serve(listener)
+
+	// Closing the listener stops the server, Shutdown races with Serve setting up the server
+	t.Cleanup(func() { listener.Close() })
+
+	return srv
+}
+
+// Files returns the names of files in dir, such as OutboundPath.
+func (srv *FTPServer) Files(dir string) []string {
+	srv.t.Helper()
+	return listFiles(srv.t, srv.RootDir, dir)
+}
+
+// ReadFile returns the contents of a file, where path is relative to RootDir.
+func (srv *FTPServer) ReadFile(path string) []byte {
+	srv.t.Helper()
+	return readFile(srv.t, srv.RootDir, path)
+}
+
+// WriteFile places a file on the server, such as a return file in ReturnPath for ODFI processing.
+func (srv *FTPServer) WriteFile(path string, contents []byte) {
+	srv.t.Helper()
+	writeFile(srv.t, srv.RootDir, path, contents)
+}
@@ -0,0 +1,71 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testkit
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func uploadPPDDebit(t *testing.T, cfg service.UploadAgent) {
+	t.Helper()
+
+	agent, err := upload.New(log.NewTestLogger(), service.UploadAgents{
+		Agents: []service.UploadAgent{cfg},
+	}, cfg.ID)
+	require.NoError(t, err)
+	t.Cleanup(func() { agent.Close() })
+
+	bs, err := os.ReadFile(filepath.Join("..", "..", "testdata", "ppd-debit.ach"))
+	require.NoError(t, err)
+
+	err = agent.UploadFile(upload.File{
+		Filename: "ppd-debit.ach",
+		Contents: io.NopCloser(bytes.NewReader(bs)),
+	})
+	require.NoError(t, err)
+}
+
+func TestFTPServer(t *testing.T) {
+	srv := NewFTPServer(t)
+
+	uploadPPDDebit(t, service.UploadAgent{
+		ID: base.ID(),
+		FTP: &service.FTP{
+			Hostname: srv.Hostname,
+			Username: srv.Username,
+			Password: srv.Password,
+		},
+		Paths: service.UploadPaths{
+			Outbound: OutboundPath,
+		},
+	})
+
+	require.Equal(t, []string{"ppd-debit.ach"}, srv.Files(OutboundPath))
+	RequireGoldenACH(t, "ppd-debit.ach", srv.ReadFile("outbound/ppd-debit.ach"))
+}
@@ -0,0 +1,66 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testkit
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/ach"
+
+	"github.com/stretchr/testify/require"
+)
+
+// update rewrites golden files with the contents tests produce, run `go test -args -testkit.update`
+var update = flag.Bool("testkit.update", false, "rewrite golden files in testdata/")
+
+// RequireGolden fails the test when got differs from testdata/<name>. Tests rewrite the
+// golden file instead when run with -testkit.update.
+func RequireGolden(t testing.TB, name string, got []byte) {
+	t.Helper()
+
+	where := filepath.Join("testdata", filepath.FromSlash(name))
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(where), 0755))
+		require.NoError(t, os.WriteFile(where, got, 0644))
+		return
+	}
+	expected, err := os.ReadFile(where)
+	require.NoError(t, err, "run with -testkit.update to create %s", where)
+	require.Equal(t, string(expected), string(got), "%s differs, run with -testkit.update to rewrite it", where)
+}
+
+// RequireGoldenACH compares an uploaded ACH file with testdata/<name> after fixing the
+// file header's creation date and time, which change every run, to 000101 and 0000.
+func RequireGoldenACH(t testing.TB, name string, contents []byte) {
+	t.Helper()
+
+	file, err := ach.NewReader(bytes.NewReader(contents)).Read()
+	require.NoError(t, err)
+
+	file.Header.FileCreationDate = "000101"
+	file.Header.FileCreationTime = "0000"
+
+	var buf strings.Builder
+	require.NoError(t, ach.NewWriter(&buf).Write(&file))
+	RequireGolden(t, name, []byte(buf.String()))
+}
@@ -0,0 +1,284 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testkit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTPServer is an SFTP server serving files from RootDir which accepts password authentication.
+// It is shutdown when the test completes.
+type SFTPServer struct {
+	// Hostname is host:port as used in the SFTP upload agent config
+	Hostname string
+
+	Username string
+	Password string
+
+	// HostPublicKey is the server's key in authorized_keys format
+	HostPublicKey string
+
+	// RootDir holds the inbound, outbound, reconciliation and returned directories
+	RootDir string
+
+	t         testing.TB
+	listener  net.Listener
+	sshConfig *ssh.ServerConfig
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// NewSFTPServer starts an SFTP server on a random local port.
+func NewSFTPServer(t testing.TB) *SFTPServer {
+	t.Helper()
+
+	root := t.TempDir()
+	makeAgentPaths(t, root)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &SFTPServer{
+		Hostname:      listener.Addr().String(),
+		Username:      "achgateway",
+		Password:      "password",
+		HostPublicKey: strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))),
+		RootDir:       root,
+		t:             t,
+		listener:      listener,
+		conns:         make(map[net.Conn]struct{}),
+	}
+	srv.sshConfig = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == srv.Username && string(password) == srv.Password {
+				return nil, nil
+			}
+			return nil, errors.New("invalid username or password")
+		},
+	}
+	srv.sshConfig.AddHostKey(signer)
+
+	go srv.serve()
+	t.Cleanup(srv.shutdown)
+
+	return srv
+}
+
+// Files returns the names of files in dir, such as OutboundPath.
+func (srv *SFTPServer) Files(dir string) []string {
+	srv.t.Helper()
+	return listFiles(srv.t, srv.RootDir, dir)
+}
+
+// ReadFile returns the contents of a file, where path is relative to RootDir.
+func (srv *SFTPServer) ReadFile(path string) []byte {
+	srv.t.Helper()
+	return readFile(srv.t, srv.RootDir, path)
+}
+
+// WriteFile places a file on the server, such as a return file in ReturnPath for ODFI processing.
+func (srv *SFTPServer) WriteFile(path string, contents []byte) {
+	srv.t.Helper()
+	writeFile(srv.t, srv.RootDir, path, contents)
+}
+
+func (srv *SFTPServer) serve() {
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			return // closed during cleanup
+		}
+		go srv.handleConn(conn)
+	}
+}
+
+func (srv *SFTPServer) shutdown() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.closed = true
+	srv.listener.Close()
+	for conn := range srv.conns {
+		conn.Close()
+	}
+}
+
+func (srv *SFTPServer) track(conn net.Conn, add bool) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if add {
+		if srv.closed {
+			return false
+		}
+		srv.conns[conn] = struct{}{}
+	} else {
+		delete(srv.conns, conn)
+	}
+	return true
+}
+
+func (srv *SFTPServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+	if !srv.track(conn, true) {
+		return
+	}
+	defer srv.track(conn, false)
+
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, srv.sshConfig)
+	if err != nil {
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go srv.handleSession(channel, requests)
+	}
+}
+
+// handleSession serves the sftp subsystem, which is the only request accepted
+func (srv *SFTPServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		// The subsystem name is a length prefixed string
+		ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+		req.Reply(ok, nil)
+		if !ok {
+			continue
+		}
+
+		fs := rootFS(srv.RootDir)
+		server := sftp.NewRequestServer(channel, sftp.Handlers{
+			FileGet:  fs,
+			FilePut:  fs,
+			FileCmd:  fs,
+			FileList: fs,
+		})
+		server.Serve()
+		server.Close()
+		return
+	}
+}
+
+// rootFS serves a directory as the root of an SFTP session
+type rootFS string
+
+// resolve maps a path from the client onto the directory. Cleaning the path as
+// absolute removes any ".." which would escape it.
+func (fs rootFS) resolve(p string) string {
+	return filepath.Join(string(fs), filepath.FromSlash(path.Clean("/"+p)))
+}
+
+func (fs rootFS) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	return os.Open(fs.resolve(r.Filepath))
+}
+
+func (fs rootFS) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	return os.OpenFile(fs.resolve(r.Filepath), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+func (fs rootFS) Filecmd(r *sftp.Request) error {
+	switch r.Method {
+	case "Setstat":
+		return nil
+	case "Rename":
+		return os.Rename(fs.resolve(r.Filepath), fs.resolve(r.Target))
+	case "Rmdir", "Remove":
+		return os.Remove(fs.resolve(r.Filepath))
+	case "Mkdir":
+		return os.Mkdir(fs.resolve(r.Filepath), 0755)
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+func (fs rootFS) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	where := fs.resolve(r.Filepath)
+	switch r.Method {
+	case "List":
+		entries, err := os.ReadDir(where)
+		if err != nil {
+			return nil, err
+		}
+		var infos []os.FileInfo
+		for i := range entries {
+			info, err := entries[i].Info()
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+		return listerAt(infos), nil
+
+	case "Stat", "Lstat":
+		info, err := os.Stat(where)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt([]os.FileInfo{info}), nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(out []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(out, l[offset:])
+	if n < len(out) {
+		return n, io.EOF
+	}
+	return n, nil
+}
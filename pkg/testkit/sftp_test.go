@@ -0,0 +1,80 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testkit
+
+import (
+	"testing"
+
+	"github.com/moov-io/achgateway/internal/service"
+	"github.com/moov-io/achgateway/internal/upload"
+	"github.com/moov-io/base"
+	"github.com/moov-io/base/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSFTPServer(t *testing.T) {
+	srv := NewSFTPServer(t)
+
+	uploadPPDDebit(t, service.UploadAgent{
+		ID: base.ID(),
+		SFTP: &service.SFTP{
+			Hostname:      srv.Hostname,
+			Username:      srv.Username,
+			Password:      srv.Password,
+			HostPublicKey: srv.HostPublicKey,
+		},
+		Paths: service.UploadPaths{
+			Outbound: OutboundPath,
+		},
+	})
+
+	require.Equal(t, []string{"ppd-debit.ach"}, srv.Files(OutboundPath))
+	RequireGoldenACH(t, "ppd-debit.ach", srv.ReadFile("outbound/ppd-debit.ach"))
+}
+
+func TestSFTPServer__ReturnFiles(t *testing.T) {
+	srv := NewSFTPServer(t)
+	srv.WriteFile("returned/return.ach", []byte("return file"))
+
+	cfg := service.UploadAgent{
+		ID: base.ID(),
+		SFTP: &service.SFTP{
+			Hostname:      srv.Hostname,
+			Username:      srv.Username,
+			Password:      srv.Password,
+			HostPublicKey: srv.HostPublicKey,
+		},
+		Paths: service.UploadPaths{
+			Return: ReturnPath,
+		},
+	}
+	agent, err := upload.New(log.NewTestLogger(), service.UploadAgents{
+		Agents: []service.UploadAgent{cfg},
+	}, cfg.ID)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	files, err := agent.GetReturnFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "return.ach", files[0].Filename)
+
+	require.NoError(t, agent.Delete("returned/return.ach"))
+	require.Empty(t, srv.Files(ReturnPath))
+}
@@ -0,0 +1,68 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testkit
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"gocloud.dev/pubsub"
+	_ "gocloud.dev/pubsub/mempubsub"
+)
+
+// Stream is an in-memory topic and subscription. Configs rendered with the Stream have
+// achgateway consume files published to Topic and Subscription receives the same messages,
+// which only works when achgateway runs in the test's process.
+type Stream struct {
+	// URL is the mem:// address of the topic and subscription
+	URL string
+
+	Topic        *pubsub.Topic
+	Subscription *pubsub.Subscription
+}
+
+// NewStream opens a uniquely named in-memory stream. It is shutdown when the test completes.
+func NewStream(t testing.TB) *Stream {
+	t.Helper()
+
+	n, _ := rand.Int(rand.Reader, big.NewInt(10000))
+	url := fmt.Sprintf("mem://achgateway-testkit-%d", n)
+
+	ctx := context.Background()
+	topic, err := pubsub.OpenTopic(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := pubsub.OpenSubscription(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sub.Shutdown(ctx)
+		topic.Shutdown(ctx)
+	})
+
+	return &Stream{
+		URL:          url,
+		Topic:        topic,
+		Subscription: sub,
+	}
+}
@@ -0,0 +1,39 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testkit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/pubsub"
+)
+
+func TestStream(t *testing.T) {
+	stream := NewStream(t)
+
+	ctx := context.Background()
+	err := stream.Topic.Send(ctx, &pubsub.Message{Body: []byte("hello")})
+	require.NoError(t, err)
+
+	msg, err := stream.Subscription.Receive(ctx)
+	require.NoError(t, err)
+	msg.Ack()
+	require.Equal(t, "hello", string(msg.Body))
+}
@@ -0,0 +1,83 @@
+// Licensed to The Moov Authors under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. The Moov Authors licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package testkit helps applications write integration tests against achgateway. It starts
+// FTP and SFTP servers for upload agents, opens in-memory streams and renders achgateway
+// config files pointing at them. Golden files compare the ACH files achgateway uploads.
+package testkit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Paths are the directories created under each server's RootDir and used by agents
+// in rendered configs.
+const (
+	InboundPath        = "inbound"
+	OutboundPath       = "outbound"
+	ReconciliationPath = "reconciliation"
+	ReturnPath         = "returned"
+)
+
+var agentPaths = []string{InboundPath, OutboundPath, ReconciliationPath, ReturnPath}
+
+func makeAgentPaths(t testing.TB, root string) {
+	t.Helper()
+
+	for _, path := range agentPaths {
+		if err := os.MkdirAll(filepath.Join(root, path), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// listFiles returns the names of regular files in dir, which is relative to root
+func listFiles(t testing.TB, root, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(dir)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for i := range entries {
+		if entries[i].Type().IsRegular() {
+			out = append(out, entries[i].Name())
+		}
+	}
+	return out
+}
+
+func readFile(t testing.TB, root, path string) []byte {
+	t.Helper()
+
+	bs, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bs
+}
+
+func writeFile(t testing.TB, root, path string, contents []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(path)), contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+}